@@ -15,6 +15,11 @@
 //	Write: user@domain.com, joe@domain.com
 //	Delete: user@domain.com # This is a comment.
 //
+// A user may be named by the user's own name, by a wildcard for the
+// user's domain such as *@domain.com, or by "all". If these grant the
+// user different rights, the user has all of them. Servers may instead
+// let the most specific name win; see Access.CanMostSpecific.
+//
 // Each line of a Group file specifies a user or group
 // to be included in the group:
 // 	<user/group>
@@ -662,6 +667,9 @@ func (a *Access) rightGranted(requester upspin.UserName, right Right, pathName u
 // correct Access file to the question, and separately to verify
 // issues such as attempts to write to a directory rather than a file.
 //
+// When the Access file names the requester in more than one way, the
+// requester has the union of the rights granted to each name.
+//
 // The method loads Group files as needed by
 // calling the provided function to read each file's contents.
 //
@@ -669,6 +677,30 @@ func (a *Access) rightGranted(requester upspin.UserName, right Right, pathName u
 // reported only if the requester does not match any names that
 // can be found in the Access file or other Group files.
 func (a *Access) Can(requester upspin.UserName, right Right, pathName upspin.PathName, load func(upspin.PathName) ([]byte, error)) (bool, error) {
+	return a.can(requester, right, pathName, load, false)
+}
+
+// CanMostSpecific is like Can, but when the Access file names the
+// requester in more than one way, the most specific rule wins. Servers
+// that want these stricter semantics must ask for them by calling it.
+// In decreasing order of specificity, a user is named by the user's own name (or by a group the user owns), by a
+// wildcard for the user's domain such as *@example.com, and by "all".
+// A right granted to a less specific name is therefore withheld if a
+// more specific name is granted only other rights. For example, given
+//	read: *@example.com
+//	write: ann@example.com
+// ann@example.com may write but not read, while other users at
+// example.com may read. A user's rights are still the union of the
+// rights granted to names of equal specificity, so ann can both read and
+// write given "read: ann@example.com" and "write: ann@example.com".
+// Membership in a group is as specific as the entry that names the
+// user in that group.
+func (a *Access) CanMostSpecific(requester upspin.UserName, right Right, pathName upspin.PathName, load func(upspin.PathName) ([]byte, error)) (bool, error) {
+	return a.can(requester, right, pathName, load, true)
+}
+
+// can implements Can and, if mostSpecific is set, CanMostSpecific.
+func (a *Access) can(requester upspin.UserName, right Right, pathName upspin.PathName, load func(upspin.PathName) ([]byte, error), mostSpecific bool) (bool, error) {
 
 	parsedRequester, err := path.Parse(upspin.PathName(requester + "/"))
 	if err != nil {
@@ -688,20 +720,59 @@ func (a *Access) Can(requester upspin.UserName, right Right, pathName upspin.Pat
 		return granted, err
 	}
 
+	spec, err := specificityOf(requesterUserName, domain, group, load, mostSpecific)
+	if !mostSpecific || spec == noMatch || spec == matchUser || right == AnyRight {
+		return spec != noMatch, err
+	}
+	// The requester was granted the right by a wildcard or by "all".
+	// A more specific rule for another right overrides it.
+	for r := Read; r < numRights; r++ {
+		if r == right {
+			continue
+		}
+		if other, _ := specificityOf(requesterUserName, domain, a.list[r], load, true); other > spec {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// specificity records how specifically an entry in an Access or Group
+// file names a user. Larger values are more specific.
+type specificity int
+
+const (
+	noMatch     specificity = iota
+	matchAll                // The entry is "all".
+	matchDomain             // The entry is a wildcard such as *@example.com.
+	matchUser               // The entry is the user's name or a group the user owns.
+)
+
+// specificityOf reports how specifically the requester is named in the list of
+// users and groups, loading Group files as necessary. It returns noMatch
+// and the first error loading a Group file if the requester is not
+// named at all. Unless mostSpecific is set, it returns as soon as the
+// requester is found, however specifically.
+func specificityOf(requesterUserName upspin.UserName, domain string, group []path.Parsed, load func(upspin.PathName) ([]byte, error), mostSpecific bool) (specificity, error) {
 	// The groups graph is traversed depth-first, always preferring to check
 	// loaded groups first.
 
 	var groupsToCheck iter
 	var missing []path.Parsed
 	var groupErr error
+	var err error
+	best := noMatch
 
 	for len(group) > 0 {
-		// The loop searches lists to find whether the requester is represented
-		// in the group graph.
-
-		granted = inGroup(requesterUserName, domain, group, &groupsToCheck)
-		if granted {
-			return true, nil
+		// The loop searches lists to find how specifically the requester
+		// is represented in the group graph.
+
+		if spec := inGroup(requesterUserName, domain, group, &groupsToCheck); spec > best {
+			best = spec
+			if best == matchUser || !mostSpecific {
+				// Nothing is more specific, or it does not matter.
+				return best, nil
+			}
 		}
 
 		// Until a non-empty group is found, iterate through groupsToCheck,
@@ -738,41 +809,49 @@ func (a *Access) Can(requester upspin.UserName, right Right, pathName upspin.Pat
 			}
 		}
 	}
-	return false, groupErr
+	if best != noMatch {
+		return best, nil
+	}
+	return noMatch, groupErr
 }
 
-// inGroup reports whether the requester is present in the group, either
-// directly, by wildcard, by being the owner of a nested group, or virtually by
-// finding the allUsersParsed id in the list. Any nested groups encountered
-// before ascertaining an answer get included in the set of groupsToCheck.
-func inGroup(requesterUserName upspin.UserName, domain string, group []path.Parsed, groupsToCheck *iter) bool {
+// inGroup reports how specifically the requester is named in the group,
+// either directly, by wildcard, by being the owner of a nested group, or
+// virtually by finding the allUsersParsed id in the list. Any nested groups
+// encountered before finding the requester by name get included in the
+// set of groupsToCheck.
+func inGroup(requesterUserName upspin.UserName, domain string, group []path.Parsed, groupsToCheck *iter) specificity {
+	best := noMatch
 	for _, member := range group {
 		memberUserName := member.User()
 		if member.IsRoot() {
 			// A user id
 			// Simple test for AllUsers, granting universal access.
 			if member == allUsersParsed {
-				return true
+				if best < matchAll {
+					best = matchAll
+				}
+				continue
 			}
 
 			if memberUserName == requesterUserName {
-				return true
+				return matchUser
 			}
 			// Wildcard: The path name *@domain.com matches anyone in domain.
 			if strings.HasPrefix(string(memberUserName), "*@") && string(memberUserName[2:]) == domain {
-				return true
+				best = matchDomain
 			}
 		} else {
 			// A nested group
 			if memberUserName == requesterUserName {
 				// The owner of a group is automatically a member of the group.
 				// No need to see that the group can even be loaded.
-				return true
+				return matchUser
 			}
 			groupsToCheck.add(member)
 		}
 	}
-	return false
+	return best
 }
 
 // loadAndAdd returns the group having loaded the file and calling AddGroup on the result.
@@ -486,6 +486,108 @@ func TestAccessAllUsers(t *testing.T) {
 	check("someone@obscure.com", Write, "me@here.com/foo/bar", false)
 }
 
+// TestSpecificity tests that when rules name a user in different ways,
+// Can grants the union of their rights while with CanMostSpecific the
+// most specific rule wins: the user's own name, then a domain wildcard,
+// then "all".
+func TestSpecificity(t *testing.T) {
+	resetGroupsCache()
+
+	const accessText = "r: all\n" +
+		"l: *@example.com\n" +
+		"w: ann@example.com\n" +
+		"c: *@group.com, ann@example.com\n" +
+		"d: admins\n"
+
+	loadTest := func(name upspin.PathName) ([]byte, error) {
+		switch name {
+		case "me@here.com/Group/admins":
+			return []byte("root@group.com\n*@admin.com\n"), nil
+		default:
+			return nil, errors.Errorf("%s not found", name)
+		}
+	}
+
+	a, err := Parse(testFile, []byte(accessText))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range []struct {
+		user  upspin.UserName
+		right Right
+		want  bool
+	}{
+		// Named explicitly for write and create, so the wildcard
+		// grant of list and the grant of read to all do not apply.
+		{"ann@example.com", Write, true},
+		{"ann@example.com", Create, true},
+		{"ann@example.com", List, false},
+		{"ann@example.com", Read, false},
+		{"ann@example.com", Delete, false},
+		{"ann@example.com", AnyRight, true},
+
+		// Named by domain wildcard for list, which overrides all.
+		{"bob@example.com", List, true},
+		{"bob@example.com", Read, false},
+		{"bob@example.com", Write, false},
+
+		// A suffixed user is a distinct user, named here by the wildcard.
+		{"ann+snapshot@example.com", List, true},
+		{"ann+snapshot@example.com", Write, false},
+		{"ann+snapshot@example.com", Read, false},
+
+		// Named only by all.
+		{"joe@other.com", Read, true},
+		{"joe@other.com", List, false},
+		{"joe@other.com", AnyRight, true},
+
+		// Rights granted at equal specificity are additive:
+		// both are wildcards, one through a group.
+		{"pat@group.com", Create, true},
+		{"pat@group.com", Read, false},
+		{"sue@admin.com", Delete, true},
+		{"sue@admin.com", Read, false},
+
+		// Named explicitly in a group, which outranks the
+		// wildcard for create.
+		{"root@group.com", Delete, true},
+		{"root@group.com", Create, false},
+		{"root@group.com", Read, false},
+	} {
+		got, err := a.CanMostSpecific(test.user, test.right, "me@here.com/foo/bar", loadTest)
+		if err != nil {
+			t.Errorf("%s %s: %v", test.user, test.right, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("CanMostSpecific(%s, %s) = %t; want %t", test.user, test.right, got, test.want)
+		}
+	}
+
+	// By default, rights are additive.
+	for _, test := range []struct {
+		user  upspin.UserName
+		right Right
+	}{
+		{"ann@example.com", Read},
+		{"ann@example.com", List},
+		{"ann@example.com", Write},
+		{"bob@example.com", Read},
+		{"root@group.com", Create},
+		{"root@group.com", Read},
+	} {
+		got, err := a.Can(test.user, test.right, "me@here.com/foo/bar", loadTest)
+		if err != nil {
+			t.Errorf("%s %s: %v", test.user, test.right, err)
+			continue
+		}
+		if !got {
+			t.Errorf("Can(%s, %s) = false; want true", test.user, test.right)
+		}
+	}
+}
+
 func TestGroupDisallowsAll(t *testing.T) {
 	parsed, err := path.Parse("me@here.com/Group/meAndAllElse")
 	if err != nil {
@@ -0,0 +1,167 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bind
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// ReadAffinityPolicy orders the replicas of a directory server so that
+// reads go to the nearest reachable one. Replicas are ranked by the
+// round-trip time of a Ping; rankings are recomputed after Refresh has
+// passed or when the preferred replica becomes unreachable.
+type ReadAffinityPolicy struct {
+	// Ping probes the directory server at the endpoint and returns
+	// an error if it cannot be reached.
+	Ping func(cc upspin.Config, e upspin.Endpoint) error
+
+	// Refresh is how long a ranking is used before it is recomputed.
+	Refresh time.Duration
+
+	mu       sync.Mutex
+	rankings map[string]*ranking // Keyed by endpointsKey.
+}
+
+// ranking records replicas in order of preference and when that
+// order was determined.
+type ranking struct {
+	order []upspin.Endpoint
+	when  time.Time
+}
+
+// NewReadAffinityPolicy returns a ReadAffinityPolicy that uses
+// PingDirServer and recomputes its rankings every five minutes.
+func NewReadAffinityPolicy() *ReadAffinityPolicy {
+	return &ReadAffinityPolicy{
+		Ping:    PingDirServer,
+		Refresh: 5 * time.Minute,
+	}
+}
+
+var (
+	affinityMu   sync.Mutex
+	readAffinity = NewReadAffinityPolicy()
+)
+
+// SetReadAffinityPolicy sets the policy used by ReadDirServerFor to
+// choose among directory server replicas. A nil policy disables ranking, in
+// which case replicas are tried in the order they are listed.
+func SetReadAffinityPolicy(p *ReadAffinityPolicy) {
+	affinityMu.Lock()
+	readAffinity = p
+	affinityMu.Unlock()
+}
+
+func readAffinityPolicy() *ReadAffinityPolicy {
+	affinityMu.Lock()
+	defer affinityMu.Unlock()
+	return readAffinity
+}
+
+// PingDirServer probes the directory server at e with a Lookup of the
+// root of the config's user. Only a network failure counts as the
+// server being unreachable; other errors, such as the root not
+// existing, show that the server answered.
+func PingDirServer(cc upspin.Config, e upspin.Endpoint) error {
+	dir, err := DirServer(cc, e)
+	if err != nil {
+		return err
+	}
+	_, err = dir.Lookup(upspin.PathName(cc.UserName()) + "/")
+	if errors.Is(errors.IO, err) {
+		return err
+	}
+	return nil
+}
+
+// Rank returns the endpoints ordered by preference, nearest reachable
+// replica first. Unreachable replicas are placed last, in their
+// original order.
+func (p *ReadAffinityPolicy) Rank(cc upspin.Config, eps []upspin.Endpoint) []upspin.Endpoint {
+	if p == nil || len(eps) < 2 {
+		return eps
+	}
+	key := endpointsKey(eps)
+	p.mu.Lock()
+	r, ok := p.rankings[key]
+	p.mu.Unlock()
+	if ok && time.Since(r.when) < p.Refresh {
+		return r.order
+	}
+
+	r = &ranking{
+		order: p.probe(cc, eps),
+		when:  time.Now(),
+	}
+	p.mu.Lock()
+	if p.rankings == nil {
+		p.rankings = make(map[string]*ranking)
+	}
+	p.rankings[key] = r
+	p.mu.Unlock()
+	return r.order
+}
+
+// Invalidate discards the ranking for the endpoints, so that the next
+// call to Rank probes them again.
+func (p *ReadAffinityPolicy) Invalidate(eps []upspin.Endpoint) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	delete(p.rankings, endpointsKey(eps))
+	p.mu.Unlock()
+}
+
+// probe pings all the endpoints in parallel and returns them sorted
+// by round-trip time.
+func (p *ReadAffinityPolicy) probe(cc upspin.Config, eps []upspin.Endpoint) []upspin.Endpoint {
+	rtt := make([]time.Duration, len(eps))
+	var wg sync.WaitGroup
+	for i, e := range eps {
+		wg.Add(1)
+		go func(i int, e upspin.Endpoint) {
+			defer wg.Done()
+			start := time.Now()
+			if err := p.Ping(cc, e); err != nil {
+				rtt[i] = -1
+				return
+			}
+			rtt[i] = time.Since(start)
+		}(i, e)
+	}
+	wg.Wait()
+
+	idx := make([]int, len(eps))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		ra, rb := rtt[idx[a]], rtt[idx[b]]
+		if ra < 0 || rb < 0 {
+			return rb < 0 && ra >= 0
+		}
+		return ra < rb
+	})
+	order := make([]upspin.Endpoint, len(eps))
+	for i, j := range idx {
+		order[i] = eps[j]
+	}
+	return order
+}
+
+func endpointsKey(eps []upspin.Endpoint) string {
+	s := make([]string, len(eps))
+	for i, e := range eps {
+		s[i] = e.String()
+	}
+	return strings.Join(s, " ")
+}
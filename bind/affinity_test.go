@@ -0,0 +1,122 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package bind
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/test/testfixtures"
+	"upspin.io/upspin"
+)
+
+func TestReadAffinityRank(t *testing.T) {
+	far := upspin.Endpoint{Transport: upspin.Remote, NetAddr: "far.example.com:443"}
+	near := upspin.Endpoint{Transport: upspin.Remote, NetAddr: "near.example.com:443"}
+	down := upspin.Endpoint{Transport: upspin.Remote, NetAddr: "down.example.com:443"}
+
+	var mu sync.Mutex
+	pings := 0
+	p := &ReadAffinityPolicy{
+		Ping: func(_ upspin.Config, e upspin.Endpoint) error {
+			mu.Lock()
+			pings++
+			mu.Unlock()
+			switch e {
+			case far:
+				time.Sleep(50 * time.Millisecond)
+			case down:
+				return errors.E(errors.IO, "unreachable")
+			}
+			return nil
+		},
+		Refresh: time.Hour,
+	}
+
+	eps := []upspin.Endpoint{down, far, near}
+	want := []upspin.Endpoint{near, far, down}
+	if got := p.Rank(nil, eps); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Rank = %v, want %v", got, want)
+	}
+	if pings != 3 {
+		t.Fatalf("pings = %d, want 3", pings)
+	}
+
+	// The ranking is cached until invalidated.
+	p.Rank(nil, eps)
+	if pings != 3 {
+		t.Fatalf("after second Rank, pings = %d, want 3", pings)
+	}
+	p.Invalidate(eps)
+	if got := p.Rank(nil, eps); !reflect.DeepEqual(got, want) {
+		t.Fatalf("Rank after Invalidate = %v, want %v", got, want)
+	}
+	if pings != 6 {
+		t.Fatalf("after Invalidate, pings = %d, want 6", pings)
+	}
+}
+
+// replicaConfig is a Config that lists replicas of the user's DirServer.
+type replicaConfig struct {
+	upspin.Config
+	replicas []upspin.Endpoint
+}
+
+func (c replicaConfig) DirEndpoint() upspin.Endpoint    { return c.replicas[0] }
+func (c replicaConfig) DirEndpoints() []upspin.Endpoint { return c.replicas }
+
+func TestReadDirServerFor(t *testing.T) {
+	primary := upspin.Endpoint{Transport: upspin.Remote, NetAddr: "primary.example.com:443"}
+	replica := upspin.Endpoint{Transport: upspin.Remote, NetAddr: "replica.example.com:443"}
+	if err := RegisterDirServer(upspin.Remote, &dummyDirServer{}); err != nil {
+		t.Fatal(err)
+	}
+	pings := 0
+	SetReadAffinityPolicy(&ReadAffinityPolicy{
+		Ping: func(_ upspin.Config, e upspin.Endpoint) error {
+			pings++
+			if e == primary {
+				time.Sleep(50 * time.Millisecond)
+			}
+			return nil
+		},
+		Refresh: time.Hour,
+	})
+	defer SetReadAffinityPolicy(NewReadAffinityPolicy())
+
+	const me = upspin.UserName("ann@example.com")
+	cfg := replicaConfig{
+		Config:   testfixtures.NewSimpleConfig(me),
+		replicas: []upspin.Endpoint{primary, replica},
+	}
+
+	// Reads of the user's own tree go to the nearest replica.
+	dir, err := ReadDirServerFor(cfg, me)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dir.Endpoint(); got != replica {
+		t.Errorf("ReadDirServerFor(%q) = %v, want %v", me, got, replica)
+	}
+	if pings != 2 {
+		t.Errorf("pings = %d, want 2", pings)
+	}
+
+	// Everything else goes to the primary, without probing replicas.
+	dir, err = DirServerFor(cfg, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := dir.Endpoint(); got != primary {
+		t.Errorf("DirServerFor(\"\") = %v, want %v", got, primary)
+	}
+	ReadDirServerFor(cfg, "bob@example.com") // May fail; there is no key server.
+	if pings != 2 {
+		t.Errorf("after other requests, pings = %d, want 2", pings)
+	}
+}
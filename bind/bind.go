@@ -142,6 +142,40 @@ func DirServerFor(cc upspin.Config, userName upspin.UserName) (upspin.DirServer,
 
 }
 
+// ReadDirServerFor is like DirServerFor but returns a DirServer to be
+// used only to read, as by Lookup or Glob. If the user is the config's
+// user and the config lists replicas of the user's DirServer (see
+// upspin.DirReplicaConfig), it returns the nearest reachable replica,
+// as ranked by the read affinity policy. Otherwise it returns the same
+// as DirServerFor.
+func ReadDirServerFor(cc upspin.Config, userName upspin.UserName) (upspin.DirServer, error) {
+	const op errors.Op = "bind.ReadDirServerFor"
+	r, ok := cc.(upspin.DirReplicaConfig)
+	if !ok || userName != cc.UserName() {
+		return DirServerFor(cc, userName)
+	}
+	replicas := r.DirEndpoints()
+	policy := readAffinityPolicy()
+	var firstErr error
+	for i, e := range policy.Rank(cc, replicas) {
+		d, err := DirServer(cc, e)
+		if err == nil {
+			return d, nil
+		}
+		if i == 0 {
+			// The preferred replica is unreachable; rank again next time.
+			policy.Invalidate(replicas)
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	if firstErr != nil {
+		return nil, errors.E(op, firstErr)
+	}
+	return nil, errors.E(op, userName, "no directory endpoints found")
+}
+
 func (s *servers) register(transport upspin.Transport, key upspin.Dialer) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
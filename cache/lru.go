@@ -25,12 +25,16 @@ type EvictionNotifier interface {
 }
 
 // LRU is a least-recently used cache, safe for concurrent access.
+//
+// An entry may be pinned, which prevents its eviction until it is unpinned.
+// A cache whose entries are all pinned may grow beyond its maximum size.
 type LRU struct {
 	maxEntries int
 
 	mu    sync.Mutex
 	ll    *list.List
 	cache map[interface{}]*list.Element
+	pins  map[interface{}]int // Count of Pins for each pinned key.
 }
 
 // *entry is the type stored in each *list.Element.
@@ -46,6 +50,7 @@ func NewLRU(maxEntries int) *LRU {
 		maxEntries: maxEntries,
 		ll:         list.New(),
 		cache:      make(map[interface{}]*list.Element),
+		pins:       make(map[interface{}]int),
 	}
 }
 
@@ -67,7 +72,8 @@ func (c *LRU) Add(key, value interface{}) {
 	c.cache[key] = ele
 
 	if c.ll.Len() > c.maxEntries {
-		c.removeOldest(runEvictionNotifier)
+		// Never evict the new entry, even if all the others are pinned.
+		c.removeOldest(runEvictionNotifier, ele)
 	}
 }
 
@@ -83,13 +89,13 @@ func (c *LRU) Get(key interface{}) (value interface{}, ok bool) {
 	return
 }
 
-// RemoveOldest removes the oldest item in the cache and returns its key and
-// value. If the cache is empty, the empty string and nil are returned. The
+// RemoveOldest removes the oldest unpinned item in the cache and returns its
+// key and value. If there is no such item, nil and nil are returned. The
 // value's EvictionNotifier is not run.
 func (c *LRU) RemoveOldest() (key, value interface{}) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
-	return c.removeOldest(!runEvictionNotifier)
+	return c.removeOldest(!runEvictionNotifier, nil)
 }
 
 // Remove removes a key from the cache. The return value is the value that was
@@ -105,9 +111,34 @@ func (c *LRU) Remove(key interface{}) interface{} {
 	return nil
 }
 
+// Pin prevents the entry for key from being evicted until a matching call to
+// Unpin. Pins are counted, so a key pinned twice must be unpinned twice.
+// The key need not yet be in the cache; if it is added later it will be
+// pinned. Remove removes an entry whether or not it is pinned.
+func (c *LRU) Pin(key interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.pins[key]++
+}
+
+// Unpin undoes a call to Pin. It is a no-op if key is not pinned.
+func (c *LRU) Unpin(key interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n := c.pins[key]; n > 1 {
+		c.pins[key] = n - 1
+	} else {
+		delete(c.pins, key)
+	}
+}
+
+// removeOldest removes the oldest unpinned element other than except.
 // note: must hold c.mu
-func (c *LRU) removeOldest(runEvictionNotifier bool) (key, value interface{}) {
+func (c *LRU) removeOldest(runEvictionNotifier bool, except *list.Element) (key, value interface{}) {
 	ele := c.ll.Back()
+	for ele != nil && (ele == except || c.pins[ele.Value.(*entry).key] > 0) {
+		ele = ele.Prev()
+	}
 	if ele == nil {
 		return
 	}
@@ -103,6 +103,49 @@ func TestRemoveOldest(t *testing.T) {
 	}
 }
 
+func TestPin(t *testing.T) {
+	c := cache.NewLRU(2)
+	c.Pin("1")
+	c.Pin("1")
+	c.Add("1", "one")
+	c.Add("2", "two")
+	c.Add("3", "three")
+	if _, ok := c.Get("1"); !ok {
+		t.Errorf("pinned entry 1 was evicted")
+	}
+	if _, ok := c.Get("2"); ok {
+		t.Errorf("unpinned entry 2 was not evicted")
+	}
+
+	// When all entries are pinned, the cache grows.
+	c.Pin("3")
+	c.Add("4", "four")
+	if c.Len() != 3 {
+		t.Errorf("Len = %d, want 3", c.Len())
+	}
+	if k, v := c.RemoveOldest(); k != "4" || v != "four" {
+		t.Errorf("oldest = %q, %q; want 4, four", k, v)
+	}
+	if k, v := c.RemoveOldest(); k != nil || v != nil {
+		t.Errorf("oldest = %v, %v; want nil, nil", k, v)
+	}
+
+	// Pins are counted.
+	c.Unpin("1")
+	if k, _ := c.RemoveOldest(); k != nil {
+		t.Errorf("oldest = %v, want nil", k)
+	}
+	c.Unpin("1")
+	if k, _ := c.RemoveOldest(); k != "1" {
+		t.Errorf("oldest = %v, want 1", k)
+	}
+	c.Unpin("1") // Not pinned; no-op.
+	c.Unpin("3")
+	if k, _ := c.RemoveOldest(); k != "3" {
+		t.Errorf("oldest = %v, want 3", k)
+	}
+}
+
 func TestRemoveOne(t *testing.T) {
 	c := cache.NewLRU(10)
 	c.Add("1", "one")
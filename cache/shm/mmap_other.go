@@ -0,0 +1,31 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build windows plan9
+
+package shm
+
+import (
+	"os"
+
+	"upspin.io/errors"
+)
+
+var errNotSupported = errors.Str("shared memory cache not supported on this platform")
+
+func mapFile(f *os.File, size int) ([]byte, error) {
+	return nil, errNotSupported
+}
+
+func unmapFile(b []byte) error {
+	return nil
+}
+
+func lockFile(f *os.File) error {
+	return errNotSupported
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
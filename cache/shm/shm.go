@@ -0,0 +1,325 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package shm implements a block cache held in memory shared by all the
+// processes on a machine that open it.
+//
+// The cache is kept in two memory-mapped files. The index file holds a
+// header and a fixed number of slots, each one cache line long, that map
+// the SHA-256 hash of a key to the offset, size and checksum of a block in
+// the data file. The data file is a ring buffer: blocks are appended at a
+// cursor that wraps around, overwriting the oldest blocks, so the cache
+// approximates a FIFO rather than a true LRU.
+//
+// No locks are held while the cache is in use. A writer reserves space in
+// the ring by advancing the cursor with compare-and-swap and claims a slot
+// by making its sequence number odd. A reader checks that the slot's
+// sequence number was even and unchanged while it copied the block, that
+// the cursor has not since lapped the block, and that the block's checksum
+// matches. Any failure is treated as a miss, so a process that dies while
+// writing can at worst leave one slot unusable.
+package shm // import "upspin.io/cache/shm"
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash/crc64"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"unsafe"
+
+	"upspin.io/errors"
+)
+
+const (
+	indexName = "shm.index"
+	dataName  = "shm.data"
+
+	// magic identifies an index file in this format.
+	magic = 0x75707370696e6331 // "upspinc1"
+
+	// lineSize is the size of a cache line, the unit of the index.
+	lineSize = 64
+
+	// minSlots is the minimum number of slots in a new index.
+	minSlots = 1024
+
+	// bytesPerSlot is the expected size of a block, used to decide
+	// how many slots to allocate for a given amount of data.
+	bytesPerSlot = 16 * 1024
+)
+
+// Offsets of the fields of the header, which is the first line of the index.
+const (
+	hdrMagic    = 0
+	hdrSlots    = 8
+	hdrDataSize = 16
+	hdrCursor   = 24
+)
+
+// Offsets of the fields of a slot.
+const (
+	slotSeq    = 0
+	slotOffset = 8
+	slotSize   = 16
+	slotSum    = 24
+	slotKey    = 32 // sha256.Size bytes.
+)
+
+var crcTable = crc64.MakeTable(crc64.ECMA)
+
+// Cache is a block cache shared between processes.
+// It is safe for concurrent use.
+type Cache struct {
+	index    []byte
+	data     []byte
+	slots    uint64
+	dataSize uint64
+}
+
+// Open opens the shared cache in dir, creating it if it does not exist.
+// A new cache holds up to maxBytes of data; an existing one keeps the size
+// it was created with.
+func Open(dir string, maxBytes int64) (*Cache, error) {
+	const op errors.Op = "cache/shm.Open"
+	if maxBytes <= 0 {
+		return nil, errors.E(op, errors.Invalid, errors.Errorf("invalid size %d", maxBytes))
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	idx, err := os.OpenFile(filepath.Join(dir, indexName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	defer idx.Close()
+	data, err := os.OpenFile(filepath.Join(dir, dataName), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	defer data.Close()
+
+	// Hold the lock while checking or creating the files so that
+	// processes starting together agree on their layout.
+	if err := lockFile(idx); err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	defer unlockFile(idx)
+
+	slots, dataSize, err := readHeader(idx)
+	if err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	if slots == 0 {
+		// A new cache, or one we do not understand: start afresh.
+		dataSize = uint64(maxBytes)
+		slots = dataSize / bytesPerSlot
+		if slots < minSlots {
+			slots = minSlots
+		}
+		if err := writeHeader(idx, slots, dataSize); err != nil {
+			return nil, errors.E(op, errors.IO, err)
+		}
+	}
+	indexSize := int64(lineSize * (1 + slots))
+	if err := growFile(idx, indexSize); err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	if err := growFile(data, int64(dataSize)); err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+
+	c := &Cache{
+		slots:    slots,
+		dataSize: dataSize,
+	}
+	c.index, err = mapFile(idx, int(indexSize))
+	if err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	c.data, err = mapFile(data, int(dataSize))
+	if err != nil {
+		unmapFile(c.index)
+		return nil, errors.E(op, errors.IO, err)
+	}
+	return c, nil
+}
+
+// readHeader returns the number of slots and the size of the data in the
+// index file, or zeros if it has no valid header.
+func readHeader(f *os.File) (slots, dataSize uint64, err error) {
+	var hdr [lineSize]byte
+	n, err := f.ReadAt(hdr[:], 0)
+	if n < len(hdr) {
+		// Empty or truncated; the error is uninteresting.
+		return 0, 0, nil
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	if binary.LittleEndian.Uint64(hdr[hdrMagic:]) != magic {
+		return 0, 0, nil
+	}
+	slots = binary.LittleEndian.Uint64(hdr[hdrSlots:])
+	dataSize = binary.LittleEndian.Uint64(hdr[hdrDataSize:])
+	if slots == 0 || dataSize == 0 {
+		return 0, 0, nil
+	}
+	return slots, dataSize, nil
+}
+
+// writeHeader initializes the index file, emptying the cache.
+func writeHeader(f *os.File, slots, dataSize uint64) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	var hdr [lineSize]byte
+	binary.LittleEndian.PutUint64(hdr[hdrMagic:], magic)
+	binary.LittleEndian.PutUint64(hdr[hdrSlots:], slots)
+	binary.LittleEndian.PutUint64(hdr[hdrDataSize:], dataSize)
+	_, err := f.WriteAt(hdr[:], 0)
+	return err
+}
+
+// growFile makes sure the file is at least size bytes long.
+func growFile(f *os.File, size int64) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() >= size {
+		return nil
+	}
+	return f.Truncate(size)
+}
+
+// Close unmaps the cache. The Cache must not be used afterwards.
+func (c *Cache) Close() error {
+	err := unmapFile(c.data)
+	if err2 := unmapFile(c.index); err == nil {
+		err = err2
+	}
+	c.index, c.data = nil, nil
+	return err
+}
+
+// word returns a pointer to the 64-bit word at offset off in b.
+// Offsets are multiples of 8 within page-aligned mappings, so the
+// word is suitably aligned for atomic operations.
+func word(b []byte, off uint64) *uint64 {
+	return (*uint64)(unsafe.Pointer(&b[off]))
+}
+
+// slot returns the index entry for the key hash.
+func (c *Cache) slot(k *[sha256.Size]byte) []byte {
+	n := binary.LittleEndian.Uint64(k[:]) % c.slots
+	start := lineSize * (1 + n)
+	return c.index[start : start+lineSize]
+}
+
+// Get returns the block stored under key, if it is in the cache.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	k := sha256.Sum256([]byte(key))
+	s := c.slot(&k)
+	seq := atomic.LoadUint64(word(s, slotSeq))
+	if seq&1 != 0 {
+		// Being written.
+		return nil, false
+	}
+	off := atomic.LoadUint64(word(s, slotOffset))
+	size := atomic.LoadUint64(word(s, slotSize))
+	sum := atomic.LoadUint64(word(s, slotSum))
+	if size == 0 || size > c.dataSize || !bytes.Equal(s[slotKey:slotKey+sha256.Size], k[:]) {
+		return nil, false
+	}
+	pos := off % c.dataSize
+	if pos+size > c.dataSize {
+		return nil, false
+	}
+	buf := make([]byte, size)
+	copy(buf, c.data[pos:pos+size])
+
+	// Make sure nothing changed underfoot.
+	if atomic.LoadUint64(word(s, slotSeq)) != seq {
+		return nil, false
+	}
+	if atomic.LoadUint64(word(c.index, hdrCursor)) > off+c.dataSize {
+		// Overwritten by newer blocks.
+		return nil, false
+	}
+	if crc64.Checksum(buf, crcTable) != sum {
+		return nil, false
+	}
+	return buf, true
+}
+
+// Put stores the block under key. It does nothing if the block is empty
+// or too large, or if another writer is using the key's slot.
+func (c *Cache) Put(key string, data []byte) {
+	size := uint64(len(data))
+	if size == 0 || size > c.dataSize/4 {
+		return
+	}
+	k := sha256.Sum256([]byte(key))
+	s := c.slot(&k)
+	seq, ok := c.claim(s)
+	if !ok {
+		return
+	}
+	off := c.reserve(size)
+	pos := off % c.dataSize
+	copy(c.data[pos:pos+size], data)
+	atomic.StoreUint64(word(s, slotOffset), off)
+	atomic.StoreUint64(word(s, slotSize), size)
+	atomic.StoreUint64(word(s, slotSum), crc64.Checksum(data, crcTable))
+	copy(s[slotKey:slotKey+sha256.Size], k[:])
+	atomic.StoreUint64(word(s, slotSeq), seq+2)
+}
+
+// Remove removes the block stored under key, if any.
+func (c *Cache) Remove(key string) {
+	k := sha256.Sum256([]byte(key))
+	s := c.slot(&k)
+	if !bytes.Equal(s[slotKey:slotKey+sha256.Size], k[:]) {
+		return
+	}
+	seq, ok := c.claim(s)
+	if !ok {
+		return
+	}
+	if bytes.Equal(s[slotKey:slotKey+sha256.Size], k[:]) {
+		atomic.StoreUint64(word(s, slotSize), 0)
+	}
+	atomic.StoreUint64(word(s, slotSeq), seq+2)
+}
+
+// claim makes the slot's sequence number odd, excluding readers and other
+// writers, and returns its previous value. It fails if the slot is in use.
+func (c *Cache) claim(s []byte) (uint64, bool) {
+	seq := atomic.LoadUint64(word(s, slotSeq))
+	if seq&1 != 0 {
+		return 0, false
+	}
+	return seq, atomic.CompareAndSwapUint64(word(s, slotSeq), seq, seq+1)
+}
+
+// reserve allocates size contiguous bytes in the data ring and returns
+// their offset, which increases monotonically; the position in the data
+// file is the offset modulo its size.
+func (c *Cache) reserve(size uint64) uint64 {
+	cursor := word(c.index, hdrCursor)
+	for {
+		cur := atomic.LoadUint64(cursor)
+		start := cur
+		if pos := cur % c.dataSize; pos+size > c.dataSize {
+			// Skip the tail of the ring so the block is contiguous.
+			start += c.dataSize - pos
+		}
+		if atomic.CompareAndSwapUint64(cursor, cur, start+size) {
+			return start
+		}
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build !windows,!plan9
+
+package shm
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+)
+
+func openTemp(t *testing.T, maxBytes int64) (string, *Cache) {
+	dir, err := os.MkdirTemp("", "shm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := Open(dir, maxBytes)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return dir, c
+}
+
+func block(i, size int) []byte {
+	b := []byte(fmt.Sprintf("block %d;", i))
+	return bytes.Repeat(b, size/len(b)+1)[:size]
+}
+
+func TestPutGet(t *testing.T) {
+	dir, c := openTemp(t, 1<<20)
+	defer os.RemoveAll(dir)
+	defer c.Close()
+
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("found block in empty cache")
+	}
+	c.Put("a", block(1, 1000))
+	c.Put("b", block(2, 5000))
+	for i, k := range []string{"a", "b"} {
+		want := block(i+1, []int{1000, 5000}[i])
+		got, ok := c.Get(k)
+		if !ok {
+			t.Fatalf("Get(%q) missed", k)
+		}
+		if !bytes.Equal(got, want) {
+			t.Fatalf("Get(%q) returned wrong data", k)
+		}
+	}
+
+	c.Remove("a")
+	if _, ok := c.Get("a"); ok {
+		t.Fatal("found removed block")
+	}
+	if _, ok := c.Get("b"); !ok {
+		t.Fatal("Remove removed the wrong block")
+	}
+
+	// Blocks too big for the cache are not stored.
+	c.Put("big", block(3, 1<<19))
+	if _, ok := c.Get("big"); ok {
+		t.Fatal("stored oversized block")
+	}
+}
+
+func TestShared(t *testing.T) {
+	// Two Caches opened on the same directory, as two processes would.
+	dir, c1 := openTemp(t, 1<<20)
+	defer os.RemoveAll(dir)
+	defer c1.Close()
+	c2, err := Open(dir, 1<<30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c2.Close()
+	if c2.dataSize != c1.dataSize {
+		t.Fatalf("second Open resized cache to %d bytes, want %d", c2.dataSize, c1.dataSize)
+	}
+
+	c1.Put("x", block(1, 100))
+	got, ok := c2.Get("x")
+	if !ok || !bytes.Equal(got, block(1, 100)) {
+		t.Fatal("block put by one cache not visible to the other")
+	}
+	c2.Remove("x")
+	if _, ok := c1.Get("x"); ok {
+		t.Fatal("block removed by one cache still visible to the other")
+	}
+}
+
+func TestWrap(t *testing.T) {
+	const size = 64 * 1024
+	dir, c := openTemp(t, 16*size)
+	defer os.RemoveAll(dir)
+	defer c.Close()
+
+	// Write three times the capacity; the oldest blocks are overwritten.
+	const n = 48
+	for i := 0; i < n; i++ {
+		c.Put(fmt.Sprint(i), block(i, size-i))
+	}
+	for i := 0; i < n; i++ {
+		got, ok := c.Get(fmt.Sprint(i))
+		if i < n-16 {
+			if ok {
+				t.Errorf("block %d survived being overwritten", i)
+			}
+			continue
+		}
+		if !ok {
+			t.Errorf("block %d missing", i)
+			continue
+		}
+		if !bytes.Equal(got, block(i, size-i)) {
+			t.Errorf("block %d has wrong data", i)
+		}
+	}
+}
+
+func TestConcurrent(t *testing.T) {
+	dir, c := openTemp(t, 256*1024)
+	defer os.RemoveAll(dir)
+	defer c.Close()
+
+	// Readers must never see a block other than the one they asked for,
+	// although with a small cache they will often miss.
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 2000; i++ {
+				k := (g*7 + i) % 50
+				want := block(k, 1000+k*100)
+				if i%3 == 0 {
+					c.Put(fmt.Sprint(k), want)
+					continue
+				}
+				if got, ok := c.Get(fmt.Sprint(k)); ok && !bytes.Equal(got, want) {
+					t.Errorf("block %d has wrong data", k)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
@@ -5,15 +5,19 @@
 package client
 
 import (
+	"archive/zip"
 	"bytes"
+	goerrors "errors"
 	"fmt"
 	"io"
 	"math/rand"
+	"reflect"
 	"strings"
 	"testing"
 	"testing/iotest"
 
 	"upspin.io/bind"
+	"upspin.io/client/clientutil"
 	"upspin.io/config"
 	"upspin.io/factotum"
 	"upspin.io/flags"
@@ -188,8 +192,306 @@ func TestPutSequencedGetTopLevelFile(t *testing.T) {
 	}
 }
 
+func TestSequenceConflict(t *testing.T) {
+	const (
+		user     = "user1@google.com"
+		root     = user + "/"
+		dirName  = root + "seqconflict"
+		fileName = dirName + "/file"
+	)
+	client := New(setup(baseCfg, user))
+
+	// MakeDirectory returns an entry with the directory's sequence number.
+	d, err := client.MakeDirectory(dirName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.IsIncomplete() || d.Name != dirName {
+		t.Errorf("MakeDirectory returned %+v, want complete entry for %s", d, dirName)
+	}
+	checkSequence(t, client, dirName, d.Sequence)
+
+	// Two clients race to create the file; one must lose.
+	d, errs := racePuts(client, fileName, upspin.SeqNotExist)
+	if len(errs) != 1 {
+		t.Fatalf("racing creates: %d errors, want 1: %v", len(errs), errs)
+	}
+	if !goerrors.Is(errs[0], ErrSequenceConflict) || !errors.Is(errors.Exist, errs[0]) {
+		t.Errorf("losing create: err = %v, want Exist and ErrSequenceConflict", errs[0])
+	}
+	checkSequence(t, client, fileName, d.Sequence)
+
+	// They race again to update it from the same version.
+	d, errs = racePuts(client, fileName, d.Sequence)
+	if len(errs) != 1 {
+		t.Fatalf("racing updates: %d errors, want 1: %v", len(errs), errs)
+	}
+	if !goerrors.Is(errs[0], ErrSequenceConflict) || !errors.Is(errors.Invalid, errs[0]) {
+		t.Errorf("losing update: err = %v, want Invalid and ErrSequenceConflict", errs[0])
+	}
+	checkSequence(t, client, fileName, d.Sequence)
+	seq := d.Sequence
+
+	// SetTimeSequenced with a stale sequence also conflicts.
+	if d, err = client.SetTimeSequenced(fileName, seq, upspin.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SetTimeSequenced(fileName, seq, upspin.Now()); !goerrors.Is(err, ErrSequenceConflict) {
+		t.Errorf("SetTimeSequenced with stale sequence: err = %v, want ErrSequenceConflict", err)
+	}
+	checkSequence(t, client, fileName, d.Sequence)
+
+	// Once the file is removed, the old sequence conflicts too.
+	if err := client.Delete(fileName); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.PutSequenced(fileName, d.Sequence, []byte("gone")); !goerrors.Is(err, ErrSequenceConflict) || !errors.Is(errors.Invalid, err) {
+		t.Errorf("PutSequenced of deleted file: err = %v, want Invalid and ErrSequenceConflict", err)
+	}
+
+	// Other failures are not conflicts.
+	if _, err := client.PutSequenced(dirName+"/missing/file", upspin.SeqNotExist, []byte("x")); err == nil || goerrors.Is(err, ErrSequenceConflict) {
+		t.Errorf("PutSequenced in missing directory: err = %v, want error other than ErrSequenceConflict", err)
+	}
+}
+
+// TestSequenceConflictPermission checks that a Put refused for want of
+// rights is not reported as a conflict, even if the sequence is stale.
+func TestSequenceConflictPermission(t *testing.T) {
+	const (
+		owner    = "seqowner@example.com"
+		reader   = "seqreader@example.org"
+		fileName = owner + "/file"
+	)
+	ownerClient := New(setup(baseCfg, owner))
+	readerClient := New(setup(baseCfg2, reader))
+
+	if _, err := ownerClient.Put(owner+"/Access", []byte("*:"+owner+"\nread,list:"+reader)); err != nil {
+		t.Fatal(err)
+	}
+	d, err := ownerClient.Put(fileName, []byte("v1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ownerClient.Put(fileName, []byte("v2")); err != nil {
+		t.Fatal(err)
+	}
+	_, err = readerClient.PutSequenced(fileName, d.Sequence, []byte("mine"))
+	if !errors.Is(errors.Permission, err) || goerrors.Is(err, ErrSequenceConflict) {
+		t.Errorf("PutSequenced without write right: err = %v, want Permission and not ErrSequenceConflict", err)
+	}
+}
+
+// racePuts runs two concurrent PutSequenced calls of the name with seq.
+// It returns the entry of the one that succeeded and the errors.
+func racePuts(client upspin.Client, name upspin.PathName, seq int64) (*upspin.DirEntry, []error) {
+	type result struct {
+		entry *upspin.DirEntry
+		err   error
+	}
+	results := make(chan result)
+	for i := 0; i < 2; i++ {
+		data := []byte(fmt.Sprintf("writer %d", i))
+		go func() {
+			e, err := client.PutSequenced(name, seq, data)
+			results <- result{e, err}
+		}()
+	}
+	var winner *upspin.DirEntry
+	var errs []error
+	for i := 0; i < 2; i++ {
+		r := <-results
+		if r.err != nil {
+			errs = append(errs, r.err)
+		} else {
+			winner = r.entry
+		}
+	}
+	return winner, errs
+}
+
+// checkSequence checks that a Lookup of name reports the sequence number.
+func checkSequence(t *testing.T, client upspin.Client, name upspin.PathName, seq int64) {
+	t.Helper()
+	e, err := client.Lookup(name, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Sequence != seq {
+		t.Errorf("Lookup(%s).Sequence = %d, want %d as returned by Put", name, e.Sequence, seq)
+	}
+}
+
 const Max = 100 * 1000 // Must be > 100.
 
+func TestPutSparse(t *testing.T) {
+	oldBlockSize := flags.BlockSize
+	flags.BlockSize = 1024
+	defer func() {
+		flags.BlockSize = oldBlockSize
+	}()
+
+	const (
+		user     = "sparse@google.com"
+		fileName = user + "/file"
+	)
+	client := New(setup(baseCfg, user)).(*Client)
+
+	// Blocks 1, 2 and the short block 4 are all zeros.
+	data := make([]byte, 4*1024+200)
+	for i := range data[:1024] {
+		data[i] = 'a'
+	}
+	data[3*1024+10] = 'b'
+	if _, err := client.PutSparse(fileName, data); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := client.Lookup(fileName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entry.Blocks) != 5 {
+		t.Fatalf("got %d blocks, want 5", len(entry.Blocks))
+	}
+	for i, b := range entry.Blocks {
+		zero := i == 1 || i == 2 || i == 4
+		if got := b.Location.Reference == upspin.ZeroReference; got != zero {
+			t.Errorf("block %d: zero reference is %t, want %t", i, got, zero)
+		}
+	}
+
+	got, err := client.Get(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("Get returned wrong data")
+	}
+	f, err := client.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	buf := make([]byte, 2048)
+	if _, err := f.ReadAt(buf, 1000); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf, data[1000:3048]) {
+		t.Fatal("ReadAt returned wrong data")
+	}
+
+	// A block that is not zero cannot be passed off as one.
+	forged := *entry
+	forged.Blocks = append([]upspin.DirBlock(nil), entry.Blocks...)
+	forged.Blocks[3].Location.Reference = upspin.ZeroReference
+	if _, err := clientutil.ReadAll(client.config, &forged); err == nil {
+		t.Fatal("ReadAll of forged zero block succeeded")
+	}
+
+	// PutStream stores the same blocks, reading the data a little at a time.
+	const streamName = user + "/stream"
+	if _, err := client.PutStream(streamName, iotest.HalfReader(bytes.NewReader(data)), PutOptions{Sparse: true}); err != nil {
+		t.Fatal(err)
+	}
+	streamEntry, err := client.Lookup(streamName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(streamEntry.Blocks) != 5 {
+		t.Fatalf("streamed file has %d blocks, want 5", len(streamEntry.Blocks))
+	}
+	for i, b := range streamEntry.Blocks {
+		zero := i == 1 || i == 2 || i == 4
+		if got := b.Location.Reference == upspin.ZeroReference; got != zero {
+			t.Errorf("streamed block %d: zero reference is %t, want %t", i, got, zero)
+		}
+	}
+	got, err = client.Get(streamName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatal("Get of streamed file returned wrong data")
+	}
+}
+
+func TestPutAttributes(t *testing.T) {
+	const (
+		user     = "attributes@google.com"
+		fileName = user + "/file"
+		newName  = user + "/renamed"
+	)
+	client := New(setup(baseCfg, user)).(*Client)
+	attrs := map[string][]byte{
+		"content-type": []byte("text/plain"),
+		"mtime":        []byte("1500000000"),
+	}
+	if _, err := client.PutWithOptions(fileName, []byte("hello"), PutOptions{Attributes: attrs}); err != nil {
+		t.Fatal(err)
+	}
+	// The attributes survive a round trip through the DirServer,
+	// and the signature covering them still verifies after a rename.
+	if _, err := client.Rename(fileName, newName); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := client.Lookup(newName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(entry.Attributes, attrs) {
+		t.Errorf("Attributes = %q, want %q", entry.Attributes, attrs)
+	}
+	if data, err := client.Get(newName); err != nil || string(data) != "hello" {
+		t.Errorf("Get = %q, %v; want %q, nil", data, err, "hello")
+	}
+
+	// Oversized attributes are rejected before anything is stored.
+	big := map[string][]byte{"big": make([]byte, upspin.MaxAttributesSize)}
+	if _, err := client.PutWithOptions(fileName, []byte("x"), PutOptions{Attributes: big}); !errors.Is(errors.Invalid, err) {
+		t.Errorf("PutWithOptions with large attributes: err = %v, want Invalid", err)
+	}
+}
+
+func TestGetBlockCache(t *testing.T) {
+	const (
+		user     = "blockcache@google.com"
+		fileName = user + "/file"
+		text     = "cached text"
+	)
+	cfg := setup(baseCfg, user)
+	client := New(cfg).(*Client)
+	if client.blocks == nil {
+		t.Fatal("client has no block cache")
+	}
+	entry, err := client.Put(fileName, []byte(text))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, err := client.Get(fileName); err != nil || string(data) != text {
+		t.Fatalf("Get = %q, %v; want %q, nil", data, err, text)
+	}
+
+	// Once read, the data no longer comes from the store.
+	store, err := bind.StoreServer(cfg, cfg.StoreEndpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, b := range entry.Blocks {
+		if err := store.Delete(b.Location.Reference); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if data, err := client.Get(fileName); err != nil || string(data) != text {
+		t.Errorf("Get after Delete = %q, %v; want %q, nil", data, err, text)
+	}
+
+	// A cacheserver does the caching instead.
+	cached := New(config.SetCacheEndpoint(cfg, upspin.Endpoint{Transport: upspin.Remote, NetAddr: "localhost:8888"})).(*Client)
+	if cached.blocks != nil {
+		t.Error("client of a cacheserver has a block cache")
+	}
+}
+
 func setupFileIO(user upspin.UserName, fileName upspin.PathName, max int, t *testing.T) (upspin.Client, upspin.File, []byte) {
 	client := New(setup(baseCfg, user))
 	f, err := client.Create(fileName)
@@ -596,6 +898,154 @@ func TestFileZeroFill(t *testing.T) {
 	}
 }
 
+func TestFileSparseWrite(t *testing.T) {
+	oldBlockSize := flags.BlockSize
+	flags.BlockSize = 1024
+	defer func() {
+		flags.BlockSize = oldBlockSize
+	}()
+
+	const (
+		user     = "sparsewrite@google.com"
+		fileName = user + "/file"
+	)
+	client, f, _ := setupFileIO(user, fileName, 0, t)
+	// Writing past the end leaves a hole that is not stored.
+	const off = 3*1024 + 10
+	if _, err := f.Seek(off, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("tail")); err != nil {
+		t.Fatal(err)
+	}
+	// Nothing is visible until Close.
+	if _, err := client.Lookup(fileName, true); !errors.Is(errors.NotExist, err) {
+		t.Fatalf("Lookup before Close: err = %v, want NotExist", err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	entry, err := client.Lookup(fileName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entry.Blocks) != 4 {
+		t.Fatalf("got %d blocks, want 4", len(entry.Blocks))
+	}
+	for i, b := range entry.Blocks {
+		if got, want := b.Location.Reference == upspin.ZeroReference, i < 3; got != want {
+			t.Errorf("block %d: zero reference is %t, want %t", i, got, want)
+		}
+	}
+	want := make([]byte, off+4)
+	copy(want[off:], "tail")
+	got, err := client.Get(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatal("Get returned wrong data")
+	}
+}
+
+func TestFileReaderAt(t *testing.T) {
+	oldBlockSize := flags.BlockSize
+	flags.BlockSize = 1023
+	defer func() {
+		flags.BlockSize = oldBlockSize
+	}()
+
+	const (
+		user     = "readerat@google.com"
+		fileName = user + "/file.zip"
+	)
+	client, f, data := setupFileIO(user, fileName, 10000, t)
+	// Write a zip archive, which is read from the end with ReadAt.
+	zw := zip.NewWriter(f)
+	files := map[string][]byte{
+		"a": data,
+		"b": []byte(strings.Repeat("upspin ", 1000)),
+	}
+	for _, name := range []string{"a", "b"} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write(files[name]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := client.Lookup(fileName, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	size, err := entry.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err = client.Open(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(zr.File) != len(files) {
+		t.Fatalf("got %d files in archive, want %d", len(zr.File), len(files))
+	}
+	for _, zf := range zr.File {
+		r, err := zf.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", zf.Name, err)
+		}
+		if !bytes.Equal(got, files[zf.Name]) {
+			t.Errorf("%s: wrong contents", zf.Name)
+		}
+	}
+
+	// ReadAt may be called in parallel.
+	all, err := client.Get(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	errc := make(chan error)
+	for i := 0; i < 8; i++ {
+		off := int64(i) * size / 8
+		go func() {
+			buf := make([]byte, 1500)
+			n, err := f.ReadAt(buf, off)
+			if err != nil && err != io.EOF {
+				errc <- err
+				return
+			}
+			if !bytes.Equal(buf[:n], all[off:off+int64(n)]) {
+				errc <- fmt.Errorf("ReadAt(%d) returned wrong data", off)
+				return
+			}
+			errc <- nil
+		}()
+	}
+	for i := 0; i < 8; i++ {
+		if err := <-errc; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
 func globAndCheck(t *testing.T, client upspin.Client, pattern string, expect ...upspin.PathName) bool {
 	entries, err := client.Glob(pattern)
 	if err != nil {
@@ -1058,6 +1508,77 @@ func TestBrokenLink(t *testing.T) {
 	}
 }
 
+func TestLinkCycles(t *testing.T) {
+	const (
+		user = "linkcycle@google.com"
+		root = user + "/"
+	)
+	client := New(setup(baseCfg, user))
+	if _, err := client.Put(root+"file", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	for _, links := range [][]upspin.PathName{
+		{root + "a2", root + "b2"},
+		{root + "a3", root + "b3", root + "c3"},
+	} {
+		for i, link := range links {
+			target := links[(i+1)%len(links)]
+			if _, err := client.PutLink(target, link); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// The cycle is named in the order it is followed.
+		var cycle []string
+		for _, l := range append(links, links[0]) {
+			cycle = append(cycle, string(l))
+		}
+		want := "link loop: " + strings.Join(cycle, " -> ")
+		_, err := client.Get(links[0])
+		if !errors.Is(errors.Invalid, err) || !strings.Contains(err.Error(), want) {
+			t.Errorf("Get(%q) = %v; want Invalid error containing %q", links[0], err, want)
+		}
+		_, err = client.Lookup(links[0]+"/file", followFinalLink)
+		if !errors.Is(errors.Invalid, err) || !strings.Contains(err.Error(), "link loop") {
+			t.Errorf("Lookup(%q) = %v; want link loop", links[0]+"/file", err)
+		}
+		_, err = client.Glob(string(links[0]) + "/*")
+		if !errors.Is(errors.Invalid, err) || !strings.Contains(err.Error(), "link loop") {
+			t.Errorf("Glob(%q) = %v; want link loop", links[0]+"/*", err)
+		}
+		// The link itself is still visible.
+		if _, err := client.Lookup(links[0], doNotFollowFinalLink); err != nil {
+			t.Errorf("Lookup(%q) without following: %v", links[0], err)
+		}
+	}
+
+	// A link may be crossed many times if the path leads through it
+	// many times without looping.
+	if _, err := client.PutLink(root, root+"self"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := client.Get(root + "self/self/self/file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "hello" {
+		t.Errorf("got %q, want %q", data, "hello")
+	}
+
+	// A chain of links longer than the limit fails, even without a cycle.
+	target := upspin.PathName(root + "file")
+	for i := 0; i <= upspin.MaxLinkHops; i++ {
+		link := upspin.PathName(fmt.Sprintf("%schain%d", root, i))
+		if _, err := client.PutLink(target, link); err != nil {
+			t.Fatal(err)
+		}
+		target = link
+	}
+	_, err = client.Get(target)
+	if !errors.Is(errors.Invalid, err) || !strings.Contains(err.Error(), "more than") {
+		t.Errorf("Get(%q) = %v; want too many links", target, err)
+	}
+}
+
 func TestRejectBadAccessFile(t *testing.T) {
 	const (
 		user          = "bad@access.org"
@@ -7,7 +7,9 @@
 package client // import "upspin.io/client"
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"strings"
 
 	"upspin.io/access"
@@ -25,9 +27,21 @@ import (
 	_ "upspin.io/pack/plain"
 )
 
+// ErrSequenceConflict is the underlying error of a PutSequenced or
+// SetTimeSequenced that failed because the item was created, changed, or
+// removed since the caller learned its sequence number. The error's Kind
+// is Exist if the item was to be created but already exists, and Invalid
+// otherwise. Callers may test for it with the Is function of the standard
+// errors package.
+var ErrSequenceConflict = errors.Str("sequence number conflict")
+
 // Client implements upspin.Client.
 type Client struct {
 	config upspin.Config
+
+	// blocks caches the data read by Get. It is nil if the
+	// config names a cacheserver, which caches blocks itself.
+	blocks *clientutil.BlockCache
 }
 
 var _ upspin.Client = (*Client)(nil)
@@ -39,13 +53,32 @@ const (
 
 // New creates a Client that uses the given configuration to
 // access the various Upspin servers.
+//
+// Unless config names a cacheserver, the client keeps up to
+// flags.BlockCacheSize bytes of the data it reads in memory.
 func New(config upspin.Config) upspin.Client {
-	return &Client{config: config}
+	c := &Client{config: config}
+	if ce := config.CacheEndpoint(); ce.Unassigned() && flags.BlockCacheSize > 0 {
+		c.blocks = clientutil.NewBlockCache(flags.BlockCacheSize)
+	}
+	return c
 }
 
 // PutLink implements upspin.Client.
 func (c *Client) PutLink(oldName, linkName upspin.PathName) (*upspin.DirEntry, error) {
 	const op errors.Op = "client.PutLink"
+	return c.putLink(op, oldName, linkName, putLookupFn)
+}
+
+// PutUncheckedLink is like PutLink but asks the directory server not to
+// check that the link's target exists. Servers that do not implement
+// upspin.UncheckedLinkPutter are sent an ordinary Put.
+func (c *Client) PutUncheckedLink(oldName, linkName upspin.PathName) (*upspin.DirEntry, error) {
+	const op errors.Op = "client.PutUncheckedLink"
+	return c.putLink(op, oldName, linkName, putUncheckedLinkLookupFn)
+}
+
+func (c *Client) putLink(op errors.Op, oldName, linkName upspin.PathName, fn lookupFn) (*upspin.DirEntry, error) {
 	m, s := newMetric(op)
 	defer m.Done()
 
@@ -79,10 +112,27 @@ func (c *Client) PutLink(oldName, linkName upspin.PathName) (*upspin.DirEntry, e
 	}
 
 	// Record directory entry.
-	entry, _, err = c.lookup(op, entry, putLookupFn, doNotFollowFinalLink, s)
+	entry, _, err = c.lookup(op, entry, fn, doNotFollowFinalLink, s)
 	return entry, err
 }
 
+// Used by PutUncheckedLink.
+func putUncheckedLinkLookupFn(dir upspin.DirServer, entry *upspin.DirEntry, s *metric.Span) (*upspin.DirEntry, error) {
+	p, ok := dir.(upspin.UncheckedLinkPutter)
+	if !ok {
+		return putLookupFn(dir, entry, s)
+	}
+	defer s.StartSpan("dir.PutUncheckedLink").End()
+	e, err := p.PutUncheckedLink(entry)
+	if err != nil {
+		return e, err
+	}
+	if e != nil {
+		entry.Sequence = e.Sequence
+	}
+	return entry, nil
+}
+
 // Used by PutLink etc. but not by Put itself.
 func putLookupFn(dir upspin.DirServer, entry *upspin.DirEntry, s *metric.Span) (*upspin.DirEntry, error) {
 	defer s.StartSpan("dir.Put").End()
@@ -106,6 +156,64 @@ func (c *Client) Put(name upspin.PathName, data []byte) (*upspin.DirEntry, error
 // PutSequenced implements upspin.Client.
 func (c *Client) PutSequenced(name upspin.PathName, seq int64, data []byte) (*upspin.DirEntry, error) {
 	const op errors.Op = "client.Put"
+	return c.put(op, name, bytes.NewReader(data), PutOptions{Sequence: seq})
+}
+
+// PutSparse is like Put but does not store the blocks of data that are
+// entirely zero. They are recorded with the reference upspin.ZeroReference
+// instead, saving the upload and storage of sparse files.
+func (c *Client) PutSparse(name upspin.PathName, data []byte) (*upspin.DirEntry, error) {
+	const op errors.Op = "client.PutSparse"
+	return c.put(op, name, bytes.NewReader(data), PutOptions{Sparse: true})
+}
+
+// PutOptions holds the optional settings of PutWithOptions.
+// The zero value gives the behavior of Put.
+type PutOptions struct {
+	// Sequence is the sequence number the item must have, as for
+	// PutSequenced. The default, upspin.SeqIgnore, makes no check.
+	Sequence int64
+
+	// Sparse, if set, avoids storing blocks that are entirely zero,
+	// as for PutSparse.
+	Sparse bool
+
+	// Attributes are the user-defined attributes to record in the
+	// entry. They are signed with the data and are subject to the
+	// limit of upspin.MaxAttributesSize.
+	Attributes map[string][]byte
+
+	// Upload, if not nil, records the progress of the Put so that it
+	// can be resumed if it fails. If it holds the progress of an
+	// earlier Put of the same data to the same name, the blocks that
+	// Put stored are not stored again if the StoreServer still has them.
+	Upload *Upload
+}
+
+// PutWithOptions is like Put but with the settings of opts.
+func (c *Client) PutWithOptions(name upspin.PathName, data []byte, opts PutOptions) (*upspin.DirEntry, error) {
+	const op errors.Op = "client.PutWithOptions"
+	return c.put(op, name, bytes.NewReader(data), opts)
+}
+
+// PutStream is like PutWithOptions but reads the data from r, a block
+// at a time, so a large file need not be held in memory. If opts.Upload
+// is set, r must also implement io.Seeker: it is read once to check that
+// its data is that of the Put being resumed and again to store it.
+func (c *Client) PutStream(name upspin.PathName, r io.Reader, opts PutOptions) (*upspin.DirEntry, error) {
+	const op errors.Op = "client.PutStream"
+	return c.put(op, name, r, opts)
+}
+
+// StreamPutter is implemented by clients, such as this one, that can store
+// a file as it is read. Code holding an upspin.Client can check for it.
+type StreamPutter interface {
+	PutStream(name upspin.PathName, r io.Reader, opts PutOptions) (*upspin.DirEntry, error)
+}
+
+var _ StreamPutter = (*Client)(nil)
+
+func (c *Client) put(op errors.Op, name upspin.PathName, r io.Reader, opts PutOptions) (*upspin.DirEntry, error) {
 	m, s := newMetric(op)
 	defer m.Done()
 
@@ -113,6 +221,10 @@ func (c *Client) PutSequenced(name upspin.PathName, seq int64, data []byte) (*up
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
+	if err := upspin.CheckAttributes(opts.Attributes); err != nil {
+		return nil, errors.E(op, name, errors.Invalid, err)
+	}
+	seq := opts.Sequence
 
 	// Find the Access file that applies. This will also cause us to evaluate links in the path,
 	// and if we do, evalEntry will contain the true file name of the Put operation we will do.
@@ -132,6 +244,15 @@ func (c *Client) PutSequenced(name upspin.PathName, seq int64, data []byte) (*up
 		return nil, errors.E(op, name, errors.Errorf("unrecognized Packing %d", c.config.Packing()))
 	}
 
+	// Access and Group files are small and must be parsed whole.
+	var data []byte
+	if access.IsAccessFile(name) || access.IsGroupFile(name) {
+		data, err = io.ReadAll(r)
+		if err != nil {
+			return nil, errors.E(op, name, errors.IO, err)
+		}
+		r = bytes.NewReader(data)
+	}
 	// Ensure Access file is valid.
 	if access.IsAccessFile(name) {
 		_, err := access.Parse(name, data)
@@ -157,9 +278,15 @@ func (c *Client) PutSequenced(name upspin.PathName, seq int64, data []byte) (*up
 		Link:       "",
 		Attr:       upspin.AttrNone,
 	}
+	if len(opts.Attributes) > 0 {
+		entry.Attributes = make(map[string][]byte, len(opts.Attributes))
+		for k, v := range opts.Attributes {
+			entry.Attributes[k] = append([]byte{}, v...)
+		}
+	}
 
 	ss := s.StartSpan("pack")
-	if err := c.pack(entry, data, packer, ss); err != nil {
+	if err := c.pack(entry, r, packer, opts, ss); err != nil {
 		return nil, errors.E(op, err)
 	}
 	ss.End()
@@ -178,7 +305,7 @@ func (c *Client) PutSequenced(name upspin.PathName, seq int64, data []byte) (*up
 	defer s.StartSpan("dir.Put").End()
 	e, err := dir.Put(entry)
 	if err != nil {
-		return e, err
+		return e, c.sequenceConflict(op, name, seq, err)
 	}
 	// dir.Put returns an incomplete entry, with the updated sequence number.
 	if e != nil { // TODO: Can be nil only when talking to old servers.
@@ -187,6 +314,35 @@ func (c *Client) PutSequenced(name upspin.PathName, seq int64, data []byte) (*up
 	return entry, nil
 }
 
+// sequenceConflict returns an error wrapping ErrSequenceConflict if err,
+// the failure of a Put of the named item with sequence number seq, was
+// because the sequence number did not match. Otherwise it returns err.
+// DirServers report a mismatch as an Invalid or Exist error, but those
+// kinds have other causes too, so the item is looked up to decide.
+// Errors of any other kind, such as Permission, are never conflicts.
+func (c *Client) sequenceConflict(op errors.Op, name upspin.PathName, seq int64, err error) error {
+	if seq == upspin.SeqIgnore {
+		return err
+	}
+	if !errors.Is(errors.Invalid, err) && !errors.Is(errors.Exist, err) {
+		return err
+	}
+	dir, dirErr := c.DirServer(name)
+	if dirErr != nil {
+		return err
+	}
+	entry, lookupErr := dir.Lookup(name)
+	switch {
+	case seq == upspin.SeqNotExist:
+		if lookupErr == nil {
+			return errors.E(op, name, errors.Exist, ErrSequenceConflict)
+		}
+	case lookupErr == nil && entry.Sequence != seq, errors.Is(errors.NotExist, lookupErr):
+		return errors.E(op, name, errors.Invalid, ErrSequenceConflict)
+	}
+	return err
+}
+
 // validSigner checks that the file signer is either the owner
 // or else has write permission.
 // The directory server already checks that entry.Writer
@@ -241,7 +397,13 @@ func (c *Client) access(path upspin.PathName, dir upspin.DirServer) (*access.Acc
 	return access.Parse(whichAccess.Name, accessData)
 }
 
-func (c *Client) pack(entry *upspin.DirEntry, data []byte, packer upspin.Packer, s *metric.Span) error {
+// pack packs the data read from r into the entry's blocks, storing them
+// in the store server.
+// If opts.Sparse is set, blocks that are entirely zero are not stored.
+// If opts.Upload is set, blocks it records as stored are not stored again.
+// Up to flags.PutConcurrency blocks are stored at once, while later
+// blocks are packed.
+func (c *Client) pack(entry *upspin.DirEntry, r io.Reader, packer upspin.Packer, opts PutOptions, s *metric.Span) error {
 	// Verify the blocks aren't too big. This can't happen unless someone's modified
 	// flags.BlockSize underfoot, but protect anyway.
 	if flags.BlockSize > upspin.MaxBlockSize {
@@ -252,38 +414,150 @@ func (c *Client) pack(entry *upspin.DirEntry, data []byte, packer upspin.Packer,
 	if err != nil {
 		return err
 	}
-	bp, err := packer.Pack(c.config, entry)
+	var sum []byte
+	if opts.Upload != nil {
+		sum, err = uploadSum(r)
+		if err != nil {
+			return err
+		}
+	}
+	bp, err := c.blockPacker(entry, packer, opts.Upload, sum)
 	if err != nil {
 		return err
 	}
-	for len(data) > 0 {
-		n := len(data)
-		if n > flags.BlockSize {
-			n = flags.BlockSize
+	up := newUploader(store, c.config.UserName(), flags.PutConcurrency, s)
+	var resumed []UploadedBlock // Blocks stored by the Put being resumed.
+	if opts.Upload != nil {
+		resumed = append(resumed, opts.Upload.Blocks...)
+		up.stored = opts.Upload.record
+	}
+	var stored []int // Indexes of the blocks being stored.
+	buf := make([]byte, flags.BlockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			up.wait()
+			return errors.E(errors.IO, err)
 		}
 		ss := s.StartSpan("bp.pack")
-		cipher, err := bp.Pack(data[:n])
+		zero := opts.Sparse && isZero(buf[:n])
+		cipher, err := bp.Pack(buf[:n])
 		ss.End()
 		if err != nil {
+			up.wait()
 			return err
 		}
-		data = data[n:]
-		ss = s.StartSpan("store.Put")
-		refdata, err := store.Put(cipher)
-		ss.End()
+		if zero {
+			bp.SetLocation(
+				upspin.Location{
+					Endpoint:  c.config.StoreEndpoint(),
+					Reference: upspin.ZeroReference,
+				},
+			)
+			continue
+		}
+		i := len(entry.Blocks) - 1
+		ref, err := storedBlock(resumed, i, cipher)
 		if err != nil {
+			up.wait()
+			return err
+		}
+		if ref != "" {
+			loc := upspin.Location{
+				Endpoint:  c.config.StoreEndpoint(),
+				Reference: ref,
+			}
+			// If the check fails, the block is stored again.
+			if ok, _ := clientutil.HaveLocation(c.config, loc); ok {
+				bp.SetLocation(loc)
+				continue
+			}
+		}
+		// The packer may reuse the ciphertext's buffer for the next block.
+		if err := up.put(i, append([]byte(nil), cipher...)); err != nil {
+			up.wait()
 			return err
 		}
+		stored = append(stored, i)
+		// The packer requires a location before packing the next block.
+		// The real one is set below, once the block is stored.
 		bp.SetLocation(
 			upspin.Location{
 				Endpoint:  c.config.StoreEndpoint(),
-				Reference: refdata.Reference,
+				Reference: pendingReference,
 			},
 		)
 	}
+	refs, err := up.wait()
+	if err != nil {
+		return err
+	}
+	for _, i := range stored {
+		entry.Blocks[i].Location.Reference = refs[i]
+	}
 	return bp.Close()
 }
 
+// blockPacker returns a BlockPacker for the entry. If upload is not nil
+// and records a Put of the same data, whose hash is sum, to the same name,
+// the BlockPacker uses the file key of the upload, if any, so the blocks
+// already stored are packed identically. Otherwise upload is started
+// afresh and records the new file key, if the packing has one.
+func (c *Client) blockPacker(entry *upspin.DirEntry, packer upspin.Packer, upload *Upload, sum []byte) (upspin.BlockPacker, error) {
+	if upload == nil {
+		return packer.Pack(c.config, entry)
+	}
+	if upload.Name == entry.Name && bytes.Equal(upload.Source, sum) && upload.Packing == packer.Packing() {
+		kp, keyed := packer.(pack.KeyedPacker)
+		switch {
+		case keyed && upload.Key != nil:
+			bp, err := kp.PackWithKey(c.config, entry, upload.Key)
+			if err == nil {
+				return bp, nil
+			}
+			// The key is unusable, perhaps because the user's
+			// keys have changed. Start again.
+		case !keyed && upload.Key == nil:
+			return packer.Pack(c.config, entry)
+		}
+	}
+	upload.reset()
+	upload.Name = entry.Name
+	upload.Source = sum
+	upload.Packing = packer.Packing()
+	bp, err := packer.Pack(c.config, entry)
+	if err != nil {
+		return nil, err
+	}
+	if kbp, ok := bp.(pack.KeyedBlockPacker); ok {
+		upload.Key, err = kbp.WrappedKey()
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := upload.save(); err != nil {
+		return nil, err
+	}
+	return bp, nil
+}
+
+// pendingReference is the placeholder reference of a block whose Put
+// is in progress.
+const pendingReference upspin.Reference = "pending"
+
+// isZero reports whether b holds only zero bytes.
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func whichAccessLookupFn(dir upspin.DirServer, entry *upspin.DirEntry, s *metric.Span) (*upspin.DirEntry, error) {
 	defer s.StartSpan("dir.WhichAccess").End()
 	whichEntry, err := dir.WhichAccess(entry.Name)
@@ -420,7 +694,15 @@ func (c *Client) getReaders(op errors.Op, name upspin.PathName, accessEntry *ups
 func makeDirectoryLookupFn(dir upspin.DirServer, entry *upspin.DirEntry, s *metric.Span) (*upspin.DirEntry, error) {
 	defer s.StartSpan("dir.makeDirectory").End()
 	entry.SignedName = entry.Name // Make sure they match as we step through links.
-	return dir.Put(entry)
+	e, err := dir.Put(entry)
+	if err != nil {
+		return e, err
+	}
+	// As for putLookupFn, return the entry with the new sequence number.
+	if e != nil {
+		entry.Sequence = e.Sequence
+	}
+	return entry, nil
 }
 
 // MakeDirectory implements upspin.Client.
@@ -447,7 +729,7 @@ func (c *Client) Get(name upspin.PathName) ([]byte, error) {
 	m, s := newMetric(op)
 	defer m.Done()
 
-	entry, _, err := c.lookup(op, &upspin.DirEntry{Name: name}, lookupLookupFn, followFinalLink, s)
+	entry, _, err := c.lookupVia(op, c.readDirServer, &upspin.DirEntry{Name: name}, lookupLookupFn, followFinalLink, s)
 	if err != nil {
 		return nil, errors.E(op, name, err)
 	}
@@ -459,7 +741,7 @@ func (c *Client) Get(name upspin.PathName) ([]byte, error) {
 		return nil, errors.E(op, name, err)
 	}
 	ss := s.StartSpan("ReadAll")
-	data, err := clientutil.ReadAll(c.config, entry)
+	data, err := c.blocks.ReadAll(c.config, entry)
 	ss.End()
 	if err != nil {
 		return nil, errors.E(op, name, err)
@@ -487,7 +769,7 @@ func (c *Client) Lookup(name upspin.PathName, followFinal bool) (*upspin.DirEntr
 	m, s := newMetric(op)
 	defer m.Done()
 
-	entry, _, err := c.lookup(op, &upspin.DirEntry{Name: name}, lookupLookupFn, followFinal, s)
+	entry, _, err := c.lookupVia(op, c.readDirServer, &upspin.DirEntry{Name: name}, lookupLookupFn, followFinal, s)
 	return entry, err
 }
 
@@ -511,6 +793,12 @@ type lookupFn func(upspin.DirServer, *upspin.DirEntry, *metric.Span) (*upspin.Di
 // call to fn, which for instance will contain the actual path that
 // resulted in a successful call to WhichAccess.
 func (c *Client) lookup(op errors.Op, entry *upspin.DirEntry, fn lookupFn, followFinal bool, s *metric.Span) (resultEntry, finalSuccessfulEntry *upspin.DirEntry, err error) {
+	return c.lookupVia(op, c.DirServer, entry, fn, followFinal, s)
+}
+
+// lookupVia is like lookup but uses dirFor to find the DirServer for
+// each name, so that a read may go to a replica. See readDirServer.
+func (c *Client) lookupVia(op errors.Op, dirFor func(upspin.PathName) (upspin.DirServer, error), entry *upspin.DirEntry, fn lookupFn, followFinal bool, s *metric.Span) (resultEntry, finalSuccessfulEntry *upspin.DirEntry, err error) {
 	ss := s.StartSpan("lookup")
 	defer ss.End()
 
@@ -520,12 +808,13 @@ func (c *Client) lookup(op errors.Op, entry *upspin.DirEntry, fn lookupFn, follo
 	originalName := entry.Name
 	var prevEntry *upspin.DirEntry
 	copied := false // Do we need to allocate a new entry to modify its name?
-	for loop := 0; loop < upspin.MaxLinkHops; loop++ {
+	var hops linkHops
+	for {
 		parsed, err := path.Parse(entry.Name)
 		if err != nil {
 			return nil, nil, errors.E(op, err)
 		}
-		dir, err := c.DirServer(parsed.Path())
+		dir, err := dirFor(parsed.Path())
 		if err != nil {
 			return nil, nil, errors.E(op, err)
 		}
@@ -545,6 +834,9 @@ func (c *Client) lookup(op errors.Op, entry *upspin.DirEntry, fn lookupFn, follo
 			return nil, nil, errors.E(op, errors.Internal, prevEntry.Name, "server returned nil entry for link")
 		}
 		// We have a link.
+		if err := hops.follow(entry.Name, resultEntry.Name); err != nil {
+			return nil, nil, errors.E(op, originalName, err)
+		}
 		// First, allocate a new entry if necessary so we don't overwrite user's memory.
 		if !copied {
 			tmp := *entry
@@ -574,7 +866,36 @@ func (c *Client) lookup(op errors.Op, entry *upspin.DirEntry, fn lookupFn, follo
 			entry.Name = path.Join(resultEntry.Link, string(parsed.Path()[len(resultPath):]))
 		}
 	}
-	return nil, nil, errors.E(op, errors.IO, originalName, "link loop")
+}
+
+// linkHops records the links crossed while evaluating a path name, to stop
+// the evaluation if it loops.
+type linkHops struct {
+	names []upspin.PathName // The names evaluated, in order.
+	links []upspin.PathName // The link crossed in evaluating each name.
+}
+
+// follow records that evaluating name crossed link. It returns an Invalid
+// error if name has been evaluated before, which means the links form a
+// cycle, or if too many links have been crossed.
+func (h *linkHops) follow(name, link upspin.PathName) error {
+	for i, n := range h.names {
+		if n != name {
+			continue
+		}
+		cycle := make([]string, 0, len(h.links)-i+1)
+		for _, l := range h.links[i:] {
+			cycle = append(cycle, string(l))
+		}
+		cycle = append(cycle, string(link))
+		return errors.E(errors.Invalid, errors.Errorf("link loop: %s", strings.Join(cycle, " -> ")))
+	}
+	if len(h.links) >= upspin.MaxLinkHops {
+		return errors.E(errors.Invalid, errors.Errorf("link loop: more than %d links", upspin.MaxLinkHops))
+	}
+	h.names = append(h.names, name)
+	h.links = append(h.links, link)
+	return nil
 }
 
 func deleteLookupFn(dir upspin.DirServer, entry *upspin.DirEntry, s *metric.Span) (*upspin.DirEntry, error) {
@@ -595,17 +916,30 @@ func (c *Client) Delete(name upspin.PathName) error {
 // Glob implements upspin.Client.
 func (c *Client) Glob(pattern string) ([]*upspin.DirEntry, error) {
 	const op errors.Op = "client.Glob"
+	return c.glob(op, pattern, false)
+}
+
+// GlobShallow implements upspin.ShallowGlobber.
+func (c *Client) GlobShallow(pattern string) ([]*upspin.DirEntry, error) {
+	const op errors.Op = "client.GlobShallow"
+	return c.glob(op, pattern, true)
+}
+
+// glob implements Glob and, if shallow is set, GlobShallow.
+func (c *Client) glob(op errors.Op, pattern string, shallow bool) ([]*upspin.DirEntry, error) {
 	m, s := newMetric(op)
 	defer m.Done()
 
 	var results []*upspin.DirEntry
 	var this []string
 	next := []string{pattern}
+	seen := map[string]bool{pattern: true} // Patterns already evaluated.
+	var loopErr error                      // Set if a link leads to a pattern already seen.
 	for loop := 0; loop < upspin.MaxLinkHops && len(next) > 0; loop++ {
 		this, next = next, this
 		next = next[:0]
 		for _, pattern := range this {
-			files, links, err := c.globOnePattern(pattern, s)
+			files, links, err := c.globOnePattern(pattern, shallow, s)
 			if err != nil {
 				first := len(this) == 1 && len(next) == 0
 				if first || !benignGlobError(err) {
@@ -637,14 +971,29 @@ func (c *Client) Glob(pattern string) ([]*upspin.DirEntry, error) {
 				}
 				tail := strings.TrimPrefix(parsed.FilePath(),
 					parsed.First(linkName.NElem()).FilePath())
-				newPattern := path.Join(link.Link, tail)
-				next = append(next, string(newPattern))
+				newPattern := string(path.Join(link.Link, tail))
+				if seen[newPattern] {
+					// Either the links form a cycle or two links
+					// lead to the same place; either way, there
+					// is nothing new to find.
+					if loopErr == nil {
+						loopErr = errors.E(op, errors.Invalid, upspin.PathName(pattern),
+							errors.Errorf("link loop: %s leads back to %s", link.Name, newPattern))
+					}
+					continue
+				}
+				seen[newPattern] = true
+				next = append(next, newPattern)
 			}
 		}
 	}
 	if len(next) > 0 {
 		// TODO: Return partial results?
-		return nil, errors.E(op, upspin.PathName(pattern), "link loop")
+		return nil, errors.E(op, errors.Invalid, upspin.PathName(pattern),
+			errors.Errorf("link loop: more than %d links", upspin.MaxLinkHops))
+	}
+	if len(results) == 0 && loopErr != nil {
+		return nil, loopErr
 	}
 	results = upspin.SortDirEntries(results, true)
 	return results, nil
@@ -658,13 +1007,22 @@ func benignGlobError(err error) bool {
 		errors.Is(errors.Private, err)
 }
 
-func (c *Client) globOnePattern(pattern string, s *metric.Span) (entries, links []*upspin.DirEntry, err error) {
+func (c *Client) globOnePattern(pattern string, shallow bool, s *metric.Span) (entries, links []*upspin.DirEntry, err error) {
 	defer s.StartSpan("dir.Glob").End()
-	dir, err := c.DirServer(upspin.PathName(pattern))
+	dir, err := c.readDirServer(upspin.PathName(pattern))
 	if err != nil {
 		return nil, nil, err
 	}
-	entries, err = dir.Glob(pattern)
+	if g, ok := dir.(upspin.ShallowGlobber); ok && shallow {
+		entries, err = g.GlobShallow(pattern)
+	} else {
+		entries, err = dir.Glob(pattern)
+		if shallow {
+			for _, e := range entries {
+				e.MarkIncomplete()
+			}
+		}
+	}
 	switch err {
 	case nil:
 		return entries, nil, nil
@@ -723,6 +1081,22 @@ func (c *Client) DirServer(name upspin.PathName) (upspin.DirServer, error) {
 	return dir, nil
 }
 
+// readDirServer is like DirServer but returns a DirServer to be used
+// only to read, which for the user's own tree may be a nearby replica.
+// See bind.ReadDirServerFor.
+func (c *Client) readDirServer(name upspin.PathName) (upspin.DirServer, error) {
+	const op errors.Op = "Client.DirServer"
+	parsed, err := path.Parse(name)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	dir, err := bind.ReadDirServerFor(c.config, parsed.User())
+	if err != nil {
+		return nil, errors.E(op, name, err)
+	}
+	return dir, nil
+}
+
 // PutDuplicate implements upspin.Client.
 // If one of the two files is later modified, the copy and the original will differ.
 func (c *Client) PutDuplicate(oldName, newName upspin.PathName) (*upspin.DirEntry, error) {
@@ -742,6 +1116,167 @@ func (c *Client) Rename(oldName, newName upspin.PathName) (*upspin.DirEntry, err
 	return c.dupOrRename(op, oldName, newName, true, s)
 }
 
+// RenameDir renames the directory oldName, and everything beneath it, to
+// newName, which must not exist. The change is made atomically, so other
+// clients see either the old tree or the new one, never a mix. This
+// requires that the directory server implement upspin.DirBatcher and that
+// the tree hold at most upspin.MaxBatchChanges entries; otherwise RenameDir
+// makes no change and returns an error. If newName is in another user's
+// tree, the change is made by ApplyTransaction instead, which requires
+// that both directory servers implement upspin.DirTransactor.
+// A successful RenameDir returns an incomplete DirEntry (see the
+// description of AttrIncomplete) containing only the new sequence number
+// of the directory.
+func (c *Client) RenameDir(oldName, newName upspin.PathName) (*upspin.DirEntry, error) {
+	const op errors.Op = "client.RenameDir"
+	m, s := newMetric(op)
+	defer m.Done()
+
+	entry, _, err := c.lookup(op, &upspin.DirEntry{Name: oldName}, lookupLookupFn, doNotFollowFinalLink, s)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	if !entry.IsDir() {
+		return nil, errors.E(op, oldName, errors.NotDir)
+	}
+	oldParsed, err := path.Parse(entry.Name)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	newParsed, err := path.Parse(newName)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	if oldParsed.IsRoot() || newParsed.IsRoot() {
+		return nil, errors.E(op, oldName, errors.Invalid, "cannot rename a root")
+	}
+	if newParsed.HasPrefix(oldParsed) {
+		return nil, errors.E(op, newName, errors.Invalid, "cannot move a directory beneath itself")
+	}
+	dir, err := c.DirServer(entry.Name)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	sameUser := oldParsed.User() == newParsed.User()
+	batcher, ok := dir.(upspin.DirBatcher)
+	if sameUser && !ok {
+		return nil, errors.E(op, oldName, upspin.ErrNotSupported)
+	}
+
+	// Collect the tree, parents before children.
+	entries, err := c.walkDir(op, dir, entry)
+	if err != nil {
+		return nil, err
+	}
+	if 2*len(entries) > upspin.MaxBatchChanges {
+		return nil, errors.E(op, oldName, errors.Invalid, errors.Errorf("too many entries to rename: %d", len(entries)))
+	}
+
+	// Encrypted files governed by an Access file outside the tree will
+	// be governed by the one above newName instead, so their keys must
+	// be rewrapped for its readers if the tree changes directory.
+	rewrap := !oldParsed.Drop(1).Equal(newParsed.Drop(1))
+	var newReaders []upspin.UserName
+	if rewrap {
+		accessEntry, _, err := c.lookup(op, &upspin.DirEntry{Name: newParsed.Path()}, whichAccessLookupFn, doNotFollowFinalLink, s)
+		if err != nil {
+			return nil, errors.E(op, newName, err)
+		}
+		newReaders, err = c.getReaders(op, newParsed.Path(), accessEntry)
+		if err != nil {
+			return nil, errors.E(op, newName, err)
+		}
+	}
+	// governed records the directories within the tree that are
+	// governed by an Access file also within the tree.
+	governed := make(map[upspin.PathName]bool)
+	for _, e := range entries {
+		if access.IsAccessFile(e.Name) {
+			governed[path.DropPath(e.Name, 1)] = true
+		}
+	}
+
+	changes := make([]upspin.DirChange, 0, 2*len(entries))
+	for _, e := range entries {
+		p, err := path.Parse(e.Name)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		name := newParsed.Path() + p.Path()[len(oldParsed.Path()):]
+		if e.IsDir() {
+			if governed[path.DropPath(e.Name, 1)] {
+				governed[e.Name] = true
+			}
+			// The server rebuilds the directory's contents
+			// from the entries put beneath it.
+			changes = append(changes, upspin.DirChange{Entry: &upspin.DirEntry{
+				Name:       name,
+				SignedName: name,
+				Attr:       upspin.AttrDirectory,
+				Time:       e.Time,
+				Sequence:   upspin.SeqNotExist,
+			}})
+			continue
+		}
+		if e.IsIncomplete() {
+			return nil, errors.E(op, e.Name, errors.Permission, "cannot rename a file without read access")
+		}
+		packer := pack.Lookup(e.Packing)
+		if packer == nil {
+			return nil, errors.E(op, e.Name, errors.Invalid, errors.Errorf("unrecognized Packing %d", e.Packing))
+		}
+		ne := e.Copy()
+		ne.Sequence = upspin.SeqNotExist
+		if err := packer.Name(c.config, ne, name); err != nil {
+			return nil, errors.E(op, err)
+		}
+		if rewrap && !e.IsLink() && !governed[path.DropPath(e.Name, 1)] {
+			if err := c.addReaders(op, ne, packer, newReaders); err != nil {
+				return nil, errors.E(op, e.Name, err)
+			}
+		}
+		changes = append(changes, upspin.DirChange{Entry: ne})
+	}
+	// Delete the old tree, children before parents.
+	for i := len(entries) - 1; i >= 0; i-- {
+		changes = append(changes, upspin.DirChange{
+			Delete: true,
+			Entry:  &upspin.DirEntry{Name: entries[i].Name},
+		})
+	}
+
+	var results []*upspin.DirEntry
+	if sameUser {
+		results, err = batcher.ApplyBatch(changes)
+	} else {
+		results, err = c.applyTransaction(op, changes)
+	}
+	if err == upspin.ErrNotSupported {
+		return nil, errors.E(op, oldName, err)
+	}
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	return results[0], nil
+}
+
+// walkDir returns the entry for the directory and all the entries beneath
+// it, each directory before its contents. Links are not followed.
+func (c *Client) walkDir(op errors.Op, dir upspin.DirServer, entry *upspin.DirEntry) ([]*upspin.DirEntry, error) {
+	entries := []*upspin.DirEntry{entry}
+	for i := 0; i < len(entries); i++ {
+		if !entries[i].IsDir() {
+			continue
+		}
+		children, err := dir.Glob(upspin.AllFilesGlob(entries[i].Name))
+		if err != nil && err != upspin.ErrFollowLink {
+			return nil, errors.E(op, err)
+		}
+		entries = append(entries, children...)
+	}
+	return entries, nil
+}
+
 // SetTime implements upspin.Client.
 func (c *Client) SetTime(name upspin.PathName, t upspin.Time) error {
 	_, err := c.SetTimeSequenced(name, upspin.SeqIgnore, t)
@@ -769,9 +1304,9 @@ func (c *Client) SetTimeSequenced(name upspin.PathName, seq int64, t upspin.Time
 
 	// Record directory entry.
 	entry.Sequence = seq
-	e , _, err := c.lookup(op, entry, putLookupFn, doNotFollowFinalLink, s)
+	e, _, err := c.lookup(op, entry, putLookupFn, doNotFollowFinalLink, s)
 	if err != nil {
-		return nil, errors.E(op, err)
+		return nil, c.sequenceConflict(op, entry.Name, seq, errors.E(op, err))
 	}
 
 	// dir.Put returns an incomplete entry, with the updated sequence number.
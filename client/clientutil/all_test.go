@@ -5,8 +5,10 @@
 package clientutil
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"encoding/binary"
+	"io"
 	"math/big"
 	"strconv"
 	"testing"
@@ -98,6 +100,108 @@ func TestReadAll(t *testing.T) {
 	}
 }
 
+// streamStore is a mockStore that also offers GetStream and counts
+// the bytes read from its streams.
+type streamStore struct {
+	mockStore
+	read int
+}
+
+func (s *streamStore) GetStream(ref upspin.Reference) (io.ReadCloser, *upspin.Refdata, []upspin.Location, error) {
+	data, refdata, locs, err := s.Get(ref)
+	if data == nil {
+		return nil, nil, locs, err
+	}
+	return io.NopCloser(&countingReader{r: bytes.NewReader(data), n: &s.read}), refdata, nil, nil
+}
+
+func (s *streamStore) Dial(upspin.Config, upspin.Endpoint) (upspin.Service, error) {
+	return s, nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n *int
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	*c.n += n
+	return n, err
+}
+
+func TestHaveLocation(t *testing.T) {
+	// Use a transport of its own, as the others are registered by
+	// other tests.
+	e := upspin.Endpoint{Transport: upspin.Transport(100), NetAddr: "stream.example.com:443"}
+	loc := func(ref upspin.Reference) upspin.Location {
+		return upspin.Location{Endpoint: e, Reference: ref}
+	}
+	store := &streamStore{
+		mockStore: mockStore{
+			locWithContent: loc("data"),
+			content:        []byte("the data"),
+			locRedirection: map[upspin.Reference][]upspin.Location{
+				"redirect": {loc("missing"), loc("data")},
+				"loop":     {loc("loop"), loc("missing")},
+			},
+		},
+	}
+	if err := bind.RegisterStoreServer(e.Transport, store); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.SetUserName(config.New(), userName)
+	for _, test := range []struct {
+		ref  upspin.Reference
+		want bool
+	}{
+		{"data", true},
+		{"redirect", true},
+		{"missing", false},
+		{"loop", false},
+	} {
+		got, err := HaveLocation(cfg, loc(test.ref))
+		if err != nil {
+			t.Errorf("HaveLocation(%q): %v", test.ref, err)
+		}
+		if got != test.want {
+			t.Errorf("HaveLocation(%q) = %t, want %t", test.ref, got, test.want)
+		}
+	}
+	if store.read != 0 {
+		t.Errorf("HaveLocation read %d bytes of data, want none", store.read)
+	}
+}
+
+func TestBlockRange(t *testing.T) {
+	blocks := []upspin.DirBlock{
+		{Offset: 0, Size: 100},
+		{Offset: 100, Size: 100},
+		{Offset: 200, Size: 50},
+	}
+	for _, test := range []struct {
+		offset, size int64
+		first, end   int
+	}{
+		{0, 1, 0, 1},
+		{0, 100, 0, 1},
+		{0, 101, 0, 2},
+		{99, 2, 0, 2},
+		{100, 100, 1, 2},
+		{150, 1000, 1, 3},
+		{0, 250, 0, 3},
+		{249, 1, 2, 3},
+		{250, 10, 3, 3},
+		{1000, 10, 3, 3},
+		{50, 0, 0, 0},
+	} {
+		first, end := BlockRange(blocks, test.offset, test.size)
+		if first != test.first || end != test.end {
+			t.Errorf("BlockRange(%d, %d) = %d, %d; want %d, %d", test.offset, test.size, first, end, test.first, test.end)
+		}
+	}
+}
+
 func pdMarshal(dst *[]byte, sig, sig2 upspin.Signature) error {
 	// sig2 is a signature with another owner key, to enable smoother key rotation.
 	n := packdataLen()
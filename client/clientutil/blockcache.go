@@ -0,0 +1,109 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientutil
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"upspin.io/upspin"
+)
+
+// BlockCache holds in memory the data of recently read blocks, keyed by
+// Location, so that reading them again need not contact a StoreServer.
+// It honors the Refdata returned with the data: volatile data is not
+// cached and other data is dropped once its Duration, if any, has passed.
+// When full, the least recently used blocks are evicted.
+//
+// A nil *BlockCache caches nothing. A BlockCache is safe for concurrent use.
+type BlockCache struct {
+	mu    sync.Mutex
+	limit int64
+	size  int64
+	lru   *list.List // Of *cachedBlock, most recently used first.
+	m     map[upspin.Location]*list.Element
+
+	now func() time.Time // Replaced in tests.
+}
+
+type cachedBlock struct {
+	loc     upspin.Location
+	data    []byte
+	expires time.Time // Zero if the data does not expire.
+}
+
+// NewBlockCache returns a BlockCache holding at most maxBytes of data.
+func NewBlockCache(maxBytes int64) *BlockCache {
+	return &BlockCache{
+		limit: maxBytes,
+		lru:   list.New(),
+		m:     make(map[upspin.Location]*list.Element),
+		now:   time.Now,
+	}
+}
+
+// ReadAll is like the function ReadAll but reads blocks through the cache.
+func (c *BlockCache) ReadAll(cfg upspin.Config, entry *upspin.DirEntry) ([]byte, error) {
+	return readAll(cfg, entry, c)
+}
+
+// ReadLocation is like the function ReadLocation but reads through the
+// cache. The returned data must not be modified.
+func (c *BlockCache) ReadLocation(cfg upspin.Config, loc upspin.Location) ([]byte, error) {
+	return readLocation(cfg, loc, c)
+}
+
+// get returns the cached data for loc, if present and unexpired.
+func (c *BlockCache) get(loc upspin.Location) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.m[loc]
+	if !ok {
+		return nil, false
+	}
+	b := elem.Value.(*cachedBlock)
+	if !b.expires.IsZero() && !c.now().Before(b.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return b.data, true
+}
+
+// put adds the data for loc, described by refdata, to the cache if it is
+// cacheable and fits.
+func (c *BlockCache) put(loc upspin.Location, data []byte, refdata *upspin.Refdata) {
+	if c == nil || refdata == nil || refdata.Volatile || int64(len(data)) > c.limit {
+		return
+	}
+	b := &cachedBlock{
+		loc:  loc,
+		data: data,
+	}
+	if refdata.Duration > 0 {
+		b.expires = c.now().Add(refdata.Duration)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.m[loc]; ok {
+		c.remove(elem)
+	}
+	for c.size+int64(len(data)) > c.limit {
+		c.remove(c.lru.Back())
+	}
+	c.m[loc] = c.lru.PushFront(b)
+	c.size += int64(len(data))
+}
+
+// remove removes elem from the cache. c.mu must be held.
+func (c *BlockCache) remove(elem *list.Element) {
+	b := c.lru.Remove(elem).(*cachedBlock)
+	delete(c.m, b.loc)
+	c.size -= int64(len(b.data))
+}
@@ -0,0 +1,101 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientutil
+
+import (
+	"testing"
+	"time"
+
+	"upspin.io/bind"
+	"upspin.io/config"
+	"upspin.io/errors"
+	"upspin.io/test/testfixtures"
+	"upspin.io/upspin"
+)
+
+// countingStore serves refs with the given Refdata and counts the Gets
+// of each.
+type countingStore struct {
+	testfixtures.DummyStoreServer
+	refdata map[upspin.Reference]upspin.Refdata
+	gets    map[upspin.Reference]int
+}
+
+func (s *countingStore) Get(ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Location, error) {
+	refdata, ok := s.refdata[ref]
+	if !ok {
+		return nil, nil, nil, errors.E(errors.NotExist)
+	}
+	s.gets[ref]++
+	return []byte("data for " + ref), &refdata, nil, nil
+}
+
+func (s *countingStore) Dial(upspin.Config, upspin.Endpoint) (upspin.Service, error) {
+	return s, nil
+}
+
+func TestBlockCache(t *testing.T) {
+	store := &countingStore{
+		refdata: map[upspin.Reference]upspin.Refdata{
+			"forever":  {Reference: "forever"},
+			"minute":   {Reference: "minute", Duration: time.Minute},
+			"volatile": {Reference: "volatile", Volatile: true},
+			"other":    {Reference: "other"},
+		},
+		gets: make(map[upspin.Reference]int),
+	}
+	// Use a transport of its own, as TestReadAll registers InProcess.
+	e := upspin.Endpoint{Transport: upspin.Remote, NetAddr: "counting.example.com:443"}
+	if err := bind.RegisterStoreServer(upspin.Remote, store); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.SetUserName(config.New(), userName)
+
+	// The cached blocks are 14 to 16 bytes long, so the cache holds two.
+	c := NewBlockCache(35)
+	now := time.Now()
+	c.now = func() time.Time { return now }
+	read := func(refs ...upspin.Reference) {
+		t.Helper()
+		for _, ref := range refs {
+			data, err := c.ReadLocation(cfg, upspin.Location{Endpoint: e, Reference: ref})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := "data for " + string(ref); string(data) != want {
+				t.Fatalf("ReadLocation(%q) = %q, want %q", ref, data, want)
+			}
+		}
+	}
+	check := func(ref upspin.Reference, want int) {
+		t.Helper()
+		if got := store.gets[ref]; got != want {
+			t.Errorf("%q fetched %d times, want %d", ref, got, want)
+		}
+	}
+
+	read("forever", "forever", "volatile", "volatile")
+	check("forever", 1)
+	check("volatile", 2)
+
+	read("minute", "minute")
+	check("minute", 1)
+	now = now.Add(time.Minute)
+	read("minute")
+	check("minute", 2)
+
+	// The cache is full with forever and minute, so other evicts forever,
+	// the least recently used.
+	read("other", "minute", "forever")
+	check("other", 1)
+	check("minute", 2)
+	check("forever", 2)
+
+	// Without a cache, every read is a fetch.
+	if _, err := ReadLocation(cfg, upspin.Location{Endpoint: e, Reference: "forever"}); err != nil {
+		t.Fatal(err)
+	}
+	check("forever", 3)
+}
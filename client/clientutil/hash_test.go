@@ -0,0 +1,88 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientutil
+
+import (
+	goerrors "errors"
+	"testing"
+
+	"upspin.io/bind"
+	"upspin.io/config"
+	"upspin.io/errors"
+	"upspin.io/flags"
+	"upspin.io/key/sha256key"
+	"upspin.io/test/testfixtures"
+	"upspin.io/upspin"
+)
+
+// blobStore returns the data it holds for each reference.
+type blobStore struct {
+	testfixtures.DummyStoreServer
+	blobs map[upspin.Reference][]byte
+}
+
+func (s *blobStore) Get(ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Location, error) {
+	data, ok := s.blobs[ref]
+	if !ok {
+		return nil, nil, nil, errors.E(errors.NotExist)
+	}
+	return data, &upspin.Refdata{Reference: ref}, nil, nil
+}
+
+func (s *blobStore) Dial(upspin.Config, upspin.Endpoint) (upspin.Service, error) {
+	return s, nil
+}
+
+func TestPrudentHashCheck(t *testing.T) {
+	defer func(prudent bool) { flags.Prudent = prudent }(flags.Prudent)
+
+	good := []byte("the data as written")
+	goodRef := upspin.Reference(sha256key.Of(good).String())
+	badRef := upspin.Reference(sha256key.Of([]byte("the data as written, before tampering")).String())
+	store := &blobStore{
+		blobs: map[upspin.Reference][]byte{
+			goodRef:      good,
+			badRef:       []byte("tampered data"),
+			"not-a-hash": []byte("anything"),
+		},
+	}
+	// Other tests have registered the InProcess and Remote transports.
+	if err := bind.RegisterStoreServer(upspin.HTTPS, store); err != nil {
+		t.Fatal(err)
+	}
+	e := upspin.Endpoint{Transport: upspin.HTTPS, NetAddr: "https://store.example.com"}
+	cfg := config.SetUserName(config.New(), userName)
+	read := func(ref upspin.Reference) error {
+		_, err := ReadLocation(cfg, upspin.Location{Endpoint: e, Reference: ref})
+		return err
+	}
+
+	// Without prudence, the store is trusted.
+	flags.Prudent = false
+	if err := read(badRef); err != nil {
+		t.Errorf("imprudent read of tampered block: %v", err)
+	}
+
+	flags.Prudent = true
+	for _, ref := range []upspin.Reference{goodRef, "not-a-hash"} {
+		if err := read(ref); err != nil {
+			t.Errorf("prudent read of %q: %v", ref, err)
+		}
+	}
+	err := read(badRef)
+	var hashErr *HashMismatchError
+	if !errors.Is(errors.IO, err) || !goerrors.As(err, &hashErr) {
+		t.Fatalf("prudent read of tampered block: err = %v, want IO error with HashMismatchError", err)
+	}
+	if want := (upspin.Location{Endpoint: e, Reference: badRef}); hashErr.Location != want {
+		t.Errorf("HashMismatchError names %v, want %v", hashErr.Location, want)
+	}
+
+	// A store may declare that its references are not hashes.
+	UnhashedReferences(e)
+	if err := read(badRef); err != nil {
+		t.Errorf("prudent read from unhashed store: %v", err)
+	}
+}
@@ -7,9 +7,17 @@
 package clientutil // import "upspin.io/client/clientutil"
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
 	"upspin.io/access"
 	"upspin.io/bind"
 	"upspin.io/errors"
+	"upspin.io/flags"
+	"upspin.io/key/sha256key"
 	"upspin.io/pack"
 	"upspin.io/path"
 	"upspin.io/upspin"
@@ -19,6 +27,11 @@ import (
 // the necessary keys loaded in the config to unpack the cipher if the entry
 // is encrypted.
 func ReadAll(cfg upspin.Config, entry *upspin.DirEntry) ([]byte, error) {
+	return readAll(cfg, entry, nil)
+}
+
+// readAll implements ReadAll, reading blocks through cache, which may be nil.
+func readAll(cfg upspin.Config, entry *upspin.DirEntry, cache *BlockCache) ([]byte, error) {
 	if entry.IsLink() {
 		return nil, errors.E(entry.Name, errors.Invalid, "can't read a link entry")
 	}
@@ -42,6 +55,7 @@ func ReadAll(cfg upspin.Config, entry *upspin.DirEntry) ([]byte, error) {
 	if err != nil {
 		return nil, errors.E(entry.Name, err) // Showstopper.
 	}
+	defer pinBlocks(cfg, entry.Blocks)()
 	for {
 		block, ok := bu.NextBlock()
 		if !ok {
@@ -49,11 +63,7 @@ func ReadAll(cfg upspin.Config, entry *upspin.DirEntry) ([]byte, error) {
 		}
 		// block is known valid as per valid.DirEntry above.
 
-		cipher, err := ReadLocation(cfg, block.Location)
-		if err != nil {
-			return nil, errors.E(err)
-		}
-		clear, err := bu.Unpack(cipher)
+		clear, err := readBlock(cfg, bu, block, cache)
 		if err != nil {
 			return nil, errors.E(entry.Name, err)
 		}
@@ -62,9 +72,103 @@ func ReadAll(cfg upspin.Config, entry *upspin.DirEntry) ([]byte, error) {
 	return data, nil
 }
 
+// pinBlocks asks the cacheserver, if one is in use, to keep the blocks in
+// its cache until the returned function is called, so that none of them is
+// evicted before it is read. As pinning is only an optimization, errors
+// are ignored.
+func pinBlocks(cfg upspin.Config, blocks []upspin.DirBlock) (unpin func()) {
+	if ce := cfg.CacheEndpoint(); ce.Unassigned() || len(blocks) < 2 {
+		return func() {}
+	}
+	refs := make(map[upspin.Endpoint][]upspin.Reference)
+	for _, b := range blocks {
+		if b.Location.Reference == upspin.ZeroReference {
+			continue
+		}
+		e := b.Location.Endpoint
+		refs[e] = append(refs[e], b.Location.Reference)
+	}
+	var tokens []upspin.Reference
+	var stores []upspin.StoreServer
+	for e, list := range refs {
+		store, err := bind.StoreServer(cfg, e)
+		if err != nil {
+			continue
+		}
+		b, err := json.Marshal(list)
+		if err != nil {
+			continue
+		}
+		token, _, _, err := store.Get(upspin.PinMetadata + upspin.Reference(b))
+		if err != nil {
+			continue
+		}
+		tokens = append(tokens, upspin.UnpinMetadata+upspin.Reference(token))
+		stores = append(stores, store)
+	}
+	return func() {
+		for i, store := range stores {
+			store.Get(tokens[i])
+		}
+	}
+}
+
+// ReadBlock fetches and unpacks the block at which bu is positioned.
+// A block whose Reference is upspin.ZeroReference is returned as zero
+// bytes without contacting a StoreServer, once the packing has verified
+// that it was packed from zeros.
+func ReadBlock(cfg upspin.Config, bu upspin.BlockUnpacker, block upspin.DirBlock) ([]byte, error) {
+	return readBlock(cfg, bu, block, nil)
+}
+
+func readBlock(cfg upspin.Config, bu upspin.BlockUnpacker, block upspin.DirBlock, cache *BlockCache) ([]byte, error) {
+	if block.Location.Reference == upspin.ZeroReference {
+		zu, ok := bu.(upspin.ZeroBlockUnpacker)
+		if !ok {
+			return nil, errors.E(errors.Invalid, errors.Str("packing does not support zero blocks"))
+		}
+		if block.Size < 0 || block.Size > upspin.MaxBlockSize {
+			return nil, errors.E(errors.Invalid, errors.Errorf("bad size %d for zero block", block.Size))
+		}
+		return zu.UnpackZero()
+	}
+	cipher, err := readLocation(cfg, block.Location, cache)
+	if err != nil {
+		return nil, err
+	}
+	return bu.Unpack(cipher)
+}
+
+// BlockRange returns the indices of the blocks that hold the bytes in the
+// range [offset, offset+size), as the half-open interval [first, end).
+// The blocks must be in order of offset, as in a DirEntry. If no block
+// holds any of the bytes, first equals end.
+func BlockRange(blocks []upspin.DirBlock, offset, size int64) (first, end int) {
+	first = sort.Search(len(blocks), func(i int) bool {
+		return blocks[i].Offset+blocks[i].Size > offset
+	})
+	end = sort.Search(len(blocks), func(i int) bool {
+		return blocks[i].Offset >= offset+size
+	})
+	if size <= 0 || end < first {
+		end = first
+	}
+	return first, end
+}
+
 // ReadLocation uses the provided Config to fetch the contents of the given
 // Location, following any StoreServer.Get redirects.
 func ReadLocation(cfg upspin.Config, loc upspin.Location) ([]byte, error) {
+	return readLocation(cfg, loc, nil)
+}
+
+// readLocation implements ReadLocation, consulting cache, which may be
+// nil, first and saving in it what is fetched.
+func readLocation(cfg upspin.Config, orig upspin.Location, cache *BlockCache) ([]byte, error) {
+	if data, ok := cache.get(orig); ok {
+		return data, nil
+	}
+
 	// firstError remembers the first error we saw.
 	// If we fail completely we return it.
 	var firstError error
@@ -84,18 +188,22 @@ func ReadLocation(cfg upspin.Config, loc upspin.Location) ([]byte, error) {
 	knownLocs := make(map[upspin.Location]bool)
 	// Get the data for this block.
 	// where is the list of locations to examine. It is updated in the loop.
-	where := []upspin.Location{loc}
+	where := []upspin.Location{orig}
 	for i := 0; i < len(where); i++ { // Not range loop - where changes as we run.
 		loc := where[i]
 		store, err := bind.StoreServer(cfg, loc.Endpoint)
 		if isError(err) {
 			continue
 		}
-		data, _, locs, err := store.Get(loc.Reference)
+		data, refdata, locs, err := get(store, loc.Reference)
 		if isError(err) {
 			continue // locs guaranteed to be nil.
 		}
 		if locs == nil && err == nil {
+			if isError(checkHash(loc, data)) {
+				continue
+			}
+			cache.put(orig, data, refdata)
 			return data, nil
 		}
 		// Add new locs to the list. Skip ones already there - they've been processed.
@@ -111,5 +219,118 @@ func ReadLocation(cfg upspin.Config, loc upspin.Location) ([]byte, error) {
 	if firstError != nil {
 		return nil, errors.E(firstError)
 	}
-	return nil, errors.E(errors.IO, errors.Errorf("data for location %v not found on any store server", loc))
+	return nil, errors.E(errors.IO, errors.Errorf("data for location %v not found on any store server", orig))
+}
+
+// HaveLocation reports whether a StoreServer holds the contents of the
+// given Location, following any StoreServer.Get redirects. If the store
+// offers GetStream the data is opened and closed unread rather than
+// downloaded. It reports false with a nil error if the data is not found;
+// other failures to learn whether it is held are returned as errors.
+func HaveLocation(cfg upspin.Config, orig upspin.Location) (bool, error) {
+	var firstError error
+	knownLocs := map[upspin.Location]bool{orig: true}
+	where := []upspin.Location{orig}
+	for i := 0; i < len(where); i++ { // Not range loop - where changes as we run.
+		loc := where[i]
+		store, err := bind.StoreServer(cfg, loc.Endpoint)
+		if err == nil {
+			var locs []upspin.Location
+			locs, err = have(store, loc.Reference)
+			if err == nil && locs == nil {
+				return true, nil
+			}
+			for _, newLoc := range locs {
+				if !knownLocs[newLoc] {
+					where = append(where, newLoc)
+					knownLocs[newLoc] = true
+				}
+			}
+		}
+		if err != nil && !errors.Is(errors.NotExist, err) && firstError == nil {
+			firstError = err
+		}
+	}
+	return false, firstError
+}
+
+// have reports whether the store holds the data with the given reference
+// by returning a nil error, or else the redirects to follow or the error.
+func have(store upspin.StoreServer, ref upspin.Reference) ([]upspin.Location, error) {
+	sg, ok := store.(upspin.StoreStreamGetter)
+	if !ok {
+		_, _, locs, err := store.Get(ref)
+		return locs, err
+	}
+	rc, _, locs, err := sg.GetStream(ref)
+	if err != nil || rc == nil {
+		return locs, err
+	}
+	return nil, rc.Close()
+}
+
+// get is like StoreServer.Get but uses GetStream if the store offers it,
+// so that the data is not held in memory more than once while in transit.
+func get(store upspin.StoreServer, ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Location, error) {
+	sg, ok := store.(upspin.StoreStreamGetter)
+	if !ok {
+		return store.Get(ref)
+	}
+	rc, refdata, locs, err := sg.GetStream(ref)
+	if err != nil || rc == nil {
+		return nil, nil, locs, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, nil, nil, errors.E(errors.IO, err)
+	}
+	return data, refdata, nil, nil
+}
+
+// A HashMismatchError reports that, in prudent mode, the data read from a
+// Location does not have the SHA-256 hash given by its Reference.
+type HashMismatchError struct {
+	Location upspin.Location
+}
+
+func (e *HashMismatchError) Error() string {
+	return fmt.Sprintf("data for block %s from %s does not match its reference", e.Location.Reference, e.Location.Endpoint)
+}
+
+var unhashed struct {
+	sync.Mutex
+	endpoints map[upspin.Endpoint]bool
+}
+
+// UnhashedReferences records that the references of the store at e are not
+// SHA-256 hashes of their data, even if they look like them, so that data
+// read from it is not checked in prudent mode.
+func UnhashedReferences(e upspin.Endpoint) {
+	unhashed.Lock()
+	defer unhashed.Unlock()
+	if unhashed.endpoints == nil {
+		unhashed.endpoints = make(map[upspin.Endpoint]bool)
+	}
+	unhashed.endpoints[e] = true
+}
+
+// checkHash returns a HashMismatchError if, in prudent mode, loc's
+// reference is a SHA-256 hash, as made by upspin.io/store/server, and
+// data does not match it.
+func checkHash(loc upspin.Location, data []byte) error {
+	if !flags.Prudent {
+		return nil
+	}
+	hash, err := sha256key.Parse(string(loc.Reference))
+	if err != nil {
+		return nil
+	}
+	unhashed.Lock()
+	skip := unhashed.endpoints[loc.Endpoint]
+	unhashed.Unlock()
+	if skip || sha256key.Of(data) == hash {
+		return nil
+	}
+	return errors.E(errors.IO, &HashMismatchError{Location: loc})
 }
@@ -0,0 +1,13 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package clientutil
+
+import "upspin.io/upspin"
+
+// DirRenamer is implemented by clients, such as upspin.io/client, that
+// can rename a directory and everything beneath it.
+type DirRenamer interface {
+	RenameDir(oldName, newName upspin.PathName) (*upspin.DirEntry, error)
+}
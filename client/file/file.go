@@ -3,10 +3,19 @@
 // license that can be found in the LICENSE file.
 
 // Package file implements the File interface used in client.Open and client.Create.
+//
+// A File opened for reading fetches and unpacks only the blocks that hold
+// the bytes requested, so its ReadAt and Seek methods make it suitable for
+// libraries, such as archive/zip, that read a file out of order.
+//
+// A File created for writing buffers its contents in memory. Nothing is
+// stored, and no other reader can see the data, until Close, which writes
+// the whole file in one Put, replacing any file of the same name.
 package file // import "upspin.io/client/file"
 
 import (
 	"io"
+	"sync"
 
 	"upspin.io/client/clientutil"
 	"upspin.io/errors"
@@ -20,9 +29,21 @@ import (
 var maxInt = int64(^uint(0) >> 1)
 
 // File is a simple implementation of upspin.File.
-// It always keeps the whole file in memory under the assumption
-// that it is encrypted and must be read and written atomically.
+// A writable File keeps the whole file in memory under the assumption
+// that it is encrypted and must be written atomically.
+//
+// The offset of a writable File may be moved by Seek beyond the end of
+// the data; that alone does not change the file's length. A subsequent
+// Write there extends the file, and the gap reads as zeros. On Close,
+// blocks of the gap that are entirely zero are not stored if the client
+// supports sparse files.
+//
+// The methods of File are safe for concurrent use, but concurrent calls
+// of Read, Write, and Seek interfere through the shared offset; use
+// ReadAt and WriteAt instead.
 type File struct {
+	mu sync.Mutex // Guards all fields.
+
 	name     upspin.PathName // Full path name.
 	offset   int64           // File location for next read or write operation. Constrained to <= maxInt.
 	writable bool            // File is writable (made with Create, not Open).
@@ -41,10 +62,17 @@ type File struct {
 	// Used only by writers.
 	client upspin.Client // Client the File belongs to.
 	data   []byte        // Contents of file.
+	sparse bool          // A write past the end of data left a gap of zeros.
 }
 
 var _ upspin.File = (*File)(nil)
 
+// sparsePutter is implemented by clients, such as upspin.io/client, that
+// can store a file without the blocks that are entirely zero.
+type sparsePutter interface {
+	PutSparse(name upspin.PathName, data []byte) (*upspin.DirEntry, error)
+}
+
 // Readable creates a new File for the given DirEntry that must be readable
 // using the given Config.
 func Readable(cfg upspin.Config, entry *upspin.DirEntry) (*File, error) {
@@ -94,14 +122,19 @@ func (f *File) Name() upspin.PathName {
 // Read implements upspin.File.
 func (f *File) Read(b []byte) (n int, err error) {
 	const op errors.Op = "file.Read"
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	n, err = f.readAt(op, b, f.offset)
 	f.offset += int64(n)
 	return n, err
 }
 
-// ReadAt implements upspin.File.
+// ReadAt implements upspin.File. It does not change the offset used
+// by Read, Write, and Seek.
 func (f *File) ReadAt(b []byte, off int64) (n int, err error) {
 	const op errors.Op = "file.ReadAt"
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	return f.readAt(op, b, off)
 }
 
@@ -127,7 +160,7 @@ func (f *File) readAt(op errors.Op, dst []byte, off int64) (n int, err error) {
 	for i := range f.entry.Blocks {
 		b := &f.entry.Blocks[i]
 
-		if b.Offset+b.Size < off {
+		if b.Offset+b.Size <= off {
 			// This block is before our interest.
 			continue
 		}
@@ -149,11 +182,8 @@ func (f *File) readAt(op errors.Op, dst []byte, off int64) (n int, err error) {
 			clear = f.lastBlockBytes
 		} else {
 			// Otherwise, we need to read the block and unpack.
-			cipher, err := clientutil.ReadLocation(f.config, b.Location)
-			if err != nil {
-				return 0, errors.E(op, errors.IO, f.name, err)
-			}
-			clear, err = f.bu.Unpack(cipher)
+			var err error
+			clear, err = clientutil.ReadBlock(f.config, f.bu, *b)
 			if err != nil {
 				return 0, errors.E(op, errors.IO, f.name, err)
 			}
@@ -174,9 +204,12 @@ func (f *File) readAt(op errors.Op, dst []byte, off int64) (n int, err error) {
 	return n, err
 }
 
-// Seek implements upspin.File.
+// Seek implements upspin.File. A readable File may not seek beyond its
+// end; a writable one may, up to the largest length of a slice.
 func (f *File) Seek(offset int64, whence int) (ret int64, err error) {
 	const op errors.Op = "file.Seek"
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	if f.closed {
 		return 0, f.errClosed(op)
 	}
@@ -194,7 +227,7 @@ func (f *File) Seek(offset int64, whence int) (ret int64, err error) {
 	default:
 		return 0, errors.E(op, errors.Invalid, f.name, "bad whence")
 	}
-	if ret < 0 || offset > maxInt || !f.writable && ret > f.size {
+	if ret < 0 || ret > maxInt || !f.writable && ret > f.size {
 		return 0, errors.E(op, errors.Invalid, f.name, "bad offset")
 	}
 	f.offset = ret
@@ -204,6 +237,8 @@ func (f *File) Seek(offset int64, whence int) (ret int64, err error) {
 // Write implements upspin.File.
 func (f *File) Write(b []byte) (n int, err error) {
 	const op errors.Op = "file.Write"
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	n, err = f.writeAt(op, b, f.offset)
 	if err == nil {
 		f.offset += int64(n)
@@ -211,9 +246,12 @@ func (f *File) Write(b []byte) (n int, err error) {
 	return n, err
 }
 
-// WriteAt implements upspin.File.
+// WriteAt implements upspin.File. It does not change the offset used
+// by Read, Write, and Seek.
 func (f *File) WriteAt(b []byte, off int64) (n int, err error) {
 	const op errors.Op = "file.WriteAt"
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	return f.writeAt(op, b, off)
 }
 
@@ -242,7 +280,12 @@ func (f *File) writeAt(op errors.Op, b []byte, off int64) (n int, err error) {
 		copy(ndata, f.data)
 		f.data = ndata
 	}
+	if off > int64(len(f.data)) {
+		f.sparse = true
+	}
 	// Capacity is OK now. Fix the length if necessary.
+	// The bytes between the old length and off are zero
+	// because the slice was never extended this far before.
 	if end > int64(len(f.data)) {
 		f.data = f.data[:end]
 	}
@@ -250,9 +293,12 @@ func (f *File) writeAt(op errors.Op, b []byte, off int64) (n int, err error) {
 	return len(b), nil
 }
 
-// Close implements upspin.File.
+// Close implements upspin.File. For a writable File, it stores the
+// data written, which becomes visible to readers only then.
 func (f *File) Close() error {
 	const op errors.Op = "file.Close"
+	f.mu.Lock()
+	defer f.mu.Unlock()
 	if f.closed {
 		return f.errClosed(op)
 	}
@@ -265,7 +311,12 @@ func (f *File) Close() error {
 		}
 		return nil
 	}
-	_, err := f.client.Put(f.name, f.data)
+	var err error
+	if sp, ok := f.client.(sparsePutter); ok && f.sparse {
+		_, err = sp.PutSparse(f.name, f.data)
+	} else {
+		_, err = f.client.Put(f.name, f.data)
+	}
 	f.data = nil // Might as well release it early.
 	return err
 }
@@ -5,6 +5,8 @@
 package file
 
 import (
+	"bytes"
+	"io"
 	"strings"
 	"testing"
 
@@ -15,6 +17,10 @@ func create(name upspin.PathName) upspin.File {
 	return Writable(&dummyClient{}, name)
 }
 
+func createSparse(name upspin.PathName) upspin.File {
+	return Writable(&sparseClient{}, name)
+}
+
 const (
 	dummyData = "This is some dummy data."
 )
@@ -103,6 +109,68 @@ func TestFileOverflow(t *testing.T) {
 	}
 }
 
+func TestSeekPastEnd(t *testing.T) {
+	f := createSparse(fileName)
+	if _, err := f.Write([]byte(dummyData)); err != nil {
+		t.Fatal(err)
+	}
+	// Seeking alone does not extend the file.
+	if _, err := f.Seek(100, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if n, err := f.Seek(0, io.SeekEnd); err != nil || n != int64(len(dummyData)) {
+		t.Fatalf("Seek to end = %d, %v; want %d, nil", n, err, len(dummyData))
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	d := f.(*File).client.(*sparseClient)
+	if d.sparse || string(d.putData) != dummyData {
+		t.Errorf("Close stored %q (sparse %t), want %q (not sparse)", d.putData, d.sparse, dummyData)
+	}
+}
+
+func TestWritePastEnd(t *testing.T) {
+	const gap = 100
+	f := createSparse(fileName)
+	if _, err := f.Write([]byte(dummyData)); err != nil {
+		t.Fatal(err)
+	}
+	// WriteAt does not move the offset used by Write.
+	if _, err := f.WriteAt([]byte("x"), int64(len(dummyData)+gap)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte("y")); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	want := make([]byte, len(dummyData)+gap+1)
+	copy(want, dummyData+"y")
+	want[len(want)-1] = 'x'
+	d := f.(*File).client.(*sparseClient)
+	if !d.sparse || !bytes.Equal(d.putData, want) {
+		t.Errorf("Close stored %q (sparse %t), want %q (sparse)", d.putData, d.sparse, want)
+	}
+}
+
+// sparseClient is a dummyClient that also records whether PutSparse is used.
+type sparseClient struct {
+	dummyClient
+	sparse bool
+}
+
+func (s *sparseClient) Put(name upspin.PathName, data []byte) (*upspin.DirEntry, error) {
+	s.sparse = false
+	return s.dummyClient.Put(name, data)
+}
+
+func (s *sparseClient) PutSparse(name upspin.PathName, data []byte) (*upspin.DirEntry, error) {
+	s.sparse = true
+	return s.dummyClient.Put(name, data)
+}
+
 type dummyClient struct {
 	putData []byte
 }
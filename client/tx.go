@@ -0,0 +1,142 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"crypto/rand"
+	"fmt"
+
+	"upspin.io/errors"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+// ApplyTransaction applies the changes, which may be to the trees of
+// several users served by several directory servers, as a single atomic
+// operation. The changes to each user's tree are applied in order and
+// are subject to the same checks as those of upspin.DirBatcher.ApplyBatch.
+// This requires that the directory server of every tree implement
+// upspin.DirTransactor; otherwise ApplyTransaction makes no change and
+// returns an error.
+//
+// ApplyTransaction coordinates the directory servers using two-phase
+// commit. If a server fails after agreeing to commit, the changes to the
+// other trees are still made and the error returned says which tree was
+// not changed.
+//
+// ApplyTransaction returns, for each change, the entry that ApplyBatch
+// would have returned.
+func (c *Client) ApplyTransaction(changes []upspin.DirChange) ([]*upspin.DirEntry, error) {
+	const op errors.Op = "client.ApplyTransaction"
+	m, _ := newMetric(op)
+	defer m.Done()
+
+	return c.applyTransaction(op, changes)
+}
+
+// A participant is a directory server taking part in a transaction,
+// with the changes to be made to one user's tree.
+type participant struct {
+	user    upspin.UserName
+	dir     upspin.DirTransactor
+	txID    string
+	index   []int // Index in the transaction of each of the changes.
+	changes []upspin.DirChange
+}
+
+func (c *Client) applyTransaction(op errors.Op, changes []upspin.DirChange) ([]*upspin.DirEntry, error) {
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+
+	// Collect the changes to each user's tree.
+	var parts []*participant
+	byUser := make(map[upspin.UserName]*participant)
+	for i, ch := range changes {
+		if ch.Entry == nil {
+			return nil, errors.E(op, errors.Invalid, "nil entry in transaction")
+		}
+		p, err := path.Parse(ch.Entry.Name)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		part, ok := byUser[p.User()]
+		if !ok {
+			dir, err := c.DirServer(p.Path())
+			if err != nil {
+				return nil, errors.E(op, err)
+			}
+			t, ok := dir.(upspin.DirTransactor)
+			if !ok {
+				return nil, errors.E(op, p.Path(), upspin.ErrNotSupported)
+			}
+			// A user's trees may share a server, so each
+			// participant has its own transaction ID.
+			part = &participant{
+				user: p.User(),
+				dir:  t,
+				txID: fmt.Sprintf("%x-%d", id, len(parts)),
+			}
+			byUser[p.User()] = part
+			parts = append(parts, part)
+		}
+		part.index = append(part.index, i)
+		part.changes = append(part.changes, ch)
+	}
+
+	// Phase one: every participant must agree to the changes.
+	for i, part := range parts {
+		if err := part.dir.PreparePut(part.txID, part.changes); err != nil {
+			abortAll(parts[:i])
+			if err == upspin.ErrNotSupported {
+				return nil, errors.E(op, upspin.PathName(part.user+"/"), err)
+			}
+			return nil, errors.E(op, err)
+		}
+	}
+
+	// Phase two: commit everywhere. Until the first commit succeeds
+	// the transaction can still be abandoned.
+	results := make([]*upspin.DirEntry, len(changes))
+	var firstErr error
+	for i, part := range parts {
+		entries, err := part.dir.CommitTx(part.txID)
+		if err != nil {
+			if i == 0 {
+				abortAll(parts)
+				return nil, errors.E(op, err)
+			}
+			if firstErr == nil {
+				firstErr = errors.E(op, upspin.PathName(part.user+"/"), errors.Errorf("transaction partly committed: %v", err))
+			}
+			continue
+		}
+		if len(entries) != len(part.changes) {
+			if firstErr == nil {
+				firstErr = errors.E(op, upspin.PathName(part.user+"/"), errors.Internal, errors.Errorf("server returned %d entries for %d changes", len(entries), len(part.changes)))
+			}
+			continue
+		}
+		for j, e := range entries {
+			results[part.index[j]] = e
+		}
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return results, nil
+}
+
+// abortAll aborts the transaction at each of the participants,
+// ignoring errors: a transaction that is not aborted expires.
+func abortAll(parts []*participant) {
+	for _, part := range parts {
+		part.dir.AbortTx(part.txID)
+	}
+}
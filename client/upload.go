@@ -0,0 +1,211 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"sync"
+
+	"upspin.io/errors"
+	"upspin.io/metric"
+	"upspin.io/upspin"
+)
+
+// An uploader stores the blocks of a file in a StoreServer, running a
+// bounded number of Puts at once so that storing blocks overlaps with
+// packing the next ones.
+type uploader struct {
+	store upspin.StoreServer
+	user  upspin.UserName
+	span  *metric.Span
+	slots chan bool // Holds a value for each Put in progress.
+	wg    sync.WaitGroup
+
+	// stored, if not nil, is called with the ciphertext and reference of
+	// each block once it is stored. Calls are serialized; an error fails
+	// the upload as a failed Put does.
+	stored func(i int, cipher []byte, ref upspin.Reference) error
+
+	mu   sync.Mutex
+	refs []upspin.Reference // Indexed by block number.
+	err  error              // The first error from a Put.
+}
+
+func newUploader(store upspin.StoreServer, user upspin.UserName, concurrency int, s *metric.Span) *uploader {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &uploader{
+		store: store,
+		user:  user,
+		span:  s,
+		slots: make(chan bool, concurrency),
+	}
+}
+
+// put starts storing the ciphertext of block number i, waiting until
+// fewer Puts than the limit are in progress. The uploader keeps the
+// ciphertext, which must not be modified. Once a Put has failed, put
+// starts no more and returns its error.
+func (u *uploader) put(i int, cipher []byte) error {
+	u.slots <- true
+	if err := u.error(); err != nil {
+		<-u.slots
+		return err
+	}
+	u.wg.Add(1)
+	go func() {
+		defer u.wg.Done()
+		defer func() { <-u.slots }()
+		ss := u.span.StartSpan("store.Put")
+		var refdata *upspin.Refdata
+		var err error
+		if mp, ok := u.store.(upspin.StoreMetaPutter); ok {
+			refdata, err = mp.PutWithMeta(cipher, u.user)
+		} else {
+			refdata, err = u.store.Put(cipher)
+		}
+		ss.End()
+
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		if err != nil {
+			if u.err == nil {
+				u.err = err
+			}
+			return
+		}
+		for len(u.refs) <= i {
+			u.refs = append(u.refs, "")
+		}
+		u.refs[i] = refdata.Reference
+		if u.stored != nil && u.err == nil {
+			u.err = u.stored(i, cipher, refdata.Reference)
+		}
+	}()
+	return nil
+}
+
+func (u *uploader) error() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.err
+}
+
+// wait waits for the Puts in progress and returns the references of
+// the stored blocks, indexed by block number, or the first error.
+// Blocks stored before a failure are left in the StoreServer.
+func (u *uploader) wait() ([]upspin.Reference, error) {
+	u.wg.Wait()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.refs, u.err
+}
+
+// An Upload records the progress of a Put so that, should the Put fail,
+// a later Put of the same data with the same block size can resume it,
+// storing only the blocks that were not already stored. See PutOptions.
+// A Put of other data, or to another name, starts the Upload afresh.
+//
+// An Upload holds the file key of packings that encrypt, such as EEPack,
+// wrapped so that only the user can recover it. It need not be kept
+// secret but must be kept only as long as needed.
+type Upload struct {
+	// Name is the path name being put.
+	Name upspin.PathName
+
+	// Source is the SHA-256 hash of the data being put. As a key must
+	// never be used to pack different data, Key is reused only by a
+	// Put of data with this hash to Name.
+	Source []byte
+
+	// Packing is the packing of the blocks.
+	Packing upspin.Packing
+
+	// Key is the file key used to pack the blocks, wrapped for the
+	// user, or nil if the packing has none to reuse. It is set before
+	// any block is stored.
+	Key []byte
+
+	// Blocks holds the blocks stored so far, indexed by block number.
+	// Those not stored have an empty Reference.
+	Blocks []UploadedBlock
+
+	// Save, if not nil, is called once Key is set and each time a block
+	// is stored, so that the progress can be recorded. Calls are
+	// serialized. If Save returns an error, the Put fails.
+	Save func(*Upload) error `json:"-"`
+}
+
+// UploadedBlock describes a block stored by an Upload.
+type UploadedBlock struct {
+	Reference upspin.Reference
+	Sum       []byte // The SHA-256 hash of the block's ciphertext.
+}
+
+// reset forgets the progress recorded in u.
+func (u *Upload) reset() {
+	u.Name = ""
+	u.Source = nil
+	u.Packing = 0
+	u.Key = nil
+	u.Blocks = nil
+}
+
+// uploadSum returns the SHA-256 hash of the data remaining in r, which
+// must be an io.Seeker, leaving r positioned where it was.
+func uploadSum(r io.Reader) ([]byte, error) {
+	s, ok := r.(io.Seeker)
+	if !ok {
+		return nil, errors.E(errors.Invalid, errors.Str("resumable Put needs a reader that can seek"))
+	}
+	start, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	if _, err := s.Seek(start, io.SeekStart); err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	return h.Sum(nil), nil
+}
+
+// storedBlock returns the reference of block i among blocks, the blocks
+// of an Upload, or "" if it was not stored. It is an error if the block
+// was stored with different ciphertext, as the data is then not that of
+// the Put being resumed.
+func storedBlock(blocks []UploadedBlock, i int, cipher []byte) (upspin.Reference, error) {
+	if i >= len(blocks) || blocks[i].Reference == "" {
+		return "", nil
+	}
+	b := blocks[i]
+	if sum := sha256.Sum256(cipher); !bytes.Equal(sum[:], b.Sum) {
+		return "", errors.E(errors.Invalid, errors.Errorf("block %d differs from that of the upload being resumed", i))
+	}
+	return b.Reference, nil
+}
+
+// record records that block i, with the given ciphertext, is stored
+// under ref, and saves the progress.
+func (u *Upload) record(i int, cipher []byte, ref upspin.Reference) error {
+	for len(u.Blocks) <= i {
+		u.Blocks = append(u.Blocks, UploadedBlock{})
+	}
+	sum := sha256.Sum256(cipher)
+	u.Blocks[i] = UploadedBlock{Reference: ref, Sum: sum[:]}
+	return u.save()
+}
+
+func (u *Upload) save() error {
+	if u.Save == nil {
+		return nil
+	}
+	return u.Save(u)
+}
@@ -0,0 +1,259 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package client
+
+import (
+	"bytes"
+	"sync"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"upspin.io/bind"
+	"upspin.io/config"
+	"upspin.io/errors"
+	"upspin.io/flags"
+	"upspin.io/upspin"
+
+	storeserver "upspin.io/store/inprocess"
+)
+
+// slowEndpoint is the endpoint of slow, a StoreServer whose Puts take a while.
+var slowEndpoint = upspin.Endpoint{
+	Transport: upspin.Remote,
+	NetAddr:   "slow.example.com:443",
+}
+
+var slow = &slowStore{StoreServer: storeserver.New()}
+
+func init() {
+	bind.RegisterStoreServer(upspin.Remote, slow)
+}
+
+// slowStore is a StoreServer whose Puts sleep for delay and the failAt'th
+// of which, counting from 1, fails.
+type slowStore struct {
+	upspin.StoreServer
+
+	mu        sync.Mutex
+	delay     time.Duration
+	failAt    int
+	puts      int // Number of Puts started.
+	active    int // Number of Puts in progress.
+	maxActive int // Most Puts in progress at once.
+}
+
+func (s *slowStore) reset(delay time.Duration, failAt int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.delay = delay
+	s.failAt = failAt
+	s.puts = 0
+	s.maxActive = 0
+}
+
+func (s *slowStore) stats() (puts, maxActive int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.puts, s.maxActive
+}
+
+func (s *slowStore) Dial(upspin.Config, upspin.Endpoint) (upspin.Service, error) {
+	return s, nil
+}
+
+func (s *slowStore) Endpoint() upspin.Endpoint {
+	return slowEndpoint
+}
+
+func (s *slowStore) Put(data []byte) (*upspin.Refdata, error) {
+	s.mu.Lock()
+	s.puts++
+	fail := s.puts == s.failAt
+	s.active++
+	if s.active > s.maxActive {
+		s.maxActive = s.active
+	}
+	delay := s.delay
+	s.mu.Unlock()
+
+	time.Sleep(delay)
+
+	s.mu.Lock()
+	s.active--
+	s.mu.Unlock()
+	if fail {
+		return nil, errors.E(errors.IO, errors.Str("injected failure"))
+	}
+	return s.StoreServer.Put(data)
+}
+
+// setupSlow returns a client that stores blocks in slow, and sets the
+// block size and Put concurrency for the duration of the test.
+func setupSlow(t *testing.T, user upspin.UserName, concurrency int) upspin.Client {
+	blockSize, putConcurrency := flags.BlockSize, flags.PutConcurrency
+	t.Cleanup(func() {
+		flags.BlockSize, flags.PutConcurrency = blockSize, putConcurrency
+	})
+	flags.BlockSize = 1024
+	flags.PutConcurrency = concurrency
+	return New(config.SetStoreEndpoint(setup(baseCfg, user), slowEndpoint))
+}
+
+func TestPutConcurrent(t *testing.T) {
+	const (
+		user   = "concurrent@google.com"
+		blocks = 16
+		delay  = 50 * time.Millisecond
+	)
+	data := make([]byte, blocks*1024-100)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	var elapsed [2]time.Duration
+	for i, concurrency := range []int{1, 4} {
+		client := setupSlow(t, user, concurrency)
+		slow.reset(delay, 0)
+		name := upspin.PathName(user + "/file")
+		start := time.Now()
+		entry, err := client.Put(name, data)
+		elapsed[i] = time.Since(start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if puts, max := slow.stats(); puts != blocks || max != concurrency {
+			t.Errorf("concurrency %d: %d Puts, %d at once; want %d, %d", concurrency, puts, max, blocks, concurrency)
+		}
+		if len(entry.Blocks) != blocks {
+			t.Fatalf("concurrency %d: %d blocks, want %d", concurrency, len(entry.Blocks), blocks)
+		}
+		for j, b := range entry.Blocks {
+			if b.Location.Endpoint != slowEndpoint || b.Location.Reference == pendingReference {
+				t.Errorf("concurrency %d: block %d has location %v", concurrency, j, b.Location)
+			}
+			if b.Offset != int64(j*1024) {
+				t.Errorf("concurrency %d: block %d has offset %d", concurrency, j, b.Offset)
+			}
+		}
+		got, err := client.Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("concurrency %d: data does not round trip", concurrency)
+		}
+	}
+
+	// The uploads dominate, so four at once should take not much more
+	// than a quarter of the time.
+	if speedup := float64(elapsed[0]) / float64(elapsed[1]); speedup < 2.5 {
+		t.Errorf("speedup with 4 concurrent Puts is %.1f (%v vs %v), want near 4", speedup, elapsed[0], elapsed[1])
+	}
+}
+
+func TestPutConcurrentFailure(t *testing.T) {
+	const (
+		user   = "failure@google.com"
+		blocks = 16
+		failAt = 5
+	)
+	client := setupSlow(t, user, 4)
+	slow.reset(10*time.Millisecond, failAt)
+	name := upspin.PathName(user + "/file")
+	_, err := client.Put(name, make([]byte, blocks*1024))
+	if !errors.Is(errors.IO, err) {
+		t.Fatalf("Put: err = %v, want IO error", err)
+	}
+	// No Puts should start once the failure is seen; those already
+	// started, or waiting for a slot, may finish.
+	if puts, _ := slow.stats(); puts > failAt+4 {
+		t.Errorf("%d Puts started, want at most %d", puts, failAt+4)
+	}
+	if _, err := client.Lookup(name, false); !errors.Is(errors.NotExist, err) {
+		t.Errorf("Lookup: err = %v, want NotExist", err)
+	}
+}
+
+func TestPutResume(t *testing.T) {
+	const (
+		user   = "resume@google.com"
+		blocks = 8
+		failAt = 4
+	)
+	client := setupSlow(t, user, 1).(*Client)
+	slow.reset(0, failAt)
+	name := upspin.PathName(user + "/file")
+	data := make([]byte, blocks*1024-10)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	// The first attempt stores the blocks before the failure.
+	saves := 0
+	upload := &Upload{Save: func(*Upload) error { saves++; return nil }}
+	_, err := client.PutWithOptions(name, data, PutOptions{Upload: upload})
+	if !errors.Is(errors.IO, err) {
+		t.Fatalf("Put: err = %v, want IO error", err)
+	}
+	if upload.Key == nil {
+		t.Error("no key recorded for EE packing")
+	}
+	if len(upload.Blocks) != failAt-1 || saves != failAt {
+		t.Fatalf("%d blocks recorded with %d saves, want %d with %d", len(upload.Blocks), saves, failAt-1, failAt)
+	}
+
+	// A block the store has lost is stored again.
+	if err := slow.Delete(upload.Blocks[0].Reference); err != nil {
+		t.Fatal(err)
+	}
+
+	// The second attempt stores the rest.
+	slow.reset(0, 0)
+	if _, err := client.PutWithOptions(name, data, PutOptions{Upload: upload}); err != nil {
+		t.Fatal(err)
+	}
+	if puts, _ := slow.stats(); puts != blocks-failAt+2 {
+		t.Errorf("resumed Put stored %d blocks, want %d", puts, blocks-failAt+2)
+	}
+	got, err := client.Get(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("data does not round trip")
+	}
+
+	// Different data, or the same data to another name, is never
+	// packed with the same key; the upload starts again.
+	for _, test := range []struct {
+		name   upspin.PathName
+		change byte
+	}{
+		{name, 1},
+		{user + "/other", 0},
+	} {
+		data[0] += test.change
+		key := upload.Key
+		slow.reset(0, 0)
+		if _, err := client.PutWithOptions(test.name, data, PutOptions{Upload: upload}); err != nil {
+			t.Fatalf("Put to %s: %v", test.name, err)
+		}
+		if puts, _ := slow.stats(); puts != blocks {
+			t.Errorf("Put to %s stored %d blocks, want %d", test.name, puts, blocks)
+		}
+		if bytes.Equal(upload.Key, key) {
+			t.Errorf("Put to %s reused the key", test.name)
+		}
+		if upload.Name != test.name {
+			t.Errorf("upload records name %s, want %s", upload.Name, test.name)
+		}
+	}
+
+	// A resumed stream must be seekable.
+	if _, err := client.PutStream(name, iotest.OneByteReader(bytes.NewReader(data)), PutOptions{Upload: upload}); !errors.Is(errors.Invalid, err) {
+		t.Errorf("PutStream of unseekable reader: err = %v, want Invalid", err)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package https
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"upspin.io/errors"
+)
+
+// CertificateCheck returns a health check, for use with serverutil.Health,
+// that reports the number of days before the TLS certificate served
+// with the given options expires. The check fails once the certificate
+// has expired. It returns nil if the options do not serve HTTPS.
+func CertificateCheck(opt *Options) func() (string, error) {
+	if opt == nil {
+		opt = defaultOptions
+	}
+	if opt.InsecureHTTP || opt.AutocertCache != nil {
+		// There is no certificate file to inspect.
+		return nil
+	}
+	file := opt.CertFile
+	letsEncrypt := opt.LetsEncryptCache != "" && (file == "" || file == defaultOptions.CertFile)
+	if letsEncrypt {
+		if len(opt.LetsEncryptHosts) == 0 {
+			return nil
+		}
+		// The autocert package caches the key and certificate
+		// chain of a host in a file named for the host.
+		file = filepath.Join(opt.LetsEncryptCache, opt.LetsEncryptHosts[0])
+	}
+	if file == "" {
+		file = defaultOptions.CertFile
+	}
+	return func() (string, error) {
+		data, err := os.ReadFile(file)
+		if os.IsNotExist(err) && letsEncrypt {
+			// Let's Encrypt has not issued one yet.
+			return "no certificate yet", nil
+		}
+		if err != nil {
+			return "", errors.E(errors.IO, err)
+		}
+		cert, err := parseCertificate(data)
+		if err != nil {
+			return "", errors.E(errors.Invalid, errors.Errorf("%s: %v", file, err))
+		}
+		left := time.Until(cert.NotAfter)
+		if left <= 0 {
+			return "", errors.E(errors.Invalid, errors.Errorf("certificate expired at %v", cert.NotAfter.UTC()))
+		}
+		return fmt.Sprintf("%d days remaining", int(left.Hours()/24)), nil
+	}
+}
+
+// parseCertificate returns the first certificate among the PEM blocks
+// in data, skipping any others, such as private keys.
+func parseCertificate(data []byte) (*x509.Certificate, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.Str("no certificate found")
+		}
+		if block.Type == "CERTIFICATE" {
+			return x509.ParseCertificate(block.Bytes)
+		}
+	}
+}
@@ -6,19 +6,29 @@
 package disk // import "upspin.io/cloud/storage/disk"
 
 import (
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 
 	"upspin.io/cloud/storage"
 	"upspin.io/cloud/storage/disk/internal/local"
 	"upspin.io/errors"
 	"upspin.io/upspin"
+	"upspin.io/user"
 )
 
 // New initializes and returns a disk-backed storage.Storage with the given
-// options. The single, required option is "basePath" that must be an absolute
+// options. The required option is "basePath" that must be an absolute
 // path under which all objects should be stored.
+//
+// If the option "tenantPrefix" is "true", data stored with PutTenant
+// is kept in a directory named for the user, below the directory
+// "tenants" of the base path, so that each user's data may be measured,
+// backed up or removed separately. Data stored with Put is kept at the
+// top level as usual.
 func New(opts *storage.Opts) (storage.Storage, error) {
 	const op errors.Op = "cloud/storage/disk.New"
 
@@ -26,6 +36,14 @@ func New(opts *storage.Opts) (storage.Storage, error) {
 	if !ok {
 		return nil, errors.E(op, "the basePath option must be specified")
 	}
+	tenants := false
+	if v, ok := opts.Opts["tenantPrefix"]; ok {
+		var err error
+		tenants, err = strconv.ParseBool(v)
+		if err != nil {
+			return nil, errors.E(op, errors.Invalid, errors.Errorf("bad tenantPrefix option %q", v))
+		}
+	}
 	if err := os.MkdirAll(base, 0700); err != nil {
 		return nil, errors.E(op, errors.IO, err)
 	}
@@ -34,7 +52,12 @@ func New(opts *storage.Opts) (storage.Storage, error) {
 		return nil, errors.E(op, errors.IO, err)
 	}
 
-	return &storageImpl{base: base}, nil
+	owners, err := indexTenants(base)
+	if err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+
+	return &storageImpl{base: base, tenants: tenants, owners: owners}, nil
 }
 
 // guaranteeNewEncoding makes sure we are using the new, safe path encoding.
@@ -91,12 +114,27 @@ func init() {
 }
 
 type storageImpl struct {
-	base string
+	base    string
+	tenants bool // Whether PutTenant stores data in per-user directories.
+
+	// owners records, for each reference held in per-user directories,
+	// the users whose directories hold it. It is built by New from the
+	// per-user directories, which are used even if tenantPrefix is not
+	// set, in case it once was.
+	mu     sync.Mutex
+	owners map[string][]upspin.UserName
 }
 
+// tenantsDir is the directory, below the base path, that holds the
+// per-user directories. Its name cannot be confused with the two-byte
+// names of the directories that hold the data stored by Put.
+const tenantsDir = "tenants"
+
 var (
-	_ storage.Storage = (*storageImpl)(nil)
-	_ storage.Lister  = (*storageImpl)(nil)
+	_ storage.Storage      = (*storageImpl)(nil)
+	_ storage.Lister       = (*storageImpl)(nil)
+	_ storage.TenantPutter = (*storageImpl)(nil)
+	_ storage.Streamer     = (*storageImpl)(nil)
 )
 
 // LinkBase implements storage.Storage.
@@ -108,6 +146,13 @@ func (s *storageImpl) LinkBase() (base string, err error) {
 func (s *storageImpl) Download(ref string) ([]byte, error) {
 	const op errors.Op = "cloud/storage/disk.Download"
 	b, err := os.ReadFile(s.path(ref))
+	if os.IsNotExist(err) {
+		for _, p := range s.tenantPaths(ref) {
+			if b, err = os.ReadFile(p); !os.IsNotExist(err) {
+				break
+			}
+		}
+	}
 	if os.IsNotExist(err) {
 		return nil, errors.E(op, errors.NotExist, errors.Str(ref))
 	} else if err != nil {
@@ -116,10 +161,56 @@ func (s *storageImpl) Download(ref string) ([]byte, error) {
 	return b, nil
 }
 
+// DownloadStream implements storage.Streamer.
+func (s *storageImpl) DownloadStream(ref string) (io.ReadCloser, error) {
+	const op errors.Op = "cloud/storage/disk.DownloadStream"
+	f, err := os.Open(s.path(ref))
+	if os.IsNotExist(err) {
+		for _, p := range s.tenantPaths(ref) {
+			if f, err = os.Open(p); !os.IsNotExist(err) {
+				break
+			}
+		}
+	}
+	if os.IsNotExist(err) {
+		return nil, errors.E(op, errors.NotExist, errors.Str(ref))
+	} else if err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	return f, nil
+}
+
 // Put implements storage.Storage.
 func (s *storageImpl) Put(ref string, contents []byte) error {
 	const op errors.Op = "cloud/storage/disk.Put"
-	p := s.path(ref)
+	return put(op, s.path(ref), contents)
+}
+
+// PutTenant implements storage.TenantPutter.
+func (s *storageImpl) PutTenant(ref string, contents []byte, userName upspin.UserName) error {
+	const op errors.Op = "cloud/storage/disk.PutTenant"
+	if !s.tenants {
+		return put(op, s.path(ref), contents)
+	}
+	// The user name becomes a directory name, so it must be valid.
+	if _, _, _, err := user.Parse(userName); err != nil {
+		return errors.E(op, errors.Invalid, err)
+	}
+	if err := put(op, s.tenantPath(userName, ref), contents); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.owners[ref] {
+		if u == userName {
+			return nil
+		}
+	}
+	s.owners[ref] = append(s.owners[ref], userName)
+	return nil
+}
+
+func put(op errors.Op, p string, contents []byte) error {
 	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
 		return errors.E(op, errors.IO, err)
 	}
@@ -130,16 +221,81 @@ func (s *storageImpl) Put(ref string, contents []byte) error {
 }
 
 // Delete implements storage.Storage.
+// It removes every copy of the data, whichever users stored it.
 func (s *storageImpl) Delete(ref string) error {
 	const op errors.Op = "cloud/storage/disk.Delete"
-	if err := os.Remove(s.path(ref)); os.IsNotExist(err) {
+	found := false
+	paths := append([]string{s.path(ref)}, s.tenantPaths(ref)...)
+	for _, p := range paths {
+		if err := os.Remove(p); err == nil {
+			found = true
+		} else if !os.IsNotExist(err) {
+			return errors.E(op, errors.IO, err)
+		}
+	}
+	s.mu.Lock()
+	delete(s.owners, ref)
+	s.mu.Unlock()
+	if !found {
 		return errors.E(op, errors.NotExist, errors.Str(ref))
-	} else if err != nil {
-		return errors.E(op, errors.IO, err)
 	}
 	return nil
 }
 
+// indexTenants returns the users whose directories, below the base
+// path, hold each reference.
+func indexTenants(base string) (map[string][]upspin.UserName, error) {
+	owners := make(map[string][]upspin.UserName)
+	dir := filepath.Join(base, tenantsDir)
+	users, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return owners, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	for _, u := range users {
+		if !u.IsDir() {
+			continue
+		}
+		userName := upspin.UserName(u.Name())
+		userDir := filepath.Join(dir, u.Name())
+		err := filepath.Walk(userDir, func(path string, fi os.FileInfo, err error) error {
+			if err != nil || fi.IsDir() {
+				return err
+			}
+			ref, err := local.Ref(strings.TrimPrefix(path, userDir+string(filepath.Separator)))
+			if err != nil {
+				return err
+			}
+			owners[ref] = append(owners[ref], userName)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return owners, nil
+}
+
+// tenantPath returns the absolute path that should contain ref when it
+// is stored on behalf of the user.
+func (s *storageImpl) tenantPath(userName upspin.UserName, ref string) string {
+	return local.Path(filepath.Join(s.base, tenantsDir, string(userName)), ref)
+}
+
+// tenantPaths returns the absolute paths of the copies of ref held in
+// per-user directories.
+func (s *storageImpl) tenantPaths(ref string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var paths []string
+	for _, u := range s.owners[ref] {
+		paths = append(paths, s.tenantPath(u, ref))
+	}
+	return paths
+}
+
 var maxRefsPerCall = 1000 // A variable so that it may be overridden by tests.
 
 // List implements storage.Lister.
@@ -180,9 +336,15 @@ func (s *storageImpl) List(token string) (refs []upspin.ListRefsItem, next strin
 			return nil
 		}
 
-		// Convert the file path into its reference name
-		// and append it to refs.
-		ref, err := local.Ref(path)
+		// Convert the file path, less any per-user directory,
+		// into its reference name and append it to refs.
+		refPath := path
+		if rest := strings.TrimPrefix(path, tenantsDir+string(filepath.Separator)); rest != path {
+			if i := strings.IndexRune(rest, filepath.Separator); i > 0 {
+				refPath = rest[i+1:]
+			}
+		}
+		ref, err := local.Ref(refPath)
 		if err != nil {
 			return err
 		}
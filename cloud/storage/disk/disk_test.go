@@ -6,11 +6,15 @@ package disk
 
 import (
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"upspin.io/cloud/storage"
+	"upspin.io/cloud/storage/disk/internal/local"
+	"upspin.io/errors"
 	"upspin.io/upspin"
 )
 
@@ -90,6 +94,117 @@ func TestList(t *testing.T) {
 	}
 }
 
+func TestTenantPrefix(t *testing.T) {
+	base, err := os.MkdirTemp("", "upspin-storage-disk-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(base)
+
+	opts := &storage.Opts{Opts: map[string]string{"basePath": base, "tenantPrefix": "true"}}
+	store, err := New(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tp := store.(storage.TenantPutter)
+
+	const (
+		ann = "ann@example.com"
+		bob = "bob@example.com"
+	)
+	data := map[string]upspin.UserName{
+		"ann-ref":  ann,
+		"bob-ref":  bob,
+		"anon-ref": "",
+	}
+	for ref, user := range data {
+		if user == "" {
+			err = store.Put(ref, []byte(ref))
+		} else {
+			err = tp.PutTenant(ref, []byte(ref), user)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tp.PutTenant("bad", nil, "not a user"); err == nil {
+		t.Error("PutTenant with bad user name succeeded")
+	}
+
+	// Each user's data is in their own directory; the rest is not.
+	for ref, user := range data {
+		dir := base
+		if user != "" {
+			dir = filepath.Join(base, tenantsDir, string(user))
+		}
+		if _, err := os.Stat(local.Path(dir, ref)); err != nil {
+			t.Errorf("%s: %v", ref, err)
+		}
+		b, err := store.Download(ref)
+		if err != nil {
+			t.Errorf("Download(%q): %v", ref, err)
+		} else if string(b) != ref {
+			t.Errorf("Download(%q) = %q", ref, b)
+		}
+		rc, err := store.(storage.Streamer).DownloadStream(ref)
+		if err != nil {
+			t.Errorf("DownloadStream(%q): %v", ref, err)
+			continue
+		}
+		b, err = io.ReadAll(rc)
+		rc.Close()
+		if err != nil || string(b) != ref {
+			t.Errorf("DownloadStream(%q) read %q, %v", ref, b, err)
+		}
+	}
+
+	refs, _, err := store.(storage.Lister).List("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != len(data) {
+		t.Errorf("List returned %d refs, want %d: %v", len(refs), len(data), refs)
+	}
+	for _, r := range refs {
+		if _, ok := data[string(r.Ref)]; !ok {
+			t.Errorf("List returned unexpected ref %q", r.Ref)
+		}
+	}
+
+	// The same data stored by two users is deleted for both.
+	if err := tp.PutTenant("ann-ref", []byte("ann-ref"), bob); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("ann-ref"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Download("ann-ref"); !errors.Is(errors.NotExist, err) {
+		t.Errorf("Download after Delete: got %v, want NotExist", err)
+	}
+	if _, err := store.(storage.Streamer).DownloadStream("ann-ref"); !errors.Is(errors.NotExist, err) {
+		t.Errorf("DownloadStream after Delete: got %v, want NotExist", err)
+	}
+	if err := store.Delete("ann-ref"); !errors.Is(errors.NotExist, err) {
+		t.Errorf("second Delete: got %v, want NotExist", err)
+	}
+
+	// Without the option, PutTenant stores at the top level.
+	plain, err := New(&storage.Opts{Opts: map[string]string{"basePath": base}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := plain.(storage.TenantPutter).PutTenant("plain-ref", nil, ann); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(local.Path(base, "plain-ref")); err != nil {
+		t.Error(err)
+	}
+	// But it finds the data in the per-user directories.
+	if b, err := plain.Download("bob-ref"); err != nil || string(b) != "bob-ref" {
+		t.Errorf("Download(%q) without tenantPrefix = %q, %v", "bob-ref", b, err)
+	}
+}
+
 func randomBytes(n int) []byte {
 	b := make([]byte, n)
 	rand.Read(b)
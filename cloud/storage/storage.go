@@ -7,6 +7,7 @@
 package storage // import "upspin.io/cloud/storage"
 
 import (
+	"io"
 	"strings"
 
 	"upspin.io/errors"
@@ -45,6 +46,22 @@ type Lister interface {
 	List(token string) (refs []upspin.ListRefsItem, nextToken string, err error)
 }
 
+// TenantPutter is implemented by Storage backends that can store each
+// user's data separately. Download and Delete find the data whichever way
+// it was stored.
+type TenantPutter interface {
+	// PutTenant stores the contents given as ref on behalf of the user.
+	PutTenant(ref string, contents []byte, user upspin.UserName) error
+}
+
+// Streamer is implemented by Storage backends that can read data
+// incrementally, so that large items need not be held in memory.
+type Streamer interface {
+	// DownloadStream returns a reader of the bytes associated with a ref.
+	// The caller must close it.
+	DownloadStream(ref string) (io.ReadCloser, error)
+}
+
 // StorageConstructor is a function that initializes and returns a Storage
 // implementation with the given options.
 type StorageConstructor func(*Opts) (Storage, error)
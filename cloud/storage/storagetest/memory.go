@@ -5,6 +5,7 @@
 package storagetest
 
 import (
+	"sort"
 	"sync"
 
 	"upspin.io/cloud/storage"
@@ -13,6 +14,7 @@ import (
 )
 
 // Memory returns a storage.Storage implementation that stores data in memory.
+// It also implements storage.Lister, returning all refs in a single call.
 // It is safe for concurrent use.
 func Memory() storage.Storage {
 	return &mem{
@@ -59,3 +61,16 @@ func (m *mem) Delete(ref string) error {
 	delete(m.m, ref)
 	return nil
 }
+
+// List implements storage.Lister.
+func (m *mem) List(token string) ([]upspin.ListRefsItem, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var refs []upspin.ListRefsItem
+	for ref, b := range m.m {
+		refs = append(refs, upspin.ListRefsItem{Ref: upspin.Reference(ref), Size: int64(len(b))})
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].Ref < refs[j].Ref })
+	return refs, "", nil
+}
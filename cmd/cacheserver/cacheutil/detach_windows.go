@@ -0,0 +1,27 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// +build windows
+
+package cacheutil
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detachedProcess is the DETACHED_PROCESS process creation flag, which
+// the syscall package does not define.
+const detachedProcess = 0x00000008
+
+func init() {
+	// Start the cacheserver without a console and in its own process
+	// group, so that it outlives the console of the program that
+	// started it and does not receive its Ctrl-C.
+	detach = func(cmd *exec.Cmd) {
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess,
+		}
+	}
+}
@@ -47,8 +47,9 @@ func Start(cfg upspin.Config) (usingCache bool) {
 	// Start a cache server.
 	cacheErrorChan := make(chan bool)
 	go func() {
-		args := []string{"-log=" + log.GetLevel()}
+		args := []string{"-log=" + log.GetLevels()}
 		args = addFlag(args, "config")
+		args = addFlag(args, "logformat")
 		args = addFlag(args, "addr")
 		args = addFlag(args, "cachedir")
 		args = addFlag(args, "cachesize")
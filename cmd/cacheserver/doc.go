@@ -32,13 +32,31 @@ Usage:
 The flags are:
 
 	-log=level
- 		Set the log level to 'level'.
+ 		Set the log level to 'level'. Levels of subsystems may follow,
+		as in info,store/storecache=debug; the cacheserver's own
+		subsystems are cmd/cacheserver, dir/dircache and store/storecache.
 	-cachedir=directory
 		Cache all state in 'directory'/{storecache,dircache}.
 	-writethrough
 		Make storage cache writethrough.
 	-cachesize=bytes
 		Set the maximum bytes usable for the on disk cache to 'bytes'.
+	-sharedcache=bytes
+		Also keep up to 'bytes' of recently used blocks in memory shared
+		with other cacheservers using the same cachedir, such as those
+		for other users. The default, 0, disables the shared cache.
+	-overflowsize=bytes
+		Blocks of files being read are pinned in the cache until the
+		read completes. If the cache is full and all of it is pinned,
+		keep up to 'bytes' of newly fetched blocks in memory instead.
+		The default is 64MB.
+	-warmup-file=file
+		At startup, fetch into the cache in the background the blocks
+		listed in 'file', one per line as an endpoint and a reference
+		separated by a space. Such a file is written by the
+		generate-warmup subcommand of upspin-audit. The cacheserver
+		serves requests meanwhile; its progress is reported at
+		/debug/warmup-status.
 
 Example $HOME/upspin/config entry:
 
@@ -15,6 +15,8 @@ import (
 	"upspin.io/version"
 )
 
+var logger = log.For("cmd/cacheserver")
+
 const cmdName = "cacheserver"
 
 func main() {
@@ -29,12 +31,12 @@ func main() {
 	// Load configuration and keys for this server. It needn't have a real username.
 	cfg, err := config.FromFile(flags.Config)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal(err)
 	}
 
 	// Set any flags contained in the config.
 	if err := config.SetFlagValues(cfg, cmdName); err != nil {
-		log.Fatalf("%s: %s", cmdName, err)
+		logger.Fatalf("%s: %s", cmdName, err)
 	}
 
 	// Serving address comes from config with flag overriding.
@@ -47,16 +49,16 @@ func main() {
 		addr = flags.NetAddr
 	}
 	if len(addr) == 0 {
-		log.Fatalf("no storage/dir cache network address specified")
+		logger.Fatalf("no storage/dir cache network address specified")
 	}
 
 	// Start the server and wait until it terminates.
 	done, err := serve(cfg, addr)
 	if err != nil {
-		log.Fatalf("cacheserver: %s", err)
+		logger.Fatalf("cacheserver: %s", err)
 	}
 	if err := <-done; err != nil {
-		log.Fatalf("cacheserver: %s", err)
+		logger.Fatalf("cacheserver: %s", err)
 	}
 }
 
@@ -11,6 +11,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"upspin.io/cache/shm"
 	"upspin.io/config"
 	"upspin.io/dir/dircache"
 	"upspin.io/flags"
@@ -18,6 +19,7 @@ import (
 	"upspin.io/rpc/dirserver"
 	"upspin.io/rpc/local"
 	"upspin.io/rpc/storeserver"
+	"upspin.io/serverutil"
 	"upspin.io/store/storecache"
 	"upspin.io/upspin"
 
@@ -32,6 +34,9 @@ import (
 
 var (
 	writethrough = flag.Bool("writethrough", false, "make storage cache writethrough")
+	sharedCache  = flag.Int64("sharedcache", 0, "share up to `bytes` of cached blocks in memory with other processes")
+	overflowSize = flag.Int64("overflowsize", 64<<20, "keep up to `bytes` of blocks in memory when the cache is full of pinned blocks")
+	warmupFile   = flag.String("warmup-file", "", "at startup, fetch into the cache the blocks listed in `file`")
 )
 
 func serve(cfg upspin.Config, addr string) (<-chan error, error) {
@@ -47,12 +52,32 @@ func serve(cfg upspin.Config, addr string) (<-chan error, error) {
 	// Link old structure cache files into the new structure.
 	relocate(flags.CacheDir, myCacheDir)
 
-	sc, blockFlusher, err := storecache.New(uncachedCfg, myCacheDir, maxRefBytes, *writethrough)
+	// The shared cache is common to all users' cache servers.
+	var shared *shm.Cache
+	if *sharedCache > 0 {
+		var err error
+		shared, err = shm.Open(flags.CacheDir, *sharedCache)
+		if err != nil {
+			logger.Error.Printf("cacheserver: not sharing cache: %v", err)
+			shared = nil
+		}
+	}
+
+	sc, blockFlusher, err := storecache.New(uncachedCfg, myCacheDir, maxRefBytes, *overflowSize, *writethrough, shared)
 	if err != nil {
 		return nil, err
 	}
 	ss := storeserver.New(uncachedCfg, sc, "")
 
+	// Warm up the cache in the background.
+	var wu *warmup
+	if *warmupFile != "" {
+		wu, err = startWarmup(uncachedCfg, sc, *warmupFile)
+		if err != nil {
+			logger.Error.Printf("cacheserver: not warming up: %v", err)
+		}
+	}
+
 	dc, err := dircache.New(uncachedCfg, cachedCfg, myCacheDir, maxLogBytes, blockFlusher)
 	if err != nil {
 		return nil, err
@@ -75,6 +100,14 @@ func serve(cfg upspin.Config, addr string) (<-chan error, error) {
 	mux.Handle("/api/Store/", ss)
 	mux.Handle("/api/Dir/", ds)
 	mux.Handle("/debug/vars", expvar.Handler())
+	mux.Handle("/debug/warmup-status", wu)
+
+	h := serverutil.NewHealth()
+	h.Add("keyserver", serverutil.KeyServerCheck(uncachedCfg))
+	h.Add("cachedir", serverutil.WritableCheck(myCacheDir))
+	h.Start()
+	mux.Handle("/healthz", h)
+
 	done := make(chan error)
 	go func() {
 		done <- httpServer.Serve(ln)
@@ -95,7 +128,7 @@ func relocate(old, new string) {
 		return
 	}
 	if err := os.MkdirAll(new, 0700); err != nil {
-		log.Debug.Printf("cacheserver/relocate: %s", err)
+		logger.Debug.Printf("cacheserver/relocate: %s", err)
 		return
 	}
 	walkAndMove(old, new, "storewritebackqueue", nil)
@@ -111,7 +144,7 @@ func walkAndMove(oldDir, newDir, name string, info os.FileInfo) {
 		var err error
 		info, err = os.Stat(old)
 		if err != nil {
-			log.Debug.Printf("cacheserver/walkAndMove: %s", err)
+			logger.Debug.Printf("cacheserver/walkAndMove: %s", err)
 			return
 		}
 	}
@@ -119,25 +152,25 @@ func walkAndMove(oldDir, newDir, name string, info os.FileInfo) {
 	// Link files into new directory structure.
 	if !info.Mode().IsDir() {
 		if err := os.Link(old, new); err != nil {
-			log.Debug.Printf("cacheserver/walkAndMove: %s", err)
+			logger.Debug.Printf("cacheserver/walkAndMove: %s", err)
 		}
 		return
 	}
 	if err := os.MkdirAll(new, 0700); err != nil {
-		log.Debug.Printf("cacheserver/walkAndMove: %s", err)
+		logger.Debug.Printf("cacheserver/walkAndMove: %s", err)
 		return
 	}
 
 	// Read and descend directories.
 	f, err := os.Open(old)
 	if err != nil {
-		log.Debug.Printf("cacheserver/walkAndMove: %s", err)
+		logger.Debug.Printf("cacheserver/walkAndMove: %s", err)
 		return
 	}
 	infos, err := f.Readdir(0)
 	f.Close()
 	if err != nil {
-		log.Debug.Printf("cacheserver/walkAndMove: %s", err)
+		logger.Debug.Printf("cacheserver/walkAndMove: %s", err)
 		return
 
 	}
@@ -0,0 +1,148 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// warmupParallelism is the number of blocks fetched at once during warm-up.
+const warmupParallelism = 4
+
+// warmup fetches the blocks listed in a file into the store cache, so that
+// a restarted cacheserver need not start cold. It runs in the background
+// and reports its progress through ServeHTTP.
+type warmup struct {
+	file string
+	locs []upspin.Location
+
+	mu      sync.Mutex
+	fetched int  // Blocks fetched so far.
+	failed  int  // Blocks that could not be fetched.
+	done    bool // All blocks have been tried.
+}
+
+// startWarmup reads the list of blocks in file and starts fetching them
+// through store, which should be the store cache.
+//
+// Each line of the file holds an endpoint and a reference separated by a
+// space, as written by upspin-audit generate-warmup. Blank lines and
+// lines beginning with '#' are ignored.
+func startWarmup(cfg upspin.Config, store upspin.StoreServer, file string) (*warmup, error) {
+	locs, err := readWarmupFile(file)
+	if err != nil {
+		return nil, err
+	}
+	w := &warmup{
+		file: file,
+		locs: locs,
+	}
+	go w.run(cfg, store)
+	return w, nil
+}
+
+// readWarmupFile returns the locations listed in the named file.
+func readWarmupFile(file string) ([]upspin.Location, error) {
+	const op errors.Op = "cacheserver.readWarmupFile"
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	defer f.Close()
+	var locs []upspin.Location
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		ep, ref, ok := strings.Cut(line, " ")
+		ref = strings.TrimSpace(ref)
+		if !ok || ref == "" {
+			return nil, errors.E(op, errors.Invalid, errors.Errorf("%s:%d: want endpoint and reference", file, n))
+		}
+		e, err := upspin.ParseEndpoint(ep)
+		if err != nil {
+			return nil, errors.E(op, errors.Invalid, errors.Errorf("%s:%d: %v", file, n, err))
+		}
+		locs = append(locs, upspin.Location{Endpoint: *e, Reference: upspin.Reference(ref)})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	return locs, nil
+}
+
+// run fetches the blocks, a few at a time.
+func (w *warmup) run(cfg upspin.Config, store upspin.StoreServer) {
+	work := make(chan upspin.Location)
+	var wg sync.WaitGroup
+	for i := 0; i < warmupParallelism; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for loc := range work {
+				w.record(fetch(cfg, store, loc))
+			}
+		}()
+	}
+	for _, loc := range w.locs {
+		work <- loc
+	}
+	close(work)
+	wg.Wait()
+
+	w.mu.Lock()
+	w.done = true
+	logger.Info.Printf("cacheserver: warm-up from %s done: %d blocks fetched, %d failed", w.file, w.fetched, w.failed)
+	w.mu.Unlock()
+}
+
+// fetch reads the block at loc through the store cache, which keeps it.
+func fetch(cfg upspin.Config, store upspin.StoreServer, loc upspin.Location) error {
+	svc, err := store.Dial(cfg, loc.Endpoint)
+	if err != nil {
+		return err
+	}
+	_, _, _, err = svc.(upspin.StoreServer).Get(loc.Reference)
+	return err
+}
+
+// record counts the result of fetching a block.
+func (w *warmup) record(err error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err != nil {
+		logger.Debug.Printf("cacheserver: warm-up: %v", err)
+		w.failed++
+		return
+	}
+	w.fetched++
+}
+
+// ServeHTTP reports the progress of the warm-up, one "field: value"
+// pair per line.
+func (w *warmup) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if w == nil {
+		fmt.Fprintln(rw, "file: none")
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	fmt.Fprintf(rw, "file: %s\n", w.file)
+	fmt.Fprintf(rw, "blocks: %d\n", len(w.locs))
+	fmt.Fprintf(rw, "fetched: %d\n", w.fetched)
+	fmt.Fprintf(rw, "failed: %d\n", w.failed)
+	fmt.Fprintf(rw, "done: %t\n", w.done)
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// countingStore is a StoreServer that records the blocks fetched from it.
+type countingStore struct {
+	upspin.StoreServer
+
+	mu      sync.Mutex
+	fetched map[upspin.Location]bool
+}
+
+// dialedStore is a countingStore dialed for an endpoint.
+type dialedStore struct {
+	upspin.StoreServer
+	store    *countingStore
+	endpoint upspin.Endpoint
+}
+
+func (s *countingStore) Dial(cfg upspin.Config, e upspin.Endpoint) (upspin.Service, error) {
+	return &dialedStore{store: s, endpoint: e}, nil
+}
+
+func (s *dialedStore) Get(ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Location, error) {
+	if ref == "missing" {
+		return nil, nil, nil, errors.E(errors.NotExist)
+	}
+	s.store.mu.Lock()
+	s.store.fetched[upspin.Location{Endpoint: s.endpoint, Reference: ref}] = true
+	s.store.mu.Unlock()
+	return []byte("data"), nil, nil, nil
+}
+
+func TestWarmup(t *testing.T) {
+	dir, err := os.MkdirTemp("", "cacheserver")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "warmup")
+	const list = "# Blocks to fetch.\n" +
+		"remote,store.example.com:443 ref1\n" +
+		"\n" +
+		"remote,store.example.com:443 missing\n" +
+		"remote,other.example.com:443 ref2\n"
+	if err := os.WriteFile(file, []byte(list), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	store := &countingStore{fetched: make(map[upspin.Location]bool)}
+	w, err := startWarmup(nil, store, file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var status string
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		rec := httptest.NewRecorder()
+		w.ServeHTTP(rec, httptest.NewRequest("GET", "/debug/warmup-status", nil))
+		status = rec.Body.String()
+		if strings.Contains(status, "done: true") {
+			break
+		}
+	}
+	want := "file: " + file + "\nblocks: 3\nfetched: 2\nfailed: 1\ndone: true\n"
+	if status != want {
+		t.Errorf("status:\n%s\nwant:\n%s", status, want)
+	}
+	for _, loc := range []string{"remote,store.example.com:443 ref1", "remote,other.example.com:443 ref2"} {
+		ep, ref, _ := strings.Cut(loc, " ")
+		e, err := upspin.ParseEndpoint(ep)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !store.fetched[upspin.Location{Endpoint: *e, Reference: upspin.Reference(ref)}] {
+			t.Errorf("%s not fetched", loc)
+		}
+	}
+
+	// A malformed file is rejected.
+	if err := os.WriteFile(file, []byte("remote,store.example.com:443\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := startWarmup(nil, store, file); !errors.Is(errors.Invalid, err) {
+		t.Errorf("malformed file: err = %v, want Invalid", err)
+	}
+}
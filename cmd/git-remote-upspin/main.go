@@ -0,0 +1,385 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Git-remote-upspin is a git remote helper that stores repositories in
+// Upspin. With it installed in the PATH, a repository at the Upspin
+// directory ann@example.com/repos/project may be cloned with
+//
+//	git clone upspin::ann@example.com/repos/project
+//
+// and pushed to and fetched from like any other remote. Access to the
+// repository is controlled by the Access files of the directory.
+//
+// The helper uses the Upspin config named by the git configuration
+// variable upspin.config, or $HOME/upspin/config if that is not set.
+//
+// The repository's references are held in a single file whose updates
+// are checked against its sequence number, so concurrent pushes cannot
+// overwrite each other's changes; a push that would lose commits is
+// rejected. Objects are stored as pack files, each accompanied by a
+// small description of what it holds, so a fetch reads only the packs
+// it needs.
+package main // import "upspin.io/cmd/git-remote-upspin"
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"upspin.io/client"
+	"upspin.io/config"
+	"upspin.io/errors"
+	"upspin.io/flags"
+	"upspin.io/path"
+	"upspin.io/transports"
+	"upspin.io/upspin"
+
+	_ "upspin.io/pack/ee"
+	_ "upspin.io/pack/eeintegrity"
+	_ "upspin.io/pack/plain"
+)
+
+const cmdName = "git-remote-upspin"
+
+func main() {
+	if len(os.Args) != 3 {
+		fmt.Fprintf(os.Stderr, "Usage: %s <remote> <url>\n", cmdName)
+		fmt.Fprintf(os.Stderr, "%s is run by git for URLs of the form upspin::user@domain/path\n", cmdName)
+		os.Exit(2)
+	}
+	if err := run(os.Args[2], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", cmdName, err)
+		os.Exit(1)
+	}
+}
+
+func run(url string, in io.Reader, out io.Writer) error {
+	root, err := path.Parse(upspin.PathName(strings.TrimPrefix(url, "upspin://")))
+	if err != nil {
+		return err
+	}
+	file := flags.Config
+	if b, err := exec.Command("git", "config", "--get", "upspin.config").Output(); err == nil {
+		file = strings.TrimSpace(string(b))
+	}
+	cfg, err := config.FromFile(file)
+	if err != nil {
+		return err
+	}
+	transports.Init(cfg)
+	h := &helper{
+		remote: &remote{cli: client.New(cfg), root: root.Path()},
+		in:     bufio.NewReader(in),
+		out:    bufio.NewWriter(out),
+	}
+	return h.serve()
+}
+
+// helper speaks the git remote helper protocol; see gitremote-helpers(7).
+type helper struct {
+	remote *remote
+	in     *bufio.Reader
+	out    *bufio.Writer
+}
+
+// serve reads commands from git until it is done.
+func (h *helper) serve() error {
+	for {
+		line, err := h.readLine()
+		if err == io.EOF || line == "" && err == nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		cmd := strings.Fields(line)
+		switch cmd[0] {
+		case "capabilities":
+			fmt.Fprint(h.out, "fetch\npush\n\n")
+		case "list":
+			err = h.list()
+		case "fetch":
+			err = h.fetch(line)
+		case "push":
+			err = h.push(line)
+		default:
+			err = errors.Errorf("unknown command %q", line)
+		}
+		if err != nil {
+			return err
+		}
+		if err := h.out.Flush(); err != nil {
+			return err
+		}
+	}
+}
+
+func (h *helper) readLine() (string, error) {
+	line, err := h.in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+	return strings.TrimSuffix(line, "\n"), err
+}
+
+// batch returns the command line and those following it up to a blank line.
+func (h *helper) batch(first string) ([]string, error) {
+	lines := []string{first}
+	for {
+		line, err := h.readLine()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if line == "" {
+			return lines, nil
+		}
+		lines = append(lines, line)
+	}
+}
+
+func (h *helper) list() error {
+	refs, _, err := h.remote.readRefs()
+	if err != nil {
+		return err
+	}
+	h.out.Write(refs.bytes())
+	h.out.WriteString("\n")
+	return nil
+}
+
+// fetch reads the packs holding the requested objects, and those
+// holding the objects they depend on, until the local repository
+// has everything it needs.
+func (h *helper) fetch(first string) error {
+	lines, err := h.batch(first)
+	if err != nil {
+		return err
+	}
+	var want []string
+	for _, line := range lines {
+		f := strings.Fields(line)
+		if len(f) < 2 || f[0] != "fetch" {
+			return errors.Errorf("bad fetch command %q", line)
+		}
+		want = append(want, f[1])
+	}
+
+	packs, err := h.remote.packs()
+	if err != nil {
+		return err
+	}
+	holder := make(map[string]*packInfo)
+	for _, p := range packs {
+		for _, sha := range p.objects {
+			holder[sha] = p
+		}
+	}
+	fetched := make(map[string]bool)
+	for len(want) > 0 {
+		sha := want[0]
+		want = want[1:]
+		if hasObject(sha) {
+			continue
+		}
+		p, ok := holder[sha]
+		if !ok {
+			return errors.E(errors.NotExist, errors.Errorf("object %s not found in %s", sha, h.remote.root))
+		}
+		if fetched[p.name] {
+			continue
+		}
+		if err := h.fetchPack(p.name); err != nil {
+			return err
+		}
+		fetched[p.name] = true
+		want = append(want, p.bases...)
+	}
+	h.out.WriteString("\n")
+	return nil
+}
+
+// fetchPack streams the named pack into the local repository.
+func (h *helper) fetchPack(name string) error {
+	f, err := h.remote.openPack(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	cmd := exec.Command("git", "index-pack", "--stdin")
+	cmd.Stdin = f
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return errors.Errorf("git index-pack of %s: %v", name, err)
+	}
+	return nil
+}
+
+// refUpdate is a reference update requested by a push.
+type refUpdate struct {
+	src, dst string
+	sha      string // Empty to delete dst.
+	force    bool
+}
+
+// maxAttempts is the number of times to try to update the references
+// when other pushes change them concurrently.
+const maxAttempts = 10
+
+func (h *helper) push(first string) error {
+	lines, err := h.batch(first)
+	if err != nil {
+		return err
+	}
+	var updates []*refUpdate
+	for _, line := range lines {
+		spec := strings.TrimPrefix(line, "push ")
+		u := &refUpdate{force: strings.HasPrefix(spec, "+")}
+		spec = strings.TrimPrefix(spec, "+")
+		i := strings.IndexByte(spec, ':')
+		if i < 0 {
+			return errors.Errorf("bad push command %q", line)
+		}
+		u.src, u.dst = spec[:i], spec[i+1:]
+		if u.src != "" {
+			if u.sha, err = git("rev-parse", u.src); err != nil {
+				return err
+			}
+		}
+		updates = append(updates, u)
+	}
+
+	refs, seq, err := h.remote.readRefs()
+	if err != nil {
+		return err
+	}
+	if err := h.sendObjects(refs, updates); err != nil {
+		return err
+	}
+	results := make(map[string]string)
+	for attempt := 0; ; attempt++ {
+		for _, u := range updates {
+			results[u.dst] = checkUpdate(refs, u)
+		}
+		for _, u := range updates {
+			if results[u.dst] != "" {
+				continue
+			}
+			if u.sha == "" {
+				delete(refs.refs, u.dst)
+				continue
+			}
+			refs.refs[u.dst] = u.sha
+			if refs.head == "" && strings.HasPrefix(u.dst, "refs/heads/") {
+				refs.head = u.dst
+			}
+		}
+		err = h.remote.writeRefs(refs, seq)
+		if err != errConflict || attempt == maxAttempts {
+			break
+		}
+		// Someone else pushed. Check the updates again.
+		if refs, seq, err = h.remote.readRefs(); err != nil {
+			return err
+		}
+	}
+	for _, u := range updates {
+		switch {
+		case results[u.dst] != "":
+			fmt.Fprintf(h.out, "error %s %s\n", u.dst, results[u.dst])
+		case err != nil:
+			fmt.Fprintf(h.out, "error %s %v\n", u.dst, err)
+		default:
+			fmt.Fprintf(h.out, "ok %s\n", u.dst)
+		}
+	}
+	h.out.WriteString("\n")
+	return nil
+}
+
+// checkUpdate returns why the update may not be made to the refs, or
+// the empty string if it may. Unless forced, an update must not lose
+// commits that the ref now holds.
+func checkUpdate(refs *refs, u *refUpdate) string {
+	old, ok := refs.refs[u.dst]
+	if !ok || u.force || u.sha == "" || old == u.sha {
+		return ""
+	}
+	if !hasObject(old) {
+		return "fetch first"
+	}
+	if _, err := git("merge-base", "--is-ancestor", old, u.sha); err != nil {
+		return "non-fast-forward"
+	}
+	return ""
+}
+
+// sendObjects stores a pack holding the objects needed by the updates
+// that the remote does not already have.
+func (h *helper) sendObjects(refs *refs, updates []*refUpdate) error {
+	info := &packInfo{}
+	for _, sha := range refs.refs {
+		if hasObject(sha) {
+			info.bases = append(info.bases, sha)
+		}
+	}
+	var tips []string
+	for _, u := range updates {
+		if u.sha != "" {
+			tips = append(tips, u.sha)
+		}
+	}
+	if len(tips) == 0 {
+		return nil
+	}
+	var revs bytes.Buffer
+	for _, sha := range tips {
+		fmt.Fprintln(&revs, sha)
+	}
+	for _, sha := range info.bases {
+		fmt.Fprintln(&revs, "^"+sha)
+	}
+	cmd := exec.Command("git", "pack-objects", "--revs", "--stdout", "-q")
+	cmd.Stdin = bytes.NewReader(revs.Bytes())
+	cmd.Stderr = os.Stderr
+	pack, err := cmd.Output()
+	if err != nil {
+		return errors.Errorf("git pack-objects: %v", err)
+	}
+	// The object count follows the 4-byte signature and version.
+	if len(pack) < 12 || bytes.Equal(pack[8:12], []byte{0, 0, 0, 0}) {
+		return nil
+	}
+	args := append([]string{"rev-list"}, tips...)
+	if len(info.bases) > 0 {
+		args = append(append(args, "--not"), info.bases...)
+	}
+	commits, err := git(args...)
+	if err != nil {
+		return err
+	}
+	info.objects = append(strings.Fields(commits), tips...)
+	return h.remote.putPack(pack, info)
+}
+
+// hasObject reports whether the local repository holds the object.
+func hasObject(sha string) bool {
+	_, err := git("cat-file", "-e", sha)
+	return err == nil
+}
+
+// git runs git with the arguments and returns its trimmed output.
+func git(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return "", errors.Errorf("git %s: %v: %s", strings.Join(args, " "), err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
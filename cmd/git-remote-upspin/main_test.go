@@ -0,0 +1,196 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"upspin.io/client"
+	"upspin.io/config"
+	"upspin.io/transports"
+	"upspin.io/upbox"
+)
+
+const (
+	userName = "ann@example.com"
+	repoURL  = "upspin::" + userName + "/repos/project"
+)
+
+// gitTest runs git commands with the helper installed, in a home
+// directory whose git configuration points at an upbox cluster.
+type gitTest struct {
+	t   *testing.T
+	dir string
+	env []string
+}
+
+func (g *gitTest) run(dir string, args ...string) (string, error) {
+	g.t.Helper()
+	cmd := exec.Command("git", args...)
+	cmd.Dir = filepath.Join(g.dir, dir)
+	cmd.Env = g.env
+	out, err := cmd.CombinedOutput()
+	return string(out), err
+}
+
+func (g *gitTest) git(dir string, args ...string) string {
+	g.t.Helper()
+	out, err := g.run(dir, args...)
+	if err != nil {
+		g.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(out)
+}
+
+// commit writes the file and commits it.
+func (g *gitTest) commit(dir, file, data string) {
+	g.t.Helper()
+	if err := os.WriteFile(filepath.Join(g.dir, dir, file), []byte(data), 0644); err != nil {
+		g.t.Fatal(err)
+	}
+	g.git(dir, "add", file)
+	g.git(dir, "commit", "-q", "-m", "update "+file)
+}
+
+func (g *gitTest) read(dir, file string) string {
+	g.t.Helper()
+	b, err := os.ReadFile(filepath.Join(g.dir, dir, file))
+	if err != nil {
+		g.t.Fatal(err)
+	}
+	return string(b)
+}
+
+func setupGit(t *testing.T) (*gitTest, func()) {
+	if testing.Short() {
+		t.Skip("skipping upbox test in short mode")
+	}
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir, err := os.MkdirTemp("", "git-remote-upspin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	schema, err := upbox.SchemaFromYAML(`
+users:
+- name: ` + userName + `
+servers:
+- name: keyserver
+- name: storeserver
+- name: dirserver
+  flags:
+    kind: server
+domain: example.com
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := schema.Start(); err != nil {
+		t.Fatal(err)
+	}
+	cleanup := func() {
+		schema.Stop()
+		os.RemoveAll(dir)
+	}
+
+	cfg, err := config.FromFile(schema.Config(userName))
+	if err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	transports.Init(cfg)
+	if _, err := client.New(cfg).MakeDirectory(userName + "/"); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+
+	bin := filepath.Join(dir, "bin")
+	if out, err := exec.Command("go", "build", "-o", filepath.Join(bin, cmdName), ".").CombinedOutput(); err != nil {
+		cleanup()
+		t.Fatalf("building %s: %v\n%s", cmdName, err, out)
+	}
+	gitConfig := fmt.Sprintf("[user]\n\tname = Ann\n\temail = %s\n[init]\n\tdefaultBranch = main\n[upspin]\n\tconfig = %s\n",
+		userName, schema.Config(userName))
+	if err := os.WriteFile(filepath.Join(dir, ".gitconfig"), []byte(gitConfig), 0644); err != nil {
+		cleanup()
+		t.Fatal(err)
+	}
+	g := &gitTest{
+		t:   t,
+		dir: dir,
+		env: append(os.Environ(),
+			"HOME="+dir,
+			"PATH="+bin+string(os.PathListSeparator)+os.Getenv("PATH"),
+			"GIT_CONFIG_NOSYSTEM=1",
+			"GIT_CONFIG_GLOBAL="+filepath.Join(dir, ".gitconfig"),
+		),
+	}
+	return g, cleanup
+}
+
+func TestPushClone(t *testing.T) {
+	g, cleanup := setupGit(t)
+	defer cleanup()
+
+	// Create a repository and push it.
+	g.git("", "init", "-q", "a")
+	g.commit("a", "file", "one\n")
+	g.git("a", "remote", "add", "origin", repoURL)
+	g.git("a", "push", "-q", "origin", "main")
+
+	// Clone it.
+	g.git("", "clone", "-q", repoURL, "b")
+	if got := g.read("b", "file"); got != "one\n" {
+		t.Fatalf("cloned file = %q, want %q", got, "one\n")
+	}
+	if got, want := g.git("b", "rev-parse", "HEAD"), g.git("a", "rev-parse", "HEAD"); got != want {
+		t.Fatalf("cloned HEAD = %s, want %s", got, want)
+	}
+
+	// Push a change from the first repository.
+	g.commit("a", "file", "two\n")
+	g.git("a", "push", "-q", "origin", "main")
+
+	// A conflicting change from the clone is rejected.
+	g.commit("b", "other", "conflict\n")
+	out, err := g.run("b", "push", "origin", "main")
+	if err == nil {
+		t.Fatalf("conflicting push succeeded:\n%s", out)
+	}
+	if !strings.Contains(out, "rejected") {
+		t.Errorf("conflicting push did not report rejection:\n%s", out)
+	}
+	if got, want := g.git("b", "ls-remote", "origin", "refs/heads/main"), g.git("a", "rev-parse", "HEAD"); !strings.HasPrefix(got, want) {
+		t.Fatalf("remote main after rejected push = %q, want %s", got, want)
+	}
+
+	// After fetching and rebasing the clone can push.
+	g.git("b", "fetch", "-q", "origin")
+	g.git("b", "rebase", "-q", "origin/main")
+	g.git("b", "push", "-q", "origin", "main")
+	g.git("a", "pull", "-q", "--ff-only", "origin", "main")
+	if got := g.read("a", "other"); got != "conflict\n" {
+		t.Errorf("pulled file = %q, want %q", got, "conflict\n")
+	}
+	if got := g.read("a", "file"); got != "two\n" {
+		t.Errorf("pulled file = %q, want %q", got, "two\n")
+	}
+
+	// A push that would lose commits is refused unless forced.
+	g.git("a", "reset", "-q", "--hard", "HEAD~2")
+	if out, err := g.run("a", "push", "origin", "main"); err == nil {
+		t.Fatalf("non-fast-forward push succeeded:\n%s", out)
+	}
+	g.git("a", "push", "-q", "--force", "origin", "main")
+	if got, want := g.git("b", "ls-remote", "origin", "refs/heads/main"), g.git("a", "rev-parse", "HEAD"); !strings.HasPrefix(got, want) {
+		t.Errorf("remote main after forced push = %q, want %s", got, want)
+	}
+}
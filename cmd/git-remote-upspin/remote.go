@@ -0,0 +1,226 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	goerrors "errors"
+	"sort"
+	"strings"
+
+	"upspin.io/client"
+	"upspin.io/errors"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+// A repository stored in Upspin is a directory holding
+//
+//	refs
+//		the references, one per line as "<sha> <name>", and
+//		the symbolic reference HEAD as "@<target> HEAD".
+//	packs/<name>.pack
+//		a pack file, named by the hex of its trailing checksum.
+//	packs/<name>.info
+//		the objects a pack depends on, one per line as "base <sha>",
+//		and the commits and tagged objects it holds, one per line.
+//
+// Packs are only ever added. Updates to the refs file are made with
+// PutSequenced, so that a push succeeds only if the references have
+// not changed since they were read.
+
+const (
+	refsFile = "refs"
+	packsDir = "packs"
+)
+
+// remote is a repository stored in Upspin.
+type remote struct {
+	cli  upspin.Client
+	root upspin.PathName
+}
+
+// refs holds the references of a repository.
+type refs struct {
+	head string            // Target of HEAD, if any.
+	refs map[string]string // Ref name to object name.
+}
+
+func parseRefs(data []byte) (*refs, error) {
+	r := &refs{refs: make(map[string]string)}
+	s := bufio.NewScanner(bytes.NewReader(data))
+	for s.Scan() {
+		f := strings.Fields(s.Text())
+		if len(f) == 0 {
+			continue
+		}
+		if len(f) != 2 {
+			return nil, errors.E(errors.Invalid, errors.Errorf("malformed refs line %q", s.Text()))
+		}
+		if f[1] == "HEAD" && strings.HasPrefix(f[0], "@") {
+			r.head = f[0][1:]
+			continue
+		}
+		r.refs[f[1]] = f[0]
+	}
+	return r, s.Err()
+}
+
+// bytes returns the refs in the form stored in Upspin, which is
+// also the form of a response to the remote helper list command.
+func (r *refs) bytes() []byte {
+	var b bytes.Buffer
+	if r.head != "" {
+		if _, ok := r.refs[r.head]; ok {
+			b.WriteString("@" + r.head + " HEAD\n")
+		}
+	}
+	names := make([]string, 0, len(r.refs))
+	for name := range r.refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		b.WriteString(r.refs[name] + " " + name + "\n")
+	}
+	return b.Bytes()
+}
+
+func (r *remote) name(elems ...string) upspin.PathName {
+	return path.Join(r.root, elems...)
+}
+
+// readRefs returns the repository's references and the sequence number
+// to use when writing them back. A repository that does not exist has
+// no references.
+func (r *remote) readRefs() (*refs, int64, error) {
+	entry, err := r.cli.Lookup(r.name(refsFile), true)
+	if errors.Is(errors.NotExist, err) {
+		return &refs{refs: make(map[string]string)}, upspin.SeqNotExist, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := r.cli.Get(entry.Name)
+	if err != nil {
+		return nil, 0, err
+	}
+	refs, err := parseRefs(data)
+	if err != nil {
+		return nil, 0, errors.E(entry.Name, err)
+	}
+	return refs, entry.Sequence, nil
+}
+
+// writeRefs stores the references if the refs file still has the
+// given sequence number. If it has changed, the error is errConflict.
+func (r *remote) writeRefs(refs *refs, seq int64) error {
+	name := r.name(refsFile)
+	_, err := r.cli.PutSequenced(name, seq, refs.bytes())
+	if goerrors.Is(err, client.ErrSequenceConflict) {
+		return errConflict
+	}
+	return err
+}
+
+var errConflict = errors.Str("references changed during push")
+
+// packInfo describes a pack.
+type packInfo struct {
+	name    string
+	bases   []string
+	objects []string
+}
+
+func parsePackInfo(name string, data []byte) *packInfo {
+	p := &packInfo{name: name}
+	for _, line := range strings.Split(string(data), "\n") {
+		switch {
+		case line == "":
+		case strings.HasPrefix(line, "base "):
+			p.bases = append(p.bases, strings.TrimPrefix(line, "base "))
+		default:
+			p.objects = append(p.objects, line)
+		}
+	}
+	return p
+}
+
+func (p *packInfo) bytes() []byte {
+	var b bytes.Buffer
+	for _, sha := range p.bases {
+		b.WriteString("base " + sha + "\n")
+	}
+	for _, sha := range p.objects {
+		b.WriteString(sha + "\n")
+	}
+	return b.Bytes()
+}
+
+// packs returns a description of the repository's packs.
+func (r *remote) packs() ([]*packInfo, error) {
+	entries, err := r.cli.Glob(string(r.name(packsDir, "*.info")))
+	if err != nil {
+		return nil, err
+	}
+	var packs []*packInfo
+	for _, e := range entries {
+		data, err := r.cli.Get(e.Name)
+		if err != nil {
+			return nil, err
+		}
+		p, err := path.Parse(e.Name)
+		if err != nil {
+			return nil, err
+		}
+		name := strings.TrimSuffix(p.Elem(p.NElem()-1), ".info")
+		packs = append(packs, parsePackInfo(name, data))
+	}
+	return packs, nil
+}
+
+// putPack stores the pack and its description. The pack is stored
+// first, so a pack is never described before it can be read.
+func (r *remote) putPack(data []byte, info *packInfo) error {
+	if len(data) < 20 {
+		return errors.E(errors.Invalid, "short pack")
+	}
+	info.name = hex.EncodeToString(data[len(data)-20:])
+	if err := r.makeDirs(); err != nil {
+		return err
+	}
+	if _, err := r.cli.Put(r.name(packsDir, info.name+".pack"), data); err != nil {
+		return err
+	}
+	_, err := r.cli.Put(r.name(packsDir, info.name+".info"), info.bytes())
+	return err
+}
+
+// openPack opens the named pack for reading.
+func (r *remote) openPack(name string) (upspin.File, error) {
+	return r.cli.Open(r.name(packsDir, name+".pack"))
+}
+
+// makeDirs creates the repository directory, and any missing parents,
+// and its packs directory.
+func (r *remote) makeDirs() error {
+	p, err := path.Parse(r.name(packsDir))
+	if err != nil {
+		return err
+	}
+	for i := 1; i <= p.NElem(); i++ {
+		dir := p.First(i).Path()
+		_, err := r.cli.Lookup(dir, true)
+		if errors.Is(errors.NotExist, err) {
+			_, err = r.cli.MakeDirectory(dir)
+		}
+		if err != nil && !errors.Is(errors.Exist, err) {
+			return err
+		}
+	}
+	return nil
+}
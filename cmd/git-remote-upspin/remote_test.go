@@ -0,0 +1,81 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"upspin.io/test/testenv"
+	"upspin.io/upspin"
+)
+
+func TestRefsConflict(t *testing.T) {
+	const owner = "aly@example.com"
+	env, err := testenv.New(&testenv.Setup{
+		OwnerName: owner,
+		Packing:   upspin.EEPack,
+		Kind:      "inprocess",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Exit()
+	r := &remote{cli: env.Client, root: owner + "/repos/project"}
+	if err := r.makeDirs(); err != nil {
+		t.Fatal(err)
+	}
+
+	empty, seq, err := r.readRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(empty.refs) != 0 || seq != upspin.SeqNotExist {
+		t.Fatalf("new repository has refs %v, sequence %d", empty.refs, seq)
+	}
+
+	// Two pushers read the same state; only the first may write.
+	first := &refs{head: "refs/heads/main", refs: map[string]string{"refs/heads/main": "1111"}}
+	second := &refs{refs: map[string]string{"refs/heads/main": "2222"}}
+	if err := r.writeRefs(first, seq); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.writeRefs(second, seq); err != errConflict {
+		t.Fatalf("stale write of new refs: got %v, want %v", err, errConflict)
+	}
+
+	got, seq, err := r.readRefs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got.bytes(), first.bytes()) {
+		t.Fatalf("refs = %q, want %q", got.bytes(), first.bytes())
+	}
+	if err := r.writeRefs(second, seq); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.writeRefs(first, seq); err != errConflict {
+		t.Fatalf("stale write of existing refs: got %v, want %v", err, errConflict)
+	}
+}
+
+func TestParseRefs(t *testing.T) {
+	const data = "@refs/heads/main HEAD\n" +
+		"1111 refs/heads/dev\n" +
+		"2222 refs/heads/main\n"
+	refs, err := parseRefs([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if refs.head != "refs/heads/main" || refs.refs["refs/heads/dev"] != "1111" || refs.refs["refs/heads/main"] != "2222" {
+		t.Errorf("parseRefs = %+v", refs)
+	}
+	if got := string(refs.bytes()); got != data {
+		t.Errorf("bytes = %q, want %q", got, data)
+	}
+	if _, err := parseRefs([]byte("1111 refs/heads/main extra\n")); err == nil {
+		t.Error("malformed refs parsed without error")
+	}
+}
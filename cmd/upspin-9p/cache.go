@@ -0,0 +1,332 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// Open files, and a small cache of previously opened ones, are kept
+// locally in disk files, as upspinfs does. A file is copied in whole
+// when it is opened, unless the cached copy of a closed file has the
+// same sequence number as the file in Upspin. Data written to a file
+// goes to the local copy, which is written back to Upspin when the last
+// fid using it is clunked or fsync'ed.
+//
+// Unlike upspinfs, the cache files are not encrypted; they are readable
+// only by the user running the server. All old cache files are removed
+// at startup.
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	lrucache "upspin.io/cache"
+	"upspin.io/errors"
+	"upspin.io/log"
+	"upspin.io/upspin"
+)
+
+const (
+	maxRefs         = 100  // Maximum closed files we will cache.
+	cachedFilePerms = 0600 // Permissions for cached files.
+	cacheDirPerms   = 0700 // Permissions for the cache directory.
+)
+
+// cache holds the local copies of files for all connections.
+type cache struct {
+	mu   sync.Mutex
+	dir  string        // Directory for in-the-clear cached files.
+	cli  upspin.Client // A client for reading and writing back files.
+	next int           // The number of the next cache file.
+
+	// open holds the files open by some fid, by Upspin name.
+	open map[upspin.PathName]*cachedFile
+
+	// lru is a cache of closed but not yet deleted files.
+	lru         *lrucache.LRU
+	lruBytes    int64 // Sum of the sizes of files in lru.
+	lruMaxBytes int64 // Maximum size of files in lru.
+}
+
+// cachedFile is the local copy of a file. The name, file and refs
+// fields are protected by the cache's lock; the others by the file's.
+type cachedFile struct {
+	c     *cache
+	name  upspin.PathName
+	fname string   // Name of the local copy.
+	file  *os.File // The local copy, while the file is open.
+	refs  int      // Number of fids that have the file open.
+
+	mu    sync.Mutex
+	seq   int64 // Sequence number of the copied Upspin version.
+	size  int64 // Size of the file, kept up to date by writes.
+	dirty bool  // Whether the copy must be written back.
+}
+
+func newCache(cli upspin.Client, dir string, maxBytes int64) (*cache, error) {
+	const op errors.Op = "upspin-9p.newCache"
+
+	// Clean out all cache files.
+	if err := os.RemoveAll(dir); err != nil {
+		return nil, errors.E(op, err)
+	}
+	if err := os.MkdirAll(dir, cacheDirPerms); err != nil {
+		return nil, errors.E(op, err)
+	}
+	return &cache{
+		dir:         dir,
+		cli:         cli,
+		open:        make(map[upspin.PathName]*cachedFile),
+		lru:         lrucache.NewLRU(maxRefs),
+		lruMaxBytes: maxBytes,
+	}, nil
+}
+
+// newFile returns a new, empty, open cache file. The cache must be locked.
+func (c *cache) newFile(name upspin.PathName, seq int64) (*cachedFile, error) {
+	cf := &cachedFile{
+		c:     c,
+		name:  name,
+		fname: filepath.Join(c.dir, fmt.Sprint(c.next)),
+		seq:   seq,
+		refs:  1,
+	}
+	c.next++
+	var err error
+	cf.file, err = os.OpenFile(cf.fname, os.O_CREATE|os.O_RDWR|os.O_TRUNC, cachedFilePerms)
+	if err != nil {
+		return nil, err
+	}
+	c.open[name] = cf
+	return cf, nil
+}
+
+// openFile returns the open local copy of the named file, making one
+// if necessary.
+func (c *cache) openFile(name upspin.PathName) (*cachedFile, error) {
+	const op errors.Op = "upspin-9p.openFile"
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cf, ok := c.open[name]; ok {
+		cf.refs++
+		return cf, nil
+	}
+	entry, err := c.cli.Lookup(name, false)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	if entry.IsDir() {
+		return nil, errors.E(op, name, errors.IsDir)
+	}
+
+	// Look for a cached copy of the current version.
+	if v := c.lru.Remove(name); v != nil {
+		cf := v.(*cachedFile)
+		c.lruBytes -= cf.size
+		if cf.seq == entry.Sequence {
+			cf.file, err = os.OpenFile(cf.fname, os.O_RDWR, cachedFilePerms)
+			if err == nil {
+				cf.refs = 1
+				c.open[name] = cf
+				return cf, nil
+			}
+		}
+		os.Remove(cf.fname)
+	}
+
+	cf, err := c.newFile(name, entry.Sequence)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	f, err := c.cli.Open(name)
+	if err == nil {
+		cf.size, err = io.Copy(cf.file, f)
+		f.Close()
+	}
+	if err != nil {
+		cf.discard()
+		return nil, errors.E(op, err)
+	}
+	return cf, nil
+}
+
+// createFile creates the named file in Upspin, empty, and returns its
+// open local copy. If excl is set, the file must not already exist.
+func (c *cache) createFile(name upspin.PathName, excl bool) (*cachedFile, error) {
+	const op errors.Op = "upspin-9p.createFile"
+
+	seq := int64(upspin.SeqIgnore)
+	if excl {
+		seq = upspin.SeqNotExist
+	}
+	entry, err := c.cli.PutSequenced(name, seq, nil)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cf, ok := c.open[name]; ok {
+		// Another fid has the old version open; this one replaces it.
+		cf.mu.Lock()
+		defer cf.mu.Unlock()
+		if err := cf.file.Truncate(0); err != nil {
+			return nil, errors.E(op, err)
+		}
+		cf.size = 0
+		cf.seq = entry.Sequence
+		cf.dirty = false
+		cf.refs++
+		return cf, nil
+	}
+	c.lru.Remove(name)
+	cf, err := c.newFile(name, entry.Sequence)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	return cf, nil
+}
+
+// forget drops any closed copy of the named file, and moves an open
+// one to the new name. If there is no new name, the file has been
+// deleted and the open copy is not written back.
+func (c *cache) forget(name, newName upspin.PathName) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if v := c.lru.Remove(name); v != nil {
+		cf := v.(*cachedFile)
+		c.lruBytes -= cf.size
+		os.Remove(cf.fname)
+	}
+	cf, ok := c.open[name]
+	if !ok {
+		return
+	}
+	delete(c.open, name)
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	if newName == "" {
+		cf.dirty = false
+		return
+	}
+	cf.name = newName
+	c.open[newName] = cf
+}
+
+// discard removes a copy that could not be made. The cache must be locked.
+func (cf *cachedFile) discard() {
+	cf.file.Close()
+	os.Remove(cf.fname)
+	delete(cf.c.open, cf.name)
+}
+
+func (cf *cachedFile) readAt(b []byte, off int64) (int, error) {
+	n, err := cf.file.ReadAt(b, off)
+	if err == io.EOF {
+		err = nil
+	}
+	return n, err
+}
+
+func (cf *cachedFile) writeAt(b []byte, off int64) (int, error) {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	n, err := cf.file.WriteAt(b, off)
+	if end := off + int64(n); end > cf.size {
+		cf.size = end
+	}
+	cf.dirty = true
+	return n, err
+}
+
+func (cf *cachedFile) truncate(size int64) error {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	if err := cf.file.Truncate(size); err != nil {
+		return err
+	}
+	cf.size = size
+	cf.dirty = true
+	return nil
+}
+
+// getSize returns the current size of the file.
+func (cf *cachedFile) getSize() int64 {
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	return cf.size
+}
+
+// sync writes the local copy back to Upspin if it has changed.
+func (cf *cachedFile) sync() error {
+	cf.c.mu.Lock()
+	defer cf.c.mu.Unlock()
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	return cf.syncLocked()
+}
+
+// syncLocked is sync with the file locked.
+func (cf *cachedFile) syncLocked() error {
+	const op errors.Op = "upspin-9p.sync"
+
+	if !cf.dirty {
+		return nil
+	}
+	data := make([]byte, cf.size)
+	if _, err := cf.file.ReadAt(data, 0); err != nil && err != io.EOF {
+		return errors.E(op, cf.name, err)
+	}
+	entry, err := cf.c.cli.Put(cf.name, data)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	cf.seq = entry.Sequence
+	cf.dirty = false
+	return nil
+}
+
+// close releases a fid's use of the file. When no fid uses it, the file
+// is written back if necessary and the copy kept among the closed files.
+func (cf *cachedFile) close() error {
+	c := cf.c
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cf.refs--
+	if cf.refs > 0 {
+		return nil
+	}
+	cf.mu.Lock()
+	defer cf.mu.Unlock()
+	err := cf.syncLocked()
+	cf.file.Close()
+	cf.file = nil
+	if c.open[cf.name] == cf {
+		delete(c.open, cf.name)
+	}
+	if err != nil || cf.size > c.lruMaxBytes {
+		os.Remove(cf.fname)
+		return err
+	}
+	for c.lruBytes+cf.size > c.lruMaxBytes {
+		_, v := c.lru.RemoveOldest()
+		if v == nil {
+			break
+		}
+		v.(*cachedFile).OnEviction(nil)
+	}
+	c.lru.Add(cf.name, cf)
+	c.lruBytes += cf.size
+	return nil
+}
+
+// OnEviction implements cache.EvictionNotifier. It is called with the
+// cache locked.
+func (cf *cachedFile) OnEviction(key interface{}) {
+	cf.c.lruBytes -= cf.size
+	if err := os.Remove(cf.fname); err != nil {
+		log.Debug.Printf("upspin-9p: removing cache file: %v", err)
+	}
+}
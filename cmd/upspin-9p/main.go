@@ -0,0 +1,132 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Upspin-9p serves the Upspin name space using the 9P2000.L protocol,
+// so that it may be mounted where FUSE is not available, for instance
+// by the Linux v9fs file system in WSL2 or a virtual machine:
+//
+//	upspin-9p &
+//	mount -t 9p -o trans=unix,version=9p2000.L,access=user,uname=$USER \
+//		$HOME/upspin/9p.sock /mnt/upspin
+//
+// The server runs as the user named in the Upspin config and all
+// requests are made with that user's identity; there is no further
+// authentication. By default it listens on a Unix domain socket that
+// only the user running it may connect to. Given -net=tcp it listens on
+// the TCP address named by -addr, such as localhost:564, where any local
+// user may connect.
+//
+// The root of the served tree holds the root directories of Upspin
+// users. Only the server's user is listed, but any user's tree may be
+// reached by name. Upspin links appear as symbolic links, with targets
+// relative to the link, which may be followed and read but not created.
+//
+// Files are copied to a local cache, in the directory named by the
+// -cachedir flag, when they are opened, and data written to a file is
+// saved to Upspin when the file is closed or synced. Upspin permission
+// errors are reported as EACCES.
+package main // import "upspin.io/cmd/upspin-9p"
+
+import (
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"upspin.io/client"
+	"upspin.io/config"
+	"upspin.io/flags"
+	"upspin.io/log"
+	"upspin.io/transports"
+	"upspin.io/upspin"
+	"upspin.io/version"
+
+	_ "upspin.io/pack/ee"
+	_ "upspin.io/pack/eeintegrity"
+	_ "upspin.io/pack/plain"
+)
+
+const cmdName = "upspin-9p"
+
+var (
+	netFlag  = flag.String("net", "unix", "`network` on which to listen: unix or tcp")
+	addrFlag = flag.String("addr", filepath.Join(os.Getenv("HOME"), "upspin", "9p.sock"), "`address` on which to listen")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags]\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flags.Parse(flags.Client, "cachedir", "cachesize", "version")
+	if flags.Version {
+		fmt.Print(version.Version())
+		return
+	}
+	if flag.NArg() != 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.FromFile(flags.Config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := config.SetFlagValues(cfg, cmdName); err != nil {
+		log.Fatalf("%s: %s", cmdName, err)
+	}
+	transports.Init(cfg)
+
+	cli := client.New(cfg)
+	c, err := newCache(cli, filepath.Join(flags.CacheDir, string(cfg.UserName()), "9p"), flags.CacheSize)
+	if err != nil {
+		log.Fatalf("%s: %s", cmdName, err)
+	}
+	ln, err := listen(*netFlag, *addrFlag)
+	if err != nil {
+		log.Fatalf("%s: %s", cmdName, err)
+	}
+	log.Printf("%s: serving %s on %s", cmdName, cfg.UserName(), ln.Addr())
+	log.Fatal(serve(ln, cli, cfg.UserName(), c))
+}
+
+// listen listens on the address. A Unix domain socket replaces any left
+// by an earlier server and may be used only by the current user.
+func listen(network, addr string) (net.Listener, error) {
+	if network != "unix" {
+		return net.Listen(network, addr)
+	}
+	if err := os.MkdirAll(filepath.Dir(addr), 0700); err != nil {
+		return nil, err
+	}
+	os.Remove(addr)
+	ln, err := net.Listen(network, addr)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(addr, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+// serve accepts connections on the listener and serves each.
+func serve(ln net.Listener, cli upspin.Client, user upspin.UserName, c *cache) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer conn.Close()
+			if err := newServer(cli, user, c).serve(conn); err != nil {
+				log.Debug.Printf("%s: %v", cmdName, err)
+			}
+		}()
+	}
+}
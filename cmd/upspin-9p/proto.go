@@ -0,0 +1,341 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// This file holds the message formats of the 9P2000.L protocol, as
+// described in the Linux kernel's Documentation/filesystems/9p.txt and
+// the diod protocol notes.
+
+import (
+	"encoding/binary"
+	"io"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// Message types. Each response type is one more than its request type.
+const (
+	tlerror      = 6
+	rlerror      = 7
+	tstatfs      = 8
+	tlopen       = 12
+	tlcreate     = 14
+	tsymlink     = 16
+	tmknod       = 18
+	trename      = 20
+	treadlink    = 22
+	tgetattr     = 24
+	tsetattr     = 26
+	txattrwalk   = 30
+	txattrcreate = 32
+	treaddir     = 40
+	tfsync       = 50
+	tlock        = 52
+	tgetlock     = 54
+	tlink        = 70
+	tmkdir       = 72
+	trenameat    = 74
+	tunlinkat    = 76
+	tversion     = 100
+	tauth        = 102
+	tattach      = 104
+	tflush       = 108
+	twalk        = 110
+	tread        = 116
+	twrite       = 118
+	tclunk       = 120
+	tremove      = 122
+)
+
+const (
+	// noTag is the tag of a version request.
+	noTag = 0xFFFF
+
+	// noFid is the fid meaning none, as in the afid of an attach
+	// without authentication.
+	noFid = 0xFFFFFFFF
+
+	protocolVersion = "9P2000.L"
+
+	// maxMessage is the largest message the server accepts or sends.
+	// A client may negotiate a smaller one.
+	maxMessage = 1 << 17
+
+	// ioHeader is the size of the header of a read response or write
+	// request, which holds the data of a message.
+	ioHeader = 4 + 1 + 2 + 4 + 8 + 4
+
+	// maxWalk is the most names a walk request may hold.
+	maxWalk = 16
+)
+
+// Bits of a qid type.
+const (
+	qtDir     = 0x80
+	qtSymlink = 0x02
+	qtFile    = 0x00
+)
+
+// Linux open flags, as sent in lopen and lcreate requests.
+const (
+	oAccmode = 0x3
+	oRdonly  = 0x0
+	oWronly  = 0x1
+	oRdwr    = 0x2
+	oCreat   = 0x40
+	oExcl    = 0x80
+	oTrunc   = 0x200
+	oAppend  = 0x400
+)
+
+// Bits of the valid mask of getattr and setattr requests.
+const (
+	getattrBasic = 0x7ff // Mode through blocks.
+
+	setattrMode    = 0x1
+	setattrUID     = 0x2
+	setattrGID     = 0x4
+	setattrSize    = 0x8
+	setattrAtime   = 0x10
+	setattrMtime   = 0x20
+	setattrCtime   = 0x40
+	setattrAtimeOn = 0x80
+	setattrMtimeOn = 0x100
+)
+
+// Linux file mode bits, as sent in getattr responses.
+const (
+	sIFDIR = 0040000
+	sIFREG = 0100000
+	sIFLNK = 0120000
+)
+
+// Linux directory entry types, as sent in readdir responses.
+const (
+	dtDir = 4
+	dtReg = 8
+	dtLnk = 10
+)
+
+// Flag of an unlinkat request naming a directory.
+const atRemovedir = 0x200
+
+// Linux error numbers, which 9P2000.L sends in lerror responses.
+const (
+	ePERM      = 1
+	eNOENT     = 2
+	eIO        = 5
+	eBADF      = 9
+	eACCES     = 13
+	eEXIST     = 17
+	eNOTDIR    = 20
+	eISDIR     = 21
+	eINVAL     = 22
+	eNOTEMPTY  = 39
+	eOPNOTSUPP = 95
+)
+
+// errnoError is an error that carries the Linux error number to report.
+type errnoError struct {
+	errno uint32
+	err   error
+}
+
+func (e *errnoError) Error() string {
+	return e.err.Error()
+}
+
+func errno(n uint32, msg string) error {
+	return &errnoError{errno: n, err: errors.Str(msg)}
+}
+
+var kindToErrno = map[errors.Kind]uint32{
+	errors.Permission:    eACCES,
+	errors.Private:       eACCES,
+	errors.Exist:         eEXIST,
+	errors.NotExist:      eNOENT,
+	errors.BrokenLink:    eNOENT,
+	errors.IsDir:         eISDIR,
+	errors.NotDir:        eNOTDIR,
+	errors.NotEmpty:      eNOTEMPTY,
+	errors.Invalid:       eINVAL,
+	errors.CannotDecrypt: ePERM,
+}
+
+// errnoOf returns the Linux error number for the error.
+func errnoOf(err error) uint32 {
+	switch e := err.(type) {
+	case *errnoError:
+		return e.errno
+	case *errors.Error:
+		if n, ok := kindToErrno[e.Kind]; ok {
+			return n
+		}
+		if e.Err != nil {
+			return errnoOf(e.Err)
+		}
+	}
+	switch err {
+	case errShort:
+		return eINVAL
+	case upspin.ErrNotSupported:
+		return eOPNOTSUPP
+	}
+	return eIO
+}
+
+// readMessage reads a message and returns its type, tag and body.
+func readMessage(r io.Reader, max uint32) (byte, uint16, []byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, 0, nil, err
+	}
+	n := binary.LittleEndian.Uint32(hdr[:])
+	if n < 4+1+2 || n > max {
+		return 0, 0, nil, errors.Errorf("bad message length %d", n)
+	}
+	b := make([]byte, n-4)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, 0, nil, err
+	}
+	return b[0], binary.LittleEndian.Uint16(b[1:]), b[3:], nil
+}
+
+// errShort reports a truncated message.
+var errShort = errors.Str("short message")
+
+// decoder reads the fields of a message.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) uint8() uint8 {
+	if len(d.b) < 1 {
+		d.err = errShort
+		return 0
+	}
+	v := d.b[0]
+	d.b = d.b[1:]
+	return v
+}
+
+func (d *decoder) uint16() uint16 {
+	if len(d.b) < 2 {
+		d.err = errShort
+		return 0
+	}
+	v := binary.LittleEndian.Uint16(d.b)
+	d.b = d.b[2:]
+	return v
+}
+
+func (d *decoder) uint32() uint32 {
+	if len(d.b) < 4 {
+		d.err = errShort
+		return 0
+	}
+	v := binary.LittleEndian.Uint32(d.b)
+	d.b = d.b[4:]
+	return v
+}
+
+func (d *decoder) uint64() uint64 {
+	if len(d.b) < 8 {
+		d.err = errShort
+		return 0
+	}
+	v := binary.LittleEndian.Uint64(d.b)
+	d.b = d.b[8:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := int(d.uint16())
+	if len(d.b) < n {
+		d.err = errShort
+		return ""
+	}
+	v := string(d.b[:n])
+	d.b = d.b[n:]
+	return v
+}
+
+// data reads a count followed by that many bytes.
+func (d *decoder) data() []byte {
+	n := d.uint32()
+	if uint32(len(d.b)) < n {
+		d.err = errShort
+		return nil
+	}
+	v := d.b[:n]
+	d.b = d.b[n:]
+	return v
+}
+
+func (d *decoder) qid() qid {
+	return qid{typ: d.uint8(), version: d.uint32(), path: d.uint64()}
+}
+
+// encoder builds a message. The size is filled in by bytes.
+type encoder struct {
+	b []byte
+}
+
+func newMessage(typ byte, tag uint16) *encoder {
+	e := &encoder{b: make([]byte, 4, 64)}
+	e.uint8(typ)
+	e.uint16(tag)
+	return e
+}
+
+func (e *encoder) uint8(v uint8) {
+	e.b = append(e.b, v)
+}
+
+func (e *encoder) uint16(v uint16) {
+	e.b = binary.LittleEndian.AppendUint16(e.b, v)
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.b = binary.LittleEndian.AppendUint32(e.b, v)
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.b = binary.LittleEndian.AppendUint64(e.b, v)
+}
+
+func (e *encoder) string(v string) {
+	e.uint16(uint16(len(v)))
+	e.b = append(e.b, v...)
+}
+
+func (e *encoder) data(v []byte) {
+	e.uint32(uint32(len(v)))
+	e.b = append(e.b, v...)
+}
+
+func (e *encoder) qid(q qid) {
+	e.uint8(q.typ)
+	e.uint32(q.version)
+	e.uint64(q.path)
+}
+
+// bytes returns the complete message.
+func (e *encoder) bytes() []byte {
+	binary.LittleEndian.PutUint32(e.b, uint32(len(e.b)))
+	return e.b
+}
+
+// qid is the server's unique identification of a file.
+type qid struct {
+	typ     uint8
+	version uint32
+	path    uint64
+}
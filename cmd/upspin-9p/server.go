@@ -0,0 +1,910 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"upspin.io/client/clientutil"
+	"upspin.io/errors"
+	"upspin.io/log"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+const (
+	// Permission bits reported for files and directories; Upspin access
+	// is governed by Access files, not by these bits.
+	filePerm = 0600
+	dirPerm  = 0700
+	linkPerm = 0777
+
+	// v9fsMagic is the file system type reported by statfs.
+	v9fsMagic = 0x01021997
+
+	blockSize = 4096
+)
+
+// server serves the 9P2000.L protocol for one connection. Requests are
+// handled in the order they arrive.
+type server struct {
+	cli   upspin.Client
+	user  upspin.UserName
+	cache *cache
+	msize uint32
+
+	fids map[uint32]*fid
+}
+
+// fid is the server's state for a fid: the file it names and, once
+// opened, the open file or directory.
+type fid struct {
+	// name is the Upspin name of the file. It is empty for the root of
+	// the name space, which holds the users' root directories.
+	name upspin.PathName
+
+	open  bool
+	flags uint32             // Flags of the lopen or lcreate request.
+	file  *cachedFile        // For files.
+	ents  []*upspin.DirEntry // For directories, the entries at open.
+}
+
+func newServer(cli upspin.Client, user upspin.UserName, c *cache) *server {
+	return &server{
+		cli:   cli,
+		user:  user,
+		cache: c,
+		msize: maxMessage,
+		fids:  make(map[uint32]*fid),
+	}
+}
+
+// serve reads requests from rw and writes the responses until the
+// client closes the connection. Files left open are closed, so data
+// written to them is saved.
+func (s *server) serve(rw io.ReadWriter) error {
+	defer s.clunkAll()
+	for {
+		typ, tag, body, err := readMessage(rw, s.msize)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := rw.Write(s.handle(typ, tag, body)); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *server) clunkAll() {
+	for n, f := range s.fids {
+		if err := f.clunk(); err != nil {
+			log.Error.Printf("upspin-9p: closing %s: %v", f.name, err)
+		}
+		delete(s.fids, n)
+	}
+}
+
+// clunk releases the fid's open file, if any.
+func (f *fid) clunk() error {
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.close()
+	f.file = nil
+	return err
+}
+
+// handle handles one request, returning the response to send.
+func (s *server) handle(typ byte, tag uint16, body []byte) []byte {
+	d := &decoder{b: body}
+	var e *encoder
+	var err error
+	switch typ {
+	case tversion:
+		msize, version := d.uint32(), d.string()
+		if d.err == nil {
+			e = s.version(tag, msize, version)
+		}
+	case tauth:
+		err = errno(eOPNOTSUPP, "authentication not required")
+	case tattach:
+		n, _, _, _ := d.uint32(), d.uint32(), d.string(), d.string()
+		if d.err == nil {
+			e, err = s.attach(tag, n)
+		}
+	case tflush:
+		// Requests are answered in order, so the flushed one has been.
+		d.uint16()
+		e = newMessage(tflush+1, tag)
+	case twalk:
+		n, newN, nwname := d.uint32(), d.uint32(), int(d.uint16())
+		var names []string
+		for i := 0; i < nwname && d.err == nil; i++ {
+			names = append(names, d.string())
+		}
+		if d.err == nil {
+			e, err = s.walk(tag, n, newN, names)
+		}
+	case tlopen:
+		n, flags := d.uint32(), d.uint32()
+		if d.err == nil {
+			e, err = s.lopen(tag, n, flags)
+		}
+	case tlcreate:
+		n, name, flags, _, _ := d.uint32(), d.string(), d.uint32(), d.uint32(), d.uint32()
+		if d.err == nil {
+			e, err = s.lcreate(tag, n, name, flags)
+		}
+	case tread:
+		n, off, count := d.uint32(), d.uint64(), d.uint32()
+		if d.err == nil {
+			e, err = s.read(tag, n, int64(off), count)
+		}
+	case twrite:
+		n, off, data := d.uint32(), d.uint64(), d.data()
+		if d.err == nil {
+			e, err = s.write(tag, n, int64(off), data)
+		}
+	case tclunk:
+		n := d.uint32()
+		if d.err == nil {
+			e, err = s.clunk(tag, n)
+		}
+	case tremove:
+		n := d.uint32()
+		if d.err == nil {
+			e, err = s.remove(tag, n)
+		}
+	case tgetattr:
+		n, _ := d.uint32(), d.uint64()
+		if d.err == nil {
+			e, err = s.getattr(tag, n)
+		}
+	case tsetattr:
+		n, valid := d.uint32(), d.uint32()
+		d.uint32() // Mode.
+		d.uint32() // UID.
+		d.uint32() // GID.
+		size := d.uint64()
+		d.uint64() // Access time.
+		d.uint64()
+		mtime, mtimeNsec := d.uint64(), d.uint64()
+		if d.err == nil {
+			e, err = s.setattr(tag, n, valid, int64(size), time.Unix(int64(mtime), int64(mtimeNsec)))
+		}
+	case treaddir:
+		n, off, count := d.uint32(), d.uint64(), d.uint32()
+		if d.err == nil {
+			e, err = s.readdir(tag, n, off, count)
+		}
+	case tfsync:
+		n := d.uint32()
+		if d.err == nil {
+			e, err = s.fsync(tag, n)
+		}
+	case tmkdir:
+		n, name := d.uint32(), d.string()
+		if d.err == nil {
+			e, err = s.mkdir(tag, n, name)
+		}
+	case trenameat:
+		oldDir, oldName, newDir, newName := d.uint32(), d.string(), d.uint32(), d.string()
+		if d.err == nil {
+			e, err = s.renameat(tag, oldDir, oldName, newDir, newName)
+		}
+	case trename:
+		n, dir, name := d.uint32(), d.uint32(), d.string()
+		if d.err == nil {
+			e, err = s.rename(tag, n, dir, name)
+		}
+	case tunlinkat:
+		dir, name := d.uint32(), d.string()
+		if d.err == nil {
+			e, err = s.unlinkat(tag, dir, name)
+		}
+	case treadlink:
+		n := d.uint32()
+		if d.err == nil {
+			e, err = s.readlink(tag, n)
+		}
+	case tstatfs:
+		e = s.statfs(tag)
+	case tlock:
+		// Locks are advisory and Upspin has none; all succeed.
+		e = newMessage(tlock+1, tag)
+		e.uint8(0) // Success.
+	case tgetlock:
+		n, _, start, length, proc, client := d.uint32(), d.uint8(), d.uint64(), d.uint64(), d.uint32(), d.string()
+		if _, err = s.lookupFid(n); err == nil && d.err == nil {
+			e = newMessage(tgetlock+1, tag)
+			e.uint8(2) // Unlocked.
+			e.uint64(start)
+			e.uint64(length)
+			e.uint32(proc)
+			e.string(client)
+		}
+	case txattrwalk, txattrcreate:
+		err = errno(eOPNOTSUPP, "extended attributes not supported")
+	case tsymlink, tmknod, tlink:
+		err = errno(ePERM, "only files and directories may be created")
+	default:
+		err = errno(eOPNOTSUPP, fmt.Sprintf("unsupported message type %d", typ))
+	}
+	if d.err != nil {
+		err = d.err
+	}
+	if err != nil {
+		log.Debug.Printf("upspin-9p: message type %d: %v", typ, err)
+		e = newMessage(rlerror, tag)
+		e.uint32(errnoOf(err))
+	}
+	return e.bytes()
+}
+
+func (s *server) version(tag uint16, msize uint32, version string) *encoder {
+	s.clunkAll()
+	if msize < s.msize {
+		s.msize = msize
+	}
+	if !strings.HasPrefix(version, protocolVersion) {
+		version = "unknown"
+	} else {
+		version = protocolVersion
+	}
+	e := newMessage(tversion+1, tag)
+	e.uint32(s.msize)
+	e.string(version)
+	return e
+}
+
+// newFid records a fid, which must not be in use.
+func (s *server) newFid(n uint32, f *fid) error {
+	if _, ok := s.fids[n]; ok || n == noFid {
+		return errno(eBADF, "fid in use")
+	}
+	s.fids[n] = f
+	return nil
+}
+
+func (s *server) lookupFid(n uint32) (*fid, error) {
+	f, ok := s.fids[n]
+	if !ok {
+		return nil, errno(eBADF, "unknown fid")
+	}
+	return f, nil
+}
+
+// lookupDir returns the fid, which must be of a directory that is not open.
+func (s *server) lookupDir(n uint32) (*fid, error) {
+	f, err := s.lookupFid(n)
+	if err != nil {
+		return nil, err
+	}
+	if f.open {
+		return nil, errno(eBADF, "fid is open")
+	}
+	return f, nil
+}
+
+func (s *server) attach(tag uint16, n uint32) (*encoder, error) {
+	if err := s.newFid(n, &fid{}); err != nil {
+		return nil, err
+	}
+	e := newMessage(tattach+1, tag)
+	e.qid(rootQid)
+	return e, nil
+}
+
+// rootQid is the qid of the root of the name space.
+var rootQid = qid{typ: qtDir}
+
+// entryQid returns the qid for the entry.
+func entryQid(entry *upspin.DirEntry) qid {
+	return nameQid(entry.Name, entry.Attr, entry.Sequence)
+}
+
+// nameQid returns the qid of a file with the given name, attributes and
+// sequence number. The qid path is a hash of the name.
+func nameQid(name upspin.PathName, attr upspin.Attribute, seq int64) qid {
+	h := fnv.New64a()
+	h.Write([]byte(name))
+	q := qid{typ: qtFile, version: uint32(seq), path: h.Sum64()}
+	switch {
+	case attr&upspin.AttrDirectory != 0:
+		q.typ = qtDir
+	case attr&upspin.AttrLink != 0:
+		q.typ = qtSymlink
+	}
+	return q
+}
+
+// child returns the Upspin name of the named element of the directory.
+func child(dir upspin.PathName, elem string) (upspin.PathName, error) {
+	if elem == "" || elem == "." || elem == ".." || strings.Contains(elem, "/") {
+		return "", errno(eINVAL, fmt.Sprintf("invalid name %q", elem))
+	}
+	name := upspin.PathName(elem)
+	if dir != "" {
+		name = dir + "/" + name
+	}
+	p, err := path.Parse(name)
+	if err != nil {
+		return "", err
+	}
+	return p.Path(), nil
+}
+
+// parent returns the Upspin name of the directory holding name.
+func parent(name upspin.PathName) upspin.PathName {
+	p, err := path.Parse(name)
+	if err != nil || p.IsRoot() {
+		return ""
+	}
+	return p.Drop(1).Path()
+}
+
+func (s *server) walk(tag uint16, n, newN uint32, names []string) (*encoder, error) {
+	f, err := s.lookupDir(n)
+	if err != nil {
+		return nil, err
+	}
+	if len(names) > maxWalk {
+		return nil, errno(eINVAL, "too many names in walk")
+	}
+	name := f.name
+	var qids []qid
+	for i, elem := range names {
+		var q qid
+		if elem == ".." {
+			name = parent(name)
+			q = rootQid
+			if name != "" {
+				q = nameQid(name, upspin.AttrDirectory, 0)
+			}
+		} else {
+			name, err = child(name, elem)
+			var entry *upspin.DirEntry
+			if err == nil {
+				entry, err = s.walkLookup(name)
+			}
+			if err == nil && entry.IsLink() && i < len(names)-1 {
+				// The client must read and follow the link itself.
+				err = errno(eNOTDIR, "walk through link")
+			}
+			if err != nil {
+				if i == 0 {
+					return nil, err
+				}
+				break
+			}
+			q = entryQid(entry)
+		}
+		qids = append(qids, q)
+	}
+	if len(qids) == len(names) {
+		if n == newN {
+			f.name = name
+		} else if err := s.newFid(newN, &fid{name: name}); err != nil {
+			return nil, err
+		}
+	}
+	e := newMessage(twalk+1, tag)
+	e.uint16(uint16(len(qids)))
+	for _, q := range qids {
+		e.qid(q)
+	}
+	return e, nil
+}
+
+// walkLookup returns the entry for the named file. As in upspinfs, a
+// file the user may not see is taken to be a directory, in the hope
+// that a longer path within it is visible; operations on it fail.
+func (s *server) walkLookup(name upspin.PathName) (*upspin.DirEntry, error) {
+	entry, err := s.cli.Lookup(name, false)
+	if errors.Is(errors.Private, err) {
+		return &upspin.DirEntry{Name: name, Attr: upspin.AttrDirectory}, nil
+	}
+	return entry, err
+}
+
+// iounit returns the most data that may be sent in a read or write.
+func (s *server) iounit() uint32 {
+	return s.msize - ioHeader
+}
+
+func (s *server) lopen(tag uint16, n, flags uint32) (*encoder, error) {
+	f, err := s.lookupDir(n)
+	if err != nil {
+		return nil, err
+	}
+	q := rootQid
+	if f.name == "" {
+		// The root holds only our user's root, the one we can list.
+		entry, err := s.cli.Lookup(upspin.PathName(s.user)+"/", false)
+		if err != nil {
+			return nil, err
+		}
+		f.ents = []*upspin.DirEntry{entry}
+	} else {
+		entry, err := s.cli.Lookup(f.name, false)
+		if err != nil {
+			return nil, err
+		}
+		q = entryQid(entry)
+		switch {
+		case entry.IsDir():
+			if f.ents, err = s.cli.Glob(upspin.AllFilesGlob(f.name)); err != nil {
+				return nil, err
+			}
+		case entry.IsLink():
+			return nil, errno(eINVAL, "cannot open a link")
+		default:
+			if f.file, err = s.cache.openFile(f.name); err != nil {
+				return nil, err
+			}
+			if flags&oTrunc != 0 && flags&oAccmode != oRdonly {
+				if err := f.file.truncate(0); err != nil {
+					f.clunk()
+					return nil, err
+				}
+			}
+		}
+	}
+	f.open = true
+	f.flags = flags
+	e := newMessage(tlopen+1, tag)
+	e.qid(q)
+	e.uint32(s.iounit())
+	return e, nil
+}
+
+func (s *server) lcreate(tag uint16, n uint32, elem string, flags uint32) (*encoder, error) {
+	f, err := s.lookupDir(n)
+	if err != nil {
+		return nil, err
+	}
+	if f.name == "" {
+		return nil, errno(eACCES, "cannot create in the root")
+	}
+	name, err := child(f.name, elem)
+	if err != nil {
+		return nil, err
+	}
+	cf, err := s.cache.createFile(name, flags&oExcl != 0)
+	if err != nil {
+		return nil, err
+	}
+	f.name = name
+	f.file = cf
+	f.open = true
+	f.flags = flags
+	e := newMessage(tlcreate+1, tag)
+	cf.mu.Lock()
+	e.qid(nameQid(name, upspin.AttrNone, cf.seq))
+	cf.mu.Unlock()
+	e.uint32(s.iounit())
+	return e, nil
+}
+
+// lookupFile returns the fid, which must be of an open file.
+func (s *server) lookupFile(n uint32) (*fid, error) {
+	f, err := s.lookupFid(n)
+	if err != nil {
+		return nil, err
+	}
+	if !f.open {
+		return nil, errno(eBADF, "fid is not open")
+	}
+	if f.file == nil {
+		return nil, errno(eISDIR, "fid is a directory")
+	}
+	return f, nil
+}
+
+func (s *server) read(tag uint16, n uint32, off int64, count uint32) (*encoder, error) {
+	f, err := s.lookupFile(n)
+	if err != nil {
+		return nil, err
+	}
+	if f.flags&oAccmode == oWronly {
+		return nil, errno(eBADF, "file not open for reading")
+	}
+	if count > s.iounit() {
+		count = s.iounit()
+	}
+	buf := make([]byte, count)
+	m, err := f.file.readAt(buf, off)
+	if err != nil {
+		return nil, err
+	}
+	e := newMessage(tread+1, tag)
+	e.data(buf[:m])
+	return e, nil
+}
+
+func (s *server) write(tag uint16, n uint32, off int64, data []byte) (*encoder, error) {
+	f, err := s.lookupFile(n)
+	if err != nil {
+		return nil, err
+	}
+	if f.flags&oAccmode == oRdonly {
+		return nil, errno(eBADF, "file not open for writing")
+	}
+	if f.flags&oAppend != 0 {
+		off = f.file.getSize()
+	}
+	m, err := f.file.writeAt(data, off)
+	if err != nil {
+		return nil, err
+	}
+	e := newMessage(twrite+1, tag)
+	e.uint32(uint32(m))
+	return e, nil
+}
+
+func (s *server) clunk(tag uint16, n uint32) (*encoder, error) {
+	f, err := s.lookupFid(n)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.fids, n)
+	if err := f.clunk(); err != nil {
+		return nil, err
+	}
+	return newMessage(tclunk+1, tag), nil
+}
+
+func (s *server) remove(tag uint16, n uint32) (*encoder, error) {
+	f, err := s.lookupFid(n)
+	if err != nil {
+		return nil, err
+	}
+	// The fid is clunked even if the remove fails.
+	delete(s.fids, n)
+	if f.name == "" {
+		f.clunk()
+		return nil, errno(eACCES, "cannot remove the root")
+	}
+	err = s.delete(f.name)
+	f.clunk()
+	if err != nil {
+		return nil, err
+	}
+	return newMessage(tremove+1, tag), nil
+}
+
+// delete removes the named file and its cached copy.
+func (s *server) delete(name upspin.PathName) error {
+	if err := s.cli.Delete(name); err != nil {
+		return err
+	}
+	s.cache.forget(name, "")
+	return nil
+}
+
+func (s *server) getattr(tag uint16, n uint32) (*encoder, error) {
+	f, err := s.lookupFid(n)
+	if err != nil {
+		return nil, err
+	}
+	q := rootQid
+	mode := uint32(sIFDIR | dirPerm)
+	var size int64
+	var mtime time.Time
+	if f.name != "" {
+		entry, err := s.cli.Lookup(f.name, false)
+		if err != nil {
+			return nil, err
+		}
+		q = entryQid(entry)
+		mtime = entry.Time.Go()
+		switch {
+		case entry.IsDir():
+		case entry.IsLink():
+			mode = sIFLNK | linkPerm
+			size = int64(len(s.linkTarget(entry)))
+		default:
+			mode = sIFREG | filePerm
+			if f.file != nil {
+				size = f.file.getSize()
+			} else if size, err = entry.Size(); err != nil {
+				return nil, err
+			}
+		}
+	}
+	e := newMessage(tgetattr+1, tag)
+	e.uint64(getattrBasic)
+	e.qid(q)
+	e.uint32(mode)
+	e.uint32(uint32(os.Getuid()))
+	e.uint32(uint32(os.Getgid()))
+	e.uint64(1) // Links.
+	e.uint64(0) // Device.
+	e.uint64(uint64(size))
+	e.uint64(blockSize)
+	e.uint64(uint64(size+511) / 512)
+	for i := 0; i < 3; i++ { // Access, modification and change times.
+		if mtime.IsZero() {
+			e.uint64(0)
+			e.uint64(0)
+		} else {
+			e.uint64(uint64(mtime.Unix()))
+			e.uint64(uint64(mtime.Nanosecond()))
+		}
+	}
+	e.uint64(0) // Birth time.
+	e.uint64(0)
+	e.uint64(0) // Generation.
+	e.uint64(0) // Data version.
+	return e, nil
+}
+
+func (s *server) setattr(tag uint16, n, valid uint32, size int64, mtime time.Time) (*encoder, error) {
+	f, err := s.lookupFid(n)
+	if err != nil {
+		return nil, err
+	}
+	if f.name == "" {
+		return nil, errno(eACCES, "cannot change the root")
+	}
+	// Modes and owners mean nothing to Upspin and are ignored, as are
+	// access and change times.
+	if valid&setattrSize != 0 {
+		cf := f.file
+		if cf == nil {
+			if cf, err = s.cache.openFile(f.name); err != nil {
+				return nil, err
+			}
+			defer cf.close()
+		}
+		if err := cf.truncate(size); err != nil {
+			return nil, err
+		}
+		if err := cf.sync(); err != nil {
+			return nil, err
+		}
+	}
+	if valid&setattrMtime != 0 {
+		if valid&setattrMtimeOn == 0 {
+			mtime = time.Now()
+		}
+		if f.file != nil {
+			// Write any data first, so the time is not replaced.
+			if err := f.file.sync(); err != nil {
+				return nil, err
+			}
+		}
+		if err := s.cli.SetTime(f.name, upspin.TimeFromGo(mtime)); err != nil {
+			return nil, err
+		}
+	}
+	return newMessage(tsetattr+1, tag), nil
+}
+
+func (s *server) readdir(tag uint16, n uint32, off uint64, count uint32) (*encoder, error) {
+	f, err := s.lookupFid(n)
+	if err != nil {
+		return nil, err
+	}
+	if !f.open || f.file != nil {
+		return nil, errno(eNOTDIR, "fid is not an open directory")
+	}
+	if count > s.iounit() {
+		count = s.iounit()
+	}
+	data := &encoder{}
+	for i := off; i < uint64(len(f.ents)); i++ {
+		entry := f.ents[i]
+		p, err := path.Parse(entry.Name)
+		if err != nil {
+			return nil, err
+		}
+		elem := string(p.User())
+		if !p.IsRoot() {
+			elem = p.Elem(p.NElem() - 1)
+		}
+		if len(data.b)+13+8+1+2+len(elem) > int(count) {
+			break
+		}
+		q := entryQid(entry)
+		data.qid(q)
+		data.uint64(i + 1) // Offset of the next entry.
+		switch q.typ {
+		case qtDir:
+			data.uint8(dtDir)
+		case qtSymlink:
+			data.uint8(dtLnk)
+		default:
+			data.uint8(dtReg)
+		}
+		data.string(elem)
+	}
+	e := newMessage(treaddir+1, tag)
+	e.data(data.b)
+	return e, nil
+}
+
+func (s *server) fsync(tag uint16, n uint32) (*encoder, error) {
+	f, err := s.lookupFid(n)
+	if err != nil {
+		return nil, err
+	}
+	if f.file != nil {
+		if err := f.file.sync(); err != nil {
+			return nil, err
+		}
+	}
+	return newMessage(tfsync+1, tag), nil
+}
+
+func (s *server) mkdir(tag uint16, n uint32, elem string) (*encoder, error) {
+	f, err := s.lookupDir(n)
+	if err != nil {
+		return nil, err
+	}
+	if f.name == "" {
+		return nil, errno(eACCES, "cannot create in the root")
+	}
+	name, err := child(f.name, elem)
+	if err != nil {
+		return nil, err
+	}
+	entry, err := s.cli.MakeDirectory(name)
+	if err != nil {
+		return nil, err
+	}
+	e := newMessage(tmkdir+1, tag)
+	e.qid(nameQid(name, upspin.AttrDirectory, entry.Sequence))
+	return e, nil
+}
+
+// childOf returns the name of the element of the directory named by the fid.
+func (s *server) childOf(n uint32, elem string) (upspin.PathName, error) {
+	f, err := s.lookupDir(n)
+	if err != nil {
+		return "", err
+	}
+	if f.name == "" {
+		return "", errno(eACCES, "cannot change the root")
+	}
+	return child(f.name, elem)
+}
+
+func (s *server) renameat(tag uint16, oldDir uint32, oldElem string, newDir uint32, newElem string) (*encoder, error) {
+	oldName, err := s.childOf(oldDir, oldElem)
+	if err != nil {
+		return nil, err
+	}
+	newName, err := s.childOf(newDir, newElem)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.renameFile(oldName, newName); err != nil {
+		return nil, err
+	}
+	return newMessage(trenameat+1, tag), nil
+}
+
+func (s *server) rename(tag uint16, n, dir uint32, elem string) (*encoder, error) {
+	f, err := s.lookupFid(n)
+	if err != nil {
+		return nil, err
+	}
+	if f.name == "" {
+		return nil, errno(eACCES, "cannot rename the root")
+	}
+	newName, err := s.childOf(dir, elem)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.renameFile(f.name, newName); err != nil {
+		return nil, err
+	}
+	return newMessage(trename+1, tag), nil
+}
+
+// renameFile renames the file, replacing any file, but not directory,
+// of the new name, and updates the fids that name it or its contents.
+func (s *server) renameFile(oldName, newName upspin.PathName) error {
+	entry, err := s.cli.Lookup(oldName, false)
+	if err != nil {
+		return err
+	}
+	if old, err := s.cli.Lookup(newName, false); err == nil && !old.IsDir() {
+		if err := s.delete(newName); err != nil {
+			return err
+		}
+	}
+	if entry.IsDir() {
+		dr, ok := s.cli.(clientutil.DirRenamer)
+		if !ok {
+			return upspin.ErrNotSupported
+		}
+		_, err = dr.RenameDir(oldName, newName)
+	} else {
+		_, err = s.cli.Rename(oldName, newName)
+	}
+	if err != nil {
+		return err
+	}
+	s.cache.forget(oldName, newName)
+	for _, f := range s.fids {
+		if f.name == oldName {
+			f.name = newName
+		} else if strings.HasPrefix(string(f.name), string(oldName)+"/") {
+			f.name = newName + f.name[len(oldName):]
+		}
+	}
+	return nil
+}
+
+func (s *server) unlinkat(tag uint16, dir uint32, elem string) (*encoder, error) {
+	name, err := s.childOf(dir, elem)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.delete(name); err != nil {
+		return nil, err
+	}
+	return newMessage(tunlinkat+1, tag), nil
+}
+
+func (s *server) readlink(tag uint16, n uint32) (*encoder, error) {
+	f, err := s.lookupFid(n)
+	if err != nil {
+		return nil, err
+	}
+	if f.name == "" {
+		return nil, errno(eINVAL, "not a link")
+	}
+	entry, err := s.cli.Lookup(f.name, false)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.IsLink() {
+		return nil, errno(eINVAL, "not a link")
+	}
+	e := newMessage(treadlink+1, tag)
+	e.string(s.linkTarget(entry))
+	return e, nil
+}
+
+// linkTarget returns the target of the link as a path relative to the
+// link's directory, which leads to the target wherever the name space
+// is mounted.
+func (s *server) linkTarget(entry *upspin.DirEntry) string {
+	p, err := path.Parse(entry.Name)
+	if err != nil {
+		return string(entry.Link)
+	}
+	// Climb from the link's directory to the root of the name space,
+	// past the directory's elements and its user name.
+	up := strings.Repeat("../", p.NElem())
+	return up + strings.TrimSuffix(string(entry.Link), "/")
+}
+
+func (s *server) statfs(tag uint16) *encoder {
+	// Upspin has no fixed capacity; report plenty.
+	const lots = 1 << 40
+	e := newMessage(tstatfs+1, tag)
+	e.uint32(v9fsMagic)
+	e.uint32(blockSize)
+	e.uint64(lots) // Blocks.
+	e.uint64(lots) // Free blocks.
+	e.uint64(lots) // Available blocks.
+	e.uint64(lots) // Files.
+	e.uint64(lots) // Free files.
+	e.uint64(0)    // File system ID.
+	e.uint32(255)  // Maximum name length.
+	return e
+}
@@ -0,0 +1,485 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+
+	"upspin.io/client"
+	"upspin.io/test/testenv"
+	"upspin.io/upspin"
+)
+
+const (
+	owner = "aly@example.com"
+	other = "bob@uncle.com"
+
+	rootFid = 1
+)
+
+// p9Client is a minimal 9P2000.L client for testing the server.
+type p9Client struct {
+	t       *testing.T
+	rw      io.ReadWriter
+	tag     uint16
+	nextFid uint32
+}
+
+func newP9Client(t *testing.T, rw io.ReadWriter) *p9Client {
+	c := &p9Client{t: t, rw: rw, nextFid: rootFid + 1}
+	d := c.rpc(tversion, func(e *encoder) {
+		e.uint32(8192)
+		e.string(protocolVersion)
+	})
+	if msize, version := d.uint32(), d.string(); msize != 8192 || version != protocolVersion {
+		t.Fatalf("version: got %d %q", msize, version)
+	}
+	c.rpc(tattach, func(e *encoder) {
+		e.uint32(rootFid)
+		e.uint32(noFid)
+		e.string(owner)
+		e.string("")
+		e.uint32(0)
+	})
+	return c
+}
+
+// call sends a request and returns the type and body of the response.
+func (c *p9Client) call(typ byte, fill func(e *encoder)) (byte, *decoder) {
+	c.t.Helper()
+	c.tag++
+	e := newMessage(typ, c.tag)
+	fill(e)
+	if _, err := c.rw.Write(e.bytes()); err != nil {
+		c.t.Fatal(err)
+	}
+	rtyp, tag, body, err := readMessage(c.rw, maxMessage)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if tag != c.tag {
+		c.t.Fatalf("response tag %d, want %d", tag, c.tag)
+	}
+	return rtyp, &decoder{b: body}
+}
+
+// rpc sends a request that must succeed and returns the response body.
+func (c *p9Client) rpc(typ byte, fill func(e *encoder)) *decoder {
+	c.t.Helper()
+	rtyp, d := c.call(typ, fill)
+	if rtyp == rlerror {
+		c.t.Fatalf("request type %d: error %d", typ, d.uint32())
+	}
+	if rtyp != typ+1 {
+		c.t.Fatalf("request type %d: response type %d", typ, rtyp)
+	}
+	return d
+}
+
+// lerror sends a request that must fail and returns the error number.
+func (c *p9Client) lerror(typ byte, fill func(e *encoder)) uint32 {
+	c.t.Helper()
+	rtyp, d := c.call(typ, fill)
+	if rtyp != rlerror {
+		c.t.Fatalf("request type %d: response type %d, want error", typ, rtyp)
+	}
+	return d.uint32()
+}
+
+func walkRequest(fid, newFid uint32, names []string) func(e *encoder) {
+	return func(e *encoder) {
+		e.uint32(fid)
+		e.uint32(newFid)
+		e.uint16(uint16(len(names)))
+		for _, n := range names {
+			e.string(n)
+		}
+	}
+}
+
+// walk walks from the root to the slash-separated name and returns the new fid.
+func (c *p9Client) walk(name string) uint32 {
+	c.t.Helper()
+	fid := c.nextFid
+	c.nextFid++
+	var names []string
+	if name != "" {
+		names = strings.Split(name, "/")
+	}
+	d := c.rpc(twalk, walkRequest(rootFid, fid, names))
+	if n := d.uint16(); int(n) != len(names) {
+		c.t.Fatalf("walk %s: %d qids", name, n)
+	}
+	return fid
+}
+
+// walkError walks to the named file, which must fail, from its
+// directory and returns the error number. (A walk of several names that
+// fails after the first reports how far it got, not the error.)
+func (c *p9Client) walkError(name string) uint32 {
+	c.t.Helper()
+	i := strings.LastIndex(name, "/")
+	dir := c.walk(name[:i])
+	defer c.clunk(dir)
+	return c.lerror(twalk, walkRequest(dir, c.nextFid, []string{name[i+1:]}))
+}
+
+func (c *p9Client) open(fid, flags uint32) {
+	c.t.Helper()
+	c.rpc(tlopen, func(e *encoder) {
+		e.uint32(fid)
+		e.uint32(flags)
+	})
+}
+
+func (c *p9Client) clunk(fid uint32) {
+	c.t.Helper()
+	c.rpc(tclunk, func(e *encoder) { e.uint32(fid) })
+}
+
+// readFile reads the named file.
+func (c *p9Client) readFile(name string) string {
+	c.t.Helper()
+	fid := c.walk(name)
+	defer c.clunk(fid)
+	c.open(fid, oRdonly)
+	var data []byte
+	for {
+		d := c.rpc(tread, func(e *encoder) {
+			e.uint32(fid)
+			e.uint64(uint64(len(data)))
+			e.uint32(100)
+		})
+		b := d.data()
+		if len(b) == 0 {
+			return string(data)
+		}
+		data = append(data, b...)
+	}
+}
+
+// createFile creates the named file in the directory and writes data to it.
+func (c *p9Client) createFile(dir, name, data string) {
+	c.t.Helper()
+	fid := c.walk(dir)
+	c.rpc(tlcreate, func(e *encoder) {
+		e.uint32(fid)
+		e.string(name)
+		e.uint32(oRdwr | oCreat | oExcl)
+		e.uint32(0644)
+		e.uint32(0)
+	})
+	d := c.rpc(twrite, func(e *encoder) {
+		e.uint32(fid)
+		e.uint64(0)
+		e.data([]byte(data))
+	})
+	if n := d.uint32(); int(n) != len(data) {
+		c.t.Fatalf("wrote %d bytes, want %d", n, len(data))
+	}
+	c.clunk(fid)
+}
+
+// readDir returns the names in the directory, sorted.
+func (c *p9Client) readDir(name string) []string {
+	c.t.Helper()
+	fid := c.walk(name)
+	defer c.clunk(fid)
+	c.open(fid, oRdonly)
+	var names []string
+	var off uint64
+	for {
+		d := c.rpc(treaddir, func(e *encoder) {
+			e.uint32(fid)
+			e.uint64(off)
+			e.uint32(60) // Small, to need several requests.
+		})
+		ents := &decoder{b: d.data()}
+		if len(ents.b) == 0 {
+			break
+		}
+		for len(ents.b) > 0 && ents.err == nil {
+			ents.qid()
+			off = ents.uint64()
+			ents.uint8()
+			names = append(names, ents.string())
+		}
+		if ents.err != nil {
+			c.t.Fatal(ents.err)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// getattr returns the mode and size of the named file.
+func (c *p9Client) getattr(name string) (uint32, uint64) {
+	c.t.Helper()
+	fid := c.walk(name)
+	defer c.clunk(fid)
+	d := c.rpc(tgetattr, func(e *encoder) {
+		e.uint32(fid)
+		e.uint64(getattrBasic)
+	})
+	d.uint64()
+	d.qid()
+	mode := d.uint32()
+	d.uint32()
+	d.uint32()
+	d.uint64()
+	d.uint64()
+	return mode, d.uint64()
+}
+
+func setup(t *testing.T) (*testenv.Env, upspin.Client) {
+	env, err := testenv.New(&testenv.Setup{
+		OwnerName: owner,
+		Packing:   upspin.EEPack,
+		Kind:      "server",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := testenv.NewRunner()
+	r.AddUser(env.Config)
+	r.As(owner)
+	r.MakeDirectory(owner + "/dir")
+	r.Put(owner+"/dir/file", "hello, world")
+	r.PutLink(owner+"/dir/file", owner+"/link")
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+	return env, client.New(env.Config)
+}
+
+// newPipe starts a 9P server on one end of a pipe and returns a client,
+// attached to the root, for the other.
+func newPipe(t *testing.T, cli upspin.Client) (*p9Client, func()) {
+	dir, err := os.MkdirTemp("", "upspin-9p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := newCache(cli, dir, 1<<20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sc, cc := net.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- newServer(cli, owner, c).serve(sc)
+	}()
+	return newP9Client(t, cc), func() {
+		cc.Close()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+		os.RemoveAll(dir)
+	}
+}
+
+func TestServer(t *testing.T) {
+	env, cli := setup(t)
+	defer env.Exit()
+	c, done := newPipe(t, cli)
+	defer done()
+
+	// Directory listings.
+	if got, want := strings.Join(c.readDir(owner), " "), "dir link"; got != want {
+		t.Errorf("listing of %s = %q, want %q", owner, got, want)
+	}
+	if got, want := strings.Join(c.readDir(""), " "), owner; got != want {
+		t.Errorf("listing of root = %q, want %q", got, want)
+	}
+	for i := 0; i < 20; i++ {
+		if _, err := cli.Put(upspin.PathName(owner+"/dir/f"+string(rune('a'+i))), nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := c.readDir(owner + "/dir"); len(got) != 21 || got[0] != "fa" || got[9] != "file" {
+		t.Errorf("listing of dir = %q", got)
+	}
+	if mode, _ := c.getattr(owner + "/dir"); mode&sIFDIR == 0 {
+		t.Errorf("mode of dir is %o", mode)
+	}
+
+	// Reads, including through a link.
+	if got, want := c.readFile(owner+"/dir/file"), "hello, world"; got != want {
+		t.Errorf("file = %q, want %q", got, want)
+	}
+	fid := c.walk(owner + "/link")
+	d := c.rpc(treadlink, func(e *encoder) { e.uint32(fid) })
+	if got, want := d.string(), "../"+owner+"/dir/file"; got != want {
+		t.Errorf("link target = %q, want %q", got, want)
+	}
+	c.clunk(fid)
+	if got, want := c.readFile(owner+"/"+"../"+owner+"/dir/file"), "hello, world"; got != want {
+		t.Errorf("file through link target = %q, want %q", got, want)
+	}
+
+	// Round trip of a new file.
+	c.createFile(owner+"/dir", "new", "new data")
+	if data, err := cli.Get(owner + "/dir/new"); err != nil || string(data) != "new data" {
+		t.Fatalf("Get = %q, %v; want %q", data, err, "new data")
+	}
+	if got, want := c.readFile(owner+"/dir/new"), "new data"; got != want {
+		t.Errorf("new file = %q, want %q", got, want)
+	}
+	if mode, size := c.getattr(owner + "/dir/new"); mode&sIFREG == 0 || size != 8 {
+		t.Errorf("mode and size of new file = %o %d", mode, size)
+	}
+	dir := c.walk(owner + "/dir")
+	if errno := c.lerror(tlcreate, func(e *encoder) {
+		e.uint32(dir)
+		e.string("new")
+		e.uint32(oWronly | oCreat | oExcl)
+		e.uint32(0644)
+		e.uint32(0)
+	}); errno != eEXIST {
+		t.Errorf("exclusive create of existing file: error %d, want %d", errno, eEXIST)
+	}
+
+	// Appending, and truncating through setattr.
+	fid = c.walk(owner + "/dir/new")
+	c.open(fid, oWronly|oAppend)
+	c.rpc(twrite, func(e *encoder) {
+		e.uint32(fid)
+		e.uint64(0)
+		e.data([]byte(", more"))
+	})
+	c.clunk(fid)
+	if got, want := c.readFile(owner+"/dir/new"), "new data, more"; got != want {
+		t.Errorf("appended file = %q, want %q", got, want)
+	}
+	fid = c.walk(owner + "/dir/new")
+	c.rpc(tsetattr, func(e *encoder) {
+		e.uint32(fid)
+		e.uint32(setattrSize)
+		e.uint32(0)
+		e.uint32(0)
+		e.uint32(0)
+		e.uint64(3)
+		for i := 0; i < 4; i++ {
+			e.uint64(0)
+		}
+	})
+	c.clunk(fid)
+	if data, err := cli.Get(owner + "/dir/new"); err != nil || string(data) != "new" {
+		t.Errorf("truncated file = %q, %v; want %q", data, err, "new")
+	}
+
+	// Renames: a file, replacing another, and a directory.
+	c.rpc(trenameat, func(e *encoder) {
+		e.uint32(dir)
+		e.string("new")
+		e.uint32(dir)
+		e.string("file")
+	})
+	if _, err := cli.Lookup(owner+"/dir/new", false); err == nil {
+		t.Errorf("renamed file still exists")
+	}
+	if got, want := c.readFile(owner+"/dir/file"), "new"; got != want {
+		t.Errorf("renamed file = %q, want %q", got, want)
+	}
+	root := c.walk(owner)
+	c.rpc(tmkdir, func(e *encoder) {
+		e.uint32(root)
+		e.string("sub")
+		e.uint32(0755)
+		e.uint32(0)
+	})
+	c.createFile(owner+"/sub", "file", "sub file")
+	sub := c.walk(owner + "/sub")
+	c.rpc(trename, func(e *encoder) {
+		e.uint32(sub)
+		e.uint32(root)
+		e.string("moved")
+	})
+	if got, want := c.readFile(owner+"/moved/file"), "sub file"; got != want {
+		t.Errorf("file in renamed directory = %q, want %q", got, want)
+	}
+
+	// Removal.
+	if errno := c.lerror(tunlinkat, func(e *encoder) {
+		e.uint32(root)
+		e.string("moved")
+		e.uint32(atRemovedir)
+	}); errno != eNOTEMPTY {
+		t.Errorf("removing non-empty directory: error %d, want %d", errno, eNOTEMPTY)
+	}
+	c.rpc(tunlinkat, func(e *encoder) {
+		e.uint32(sub)
+		e.string("file")
+		e.uint32(0)
+	})
+	c.rpc(tunlinkat, func(e *encoder) {
+		e.uint32(root)
+		e.string("moved")
+		e.uint32(atRemovedir)
+	})
+	if errno := c.walkError(owner + "/moved"); errno != eNOENT {
+		t.Errorf("walk to removed directory: error %d, want %d", errno, eNOENT)
+	}
+	c.clunk(sub)
+	c.clunk(root)
+	c.clunk(dir)
+}
+
+func TestPermission(t *testing.T) {
+	env, cli := setup(t)
+	defer env.Exit()
+	cfg, err := env.NewUser(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := testenv.NewRunner()
+	r.AddUser(cfg)
+	r.As(other)
+	r.MakeDirectory(other + "/")
+	r.MakeDirectory(other + "/shared")
+	r.Put(other+"/shared/Access", "r: "+owner+"\n*: "+other+"\n")
+	r.Put(other+"/shared/file", "shared")
+	r.Put(other+"/private", "private")
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+	c, done := newPipe(t, cli)
+	defer done()
+
+	if got, want := c.readFile(other+"/shared/file"), "shared"; got != want {
+		t.Errorf("shared file = %q, want %q", got, want)
+	}
+	// Files that may not be seen can be walked to but not used.
+	private := c.walk(other + "/private")
+	if errno := c.lerror(tlopen, func(e *encoder) {
+		e.uint32(private)
+		e.uint32(oRdonly)
+	}); errno != eACCES {
+		t.Errorf("open of private file: error %d, want %d", errno, eACCES)
+	}
+	if errno := c.lerror(tgetattr, func(e *encoder) {
+		e.uint32(private)
+		e.uint64(getattrBasic)
+	}); errno != eACCES {
+		t.Errorf("getattr of private file: error %d, want %d", errno, eACCES)
+	}
+	dir := c.walk(other + "/shared")
+	if errno := c.lerror(tlcreate, func(e *encoder) {
+		e.uint32(dir)
+		e.string("new")
+		e.uint32(oWronly | oCreat)
+		e.uint32(0644)
+		e.uint32(0)
+	}); errno != eACCES {
+		t.Errorf("create in read-only directory: error %d, want %d", errno, eACCES)
+	}
+	if errno := c.walkError(owner + "/missing"); errno != eNOENT {
+		t.Errorf("walk to missing file: error %d, want %d", errno, eNOENT)
+	}
+}
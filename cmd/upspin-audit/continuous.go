@@ -0,0 +1,113 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/shutdown"
+)
+
+// This file implements the -continuous mode of the subcommands, in which
+// they repeat periodically, and the lock files that stop two runs of the
+// same subcommand working in the same data directory at once.
+
+const lockFileSuffix = ".lock"
+
+// scheduleFlags holds the values of the flags that control repetition.
+type scheduleFlags struct {
+	command    string // Name of the subcommand, naming its lock file.
+	continuous bool
+	interval   time.Duration
+}
+
+// newScheduleFlags returns a scheduleFlags bound to new flags in fs.
+// Done here so the definition can be common among the commands.
+func newScheduleFlags(fs *flag.FlagSet, interval time.Duration) *scheduleFlags {
+	f := scheduleFlags{command: fs.Name()}
+	fs.BoolVar(&f.continuous, "continuous", false, "repeat the command every -interval until killed")
+	fs.DurationVar(&f.interval, "interval", interval, "`duration` between the starts of runs in -continuous mode")
+	return &f
+}
+
+// repeat calls round, holding the subcommand's lock file in dataDir while
+// it does. In continuous mode it does so every interval, forever; a round
+// whose turn comes while another run of the subcommand holds the lock is
+// skipped.
+func (s *State) repeat(dataDir string, f *scheduleFlags, round func()) {
+	if f.continuous && f.interval <= 0 {
+		s.Exitf("-interval must be positive")
+	}
+	for {
+		start := time.Now()
+		if err := lock(dataDir, f.command); err != nil {
+			if !f.continuous {
+				s.Exit(err)
+			}
+			log.Printf("skipping run: %v", err)
+		} else {
+			round()
+			unlock()
+		}
+		if !f.continuous {
+			return
+		}
+		next := start.Add(f.interval)
+		log.Printf("next run at %s", next.Format(timeFormat))
+		time.Sleep(time.Until(next))
+	}
+}
+
+var lockState struct {
+	sync.Mutex
+	once sync.Once
+	file string // Name of the lock file held, if any.
+}
+
+// lock creates the lock file for the command in dataDir, failing if it
+// exists. The lock file is removed by unlock or if the process exits.
+func lock(dataDir, command string) error {
+	lockState.once.Do(func() { shutdown.Handle(unlock) })
+	file := filepath.Join(dataDir, command+lockFileSuffix)
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return errors.Errorf("another %s is using %s; if none is, remove the lock file %s", command, dataDir, file)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(f, "%d\n", os.Getpid())
+	if cErr := f.Close(); err == nil {
+		err = cErr
+	}
+	if err != nil {
+		os.Remove(file)
+		return err
+	}
+	lockState.Lock()
+	lockState.file = file
+	lockState.Unlock()
+	return nil
+}
+
+// unlock removes the lock file held by lock, if any.
+func unlock() {
+	lockState.Lock()
+	defer lockState.Unlock()
+	if lockState.file == "" {
+		return
+	}
+	if err := os.Remove(lockState.file); err != nil {
+		log.Printf("removing lock file: %v", err)
+	}
+	lockState.file = ""
+}
@@ -9,8 +9,10 @@ package main
 import (
 	"flag"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
+	"time"
 
 	"upspin.io/bind"
 	"upspin.io/cloud/storage"
@@ -30,18 +32,39 @@ as only that user has permission to delete blocks.
 The -backup flag specifies a local directory in which to store local
 copies of the blocks before they are deleted.
 
+With -continuous, the deletion is repeated every -interval. Each run
+deletes the blocks in the latest garbage list for the store that is at
+least -delay old, giving time to review the list, and that it has not
+already deleted.
+
 Misuse of this command may result in permanent data loss. Use with caution.
+
+A schedule for collecting garbage continuously, with scans daily and
+deletion a day after detection, is:
+
+  upspin-audit scan-store -continuous -interval=24h
+  upspin-audit scan-dir -continuous -interval=24h root ...
+  upspin-audit find-garbage -continuous -interval=1h
+  upspin-audit delete-garbage -continuous -interval=1h -delay=24h
+
+with scan-dir started after scan-store.
 `
 	fs := flag.NewFlagSet("delete-garbage", flag.ExitOnError)
 	dataDir := dataDirFlag(fs)
 	backupDir := fs.String("backup", "", "local directory in which to store deleted blocks")
-	s.ParseFlags(fs, args, help, "audit delete-garbage")
+	delay := fs.Duration("delay", 24*time.Hour, "in -continuous mode, minimum `age` of a garbage list before its blocks are deleted")
+	schedule := newScheduleFlags(fs, time.Hour)
+	s.ParseFlags(fs, args, help, "audit delete-garbage [-continuous [-interval <duration>] [-delay <duration>]]")
 
 	if fs.NArg() != 0 {
 		fs.Usage()
 		os.Exit(2)
 	}
 
+	if err := os.MkdirAll(*dataDir, 0700); err != nil {
+		s.Exit(err)
+	}
+
 	var backup storage.Storage
 	if *backupDir != "" {
 		var err error
@@ -55,46 +78,95 @@ Misuse of this command may result in permanent data loss. Use with caution.
 		}
 	}
 
-	for _, fi := range s.latestFilesWithPrefix(*dataDir, garbageFilePrefix) {
-		if fi.Addr != s.Config.StoreEndpoint().NetAddr {
-			// Only delete from the store endpoint of the current user.
+	// Garbage lists already deleted, by file name.
+	deleted := make(map[string]bool)
+	s.repeat(*dataDir, schedule, func() {
+		files := s.latestFilesWithPrefix(*dataDir, garbageFilePrefix)
+		if schedule.continuous {
+			files = s.ripeGarbageFiles(*dataDir, *delay, deleted)
+		}
+		for _, fi := range files {
+			if fi.Addr != s.Config.StoreEndpoint().NetAddr {
+				// Only delete from the store endpoint of the current user.
+				continue
+			}
+			s.deleteGarbageIn(fi, backup)
+			deleted[fi.Path] = true
+		}
+	})
+}
+
+// deleteGarbageIn deletes the blocks listed in the garbage file fi from the
+// store, first saving them in backup if it is not nil.
+func (s *State) deleteGarbageIn(fi fileInfo, backup storage.Storage) {
+	garbage, err := s.readItems(fi.Path)
+	if err != nil {
+		s.Exit(err)
+	}
+	store, err := bind.StoreServer(s.Config, s.Config.StoreEndpoint())
+	if err != nil {
+		s.Exit(err)
+	}
+	const numWorkers = 10
+	d := deleter{
+		State:  s,
+		store:  store,
+		backup: backup,
+		refs:   make(chan upspin.Reference),
+		stop:   make(chan bool, numWorkers),
+	}
+	d.done.Add(numWorkers)
+	for i := 0; i < numWorkers; i++ {
+		go d.worker()
+	}
+loop:
+	for ref := range garbage {
+		if strings.HasPrefix(string(ref), rootRefPrefix) {
+			// Don't ever collect root backups.
+			continue
+		}
+		select {
+		case d.refs <- ref:
+		case <-d.stop:
+			break loop
+		}
+	}
+	close(d.refs)
+	d.done.Wait()
+}
+
+// ripeGarbageFiles returns, for each store endpoint, the latest garbage file
+// in dir that was written at least delay ago and is not in deleted.
+func (s *State) ripeGarbageFiles(dir string, delay time.Duration, deleted map[string]bool) (files []fileInfo) {
+	paths, err := filepath.Glob(filepath.Join(dir, garbageFilePrefix+"*"))
+	if err != nil {
+		s.Exit(err)
+	}
+	latest := make(map[upspin.NetAddr]fileInfo)
+	for _, file := range paths {
+		if strings.HasSuffix(file, tmpFileSuffix) || deleted[file] {
 			continue
 		}
-		garbage, err := s.readItems(fi.Path)
+		fi, err := filenameToFileInfo(file, garbageFilePrefix)
 		if err != nil {
 			s.Exit(err)
 		}
-		store, err := bind.StoreServer(s.Config, s.Config.StoreEndpoint())
+		info, err := os.Stat(file)
 		if err != nil {
 			s.Exit(err)
 		}
-		const numWorkers = 10
-		d := deleter{
-			State:  s,
-			store:  store,
-			backup: backup,
-			refs:   make(chan upspin.Reference),
-			stop:   make(chan bool, numWorkers),
-		}
-		d.done.Add(numWorkers)
-		for i := 0; i < numWorkers; i++ {
-			go d.worker()
+		if time.Since(info.ModTime()) < delay {
+			continue // Still under review.
 		}
-	loop:
-		for ref := range garbage {
-			if strings.HasPrefix(string(ref), rootRefPrefix) {
-				// Don't ever collect root backups.
-				continue
-			}
-			select {
-			case d.refs <- ref:
-			case <-d.stop:
-				break loop
-			}
+		if cur, ok := latest[fi.Addr]; ok && cur.Time.After(fi.Time) {
+			continue
 		}
-		close(d.refs)
-		d.done.Wait()
+		latest[fi.Addr] = fi
+	}
+	for _, fi := range latest {
+		files = append(files, fi)
 	}
+	return files
 }
 
 // deleter holds the state of delete-garbage workers.
@@ -7,9 +7,11 @@ package main
 import (
 	"flag"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 func (s *State) findGarbage(args []string) {
@@ -22,10 +24,17 @@ directory entries but not present in the store server (missing blocks).
 If garbage or missing blocks are found they are written to a files named
 "garbage_EP_TS" and "missing_EP_TS" in the directory nominated by -data, where
 "EP" is the store endpoint and "TS" is the time at which the store was scanned.
+
+With -continuous, the analysis is repeated every -interval. Each run
+analyses the latest scan of each store once scan-dir output newer than
+it is present, and skips stores whose latest scan it has already
+analysed, so that run alongside continuous scans it maintains a rolling
+list of garbage for delete-garbage -continuous to consume.
 `
 	fs := flag.NewFlagSet("find-garbage", flag.ExitOnError)
 	dataDir := dataDirFlag(fs)
-	s.ParseFlags(fs, args, help, "audit find-garbage")
+	schedule := newScheduleFlags(fs, time.Hour)
+	s.ParseFlags(fs, args, help, "audit find-garbage [-continuous [-interval <duration>]]")
 
 	if fs.NArg() != 0 {
 		fs.Usage()
@@ -36,9 +45,21 @@ If garbage or missing blocks are found they are written to a files named
 		s.Exit(err)
 	}
 
+	// Store scans already analysed, by file name.
+	analysed := make(map[string]bool)
+	s.repeat(*dataDir, schedule, func() {
+		s.findGarbageOnce(*dataDir, schedule.continuous, analysed)
+	})
+}
+
+// findGarbageOnce analyses the latest scans in dataDir. In continuous mode,
+// a store scan that is in analysed or is not yet followed by scans of the
+// trees that use it is skipped rather than being an error, and each store
+// scan analysed is added to analysed.
+func (s *State) findGarbageOnce(dataDir string, continuous bool, analysed map[string]bool) {
 	// Iterate through the files in dataDir and collect a set of the latest
 	// files for each dir endpoint/tree and store endpoint.
-	latest := s.latestFilesWithPrefix(*dataDir, storeFilePrefix, dirFilePrefix)
+	latest := s.latestFilesWithPrefix(dataDir, storeFilePrefix, dirFilePrefix)
 
 	// Print a summary of the files we found.
 	nDirs, nStores := 0, 0
@@ -65,25 +86,25 @@ If garbage or missing blocks are found they are written to a files named
 	fmt.Println()
 
 	if nDirs == 0 || nStores == 0 {
+		if continuous {
+			log.Printf("nothing to do yet; waiting for scan-store and scan-dir")
+			return
+		}
 		s.Exitf("nothing to do; run scan-store and scan-dir first")
 	}
 
 	// Look for garbage references and summarize them.
+stores:
 	for _, store := range latest {
 		if store.User != "" {
 			continue // Ignore dirs.
 		}
-		storeItems, err := s.readItems(store.Path)
-		if err != nil {
-			s.Exit(err)
-		}
-		dirsMissing := make(refMap)
-		for _, ri := range storeItems {
-			dirsMissing.addRef(ri.Ref, ri.Size, "")
+		if analysed[store.Path] {
+			continue
 		}
-		storeMissing := make(refMap)
 
-		var users []string
+		// Collect the scans of the trees that use the store.
+		var dirs []fileInfo
 		for _, dir := range latest {
 			if dir.User == "" {
 				continue // Ignore stores.
@@ -92,10 +113,34 @@ If garbage or missing blocks are found they are written to a files named
 				continue
 			}
 			if dir.Time.Before(store.Time) {
+				if continuous {
+					log.Printf("waiting for scan-dir of %s to follow scan-store of %s", dir.User, store.Addr)
+					continue stores
+				}
 				s.Exitf("scan-store must be performed before all scan-dir operations\n"+
 					"scan-dir output in\n\t%s\npredates scan-store output in\n\t%s",
 					filepath.Base(dir.Path), filepath.Base(store.Path))
 			}
+			dirs = append(dirs, dir)
+		}
+		if continuous && len(dirs) == 0 {
+			// Without any trees, every block would be garbage.
+			log.Printf("waiting for scan-dir of a tree using %s", store.Addr)
+			continue
+		}
+
+		storeItems, err := s.readItems(store.Path)
+		if err != nil {
+			s.Exit(err)
+		}
+		dirsMissing := make(refMap)
+		for _, ri := range storeItems {
+			dirsMissing.addRef(ri.Ref, ri.Size, "")
+		}
+		storeMissing := make(refMap)
+
+		var users []string
+		for _, dir := range dirs {
 			users = append(users, string(dir.User))
 			dirItems, err := s.readItems(dir.Path)
 			if err != nil {
@@ -114,13 +159,14 @@ If garbage or missing blocks are found they are written to a files named
 			file := fmt.Sprintf("%s%s_%d", missingFilePrefix, store.Addr, store.Time.Unix())
 			fmt.Printf("Store %q is missing %d blocks referred to by the scanned trees, written to:\n\t%s\n",
 				store.Addr, len(storeMissing), file)
-			s.writeItems(filepath.Join(*dataDir, file), storeMissing.slice())
+			s.writeItems(filepath.Join(dataDir, file), storeMissing.slice())
 		}
 		if len(dirsMissing) > 0 {
 			file := fmt.Sprintf("%s%s_%d", garbageFilePrefix, store.Addr, store.Time.Unix())
 			fmt.Printf("Store %q contains %d blocks not present in these trees:\n\t%s\nwritten to:\n\t%s\n",
 				store.Addr, len(dirsMissing), strings.Join(users, "\n\t"), file)
-			s.writeItems(filepath.Join(*dataDir, file), dirsMissing.slice())
+			s.writeItems(filepath.Join(dataDir, file), dirsMissing.slice())
 		}
+		analysed[store.Path] = true
 	}
 }
@@ -42,6 +42,8 @@ const (
 	storeFilePrefix   = "store_"
 	garbageFilePrefix = "garbage_"
 	missingFilePrefix = "missing_"
+
+	tmpFileSuffix = ".tmp"
 )
 
 type State struct {
@@ -67,6 +69,10 @@ The subcommands are:
   delete-garbage
   	Delete the blocks found by find-garbage from the store server.
 
+  generate-warmup
+  	Scan directory trees and list the blocks of their most recently
+  	modified files, for use as a cacheserver -warmup-file.
+
 To delete the garbage references in a given store server:
 
   1. Run scan-store (as the store server user) to generate a list of references
@@ -77,7 +83,12 @@ To delete the garbage references in a given store server:
   3. Run find-garbage to compile a list of references that are in the scan-store
      output but not in the combined output of the scan-dir runs.
   4. Run delete-garbage (as the store server user) to delete the blocks in the
-     find-garbage output.`
+     find-garbage output.
+
+Each subcommand accepts -continuous, which makes it repeat every -interval
+until killed, so that garbage is found and deleted on a schedule; see the
+help for delete-garbage. Two runs of the same subcommand cannot use the
+same data directory at once.`
 
 func main() {
 	const name = "audit"
@@ -115,6 +126,8 @@ func main() {
 		s.findGarbage(flag.Args()[1:])
 	case "delete-garbage":
 		s.deleteGarbage(flag.Args()[1:])
+	case "generate-warmup":
+		s.generateWarmup(flag.Args()[1:])
 	default:
 		usage()
 	}
@@ -126,7 +139,7 @@ func usage() {
 	fmt.Fprintln(os.Stderr, help)
 	fmt.Fprintln(os.Stderr, "Usage of upspin audit:")
 	fmt.Fprintln(os.Stderr, "\tupspin [globalflags] audit <command> [flags] ...")
-	fmt.Fprintln(os.Stderr, "Commands: scan-dir, scan-store, find-garbage, delete-garbage")
+	fmt.Fprintln(os.Stderr, "Commands: scan-dir, scan-store, find-garbage, delete-garbage, generate-warmup")
 	fmt.Fprintln(os.Stderr, "Global flags:")
 	flag.PrintDefaults()
 	os.Exit(2)
@@ -186,7 +199,10 @@ func (m refsByEndpoint) addRef(ep upspin.Endpoint, ref upspin.Reference, size in
 func (s *State) writeItems(file string, items []refInfo) {
 	sort.Slice(items, func(i, j int) bool { return items[i].Ref < items[j].Ref })
 
-	f, err := os.Create(file)
+	// Write to a temporary file and rename it when done, so that a
+	// concurrent find-garbage or delete-garbage never sees part of it.
+	tmp := file + tmpFileSuffix
+	f, err := os.Create(tmp)
 	if err != nil {
 		s.Exit(err)
 	}
@@ -194,6 +210,9 @@ func (s *State) writeItems(file string, items []refInfo) {
 		if err := f.Close(); err != nil {
 			s.Exit(err)
 		}
+		if err := os.Rename(tmp, file); err != nil {
+			s.Exit(err)
+		}
 	}()
 	w := bufio.NewWriter(f)
 	for _, ri := range items {
@@ -274,6 +293,9 @@ func (s *State) latestFilesWithPrefix(dir string, prefixes ...string) (files []f
 	}
 	latest := make(map[latestKey]fileInfo)
 	for _, file := range paths {
+		if strings.HasSuffix(file, tmpFileSuffix) {
+			continue // Still being written.
+		}
 		fi, err := filenameToFileInfo(file, prefixes...)
 		if err == errIgnoreFile {
 			continue
@@ -45,12 +45,16 @@ by -data, where "EP" is the store endpoint, "USER" is the directory user name,
 and "TS" is the current time.
 
 It should be run as a user that has full read access to the named roots.
+
+With -continuous, the scan is repeated every -interval, each writing
+new files.
 `
 
 	fs := flag.NewFlagSet("scan-dir", flag.ExitOnError)
 	glob := fs.Bool("glob", true, "apply glob processing to the arguments")
 	dataDir := dataDirFlag(fs)
-	s.ParseFlags(fs, args, help, "audit scan-dir root ...")
+	schedule := newScheduleFlags(fs, 24*time.Hour)
+	s.ParseFlags(fs, args, help, "audit scan-dir [-continuous [-interval <duration>]] root ...")
 
 	if fs.NArg() == 0 || fs.Arg(0) == "help" {
 		fs.Usage()
@@ -81,35 +85,16 @@ It should be run as a user that has full read access to the named roots.
 		}
 	}
 
-	now := time.Now()
-
-	sc := dirScanner{
-		State:    s,
-		buffer:   make(chan *upspin.DirEntry),
-		dirsToDo: make(chan *upspin.DirEntry),
-		done:     make(chan *upspin.DirEntry),
-	}
-
-	for i := 0; i < scanParallelism; i++ {
-		go sc.dirWorker()
-	}
-	go sc.bufferLoop()
-
-	// Prime the pump.
-	for _, p := range paths {
-		de, err := s.DirServer(p).Lookup(p)
-		if err != nil {
-			s.Exit(err)
-		}
-		sc.do(de)
-	}
+	s.repeat(*dataDir, schedule, func() {
+		s.scanDirectoriesOnce(*dataDir, paths)
+	})
+}
 
-	// Shut down the process tree once nothing is in flight.
-	go func() {
-		sc.inFlight.Wait()
-		close(sc.buffer)
-		close(sc.done)
-	}()
+// scanDirectoriesOnce scans the trees rooted at paths and writes the lists
+// of references they hold to new files in dataDir.
+func (s *State) scanDirectoriesOnce(dataDir string, paths []upspin.PathName) {
+	now := time.Now()
+	sc := s.startScan(paths)
 
 	// Receive and collect the data.
 	endpoints := make(refsByEndpoint)
@@ -149,12 +134,51 @@ It should be run as a user that has full read access to the named roots.
 	// Write the data to files, one for each user/endpoint combo.
 	for u, size := range users {
 		for ep, refs := range size {
-			file := filepath.Join(*dataDir, fmt.Sprintf("%s%s_%s_%d", dirFilePrefix, ep.NetAddr, u, now.Unix()))
+			file := filepath.Join(dataDir, fmt.Sprintf("%s%s_%s_%d", dirFilePrefix, ep.NetAddr, u, now.Unix()))
 			s.writeItems(file, refs.slice())
 		}
 	}
 }
 
+// startScan starts scanning the trees rooted at paths. The returned
+// scanner's done channel delivers every file and directory in the trees
+// and is closed once the scan is complete.
+func (s *State) startScan(paths []upspin.PathName) *dirScanner {
+	sc := &dirScanner{
+		State:    s,
+		buffer:   make(chan *upspin.DirEntry),
+		dirsToDo: make(chan *upspin.DirEntry),
+		done:     make(chan *upspin.DirEntry),
+	}
+
+	for i := 0; i < scanParallelism; i++ {
+		go sc.dirWorker()
+	}
+	go sc.bufferLoop()
+
+	// Prime the pump. The roots are looked up in the background, as a
+	// root that is a file is delivered on the done channel at once.
+	sc.inFlight.Add(1)
+	go func() {
+		defer sc.inFlight.Done()
+		for _, p := range paths {
+			de, err := s.DirServer(p).Lookup(p)
+			if err != nil {
+				s.Exit(err)
+			}
+			sc.do(de)
+		}
+	}()
+
+	// Shut down the process tree once nothing is in flight.
+	go func() {
+		sc.inFlight.Wait()
+		close(sc.buffer)
+		close(sc.done)
+	}()
+	return sc
+}
+
 // do processes a DirEntry. If it's a file, we deliver it to the done channel.
 // Otherwise it's a directory and we buffer it for expansion.
 func (sc *dirScanner) do(entry *upspin.DirEntry) {
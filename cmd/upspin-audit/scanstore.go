@@ -29,12 +29,16 @@ by -data, where "EP" is the store endpoint and "TS" is the current time.
 
 It must be run as the same Upspin user as the store server itself,
 as only that user has permission to list references.
+
+With -continuous, the scan is repeated every -interval, each writing
+a new file.
 `
 
 	fs := flag.NewFlagSet("scan-store", flag.ExitOnError)
 	endpointFlag := fs.String("endpoint", string(s.Config.StoreEndpoint().NetAddr), "network `address` of storage server; default is from config")
 	dataDir := dataDirFlag(fs)
-	s.ParseFlags(fs, args, help, "audit scan-store [-endpoint <storeserver address>]")
+	schedule := newScheduleFlags(fs, 24*time.Hour)
+	s.ParseFlags(fs, args, help, "audit scan-store [-endpoint <storeserver address>] [-continuous [-interval <duration>]]")
 
 	if fs.NArg() != 0 { // "audit scan-store help" is covered by this.
 		fs.Usage()
@@ -50,9 +54,17 @@ as only that user has permission to list references.
 		s.Exit(err)
 	}
 
+	s.repeat(*dataDir, schedule, func() {
+		s.scanStoreOnce(*dataDir, *endpoint)
+	})
+}
+
+// scanStoreOnce scans the store server at endpoint and writes the list
+// of its references to a new file in dataDir.
+func (s *State) scanStoreOnce(dataDir string, endpoint upspin.Endpoint) {
 	now := time.Now()
 
-	store, err := bind.StoreServer(s.Config, *endpoint)
+	store, err := bind.StoreServer(s.Config, endpoint)
 	if err != nil {
 		s.Fail(err)
 		return
@@ -87,6 +99,6 @@ as only that user has permission to list references.
 		}
 	}
 	fmt.Printf("%s: %d bytes total (%s) in %d references\n", endpoint.NetAddr, sum, ByteSize(sum), len(items))
-	file := filepath.Join(*dataDir, fmt.Sprintf("%s%s_%d", storeFilePrefix, endpoint.NetAddr, now.Unix()))
+	file := filepath.Join(dataDir, fmt.Sprintf("%s%s_%d", storeFilePrefix, endpoint.NetAddr, now.Unix()))
 	s.writeItems(file, items)
 }
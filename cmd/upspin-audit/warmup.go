@@ -0,0 +1,89 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"upspin.io/upspin"
+)
+
+func (s *State) generateWarmup(args []string) {
+	const help = `
+Audit generate-warmup scans the named trees and writes to standard output a
+list of the store blocks held by their files, one "endpoint reference" pair
+per line, suitable for the -warmup-file flag of cacheserver.
+
+Blocks of the most recently modified files come first. With -since, only
+files modified within that duration are listed; with -max, the list stops
+once the blocks listed add up to that many bytes.
+
+It should be run as a user that has read access to the named trees.
+`
+
+	fs := flag.NewFlagSet("generate-warmup", flag.ExitOnError)
+	glob := fs.Bool("glob", true, "apply glob processing to the arguments")
+	since := fs.Duration("since", 0, "list only files modified within this `duration`; 0 means all")
+	max := fs.Int64("max", 0, "stop after listing this many `bytes` of blocks; 0 means no limit")
+	s.ParseFlags(fs, args, help, "audit generate-warmup [-since <duration>] [-max <bytes>] path ...")
+
+	if fs.NArg() == 0 || fs.Arg(0) == "help" {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var paths []upspin.PathName
+	if *glob {
+		paths = s.GlobAllUpspinPath(fs.Args())
+	} else {
+		for _, p := range fs.Args() {
+			paths = append(paths, upspin.PathName(p))
+		}
+	}
+
+	var cutoff upspin.Time
+	if *since > 0 {
+		cutoff = upspin.TimeFromGo(time.Now().Add(-*since))
+	}
+
+	// Collect the files.
+	var files []*upspin.DirEntry
+	sc := s.startScan(paths)
+	for de := range sc.done {
+		if de.IsDir() || de.IsLink() || de.Time < cutoff {
+			continue
+		}
+		files = append(files, de)
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].Time > files[j].Time })
+
+	// List their blocks, each only once.
+	w := bufio.NewWriter(os.Stdout)
+	seen := make(map[upspin.Location]bool)
+	var total int64
+Files:
+	for _, de := range files {
+		for _, b := range de.Blocks {
+			loc := b.Location
+			if loc.Reference == upspin.ZeroReference || seen[loc] {
+				continue
+			}
+			if *max > 0 && total+b.Size > *max {
+				break Files
+			}
+			seen[loc] = true
+			total += b.Size
+			fmt.Fprintf(w, "%s %s\n", loc.Endpoint, loc.Reference)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		s.Exit(err)
+	}
+}
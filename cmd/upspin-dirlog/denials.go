@@ -0,0 +1,119 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"upspin.io/dir/server"
+	"upspin.io/upspin"
+)
+
+// denialSummary summarizes the denied requests of a user.
+type denialSummary struct {
+	user       upspin.UserName
+	permission int                 // Requests refused.
+	private    int                 // Requests whose information was withheld.
+	paths      map[string]struct{} // Paths, or their hashes, requested.
+	last       time.Time
+}
+
+func denials(args []string) {
+	fs := flag.NewFlagSet("denials", flag.ExitOnError)
+	since := fs.Duration("since", 0, "summarize only denials in the last `duration`, if set")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: upspin-dirlog denials [-since=duration] auditlog...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	var start time.Time
+	if *since > 0 {
+		start = time.Now().Add(-*since)
+	}
+
+	byUser := make(map[upspin.UserName]*denialSummary)
+	for _, file := range fs.Args() {
+		if err := readAuditLog(file, start, byUser); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	sums := make([]*denialSummary, 0, len(byUser))
+	for _, s := range byUser {
+		sums = append(sums, s)
+	}
+	// Most denied first.
+	sort.Slice(sums, func(i, j int) bool {
+		ni, nj := sums[i].permission+sums[i].private, sums[j].permission+sums[j].private
+		if ni != nj {
+			return ni > nj
+		}
+		return sums[i].user < sums[j].user
+	})
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "USER\tDENIED\tWITHHELD\tPATHS\tLAST")
+	for _, s := range sums {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s\n", s.user, s.permission, s.private, len(s.paths), s.last.Local().Format(time.RFC3339))
+	}
+	w.Flush()
+}
+
+// readAuditLog adds to byUser the denials recorded in the named audit
+// log at or after start.
+func readAuditLog(file string, start time.Time, byUser map[upspin.UserName]*denialSummary) error {
+	f, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r server.AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// Probably a partial line written during a crash.
+			continue
+		}
+		if r.Time.Before(start) {
+			continue
+		}
+		s := byUser[r.User]
+		if s == nil {
+			s = &denialSummary{user: r.User, paths: make(map[string]struct{})}
+			byUser[r.User] = s
+		}
+		// Each record stands for itself and those it suppressed.
+		n := 1 + r.Suppressed
+		if r.Kind == "private" {
+			s.private += n
+		} else {
+			s.permission += n
+		}
+		if r.Path != "" {
+			s.paths[string(r.Path)] = struct{}{}
+		} else {
+			s.paths[r.PathHash] = struct{}{}
+		}
+		if r.Time.After(s.last) {
+			s.last = r.Time
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %v", file, err)
+	}
+	return nil
+}
@@ -0,0 +1,318 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Upspin-dirlog reads the logs of a directory server, such as to stream
+// users' histories into a backup system, to check them against the
+// server's trees, or to summarize the requests the server denied.
+// It reads the logs without modifying them. See the
+// command's usage method for documentation.
+package main // import "upspin.io/cmd/upspin-dirlog"
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"upspin.io/config"
+	"upspin.io/dir/server"
+	"upspin.io/dir/server/serverlog"
+	"upspin.io/upspin"
+	"upspin.io/user"
+
+	// Load the packers and transports the directory server needs.
+	_ "upspin.io/pack/ee"
+	_ "upspin.io/pack/eeintegrity"
+	_ "upspin.io/pack/plain"
+	_ "upspin.io/transports"
+)
+
+const help = `Upspin-dirlog reads the logs of a directory server.
+
+The subcommands are:
+
+  dump
+	Print the saved root, the checkpoint and the log records up to
+	the checkpoint of each user, or of the user named by -user.
+	With -format=json, each is printed as a JSON object on its own
+	line: first an object holding the user's root and checkpoint,
+	then one for each record holding its offset, operation and
+	directory entry.
+
+  consistency-check
+	Replay the logs of each user, or of the user named by -user,
+	and compare the resulting tree with the one the directory
+	server holds, reporting any entry missing from either or whose
+	sequence numbers differ. It runs an instance of the directory
+	server, configured by -config and -serverconfig, on a private
+	copy of the logs; the server using them should be stopped.
+	Snapshot users are not checked. The exit status is 1 if any
+	differences are found.
+
+  denials
+	Summarize by user the denied requests recorded in the audit logs
+	named as arguments, as kept by a directory server given the
+	option auditLog=file. For each user it reports the number of
+	requests refused and of those whose information was withheld,
+	the number of distinct paths requested and the time of the last
+	denial.`
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("upspin-dirlog: ")
+	flag.Usage = usage
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		usage()
+	}
+	switch flag.Arg(0) {
+	case "dump":
+		dump(flag.Args()[1:])
+	case "consistency-check":
+		consistencyCheck(flag.Args()[1:])
+	case "denials":
+		denials(flag.Args()[1:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, help)
+	fmt.Fprintln(os.Stderr, "Usage of upspin-dirlog:")
+	fmt.Fprintln(os.Stderr, "\tupspin-dirlog <command> [flags] ...")
+	fmt.Fprintln(os.Stderr, "Commands: dump, consistency-check, denials")
+	os.Exit(2)
+}
+
+func dump(args []string) {
+	fs := flag.NewFlagSet("dump", flag.ExitOnError)
+	logDir := fs.String("logdir", filepath.Join(os.Getenv("HOME"), "upspin", "server", "dirserver-logs"), "directory server log `directory`")
+	user := fs.String("user", "", "dump only this `user`'s logs")
+	format := fs.String("format", "text", "output `format`: text or json")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: upspin-dirlog dump [-logdir=dir] [-user=name] [-format=text|json]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	var p printer
+	w := bufio.NewWriter(os.Stdout)
+	switch *format {
+	case "text":
+		p = &textPrinter{w: w}
+	case "json":
+		p = &jsonPrinter{enc: json.NewEncoder(w)}
+	default:
+		log.Fatalf("unknown format %q", *format)
+	}
+
+	users := []upspin.UserName{upspin.UserName(*user)}
+	if *user == "" {
+		var err error
+		users, err = serverlog.ListUsers(*logDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, u := range users {
+		h, err := serverlog.OpenUser(u, *logDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := p.user(h); err != nil {
+			log.Fatal(err)
+		}
+		c := h.NewCursor(0)
+		for c.Next() {
+			if err := p.record(h.User(), c.Record()); err != nil {
+				log.Fatal(err)
+			}
+		}
+		if err := c.Err(); err != nil {
+			log.Fatal(err)
+		}
+		c.Close()
+	}
+	if err := w.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func consistencyCheck(args []string) {
+	fs := flag.NewFlagSet("consistency-check", flag.ExitOnError)
+	logDir := fs.String("logdir", filepath.Join(os.Getenv("HOME"), "upspin", "server", "dirserver-logs"), "directory server log `directory`")
+	userName := fs.String("user", "", "check only this `user`'s tree")
+	configFile := fs.String("config", filepath.Join(os.Getenv("HOME"), "upspin", "server", "config"), "directory server configuration `file`")
+	serverConfig := fs.String("serverconfig", "", "comma-separated list of directory server `options`, as for dirserver")
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Usage: upspin-dirlog consistency-check [-logdir=dir] [-user=name] [-config=file] [-serverconfig=options]")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.FromFile(*configFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	users := []upspin.UserName{upspin.UserName(*userName)}
+	if *userName == "" {
+		users, err = serverlog.ListUsers(*logDir)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// The server may write to the logs it runs on, so give it a copy.
+	copyDir, err := os.MkdirTemp("", "upspin-dirlog")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(copyDir)
+	if err := copyFiles(copyDir, *logDir); err != nil {
+		log.Fatal(err)
+	}
+	opts := []string{"logDir=" + copyDir}
+	for _, opt := range strings.Split(*serverConfig, ",") {
+		if opt != "" && !strings.HasPrefix(opt, "logDir=") {
+			opts = append(opts, opt)
+		}
+	}
+	dir, err := server.New(cfg, opts...)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	differ := false
+	for _, u := range users {
+		if _, suffix, _, err := user.Parse(u); err != nil {
+			log.Fatal(err)
+		} else if suffix == "snapshot" {
+			continue
+		}
+		// Dialed as the user, the server shows the whole tree.
+		svc, err := dir.Dial(config.SetUserName(cfg, u), cfg.DirEndpoint())
+		if err != nil {
+			log.Fatal(err)
+		}
+		diffs, err := server.ConsistencyCheck(copyDir, u, svc.(upspin.DirServer))
+		if err != nil {
+			log.Fatal(err)
+		}
+		if len(diffs) == 0 {
+			fmt.Printf("%s: ok\n", u)
+			continue
+		}
+		differ = true
+		fmt.Printf("%s: %d differences\n", u, len(diffs))
+		for _, d := range diffs {
+			fmt.Printf("\t%s\n", d)
+		}
+	}
+	if differ {
+		os.RemoveAll(copyDir)
+		os.Exit(1)
+	}
+}
+
+// copyFiles copies the files in the directory src, and in its
+// subdirectories, to dst.
+func copyFiles(dst, src string) error {
+	return filepath.Walk(src, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, name)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+		if info.IsDir() {
+			return os.MkdirAll(target, 0700)
+		}
+		in, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer in.Close()
+		out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(out, in); err != nil {
+			out.Close()
+			return err
+		}
+		return out.Close()
+	})
+}
+
+// printer prints the contents of logs.
+type printer interface {
+	user(h *serverlog.History) error
+	record(user upspin.UserName, r serverlog.Record) error
+}
+
+type textPrinter struct {
+	w io.Writer
+}
+
+func (p *textPrinter) user(h *serverlog.History) error {
+	root := "none"
+	if r := h.Root(); r != nil {
+		root = fmt.Sprintf("sequence %d", r.Sequence)
+	}
+	_, err := fmt.Fprintf(p.w, "%s: checkpoint %d, root %s\n", h.User(), h.Checkpoint(), root)
+	return err
+}
+
+func (p *textPrinter) record(user upspin.UserName, r serverlog.Record) error {
+	_, err := fmt.Fprintf(p.w, "%d\t%s\t%s\tseq %d\n", r.Offset, opName(r.Op), r.Entry.Name, r.Entry.Sequence)
+	return err
+}
+
+type jsonPrinter struct {
+	enc *json.Encoder
+}
+
+func (p *jsonPrinter) user(h *serverlog.History) error {
+	return p.enc.Encode(struct {
+		User       upspin.UserName
+		Checkpoint int64
+		Root       *upspin.DirEntry
+	}{h.User(), h.Checkpoint(), h.Root()})
+}
+
+func (p *jsonPrinter) record(user upspin.UserName, r serverlog.Record) error {
+	return p.enc.Encode(struct {
+		User   upspin.UserName
+		Offset int64
+		Op     string
+		Entry  *upspin.DirEntry
+	}{user, r.Offset, opName(r.Op), r.Entry})
+}
+
+func opName(op serverlog.Operation) string {
+	switch op {
+	case serverlog.Put:
+		return "put"
+	case serverlog.Delete:
+		return "delete"
+	}
+	return fmt.Sprintf("op%d", op)
+}
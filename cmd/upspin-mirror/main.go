@@ -0,0 +1,130 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Upspin-mirror keeps a copy of an Upspin directory in a local directory,
+// or of a local directory in Upspin, up to date. It may be used, for
+// instance, to publish a web site kept in Upspin to a web server's
+// document root.
+//
+// Usage:
+//
+//	upspin-mirror [flags] down <upspin directory> <local directory>
+//	upspin-mirror [flags] up <local directory> <upspin directory>
+//
+// The down direction copies from Upspin to the local directory. After
+// making the local directory match the Upspin one it watches the Upspin
+// directory for changes and applies them as they happen. If the watch is
+// interrupted it resumes from the last event seen, or makes the two match
+// again if that is not possible. Local changes are overwritten: the
+// Upspin directory always wins.
+//
+// The up direction copies from the local directory to Upspin. After the
+// initial copy it checks the local directory for changes every -poll
+// interval and applies them to Upspin. The local directory always wins.
+//
+// In both directions, files that are removed from the source are removed
+// from the destination. Links, in Upspin, and symbolic links, locally,
+// are copied if their targets are within the mirrored tree, with targets
+// adjusted to the destination tree; other links are ignored, as are
+// files the user cannot read. Files are given the modification time of
+// their source, which is used, with their size, to tell whether a file
+// needs copying.
+//
+// Access files are not copied in either direction, and are not removed
+// from the destination, unless the -access flag is set. This keeps a
+// mirror from revealing who may read the Upspin tree or from changing
+// who may.
+package main // import "upspin.io/cmd/upspin-mirror"
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"upspin.io/client"
+	"upspin.io/config"
+	"upspin.io/flags"
+	"upspin.io/log"
+	"upspin.io/path"
+	"upspin.io/transports"
+	"upspin.io/upspin"
+	"upspin.io/version"
+
+	_ "upspin.io/pack/ee"
+	_ "upspin.io/pack/eeintegrity"
+	_ "upspin.io/pack/plain"
+)
+
+const cmdName = "upspin-mirror"
+
+var (
+	accessFlag = flag.Bool("access", false, "copy and remove Access files")
+	once       = flag.Bool("once", false, "make the destination match the source once, then exit")
+	poll       = flag.Duration("poll", 10*time.Second, "for the up direction, how often to check the local directory for changes")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] down <upspin directory> <local directory>\n", cmdName)
+	fmt.Fprintf(os.Stderr, "       %s [flags] up <local directory> <upspin directory>\n", cmdName)
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flags.Parse(flags.Client, "version")
+	if flags.Version {
+		fmt.Print(version.Version())
+		return
+	}
+	if flag.NArg() != 3 {
+		usage()
+		os.Exit(2)
+	}
+	dir, src, dst := flag.Arg(0), flag.Arg(1), flag.Arg(2)
+	if dir == "up" {
+		src, dst = dst, src
+	} else if dir != "down" {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.FromFile(flags.Config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := config.SetFlagValues(cfg, cmdName); err != nil {
+		log.Fatalf("%s: %s", cmdName, err)
+	}
+	transports.Init(cfg)
+
+	// Here src is the Upspin directory and dst the local one.
+	p, err := path.Parse(upspin.PathName(src))
+	if err != nil {
+		log.Fatalf("%s: %s", cmdName, err)
+	}
+	local, err := filepath.Abs(dst)
+	if err != nil {
+		log.Fatalf("%s: %s", cmdName, err)
+	}
+	m := newMirror(client.New(cfg), p.Path(), local, *accessFlag)
+
+	switch {
+	case dir == "down" && *once:
+		err = m.syncDown()
+	case dir == "down":
+		err = m.runDown(nil)
+	case *once:
+		var remote map[string]item
+		if remote, err = m.remoteTree(); err == nil {
+			_, err = m.syncUp(remote)
+		}
+	default:
+		err = m.runUp(*poll, nil)
+	}
+	if err != nil {
+		log.Fatalf("%s: %s", cmdName, err)
+	}
+}
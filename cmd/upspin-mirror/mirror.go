@@ -0,0 +1,474 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"upspin.io/access"
+	"upspin.io/client"
+	"upspin.io/errors"
+	"upspin.io/log"
+	"upspin.io/upspin"
+)
+
+// retryDelay is how long to wait before trying to watch again after
+// a watch fails.
+var retryDelay = 5 * time.Second
+
+// mirror copies between an Upspin tree and a local one.
+type mirror struct {
+	cli    upspin.Client
+	remote upspin.PathName // Root of the Upspin tree.
+	prefix string          // The root as a prefix of the names within it.
+	local  string          // Root of the local tree.
+	access bool            // Whether to copy Access files.
+
+	// watch is the Watch method of the DirServer for the Upspin tree.
+	// Tests replace it.
+	watch func(upspin.PathName, int64, <-chan struct{}) (<-chan upspin.Event, error)
+}
+
+func newMirror(cli upspin.Client, remote upspin.PathName, local string, access bool) *mirror {
+	prefix := string(remote)
+	if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return &mirror{
+		cli:    cli,
+		remote: remote,
+		prefix: prefix,
+		local:  local,
+		access: access,
+	}
+}
+
+type itemKind int
+
+const (
+	fileItem itemKind = iota
+	dirItem
+	linkItem
+	otherItem // Present, but not to be copied.
+)
+
+// item describes a file, directory or link in either tree. Items are
+// identified by their slash-separated names relative to the root.
+type item struct {
+	kind  itemKind
+	size  int64       // For files.
+	mtime upspin.Time // For files.
+	link  string      // For links, the name of the target.
+}
+
+func (i item) equal(j item) bool {
+	switch {
+	case i.kind != j.kind:
+		return false
+	case i.kind == fileItem:
+		return i.size == j.size && i.mtime == j.mtime
+	case i.kind == linkItem:
+		return i.link == j.link
+	}
+	return true
+}
+
+// diff returns the names of the items to remove from, and then to put
+// into, a tree holding old to make it hold new. Items to remove are in
+// reverse order, so a directory's contents precede it, and those to put
+// in order, so a directory precedes its contents.
+// Where new holds an item that is not to be copied, old is left alone.
+func diff(old, new map[string]item) (remove, put []string) {
+	for name, o := range old {
+		if n, ok := new[name]; !ok || n.kind != o.kind && n.kind != otherItem {
+			remove = append(remove, name)
+		}
+	}
+	for name, n := range new {
+		if n.kind == otherItem {
+			continue
+		}
+		if o, ok := old[name]; !ok || !o.equal(n) {
+			put = append(put, name)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(remove)))
+	sort.Strings(put)
+	return remove, put
+}
+
+// skip reports whether the named item is not to be copied.
+func (m *mirror) skip(name string) bool {
+	return !m.access && access.IsAccessFile(upspin.PathName(m.prefix+name))
+}
+
+// relName returns the name relative to the root of the Upspin tree,
+// and whether the name is within it.
+func (m *mirror) relName(name upspin.PathName) (string, bool) {
+	if !strings.HasPrefix(string(name), m.prefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(string(name), m.prefix), true
+}
+
+func (m *mirror) upspinName(name string) upspin.PathName {
+	return upspin.PathName(m.prefix + name)
+}
+
+func (m *mirror) localName(name string) string {
+	return filepath.Join(m.local, filepath.FromSlash(name))
+}
+
+// remoteItem returns the item described by the entry.
+func (m *mirror) remoteItem(entry *upspin.DirEntry) item {
+	switch {
+	case entry.IsDir():
+		return item{kind: dirItem}
+	case entry.IsLink():
+		target, ok := m.relName(entry.Link)
+		if !ok || target == "" {
+			return item{kind: otherItem}
+		}
+		return item{kind: linkItem, link: target}
+	case entry.IsIncomplete():
+		return item{kind: otherItem}
+	}
+	size, err := entry.Size()
+	if err != nil {
+		return item{kind: otherItem}
+	}
+	return item{kind: fileItem, size: size, mtime: entry.Time}
+}
+
+// remoteTree returns the items in the Upspin tree.
+func (m *mirror) remoteTree() (map[string]item, error) {
+	tree := make(map[string]item)
+	var walk func(dir upspin.PathName) error
+	walk = func(dir upspin.PathName) error {
+		entries, err := m.cli.Glob(upspin.AllFilesGlob(dir))
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			name, ok := m.relName(e.Name)
+			if !ok || m.skip(name) {
+				continue
+			}
+			it := m.remoteItem(e)
+			tree[name] = it
+			if it.kind == dirItem {
+				if err := walk(e.Name); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return tree, walk(m.remote)
+}
+
+// localItem returns the item described by the file information.
+func (m *mirror) localItem(file string, info os.FileInfo) item {
+	switch {
+	case info.IsDir():
+		return item{kind: dirItem}
+	case info.Mode()&os.ModeSymlink != 0:
+		target, err := os.Readlink(file)
+		if err != nil {
+			return item{kind: otherItem}
+		}
+		if !filepath.IsAbs(target) {
+			target = filepath.Join(filepath.Dir(file), target)
+		}
+		rel, err := filepath.Rel(m.local, target)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return item{kind: otherItem}
+		}
+		return item{kind: linkItem, link: filepath.ToSlash(rel)}
+	case info.Mode().IsRegular():
+		return item{kind: fileItem, size: info.Size(), mtime: upspin.TimeFromGo(info.ModTime())}
+	}
+	return item{kind: otherItem}
+}
+
+// localTree returns the items in the local tree.
+func (m *mirror) localTree() (map[string]item, error) {
+	tree := make(map[string]item)
+	err := filepath.Walk(m.local, func(file string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if file == m.local {
+			return nil
+		}
+		rel, err := filepath.Rel(m.local, file)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(rel)
+		if m.skip(name) {
+			return nil
+		}
+		tree[name] = m.localItem(file, info)
+		return nil
+	})
+	return tree, err
+}
+
+// syncDown makes the local tree match the Upspin tree.
+func (m *mirror) syncDown() error {
+	remote, err := m.remoteTree()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.local, 0755); err != nil {
+		return err
+	}
+	local, err := m.localTree()
+	if err != nil {
+		return err
+	}
+	remove, put := diff(local, remote)
+	for _, name := range remove {
+		if err := os.RemoveAll(m.localName(name)); err != nil {
+			return err
+		}
+	}
+	for _, name := range put {
+		if err := m.putLocal(name, remote[name]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putLocal stores the item, copied from the Upspin tree, in the local tree.
+func (m *mirror) putLocal(name string, it item) error {
+	file := m.localName(name)
+	if info, err := os.Lstat(file); err == nil {
+		old := m.localItem(file, info)
+		if old.equal(it) {
+			return nil
+		}
+		if old.kind != it.kind || it.kind == linkItem {
+			if err := os.RemoveAll(file); err != nil {
+				return err
+			}
+		}
+	}
+	if it.kind == dirItem {
+		return os.MkdirAll(file, 0755)
+	}
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	if it.kind == linkItem {
+		target, err := filepath.Rel(dir, m.localName(it.link))
+		if err != nil {
+			return err
+		}
+		return os.Symlink(target, file)
+	}
+
+	src, err := m.cli.Open(m.upspinName(name))
+	if errors.Is(errors.NotExist, err) {
+		// Removed since we looked; an event will say so.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	// Write a temporary file and rename it, so that the file is
+	// replaced all at once.
+	f, err := os.CreateTemp(dir, ".upspin-mirror-")
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, src)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(f.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Chtimes(f.Name(), time.Now(), it.mtime.Go())
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), file)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// applyEvent applies a change to the Upspin tree to the local tree.
+func (m *mirror) applyEvent(e *upspin.Event) error {
+	name, ok := m.relName(e.Entry.Name)
+	if !ok || name == "" || m.skip(name) {
+		return nil
+	}
+	if e.Delete {
+		return os.RemoveAll(m.localName(name))
+	}
+	it := m.remoteItem(e.Entry)
+	if it.kind == otherItem {
+		return nil
+	}
+	return m.putLocal(name, it)
+}
+
+// runDown makes the local tree match the Upspin tree and then applies
+// changes to the Upspin tree as they happen, until done is closed.
+func (m *mirror) runDown(done <-chan struct{}) error {
+	watch := m.watch
+	if watch == nil {
+		dir, err := m.cli.DirServer(m.remote)
+		if err != nil {
+			return err
+		}
+		watch = dir.Watch
+	}
+	sequence := int64(upspin.WatchNew)
+	for {
+		// To be sure no change is missed, the watch starts before
+		// the trees are compared.
+		events, err := watch(m.remote, sequence, done)
+		if err == upspin.ErrNotSupported {
+			return err
+		}
+		if err == nil && sequence == upspin.WatchNew {
+			err = m.syncDown()
+		}
+		if err != nil {
+			log.Error.Printf("%s: %v", cmdName, err)
+		}
+		for events != nil {
+			var e upspin.Event
+			var ok bool
+			select {
+			case <-done:
+				return nil
+			case e, ok = <-events:
+			}
+			if !ok {
+				break
+			}
+			if e.Error != nil {
+				log.Error.Printf("%s: watch: %v", cmdName, e.Error)
+				if errors.Is(errors.Invalid, e.Error) {
+					// The sequence is unknown; start again.
+					sequence = upspin.WatchNew
+				}
+				continue
+			}
+			if err := m.applyEvent(&e); err != nil {
+				log.Error.Printf("%s: %v", cmdName, err)
+			}
+			if e.Entry.Sequence >= sequence {
+				sequence = e.Entry.Sequence + 1
+			}
+		}
+		select {
+		case <-done:
+			return nil
+		case <-time.After(retryDelay):
+		}
+		log.Info.Printf("%s: watching again from sequence %d", cmdName, sequence)
+	}
+}
+
+// syncUp makes the Upspin tree, which holds remote, match the local
+// tree. It returns the items in the local tree.
+func (m *mirror) syncUp(remote map[string]item) (map[string]item, error) {
+	local, err := m.localTree()
+	if err != nil {
+		return nil, err
+	}
+	remove, put := diff(remote, local)
+	for _, name := range remove {
+		err := m.cli.Delete(m.upspinName(name))
+		if err != nil && !errors.Is(errors.NotExist, err) {
+			return nil, err
+		}
+	}
+	for _, name := range put {
+		if err := m.putRemote(name, local[name]); err != nil {
+			return nil, err
+		}
+	}
+	return local, nil
+}
+
+// putRemote stores the item, copied from the local tree, in Upspin.
+func (m *mirror) putRemote(name string, it item) error {
+	uname := m.upspinName(name)
+	switch it.kind {
+	case dirItem:
+		_, err := m.cli.MakeDirectory(uname)
+		if errors.Is(errors.Exist, err) {
+			return nil
+		}
+		return err
+	case linkItem:
+		// A link replaces whatever was there.
+		if err := m.cli.Delete(uname); err != nil && !errors.Is(errors.NotExist, err) {
+			return err
+		}
+		_, err := m.cli.PutLink(m.upspinName(it.link), uname)
+		return err
+	}
+	p, ok := m.cli.(client.StreamPutter)
+	if !ok {
+		return upspin.ErrNotSupported
+	}
+	f, err := os.Open(m.localName(name))
+	if os.IsNotExist(err) {
+		// Removed since we looked; the next pass will see it.
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	_, err = p.PutStream(uname, f, client.PutOptions{})
+	f.Close()
+	if err != nil {
+		return err
+	}
+	return m.cli.SetTime(uname, it.mtime)
+}
+
+// runUp makes the Upspin tree match the local tree, checking the local
+// tree for changes at the given interval, until done is closed.
+func (m *mirror) runUp(interval time.Duration, done <-chan struct{}) error {
+	var prev map[string]item
+	for {
+		var err error
+		if prev == nil {
+			prev, err = m.remoteTree()
+		}
+		if err == nil {
+			prev, err = m.syncUp(prev)
+		}
+		if err != nil {
+			// Compare with the Upspin tree next time.
+			log.Error.Printf("%s: %v", cmdName, err)
+			prev = nil
+		}
+		select {
+		case <-done:
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,344 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"upspin.io/test/testenv"
+	"upspin.io/upspin"
+)
+
+const (
+	owner = "aly@example.com"
+	root  = owner + "/site"
+)
+
+func setup(t *testing.T) (*testenv.Env, *testenv.Runner, string) {
+	// The dir/server implementation, run in this process, has the
+	// Watch sequence numbers of a deployed server.
+	env, err := testenv.New(&testenv.Setup{
+		OwnerName: owner,
+		Packing:   upspin.EEPack,
+		Kind:      "server",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := testenv.NewRunner()
+	r.AddUser(env.Config)
+	r.As(owner)
+	r.MakeDirectory(root)
+	r.MakeDirectory(root + "/dir")
+	r.Put(root+"/index.html", "<h1>hello</h1>")
+	r.Put(root+"/dir/page.html", "a page")
+	r.Put(root+"/Access", "r: all\n*: "+owner+"\n")
+	r.PutLink(root+"/dir/page.html", root+"/link")
+	r.Put(owner+"/outside", "outside")
+	r.PutLink(owner+"/outside", root+"/outlink")
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+	dir, err := os.MkdirTemp("", "upspin-mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return env, r, dir
+}
+
+// localState describes the local tree as sorted lines of the form
+// "name kind[:detail]", where detail is a file's contents or a
+// symbolic link's target.
+func localState(t *testing.T, dir string) string {
+	var lines []string
+	err := filepath.Walk(dir, func(file string, info os.FileInfo, err error) error {
+		if err != nil || file == dir {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, file)
+		switch {
+		case info.IsDir():
+			lines = append(lines, rel+" dir")
+		case info.Mode()&os.ModeSymlink != 0:
+			target, _ := os.Readlink(file)
+			lines = append(lines, rel+" link:"+target)
+		default:
+			data, _ := os.ReadFile(file)
+			lines = append(lines, rel+" file:"+string(data))
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// remoteState describes the Upspin tree like localState.
+func remoteState(t *testing.T, env *testenv.Env, dir upspin.PathName) string {
+	var lines []string
+	var walk func(dir upspin.PathName)
+	walk = func(dir upspin.PathName) {
+		entries, err := env.Client.Glob(upspin.AllFilesGlob(dir))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range entries {
+			rel := strings.TrimPrefix(string(e.Name), root+"/")
+			switch {
+			case e.IsDir():
+				lines = append(lines, rel+" dir")
+				walk(e.Name)
+			case e.IsLink():
+				lines = append(lines, rel+" link:"+string(e.Link))
+			default:
+				data, err := env.Client.Get(e.Name)
+				if err != nil {
+					t.Fatal(err)
+				}
+				lines = append(lines, rel+" file:"+string(data))
+			}
+		}
+	}
+	walk(dir)
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
+
+// waitFor waits for the local tree to reach the wanted state.
+func waitFor(t *testing.T, dir, want string) {
+	t.Helper()
+	var got string
+	for i := 0; i < 100; i++ {
+		if got = localState(t, dir); got == want {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	t.Fatalf("local tree:\n%s\nwant:\n%s", got, want)
+}
+
+func lines(l ...string) string {
+	return strings.Join(l, "\n")
+}
+
+func TestDown(t *testing.T) {
+	env, r, dir := setup(t)
+	defer env.Exit()
+	defer os.RemoveAll(dir)
+
+	// Local changes are overwritten or removed, except Access files.
+	local := filepath.Join(dir, "site")
+	os.MkdirAll(filepath.Join(local, "old"), 0755)
+	os.WriteFile(filepath.Join(local, "old", "stale"), []byte("stale"), 0644)
+	os.WriteFile(filepath.Join(local, "index.html"), []byte("changed"), 0644)
+	os.WriteFile(filepath.Join(local, "Access"), []byte("local"), 0644)
+
+	m := newMirror(env.Client, root, local, false)
+	if err := m.syncDown(); err != nil {
+		t.Fatal(err)
+	}
+	want := lines(
+		"Access file:local",
+		"dir dir",
+		"dir/page.html file:a page",
+		"index.html file:<h1>hello</h1>",
+		"link link:dir/page.html",
+	)
+	if got := localState(t, local); got != want {
+		t.Fatalf("after sync:\n%s\nwant:\n%s", got, want)
+	}
+	info, err := os.Stat(filepath.Join(local, "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := env.Client.Lookup(root+"/index.html", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !info.ModTime().Equal(e.Time.Go()) {
+		t.Errorf("modification time %v, want %v", info.ModTime(), e.Time.Go())
+	}
+
+	// Watch, dropping the first watch after one event.
+	defer func(d time.Duration) { retryDelay = d }(retryDelay)
+	retryDelay = 10 * time.Millisecond
+	dirServer, err := env.Client.DirServer(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each watch reports when it has started.
+	watching := make(chan int64, 10)
+	calls := 0
+	m.watch = func(name upspin.PathName, seq int64, done <-chan struct{}) (<-chan upspin.Event, error) {
+		calls++
+		events, err := dirServer.Watch(name, seq, done)
+		defer func() { watching <- seq }()
+		if err != nil || calls > 1 {
+			return events, err
+		}
+		dropped := make(chan upspin.Event)
+		go func() {
+			dropped <- <-events
+			close(dropped)
+		}()
+		return dropped, nil
+	}
+	wait := func() int64 {
+		t.Helper()
+		select {
+		case seq := <-watching:
+			return seq
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for watch")
+		}
+		return 0
+	}
+	done := make(chan struct{})
+	finished := make(chan error)
+	go func() {
+		finished <- m.runDown(done)
+	}()
+	if seq := wait(); seq != upspin.WatchNew {
+		t.Fatalf("first watch from sequence %d, want %d", seq, upspin.WatchNew)
+	}
+	// Wait for the first sync, which replaces nothing.
+	waitFor(t, local, want)
+
+	// The first watch is dropped after this event.
+	r.Put(root+"/dir/new.html", "new")
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+	if seq := wait(); seq < 0 {
+		t.Fatalf("second watch from sequence %d; want a resumed sequence", seq)
+	}
+	r.Put(root+"/index.html", "<h1>goodbye</h1>")
+	r.Delete(root + "/link")
+	r.MakeDirectory(root + "/sub")
+	r.PutLink(root+"/index.html", root+"/sub/index")
+	r.Put(root+"/sub/Access", "r: all\n")
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+	waitFor(t, local, lines(
+		"Access file:local",
+		"dir dir",
+		"dir/new.html file:new",
+		"dir/page.html file:a page",
+		"index.html file:<h1>goodbye</h1>",
+		"sub dir",
+		"sub/index link:../index.html",
+	))
+	close(done)
+	if err := <-finished; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUp(t *testing.T) {
+	env, r, dir := setup(t)
+	defer env.Exit()
+	defer os.RemoveAll(dir)
+
+	write := func(name, data string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	os.MkdirAll(filepath.Join(dir, "dir", "deep"), 0755)
+	write("index.html", "<h1>local copy</h1>")
+	write("dir/deep/file", "deep")
+	write("Access", "w: all\n")
+	os.Symlink("dir/deep/file", filepath.Join(dir, "link"))
+	os.Symlink("/etc/passwd", filepath.Join(dir, "outlink"))
+
+	m := newMirror(env.Client, root, dir, false)
+	remote, err := m.remoteTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	prev, err := m.syncUp(remote)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessFile := "Access file:r: all\n*: " + owner + "\n"
+	want := lines(
+		accessFile,
+		"dir dir",
+		"dir/deep dir",
+		"dir/deep/file file:deep",
+		"index.html file:<h1>local copy</h1>",
+		"link link:"+root+"/dir/deep/file",
+		"outlink link:"+owner+"/outside",
+	)
+	if got := remoteState(t, env, root); got != want {
+		t.Fatalf("after sync:\n%s\nwant:\n%s", got, want)
+	}
+	e, err := env.Client.Lookup(root+"/index.html", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(filepath.Join(dir, "index.html")); err != nil || upspin.TimeFromGo(info.ModTime()) != e.Time {
+		t.Errorf("time of index.html = %v, want modification time", e.Time)
+	}
+
+	// Incremental changes.
+	write("index.html", "<h1>changed</h1>")
+	write("new", "new")
+	os.RemoveAll(filepath.Join(dir, "dir"))
+	os.Remove(filepath.Join(dir, "link"))
+	os.Mkdir(filepath.Join(dir, "link"), 0755)
+	if _, err := m.syncUp(prev); err != nil {
+		t.Fatal(err)
+	}
+	want = lines(
+		accessFile,
+		"index.html file:<h1>changed</h1>",
+		"link dir",
+		"new file:new",
+		"outlink link:"+owner+"/outside",
+	)
+	if got := remoteState(t, env, root); got != want {
+		t.Fatalf("after changes:\n%s\nwant:\n%s", got, want)
+	}
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+}
+
+func TestDiff(t *testing.T) {
+	old := map[string]item{
+		"a":     {kind: fileItem, size: 1, mtime: 1},
+		"b":     {kind: dirItem},
+		"b/c":   {kind: fileItem, size: 1, mtime: 1},
+		"d":     {kind: linkItem, link: "a"},
+		"e":     {kind: fileItem, size: 1, mtime: 1},
+		"f/g/h": {kind: dirItem},
+		"x":     {kind: fileItem, size: 1, mtime: 1},
+		"y":     {kind: otherItem},
+	}
+	new := map[string]item{
+		"a": {kind: fileItem, size: 1, mtime: 2},
+		"b": {kind: fileItem, size: 1, mtime: 1},
+		"d": {kind: linkItem, link: "b"},
+		"e": {kind: fileItem, size: 1, mtime: 1},
+		"x": {kind: otherItem},
+	}
+	remove, put := diff(old, new)
+	if got, want := fmt.Sprint(remove), "[y f/g/h b/c b]"; got != want {
+		t.Errorf("remove = %s, want %s", got, want)
+	}
+	if got, want := fmt.Sprint(put), "[a b d]"; got != want {
+		t.Errorf("put = %s, want %s", got, want)
+	}
+}
@@ -0,0 +1,336 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// This file implements the setup of storage in Amazon S3 or a service
+// with a compatible API, such as MinIO. It speaks just enough of the S3
+// REST API, with requests signed by AWS Signature Version 4, to create a
+// bucket and check that it can be written.
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"upspin.io/errors"
+)
+
+// probeObject is the name of the object written and deleted to check
+// that the bucket is usable.
+const probeObject = "upspin-setupstorage-probe"
+
+// s3Options describes the storage to set up.
+type s3Options struct {
+	bucket   string
+	region   string
+	endpoint string // URL of an S3-compatible service; empty for Amazon S3.
+	profile  string // Profile in the shared credentials file; empty for the environment.
+	create   bool   // Create the bucket and block public access to it.
+}
+
+// setupS3 prepares the bucket described by opts, checks that it can be
+// written with the given credentials, and returns the StoreConfig options
+// for the S3 storage backend.
+func setupS3(c *s3Client, opts s3Options) ([]string, error) {
+	if opts.create {
+		if err := c.createBucket(opts.bucket); err != nil {
+			return nil, err
+		}
+		if err := c.blockPublicAccess(opts.bucket); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.do("PUT", opts.bucket, probeObject, "", []byte("probe")); err != nil {
+		return nil, errors.Errorf("writing probe object: %v", err)
+	}
+	if err := c.do("DELETE", opts.bucket, probeObject, "", nil); err != nil {
+		return nil, errors.Errorf("deleting probe object: %v", err)
+	}
+
+	// The credentials themselves are never written to the config;
+	// the server finds them where this command did.
+	cfg := []string{
+		"backend=S3",
+		"s3BucketName=" + opts.bucket,
+		"s3Region=" + opts.region,
+	}
+	if opts.endpoint != "" {
+		cfg = append(cfg, "s3Endpoint="+opts.endpoint)
+	}
+	if opts.profile != "" {
+		cfg = append(cfg, "s3Profile="+opts.profile)
+	}
+	return cfg, nil
+}
+
+// s3Credentials holds the keys with which S3 requests are signed.
+type s3Credentials struct {
+	accessKey    string
+	secretKey    string
+	sessionToken string // Optional.
+}
+
+// loadS3Credentials returns the credentials of the named profile in the
+// shared AWS credentials file, or if profile is empty, those in the
+// standard AWS environment variables.
+func loadS3Credentials(profile string) (s3Credentials, error) {
+	if profile == "" {
+		c := s3Credentials{
+			accessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+			secretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		}
+		if c.accessKey == "" || c.secretKey == "" {
+			return c, errors.Str("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set, or use -profile")
+		}
+		return c, nil
+	}
+	file := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if file == "" {
+		file = filepath.Join(os.Getenv("HOME"), ".aws", "credentials")
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		return s3Credentials{}, err
+	}
+	defer f.Close()
+	return parseS3Credentials(f, profile)
+}
+
+// parseS3Credentials returns the credentials of the profile in r, which
+// holds a credentials file in the INI format used by the AWS tools.
+func parseS3Credentials(r io.Reader, profile string) (s3Credentials, error) {
+	var c s3Credentials
+	found := false
+	section := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && line[len(line)-1] == ']' {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			found = found || section == profile
+			continue
+		}
+		if section != profile {
+			continue
+		}
+		eq := strings.IndexByte(line, '=')
+		if eq < 0 {
+			continue
+		}
+		val := strings.TrimSpace(line[eq+1:])
+		switch strings.TrimSpace(line[:eq]) {
+		case "aws_access_key_id":
+			c.accessKey = val
+		case "aws_secret_access_key":
+			c.secretKey = val
+		case "aws_session_token":
+			c.sessionToken = val
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return c, err
+	}
+	if !found {
+		return c, errors.Errorf("no profile %q in credentials file", profile)
+	}
+	if c.accessKey == "" || c.secretKey == "" {
+		return c, errors.Errorf("profile %q has no aws_access_key_id or aws_secret_access_key", profile)
+	}
+	return c, nil
+}
+
+// s3Client makes requests of an S3 service. Buckets are addressed by
+// path, which both Amazon S3 and compatible services accept.
+type s3Client struct {
+	endpoint string // Such as https://s3.us-east-1.amazonaws.com.
+	region   string
+	creds    s3Credentials
+	client   *http.Client
+	now      func() time.Time
+}
+
+func newS3Client(opts s3Options, creds s3Credentials) *s3Client {
+	endpoint := opts.endpoint
+	if endpoint == "" {
+		endpoint = "https://s3." + opts.region + ".amazonaws.com"
+	}
+	return &s3Client{
+		endpoint: strings.TrimSuffix(endpoint, "/"),
+		region:   opts.region,
+		creds:    creds,
+		client:   &http.Client{Timeout: 30 * time.Second},
+		now:      time.Now,
+	}
+}
+
+// createBucket creates the bucket. It is not an error if the bucket
+// already exists and belongs to the caller.
+func (c *s3Client) createBucket(bucket string) error {
+	var body []byte
+	// us-east-1 is the default region and may not be given explicitly.
+	if c.region != "us-east-1" {
+		body = []byte(`<CreateBucketConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><LocationConstraint>` +
+			c.region + `</LocationConstraint></CreateBucketConfiguration>`)
+	}
+	err := c.do("PUT", bucket, "", "", body)
+	if e, ok := err.(*s3Error); ok && e.Code == "BucketAlreadyOwnedByYou" {
+		return nil
+	}
+	return err
+}
+
+// blockPublicAccess prevents the objects in the bucket from being made
+// public; all access to Upspin storage goes through the server.
+func (c *s3Client) blockPublicAccess(bucket string) error {
+	const config = `<PublicAccessBlockConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/">` +
+		`<BlockPublicAcls>true</BlockPublicAcls>` +
+		`<IgnorePublicAcls>true</IgnorePublicAcls>` +
+		`<BlockPublicPolicy>true</BlockPublicPolicy>` +
+		`<RestrictPublicBuckets>true</RestrictPublicBuckets>` +
+		`</PublicAccessBlockConfiguration>`
+	return c.do("PUT", bucket, "", "publicAccessBlock", []byte(config))
+}
+
+// s3Error is an error response from an S3 service.
+type s3Error struct {
+	Status  string `xml:"-"`
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+func (e *s3Error) Error() string {
+	if e.Code == "" {
+		return e.Status
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// do makes a signed request for the object in the bucket, or the bucket
+// itself if object is empty, with the given sub-resource query.
+func (c *s3Client) do(method, bucket, object, query string, body []byte) error {
+	u := c.endpoint + "/" + url.PathEscape(bucket)
+	if object != "" {
+		u += "/" + url.PathEscape(object)
+	}
+	if query != "" {
+		u += "?" + query + "="
+	}
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	if c.creds.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", c.creds.sessionToken)
+	}
+	c.sign(req, body)
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+	e := &s3Error{Status: resp.Status}
+	if b, err := io.ReadAll(resp.Body); err == nil {
+		xml.Unmarshal(b, e)
+	}
+	return e
+}
+
+// sign adds to req the headers of AWS Signature Version 4, signing the
+// host and all headers already set.
+func (c *s3Client) sign(req *http.Request, body []byte) {
+	const (
+		algorithm = "AWS4-HMAC-SHA256"
+		service   = "s3"
+	)
+	now := c.now().UTC()
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", now.Format("20060102T150405Z"))
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", k, headers[k])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	query := req.URL.Query()
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var canonicalQuery []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			canonicalQuery = append(canonicalQuery, url.QueryEscape(k)+"="+url.QueryEscape(v))
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		strings.Join(canonicalQuery, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + c.region + "/" + service + "/aws4_request"
+	stringToSign := strings.Join([]string{
+		algorithm,
+		req.Header.Get("X-Amz-Date"),
+		scope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+c.creds.secretKey), date)
+	key = hmacSHA256(key, c.region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		algorithm, c.creds.accessKey, scope, signedHeaders, signature))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
@@ -13,6 +13,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"upspin.io/subcmd"
 )
@@ -26,10 +27,25 @@ Setupstorage is the second step in establishing an upspinserver,
 It sets up storage for your Upspin installation.
 The first step is 'setupdomain' and the final step is 'setupserver'.
 
-This version of setupstorage configures local disk storage.
+By default setupstorage configures local disk storage, kept in the
+directory given by the -path flag.
+
+With -backend=S3, it configures storage in the Amazon S3 bucket named
+by the -bucket flag, or with the -endpoint flag, in a bucket of a service
+with a compatible API, such as MinIO. The credentials used are those in
+the environment variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and
+AWS_SESSION_TOKEN, or if the -profile flag is set, those of the named
+profile in the shared credentials file ($HOME/.aws/credentials, or that
+named by $AWS_SHARED_CREDENTIALS_FILE). The upspinserver must be able to
+find the same credentials, and must include the S3 storage backend, as
+does upspinserver-aws from aws.upspin.io. The -create flag creates the
+bucket and blocks public access to its contents. Setupstorage checks
+that the bucket is usable by writing and deleting an object in it.
+
 Read the documentation at
 	https://upspin.io/doc/server_setup.md
-for information on configuring upspinserver to use cloud storage services.
+for information on configuring upspinserver to use other cloud storage
+services.
 `
 
 func main() {
@@ -46,9 +62,16 @@ func main() {
 	where := flag.String("where", filepath.Join(os.Getenv("HOME"), "upspin", "deploy"), "`directory` to store private configuration files")
 	domain := flag.String("domain", "", "domain `name` for this Upspin installation")
 	storagePath := flag.String("path", "", "`directory` on the server in which to keep Upspin storage (default is $HOME/upspin/server/storage)")
+	backend := flag.String("backend", "Disk", "storage `backend` to configure: Disk or S3")
+	var s3 s3Options
+	flag.StringVar(&s3.bucket, "bucket", "", "S3 bucket `name`")
+	flag.StringVar(&s3.region, "region", "us-east-1", "S3 `region` of the bucket")
+	flag.StringVar(&s3.endpoint, "endpoint", "", "`URL` of an S3-compatible service (default Amazon S3)")
+	flag.StringVar(&s3.profile, "profile", "", "`name` of the profile in the AWS shared credentials file holding the S3 credentials (default from the environment)")
+	flag.BoolVar(&s3.create, "create", false, "create the S3 bucket and block public access to it")
 
 	s.ParseFlags(flag.CommandLine, os.Args[1:], help,
-		"setupstorage -domain=<name> -path=<storage_dir>")
+		"setupstorage -domain=<name> [-path=<storage_dir> | -backend=S3 -bucket=<name> [-region=<region>] [-endpoint=<url>] [-profile=<name>] [-create]]")
 	if *configFlag != "" {
 		s.Exitf("the -config flag must not be set")
 	}
@@ -59,11 +82,28 @@ func main() {
 	cfgPath := filepath.Join(*where, *domain)
 	cfg := s.ReadServerConfig(cfgPath)
 
-	if *storagePath != "" {
-		cfg.StoreConfig = []string{
-			"backend=Disk",
-			"basePath=" + *storagePath,
+	switch strings.ToLower(*backend) {
+	case "disk":
+		if *storagePath != "" {
+			cfg.StoreConfig = []string{
+				"backend=Disk",
+				"basePath=" + *storagePath,
+			}
+		}
+	case "s3":
+		if s3.bucket == "" {
+			s.Exitf("the -bucket flag must be provided with -backend=S3")
+		}
+		creds, err := loadS3Credentials(s3.profile)
+		if err != nil {
+			s.Exit(err)
+		}
+		cfg.StoreConfig, err = setupS3(newS3Client(s3, creds), s3)
+		if err != nil {
+			s.Exitf("setting up S3 bucket %q: %v", s3.bucket, err)
 		}
+	default:
+		s.Exitf("unknown -backend %q; must be Disk or S3", *backend)
 	}
 	s.WriteServerConfig(cfgPath, cfg)
 
@@ -0,0 +1,190 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Upspin-sftp serves the Upspin name space over SFTP, so that standard
+// SFTP clients and tools that speak it can read and write Upspin files.
+//
+// The server runs as the user named in the Upspin config and all
+// requests are made with that user's identity. SSH clients authenticate
+// with a public key listed in the file named by -authorized_keys, in the
+// format of OpenSSH's authorized_keys file; the SSH user name is ignored.
+// The server's own SSH host key is read from the file named by -hostkey.
+//
+// Paths seen by SFTP clients are Upspin path names with a leading slash,
+// such as /ann@example.com/dir/file. Relative paths are interpreted
+// relative to the root of the server's user. Upspin links appear as
+// symbolic links, which may be followed and read but not created.
+// Files are read a block at a time as the client asks for them.
+// Data written to a file is stored as it arrives and the file is saved
+// to Upspin when it is closed. Writes must be made roughly in order, as
+// SFTP clients do; data before what has been stored cannot be changed.
+package main // import "upspin.io/cmd/upspin-sftp"
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/ssh"
+
+	"upspin.io/client"
+	"upspin.io/config"
+	"upspin.io/errors"
+	"upspin.io/flags"
+	"upspin.io/log"
+	"upspin.io/transports"
+	"upspin.io/upspin"
+	"upspin.io/version"
+
+	_ "upspin.io/pack/ee"
+	_ "upspin.io/pack/eeintegrity"
+	_ "upspin.io/pack/plain"
+)
+
+const cmdName = "upspin-sftp"
+
+var (
+	listenAddr     = flag.String("listen", "localhost:2022", "`address` on which to listen for SSH connections")
+	hostKeyFile    = flag.String("hostkey", filepath.Join(os.Getenv("HOME"), ".ssh", "upspin-sftp_host_key"), "`file` holding the server's SSH private host key")
+	authorizedKeys = flag.String("authorized_keys", filepath.Join(os.Getenv("HOME"), ".ssh", "authorized_keys"), "`file` listing the SSH public keys allowed to connect")
+)
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags]\n", os.Args[0])
+	flag.PrintDefaults()
+}
+
+func main() {
+	flag.Usage = usage
+	flags.Parse(flags.Client, "version")
+	if flags.Version {
+		fmt.Print(version.Version())
+		return
+	}
+	if flag.NArg() != 0 {
+		usage()
+		os.Exit(2)
+	}
+
+	cfg, err := config.FromFile(flags.Config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := config.SetFlagValues(cfg, cmdName); err != nil {
+		log.Fatalf("%s: %s", cmdName, err)
+	}
+	transports.Init(cfg)
+
+	hostKey, err := os.ReadFile(*hostKeyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	authKeys, err := os.ReadFile(*authorizedKeys)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sshCfg, err := serverConfig(hostKey, authKeys)
+	if err != nil {
+		log.Fatalf("%s: %s", cmdName, err)
+	}
+
+	ln, err := net.Listen("tcp", *listenAddr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Info.Printf("%s: serving %s on %s", cmdName, cfg.UserName(), ln.Addr())
+	log.Fatal(serve(ln, sshCfg, client.New(cfg), cfg.UserName()))
+}
+
+// serverConfig returns the SSH server configuration for the given
+// private host key and list of authorized public keys, both in the
+// formats used by OpenSSH.
+func serverConfig(hostKey, authKeys []byte) (*ssh.ServerConfig, error) {
+	const op errors.Op = "cmd/upspin-sftp.serverConfig"
+	signer, err := ssh.ParsePrivateKey(hostKey)
+	if err != nil {
+		return nil, errors.E(op, errors.Invalid, errors.Errorf("host key: %v", err))
+	}
+	var keys [][]byte
+	for len(bytes.TrimSpace(authKeys)) > 0 {
+		var key ssh.PublicKey
+		key, _, _, authKeys, err = ssh.ParseAuthorizedKey(authKeys)
+		if err != nil {
+			return nil, errors.E(op, errors.Invalid, errors.Errorf("authorized keys: %v", err))
+		}
+		keys = append(keys, key.Marshal())
+	}
+	if len(keys) == 0 {
+		return nil, errors.E(op, errors.Invalid, "no authorized keys")
+	}
+	cfg := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			k := key.Marshal()
+			for _, ak := range keys {
+				if bytes.Equal(k, ak) {
+					return nil, nil
+				}
+			}
+			return nil, errors.Errorf("unknown public key for %q", conn.User())
+		},
+	}
+	cfg.AddHostKey(signer)
+	return cfg, nil
+}
+
+// serve accepts SSH connections on the listener and serves SFTP
+// sessions on them as the given user.
+func serve(ln net.Listener, cfg *ssh.ServerConfig, cli upspin.Client, user upspin.UserName) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+		go serveConn(conn, cfg, cli, user)
+	}
+}
+
+func serveConn(conn net.Conn, cfg *ssh.ServerConfig, cli upspin.Client, user upspin.UserName) {
+	sconn, chans, reqs, err := ssh.NewServerConn(conn, cfg)
+	if err != nil {
+		log.Info.Printf("%s: %s: %v", cmdName, conn.RemoteAddr(), err)
+		conn.Close()
+		return
+	}
+	defer sconn.Close()
+	go ssh.DiscardRequests(reqs)
+	for nc := range chans {
+		if nc.ChannelType() != "session" {
+			nc.Reject(ssh.UnknownChannelType, "unknown channel type")
+			continue
+		}
+		ch, reqs, err := nc.Accept()
+		if err != nil {
+			log.Error.Printf("%s: accepting channel: %v", cmdName, err)
+			return
+		}
+		go serveSession(ch, reqs, cli, user)
+	}
+}
+
+// serveSession serves a session channel, which may request only
+// the sftp subsystem.
+func serveSession(ch ssh.Channel, reqs <-chan *ssh.Request, cli upspin.Client, user upspin.UserName) {
+	defer ch.Close()
+	for req := range reqs {
+		ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+		req.Reply(ok, nil)
+		if !ok {
+			continue
+		}
+		go ssh.DiscardRequests(reqs)
+		if err := newSFTPServer(cli, user).serve(ch); err != nil {
+			log.Error.Printf("%s: %v", cmdName, err)
+		}
+		return
+	}
+}
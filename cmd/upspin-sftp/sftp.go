@@ -0,0 +1,945 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// This file implements version 3 of the SSH File Transfer Protocol,
+// as described in draft-ietf-secsh-filexfer-02, over an upspin.Client.
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	ospath "path"
+	"strings"
+	"time"
+
+	"upspin.io/client"
+	"upspin.io/client/clientutil"
+	"upspin.io/errors"
+	"upspin.io/log"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+// Packet types.
+const (
+	fxpInit     = 1
+	fxpVersion  = 2
+	fxpOpen     = 3
+	fxpClose    = 4
+	fxpRead     = 5
+	fxpWrite    = 6
+	fxpLstat    = 7
+	fxpFstat    = 8
+	fxpSetstat  = 9
+	fxpFsetstat = 10
+	fxpOpendir  = 11
+	fxpReaddir  = 12
+	fxpRemove   = 13
+	fxpMkdir    = 14
+	fxpRmdir    = 15
+	fxpRealpath = 16
+	fxpStat     = 17
+	fxpRename   = 18
+	fxpReadlink = 19
+	fxpSymlink  = 20
+	fxpStatus   = 101
+	fxpHandle   = 102
+	fxpData     = 103
+	fxpName     = 104
+	fxpAttrs    = 105
+	fxpExtended = 200
+)
+
+// Status codes.
+const (
+	fxOK               = 0
+	fxEOF              = 1
+	fxNoSuchFile       = 2
+	fxPermissionDenied = 3
+	fxFailure          = 4
+	fxBadMessage       = 5
+	fxOpUnsupported    = 8
+)
+
+// Flags of an open request.
+const (
+	fxfRead   = 0x01
+	fxfWrite  = 0x02
+	fxfAppend = 0x04
+	fxfCreat  = 0x08
+	fxfTrunc  = 0x10
+	fxfExcl   = 0x20
+)
+
+// Flags of a file attributes structure.
+const (
+	attrSize        = 0x01
+	attrUIDGID      = 0x02
+	attrPermissions = 0x04
+	attrACModTime   = 0x08
+	attrExtended    = 0x80000000
+)
+
+const (
+	protocolVersion = 3
+
+	// maxPacket is the largest packet accepted, comfortably more than
+	// the 32KB of data clients typically send in a write.
+	maxPacket = 1 << 18
+
+	// maxRead is the most data returned by a single read.
+	maxRead = 1 << 15
+
+	// readdirBatch is the number of names returned by each readdir.
+	readdirBatch = 100
+
+	// Permission bits reported for files and directories; Upspin access
+	// is governed by Access files, not by these bits.
+	filePerm = 0600
+	dirPerm  = 0700
+)
+
+// sftpServer serves the SFTP protocol for one connection.
+type sftpServer struct {
+	cli  upspin.Client
+	user upspin.UserName
+
+	handles    map[string]*sftpHandle
+	nextHandle int
+}
+
+// sftpHandle is an open file or directory.
+type sftpHandle struct {
+	name upspin.PathName
+
+	// For files.
+	file    upspin.File // Being read.
+	w       *fileWriter // Being written.
+	writing bool
+	append  bool
+	size    int64 // Of a file being written.
+
+	// For directories.
+	dir  bool
+	ents []*upspin.DirEntry // Entries not yet returned by readdir.
+}
+
+func newSFTPServer(cli upspin.Client, user upspin.UserName) *sftpServer {
+	return &sftpServer{
+		cli:     cli,
+		user:    user,
+		handles: make(map[string]*sftpHandle),
+	}
+}
+
+// serve reads requests from rw and writes the responses until the
+// client closes the connection. Files left open are closed, so data
+// written to them is saved.
+func (s *sftpServer) serve(rw io.ReadWriter) error {
+	defer s.closeAll()
+	for {
+		typ, payload, err := readPacket(rw)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		resp := s.handle(typ, payload)
+		if resp == nil {
+			continue
+		}
+		if err := writePacket(rw, resp); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *sftpServer) closeAll() {
+	for id, h := range s.handles {
+		if err := h.close(); err != nil {
+			log.Error.Printf("upspin-sftp: closing %s: %v", h.name, err)
+		}
+		delete(s.handles, id)
+	}
+}
+
+// readPacket reads a packet and returns its type and payload.
+func readPacket(r io.Reader) (byte, []byte, error) {
+	var hdr [4]byte
+	if _, err := io.ReadFull(r, hdr[:]); err != nil {
+		return 0, nil, err
+	}
+	n := binary.BigEndian.Uint32(hdr[:])
+	if n == 0 || n > maxPacket {
+		return 0, nil, errors.Errorf("bad packet length %d", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(r, b); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return 0, nil, err
+	}
+	return b[0], b[1:], nil
+}
+
+// writePacket writes the packet, which holds the type and payload,
+// preceded by its length.
+func writePacket(w io.Writer, b []byte) error {
+	var hdr [4]byte
+	binary.BigEndian.PutUint32(hdr[:], uint32(len(b)))
+	if _, err := w.Write(append(hdr[:], b...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// errShort reports a truncated request.
+var errShort = errors.Str("short packet")
+
+// decoder reads the fields of a request.
+type decoder struct {
+	b   []byte
+	err error
+}
+
+func (d *decoder) uint32() uint32 {
+	if len(d.b) < 4 {
+		d.err = errShort
+		return 0
+	}
+	v := binary.BigEndian.Uint32(d.b)
+	d.b = d.b[4:]
+	return v
+}
+
+func (d *decoder) uint64() uint64 {
+	if len(d.b) < 8 {
+		d.err = errShort
+		return 0
+	}
+	v := binary.BigEndian.Uint64(d.b)
+	d.b = d.b[8:]
+	return v
+}
+
+func (d *decoder) string() string {
+	n := d.uint32()
+	if uint32(len(d.b)) < n {
+		d.err = errShort
+		return ""
+	}
+	v := string(d.b[:n])
+	d.b = d.b[n:]
+	return v
+}
+
+// attrs reads a file attributes structure.
+func (d *decoder) attrs() *fileAttrs {
+	a := &fileAttrs{flags: d.uint32()}
+	if a.flags&attrSize != 0 {
+		a.size = d.uint64()
+	}
+	if a.flags&attrUIDGID != 0 {
+		d.uint32()
+		d.uint32()
+	}
+	if a.flags&attrPermissions != 0 {
+		a.perm = d.uint32()
+	}
+	if a.flags&attrACModTime != 0 {
+		d.uint32()
+		a.mtime = d.uint32()
+	}
+	if a.flags&attrExtended != 0 {
+		for n := d.uint32(); n > 0 && d.err == nil; n-- {
+			d.string()
+			d.string()
+		}
+	}
+	return a
+}
+
+// encoder builds a response.
+type encoder struct {
+	b []byte
+}
+
+func newResponse(typ byte, id uint32) *encoder {
+	e := &encoder{b: []byte{typ}}
+	e.uint32(id)
+	return e
+}
+
+func (e *encoder) uint32(v uint32) {
+	e.b = binary.BigEndian.AppendUint32(e.b, v)
+}
+
+func (e *encoder) uint64(v uint64) {
+	e.b = binary.BigEndian.AppendUint64(e.b, v)
+}
+
+func (e *encoder) string(v string) {
+	e.uint32(uint32(len(v)))
+	e.b = append(e.b, v...)
+}
+
+// fileAttrs holds the attributes of a file that the protocol carries.
+type fileAttrs struct {
+	flags uint32
+	size  uint64
+	perm  uint32 // Including the file type bits, as in stat(2).
+	mtime uint32
+}
+
+func (e *encoder) attrs(a *fileAttrs) {
+	e.uint32(a.flags)
+	if a.flags&attrSize != 0 {
+		e.uint64(a.size)
+	}
+	if a.flags&attrUIDGID != 0 {
+		e.uint32(0)
+		e.uint32(0)
+	}
+	if a.flags&attrPermissions != 0 {
+		e.uint32(a.perm)
+	}
+	if a.flags&attrACModTime != 0 {
+		e.uint32(a.mtime)
+		e.uint32(a.mtime)
+	}
+}
+
+// File type bits of the permissions attribute.
+const (
+	modeDir     = 0040000
+	modeRegular = 0100000
+	modeSymlink = 0120000
+)
+
+// entryAttrs returns the attributes of the item described by the entry.
+func entryAttrs(entry *upspin.DirEntry) *fileAttrs {
+	a := &fileAttrs{
+		flags: attrSize | attrPermissions | attrACModTime,
+		mtime: uint32(entry.Time),
+	}
+	switch {
+	case entry.IsDir():
+		a.perm = modeDir | dirPerm
+	case entry.IsLink():
+		a.perm = modeSymlink | 0777
+		a.size = uint64(len(entry.Link))
+	default:
+		a.perm = modeRegular | filePerm
+		if size, err := entry.Size(); err == nil {
+			a.size = uint64(size)
+		}
+	}
+	return a
+}
+
+// rootAttrs are the attributes of the root, which lists user trees.
+var rootAttrs = &fileAttrs{
+	flags: attrSize | attrPermissions | attrACModTime,
+	perm:  modeDir | 0500,
+}
+
+// longName returns the ls -l style description of an item that the
+// protocol returns alongside its name.
+func longName(name string, a *fileAttrs) string {
+	mode := os.FileMode(a.perm & 0777)
+	switch a.perm &^ 0777 {
+	case modeDir:
+		mode |= os.ModeDir
+	case modeSymlink:
+		mode |= os.ModeSymlink
+	}
+	t := time.Unix(int64(a.mtime), 0).UTC().Format("Jan _2 15:04")
+	return fmt.Sprintf("%s 1 upspin upspin %8d %s %s", mode, a.size, t, name)
+}
+
+func statusResponse(id uint32, code uint32, msg string) []byte {
+	e := newResponse(fxpStatus, id)
+	e.uint32(code)
+	e.string(msg)
+	e.string("")
+	return e.b
+}
+
+// errorResponse returns the status response for the error.
+func errorResponse(id uint32, err error) []byte {
+	code := uint32(fxFailure)
+	switch {
+	case errors.Is(errors.NotExist, err), errors.Is(errors.BrokenLink, err):
+		code = fxNoSuchFile
+	case errors.Is(errors.Permission, err), errors.Is(errors.Private, err):
+		code = fxPermissionDenied
+	case err == upspin.ErrNotSupported:
+		code = fxOpUnsupported
+	}
+	return statusResponse(id, code, err.Error())
+}
+
+func okResponse(id uint32) []byte {
+	return statusResponse(id, fxOK, "OK")
+}
+
+// upspinName converts the slash-separated name used by the client into
+// an Upspin path name. The root, which holds no Upspin items, is returned
+// as the empty name. Relative names are interpreted relative to the
+// root of the server's user.
+func (s *sftpServer) upspinName(name string) (upspin.PathName, error) {
+	if !strings.HasPrefix(name, "/") {
+		name = "/" + string(s.user) + "/" + name
+	}
+	name = strings.Trim(ospath.Clean(name), "/")
+	if name == "" {
+		return "", nil
+	}
+	p, err := path.Parse(upspin.PathName(name))
+	if err != nil {
+		return "", err
+	}
+	return p.Path(), nil
+}
+
+// clientName converts an Upspin path name into the form seen by the client.
+func clientName(name upspin.PathName) string {
+	return "/" + strings.TrimSuffix(string(name), "/")
+}
+
+// handle handles one request, returning the response to send, if any.
+func (s *sftpServer) handle(typ byte, payload []byte) []byte {
+	d := &decoder{b: payload}
+	if typ == fxpInit {
+		d.uint32() // Client version; we speak only version 3.
+		e := &encoder{b: []byte{fxpVersion}}
+		e.uint32(protocolVersion)
+		return e.b
+	}
+	id := d.uint32()
+	if d.err != nil {
+		return nil
+	}
+	var resp []byte
+	var err error
+	switch typ {
+	case fxpOpen:
+		name, pflags := d.string(), d.uint32()
+		d.attrs()
+		if d.err == nil {
+			resp, err = s.open(id, name, pflags)
+		}
+	case fxpClose:
+		handle := d.string()
+		if d.err == nil {
+			resp, err = s.close(id, handle)
+		}
+	case fxpRead:
+		handle, off, n := d.string(), d.uint64(), d.uint32()
+		if d.err == nil {
+			resp, err = s.read(id, handle, int64(off), n)
+		}
+	case fxpWrite:
+		handle, off, data := d.string(), d.uint64(), d.string()
+		if d.err == nil {
+			resp, err = s.write(id, handle, int64(off), []byte(data))
+		}
+	case fxpStat, fxpLstat:
+		name := d.string()
+		if d.err == nil {
+			resp, err = s.stat(id, name, typ == fxpStat)
+		}
+	case fxpFstat:
+		handle := d.string()
+		if d.err == nil {
+			resp, err = s.fstat(id, handle)
+		}
+	case fxpSetstat:
+		name := d.string()
+		a := d.attrs()
+		if d.err == nil {
+			resp, err = s.setstat(id, name, a)
+		}
+	case fxpFsetstat:
+		handle := d.string()
+		a := d.attrs()
+		if d.err == nil {
+			resp, err = s.fsetstat(id, handle, a)
+		}
+	case fxpOpendir:
+		name := d.string()
+		if d.err == nil {
+			resp, err = s.opendir(id, name)
+		}
+	case fxpReaddir:
+		handle := d.string()
+		if d.err == nil {
+			resp, err = s.readdir(id, handle)
+		}
+	case fxpRemove:
+		name := d.string()
+		if d.err == nil {
+			resp, err = s.remove(id, name, false)
+		}
+	case fxpRmdir:
+		name := d.string()
+		if d.err == nil {
+			resp, err = s.remove(id, name, true)
+		}
+	case fxpMkdir:
+		name := d.string()
+		d.attrs()
+		if d.err == nil {
+			resp, err = s.mkdir(id, name)
+		}
+	case fxpRealpath:
+		name := d.string()
+		if d.err == nil {
+			resp, err = s.realpath(id, name)
+		}
+	case fxpRename:
+		oldName, newName := d.string(), d.string()
+		if d.err == nil {
+			resp, err = s.rename(id, oldName, newName)
+		}
+	case fxpReadlink:
+		name := d.string()
+		if d.err == nil {
+			resp, err = s.readlink(id, name)
+		}
+	case fxpSymlink:
+		// Links may be followed but not made.
+		return statusResponse(id, fxPermissionDenied, "symbolic links are read-only")
+	default:
+		return statusResponse(id, fxOpUnsupported, fmt.Sprintf("unsupported request type %d", typ))
+	}
+	if d.err != nil {
+		return statusResponse(id, fxBadMessage, d.err.Error())
+	}
+	if err != nil {
+		return errorResponse(id, err)
+	}
+	return resp
+}
+
+// newHandle records the open item and returns the handle response for it.
+func (s *sftpServer) newHandle(id uint32, h *sftpHandle) []byte {
+	s.nextHandle++
+	handle := fmt.Sprint(s.nextHandle)
+	s.handles[handle] = h
+	e := newResponse(fxpHandle, id)
+	e.string(handle)
+	return e.b
+}
+
+func (s *sftpServer) lookupHandle(handle string) (*sftpHandle, error) {
+	h, ok := s.handles[handle]
+	if !ok {
+		return nil, errors.E(errors.Invalid, errors.Errorf("unknown handle %q", handle))
+	}
+	return h, nil
+}
+
+func (s *sftpServer) open(id uint32, name string, pflags uint32) ([]byte, error) {
+	p, err := s.upspinName(name)
+	if err != nil {
+		return nil, err
+	}
+	if p == "" {
+		return nil, errors.E(errors.IsDir, "/")
+	}
+	if pflags&(fxfWrite|fxfAppend) == 0 {
+		// Reads are served a block at a time from the store.
+		entry, err := s.cli.Lookup(p, true)
+		if err != nil {
+			return nil, err
+		}
+		if entry.IsDir() {
+			return nil, errors.E(errors.IsDir, p)
+		}
+		f, err := s.cli.Open(entry.Name)
+		if err != nil {
+			return nil, err
+		}
+		return s.newHandle(id, &sftpHandle{name: entry.Name, file: f}), nil
+	}
+
+	// Writes are streamed to the store as they arrive and the file is
+	// saved when it is closed. Unless the file is truncated the parts
+	// of its existing contents that are not overwritten are kept.
+	sp, ok := s.cli.(client.StreamPutter)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	var (
+		old     upspin.File
+		oldSize int64
+	)
+	entry, err := s.cli.Lookup(p, true)
+	switch {
+	case err == nil && pflags&fxfExcl != 0 && pflags&fxfCreat != 0:
+		return nil, errors.E(errors.Exist, p)
+	case err == nil && entry.IsDir():
+		return nil, errors.E(errors.IsDir, p)
+	case err == nil:
+		p = entry.Name
+		if pflags&fxfTrunc == 0 {
+			if oldSize, err = entry.Size(); err != nil {
+				return nil, err
+			}
+			if old, err = s.cli.Open(p); err != nil {
+				return nil, err
+			}
+		}
+	case errors.Is(errors.NotExist, err) && pflags&fxfCreat != 0:
+		// A new file.
+	default:
+		return nil, err
+	}
+	h := &sftpHandle{
+		name:    p,
+		w:       newFileWriter(sp, p, old, oldSize),
+		writing: true,
+		append:  pflags&fxfAppend != 0,
+		size:    oldSize,
+	}
+	return s.newHandle(id, h), nil
+}
+
+func (h *sftpHandle) close() error {
+	if h.w != nil {
+		w := h.w
+		h.w = nil
+		return w.Close()
+	}
+	if h.file == nil {
+		return nil
+	}
+	f := h.file
+	h.file = nil
+	return f.Close()
+}
+
+func (s *sftpServer) close(id uint32, handle string) ([]byte, error) {
+	h, err := s.lookupHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+	delete(s.handles, handle)
+	if err := h.close(); err != nil {
+		return nil, err
+	}
+	return okResponse(id), nil
+}
+
+func (s *sftpServer) read(id uint32, handle string, off int64, n uint32) ([]byte, error) {
+	h, err := s.lookupHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+	if h.file == nil || h.writing {
+		return nil, errors.E(errors.Invalid, h.name, "not open for reading")
+	}
+	if n > maxRead {
+		n = maxRead
+	}
+	buf := make([]byte, n)
+	m, err := h.file.ReadAt(buf, off)
+	if m == 0 && (err == io.EOF || errors.Is(errors.Invalid, err)) {
+		// The client File reports reads beyond the end as invalid.
+		return statusResponse(id, fxEOF, "EOF"), nil
+	}
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	e := newResponse(fxpData, id)
+	e.string(string(buf[:m]))
+	return e.b, nil
+}
+
+func (s *sftpServer) write(id uint32, handle string, off int64, data []byte) ([]byte, error) {
+	h, err := s.lookupHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+	if h.w == nil {
+		return nil, errors.E(errors.Invalid, h.name, "not open for writing")
+	}
+	if h.append {
+		off = h.size
+	}
+	if err := h.w.WriteAt(data, off); err != nil {
+		return nil, err
+	}
+	if end := off + int64(len(data)); end > h.size {
+		h.size = end
+	}
+	return okResponse(id), nil
+}
+
+func (s *sftpServer) stat(id uint32, name string, follow bool) ([]byte, error) {
+	p, err := s.upspinName(name)
+	if err != nil {
+		return nil, err
+	}
+	a := rootAttrs
+	if p != "" {
+		entry, err := s.cli.Lookup(p, follow)
+		if err != nil {
+			return nil, err
+		}
+		a = entryAttrs(entry)
+	}
+	e := newResponse(fxpAttrs, id)
+	e.attrs(a)
+	return e.b, nil
+}
+
+func (s *sftpServer) fstat(id uint32, handle string) ([]byte, error) {
+	h, err := s.lookupHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+	var a *fileAttrs
+	switch {
+	case h.name == "":
+		a = rootAttrs
+	case h.writing:
+		a = &fileAttrs{
+			flags: attrSize | attrPermissions | attrACModTime,
+			size:  uint64(h.size),
+			perm:  modeRegular | filePerm,
+			mtime: uint32(time.Now().Unix()),
+		}
+	default:
+		entry, err := s.cli.Lookup(h.name, false)
+		if err != nil {
+			return nil, err
+		}
+		a = entryAttrs(entry)
+	}
+	e := newResponse(fxpAttrs, id)
+	e.attrs(a)
+	return e.b, nil
+}
+
+// setstat sets the modification time of the named file. Permissions
+// and ownership are ignored, as Access files govern access in Upspin,
+// but the requests succeed so that clients preserving them still work.
+func (s *sftpServer) setstat(id uint32, name string, a *fileAttrs) ([]byte, error) {
+	p, err := s.upspinName(name)
+	if err != nil {
+		return nil, err
+	}
+	if p == "" {
+		return nil, errors.E(errors.Permission, "/")
+	}
+	entry, err := s.cli.Lookup(p, true)
+	if err != nil {
+		return nil, err
+	}
+	if a.flags&attrSize != 0 && !entry.IsDir() {
+		if size, err := entry.Size(); err != nil || uint64(size) != a.size {
+			return nil, upspin.ErrNotSupported
+		}
+	}
+	if a.flags&attrACModTime != 0 && !entry.IsDir() {
+		if err := s.cli.SetTime(entry.Name, upspin.Time(a.mtime)); err != nil {
+			return nil, err
+		}
+	}
+	return okResponse(id), nil
+}
+
+// fsetstat is like setstat but for an open file. Times set on a file
+// being written are lost when it is saved, so only size changes that
+// do nothing are accepted.
+func (s *sftpServer) fsetstat(id uint32, handle string, a *fileAttrs) ([]byte, error) {
+	h, err := s.lookupHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+	if !h.writing {
+		return s.setstat(id, clientName(h.name), a)
+	}
+	if a.flags&attrSize != 0 && int64(a.size) != h.size {
+		return nil, upspin.ErrNotSupported
+	}
+	return okResponse(id), nil
+}
+
+func (s *sftpServer) opendir(id uint32, name string) ([]byte, error) {
+	p, err := s.upspinName(name)
+	if err != nil {
+		return nil, err
+	}
+	h := &sftpHandle{dir: true}
+	if p == "" {
+		// The root holds only the server user's tree.
+		entry, err := s.cli.Lookup(upspin.PathName(s.user+"/"), true)
+		if err != nil {
+			return nil, err
+		}
+		h.ents = []*upspin.DirEntry{entry}
+		return s.newHandle(id, h), nil
+	}
+	entry, err := s.cli.Lookup(p, true)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.IsDir() {
+		return nil, errors.E(errors.NotDir, p)
+	}
+	h.name = entry.Name
+	h.ents, err = s.cli.Glob(upspin.AllFilesGlob(entry.Name))
+	if err != nil {
+		return nil, err
+	}
+	return s.newHandle(id, h), nil
+}
+
+func (s *sftpServer) readdir(id uint32, handle string) ([]byte, error) {
+	h, err := s.lookupHandle(handle)
+	if err != nil {
+		return nil, err
+	}
+	if !h.dir {
+		return nil, errors.E(errors.NotDir, h.name)
+	}
+	if len(h.ents) == 0 {
+		return statusResponse(id, fxEOF, "EOF"), nil
+	}
+	n := len(h.ents)
+	if n > readdirBatch {
+		n = readdirBatch
+	}
+	e := newResponse(fxpName, id)
+	e.uint32(uint32(n))
+	for _, entry := range h.ents[:n] {
+		elem := string(entry.Name)
+		if p, err := path.Parse(entry.Name); err == nil && !p.IsRoot() {
+			elem = p.Elem(p.NElem() - 1)
+		} else {
+			elem = strings.TrimSuffix(elem, "/")
+		}
+		a := entryAttrs(entry)
+		e.string(elem)
+		e.string(longName(elem, a))
+		e.attrs(a)
+	}
+	h.ents = h.ents[n:]
+	return e.b, nil
+}
+
+func (s *sftpServer) remove(id uint32, name string, dir bool) ([]byte, error) {
+	p, err := s.upspinName(name)
+	if err != nil {
+		return nil, err
+	}
+	if p == "" {
+		return nil, errors.E(errors.Permission, "/")
+	}
+	entry, err := s.cli.Lookup(p, false)
+	if err != nil {
+		return nil, err
+	}
+	if dir && !entry.IsDir() {
+		return nil, errors.E(errors.NotDir, p)
+	}
+	if !dir && entry.IsDir() {
+		return nil, errors.E(errors.IsDir, p)
+	}
+	if err := s.cli.Delete(p); err != nil {
+		return nil, err
+	}
+	return okResponse(id), nil
+}
+
+func (s *sftpServer) mkdir(id uint32, name string) ([]byte, error) {
+	p, err := s.upspinName(name)
+	if err != nil {
+		return nil, err
+	}
+	if p == "" {
+		return nil, errors.E(errors.Exist, "/")
+	}
+	if _, err := s.cli.MakeDirectory(p); err != nil {
+		return nil, err
+	}
+	return okResponse(id), nil
+}
+
+func (s *sftpServer) realpath(id uint32, name string) ([]byte, error) {
+	p, err := s.upspinName(name)
+	if err != nil {
+		return nil, err
+	}
+	real := clientName(p)
+	e := newResponse(fxpName, id)
+	e.uint32(1)
+	e.string(real)
+	e.string(real)
+	e.attrs(&fileAttrs{})
+	return e.b, nil
+}
+
+func (s *sftpServer) rename(id uint32, oldName, newName string) ([]byte, error) {
+	oldP, err := s.upspinName(oldName)
+	if err != nil {
+		return nil, err
+	}
+	newP, err := s.upspinName(newName)
+	if err != nil {
+		return nil, err
+	}
+	if oldP == "" || newP == "" {
+		return nil, errors.E(errors.Permission, "/")
+	}
+	entry, err := s.cli.Lookup(oldP, false)
+	if err != nil {
+		return nil, err
+	}
+	if entry.IsDir() {
+		dr, ok := s.cli.(clientutil.DirRenamer)
+		if !ok {
+			return nil, upspin.ErrNotSupported
+		}
+		_, err = dr.RenameDir(oldP, newP)
+	} else {
+		_, err = s.cli.Rename(oldP, newP)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return okResponse(id), nil
+}
+
+func (s *sftpServer) readlink(id uint32, name string) ([]byte, error) {
+	p, err := s.upspinName(name)
+	if err != nil {
+		return nil, err
+	}
+	if p == "" {
+		return nil, errors.E(errors.Invalid, "/", "not a link")
+	}
+	entry, err := s.cli.Lookup(p, false)
+	if err != nil {
+		return nil, err
+	}
+	if !entry.IsLink() {
+		return nil, errors.E(errors.Invalid, p, "not a link")
+	}
+	target := clientName(entry.Link)
+	e := newResponse(fxpName, id)
+	e.uint32(1)
+	e.string(target)
+	e.string(target)
+	e.attrs(&fileAttrs{})
+	return e.b, nil
+}
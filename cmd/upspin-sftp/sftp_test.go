@@ -0,0 +1,468 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"io"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+
+	"upspin.io/client"
+	"upspin.io/test/testenv"
+	"upspin.io/upspin"
+)
+
+const owner = "aly@example.com"
+
+// sftpClient is a minimal SFTP client for testing the server.
+type sftpClient struct {
+	t  *testing.T
+	rw io.ReadWriter
+	id uint32
+}
+
+func newSFTPClient(t *testing.T, rw io.ReadWriter) *sftpClient {
+	c := &sftpClient{t: t, rw: rw}
+	e := &encoder{b: []byte{fxpInit}}
+	e.uint32(protocolVersion)
+	if err := writePacket(rw, e.b); err != nil {
+		t.Fatal(err)
+	}
+	typ, payload, err := readPacket(rw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ != fxpVersion {
+		t.Fatalf("init: got packet type %d, want %d", typ, fxpVersion)
+	}
+	if d := (&decoder{b: payload}); d.uint32() != protocolVersion {
+		t.Fatal("init: wrong version")
+	}
+	return c
+}
+
+// call sends the request, with fields built by fill, and returns the
+// type and decoder for the response.
+func (c *sftpClient) call(typ byte, fill func(e *encoder)) (byte, *decoder) {
+	c.t.Helper()
+	c.id++
+	e := newResponse(typ, c.id)
+	fill(e)
+	if err := writePacket(c.rw, e.b); err != nil {
+		c.t.Fatal(err)
+	}
+	rtyp, payload, err := readPacket(c.rw)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	d := &decoder{b: payload}
+	if id := d.uint32(); id != c.id {
+		c.t.Fatalf("response id %d, want %d", id, c.id)
+	}
+	return rtyp, d
+}
+
+// status calls the request and returns the status code of the response.
+func (c *sftpClient) status(typ byte, fill func(e *encoder)) uint32 {
+	c.t.Helper()
+	rtyp, d := c.call(typ, fill)
+	if rtyp != fxpStatus {
+		c.t.Fatalf("request %d: got response type %d, want status", typ, rtyp)
+	}
+	return d.uint32()
+}
+
+// ok calls the request and fails the test unless it succeeds.
+func (c *sftpClient) ok(typ byte, fill func(e *encoder)) {
+	c.t.Helper()
+	if code := c.status(typ, fill); code != fxOK {
+		c.t.Fatalf("request %d: status %d", typ, code)
+	}
+}
+
+func (c *sftpClient) open(name string, pflags uint32) (string, uint32) {
+	c.t.Helper()
+	typ, d := c.call(fxpOpen, func(e *encoder) {
+		e.string(name)
+		e.uint32(pflags)
+		e.uint32(0)
+	})
+	if typ == fxpStatus {
+		return "", d.uint32()
+	}
+	return d.string(), fxOK
+}
+
+func (c *sftpClient) readFile(name string) string {
+	c.t.Helper()
+	h, code := c.open(name, fxfRead)
+	if code != fxOK {
+		c.t.Fatalf("open %s: status %d", name, code)
+	}
+	var buf bytes.Buffer
+	for {
+		typ, d := c.call(fxpRead, func(e *encoder) {
+			e.string(h)
+			e.uint64(uint64(buf.Len()))
+			e.uint32(10)
+		})
+		if typ == fxpStatus {
+			if code := d.uint32(); code != fxEOF {
+				c.t.Fatalf("read %s: status %d", name, code)
+			}
+			break
+		}
+		buf.WriteString(d.string())
+	}
+	c.ok(fxpClose, func(e *encoder) { e.string(h) })
+	return buf.String()
+}
+
+func (c *sftpClient) writeFile(name string, pflags uint32, data string) {
+	c.t.Helper()
+	h, code := c.open(name, pflags)
+	if code != fxOK {
+		c.t.Fatalf("open %s: status %d", name, code)
+	}
+	// Write in pieces, out of order, as clients may.
+	mid := len(data) / 2
+	c.ok(fxpWrite, func(e *encoder) {
+		e.string(h)
+		e.uint64(uint64(mid))
+		e.string(data[mid:])
+	})
+	c.ok(fxpWrite, func(e *encoder) {
+		e.string(h)
+		e.uint64(0)
+		e.string(data[:mid])
+	})
+	c.ok(fxpClose, func(e *encoder) { e.string(h) })
+}
+
+func (c *sftpClient) stat(name string, typ byte) (*fileAttrs, uint32) {
+	c.t.Helper()
+	rtyp, d := c.call(typ, func(e *encoder) { e.string(name) })
+	if rtyp == fxpStatus {
+		return nil, d.uint32()
+	}
+	return d.attrs(), fxOK
+}
+
+func (c *sftpClient) readDir(name string) []string {
+	c.t.Helper()
+	rtyp, d := c.call(fxpOpendir, func(e *encoder) { e.string(name) })
+	if rtyp != fxpHandle {
+		c.t.Fatalf("opendir %s: status %d", name, d.uint32())
+	}
+	h := d.string()
+	var names []string
+	for {
+		rtyp, d := c.call(fxpReaddir, func(e *encoder) { e.string(h) })
+		if rtyp == fxpStatus {
+			if code := d.uint32(); code != fxEOF {
+				c.t.Fatalf("readdir %s: status %d", name, code)
+			}
+			break
+		}
+		for n := d.uint32(); n > 0; n-- {
+			names = append(names, d.string())
+			d.string()
+			d.attrs()
+		}
+	}
+	c.ok(fxpClose, func(e *encoder) { e.string(h) })
+	sort.Strings(names)
+	return names
+}
+
+func (c *sftpClient) name(typ byte, name string) string {
+	c.t.Helper()
+	rtyp, d := c.call(typ, func(e *encoder) { e.string(name) })
+	if rtyp != fxpName {
+		c.t.Fatalf("request %d %s: status %d", typ, name, d.uint32())
+	}
+	if n := d.uint32(); n != 1 {
+		c.t.Fatalf("request %d %s: %d names", typ, name, n)
+	}
+	return d.string()
+}
+
+func str(s string) func(e *encoder) {
+	return func(e *encoder) { e.string(s) }
+}
+
+func setup(t *testing.T) (*testenv.Env, upspin.Client) {
+	env, err := testenv.New(&testenv.Setup{
+		OwnerName: owner,
+		Packing:   upspin.EEPack,
+		Kind:      "inprocess",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := testenv.NewRunner()
+	r.AddUser(env.Config)
+	r.As(owner)
+	r.MakeDirectory(owner + "/dir")
+	r.Put(owner+"/dir/file", "hello, world")
+	r.PutLink(owner+"/dir/file", owner+"/link")
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+	return env, client.New(env.Config)
+}
+
+// newPipe starts an SFTP server on one end of a pipe and returns a
+// client for the other.
+func newPipe(t *testing.T, cli upspin.Client) (*sftpClient, func()) {
+	sc, cc := net.Pipe()
+	done := make(chan error)
+	go func() {
+		done <- newSFTPServer(cli, owner).serve(sc)
+	}()
+	return newSFTPClient(t, cc), func() {
+		cc.Close()
+		if err := <-done; err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+func TestSFTP(t *testing.T) {
+	env, cli := setup(t)
+	defer env.Exit()
+	c, closeClient := newPipe(t, cli)
+
+	const (
+		file = "/" + owner + "/dir/file"
+		link = "/" + owner + "/link"
+	)
+
+	if got := c.name(fxpRealpath, "."); got != "/"+owner {
+		t.Errorf("realpath . = %q, want %q", got, "/"+owner)
+	}
+	if got := c.name(fxpRealpath, "dir/../dir/file"); got != file {
+		t.Errorf("realpath = %q, want %q", got, file)
+	}
+
+	// Reads, through links too.
+	if got := c.readFile(file); got != "hello, world" {
+		t.Errorf("read %s = %q", file, got)
+	}
+	if got := c.readFile(link); got != "hello, world" {
+		t.Errorf("read %s = %q", link, got)
+	}
+	if got := c.name(fxpReadlink, link); got != file {
+		t.Errorf("readlink = %q, want %q", got, file)
+	}
+	if code := c.status(fxpSymlink, func(e *encoder) {
+		e.string(link + "2")
+		e.string(file)
+	}); code != fxPermissionDenied {
+		t.Errorf("symlink: status %d, want %d", code, fxPermissionDenied)
+	}
+
+	// Attributes.
+	a, _ := c.stat(file, fxpStat)
+	if a.size != 12 || a.perm&^0777 != modeRegular {
+		t.Errorf("stat %s: size %d, mode %o", file, a.size, a.perm)
+	}
+	a, _ = c.stat(link, fxpLstat)
+	if a.perm&^0777 != modeSymlink {
+		t.Errorf("lstat %s: mode %o", link, a.perm)
+	}
+	a, _ = c.stat(link, fxpStat)
+	if a.perm&^0777 != modeRegular {
+		t.Errorf("stat %s: mode %o", link, a.perm)
+	}
+	a, _ = c.stat("/"+owner+"/dir", fxpStat)
+	if a.perm&^0777 != modeDir {
+		t.Errorf("stat dir: mode %o", a.perm)
+	}
+	if _, code := c.stat("/"+owner+"/nothing", fxpStat); code != fxNoSuchFile {
+		t.Errorf("stat missing file: status %d, want %d", code, fxNoSuchFile)
+	}
+
+	// Writes, of new and existing files.
+	c.writeFile("/"+owner+"/dir/new", fxfWrite|fxfCreat|fxfTrunc, "new file")
+	if got, err := cli.Get(owner + "/dir/new"); err != nil || string(got) != "new file" {
+		t.Errorf("Get new = %q, %v", got, err)
+	}
+	c.writeFile(file, fxfWrite, "HELLO")
+	if got := c.readFile(file); got != "HELLO, world" {
+		t.Errorf("after overwrite: %q", got)
+	}
+	c.writeFile(file, fxfWrite|fxfAppend, "!!")
+	if got := c.readFile(file); got != "HELLO, world!!" {
+		t.Errorf("after append: %q", got)
+	}
+	h, _ := c.open(file, fxfWrite)
+	c.ok(fxpWrite, func(e *encoder) {
+		e.string(h)
+		e.uint64(5)
+		e.string("--")
+	})
+	c.ok(fxpWrite, func(e *encoder) {
+		e.string(h)
+		e.uint64(0)
+		e.string("h")
+	})
+	// Data before what has been saved cannot be changed.
+	if code := c.status(fxpWrite, func(e *encoder) {
+		e.string(h)
+		e.uint64(0)
+		e.string("x")
+	}); code != fxOpUnsupported {
+		t.Errorf("write before saved data: status %d, want %d", code, fxOpUnsupported)
+	}
+	c.ok(fxpClose, str(h))
+	if got := c.readFile(file); got != "hELLO--world!!" {
+		t.Errorf("after writes in the middle: %q", got)
+	}
+	if _, code := c.open(file, fxfWrite|fxfCreat|fxfExcl); code == fxOK {
+		t.Errorf("exclusive create of existing file succeeded")
+	}
+	if _, code := c.open("/"+owner+"/dir/nothing", fxfWrite); code != fxNoSuchFile {
+		t.Errorf("open missing file for write: status %d, want %d", code, fxNoSuchFile)
+	}
+
+	// Directories.
+	c.ok(fxpMkdir, func(e *encoder) {
+		e.string("/" + owner + "/sub")
+		e.uint32(0)
+	})
+	if got, want := strings.Join(c.readDir("/"+owner), " "), "dir link sub"; got != want {
+		t.Errorf("readdir = %q, want %q", got, want)
+	}
+	if got, want := strings.Join(c.readDir("/"), " "), owner; got != want {
+		t.Errorf("readdir / = %q, want %q", got, want)
+	}
+
+	// Renames and removals.
+	c.ok(fxpRename, func(e *encoder) {
+		e.string("/" + owner + "/dir/new")
+		e.string("/" + owner + "/sub/moved")
+	})
+	if got := c.readFile("/" + owner + "/sub/moved"); got != "new file" {
+		t.Errorf("after rename: %q", got)
+	}
+	if code := c.status(fxpRmdir, str("/"+owner+"/sub")); code == fxOK {
+		t.Errorf("rmdir of non-empty directory succeeded")
+	}
+	c.ok(fxpRemove, str("/"+owner+"/sub/moved"))
+	c.ok(fxpRmdir, str("/"+owner+"/sub"))
+	if _, code := c.stat("/"+owner+"/sub", fxpStat); code != fxNoSuchFile {
+		t.Errorf("stat removed directory: status %d, want %d", code, fxNoSuchFile)
+	}
+
+	closeClient()
+}
+
+// TestUnclosed checks that files left open when the client goes away
+// are saved.
+func TestUnclosed(t *testing.T) {
+	env, cli := setup(t)
+	defer env.Exit()
+	c, closeClient := newPipe(t, cli)
+
+	h, code := c.open("/"+owner+"/unclosed", fxfWrite|fxfCreat)
+	if code != fxOK {
+		t.Fatalf("open: status %d", code)
+	}
+	c.ok(fxpWrite, func(e *encoder) {
+		e.string(h)
+		e.uint64(0)
+		e.string("data")
+	})
+	closeClient()
+	if got, err := cli.Get(owner + "/unclosed"); err != nil || string(got) != "data" {
+		t.Errorf("Get = %q, %v", got, err)
+	}
+}
+
+func newSSHKey(t *testing.T) (ssh.Signer, []byte) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block, err := ssh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer, pem.EncodeToMemory(block)
+}
+
+func TestSSH(t *testing.T) {
+	env, cli := setup(t)
+	defer env.Exit()
+
+	_, hostKey := newSSHKey(t)
+	userKey, _ := newSSHKey(t)
+	otherKey, _ := newSSHKey(t)
+	authKeys := append([]byte("# A comment.\n"), ssh.MarshalAuthorizedKey(userKey.PublicKey())...)
+	sshCfg, err := serverConfig(hostKey, authKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go serve(ln, sshCfg, cli, owner)
+
+	dial := func(key ssh.Signer) (*ssh.Client, error) {
+		return ssh.Dial("tcp", ln.Addr().String(), &ssh.ClientConfig{
+			User:            "anyone",
+			Auth:            []ssh.AuthMethod{ssh.PublicKeys(key)},
+			HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		})
+	}
+
+	if _, err := dial(otherKey); err == nil {
+		t.Fatal("unauthorized key was accepted")
+	}
+
+	conn, err := dial(userKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	session, err := conn.NewSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer session.Close()
+	w, err := session.StdinPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := session.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		t.Fatal(err)
+	}
+	c := newSFTPClient(t, struct {
+		io.Reader
+		io.Writer
+	}{r, w})
+	if got := c.readFile("/" + owner + "/dir/file"); got != "hello, world" {
+		t.Errorf("read = %q", got)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// This file implements the saving of files written by SFTP clients.
+
+import (
+	"io"
+	"sort"
+
+	"upspin.io/client"
+	"upspin.io/upspin"
+)
+
+// maxPending is the most written data held waiting for the data before it.
+const maxPending = 1 << 24
+
+// A fileWriter saves the data written to an open file as it arrives,
+// streaming it to PutStream so the file is never held whole in memory.
+// Clients write more or less in order; writes beyond the data passed
+// on so far are held until the gap before them is filled by others or,
+// when the file is closed, from the file's old contents, of which only
+// the parts not overwritten are read. Writes before the data passed on
+// so far cannot be honored.
+type fileWriter struct {
+	old     upspin.File // The existing contents to keep, or nil.
+	oldSize int64
+
+	pw   *io.PipeWriter
+	done chan error // Receives the result of PutStream.
+
+	pos         int64    // Bytes passed to PutStream.
+	pending     []extent // Writes beyond pos, in order and disjoint.
+	pendingSize int
+}
+
+// An extent is data written at an offset.
+type extent struct {
+	off  int64
+	data []byte
+}
+
+// newFileWriter starts saving name with p. The parts of old, if not nil,
+// that are not overwritten are kept.
+func newFileWriter(p client.StreamPutter, name upspin.PathName, old upspin.File, oldSize int64) *fileWriter {
+	pr, pw := io.Pipe()
+	w := &fileWriter{
+		old:     old,
+		oldSize: oldSize,
+		pw:      pw,
+		done:    make(chan error, 1),
+	}
+	go func() {
+		_, err := p.PutStream(name, pr, client.PutOptions{})
+		// Unblock the writer should PutStream stop early.
+		pr.CloseWithError(err)
+		w.done <- err
+	}()
+	return w
+}
+
+// WriteAt records b as the data at off.
+func (w *fileWriter) WriteAt(b []byte, off int64) error {
+	if off < w.pos {
+		return upspin.ErrNotSupported
+	}
+	// The data arrives in a packet buffer; keep a copy.
+	w.insert(off, append([]byte(nil), b...))
+	return w.flush(false)
+}
+
+// insert adds the data at off to the pending writes, replacing any parts
+// of those it overlaps.
+func (w *fileWriter) insert(off int64, b []byte) {
+	end := off + int64(len(b))
+	p := []extent{{off, b}}
+	w.pendingSize = len(b)
+	for _, e := range w.pending {
+		eEnd := e.off + int64(len(e.data))
+		if eEnd <= off || e.off >= end {
+			p = append(p, e)
+			w.pendingSize += len(e.data)
+			continue
+		}
+		if e.off < off {
+			p = append(p, extent{e.off, e.data[:off-e.off]})
+			w.pendingSize += int(off - e.off)
+		}
+		if eEnd > end {
+			p = append(p, extent{end, e.data[end-e.off:]})
+			w.pendingSize += int(eEnd - end)
+		}
+	}
+	sort.Slice(p, func(i, j int) bool { return p[i].off < p[j].off })
+	w.pending = p
+}
+
+// flush passes on the pending writes that follow on from the data passed
+// so far or, if all is set or too much is pending, every one, filling the
+// gaps before them.
+func (w *fileWriter) flush(all bool) error {
+	for len(w.pending) > 0 {
+		e := w.pending[0]
+		if e.off > w.pos && !all && w.pendingSize <= maxPending {
+			break
+		}
+		if err := w.fill(e.off); err != nil {
+			return err
+		}
+		if _, err := w.pw.Write(e.data); err != nil {
+			return err
+		}
+		w.pos += int64(len(e.data))
+		w.pending = w.pending[1:]
+		w.pendingSize -= len(e.data)
+	}
+	return nil
+}
+
+// fill passes on the data from pos to off, taken from the old contents
+// of the file and, beyond their end, zeros.
+func (w *fileWriter) fill(off int64) error {
+	if w.old != nil && w.pos < w.oldSize {
+		end := off
+		if end > w.oldSize {
+			end = w.oldSize
+		}
+		n, err := io.Copy(w.pw, io.NewSectionReader(w.old, w.pos, end-w.pos))
+		w.pos += n
+		if err != nil {
+			return err
+		}
+	}
+	if w.pos < off {
+		n, err := io.CopyN(w.pw, zeros{}, off-w.pos)
+		w.pos += n
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close passes on the pending writes and the rest of the old contents,
+// and waits for PutStream to save the file.
+func (w *fileWriter) Close() error {
+	err := w.flush(true)
+	if err == nil && w.pos < w.oldSize {
+		err = w.fill(w.oldSize)
+	}
+	if w.old != nil {
+		w.old.Close()
+	}
+	if err != nil {
+		// Abandon the Put.
+		w.pw.CloseWithError(err)
+		<-w.done
+		return err
+	}
+	w.pw.Close()
+	return <-w.done
+}
+
+// zeros is an endless reader of zero bytes.
+type zeros struct{}
+
+func (zeros) Read(b []byte) (int, error) {
+	for i := range b {
+		b[i] = 0
+	}
+	return len(b), nil
+}
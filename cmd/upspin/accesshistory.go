@@ -0,0 +1,149 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"time"
+
+	"upspin.io/access"
+	"upspin.io/client/clientutil"
+	"upspin.io/errors"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+// accessHistoryIdle is how long access-history waits for further events
+// once it can no longer tell whether the history is complete.
+const accessHistoryIdle = 5 * time.Second
+
+func (s *State) accessHistory(args ...string) {
+	const help = `
+Access-history prints the past versions of an Access file, oldest
+first, with the sequence number, time and writer of each. The argument
+is either the Access file or the directory holding it.
+
+The history is recovered by watching the Access file from the start of
+the directory server's log, so it is complete only if the server has
+kept the log since the file was first written.
+
+The -restore flag puts the version with the given sequence number back
+as the current contents of the Access file.
+`
+	fs := flag.NewFlagSet("access-history", flag.ExitOnError)
+	restore := fs.Int64("restore", 0, "restore the version with this `sequence` number")
+	s.ParseFlags(fs, args, help, "access-history [-restore=sequence] path")
+	if fs.NArg() != 1 {
+		usageAndExit(fs)
+	}
+	name := upspin.PathName(s.AtSign(fs.Arg(0)))
+	parsed, err := path.Parse(name)
+	if err != nil {
+		s.Exit(err)
+	}
+	name = parsed.Path()
+	if !access.IsAccessFile(name) {
+		name = path.Join(name, access.AccessFile)
+	}
+
+	history := s.accessVersions(name)
+	if *restore == 0 {
+		for _, e := range history {
+			if e.Delete {
+				s.Printf("sequence %d: deleted\n", e.Entry.Sequence)
+				continue
+			}
+			s.Printf("sequence %d, %s, written by %s:\n", e.Entry.Sequence, e.Entry.Time, e.Entry.Writer)
+			data, err := clientutil.ReadAll(s.Config, e.Entry)
+			if err != nil {
+				s.Printf("\tcontents unavailable: %v\n", err)
+				continue
+			}
+			for _, line := range bytes.SplitAfter(data, []byte("\n")) {
+				if len(line) > 0 {
+					s.Printf("\t%s", line)
+				}
+			}
+			if len(data) > 0 && data[len(data)-1] != '\n' {
+				s.Printf("\n")
+			}
+		}
+		return
+	}
+
+	for _, e := range history {
+		if e.Entry.Sequence != *restore {
+			continue
+		}
+		if e.Delete {
+			s.Exitf("sequence %d of %s is a deletion", *restore, name)
+		}
+		data, err := clientutil.ReadAll(s.Config, e.Entry)
+		if err != nil {
+			s.Exit(err)
+		}
+		entry, err := s.Client.Put(name, data)
+		if err != nil {
+			s.Exit(err)
+		}
+		s.Printf("restored sequence %d of %s as sequence %d\n", *restore, name, entry.Sequence)
+		return
+	}
+	s.Exitf("no version of %s with sequence %d", name, *restore)
+}
+
+// accessVersions returns the events recorded for the named file by its
+// directory server, from the start of the server's log to the present.
+func (s *State) accessVersions(name upspin.PathName) []upspin.Event {
+	dir, err := s.Client.DirServer(name)
+	if err != nil {
+		s.Exit(err)
+	}
+	// The history is complete once it reaches the current version.
+	// If the file does not exist, there is no such marker, so stop
+	// once the server goes quiet.
+	last := int64(-1)
+	current, err := s.Client.Lookup(name, false)
+	if err == nil {
+		last = current.Sequence
+	} else if !errors.Is(errors.NotExist, err) {
+		s.Exit(err)
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	events, err := dir.Watch(name, upspin.WatchStart, done)
+	if err != nil {
+		s.Exit(err)
+	}
+	var history []upspin.Event
+	timer := time.NewTimer(accessHistoryIdle)
+	defer timer.Stop()
+	for {
+		select {
+		case e, ok := <-events:
+			if !ok {
+				return history
+			}
+			if e.Error != nil {
+				s.Exit(e.Error)
+			}
+			if e.Entry.Name != name {
+				continue
+			}
+			history = append(history, e)
+			if last >= 0 && e.Entry.Sequence >= last {
+				return history
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(accessHistoryIdle)
+		case <-timer.C:
+			return history
+		}
+	}
+}
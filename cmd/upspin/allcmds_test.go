@@ -5,6 +5,9 @@
 package main
 
 import (
+	"bytes"
+	"fmt"
+	"strings"
 	"testing"
 
 	"upspin.io/upspin"
@@ -123,6 +126,41 @@ var basicCmdTests = []cmdTest{
 		"r,l: all\n*:ann@example.com\n",
 		expect("r,l: all\n*:ann@example.com\n"),
 	},
+	{
+		"short listing",
+		ann,
+		do("ls @/Public"),
+		"",
+		expect("ann@example.com/Public/Access\n", "ann@example.com/Public/Photo/\n"),
+	},
+	{
+		"extended listing",
+		ann,
+		do("ls -e @/Public"),
+		"",
+		expect(
+			"ann@example.com/Public/Access:\n",
+			"\tname: ann@example.com/Public/Access\n",
+			"\tattr: none\n",
+			"\tpacking: ",
+			"\twriter: ann@example.com\n",
+			"\tsequence: ",
+			"\tsize: 27\n",
+			"\tblockbytes: 27\n",
+			"\tblocks: 1\n",
+			"\tblock 0: offset 0 size 27 location ",
+			"ann@example.com/Public/Photo:\n",
+			"\tattr: directory\n",
+			"\tblocks: 0\n",
+		),
+	},
+	{
+		"extended listing as JSON",
+		ann,
+		do("ls -e -json @/Public/Access"),
+		"",
+		expect(`"Writer": "ann@example.com"`, `"Name": "ann@example.com/Public/Access"`),
+	},
 	putFile(
 		ann,
 		"@/Friends/Photo/friends.jpg",
@@ -223,6 +261,21 @@ var basicCmdTests = []cmdTest{
 			"some stuff to save",
 		),
 	},
+	{
+		"put with attributes",
+		ann,
+		do(
+			"put -attr=content-type=text/plain -attr=note=a=b @/withattrs",
+			"info @/withattrs",
+			"get @/withattrs",
+		),
+		"some attributed stuff",
+		expect(
+			"attribute content-type:", `"text/plain"`,
+			"attribute note:", `"a=b"`,
+			"some attributed stuff",
+		),
+	},
 	{
 		"whichaccess",
 		ann,
@@ -263,6 +316,61 @@ var basicCmdTests = []cmdTest{
 		"",
 		expect("ann+snapshot@example.com/2"), // "/2" for "/2017" - or maybe later.
 	},
+	{
+		"schedule snapshots",
+		ann,
+		do(
+			"snapshot -schedule=6h",
+			"ls @+snapshot",
+		),
+		"",
+		expect("ann+snapshot@example.com/Schedule"),
+	},
+	{
+		"change tree after snapshot",
+		ann,
+		do(
+			"put @/notinsnapshot",
+			"rm @/Public/Photo/public.jpg",
+			"snapshot-restore -dry-run 2*/*/*/*",
+			"ls @/Public/Photo",
+		),
+		"new file",
+		expect(
+			"delete ann@example.com/notinsnapshot",
+			"put ann@example.com/Public/Photo/public.jpg",
+			"ann@example.com/Public/Photo/", // Dry run changed nothing.
+		),
+	},
+	{
+		"restore snapshot",
+		ann,
+		do(
+			"snapshot-restore 2*/*/*/*",
+			"get @/Public/Photo/public.jpg",
+			"ls @/",
+		),
+		"",
+		expect(
+			"delete ann@example.com/notinsnapshot",
+			"put ann@example.com/Public/Photo/public.jpg",
+			"this is public.jpg",
+		),
+	},
+	{
+		"restored file not in snapshot is gone",
+		ann,
+		do("ls @/notinsnapshot"),
+		"",
+		fail("item does not exist"),
+	},
+	{
+		"web link",
+		ann,
+		do("link -web -expires=1h -gateway=https://share.example.com/ @/Public/Photo/public.jpg"),
+		"",
+		expect("https://share.example.com/share/ann@example.com/Public/Photo/public.jpg?expires=", "&sig="),
+	},
 	{
 		"info on public file",
 		ann,
@@ -429,6 +537,24 @@ var cpTests = []cmdTest{
 		"this is @/cp/file new content",
 		expect("this is @/cp/file new content", "this is @/cp/subdir/file"),
 	},
+	{
+		"cp with exclude and include",
+		ann,
+		do(
+			"mkdir @/cp4",
+			"cp -R -exclude=sub* -exclude=f* -include=file @/cp/* @/cp4",
+			"get @/cp4/file",
+		),
+		"",
+		expect("this is @/cp/file new content"),
+	},
+	{
+		"excluded directory not copied",
+		ann,
+		do("ls @/cp4/subdir"),
+		"",
+		fail("item does not exist"),
+	},
 }
 
 // lsTests tests the ls command, in particular its handling of links.
@@ -521,6 +647,32 @@ var shareTests = []cmdTest{
 		"",
 		fail("no wrapped key for user"),
 	},
+	// Info shows that kelly@, in the group, may read it but has no key.
+	{
+		"info -user before share",
+		ann,
+		do(
+			"info -user=kelly@example.com @/Friends/Photo/friends.jpg",
+		),
+		"",
+		expect(
+			"rights of kelly@example.com:", "read list\n",
+			"key wrapped for kelly@example.com:", "no\n",
+			"share -fix needed:", "yes\n",
+		),
+	},
+	// The JSON report shows the wrapped keys are out of date.
+	{
+		"share -json reports inconsistency",
+		ann,
+		do(
+			"share -json -r @/Friends",
+		),
+		"",
+		expect(
+			`{"path":"ann@example.com/Friends/Photo/friends.jpg","readers":["ann@example.com","chris@example.com","kelly@example.com"],"writers":["ann@example.com"],"inconsistent":true}`,
+		),
+	},
 	// Do the share; that should fix it.
 	{
 		"ann shares @/Friends",
@@ -531,6 +683,30 @@ var shareTests = []cmdTest{
 		"",
 		expectNoOutput(),
 	},
+	{
+		"share -json after fix",
+		ann,
+		do(
+			"share -json -r @/Friends",
+		),
+		"",
+		expect(
+			`{"path":"ann@example.com/Friends/Photo/friends.jpg","readers":["ann@example.com","chris@example.com","kelly@example.com"],"writers":["ann@example.com"],"inconsistent":false}`,
+		),
+	},
+	{
+		"info -user after share",
+		ann,
+		do(
+			"info -user=kelly@example.com @/Friends/Photo/friends.jpg",
+		),
+		"",
+		expect(
+			"rights of kelly@example.com:", "read list\n",
+			"key wrapped for kelly@example.com:", "yes\n",
+			"share -fix needed:", "no\n",
+		),
+	},
 	// Now kelly@ can read it.
 	{
 		"kelly can read friends.jpg now",
@@ -655,3 +831,144 @@ var suffixedUserTests = []cmdTest{
 		expect("name: ann+quux@example.com", "dirs", "- remote,localhost", "stores", "- remote,localhost", "publickey"),
 	},
 }
+
+// The rotate tests rotate lee's key with rotate -all and check that
+// lee's files can still be read with the new key.
+var rotateTests = []cmdTest{
+	{
+		"lee makes a small tree",
+		lee,
+		do(
+			"mkdir lee@example.com",
+			"mkdir @/rotate",
+			"put @/rotate/file",
+			"get @/rotate/file",
+		),
+		"this file survives rotation",
+		expect("this file survives rotation"),
+	},
+	{
+		"rotate dry run",
+		lee,
+		do(
+			"rotate -all -dry-run",
+		),
+		"",
+		expect("step 1: keygen -rotate", "step 2: countersign", "step 3: rotate", "step 4: share -r -fix lee@example.com/"),
+	},
+	{
+		"rotate all",
+		lee,
+		do(
+			"rotate -all",
+		),
+		"",
+		rotated(),
+	},
+	{
+		"lee reads with the new key",
+		lee,
+		do(
+			"get @/rotate/file",
+		),
+		"",
+		expect("this file survives rotation"),
+	},
+	{
+		"rotate refuses a rotation to another key",
+		lee,
+		do(
+			"rotate -all",
+		),
+		"",
+		foreignRotation(),
+	},
+}
+
+// accessHistoryTests tests the access-history command. It depends on the
+// Access file history built by basicCmdTests and shareTests.
+var accessHistoryTests = []cmdTest{
+	{
+		"ann lists Friends Access history",
+		ann,
+		do(
+			"access-history @/Friends",
+		),
+		"",
+		expect(
+			"written by ann@example.com:\n",
+			"\tr,l: friends\n",
+			"written by ann@example.com:\n",
+			"\tr,l: friends lee@example.com\n",
+		),
+	},
+	{
+		"restore of missing version fails",
+		ann,
+		do(
+			"access-history -restore=1 @/Friends/Access",
+		),
+		"",
+		fail("no version of ann@example.com/Friends/Access with sequence 1"),
+	},
+}
+
+// getrefTests tests the getref command.
+var getrefTests = []cmdTest{
+	{
+		"getref all replicas",
+		ann,
+		do(
+			"put @/getref",
+		),
+		"this is ann@example.com/getref\n",
+		getrefVerify("ann@example.com/getref"),
+	},
+}
+
+// getrefVerify is a post function that checks that getref -all-replicas
+// finds the first block of the named file intact in the user's store.
+func getrefVerify(name upspin.PathName) func(t *testing.T, r *runner, cmd *cmdTest, stdout, stderr string) {
+	return func(t *testing.T, r *runner, cmd *cmdTest, stdout, stderr string) {
+		if stderr != "" {
+			t.Fatalf("%q: unexpected error:\n\t%q", cmd.name, stderr)
+		}
+		entry, err := r.state.Client.Lookup(name, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		loc := entry.Blocks[0].Location
+		out := new(bytes.Buffer)
+		r.state.SetIO(devNull{}, out, out)
+		defer r.state.DefaultIO()
+		r.runOne(t, "getref -all-replicas "+string(loc.Reference))
+		want := fmt.Sprintf("%s %s ", loc.Reference, loc.Endpoint)
+		if !strings.HasPrefix(out.String(), want) || !strings.HasSuffix(out.String(), " [OK]\n") {
+			t.Fatalf("%q: got %q, want a line starting %q and ending [OK]", cmd.name, out, want)
+		}
+	}
+}
+
+// The signup tests sign up a batch of users as the key server's user,
+// which may create users without email confirmation.
+var signupTests = []cmdTest{
+	{
+		"signup batch",
+		keyserverUser,
+		do(
+			"signup -batch=- -no-email -secrets-dir=" + testTempDir("signup", deleteOld),
+		),
+		"username,dir-server,store-server\n" +
+			"batch@example.com,dir.example.com,store.example.com\n" +
+			"lee@example.com,dir.example.com,store.example.com\n" +
+			"batch+suffix@example.com,dir.example.com,store.example.com\n",
+		signupBatchVerify(testTempDir("signup", keepOld), "batch@example.com"),
+	},
+	{
+		"user batch@example.com",
+		ann,
+		do("user batch@example.com"),
+		"",
+		expect("name: batch@example.com", "dirs", "- remote,dir.example.com:443", "stores", "- remote,store.example.com:443", "publickey"),
+	},
+}
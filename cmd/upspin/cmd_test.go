@@ -14,6 +14,7 @@ import (
 	"strings"
 	"testing"
 
+	"upspin.io/rpc"
 	"upspin.io/upbox"
 	"upspin.io/upspin"
 )
@@ -25,7 +26,11 @@ var allCmdTests = []*[]cmdTest{
 	&keygenTests,
 	&lsTests,
 	&shareTests,
+	&accessHistoryTests,
+	&getrefTests,
 	&suffixedUserTests,
+	&rotateTests,
+	&signupTests,
 }
 
 // TestCommands runs the tests defined in cmdTests as subtests.
@@ -58,10 +63,50 @@ func TestCommands(t *testing.T) {
 		}
 	}
 
+	t.Run("timing", func(t *testing.T) { testTiming(t, schema) })
+	t.Run("shell", func(t *testing.T) { testShell(t, schema) })
+	t.Run("config check", func(t *testing.T) { testConfigCheck(t, schema) })
+
 	// Tear down upbox.
 	schema.Stop()
 }
 
+// testTiming runs commands with the -timing flag and checks the summary.
+func testTiming(t *testing.T, schema *upbox.Schema) {
+	r := &runner{
+		fs:     flag.NewFlagSet("timing", flag.PanicOnError),
+		schema: schema,
+	}
+	state, _, ok := setup(r.fs, []string{"-timing", "-config=" + r.config(ann), "test"})
+	if !ok {
+		t.Fatal("setup failed; bad arg list?")
+	}
+	defer rpc.ObserveCalls(nil)
+	r.state = state
+	if state.timings == nil {
+		t.Fatal("-timing did not start timing")
+	}
+	r.run(&cmdTest{
+		name: "timing",
+		user: ann,
+		cmds: do(
+			"put ann@example.com/timing",
+			"get ann@example.com/timing",
+		),
+		stdin: "timed",
+		post:  expect("timed"),
+	})(t)
+
+	var buf bytes.Buffer
+	state.timings.write(&buf)
+	summary := buf.String()
+	for _, method := range []string{"METHOD", "Dir/Lookup", "Dir/Put", "Store/Put", "Store/Get"} {
+		if !strings.Contains(summary, "\n"+method+" ") && !strings.HasPrefix(summary, method+" ") {
+			t.Errorf("summary has no %s row:\n%s", method, summary)
+		}
+	}
+}
+
 // TODO: Loop over server implementations?
 
 const upboxSchema = `
@@ -84,6 +129,9 @@ const (
 	chris = upspin.UserName("chris@example.com")
 	kelly = upspin.UserName("kelly@example.com")
 	lee   = upspin.UserName("lee@example.com")
+
+	// keyserverUser is the user of the key server, created by upbox.
+	keyserverUser = upspin.UserName("keyserver@example.com")
 )
 
 // devNull gives EOF on read and absorbs anything error-free on write, like Unix's /dev/null.
@@ -295,6 +343,38 @@ func keyVerify(t *testing.T, name, prefix string) {
 	}
 }
 
+// signupBatchVerify is a post function for signup -batch. It verifies
+// the report and that the config and keys of the new user were written
+// to a private subdirectory of dir.
+func signupBatchVerify(dir string, newUser upspin.UserName) func(t *testing.T, r *runner, cmd *cmdTest, stdout, stderr string) {
+	return func(t *testing.T, r *runner, cmd *cmdTest, stdout, stderr string) {
+		want := "username,result,error\n" +
+			"batch@example.com,success,\n" +
+			"lee@example.com,already-exists,\n" +
+			"batch+suffix@example.com,error,name must not include a +suffix\n"
+		if stdout != want || stderr != "" {
+			t.Fatalf("stdout:\n%s\nstderr:\n%s\nwant stdout:\n%s", stdout, stderr, want)
+		}
+		where := filepath.Join(dir, string(newUser))
+		info, err := os.Stat(where)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0700 {
+			t.Errorf("%s has mode %o, want 0700", where, perm)
+		}
+		cfg, err := os.ReadFile(filepath.Join(where, "config"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(cfg), "username: "+string(newUser)) {
+			t.Errorf("config does not name %s:\n%s", newUser, cfg)
+		}
+		keyVerify(t, filepath.Join(where, "public.upspinkey"), "p256")
+		keyVerify(t, filepath.Join(where, "secret.upspinkey"), "")
+	}
+}
+
 // suffixedUserExists is a post function. It returns a function that ensures that a
 // config file and key files exist for the suffixed user.
 func suffixedUserExists(user, suffix string) func(t *testing.T, r *runner, cmd *cmdTest, stdout, stderr string) {
@@ -320,3 +400,42 @@ func suffixedUserExists(user, suffix string) func(t *testing.T, r *runner, cmd *
 		}
 	}
 }
+
+// rotated is a post function for rotate -all. It verifies that the
+// rotation completed, that the key server holds the new key, and then
+// plants a rotation state file for a different key for the next test.
+func rotated() func(t *testing.T, r *runner, cmd *cmdTest, stdout, stderr string) {
+	return func(t *testing.T, r *runner, cmd *cmdTest, stdout, stderr string) {
+		if !strings.Contains(stderr, "Key rotation complete.") || strings.Contains(stderr, "upspin: ") {
+			t.Fatalf("%q: rotation failed:\n%s", cmd.name, stderr)
+		}
+		dir := r.state.Config.Value("secrets")
+		stateFile := filepath.Join(dir, rotateStateFile)
+		if _, err := os.Stat(stateFile); !os.IsNotExist(err) {
+			t.Errorf("%q: state file remains after rotation: %v", cmd.name, err)
+		}
+		public, err := os.ReadFile(filepath.Join(dir, "public.upspinkey"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		u, err := r.state.KeyServer().Lookup(cmd.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(u.PublicKey) != string(public) {
+			t.Errorf("%q: key server has key %q, want %q", cmd.name, u.PublicKey, public)
+		}
+		if err := os.WriteFile(stateFile, []byte(`{"OldKey":"a","NewKey":"b","Done":2}`), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// foreignRotation is a post function that verifies that rotate -all
+// refused to continue the rotation planted by rotated, and removes it.
+func foreignRotation() func(t *testing.T, r *runner, cmd *cmdTest, stdout, stderr string) {
+	return func(t *testing.T, r *runner, cmd *cmdTest, stdout, stderr string) {
+		fail("incomplete rotation to a different key")(t, r, cmd, stdout, stderr)
+		os.Remove(filepath.Join(r.state.Config.Value("secrets"), rotateStateFile))
+	}
+}
@@ -4,7 +4,20 @@
 
 package main
 
-import "flag"
+import (
+	"bytes"
+	"flag"
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+
+	"upspin.io/bind"
+	"upspin.io/config"
+	"upspin.io/errors"
+	"upspin.io/flags"
+	"upspin.io/transports"
+	"upspin.io/upspin"
+)
 
 func (s *State) config(args ...string) {
 	const help = `
@@ -13,10 +26,203 @@ Config prints to standard output the contents of the current config file.
 It works by saving the file at initialization time, so if the actual
 file has changed since the command started, it will still show the
 configuration being used.
+
+The -check flag instead checks that the configuration works. It checks
+that the file is valid, that the user's keys can be loaded and match
+those the key server holds for the user, that the key, directory and
+store servers can be reached, that the directory server is the one the
+key server records for the user, and that the user's root exists. It
+prints PASS, FAIL, or WARN and a description for each check, with a
+hint of how to fix the problem for those that do not pass, and exits
+with a non-zero status if any check fails.
 `
 	fs := flag.NewFlagSet("config", flag.ExitOnError)
 	outFile := fs.String("out", "", "output file (default standard output)")
-	s.ParseFlags(fs, args, help, "config [-out=outputfile]")
+	check := fs.Bool("check", false, "check the configuration rather than print it")
+	s.ParseFlags(fs, args, help, "config [-out=outputfile | -check]")
 
+	if *check {
+		if *outFile != "" {
+			s.Exitf("-out cannot be used with -check")
+		}
+		failed := false
+		for _, c := range checkConfig(s.configFile) {
+			c.print(s)
+			if c.status == checkFail {
+				failed = true
+			}
+		}
+		if failed {
+			s.ExitCode = 1
+		}
+		return
+	}
+	if s.configErr != nil {
+		s.Exit(s.configErr)
+	}
 	s.writeOut(*outFile, s.configFile)
 }
+
+// Results of a configuration check.
+const (
+	checkPass = "PASS"
+	checkFail = "FAIL"
+	checkWarn = "WARN" // A problem, but perhaps expected.
+)
+
+// configCheck is the result of one check made by config -check.
+type configCheck struct {
+	status string
+	name   string
+	detail string // What was found or went wrong.
+	hint   string // How to fix a problem.
+}
+
+func (c configCheck) print(s *State) {
+	s.Printf("%s %s: %s\n", c.status, c.name, c.detail)
+	if c.hint != "" && c.status != checkPass {
+		s.Printf("\thint: %s\n", c.hint)
+	}
+}
+
+func pass(name, format string, args ...interface{}) configCheck {
+	return configCheck{status: checkPass, name: name, detail: fmt.Sprintf(format, args...)}
+}
+
+func failed(name string, err error, hint string) configCheck {
+	return configCheck{status: checkFail, name: name, detail: err.Error(), hint: hint}
+}
+
+// checkConfig checks the configuration file with the given contents.
+// Checks that depend on one that failed are not made.
+func checkConfig(data []byte) []configCheck {
+	var checks []configCheck
+	cfg, err := config.InitConfig(bytes.NewReader(data))
+	if err != nil && err != config.ErrNoFactotum && !secretsOnlyError(data) {
+		return append(checks, failed("config", err, "correct the config file "+flags.Config))
+	}
+	checks = append(checks, pass("config", "valid"))
+	if cfg == nil || cfg.Factotum() == nil {
+		if err == nil || err == config.ErrNoFactotum {
+			err = errors.Str("no secrets")
+		}
+		return append(checks, failed("secrets", err, "set secrets in the config to the directory holding the keys made by 'upspin keygen' or 'upspin signup'"))
+	}
+	checks = append(checks, pass("secrets", "keys loaded"))
+	transports.Init(cfg)
+
+	// Key server and the user's record.
+	me := cfg.UserName()
+	keyName := fmt.Sprintf("keyserver %s", cfg.KeyEndpoint())
+	key, err := bind.KeyServer(cfg, cfg.KeyEndpoint())
+	if err != nil {
+		return append(checks, failed(keyName, err, "correct keyserver in the config"))
+	}
+	record, err := key.Lookup(me)
+	switch {
+	case errors.Is(errors.NotExist, err):
+		return append(checks,
+			pass(keyName, "reachable"),
+			failed("user", err, "register "+string(me)+" with 'upspin signup'"))
+	case err != nil:
+		return append(checks, failed(keyName, err, "correct keyserver in the config, or check the network"))
+	}
+	checks = append(checks, pass(keyName, "reachable"))
+	if record.PublicKey != cfg.Factotum().PublicKey() {
+		checks = append(checks, failed("public key", errors.Errorf("key server holds a different public key for %s", me),
+			"use the secrets for "+string(me)+", or if they were replaced, register the new key with 'upspin keygen -rotate' and 'upspin rotate'"))
+	} else {
+		checks = append(checks, pass("public key", "matches the key server"))
+	}
+
+	// Directory servers.
+	dirs := []upspin.Endpoint{cfg.DirEndpoint()}
+	if r, ok := cfg.(upspin.DirReplicaConfig); ok {
+		dirs = r.DirEndpoints()
+	}
+	for _, e := range dirs {
+		name := fmt.Sprintf("dirserver %s", e)
+		if e.Transport == upspin.Unassigned {
+			checks = append(checks, failed(name, errors.Str("not set"), "set dirserver in the config"))
+			continue
+		}
+		dir, err := bind.DirServer(cfg, e)
+		if err == nil {
+			_, err = dir.Lookup(upspin.PathName(me) + "/")
+		}
+		if err != nil && !serverReplied(err) {
+			checks = append(checks, failed(name, err, "correct dirserver in the config, or check the network"))
+			continue
+		}
+		if !hasEndpoint(record.Dirs, e) {
+			checks = append(checks, failed(name, errors.Errorf("not the directory server the key server records for %s (%v)", me, record.Dirs),
+				"correct dirserver in the config, or update the key server with 'upspin user -put'"))
+			continue
+		}
+		checks = append(checks, pass(name, "reachable"))
+	}
+
+	// Store server.
+	storeName := fmt.Sprintf("storeserver %s", cfg.StoreEndpoint())
+	if cfg.StoreEndpoint().Transport == upspin.Unassigned {
+		checks = append(checks, configCheck{status: checkWarn, name: storeName, detail: "not set; files cannot be written", hint: "set storeserver in the config"})
+	} else {
+		store, err := bind.StoreServer(cfg, cfg.StoreEndpoint())
+		if err == nil {
+			_, _, _, err = store.Get(upspin.HealthMetadata)
+		}
+		if err != nil && !serverReplied(err) {
+			checks = append(checks, failed(storeName, err, "correct storeserver in the config, or check the network"))
+		} else {
+			checks = append(checks, pass(storeName, "reachable"))
+		}
+	}
+
+	// The user's root, wherever the key server says it is.
+	root := upspin.PathName(me) + "/"
+	dir, err := bind.DirServerFor(cfg, me)
+	if err == nil {
+		_, err = dir.Lookup(root)
+	}
+	switch {
+	case err == nil:
+		checks = append(checks, pass("root", "%s exists", root))
+	case errors.Is(errors.NotExist, err):
+		checks = append(checks, configCheck{status: checkWarn, name: "root", detail: fmt.Sprintf("%s does not exist yet", root),
+			hint: "create it with 'upspin mkdir " + string(root) + "', or 'upspin setupwriters' for a new server"})
+	default:
+		checks = append(checks, failed("root", err, "check that the directory servers recorded for "+string(me)+" are running"))
+	}
+	return checks
+}
+
+// secretsOnlyError reports whether the config data is valid but for its
+// secrets, by checking it again with none.
+func secretsOnlyError(data []byte) bool {
+	vals := make(map[string]interface{})
+	if err := yaml.Unmarshal(data, vals); err != nil {
+		return false
+	}
+	vals["secrets"] = "none"
+	data, err := yaml.Marshal(vals)
+	if err != nil {
+		return false
+	}
+	_, err = config.InitConfig(bytes.NewReader(data))
+	return err == config.ErrNoFactotum
+}
+
+// serverReplied reports whether err is a reply from a server, rather than
+// a failure to reach it.
+func serverReplied(err error) bool {
+	return errors.Is(errors.NotExist, err) || errors.Is(errors.Private, err) || errors.Is(errors.Permission, err)
+}
+
+func hasEndpoint(list []upspin.Endpoint, e upspin.Endpoint) bool {
+	for _, x := range list {
+		if x == e {
+			return true
+		}
+	}
+	return false
+}
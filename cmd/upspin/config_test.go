@@ -0,0 +1,82 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"upspin.io/upbox"
+)
+
+// testConfigCheck runs config -check against working and broken variants
+// of ann's config.
+func testConfigCheck(t *testing.T, schema *upbox.Schema) {
+	data, err := os.ReadFile(schema.Config(string(ann)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := string(data)
+	// replace replaces the line of the config with the given key.
+	replace := func(key, line string) string {
+		re := regexp.MustCompile("(?m)^" + key + ":.*$")
+		if !re.MatchString(good) {
+			t.Fatalf("config has no %s:\n%s", key, good)
+		}
+		return re.ReplaceAllString(good, line)
+	}
+	dir := t.TempDir()
+	tests := []struct {
+		name   string
+		config string
+		fail   string // Name of the check that should fail, or empty.
+	}{
+		{"good", good, ""},
+		{"bad packing", replace("packing", "packing: nosuchpacking"), "config"},
+		{"no secrets", replace("secrets", "secrets: "+filepath.Join(dir, "nosuchdir")), "secrets"},
+		{"wrong key", replace("secrets", "secrets: "+filepath.Join(schema.Dir, string(chris))), "public key"},
+		{"bad dirserver", replace("dirserver", "dirserver: remote,localhost:1"), "dirserver remote,localhost:1"},
+	}
+	for _, test := range tests {
+		file := filepath.Join(dir, strings.Replace(test.name, " ", "", -1))
+		if err := os.WriteFile(file, []byte(test.config), 0644); err != nil {
+			t.Fatal(err)
+		}
+		fs := flag.NewFlagSet(test.name, flag.PanicOnError)
+		state, _, ok := setup(fs, []string{"-config=" + file, "config"})
+		if !ok {
+			t.Fatal("setup failed; bad arg list?")
+		}
+		var stdout, stderr bytes.Buffer
+		state.SetIO(nil, &stdout, &stderr)
+		state.run([]string{"config", "-check"})
+		out := stdout.String()
+		if test.fail == "" {
+			if state.ExitCode != 0 || strings.Contains(out, checkFail) {
+				t.Errorf("%s: exit code %d, output:\n%s%s", test.name, state.ExitCode, out, stderr.String())
+			}
+			for _, check := range []string{"config", "secrets", "public key", "root"} {
+				if !strings.Contains(out, checkPass+" "+check+":") {
+					t.Errorf("%s: %s check did not pass:\n%s", test.name, check, out)
+				}
+			}
+			continue
+		}
+		if state.ExitCode == 0 {
+			t.Errorf("%s: exit code 0, output:\n%s", test.name, out)
+		}
+		if !strings.Contains(out, checkFail+" "+test.fail+":") {
+			t.Errorf("%s: %s check did not fail:\n%s", test.name, test.fail, out)
+		}
+		if !strings.Contains(out, "\thint: ") {
+			t.Errorf("%s: no hint:\n%s", test.name, out)
+		}
+	}
+}
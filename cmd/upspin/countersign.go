@@ -10,6 +10,7 @@ import (
 	"flag"
 
 	"upspin.io/config"
+	"upspin.io/factotum"
 	"upspin.io/upspin"
 )
 
@@ -50,11 +51,18 @@ func (s *State) countersignCommand(fs *flag.FlagSet) {
 }
 
 // countersign adds a second signature using factotum.
+// The entry may have been signed by any of the old keys,
+// so each is tried in turn, newest first.
 func (c *Countersigner) countersign(entry *upspin.DirEntry) {
 	packer := c.oState.lookupPacker(entry)
 	newF := c.nState.Config.Factotum()
-	oldKey := c.oState.Config.Factotum().PublicKey()
-	err := packer.Countersign(oldKey, newF, entry)
+	var err error
+	for _, oldKey := range factotum.Keys(c.oState.Config.Factotum()) {
+		err = packer.Countersign(oldKey, newF, entry)
+		if err == nil {
+			break
+		}
+	}
 	if err != nil {
 		c.nState.Fail(err)
 		return
@@ -5,15 +5,20 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/json"
 	"flag"
+	"fmt"
 	"io"
 	"log"
 	"os"
 	"path/filepath"
 	"strings"
 
+	"upspin.io/client"
 	"upspin.io/config"
 	"upspin.io/errors"
+	"upspin.io/flags"
 	"upspin.io/path"
 	"upspin.io/subcmd"
 	"upspin.io/upspin"
@@ -38,11 +43,36 @@ they must be absolute paths or start with '.', '..',  or '~'.
 When copying from one Upspin path to another Upspin path, cp can be
 very efficient, copying only the references to the data rather than
 the data itself.
+
+A copy of a local file into Upspin can be resumed: if it fails, for
+instance because the network connection is lost, repeating it stores
+only the blocks that were not already stored. The progress is recorded
+in a file under the cache directory, which is removed once the copy
+is complete and is ignored if the local file changes.
+
+The -sparse flag saves uploading and storing the blocks of files
+copied into Upspin that consist entirely of zero bytes, as in disk
+images and other sparse files. Such blocks are recorded in the
+directory entry and read back as zeros without contacting the store.
+
+The -exclude flag, which may be repeated, skips files and directories
+whose base names match the glob pattern, such as '*.pyc' or '.git'.
+The -include flag makes an exception to a preceding -exclude, as in
+-exclude='*.pyc' -include='keep.pyc'; when several patterns match a
+name, the last one given decides. The -exclude-from flag reads
+patterns from a local file, one per line, in the style of .gitignore:
+blank lines and lines beginning with '#' are ignored, a leading '!'
+marks an include pattern, and a trailing '/' is dropped.
 `
 	fs := flag.NewFlagSet("cp", flag.ExitOnError)
 	verbose := fs.Bool("v", false, "log each file as it is copied")
 	recur := fs.Bool("R", false, "recursively copy directories")
 	overwrite := fs.Bool("overwrite", true, "overwrite existing files")
+	sparse := fs.Bool("sparse", false, "do not store blocks that are all zeros")
+	filter := new(cpFilter)
+	fs.Var(filterFlag{filter, false, false}, "exclude", "skip files whose base name matches `pattern`; may be repeated")
+	fs.Var(filterFlag{filter, true, false}, "include", "copy files whose base name matches `pattern` despite a preceding -exclude")
+	fs.Var(filterFlag{filter, false, true}, "exclude-from", "read exclude patterns from the local `file`")
 	s.ParseFlags(fs, args, help, "cp [opts] file... file or cp [opts] file... directory")
 
 	var err error
@@ -58,7 +88,9 @@ the data itself.
 		flagSet:   fs,
 		overwrite: *overwrite,
 		recur:     *recur,
+		sparse:    *sparse,
 		verbose:   *verbose,
+		filter:    filter,
 	}
 
 	// Do all the glob processing here.
@@ -82,7 +114,79 @@ type copyState struct {
 	flagSet   *flag.FlagSet // Used only to call Usage.
 	overwrite bool
 	recur     bool
+	sparse    bool
 	verbose   bool
+	filter    *cpFilter
+}
+
+// cpFilter holds the patterns given by the -exclude, -include and
+// -exclude-from flags, in order.
+type cpFilter struct {
+	rules []cpRule
+}
+
+type cpRule struct {
+	pattern string
+	include bool
+}
+
+// excluded reports whether the file with the given base name should be
+// skipped. The last matching rule decides; by default files are copied.
+func (f *cpFilter) excluded(name string) bool {
+	exclude := false
+	for _, r := range f.rules {
+		// Patterns were checked when added, so Match cannot fail.
+		if ok, _ := filepath.Match(r.pattern, name); ok {
+			exclude = !r.include
+		}
+	}
+	return exclude
+}
+
+func (f *cpFilter) add(pattern string, include bool) error {
+	if _, err := filepath.Match(pattern, ""); err != nil {
+		return errors.Errorf("bad pattern %q: %v", pattern, err)
+	}
+	f.rules = append(f.rules, cpRule{pattern: pattern, include: include})
+	return nil
+}
+
+// filterFlag implements flag.Value for the -exclude, -include and
+// -exclude-from flags. All three add to the same cpFilter so that the
+// order of the flags is kept.
+type filterFlag struct {
+	f        *cpFilter
+	include  bool
+	fromFile bool
+}
+
+// String implements flag.Value.
+func (ff filterFlag) String() string {
+	return ""
+}
+
+// Set implements flag.Value.
+func (ff filterFlag) Set(s string) error {
+	if !ff.fromFile {
+		return ff.f.add(s, ff.include)
+	}
+	data, err := os.ReadFile(subcmd.Tilde(s))
+	if err != nil {
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		include := strings.HasPrefix(line, "!")
+		line = strings.TrimPrefix(line, "!")
+		line = strings.TrimSuffix(line, "/")
+		if err := ff.f.add(line, include); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (c *copyState) logf(format string, args ...interface{}) {
@@ -182,6 +286,10 @@ func (s *State) create(file cpFile) (io.WriteCloser, error) {
 // It recurs if -R is set and a source is a subdirectory.
 func (s *State) copyToDir(cs *copyState, src []cpFile, dir cpFile) {
 	for _, from := range src {
+		if cs.filter.excluded(filepath.Base(from.path)) {
+			cs.logf("exclude %s", from.path)
+			continue
+		}
 		dstPath := path.Join(upspin.PathName(dir.path), filepath.Base(from.path))
 		if dir.isUpspin && from.isUpspin {
 			// Try a fast copy. It can fail but that's OK.
@@ -253,6 +361,15 @@ func (s *State) copyToFile(cs *copyState, reader io.ReadCloser, src, dst cpFile)
 		}
 		s.Fail(err) // Failed at fastCopy; but try normal copy.
 	}
+	f, isFile := reader.(*os.File)
+	if p, ok := s.Client.(client.StreamPutter); ok && isFile && dst.isUpspin {
+		cs.localCopy(p, f, upspin.PathName(dst.path))
+		return
+	}
+	if p, ok := s.Client.(client.StreamPutter); ok && cs.sparse && dst.isUpspin {
+		cs.sparseCopy(p, reader, upspin.PathName(dst.path))
+		return
+	}
 	writer, err := s.create(dst)
 	if err != nil {
 		s.Fail(err)
@@ -298,6 +415,88 @@ func (cs *copyState) doCopy(reader io.ReadCloser, writer io.WriteCloser) {
 	}
 }
 
+func (cs *copyState) sparseCopy(p client.StreamPutter, reader io.ReadCloser, dst upspin.PathName) {
+	defer reader.Close()
+	if _, err := p.PutStream(dst, reader, client.PutOptions{Sparse: true}); err != nil {
+		cs.state.Fail(err)
+	}
+}
+
+// localCopy copies the local file f into Upspin as dst, resuming an
+// earlier attempt at the copy if there is one.
+func (cs *copyState) localCopy(p client.StreamPutter, f *os.File, dst upspin.PathName) {
+	defer f.Close()
+	dir := filepath.Join(flags.CacheDir, string(cs.state.Config.UserName()), "cp")
+	if err := resumablePut(p, f, dst, cs.sparse, dir); err != nil {
+		cs.state.Fail(err)
+	}
+}
+
+// cpManifest records the progress of a copy of a local file into Upspin
+// so that, if the copy fails, it can be resumed.
+type cpManifest struct {
+	BlockSize int
+	Upload    client.Upload
+}
+
+// resumablePut puts the contents of the local file f as dst. If they
+// span several blocks, the progress is recorded in a manifest in dir,
+// which is used to resume the Put should it fail and a later one copy
+// the same file to the same place. The client starts the upload afresh
+// if the file's contents have changed since.
+func resumablePut(p client.StreamPutter, f *os.File, dst upspin.PathName, sparse bool, dir string) error {
+	opts := client.PutOptions{Sparse: sparse}
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() <= int64(flags.BlockSize) {
+		_, err := p.PutStream(dst, f, opts)
+		return err
+	}
+
+	// The manifest is named for the source and destination, so that a
+	// copy of a changed source replaces the manifest of the original.
+	abs, err := filepath.Abs(f.Name())
+	if err != nil {
+		return err
+	}
+	file := filepath.Join(dir, fmt.Sprintf("%x", sha256.Sum256([]byte(abs+"\x00"+string(dst)))))
+	m := &cpManifest{
+		BlockSize: flags.BlockSize,
+	}
+	if b, err := os.ReadFile(file); err == nil {
+		var old cpManifest
+		if json.Unmarshal(b, &old) == nil && old.BlockSize == m.BlockSize {
+			m = &old
+		}
+	}
+	m.Upload.Save = func(*client.Upload) error {
+		return m.save(file)
+	}
+	opts.Upload = &m.Upload
+	if _, err := p.PutStream(dst, f, opts); err != nil {
+		return err
+	}
+	return os.Remove(file)
+}
+
+// save writes the manifest to file, replacing its previous contents.
+func (m *cpManifest) save(file string) error {
+	b, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
+
 // isLocal reports whether the argument names a fully-qualified local file.
 // TODO: This is Unix-specific.
 func isLocal(file string) bool {
@@ -0,0 +1,157 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"upspin.io/client"
+	"upspin.io/errors"
+	"upspin.io/flags"
+	"upspin.io/upspin"
+)
+
+// fakeUploader is a client.StreamPutter that, like the client, stores data in
+// blocks of flags.BlockSize, records them in the Upload of the options
+// and does not store again those recorded as stored by an Upload of the
+// same data to the same name. Its store fails once limit Puts have
+// succeeded, if limit is not negative.
+type fakeUploader struct {
+	limit  int
+	puts   int // Number of successful Puts.
+	blocks map[upspin.Reference]bool
+	files  map[upspin.PathName][]byte
+}
+
+func (f *fakeUploader) reset(limit int) {
+	f.limit = limit
+	f.puts = 0
+}
+
+func (f *fakeUploader) PutStream(name upspin.PathName, r io.Reader, opts client.PutOptions) (*upspin.DirEntry, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	u := opts.Upload
+	if source := sha256.Sum256(data); u != nil && (u.Name != name || !bytes.Equal(u.Source, source[:])) {
+		*u = client.Upload{Name: name, Source: source[:], Save: u.Save}
+	}
+	for i := 0; i*flags.BlockSize < len(data); i++ {
+		block := data[i*flags.BlockSize:]
+		if len(block) > flags.BlockSize {
+			block = block[:flags.BlockSize]
+		}
+		sum := sha256.Sum256(block)
+		ref := upspin.Reference(fmt.Sprintf("%x", sum))
+		if u != nil && i < len(u.Blocks) && u.Blocks[i].Reference == ref && f.blocks[ref] {
+			continue
+		}
+		if f.limit >= 0 && f.puts >= f.limit {
+			return nil, errors.E(errors.IO, errors.Str("injected failure"))
+		}
+		f.puts++
+		f.blocks[ref] = true
+		if u == nil {
+			continue
+		}
+		for len(u.Blocks) <= i {
+			u.Blocks = append(u.Blocks, client.UploadedBlock{})
+		}
+		u.Blocks[i] = client.UploadedBlock{Reference: ref, Sum: sum[:]}
+		if err := u.Save(u); err != nil {
+			return nil, err
+		}
+	}
+	f.files[name] = append([]byte(nil), data...)
+	return &upspin.DirEntry{Name: name}, nil
+}
+
+func TestResumablePut(t *testing.T) {
+	const (
+		user   = upspin.UserName("cp@example.com")
+		dst    = upspin.PathName(user + "/file")
+		blocks = 10
+		failAt = 4
+	)
+	blockSize := flags.BlockSize
+	defer func() { flags.BlockSize = blockSize }()
+	flags.BlockSize = 1024
+	c := &fakeUploader{
+		blocks: make(map[upspin.Reference]bool),
+		files:  make(map[upspin.PathName][]byte),
+	}
+	dir := t.TempDir()
+	src := filepath.Join(dir, "src")
+	manifests := filepath.Join(dir, "cp")
+	data := make([]byte, blocks*1024)
+	for i := range data {
+		data[i] = byte(i * 13)
+	}
+
+	put := func() error {
+		t.Helper()
+		if err := os.WriteFile(src, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		f, err := os.Open(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		return resumablePut(c, f, dst, false, manifests)
+	}
+	checkManifests := func(want int) {
+		t.Helper()
+		files, _ := filepath.Glob(filepath.Join(manifests, "*"))
+		if len(files) != want {
+			t.Fatalf("%d manifests %q, want %d", len(files), files, want)
+		}
+	}
+
+	// The first attempt is interrupted and leaves a manifest.
+	c.reset(failAt)
+	if err := put(); !errors.Is(errors.IO, err) {
+		t.Fatalf("first copy: err = %v, want IO error", err)
+	}
+	checkManifests(1)
+
+	// The second completes the copy, storing only the remaining blocks.
+	c.reset(-1)
+	if err := put(); err != nil {
+		t.Fatal(err)
+	}
+	if c.puts != blocks-failAt {
+		t.Errorf("resumed copy stored %d blocks, want %d", c.puts, blocks-failAt)
+	}
+	if !bytes.Equal(c.files[dst], data) {
+		t.Error("copied data does not match")
+	}
+	checkManifests(0)
+
+	// A copy of a changed source starts again.
+	c.reset(failAt)
+	if err := put(); !errors.Is(errors.IO, err) {
+		t.Fatalf("copy: err = %v, want IO error", err)
+	}
+	data[len(data)-1]++
+	c.reset(-1)
+	if err := put(); err != nil {
+		t.Fatal(err)
+	}
+	if c.puts != blocks {
+		t.Errorf("copy of changed source stored %d blocks, want %d", c.puts, blocks)
+	}
+	if !bytes.Equal(c.files[dst], data) {
+		t.Error("copied data does not match changed source")
+	}
+	checkManifests(0)
+}
@@ -48,6 +48,7 @@ Usage of upspin:
 	upspin [globalflags] <command> [flags] <path>
 Upspin commands:
 	shell (Interactive mode)
+	access-history
 	config
 	countersign
 	cp
@@ -62,34 +63,68 @@ Upspin commands:
 	mkdir
 	put
 	repack
+	revokekey
 	rm
 	rotate
 	setupdomain
 	setupserver
 	setupstorage
+	setupsystemd
 	setupwriters
 	share
 	signup
 	snapshot
+	snapshot-restore
 	tar
+	transfer-domain
 	user
 	watch
 	whichaccess
 Global flags:
+  -blockcache bytes
+    	maximum bytes of recently read blocks to keep in memory (default 268435456)
   -blocksize size
     	size of blocks when writing large files (default 1048576)
   -config file
     	user's configuration file (default "/home/user/upspin/config")
   -log level
-    	level of logging: debug, info, error, disabled (default info)
+    	level of logging: debug, info, error, disabled; may be followed by
+    	subsystem=level pairs, as in info,dir/server=debug (default info)
   -prudent
     	protect against malicious directory server
+  -putconcurrency number
+    	number of blocks to store in parallel when writing a file (default 4)
+  -timing
+    	print a summary of RPC times to standard error on exit
   -version
     	print build version and exit
   -writethrough
     	make storage cache writethrough
 
 
+Sub-command access-history
+
+Usage: upspin access-history [-restore=sequence] path
+
+Access-history prints the past versions of an Access file, oldest
+first, with the sequence number, time and writer of each. The argument
+is either the Access file or the directory holding it.
+
+The history is recovered by watching the Access file from the start of
+the directory server's log, so it is complete only if the server has
+kept the log since the file was first written.
+
+The -restore flag puts the version with the given sequence number back
+as the current contents of the Access file.
+
+Flags:
+  -help
+    	print more information about the command
+  -restore sequence
+    	restore the version with this sequence number
+
+
+
 Sub-command audit
 
 Audit provides subcommands for auditing storage consumption.
@@ -123,6 +158,11 @@ To delete the garbage references in a given store server:
   4. Run delete-garbage (as the store server user) to delete the blocks in the
      find-garbage output.
 
+Each subcommand accepts -continuous, which makes it repeat every -interval
+until killed, so that garbage is found and deleted on a schedule; see the
+help for delete-garbage. Two runs of the same subcommand cannot use the
+same data directory at once.
+
 Usage of upspin audit:
 	upspin [globalflags] audit <command> [flags] ...
 Commands: scan-dir, scan-store, find-garbage, delete-garbage
@@ -131,7 +171,7 @@ Commands: scan-dir, scan-store, find-garbage, delete-garbage
 
 Sub-command config
 
-Usage: upspin config [-out=outputfile]
+Usage: upspin config [-out=outputfile | -check]
 
 Config prints to standard output the contents of the current config file.
 
@@ -139,7 +179,18 @@ It works by saving the file at initialization time, so if the actual
 file has changed since the command started, it will still show the
 configuration being used.
 
+The -check flag instead checks that the configuration works. It checks
+that the file is valid, that the user's keys can be loaded and match
+those the key server holds for the user, that the key, directory and
+store servers can be reached, that the directory server is the one the
+key server records for the user, and that the user's root exists. It
+prints PASS, FAIL, or WARN and a description for each check, with a
+hint of how to fix the problem for those that do not pass, and exits
+with a non-zero status if any check fails.
+
 Flags:
+  -check
+    	check the configuration rather than print it
   -help
     	print more information about the command
   -out string
@@ -183,12 +234,40 @@ When copying from one Upspin path to another Upspin path, cp can be
 very efficient, copying only the references to the data rather than
 the data itself.
 
+A copy of a local file into Upspin can be resumed: if it fails, for
+instance because the network connection is lost, repeating it stores
+only the blocks that were not already stored. The progress is recorded
+in a file under the cache directory, which is removed once the copy
+is complete and is ignored if the local file changes.
+
+The -sparse flag saves uploading and storing the blocks of files
+copied into Upspin that consist entirely of zero bytes, as in disk
+images and other sparse files. Such blocks are recorded in the
+directory entry and read back as zeros without contacting the store.
+
+The -exclude flag, which may be repeated, skips files and directories
+whose base names match the glob pattern, such as '*.pyc' or '.git'.
+The -include flag makes an exception to a preceding -exclude, as in
+-exclude='*.pyc' -include='keep.pyc'; when several patterns match a
+name, the last one given decides. The -exclude-from flag reads
+patterns from a local file, one per line, in the style of .gitignore:
+blank lines and lines beginning with '#' are ignored, a leading '!'
+marks an include pattern, and a trailing '/' is dropped.
+
 Flags:
   -R	recursively copy directories
+  -exclude pattern
+    	skip files whose base name matches pattern; may be repeated
+  -exclude-from file
+    	read exclude patterns from the local file
   -help
     	print more information about the command
+  -include pattern
+    	copy files whose base name matches pattern despite a preceding -exclude
   -overwrite
     	overwrite existing files (default true)
+  -sparse
+    	do not store blocks that are all zeros
   -v	log each file as it is copied
 
 
@@ -286,17 +365,51 @@ Flags:
 
 Sub-command getref
 
-Usage: upspin getref [-store endpoint] [-out=outputfile] ref
+Usage: upspin getref [-store endpoint] [-out=outputfile | -outdir=directory] [-in=file] [-follow] [-noverify] [-all-replicas] ref...
 
 Getref writes to standard output the contents identified by the reference from
 the specified store endpoint, by default the user's default store server.
-It does not resolve redirections.
+
+Several references may be given, as arguments or one per line in the
+file named by -in. Each is then written to a file, named for the
+reference, in the directory given by -outdir, which must be set.
+Getref continues past references that cannot be fetched but exits with
+a non-zero status.
+
+If a reference has the form of a SHA-256 hash, as do those made by the
+standard store servers, getref checks that the data it fetches hashes
+to it and fails if not. The -noverify flag disables the check.
+
+Getref does not resolve redirections; it prints the locations to which
+the store redirects. With the -follow flag, it instead fetches the data
+from those locations, trying each in turn.
+
+The -all-replicas flag instead fetches the block from every location
+that holds it and reports whether the copies are identical. The
+locations are the store endpoint, or each of a comma-separated list of
+them given by -store, and every location a store redirects to. For
+each, getref prints the endpoint, the SHA-256 hash and size of the
+data, and [OK] if it matches the data held by most replicas or
+[MISMATCH] if it does not. A mismatch, or a replica that cannot be
+read, indicates divergence or corruption in storage, and getref exits
+with a non-zero status. This can be used to confirm that a block has
+been copied correctly before deleting the original.
 
 Flags:
+  -all-replicas
+    	fetch from every replica and compare them
+  -follow
+    	fetch the data from the locations of any redirection
   -help
     	print more information about the command
+  -in file
+    	read references, one per line, from file
+  -noverify
+    	do not check that the data matches a SHA-256 reference
   -out string
     	output file (default standard output)
+  -outdir directory
+    	write each reference to a file in directory
   -store string
     	store endpoint (default the user's store)
 
@@ -304,7 +417,7 @@ Flags:
 
 Sub-command info
 
-Usage: upspin info path...
+Usage: upspin info [-R] [-user=user] path...
 
 Info prints to standard output a thorough description of all the
 information about named paths, including information provided by
@@ -314,16 +427,24 @@ If the path names an Access or Group file, it is also checked for
 validity. If it is a link, the command attempts to access the target
 of the link.
 
+The -user flag adds what the named user may do with each path: the
+rights granted them by the Access file that controls it, whether the
+user is among those for whom the key of an encrypted file is wrapped,
+and so may decrypt it, and whether the two disagree, in which case the
+owner should run 'upspin share -fix' on the file.
+
 Flags:
   -R	recur into subdirectories
   -help
     	print more information about the command
+  -user user
+    	show the rights of user to each path
 
 
 
 Sub-command keygen
 
-Usage: upspin keygen [-curve=256] [-secretseed=seed] <directory>
+Usage: upspin keygen [-curve=256] [-secretseed=seed] [-prune=date] [-verifyseed] [-qr=file] <directory>
 
 Keygen creates a new Upspin key pair and stores the pair in local files
 secret.upspinkey and public.upspinkey in the specified directory.
@@ -331,6 +452,22 @@ Existing key pairs are appended to secret2.upspinkey.
 Keygen does not update the information in the key server;
 use the "user -put" command for that.
 
+The -prune flag removes from secret2.upspinkey all key pairs archived
+before the given date, after asking for confirmation. Once pruned, files
+readable only with those keys cannot be recovered. When -prune is set
+no new key is generated.
+
+When keygen prints the secret seed it also prints a checksum word.
+The -verifyseed flag prompts for a seed, optionally followed by its
+checksum word, and reports whether it regenerates the public key
+stored in the directory. It writes nothing.
+
+The -qr flag also writes the secret seed as a QR code, for backup
+to a phone. If the argument is "-" the code is printed on standard
+error as text, to be shown on a light background; otherwise a PNG
+image is written to the named file, which should be kept as safe
+as the seed itself.
+
 New users should instead use the "signup" command to create their first key.
 
 See the description for rotate for information about updating keys.
@@ -340,42 +477,104 @@ Flags:
     	cryptographic curve name: p256, p384, or p521 (default "p256")
   -help
     	print more information about the command
+  -prune date
+    	remove archived keys older than date (YYYY-MM-DD)
+  -qr file
+    	also write the secret seed as a QR code to file (PNG), or - for text
   -rotate
     	back up the existing keys and replace them with new ones
   -secretseed string
     	the seed containing a 128-bit secret in proquint format or a file that contains it
+  -verifyseed
+    	check that a seed read from standard input regenerates the public key
 
 
 
 Sub-command link
 
 Usage: upspin link [-f] original_path link_path
+              link -web [-expires=duration] [-gateway=url] path
 
 Link creates an Upspin link. The link is created at the second path
 argument and points to the first path argument.
 
+The directory server refuses to create a link to a path in the user's
+own tree that does not exist. The -f flag skips both that check and the
+check made by the command itself.
+
+With the -web flag, link instead prints a URL through which anyone may
+download the single named file, which must belong to the user, until
+the time set by the -expires flag. The URL is signed with the user's
+key and is served by an upspinserver run with the -share flag; the
+-gateway flag gives its address, by default that of the user's
+directory server. The gateway serves only the files of its own user,
+so the user must be the one the gateway runs as.
+
 Flags:
+  -expires duration
+    	with -web, how long the URL is valid (default 24h0m0s)
   -f	force creation of link when original path is inaccessible
+  -gateway URL
+    	with -web, the URL of the gateway serving the file
   -help
     	print more information about the command
+  -web
+    	print a signed web URL for the file
 
 
 
 Sub-command ls
 
-Usage: upspin ls [-l] [path...]
+Usage: upspin ls [-l [-fulltime] | -e [-json]] [-t | -S] [-r] [-since=time] [-before=time] [path...]
 
 Ls lists the names and, if requested, other properties of Upspin
 files and directories. If given no path arguments, it lists the
 user's root. By default ls does not follow links; use the -L flag
 to learn about the targets of links.
 
+The -e (or -extended) flag prints every field of each entry, one per
+line in the form "field: value", under a header line holding the
+entry's path name followed by a colon. Entries are separated by a
+blank line. The signedname field appears only if it differs from the
+name and the link field only for links. The size field is the size
+computed from the block offsets, while blockbytes is the sum of the
+block sizes; they differ only if the entry is inconsistent. Each block
+is described by a line of the form
+	block N: offset O size S location ENDPOINT REFERENCE
+With -json as well, each entry is instead printed as JSON.
+
+By default, the entries of a directory are listed in the order the
+directory server returns them, which is sorted by name. The -t flag
+sorts them by time, newest first, and the -S flag by size, largest
+first; -r reverses the order. Entries that sort equally keep their
+order, and entries whose size cannot be determined follow all others.
+The -since and -before flags list only the entries modified at or after,
+or before, the given time, which may be a date (2006-01-02), a time
+in RFC 3339 format (2006-01-02T15:04:05Z), or a duration such as 36h,
+meaning that long ago. With -R, directories are explored even if they
+are not listed. The -fulltime flag makes -l print times in full, in
+RFC 3339 format.
+
 Flags:
   -L	follow links
   -R	recur into subdirectories
+  -S	sort by size, largest first
+  -before time
+    	list only entries modified before time
+  -e	extended format: print every field of each entry
+  -extended
+    	same as -e
+  -fulltime
+    	with -l, print times in full
   -help
     	print more information about the command
+  -json
+    	with -e, print each entry as JSON
   -l	long format
+  -r	reverse the order of the listing
+  -since time
+    	list only entries modified at or after time
+  -t	sort by time, newest first
 
 
 
@@ -403,7 +602,7 @@ Flags:
 
 Sub-command put
 
-Usage: upspin put [-in=inputfile] path
+Usage: upspin put [-in=inputfile] [-attr=name=value]... path
 
 Put writes its input to the store server and installs a directory
 entry with the given path name to refer to the data.
@@ -412,7 +611,13 @@ The -glob flag can be set to false to have put skip Glob processing,
 treating its arguments as literal text even if they contain special
 characters. (Leading @ signs are always expanded.)
 
+The -attr flag, which may be repeated, records a user-defined attribute
+name=value in the directory entry, such as -attr=content-type=text/plain.
+Attributes are signed along with the data and shown by upspin info.
+
 Flags:
+  -attr name=value
+    	record attribute name=value in the entry (may be repeated)
   -glob
     	apply glob processing to the arguments (default true)
   -help
@@ -448,6 +653,39 @@ Flags:
 
 
 
+Sub-command revokekey
+
+Usage: upspin revokekey [-user=name] [-key=file]
+
+Revokekey marks a public key as revoked in the key server, for use
+when the corresponding secret key has been compromised. Servers refuse
+to authenticate sessions for a revoked key and drop any established
+sessions that used it within a minute or so.
+
+By default the current key of the current user is revoked. The -key
+flag names a file holding a different public key to revoke, such as
+an older key or a key of another user named with the -user flag.
+
+The revocation is signed with the key being revoked if it is held by
+the current user's factotum (the current key or one of its archived
+predecessors). Otherwise it is signed with the current user's key,
+which the key server accepts only from an administrator of the
+target user's domain.
+
+A revoked key cannot be reinstated. After revoking their own key, a
+user must have a domain administrator install a new one, for example
+with 'upspin user -put'.
+
+Flags:
+  -help
+    	print more information about the command
+  -key string
+    	file holding the public key to revoke (default current key)
+  -user string
+    	user whose key to revoke (default current user)
+
+
+
 Sub-command rm
 
 Usage: upspin rm path...
@@ -479,7 +717,7 @@ Flags:
 
 Sub-command rotate
 
-Usage: upspin rotate
+Usage: upspin rotate [-all [-dry-run]]
 
 Rotate pushes an updated key to the key server.
 
@@ -499,9 +737,20 @@ with the old key to use the new key.
 Some of these steps could be folded together but the full sequence
 makes it easier to recover if a step fails.
 
+The -all flag runs the whole sequence, using the secrets directory
+from the config. Progress is recorded in the file rotate.state in that
+directory, so if a step fails, running rotate -all again resumes
+where it left off. Rotate -all refuses to continue a recorded rotation
+to a key other than the current one. With -dry-run, it prints the
+steps that remain without running them.
+
 TODO: Rotate and countersign are terms of art, not clear to users.
 
 Flags:
+  -all
+    	run the complete key rotation sequence
+  -dry-run
+    	with -all, print the remaining steps without running them
   -help
     	print more information about the command
 
@@ -566,6 +815,9 @@ additional writers with the -writers flag. For instance, if you want all users
 
 The calling user must be the same one that ran 'upspin setupdomain'.
 
+To run upspinserver as a systemd service, run 'upspin setupsystemd' on
+the server.
+
 Flags:
   -domain name
     	domain name for this Upspin installation
@@ -582,31 +834,105 @@ Flags:
 
 Sub-command setupstorage
 
-Usage: upspin setupstorage -domain=<name> -path=<storage_dir>
+Usage: upspin setupstorage -domain=<name> [-path=<storage_dir> | -backend=S3 -bucket=<name> [-region=<region>] [-endpoint=<url>] [-profile=<name>] [-create]]
 
 Setupstorage is the second step in establishing an upspinserver,
 It sets up storage for your Upspin installation.
 The first step is 'setupdomain' and the final step is 'setupserver'.
 
-This version of setupstorage configures local disk storage.
+By default setupstorage configures local disk storage, kept in the
+directory given by the -path flag.
+
+With -backend=S3, it configures storage in the Amazon S3 bucket named
+by the -bucket flag, or with the -endpoint flag, in a bucket of a service
+with a compatible API, such as MinIO. The credentials used are those in
+the environment variables AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, and
+AWS_SESSION_TOKEN, or if the -profile flag is set, those of the named
+profile in the shared credentials file ($HOME/.aws/credentials, or that
+named by $AWS_SHARED_CREDENTIALS_FILE). The upspinserver must be able to
+find the same credentials, and must include the S3 storage backend, as
+does upspinserver-aws from aws.upspin.io. The -create flag creates the
+bucket and blocks public access to its contents. Setupstorage checks
+that the bucket is usable by writing and deleting an object in it.
+
 Read the documentation at
 	https://upspin.io/doc/server_setup.md
-for information on configuring upspinserver to use cloud storage services.
+for information on configuring upspinserver to use other cloud storage
+services.
 
 Flags:
+  -backend backend
+    	storage backend to configure: Disk or S3 (default "Disk")
+  -bucket name
+    	S3 bucket name
   -config string
     	do not set; here only for consistency with other upspin commands
+  -create
+    	create the S3 bucket and block public access to it
   -domain name
     	domain name for this Upspin installation
+  -endpoint URL
+    	URL of an S3-compatible service (default Amazon S3)
   -help
     	print more information about the command
   -path directory
     	directory on the server in which to keep Upspin storage (default is $HOME/upspin/server/storage)
+  -profile name
+    	name of the profile in the AWS shared credentials file holding the S3 credentials (default from the environment)
+  -region region
+    	S3 region of the bucket (default "us-east-1")
   -where directory
     	directory to store private configuration files (default "/home/user/upspin/deploy")
 
 
 
+Sub-command setupsystemd
+
+Usage: upspin setupsystemd [-user=upspin] [-domain=<domain>] [-binary=<path>] [-args=<flags>] [-install]
+
+Setupsystemd writes a systemd unit file that runs upspinserver as a
+service, restarting it if it fails. It is intended to be run on the
+server machine, and does not need an Upspin configuration.
+
+The service runs as the Unix account given by the -user flag, under a
+sandbox that lets the server write only to its configuration directory,
+its Let's Encrypt cache, and, for disk storage, its storage directory,
+and lets it listen on ports 80 and 443 without running as root or
+setting capabilities on the binary with setcap.
+
+By default the binary is upspinserver in the account's home directory,
+or if there is none there, the one found in $PATH, and the directories
+are those upspinserver uses by default, in $HOME/upspin. If the -domain
+flag is given, the storage directory is that configured by setupstorage
+in $where/$domain, if it is on local disk.
+
+The unit file is written to standard output, or with -install, to
+/etc/systemd/system/upspinserver.service, after which the service is
+enabled and started. The -install flag requires running as root,
+perhaps via sudo.
+
+Flags:
+  -args flags
+    	additional flags for upspinserver, such as -web
+  -binary path
+    	path of the upspinserver binary (default $home/upspinserver or from $PATH)
+  -domain name
+    	domain name for this Upspin installation, if setupstorage has been run on this machine
+  -help
+    	print more information about the command
+  -home directory
+    	home directory of the account (default /home/$user)
+  -install
+    	install, enable, and start the service
+  -restart policy
+    	systemd Restart policy (default "on-failure")
+  -user account
+    	Unix account to run upspinserver as (default "upspin")
+  -where directory
+    	directory containing private configuration files (default "/home/user/upspin/deploy")
+
+
+
 Sub-command setupwriters
 
 Usage: upspin setupwriters [-where=$HOME/upspin/deploy] -domain=<domain> <user names>
@@ -647,6 +973,14 @@ the -unencryptforall flag in combination with -fix will rewrite the file
 using the EEIntegrity packing, decrypting it and making its contents
 visible to anyone.
 
+Given the -json flag, share instead writes to standard output a JSON
+array with an object for each file, holding its path, the users the
+Access files allow to read and to write it, and whether the keys stored
+in its directory metadata are inconsistent with its readers:
+
+	[{"path":"ann@example.com/dir/file","readers":["ann@example.com"],
+	  "writers":["ann@example.com"],"inconsistent":false}]
+
 The -glob flag can be set to false to have share skip Glob processing,
 treating its arguments as literal text even if they contain special
 characters. (Leading @ signs are always expanded.)
@@ -663,6 +997,8 @@ Flags:
     	apply glob processing to the arguments (default true)
   -help
     	print more information about the command
+  -json
+    	write the state of every file as JSON
   -q	suppress output. Default is to show state for every file
   -r	recur into subdirectories; path must be a directory. assumes -d
   -unencryptforall
@@ -672,7 +1008,7 @@ Flags:
 
 Sub-command shell
 
-Usage: upspin shell [-v] [-prompt=<prompt_string>]
+Usage: upspin shell [-v] [-prompt=<prompt_string>] [-f=file [-k]]
 
 Shell runs an interactive session for Upspin subcommands.
 When running the shell, the leading "upspin" is assumed on each command.
@@ -682,6 +1018,20 @@ associated with interactive shells. It is intended only for testing and is kept
 simple for reasons of comprehensibility, portability, and maintainability.
 Those who need quoting or line editing or other such features should use their
 regular shell and run upspinfs or invoke the upspin command line-by-line.
+Each line is split into words at white space, and there is no way to
+include white space in an argument.
+
+A # and the rest of the line after it are ignored, as are blank lines.
+The text $UPSPINUSER anywhere in a line is replaced by the current user's
+name. Lines typed are remembered in a history, which the history command
+lists with their numbers. A line beginning !! repeats the previous line
+and one beginning !n, where n is a number, repeats line n; any words
+after them are appended.
+
+The -f flag runs the commands in the named file rather than those read
+from standard input, and stops at the first command that fails, unless
+the -k flag is set. If any command fails, the shell exits with a
+non-zero status.
 
 The shell does have one convenience feature, though, in the handling of path
 names. A path beginning with a plain @ refers to the current user's root
@@ -690,8 +1040,11 @@ included (ann+suffix@example.com). This feature works in all upspin commands
 but is particularly handy inside the shell.
 
 Flags:
+  -f file
+    	run the commands in file
   -help
     	print more information about the command
+  -k	with -f, keep going after a command fails
   -prompt prompt
     	interactive prompt (default "<username>")
   -v	verbose; print to stderr each command before execution
@@ -702,6 +1055,7 @@ Sub-command signup
 
 Usage: upspin [-config=<file>] signup -dir=<addr> -store=<addr> [flags] <username>
        upspin [-config=<file>] signup -server=<addr> [flags] <username>
+       upspin [-config=<file>] signup -batch=<file> -secrets-dir=<dir> [flags]
 
 Signup generates an Upspin configuration file and private/public key pair,
 stores them locally, and sends a signup request to the public Upspin key server
@@ -732,7 +1086,29 @@ recreate or reuse prior keys.
 The -signuponly flag tells signup to skip the generation of the configuration
 file and keys and only send the signup request to the key server.
 
+The -batch flag signs up many users at once. It names a CSV file, or - for
+standard input, with one line per user holding the user name and the
+addresses of the user's directory and store servers:
+
+	username,dir-server,store-server
+	ann@example.com,dir.example.com,store.example.com
+
+For each user, signup generates a key pair and writes it, with a
+configuration file named config, to a subdirectory of the directory named
+by the -secrets-dir flag, which -batch requires. The subdirectory, named
+for the user, is readable only by its owner. Signup then sends the signup
+request for the user to the key server. With the -no-email flag, it instead
+registers the user directly, without email confirmation, using the current
+configuration file, which must be that of a user allowed to create users
+on the key server, such as the key server's own user; the key server is
+then that of the current configuration unless -key is set. Signup writes a
+CSV report of the result for each user, one of success, already-exists or
+error, to standard output. Keys left by an earlier run that did not
+register a user are reused.
+
 Flags:
+  -batch file
+    	sign up the users listed in the CSV file (- for standard input)
   -curve name
     	cryptographic curve name: p256, p384, or p521 (default "p256")
   -dir address
@@ -743,8 +1119,12 @@ Flags:
     	print more information about the command
   -key address
     	Key server address (default "key.upspin.io:443")
+  -no-email
+    	with -batch, register users directly instead of by email confirmation
   -secrets directory
     	directory to store key pair
+  -secrets-dir directory
+    	directory in which -batch writes each user's configuration and keys
   -secretseed string
     	the seed containing a 128 bit secret in proquint format or a file that contains it
   -server address
@@ -758,13 +1138,51 @@ Flags:
 
 Sub-command snapshot
 
-Usage: upspin snapshot
+Usage: upspin snapshot [-schedule=spec]
 
 Snapshot requests the system to take a snapshot of the user's
 directory tree as soon as possible. Snapshots are created only if
 the directory server for the user's root supports them.
 
+With the -schedule flag, snapshot instead sets when the server takes
+snapshots automatically. The schedule is either an interval such as
+"6h" or a cron expression of five fields (minute, hour, day of month,
+month, day of week) made of numbers, ranges, steps, lists and "*",
+as in "0 9-17 * * 1-5" for hourly snapshots during working hours on
+weekdays. Times are UTC. An empty schedule restores the server's
+default, which is usually every 12 hours. The schedule is stored in
+the file named Schedule at the root of the snapshot tree.
+
+Flags:
+  -help
+    	print more information about the command
+  -schedule schedule
+    	set the snapshot schedule rather than taking a snapshot
+
+
+
+Sub-command snapshot-restore
+
+Usage: upspin snapshot-restore [-dry-run] snapshot
+
+Snapshot-restore makes the user's directory tree match a snapshot of
+it. The argument names the snapshot, either as a full path such as
+ann+snapshot@example.com/2017/06/01/12:00 or relative to the root of
+the snapshot tree, as in 2017/06/01/12:00. Glob patterns are expanded
+and must match a single snapshot.
+
+Files and links present in the snapshot but missing or different in
+the live tree are put back, and entries in the live tree that are not
+in the snapshot are deleted. Restored files refer to the blocks already
+in the store server, so no data is uploaded. If some of a file's blocks
+are no longer in the store, that file is left as it is and reported,
+and the rest of the tree is restored.
+
+The -dry-run flag prints the changes without making them.
+
 Flags:
+  -dry-run
+    	print the changes without making them
   -help
     	print more information about the command
 
@@ -795,6 +1213,37 @@ Flags:
 
 
 
+Sub-command transfer-domain
+
+Usage: upspin transfer-domain -domain=<name> -dest=<host:port>
+
+Transfer-domain moves the records of all the users of a domain from the
+current key server, the one named in the config, to another key server,
+such as when an installation leaves key.upspin.io for a key server of
+its own. The -dest flag gives the network address of the new server.
+
+The current user must administer the domain, as set up by
+'upspin setupdomain', and the transfer is signed with the current
+user's key. Both key servers check the signature and the domain's DNS
+TXT record.
+
+The current key server sends the records to the new one and then
+records that the domain has moved. From then on it answers a lookup of
+a user of the domain by naming the new server, which clients then ask
+instead. The forwarding is kept indefinitely, but every user of the
+domain should update the keyserver line in their config to name the
+new server.
+
+Flags:
+  -dest address
+    	network address of the destination key server
+  -domain name
+    	domain name whose users to transfer
+  -help
+    	print more information about the command
+
+
+
 Sub-command user
 
 Usage: upspin user [username...]
@@ -834,7 +1283,7 @@ Flags:
 
 Sub-command watch
 
-Usage: upspin watch [-sequence=n] path
+Usage: upspin watch [-sequence=n] [-reconnect [-max-reconnects=n]] path
 
 Watch watches the given Upspin path beginning with the specified
 sequence number and prints the events to standard output. A sequence
@@ -845,11 +1294,25 @@ The -glob flag can be set to false to have watch skip Glob processing,
 treating its arguments as literal text even if they contain special
 characters. (Leading @ signs are always expanded.)
 
+Watch normally exits when the server stops sending events, such as
+when it restarts or the network fails. With the -reconnect flag it
+instead calls the server again, waiting longer after each failed
+attempt, and continues from the sequence number following the last
+event printed so that no events are missed. Each time it reconnects
+it prints a line of the form
+	[reconnected at sequence N]
+to standard error. The -max-reconnects flag limits the number of
+attempts; zero, the default, means there is no limit.
+
 Flags:
   -glob
     	apply glob processing to the arguments (default true)
   -help
     	print more information about the command
+  -max-reconnects n
+    	with -reconnect, give up after n consecutive failed attempts; 0 means no limit
+  -reconnect
+    	reconnect when the watch ends
   -sequence sequence
     	sequence number (default -1)
 
@@ -857,7 +1320,7 @@ Flags:
 
 Sub-command whichaccess
 
-Usage: upspin whichaccess path...
+Usage: upspin whichaccess [-expand [-user=user]] path...
 
 Whichaccess reports the Upspin path of the Access file
 that controls permissions for each of the argument paths.
@@ -866,11 +1329,23 @@ The -glob flag can be set to false to have watchaccess skip Glob
 processing, treating its arguments as literal text even if they
 contain special characters. (Leading @ signs are always expanded.)
 
+The -expand flag also prints, for each right, the users and groups
+the Access file grants it to, with each group followed by its members,
+indented, and so on for groups within groups. The owner's implicit
+rights to read and list are included. A group that cannot be read is
+marked unreachable, and one that contains itself, directly or not, is
+marked as a cycle. With -user, only the chains of groups that grant
+the named user each right are printed.
+
 Flags:
+  -expand
+    	print the users and groups granted each right, expanding groups
   -glob
     	apply glob processing to the arguments (default true)
   -help
     	print more information about the command
+  -user user
+    	with -expand, print only how user is granted each right
 
 */
 package main
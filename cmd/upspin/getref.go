@@ -5,28 +5,106 @@
 package main
 
 import (
+	"crypto/sha256"
 	"flag"
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"upspin.io/bind"
+	"upspin.io/errors"
+	"upspin.io/key/sha256key"
+	"upspin.io/subcmd"
 	"upspin.io/upspin"
 )
 
+// maxReplicas bounds the number of locations getref -all-replicas will
+// visit, in case stores redirect to one another in a loop.
+const maxReplicas = 32
+
 func (s *State) getref(args ...string) {
 	const help = `
 Getref writes to standard output the contents identified by the reference from
 the specified store endpoint, by default the user's default store server.
-It does not resolve redirections.
+
+Several references may be given, as arguments or one per line in the
+file named by -in. Each is then written to a file, named for the
+reference, in the directory given by -outdir, which must be set.
+Getref continues past references that cannot be fetched but exits with
+a non-zero status.
+
+If a reference has the form of a SHA-256 hash, as do those made by the
+standard store servers, getref checks that the data it fetches hashes
+to it and fails if not. The -noverify flag disables the check.
+
+Getref does not resolve redirections; it prints the locations to which
+the store redirects. With the -follow flag, it instead fetches the data
+from those locations, trying each in turn.
+
+The -all-replicas flag instead fetches the block from every location
+that holds it and reports whether the copies are identical. The
+locations are the store endpoint, or each of a comma-separated list of
+them given by -store, and every location a store redirects to. For
+each, getref prints the endpoint, the SHA-256 hash and size of the
+data, and [OK] if it matches the data held by most replicas or
+[MISMATCH] if it does not. A mismatch, or a replica that cannot be
+read, indicates divergence or corruption in storage, and getref exits
+with a non-zero status. This can be used to confirm that a block has
+been copied correctly before deleting the original.
 `
 	fs := flag.NewFlagSet("getref", flag.ExitOnError)
 	outFile := fs.String("out", "", "output file (default standard output)")
+	outDir := fs.String("outdir", "", "write each reference to a file in `directory`")
+	inFile := fs.String("in", "", "read references, one per line, from `file`")
 	store := fs.String("store", "", "store endpoint (default the user's store)")
-	s.ParseFlags(fs, args, help, "getref [-store endpoint] [-out=outputfile] ref")
+	follow := fs.Bool("follow", false, "fetch the data from the locations of any redirection")
+	noVerify := fs.Bool("noverify", false, "do not check that the data matches a SHA-256 reference")
+	allReplicas := fs.Bool("all-replicas", false, "fetch from every replica and compare them")
+	s.ParseFlags(fs, args, help, "getref [-store endpoint] [-out=outputfile | -outdir=directory] [-in=file] [-follow] [-noverify] [-all-replicas] ref...")
 
-	if fs.NArg() != 1 {
+	refs := fs.Args()
+	if *inFile != "" {
+		for _, line := range strings.Split(string(s.ReadAll(*inFile)), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				refs = append(refs, line)
+			}
+		}
+	}
+	if len(refs) == 0 {
 		usageAndExit(fs)
 	}
-	ref := fs.Arg(0)
+
+	if *allReplicas {
+		if *outFile != "" || *outDir != "" {
+			s.Exitf("-out and -outdir cannot be used with -all-replicas")
+		}
+		if len(refs) != 1 {
+			s.Exitf("-all-replicas takes a single reference")
+		}
+		ref := upspin.Reference(refs[0])
+		locs := []upspin.Location{{Endpoint: s.Config.StoreEndpoint(), Reference: ref}}
+		if *store != "" {
+			locs = nil
+			for _, str := range strings.Split(*store, ",") {
+				e, err := upspin.ParseEndpoint(str)
+				if err != nil {
+					s.Exit(err)
+				}
+				locs = append(locs, upspin.Location{Endpoint: *e, Reference: ref})
+			}
+		}
+		s.checkReplicas(locs)
+		return
+	}
+
+	if *outFile != "" && *outDir != "" {
+		s.Exitf("only one of -out and -outdir may be set")
+	}
+	if len(refs) > 1 && *outDir == "" {
+		s.Exitf("-outdir must be set to get more than one reference")
+	}
 
 	endpoint := s.Config.StoreEndpoint()
 	if *store != "" {
@@ -37,22 +115,202 @@ It does not resolve redirections.
 		endpoint = *e
 	}
 
-	storeServer, err := bind.StoreServer(s.Config, endpoint)
-	if err != nil {
-		s.Exit(err)
+	if *outDir == "" {
+		data, locs, err := s.fetchRef(bind.StoreServer, upspin.Location{Endpoint: endpoint, Reference: upspin.Reference(refs[0])}, *follow, !*noVerify)
+		if err != nil {
+			s.Exit(err)
+		}
+		if len(locs) > 0 {
+			s.printRedirection(locs)
+			return
+		}
+		s.writeOut(*outFile, data)
+		return
 	}
+	s.MkdirAllLocal(*outDir)
+	s.getRefs(bind.StoreServer, endpoint, refs, *outDir, *follow, !*noVerify)
+}
+
+// storeDialer returns the StoreServer for an endpoint, as does bind.StoreServer.
+type storeDialer func(upspin.Config, upspin.Endpoint) (upspin.StoreServer, error)
 
-	data, _, locs, err := storeServer.Get(upspin.Reference(ref))
+// getRefs fetches each of the references from the store at the endpoint
+// and writes them to files of the same name in dir. It reports, but does
+// not stop for, references that cannot be fetched.
+func (s *State) getRefs(dial storeDialer, endpoint upspin.Endpoint, refs []string, dir string, follow, verify bool) {
+	for _, ref := range refs {
+		name := url.PathEscape(ref)
+		if name == "." || name == ".." {
+			s.Failf("reference %q cannot be used as a file name", ref)
+			continue
+		}
+		data, locs, err := s.fetchRef(dial, upspin.Location{Endpoint: endpoint, Reference: upspin.Reference(ref)}, follow, verify)
+		if err != nil {
+			s.Fail(err)
+			continue
+		}
+		if len(locs) > 0 {
+			s.printRedirection(locs)
+			s.Failf("%s: redirected; use -follow to fetch it", ref)
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(subcmd.Tilde(dir), name), data, 0600); err != nil {
+			s.Fail(err)
+		}
+	}
+}
+
+// fetchRef gets the data at loc. If the store redirects and follow is not
+// set, it returns the locations it was redirected to instead. If verify
+// is set and the reference is a SHA-256 hash, it checks that the data has
+// that hash.
+func (s *State) fetchRef(dial storeDialer, loc upspin.Location, follow, verify bool) ([]byte, []upspin.Location, error) {
+	locs := []upspin.Location{loc}
+	seen := make(map[upspin.Location]bool)
+	var firstErr error
+	for len(locs) > 0 {
+		loc := locs[0]
+		locs = locs[1:]
+		if seen[loc] {
+			continue
+		}
+		if len(seen) >= maxReplicas {
+			return nil, nil, errors.E(errors.IO, errors.Errorf("%s: more than %d redirections", loc.Reference, maxReplicas))
+		}
+		seen[loc] = true
+		store, err := dial(s.Config, loc.Endpoint)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		data, _, redirect, err := store.Get(loc.Reference)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		if len(redirect) > 0 {
+			if !follow {
+				return nil, redirect, nil
+			}
+			// Try the new locations before any remaining old ones.
+			locs = append(redirect, locs...)
+			continue
+		}
+		if verify {
+			if err := verifyRef(loc, data); err != nil {
+				return nil, nil, err
+			}
+		}
+		return data, nil, nil
+	}
+	if firstErr == nil {
+		// Every redirection led to a location already visited.
+		firstErr = errors.E(errors.IO, errors.Errorf("%s: redirection loop", loc.Reference))
+	}
+	return nil, nil, firstErr
+}
+
+// verifyRef checks that data hashes to the location's reference, if the
+// reference is a SHA-256 hash.
+func verifyRef(loc upspin.Location, data []byte) error {
+	hash, err := sha256key.Parse(string(loc.Reference))
 	if err != nil {
-		s.Exit(err)
+		// Not a hash; nothing to check.
+		return nil
+	}
+	if got := sha256key.Of(data); got != hash {
+		return errors.E(errors.Invalid, errors.Errorf("%s from %s: data does not match reference; its SHA-256 hash is %s", loc.Reference, loc.Endpoint, got))
+	}
+	return nil
+}
+
+// printRedirection reports the locations to which a store redirected.
+func (s *State) printRedirection(locs []upspin.Location) {
+	fmt.Fprintln(s.Stderr, "Redirection detected:")
+	for _, loc := range locs {
+		fmt.Fprintf(s.Stderr, "%+v\n", loc)
+	}
+}
+
+// replica is what was found at one location of a block.
+type replica struct {
+	loc  upspin.Location
+	hash string // Hex SHA-256 of the data.
+	size int
+	err  error
+}
+
+// checkReplicas fetches the block at each of the locations and at every
+// location they redirect to, and reports whether the copies agree.
+func (s *State) checkReplicas(locs []upspin.Location) {
+	var replicas []replica
+	seen := make(map[upspin.Location]bool)
+	for len(locs) > 0 && len(seen) < maxReplicas {
+		loc := locs[0]
+		locs = locs[1:]
+		if seen[loc] {
+			continue
+		}
+		seen[loc] = true
+		data, redirect, err := s.fetchReplica(loc)
+		if len(redirect) > 0 {
+			locs = append(locs, redirect...)
+			continue
+		}
+		r := replica{loc: loc, err: err}
+		if err == nil {
+			r.hash = fmt.Sprintf("%x", sha256.Sum256(data))
+			r.size = len(data)
+		}
+		replicas = append(replicas, r)
 	}
 	if len(locs) > 0 {
-		fmt.Fprintln(s.Stderr, "Redirection detected:")
-		for _, loc := range locs {
-			fmt.Fprintf(s.Stderr, "%+v\n", loc)
+		s.Failf("more than %d locations; not all were checked", maxReplicas)
+	}
+
+	want := majorityHash(replicas)
+	for _, r := range replicas {
+		switch {
+		case r.err != nil:
+			fmt.Fprintf(s.Stdout, "%s %s [ERROR] %v\n", r.loc.Reference, r.loc.Endpoint, r.err)
+			s.ExitCode = 1
+		case r.hash != want:
+			fmt.Fprintf(s.Stdout, "%s %s %s %d [MISMATCH]\n", r.loc.Reference, r.loc.Endpoint, r.hash, r.size)
+			s.ExitCode = 1
+		default:
+			fmt.Fprintf(s.Stdout, "%s %s %s %d [OK]\n", r.loc.Reference, r.loc.Endpoint, r.hash, r.size)
 		}
-		return
 	}
+}
+
+// fetchReplica gets the block at loc, returning either its data or the
+// locations the store redirects to.
+func (s *State) fetchReplica(loc upspin.Location) ([]byte, []upspin.Location, error) {
+	store, err := bind.StoreServer(s.Config, loc.Endpoint)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, _, redirect, err := store.Get(loc.Reference)
+	return data, redirect, err
+}
 
-	s.writeOut(*outFile, data)
+// majorityHash returns the hash held by the most replicas that could be
+// read, preferring the earliest in case of a tie.
+func majorityHash(replicas []replica) string {
+	count := make(map[string]int)
+	best := ""
+	for _, r := range replicas {
+		if r.err != nil {
+			continue
+		}
+		count[r.hash]++
+		if best == "" || count[r.hash] > count[best] {
+			best = r.hash
+		}
+	}
+	return best
 }
@@ -0,0 +1,157 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"upspin.io/errors"
+	"upspin.io/key/sha256key"
+	"upspin.io/subcmd"
+	"upspin.io/upspin"
+)
+
+// fakeRefStore is a StoreServer that holds blocks and redirections.
+type fakeRefStore struct {
+	upspin.StoreServer
+	blocks   map[upspin.Reference][]byte
+	redirect map[upspin.Reference][]upspin.Location
+}
+
+func (f *fakeRefStore) Get(ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Location, error) {
+	if locs, ok := f.redirect[ref]; ok {
+		return nil, nil, locs, nil
+	}
+	if data, ok := f.blocks[ref]; ok {
+		return data, &upspin.Refdata{Reference: ref}, nil, nil
+	}
+	return nil, nil, nil, errors.E(errors.NotExist, errors.Str(string(ref)))
+}
+
+// fakeStores is a set of fake stores, keyed by network address.
+type fakeStores map[upspin.NetAddr]*fakeRefStore
+
+func (f fakeStores) dial(_ upspin.Config, e upspin.Endpoint) (upspin.StoreServer, error) {
+	if s, ok := f[e.NetAddr]; ok {
+		return s, nil
+	}
+	return nil, errors.E(errors.IO, errors.Errorf("no store at %s", e.NetAddr))
+}
+
+func storeAt(addr upspin.NetAddr, ref upspin.Reference) upspin.Location {
+	return upspin.Location{
+		Endpoint:  upspin.Endpoint{Transport: upspin.Remote, NetAddr: addr},
+		Reference: ref,
+	}
+}
+
+func TestFetchRef(t *testing.T) {
+	data := []byte("the block")
+	good := upspin.Reference(sha256key.Of(data).String())
+	bad := upspin.Reference(sha256key.Of([]byte("another block")).String())
+	stores := fakeStores{
+		"a": {
+			blocks: map[upspin.Reference][]byte{
+				good:    data,
+				bad:     data,
+				"plain": data,
+			},
+			redirect: map[upspin.Reference][]upspin.Location{
+				"moved": {storeAt("b", "moved")},
+				"loop":  {storeAt("a", "loop")},
+			},
+		},
+		"b": {
+			redirect: map[upspin.Reference][]upspin.Location{
+				// The first location is down.
+				"moved": {storeAt("down", "moved"), storeAt("c", good)},
+			},
+		},
+		"c": {
+			blocks: map[upspin.Reference][]byte{good: data},
+		},
+	}
+	tests := []struct {
+		ref      upspin.Reference
+		follow   bool
+		verify   bool
+		redirect bool   // Whether a redirection is returned.
+		err      string // If not empty, a substring of the expected error.
+	}{
+		{ref: good, verify: true},
+		{ref: bad, verify: true, err: "does not match reference"},
+		{ref: bad, verify: false},
+		{ref: "plain", verify: true}, // Not a hash; not checked.
+		{ref: "missing", verify: true, err: "missing"},
+		{ref: "moved", verify: true, redirect: true},
+		{ref: "moved", follow: true, verify: true},
+		{ref: "loop", follow: true, err: "loop"},
+	}
+	for _, test := range tests {
+		s := &State{State: &subcmd.State{Name: "getref"}}
+		got, redirect, err := s.fetchRef(stores.dial, storeAt("a", test.ref), test.follow, test.verify)
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%s (follow=%t): err = %v, want %q", test.ref, test.follow, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s (follow=%t): %v", test.ref, test.follow, err)
+			continue
+		}
+		if test.redirect {
+			if len(redirect) != 1 || redirect[0] != storeAt("b", "moved") {
+				t.Errorf("%s (follow=%t): redirect = %v, want b", test.ref, test.follow, redirect)
+			}
+			continue
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s (follow=%t): got %q, want %q", test.ref, test.follow, got, data)
+		}
+	}
+}
+
+func TestGetRefs(t *testing.T) {
+	data := []byte("the block")
+	good := sha256key.Of(data).String()
+	bad := sha256key.Of([]byte("another block")).String()
+	stores := fakeStores{
+		"a": {blocks: map[upspin.Reference][]byte{
+			upspin.Reference(good): data,
+			upspin.Reference(bad):  data,
+			"dir/plain":            data,
+		}},
+	}
+
+	var stderr bytes.Buffer
+	s := &State{State: &subcmd.State{Name: "getref", Stderr: &stderr}}
+	dir := t.TempDir()
+	s.getRefs(stores.dial, storeAt("a", "").Endpoint, []string{good, bad, "dir/plain"}, dir, false, true)
+
+	if s.ExitCode != 1 {
+		t.Errorf("exit code %d, want 1", s.ExitCode)
+	}
+	if !strings.Contains(stderr.String(), bad+" from") {
+		t.Errorf("stderr %q does not report mismatch of %s", stderr.String(), bad)
+	}
+	for _, name := range []string{good, "dir%2Fplain"} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if !bytes.Equal(got, data) {
+			t.Errorf("%s: got %q, want %q", name, got, data)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, bad)); !os.IsNotExist(err) {
+		t.Errorf("mismatched block was written: %v", err)
+	}
+}
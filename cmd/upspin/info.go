@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"flag"
 	"fmt"
+	"strings"
 	"text/tabwriter"
 	"text/template"
 	"time"
@@ -16,6 +17,7 @@ import (
 	"upspin.io/pack"
 	"upspin.io/path"
 	"upspin.io/upspin"
+	"upspin.io/user"
 )
 
 func (s *State) info(args ...string) {
@@ -27,21 +29,34 @@ ls but also storage references, sizes, and other metadata.
 If the path names an Access or Group file, it is also checked for
 validity. If it is a link, the command attempts to access the target
 of the link.
+
+The -user flag adds what the named user may do with each path: the
+rights granted them by the Access file that controls it, whether the
+user is among those for whom the key of an encrypted file is wrapped,
+and so may decrypt it, and whether the two disagree, in which case the
+owner should run 'upspin share -fix' on the file.
 `
 	fs := flag.NewFlagSet("info", flag.ExitOnError)
 	recur := fs.Bool("R", false, "recur into subdirectories")
-	s.ParseFlags(fs, args, help, "info path...")
+	userFlag := fs.String("user", "", "show the rights of `user` to each path")
+	s.ParseFlags(fs, args, help, "info [-R] [-user=user] path...")
 
 	if fs.NArg() == 0 {
 		usageAndExit(fs)
 	}
+	forUser := upspin.UserName(*userFlag)
+	if forUser != "" {
+		if _, _, _, err := user.Parse(forUser); err != nil {
+			s.Exit(err)
+		}
+	}
 
 	for _, name := range fs.Args() {
-		s.doInfo(string(s.AtSign(name)), *recur, true)
+		s.doInfo(string(s.AtSign(name)), forUser, *recur, true)
 	}
 }
 
-func (s *State) doInfo(pattern string, recur, first bool) {
+func (s *State) doInfo(pattern string, forUser upspin.UserName, recur, first bool) {
 	entries, err := s.DirServer(upspin.PathName(pattern)).Glob(pattern)
 	// ErrFollowLink is OK: we show the link itself.
 	if err != nil && err != upspin.ErrFollowLink {
@@ -51,7 +66,7 @@ func (s *State) doInfo(pattern string, recur, first bool) {
 		s.Exitf("no such file %q", pattern)
 	}
 	for _, entry := range entries {
-		s.printInfo(entry)
+		s.printInfo(entry, forUser)
 		switch {
 		case access.IsAccessFile(entry.Name):
 			s.checkAccessFile(entry.Name)
@@ -59,7 +74,7 @@ func (s *State) doInfo(pattern string, recur, first bool) {
 			s.checkGroupFile(entry.Name)
 		case entry.IsDir():
 			if recur {
-				s.doInfo(upspin.AllFilesGlob(entry.Name), recur, false)
+				s.doInfo(upspin.AllFilesGlob(entry.Name), forUser, recur, false)
 			}
 		}
 	}
@@ -74,6 +89,10 @@ type infoDirEntry struct {
 	access     *access.Access
 	accessFile string
 	lastUsers  string
+
+	// forUser, if set, is the user whose rights are shown.
+	forUser upspin.UserName
+	canRead bool // Whether forUser has read rights; set by UserRights.
 }
 
 func (d *infoDirEntry) TimeString() string {
@@ -155,6 +174,77 @@ func (d *infoDirEntry) Users(right access.Right) string {
 	return users
 }
 
+func (d *infoDirEntry) ForUser() upspin.UserName {
+	return d.forUser
+}
+
+// UserRights returns the rights granted to forUser by the Access file.
+func (d *infoDirEntry) UserRights() string {
+	d.WhichAccess()
+	if d.access == nil {
+		return "unknown"
+	}
+	var rights []string
+	for _, right := range d.Rights() {
+		ok, err := d.access.Can(d.forUser, right, d.Name, d.state.Client.Get)
+		if err != nil {
+			return err.Error()
+		}
+		if ok {
+			rights = append(rights, right.String())
+			if right == access.Read {
+				d.canRead = true
+			}
+		}
+	}
+	if len(rights) == 0 {
+		return "none"
+	}
+	return strings.Join(rights, " ")
+}
+
+// UserKey reports whether the key of the file is wrapped for forUser,
+// and ShareFix whether that disagrees with forUser's right to read it.
+// The results are a short explanation for entries that have no keys.
+func (d *infoDirEntry) UserKey() string {
+	wrapped, why := d.userKey()
+	if why != "" {
+		return why
+	}
+	if wrapped {
+		return "yes"
+	}
+	return "no"
+}
+
+func (d *infoDirEntry) ShareFix() string {
+	wrapped, why := d.userKey()
+	if why != "" {
+		return "no"
+	}
+	if wrapped != d.canRead {
+		return "yes"
+	}
+	return "no"
+}
+
+func (d *infoDirEntry) userKey() (wrapped bool, why string) {
+	if d.IsDir() {
+		return false, "is a directory"
+	}
+	if d.IsLink() {
+		return false, "is a link"
+	}
+	if d.Packing != upspin.EEPack {
+		return false, fmt.Sprintf("not needed for %s packing", d.Packing)
+	}
+	wrapped, err := d.state.sharer.hasWrappedKey(d.DirEntry, d.forUser)
+	if err != nil {
+		return false, err.Error()
+	}
+	return wrapped, ""
+}
+
 func (d *infoDirEntry) WhichAccess() string {
 	if d.access != nil {
 		return d.accessFile
@@ -192,10 +282,11 @@ func (d *infoDirEntry) WhichAccess() string {
 // the entry, including the users that have permission to access it.
 // TODO: Present this more neatly.
 // TODO: Present group information.
-func (s *State) printInfo(entry *upspin.DirEntry) {
+func (s *State) printInfo(entry *upspin.DirEntry, forUser upspin.UserName) {
 	infoDir := &infoDirEntry{
 		state:    s,
 		DirEntry: entry,
+		forUser:  forUser,
 	}
 	writer := tabwriter.NewWriter(s.Stdout, 4, 4, 1, ' ', 0)
 	err := infoTmpl.Execute(writer, infoDir)
@@ -216,7 +307,7 @@ func (s *State) printInfo(entry *upspin.DirEntry) {
 		s.Exitf("Error: link %s has invalid target %s:\n\t%v", entry.Name, entry.Link, err)
 	}
 	s.Printf("Target of link %s:\n", entry.Name)
-	s.printInfo(target)
+	s.printInfo(target, forUser)
 }
 
 func attrFormat(attr upspin.Attribute) string {
@@ -246,6 +337,9 @@ const infoText = `
 	time:	{{.TimeString}}
 	writer:	{{.Writer}}
 	attributes:	{{.AttrString}}
+	{{range $name, $value := .Attributes -}}
+	attribute {{$name}}:	{{printf "%q" $value}}
+	{{end -}}
 	sequence:	{{.Sequence}}
 	access file:	{{.WhichAccess}}
 	key holders: 	{{.Readers}}
@@ -253,6 +347,11 @@ const infoText = `
 	{{range $right := .Rights -}}
 	can {{$right}}:	{{$.Users $right}}
 	{{end -}}
+	{{with .ForUser -}}
+	rights of {{.}}:	{{$.UserRights}}
+	key wrapped for {{.}}:	{{$.UserKey}}
+	share -fix needed:	{{$.ShareFix}}
+	{{end -}}
 	Block#	Offset	Size	Location
 	{{range $index, $block := .Blocks -}}
 	{{$index}}	{{.Offset}}	{{.Size}}	{{.Location}}
@@ -7,14 +7,18 @@ package main
 // This file contains the implementation of the keygen command.
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
+	"image/png"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"upspin.io/errors"
 	"upspin.io/key/keygen"
+	"upspin.io/key/qr"
 	"upspin.io/subcmd"
 )
 
@@ -26,6 +30,22 @@ Existing key pairs are appended to secret2.upspinkey.
 Keygen does not update the information in the key server;
 use the "user -put" command for that.
 
+The -prune flag removes from secret2.upspinkey all key pairs archived
+before the given date, after asking for confirmation. Once pruned, files
+readable only with those keys cannot be recovered. When -prune is set
+no new key is generated.
+
+When keygen prints the secret seed it also prints a checksum word.
+The -verifyseed flag prompts for a seed, optionally followed by its
+checksum word, and reports whether it regenerates the public key
+stored in the directory. It writes nothing.
+
+The -qr flag also writes the secret seed as a QR code, for backup
+to a phone. If the argument is "-" the code is printed on standard
+error as text, to be shown on a light background; otherwise a PNG
+image is written to the named file, which should be kept as safe
+as the seed itself.
+
 New users should instead use the "signup" command to create their first key.
 
 See the description for rotate for information about updating keys.
@@ -37,15 +57,110 @@ See the description for rotate for information about updating keys.
 		curve      = fs.String("curve", "p256", "cryptographic curve `name`: p256, p384, or p521")
 		secretSeed = fs.String("secretseed", "", "the seed containing a 128-bit secret in proquint format or a file that contains it")
 		rotate     = fs.Bool("rotate", false, "back up the existing keys and replace them with new ones")
+		prune      = fs.String("prune", "", "remove archived keys older than `date` (YYYY-MM-DD)")
+		verifySeed = fs.Bool("verifyseed", false, "check that a seed read from standard input regenerates the public key")
+		qrFile     = fs.String("qr", "", "also write the secret seed as a QR code to `file` (PNG), or - for text")
 	)
-	s.ParseFlags(fs, args, help, "keygen [-curve=256] [-secretseed=seed] <directory>")
+	s.ParseFlags(fs, args, help, "keygen [-curve=256] [-secretseed=seed] [-prune=date] [-verifyseed] [-qr=file] <directory>")
 	if fs.NArg() != 1 {
 		usageAndExit(fs)
 	}
-	s.keygenCommand(fs.Arg(0), *curve, *secretSeed, *rotate)
+	if *prune != "" {
+		s.pruneCommand(fs.Arg(0), *prune)
+		return
+	}
+	if *verifySeed {
+		s.verifySeedCommand(fs.Arg(0))
+		return
+	}
+	s.keygenCommand(fs.Arg(0), *curve, *secretSeed, *qrFile, *rotate)
 }
 
-func (s *State) keygenCommand(where, curve, secretseed string, rotate bool) {
+// verifySeedCommand reads a secret seed and optional checksum word from
+// standard input and checks that the seed regenerates the public key
+// in the directory where.
+func (s *State) verifySeedCommand(where string) {
+	public, err := os.ReadFile(filepath.Join(where, "public.upspinkey"))
+	if err != nil {
+		s.Exit(err)
+	}
+	fmt.Fprint(s.Stderr, "Secret seed (and checksum word, if known): ")
+	line, _ := bufio.NewReader(s.Stdin).ReadString('\n')
+	words := strings.Fields(line)
+	if len(words) == 0 || len(words) > 2 {
+		s.Exitf("expected a secret seed, optionally followed by its checksum word")
+	}
+	checksum := ""
+	if len(words) == 2 {
+		checksum = words[1]
+	}
+	if err := keygen.VerifySeed(words[0], checksum, string(public)); err != nil {
+		s.Exit(err)
+	}
+	fmt.Fprintf(s.Stderr, "Seed verified: it regenerates the public key in %s.\n", where)
+}
+
+// writeQR writes the secret seed as a QR code to the named file as a PNG
+// image, or to standard error as text if the name is "-".
+func (s *State) writeQR(name, secretStr string) {
+	code, err := qr.Encode(secretStr)
+	if err != nil {
+		s.Exit(err)
+	}
+	if name == "-" {
+		fmt.Fprint(s.Stderr, code.Text())
+		return
+	}
+	f, err := os.OpenFile(subcmd.Tilde(name), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		s.Exit(err)
+	}
+	err = png.Encode(f, code.Image(8))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		s.Exit(err)
+	}
+	fmt.Fprintf(s.Stderr, "Secret seed QR code written to %s.\n", name)
+}
+
+// pruneCommand removes archived keys older than the date from the
+// secret2.upspinkey file in the directory where.
+func (s *State) pruneCommand(where, date string) {
+	before, err := time.Parse("2006-01-02", date)
+	if err != nil {
+		s.Exitf("bad -prune date: %v", err)
+	}
+	keys, err := keygen.ReadArchive(where)
+	if err != nil {
+		s.Exit(err)
+	}
+	n := 0
+	for _, k := range keys {
+		if !k.Time.IsZero() && k.Time.Before(before) {
+			n++
+		}
+	}
+	if n == 0 {
+		fmt.Fprintf(s.Stderr, "No archived keys older than %s.\n", date)
+		return
+	}
+	fmt.Fprintf(s.Stderr, "Remove %d of %d archived keys older than %s?\n", n, len(keys), date)
+	fmt.Fprintln(s.Stderr, "Files readable only with those keys will become unreadable.")
+	fmt.Fprint(s.Stderr, "Type 'yes' to continue: ")
+	answer, _ := bufio.NewReader(s.Stdin).ReadString('\n')
+	if strings.TrimSpace(answer) != "yes" {
+		s.Exitf("keys not pruned")
+	}
+	pruned, err := keygen.PruneKeys(where, before)
+	if err != nil {
+		s.Exit(err)
+	}
+	fmt.Fprintf(s.Stderr, "Removed %d archived keys from %s.\n", len(pruned), filepath.Join(where, "secret2.upspinkey"))
+}
+
+func (s *State) keygenCommand(where, curve, secretseed, qrFile string, rotate bool) {
 	switch curve {
 	case "p256", "p384", "p521":
 		// ok
@@ -72,10 +187,19 @@ func (s *State) keygenCommand(where, curve, secretseed string, rotate bool) {
 	fmt.Fprintf(s.Stderr, "\t%s\n", filepath.Join(where, "secret.upspinkey"))
 	fmt.Fprintln(s.Stderr, "This key pair provides access to your Upspin identity and data.")
 	if secretseed == "" {
+		checksum, err := keygen.SeedChecksum(secretStr)
+		if err != nil {
+			s.Exit(err)
+		}
 		fmt.Fprintln(s.Stderr, "If you lose the keys you can re-create them by running this command:")
 		fmt.Fprintf(s.Stderr, "\tupspin keygen -curve %s -secretseed %s %s\n", curve, secretStr, where)
-		fmt.Fprintln(s.Stderr, "Write this command down and store it in a secure, private place.")
+		fmt.Fprintf(s.Stderr, "The checksum word for this seed is %q.\n", checksum)
+		fmt.Fprintln(s.Stderr, "Write this command and the checksum down and store them in a secure, private place.")
 		fmt.Fprintln(s.Stderr, "Do not share your private key or this command with anyone.")
+		fmt.Fprintln(s.Stderr, "To check what you wrote down, run 'upspin keygen -verifyseed' on this directory.")
+	}
+	if qrFile != "" {
+		s.writeQR(qrFile, secretStr)
 	}
 	if rotate {
 		fmt.Fprintln(s.Stderr, "\nTo install new keys in the key server, see 'upspin rotate -help'.")
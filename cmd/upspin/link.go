@@ -6,7 +6,12 @@ package main
 
 import (
 	"flag"
+	"net"
+	"strings"
+	"time"
 
+	"upspin.io/errors"
+	"upspin.io/serverutil/share"
 	"upspin.io/upspin"
 )
 
@@ -14,13 +19,35 @@ func (s *State) link(args ...string) {
 	const help = `
 Link creates an Upspin link. The link is created at the second path
 argument and points to the first path argument.
+
+The directory server refuses to create a link to a path in the user's
+own tree that does not exist. The -f flag skips both that check and the
+check made by the command itself.
+
+With the -web flag, link instead prints a URL through which anyone may
+download the single named file, which must belong to the user, until
+the time set by the -expires flag. The URL is signed with the user's
+key and is served by an upspinserver run with the -share flag; the
+-gateway flag gives its address, by default that of the user's
+directory server. The gateway serves only the files of its own user,
+so the user must be the one the gateway runs as.
 `
 	var force bool
 	fs := flag.NewFlagSet("link", flag.ExitOnError)
 	fs.BoolVar(&force, "f", false, "force creation of link when original path is inaccessible")
+	web := fs.Bool("web", false, "print a signed web URL for the file")
+	expires := fs.Duration("expires", 24*time.Hour, "with -web, how long the URL is valid")
+	gateway := fs.String("gateway", "", "with -web, the `URL` of the gateway serving the file")
 	// This is the same order as in the Unix ln command. It feels sort of
 	// backwards, but it's also the same as in cp, with the new name second.
-	s.ParseFlags(fs, args, help, "link [-f] original_path link_path")
+	s.ParseFlags(fs, args, help, "link [-f] original_path link_path\n              link -web [-expires=duration] [-gateway=url] path")
+	if *web {
+		if fs.NArg() != 1 {
+			usageAndExit(fs)
+		}
+		s.webLink(upspin.PathName(s.AtSign(fs.Arg(0))), *expires, *gateway)
+		return
+	}
 	if fs.NArg() != 2 {
 		usageAndExit(fs)
 	}
@@ -35,8 +62,50 @@ argument and points to the first path argument.
 		}
 	}
 
-	_, err := s.Client.PutLink(originalPath, linkPath)
+	var err error
+	if p, ok := s.Client.(uncheckedLinkPutter); ok && force {
+		_, err = p.PutUncheckedLink(originalPath, linkPath)
+	} else {
+		_, err = s.Client.PutLink(originalPath, linkPath)
+	}
+	if err != nil {
+		s.Exit(err)
+	}
+}
+
+// webLink prints a URL through which the named file may be fetched
+// from the gateway until the expiry duration has passed.
+func (s *State) webLink(name upspin.PathName, expires time.Duration, gateway string) {
+	if expires <= 0 {
+		s.Exitf("expiry duration must be positive")
+	}
+	entry, err := s.Client.Lookup(name, true)
+	if err != nil {
+		s.Exit(err)
+	}
+	if entry.IsDir() {
+		s.Exit(errors.E(entry.Name, errors.IsDir))
+	}
+	if gateway == "" {
+		ep := s.Config.DirEndpoint()
+		if ep.Transport != upspin.Remote {
+			s.Exitf("no gateway address; use -gateway")
+		}
+		host := string(ep.NetAddr)
+		if h, port, err := net.SplitHostPort(host); err == nil && port == "443" {
+			host = h
+		}
+		gateway = "https://" + host
+	}
+	u, err := share.URL(s.Config, entry.Name, time.Now().Add(expires))
 	if err != nil {
 		s.Exit(err)
 	}
+	s.Printf("%s%s\n", strings.TrimSuffix(gateway, "/"), u)
+}
+
+// uncheckedLinkPutter is implemented by clients that can create links
+// without the directory server checking their targets.
+type uncheckedLinkPutter interface {
+	PutUncheckedLink(oldName, linkName upspin.PathName) (*upspin.DirEntry, error)
 }
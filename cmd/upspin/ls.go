@@ -5,10 +5,14 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
 	"fmt"
+	"sort"
 	"strings"
+	"time"
 
+	"upspin.io/errors"
 	"upspin.io/upspin"
 )
 
@@ -18,12 +22,77 @@ Ls lists the names and, if requested, other properties of Upspin
 files and directories. If given no path arguments, it lists the
 user's root. By default ls does not follow links; use the -L flag
 to learn about the targets of links.
+
+The -e (or -extended) flag prints every field of each entry, one per
+line in the form "field: value", under a header line holding the
+entry's path name followed by a colon. Entries are separated by a
+blank line. The signedname field appears only if it differs from the
+name and the link field only for links. The size field is the size
+computed from the block offsets, while blockbytes is the sum of the
+block sizes; they differ only if the entry is inconsistent. Each block
+is described by a line of the form
+	block N: offset O size S location ENDPOINT REFERENCE
+With -json as well, each entry is instead printed as JSON.
+
+By default, the entries of a directory are listed in the order the
+directory server returns them, which is sorted by name. The -t flag
+sorts them by time, newest first, and the -S flag by size, largest
+first; -r reverses the order. Entries that sort equally keep their
+order, and entries whose size cannot be determined follow all others.
+The -since and -before flags list only the entries modified at or after,
+or before, the given time, which may be a date (2006-01-02), a time
+in RFC 3339 format (2006-01-02T15:04:05Z), or a duration such as 36h,
+meaning that long ago. With -R, directories are explored even if they
+are not listed. The -fulltime flag makes -l print times in full, in
+RFC 3339 format.
 `
 	fs := flag.NewFlagSet("ls", flag.ExitOnError)
 	longFormat := fs.Bool("l", false, "long format")
 	followLinks := fs.Bool("L", false, "follow links")
 	recur := fs.Bool("R", false, "recur into subdirectories")
-	s.ParseFlags(fs, args, help, "ls [-l] [path...]")
+	extended := fs.Bool("e", false, "extended format: print every field of each entry")
+	fs.BoolVar(extended, "extended", false, "same as -e")
+	jsonFormat := fs.Bool("json", false, "with -e, print each entry as JSON")
+	byTime := fs.Bool("t", false, "sort by time, newest first")
+	bySize := fs.Bool("S", false, "sort by size, largest first")
+	reverse := fs.Bool("r", false, "reverse the order of the listing")
+	since := fs.String("since", "", "list only entries modified at or after `time`")
+	before := fs.String("before", "", "list only entries modified before `time`")
+	fullTime := fs.Bool("fulltime", false, "with -l, print times in full")
+	s.ParseFlags(fs, args, help, "ls [-l [-fulltime] | -e [-json]] [-t | -S] [-r] [-since=time] [-before=time] [path...]")
+	if *jsonFormat && !*extended {
+		s.Exitf("-json requires -e")
+	}
+	if *byTime && *bySize {
+		s.Exitf("only one of -t and -S may be set")
+	}
+	opts := listOpts{
+		longFormat:  *longFormat,
+		extended:    *extended,
+		json:        *jsonFormat,
+		followLinks: *followLinks,
+		recur:       *recur,
+		reverse:     *reverse,
+		fullTime:    *fullTime,
+	}
+	switch {
+	case *byTime:
+		opts.sortBy = sortByTime
+	case *bySize:
+		opts.sortBy = sortBySize
+	}
+	now := time.Now()
+	var err error
+	if *since != "" {
+		if opts.since, err = parseTimeFlag(*since, now); err != nil {
+			s.Exitf("-since: %v", err)
+		}
+	}
+	if *before != "" {
+		if opts.before, err = parseTimeFlag(*before, now); err != nil {
+			s.Exitf("-before: %v", err)
+		}
+	}
 
 	done := map[upspin.PathName]bool{}
 	if fs.NArg() == 0 {
@@ -32,23 +101,53 @@ to learn about the targets of links.
 		if err != nil {
 			s.Exit(err)
 		}
-		s.list(rootEntry, done, *longFormat, *followLinks, *recur)
+		s.list(rootEntry, done, opts)
 		return
 	}
 	// The done map marks a directory we have listed, so we don't recur endlessly
 	// when given a chain of links with -L.
 	for _, entry := range s.GlobAllUpspin(fs.Args()) {
-		s.list(entry, done, *longFormat, *followLinks, *recur)
+		s.list(entry, done, opts)
 	}
 }
 
-func (s *State) list(entry *upspin.DirEntry, done map[upspin.PathName]bool, longFormat, followLinks, recur bool) {
+// listOpts holds the flags that control ls.
+type listOpts struct {
+	longFormat  bool
+	extended    bool
+	json        bool
+	followLinks bool
+	recur       bool
+
+	sortBy   sortKey
+	reverse  bool
+	since    time.Time // If not zero, list only entries at or after since.
+	before   time.Time // If not zero, list only entries before before.
+	fullTime bool      // Print RFC 3339 times with -l.
+}
+
+// sortKey is the property by which ls sorts entries.
+type sortKey int
+
+const (
+	sortByName sortKey = iota // The order of the directory server.
+	sortByTime
+	sortBySize
+)
+
+func (s *State) list(entry *upspin.DirEntry, done map[upspin.PathName]bool, opts listOpts) {
 	done[entry.Name] = true
 
 	var dirContents []*upspin.DirEntry
 	var err error
 	if entry.IsDir() {
-		dirContents, err = s.Client.Glob(upspin.AllFilesGlob(entry.Name))
+		pattern := string(upspin.AllFilesGlob(entry.Name))
+		if g, ok := s.Client.(upspin.ShallowGlobber); ok && !opts.longFormat && !opts.extended && opts.sortBy != sortBySize {
+			// The short format shows only names, so don't fetch blocks.
+			dirContents, err = g.GlobShallow(pattern)
+		} else {
+			dirContents, err = s.Client.Glob(pattern)
+		}
 		if err != nil {
 			s.Exit(err)
 		}
@@ -57,7 +156,7 @@ func (s *State) list(entry *upspin.DirEntry, done map[upspin.PathName]bool, long
 	}
 
 	// Glob doesn't follow the final link. We may have to do so ourselves.
-	if followLinks {
+	if opts.followLinks {
 		for i, entry := range dirContents {
 			if entry.IsLink() {
 				e, err := s.Client.Lookup(entry.Link, false)
@@ -70,21 +169,113 @@ func (s *State) list(entry *upspin.DirEntry, done map[upspin.PathName]bool, long
 		}
 	}
 
-	if longFormat {
-		s.printLongDirEntries(dirContents)
-	} else {
-		s.printShortDirEntries(dirContents)
+	dirContents = sortEntries(dirContents, opts.sortBy, opts.reverse)
+	listed := filterEntries(dirContents, opts.since, opts.before)
+
+	switch {
+	case opts.extended:
+		s.printExtendedDirEntries(listed, opts.json)
+	case opts.longFormat:
+		s.printLongDirEntries(listed, opts.fullTime)
+	default:
+		s.printShortDirEntries(listed)
 	}
 
-	if !recur {
+	if !opts.recur {
 		return
 	}
 	for _, entry := range dirContents {
 		if entry.IsDir() && !done[entry.Name] {
 			s.Printf("\n%s:\n", entry.Name)
-			s.list(entry, done, longFormat, followLinks, recur)
+			s.list(entry, done, opts)
+		}
+	}
+}
+
+// sortEntries sorts the entries in place by the key, or reverses them if
+// reverse is set, and returns them. The sort is stable, and entries whose
+// size is unknown sort after all others by size.
+func sortEntries(entries []*upspin.DirEntry, by sortKey, reverse bool) []*upspin.DirEntry {
+	switch by {
+	case sortByName:
+		if reverse {
+			for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+				entries[i], entries[j] = entries[j], entries[i]
+			}
 		}
+	case sortByTime:
+		sort.SliceStable(entries, func(i, j int) bool {
+			if reverse {
+				return entries[i].Time < entries[j].Time
+			}
+			return entries[i].Time > entries[j].Time
+		})
+	case sortBySize:
+		sizes := make(map[*upspin.DirEntry]int64, len(entries))
+		for _, e := range entries {
+			sizes[e] = entrySize(e)
+		}
+		sort.SliceStable(entries, func(i, j int) bool {
+			si, sj := sizes[entries[i]], sizes[entries[j]]
+			switch {
+			case si < 0 || sj < 0:
+				return si >= 0 && sj < 0
+			case reverse:
+				return si < sj
+			default:
+				return si > sj
+			}
+		})
 	}
+	return entries
+}
+
+// entrySize returns the size of the entry, or -1 if it is unknown because
+// the entry is incomplete or inconsistent.
+func entrySize(e *upspin.DirEntry) int64 {
+	if e.IsIncomplete() {
+		return -1
+	}
+	size, err := e.Size()
+	if err != nil {
+		return -1
+	}
+	return size
+}
+
+// filterEntries returns the entries whose times are at or after since
+// and before before, either of which is ignored if zero.
+func filterEntries(entries []*upspin.DirEntry, since, before time.Time) []*upspin.DirEntry {
+	if since.IsZero() && before.IsZero() {
+		return entries
+	}
+	var out []*upspin.DirEntry
+	for _, e := range entries {
+		t := e.Time.Go()
+		if !since.IsZero() && t.Before(since) {
+			continue
+		}
+		if !before.IsZero() && !t.Before(before) {
+			continue
+		}
+		out = append(out, e)
+	}
+	return out
+}
+
+// parseTimeFlag parses the value of a time flag, a date, an RFC 3339
+// time, or a duration before now.
+func parseTimeFlag(value string, now time.Time) (time.Time, error) {
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	return time.Time{}, errors.Errorf("bad time %q; want a date, RFC 3339 time or duration", value)
 }
 
 func hasFinalSlash(name upspin.PathName) bool {
@@ -102,7 +293,11 @@ func (s *State) printShortDirEntries(de []*upspin.DirEntry) {
 	}
 }
 
-func (s *State) printLongDirEntries(de []*upspin.DirEntry) {
+func (s *State) printLongDirEntries(de []*upspin.DirEntry, fullTime bool) {
+	timeFormat := "Mon Jan _2 15:04:05"
+	if fullTime {
+		timeFormat = time.RFC3339
+	}
 	seqWidth := 2
 	sizeWidth := 2
 	for _, e := range de {
@@ -151,13 +346,82 @@ func (s *State) printLongDirEntries(de []*upspin.DirEntry) {
 			packStr,
 			seqWidth, e.Sequence,
 			sizeWidth, s.sizeOf(e),
-			e.Time.Go().Local().Format("Mon Jan _2 15:04:05"),
+			e.Time.Go().Local().Format(timeFormat),
 			endpt,
 			e.Name,
 			redirect)
 	}
 }
 
+func (s *State) printExtendedDirEntries(de []*upspin.DirEntry, asJSON bool) {
+	for i, e := range de {
+		if asJSON {
+			data, err := json.MarshalIndent(e, "", "\t")
+			if err != nil {
+				s.Exit(err)
+			}
+			s.Printf("%s\n", data)
+			continue
+		}
+		if i > 0 {
+			s.Printf("\n")
+		}
+		s.Printf("%s:\n", e.Name)
+		s.Printf("\tname: %s\n", e.Name)
+		if e.SignedName != e.Name {
+			s.Printf("\tsignedname: %s\n", e.SignedName)
+		}
+		s.Printf("\tattr: %s\n", attrString(e.Attr))
+		if e.IsLink() {
+			s.Printf("\tlink: %s\n", e.Link)
+		}
+		s.Printf("\tpacking: %s\n", e.Packing)
+		s.Printf("\twriter: %s\n", e.Writer)
+		s.Printf("\ttime: %s\n", e.Time.Go().Format(time.RFC3339))
+		s.Printf("\tsequence: %d\n", e.Sequence)
+		var blockBytes int64
+		for _, b := range e.Blocks {
+			blockBytes += b.Size
+		}
+		if size, err := e.Size(); err != nil {
+			s.Printf("\tsize: %v\n", err)
+		} else {
+			s.Printf("\tsize: %d\n", size)
+		}
+		s.Printf("\tblockbytes: %d\n", blockBytes)
+		s.Printf("\tblocks: %d\n", len(e.Blocks))
+		for j, b := range e.Blocks {
+			s.Printf("\tblock %d: offset %d size %d location %s %s\n", j, b.Offset, b.Size, b.Location.Endpoint, b.Location.Reference)
+		}
+	}
+}
+
+// attrString returns the names of the bits set in the attribute,
+// separated by '|', or "none" if no bits are set.
+func attrString(attr upspin.Attribute) string {
+	if attr == upspin.AttrNone {
+		return "none"
+	}
+	var names []string
+	for _, a := range []struct {
+		bit  upspin.Attribute
+		name string
+	}{
+		{upspin.AttrDirectory, "directory"},
+		{upspin.AttrLink, "link"},
+		{upspin.AttrIncomplete, "incomplete"},
+	} {
+		if attr&a.bit != 0 {
+			names = append(names, a.name)
+			attr &^= a.bit
+		}
+	}
+	if attr != 0 {
+		names = append(names, fmt.Sprintf("%#x", uint8(attr)))
+	}
+	return strings.Join(names, "|")
+}
+
 func (s *State) sizeOf(e *upspin.DirEntry) int64 {
 	size, err := e.Size()
 	if err != nil {
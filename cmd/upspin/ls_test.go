@@ -0,0 +1,118 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"upspin.io/upspin"
+)
+
+// lsEntry returns a file entry with the given time and size. A negative
+// size makes an incomplete entry, whose size is unknown.
+func lsEntry(name string, t upspin.Time, size int64) *upspin.DirEntry {
+	e := &upspin.DirEntry{
+		Name: upspin.PathName("ann@example.com/" + name),
+		Time: t,
+	}
+	if size < 0 {
+		e.Attr = upspin.AttrIncomplete
+		return e
+	}
+	e.Blocks = []upspin.DirBlock{{Size: size}}
+	return e
+}
+
+func lsEntries() []*upspin.DirEntry {
+	return []*upspin.DirEntry{
+		lsEntry("a", 300, 10),
+		lsEntry("b", 100, 30),
+		lsEntry("c", 200, -1), // Incomplete.
+		lsEntry("d", 300, 30), // Same time as a, same size as b.
+		lsEntry("e", 400, 20),
+	}
+}
+
+func entryNames(entries []*upspin.DirEntry) string {
+	var names []string
+	for _, e := range entries {
+		names = append(names, strings.TrimPrefix(string(e.Name), "ann@example.com/"))
+	}
+	return strings.Join(names, " ")
+}
+
+func TestSortEntries(t *testing.T) {
+	tests := []struct {
+		by      sortKey
+		reverse bool
+		want    string
+	}{
+		{sortByName, false, "a b c d e"},
+		{sortByName, true, "e d c b a"},
+		{sortByTime, false, "e a d c b"},
+		{sortByTime, true, "b c a d e"},
+		{sortBySize, false, "b d e a c"},
+		{sortBySize, true, "a e b d c"},
+	}
+	for _, test := range tests {
+		got := entryNames(sortEntries(lsEntries(), test.by, test.reverse))
+		if got != test.want {
+			t.Errorf("sort by %d, reverse %t: got %q, want %q", test.by, test.reverse, got, test.want)
+		}
+	}
+}
+
+func TestFilterEntries(t *testing.T) {
+	at := func(t upspin.Time) time.Time { return t.Go() }
+	var zero time.Time
+	tests := []struct {
+		since, before time.Time
+		by            sortKey
+		want          string
+	}{
+		{zero, zero, sortByName, "a b c d e"},
+		{at(300), zero, sortByName, "a d e"},
+		{zero, at(300), sortByName, "b c"},
+		{at(200), at(400), sortByName, "a c d"},
+		{at(200), at(400), sortByTime, "a d c"},
+		{at(200), zero, sortBySize, "d e a c"},
+		{at(500), zero, sortByTime, ""},
+	}
+	for _, test := range tests {
+		got := entryNames(filterEntries(sortEntries(lsEntries(), test.by, false), test.since, test.before))
+		if got != test.want {
+			t.Errorf("since %v, before %v, sort by %d: got %q, want %q", test.since, test.before, test.by, got, test.want)
+		}
+	}
+}
+
+func TestParseTimeFlag(t *testing.T) {
+	now := time.Date(2017, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"36h", now.Add(-36 * time.Hour)},
+		{"2017-05-01T10:00:00Z", time.Date(2017, 5, 1, 10, 0, 0, 0, time.UTC)},
+		{"2017-05-01", time.Date(2017, 5, 1, 0, 0, 0, 0, time.Local)},
+	}
+	for _, test := range tests {
+		got, err := parseTimeFlag(test.value, now)
+		if err != nil {
+			t.Errorf("%q: %v", test.value, err)
+			continue
+		}
+		if !got.Equal(test.want) {
+			t.Errorf("%q: got %v, want %v", test.value, got, test.want)
+		}
+	}
+	for _, bad := range []string{"", "yesterday", "2017-13-01", "36"} {
+		if _, err := parseTimeFlag(bad, now); err == nil {
+			t.Errorf("%q: no error", bad)
+		}
+	}
+}
@@ -76,6 +76,7 @@ For a list of available subcommands and global flags, run
 `
 
 var commands = map[string]func(*State, ...string){
+	"access-history":     (*State).accessHistory,
 	"countersign":        (*State).countersign,
 	"cp":                 (*State).cp,
 	"config":             (*State).config,
@@ -90,15 +91,19 @@ var commands = map[string]func(*State, ...string){
 	"mkdir":              (*State).mkdir,
 	"put":                (*State).put,
 	"repack":             (*State).repack,
+	"revokekey":          (*State).revokekey,
 	"rotate":             (*State).rotate,
 	"rm":                 (*State).rm,
 	"setupdomain":        (*State).setupdomain,
 	"setupserver":        (*State).setupserver,
+	"setupsystemd":       (*State).setupsystemd,
 	"setupwriters":       (*State).setupwriters,
 	"share":              (*State).share,
 	"signup":             (*State).signup,
 	"snapshot":           (*State).snapshot,
+	"snapshot-restore":   (*State).snapshotRestore,
 	"tar":                (*State).tar,
+	"transfer-domain":    (*State).transferDomain,
 	"user":               (*State).user,
 	"watch":              (*State).watch,
 	"whichaccess":        (*State).whichAccess,
@@ -114,7 +119,9 @@ var externalCommands = []string{
 type State struct {
 	*subcmd.State
 	sharer     *Sharer
-	configFile []byte // The contents of the config file we loaded.
+	configFile []byte   // The contents of the config file we loaded.
+	configErr  error    // The error loading it, if the command is config.
+	timings    *timings // The RPCs made, if -timing is set.
 }
 
 func main() {
@@ -144,7 +151,7 @@ func setup(fs *flag.FlagSet, args []string) (*State, []string, bool) {
 	log.SetFlags(0)
 	log.SetPrefix("upspin: ")
 	fs.Usage = usage
-	flags.ParseArgsInto(fs, args, flags.Client, "version")
+	flags.ParseArgsInto(fs, args, flags.Client, "version", "timing")
 	if flags.Version {
 		fmt.Fprint(os.Stdout, version.Version())
 		os.Exit(2)
@@ -154,6 +161,9 @@ func setup(fs *flag.FlagSet, args []string) (*State, []string, bool) {
 	}
 	state := newState(strings.ToLower(fs.Arg(0)))
 	state.init()
+	if flags.Timing {
+		state.startTiming()
+	}
 	// Start the cache if needed.
 	if !strings.Contains(state.Name, "setup") && !strings.Contains(state.Name, "signup") {
 		cacheutil.Start(state.Config)
@@ -285,8 +295,9 @@ func newState(name string) *State {
 // usually including setting up a Config.
 func (s *State) init() {
 	// signup is special since there is no user yet.
-	// keygen simply does not require a config or anything else.
-	if s.Name != "signup" && s.Name != "keygen" {
+	// keygen simply does not require a config or anything else,
+	// and setupsystemd runs on a server that need not have one.
+	if s.Name != "signup" && s.Name != "keygen" && s.Name != "setupsystemd" {
 		// Read the config file and pass it to config.InitConfig
 		// instead of calling config.FromFile, so that we can stash its
 		// contents away for later use by the "config" sub-command.
@@ -307,14 +318,19 @@ func (s *State) init() {
 			s.Exit(err)
 		}
 
+		s.configFile = data
 		cfg, err := config.InitConfig(bytes.NewReader(data))
 		if err != nil && err != config.ErrNoFactotum {
-			s.Exit(err)
+			if s.Name != "config" {
+				s.Exit(err)
+			}
+			// Config -check will diagnose the problem.
+			s.configErr = err
+		} else {
+			transports.Init(cfg)
+			s.State.Init(cfg)
+			s.sharer = newSharer(s)
 		}
-		transports.Init(cfg)
-		s.State.Init(cfg)
-		s.sharer = newSharer(s)
-		s.configFile = data
 	}
 	s.enableMetrics()
 }
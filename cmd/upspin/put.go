@@ -6,6 +6,8 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"strings"
 
 	"upspin.io/access"
 	"upspin.io/client"
@@ -13,6 +15,7 @@ import (
 	"upspin.io/pack"
 	"upspin.io/path"
 	"upspin.io/subcmd"
+	"upspin.io/upspin"
 )
 
 func (s *State) put(args ...string) {
@@ -23,12 +26,18 @@ entry with the given path name to refer to the data.
 The -glob flag can be set to false to have put skip Glob processing,
 treating its arguments as literal text even if they contain special
 characters. (Leading @ signs are always expanded.)
+
+The -attr flag, which may be repeated, records a user-defined attribute
+name=value in the directory entry, such as -attr=content-type=text/plain.
+Attributes are signed along with the data and shown by upspin info.
 `
 	fs := flag.NewFlagSet("put", flag.ExitOnError)
 	inFile := fs.String("in", "", "input file (default standard input)")
 	packing := fs.String("packing", "", "packing to use (default from user's config)")
 	glob := globFlag(fs)
-	s.ParseFlags(fs, args, help, "put [-in=inputfile] path")
+	attrs := make(attrFlag)
+	fs.Var(attrs, "attr", "record attribute `name=value` in the entry (may be repeated)")
+	s.ParseFlags(fs, args, help, "put [-in=inputfile] [-attr=name=value]... path")
 
 	if fs.NArg() != 1 {
 		usageAndExit(fs)
@@ -60,7 +69,15 @@ characters. (Leading @ signs are always expanded.)
 		}
 		cl = client.New(config.SetPacking(s.Config, p.Packing()))
 	}
-	_, err = cl.Put(name, data)
+	if len(attrs) > 0 {
+		ap, ok := cl.(optionsPutter)
+		if !ok {
+			s.Exitf("client cannot record attributes")
+		}
+		_, err = ap.PutWithOptions(name, data, client.PutOptions{Attributes: attrs})
+	} else {
+		_, err = cl.Put(name, data)
+	}
 	if err != nil {
 		s.Exit(err)
 	}
@@ -74,3 +91,27 @@ characters. (Leading @ signs are always expanded.)
 		}
 	}
 }
+
+// optionsPutter is implemented by clients that accept PutOptions,
+// with which they can record attributes and resume uploads.
+type optionsPutter interface {
+	PutWithOptions(name upspin.PathName, data []byte, opts client.PutOptions) (*upspin.DirEntry, error)
+}
+
+// attrFlag implements flag.Value for the repeatable -attr flag.
+type attrFlag map[string][]byte
+
+// String implements flag.Value.
+func (a attrFlag) String() string {
+	return ""
+}
+
+// Set implements flag.Value.
+func (a attrFlag) Set(s string) error {
+	i := strings.Index(s, "=")
+	if i <= 0 {
+		return fmt.Errorf("attribute %q not of form name=value", s)
+	}
+	a[s[:i]] = []byte(s[i+1:])
+	return nil
+}
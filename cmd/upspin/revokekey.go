@@ -0,0 +1,84 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+
+	"upspin.io/factotum"
+	"upspin.io/upspin"
+)
+
+func (s *State) revokekey(args ...string) {
+	const help = `
+Revokekey marks a public key as revoked in the key server, for use
+when the corresponding secret key has been compromised. Servers refuse
+to authenticate sessions for a revoked key and drop any established
+sessions that used it within a minute or so.
+
+By default the current key of the current user is revoked. The -key
+flag names a file holding a different public key to revoke, such as
+an older key or a key of another user named with the -user flag.
+
+The revocation is signed with the key being revoked if it is held by
+the current user's factotum (the current key or one of its archived
+predecessors). Otherwise it is signed with the current user's key,
+which the key server accepts only from an administrator of the
+target user's domain.
+
+A revoked key cannot be reinstated. After revoking their own key, a
+user must have a domain administrator install a new one, for example
+with 'upspin user -put'.
+`
+	fs := flag.NewFlagSet("revokekey", flag.ExitOnError)
+	userFlag := fs.String("user", "", "user whose key to revoke (default current user)")
+	keyFile := fs.String("key", "", "file holding the public key to revoke (default current key)")
+	s.ParseFlags(fs, args, help, "revokekey [-user=name] [-key=file]")
+	if fs.NArg() != 0 {
+		usageAndExit(fs)
+	}
+
+	f := s.Config.Factotum()
+	if f == nil {
+		s.Exitf("no factotum available")
+	}
+	userName := s.Config.UserName()
+	if *userFlag != "" {
+		userName = upspin.UserName(*userFlag)
+	}
+	r, ok := s.KeyServer().(upspin.KeyRevoker)
+	if !ok {
+		s.Exitf("key server cannot revoke keys")
+	}
+	key := f.PublicKey()
+	if *keyFile != "" {
+		key = upspin.PublicKey(s.ReadAll(s.GlobOneLocal(*keyFile)))
+	} else if userName != s.Config.UserName() {
+		s.Exitf("-key must be set when revoking another user's key")
+	}
+
+	// Sign with the key being revoked if we have it; otherwise sign
+	// with the current key as an administrator.
+	signer := f
+	for g := f; ; {
+		if g.PublicKey() == key {
+			signer = g
+			break
+		}
+		prev := g.Pop()
+		if prev.PublicKey() == g.PublicKey() {
+			break
+		}
+		g = prev
+	}
+	sig, err := signer.Sign(factotum.RevokeHash(key))
+	if err != nil {
+		s.Exit(err)
+	}
+	err = r.RevokeKey(userName, key, []byte(factotum.FormatSignature(sig)))
+	if err != nil {
+		s.Exit(err)
+	}
+}
@@ -5,9 +5,16 @@
 package main
 
 import (
+	"encoding/json"
 	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"upspin.io/config"
+	"upspin.io/factotum"
+	"upspin.io/upspin"
 )
 
 func (s *State) rotate(args ...string) {
@@ -30,14 +37,35 @@ with the old key to use the new key.
 Some of these steps could be folded together but the full sequence
 makes it easier to recover if a step fails.
 
+The -all flag runs the whole sequence, using the secrets directory
+from the config. Progress is recorded in the file rotate.state in that
+directory, so if a step fails, running rotate -all again resumes
+where it left off. Rotate -all refuses to continue a recorded rotation
+to a key other than the current one. With -dry-run, it prints the
+steps that remain without running them.
+
 TODO: Rotate and countersign are terms of art, not clear to users.
 `
 	fs := flag.NewFlagSet("rotate", flag.ExitOnError)
-	s.ParseFlags(fs, args, help, "rotate")
+	all := fs.Bool("all", false, "run the complete key rotation sequence")
+	dryRun := fs.Bool("dry-run", false, "with -all, print the remaining steps without running them")
+	s.ParseFlags(fs, args, help, "rotate [-all [-dry-run]]")
 	if fs.NArg() != 0 {
 		usageAndExit(fs)
 	}
+	if *dryRun && !*all {
+		s.Exitf("-dry-run requires -all")
+	}
+	if *all {
+		s.rotateAll(*dryRun)
+		return
+	}
+	s.rotateKey()
+}
 
+// rotateKey pushes the current key to the key server, authenticating
+// with the previous key.
+func (s *State) rotateKey() {
 	f := s.Config.Factotum()
 	if f == nil {
 		s.Exitf("no factotum available")
@@ -63,3 +91,141 @@ TODO: Rotate and countersign are terms of art, not clear to users.
 		s.Exit(err)
 	}
 }
+
+// rotateStateFile is the name of the file, in the secrets directory,
+// that records the progress of rotate -all.
+const rotateStateFile = "rotate.state"
+
+// rotation records the progress of rotate -all.
+type rotation struct {
+	OldKey upspin.PublicKey // The key being replaced.
+	NewKey upspin.PublicKey // The replacement, once generated.
+	Done   int              // Number of steps completed.
+}
+
+// The steps of rotate -all, in order.
+const (
+	stepKeygen = iota
+	stepCountersign
+	stepRotate
+	stepShare
+	numRotateSteps
+)
+
+// rotateAll runs, or with dryRun describes, the remaining steps of a
+// key rotation, recording progress so that it can be resumed.
+func (s *State) rotateAll(dryRun bool) {
+	f := s.Config.Factotum()
+	if f == nil {
+		s.Exitf("no factotum available")
+	}
+	dir := s.Config.Value("secrets")
+	if dir == "" {
+		var err error
+		dir, err = config.DefaultSecretsDir(s.Config.UserName())
+		if err != nil {
+			s.Exit(err)
+		}
+	}
+	stateFile := filepath.Join(dir, rotateStateFile)
+	root := upspin.PathName(s.Config.UserName()) + "/"
+
+	r := s.readRotation(stateFile)
+	switch {
+	case r == nil:
+		r = &rotation{OldKey: f.PublicKey()}
+	case r.Done == stepKeygen:
+		// Keygen may have run without its completion being recorded.
+		if f.PublicKey() != r.OldKey {
+			if f.Pop().PublicKey() != r.OldKey {
+				s.Exitf("incomplete rotation from a different key recorded in %s", stateFile)
+			}
+			r.NewKey = f.PublicKey()
+			r.Done = stepCountersign
+		}
+	case f.PublicKey() != r.NewKey:
+		s.Exitf("incomplete rotation to a different key recorded in %s", stateFile)
+	}
+
+	if dryRun {
+		steps := []string{
+			"keygen -rotate " + dir,
+			"countersign",
+			"rotate",
+			"share -r -fix " + string(root),
+		}
+		for i := r.Done; i < numRotateSteps; i++ {
+			s.Printf("step %d: %s\n", i+1, steps[i])
+		}
+		return
+	}
+
+	for ; r.Done < numRotateSteps; r.Done++ {
+		s.writeRotation(stateFile, r)
+		switch r.Done {
+		case stepKeygen:
+			curve := string(r.OldKey)
+			if i := strings.IndexByte(curve, '\n'); i >= 0 {
+				curve = curve[:i]
+			}
+			s.keygenCommand(dir, curve, "", "", true)
+			nf, err := factotum.NewFromDir(dir)
+			if err != nil {
+				s.Exit(err)
+			}
+			s.State.Init(config.SetFactotum(s.Config, nf))
+			r.NewKey = nf.PublicKey()
+		case stepCountersign:
+			s.countersign()
+			if s.ExitCode != 0 {
+				s.Exitf("countersign failed; fix the problem and run rotate -all again")
+			}
+		case stepRotate:
+			s.rotateKey()
+			u, err := s.KeyServer().Lookup(s.Config.UserName())
+			if err != nil {
+				s.Exit(err)
+			}
+			if u.PublicKey != r.NewKey {
+				s.Exitf("key server does not yet show the new key; run rotate -all again")
+			}
+		case stepShare:
+			s.share("-q", "-r", "-fix", string(root))
+			if s.ExitCode != 0 {
+				s.Exitf("share failed; fix the problem and run rotate -all again")
+			}
+		}
+	}
+	if err := os.Remove(stateFile); err != nil {
+		s.Exit(err)
+	}
+	fmt.Fprintln(s.Stderr, "Key rotation complete.")
+}
+
+// readRotation returns the rotation recorded in the file,
+// or nil if there is none.
+func (s *State) readRotation(file string) *rotation {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		s.Exit(err)
+	}
+	r := new(rotation)
+	if err := json.Unmarshal(data, r); err != nil {
+		s.Exitf("bad rotation state in %s: %v", file, err)
+	}
+	return r
+}
+
+// writeRotation records the rotation in the file.
+func (s *State) writeRotation(file string, r *rotation) {
+	data, err := json.Marshal(r)
+	if err != nil {
+		s.Exit(err)
+	}
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		s.Exit(err)
+	}
+}
@@ -40,6 +40,9 @@ additional writers with the -writers flag. For instance, if you want all users
 @example.com to be able to access storage, specify "-writers=*@example.com".
 
 The calling user must be the same one that ran 'upspin setupdomain'.
+
+To run upspinserver as a systemd service, run 'upspin setupsystemd' on
+the server.
 `
 	)
 	fs := flag.NewFlagSet("setupserver", flag.ExitOnError)
@@ -0,0 +1,250 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"upspin.io/config"
+	"upspin.io/subcmd"
+)
+
+// systemdUnitFile is where -install writes the unit.
+const systemdUnitFile = "/etc/systemd/system/upspinserver.service"
+
+func (s *State) setupsystemd(args ...string) {
+	const help = `
+Setupsystemd writes a systemd unit file that runs upspinserver as a
+service, restarting it if it fails. It is intended to be run on the
+server machine, and does not need an Upspin configuration.
+
+The service runs as the Unix account given by the -user flag, under a
+sandbox that lets the server write only to its configuration directory,
+its Let's Encrypt cache, and, for disk storage, its storage directory,
+and lets it listen on ports 80 and 443 without running as root or
+setting capabilities on the binary with setcap.
+
+By default the binary is upspinserver in the account's home directory,
+or if there is none there, the one found in $PATH, and the directories
+are those upspinserver uses by default, in $HOME/upspin. If the -domain
+flag is given, the storage directory is that configured by setupstorage
+in $where/$domain, if it is on local disk.
+
+The unit file is written to standard output, or with -install, to
+/etc/systemd/system/upspinserver.service, after which the service is
+enabled and started. The -install flag requires running as root,
+perhaps via sudo.
+`
+	fs := flag.NewFlagSet("setupsystemd", flag.ExitOnError)
+	where := fs.String("where", filepath.Join(config.Home(), "upspin", "deploy"), "`directory` containing private configuration files")
+	domain := fs.String("domain", "", "domain `name` for this Upspin installation, if setupstorage has been run on this machine")
+	userFlag := fs.String("user", "upspin", "Unix `account` to run upspinserver as")
+	home := fs.String("home", "", "home `directory` of the account (default /home/$user)")
+	binary := fs.String("binary", "", "`path` of the upspinserver binary (default $home/upspinserver or from $PATH)")
+	serverArgs := fs.String("args", "", "additional `flags` for upspinserver, such as -web")
+	restart := fs.String("restart", "on-failure", "systemd Restart `policy`")
+	install := fs.Bool("install", false, "install, enable, and start the service")
+	s.ParseFlags(fs, args, help, "setupsystemd [-user=upspin] [-domain=<domain>] [-binary=<path>] [-args=<flags>] [-install]")
+	if fs.NArg() != 0 {
+		usageAndExit(fs)
+	}
+
+	opts := unitOptions{
+		User:    *userFlag,
+		Restart: *restart,
+		Args:    strings.Fields(*serverArgs),
+	}
+	if *home == "" {
+		*home = filepath.Join("/home", opts.User)
+	}
+	opts.Binary = *binary
+	if opts.Binary == "" {
+		opts.Binary = findServerBinary(*home)
+	}
+	opts.ServerConfig = filepath.Join(*home, "upspin", "server")
+	opts.LetsCache = filepath.Join(*home, "upspin", "letsencrypt")
+	if *domain != "" {
+		cfg := s.ReadServerConfig(filepath.Join(subcmd.Tilde(*where), *domain))
+		opts.Storage = diskStoragePath(cfg.StoreConfig)
+	}
+
+	var buf bytes.Buffer
+	if err := writeSystemdUnit(&buf, opts); err != nil {
+		s.Exit(err)
+	}
+	if !*install {
+		s.Stdout.Write(buf.Bytes())
+		fmt.Fprintf(s.Stderr, "To install the service, as root write the unit to %s and run\n", systemdUnitFile)
+		fmt.Fprintf(s.Stderr, "\tsystemctl daemon-reload\n\tsystemctl enable --now upspinserver\n")
+		return
+	}
+	if err := os.WriteFile(systemdUnitFile, buf.Bytes(), 0644); err != nil {
+		s.Exitf("%v\nThe -install flag must be run as root.", err)
+	}
+	for _, args := range [][]string{
+		{"daemon-reload"},
+		{"enable", "--now", "upspinserver"},
+	} {
+		cmd := exec.Command("systemctl", args...)
+		cmd.Stdout = s.Stdout
+		cmd.Stderr = s.Stderr
+		if err := cmd.Run(); err != nil {
+			s.Exitf("systemctl %s: %v", strings.Join(args, " "), err)
+		}
+	}
+	fmt.Fprintf(s.Stderr, "Installed and started upspinserver.service.\nSee its logs with 'journalctl -f -u upspinserver'.\n")
+}
+
+// findServerBinary returns the path of the upspinserver binary: the one
+// in home, where the setup instructions place it, if present, or else
+// the one in $PATH.
+func findServerBinary(home string) string {
+	file := filepath.Join(home, "upspinserver")
+	if _, err := os.Stat(file); err == nil {
+		return file
+	}
+	if file, err := exec.LookPath("upspinserver"); err == nil {
+		if abs, err := filepath.Abs(file); err == nil {
+			return abs
+		}
+	}
+	return file
+}
+
+// diskStoragePath returns the directory of a Disk storage backend
+// configured by storeConfig, or the empty string if there is none.
+func diskStoragePath(storeConfig []string) string {
+	disk := false
+	path := ""
+	for _, opt := range storeConfig {
+		switch {
+		case opt == "backend=Disk":
+			disk = true
+		case strings.HasPrefix(opt, "basePath="):
+			path = strings.TrimPrefix(opt, "basePath=")
+		}
+	}
+	if !disk {
+		return ""
+	}
+	return path
+}
+
+// unitOptions describes the service written by writeSystemdUnit.
+type unitOptions struct {
+	User         string   // Unix account running the server.
+	Binary       string   // Path of upspinserver.
+	ServerConfig string   // The -serverconfig directory.
+	LetsCache    string   // The -letscache directory.
+	Storage      string   // Disk storage directory, if any.
+	Args         []string // Additional flags.
+	Restart      string   // Restart policy.
+}
+
+// ExecStart returns the command line of the service, quoted for systemd.
+func (o unitOptions) ExecStart() string {
+	args := []string{
+		o.Binary,
+		"-serverconfig=" + o.ServerConfig,
+		"-letscache=" + o.LetsCache,
+	}
+	// Let systemd restart the server if it cannot reach what it depends on.
+	if o.Restart != "no" {
+		args = append(args, "-restart-on-dependency-failure")
+	}
+	args = append(args, o.Args...)
+	for i, arg := range args {
+		// Only command lines expand environment variables.
+		args[i] = systemdQuote(strings.ReplaceAll(arg, "$", "$$"))
+	}
+	return strings.Join(args, " ")
+}
+
+// WritePaths returns the directories the service may write, quoted for
+// systemd. Those within others are omitted.
+func (o unitOptions) WritePaths() string {
+	dirs := []string{o.ServerConfig, o.LetsCache}
+	if o.Storage != "" {
+		dirs = append(dirs, filepath.Clean(o.Storage))
+	}
+	sort.Strings(dirs)
+	var paths []string
+	for _, dir := range dirs {
+		if n := len(paths); n > 0 {
+			last := paths[n-1]
+			if dir == last || strings.HasPrefix(dir, last+"/") {
+				continue
+			}
+		}
+		paths = append(paths, dir)
+	}
+	for i, p := range paths {
+		paths[i] = systemdQuote(p)
+	}
+	return strings.Join(paths, " ")
+}
+
+// systemdQuote quotes s, if necessary, as a word of a systemd setting,
+// escaping specifiers such as %h.
+func systemdQuote(s string) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\%;") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `%`, `%%`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func writeSystemdUnit(w io.Writer, opts unitOptions) error {
+	if opts.Restart == "" {
+		opts.Restart = "on-failure"
+	}
+	return systemdUnitTemplate.Execute(w, opts)
+}
+
+var systemdUnitTemplate = template.Must(template.New("unit").Parse(`[Unit]
+Description=Upspin server
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+ExecStart={{.ExecStart}}
+User={{.User}}
+Group={{.User}}
+Restart={{.Restart}}
+RestartSec=5
+
+# Listen on ports 80 and 443 without running as root.
+AmbientCapabilities=CAP_NET_BIND_SERVICE
+CapabilityBoundingSet=CAP_NET_BIND_SERVICE
+NoNewPrivileges=true
+
+# Allow writes only to the server's own directories.
+ProtectSystem=strict
+ProtectHome=read-only
+ReadWritePaths={{.WritePaths}}
+PrivateTmp=true
+PrivateDevices=true
+ProtectKernelTunables=true
+ProtectKernelModules=true
+ProtectControlGroups=true
+RestrictAddressFamilies=AF_INET AF_INET6 AF_UNIX
+RestrictNamespaces=true
+RestrictRealtime=true
+LockPersonality=true
+MemoryDenyWriteExecute=true
+SystemCallArchitectures=native
+
+[Install]
+WantedBy=multi-user.target
+`))
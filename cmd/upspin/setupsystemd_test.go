@@ -0,0 +1,106 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "update the golden files in testdata")
+
+func TestSystemdUnit(t *testing.T) {
+	tests := []struct {
+		golden string
+		opts   unitOptions
+	}{
+		{
+			golden: "default.service",
+			opts: unitOptions{
+				User:         "upspin",
+				Binary:       "/home/upspin/upspinserver",
+				ServerConfig: "/home/upspin/upspin/server",
+				LetsCache:    "/home/upspin/upspin/letsencrypt",
+			},
+		},
+		{
+			// Storage on another disk, and the web interface.
+			golden: "storage.service",
+			opts: unitOptions{
+				User:         "upspin",
+				Binary:       "/usr/local/bin/upspinserver-gcp",
+				ServerConfig: "/home/upspin/upspin/server",
+				LetsCache:    "/home/upspin/upspin/letsencrypt",
+				Storage:      "/data/upspin/storage/",
+				Args:         []string{"-web", "-share"},
+				Restart:      "always",
+			},
+		},
+		{
+			// Storage within the server directory is not listed,
+			// nor is the server asked to exit if it is never restarted.
+			golden: "norestart.service",
+			opts: unitOptions{
+				User:         "upspin",
+				Binary:       "/home/upspin/upspinserver",
+				ServerConfig: "/home/upspin/upspin/server",
+				LetsCache:    "/home/upspin/upspin/letsencrypt",
+				Storage:      "/home/upspin/upspin/server/storage",
+				Restart:      "no",
+			},
+		},
+		{
+			// Paths that need quoting.
+			golden: "quoted.service",
+			opts: unitOptions{
+				User:         "store",
+				Binary:       "/srv/Upspin Server/upspinserver",
+				ServerConfig: "/srv/Upspin Server/config",
+				LetsCache:    "/srv/Upspin Server/certs",
+				Args:         []string{"-log=debug", "-addr=:8443"},
+			},
+		},
+	}
+	for _, test := range tests {
+		var buf bytes.Buffer
+		if err := writeSystemdUnit(&buf, test.opts); err != nil {
+			t.Fatal(err)
+		}
+		file := filepath.Join("testdata", "systemd", test.golden)
+		if *updateGolden {
+			if err := os.WriteFile(file, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != string(want) {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.golden, got, want)
+		}
+	}
+}
+
+func TestDiskStoragePath(t *testing.T) {
+	tests := []struct {
+		storeConfig []string
+		want        string
+	}{
+		{nil, ""},
+		{[]string{"backend=Disk", "basePath=/data/storage"}, "/data/storage"},
+		{[]string{"basePath=/data/storage", "backend=Disk"}, "/data/storage"},
+		{[]string{"backend=S3", "s3BucketName=upspin"}, ""},
+	}
+	for _, test := range tests {
+		if got := diskStoragePath(test.storeConfig); got != test.want {
+			t.Errorf("diskStoragePath(%q) = %q, want %q", test.storeConfig, got, test.want)
+		}
+	}
+}
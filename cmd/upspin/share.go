@@ -9,6 +9,7 @@ package main
 import (
 	"bytes"
 	"crypto/sha256"
+	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
@@ -40,6 +41,14 @@ the -unencryptforall flag in combination with -fix will rewrite the file
 using the EEIntegrity packing, decrypting it and making its contents
 visible to anyone.
 
+Given the -json flag, share instead writes to standard output a JSON
+array with an object for each file, holding its path, the users the
+Access files allow to read and to write it, and whether the keys stored
+in its directory metadata are inconsistent with its readers:
+
+	[{"path":"ann@example.com/dir/file","readers":["ann@example.com"],
+	  "writers":["ann@example.com"],"inconsistent":false}]
+
 The -glob flag can be set to false to have share skip Glob processing,
 treating its arguments as literal text even if they contain special
 characters. (Leading @ signs are always expanded.)
@@ -53,6 +62,7 @@ See the description for rotate for information about updating keys.
 	isDir := fs.Bool("d", false, "do all files in directory; path must be a directory")
 	recur := fs.Bool("r", false, "recur into subdirectories; path must be a directory. assumes -d")
 	unencryptForAll := fs.Bool("unencryptforall", false, "for currently encrypted read:all files only, rewrite using EEIntegrity; requires -fix or -force")
+	fs.Bool("json", false, "write the state of every file as JSON")
 	fs.Bool("q", false, "suppress output. Default is to show state for every file")
 	s.ParseFlags(fs, args, help, "share path...")
 	if fs.NArg() == 0 {
@@ -82,6 +92,7 @@ type Sharer struct {
 	isDir           bool
 	recur           bool
 	quiet           bool
+	json            bool
 	unencryptForAll bool
 
 	// accessFiles contains the parsed Access files, keyed by directory to which it applies.
@@ -90,6 +101,10 @@ type Sharer struct {
 	// users caches per-directory user lists computed from Access files.
 	users map[upspin.PathName]userList
 
+	// writers caches per-directory lists of users with write access.
+	// It is computed only for JSON output.
+	writers map[upspin.PathName]userList
+
 	// userKeys holds the keys we've looked up for each user.
 	userKeys map[upspin.UserName]upspin.PublicKey
 
@@ -102,6 +117,7 @@ func newSharer(s *State) *Sharer {
 		state:       s,
 		accessFiles: make(map[upspin.PathName]*access.Access),
 		users:       make(map[upspin.PathName]userList),
+		writers:     make(map[upspin.PathName]userList),
 		userKeys:    make(map[upspin.UserName]upspin.PublicKey),
 		userByHash:  make(map[[sha256.Size]byte]upspin.UserName),
 	}
@@ -115,6 +131,7 @@ func (s *State) shareCommand(fs *flag.FlagSet) {
 	s.sharer.isDir = subcmd.BoolFlag(fs, "d")
 	s.sharer.recur = subcmd.BoolFlag(fs, "r")
 	s.sharer.quiet = subcmd.BoolFlag(fs, "q")
+	s.sharer.json = subcmd.BoolFlag(fs, "json")
 	s.sharer.unencryptForAll = subcmd.BoolFlag(fs, "unencryptforall")
 
 	// To change things, User must be the owner of every file.
@@ -131,12 +148,10 @@ func (s *State) shareCommand(fs *flag.FlagSet) {
 	entries := s.sharer.allEntries(names)
 
 	// Collect the access files. We need only one per directory.
-	for _, e := range entries {
-		s.sharer.addAccess(e)
-	}
+	s.sharer.addAccessFiles(entries)
 
 	// Now we're ready. First show the state if asked.
-	if !s.sharer.quiet {
+	if !s.sharer.quiet && !s.sharer.json {
 		uNames := make(map[string][]string)
 		for _, u := range s.sharer.users {
 			uNames[u.String()] = nil
@@ -160,6 +175,7 @@ func (s *State) shareCommand(fs *flag.FlagSet) {
 	}
 
 	var entriesToFix []*upspin.DirEntry
+	var shares []shareJSON
 	printedDiscrepancyHeader := true
 
 	// Identify the entries we need to update.
@@ -167,9 +183,19 @@ func (s *State) shareCommand(fs *flag.FlagSet) {
 		if entry.IsDir() {
 			continue
 		}
+		if s.sharer.json {
+			dir := path.DropPath(entry.Name, 1)
+			shares = append(shares, shareJSON{
+				Path:    entry.Name,
+				Readers: s.sharer.users[dir].sorted(),
+				Writers: s.sharer.writers[dir].sorted(),
+			})
+		}
 		if s.sharer.force {
 			entriesToFix = append(entriesToFix, entry)
-			continue
+			if !s.sharer.json {
+				continue
+			}
 		}
 		packer := s.lookupPacker(entry)
 		if packer.Packing() == upspin.PlainPack || packer.Packing() == upspin.EEIntegrityPack {
@@ -182,7 +208,9 @@ func (s *State) shareCommand(fs *flag.FlagSet) {
 		}
 		userNameList := users.String()
 		if userNameList != keyUsers || self {
-			if !s.sharer.quiet || !s.sharer.fix {
+			if s.sharer.json {
+				shares[len(shares)-1].Inconsistent = true
+			} else if !s.sharer.quiet || !s.sharer.fix {
 				if !printedDiscrepancyHeader {
 					fmt.Fprintln(s.Stderr, "\nDiscrepancies between users in Access files and users in wrapped keys:")
 					printedDiscrepancyHeader = true
@@ -191,8 +219,21 @@ func (s *State) shareCommand(fs *flag.FlagSet) {
 				fmt.Fprintf(s.Stderr, "\tAccess: %s\n", users)
 				fmt.Fprintf(s.Stderr, "\tKeys:   %s\n", keyUsers)
 			}
-			entriesToFix = append(entriesToFix, entry)
+			if !s.sharer.force {
+				entriesToFix = append(entriesToFix, entry)
+			}
+		}
+	}
+
+	if s.sharer.json {
+		if shares == nil {
+			shares = []shareJSON{}
+		}
+		data, err := json.Marshal(shares)
+		if err != nil {
+			s.Exit(err)
 		}
+		s.Printf("%s\n", data)
 	}
 
 	// Repair the wrapped keys if necessary and requested.
@@ -207,6 +248,14 @@ func (s *State) shareCommand(fs *flag.FlagSet) {
 	}
 }
 
+// shareJSON is the JSON form of the state of a file printed by share -json.
+type shareJSON struct {
+	Path         upspin.PathName   `json:"path"`
+	Readers      []upspin.UserName `json:"readers"`
+	Writers      []upspin.UserName `json:"writers"`
+	Inconsistent bool              `json:"inconsistent"`
+}
+
 // readers returns two lists, the list of users with access according to the
 // access file, and the pretty-printed string of user names recovered from
 // looking at the list of hashed keys in the packdata.
@@ -243,26 +292,11 @@ func (s *Sharer) readers(entry *upspin.DirEntry) (userList, string, bool, error)
 				s.state.ExitCode = 1
 				continue
 			}
-			var h [sha256.Size]byte
-			copy(h[:], hash)
-			log.Debug.Printf("wrap %s %x\n", entry.Name, h)
-			var ok bool
-			thisUser, ok = s.userByHash[h]
-			if !ok {
-				// Check old keys in Factotum.
-				f := s.state.Config.Factotum()
-				if f == nil {
-					s.state.Exitf("no factotum available")
-				}
-				if _, err := f.PublicKeyFromHash(hash); err == nil {
-					thisUser = s.state.Config.UserName()
-					ok = true
-					self = true
-				}
-			}
-			if !ok && bytes.Equal(factotum.AllUsersKeyHash, hash) {
-				ok = true
-				thisUser = access.AllUsers
+			log.Debug.Printf("wrap %s %x\n", entry.Name, hash)
+			var ok, isSelf bool
+			thisUser, isSelf, ok = s.keyHolder(hash)
+			if isSelf {
+				self = true
 			}
 			if !ok && s.fix {
 				ok = true
@@ -287,6 +321,50 @@ func (s *Sharer) readers(entry *upspin.DirEntry) (userList, string, bool, error)
 	return users, keyUsers.String(), self, nil
 }
 
+// keyHolder returns the user for whom a key with the hash, one of the
+// reader hashes of an EE-packed entry, was wrapped. Only users whose keys
+// have been looked up are found. Self reports whether the key is one of
+// the current user's old keys, as held by the factotum.
+func (s *Sharer) keyHolder(hash []byte) (user upspin.UserName, self, ok bool) {
+	var h [sha256.Size]byte
+	copy(h[:], hash)
+	if user, ok := s.userByHash[h]; ok {
+		return user, false, true
+	}
+	// Check old keys in Factotum.
+	f := s.state.Config.Factotum()
+	if f == nil {
+		s.state.Exitf("no factotum available")
+	}
+	if _, err := f.PublicKeyFromHash(hash); err == nil {
+		return s.state.Config.UserName(), true, true
+	}
+	if bytes.Equal(factotum.AllUsersKeyHash, hash) {
+		return access.AllUsers, false, true
+	}
+	return "", false, false
+}
+
+// hasWrappedKey reports whether the packdata of the entry, which must be
+// EE-packed, holds a key wrapped for the user or for all users.
+func (s *Sharer) hasWrappedKey(entry *upspin.DirEntry, user upspin.UserName) (bool, error) {
+	packer := s.state.lookupPacker(entry)
+	if packer == nil || packer.Packing() != upspin.EEPack {
+		return false, errors.Errorf("%q does not have EE packing", entry.Name)
+	}
+	hashes, err := packer.ReaderHashes(entry.Packdata)
+	if err != nil {
+		return false, err
+	}
+	s.lookupKey(user)
+	for _, hash := range hashes {
+		if holder, _, ok := s.keyHolder(hash); ok && (holder == user || holder == access.AllUsers) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // allEntries expands the arguments to find all the DirEntries identifying items to examine.
 // The returned slice contains no directories and no links, only plain files.
 func (s *Sharer) allEntries(names []upspin.PathName) []*upspin.DirEntry {
@@ -359,16 +437,30 @@ func (s *State) lookupPacker(entry *upspin.DirEntry) upspin.Packer {
 	return packer
 }
 
-// addAccess loads an access file.
-func (s *Sharer) addAccess(entry *upspin.DirEntry) {
-	name := entry.Name
-	if !entry.IsDir() {
-		name = path.DropPath(name, 1) // Directory name for this file.
+// addAccessFiles loads the access files for the entries, which are
+// guaranteed to have no links. We need only one per directory.
+func (s *Sharer) addAccessFiles(entries []*upspin.DirEntry) {
+	var dirs, names []upspin.PathName
+	for _, entry := range entries {
+		name := entry.Name
+		if !entry.IsDir() {
+			name = path.DropPath(name, 1) // Directory name for this file.
+		}
+		if _, ok := s.accessFiles[name]; ok {
+			continue
+		}
+		s.accessFiles[name] = nil // Placeholder until loaded below.
+		dirs = append(dirs, name)
+		names = append(names, entry.Name)
 	}
-	if _, ok := s.accessFiles[name]; ok {
-		return
+	for i, res := range s.state.whichAccessAll(names) {
+		s.addAccess(dirs[i], res.Entry, res.Error)
 	}
-	which, err := s.state.DirServer(name).WhichAccess(entry.Name) // Guaranteed to have no links.
+}
+
+// addAccess loads the access file which, as returned with err by
+// WhichAccess, for the directory name.
+func (s *Sharer) addAccess(name upspin.PathName, which *upspin.DirEntry, err error) {
 	if err != nil {
 		s.state.Exitf("looking up access file %q: %s", name, err)
 	}
@@ -383,6 +475,9 @@ func (s *Sharer) addAccess(entry *upspin.DirEntry) {
 	}
 	s.accessFiles[name] = a
 	s.users[name] = s.state.usersWithAccess(s.state.Client, a, access.Read)
+	if s.json {
+		s.writers[name] = s.state.usersWithAccess(s.state.Client, a, access.Write)
+	}
 }
 
 // usersWithReadAccess returns the list of user names granted access by this access file.
@@ -529,6 +624,13 @@ func (u userList) Len() int           { return len(u) }
 func (u userList) Less(i, j int) bool { return u[i] < u[j] }
 func (u userList) Swap(i, j int)      { u[i], u[j] = u[j], u[i] }
 
+// sorted returns a sorted copy of the list, which is never nil.
+func (u userList) sorted() []upspin.UserName {
+	users := append([]upspin.UserName{}, u...)
+	sort.Sort(userList(users))
+	return users
+}
+
 // String returns a canonically formatted, sorted list of the users.
 func (u userList) String() string {
 	if u == nil {
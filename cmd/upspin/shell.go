@@ -8,6 +8,8 @@ import (
 	"bufio"
 	"flag"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
 )
 
@@ -22,6 +24,20 @@ associated with interactive shells. It is intended only for testing and is kept
 simple for reasons of comprehensibility, portability, and maintainability.
 Those who need quoting or line editing or other such features should use their
 regular shell and run upspinfs or invoke the upspin command line-by-line.
+Each line is split into words at white space, and there is no way to
+include white space in an argument.
+
+A # and the rest of the line after it are ignored, as are blank lines.
+The text $UPSPINUSER anywhere in a line is replaced by the current user's
+name. Lines typed are remembered in a history, which the history command
+lists with their numbers. A line beginning !! repeats the previous line
+and one beginning !n, where n is a number, repeats line n; any words
+after them are appended.
+
+The -f flag runs the commands in the named file rather than those read
+from standard input, and stops at the first command that fails, unless
+the -k flag is set. If any command fails, the shell exits with a
+non-zero status.
 
 The shell does have one convenience feature, though, in the handling of path
 names. A path beginning with a plain @ refers to the current user's root
@@ -32,30 +48,134 @@ but is particularly handy inside the shell.
 	fs := flag.NewFlagSet("shell", flag.ExitOnError)
 	promptFlag := fs.String("prompt", promptPlaceholder, "interactive `prompt`")
 	verbose := fs.Bool("v", false, "verbose; print to stderr each command before execution")
-	s.ParseFlags(fs, args, help, "shell [-v] [-prompt=<prompt_string>]")
+	file := fs.String("f", "", "run the commands in `file`")
+	keepGoing := fs.Bool("k", false, "with -f, keep going after a command fails")
+	s.ParseFlags(fs, args, help, "shell [-v] [-prompt=<prompt_string>] [-f=file [-k]]")
 	if fs.NArg() != 0 {
 		usageAndExit(fs)
 	}
-	prompt := func() {
-		if len(*promptFlag) > 0 {
-			fmt.Fprint(s.Stderr, *promptFlag)
-		}
+	if *keepGoing && *file == "" {
+		s.Exitf("-k requires -f")
 	}
 	if *promptFlag == promptPlaceholder {
 		*promptFlag = string(s.Config.UserName()) + ">"
 	}
+	sh := &shell{
+		state:     s,
+		verbose:   *verbose,
+		keepGoing: true,
+	}
+	in := s.Stdin
+	if *file != "" {
+		f := s.OpenLocal(*file)
+		defer f.Close()
+		in = f
+		*promptFlag = ""
+		sh.keepGoing = *keepGoing
+	}
 	s.Interactive = true
-	defer func() { s.Interactive = false }()
-	scanner := bufio.NewScanner(s.Stdin)
-	for prompt(); scanner.Scan(); prompt() {
-		s.exec(scanner.Text(), *verbose)
+	ok := sh.run(in, *promptFlag)
+	s.Interactive = false
+	s.Name = "shell"
+	if !ok {
+		s.ExitCode = 1
+	}
+}
+
+// shell holds the state of a shell session.
+type shell struct {
+	state     *State
+	verbose   bool
+	keepGoing bool     // Whether to continue after a command fails.
+	history   []string // Lines run so far.
+}
+
+// run runs the commands read from r, printing the prompt, if any,
+// before each. It reports whether all the commands succeeded.
+func (sh *shell) run(r io.Reader, prompt string) bool {
+	s := sh.state
+	ok := true
+	scanner := bufio.NewScanner(r)
+	for {
+		if prompt != "" {
+			fmt.Fprint(s.Stderr, prompt)
+		}
+		if !scanner.Scan() {
+			break
+		}
+		if !sh.line(scanner.Text()) {
+			ok = false
+			if !sh.keepGoing {
+				return false
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(s.Stderr, "upspin: shell: %v\n", err)
+		return false
+	}
+	return ok
+}
+
+// line runs a line of input, after expanding history references and
+// variables. It reports whether it succeeded.
+func (sh *shell) line(line string) bool {
+	s := sh.state
+	if sharp := strings.IndexByte(line, '#'); sharp >= 0 {
+		line = line[:sharp]
+	}
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return true
+	}
+	expanded, err := sh.expandHistory(line)
+	if err != nil {
+		fmt.Fprintf(s.Stderr, "upspin: shell: %v\n", err)
+		return false
+	}
+	if expanded != line {
+		// Show what will run, as other shells do.
+		fmt.Fprintln(s.Stderr, expanded)
+	}
+	sh.history = append(sh.history, expanded)
+	if expanded == "history" {
+		for i, h := range sh.history {
+			fmt.Fprintf(s.Stdout, "%5d  %s\n", i+1, h)
+		}
+		return true
+	}
+	expanded = strings.Replace(expanded, "$UPSPINUSER", string(s.Config.UserName()), -1)
+	return s.exec(expanded, sh.verbose)
+}
+
+// expandHistory replaces a leading !! or !n in the line with the previous
+// or nth line of the history.
+func (sh *shell) expandHistory(line string) (string, error) {
+	if !strings.HasPrefix(line, "!") {
+		return line, nil
+	}
+	words := strings.Fields(line)
+	ref := words[0][1:]
+	var n int
+	if ref == "!" {
+		n = len(sh.history)
+	} else {
+		var err error
+		n, err = strconv.Atoi(ref)
+		if err != nil {
+			return "", fmt.Errorf("bad history reference %q", words[0])
+		}
 	}
-	if scanner.Err() != nil {
-		s.Exit(scanner.Err())
+	if n < 1 || n > len(sh.history) {
+		return "", fmt.Errorf("%s: no such line in history", words[0])
 	}
+	return strings.Join(append([]string{sh.history[n-1]}, words[1:]...), " "), nil
 }
 
-func (s *State) exec(line string, verbose bool) {
+// exec runs the single command on the line. It reports whether the command
+// succeeded, that is, neither exited nor set a non-zero exit code. The state
+// must be interactive so exits can be recovered.
+func (s *State) exec(line string, verbose bool) (ok bool) {
 	defer func() {
 		err := recover()
 		if err != nil {
@@ -65,24 +185,24 @@ func (s *State) exec(line string, verbose bool) {
 				panic(err)
 			}
 		}
+		if err != nil || s.ExitCode != 0 {
+			ok = false
+		}
+		s.ExitCode = 0
 	}()
-	line = strings.TrimSpace(line)
-	sharp := strings.IndexByte(line, '#')
-	if sharp >= 0 {
-		line = line[:sharp]
-	}
 	words := strings.Fields(line)
 	if len(words) == 0 {
-		return
+		return true
 	}
 	fn := s.getCommand(strings.ToLower(words[0]))
 	if fn == nil {
 		fmt.Fprintf(s.Stderr, "upspin: no such command %q\n", words[0])
-		return
+		return false
 	}
 	if verbose {
 		fmt.Fprintln(s.Stderr, " + "+strings.Join(words, " "))
 	}
 	s.Name = words[0]
 	fn(s, words[1:]...)
+	return true
 }
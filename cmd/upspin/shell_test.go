@@ -0,0 +1,107 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"upspin.io/upbox"
+)
+
+// testShell runs scripts in the shell, both from standard input and
+// with -f, as ann. It is run by TestCommands.
+func testShell(t *testing.T, schema *upbox.Schema) {
+	state, _, ok := setup(flag.NewFlagSet("shell", flag.PanicOnError), []string{"-config=" + schema.Config(string(ann)), "shell"})
+	if !ok {
+		t.Fatal("setup failed; bad arg list?")
+	}
+	defer state.DefaultIO()
+
+	// runShell runs the shell with the arguments, feeding it stdin
+	// through a pipe, and returns its output and exit code.
+	runShell := func(stdin string, args ...string) (stdout, stderr string, code int) {
+		pr, pw := io.Pipe()
+		go func() {
+			io.WriteString(pw, stdin)
+			pw.Close()
+		}()
+		var out, errs bytes.Buffer
+		state.SetIO(pr, &out, &errs)
+		state.ExitCode = 0
+		state.shell(args...)
+		return out.String(), errs.String(), state.ExitCode
+	}
+
+	script := `
+# Comments and blank lines are ignored.
+mkdir @/shelltest
+mkdir $UPSPINUSER/shelltest/a # Trailing comment.
+
+ls @/shelltest
+!!
+ls @/shelltest/missing
+history
+mkdir @/shelltest/b
+!3 $UPSPINUSER/shelltest/b
+`
+	stdout, stderr, code := runShell(script, "-prompt=")
+	if code != 1 {
+		t.Errorf("exit code %d, want 1 after failed ls", code)
+	}
+	wantOut := `ann@example.com/shelltest/a/
+ann@example.com/shelltest/a/
+    1  mkdir @/shelltest
+    2  mkdir $UPSPINUSER/shelltest/a
+    3  ls @/shelltest
+    4  ls @/shelltest
+    5  ls @/shelltest/missing
+    6  history
+ann@example.com/shelltest/a/
+ann@example.com/shelltest/b/
+`
+	if stdout != wantOut {
+		t.Errorf("stdout:\n%s\nwant:\n%s", stdout, wantOut)
+	}
+	for _, want := range []string{"ls @/shelltest\n", "missing", "ls @/shelltest $UPSPINUSER/shelltest/b\n"} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("stderr %q does not contain %q", stderr, want)
+		}
+	}
+
+	// A script stops at the first failure, unless -k is set.
+	file := filepath.Join(t.TempDir(), "script")
+	err := os.WriteFile(file, []byte(`
+ls @/shelltest/missing
+mkdir @/shelltest/c
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, code := runShell("", "-f="+file); code != 1 {
+		t.Errorf("-f: exit code %d, want 1", code)
+	}
+	stdout, _, _ = runShell("ls @/shelltest\n", "-prompt=")
+	if strings.Contains(stdout, "shelltest/c") {
+		t.Errorf("-f: ran command after failure")
+	}
+	if _, _, code := runShell("", "-f="+file, "-k"); code != 1 {
+		t.Errorf("-f -k: exit code %d, want 1", code)
+	}
+
+	// A script whose commands succeed succeeds; c was made with -k.
+	stdout, _, code = runShell("ls @/shelltest\n!1 @/shelltest/c\n", "-prompt=")
+	if code != 0 {
+		t.Errorf("exit code %d, want 0", code)
+	}
+	if want := "ann@example.com/shelltest/a/\nann@example.com/shelltest/b/\nann@example.com/shelltest/c/\n"; !strings.HasPrefix(stdout, want) {
+		t.Errorf("stdout %q, want prefix %q", stdout, want)
+	}
+}
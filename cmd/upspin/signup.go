@@ -6,15 +6,20 @@ package main
 
 import (
 	"bytes"
+	"encoding/csv"
 	"flag"
 	"fmt"
 	"net"
 	"os"
 	"path/filepath"
+	"strings"
 	"text/template"
 
+	"upspin.io/bind"
 	"upspin.io/config"
+	"upspin.io/errors"
 	"upspin.io/flags"
+	"upspin.io/key/keygen"
 	"upspin.io/serverutil/signup"
 	"upspin.io/subcmd"
 	"upspin.io/upspin"
@@ -51,6 +56,26 @@ recreate or reuse prior keys.
 
 The -signuponly flag tells signup to skip the generation of the configuration
 file and keys and only send the signup request to the key server.
+
+The -batch flag signs up many users at once. It names a CSV file, or - for
+standard input, with one line per user holding the user name and the
+addresses of the user's directory and store servers:
+
+	username,dir-server,store-server
+	ann@example.com,dir.example.com,store.example.com
+
+For each user, signup generates a key pair and writes it, with a
+configuration file named config, to a subdirectory of the directory named
+by the -secrets-dir flag, which -batch requires. The subdirectory, named
+for the user, is readable only by its owner. Signup then sends the signup
+request for the user to the key server. With the -no-email flag, it instead
+registers the user directly, without email confirmation, using the current
+configuration file, which must be that of a user allowed to create users
+on the key server, such as the key server's own user; the key server is
+then that of the current configuration unless -key is set. Signup writes a
+CSV report of the result for each user, one of success, already-exists or
+error, to standard output. Keys left by an earlier run that did not
+register a user are reused.
 `
 	fs := flag.NewFlagSet("signup", flag.ExitOnError)
 	defaultKeyServer := string(config.New().KeyEndpoint().NetAddr)
@@ -64,9 +89,12 @@ file and keys and only send the signup request to the key server.
 		secrets     = fs.String("secrets", "", "`directory` to store key pair")
 		curve       = fs.String("curve", "p256", "cryptographic curve `name`: p256, p384, or p521")
 		secretseed  = fs.String("secretseed", "", "the seed containing a 128 bit secret in proquint format or a file that contains it")
+		batch       = fs.String("batch", "", "sign up the users listed in the CSV `file` (- for standard input)")
+		secretsDir  = fs.String("secrets-dir", "", "`directory` in which -batch writes each user's configuration and keys")
+		noEmail     = fs.Bool("no-email", false, "with -batch, register users directly instead of by email confirmation")
 	)
 
-	s.ParseFlags(fs, args, help, "[-config=<file>] signup -dir=<addr> -store=<addr> [flags] <username>\n       upspin [-config=<file>] signup -server=<addr> [flags] <username>")
+	s.ParseFlags(fs, args, help, "[-config=<file>] signup -dir=<addr> -store=<addr> [flags] <username>\n       upspin [-config=<file>] signup -server=<addr> [flags] <username>\n       upspin [-config=<file>] signup -batch=<file> -secrets-dir=<dir> [flags]")
 
 	// Determine config file location.
 	if !filepath.IsAbs(flags.Config) {
@@ -78,6 +106,25 @@ file and keys and only send the signup request to the key server.
 		flags.Config = filepath.Join(homedir, flags.Config)
 	}
 
+	if *batch != "" {
+		if fs.NArg() != 0 {
+			s.Failf("-batch takes no arguments")
+			usageAndExit(fs)
+		}
+		if *secretsDir == "" {
+			s.Failf("-batch requires -secrets-dir")
+			usageAndExit(fs)
+		}
+		keySet := false
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "key" {
+				keySet = true
+			}
+		})
+		s.signupBatch(*batch, subcmd.Tilde(*secretsDir), *keyServer, keySet, *curve, *noEmail)
+		return
+	}
+
 	if *signupOnly {
 		// Don't generate; just send the signup request to the key server.
 		s.registerUser(*keyServer)
@@ -177,7 +224,7 @@ file and keys and only send the signup request to the key server.
 			s.Exit(err)
 		}
 	}
-	s.keygenCommand(*secrets, *curve, *secretseed, false)
+	s.keygenCommand(*secrets, *curve, *secretseed, "", false)
 
 	// Send the signup request to the key server.
 	s.registerUser(*keyServer)
@@ -200,6 +247,178 @@ func (s *State) registerUser(keyServer string) {
 	fmt.Fprintf(s.Stderr, "please read it for further instructions.\n")
 }
 
+// Results of signing up a user, as reported by signup -batch.
+const (
+	signupSuccess       = "success"
+	signupAlreadyExists = "already-exists"
+	signupError         = "error"
+)
+
+// batchSignup holds the settings for signing up each user of a batch.
+type batchSignup struct {
+	secretsDir  string
+	curve       string
+	keyEndpoint *upspin.Endpoint
+	key         upspin.KeyServer // For looking up and, with -no-email, putting users.
+	noEmail     bool
+}
+
+// signupBatch signs up the users listed in the CSV file and writes a CSV
+// report of the results to standard output.
+func (s *State) signupBatch(file, secretsDir, keyServer string, keySet bool, curve string, noEmail bool) {
+	switch curve {
+	case "p256", "p384", "p521":
+		// ok
+	default:
+		s.Exitf("no such curve %q", curve)
+	}
+	if file == "-" {
+		file = "" // Read standard input.
+	}
+	r := csv.NewReader(bytes.NewReader(s.ReadAll(file)))
+	r.Comment = '#'
+	r.FieldsPerRecord = -1 // Checked by signupUser.
+	r.TrimLeadingSpace = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		s.Exit(err)
+	}
+	if len(rows) > 0 && strings.EqualFold(rows[0][0], "username") {
+		rows = rows[1:] // Skip the header.
+	}
+
+	b := &batchSignup{
+		secretsDir: secretsDir,
+		curve:      curve,
+		noEmail:    noEmail,
+	}
+	var cfg upspin.Config
+	if noEmail {
+		// Users are created by the user of the current config.
+		cfg = s.Config
+		if cfg == nil {
+			cfg, err = config.FromFile(flags.Config)
+			if err != nil {
+				s.Exit(err)
+			}
+		}
+		ep := cfg.KeyEndpoint()
+		b.keyEndpoint = &ep
+	} else {
+		cfg = config.New()
+	}
+	if keySet || !noEmail {
+		b.keyEndpoint, err = parseAddress(keyServer)
+		if err != nil {
+			s.Exitf("error parsing -key=%q: %v", keyServer, err)
+		}
+	}
+	cfg = config.SetKeyEndpoint(cfg, *b.keyEndpoint)
+	b.key, err = bind.KeyServer(cfg, cfg.KeyEndpoint())
+	if err != nil {
+		s.Exit(err)
+	}
+
+	w := csv.NewWriter(s.Stdout)
+	w.Write([]string{"username", "result", "error"})
+	for _, row := range rows {
+		result, err := b.signupUser(row)
+		msg := ""
+		if err != nil {
+			result, msg = signupError, err.Error()
+			s.ExitCode = 1
+		}
+		w.Write([]string{strings.TrimSpace(row[0]), result, msg})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		s.Exit(err)
+	}
+}
+
+// signupUser signs up the user described by a row of the CSV file.
+func (b *batchSignup) signupUser(row []string) (string, error) {
+	if len(row) != 3 {
+		return "", errors.Errorf("expected 3 fields, saw %d", len(row))
+	}
+	uname, suffix, domain, err := user.Parse(upspin.UserName(strings.TrimSpace(row[0])))
+	if err != nil {
+		return "", err
+	}
+	if suffix != "" {
+		return "", errors.Str("name must not include a +suffix")
+	}
+	userName := upspin.UserName(uname + "@" + domain)
+	dirEndpoint, err := parseAddress(strings.TrimSpace(row[1]))
+	if err != nil {
+		return "", err
+	}
+	storeEndpoint, err := parseAddress(strings.TrimSpace(row[2]))
+	if err != nil {
+		return "", err
+	}
+
+	_, err = b.key.Lookup(userName)
+	if err == nil {
+		return signupAlreadyExists, nil
+	}
+	if !errors.Is(errors.NotExist, err) {
+		return "", err
+	}
+
+	// Generate keys, unless an earlier run left some.
+	where := filepath.Join(b.secretsDir, string(userName))
+	if err := os.MkdirAll(where, 0700); err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(where, "secret.upspinkey")); os.IsNotExist(err) {
+		public, private, secretStr, err := keygen.Generate(b.curve)
+		if err != nil {
+			return "", err
+		}
+		if err := keygen.SaveKeys(where, false, public, private, secretStr); err != nil {
+			return "", err
+		}
+	}
+
+	// Write the config file.
+	var configContents bytes.Buffer
+	err = configTemplate.Execute(&configContents, configData{
+		UserName:  userName,
+		Key:       b.keyEndpoint,
+		Dir:       dirEndpoint,
+		Store:     storeEndpoint,
+		Packing:   "ee",
+		SecretDir: where,
+	})
+	if err != nil {
+		return "", err
+	}
+	configFile := filepath.Join(where, "config")
+	if err := os.WriteFile(configFile, configContents.Bytes(), 0640); err != nil {
+		return "", err
+	}
+	cfg, err := config.FromFile(configFile)
+	if err != nil {
+		return "", err
+	}
+
+	if b.noEmail {
+		err = b.key.Put(&upspin.User{
+			Name:      userName,
+			Dirs:      []upspin.Endpoint{*dirEndpoint},
+			Stores:    []upspin.Endpoint{*storeEndpoint},
+			PublicKey: cfg.Factotum().PublicKey(),
+		})
+	} else {
+		err = signup.MakeRequest(cfg)
+	}
+	if err != nil {
+		return "", err
+	}
+	return signupSuccess, nil
+}
+
 type configData struct {
 	UserName        upspin.UserName
 	Key, Store, Dir *upspin.Endpoint
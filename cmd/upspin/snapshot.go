@@ -7,6 +7,8 @@ package main
 import (
 	"flag"
 
+	"upspin.io/client"
+	"upspin.io/config"
 	"upspin.io/errors"
 	"upspin.io/path"
 	"upspin.io/upspin"
@@ -18,12 +20,28 @@ func (s *State) snapshot(args ...string) {
 Snapshot requests the system to take a snapshot of the user's
 directory tree as soon as possible. Snapshots are created only if
 the directory server for the user's root supports them.
+
+With the -schedule flag, snapshot instead sets when the server takes
+snapshots automatically. The schedule is either an interval such as
+"6h" or a cron expression of five fields (minute, hour, day of month,
+month, day of week) made of numbers, ranges, steps, lists and "*",
+as in "0 9-17 * * 1-5" for hourly snapshots during working hours on
+weekdays. Times are UTC. An empty schedule restores the server's
+default, which is usually every 12 hours. The schedule is stored in
+the file named Schedule at the root of the snapshot tree.
 `
 	fs := flag.NewFlagSet("snapshot", flag.ExitOnError)
-	s.ParseFlags(fs, args, help, "snapshot")
+	schedule := fs.String("schedule", "", "set the snapshot `schedule` rather than taking a snapshot")
+	s.ParseFlags(fs, args, help, "snapshot [-schedule=spec]")
 	if fs.NArg() > 0 {
 		usageAndExit(fs)
 	}
+	setSchedule := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "schedule" {
+			setSchedule = true
+		}
+	})
 
 	u, suffix, domain, err := user.Parse(s.Config.UserName())
 	if err != nil {
@@ -34,6 +52,7 @@ the directory server for the user's root supports them.
 		snapshotUser = upspin.UserName(u + "+snapshot@" + domain)
 	} else if suffix == "snapshot" {
 		// Okay -- snapshot user is allowed to trigger snapshots.
+		snapshotUser = s.Config.UserName()
 	} else {
 		s.Exitf("Only the snapshot user or the canonical user %q can trigger a snapshot", u+"@"+domain)
 	}
@@ -71,6 +90,17 @@ the directory server for the user's root supports them.
 		s.Exit(err)
 	}
 
+	if setSchedule {
+		// The server must be able to read the schedule, so it is
+		// signed but not encrypted.
+		name := path.Join(upspin.PathName(snapshotUser), "Schedule")
+		c := client.New(config.SetPacking(s.Config, upspin.EEIntegrityPack))
+		if _, err := c.Put(name, []byte(*schedule)); err != nil {
+			s.Exit(err)
+		}
+		return
+	}
+
 	// Put a new DirEntry that triggers the snapshotting process.
 	// Note: This is a hack, but it works. See dir/server/snapshot.go for
 	// the mechanism.
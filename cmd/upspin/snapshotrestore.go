@@ -0,0 +1,233 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"sort"
+	"strings"
+
+	"upspin.io/client/clientutil"
+	"upspin.io/errors"
+	"upspin.io/pack"
+	"upspin.io/path"
+	"upspin.io/upspin"
+	"upspin.io/user"
+)
+
+func (s *State) snapshotRestore(args ...string) {
+	const help = `
+Snapshot-restore makes the user's directory tree match a snapshot of
+it. The argument names the snapshot, either as a full path such as
+ann+snapshot@example.com/2017/06/01/12:00 or relative to the root of
+the snapshot tree, as in 2017/06/01/12:00. Glob patterns are expanded
+and must match a single snapshot.
+
+Files and links present in the snapshot but missing or different in
+the live tree are put back, and entries in the live tree that are not
+in the snapshot are deleted. Restored files refer to the blocks already
+in the store server, so no data is uploaded. If some of a file's blocks
+are no longer in the store, that file is left as it is and reported,
+and the rest of the tree is restored.
+
+The -dry-run flag prints the changes without making them.
+`
+	fs := flag.NewFlagSet("snapshot-restore", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print the changes without making them")
+	s.ParseFlags(fs, args, help, "snapshot-restore [-dry-run] snapshot")
+	if fs.NArg() != 1 {
+		usageAndExit(fs)
+	}
+
+	u, suffix, domain, err := user.Parse(s.Config.UserName())
+	if err != nil {
+		s.Exit(err)
+	}
+	if suffix != "" && suffix != "snapshot" {
+		s.Exitf("only the canonical user %q can restore a snapshot", u+"@"+domain)
+	}
+	owner := upspin.UserName(u + "@" + domain)
+	snapshotUser := upspin.UserName(u + "+snapshot@" + domain)
+
+	arg := fs.Arg(0)
+	if !strings.Contains(arg, "@") {
+		arg = string(path.Join(upspin.PathName(snapshotUser), arg))
+	}
+	snapName := s.GlobOneUpspinPath(arg)
+	snapParsed, err := path.Parse(snapName)
+	if err != nil {
+		s.Exit(err)
+	}
+	if snapParsed.User() != snapshotUser || snapParsed.IsRoot() {
+		s.Exitf("%s is not a snapshot of %s", snapName, owner)
+	}
+	root := upspin.PathName(owner + "/")
+
+	snap := s.snapshotTree(snapParsed.Path())
+	live := s.snapshotTree(root)
+	present := make(map[string]bool) // Keyed by relative name in snap.
+	blocksPresent := func(rel string) bool {
+		ok, checked := present[rel]
+		if !checked {
+			ok = s.blocksPresent(snap.entries[rel])
+			present[rel] = ok
+		}
+		return ok
+	}
+
+	// Delete what is not in the snapshot, or is there as a different
+	// kind of entry, children before parents.
+	deleted := make(map[string]bool)
+	for i := len(live.names) - 1; i >= 0; i-- {
+		rel := live.names[i]
+		le := live.entries[rel]
+		se, ok := snap.entries[rel]
+		if ok && se.IsDir() == le.IsDir() {
+			continue
+		}
+		if ok && !blocksPresent(rel) {
+			// Keep what we have rather than lose both.
+			continue
+		}
+		s.Printf("delete %s\n", le.Name)
+		deleted[rel] = true
+		if *dryRun {
+			continue
+		}
+		if _, err := s.DirServer(le.Name).Delete(le.Name); err != nil {
+			s.Exit(err)
+		}
+	}
+
+	// Put back what is missing or different, parents before children.
+	var missing []upspin.PathName
+	for _, rel := range snap.names {
+		se := snap.entries[rel]
+		le, ok := live.entries[rel]
+		if ok && !deleted[rel] && sameSnapshotEntry(se, le) {
+			continue
+		}
+		if ok && !deleted[rel] && se.IsDir() {
+			// Directories differ only in metadata; leave them.
+			continue
+		}
+		name := path.Join(root, rel)
+		if !se.IsDir() && !blocksPresent(rel) {
+			missing = append(missing, name)
+			continue
+		}
+		s.Printf("put %s\n", name)
+		if *dryRun {
+			continue
+		}
+		entry := se.Copy()
+		entry.Sequence = upspin.SeqIgnore
+		if entry.IsDir() {
+			entry = &upspin.DirEntry{
+				Name:       name,
+				SignedName: name,
+				Attr:       upspin.AttrDirectory,
+				Packing:    upspin.PlainPack,
+				Time:       se.Time,
+				Writer:     s.Config.UserName(),
+				Sequence:   upspin.SeqIgnore,
+			}
+		} else if entry.SignedName != name {
+			packer := pack.Lookup(entry.Packing)
+			if packer == nil {
+				s.Exit(errors.Errorf("unrecognized packing %s for %s", entry.Packing, se.Name))
+			}
+			entry.Name = se.Name
+			if err := packer.Name(s.Config, entry, name); err != nil {
+				s.Exit(err)
+			}
+		} else {
+			entry.Name = name
+		}
+		if _, err := s.DirServer(name).Put(entry); err != nil {
+			s.Exit(err)
+		}
+	}
+
+	for _, name := range missing {
+		s.Failf("%s not restored: blocks missing from store", name)
+	}
+}
+
+// snapshotTreeEntries holds the entries of a tree, keyed by their names
+// relative to its root.
+type snapshotTreeEntries struct {
+	names   []string // Sorted so parents precede their children.
+	entries map[string]*upspin.DirEntry
+}
+
+// snapshotTree returns the entries below the directory root. It does not
+// follow links.
+func (s *State) snapshotTree(root upspin.PathName) *snapshotTreeEntries {
+	t := &snapshotTreeEntries{entries: make(map[string]*upspin.DirEntry)}
+	dir := s.DirServer(root)
+	prefix := len(root)
+	if !strings.HasSuffix(string(root), "/") {
+		prefix++
+	}
+	var walk func(name upspin.PathName)
+	walk = func(name upspin.PathName) {
+		entries, err := dir.Glob(string(path.Join(name, "*")))
+		if err != nil && err != upspin.ErrFollowLink {
+			s.Exit(err)
+		}
+		sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+		for _, e := range entries {
+			rel := string(e.Name[prefix:])
+			t.names = append(t.names, rel)
+			t.entries[rel] = e
+			if e.IsDir() {
+				walk(e.Name)
+			}
+		}
+	}
+	walk(root)
+	return t
+}
+
+// sameSnapshotEntry reports whether the live entry b already has the
+// contents of the snapshot entry a.
+func sameSnapshotEntry(a, b *upspin.DirEntry) bool {
+	if a.Attr != b.Attr || a.Link != b.Link || a.Packing != b.Packing || len(a.Blocks) != len(b.Blocks) {
+		return false
+	}
+	if a.IsDir() {
+		return true
+	}
+	if !bytes.Equal(a.Packdata, b.Packdata) {
+		return false
+	}
+	for i := range a.Blocks {
+		x, y := &a.Blocks[i], &b.Blocks[i]
+		if x.Location != y.Location || x.Offset != y.Offset || x.Size != y.Size || !bytes.Equal(x.Packdata, y.Packdata) {
+			return false
+		}
+	}
+	return true
+}
+
+// blocksPresent reports whether the store servers still hold every
+// block of the entry. The blocks are not downloaded.
+func (s *State) blocksPresent(entry *upspin.DirEntry) bool {
+	for _, b := range entry.Blocks {
+		if b.Location.Reference == upspin.ZeroReference {
+			continue
+		}
+		ok, err := clientutil.HaveLocation(s.Config, b.Location)
+		if err != nil {
+			s.Exit(err)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
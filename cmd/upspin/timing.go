@@ -0,0 +1,111 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+// This file implements the -timing global flag.
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"upspin.io/rpc"
+	"upspin.io/shutdown"
+	"upspin.io/upspin"
+)
+
+// startTiming arranges for the RPCs made by the command to be recorded
+// and summarized on standard error when it exits.
+func (state *State) startTiming() {
+	state.timings = newTimings()
+	rpc.ObserveCalls(state.timings.observe)
+	shutdown.Handle(func() {
+		state.timings.write(state.Stderr)
+	})
+}
+
+// timings collects the RPCs made by the command, as reported by rpc.ObserveCalls.
+type timings struct {
+	mu    sync.Mutex
+	calls map[timingKey]*timingStats
+}
+
+type timingKey struct {
+	method string
+	addr   upspin.NetAddr
+}
+
+type timingStats struct {
+	durations []time.Duration
+	errors    int
+	bytes     int64 // Sent and received.
+}
+
+func newTimings() *timings {
+	return &timings{calls: make(map[timingKey]*timingStats)}
+}
+
+// observe records a call. It is passed to rpc.ObserveCalls.
+func (t *timings) observe(c rpc.Call) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := timingKey{c.Method, c.Addr}
+	s := t.calls[key]
+	if s == nil {
+		s = new(timingStats)
+		t.calls[key] = s
+	}
+	s.durations = append(s.durations, c.Duration)
+	s.bytes += c.Sent + c.Received
+	if c.Err != nil {
+		s.errors++
+	}
+}
+
+// write prints a table summarizing the calls for each method and endpoint,
+// those that took longest in total first.
+func (t *timings) write(w io.Writer) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	type row struct {
+		timingKey
+		*timingStats
+		total, p95 time.Duration
+	}
+	rows := make([]row, 0, len(t.calls))
+	for key, s := range t.calls {
+		r := row{timingKey: key, timingStats: s}
+		d := append([]time.Duration(nil), s.durations...)
+		sort.Slice(d, func(i, j int) bool { return d[i] < d[j] })
+		for _, x := range d {
+			r.total += x
+		}
+		// The smallest duration at least 95% of the calls took no longer than.
+		r.p95 = d[(len(d)*95+99)/100-1]
+		rows = append(rows, r)
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].total != rows[j].total {
+			return rows[i].total > rows[j].total
+		}
+		if rows[i].method != rows[j].method {
+			return rows[i].method < rows[j].method
+		}
+		return rows[i].addr < rows[j].addr
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "METHOD\tENDPOINT\tCALLS\tERRORS\tBYTES\tTOTAL\tP95")
+	for _, r := range rows {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%v\t%v\n",
+			r.method, r.addr, len(r.durations), r.errors, r.bytes,
+			r.total.Round(time.Microsecond), r.p95.Round(time.Microsecond))
+	}
+	tw.Flush()
+}
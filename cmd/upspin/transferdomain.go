@@ -0,0 +1,69 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"upspin.io/factotum"
+	"upspin.io/upspin"
+	"upspin.io/user"
+)
+
+func (s *State) transferDomain(args ...string) {
+	const help = `
+Transfer-domain moves the records of all the users of a domain from the
+current key server, the one named in the config, to another key server,
+such as when an installation leaves key.upspin.io for a key server of
+its own. The -dest flag gives the network address of the new server.
+
+The current user must administer the domain, as set up by
+'upspin setupdomain', and the transfer is signed with the current
+user's key. Both key servers check the signature and the domain's DNS
+TXT record.
+
+The current key server sends the records to the new one and then
+records that the domain has moved. From then on it answers a lookup of
+a user of the domain by naming the new server, which clients then ask
+instead. The forwarding is kept indefinitely, but every user of the
+domain should update the keyserver line in their config to name the
+new server.
+`
+	fs := flag.NewFlagSet("transfer-domain", flag.ExitOnError)
+	domain := fs.String("domain", "", "domain `name` whose users to transfer")
+	dest := fs.String("dest", "", "network `address` of the destination key server")
+	s.ParseFlags(fs, args, help, "transfer-domain -domain=<name> -dest=<host:port>")
+	if fs.NArg() != 0 {
+		usageAndExit(fs)
+	}
+	if *domain == "" || *dest == "" {
+		s.Failf("the -domain and -dest flags must be provided")
+		usageAndExit(fs)
+	}
+	d, err := user.ParseDomain(*domain)
+	if err != nil {
+		s.Exit(err)
+	}
+	addr := upspin.NetAddr(*dest)
+
+	f := s.Config.Factotum()
+	if f == nil {
+		s.Exitf("no factotum available")
+	}
+	t, ok := s.KeyServer().(upspin.KeyDomainTransferrer)
+	if !ok {
+		s.Exitf("key server cannot transfer domains")
+	}
+	sig, err := f.Sign(factotum.TransferHash(d, addr))
+	if err != nil {
+		s.Exit(err)
+	}
+	if err := t.TransferDomain(d, addr, []byte(factotum.FormatSignature(sig))); err != nil {
+		s.Exit(err)
+	}
+	fmt.Fprintf(s.Stdout, "The users of %s are now served by the key server at %s.\n", d, addr)
+	fmt.Fprintf(s.Stdout, "Their configs should be changed to say\n\tkeyserver: remote,%s\n", addr)
+}
@@ -7,6 +7,17 @@ package main
 import (
 	"flag"
 	"fmt"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// Delays between attempts to reconnect a watch. The delay doubles
+// after each failed attempt, up to the maximum.
+var (
+	watchBackoff    = time.Second
+	watchMaxBackoff = time.Minute
 )
 
 func (s *State) watch(args ...string) {
@@ -19,11 +30,23 @@ rooted at the given path.
 The -glob flag can be set to false to have watch skip Glob processing,
 treating its arguments as literal text even if they contain special
 characters. (Leading @ signs are always expanded.)
+
+Watch normally exits when the server stops sending events, such as
+when it restarts or the network fails. With the -reconnect flag it
+instead calls the server again, waiting longer after each failed
+attempt, and continues from the sequence number following the last
+event printed so that no events are missed. Each time it reconnects
+it prints a line of the form
+	[reconnected at sequence N]
+to standard error. The -max-reconnects flag limits the number of
+attempts; zero, the default, means there is no limit.
 `
 	fs := flag.NewFlagSet("watch", flag.ExitOnError)
 	glob := globFlag(fs)
 	sequence := fs.Int64("sequence", -1, "`sequence` number")
-	s.ParseFlags(fs, args, help, "watch [-sequence=n] path")
+	reconnect := fs.Bool("reconnect", false, "reconnect when the watch ends")
+	maxReconnects := fs.Int("max-reconnects", 0, "with -reconnect, give up after `n` consecutive failed attempts; 0 means no limit")
+	s.ParseFlags(fs, args, help, "watch [-sequence=n] [-reconnect [-max-reconnects=n]] path")
 
 	names := s.expandUpspin(fs.Args(), *glob)
 	if len(names) != 1 {
@@ -36,35 +59,87 @@ characters. (Leading @ signs are always expanded.)
 		s.Exit(err)
 	}
 
-	done := make(chan struct{})
-	events, err := dir.Watch(name, *sequence, done)
-	if err != nil {
+	if err := s.watchEvents(dir.Watch, name, *sequence, *reconnect, *maxReconnects); err != nil {
 		s.Exit(err)
 	}
-	for e := range events {
-		if e.Error != nil {
-			fmt.Fprintf(s.Stderr, "watch: error: %s\n", e.Error) // TODO: Failf? Set exitCode?
-			continue
-		}
+}
 
-		de := e.Entry
-		seq := fmt.Sprintf("%10d", de.Sequence)
-		attr := []byte("file")
-		if de.IsDir() {
-			copy(attr, "dir ")
-		} else if de.IsLink() {
-			copy(attr, "link")
+// watchEvents prints the events reported by watch for the name,
+// starting at the sequence number. Unless reconnect is set it returns
+// when the watch ends. If it is set, watchEvents watches again from
+// the point where the previous watch ended, returning only when it
+// has failed maxReconnects times in succession, or for an error that
+// cannot be fixed by trying again.
+func (s *State) watchEvents(watch func(upspin.PathName, int64, <-chan struct{}) (<-chan upspin.Event, error), name upspin.PathName, sequence int64, reconnect bool, maxReconnects int) error {
+	done := make(chan struct{})
+	defer close(done)
+	next := sequence
+	delay := watchBackoff
+	for failures := 0; ; {
+		events, err := watch(name, next, done)
+		if err == nil {
+			if failures > 0 {
+				fmt.Fprintf(s.Stderr, "[reconnected at sequence %d]\n", next)
+			}
+			for e := range events {
+				if e.Error != nil {
+					fmt.Fprintf(s.Stderr, "watch: error: %s\n", e.Error) // TODO: Failf? Set exitCode?
+					err = e.Error
+					continue
+				}
+				s.printEvent(&e)
+				// The server may first send the existing tree,
+				// not in sequence order, so use the largest seen.
+				if e.Entry.Sequence >= next {
+					next = e.Entry.Sequence + 1
+				}
+				failures = 0
+				delay = watchBackoff
+			}
+			if !reconnect {
+				return nil
+			}
 		}
-		if de.IsIncomplete() {
-			attr[3] = '!'
+		if !reconnect {
+			return err
 		}
-		size := "          "
-		if e.Delete {
-			size = " [deleted]"
-		} else if de.IsRegular() && !de.IsIncomplete() {
-			d, _ := de.Size()
-			size = fmt.Sprintf("%10d", d)
+		if errors.Is(errors.Invalid, err) || err == upspin.ErrNotSupported {
+			// Trying again will not help.
+			return err
 		}
-		s.Printf("%s %s [%s] %s %s\n", de.Time, seq, attr, size, de.Name)
+		if err != nil {
+			fmt.Fprintf(s.Stderr, "watch: %s\n", err)
+		}
+		failures++
+		if maxReconnects > 0 && failures > maxReconnects {
+			return errors.Errorf("watch: giving up after %d attempts to reconnect", maxReconnects)
+		}
+		time.Sleep(delay)
+		if delay *= 2; delay > watchMaxBackoff {
+			delay = watchMaxBackoff
+		}
+	}
+}
+
+// printEvent prints a line describing the event.
+func (s *State) printEvent(e *upspin.Event) {
+	de := e.Entry
+	seq := fmt.Sprintf("%10d", de.Sequence)
+	attr := []byte("file")
+	if de.IsDir() {
+		copy(attr, "dir ")
+	} else if de.IsLink() {
+		copy(attr, "link")
+	}
+	if de.IsIncomplete() {
+		attr[3] = '!'
+	}
+	size := "          "
+	if e.Delete {
+		size = " [deleted]"
+	} else if de.IsRegular() && !de.IsIncomplete() {
+		d, _ := de.Size()
+		size = fmt.Sprintf("%10d", d)
 	}
+	s.Printf("%s %s [%s] %s %s\n", de.Time, seq, attr, size, de.Name)
 }
@@ -0,0 +1,147 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/subcmd"
+	"upspin.io/upspin"
+)
+
+// fakeWatch is a Watch function whose successive calls send the events
+// of successive sessions, or return the error of a session without events.
+type fakeWatch struct {
+	sessions  []watchSession
+	sequences []int64 // The sequence numbers requested by each call.
+}
+
+type watchSession struct {
+	err    error
+	events []upspin.Event
+}
+
+func (f *fakeWatch) watch(name upspin.PathName, sequence int64, done <-chan struct{}) (<-chan upspin.Event, error) {
+	f.sequences = append(f.sequences, sequence)
+	if len(f.sessions) == 0 {
+		return nil, errors.E(errors.IO, "no more sessions")
+	}
+	sess := f.sessions[0]
+	f.sessions = f.sessions[1:]
+	if sess.err != nil {
+		return nil, sess.err
+	}
+	ch := make(chan upspin.Event, len(sess.events))
+	for _, e := range sess.events {
+		ch <- e
+	}
+	close(ch)
+	return ch, nil
+}
+
+func event(name upspin.PathName, seq int64) upspin.Event {
+	return upspin.Event{Entry: &upspin.DirEntry{Name: name, Sequence: seq, Attr: upspin.AttrDirectory}}
+}
+
+func TestWatchReconnect(t *testing.T) {
+	defer func(d time.Duration) { watchBackoff = d }(watchBackoff)
+	watchBackoff = time.Millisecond
+
+	const name = "ann@example.com/dir"
+	broken := upspin.Event{Error: errors.E(errors.IO, "connection lost")}
+	tests := []struct {
+		name          string
+		reconnect     bool
+		maxReconnects int
+		sessions      []watchSession
+		sequences     []int64 // Requested by each call.
+		printed       []string
+		stderr        []string
+		fail          bool
+	}{
+		{
+			name: "no reconnect",
+			sessions: []watchSession{
+				{events: []upspin.Event{event(name, 5), broken}},
+			},
+			sequences: []int64{-1},
+			printed:   []string{name},
+			stderr:    []string{"connection lost"},
+		},
+		{
+			name:          "reconnect",
+			reconnect:     true,
+			maxReconnects: 2,
+			sessions: []watchSession{
+				{events: []upspin.Event{event(name, 7), event(name+"/a", 5), broken}},
+				{err: errors.E(errors.IO, "dial failed")},
+				{events: []upspin.Event{event(name+"/b", 8)}},
+			},
+			sequences: []int64{-1, 8, 8, 9, 9},
+			printed:   []string{name, name + "/a", name + "/b"},
+			stderr:    []string{"connection lost", "dial failed", "[reconnected at sequence 8]", "giving up after 2"},
+			fail:      true,
+		},
+		{
+			name:      "invalid sequence",
+			reconnect: true,
+			sessions: []watchSession{
+				{events: []upspin.Event{{Error: errors.E(errors.Invalid, "bad sequence")}}},
+			},
+			sequences: []int64{100},
+			stderr:    []string{"bad sequence"},
+			fail:      true,
+		},
+	}
+	for _, test := range tests {
+		var stdout, stderr bytes.Buffer
+		s := &State{State: &subcmd.State{Stdout: &stdout, Stderr: &stderr}}
+		f := &fakeWatch{sessions: test.sessions}
+		start := test.sequences[0]
+		err := s.watchEvents(f.watch, name, start, test.reconnect, test.maxReconnects)
+		if (err != nil) != test.fail {
+			t.Errorf("%s: error %v, want failure %t", test.name, err, test.fail)
+		}
+		if len(f.sequences) != len(test.sequences) {
+			t.Errorf("%s: requested sequences %v, want %v", test.name, f.sequences, test.sequences)
+		} else {
+			for i := range f.sequences {
+				if f.sequences[i] != test.sequences[i] {
+					t.Errorf("%s: requested sequences %v, want %v", test.name, f.sequences, test.sequences)
+					break
+				}
+			}
+		}
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		if stdout.Len() == 0 {
+			lines = nil
+		}
+		if len(lines) != len(test.printed) {
+			t.Errorf("%s: printed %q, want %d events", test.name, stdout.String(), len(test.printed))
+		} else {
+			for i, line := range lines {
+				if !strings.HasSuffix(line, " "+test.printed[i]) {
+					t.Errorf("%s: event %d is %q, want %s", test.name, i, line, test.printed[i])
+				}
+			}
+		}
+		errs := stderr.String()
+		if err != nil {
+			errs += err.Error()
+		}
+		for _, want := range test.stderr {
+			i := strings.Index(errs, want)
+			if i < 0 {
+				t.Errorf("%s: error output %q does not contain %q", test.name, errs, want)
+				break
+			}
+			errs = errs[i+len(want):]
+		}
+	}
+}
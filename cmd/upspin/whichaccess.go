@@ -5,10 +5,18 @@
 package main
 
 import (
+	"bytes"
 	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
 
+	"upspin.io/access"
 	"upspin.io/errors"
+	"upspin.io/path"
 	"upspin.io/upspin"
+	"upspin.io/user"
 )
 
 func (s *State) whichAccess(args ...string) {
@@ -19,15 +27,38 @@ that controls permissions for each of the argument paths.
 The -glob flag can be set to false to have watchaccess skip Glob
 processing, treating its arguments as literal text even if they
 contain special characters. (Leading @ signs are always expanded.)
+
+The -expand flag also prints, for each right, the users and groups
+the Access file grants it to, with each group followed by its members,
+indented, and so on for groups within groups. The owner's implicit
+rights to read and list are included. A group that cannot be read is
+marked unreachable, and one that contains itself, directly or not, is
+marked as a cycle. With -user, only the chains of groups that grant
+the named user each right are printed.
 `
 	fs := flag.NewFlagSet("whichaccess", flag.ExitOnError)
 	glob := globFlag(fs)
-	s.ParseFlags(fs, args, help, "whichaccess path...")
+	expand := fs.Bool("expand", false, "print the users and groups granted each right, expanding groups")
+	forUser := fs.String("user", "", "with -expand, print only how `user` is granted each right")
+	s.ParseFlags(fs, args, help, "whichaccess [-expand [-user=user]] path...")
 	if fs.NArg() == 0 {
 		usageAndExit(fs)
 	}
-	for _, name := range s.expandUpspin(fs.Args(), *glob) {
-		acc, err := s.whichAccessFollowLinks(name)
+	if *forUser != "" {
+		if !*expand {
+			s.Exitf("-user requires -expand")
+		}
+		if _, _, _, err := user.Parse(upspin.UserName(*forUser)); err != nil {
+			s.Exit(err)
+		}
+	}
+	names := s.expandUpspin(fs.Args(), *glob)
+	for i, res := range s.whichAccessAll(names) {
+		name := names[i]
+		acc, err := res.Entry, res.Error
+		if err == upspin.ErrFollowLink {
+			acc, err = s.whichAccessFollowLinks(name)
+		}
 		if err != nil {
 			s.Exit(err)
 		}
@@ -36,14 +67,195 @@ contain special characters. (Leading @ signs are always expanded.)
 		} else {
 			s.Printf("%s: %s\n", name, acc.Name)
 		}
+		if *expand {
+			var a *access.Access
+			if acc == nil {
+				a, err = access.New(name)
+			} else {
+				a, err = access.Parse(acc.Name, s.readOrExit(s.Client, acc.Name))
+			}
+			if err != nil {
+				s.Exit(err)
+			}
+			t := newAccessTree(s.Client.Get, upspin.UserName(*forUser))
+			t.write(s.Stdout, a)
+		}
+	}
+}
+
+// accessTree prints the rights granted by an Access file, expanding
+// groups recursively.
+type accessTree struct {
+	load func(upspin.PathName) ([]byte, error)
+
+	// forUser, if set, restricts the tree to the chains that grant the
+	// user a right.
+	forUser upspin.UserName
+	domain  string // Of forUser.
+
+	groups map[upspin.PathName]groupMembers // Groups loaded so far.
+}
+
+// groupMembers is the result of loading a Group file.
+type groupMembers struct {
+	members []path.Parsed
+	err     error
+}
+
+func newAccessTree(load func(upspin.PathName) ([]byte, error), forUser upspin.UserName) *accessTree {
+	t := &accessTree{
+		load:    load,
+		forUser: forUser,
+		groups:  make(map[upspin.PathName]groupMembers),
+	}
+	if forUser != "" {
+		_, _, t.domain, _ = user.Parse(forUser)
+	}
+	return t
+}
+
+// write prints the tree for each right granted by a.
+func (t *accessTree) write(w io.Writer, a *access.Access) {
+	parsed, err := path.Parse(a.Path())
+	if err != nil {
+		// Cannot happen; the Access file has been parsed.
+		fmt.Fprintf(w, "\t%v\n", err)
+		return
+	}
+	owner := parsed.First(0)
+	printed := false
+	for _, right := range []access.Right{access.Read, access.Write, access.List, access.Create, access.Delete} {
+		list := a.List(right)
+		// The owner may always read and list.
+		ownerOnly := false
+		if right == access.Read || right == access.List {
+			ownerOnly = true
+			for _, p := range list {
+				if p.IsRoot() && p.User() == owner.User() {
+					ownerOnly = false
+				}
+			}
+		}
+		var b bytes.Buffer
+		if ownerOnly && t.grants(owner, nil) {
+			fmt.Fprintf(&b, "\t\t%s (owner)\n", owner.User())
+		}
+		for _, p := range list {
+			t.writeNode(&b, p, 2, nil)
+		}
+		if b.Len() == 0 {
+			continue
+		}
+		fmt.Fprintf(w, "\t%s:\n", right)
+		w.Write(b.Bytes())
+		printed = true
+	}
+	if !printed && t.forUser != "" {
+		fmt.Fprintf(w, "\t%s has no rights\n", t.forUser)
 	}
 }
 
+// writeNode prints p, a user or a group, indented by depth tabs, and if a
+// group, its members below it. Ancestors holds the groups above p.
+func (t *accessTree) writeNode(w io.Writer, p path.Parsed, depth int, ancestors []upspin.PathName) {
+	if !t.grants(p, ancestors) {
+		return
+	}
+	indent := strings.Repeat("\t", depth)
+	if p.IsRoot() {
+		fmt.Fprintf(w, "%s%s\n", indent, p.User())
+		return
+	}
+	name := p.Path()
+	if t.forUser != "" && p.User() == t.forUser {
+		// Owning a group is as good as being in it.
+		fmt.Fprintf(w, "%s%s (owned by %s)\n", indent, name, t.forUser)
+		return
+	}
+	for _, g := range ancestors {
+		if g == name {
+			fmt.Fprintf(w, "%s%s (cycle)\n", indent, name)
+			return
+		}
+	}
+	g := t.group(p)
+	if g.err != nil {
+		fmt.Fprintf(w, "%s%s (unreachable: %s)\n", indent, name, errorSummary(g.err))
+		return
+	}
+	fmt.Fprintf(w, "%s%s\n", indent, name)
+	ancestors = append(ancestors, name)
+	for _, m := range g.members {
+		t.writeNode(w, m, depth+1, ancestors)
+	}
+}
+
+// grants reports whether p, a user or a group, is to be printed: always
+// if there is no forUser, and otherwise if it grants rights to forUser.
+func (t *accessTree) grants(p path.Parsed, ancestors []upspin.PathName) bool {
+	if t.forUser == "" {
+		return true
+	}
+	// The owner of a group, as well as its members, is granted the right.
+	u := p.User()
+	if u == t.forUser || u == access.AllUsers || u == upspin.UserName("*@"+t.domain) {
+		return true
+	}
+	if p.IsRoot() {
+		return false
+	}
+	for _, g := range ancestors {
+		if g == p.Path() {
+			return false
+		}
+	}
+	g := t.group(p)
+	if g.err != nil {
+		return false
+	}
+	ancestors = append(ancestors[:len(ancestors):len(ancestors)], p.Path())
+	for _, m := range g.members {
+		if t.grants(m, ancestors) {
+			return true
+		}
+	}
+	return false
+}
+
+// group returns the members of the group, loading it if necessary.
+func (t *accessTree) group(p path.Parsed) groupMembers {
+	if g, ok := t.groups[p.Path()]; ok {
+		return g
+	}
+	var g groupMembers
+	data, err := t.load(p.Path())
+	if err == nil {
+		g.members, err = access.ParseGroup(p, data)
+	}
+	g.err = err
+	sort.Slice(g.members, func(i, j int) bool { return g.members[i].Compare(g.members[j]) < 0 })
+	t.groups[p.Path()] = g
+	return g
+}
+
+// errorSummary returns a short description of err that fits on one line.
+func errorSummary(err error) string {
+	if e, ok := err.(*errors.Error); ok && e.Kind != errors.Other {
+		return e.Kind.String()
+	}
+	return strings.Replace(err.Error(), "\n", " ", -1)
+}
+
 func (s *State) whichAccessFollowLinks(name upspin.PathName) (*upspin.DirEntry, error) {
 	var prevEntry *upspin.DirEntry
+	seen := make(map[upspin.PathName]bool) // Names already evaluated.
 	for loop := 0; loop < upspin.MaxLinkHops; loop++ {
 		entry, err := s.DirServer(name).WhichAccess(name)
 		if err == upspin.ErrFollowLink {
+			seen[name] = true
+			if seen[entry.Link] {
+				return nil, errors.E(errors.Invalid, name, errors.Errorf("link loop: %s leads back to %s", entry.Name, entry.Link))
+			}
 			name = entry.Link
 			continue
 		}
@@ -56,6 +268,62 @@ func (s *State) whichAccessFollowLinks(name upspin.PathName) (*upspin.DirEntry,
 		}
 		return entry, nil
 	}
-	s.Exitf("%s: link loop", name)
-	return nil, nil
+	return nil, errors.E(errors.Invalid, name, errors.Errorf("link loop: more than %d links", upspin.MaxLinkHops))
+}
+
+// whichAccessAll returns the results of WhichAccess for the names, without
+// following links. It asks each directory server about all its names at
+// once if the server implements upspin.WhichAccessBatcher.
+func (s *State) whichAccessAll(names []upspin.PathName) []upspin.WhichAccessResult {
+	// Group the names by directory server, in order of first appearance.
+	var dirs []upspin.DirServer
+	byDir := make(map[upspin.DirServer][]int)
+	for i, name := range names {
+		dir := s.DirServer(name)
+		if _, ok := byDir[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], i)
+	}
+	results := make([]upspin.WhichAccessResult, len(names))
+	for _, dir := range dirs {
+		indexes := byDir[dir]
+		if b, ok := dir.(upspin.WhichAccessBatcher); ok && len(indexes) > 1 {
+			if s.whichAccessBatch(b, names, indexes, results) {
+				continue
+			}
+		}
+		for _, i := range indexes {
+			entry, err := dir.WhichAccess(names[i])
+			results[i] = upspin.WhichAccessResult{Entry: entry, Error: err}
+		}
+	}
+	return results
+}
+
+// whichAccessBatch fills in the results for the names at the given indexes
+// using b. It reports false if b does not support WhichAccessBatch after all.
+func (s *State) whichAccessBatch(b upspin.WhichAccessBatcher, names []upspin.PathName, indexes []int, results []upspin.WhichAccessResult) bool {
+	for len(indexes) > 0 {
+		n := len(indexes)
+		if n > upspin.MaxWhichAccessBatch {
+			n = upspin.MaxWhichAccessBatch
+		}
+		batch := make([]upspin.PathName, n)
+		for j, i := range indexes[:n] {
+			batch[j] = names[i]
+		}
+		res, err := b.WhichAccessBatch(batch)
+		if err == upspin.ErrNotSupported {
+			return false
+		}
+		if err != nil {
+			s.Exit(err)
+		}
+		for j, i := range indexes[:n] {
+			results[i] = res[j]
+		}
+		indexes = indexes[n:]
+	}
+	return true
 }
@@ -0,0 +1,141 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"upspin.io/access"
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// accessTreeFiles holds an Access file and nested Group files, some of
+// which form a cycle and one of which cannot be read.
+var accessTreeFiles = map[upspin.PathName]string{
+	"ann@example.com/Access": "r,l: family, friends, *@other.com\n" +
+		"w: editors\n",
+	"ann@example.com/Group/family":  "chris@example.com, kids\n",
+	"ann@example.com/Group/kids":    "kelly@example.com, family\n",
+	"ann@example.com/Group/friends": "lee@example.com, bob@example.com/Group/club\n",
+	"ann@example.com/Group/editors": "kids\n",
+}
+
+func loadAccessTreeFile(name upspin.PathName) ([]byte, error) {
+	data, ok := accessTreeFiles[name]
+	if !ok {
+		return nil, errors.E(name, errors.NotExist)
+	}
+	return []byte(data), nil
+}
+
+func TestAccessTree(t *testing.T) {
+	const accessFile = "ann@example.com/Access"
+	tests := []struct {
+		user upspin.UserName
+		want string
+	}{
+		{
+			user: "",
+			want: `	read:
+		ann@example.com (owner)
+		ann@example.com/Group/family
+			ann@example.com/Group/kids
+				ann@example.com/Group/family (cycle)
+				kelly@example.com
+			chris@example.com
+		ann@example.com/Group/friends
+			bob@example.com/Group/club (unreachable: item does not exist)
+			lee@example.com
+		*@other.com
+	write:
+		ann@example.com/Group/editors
+			ann@example.com/Group/kids
+				ann@example.com/Group/family
+					ann@example.com/Group/kids (cycle)
+					chris@example.com
+				kelly@example.com
+	list:
+		ann@example.com (owner)
+		ann@example.com/Group/family
+			ann@example.com/Group/kids
+				ann@example.com/Group/family (cycle)
+				kelly@example.com
+			chris@example.com
+		ann@example.com/Group/friends
+			bob@example.com/Group/club (unreachable: item does not exist)
+			lee@example.com
+		*@other.com
+`,
+		},
+		{
+			user: "kelly@example.com",
+			want: `	read:
+		ann@example.com/Group/family
+			ann@example.com/Group/kids
+				kelly@example.com
+	write:
+		ann@example.com/Group/editors
+			ann@example.com/Group/kids
+				kelly@example.com
+	list:
+		ann@example.com/Group/family
+			ann@example.com/Group/kids
+				kelly@example.com
+`,
+		},
+		{
+			user: "lee@other.com",
+			want: `	read:
+		*@other.com
+	list:
+		*@other.com
+`,
+		},
+		{
+			// Owning a group grants the rights it is given.
+			user: "ann@example.com",
+			want: `	read:
+		ann@example.com (owner)
+		ann@example.com/Group/family (owned by ann@example.com)
+		ann@example.com/Group/friends (owned by ann@example.com)
+	write:
+		ann@example.com/Group/editors (owned by ann@example.com)
+	list:
+		ann@example.com (owner)
+		ann@example.com/Group/family (owned by ann@example.com)
+		ann@example.com/Group/friends (owned by ann@example.com)
+`,
+		},
+		{
+			// Even an unreachable group grants rights to its owner.
+			user: "bob@example.com",
+			want: `	read:
+		ann@example.com/Group/friends
+			bob@example.com/Group/club (owned by bob@example.com)
+	list:
+		ann@example.com/Group/friends
+			bob@example.com/Group/club (owned by bob@example.com)
+`,
+		},
+		{
+			user: "dan@example.com",
+			want: `	dan@example.com has no rights
+`,
+		},
+	}
+	a, err := access.Parse(accessFile, []byte(accessTreeFiles[accessFile]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range tests {
+		var b bytes.Buffer
+		newAccessTree(loadAccessTreeFile, test.user).write(&b, a)
+		if got := b.String(); got != test.want {
+			t.Errorf("user %q: got\n%s\nwant\n%s", test.user, got, test.want)
+		}
+	}
+}
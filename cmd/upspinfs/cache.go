@@ -9,7 +9,10 @@ package main // import "upspin.io/cmd/upspinfs"
 
 // Open files and a small cache of previously opened ones are cached
 // locally in disk files. File blocks are downloaded on demand when
-// read. If an existing file is written to, the whole file is read in
+// read, so reading part of a large file fetches only the blocks that
+// hold that part. Once a sequential reader is halfway through a block,
+// the following blocks are fetched in the background so they are ready
+// when needed. If an existing file is written to, the whole file is read in
 // since a new encryption key is chosen on writeback and old blocks
 // will no longer be valid even if unchanged.
 //
@@ -82,11 +85,29 @@ type cachedFile struct {
 	nBlocksLoaded int    // Number of blocks downloaded.
 	bu            upspin.BlockUnpacker
 	cachedSize    int64
+
+	// readEnd is the offset just past the last read, used to detect
+	// sequential reads. prefetched holds the blocks being fetched in
+	// the background, indexed by block number.
+	readEnd    int64
+	prefetched map[int]chan prefetch
+}
+
+// prefetch is the result of fetching a block's ciphertext ahead of a read.
+type prefetch struct {
+	cipher []byte
+	err    error
 }
 
+// prefetchBlocks is the number of blocks fetched ahead of a sequential reader.
+const prefetchBlocks = 2
+
 // Used only in testing. Incremented whenever a cacheblock is downloaded to a local cachefile.
 var cacheBlocksLoaded int64
 
+// Used only in testing. Incremented whenever a block is fetched ahead of a read.
+var cacheBlocksPrefetched int64
+
 func newCache(config upspin.Config, dir string, cacheSize int64) *cache {
 	c := &cache{dir: dir, client: client.New(config), lru: lrucache.NewLRU(maxRefs), lruMaxBytes: cacheSize}
 	os.Mkdir(dir, cacheDirPerms)
@@ -283,6 +304,7 @@ func (cf *cachedFile) detachDirEntry() {
 	cf.blocksLoaded = nil
 	cf.nBlocksLoaded = 0
 	cf.bu = nil
+	cf.prefetched = nil
 }
 
 // download insures that the local cache file contains at least the region specified
@@ -297,55 +319,85 @@ func (cf *cachedFile) download(offset int64, size int64) error {
 	if cf.de == nil || cf.nBlocksLoaded >= len(cf.de.Blocks) {
 		return nil // nothing to download
 	}
-	// Find beginning block in sequence.
-	bi := 0
-	for ; bi < len(cf.de.Blocks); bi++ {
-		b := &cf.de.Blocks[bi]
-		if offset >= b.Offset && offset < b.Offset+b.Size {
-			break
+	first, end := clientutil.BlockRange(cf.de.Blocks, offset, size)
+	for bi := first; bi < end; bi++ {
+		if cf.blocksLoaded[bi] {
+			continue
+		}
+		if err := cf.loadBlock(bi); err != nil {
+			return err
 		}
 	}
-	if bi >= len(cf.de.Blocks) {
-		return nil
-	}
+	return nil
+}
 
-	// Download blocks covering the range.
-	end := offset + size
-	for {
-		// Read the next block.
-		block, ok := cf.bu.SeekBlock(bi)
-		if !ok {
-			return nil // EOF
-		}
-		if block.Offset >= end {
-			return nil
-		}
-		if !cf.blocksLoaded[bi] {
-			// Not yet downloaded, download and decrypt.
-			cipher, err := clientutil.ReadLocation(cf.n.f.config, block.Location)
-			if err != nil {
+// loadBlock downloads and decrypts block bi into the local cache file,
+// using the ciphertext fetched by prefetch if there is any.
+func (cf *cachedFile) loadBlock(bi int) error {
+	block, ok := cf.bu.SeekBlock(bi)
+	if !ok {
+		return errors.Errorf("downloading %s: no block %d", cf.de.Name, bi)
+	}
+	var clear []byte
+	if c, ok := cf.prefetched[bi]; ok {
+		delete(cf.prefetched, bi)
+		if p := <-c; p.err == nil {
+			var err error
+			if clear, err = cf.bu.Unpack(p.cipher); err != nil {
 				return err
 			}
-			clear, err := cf.bu.Unpack(cipher)
-			if err != nil {
-				return err
-			}
-			for sofar := 0; sofar < len(clear); {
-				n, err := cf.file.WriteAt(clear[sofar:], block.Offset+int64(sofar))
-				if err != nil {
-					return err
-				}
-				sofar += n
-			}
-			cf.nBlocksLoaded++
-			atomic.AddInt64(&cacheBlocksLoaded, 1)
-			cf.blocksLoaded[bi] = true
 		}
-		bi++
 	}
+	if clear == nil {
+		// Not prefetched, or the prefetch failed; try again now.
+		var err error
+		if clear, err = clientutil.ReadBlock(cf.n.f.config, cf.bu, block); err != nil {
+			return err
+		}
+	}
+	for sofar := 0; sofar < len(clear); {
+		n, err := cf.file.WriteAt(clear[sofar:], block.Offset+int64(sofar))
+		if err != nil {
+			return err
+		}
+		sofar += n
+	}
+	cf.nBlocksLoaded++
+	atomic.AddInt64(&cacheBlocksLoaded, 1)
+	cf.blocksLoaded[bi] = true
 	return nil
 }
 
+// prefetch starts fetching, in the background, the ciphertext of the
+// blocks that follow the one holding offset. Only the fetch is done in
+// the background; the block is decrypted and written to the cache file
+// by loadBlock when it is read.
+func (cf *cachedFile) prefetch(offset int64) {
+	if cf.de == nil || cf.nBlocksLoaded >= len(cf.de.Blocks) {
+		return
+	}
+	first, _ := clientutil.BlockRange(cf.de.Blocks, offset, 1)
+	for bi := first + 1; bi <= first+prefetchBlocks && bi < len(cf.de.Blocks); bi++ {
+		loc := cf.de.Blocks[bi].Location
+		if cf.blocksLoaded[bi] || loc.Reference == upspin.ZeroReference {
+			continue
+		}
+		if _, ok := cf.prefetched[bi]; ok {
+			continue
+		}
+		if cf.prefetched == nil {
+			cf.prefetched = make(map[int]chan prefetch)
+		}
+		c := make(chan prefetch, 1)
+		cf.prefetched[bi] = c
+		atomic.AddInt64(&cacheBlocksPrefetched, 1)
+		go func(config upspin.Config) {
+			cipher, err := clientutil.ReadLocation(config, loc)
+			c <- prefetch{cipher: cipher, err: err}
+		}(cf.n.f.config)
+	}
+}
+
 // close is called when the last handle for a node has been closed.
 // Called with node locked.
 func (cf *cachedFile) close() {
@@ -507,9 +559,27 @@ func (cf *cachedFile) readAt(buf []byte, offset int64) (int, error) {
 	if err := cf.download(offset, int64(len(buf))); err != nil {
 		return 0, err
 	}
+	end := offset + int64(len(buf))
+	if offset > 0 && offset == cf.readEnd && cf.pastMidBlock(end-1) {
+		cf.prefetch(end - 1)
+	}
+	cf.readEnd = end
 	return cf.file.ReadAt(buf, offset)
 }
 
+// pastMidBlock reports whether offset lies in the second half of its block.
+func (cf *cachedFile) pastMidBlock(offset int64) bool {
+	if cf.de == nil {
+		return false
+	}
+	bi, end := clientutil.BlockRange(cf.de.Blocks, offset, 1)
+	if bi == end {
+		return false
+	}
+	b := &cf.de.Blocks[bi]
+	return offset-b.Offset >= b.Size/2
+}
+
 // writeAt writes to a cache file.
 func (cf *cachedFile) writeAt(buf []byte, offset int64) (int, error) {
 	cf.markDirty()
@@ -563,6 +633,15 @@ func (cf *cachedFile) writeback(n *node) error {
 	var de *upspin.DirEntry
 	for tries := 0; ; tries++ {
 		de, err = cf.c.client.PutSequenced(n.uname, n.seq, cleartext)
+		if err != nil && isConflict(err) {
+			de, err = n.f.resolveConflict(n, cleartext, err)
+			if err == nil && de == nil {
+				// Saved elsewhere. The cached version no longer
+				// matches the store, so it will not be reused.
+				cf.dirty = false
+				return nil
+			}
+		}
 		if err == nil {
 			n.seq = de.Sequence
 			cf.attachDirEntry(n.f.config, de, true)
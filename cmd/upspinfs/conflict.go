@@ -0,0 +1,83 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+// This file handles write backs that find the file changed elsewhere
+// since it was opened. See the package documentation.
+
+import (
+	goerrors "errors"
+	"os"
+	"time"
+
+	"upspin.io/client"
+	"upspin.io/errors"
+	"upspin.io/log"
+	"upspin.io/upspin"
+)
+
+// conflictMode says what to do when a write back finds the file changed.
+type conflictMode int
+
+const (
+	conflictFile conflictMode = iota
+	conflictClobber
+	conflictFail
+)
+
+// parseConflictMode parses the value of the -conflict flag.
+func parseConflictMode(s string) (conflictMode, error) {
+	switch s {
+	case "file":
+		return conflictFile, nil
+	case "clobber":
+		return conflictClobber, nil
+	case "fail":
+		return conflictFail, nil
+	}
+	return 0, errors.E(errors.Invalid, errors.Errorf("unknown conflict mode %q; want file, clobber, or fail", s))
+}
+
+// isConflict reports whether err, returned by a sequenced Put, means that
+// the file was created, changed or removed since its sequence was taken.
+func isConflict(err error) bool {
+	return goerrors.Is(err, client.ErrSequenceConflict)
+}
+
+// resolveConflict handles the failure, with error putErr, of a write back
+// of data to n, as the -conflict flag directs. It returns the entry for n
+// if n was written, or nil if the data was saved in a conflict file.
+// Called with node locked.
+func (f *upspinFS) resolveConflict(n *node, data []byte, putErr error) (*upspin.DirEntry, error) {
+	const op errors.Op = "resolveConflict"
+	switch f.conflict {
+	case conflictClobber:
+		log.Info.Printf("%s: changed elsewhere; overwriting", n.uname)
+		return f.client.PutSequenced(n.uname, upspin.SeqIgnore, data)
+	case conflictFile:
+		host, err := os.Hostname()
+		if err != nil {
+			host = "unknown"
+		}
+		name := conflictName(n.uname, host, time.Now())
+		if _, err := f.client.PutSequenced(name, upspin.SeqNotExist, data); err != nil {
+			return nil, errors.E(op, name, err)
+		}
+		log.Error.Printf("%s: changed elsewhere; local version saved as %s", n.uname, name)
+		// The node must now be read afresh.
+		n.f.watched.invalidateChan <- n
+		return nil, nil
+	}
+	return nil, putErr
+}
+
+// conflictName returns the name of the file to hold a conflicting
+// version of name written on host at time t.
+func conflictName(name upspin.PathName, host string, t time.Time) upspin.PathName {
+	return upspin.PathName(string(name) + ".conflict-" + host + "-" + t.Format("20060102T150405"))
+}
@@ -0,0 +1,211 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+// Files opened with O_DIRECT when the -allow-direct flag is set bypass
+// both the kernel's page cache and the local cache files. Every read
+// fetches and unpacks the blocks it covers from the store, and what is
+// written is held in memory by the handle and Put when the handle is
+// flushed or released.
+//
+// O_DIRECT normally requires offsets and lengths aligned to the device's
+// block size, typically 512 bytes. Upspin blocks are not aligned that
+// way, so requests of any offset and length are translated into reads of
+// whole Upspin blocks of which only the requested bytes are returned.
+
+import (
+	"upspin.io/client/clientutil"
+	"upspin.io/errors"
+	"upspin.io/pack"
+	"upspin.io/upspin"
+)
+
+// directFile holds the state of a handle opened for direct I/O.
+type directFile struct {
+	n  *node
+	de *upspin.DirEntry // Entry as of the open; nil for a created file.
+	bu upspin.BlockUnpacker
+
+	// The following are set once the handle has been written to, when
+	// the contents of the file are read into memory.
+	loaded bool   // True if data holds the contents of the file.
+	dirty  bool   // True if data needs to be written back.
+	data   []byte // Contents of the file.
+}
+
+// openDirect opens a file for direct I/O. If create is set, the file is
+// new and empty. The corresponding node should be locked.
+func openDirect(h *handle, create bool) error {
+	const op errors.Op = "openDirect"
+	n := h.n
+	d := &directFile{n: n}
+	if create {
+		d.loaded = true
+		d.dirty = true
+		n.seq = upspin.SeqNotExist
+		h.direct = d
+		return nil
+	}
+	dir, err := n.f.dirLookup(n.user)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	entry, err := dir.Lookup(n.uname)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	packer := pack.Lookup(entry.Packing)
+	if packer == nil {
+		return errors.E(op, entry.Name, errors.Errorf("unrecognized Packing %d", entry.Packing))
+	}
+	if d.bu, err = packer.Unpack(n.f.config, entry); err != nil {
+		return errors.E(op, entry.Name, err)
+	}
+	size, err := entry.Size()
+	if err != nil {
+		return errors.E(op, entry.Name, err)
+	}
+	d.de = entry
+	n.seq = entry.Sequence
+	n.attr.Size = uint64(size)
+	h.direct = d
+	return nil
+}
+
+// readAt reads from the store, or from memory once the file has been written.
+func (d *directFile) readAt(buf []byte, offset int64) (int, error) {
+	if offset < 0 {
+		return 0, errors.Errorf("reading %s: bad offset %d", d.n.uname, offset)
+	}
+	if d.loaded {
+		if offset >= int64(len(d.data)) {
+			return 0, nil
+		}
+		return copy(buf, d.data[offset:]), nil
+	}
+	end := offset + int64(len(buf))
+	sofar := 0
+	for i := range d.de.Blocks {
+		b := d.de.Blocks[i]
+		if b.Offset+b.Size <= offset {
+			continue
+		}
+		if b.Offset >= end {
+			break
+		}
+		block, ok := d.bu.SeekBlock(i)
+		if !ok {
+			break
+		}
+		clear, err := clientutil.ReadBlock(d.n.f.config, d.bu, block)
+		if err != nil {
+			return sofar, err
+		}
+		start := int64(0)
+		if offset > block.Offset {
+			start = offset - block.Offset
+		}
+		if start >= int64(len(clear)) {
+			continue
+		}
+		sofar += copy(buf[sofar:], clear[start:])
+		if sofar == len(buf) {
+			break
+		}
+	}
+	return sofar, nil
+}
+
+// load reads the whole file into memory, fetching it from the store.
+func (d *directFile) load() error {
+	if d.loaded {
+		return nil
+	}
+	size, err := d.de.Size()
+	if err != nil {
+		return err
+	}
+	data := make([]byte, size)
+	n, err := d.readAt(data, 0)
+	if err != nil {
+		return err
+	}
+	d.data = data[:n]
+	d.loaded = true
+	return nil
+}
+
+// writeAt writes to the in memory copy of the file.
+func (d *directFile) writeAt(buf []byte, offset int64) (int, error) {
+	if offset < 0 {
+		return 0, errors.Errorf("writing %s: bad offset %d", d.n.uname, offset)
+	}
+	if err := d.load(); err != nil {
+		return 0, err
+	}
+	if end := offset + int64(len(buf)); end > int64(len(d.data)) {
+		d.resize(end)
+	}
+	d.dirty = true
+	return copy(d.data[offset:], buf), nil
+}
+
+// truncate truncates or extends with zeros the in memory copy of the file.
+func (d *directFile) truncate(size int64) error {
+	if size < 0 {
+		return nil
+	}
+	if size == 0 {
+		d.data = nil
+		d.loaded = true
+	} else if err := d.load(); err != nil {
+		return err
+	}
+	d.resize(size)
+	d.dirty = true
+	return nil
+}
+
+// resize sets the length of data, extending it with zeros if needed.
+func (d *directFile) resize(size int64) {
+	if size <= int64(len(d.data)) {
+		d.data = d.data[:size]
+		return
+	}
+	data := make([]byte, size)
+	copy(data, d.data)
+	d.data = data
+}
+
+// writeback writes the file to the store if it is dirty. Called with node locked.
+func (d *directFile) writeback() error {
+	const op errors.Op = "direct.writeback"
+	if d == nil || !d.dirty || d.n.noWB {
+		return nil
+	}
+	n := d.n
+	de, err := n.f.client.PutSequenced(n.uname, n.seq, d.data)
+	if err != nil && isConflict(err) {
+		de, err = n.f.resolveConflict(n, d.data, err)
+		if err == nil && de == nil {
+			d.dirty = false
+			return nil
+		}
+	}
+	if err != nil {
+		return errors.E(op, err)
+	}
+	d.dirty = false
+	n.seq = de.Sequence
+	n.attr.Size = uint64(len(d.data))
+	n.attr.Mtime = de.Time.Go()
+
+	// Other handles may be caching the old version.
+	n.f.watched.invalidateChan <- n
+	return nil
+}
@@ -6,7 +6,9 @@
 
 /*
 Command upspinfs is a FUSE interface for Upspin. It presents Upspin
-files as a locally mounted file system.
+files as a locally mounted file system. It runs on Linux and macOS,
+and other systems supported by the FUSE package it uses, but not on
+Windows.
 
 If the config or flags specify a cache server endpoint and cacheserver
 is not running, upspinfs will attempt to start one. All the flags
@@ -20,14 +22,32 @@ Usage:
 
 The flags are:
 
+	-allow-direct
+		files opened with O_DIRECT bypass the kernel and local caches
+	-async-flush
+		close returns before a written file is stored; see below
 	-cachedir directory
 		'directory' will contain all file caches (default "$HOME/upspin")
 	-cachesize bytes
 		max disk bytes for cache (default 5000000000)
 	-config file
 		user's configuration file (default "$HOME/upspin/config")
+	-conflict mode
+		what to do with a file changed elsewhere while open: file,
+		clobber, or fail (default file); see below
+	-fuse-options list
+		comma-separated list of FUSE mount options to pass through;
+		see below
 	-log level
-		level of logging: debug, info, error, disabled (default info)
+		level of logging: debug, info, error, disabled; may be followed by
+		subsystem=level pairs, as in info,dir/server=debug (default info)
+	-logformat format
+		format of log messages: text, json (default text)
+	-persist-permissions
+		save permission bits set by chmod in a .permissions file in each directory
+	-readonly[=path]
+		mount read-only; with a path, which may be repeated, make
+		only that tree read-only; see below
 	-writethrough
 		make storage cache writethrough
 
@@ -51,14 +71,89 @@ different than Posix, some things will be different:
 - Permission bits are settable but are not stored in Upspin.
 After the final close of a file, when the kernel and FUSE decide to
 forget about the file, permissions will revert to 0700.
+With the -persist-permissions flag, permission bits set by chmod are
+instead saved in a JSON file named .permissions in the file's directory,
+stored in Upspin like any other file, and restored when the directory is
+read. The saved bits follow a file when it is renamed and survive
+restarts of upspinfs, so executable bits on scripts and binaries persist.
 The access to a file is determined by the intersection of the permission
 bits and the relevant Access file.
 
-- While random access will work, the first time a file is opened
-for read, it is read in its entirety and cached locally.
+- Files are read a block at a time: reading part of a file fetches
+only the blocks that hold that part, and the blocks are cached locally.
+Sequential reads fetch the following blocks ahead of time. Writing to
+an existing file reads it in its entirety first.
 
-- Hard links are really copy on write.
-The two names will refer to the original data until either file is changed.
-They will then diverge.
+- With -readonly, any attempt to change the file system fails with
+EROFS and the mount is read-only, so statfs reports it as such. A
+cacheserver started by upspinfs is then writethrough. Given
+-readonly=ann@example.com/ or the path name of any other tree, only
+changes within that tree fail, again with EROFS and without contacting
+any server.
+
+- Changes made by other clients are noticed by watching the directory
+servers of the trees in use, and what the kernel has cached about a
+changed file is discarded, so new contents are seen promptly. For a
+server that does not support Watch, or while a Watch is being
+reestablished, upspinfs instead asks the server again about a file
+that has not been checked recently.
+
+- O_DIRECT is ignored unless the -allow-direct flag is set. With it,
+on Linux, every read of a file opened with O_DIRECT fetches the blocks
+it covers from the store, and what is written is kept in memory and
+stored when the file is closed; neither passes through the kernel's
+page cache or the local cache. Offsets and lengths need not be aligned
+to 512 bytes.
+
+- A file written through upspinfs is stored in Upspin when it is
+closed, and close does not return until it has been. With the
+-async-flush flag, close returns at once and the file is stored in the
+background. Until it has been, any other open, stat, rename or removal
+of the file waits, so a later open, by any process, sees what was
+written, as with NFS close-to-open consistency. If storing the file
+fails, the error is returned by the next open or stat of it, and the
+data written is lost. The trade-off is one of latency for safety: a
+crash of upspinfs or of the system before the store completes also
+loses the data, with no error reported to the writer. Fsync waits for
+a pending store and reports its error.
+
+- A file is stored only if it has not changed since it was opened,
+whether through another upspinfs or any other client. If it has, the
+-conflict flag says what to do. With "file", the default, what was
+written is stored instead in a new file in the same directory, named
+for the original with ".conflict-host-time" appended, and the conflict
+is logged; the original keeps the other version. With "clobber" what
+was written replaces the other version, as if the conflict had not
+happened. With "fail" what was written is discarded and close, or
+fsync, returns an error.
+
+- The Upspin metadata of a file is presented as read-only extended
+attributes: user.upspin.writer, packing, sequence, time, signedname,
+link for a link, and blocks, a JSON object holding the number of blocks
+and the offset, size, endpoint and reference of at most the first 64.
+The user-defined attributes of the entry, as set by "upspin put -attr",
+appear as user.upspin.attr.name.
+Setting or removing them fails with EPERM; other extended attributes
+are not supported.
+
+- Statfs, and so df, reports the space used by the user's tree in a
+file system that appears very large. The space used is found by walking
+the tree in the background; until the first walk is done none is
+reported. The figure is refreshed at most every five minutes.
+
+- Platform-specific FUSE mount options may be given with -fuse-options,
+as in -fuse-options=max_readahead=131072,noappledouble. Only options
+that cannot weaken the security of the mount or conflict with those
+upspinfs sets itself are accepted: async_read, default_permissions,
+max_readahead=bytes and writeback_cache everywhere; nonempty on Linux;
+and daemon_timeout=seconds, excl_create, noappledouble, noapplexattr,
+nobrowse and volname=name on macOS. Others, such as dev, suid or
+fsname, are rejected. An option not supported by the platform is
+ignored.
+
+- Renaming a file and making a hard link to it copy no data: the new
+entry refers to the blocks already stored. A hard link is really copy
+on write. The two names refer to the original data until either file
+is changed; they then diverge.
 */
 package main
@@ -35,6 +35,7 @@ var errs = []struct {
 	str   string
 	errno syscall.Errno
 }{
+	{"link loop", syscall.ELOOP},
 	{"not found", syscall.ENOENT},
 	{"not a directory", syscall.ENOTDIR},
 	{"no such", syscall.ENOENT},
@@ -51,6 +52,7 @@ var errnoToKind = map[syscall.Errno]errors.Kind{
 	syscall.EISDIR:    errors.IsDir,
 	syscall.ENOTDIR:   errors.NotDir,
 	syscall.ENOTEMPTY: errors.NotEmpty,
+	syscall.ELOOP:     errors.Invalid,
 }
 
 var kindToErrno = map[errors.Kind]syscall.Errno{
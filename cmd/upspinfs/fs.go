@@ -67,6 +67,13 @@ type upspinFS struct {
 	enoentMap  map[upspin.PathName]time.Time // A map of non-existent names.
 	server     *fs.Server                    // The Bazil server interface.
 	watched    *watchedRoots                 // Directory servers being watched.
+	perms      *perms                        // Saved permission bits; nil unless persisting them.
+	direct     bool                          // Honor O_DIRECT; see direct.go.
+	async      bool                          // Store files in the background after the final close.
+	conflict   conflictMode                  // What to do if a file changed elsewhere; see conflict.go.
+	readOnly   readOnly                      // Names that cannot be changed; see readonly.go.
+	flushes    sync.WaitGroup                // Background stores in progress.
+	usageCache statfsUsage                   // What statfs reports; see statfs.go.
 }
 
 type nodeType uint8
@@ -91,14 +98,14 @@ type node struct {
 	link       upspin.PathName  // If this is a symlink, the target.
 	noWB       bool             // Don't write back if set.
 	deleted    bool             // A watch event deleted this node.
+	flushErr   error            // Error from a background store, not yet reported.
 
 	// cached info.
 	cf  *cachedFile        // Local file system contents of this node.
 	de  []*upspin.DirEntry // Directory contents of this node.
 	seq int64              // Seq when last opened.
 
-	refreshTime  time.Time // The next refresh of the node info.
-	doNotRefresh bool      // True if we should not try refreshing.
+	refreshTime time.Time // The next refresh of the node info.
 }
 
 func (n *node) String() string {
@@ -107,9 +114,10 @@ func (n *node) String() string {
 
 // handle represents an open file.
 type handle struct {
-	n     *node          // Associated node.
-	flags fuse.OpenFlags // flags used to  open the file.
-	id    int
+	n      *node          // Associated node.
+	flags  fuse.OpenFlags // flags used to  open the file.
+	id     int
+	direct *directFile // If non-nil, the handle bypasses the cache; see direct.go.
 }
 
 func (h *handle) String() string {
@@ -117,7 +125,14 @@ func (h *handle) String() string {
 }
 
 // newUpspinFS creates a new Upspin file system.
-func newUpspinFS(config upspin.Config, mountpoint string, cacheDir string, cacheSize int64) *upspinFS {
+// If persistPerms is set, permission bits set by chmod are saved in
+// Upspin; see perms.go. If allowDirect is set, files opened with O_DIRECT
+// bypass the cache; see direct.go. If asyncFlush is set, files are stored
+// in the background after their final close; see Release. The conflict
+// mode says what to do when a file written back was changed elsewhere
+// since it was opened; see conflict.go. Names covered by ro cannot be
+// changed; see readonly.go.
+func newUpspinFS(config upspin.Config, mountpoint string, cacheDir string, cacheSize int64, persistPerms, allowDirect, asyncFlush bool, conflict conflictMode, ro readOnly) *upspinFS {
 	sep := string(filepath.Separator)
 	if !strings.HasSuffix(mountpoint, sep) {
 		mountpoint = mountpoint + sep
@@ -131,9 +146,16 @@ func newUpspinFS(config upspin.Config, mountpoint string, cacheDir string, cache
 		userDirs:   make(map[string]bool),
 		nodeMap:    make(map[upspin.PathName]*node),
 		enoentMap:  make(map[upspin.PathName]time.Time),
+		direct:     allowDirect,
+		async:      asyncFlush,
+		conflict:   conflict,
+		readOnly:   ro,
 	}
 	f.cache = newCache(config, cacheDir+"/fscache", cacheSize)
 	f.watched = newWatchedDirs(f)
+	if persistPerms {
+		f.perms = newPerms(f)
+	}
 
 	// Preallocate root node.
 	f.root = f.allocNode(nil, "", 0500|os.ModeDir, 0, time.Now())
@@ -148,19 +170,6 @@ func (f *upspinFS) Root() (fs.Node, error) {
 	return f.root, nil
 }
 
-// Statfs implements fs.Statfser.  We make up the response just to keep FUSE happy.
-func (f *upspinFS) Statfs(ctx gContext.Context, req *fuse.StatfsRequest, resp *fuse.StatfsResponse) error {
-	resp.Blocks = 1000000000 // Total data blocks in file system.
-	resp.Bfree = 1000000000  // Free blocks in file system.
-	resp.Bavail = 1000000000 // Free blocks in file system if you're not root.
-	resp.Files = 100000      // Total files in file system.
-	resp.Ffree = 100000      // Free files in file system.
-	resp.Bsize = 64 * 1024   // Block size
-	resp.Namelen = 256       // Maximum file name length?
-	resp.Frsize = 1          // Fragment size, smallest addressable data size in the file system.
-	return nil
-}
-
 func (f *upspinFS) allocNode(parent *node, name string, mode os.FileMode, size uint64, mtime time.Time) *node {
 	n := &node{f: f}
 	now := time.Now()
@@ -202,6 +211,23 @@ func (f *upspinFS) allocNode(parent *node, name string, mode os.FileMode, size u
 	return n
 }
 
+// mode returns the mode bits with which the node for de is presented.
+func (f *upspinFS) mode(de *upspin.DirEntry) os.FileMode {
+	mode := os.FileMode(unixPermissions)
+	if f.perms != nil {
+		if saved, ok := f.perms.mode(de.Name); ok {
+			mode = saved
+		}
+	}
+	if de.IsDir() {
+		mode |= os.ModeDir
+	}
+	if de.IsLink() {
+		mode |= os.ModeSymlink
+	}
+	return mode
+}
+
 // dirLookup returns a bound directory for user 'name'.
 func (f *upspinFS) dirLookup(name upspin.UserName) (upspin.DirServer, error) {
 	return bind.DirServerFor(f.config, name)
@@ -221,6 +247,11 @@ func allocHandle(n *node) *handle {
 	return h
 }
 
+// isDirect reports whether a file opened with flags should bypass the cache.
+func (f *upspinFS) isDirect(flags fuse.OpenFlags) bool {
+	return f.direct && openDirectFlag != 0 && flags&openDirectFlag != 0
+}
+
 func (h *handle) freeNoLock() {
 	n := h.n
 	delete(n.handles, h)
@@ -239,6 +270,9 @@ func (n *node) Attr(addscontext gContext.Context, attr *fuse.Attr) error {
 	if n.deleted {
 		return e2e(errors.E(op, errors.NotExist, n.uname))
 	}
+	if err := n.takeFlushErr(); err != nil {
+		return e2e(errors.E(op, n.uname, err))
+	}
 	if err := n.f.watched.refresh(n); err != nil {
 		return err
 	}
@@ -271,6 +305,9 @@ func (n *node) Create(context gContext.Context, req *fuse.CreateRequest, resp *f
 		// them, they are implied.
 		return nil, nil, e2e(errors.E(op, errors.Permission, "can't create in root"))
 	}
+	if err := f.checkWritable(op, path.Join(n.uname, req.Name)); err != nil {
+		return nil, nil, err
+	}
 
 	// A new node.
 	nn := f.allocNode(n, req.Name, unixPermissions, 0, time.Now())
@@ -286,7 +323,15 @@ func (n *node) Create(context gContext.Context, req *fuse.CreateRequest, resp *f
 	nn.Lock()
 	defer nn.Unlock()
 	h := allocHandle(nn)
-	if err := f.cache.create(h); err != nil {
+	if f.isDirect(req.Flags) {
+		if err := openDirect(h, true); err != nil {
+			return nil, nil, e2e(errors.E(op, err))
+		}
+		h.flags = req.Flags
+		if resp != nil {
+			resp.Flags |= fuse.OpenDirectIO
+		}
+	} else if err := f.cache.create(h); err != nil {
 		return nil, nil, e2e(errors.E(op, err))
 	}
 	if resp != nil {
@@ -304,6 +349,9 @@ func (n *node) Mkdir(context gContext.Context, req *fuse.MkdirRequest) (fs.Node,
 	const op errors.Op = "Mkdir"
 	n.Lock()
 	defer n.Unlock()
+	if err := n.f.checkWritable(op, path.Join(n.uname, req.Name)); err != nil {
+		return nil, err
+	}
 
 	nn := n.f.allocNode(n, req.Name, unixPermissions|os.ModeDir, 0, time.Now())
 	nn.attr.Uid = req.Header.Uid
@@ -402,6 +450,9 @@ func (n *node) openDir(context gContext.Context, req *fuse.OpenRequest, resp *fu
 	if err != nil {
 		return nil, e2e(errors.E(op, err, n.uname))
 	}
+	if n.f.perms != nil {
+		n.f.perms.refresh(n.uname)
+	}
 	n.Lock()
 	h := allocHandle(n)
 	n.de = de
@@ -431,6 +482,9 @@ func (n *node) openDir(context gContext.Context, req *fuse.OpenRequest, resp *fu
 			return nil, e2e(errors.E(op, err, n.uname))
 		}
 		cn.attr.Mtime = child.Time.Go()
+		if n.f.perms != nil {
+			cn.attr.Mode = n.f.mode(child)
+		}
 		cn.Unlock()
 	}
 	return h, nil
@@ -441,6 +495,9 @@ func (n *node) openFile(context gContext.Context, req *fuse.OpenRequest, resp *f
 	const op errors.Op = "Open"
 	n.Lock()
 	defer n.Unlock()
+	if err := n.takeFlushErr(); err != nil {
+		return nil, e2e(errors.E(op, n.uname, err))
+	}
 	n.f.watched.refresh(n)
 	if n.attr.Mode&os.ModeDir != 0 {
 		return nil, e2e(errors.E(op, errors.IsDir, n.uname))
@@ -448,12 +505,24 @@ func (n *node) openFile(context gContext.Context, req *fuse.OpenRequest, resp *f
 
 	// Make sure we can actually write this node if requested.
 	if req.Flags.IsWriteOnly() || req.Flags.IsReadWrite() {
+		if err := n.f.checkWritable(op, n.uname); err != nil {
+			return nil, err
+		}
 		if err := n.f.checkAccess(n.uname, n.user, access.Write); err != nil {
 			return nil, e2e(errors.E(op, err))
 		}
 	}
 
 	h := allocHandle(n)
+	if n.f.isDirect(req.Flags) {
+		if err := openDirect(h, false); err != nil {
+			h.freeNoLock()
+			return nil, e2e(errors.E(op, err, n.uname))
+		}
+		h.flags = req.Flags
+		resp.Flags |= fuse.OpenDirectIO
+		return h, nil
+	}
 	err := n.f.cache.open(h, req.Flags)
 	if err != nil {
 		return nil, e2e(errors.E(op, err, n.uname))
@@ -526,6 +595,9 @@ func (n *node) Remove(context gContext.Context, req *fuse.RemoveRequest) error {
 	defer n.Unlock()
 
 	uname := path.Join(n.uname, req.Name)
+	if err := n.f.checkWritable(op, uname); err != nil {
+		return err
+	}
 
 	// Find the node in question.
 	dir, de, err := n.directoryLookup(uname)
@@ -544,6 +616,15 @@ func (n *node) Remove(context gContext.Context, req *fuse.RemoveRequest) error {
 		}
 	}
 
+	// Wait for any background store of the file, lest it recreate it.
+	n.f.Lock()
+	fn := n.f.nodeMap[uname]
+	n.f.Unlock()
+	if fn != nil {
+		fn.Lock()
+		fn.Unlock()
+	}
+
 	// Delete from the directory (but not the store).
 	_, err = dir.Delete(uname)
 	if err != nil {
@@ -551,8 +632,15 @@ func (n *node) Remove(context gContext.Context, req *fuse.RemoveRequest) error {
 		return e2e(errors.E(op, uname, err))
 	}
 
+	// Forget any saved permissions.
+	if n.f.perms != nil {
+		if err := n.f.perms.remove(uname); err != nil {
+			log.Debug.Printf("%s: %s", op, err)
+		}
+	}
+
 	// Fix the node maps.
-	fn := n.f.doesNotExist(uname)
+	fn = n.f.doesNotExist(uname)
 
 	// Avoid write back if the file is currently in use.
 	if fn != nil {
@@ -632,19 +720,12 @@ func (n *node) Lookup(context gContext.Context, name string) (fs.Node, error) {
 	}
 
 	// Make a node to hand back to fuse.
-	mode := os.FileMode(unixPermissions)
-	if de.IsDir() {
-		mode |= os.ModeDir
-	}
-	if de.IsLink() {
-		mode |= os.ModeSymlink
-	}
 	size, err := lstatSize(de, n)
 	if err != nil {
 		f.removeMapping(uname)
 		return nil, e2e(errors.E(op, n.uname, err))
 	}
-	nn := n.f.allocNode(n, name, mode, size, de.Time.Go())
+	nn := n.f.allocNode(n, name, f.mode(de), size, de.Time.Go())
 	if de.IsLink() {
 		nn.link = upspin.PathName(de.Link)
 	}
@@ -722,6 +803,9 @@ func (n *node) Forget() {
 // Files are only truncated by Setattr calls.
 func (n *node) Setattr(context gContext.Context, req *fuse.SetattrRequest, resp *fuse.SetattrResponse) error {
 	const op errors.Op = "Setattr"
+	if err := n.f.checkWritable(op, n.uname); err != nil {
+		return err
+	}
 	if req.Valid.Size() {
 		// Truncate.  Lots of cases:
 		// 1) we have it opened. Truncate the cached file and
@@ -733,7 +817,7 @@ func (n *node) Setattr(context gContext.Context, req *fuse.SetattrRequest, resp
 		//    a cached file, truncate, and write back to dir/store.
 		n.Lock()
 		if len(n.handles) > 0 {
-			if err := n.cf.truncate(n, int64(req.Size)); err != nil {
+			if err := n.truncateOpen(int64(req.Size)); err != nil {
 				n.Unlock()
 				return e2e(errors.E(op, n.uname, err))
 			}
@@ -764,6 +848,16 @@ func (n *node) Setattr(context gContext.Context, req *fuse.SetattrRequest, resp
 		}
 		n.attr.Size = req.Size
 	}
+	if req.Valid.Mode() && n.f.perms != nil && n.t == otherNode {
+		n.Lock()
+		mode := req.Mode & os.ModePerm
+		if err := n.f.perms.set(n.uname, mode); err != nil {
+			n.Unlock()
+			return e2e(errors.E(op, n.uname, err))
+		}
+		n.attr.Mode = n.attr.Mode&^os.ModePerm | mode
+		n.Unlock()
+	}
 	if req.Valid.Mtime() {
 		n.Lock()
 		defer n.Unlock()
@@ -786,18 +880,50 @@ func (n *node) Setattr(context gContext.Context, req *fuse.SetattrRequest, resp
 		n.seq = e.Sequence
 		n.attr.Mtime = req.Mtime
 	}
-	// Ignore mode changes.
+	// Mode changes are ignored unless permissions are being persisted.
 	return nil
 }
 
+// truncateOpen truncates an open file, both the cached version and the
+// copies held by handles opened for direct I/O. Called with node locked.
+func (n *node) truncateOpen(size int64) error {
+	if n.cf != nil {
+		if err := n.cf.truncate(n, size); err != nil {
+			return err
+		}
+	}
+	for h := range n.handles {
+		if h.direct == nil {
+			continue
+		}
+		if err := h.direct.truncate(size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeback writes back what was written through h.
+// Called with node locked.
+func (h *handle) writeback() error {
+	if h.direct != nil {
+		return h.direct.writeback()
+	}
+	return h.n.cf.writeback(h.n)
+}
+
 // Flush implements fs.HandleFlusher.Flush.  Called when a file is closed or synced.
+// With -async-flush, it does nothing, leaving the write back to Release.
 func (h *handle) Flush(context gContext.Context, req *fuse.FlushRequest) error {
 	const op errors.Op = "Flush"
+	if h.n.f.async {
+		return nil
+	}
 
 	// Write back to upspin.
 	h.n.Lock()
 	defer h.n.Unlock()
-	if err := h.n.cf.writeback(h.n); err != nil {
+	if err := h.writeback(); err != nil {
 		return e2e(errors.E(op, h.n.uname, err))
 	}
 
@@ -826,7 +952,13 @@ func (h *handle) Read(context gContext.Context, req *fuse.ReadRequest, resp *fus
 	h.n.Lock()
 	defer h.n.Unlock()
 	resp.Data = make([]byte, cap(resp.Data))
-	n, err := h.n.cf.readAt(resp.Data, req.Offset)
+	var n int
+	var err error
+	if h.direct != nil {
+		n, err = h.direct.readAt(resp.Data, req.Offset)
+	} else {
+		n, err = h.n.cf.readAt(resp.Data, req.Offset)
+	}
 	if n != len(resp.Data) {
 		resp.Data = resp.Data[:n]
 	}
@@ -845,7 +977,13 @@ func (h *handle) Write(context gContext.Context, req *fuse.WriteRequest, resp *f
 	const op errors.Op = "Write"
 	h.n.Lock()
 	defer h.n.Unlock()
-	n, err := h.n.cf.writeAt(req.Data, req.Offset)
+	var n int
+	var err error
+	if h.direct != nil {
+		n, err = h.direct.writeAt(req.Data, req.Offset)
+	} else {
+		n, err = h.n.cf.writeAt(req.Data, req.Offset)
+	}
 	if err != nil {
 		err = e2e(errors.E(op, h.n.uname, err))
 	}
@@ -860,14 +998,24 @@ func (h *handle) Write(context gContext.Context, req *fuse.WriteRequest, resp *f
 
 // Release implements fs.HandleWriter.Release. Similar to Flush but only when
 // a file is finally closed.
+//
+// With -async-flush, a release from the kernel (req is not nil) that has
+// something to write back returns at once, leaving the write back to a
+// goroutine. The node stays locked until the write back is done, so that
+// anything else done to the file waits for it.
 // TODO(p): If we fail writing a file, should we try later asynchronously?
 func (h *handle) Release(context gContext.Context, req *fuse.ReleaseRequest) error {
 	const op errors.Op = "Release"
 
 	// Write back to upspin.
 	h.n.Lock()
+	if req != nil && h.n.f.async && h.dirty() {
+		h.n.f.flushes.Add(1)
+		go h.releaseAsync()
+		return nil
+	}
 	defer h.n.Unlock()
-	err := h.n.cf.writeback(h.n)
+	err := h.writeback()
 	if err != nil {
 		err = e2e(errors.E(op, h.n.uname, err))
 	}
@@ -875,16 +1023,105 @@ func (h *handle) Release(context gContext.Context, req *fuse.ReleaseRequest) err
 	return err
 }
 
-// Fsync implements fs.NodeFsyncer.Fsync.
+// releaseAsync completes a Release in the background. It is called with
+// the node locked, and unlocks it when done.
+func (h *handle) releaseAsync() {
+	const op errors.Op = "Release"
+	n := h.n
+	defer n.f.flushes.Done()
+	defer n.Unlock()
+	if err := h.writeback(); err != nil {
+		err = errors.E(op, n.uname, err)
+		log.Error.Print(err)
+		n.flushErr = err
+	}
+	h.freeNoLock()
+}
+
+// dirty reports whether there is anything to write back through h.
+// Called with node locked.
+func (h *handle) dirty() bool {
+	if h.n.noWB {
+		return false
+	}
+	if h.direct != nil {
+		return h.direct.dirty
+	}
+	return h.n.cf != nil && h.n.cf.dirty
+}
+
+// dirty reports whether n has been written locally and not yet written
+// back. Called with node locked.
+func (n *node) dirty() bool {
+	if n.cf != nil && n.cf.dirty {
+		return true
+	}
+	for h := range n.handles {
+		if h.direct != nil && h.direct.dirty {
+			return true
+		}
+	}
+	return false
+}
+
+// takeFlushErr returns, and forgets, the error from the last background
+// write back of the node, if any.
+// Called with node locked.
+func (n *node) takeFlushErr() error {
+	err := n.flushErr
+	n.flushErr = nil
+	return err
+}
+
+// Fsync implements fs.NodeFsyncer.Fsync. With -async-flush, it waits for
+// any background write back of the file and reports its error.
 func (n *node) Fsync(ctx gContext.Context, req *fuse.FsyncRequest) error {
+	const op errors.Op = "Fsync"
+	if !n.f.async {
+		return nil
+	}
+	n.Lock()
+	defer n.Unlock()
+	if err := n.takeFlushErr(); err != nil {
+		return e2e(errors.E(op, n.uname, err))
+	}
 	return nil
 }
 
-// Link implements fs.NodeLinker.Link. It creates a new node in directory n that points to the same
-// reference as old.
+// Link implements fs.NodeLinker.Link. It creates a new node in directory n
+// whose Upspin entry refers to the same blocks as old, so no data is copied.
+// The two are separate files that share their contents only until either
+// is written, when it is stored in new blocks and the two diverge.
 func (n *node) Link(ctx gContext.Context, req *fuse.LinkRequest, old fs.Node) (fs.Node, error) {
 	const op errors.Op = "Link"
-	return nil, unsupported(errors.E(op, n.uname, errors.Str("hard link unsuported")))
+	on := old.(*node)
+	f := n.f
+	n.Lock()
+	defer n.Unlock()
+	newPath := path.Join(n.uname, req.NewName)
+	if n.t == rootNode {
+		return nil, e2e(errors.E(op, newPath, errors.Permission, "can't link in root"))
+	}
+	if err := f.checkWritable(op, newPath); err != nil {
+		return nil, err
+	}
+
+	// Locking old waits for any background store of it to finish, so
+	// the link shares what was last written.
+	on.Lock()
+	defer on.Unlock()
+	if on.attr.Mode.IsDir() {
+		return nil, e2e(errors.E(op, on.uname, errors.Permission, "can't link a directory"))
+	}
+	de, err := f.client.PutDuplicate(on.uname, newPath)
+	if err != nil {
+		return nil, e2e(errors.E(op, newPath, err))
+	}
+	nn := f.allocNode(n, req.NewName, on.attr.Mode, uint64(on.attr.Size), de.Time.Go())
+	nn.link = on.link
+	nn.seq = de.Sequence
+	nn.exists()
+	return nn, nil
 }
 
 // Rename implements fs.Renamer.Rename. It renames the old node to r.NewName in directory n.
@@ -892,6 +1129,9 @@ func (n *node) Rename(ctx gContext.Context, req *fuse.RenameRequest, newDir fs.N
 	const op errors.Op = "Rename"
 	nn := newDir.(*node)
 	f := n.f
+	if err := f.checkWritable(op, path.Join(n.uname, req.OldName), path.Join(nn.uname, req.NewName)); err != nil {
+		return err
+	}
 
 	// Lock both dirs in fixed order to avoid deadlock. Obey lock ordering.
 	if n.uname == nn.uname {
@@ -944,6 +1184,13 @@ func (n *node) Rename(ctx gContext.Context, req *fuse.RenameRequest, newDir fs.N
 		}
 	}
 
+	// Saved permissions follow the file.
+	if f.perms != nil {
+		if err := f.perms.rename(oldPath, newPath); err != nil {
+			log.Debug.Printf("%s: %s", op, err)
+		}
+	}
+
 	f.Lock()
 	defer f.Unlock()
 	if newn != nil {
@@ -968,29 +1215,6 @@ func (n *node) Rename(ctx gContext.Context, req *fuse.RenameRequest, newDir fs.N
 	return nil
 }
 
-// The following Xattr calls exist to short circuit any xattr calls.  Without them,
-// the macOS kernel will constantly look for ._ files.
-
-// Getxattr implements fs.NodeGetxattrer.Getxattr.
-func (n *node) Getxattr(ctx gContext.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
-	return notSupported("getxattr")
-}
-
-// Listxattr implements fs.NodeListxattrer.Listxattr.
-func (n *node) Listxattr(ctx gContext.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
-	return notSupported("listxattr")
-}
-
-// Setxattr implements fs.NodeSetxattrer.Setxattr.
-func (n *node) Setxattr(ctx gContext.Context, req *fuse.SetxattrRequest) error {
-	return notSupported("setxattr")
-}
-
-// Removexattr implements fs.NodeRemovexattrer.Removexattr.
-func (n *node) Removexattr(ctx gContext.Context, req *fuse.RemovexattrRequest) error {
-	return nil
-}
-
 // convertPath converts a host path separators into upspin ones.
 func convertPath(path string) upspin.PathName {
 	if filepath.Separator == '/' {
@@ -1004,6 +1228,9 @@ func (n *node) Symlink(ctx gContext.Context, req *fuse.SymlinkRequest) (fs.Node,
 	const op errors.Op = "Symlink"
 	n.Lock()
 	defer n.Unlock()
+	if err := n.f.checkWritable(op, path.Join(n.uname, req.NewName)); err != nil {
+		return nil, err
+	}
 	target := req.Target
 	if !filepath.IsAbs(target) {
 		target = filepath.Join(n.f.mountpoint, string(n.uname), target)
@@ -1078,12 +1305,12 @@ func debug(msg interface{}) {
 
 // do is called both by main and testing to mount a FUSE file system. It exits on failure
 // and returns when the file system has been mounted and is ready for requests.
-func do(cfg upspin.Config, mountpoint string, cacheDir string, cacheSize int64, allowOther bool) chan bool {
+func do(cfg upspin.Config, mountpoint string, cacheDir string, cacheSize int64, allowOther, persistPerms, allowDirect, asyncFlush bool, conflict conflictMode, ro readOnly, fuseOpts fuseOptions) chan bool {
 	if log.GetLevel() == "debug" {
 		fuse.Debug = debug
 	}
 
-	f := newUpspinFS(cfg, mountpoint, cacheDir, cacheSize)
+	f := newUpspinFS(cfg, mountpoint, cacheDir, cacheSize, persistPerms, allowDirect, asyncFlush, conflict, ro)
 
 	opts := []fuse.MountOption{
 		fuse.FSName("upspin"),
@@ -1098,6 +1325,11 @@ func do(cfg upspin.Config, mountpoint string, cacheDir string, cacheSize int64,
 	if allowOther {
 		opts = append(opts, fuse.AllowOther())
 	}
+	if ro.all {
+		opts = append(opts, fuse.ReadOnly())
+	}
+	// Passed-through options come last so they override the defaults above.
+	opts = append(opts, fuseOpts.opts...)
 
 	c, err := fuse.Mount(mountpoint, opts...)
 	if err == fuse.ErrOSXFUSENotFound {
@@ -1119,6 +1351,8 @@ func do(cfg upspin.Config, mountpoint string, cacheDir string, cacheSize int64,
 	}
 
 	shutdown.Handle(func() {
+		// Finish storing files closed with -async-flush.
+		f.flushes.Wait()
 		fuse.Unmount(mountpoint)
 	})
 
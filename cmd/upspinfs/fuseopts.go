@@ -0,0 +1,131 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+// The -fuse-options flag passes platform-specific options through to
+// the FUSE mount. Only options known to be safe are accepted: anything
+// that would weaken the security of the mount, such as dev or suid, or
+// that upspinfs sets itself, such as fsname, is rejected.
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/presotto/fuse"
+
+	"upspin.io/errors"
+)
+
+// fuseOption describes an option accepted by -fuse-options.
+type fuseOption struct {
+	hasValue bool // Whether the option takes a value, as in name=value.
+	mount    func(value string) (fuse.MountOption, error)
+}
+
+// fuseOptionTable holds the options accepted by -fuse-options. Options
+// not supported by the platform are ignored by the FUSE package.
+var fuseOptionTable = map[string]fuseOption{
+	// All platforms.
+	"async_read":          {false, func(string) (fuse.MountOption, error) { return fuse.AsyncRead(), nil }},
+	"default_permissions": {false, func(string) (fuse.MountOption, error) { return fuse.DefaultPermissions(), nil }},
+	"max_readahead":       {true, maxReadahead},
+	"writeback_cache":     {false, func(string) (fuse.MountOption, error) { return fuse.WritebackCache(), nil }},
+
+	// Linux.
+	"nonempty": {false, func(string) (fuse.MountOption, error) { return fuse.AllowNonEmptyMount(), nil }},
+
+	// macOS.
+	"daemon_timeout": {true, daemonTimeout},
+	"excl_create":    {false, func(string) (fuse.MountOption, error) { return fuse.ExclCreate(), nil }},
+	"noappledouble":  {false, func(string) (fuse.MountOption, error) { return fuse.NoAppleDouble(), nil }},
+	"noapplexattr":   {false, func(string) (fuse.MountOption, error) { return fuse.NoAppleXattr(), nil }},
+	"nobrowse":       {false, func(string) (fuse.MountOption, error) { return fuse.NoBrowse(), nil }},
+	"volname":        {true, func(v string) (fuse.MountOption, error) { return fuse.VolumeName(v), nil }},
+}
+
+func maxReadahead(v string) (fuse.MountOption, error) {
+	n, err := strconv.ParseUint(v, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+	return fuse.MaxReadahead(uint32(n)), nil
+}
+
+func daemonTimeout(v string) (fuse.MountOption, error) {
+	if _, err := strconv.ParseUint(v, 10, 32); err != nil {
+		return nil, err
+	}
+	return fuse.DaemonTimeout(v), nil
+}
+
+// fuseOptions implements flag.Value for the -fuse-options flag, a comma-
+// separated list of options that may be repeated.
+type fuseOptions struct {
+	names []string // As given, for String.
+	opts  []fuse.MountOption
+}
+
+// String implements flag.Value.
+func (f *fuseOptions) String() string {
+	if f == nil {
+		return ""
+	}
+	return strings.Join(f.names, ",")
+}
+
+// Set implements flag.Value.
+func (f *fuseOptions) Set(s string) error {
+	for _, opt := range strings.Split(s, ",") {
+		if opt == "" {
+			continue
+		}
+		mo, err := parseFuseOption(opt)
+		if err != nil {
+			return err
+		}
+		f.names = append(f.names, opt)
+		f.opts = append(f.opts, mo)
+	}
+	return nil
+}
+
+// parseFuseOption parses a single option, name or name=value.
+func parseFuseOption(opt string) (fuse.MountOption, error) {
+	name, value, hasValue := strings.Cut(opt, "=")
+	o, ok := fuseOptionTable[name]
+	if !ok {
+		return nil, errors.E(errors.Invalid, errors.Errorf("unsupported FUSE option %q; want one of %s", name, fuseOptionNames()))
+	}
+	if hasValue != o.hasValue {
+		if o.hasValue {
+			return nil, errors.E(errors.Invalid, errors.Errorf("FUSE option %q needs a value", name))
+		}
+		return nil, errors.E(errors.Invalid, errors.Errorf("FUSE option %q takes no value", name))
+	}
+	// The FUSE mount helpers parse the options themselves; keep
+	// anything that might confuse them out of the values.
+	if strings.ContainsAny(value, "\\\"' \t\n") {
+		return nil, errors.E(errors.Invalid, errors.Errorf("invalid value for FUSE option %q: %q", name, value))
+	}
+	mo, err := o.mount(value)
+	if err != nil {
+		return nil, errors.E(errors.Invalid, errors.Errorf("invalid value for FUSE option %q: %v", name, err))
+	}
+	return mo, nil
+}
+
+// fuseOptionNames returns the sorted names of the accepted options.
+func fuseOptionNames() string {
+	var names []string
+	for name := range fuseOptionTable {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
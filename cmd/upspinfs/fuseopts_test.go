@@ -0,0 +1,43 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import "testing"
+
+func TestFuseOptions(t *testing.T) {
+	var f fuseOptions
+	if err := f.Set("max_readahead=131072,nonempty"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Set("volname=upspin"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.String(), "max_readahead=131072,nonempty,volname=upspin"; got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+	if len(f.opts) != 3 {
+		t.Errorf("got %d mount options, want 3", len(f.opts))
+	}
+
+	for _, bad := range []string{
+		"suid",
+		"dev",
+		"fsname=evil",
+		"allow_other",
+		"nonempty=1",
+		"max_readahead",
+		"max_readahead=lots",
+		"volname=a b",
+		`volname=a\`,
+	} {
+		var f fuseOptions
+		if err := f.Set(bad); err == nil {
+			t.Errorf("Set(%q) succeeded, want error", bad)
+		}
+	}
+}
@@ -33,8 +33,19 @@ const cmdName = "upspinfs"
 var (
 	mountpointFlag = flag.String("mountpoint", "", "`directory` on which to mount file system")
 	allowOther     = flag.Bool("allow_other", false, "if set, allow other users to see the mount point; if using this option ensure that mount point access is strictly controlled")
+	persistPerms   = flag.Bool("persist-permissions", false, "if set, save permission bits set by chmod in a "+permsFile+" file in each directory")
+	allowDirect    = flag.Bool("allow-direct", false, "if set, files opened with O_DIRECT bypass the kernel and local caches")
+	asyncFlush     = flag.Bool("async-flush", false, "if set, close returns before a written file is stored; see the package documentation")
+	conflictFlag   = flag.String("conflict", "file", "what to do with a file written back after it was changed elsewhere: `file`, clobber, or fail; see the package documentation")
+	readOnlyFlag   readOnly
+	fuseOptsFlag   fuseOptions
 )
 
+func init() {
+	flag.Var(&readOnlyFlag, "readonly", "if set, mount read-only; if set to a `path`, which may be repeated, make that tree read-only")
+	flag.Var(&fuseOptsFlag, "fuse-options", "comma-separated `list` of FUSE mount options to pass through; see the package documentation")
+}
+
 func usage() {
 	fmt.Fprintf(os.Stderr, "Usage: %s [-mountpoint] <mount point>\n", os.Args[0])
 	flag.PrintDefaults()
@@ -42,7 +53,7 @@ func usage() {
 
 func main() {
 	flag.Usage = usage
-	flags.Parse(flags.Server, "cachedir", "cachesize", "prudent", "version")
+	flags.Parse(flags.Server, "cachedir", "cachesize", "putconcurrency", "prudent", "version")
 
 	if flags.Version {
 		fmt.Print(version.Version())
@@ -60,8 +71,19 @@ func main() {
 		log.Fatalf("%s: %s", cmdName, err)
 	}
 
+	conflict, err := parseConflictMode(*conflictFlag)
+	if err != nil {
+		log.Fatalf("%s: %s", cmdName, err)
+	}
+
 	transports.Init(cfg)
 
+	// A read-only mount writes nothing, so never start a cacheserver
+	// that would write back later.
+	if readOnlyFlag.all {
+		flag.Set("writethrough", "true")
+	}
+
 	// Start the cacheserver if needed.
 	if cacheutil.Start(cfg) {
 		// Using a cacheserver, adjust cache size for upspinfs down.
@@ -85,7 +107,7 @@ func main() {
 		log.Fatalf("can't determine absolute path to mount point %s: %s", *mountpointFlag, err)
 	}
 	done := do(cfg, mountpoint, filepath.Join(flags.CacheDir, string(cfg.UserName())),
-		flags.CacheSize, *allowOther)
+		flags.CacheSize, *allowOther, *persistPerms, *allowDirect, *asyncFlush, conflict, readOnlyFlag, fuseOptsFlag)
 
 	// Serve expvar data.
 	ln, err := local.Listen("tcp", config.LocalName(cfg, cmdName))
@@ -0,0 +1,14 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"syscall"
+
+	"github.com/presotto/fuse"
+)
+
+// openDirectFlag is the open flag requesting direct I/O.
+const openDirectFlag = fuse.OpenFlags(syscall.O_DIRECT)
@@ -0,0 +1,14 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows && !linux
+// +build !windows,!linux
+
+package main
+
+import "github.com/presotto/fuse"
+
+// openDirectFlag is the open flag requesting direct I/O. The kernels of
+// these systems do not pass one to FUSE.
+const openDirectFlag fuse.OpenFlags = 0
@@ -0,0 +1,174 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strconv"
+	"sync"
+
+	"upspin.io/errors"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+// permsFile is the name of the file, stored in each directory, that
+// holds the permission bits set by chmod on the directory's contents.
+// It is only used when permissions are being persisted.
+const permsFile = ".permissions"
+
+// perms caches the contents of the permission files of directories.
+type perms struct {
+	sync.Mutex // Serializes reading, changing, and writing back permission files.
+	f          *upspinFS
+	dirs       map[upspin.PathName]map[string]os.FileMode
+}
+
+func newPerms(f *upspinFS) *perms {
+	return &perms{
+		f:    f,
+		dirs: make(map[upspin.PathName]map[string]os.FileMode),
+	}
+}
+
+// splitName returns the directory holding uname and the last element of uname.
+// It returns ok false if uname is a user root, whose permissions are not saved.
+func splitName(uname upspin.PathName) (dir upspin.PathName, elem string, ok bool) {
+	p, err := path.Parse(uname)
+	if err != nil || p.IsRoot() {
+		return "", "", false
+	}
+	return p.Drop(1).Path(), p.Elem(p.NElem() - 1), true
+}
+
+// mode returns the saved permission bits for uname, if any.
+func (p *perms) mode(uname upspin.PathName) (os.FileMode, bool) {
+	dir, elem, ok := splitName(uname)
+	if !ok {
+		return 0, false
+	}
+	p.Lock()
+	defer p.Unlock()
+	m, ok := p.dirs[dir]
+	if !ok {
+		m = p.read(dir)
+	}
+	mode, ok := m[elem]
+	return mode, ok
+}
+
+// refresh rereads the permission file of dir.
+func (p *perms) refresh(dir upspin.PathName) {
+	p.Lock()
+	p.read(dir)
+	p.Unlock()
+}
+
+// forget drops the cached permissions of dir.
+func (p *perms) forget(dir upspin.PathName) {
+	p.Lock()
+	delete(p.dirs, dir)
+	p.Unlock()
+}
+
+// read reads and caches the permission file of dir. A missing or
+// unreadable file is treated as an empty one. p must be locked.
+func (p *perms) read(dir upspin.PathName) map[string]os.FileMode {
+	m := make(map[string]os.FileMode)
+	if data, err := p.f.client.Get(path.Join(dir, permsFile)); err == nil {
+		var saved map[string]string
+		if err := json.Unmarshal(data, &saved); err == nil {
+			for elem, s := range saved {
+				if mode, err := strconv.ParseUint(s, 8, 32); err == nil {
+					m[elem] = os.FileMode(mode) & os.ModePerm
+				}
+			}
+		}
+	}
+	p.dirs[dir] = m
+	return m
+}
+
+// set saves the permission bits of uname.
+func (p *perms) set(uname upspin.PathName, mode os.FileMode) error {
+	return p.update(uname, func(m map[string]os.FileMode, elem string) bool {
+		mode &= os.ModePerm
+		if old, ok := m[elem]; ok && old == mode {
+			return false
+		}
+		m[elem] = mode
+		return true
+	})
+}
+
+// remove forgets any saved permission bits of uname.
+func (p *perms) remove(uname upspin.PathName) error {
+	return p.update(uname, func(m map[string]os.FileMode, elem string) bool {
+		if _, ok := m[elem]; !ok {
+			return false
+		}
+		delete(m, elem)
+		return true
+	})
+}
+
+// rename moves any saved permission bits of oldName to newName.
+func (p *perms) rename(oldName, newName upspin.PathName) error {
+	mode, ok := p.mode(oldName)
+	if err := p.remove(oldName); err != nil {
+		return err
+	}
+	if !ok {
+		return p.remove(newName)
+	}
+	return p.set(newName, mode)
+}
+
+// update applies fn to the saved permissions of the directory holding
+// uname and writes them back if fn reports a change. The permission file
+// is deleted once it holds no entries.
+func (p *perms) update(uname upspin.PathName, fn func(m map[string]os.FileMode, elem string) bool) error {
+	const op errors.Op = "upspinfs.perms"
+	dir, elem, ok := splitName(uname)
+	if !ok {
+		return nil
+	}
+	if elem == permsFile {
+		// The permission file itself has no saved permissions,
+		// but if it is being removed or replaced our copy is stale.
+		p.forget(dir)
+		return nil
+	}
+	p.Lock()
+	defer p.Unlock()
+	// Reread in case another client has changed the file.
+	m := p.read(dir)
+	if !fn(m, elem) {
+		return nil
+	}
+	name := path.Join(dir, permsFile)
+	if len(m) == 0 {
+		if err := p.f.client.Delete(name); err != nil && !errors.Is(errors.NotExist, err) {
+			return errors.E(op, err)
+		}
+		return nil
+	}
+	saved := make(map[string]string, len(m))
+	for elem, mode := range m {
+		saved[elem] = "0" + strconv.FormatUint(uint64(mode), 8)
+	}
+	data, err := json.MarshalIndent(saved, "", "\t")
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if _, err := p.f.client.Put(name, data); err != nil {
+		return errors.E(op, err)
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+// Parts or all of the name space may be mounted read-only. Operations
+// that would change a read-only name fail with EROFS before any request
+// is made to a server. If the whole name space is read-only the mount
+// itself is read-only, so the kernel rejects changes too and statfs
+// reports the file system as read-only.
+
+import (
+	"strconv"
+	"strings"
+	"syscall"
+
+	"upspin.io/errors"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+// readOnly records which names are read-only. It implements flag.Value
+// for the -readonly flag, which may be given alone, making everything
+// read-only, or repeatedly with the path name of a read-only tree.
+type readOnly struct {
+	all   bool          // Everything is read-only.
+	trees []path.Parsed // Roots of read-only trees.
+}
+
+// String implements flag.Value.
+func (r *readOnly) String() string {
+	if r == nil {
+		return "false"
+	}
+	if r.all {
+		return "true"
+	}
+	var s []string
+	for _, p := range r.trees {
+		s = append(s, p.String())
+	}
+	if len(s) == 0 {
+		return "false"
+	}
+	return strings.Join(s, ",")
+}
+
+// Set implements flag.Value.
+func (r *readOnly) Set(s string) error {
+	if b, err := strconv.ParseBool(s); err == nil {
+		r.all = b
+		return nil
+	}
+	p, err := path.Parse(upspin.PathName(s))
+	if err != nil {
+		return err
+	}
+	r.trees = append(r.trees, p)
+	return nil
+}
+
+// IsBoolFlag allows -readonly to be given without a value.
+func (r *readOnly) IsBoolFlag() bool {
+	return true
+}
+
+// covers reports whether name is read-only.
+func (r *readOnly) covers(name upspin.PathName) bool {
+	if r.all {
+		return true
+	}
+	if len(r.trees) == 0 {
+		return false
+	}
+	p, err := path.Parse(name)
+	if err != nil {
+		return false
+	}
+	for _, tree := range r.trees {
+		if p.HasPrefix(tree) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkWritable returns an EROFS error if any of the names is read-only.
+func (f *upspinFS) checkWritable(op errors.Op, names ...upspin.PathName) error {
+	for _, name := range names {
+		if f.readOnly.covers(name) {
+			return &errnoError{syscall.EROFS, errors.E(op, name, errors.Permission, "read-only file system")}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+// Statfs reports the mounting user's tree as the file system. The bytes
+// used are found by walking the tree, which may take a long time, so it
+// is done in the background: statfs reports nothing used until the first
+// walk completes, and after that the last answer, which is refreshed once
+// it is older than statfsTTL. As the DirServer has no quota API, the file
+// system is reported to be very large.
+
+import (
+	"sync"
+	"time"
+
+	gContext "golang.org/x/net/context"
+
+	"github.com/presotto/fuse"
+
+	"upspin.io/log"
+	"upspin.io/upspin"
+)
+
+const (
+	// statfsTTL is how long the usage reported by statfs is kept.
+	statfsTTL = 5 * time.Minute
+
+	// statfsFrsize is the fragment size reported by statfs, the unit in
+	// which the sizes are reported.
+	statfsFrsize = 4096
+
+	// statfsSize is the size of the file system reported by statfs.
+	statfsSize = 1 << 50
+)
+
+// usageSource returns the bytes used in the tree of the file system's
+// user. Tests replace it.
+var usageSource = (*upspinFS).treeUsage
+
+// statfsUsage caches the answer of usageSource.
+type statfsUsage struct {
+	mu         sync.Mutex
+	valid      bool      // The fields below have been set.
+	at         time.Time // When they were set.
+	used       int64
+	refreshing bool // A background refresh is in progress.
+}
+
+// Statfs implements fs.Statfser.
+func (f *upspinFS) Statfs(ctx gContext.Context, req *fuse.StatfsRequest, resp *fuse.StatfsResponse) error {
+	used := f.currentUsage()
+	free := int64(statfsSize) - used
+	if free < 0 {
+		free = 0
+	}
+	resp.Blocks = statfsSize / statfsFrsize  // Total data blocks in file system.
+	resp.Bfree = uint64(free / statfsFrsize) // Free blocks in file system.
+	resp.Bavail = resp.Bfree                 // Free blocks in file system if you're not root.
+	resp.Files = 100000                      // Total files in file system.
+	resp.Ffree = 100000                      // Free files in file system.
+	resp.Bsize = 64 * 1024                   // Block size
+	resp.Namelen = 256                       // Maximum file name length?
+	resp.Frsize = statfsFrsize               // Fragment size, smallest addressable data size in the file system.
+	return nil
+}
+
+// currentUsage returns the last known bytes used in the user's tree, or
+// zero if they are not yet known, and starts finding them again in the
+// background if that answer is missing or stale.
+func (f *upspinFS) currentUsage() int64 {
+	u := &f.usageCache
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if !u.refreshing && (!u.valid || time.Since(u.at) > statfsTTL) {
+		u.refreshing = true
+		go u.refresh(f)
+	}
+	return u.used
+}
+
+// refresh asks usageSource for the bytes used and records the answer.
+// After an error the previous answer, if any, is kept but not asked for
+// again until it is stale.
+func (u *statfsUsage) refresh(f *upspinFS) {
+	used, err := usageSource(f)
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.refreshing = false
+	if err != nil {
+		log.Info.Printf("upspinfs: statfs: %v", err)
+	} else {
+		u.used = used
+	}
+	u.valid = true
+	u.at = time.Now()
+}
+
+// treeUsage implements usageSource for the user's tree.
+func (f *upspinFS) treeUsage() (int64, error) {
+	return f.walkUsage(upspin.PathName(f.config.UserName()) + "/")
+}
+
+// walkUsage returns the total size of the files in the tree rooted at
+// dir. Links are not followed.
+func (f *upspinFS) walkUsage(dir upspin.PathName) (int64, error) {
+	entries, err := f.client.Glob(upspin.AllFilesGlob(dir))
+	if err != nil && err != upspin.ErrFollowLink {
+		return 0, err
+	}
+	var total int64
+	for _, e := range entries {
+		switch {
+		case e.IsLink():
+		case e.IsDir():
+			n, err := f.walkUsage(e.Name)
+			if err != nil {
+				return 0, err
+			}
+			total += n
+		default:
+			n, err := e.Size()
+			if err != nil {
+				return 0, err
+			}
+			total += n
+		}
+	}
+	return total, nil
+}
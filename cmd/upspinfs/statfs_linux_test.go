@@ -0,0 +1,74 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// statfs returns the total and free bytes reported for the mount, as df
+// computes them.
+func statfs(t *testing.T) (total, free int64) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(testConfig.root, &st); err != nil {
+		fatal(t, err)
+	}
+	return int64(st.Blocks) * st.Frsize, int64(st.Bavail) * st.Frsize
+}
+
+func TestStatfs(t *testing.T) {
+	const used = 100 * statfsFrsize
+	var calls int32
+	release := make(chan bool)
+	usageSource = func(*upspinFS) (int64, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return used, nil
+	}
+	defer func() {
+		usageSource = (*upspinFS).treeUsage
+		remount(t)
+	}()
+	remount(t)
+
+	// Until the usage is known, statfs reports none without waiting.
+	total, free := statfs(t)
+	if total != statfsSize || free != statfsSize {
+		fatalf(t, "statfs before usage known: total %d free %d, expected %d %d", total, free, int64(statfsSize), int64(statfsSize))
+	}
+	statfs(t)
+	close(release)
+	eventually(t, func() error {
+		if _, free := statfs(t); free != statfsSize-used {
+			return fmt.Errorf("statfs: free %d, expected %d", free, int64(statfsSize-used))
+		}
+		return nil
+	}, 5*time.Second)
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		fatalf(t, "usage asked for %d times, expected once", n)
+	}
+
+	// The real source walks the user's tree.
+	usageSource = (*upspinFS).treeUsage
+	const size = 10 * statfsFrsize
+	file := filepath.Join(testConfig.root, "statfs")
+	if err := os.WriteFile(file, make([]byte, size), perm); err != nil {
+		fatal(t, err)
+	}
+	defer os.Remove(file)
+	remount(t)
+	eventually(t, func() error {
+		if _, free := statfs(t); free > statfsSize-size {
+			return fmt.Errorf("statfs: free %d, expected at most %d", free, int64(statfsSize-size))
+		}
+		return nil
+	}, 5*time.Second)
+}
@@ -12,12 +12,14 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"path/filepath"
 	rtdebug "runtime/debug"
 	"strconv"
 	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -43,6 +45,7 @@ var testConfig struct {
 	root       string
 	user       string
 	cfg        upspin.Config
+	done       chan bool // Closed when the file system is unmounted.
 }
 
 const (
@@ -71,7 +74,7 @@ func testSetup(name string) (upspin.Config, error) {
 	cfg = config.SetFactotum(cfg, f)
 
 	bind.RegisterKeyServer(upspin.InProcess, keyserver.New())
-	bind.RegisterStoreServer(upspin.InProcess, storeserver.New())
+	bind.RegisterStoreServer(upspin.InProcess, &countingStore{StoreServer: storeserver.New()})
 	bind.RegisterDirServer(upspin.InProcess, dirserver.New(cfg))
 
 	publicKey := upspin.PublicKey(fmt.Sprintf("key for %s", name))
@@ -89,6 +92,27 @@ func testSetup(name string) (upspin.Config, error) {
 	return cfg, err
 }
 
+// storePuts counts the Puts to the store.
+var storePuts int64
+
+// countingStore is a StoreServer that counts Puts in storePuts.
+type countingStore struct {
+	upspin.StoreServer
+}
+
+func (s *countingStore) Dial(cfg upspin.Config, e upspin.Endpoint) (upspin.Service, error) {
+	svc, err := s.StoreServer.Dial(cfg, e)
+	if err != nil {
+		return nil, err
+	}
+	return &countingStore{StoreServer: svc.(upspin.StoreServer)}, nil
+}
+
+func (s *countingStore) Put(data []byte) (*upspin.Refdata, error) {
+	atomic.AddInt64(&storePuts, 1)
+	return s.StoreServer.Put(data)
+}
+
 func mount() error {
 	// Create a mountpoint. There are 4 possible mountpoints /tmp/upsinfstest[1-4].
 	// This lets us set up some /etc/fstab entries on Linux for the tests and
@@ -135,13 +159,39 @@ func mount() error {
 
 	// Mount the file system. It will be served in a separate go routine.
 	log.SetLevel("info")
-	do(cfg, testConfig.mountpoint, testConfig.cacheDir, maxBytes, false)
+	serve()
 
 	// Create the user root, all tests will need it.
 	testConfig.root = filepath.Join(testConfig.mountpoint, testConfig.user)
 	return os.Mkdir(testConfig.root, 0777)
 }
 
+// serve mounts and serves the file system.
+// The tree TestReadOnly is read-only.
+func serve() {
+	var ro readOnly
+	if err := ro.Set(string(testConfig.user) + "/TestReadOnly"); err != nil {
+		panic(err)
+	}
+	testConfig.done = do(testConfig.cfg, testConfig.mountpoint, testConfig.cacheDir, maxBytes, false, true, false, false, conflictFile, ro, fuseOptions{})
+}
+
+// remount unmounts the file system and mounts it again with an empty
+// cache, as if upspinfs had been restarted.
+func remount(t *testing.T) {
+	if err := fuse.Unmount(testConfig.mountpoint); err != nil {
+		fatal(t, err)
+	}
+	<-testConfig.done
+	os.RemoveAll(testConfig.cacheDir)
+	var err error
+	testConfig.cacheDir, err = os.MkdirTemp("", "upspincache")
+	if err != nil {
+		fatal(t, err)
+	}
+	serve()
+}
+
 func cleanup() {
 	fuse.Unmount(testConfig.mountpoint)
 	os.RemoveAll(testConfig.mountpoint)
@@ -523,6 +573,200 @@ func TestRename(t *testing.T) {
 	}
 }
 
+// TestRenameAndLinkShareBlocks tests that renaming and linking a file
+// store no data, and that a linked file diverges when written.
+func TestRenameAndLinkShareBlocks(t *testing.T) {
+	testDir := mkTestDir(t, "TestRenameAndLinkShareBlocks")
+	uTestDir := path.Join(upspin.PathName(testConfig.user), "TestRenameAndLinkShareBlocks")
+	cl := client.New(testConfig.cfg)
+
+	buf := randomBytes(t, 3*upspin.BlockSize)
+	if _, err := cl.Put(path.Join(uTestDir, "original"), buf); err != nil {
+		fatal(t, err)
+	}
+	mkDir(t, filepath.Join(testDir, "sub"))
+	original := filepath.Join(testDir, "original")
+	renamed := filepath.Join(testDir, "sub", "renamed")
+	linked := filepath.Join(testDir, "linked")
+
+	puts := atomic.LoadInt64(&storePuts)
+	if err := os.Rename(original, renamed); err != nil {
+		fatal(t, err)
+	}
+	notExist(t, original, "rename")
+	if err := os.Link(renamed, linked); err != nil {
+		fatal(t, err)
+	}
+	if p := atomic.LoadInt64(&storePuts) - puts; p != 0 {
+		fatalf(t, "rename and link stored %d blocks, expected none", p)
+	}
+	openReadAndCheckContentsOrDie(t, renamed, buf)
+	openReadAndCheckContentsOrDie(t, linked, buf)
+
+	// Writing one name leaves the other as it was.
+	changed := randomBytes(t, 100)
+	if err := os.WriteFile(linked, changed, perm); err != nil {
+		fatal(t, err)
+	}
+	openReadAndCheckContentsOrDie(t, linked, changed)
+	openReadAndCheckContentsOrDie(t, renamed, buf)
+
+	if err := os.RemoveAll(testDir); err != nil {
+		fatal(t, err)
+	}
+}
+
+// TestPersistPermissions tests that permission bits set by chmod are
+// saved in Upspin and removed with the file.
+func TestPersistPermissions(t *testing.T) {
+	testDir := mkTestDir(t, "testpersistperms")
+	script := filepath.Join(testDir, "script")
+	mkFile(t, script, []byte("#!/bin/sh\necho hello\n"))
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := info.Mode().Perm(); got != 0755 {
+		t.Errorf("mode after chmod: got %o, want %o", got, 0755)
+	}
+
+	// The bits are stored in the directory's permissions file.
+	permsName := upspin.PathName(testConfig.user + "/testpersistperms/" + permsFile)
+	data, err := client.New(testConfig.cfg).Get(permsName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(data, []byte(`"script": "0755"`)) {
+		t.Errorf("%s does not record mode of script:\n%s", permsName, data)
+	}
+
+	// Removing the file forgets its bits and the now empty permissions file.
+	remove(t, script)
+	if _, err := client.New(testConfig.cfg).Get(permsName); err == nil {
+		t.Errorf("%s still exists after removing the only file it describes", permsName)
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestPersistPermissionsRemount tests that permission bits set by chmod
+// survive a restart of upspinfs and follow a file when it is renamed.
+func TestPersistPermissionsRemount(t *testing.T) {
+	testDir := mkTestDir(t, "testpersistpermsremount")
+	subDir := filepath.Join(testDir, "bin")
+	if err := os.Mkdir(subDir, perm); err != nil {
+		t.Fatal(err)
+	}
+	script := filepath.Join(testDir, "script")
+	mkFile(t, script, []byte("#!/bin/sh\necho hello\n"))
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatal(err)
+	}
+	data := filepath.Join(testDir, "data")
+	mkFile(t, data, []byte("data"))
+	if err := os.Chmod(data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	renamed := filepath.Join(subDir, "tool")
+	if err := os.Rename(script, renamed); err != nil {
+		t.Fatal(err)
+	}
+
+	remount(t)
+
+	for _, c := range []struct {
+		name string
+		want os.FileMode
+	}{
+		{renamed, 0755},
+		{data, 0600},
+	} {
+		info, err := os.Stat(c.name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != c.want {
+			t.Errorf("mode of %s after remount: got %o, want %o", c.name, got, c.want)
+		}
+	}
+	if _, err := os.Stat(script); !os.IsNotExist(err) {
+		t.Errorf("stat of %s after rename: err = %v, want not exist", script, err)
+	}
+
+	if err := os.RemoveAll(testDir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestReadOnly tests that every change to a read-only tree fails with EROFS.
+func TestReadOnly(t *testing.T) {
+	uTestDir := path.Join(upspin.PathName(testConfig.user), "TestReadOnly")
+	cl := client.New(testConfig.cfg)
+	if _, err := cl.MakeDirectory(uTestDir); err != nil {
+		fatal(t, err)
+	}
+	buf := randomBytes(t, 128)
+	if _, err := cl.Put(path.Join(uTestDir, "file"), buf); err != nil {
+		fatal(t, err)
+	}
+	testDir := filepath.Join(testConfig.root, "TestReadOnly")
+	fn := filepath.Join(testDir, "file")
+	outside := mkTestDir(t, "TestReadOnlyOutside")
+	outsideFile := filepath.Join(outside, "file")
+	mkFile(t, outsideFile, buf)
+
+	// Reading works.
+	openReadAndCheckContentsOrDie(t, fn, buf)
+
+	for _, test := range []struct {
+		name string
+		op   func() error
+	}{
+		{"create", func() error {
+			f, err := os.Create(filepath.Join(testDir, "new"))
+			if err == nil {
+				f.Close()
+			}
+			return err
+		}},
+		{"open for write", func() error {
+			f, err := os.OpenFile(fn, os.O_WRONLY, 0)
+			if err == nil {
+				f.Close()
+			}
+			return err
+		}},
+		{"truncate", func() error { return os.Truncate(fn, 0) }},
+		{"chmod", func() error { return os.Chmod(fn, 0755) }},
+		{"mkdir", func() error { return os.Mkdir(filepath.Join(testDir, "dir"), perm) }},
+		{"symlink", func() error { return os.Symlink(fn, filepath.Join(testDir, "link")) }},
+		{"rename", func() error { return os.Rename(fn, filepath.Join(testDir, "renamed")) }},
+		{"rename in", func() error { return os.Rename(outsideFile, filepath.Join(testDir, "in")) }},
+		{"rename out", func() error { return os.Rename(fn, filepath.Join(outside, "out")) }},
+		{"unlink", func() error { return os.Remove(fn) }},
+	} {
+		err := test.op()
+		if !errors.Is(err, syscall.EROFS) {
+			t.Errorf("%s: got error %v, want EROFS", test.name, err)
+		}
+	}
+
+	// Nothing changed.
+	openReadAndCheckContentsOrDie(t, fn, buf)
+	openReadAndCheckContentsOrDie(t, outsideFile, buf)
+	if err := os.RemoveAll(outside); err != nil {
+		fatal(t, err)
+	}
+	if err := cl.Delete(path.Join(uTestDir, "file")); err != nil {
+		fatal(t, err)
+	}
+}
+
 // TestAccess tests access control. This is not a rigorous right test, we just want
 // to ensure that the access file is checked at file creation and open.
 func TestAccess(t *testing.T) {
@@ -670,6 +914,73 @@ func TestEventualConsistency(t *testing.T) {
 	eventually(t, f, 5*time.Second)
 }
 
+// TestWatchInvalidation tests that a file rewritten by another client
+// is seen through the mount within a second, even though the kernel has
+// cached its contents.
+func TestWatchInvalidation(t *testing.T) {
+	testDir := mkTestDir(t, "TestWatchInvalidation")
+	uTestDir := path.Join(upspin.PathName(testConfig.user), "TestWatchInvalidation")
+	cl := client.New(testConfig.cfg)
+
+	buf := randomBytes(t, 128)
+	fn := filepath.Join(testDir, "file")
+	mkFile(t, fn, buf)
+	openReadAndCheckContentsOrDie(t, fn, buf)
+
+	// Make sure the watcher for the tree is running before the write.
+	time.Sleep(100 * time.Millisecond)
+
+	buf2 := randomBytes(t, 128)
+	if _, err := cl.Put(path.Join(uTestDir, "file"), buf2); err != nil {
+		fatal(t, err)
+	}
+	eventually(t, func() error { return openReadAndCheckContents(fn, buf2) }, time.Second)
+}
+
+// TestConflict tests that a file changed through another client while
+// open in upspinfs is not overwritten when upspinfs writes it back; what
+// upspinfs had written is saved in a conflict file instead.
+func TestConflict(t *testing.T) {
+	testDir := mkTestDir(t, "TestConflict")
+	uTestDir := path.Join(upspin.PathName(testConfig.user), "TestConflict")
+	cl := client.New(testConfig.cfg)
+
+	fn := filepath.Join(testDir, "file")
+	ufn := path.Join(uTestDir, "file")
+	mkFile(t, fn, randomBytes(t, 128))
+
+	// Write the file through upspinfs and, before it is closed and
+	// written back, through the other client.
+	local := randomBytes(t, 128)
+	f := writeFile(t, fn, local)
+	remote := randomBytes(t, 128)
+	if _, err := cl.Put(ufn, remote); err != nil {
+		f.Close()
+		fatal(t, err)
+	}
+	if err := f.Close(); err != nil {
+		fatal(t, err)
+	}
+
+	// The other client's version wins; upspinfs's is in a conflict file.
+	eventually(t, func() error { return openReadAndCheckContents(fn, remote) }, 5*time.Second)
+	des, err := cl.Glob(string(ufn) + ".conflict-*")
+	if err != nil {
+		fatal(t, err)
+	}
+	if len(des) != 1 {
+		fatalf(t, "found %d conflict files, expected 1", len(des))
+	}
+	got, err := cl.Get(des[0].Name)
+	if err != nil {
+		fatal(t, err)
+	}
+	if !bytes.Equal(got, local) {
+		fatalf(t, "%s: contents differ from what was written", des[0].Name)
+	}
+	openReadAndCheckContentsOrDie(t, filepath.Join(testDir, string(des[0].Name[len(uTestDir)+1:])), local)
+}
+
 // TestDemandLoad tests loading multiple Block files.
 func TestDemandLoad(t *testing.T) {
 	testDir := mkTestDir(t, "TestDemandLoad")
@@ -726,6 +1037,51 @@ func TestDemandLoad(t *testing.T) {
 	file.Close()
 }
 
+// TestLazyRead tests that reading the start of a large file fetches only
+// its first block, and that reading it sequentially prefetches the rest.
+func TestLazyRead(t *testing.T) {
+	testDir := mkTestDir(t, "TestLazyRead")
+	uTestDir := path.Join(upspin.PathName(testConfig.user), "TestLazyRead")
+	cl := client.New(testConfig.cfg)
+
+	const nBlocks = 8
+	fn := filepath.Join(testDir, "file")
+	buf := randomBytes(t, nBlocks*upspin.BlockSize)
+	if _, err := cl.Put(path.Join(uTestDir, "file"), buf); err != nil {
+		fatal(t, err)
+	}
+
+	loaded := atomic.LoadInt64(&cacheBlocksLoaded)
+	prefetched := atomic.LoadInt64(&cacheBlocksPrefetched)
+	file, err := os.Open(fn)
+	if err != nil {
+		fatal(t, err)
+	}
+	readAtAndCheckContentsOrDie(t, file, 0, buf[:1024])
+	if l := atomic.LoadInt64(&cacheBlocksLoaded) - loaded; l != 1 {
+		fatalf(t, "cacheBlocksLoaded after reading 1KB: got %d expected 1", l)
+	}
+	if p := atomic.LoadInt64(&cacheBlocksPrefetched) - prefetched; p != 0 {
+		fatalf(t, "cacheBlocksPrefetched after reading 1KB: got %d expected 0", p)
+	}
+
+	// Read the whole file sequentially.
+	got, err := io.ReadAll(file)
+	if err != nil {
+		fatal(t, err)
+	}
+	file.Close()
+	if !bytes.Equal(got, buf[1024:]) {
+		fatalf(t, "%s: contents differ from what was written", fn)
+	}
+	if l := atomic.LoadInt64(&cacheBlocksLoaded) - loaded; l != nBlocks {
+		fatalf(t, "cacheBlocksLoaded after reading the file: got %d expected %d", l, nBlocks)
+	}
+	if p := atomic.LoadInt64(&cacheBlocksPrefetched) - prefetched; p == 0 {
+		fatalf(t, "no blocks prefetched by sequential read")
+	}
+}
+
 func TestCleanup(t *testing.T) {
 	testDir := mkTestDir(t, "testcleanup")
 	bufSize := int(maxBytes / 10)
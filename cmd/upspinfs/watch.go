@@ -12,7 +12,6 @@
 package main // import "upspin.io/cmd/upspinfs"
 
 import (
-	"os"
 	"sync"
 	"time"
 
@@ -52,6 +51,9 @@ type watchedRoot struct {
 	// retryInterval is the interval between Watch attempts.
 	retryInterval time.Duration
 
+	// watchSupported is true while a Watch is established. Otherwise
+	// nodes are refreshed by polling. It is protected by the
+	// watchedRoots lock.
 	watchSupported bool
 }
 
@@ -144,11 +146,6 @@ func (w *watchedRoots) watchSupported(name upspin.PathName) bool {
 func (w *watchedRoots) refresh(n *node) error {
 	const op errors.Op = "refresh"
 
-	// Watch is handling refreshes.
-	if n.doNotRefresh {
-		return nil
-	}
-
 	// Don't refresh special nodes.
 	if n.t != otherNode {
 		return nil
@@ -173,8 +170,7 @@ func (w *watchedRoots) refresh(n *node) error {
 
 	d, ok := w.m[user]
 	if ok && d.watchSupported {
-		// Don't refresh if the DirServer supports Watch.
-		n.doNotRefresh = true
+		// Don't refresh while Watch is handling refreshes.
 		w.Unlock()
 		return nil
 	}
@@ -196,13 +192,7 @@ func (w *watchedRoots) refresh(n *node) error {
 	n.seq = de.Sequence
 
 	// Update cached info for node.
-	mode := os.FileMode(unixPermissions)
-	if de.IsDir() {
-		mode |= os.ModeDir
-	}
-	if de.IsLink() {
-		mode |= os.ModeSymlink
-	}
+	mode := n.f.mode(de)
 	size, err := lstatSize(de, n)
 	if err != nil {
 		n.f.removeMapping(n.uname)
@@ -223,6 +213,31 @@ func (w *watchedRoots) refresh(n *node) error {
 	return nil
 }
 
+// setWatchSupported records whether d has a Watch established. When the
+// Watch is lost, names recorded as not existing may be out of date, so
+// they are forgotten.
+func (d *watchedRoot) setWatchSupported(supported bool) {
+	w := d.f.watched
+	w.Lock()
+	was := d.watchSupported
+	d.watchSupported = supported
+	w.Unlock()
+	if was && !supported {
+		d.f.forgetNonexistent(d.user)
+	}
+}
+
+// forgetNonexistent removes from the enoentMap all names in user's tree.
+func (f *upspinFS) forgetNonexistent(user upspin.UserName) {
+	f.Lock()
+	defer f.Unlock()
+	for name := range f.enoentMap {
+		if p, err := path.Parse(name); err == nil && p.User() == user {
+			delete(f.enoentMap, name)
+		}
+	}
+}
+
 // invalidate tells the kernel to purge data about a node. It must be called
 // with no locks held since it could generate a FUSE request causing a
 // deadlock in the kernel.
@@ -250,7 +265,6 @@ func (d *watchedRoot) watcher() {
 	d.sequence = upspin.WatchNew
 
 	d.retryInterval = initialRetryInterval
-	d.watchSupported = true
 	for {
 		err := d.watch()
 		if err == nil {
@@ -259,21 +273,27 @@ func (d *watchedRoot) watcher() {
 			// or if there is an error requiring a new Watch.
 			return
 		}
+		// Until the Watch is reestablished, fall back to polling.
+		d.setWatchSupported(false)
 		if err == upspin.ErrNotSupported {
 			// Can't survive this.
-			d.watchSupported = false
 			log.Debug.Printf("upspinfs.watcher: %s: %s", d.user, err)
 			return
 		}
 		if errors.Is(errors.Invalid, err) {
-			// A bad record in the log or a bad sequence number. Reread current state.
+			// A bad record in the log or a bad sequence number. Events
+			// may have been missed, so reread current state.
 			log.Info.Printf("upspinfs.watcher restarting Watch: %s: %s", d.user, err)
-			d.sequence = upspin.WatchNew
+			d.sequence = upspin.WatchCurrent
 		} else {
+			// Resume from the last event seen.
 			log.Info.Printf("upspinfs.watcher: %s: %s", d.user, err)
 		}
 
 		select {
+		case <-d.die:
+			log.Debug.Printf("upspinfs.watcher %s exiting", d.user)
+			return
 		case <-time.After(d.retryInterval):
 			d.retryInterval *= 2
 			if d.retryInterval > maxRetryInterval {
@@ -300,6 +320,7 @@ func (d *watchedRoot) watch() error {
 
 	// If Watch succeeds, go back to the initial interval.
 	d.retryInterval = initialRetryInterval
+	d.setWatchSupported(true)
 
 	// Loop receiving events until we are told to stop or the event stream is closed.
 	for {
@@ -325,6 +346,7 @@ func (d *watchedRoot) watch() error {
 				close(done)
 				return err
 			}
+			d.sequence = e.Entry.Sequence
 		}
 	}
 }
@@ -339,6 +361,11 @@ func (d *watchedRoot) handleEvent(e *upspin.Event) error {
 	// We will have to invalidate the directory also.
 	dir := path.DropPath(e.Entry.Name, 1)
 
+	// A changed permissions file must be reread.
+	if f.perms != nil && e.Entry.Name == path.Join(dir, permsFile) {
+		f.perms.forget(dir)
+	}
+
 	// Is this a file we are watching?
 	f.Lock()
 	n, ok := f.nodeMap[e.Entry.Name]
@@ -368,7 +395,7 @@ func (d *watchedRoot) handleEvent(e *upspin.Event) error {
 	}
 
 	// Don't update files being written.
-	if n.cf != nil && n.cf.dirty {
+	if n.dirty() {
 		n.Unlock()
 		return nil
 	}
@@ -393,13 +420,7 @@ func (d *watchedRoot) handleEvent(e *upspin.Event) error {
 		n.deleted = false
 	} else {
 		// Update cached info for node.
-		mode := os.FileMode(unixPermissions)
-		if e.Entry.IsDir() {
-			mode |= os.ModeDir
-		}
-		if e.Entry.IsLink() {
-			mode |= os.ModeSymlink
-		}
+		mode := f.mode(e.Entry)
 		size, err := lstatSize(e.Entry, n)
 		if err == nil {
 			n.attr.Size = size
@@ -0,0 +1,188 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !windows
+// +build !windows
+
+package main
+
+// The metadata of an Upspin file is presented as read-only extended
+// attributes in the "user.upspin." name space, so scripts can learn the
+// writer, packing and so on of a file without running upspin info. The
+// user-defined Attributes of the entry appear as "user.upspin.attr.name".
+// The attributes are computed from a fresh Lookup of the entry each time.
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	gContext "context"
+
+	"github.com/presotto/fuse"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+const (
+	xattrPrefix = "user.upspin."
+
+	// xattrUserPrefix follows xattrPrefix in the names of the entry's
+	// user-defined Attributes.
+	xattrUserPrefix = "attr."
+
+	// maxXattrBlocks is the most blocks listed by the blocks attribute,
+	// which keeps its value small for large files.
+	maxXattrBlocks = 64
+)
+
+// xattrBlock describes a block in the blocks attribute.
+type xattrBlock struct {
+	Offset    int64            `json:"offset"`
+	Size      int64            `json:"size"`
+	Endpoint  string           `json:"endpoint"`
+	Reference upspin.Reference `json:"reference"`
+}
+
+// xattrBlocks is the value of the blocks attribute. Count is the number
+// of blocks in the file, which may be more than are listed.
+type xattrBlocks struct {
+	Count  int          `json:"count"`
+	Blocks []xattrBlock `json:"blocks"`
+}
+
+// xattrs returns the extended attributes of the entry, keyed by name.
+func xattrs(de *upspin.DirEntry) (map[string][]byte, error) {
+	x := map[string][]byte{
+		"writer":     []byte(de.Writer),
+		"packing":    []byte(de.Packing.String()),
+		"sequence":   []byte(strconv.FormatInt(de.Sequence, 10)),
+		"time":       []byte(de.Time.Go().Format(time.RFC3339)),
+		"signedname": []byte(de.SignedName),
+	}
+	if de.IsLink() {
+		x["link"] = []byte(de.Link)
+	}
+	for name, value := range de.Attributes {
+		x[xattrUserPrefix+name] = value
+	}
+	if !de.IsDir() && !de.IsLink() {
+		blocks := xattrBlocks{Count: len(de.Blocks), Blocks: []xattrBlock{}}
+		for i := range de.Blocks {
+			if i == maxXattrBlocks {
+				break
+			}
+			b := &de.Blocks[i]
+			blocks.Blocks = append(blocks.Blocks, xattrBlock{
+				Offset:    b.Offset,
+				Size:      b.Size,
+				Endpoint:  b.Location.Endpoint.String(),
+				Reference: b.Location.Reference,
+			})
+		}
+		buf, err := json.Marshal(blocks)
+		if err != nil {
+			return nil, err
+		}
+		x["blocks"] = buf
+	}
+	return x, nil
+}
+
+// lookupXattrs returns the extended attributes of the node. The root of
+// the mount has none.
+func (n *node) lookupXattrs(op errors.Op) (map[string][]byte, error) {
+	n.Lock()
+	t, uname, user, deleted := n.t, n.uname, n.user, n.deleted
+	n.Unlock()
+	if deleted {
+		return nil, e2e(errors.E(op, errors.NotExist, uname))
+	}
+	if t == rootNode {
+		return nil, nil
+	}
+	dir, err := n.f.dirLookup(user)
+	if err != nil {
+		return nil, e2e(errors.E(op, uname, err))
+	}
+	de, err := dir.Lookup(uname)
+	if err == upspin.ErrFollowLink && de != nil && de.Name == uname {
+		err = nil
+	}
+	if err != nil {
+		return nil, e2e(errors.E(op, uname, err))
+	}
+	x, err := xattrs(de)
+	if err != nil {
+		return nil, e2e(errors.E(op, uname, err))
+	}
+	return x, nil
+}
+
+// Getxattr implements fs.NodeGetxattrer.Getxattr.
+func (n *node) Getxattr(ctx gContext.Context, req *fuse.GetxattrRequest, resp *fuse.GetxattrResponse) error {
+	const op errors.Op = "Getxattr"
+	if !strings.HasPrefix(req.Name, xattrPrefix) {
+		return fuse.ErrNoXattr
+	}
+	x, err := n.lookupXattrs(op)
+	if err != nil {
+		return err
+	}
+	val, ok := x[strings.TrimPrefix(req.Name, xattrPrefix)]
+	if !ok {
+		return fuse.ErrNoXattr
+	}
+	resp.Xattr = val
+	return nil
+}
+
+// Listxattr implements fs.NodeListxattrer.Listxattr.
+func (n *node) Listxattr(ctx gContext.Context, req *fuse.ListxattrRequest, resp *fuse.ListxattrResponse) error {
+	const op errors.Op = "Listxattr"
+	x, err := n.lookupXattrs(op)
+	if err != nil {
+		return err
+	}
+	// List the names in a fixed order.
+	for _, name := range []string{"writer", "packing", "sequence", "time", "signedname", "link", "blocks"} {
+		if _, ok := x[name]; ok {
+			resp.Append(xattrPrefix + name)
+		}
+	}
+	var user []string
+	for name := range x {
+		if strings.HasPrefix(name, xattrUserPrefix) {
+			user = append(user, name)
+		}
+	}
+	sort.Strings(user)
+	for _, name := range user {
+		resp.Append(xattrPrefix + name)
+	}
+	return nil
+}
+
+// Setxattr implements fs.NodeSetxattrer.Setxattr. The Upspin attributes
+// cannot be set and no others are supported.
+func (n *node) Setxattr(ctx gContext.Context, req *fuse.SetxattrRequest) error {
+	const op errors.Op = "Setxattr"
+	if strings.HasPrefix(req.Name, xattrPrefix) {
+		return &errnoError{syscall.EPERM, errors.E(op, errors.Permission, errors.Errorf("attribute %s is read-only", req.Name))}
+	}
+	return notSupported("setxattr")
+}
+
+// Removexattr implements fs.NodeRemovexattrer.Removexattr.
+func (n *node) Removexattr(ctx gContext.Context, req *fuse.RemovexattrRequest) error {
+	const op errors.Op = "Removexattr"
+	if strings.HasPrefix(req.Name, xattrPrefix) {
+		return &errnoError{syscall.EPERM, errors.E(op, errors.Permission, errors.Errorf("attribute %s is read-only", req.Name))}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"upspin.io/client"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+func getxattr(t *testing.T, fn, name string) string {
+	buf := make([]byte, 4096)
+	n, err := syscall.Getxattr(fn, name, buf)
+	if err != nil {
+		fatalf(t, "getxattr %s %s: %v", fn, name, err)
+	}
+	return string(buf[:n])
+}
+
+func TestXattr(t *testing.T) {
+	testDir := mkTestDir(t, "TestXattr")
+	uTestDir := path.Join(upspin.PathName(testConfig.user), "TestXattr")
+	cl := client.New(testConfig.cfg)
+
+	const nBlocks = 3
+	fn := filepath.Join(testDir, "file")
+	if _, err := cl.Put(path.Join(uTestDir, "file"), randomBytes(t, nBlocks*upspin.BlockSize)); err != nil {
+		fatal(t, err)
+	}
+	de, err := cl.Lookup(path.Join(uTestDir, "file"), false)
+	if err != nil {
+		fatal(t, err)
+	}
+
+	buf := make([]byte, 4096)
+	n, err := syscall.Listxattr(fn, buf)
+	if err != nil {
+		fatal(t, err)
+	}
+	names := strings.Split(strings.TrimSuffix(string(buf[:n]), "\x00"), "\x00")
+	want := []string{"user.upspin.writer", "user.upspin.packing", "user.upspin.sequence", "user.upspin.time", "user.upspin.signedname", "user.upspin.blocks"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		fatalf(t, "listxattr: got %q expected %q", names, want)
+	}
+
+	if got := getxattr(t, fn, "user.upspin.writer"); got != string(testConfig.user) {
+		fatalf(t, "writer: got %q expected %q", got, testConfig.user)
+	}
+	if got := getxattr(t, fn, "user.upspin.packing"); got != de.Packing.String() {
+		fatalf(t, "packing: got %q expected %q", got, de.Packing)
+	}
+	if got := getxattr(t, fn, "user.upspin.sequence"); got != strconv.FormatInt(de.Sequence, 10) {
+		fatalf(t, "sequence: got %q expected %d", got, de.Sequence)
+	}
+	var blocks xattrBlocks
+	if err := json.Unmarshal([]byte(getxattr(t, fn, "user.upspin.blocks")), &blocks); err != nil {
+		fatal(t, err)
+	}
+	if blocks.Count != nBlocks || len(blocks.Blocks) != nBlocks {
+		fatalf(t, "blocks: got %d listed of %d expected %d", len(blocks.Blocks), blocks.Count, nBlocks)
+	}
+	for i, b := range blocks.Blocks {
+		if b.Reference != de.Blocks[i].Location.Reference || b.Offset != de.Blocks[i].Offset {
+			fatalf(t, "block %d: got %+v expected %+v", i, b, de.Blocks[i])
+		}
+	}
+
+	// Unknown attributes don't exist.
+	if _, err := syscall.Getxattr(fn, "user.upspin.nonesuch", buf); err != syscall.ENODATA {
+		fatalf(t, "getxattr of unknown attribute: got %v expected ENODATA", err)
+	}
+
+	// User-defined attributes are listed after the others.
+	afn := filepath.Join(testDir, "attrfile")
+	attrs := map[string][]byte{"content-type": []byte("text/plain"), "tag": []byte("blue")}
+	if _, err := cl.(*client.Client).PutWithOptions(path.Join(uTestDir, "attrfile"), []byte("x"), client.PutOptions{Attributes: attrs}); err != nil {
+		fatal(t, err)
+	}
+	n, err = syscall.Listxattr(afn, buf)
+	if err != nil {
+		fatal(t, err)
+	}
+	names = strings.Split(strings.TrimSuffix(string(buf[:n]), "\x00"), "\x00")
+	want = append(want, "user.upspin.attr.content-type", "user.upspin.attr.tag")
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		fatalf(t, "listxattr with attributes: got %q expected %q", names, want)
+	}
+	if got := getxattr(t, afn, "user.upspin.attr.content-type"); got != "text/plain" {
+		fatalf(t, "content-type: got %q expected %q", got, "text/plain")
+	}
+
+	// The attributes can't be changed.
+	if err := syscall.Setxattr(fn, "user.upspin.writer", []byte("evil@example.com"), 0); err != syscall.EPERM {
+		fatalf(t, "setxattr: got %v expected EPERM", err)
+	}
+	if err := syscall.Removexattr(fn, "user.upspin.writer"); err != syscall.EPERM {
+		fatalf(t, "removexattr: got %v expected EPERM", err)
+	}
+}
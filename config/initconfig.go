@@ -103,6 +103,11 @@ func FromFile(name string) (upspin.Config, error) {
 // where key may be one of username, keyserver, dirserver, storeserver,
 // packing, secrets, or tlscerts.
 //
+// The dirserver value may also be a YAML list of the endpoints of
+// replicas of the user's directory server, primary first. The client
+// reads the user's own tree from the nearest of them and sends all
+// other requests to the primary.
+//
 // The default configuration file location is $HOME/upspin/config.
 // If passed a non-nil io.Reader, that is used instead of the default file.
 //
@@ -127,6 +132,10 @@ func FromFile(name string) (upspin.Config, error) {
 // Files without the suffix ".pem" are ignored.
 // The default value for tlscerts is the empty string,
 // in which case just the system roots are used.
+//
+// The rpc key holds settings for the HTTP connections to servers,
+// such as whether to use HTTP/2. See the Tuning type in package
+// upspin.io/rpc for details.
 func InitConfig(r io.Reader) (upspin.Config, error) {
 	const op errors.Op = "config.InitConfig"
 	vals := map[string]string{
@@ -137,6 +146,9 @@ func InitConfig(r io.Reader) (upspin.Config, error) {
 		storeserver: "",
 		cache:       "",
 	}
+	lists := map[string][]string{
+		dirserver: nil,
+	}
 	other := make(map[string]interface{})
 
 	// If the provided reader is nil, try $HOME/upspin/config.
@@ -158,7 +170,7 @@ func InitConfig(r io.Reader) (upspin.Config, error) {
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
-	if err := valsFromYAML(vals, other, data); err != nil {
+	if err := valsFromYAML(vals, lists, other, data); err != nil {
 		return nil, errors.E(op, err)
 	}
 
@@ -209,7 +221,11 @@ func InitConfig(r io.Reader) (upspin.Config, error) {
 
 	cfg = SetKeyEndpoint(cfg, parseEndpoint(op, vals, keyserver, &err))
 	cfg = SetStoreEndpoint(cfg, parseEndpoint(op, vals, storeserver, &err))
-	cfg = SetDirEndpoint(cfg, parseEndpoint(op, vals, dirserver, &err))
+	if dirs := lists[dirserver]; dirs != nil {
+		cfg = SetDirEndpoints(cfg, parseEndpoints(op, dirs, &err))
+	} else {
+		cfg = SetDirEndpoint(cfg, parseEndpoint(op, vals, dirserver, &err))
+	}
 	cfg = parseCacheValue(op, cfg, vals, &err)
 
 	valueMap := make(map[string]string)
@@ -224,19 +240,35 @@ func InitConfig(r io.Reader) (upspin.Config, error) {
 		}
 		valueMap[key] = string(bytes.TrimSpace(b))
 	}
-	cfg = cfgValueMap{cfg, valueMap}
+	cfg = keepReplicas(cfg, cfgValueMap{cfg, valueMap})
 
 	return cfg, err
 }
 
 // valsFromYAML parses YAML from the given map and puts the values
-// into the provided map. Unrecognized keys generate an error.
-func valsFromYAML(vals map[string]string, other map[string]interface{}, data []byte) error {
+// into the provided maps. The keys of lists may have a YAML sequence
+// as their value, which is stored there rather than in vals.
+// Keys that are in neither vals nor lists are stored in other.
+func valsFromYAML(vals map[string]string, lists map[string][]string, other map[string]interface{}, data []byte) error {
 	newVals := map[string]interface{}{}
 	if err := yaml.Unmarshal(data, newVals); err != nil {
 		return errors.E(errors.Invalid, errors.Errorf("parsing YAML file: %v", err))
 	}
 	for k, v := range newVals {
+		if _, isList := lists[k]; isList {
+			if seq, ok := v.([]interface{}); ok {
+				list := make([]string, len(seq))
+				for i, e := range seq {
+					s, err := asString(e)
+					if err != nil {
+						return fmt.Errorf("%q: %v", k, err)
+					}
+					list[i] = s
+				}
+				lists[k] = list
+				continue
+			}
+		}
 		if _, ok := vals[k]; ok {
 			s, err := asString(v)
 			if err != nil {
@@ -262,11 +294,31 @@ func asString(v interface{}) (string, error) {
 	return "", errors.E(errors.Invalid, errors.Errorf("unrecognized value %T", v))
 }
 
+// parseEndpoints parses each of a list of endpoints. If any cannot be
+// parsed, it sets *errorp and returns nil.
+func parseEndpoints(op errors.Op, list []string, errorp *error) []upspin.Endpoint {
+	eps := make([]upspin.Endpoint, len(list))
+	for i, text := range list {
+		eps[i] = parseEndpointText(op, text, errorp)
+		if eps[i].Unassigned() {
+			if *errorp == nil {
+				*errorp = errors.E(op, errors.Invalid, "empty endpoint in list")
+			}
+			return nil
+		}
+	}
+	return eps
+}
+
 func parseEndpoint(op errors.Op, vals map[string]string, key string, errorp *error) upspin.Endpoint {
 	text, ok := vals[key]
 	if !ok || text == "" {
 		return upspin.Endpoint{}
 	}
+	return parseEndpointText(op, text, errorp)
+}
+
+func parseEndpointText(op errors.Op, text string, errorp *error) upspin.Endpoint {
 
 	ep, err := upspin.ParseEndpoint(text)
 	// If no transport is provided, assume remote transport.
@@ -334,10 +386,10 @@ func (cfg cfgUserName) UserName() upspin.UserName {
 // SetUserName returns a config derived from the given config
 // with the given user name.
 func SetUserName(cfg upspin.Config, u upspin.UserName) upspin.Config {
-	return cfgUserName{
+	return keepReplicas(cfg, cfgUserName{
 		Config:   cfg,
 		userName: u,
-	}
+	})
 }
 
 type cfgFactotum struct {
@@ -352,10 +404,10 @@ func (cfg cfgFactotum) Factotum() upspin.Factotum {
 // SetFactotum returns a config derived from the given config
 // with the given factotum.
 func SetFactotum(cfg upspin.Config, f upspin.Factotum) upspin.Config {
-	return cfgFactotum{
+	return keepReplicas(cfg, cfgFactotum{
 		Config:   cfg,
 		factotum: f,
-	}
+	})
 }
 
 type cfgPacking struct {
@@ -370,10 +422,10 @@ func (cfg cfgPacking) Packing() upspin.Packing {
 // SetPacking returns a config derived from the given config
 // with the given packing.
 func SetPacking(cfg upspin.Config, p upspin.Packing) upspin.Config {
-	return cfgPacking{
+	return keepReplicas(cfg, cfgPacking{
 		Config:  cfg,
 		packing: p,
-	}
+	})
 }
 
 type cfgKeyEndpoint struct {
@@ -388,10 +440,10 @@ func (cfg cfgKeyEndpoint) KeyEndpoint() upspin.Endpoint {
 // SetKeyEndpoint returns a config derived from the given config
 // with the given key endpoint.
 func SetKeyEndpoint(cfg upspin.Config, e upspin.Endpoint) upspin.Config {
-	return cfgKeyEndpoint{
+	return keepReplicas(cfg, cfgKeyEndpoint{
 		Config:      cfg,
 		keyEndpoint: e,
-	}
+	})
 }
 
 type cfgStoreEndpoint struct {
@@ -406,10 +458,10 @@ func (cfg cfgStoreEndpoint) StoreEndpoint() upspin.Endpoint {
 // SetStoreEndpoint returns a config derived from the given config
 // with the given store endpoint.
 func SetStoreEndpoint(cfg upspin.Config, e upspin.Endpoint) upspin.Config {
-	return cfgStoreEndpoint{
+	return keepReplicas(cfg, cfgStoreEndpoint{
 		Config:        cfg,
 		storeEndpoint: e,
-	}
+	})
 }
 
 type cfgDirEndpoint struct {
@@ -430,6 +482,49 @@ func SetDirEndpoint(cfg upspin.Config, e upspin.Endpoint) upspin.Config {
 	}
 }
 
+type cfgDirEndpoints struct {
+	upspin.Config
+	dirEndpoints []upspin.Endpoint
+}
+
+func (cfg cfgDirEndpoints) DirEndpoint() upspin.Endpoint {
+	return cfg.dirEndpoints[0]
+}
+
+func (cfg cfgDirEndpoints) DirEndpoints() []upspin.Endpoint {
+	return cfg.dirEndpoints
+}
+
+// SetDirEndpoints returns a config derived from the given config
+// with the given dir endpoints, the endpoints of replicas of a
+// single directory server. The first is the primary and is
+// returned by DirEndpoint. The config implements
+// upspin.DirReplicaConfig, as do configs derived from it by the
+// other Set functions of this package, except SetDirEndpoint.
+func SetDirEndpoints(cfg upspin.Config, e []upspin.Endpoint) upspin.Config {
+	if len(e) == 0 {
+		return SetDirEndpoint(cfg, upspin.Endpoint{})
+	}
+	return cfgDirEndpoints{
+		Config:       cfg,
+		dirEndpoints: append([]upspin.Endpoint(nil), e...),
+	}
+}
+
+// keepReplicas returns cfg, a config derived from base, such that it
+// lists the same DirServer replicas as base, if base lists any.
+// Embedding base in cfg would otherwise hide them.
+func keepReplicas(base, cfg upspin.Config) upspin.Config {
+	r, ok := base.(upspin.DirReplicaConfig)
+	if !ok {
+		return cfg
+	}
+	return cfgDirEndpoints{
+		Config:       cfg,
+		dirEndpoints: r.DirEndpoints(),
+	}
+}
+
 type cfgCacheEndpoint struct {
 	upspin.Config
 	cacheEndpoint upspin.Endpoint
@@ -442,10 +537,10 @@ func (cfg cfgCacheEndpoint) CacheEndpoint() upspin.Endpoint {
 // SetDirEndpoint returns a config derived from the given config
 // with the given dir endpoint.
 func SetCacheEndpoint(cfg upspin.Config, e upspin.Endpoint) upspin.Config {
-	return cfgCacheEndpoint{
+	return keepReplicas(cfg, cfgCacheEndpoint{
 		Config:        cfg,
 		cacheEndpoint: e,
-	}
+	})
 }
 
 type cfgValue struct {
@@ -463,11 +558,11 @@ func (cfg cfgValue) Value(key string) string {
 // SetValue returns a config derived from the given config that contains
 // the given key/value pair.
 func SetValue(cfg upspin.Config, key, val string) upspin.Config {
-	return cfgValue{
+	return keepReplicas(cfg, cfgValue{
 		Config: cfg,
 		key:    key,
 		val:    val,
-	}
+	})
 }
 
 type cfgValueMap struct {
@@ -199,6 +199,42 @@ secrets: ` + secretsDir + "\n"
 	testConfig(t, &expect, config)
 }
 
+func TestDirReplicas(t *testing.T) {
+	config := `
+dirserver:
+  - remote,dir.example.com
+  - dir2.example.com:8443
+secrets: ` + secretsDir + "\n"
+	cfg, err := InitConfig(strings.NewReader(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []upspin.Endpoint{
+		{Transport: upspin.Remote, NetAddr: "dir.example.com:443"},
+		{Transport: upspin.Remote, NetAddr: "dir2.example.com:8443"},
+	}
+	r, ok := cfg.(upspin.DirReplicaConfig)
+	if !ok {
+		t.Fatal("config does not list replicas")
+	}
+	if got := r.DirEndpoints(); !reflect.DeepEqual(got, want) {
+		t.Errorf("DirEndpoints() = %v, want %v", got, want)
+	}
+	if got := cfg.DirEndpoint(); got != want[0] {
+		t.Errorf("DirEndpoint() = %v, want %v", got, want[0])
+	}
+
+	// The replicas survive other changes to the config,
+	// but not a change of directory server.
+	r, ok = SetUserName(cfg, "bob@example.com").(upspin.DirReplicaConfig)
+	if !ok || !reflect.DeepEqual(r.DirEndpoints(), want) {
+		t.Errorf("SetUserName lost the replicas")
+	}
+	if _, ok := SetDirEndpoint(cfg, want[1]).(upspin.DirReplicaConfig); ok {
+		t.Errorf("SetDirEndpoint kept the replicas")
+	}
+}
+
 func makeConfig(expect *expectations) string {
 	var buf bytes.Buffer
 
@@ -40,7 +40,6 @@ import (
 	"upspin.io/access"
 	"upspin.io/cache"
 	"upspin.io/errors"
-	"upspin.io/log"
 	"upspin.io/path"
 	"upspin.io/upspin"
 )
@@ -217,9 +216,9 @@ func (l *clog) rotateLog() {
 		len += lfi.Size()
 		if len > 3*l.maxDisk/4 {
 			fn := lfi.Name(l.dir)
-			log.Debug.Printf("%s: remove log file %s", op, fn)
+			logger.Debug.Printf("%s: remove log file %s", op, fn)
 			if err := os.Remove(fn); err != nil {
-				log.Info.Printf("%s: %s", op, err)
+				logger.Info.Printf("%s: %s", op, err)
 			}
 		}
 	}
@@ -229,10 +228,10 @@ func (l *clog) rotateLog() {
 	lfi := &logFileInfo{number: l.highestLogFile}
 	f, err := os.OpenFile(lfi.Name(l.dir), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0700)
 	if err != nil {
-		log.Info.Printf("%s: %s", op, err)
+		logger.Info.Printf("%s: %s", op, err)
 		return
 	}
-	log.Debug.Printf("%s: new log file %s", op, f.Name())
+	logger.Debug.Printf("%s: new log file %s", op, f.Name())
 	l.logFileLock.Lock()
 	if l.file != nil {
 		l.wr.Flush()
@@ -254,7 +253,7 @@ func (l *clog) rotateLog() {
 // were most recently interested in. The watcher should eventually
 // replace them with trusted information.
 func (l *clog) wipeLog(user upspin.UserName) {
-	log.Info.Printf("wiping log")
+	logger.Info.Printf("wiping log")
 
 	l.globalLock.Lock()
 	defer l.globalLock.Unlock()
@@ -327,7 +326,7 @@ func listSorted(dir string, ascending bool) ([]*logFileInfo, int, error) {
 		if fi == nil {
 			// If it doesn't parse, remove it.
 			if err := os.Remove(infos[i].Name()); err != nil {
-				log.Info.Printf("rpc/dircache.listSorted: %s", err)
+				logger.Info.Printf("rpc/dircache.listSorted: %s", err)
 			}
 			continue
 		}
@@ -379,7 +378,7 @@ func (l *clog) rotater() {
 func (l *clog) readLogFile(fn string) error {
 	const op errors.Op = "rpc/dircache.readLogFile"
 
-	log.Debug.Printf("%s: %s", op, fn)
+	logger.Debug.Printf("%s: %s", op, fn)
 
 	// Open the log file.  If one didn't exist, just rename the new log file and return.
 	f, err := os.Open(fn)
@@ -394,15 +393,15 @@ func (l *clog) readLogFile(fn string) error {
 	var e clogEntry
 	if err := e.read(l, rd); err != nil {
 		if err != io.EOF {
-			log.Info.Printf("%s: %s", op, err)
+			logger.Info.Printf("%s: %s", op, err)
 		}
 		return err
 	}
 	if e.request != versionReq {
-		log.Info.Printf("%s: log %s: first entry not version request", op, fn)
+		logger.Info.Printf("%s: log %s: first entry not version request", op, fn)
 		return badVersion
 	} else if e.name != version {
-		log.Info.Printf("%s: log %s: expected version %s got %s", op, fn, version, e.name)
+		logger.Info.Printf("%s: log %s: expected version %s got %s", op, fn, version, e.name)
 		return badVersion
 	}
 	for {
@@ -411,12 +410,12 @@ func (l *clog) readLogFile(fn string) error {
 			if err == io.EOF {
 				break
 			}
-			log.Info.Printf("%s: %s", op, err)
+			logger.Info.Printf("%s: %s", op, err)
 			break
 		}
 		switch e.request {
 		case versionReq:
-			log.Info.Printf("%s: version other than first record", op)
+			logger.Info.Printf("%s: version other than first record", op)
 		case globReq:
 			// Since we first log all the contents of a directory before the glob,
 			// we need to first add all entries to a manufactured glob entry. Once
@@ -602,7 +601,7 @@ func (l *clog) globHasAccess(name upspin.PathName) (*upspin.DirEntry, bool) {
 func (l *clog) whichAccess(name upspin.PathName) (*upspin.DirEntry, error, bool) {
 	p, err := path.Parse(name)
 	if err != nil {
-		log.Debug.Printf("dir/dircache/whichAccess: %s", err)
+		logger.Debug.Printf("dir/dircache/whichAccess: %s", err)
 		return nil, nil, false
 	}
 
@@ -755,7 +754,7 @@ func (l *clog) updateLRU(e *clogEntry) {
 			return
 		}
 		if !errors.Is(errors.NotExist, e.error) {
-			log.Debug.Printf("updateLRU %s error %s", e.name, e.error)
+			logger.Debug.Printf("updateLRU %s error %s", e.name, e.error)
 			return
 		}
 		l.removeFromLRU(e, true)
@@ -801,7 +800,7 @@ func (l *clog) updateLRU(e *clogEntry) {
 		// These never get logged. They are just markers that the file
 		// is of interest to the watcher.
 	default:
-		log.Printf("unknown request type: %s", e)
+		logger.Printf("unknown request type: %s", e)
 	}
 }
 
@@ -879,7 +878,7 @@ func (l *clog) removeFromGlob(e *clogEntry) {
 func (l *clog) fixAccess(e *clogEntry) {
 	p, err := path.Parse(e.name)
 	if err != nil {
-		log.Debug.Printf("dir/dirCache/fixAccess: %s", err)
+		logger.Debug.Printf("dir/dirCache/fixAccess: %s", err)
 		return
 	}
 
@@ -928,7 +927,7 @@ func (e *clogEntry) invalidate() {
 // appendToLogFile appends to the clog file.
 func (l *clog) appendToLogFile(e *clogEntry) error {
 	if e.request == obsoleteReq {
-		log.Info.Printf("appendToLogFile: obsolete requests should not be written to log")
+		logger.Info.Printf("appendToLogFile: obsolete requests should not be written to log")
 		return nil
 	}
 
@@ -1272,11 +1271,11 @@ func dumpMemStats() {
 	if *memprofile != "" {
 		f, err := os.Create(*memprofile)
 		if err != nil {
-			log.Fatalf("could not create memory profile: %s", err)
+			logger.Fatalf("could not create memory profile: %s", err)
 		}
 		runtime.GC() // get up-to-date statistics
 		if err := pprof.WriteHeapProfile(f); err != nil {
-			log.Fatalf("could not write memory profile: %s", err)
+			logger.Fatalf("could not write memory profile: %s", err)
 		}
 		f.Close()
 	}
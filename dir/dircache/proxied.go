@@ -15,7 +15,6 @@ import (
 	"upspin.io/access"
 	"upspin.io/bind"
 	"upspin.io/errors"
-	"upspin.io/log"
 	"upspin.io/path"
 	"upspin.io/upspin"
 	"upspin.io/user"
@@ -71,7 +70,7 @@ func (p *proxiedDirs) cacheable(name upspin.PathName, ep *upspin.Endpoint) bool
 
 	parsed, err := path.Parse(name)
 	if err != nil {
-		log.Info.Printf("parse error on a cleaned name: %s", name)
+		logger.Info.Printf("parse error on a cleaned name: %s", name)
 		return false
 	}
 	u := parsed.User()
@@ -139,7 +138,7 @@ func (p *proxiedDirs) setSequence(name upspin.PathName, sequence int64) {
 
 	parsed, err := path.Parse(name)
 	if err != nil {
-		log.Info.Printf("parse error on a cleaned name: %s", name)
+		logger.Info.Printf("parse error on a cleaned name: %s", name)
 		return
 	}
 	u := parsed.User()
@@ -172,7 +171,7 @@ const (
 
 // watcher watches a directory and caches any changes to something already in the LRU.
 func (d *proxiedDir) watcher(ep upspin.Endpoint) {
-	log.Debug.Printf("dircache.Watcher %s %s", d.user, ep)
+	logger.Debug.Printf("dircache.Watcher %s %s", d.user, ep)
 	defer close(d.dying)
 
 	// If we don't know better, always read in the whole state. It
@@ -186,7 +185,7 @@ func (d *proxiedDir) watcher(ep upspin.Endpoint) {
 	for {
 		err := d.watch(ep)
 		if err == nil {
-			log.Debug.Printf("dircache.Watcher %s %s exiting", d.user, ep)
+			logger.Debug.Printf("dircache.Watcher %s %s exiting", d.user, ep)
 			// watch() only returns if the watcher has been told to die
 			// or if there is an error requiring a new Watch.
 			return
@@ -194,19 +193,19 @@ func (d *proxiedDir) watcher(ep upspin.Endpoint) {
 		if err == upspin.ErrNotSupported {
 			// Can't survive this.
 			d.watchSupported = false
-			log.Debug.Printf("dir/dircache.watcher: %s: %s", d.user, err)
+			logger.Debug.Printf("dir/dircache.watcher: %s: %s", d.user, err)
 			return
 		}
 		if errors.Is(errors.Invalid, err) {
 			// A bad record in the log or a bad sequence number. Reread current state.
-			log.Info.Printf("dir/dircache.watcher restarting from -1: %s: %s", d.user, err)
+			logger.Info.Printf("dir/dircache.watcher restarting from -1: %s: %s", d.user, err)
 			d.sequence = -1
 		} else {
-			log.Info.Printf("dir/dircache.watcher: %s: %s", d.user, err)
+			logger.Info.Printf("dir/dircache.watcher: %s: %s", d.user, err)
 		}
 		if d.retries == maxRetries {
 			// We tried often, we tried hard.  Now is the time to let go.
-			log.Info.Printf("dir/dircache.watcher %s %s maximum retries (%d) reached, giving up", d.user, ep, maxRetries)
+			logger.Info.Printf("dir/dircache.watcher %s %s maximum retries (%d) reached, giving up", d.user, ep, maxRetries)
 			close(d.die)
 			d.die = nil
 			return
@@ -238,7 +237,7 @@ func (d *proxiedDir) watch(ep upspin.Endpoint) error {
 	if err != nil {
 		return err
 	}
-	log.Info.Printf("dir/dircache: Watch(%q) started", name)
+	logger.Info.Printf("dir/dircache: Watch(%q) started", name)
 
 	// If Watch succeeds, go back to the initial interval and count.
 	d.retryInterval = initialRetryInterval
@@ -254,9 +253,9 @@ func (d *proxiedDir) watch(ep upspin.Endpoint) error {
 				return errors.Str("Watch event stream closed")
 			}
 			if e.Error != nil {
-				log.Debug.Printf("dir/dircache: Watch(%q) error: %s", name, e.Error)
+				logger.Debug.Printf("dir/dircache: Watch(%q) error: %s", name, e.Error)
 			} else {
-				log.Debug.Printf("dir/dircache: Watch(%q) entry: %s (delete=%t)", name, e.Entry.Name, e.Delete)
+				logger.Debug.Printf("dir/dircache: Watch(%q) entry: %s (delete=%t)", name, e.Entry.Name, e.Delete)
 			}
 			if err := d.handleEvent(&e); err != nil {
 				return err
@@ -9,6 +9,8 @@ package dircache
 import (
 	"fmt"
 	ospath "path"
+	"sync"
+	"time"
 
 	"upspin.io/access"
 	"upspin.io/bind"
@@ -19,6 +21,8 @@ import (
 	"upspin.io/upspin"
 )
 
+var logger = log.For("dir/dircache")
+
 const (
 	// doAccessChecks true allows the dircache to perform access checks
 	// before going to the directory server. This is in preparation to
@@ -62,6 +66,9 @@ type server struct {
 
 	// Access file cache.
 	accessFiles map[upspin.PathName]parsedAccess
+
+	// The changes of transactions prepared through this cache.
+	txns *txnChanges
 }
 
 // New creates a new DirServer cache reading in the log and writing out a new compacted log.
@@ -76,6 +83,7 @@ func New(uncachedCfg, cachedCfg upspin.Config, cacheDir string, maxLogBytes int6
 		clog:        clog,
 		flushBlock:  flushBlock,
 		accessFiles: make(map[upspin.PathName]parsedAccess),
+		txns:        &txnChanges{m: make(map[txnKey][]upspin.DirChange)},
 	}, nil
 }
 
@@ -156,9 +164,55 @@ func (s *server) Glob(pattern string) ([]*upspin.DirEntry, error) {
 	return entries, globReqErr
 }
 
+// GlobShallow implements upspin.ShallowGlobber. A Glob whose result is
+// cached is answered from the cache. Otherwise the request is passed on,
+// and its result, lacking the entries' blocks, is not cached.
+func (s *server) GlobShallow(pattern string) ([]*upspin.DirEntry, error) {
+	op := logf("GlobShallow %q", pattern)
+
+	name := path.Clean(upspin.PathName(pattern))
+	dir, cacheable, err := s.dirFor(name)
+	if err != nil {
+		op.log(err)
+		return nil, err
+	}
+	if cacheable {
+		s.clog.globalLock.RLock()
+		entries, err, ok := s.clog.lookupGlob(name)
+		s.clog.globalLock.RUnlock()
+		if ok {
+			shallow := make([]*upspin.DirEntry, len(entries))
+			for i, e := range entries {
+				cp := *e
+				cp.MarkIncomplete()
+				shallow[i] = &cp
+			}
+			return shallow, err
+		}
+	}
+	if g, ok := dir.(upspin.ShallowGlobber); ok {
+		return g.GlobShallow(string(name))
+	}
+	entries, err := dir.Glob(string(name))
+	for _, e := range entries {
+		e.MarkIncomplete()
+	}
+	return entries, err
+}
+
 // Put implements upspin.DirServer.
 // TODO(p): Remember access errors to avoid even trying?
 func (s *server) Put(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
+	return s.put(entry, true)
+}
+
+// PutUncheckedLink implements upspin.UncheckedLinkPutter.
+func (s *server) PutUncheckedLink(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
+	return s.put(entry, false)
+}
+
+// put implements Put and, if checkLink is false, PutUncheckedLink.
+func (s *server) put(entry *upspin.DirEntry, checkLink bool) (*upspin.DirEntry, error) {
 	op := logf("Put %q", entry.Name)
 	name := path.Clean(entry.Name)
 	if name != entry.Name {
@@ -171,7 +225,7 @@ func (s *server) Put(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
 		return nil, err
 	}
 	if !cacheable {
-		return dir.Put(entry)
+		return putTo(dir, entry, checkLink)
 	}
 
 	// Can we Put?
@@ -195,12 +249,12 @@ func (s *server) Put(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
 	s.clog.globalLock.Lock()
 	defer s.clog.globalLock.Unlock()
 
-	de, err := dir.Put(entry)
+	de, err := putTo(dir, entry, checkLink)
 	if err != nil {
 		// Keep track of our access checks until we are sure they
 		// match the server.
 		if doAccessChecks && granted && errors.Is(errors.Permission, err) {
-			log.Error.Printf("put access refused but we predicted granted: %s, %s, %s", name, s.uncachedCfg.UserName(), err)
+			logger.Error.Printf("put access refused but we predicted granted: %s, %s, %s", name, s.uncachedCfg.UserName(), err)
 		}
 		return de, err
 	}
@@ -223,11 +277,20 @@ func (s *server) Put(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
 	// Keep track of our access checks until we are sure they
 	// match the server.
 	if doAccessChecks && !granted {
-		log.Error.Printf("put access granted but we predicted refused: %s, %s, %s", name, s.uncachedCfg.UserName(), accErr)
+		logger.Error.Printf("put access granted but we predicted refused: %s, %s, %s", name, s.uncachedCfg.UserName(), accErr)
 	}
 	return de, err
 }
 
+// putTo puts the entry to dir, using PutUncheckedLink if checkLink is
+// false and dir implements it.
+func putTo(dir upspin.DirServer, entry *upspin.DirEntry, checkLink bool) (*upspin.DirEntry, error) {
+	if p, ok := dir.(upspin.UncheckedLinkPutter); ok && !checkLink {
+		return p.PutUncheckedLink(entry)
+	}
+	return dir.Put(entry)
+}
+
 // Delete implements upspin.DirServer.
 func (s *server) Delete(name upspin.PathName) (*upspin.DirEntry, error) {
 	op := logf("Delete %q", name)
@@ -264,11 +327,11 @@ func (s *server) Delete(name upspin.PathName) (*upspin.DirEntry, error) {
 	if doAccessChecks {
 		if granted {
 			if err != nil && errors.Is(errors.Permission, err) {
-				log.Error.Printf("delete access refused but we predicted granted: %s, %s, %s", name, s.uncachedCfg.UserName(), err)
+				logger.Error.Printf("delete access refused but we predicted granted: %s, %s, %s", name, s.uncachedCfg.UserName(), err)
 			}
 		} else {
 			if err == nil {
-				log.Error.Printf("delete access granted but we predicted refused: %s, %s, %s", name, s.uncachedCfg.UserName(), accErr)
+				logger.Error.Printf("delete access granted but we predicted refused: %s, %s, %s", name, s.uncachedCfg.UserName(), accErr)
 			}
 		}
 	}
@@ -302,6 +365,66 @@ func (s *server) WhichAccess(name upspin.PathName) (*upspin.DirEntry, error) {
 	return de, err
 }
 
+// WhichAccessBatch implements upspin.WhichAccessBatcher. All the names
+// must be served by the same directory server, which must implement it too.
+func (s *server) WhichAccessBatch(names []upspin.PathName) ([]upspin.WhichAccessResult, error) {
+	op := logf("WhichAccessBatch %d names", len(names))
+
+	var dir upspin.DirServer
+	cacheable := true
+	names = append([]upspin.PathName(nil), names...)
+	for i := range names {
+		names[i] = path.Clean(names[i])
+		d, ok, err := s.dirFor(names[i])
+		if err != nil {
+			op.log(err)
+			return nil, err
+		}
+		if i > 0 && d.Endpoint() != dir.Endpoint() {
+			err := errors.E(names[i], errors.Invalid, "names served by different directory servers")
+			op.log(err)
+			return nil, err
+		}
+		dir = d
+		cacheable = cacheable && ok
+	}
+	b, ok := dir.(upspin.WhichAccessBatcher)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	if !cacheable {
+		return b.WhichAccessBatch(names)
+	}
+
+	s.clog.globalLock.RLock()
+	defer s.clog.globalLock.RUnlock()
+
+	// Ask the server only about the names we do not know.
+	results := make([]upspin.WhichAccessResult, len(names))
+	var missing []upspin.PathName
+	var where []int
+	for i, name := range names {
+		if de, err, ok := s.clog.whichAccess(name); ok {
+			results[i] = upspin.WhichAccessResult{Entry: de, Error: err}
+			continue
+		}
+		missing = append(missing, name)
+		where = append(where, i)
+	}
+	if len(missing) == 0 {
+		return results, nil
+	}
+	fetched, err := b.WhichAccessBatch(missing)
+	if err != nil {
+		return nil, err
+	}
+	for j, res := range fetched {
+		s.clog.logRequest(whichAccessReq, missing[j], res.Error, res.Entry)
+		results[where[j]] = res
+	}
+	return results, nil
+}
+
 // Watch implements upspin.DirServer.
 func (s *server) Watch(name upspin.PathName, sequence int64, done <-chan struct{}) (<-chan upspin.Event, error) {
 	op := logf("Watch %q", name)
@@ -315,12 +438,235 @@ func (s *server) Watch(name upspin.PathName, sequence int64, done <-chan struct{
 	return dir.Watch(name, sequence, done)
 }
 
+// WatchMulti implements upspin.MultiWatcher. All the paths must be served
+// by the same directory server, which must implement it too.
+func (s *server) WatchMulti(paths []upspin.WatchPath, done <-chan struct{}) (<-chan upspin.Event, error) {
+	op := logf("WatchMulti %d paths", len(paths))
+
+	var dir upspin.DirServer
+	for i, wp := range paths {
+		d, _, err := s.dirFor(path.Clean(wp.Name))
+		if err != nil {
+			op.log(err)
+			return nil, err
+		}
+		if i > 0 && d.Endpoint() != dir.Endpoint() {
+			err := errors.E(wp.Name, errors.Invalid, "paths served by different directory servers")
+			op.log(err)
+			return nil, err
+		}
+		dir = d
+	}
+	mw, ok := dir.(upspin.MultiWatcher)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	return mw.WatchMulti(paths, done)
+}
+
+// ApplyBatch implements upspin.DirBatcher. All the changes must be served
+// by the same directory server, which must implement it too.
+func (s *server) ApplyBatch(changes []upspin.DirChange) ([]*upspin.DirEntry, error) {
+	op := logf("ApplyBatch %d changes", len(changes))
+
+	var dir upspin.DirServer
+	cacheable := true
+	for i, c := range changes {
+		d, ok, err := s.dirFor(path.Clean(c.Entry.Name))
+		if err != nil {
+			op.log(err)
+			return nil, err
+		}
+		if i > 0 && d.Endpoint() != dir.Endpoint() {
+			err := errors.E(c.Entry.Name, errors.Invalid, "changes served by different directory servers")
+			op.log(err)
+			return nil, err
+		}
+		dir = d
+		cacheable = cacheable && ok
+	}
+	b, ok := dir.(upspin.DirBatcher)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	if !cacheable {
+		return b.ApplyBatch(changes)
+	}
+
+	// Wait for Access and Group block writes to flush.
+	for _, c := range changes {
+		if s.flushBlock != nil && !c.Delete && access.IsAccessControlFile(c.Entry.Name) {
+			for _, b := range c.Entry.Blocks {
+				s.flushBlock(b.Location)
+			}
+		}
+	}
+
+	s.clog.globalLock.Lock()
+	defer s.clog.globalLock.Unlock()
+
+	entries, err := b.ApplyBatch(changes)
+	if err != nil {
+		op.log(err)
+		return nil, err
+	}
+	s.logChanges(changes, entries)
+	return entries, nil
+}
+
+// logChanges remembers changes applied together, for which the directory
+// server returned entries, as if they had been made one at a time.
+// Called with s.clog.globalLock held.
+func (s *server) logChanges(changes []upspin.DirChange, entries []*upspin.DirEntry) {
+	for i, c := range changes {
+		if c.Delete {
+			s.clog.logRequest(deleteReq, c.Entry.Name, nil, entries[i])
+			continue
+		}
+		entry := *c.Entry
+		entry.Sequence = entries[i].Sequence
+		s.clog.inSequence(entry.Name, entry.Sequence)
+		s.clog.logRequest(putReq, entry.Name, nil, &entry)
+	}
+}
+
+// PreparePut implements upspin.DirTransactor. The changes of a cacheable
+// transaction are remembered so they can be logged when it commits.
+func (s *server) PreparePut(txID string, changes []upspin.DirChange) error {
+	op := logf("PreparePut %q %d changes", txID, len(changes))
+
+	if len(changes) == 0 {
+		return errors.E(errors.Invalid, "empty transaction")
+	}
+	dir, cacheable, err := s.dirFor(path.Clean(changes[0].Entry.Name))
+	if err != nil {
+		op.log(err)
+		return err
+	}
+	t, ok := dir.(upspin.DirTransactor)
+	if !ok {
+		return upspin.ErrNotSupported
+	}
+	// Wait for Access and Group block writes to flush.
+	for _, c := range changes {
+		if s.flushBlock != nil && !c.Delete && access.IsAccessControlFile(c.Entry.Name) {
+			for _, b := range c.Entry.Blocks {
+				s.flushBlock(b.Location)
+			}
+		}
+	}
+	if err := t.PreparePut(txID, changes); err != nil {
+		op.log(err)
+		return err
+	}
+	if cacheable {
+		s.txns.add(s.authority, txID, changes)
+	}
+	return nil
+}
+
+// CommitTx implements upspin.DirTransactor.
+func (s *server) CommitTx(txID string) ([]*upspin.DirEntry, error) {
+	op := logf("CommitTx %q", txID)
+
+	dir, _, err := s.dirFor("")
+	if err != nil {
+		op.log(err)
+		return nil, err
+	}
+	t, ok := dir.(upspin.DirTransactor)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+
+	s.clog.globalLock.Lock()
+	defer s.clog.globalLock.Unlock()
+
+	entries, err := t.CommitTx(txID)
+	if err != nil {
+		op.log(err)
+		return nil, err
+	}
+	if changes, ok := s.txns.remove(s.authority, txID); ok {
+		s.logChanges(changes, entries)
+	}
+	return entries, nil
+}
+
+// AbortTx implements upspin.DirTransactor.
+func (s *server) AbortTx(txID string) error {
+	op := logf("AbortTx %q", txID)
+
+	dir, _, err := s.dirFor("")
+	if err != nil {
+		op.log(err)
+		return err
+	}
+	t, ok := dir.(upspin.DirTransactor)
+	if !ok {
+		return upspin.ErrNotSupported
+	}
+	if err := t.AbortTx(txID); err != nil {
+		op.log(err)
+		return err
+	}
+	s.txns.remove(s.authority, txID)
+	return nil
+}
+
+// AccessHistory implements upspin.AccessHistorian. The history is never
+// cached.
+func (s *server) AccessHistory(name upspin.PathName, since time.Time, limit int) ([]upspin.AccessRecord, error) {
+	op := logf("AccessHistory %q", name)
+
+	name = path.Clean(name)
+	dir, _, err := s.dirFor(name)
+	if err != nil {
+		op.log(err)
+		return nil, err
+	}
+	h, ok := dir.(upspin.AccessHistorian)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	return h.AccessHistory(name, since, limit)
+}
+
+// txnKey identifies a transaction prepared on a directory server.
+type txnKey struct {
+	dir  upspin.Endpoint
+	txID string
+}
+
+// txnChanges holds the changes of prepared transactions, shared by all
+// dialed instances of a server. The changes of a transaction that is
+// neither committed nor aborted through the cache are kept until the
+// process exits.
+type txnChanges struct {
+	mu sync.Mutex
+	m  map[txnKey][]upspin.DirChange
+}
+
+func (t *txnChanges) add(dir upspin.Endpoint, txID string, changes []upspin.DirChange) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.m[txnKey{dir, txID}] = changes
+}
+
+func (t *txnChanges) remove(dir upspin.Endpoint, txID string) ([]upspin.DirChange, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	changes, ok := t.m[txnKey{dir, txID}]
+	delete(t.m, txnKey{dir, txID})
+	return changes, ok
+}
+
 func (s *server) Endpoint() upspin.Endpoint { return s.authority }
 func (s *server) Close()                    {}
 
 func logf(format string, args ...interface{}) operation {
 	s := fmt.Sprintf(format, args...)
-	log.Debug.Print("dir/dircache: " + s)
+	logger.Debug.Print("dir/dircache: " + s)
 	return operation(s)
 }
 
@@ -475,10 +475,13 @@ func (s *server) Watch(name upspin.PathName, seq int64, done <-chan struct{}) (<
 	}
 	// The root must exist.
 	s.db.mu.RLock()
-	defer s.db.mu.RUnlock()
-	if s.db.root[parsed.User()] == nil {
+	exists := s.db.root[parsed.User()] != nil
+	s.db.mu.RUnlock()
+	if !exists {
 		return nil, errors.E(op, name, errors.NotExist)
 	}
+	// The lock must not be held here, as the event manager takes it
+	// to check access while delivering events.
 	return s.db.eventMgr.watch(s, parsed, seq, done)
 }
 
@@ -849,7 +852,7 @@ func (s *server) installEntry(op errors.Op, dirName upspin.PathName, dirEntry *u
 			// We want nextEntry's sequence but everything else from newEntry.
 			if newEntry.Sequence != upspin.SeqIgnore {
 				if newEntry.Sequence != nextEntry.Sequence {
-					return nil, nil, errors.E(op, newEntry.Name, errSeq)
+					return nil, nil, errors.E(op, newEntry.Name, errors.Invalid, errSeq)
 				}
 			}
 			newEntry.Sequence = nextEntry.Sequence
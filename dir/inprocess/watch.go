@@ -5,6 +5,7 @@
 package inprocess
 
 import (
+	"sort"
 	"time"
 
 	"upspin.io/access"
@@ -16,15 +17,21 @@ import (
 
 const watchTimeout = 10 * time.Second
 
+// MaxWatchEvents is the most events New's DirServers keep for each user's
+// tree. Older events are discarded, after which a Watch that asks for them
+// receives an error event instead; it may start again with WatchCurrent.
+var MaxWatchEvents = 10000
+
 // A listener connects the event manager to an events channel on which
 // to deliver events to a single client.
 type listener struct {
 	eventMgr *eventManager
 	root     path.Parsed     // The root of the subtree of interest.
+	user     upspin.UserName // The user whose tree holds root.
 	server   *server         // Holds the user info; needed for access control.
 	done     <-chan struct{} // From the Watch method; signals termination.
 	events   chan<- upspin.Event
-	sequence int64 // The point in the event stream the listener has reached.
+	sequence int64 // The position in its user's event log the listener has reached.
 }
 
 // want reports whether this listener is interested in the event, which means that the
@@ -58,26 +65,27 @@ func (l *listener) doneHandler() {
 // complete the list. l.sequence keeps track of our progress.
 func (l *listener) sendAll(events []upspin.Event) bool {
 	for _, event := range events {
-		parsed, err := path.Parse(event.Entry.Name)
-		if err != nil {
-			// Shouldn't happen.
-			log.Info.Printf("dir/inprocess.Sendall: parse error for %q: %v", event.Entry.Name, err)
-			continue
-		}
-		if cleanEvent, ok := l.want(event, parsed); ok {
-			select {
-			case l.events <- cleanEvent:
-				// Delivered.
-				l.sequence++
-			case <-time.After(watchTimeout):
-				// Failed to deliver; client is not keeping up.
-				return false
-			}
+		if !l.send(event) {
+			return false
 		}
 	}
 	return true
 }
 
+// send sends the event if the listener wants it, and advances l.sequence
+// past it. It returns false if the listener is not keeping up.
+func (l *listener) send(event upspin.Event) bool {
+	parsed, err := path.Parse(event.Entry.Name)
+	if err != nil {
+		// Shouldn't happen.
+		log.Info.Printf("dir/inprocess.send: parse error for %q: %v", event.Entry.Name, err)
+	} else if cleanEvent, ok := l.want(event, parsed); ok && !l.sendEvent(cleanEvent) {
+		return false
+	}
+	l.sequence++
+	return true
+}
+
 // sendTree sends events for the tree rooted at name. The boolean
 // return reports whether it sent all valid info to the client. Thus a
 // false return means this listener did not start properly, but it is
@@ -133,27 +141,61 @@ func (l *listener) sendEvent(event upspin.Event) bool {
 	}
 }
 
+// eventLog holds the most recent events in one user's tree.
+type eventLog struct {
+	events      []upspin.Event
+	dropped     int64 // The number of earlier events, now discarded.
+	lastDropped int64 // The Sequence of the last discarded event.
+}
+
+// end returns the position in the log after its last event.
+func (l *eventLog) end() int64 {
+	return l.dropped + int64(len(l.events))
+}
+
+// append adds the event to the log, discarding the oldest events so that
+// at most max remain. The events are never modified in place, so earlier
+// copies of the log remain valid.
+func (l *eventLog) append(event upspin.Event, max int) {
+	l.events = append(l.events, event)
+	if n := len(l.events) - max; n > 0 {
+		l.lastDropped = l.events[n-1].Entry.Sequence
+		l.events = l.events[n:]
+		l.dropped += int64(n)
+	}
+}
+
 // eventManager is the structure that delivers events to all the listeners.
 type eventManager struct {
-	events    []upspin.Event
+	maxEvents int // The most events kept in each log.
+	logs      map[upspin.UserName]*eventLog
 	listeners []*listener
 
 	// These channels mediate all access to the event manager.
-	eventsSoFar  chan []upspin.Event
+	logRequest   chan logRequest
 	newListener  chan *listener
 	newEvent     chan upspin.Event
 	listenerDone chan *listener
 }
 
+// A logRequest asks the event manager for a copy of a user's event log.
+type logRequest struct {
+	user  upspin.UserName
+	reply chan eventLog
+}
+
 // newEventManager returns a new event manager. There is one per database.
 func newEventManager() *eventManager {
 	// The numbers here are arbitrary, but for the testing purposes of
 	// this package should be enough to prevent blocking the client.
 	// A more serious attempt would scale these dynamically as needed.
 	em := &eventManager{
-		newListener: make(chan *listener, 100),
-		newEvent:    make(chan upspin.Event, 100),
-		eventsSoFar: make(chan []upspin.Event),
+		maxEvents:    MaxWatchEvents,
+		logs:         make(map[upspin.UserName]*eventLog),
+		logRequest:   make(chan logRequest),
+		newListener:  make(chan *listener, 100),
+		newEvent:     make(chan upspin.Event, 100),
+		listenerDone: make(chan *listener, 100),
 	}
 	go em.run()
 	return em
@@ -163,48 +205,102 @@ func newEventManager() *eventManager {
 // All interaction with the event manager is through the channels handled
 // in this goroutine, obviating explicit mutexes.
 func (e *eventManager) run() {
-	// Invariant: Each element of e.listeners has its sequence at the current point.
+	// Invariant: Each element of e.listeners is at the end of its user's log.
 	// When we receive an event, each element of that list is ready for it.
 	// We only add a new listener to the list once it has caught up to the rest.
 	for {
 		select {
-		case e.eventsSoFar <- e.events:
-			// Nothing to do.
+		case req := <-e.logRequest:
+			// Take in the events already sent, so that a Watch sees
+			// the effect of every Put or Delete that preceded it.
+			for drained := false; !drained; {
+				select {
+				case event := <-e.newEvent:
+					e.addEvent(event)
+				default:
+					drained = true
+				}
+			}
+			var elog eventLog
+			if l := e.logs[req.user]; l != nil {
+				elog = *l
+			}
+			req.reply <- elog
 		case listener := <-e.listenerDone:
 			e.delete(listener)
 		case listener := <-e.newListener:
-			// New listener has been created, but it may be behind.
-			if listener.sequence < int64(len(e.events)) && !listener.sendAll(e.events[listener.sequence:]) {
-				// It couldn't catch up, so ignore it and don't install it.
+			select {
+			case <-listener.done:
+				// Closed while the listener was starting.
 				close(listener.events)
 				continue
+			default:
 			}
-			e.listeners = append(e.listeners, listener)
-		case event := <-e.newEvent:
-			parsed, err := path.Parse(event.Entry.Name)
-			if err != nil {
-				// Shouldn't happen.
-				log.Info.Printf("dir/inprocess: parse error in event for %q: %v", event.Entry.Name, err)
-				continue
-			}
-			n := len(e.listeners)
-			for i := 0; i < n; i++ {
-				l := e.listeners[i]
-				if cleanEvent, ok := l.want(event, parsed); ok {
-					if l.sendEvent(cleanEvent) {
-						// Delivered.
-						l.sequence++
-					} else {
-						// Failed to deliver; client is not keeping up.
-						e.deleteNth(i)
-						i-- // Back up the loop counter; ith guy is gone.
-						n--
-					}
+			// New listener has been created, but it may be behind.
+			if elog := e.logs[listener.user]; elog != nil && listener.sequence < elog.end() {
+				if listener.sequence < elog.dropped {
+					// What it has yet to see has been discarded.
+					listener.sendEvent(gapEvent(listener.user, elog))
+					close(listener.events)
+					continue
+				}
+				if !listener.sendAll(elog.events[listener.sequence-elog.dropped:]) {
+					// It couldn't catch up, so ignore it and don't install it.
+					close(listener.events)
+					continue
 				}
 			}
-			e.events = append(e.events, event)
+			e.listeners = append(e.listeners, listener)
+		case event := <-e.newEvent:
+			e.addEvent(event)
+		}
+	}
+}
+
+// addEvent delivers the event to the listeners and adds it to its user's log.
+func (e *eventManager) addEvent(event upspin.Event) {
+	parsed, err := path.Parse(event.Entry.Name)
+	if err != nil {
+		// Shouldn't happen.
+		log.Info.Printf("dir/inprocess: parse error in event for %q: %v", event.Entry.Name, err)
+		return
+	}
+	user := parsed.User()
+	n := len(e.listeners)
+	for i := 0; i < n; i++ {
+		l := e.listeners[i]
+		if l.user != user {
+			continue
+		}
+		if !l.send(event) {
+			// Failed to deliver; client is not keeping up.
+			e.deleteNth(i)
+			i-- // Back up the loop counter; ith guy is gone.
+			n--
 		}
 	}
+	elog := e.logs[user]
+	if elog == nil {
+		elog = &eventLog{}
+		e.logs[user] = elog
+	}
+	elog.append(event, e.maxEvents)
+}
+
+// eventLog returns a copy of the user's event log.
+func (e *eventManager) eventLog(user upspin.UserName) eventLog {
+	reply := make(chan eventLog)
+	e.logRequest <- logRequest{user: user, reply: reply}
+	return <-reply
+}
+
+// gapEvent returns the error event reporting that events in the user's
+// log before those in elog have been discarded.
+func gapEvent(user upspin.UserName, elog *eventLog) upspin.Event {
+	const op errors.Op = "dir/inprocess.Watch"
+	return upspin.Event{
+		Error: errors.E(op, errors.Invalid, user, errors.Errorf("events up to sequence %d have been discarded", elog.lastDropped)),
+	}
 }
 
 // deleteNth deletes the Nth listener from the eventManager's list.
@@ -214,7 +310,9 @@ func (e *eventManager) deleteNth(i int) {
 	e.listeners = e.listeners[:len(e.listeners)-1]
 }
 
-// delete deletes the listener from the eventManager's list.
+// delete deletes the listener from the eventManager's list. The listener
+// need not be there, as those that fail to start or keep up are removed
+// as soon as they fail.
 func (e *eventManager) delete(which *listener) {
 	for i, l := range e.listeners {
 		if l == which {
@@ -222,7 +320,6 @@ func (e *eventManager) delete(which *listener) {
 			return
 		}
 	}
-	panic("listener not found in event loop")
 }
 
 // watch is the implementation of DirServer.Watch after basic checking is done.
@@ -232,46 +329,61 @@ func (e *eventManager) watch(server *server, root path.Parsed, sequence int64, d
 	l := &listener{
 		eventMgr: e,
 		root:     root,
+		user:     root.User(),
 		server:   server,
 		done:     done,
 		events:   events,
-		sequence: 0,
 	}
-	go l.doneHandler()
 
-	eventsSoFar := <-e.eventsSoFar
+	elog := e.eventLog(l.user)
 
-	// A sequence other than the special cases must exist.
-	// The special case of an invalid sequence is returned as an event with an "invalid" error.
-	if sequence != upspin.WatchCurrent && sequence != upspin.WatchStart && sequence != upspin.WatchNew {
-		if sequence < 0 || int64(len(eventsSoFar)) < sequence {
-			events <- upspin.Event{Error: errors.E(op, errors.Invalid, "bad sequence")}
-			close(events)
-			return events, nil
+	// Find where in the log to start. A sequence other than the special
+	// cases must be that of an event in the log or before it, or else it is
+	// returned as an event with an "invalid" error, as are sequences
+	// whose events have been discarded.
+	var errEvent *upspin.Event
+	switch sequence {
+	case upspin.WatchStart:
+		// 0 is a special case in the API, but it's not a special case here.
+		l.sequence = 0
+	case upspin.WatchCurrent, upspin.WatchNew:
+		l.sequence = elog.end()
+	default:
+		i := sort.Search(len(elog.events), func(i int) bool {
+			return elog.events[i].Entry.Sequence >= sequence
+		})
+		if sequence < 0 || i == len(elog.events) {
+			errEvent = &upspin.Event{Error: errors.E(op, errors.Invalid, "bad sequence")}
 		}
+		l.sequence = elog.dropped + int64(i)
+		if sequence <= elog.lastDropped {
+			// Some of the events asked for have been discarded.
+			l.sequence = 0
+		}
+	}
+	if errEvent == nil && l.sequence < elog.dropped {
+		gap := gapEvent(l.user, &elog)
+		errEvent = &gap
 	}
+	if errEvent != nil {
+		events <- *errEvent
+		close(events)
+		return events, nil
+	}
+	go l.doneHandler()
 
 	// Must do this in the background and return so client can receive initialization events.
 	go func() {
-		switch sequence {
-		case upspin.WatchStart:
-			// 0 is a special case in the API, but it's not a special case here.
-			fallthrough
-		default:
-			if !l.sendAll(eventsSoFar) {
-				log.Printf("dir/inprocess.Watch %q could not send all initial events", root)
-				return
-			}
-		case upspin.WatchCurrent:
+		if sequence == upspin.WatchCurrent {
 			// Send state of tree under name.
 			if !l.sendTree(root.Path()) {
 				log.Printf("dir/inprocess.Watch %q could not send all initial events", root)
 				return
 			}
-			fallthrough
-		case upspin.WatchNew:
-			// Start transmitting from where we were before sendTree.
-			l.sequence = int64(len(eventsSoFar))
+		}
+		if !l.sendAll(elog.events[l.sequence-elog.dropped:]) {
+			log.Printf("dir/inprocess.Watch %q could not send all initial events", root)
+			return
 		}
 		e.newListener <- l
 	}()
@@ -0,0 +1,141 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package inprocess
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// putFiles puts files numbered first to end-1 in the root of the
+// config's user and returns their sequence numbers.
+func putFiles(t *testing.T, cfg upspin.Config, dir upspin.DirServer, first, end int) []int64 {
+	t.Helper()
+	var seqs []int64
+	for i := first; i < end; i++ {
+		name := upspin.PathName(fmt.Sprintf("%s/file%d", cfg.UserName(), i))
+		entry, err := dir.Put(storeData(t, cfg, []byte("data"), name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		seqs = append(seqs, entry.Sequence)
+	}
+	return seqs
+}
+
+// nextEvent returns the next event on the channel, or fails the test if
+// none arrives in time. The boolean is false if the channel is closed.
+func nextEvent(t *testing.T, events <-chan upspin.Event) (upspin.Event, bool) {
+	t.Helper()
+	select {
+	case event, ok := <-events:
+		return event, ok
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event after five seconds")
+		return upspin.Event{}, false
+	}
+}
+
+// expectNames checks that the next events on the channel are for the files
+// in order.
+func expectNames(t *testing.T, events <-chan upspin.Event, user upspin.UserName, files ...int) {
+	t.Helper()
+	for _, i := range files {
+		want := upspin.PathName(fmt.Sprintf("%s/file%d", user, i))
+		event, ok := nextEvent(t, events)
+		if !ok || event.Error != nil || event.Entry.Name != want {
+			t.Fatalf("got event %+v, %t; want one for %s", event, ok, want)
+		}
+	}
+}
+
+// expectError checks that the next event on the channel is an error
+// matching want, after which the channel is closed.
+func expectError(t *testing.T, events <-chan upspin.Event, want error) {
+	t.Helper()
+	event, ok := nextEvent(t, events)
+	if !ok || !errors.Match(want, event.Error) {
+		t.Fatalf("got event %+v, %t; want error %v", event, ok, want)
+	}
+	if _, ok := nextEvent(t, events); ok {
+		t.Fatal("channel not closed after error event")
+	}
+}
+
+func TestWatchSequence(t *testing.T) {
+	cfg, dir := setup()
+	user := cfg.UserName()
+	root := upspin.PathName(user + "/")
+	seqs := putFiles(t, cfg, dir, 0, 3)
+
+	// Events start at the given sequence.
+	done := make(chan struct{})
+	events, err := dir.Watch(root, seqs[1], done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNames(t, events, user, 1, 2)
+
+	// New events follow.
+	putFiles(t, cfg, dir, 3, 4)
+	expectNames(t, events, user, 3)
+
+	// Closing done closes the channel.
+	close(done)
+	for {
+		if _, ok := nextEvent(t, events); !ok {
+			break
+		}
+	}
+
+	// A sequence beyond the last event is invalid.
+	events, err = dir.Watch(root, seqs[2]+100, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectError(t, events, errors.E(errors.Invalid))
+}
+
+func TestWatchDiscard(t *testing.T) {
+	defer func(max int) { MaxWatchEvents = max }(MaxWatchEvents)
+	MaxWatchEvents = 3
+
+	cfg, dir := setup()
+	user := cfg.UserName()
+	root := upspin.PathName(user + "/")
+
+	// A listener that is keeping up sees every event.
+	current, err := dir.Watch(root, upspin.WatchNew, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Once it has an event, it is in place.
+	seqs := putFiles(t, cfg, dir, 0, 1)
+	expectNames(t, current, user, 0)
+	// The root and files 0 and 1 are discarded.
+	seqs = append(seqs, putFiles(t, cfg, dir, 1, 5)...)
+	expectNames(t, current, user, 1, 2, 3, 4)
+
+	// Late subscribers learn of the gap.
+	gap := errors.E(errors.Invalid, user)
+	for _, seq := range []int64{upspin.WatchStart, seqs[0], seqs[1]} {
+		events, err := dir.Watch(root, seq, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectError(t, events, gap)
+	}
+
+	// The events kept are still available.
+	events, err := dir.Watch(root, seqs[2], nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectNames(t, events, user, 2, 3, 4)
+}
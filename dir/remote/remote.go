@@ -8,10 +8,13 @@ package remote // import "upspin.io/dir/remote"
 
 import (
 	"fmt"
+	"io"
+	"time"
 
 	pb "github.com/golang/protobuf/proto"
 
 	"upspin.io/bind"
+	"upspin.io/dir/server/replica"
 	"upspin.io/errors"
 	"upspin.io/log"
 	"upspin.io/rpc"
@@ -35,10 +38,19 @@ var _ upspin.DirServer = (*remote)(nil)
 
 // Glob implements upspin.DirServer.Glob.
 func (r *remote) Glob(pattern string) ([]*upspin.DirEntry, error) {
-	op := r.opf("Glob", "%q", pattern)
+	return r.glob(r.opf("Glob", "%q", pattern), pattern, false)
+}
+
+// GlobShallow implements upspin.ShallowGlobber.
+func (r *remote) GlobShallow(pattern string) ([]*upspin.DirEntry, error) {
+	return r.glob(r.opf("GlobShallow", "%q", pattern), pattern, true)
+}
 
+// glob implements Glob and, if shallow is set, GlobShallow.
+func (r *remote) glob(op *operation, pattern string, shallow bool) ([]*upspin.DirEntry, error) {
 	req := &proto.DirGlobRequest{
 		Pattern: pattern,
+		Shallow: shallow,
 	}
 	resp := new(proto.EntriesError)
 	if err := r.Invoke("Dir/Glob", req, resp, nil, nil); err != nil {
@@ -52,9 +64,161 @@ func (r *remote) Glob(pattern string) ([]*upspin.DirEntry, error) {
 	if pErr != nil {
 		return nil, op.error(errors.IO, pErr)
 	}
+	if shallow {
+		// An older server may not know to strip the entries.
+		for _, e := range entries {
+			e.MarkIncomplete()
+		}
+	}
 	return entries, op.error(err)
 }
 
+// ApplyBatch implements upspin.DirBatcher.
+func (r *remote) ApplyBatch(changes []upspin.DirChange) ([]*upspin.DirEntry, error) {
+	op := r.opf("ApplyBatch", "%d changes", len(changes))
+
+	pc, err := dirChangeProtos(changes)
+	if err != nil {
+		return nil, op.error(err)
+	}
+	req := &proto.DirApplyBatchRequest{
+		Changes: pc,
+	}
+	resp := new(proto.EntriesError)
+	if err := r.Invoke("Dir/ApplyBatch", req, resp, nil, nil); err != nil {
+		return nil, op.error(errors.IO, err)
+	}
+	if err := unmarshalError(resp.Error); err != nil {
+		if err.Error() == upspin.ErrNotSupported.Error() {
+			return nil, upspin.ErrNotSupported
+		}
+		return nil, op.error(err)
+	}
+	entries, err := proto.UpspinDirEntries(resp.Entries)
+	if err != nil {
+		return nil, op.error(errors.IO, err)
+	}
+	return entries, nil
+}
+
+// dirChangeProtos converts changes to their protocol buffer form.
+func dirChangeProtos(changes []upspin.DirChange) ([]*proto.DirChange, error) {
+	pc := make([]*proto.DirChange, len(changes))
+	for i, c := range changes {
+		b, err := c.Entry.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		pc[i] = &proto.DirChange{
+			Delete: c.Delete,
+			Entry:  b,
+		}
+	}
+	return pc, nil
+}
+
+// PreparePut implements upspin.DirTransactor.
+func (r *remote) PreparePut(txID string, changes []upspin.DirChange) error {
+	op := r.opf("PreparePut", "%q, %d changes", txID, len(changes))
+
+	pc, err := dirChangeProtos(changes)
+	if err != nil {
+		return op.error(err)
+	}
+	req := &proto.DirPrepareRequest{
+		TxId:    txID,
+		Changes: pc,
+	}
+	resp := new(proto.EntriesError)
+	if err := r.Invoke("Dir/PreparePut", req, resp, nil, nil); err != nil {
+		return op.error(errors.IO, err)
+	}
+	return txError(op, resp.Error)
+}
+
+// CommitTx implements upspin.DirTransactor.
+func (r *remote) CommitTx(txID string) ([]*upspin.DirEntry, error) {
+	op := r.opf("CommitTx", "%q", txID)
+
+	req := &proto.DirTxRequest{
+		TxId: txID,
+	}
+	resp := new(proto.EntriesError)
+	if err := r.Invoke("Dir/CommitTx", req, resp, nil, nil); err != nil {
+		return nil, op.error(errors.IO, err)
+	}
+	if err := txError(op, resp.Error); err != nil {
+		return nil, err
+	}
+	entries, err := proto.UpspinDirEntries(resp.Entries)
+	if err != nil {
+		return nil, op.error(errors.IO, err)
+	}
+	return entries, nil
+}
+
+// AbortTx implements upspin.DirTransactor.
+func (r *remote) AbortTx(txID string) error {
+	op := r.opf("AbortTx", "%q", txID)
+
+	req := &proto.DirTxRequest{
+		TxId: txID,
+	}
+	resp := new(proto.EntriesError)
+	if err := r.Invoke("Dir/AbortTx", req, resp, nil, nil); err != nil {
+		return op.error(errors.IO, err)
+	}
+	return txError(op, resp.Error)
+}
+
+// AccessHistory implements upspin.AccessHistorian.
+func (r *remote) AccessHistory(name upspin.PathName, since time.Time, limit int) ([]upspin.AccessRecord, error) {
+	op := r.opf("AccessHistory", "%q, %v, %d", name, since, limit)
+
+	req := &proto.DirAccessHistoryRequest{
+		Name:  string(name),
+		Limit: int32(limit),
+	}
+	if !since.IsZero() {
+		req.Since = since.UnixNano()
+	}
+	resp := new(proto.DirAccessHistoryResponse)
+	if err := r.Invoke("Dir/AccessHistory", req, resp, nil, nil); err != nil {
+		return nil, op.error(errors.IO, err)
+	}
+	if err := unmarshalError(resp.Error); err != nil {
+		if err.Error() == upspin.ErrNotSupported.Error() {
+			return nil, upspin.ErrNotSupported
+		}
+		return nil, op.error(err)
+	}
+	records := make([]upspin.AccessRecord, len(resp.Records))
+	for i, rec := range resp.Records {
+		records[i] = upspin.AccessRecord{
+			Time:    time.Unix(0, rec.Time),
+			User:    upspin.UserName(rec.User),
+			Op:      rec.Op,
+			Name:    upspin.PathName(rec.Name),
+			Success: rec.Success,
+		}
+	}
+	return records, nil
+}
+
+// txError returns the error marshaled in a reply to a transaction
+// request. A server that does not implement upspin.DirTransactor reports
+// upspin.ErrNotSupported, which is returned as is.
+func txError(op *operation, b []byte) error {
+	err := unmarshalError(b)
+	if err == nil {
+		return nil
+	}
+	if err.Error() == upspin.ErrNotSupported.Error() {
+		return upspin.ErrNotSupported
+	}
+	return op.error(err)
+}
+
 func entryName(entry *upspin.DirEntry) string {
 	if entry == nil {
 		return "<nil>"
@@ -75,6 +239,20 @@ func (r *remote) Put(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
 	})
 }
 
+// PutUncheckedLink implements upspin.UncheckedLinkPutter.
+func (r *remote) PutUncheckedLink(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
+	op := r.opf("PutUncheckedLink", "%s", entryName(entry))
+
+	b, err := entry.Marshal()
+	if err != nil {
+		return nil, op.error(err)
+	}
+	return r.invoke(op, "Dir/Put", &proto.DirPutRequest{
+		Entry:         b,
+		SkipLinkCheck: true,
+	})
+}
+
 // WhichAccess implements upspin.DirServer.WhichAccess.
 func (r *remote) WhichAccess(pathName upspin.PathName) (*upspin.DirEntry, error) {
 	op := r.opf("WhichAccess", "%q", pathName)
@@ -84,6 +262,48 @@ func (r *remote) WhichAccess(pathName upspin.PathName) (*upspin.DirEntry, error)
 	})
 }
 
+// WhichAccessBatch implements upspin.WhichAccessBatcher.
+func (r *remote) WhichAccessBatch(names []upspin.PathName) ([]upspin.WhichAccessResult, error) {
+	op := r.opf("WhichAccessBatch", "%d names", len(names))
+
+	req := &proto.DirWhichAccessBatchRequest{
+		Names: make([]string, len(names)),
+	}
+	for i, name := range names {
+		req.Names[i] = string(name)
+	}
+	resp := new(proto.DirWhichAccessBatchResponse)
+	if err := r.Invoke("Dir/WhichAccessBatch", req, resp, nil, nil); err != nil {
+		return nil, op.error(errors.IO, err)
+	}
+	if err := unmarshalError(resp.Error); err != nil {
+		if err.Error() == upspin.ErrNotSupported.Error() {
+			return nil, upspin.ErrNotSupported
+		}
+		return nil, op.error(err)
+	}
+	entries, err := proto.UpspinDirEntries(resp.Entries)
+	if err != nil {
+		return nil, op.error(errors.IO, err)
+	}
+	if len(resp.Results) != len(names) {
+		return nil, op.error(errors.IO, errors.Errorf("got %d results for %d names", len(resp.Results), len(names)))
+	}
+	results := make([]upspin.WhichAccessResult, len(names))
+	for i, res := range resp.Results {
+		if res.Entry < 0 || int(res.Entry) > len(entries) {
+			return nil, op.error(errors.IO, errors.Errorf("bad entry index %d", res.Entry))
+		}
+		if res.Entry > 0 {
+			results[i].Entry = entries[res.Entry-1]
+		}
+		if err := unmarshalError(res.Error); err != nil {
+			results[i].Error = op.error(err)
+		}
+	}
+	return results, nil
+}
+
 // Delete implements upspin.DirServer.Delete.
 func (r *remote) Delete(pathName upspin.PathName) (*upspin.DirEntry, error) {
 	op := r.opf("Delete", "%q", pathName)
@@ -115,24 +335,173 @@ func (r *remote) Watch(name upspin.PathName, sequence int64, done <-chan struct{
 		Name:     string(name),
 		Sequence: sequence,
 	}
+	events, err := r.watchBidi(op, req, done)
+	if err != upspin.ErrNotSupported {
+		return events, err
+	}
+	return r.watch(op, "Dir/Watch", req, done)
+}
+
+// WatchMulti implements upspin.MultiWatcher.
+func (r *remote) WatchMulti(paths []upspin.WatchPath, done <-chan struct{}) (<-chan upspin.Event, error) {
+	op := r.opf("WatchMulti", "%d paths", len(paths))
+	req := &proto.DirWatchMultiRequest{
+		Paths: make([]*proto.DirWatchRequest, len(paths)),
+	}
+	for i, p := range paths {
+		req.Paths[i] = &proto.DirWatchRequest{
+			Name:     string(p.Name),
+			Sequence: p.Sequence,
+		}
+	}
+	return r.watch(op, "Dir/WatchMulti", req, done)
+}
 
+// watch implements Watch and WatchMulti.
+func (r *remote) watch(op *operation, method string, req pb.Message, done <-chan struct{}) (<-chan upspin.Event, error) {
 	stream := make(eventStream)
 	events := make(chan upspin.Event)
+	go relayEvents(op, stream, events, nil, done)
+
+	if err := r.Invoke(method, req, nil, stream, done); err != nil {
+		close(stream)
+		if err == upspin.ErrNotSupported {
+			return nil, err
+		}
+		return nil, op.error(err)
+	}
+	return events, nil
+}
+
+// watchPingInterval is how often a bidirectional Watch stream is pinged
+// so that idle connections are not dropped along the way.
+var watchPingInterval = 1 * time.Minute
+
+// watchBidi implements Watch over a bidirectional stream. It returns
+// upspin.ErrNotSupported if the server does not offer one.
+func (r *remote) watchBidi(op *operation, req *proto.DirWatchRequest, done <-chan struct{}) (<-chan upspin.Event, error) {
+	bs, err := r.InvokeBiStream("Dir/Watch", req)
+	if err == upspin.ErrNotSupported {
+		return nil, err
+	}
+	if err != nil {
+		return nil, op.error(err)
+	}
+	stream := make(eventStream)
 	go func() {
-		defer close(events)
+		defer stream.Close()
 		for {
+			b, err := bs.Recv()
+			if err == io.EOF {
+				return
+			}
+			if err != nil {
+				select {
+				case <-done:
+					// We closed the stream.
+				default:
+					stream.Error(err)
+				}
+				return
+			}
+			if err := stream.Send(b, done); err != nil {
+				stream.Error(err)
+				return
+			}
+		}
+	}()
+	events := make(chan upspin.Event)
+	go func() {
+		relayEvents(op, stream, events, bs, done)
+		bs.Close()
+		// Let the receiving goroutine finish.
+		for range stream {
+		}
+	}()
+	return events, nil
+}
+
+// relayEvents converts the events arriving on stream and delivers them
+// on events, which it closes when stream is closed or done is. If bs is
+// not nil it is pinged every watchPingInterval.
+func relayEvents(op *operation, stream eventStream, events chan<- upspin.Event, bs *rpc.BiStream, done <-chan struct{}) {
+	defer close(events)
+	var ping <-chan time.Time
+	if bs != nil {
+		t := time.NewTicker(watchPingInterval)
+		defer t.Stop()
+		ping = t.C
+	}
+	for {
+		select {
+		case ep, ok := <-stream:
+			if !ok {
+				return
+			}
+			e, err := proto.UpspinEvent(&ep)
+			if err != nil {
+				op.logErr(err)
+				return
+			}
 			select {
-			case ep, ok := <-stream:
+			case events <- *e:
+			case <-done:
+				return
+			}
+
+		case <-ping:
+			if err := bs.Send(&proto.DirWatchControl{}); err != nil {
+				op.logErr(err)
+				return
+			}
+
+		case <-done:
+			return
+		}
+	}
+}
+
+type eventStream chan proto.Event
+
+func (s eventStream) Send(b []byte, done <-chan struct{}) error {
+	var e proto.Event
+	if err := pb.Unmarshal(b, &e); err != nil {
+		return err
+	}
+	select {
+	case s <- e:
+	case <-done:
+	}
+	return nil
+}
+
+func (s eventStream) Close() { close(s) }
+
+func (s eventStream) Error(err error) {
+	s <- proto.Event{Error: errors.MarshalError(err)}
+}
+
+// Replicate implements replica.Source.
+func (r *remote) Replicate(states []replica.UserState, done <-chan struct{}) (<-chan replica.Update, error) {
+	op := r.opf("Replicate", "%d users", len(states))
+
+	stream := make(updateStream)
+	updates := make(chan replica.Update)
+	go func() {
+		defer close(updates)
+		for {
+			select {
+			case up, ok := <-stream:
 				if !ok {
 					return
 				}
-				e, err := proto.UpspinEvent(&ep)
+				u, err := replica.UpspinUpdate(&up)
 				if err != nil {
 					op.logErr(err)
 					return
 				}
 				select {
-				case events <- *e:
+				case updates <- *u:
 				case <-done:
 					return
 				}
@@ -143,34 +512,31 @@ func (r *remote) Watch(name upspin.PathName, sequence int64, done <-chan struct{
 		}
 	}()
 
-	if err := r.Invoke("Dir/Watch", req, nil, stream, done); err != nil {
+	if err := r.Invoke("Dir/Replicate", replica.StatesProto(states), nil, stream, done); err != nil {
 		close(stream)
-		if err == upspin.ErrNotSupported {
-			return nil, err
-		}
 		return nil, op.error(err)
 	}
-	return events, nil
+	return updates, nil
 }
 
-type eventStream chan proto.Event
+type updateStream chan proto.DirReplicaUpdate
 
-func (s eventStream) Send(b []byte, done <-chan struct{}) error {
-	var e proto.Event
-	if err := pb.Unmarshal(b, &e); err != nil {
+func (s updateStream) Send(b []byte, done <-chan struct{}) error {
+	var u proto.DirReplicaUpdate
+	if err := pb.Unmarshal(b, &u); err != nil {
 		return err
 	}
 	select {
-	case s <- e:
+	case s <- u:
 	case <-done:
 	}
 	return nil
 }
 
-func (s eventStream) Close() { close(s) }
+func (s updateStream) Close() { close(s) }
 
-func (s eventStream) Error(err error) {
-	s <- proto.Event{Error: errors.MarshalError(err)}
+func (s updateStream) Error(err error) {
+	s <- proto.DirReplicaUpdate{Error: errors.MarshalError(err)}
 }
 
 // Endpoint implements upspin.StoreServer.Endpoint.
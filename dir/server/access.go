@@ -13,7 +13,6 @@ import (
 	"upspin.io/bind"
 	"upspin.io/client/clientutil"
 	"upspin.io/errors"
-	"upspin.io/log"
 	"upspin.io/path"
 	"upspin.io/upspin"
 )
@@ -192,7 +191,7 @@ func (s *server) hasRight(right access.Right, p path.Parsed, opts ...options) (b
 			// at least temporarily. Instead of refusing all rights by
 			// returning an error, we log the error and restore default
 			// (owner-only) rights.
-			log.Error.Printf("dir/server: bad Access file %q: %v; using default rights", entry.Name, err)
+			logger.Error.Printf("dir/server: bad Access file %q: %v; using default rights", entry.Name, err)
 			acc, err = s.getDefaultAccess(p.User())
 		}
 	} else {
@@ -295,7 +294,7 @@ func (s *server) groupRefreshLoop() {
 			}
 			lastLoaded, ok := v.(lastLoad)
 			if !ok {
-				log.Error.Printf("dir/server.groupRefreshLoop: value is not of type lastLoad")
+				logger.Error.Printf("dir/server.groupRefreshLoop: value is not of type lastLoad")
 				return
 			}
 			expiration := upspin.Time(lastLoaded) + upspin.Time(remoteGroupDuration.Seconds())
@@ -320,7 +319,7 @@ type lastLoad upspin.Time
 func (l lastLoad) OnEviction(key interface{}) {
 	name, ok := key.(upspin.PathName)
 	if !ok {
-		log.Error.Printf("dir/server: key in remote group cache is not a pathname: %v", key)
+		logger.Error.Printf("dir/server: key in remote group cache is not a pathname: %v", key)
 		return
 	}
 	access.RemoveGroup(name) // ignore return, it may not have been loaded.
@@ -0,0 +1,301 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+// This file implements upspin.AccessHistorian. When enabled by the option
+// "accessHistory=true", every request is recorded, as a line of JSON, in
+// the file "current" in the "access" subdirectory of the log directory.
+// Unlike the user logs, which record only changes, it records reads too,
+// so that an owner can learn who looked at a file and when. The file is
+// rotated when it grows too large or too old, as set by the options
+// accessHistoryMaxBytes and accessHistoryMaxAge. A few rotated files are
+// kept, named for the time they were rotated; older ones are removed.
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+var _ upspin.AccessHistorian = (*server)(nil)
+
+const (
+	// accessHistoryMaxBytes is the default size at which the access
+	// history file is rotated.
+	accessHistoryMaxBytes = 64 << 20
+
+	// accessHistoryMaxAge is the default age at which the access history
+	// file is rotated.
+	accessHistoryMaxAge = 24 * time.Hour
+
+	// accessHistoryKeep is the number of rotated files kept.
+	accessHistoryKeep = 7
+
+	// accessHistoryCurrent is the name of the file being written.
+	accessHistoryCurrent = "current"
+)
+
+// accessHistory is an append-only log of the requests made to a server.
+type accessHistory struct {
+	dir      string
+	maxBytes int64
+	maxAge   time.Duration
+
+	mu      sync.Mutex // Guards the fields below.
+	file    *os.File
+	size    int64
+	started time.Time // When file was created.
+}
+
+// openAccessHistory opens the access history in dir, creating it if
+// necessary.
+func openAccessHistory(dir string, maxBytes int64, maxAge time.Duration) (*accessHistory, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	h := &accessHistory{
+		dir:      dir,
+		maxBytes: maxBytes,
+		maxAge:   maxAge,
+	}
+	if err := h.open(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// open opens the current file for appending. Called with h.mu locked,
+// or before h is shared.
+func (h *accessHistory) open() error {
+	name := filepath.Join(h.dir, accessHistoryCurrent)
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return errors.E(errors.IO, err)
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return errors.E(errors.IO, err)
+	}
+	h.file = f
+	h.size = fi.Size()
+	h.started = fi.ModTime()
+	if h.size == 0 {
+		h.started = time.Now()
+	}
+	return nil
+}
+
+// record appends a record of a request to the log. Failures are logged
+// but do not fail the request.
+func (h *accessHistory) record(user upspin.UserName, op string, name upspin.PathName, success bool) {
+	b, err := json.Marshal(upspin.AccessRecord{
+		Time:    time.Now().UTC(),
+		User:    user,
+		Op:      op,
+		Name:    name,
+		Success: success,
+	})
+	if err != nil {
+		logger.Error.Printf("dir/server: access history: %v", err)
+		return
+	}
+	b = append(b, '\n')
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.size > 0 && (h.size+int64(len(b)) > h.maxBytes || time.Since(h.started) > h.maxAge) {
+		if err := h.rotate(); err != nil {
+			logger.Error.Printf("dir/server: access history: %v", err)
+		}
+	}
+	if h.file == nil {
+		return
+	}
+	n, err := h.file.Write(b)
+	h.size += int64(n)
+	if err != nil {
+		logger.Error.Printf("dir/server: access history: %v", err)
+	}
+}
+
+// rotate renames the current file for the time now, starts a new one and
+// removes the oldest rotated files. Called with h.mu locked.
+func (h *accessHistory) rotate() error {
+	h.file.Close()
+	h.file = nil
+	rotated := filepath.Join(h.dir, time.Now().UTC().Format("20060102T150405.000000000"))
+	if err := os.Rename(filepath.Join(h.dir, accessHistoryCurrent), rotated); err != nil {
+		return errors.E(errors.IO, err)
+	}
+	if err := h.open(); err != nil {
+		return err
+	}
+	files, err := h.rotatedFiles()
+	if err != nil {
+		return err
+	}
+	for len(files) > accessHistoryKeep {
+		if err := os.Remove(files[0]); err != nil {
+			return errors.E(errors.IO, err)
+		}
+		files = files[1:]
+	}
+	return nil
+}
+
+// rotatedFiles returns the names of the rotated files, oldest first.
+func (h *accessHistory) rotatedFiles() ([]string, error) {
+	f, err := os.Open(h.dir)
+	if err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	defer f.Close()
+	names, err := f.Readdirnames(-1)
+	if err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	var files []string
+	for _, name := range names {
+		if name != accessHistoryCurrent {
+			files = append(files, filepath.Join(h.dir, name))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// query returns at most limit records, oldest first, made at or after
+// since and concerning p or anything beneath it.
+func (h *accessHistory) query(p path.Parsed, since time.Time, limit int) ([]upspin.AccessRecord, error) {
+	// Hold the lock throughout so the files are not rotated under us.
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	files, err := h.rotatedFiles()
+	if err != nil {
+		return nil, err
+	}
+	files = append(files, filepath.Join(h.dir, accessHistoryCurrent))
+	var records []upspin.AccessRecord
+	for _, file := range files {
+		var err error
+		records, err = h.scan(file, p, since, limit, records)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) >= limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+// scan appends to records those in file that match the query, stopping
+// once there are limit of them.
+func (h *accessHistory) scan(file string, p path.Parsed, since time.Time, limit int, records []upspin.AccessRecord) ([]upspin.AccessRecord, error) {
+	f, err := os.Open(file)
+	if os.IsNotExist(err) {
+		return records, nil
+	}
+	if err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() && len(records) < limit {
+		var r upspin.AccessRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			// Probably a partial line written during a crash.
+			continue
+		}
+		if r.Time.Before(since) || !concerns(r.Name, p) {
+			continue
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	return records, nil
+}
+
+// concerns reports whether a request for name, which may be a Glob
+// pattern, concerns p or anything beneath it.
+func concerns(name upspin.PathName, p path.Parsed) bool {
+	np, err := path.Parse(name)
+	if err != nil {
+		return false
+	}
+	return np.HasPrefix(p)
+}
+
+// recordAccess records a request in the access history, if it is kept,
+// and in the audit log if it was denied and the log is kept.
+// The request succeeded if *errp is nil.
+func (s *server) recordAccess(op string, errp *error, names ...upspin.PathName) {
+	if s.audit != nil && *errp != nil {
+		s.audit.denied(s.userName, op, *errp, names)
+	}
+	if s.history == nil {
+		return
+	}
+	for _, name := range names {
+		s.history.record(s.userName, op, name, *errp == nil)
+	}
+}
+
+// changeNames returns the names of the entries changed by changes.
+func changeNames(changes []upspin.DirChange) []upspin.PathName {
+	names := make([]upspin.PathName, 0, len(changes))
+	for _, c := range changes {
+		if c.Entry != nil {
+			names = append(names, c.Entry.Name)
+		}
+	}
+	return names
+}
+
+// watchNames returns the names watched by paths.
+func watchNames(paths []upspin.WatchPath) []upspin.PathName {
+	names := make([]upspin.PathName, len(paths))
+	for i, p := range paths {
+		names[i] = p.Name
+	}
+	return names
+}
+
+// AccessHistory implements upspin.AccessHistorian.
+func (s *server) AccessHistory(name upspin.PathName, since time.Time, limit int) (_ []upspin.AccessRecord, err error) {
+	const op errors.Op = "dir/server.AccessHistory"
+	defer ops.Observe("AccessHistory", time.Now(), &err)
+	defer s.recordAccess("AccessHistory", &err, name)
+
+	if s.history == nil {
+		return nil, upspin.ErrNotSupported
+	}
+	p, err := path.Parse(name)
+	if err != nil {
+		return nil, errors.E(op, name, err)
+	}
+	if p.User() != s.userName && s.userName != s.serverConfig.UserName() {
+		return nil, errors.E(op, name, errors.Permission, "only the owner may see the access history")
+	}
+	if limit <= 0 || limit > upspin.MaxAccessRecords {
+		limit = upspin.MaxAccessRecords
+	}
+	records, err := s.history.query(p, since, limit)
+	if err != nil {
+		return nil, errors.E(op, name, err)
+	}
+	return records, nil
+}
@@ -0,0 +1,139 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+func TestAccessHistory(t *testing.T) {
+	// Use a user of our own so the other tests are undisturbed.
+	const (
+		owner = "betty@flintstone.org"
+		dir   = owner + "/history"
+		file  = dir + "/file"
+		other = dir + "/other"
+	)
+	s, _ := newDirServerForTesting(t, owner)
+	sOther, _ := newDirServerForTesting(t, otherUser)
+	if _, err := makeDirectory(s, owner+"/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.AccessHistory(file, time.Time{}, 0); err != upspin.ErrNotSupported {
+		t.Fatalf("AccessHistory without history: err = %v, want ErrNotSupported", err)
+	}
+
+	histDir, err := os.MkdirTemp("", "DirServerAccessHistory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(histDir)
+	// Small enough that the file is rotated a few times.
+	h, err := openAccessHistory(histDir, 500, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.history = h
+	sOther.history = h
+
+	start := time.Now()
+	if _, err := makeDirectory(s, dir); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []upspin.PathName{file, other} {
+		if _, err := s.Put(&upspin.DirEntry{
+			Name:       name,
+			SignedName: name,
+			Writer:     owner,
+			Packing:    upspin.PlainPack,
+			Attr:       upspin.AttrNone,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Lookup(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sOther.Lookup(file); !errors.Is(errors.Private, err) {
+		t.Fatalf("Lookup by other user: err = %v, want Private", err)
+	}
+	if _, err := s.Glob(dir + "/*"); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []upspin.AccessRecord{
+		{User: owner, Op: "Put", Name: file, Success: true},
+		{User: owner, Op: "Lookup", Name: file, Success: true},
+		{User: otherUser, Op: "Lookup", Name: file, Success: false},
+	}
+	got, err := s.AccessHistory(file, start, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, "history of file", got, want, start)
+
+	// A directory's history includes its own and everything beneath
+	// it, including the query just made.
+	got, err = s.AccessHistory(dir, start, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 7 {
+		t.Errorf("history of directory has %d records, want 7: %v", len(got), got)
+	}
+
+	// The limit is honored, oldest first.
+	got, err = s.AccessHistory(file, start, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, "limited history", got, want[:2], start)
+
+	// Nothing is recorded before it happened.
+	got, err = s.AccessHistory(file, time.Now().Add(time.Hour), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 {
+		t.Errorf("history from the future = %v, want none", got)
+	}
+
+	// Only the owner may see the history.
+	if _, err := sOther.AccessHistory(file, start, 0); !errors.Is(errors.Permission, err) {
+		t.Errorf("AccessHistory by other user: err = %v, want Permission", err)
+	}
+
+	// The file was rotated, but only a few old ones are kept.
+	files, err := filepath.Glob(filepath.Join(histDir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) < 2 || len(files) > accessHistoryKeep+1 {
+		t.Errorf("found %d history files, want between 2 and %d", len(files), accessHistoryKeep+1)
+	}
+}
+
+func checkRecords(t *testing.T, what string, got, want []upspin.AccessRecord, start time.Time) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("%s: got %d records, want %d: %v", what, len(got), len(want), got)
+	}
+	for i, r := range got {
+		if r.Time.Before(start) || r.Time.After(time.Now()) {
+			t.Errorf("%s: record %d at %v, not since %v", what, i, r.Time, start)
+		}
+		r.Time = time.Time{}
+		if r != want[i] {
+			t.Errorf("%s: record %d = %+v, want %+v", what, i, r, want[i])
+		}
+	}
+}
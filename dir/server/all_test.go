@@ -8,7 +8,9 @@ package server
 // by using testenv or something similar.
 
 import (
+	"fmt"
 	"os"
+	"reflect"
 	"sync"
 	"testing"
 	"time"
@@ -19,10 +21,12 @@ import (
 	"upspin.io/dir/server/serverlog"
 	"upspin.io/errors"
 	"upspin.io/factotum"
+	"upspin.io/metric"
 	"upspin.io/pack"
 	"upspin.io/path"
 	"upspin.io/test/testutil"
 	"upspin.io/upspin"
+	"upspin.io/upspin/proto"
 
 	_ "upspin.io/pack/ee"
 	_ "upspin.io/pack/eeintegrity"
@@ -147,6 +151,33 @@ func TestPut(t *testing.T) {
 	}
 }
 
+func TestOps(t *testing.T) {
+	s, _ := newDirServerForTesting(t, userName)
+	lookupOK := ops.Count("Lookup", metric.OK)
+	lookupNotExist := ops.Count("Lookup", metric.NotExist)
+	globOK := ops.Count("Glob", metric.OK)
+
+	if _, err := s.Lookup(userName + "/file1.txt"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Lookup(userName + "/nothere.txt"); !errors.Is(errors.NotExist, err) {
+		t.Fatalf("Lookup of missing file: err = %v, want NotExist", err)
+	}
+	if _, err := s.Glob(userName + "/*"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := ops.Count("Lookup", metric.OK) - lookupOK; got != 1 {
+		t.Errorf("Lookup ok count moved by %d, want 1", got)
+	}
+	if got := ops.Count("Lookup", metric.NotExist) - lookupNotExist; got != 1 {
+		t.Errorf("Lookup not-exist count moved by %d, want 1", got)
+	}
+	if got := ops.Count("Glob", metric.OK) - globOK; got != 1 {
+		t.Errorf("Glob ok count moved by %d, want 1", got)
+	}
+}
+
 func TestMakeDirectory(t *testing.T) {
 	s, _ := newDirServerForTesting(t, userName)
 	de, err := makeDirectory(s, userName+"/dir")
@@ -276,6 +307,293 @@ func TestLink(t *testing.T) {
 	// around for other tests, sadly).
 }
 
+func TestLinkTargetCheck(t *testing.T) {
+	s, _ := newDirServerForTesting(t, userName)
+	link := func(name, target upspin.PathName) *upspin.DirEntry {
+		return &upspin.DirEntry{
+			Name:       name,
+			SignedName: name,
+			Attr:       upspin.AttrLink,
+			Writer:     userName,
+			Link:       target,
+			Packing:    upspin.PlainPack,
+		}
+	}
+	if _, err := makeDirectory(s, userName+"/linktarget"); err != nil {
+		t.Fatal(err)
+	}
+	// Leave the tree as we found it for the tests that follow.
+	defer func() {
+		for _, name := range []upspin.PathName{"linktarget", "goodlink", "brokenlink", "brokenlink2", "linklink", "otherlink"} {
+			if _, err := s.Delete(userName + "/" + name); err != nil {
+				t.Error(err)
+			}
+		}
+	}()
+
+	for _, test := range []struct {
+		name, target upspin.PathName
+		ok           bool
+	}{
+		// A target in the same tree must exist.
+		{userName + "/goodlink", userName + "/linktarget", true},
+		{userName + "/brokenlink", userName + "/nonexistent", false},
+		{userName + "/brokenlink2", userName + "/nonexistent/file", false},
+		// A target reached through a link is accepted.
+		{userName + "/linklink", userName + "/goodlink/foo", true},
+		// A target in another user's tree is not looked up.
+		{userName + "/otherlink", "linkerdude@linkatron.lnk/target", true},
+	} {
+		_, err := s.Put(link(test.name, test.target))
+		if test.ok {
+			if err != nil {
+				t.Errorf("Put(%s -> %s): %v", test.name, test.target, err)
+			}
+			continue
+		}
+		if !errors.Is(errors.BrokenLink, err) {
+			t.Errorf("Put(%s -> %s): err = %v, want BrokenLink", test.name, test.target, err)
+		}
+		// PutUncheckedLink creates it anyway.
+		if _, err := s.PutUncheckedLink(link(test.name, test.target)); err != nil {
+			t.Errorf("PutUncheckedLink(%s -> %s): %v", test.name, test.target, err)
+		}
+	}
+}
+
+func TestWatchMulti(t *testing.T) {
+	s, _ := newDirServerForTesting(t, userName)
+	var (
+		dir1 = upspin.PathName(userName + "/watchmulti1")
+		dir2 = upspin.PathName(userName + "/watchmulti2")
+		dir3 = upspin.PathName(userName + "/watchmulti3")
+	)
+	put := func(name upspin.PathName) int64 {
+		e, err := s.Put(&upspin.DirEntry{
+			Name:       name,
+			SignedName: name,
+			Attr:       upspin.AttrNone,
+			Writer:     userName,
+			Packing:    upspin.PlainPack,
+			Sequence:   upspin.SeqIgnore,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return e.Sequence
+	}
+	var created []upspin.PathName
+	defer func() {
+		for i := len(created) - 1; i >= 0; i-- {
+			if _, err := s.Delete(created[i]); err != nil {
+				t.Errorf("cleanup: %v", err)
+			}
+		}
+	}()
+	for _, dir := range []upspin.PathName{dir1, dir2, dir3} {
+		if _, err := makeDirectory(s, dir); err != nil {
+			t.Fatal(err)
+		}
+		created = append(created, dir)
+	}
+	put(dir1 + "/a")
+	put(dir3 + "/a")
+	seq2 := put(dir2 + "/a")
+	created = append(created, dir1+"/a", dir3+"/a", dir2+"/a")
+
+	if _, err := s.WatchMulti([]upspin.WatchPath{{Name: dir1}, {Name: dir1}}, nil); !errors.Is(errors.Invalid, err) {
+		t.Errorf("WatchMulti with duplicate paths: err = %v, want Invalid", err)
+	}
+
+	// Each path is watched from a different sequence.
+	done := make(chan struct{})
+	defer close(done)
+	events, err := s.WatchMulti([]upspin.WatchPath{
+		{Name: dir1, Sequence: upspin.WatchCurrent},
+		{Name: dir2, Sequence: seq2},
+		{Name: dir3, Sequence: upspin.WatchNew},
+	}, done)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []upspin.PathName{dir3, dir1, dir2} {
+		put(dir + "/b")
+		created = append(created, dir+"/b")
+	}
+
+	want := map[upspin.PathName][]upspin.PathName{
+		dir1: {dir1, dir1 + "/a", dir1 + "/b"},
+		dir2: {dir2 + "/a", dir2 + "/b"},
+		dir3: {dir3 + "/b"},
+	}
+	got := make(map[upspin.PathName][]upspin.PathName)
+	for n := 0; n < 6; n++ {
+		select {
+		case e := <-events:
+			if e.Error != nil {
+				t.Fatalf("event for %s: %v", e.Root, e.Error)
+			}
+			got[e.Root] = append(got[e.Root], e.Entry.Name)
+		case <-time.After(time.Minute):
+			t.Fatalf("timed out waiting for events; got %v", got)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("events = %v, want %v", got, want)
+	}
+}
+
+func TestApplyBatch(t *testing.T) {
+	s, _ := newDirServerForTesting(t, userName)
+	var (
+		oldDir = upspin.PathName(userName + "/batchold")
+		newDir = upspin.PathName(userName + "/batchnew")
+		names  = []string{"", "/a", "/b", "/sub", "/sub/c"}
+	)
+	entry := func(name upspin.PathName, isDir bool) *upspin.DirEntry {
+		e := &upspin.DirEntry{
+			Name:       name,
+			SignedName: name,
+			Attr:       upspin.AttrNone,
+			Writer:     userName,
+			Packing:    upspin.PlainPack,
+			Sequence:   upspin.SeqIgnore,
+		}
+		if isDir {
+			e.Attr = upspin.AttrDirectory
+		}
+		return e
+	}
+	for _, n := range names {
+		if _, err := s.Put(entry(oldDir+upspin.PathName(n), n == "" || n == "/sub")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A concurrent reader must see the old tree or the new, never a mix.
+	// Each pass finds the one tree and looks up its descendants, and is
+	// checked only if the sequence number of the root shows that no
+	// change was made while it ran, so that it saw a single snapshot.
+	stop := make(chan struct{})
+	readerErr := make(chan error, 1)
+	go func() {
+		var err error
+		defer func() { readerErr <- err }()
+		seq := func() (int64, error) {
+			root, err := s.Lookup(userName + "/")
+			if err != nil {
+				return 0, err
+			}
+			return root.Sequence, nil
+		}
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			var before, after int64
+			if before, err = seq(); err != nil {
+				return
+			}
+			var entries []*upspin.DirEntry
+			entries, err = s.Glob(userName + "/batch*")
+			if err != nil {
+				return
+			}
+			var missing []upspin.PathName
+			if len(entries) == 1 {
+				for _, n := range names[1:] {
+					name := entries[0].Name + upspin.PathName(n)
+					if _, lerr := s.Lookup(name); lerr != nil {
+						missing = append(missing, name)
+					}
+				}
+			}
+			if after, err = seq(); err != nil {
+				return
+			}
+			if before != after {
+				continue // Not a single snapshot.
+			}
+			if len(entries) != 1 {
+				err = errors.Errorf("saw %d trees, want 1", len(entries))
+				return
+			}
+			if len(missing) > 0 {
+				err = errors.Errorf("saw %s without %s", entries[0].Name, missing)
+				return
+			}
+		}
+	}()
+
+	// A batch that cannot be applied in full changes nothing.
+	_, err := s.ApplyBatch([]upspin.DirChange{
+		{Entry: entry(newDir, true)},
+		{Delete: true, Entry: entry(oldDir+"/nonexistent", false)},
+	})
+	if !errors.Is(errors.NotExist, err) {
+		t.Errorf("ApplyBatch with missing file: err = %v, want NotExist", err)
+	}
+	if _, err := s.Lookup(newDir); !errors.Is(errors.NotExist, err) {
+		t.Errorf("Lookup(%q) after failed batch: err = %v, want NotExist", newDir, err)
+	}
+	_, err = s.ApplyBatch([]upspin.DirChange{
+		{Entry: entry(newDir, true)},
+		{Entry: entry(otherUser+"/file", false)},
+	})
+	if !errors.Is(errors.Invalid, err) {
+		t.Errorf("ApplyBatch across trees: err = %v, want Invalid", err)
+	}
+	_, err = s.ApplyBatch([]upspin.DirChange{{Delete: true, Entry: entry(userName+"/", true)}})
+	if !errors.Is(errors.Invalid, err) {
+		t.Errorf("ApplyBatch of root: err = %v, want Invalid", err)
+	}
+	_, err = s.ApplyBatch(make([]upspin.DirChange, upspin.MaxBatchChanges+1))
+	if !errors.Is(errors.Invalid, err) {
+		t.Errorf("ApplyBatch of too many changes: err = %v, want Invalid", err)
+	}
+
+	// Rename the old tree to the new, many times over.
+	rename := func(from, to upspin.PathName) {
+		var changes []upspin.DirChange
+		for _, n := range names {
+			changes = append(changes, upspin.DirChange{Entry: entry(to+upspin.PathName(n), n == "" || n == "/sub")})
+		}
+		for i := len(names) - 1; i >= 0; i-- {
+			changes = append(changes, upspin.DirChange{Delete: true, Entry: entry(from+upspin.PathName(names[i]), false)})
+		}
+		entries, err := s.ApplyBatch(changes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != len(changes) {
+			t.Fatalf("ApplyBatch returned %d entries, want %d", len(entries), len(changes))
+		}
+	}
+	for i := 0; i < 10; i++ {
+		rename(oldDir, newDir)
+		rename(newDir, oldDir)
+	}
+	rename(oldDir, newDir)
+	close(stop)
+	if err := <-readerErr; err != nil {
+		t.Fatalf("concurrent reader: %v", err)
+	}
+
+	if _, err := s.Lookup(oldDir); !errors.Is(errors.NotExist, err) {
+		t.Errorf("Lookup(%q): err = %v, want NotExist", oldDir, err)
+	}
+	if _, err := s.Lookup(newDir + "/sub/c"); err != nil {
+		t.Errorf("Lookup(%q): %v", newDir+"/sub/c", err)
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		if _, err := s.Delete(newDir + upspin.PathName(names[i])); err != nil {
+			t.Errorf("cleanup: %v", err)
+		}
+	}
+}
+
 func TestWhichAccess(t *testing.T) {
 	const accessFile = "*: " + userName
 	s, userCtx := newDirServerForTesting(t, userName)
@@ -333,6 +651,63 @@ func TestWhichAccess(t *testing.T) {
 	}
 }
 
+func TestWhichAccessBatch(t *testing.T) {
+	names := []upspin.PathName{
+		userName + "/",
+		userName + "/dir",
+		userName + "/dir/Access",
+		userName + "/mylink",
+		userName + "/mylink/file",
+		userName + "/nonexistent",
+		userName + "/dir/nonexistent/deeper",
+		userName + "/dir",
+	}
+	// The batch must match WhichAccess for each name, for the owner
+	// and for a user with no rights.
+	for _, user := range []upspin.UserName{userName, otherUser} {
+		s, _ := newDirServerForTesting(t, user)
+		results, err := s.WhichAccessBatch(names)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(results) != len(names) {
+			t.Fatalf("%s: got %d results, want %d", user, len(results), len(names))
+		}
+		for i, name := range names {
+			want, wantErr := s.WhichAccess(name)
+			got := results[i]
+			if !reflect.DeepEqual(got.Entry, want) || !sameErrorIgnoringOp(got.Error, wantErr) {
+				t.Errorf("%s: WhichAccessBatch(%q) = %v, %v; want %v, %v", user, name, got.Entry, got.Error, want, wantErr)
+			}
+		}
+		if user == userName && results[0].Entry != results[5].Entry {
+			t.Errorf("names controlled by the same Access file do not share an entry")
+		}
+	}
+
+	s, _ := newDirServerForTesting(t, userName)
+	_, err := s.WhichAccessBatch(make([]upspin.PathName, upspin.MaxWhichAccessBatch+1))
+	if !errors.Is(errors.Invalid, err) {
+		t.Errorf("WhichAccessBatch of too many names: err = %v, want Invalid", err)
+	}
+}
+
+// sameErrorIgnoringOp reports whether a and b are equal apart from
+// the operation that reported them.
+func sameErrorIgnoringOp(a, b error) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	ae, aok := a.(*errors.Error)
+	be, bok := b.(*errors.Error)
+	if !aok || !bok {
+		return a.Error() == b.Error()
+	}
+	x, y := *ae, *be
+	x.Op, y.Op = "", ""
+	return x.Error() == y.Error()
+}
+
 func TestHasRight(t *testing.T) {
 	const accessFile = "l,d: " + userName
 	s, userCtx := newDirServerForTesting(t, userName)
@@ -641,6 +1016,158 @@ func TestGlob(t *testing.T) {
 	}
 }
 
+// TestGlobListingCache checks that listings served from the cache are
+// up to date and filtered for each requesting user.
+func TestGlobListingCache(t *testing.T) {
+	const dir = userName + "/listing"
+	sOwner, ownerCtx := newDirServerForTesting(t, userName)
+	s, _ := newDirServerForTesting(t, otherUser)
+	if _, err := makeDirectory(sOwner, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := putAccessOrGroupFile(t, sOwner, ownerCtx, dir+"/Access", "*:"+userName+"\nl:"+otherUser); err != nil {
+		t.Fatal(err)
+	}
+	putFile := func(name upspin.PathName) {
+		t.Helper()
+		_, err := sOwner.Put(&upspin.DirEntry{
+			Name:       name,
+			SignedName: name,
+			Writer:     userName,
+			Packing:    upspin.PlainPack,
+			Blocks: []upspin.DirBlock{{
+				Location: writeToStore(t, ownerCtx, []byte("data")),
+				Size:     4,
+			}},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// check globs the directory and checks the number of files
+	// and how many of them have blocks.
+	check := func(s *server, wantN, wantBlocks int) {
+		t.Helper()
+		ents, err := s.Glob(dir + "/*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		n, withBlocks := 0, 0
+		for _, e := range ents {
+			if access.IsAccessFile(e.Name) {
+				continue
+			}
+			n++
+			if len(e.Blocks) > 0 {
+				withBlocks++
+			}
+		}
+		if n != wantN || withBlocks != wantBlocks {
+			t.Errorf("%s: %d files, %d with blocks; want %d, %d", s.userName, n, withBlocks, wantN, wantBlocks)
+		}
+	}
+
+	putFile(dir + "/file1")
+	// The owner's Glob flushes the directory and caches the listing,
+	// which is then served alternately to a user without Read rights,
+	// who sees no blocks, and to the owner, who does.
+	check(sOwner, 1, 1)
+	hits := listingHits.Value()
+	check(s, 1, 0)
+	check(sOwner, 1, 1)
+	check(s, 1, 0)
+	if got := listingHits.Value() - hits; got != 3 {
+		t.Errorf("listing cache hits = %d, want 3", got)
+	}
+
+	// Changes to the directory are seen at once.
+	putFile(dir + "/file2")
+	check(s, 2, 0)
+	check(sOwner, 2, 2)
+	if _, err := sOwner.Delete(dir + "/file1"); err != nil {
+		t.Fatal(err)
+	}
+	check(sOwner, 1, 1)
+	check(s, 1, 0)
+}
+
+func TestGlobShallow(t *testing.T) {
+	const dir = userName + "/shallow"
+	s, ctx := newDirServerForTesting(t, userName)
+	if _, err := makeDirectory(s, dir); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		name := upspin.PathName(fmt.Sprintf("%s/file%d", dir, i))
+		var blocks []upspin.DirBlock
+		for j := 0; j < 20; j++ {
+			blocks = append(blocks, upspin.DirBlock{
+				Location: writeToStore(t, ctx, []byte(fmt.Sprintf("block %d of %s", j, name))),
+				Offset:   int64(j) * 100,
+				Size:     100,
+				Packdata: []byte("block packdata"),
+			})
+		}
+		_, err := s.Put(&upspin.DirEntry{
+			Name:       name,
+			SignedName: name,
+			Writer:     userName,
+			Packing:    upspin.PlainPack,
+			Packdata:   []byte("entry packdata"),
+			Blocks:     blocks,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	full, err := s.Glob(dir + "/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	shallow, err := s.GlobShallow(dir + "/*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shallow) != len(full) {
+		t.Fatalf("GlobShallow returned %d entries, Glob %d", len(shallow), len(full))
+	}
+	for i, e := range shallow {
+		if e.Name != full[i].Name || e.Attr&^upspin.AttrIncomplete != full[i].Attr || e.Sequence != full[i].Sequence {
+			t.Errorf("shallow entry %d is %v; want names and attributes of %v", i, e, full[i])
+		}
+		if !e.IsIncomplete() || len(e.Blocks) != 0 || len(e.Packdata) != 0 {
+			t.Errorf("shallow entry %s is complete", e.Name)
+		}
+	}
+	// The shallow reply is much smaller.
+	fullBytes, err := proto.DirEntryBytes(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	shallowBytes, err := proto.DirEntryBytes(shallow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fullSize, shallowSize int
+	for i := range fullBytes {
+		fullSize += len(fullBytes[i])
+		shallowSize += len(shallowBytes[i])
+	}
+	if shallowSize*10 > fullSize {
+		t.Errorf("shallow entries take %d bytes, full entries %d; want a tenth or less", shallowSize, fullSize)
+	}
+
+	// Names without metacharacters are looked up and stripped too.
+	one, err := s.GlobShallow(dir + "/file0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(one) != 1 || !one[0].IsIncomplete() {
+		t.Errorf("GlobShallow of one file = %v, want one incomplete entry", one)
+	}
+}
+
 func TestDeletePermission(t *testing.T) {
 	s, userCtx := newDirServerForTesting(t, userName)
 	sOther, _ := newDirServerForTesting(t, otherUser)
@@ -938,6 +1465,56 @@ func TestPutBadGroup(t *testing.T) {
 	}
 }
 
+func TestPutAttributes(t *testing.T) {
+	const (
+		dir  = userName + "/attributes"
+		file = dir + "/file"
+	)
+	s, userCtx := newDirServerForTesting(t, userName)
+	sOther, _ := newDirServerForTesting(t, otherUser)
+	if _, err := makeDirectory(s, dir); err != nil {
+		t.Fatal(err)
+	}
+	// The other user may only list the directory.
+	if _, err := putAccessOrGroupFile(t, s, userCtx, dir+"/Access", "*:"+userName+"\nl:"+otherUser); err != nil {
+		t.Fatal(err)
+	}
+	de := &upspin.DirEntry{
+		Name:       file,
+		SignedName: file,
+		Attr:       upspin.AttrNone,
+		Writer:     userName,
+		Packing:    upspin.PlainPack,
+		Attributes: map[string][]byte{"content-type": []byte("text/plain")},
+	}
+	if _, err := s.Put(de); err != nil {
+		t.Fatal(err)
+	}
+	got, err := s.Lookup(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got.Attributes, de.Attributes) {
+		t.Errorf("Lookup: Attributes = %q, want %q", got.Attributes, de.Attributes)
+	}
+	entries, err := s.Glob(dir + "/f*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !reflect.DeepEqual(entries[0].Attributes, de.Attributes) {
+		t.Errorf("Glob: got %+v, want one entry with Attributes %q", entries, de.Attributes)
+	}
+
+	// Those who cannot read the file do not see its attributes.
+	got, err = sOther.Lookup(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !got.IsIncomplete() || got.Attributes != nil {
+		t.Errorf("Lookup by other user: got %+v, want incomplete entry without attributes", got)
+	}
+}
+
 func TestMain(m *testing.M) {
 	var err error
 	testDir, err = os.MkdirTemp("", "DirServer")
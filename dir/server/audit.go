@@ -0,0 +1,159 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+// This file implements the audit log of denied requests. When enabled by
+// the option "auditLog=<file>", each request refused because the user
+// lacks the rights to make it, with a Permission or Private (information
+// withheld) error, is recorded as a line of JSON appended to the file.
+// Successful requests, and other failures, are never recorded, nor is
+// anything of the contents of files. With the option
+// "auditHashPaths=true", paths are recorded only as their SHA-256 hashes.
+//
+// To keep a persistent prober from flooding the log, the denials of a
+// user's requests for a path are recorded at most once per auditInterval;
+// the next record counts those suppressed in between.
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"upspin.io/cache"
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+const (
+	// auditInterval is the minimum time between records of the denials
+	// of a user's requests for a path.
+	auditInterval = time.Minute
+
+	// auditCacheSize is the number of (user, path) pairs whose last
+	// record is remembered for rate limiting.
+	auditCacheSize = 10000
+)
+
+// AuditRecord is a record in the audit log of denied requests.
+type AuditRecord struct {
+	Time time.Time       `json:"time"`
+	User upspin.UserName `json:"user"`
+	Op   string          `json:"op"` // Name of the DirServer method.
+
+	// Exactly one of Path and PathHash is set, the latter, the hex
+	// encoded SHA-256 hash of the path, if paths are hashed.
+	Path     upspin.PathName `json:"path,omitempty"`
+	PathHash string          `json:"pathHash,omitempty"`
+
+	// Kind is "permission" if the request was refused or "private" if
+	// the information requested was withheld.
+	Kind string `json:"kind"`
+
+	// Suppressed is the number of denials of the same user's requests
+	// for the path that were not recorded since the previous record.
+	Suppressed int `json:"suppressed,omitempty"`
+}
+
+// auditLog is an append-only log of the requests denied by a server.
+type auditLog struct {
+	hashPaths bool
+	now       func() time.Time
+
+	mu     sync.Mutex // Guards the fields below.
+	file   *os.File
+	recent *cache.LRU // Of auditKey to *auditEntry.
+}
+
+type auditKey struct {
+	user upspin.UserName
+	path upspin.PathName
+}
+
+type auditEntry struct {
+	last       time.Time // Time of the last record.
+	suppressed int       // Denials since then.
+}
+
+// openAuditLog opens the named audit log for appending, creating it if
+// necessary.
+func openAuditLog(name string, hashPaths bool) (*auditLog, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	return &auditLog{
+		hashPaths: hashPaths,
+		now:       time.Now,
+		file:      f,
+		recent:    cache.NewLRU(auditCacheSize),
+	}, nil
+}
+
+// denied records err, the result of a request by user, if it denied the
+// request for want of rights. The names are those the request concerned;
+// if err names a path, only that one is recorded.
+func (a *auditLog) denied(user upspin.UserName, op string, err error, names []upspin.PathName) {
+	var kind string
+	switch {
+	case errors.Is(errors.Permission, err):
+		kind = "permission"
+	case errors.Is(errors.Private, err):
+		kind = "private"
+	default:
+		return
+	}
+	if e, ok := err.(*errors.Error); ok && e.Path != "" {
+		names = []upspin.PathName{e.Path}
+	}
+	for _, name := range names {
+		a.record(user, op, name, kind)
+	}
+}
+
+// record appends a record of a denial to the log, unless the user's
+// requests for the path were recorded too recently. Failures are logged
+// but do not affect the request.
+func (a *auditLog) record(user upspin.UserName, op string, name upspin.PathName, kind string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	now := a.now()
+	key := auditKey{user, name}
+	var suppressed int
+	if v, ok := a.recent.Get(key); ok {
+		e := v.(*auditEntry)
+		if now.Sub(e.last) < auditInterval {
+			e.suppressed++
+			return
+		}
+		suppressed = e.suppressed
+	}
+	a.recent.Add(key, &auditEntry{last: now})
+
+	r := AuditRecord{
+		Time:       now.UTC(),
+		User:       user,
+		Op:         op,
+		Kind:       kind,
+		Suppressed: suppressed,
+	}
+	if a.hashPaths {
+		sum := sha256.Sum256([]byte(name))
+		r.PathHash = hex.EncodeToString(sum[:])
+	} else {
+		r.Path = name
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		logger.Error.Printf("dir/server: audit log: %v", err)
+		return
+	}
+	if _, err := a.file.Write(append(b, '\n')); err != nil {
+		logger.Error.Printf("dir/server: audit log: %v", err)
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+func TestAuditLog(t *testing.T) {
+	const (
+		owner   = userName
+		dir     = owner + "/audit"
+		file    = dir + "/file"
+		private = dir + "/private"
+		denied  = private + "/new"
+	)
+	s, ownerCfg := newDirServerForTesting(t, owner)
+	sOther, _ := newDirServerForTesting(t, otherUser)
+
+	auditFile := filepath.Join(t.TempDir(), "audit")
+	a, err := openAuditLog(auditFile, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	a.now = func() time.Time { return now }
+	s.audit = a
+	sOther.audit = a
+
+	// The root exists if other tests have run.
+	if _, err := makeDirectory(s, owner+"/"); err != nil && !errors.Is(errors.Exist, err) {
+		t.Fatal(err)
+	}
+	if _, err := makeDirectory(s, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := makeDirectory(s, private); err != nil {
+		t.Fatal(err)
+	}
+	// The other user may read the directory but nothing beneath private.
+	if _, err := putAccessOrGroupFile(t, s, ownerCfg, dir+"/Access", "*:"+owner+"\nr,l:"+otherUser); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := putAccessOrGroupFile(t, s, ownerCfg, private+"/Access", "*:"+owner); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Put(&upspin.DirEntry{
+		Name:       file,
+		SignedName: file,
+		Writer:     owner,
+		Packing:    upspin.PlainPack,
+		Attr:       upspin.AttrNone,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Allowed requests are not recorded.
+	if _, err := sOther.Lookup(file); err != nil {
+		t.Fatal(err)
+	}
+	// Nor are failures other than denials.
+	if _, err := sOther.Lookup(dir + "/missing"); !errors.Is(errors.NotExist, err) {
+		t.Fatalf("Lookup of missing file: err = %v, want NotExist", err)
+	}
+
+	// The other user may not learn what is in private, nor write there.
+	for i := 0; i < 3; i++ {
+		if _, err := sOther.Lookup(denied); !errors.Is(errors.Private, err) {
+			t.Fatalf("Lookup in private: err = %v, want Private", err)
+		}
+	}
+	if _, err := sOther.Put(&upspin.DirEntry{
+		Name:       file,
+		SignedName: file,
+		Writer:     otherUser,
+		Packing:    upspin.PlainPack,
+		Attr:       upspin.AttrNone,
+	}); !errors.Is(errors.Permission, err) {
+		t.Fatalf("Put by other user: err = %v, want Permission", err)
+	}
+
+	// Once the interval has passed, the next denial is recorded with
+	// the count of those suppressed.
+	now = now.Add(auditInterval)
+	if _, err := sOther.Lookup(denied); !errors.Is(errors.Private, err) {
+		t.Fatalf("Lookup in private: err = %v, want Private", err)
+	}
+
+	want := []AuditRecord{
+		{User: otherUser, Op: "Lookup", Path: denied, Kind: "private"},
+		{User: otherUser, Op: "Put", Path: file, Kind: "permission"},
+		{User: otherUser, Op: "Lookup", Path: denied, Kind: "private", Suppressed: 2},
+	}
+	checkAuditRecords(t, auditFile, want)
+
+	// With hashing, the paths do not appear.
+	hashFile := filepath.Join(t.TempDir(), "audit")
+	a, err = openAuditLog(hashFile, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sOther.audit = a
+	if _, err := sOther.Lookup(denied); !errors.Is(errors.Private, err) {
+		t.Fatalf("Lookup in private: err = %v, want Private", err)
+	}
+	sum := sha256.Sum256([]byte(denied))
+	checkAuditRecords(t, hashFile, []AuditRecord{
+		{User: otherUser, Op: "Lookup", PathHash: hex.EncodeToString(sum[:]), Kind: "private"},
+	})
+}
+
+func checkAuditRecords(t *testing.T, file string, want []AuditRecord) {
+	t.Helper()
+	f, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var got []AuditRecord
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r AuditRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("bad record %q: %v", scanner.Bytes(), err)
+		}
+		if r.Time.IsZero() {
+			t.Errorf("record %+v has no time", r)
+		}
+		r.Time = time.Time{}
+		got = append(got, r)
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(got), len(want), got)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("record %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
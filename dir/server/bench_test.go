@@ -7,6 +7,7 @@ package server
 import (
 	"fmt"
 	"os"
+	"runtime"
 	"testing"
 
 	"upspin.io/cache"
@@ -211,6 +212,54 @@ func benchmarkWhichAccess(b *testing.B, cached bool, dir upspin.PathName, access
 	}
 }
 
+// The Glob benchmarks have 100 clients globbing a directory of 100
+// entries at once. Without caching, each Glob's listing is purged from
+// the cache before the next.
+func BenchmarkGlobParallelNotCached(b *testing.B) {
+	benchmarkGlobParallel(b, !cached)
+}
+
+func BenchmarkGlobParallelCached(b *testing.B) {
+	benchmarkGlobParallel(b, cached)
+}
+
+func benchmarkGlobParallel(b *testing.B, cached bool) {
+	const (
+		globbers = 100
+		entries  = 100
+	)
+	b.StopTimer()
+	s, _, cleanup := setupBenchServer(b)
+	defer cleanup()
+	dir := userName + "/" + mkName()
+	mkAll(b, s, dir)
+	for i := 0; i < entries; i++ {
+		makeDirectory(s, dir+"/"+mkName())
+	}
+	pattern := string(dir) + "/*"
+	if _, err := s.Glob(pattern); err != nil { // Flush the tree.
+		b.Fatal(err)
+	}
+	procs := runtime.GOMAXPROCS(0)
+	b.SetParallelism((globbers + procs - 1) / procs)
+	b.StartTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ents, err := s.Glob(pattern)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if len(ents) != entries {
+				b.Fatalf("got %d entries, want %d", len(ents), entries)
+			}
+			if !cached {
+				s.listings.purge()
+			}
+		}
+	})
+}
+
 // setupBenchServer sets up the benchmark tests and returns the server to use,
 // the user's config and a clean up function to use after benchmarks are run.
 func setupBenchServer(t testing.TB) (*server, upspin.Config, func()) {
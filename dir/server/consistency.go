@@ -0,0 +1,194 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"upspin.io/dir/server/serverlog"
+	"upspin.io/errors"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+// ConsistencyCheck compares the tree of the user as served by live with
+// the tree obtained by replaying the user's logs in logDir from the
+// start. It returns a description of each difference found, sorted by
+// path name: an entry in one tree but not the other, or an entry whose
+// sequence number differs. Any difference indicates a bug in the
+// maintenance of the tree or a tree out of step with its logs.
+//
+// Live should be a DirServer for the logs in logDir, dialed as the user
+// so that it lists every entry, and no other server should be using the
+// logs. The logs must end at their checkpoint, as they do once a server
+// has been stopped cleanly, and must not have been pruned. The logs are
+// only read.
+//
+// The contents of a directory put with existing blocks, as snapshots
+// are, are not in the logs, so entries beneath one that are missing from
+// the replayed tree are not reported.
+func ConsistencyCheck(logDir string, user upspin.UserName, live upspin.DirServer) ([]string, error) {
+	const op errors.Op = "dir/server.ConsistencyCheck"
+
+	r, err := serverlog.VerifyUser(user, logDir)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	if !r.OK() {
+		return nil, errors.E(op, user, errors.Invalid, errors.Errorf("logs are corrupt:\n%s", r))
+	}
+	if r.End != r.Checkpoint {
+		return nil, errors.E(op, user, errors.Invalid, errors.Errorf("logs end at offset %d but checkpoint is %d; stop the server cleanly first", r.End, r.Checkpoint))
+	}
+
+	ref, err := replayLogs(logDir, user)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var diffs []string
+	found := make(map[upspin.PathName]bool)
+	var walk func(dir upspin.PathName, copied bool) error
+	walk = func(dir upspin.PathName, copied bool) error {
+		pattern := string(upspin.QuoteGlob(dir))
+		if !strings.HasSuffix(pattern, "/") {
+			pattern += "/"
+		}
+		entries, err := live.Glob(pattern + "*")
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			found[e.Name] = true
+			re, ok := ref.entries[e.Name]
+			switch {
+			case !ok && !copied:
+				diffs = append(diffs, fmt.Sprintf("%s: in tree but not in log", e.Name))
+			case ok && re.Sequence != e.Sequence:
+				diffs = append(diffs, fmt.Sprintf("%s: sequence %d in tree, %d in log", e.Name, e.Sequence, re.Sequence))
+			}
+			if e.IsDir() {
+				if err := walk(e.Name, copied || ref.copied[e.Name]); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	root := upspin.PathName(user + "/")
+	rootEntry, err := live.Lookup(root)
+	if err != nil && !errors.Is(errors.NotExist, err) {
+		return nil, errors.E(op, err)
+	}
+	if rootEntry != nil {
+		found[root] = true
+		if re, ok := ref.entries[root]; ok && re.Sequence != rootEntry.Sequence {
+			diffs = append(diffs, fmt.Sprintf("%s: sequence %d in tree, %d in log", root, rootEntry.Sequence, re.Sequence))
+		}
+		if err := walk(root, ref.copied[root]); err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+	for name := range ref.entries {
+		if !found[name] {
+			diffs = append(diffs, fmt.Sprintf("%s: in log but not in tree", name))
+		}
+	}
+	diffs = append(diffs, ref.problems...)
+	sort.Strings(diffs)
+	return diffs, nil
+}
+
+// replayedTree is the tree of a user as built by replayLogs.
+type replayedTree struct {
+	// entries holds every entry in the tree, by path name.
+	entries map[upspin.PathName]*upspin.DirEntry
+	// copied records the directories put with existing blocks,
+	// whose contents are not in the logs.
+	copied map[upspin.PathName]bool
+	// problems describes the records that could not be applied.
+	problems []string
+}
+
+// replayLogs builds the user's tree by applying the records in the user's
+// logs to an empty root, assigning sequence numbers to directories as the
+// Tree does: a directory takes the sequence number of the latest change
+// beneath it.
+func replayLogs(logDir string, user upspin.UserName) (*replayedTree, error) {
+	h, err := serverlog.OpenUser(user, logDir)
+	if err != nil {
+		return nil, err
+	}
+	t := &replayedTree{
+		entries: make(map[upspin.PathName]*upspin.DirEntry),
+		copied:  make(map[upspin.PathName]bool),
+	}
+	// The root is saved, not logged, when it is created, and the logs
+	// begin just after.
+	root := upspin.PathName(user + "/")
+	t.entries[root] = &upspin.DirEntry{
+		Name:     root,
+		Attr:     upspin.AttrDirectory,
+		Sequence: upspin.SeqBase,
+	}
+	c := h.NewCursor(0)
+	defer c.Close()
+	for c.Next() {
+		rec := c.Record()
+		if err := t.apply(rec.Op, rec.Entry); err != nil {
+			t.problems = append(t.problems, fmt.Sprintf("%s: log offset %d: %v", rec.Entry.Name, rec.Offset, err))
+		}
+	}
+	if err := c.Err(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// apply applies one log record to the tree.
+func (t *replayedTree) apply(op serverlog.Operation, de *upspin.DirEntry) error {
+	p, err := path.Parse(de.Name)
+	if err != nil {
+		return err
+	}
+	name := p.Path()
+	switch op {
+	case serverlog.Put:
+		if _, ok := t.entries[p.Drop(1).Path()]; !ok && !p.IsRoot() && !t.inCopy(p) {
+			return errors.Str("parent not in log")
+		}
+		t.entries[name] = de
+		if de.IsDir() && len(de.Blocks) > 0 {
+			t.copied[name] = true
+		}
+	case serverlog.Delete:
+		if _, ok := t.entries[name]; !ok && !t.inCopy(p) {
+			return errors.Str("deleted entry not in log")
+		}
+		delete(t.entries, name)
+		delete(t.copied, name)
+	default:
+		return errors.Errorf("no such log operation: %v", op)
+	}
+	// The directories above the entry take its sequence number.
+	for i := p.NElem() - 1; i >= 0; i-- {
+		if dir, ok := t.entries[p.First(i).Path()]; ok {
+			dir.Sequence = de.Sequence
+		}
+	}
+	return nil
+}
+
+// inCopy reports whether p is beneath a directory put with existing blocks.
+func (t *replayedTree) inCopy(p path.Parsed) bool {
+	for i := p.NElem() - 1; i >= 0; i-- {
+		if t.copied[p.First(i).Path()] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,119 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"upspin.io/bind"
+	"upspin.io/config"
+	"upspin.io/dir/server/serverlog"
+	"upspin.io/factotum"
+	"upspin.io/test/testutil"
+	"upspin.io/upspin"
+)
+
+func TestConsistencyCheck(t *testing.T) {
+	const name = "consistency@example.com"
+	gen, _ := newDirServerForTesting(t, userName)
+	endpointInProcess := upspin.Endpoint{Transport: upspin.InProcess}
+	key, err := bind.KeyServer(gen.serverConfig, endpointInProcess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = key.Put(&upspin.User{
+		Name:      name,
+		Dirs:      []upspin.Endpoint{endpointInProcess},
+		Stores:    []upspin.Endpoint{endpointInProcess},
+		PublicKey: bob.PublicKey(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	userCfg := config.SetFactotum(config.SetUserName(config.New(), name), bob)
+	svc, err := generatorInstance.Dial(userCfg, endpointInProcess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := svc.(*server)
+
+	for _, dir := range []upspin.PathName{name + "/", name + "/dir", name + "/dir/sub"} {
+		if _, err := makeDirectory(s, dir); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, file := range []upspin.PathName{name + "/a", name + "/dir/b", name + "/dir/sub/c", name + "/a"} {
+		_, err := s.Put(&upspin.DirEntry{
+			Name:       file,
+			SignedName: file,
+			Attr:       upspin.AttrNone,
+			Writer:     name,
+			Packing:    upspin.PlainPack,
+			Sequence:   upspin.SeqIgnore,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := s.Delete(name + "/dir/b"); err != nil {
+		t.Fatal(err)
+	}
+	flushTree(t, s)
+
+	diffs, err := ConsistencyCheck(s.logDir, name, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diffs) != 0 {
+		t.Fatalf("differences in consistent tree: %q", diffs)
+	}
+
+	// Log a Put behind the tree's back.
+	if err := s.closeTree(name); err != nil {
+		t.Fatal(err)
+	}
+	u, err := serverlog.Open(name, s.logDir, bob, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ghost := upspin.DirEntry{
+		Name:       name + "/ghost",
+		SignedName: name + "/ghost",
+		Writer:     name,
+		Packing:    upspin.PlainPack,
+		Sequence:   upspin.SeqBase + 100,
+	}
+	if err := u.Append(&serverlog.Entry{Op: serverlog.Put, Entry: ghost}); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.SaveOffset(u.AppendOffset()); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	diffs, err = ConsistencyCheck(s.logDir, name, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	root, err := s.Lookup(name + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		fmt.Sprintf("%s/: sequence %d in tree, %d in log", name, root.Sequence, ghost.Sequence),
+		name + "/ghost: in log but not in tree",
+	}
+	if !reflect.DeepEqual(diffs, want) {
+		t.Errorf("differences = %q, want %q", diffs, want)
+	}
+}
@@ -0,0 +1,109 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"expvar"
+	"sync"
+
+	"upspin.io/cache"
+	"upspin.io/upspin"
+)
+
+// Counts of lookups in the listing caches of all directory servers in this
+// process, from which the hit rate may be computed.
+var (
+	listingHits   = expvar.NewInt("dirserver-listing-hits")
+	listingMisses = expvar.NewInt("dirserver-listing-misses")
+)
+
+const (
+	listingCacheDirs    = 1000   // Maximum directories whose listings are cached.
+	listingCacheEntries = 100000 // Maximum entries in all cached listings.
+)
+
+// listingCache caches the contents of directories, as returned by
+// Tree.List, so that directories that are listed often, as by repeated
+// Globs, need not be listed afresh each time. A listing is valid only
+// while the directory's sequence number, which changes whenever any of
+// its entries do, is the one it was cached with.
+//
+// The listings are raw, with complete entries and no access checks
+// applied. Callers must filter them for each request, on copies.
+type listingCache struct {
+	mu      sync.Mutex
+	lru     *cache.LRU // Of *listing, by directory name.
+	entries int        // Number of entries in all listings in lru.
+}
+
+// listing is the contents of a directory at a sequence number.
+type listing struct {
+	c       *listingCache
+	seq     int64
+	entries []*upspin.DirEntry
+}
+
+func newListingCache() *listingCache {
+	return &listingCache{lru: cache.NewLRU(listingCacheDirs)}
+}
+
+// get returns a copy of the cached listing of the directory, if it is
+// at sequence seq.
+func (c *listingCache) get(dir upspin.PathName, seq int64) ([]*upspin.DirEntry, bool) {
+	v, ok := c.lru.Get(dir)
+	if !ok || v.(*listing).seq != seq {
+		listingMisses.Add(1)
+		return nil, false
+	}
+	listingHits.Add(1)
+	return copyEntries(v.(*listing).entries), true
+}
+
+// add caches a copy of the listing of the directory at sequence seq.
+func (c *listingCache) add(dir upspin.PathName, seq int64, entries []*upspin.DirEntry) {
+	if len(entries) > listingCacheEntries/10 {
+		// Too big to be worth the space.
+		return
+	}
+	l := &listing{c: c, seq: seq, entries: copyEntries(entries)}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old := c.lru.Remove(dir); old != nil {
+		c.entries -= len(old.(*listing).entries)
+	}
+	for c.entries+len(l.entries) > listingCacheEntries {
+		_, v := c.lru.RemoveOldest()
+		if v == nil {
+			break
+		}
+		c.entries -= len(v.(*listing).entries)
+	}
+	c.lru.Add(dir, l)
+	c.entries += len(l.entries)
+}
+
+// purge empties the cache.
+func (c *listingCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for c.lru.Len() > 0 {
+		c.lru.RemoveOldest()
+	}
+	c.entries = 0
+}
+
+// OnEviction implements cache.EvictionNotifier. It is called when the
+// cache holds too many directories, with c.mu held by add.
+func (l *listing) OnEviction(key interface{}) {
+	l.c.entries -= len(l.entries)
+}
+
+func copyEntries(entries []*upspin.DirEntry) []*upspin.DirEntry {
+	cp := make([]*upspin.DirEntry, len(entries))
+	for i, e := range entries {
+		cp[i] = e.Copy()
+	}
+	return cp
+}
@@ -0,0 +1,150 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package replica implements streaming replication of a directory server's
+// logs to a warm standby.
+//
+// A primary directory server that implements Source ships each record of
+// its users' logs as it is committed, along with the roots it saves. A
+// Standby appends the records to its own log directory, keeping the logs
+// byte-for-byte identical to the primary's so that offsets, and therefore
+// checkpoints, carry over unchanged.
+//
+// The standby is read-only until it is promoted: it serves no requests,
+// and its log directory must not be used by a directory server while the
+// Standby runs. To promote it, stop the Standby, start a directory server
+// on its log directory, and point the primary's DNS name at it. The
+// directory server reads its tree from the store named by the replicated
+// roots, so the standby must use the same store as the primary, or a
+// replica of it.
+//
+// If the logs diverge, that is, a record in the standby's log does not
+// match the primary's record at the same offset, replication of that user
+// stops and the error is logged. Divergence indicates the standby's log
+// was written by something else, or a bug; it must be repaired by hand,
+// typically by deleting the user's logs on the standby so that they are
+// replicated afresh.
+package replica // import "upspin.io/dir/server/replica"
+
+import (
+	"upspin.io/errors"
+	"upspin.io/upspin"
+	"upspin.io/upspin/proto"
+)
+
+// A Source ships the logs of a directory server to a standby.
+type Source interface {
+	// Replicate streams updates to the logs of all of the server's
+	// users until done is closed. Users are replicated from the given
+	// states, or from the beginning if they are not listed. The
+	// channel is closed if the stream ends, such as when the
+	// connection to the source is lost.
+	Replicate(states []UserState, done <-chan struct{}) (<-chan Update, error)
+}
+
+// UserState describes how much of a user's log a standby holds.
+type UserState struct {
+	User upspin.UserName
+
+	// Offset is the end of the standby's log, where the next record
+	// will be appended.
+	Offset int64
+
+	// LastOffset is the offset of the last record in the standby's log,
+	// or -1 if the log is empty, and LastChecksum is its checksum.
+	// The source checks that its record at LastOffset matches.
+	LastOffset   int64
+	LastChecksum []byte
+}
+
+// Update is a change to a user's log, sent by a Source.
+type Update struct {
+	User upspin.UserName
+
+	// Record, if non-nil, is the log record stored at Offset in the
+	// source's log.
+	Offset int64
+	Record []byte
+
+	// Root, if non-nil, is the root most recently saved by the source,
+	// which reflects the log up to Checkpoint.
+	Root       *upspin.DirEntry
+	Checkpoint int64
+
+	// Error, if non-nil, reports that the user can no longer be
+	// replicated, for instance because the logs have diverged.
+	// No further updates for the user follow.
+	Error error
+}
+
+// ErrDiverged is the error reported when a standby's log does not match
+// the source's.
+var ErrDiverged = errors.Str("replica log has diverged from the primary")
+
+// StatesProto converts a slice of UserStates to a proto.DirReplicateRequest.
+func StatesProto(states []UserState) *proto.DirReplicateRequest {
+	req := &proto.DirReplicateRequest{
+		States: make([]*proto.DirReplicaState, len(states)),
+	}
+	for i, s := range states {
+		req.States[i] = &proto.DirReplicaState{
+			User:         string(s.User),
+			Offset:       s.Offset,
+			LastOffset:   s.LastOffset,
+			LastChecksum: s.LastChecksum,
+		}
+	}
+	return req
+}
+
+// UserStates converts a proto.DirReplicateRequest to a slice of UserStates.
+func UserStates(req *proto.DirReplicateRequest) []UserState {
+	states := make([]UserState, len(req.States))
+	for i, s := range req.States {
+		states[i] = UserState{
+			User:         upspin.UserName(s.User),
+			Offset:       s.Offset,
+			LastOffset:   s.LastOffset,
+			LastChecksum: s.LastChecksum,
+		}
+	}
+	return states
+}
+
+// UpdateProto converts an Update to a proto.DirReplicaUpdate.
+func UpdateProto(u *Update) (*proto.DirReplicaUpdate, error) {
+	p := &proto.DirReplicaUpdate{
+		User:       string(u.User),
+		Offset:     u.Offset,
+		Record:     u.Record,
+		Checkpoint: u.Checkpoint,
+	}
+	if u.Root != nil {
+		b, err := u.Root.Marshal()
+		if err != nil {
+			return nil, err
+		}
+		p.Root = b
+	}
+	if u.Error != nil {
+		p.Error = errors.MarshalError(u.Error)
+	}
+	return p, nil
+}
+
+// UpspinUpdate converts a proto.DirReplicaUpdate to an Update.
+func UpspinUpdate(p *proto.DirReplicaUpdate) (*Update, error) {
+	root, err := proto.UpspinDirEntry(p.Root)
+	if err != nil {
+		return nil, err
+	}
+	return &Update{
+		User:       upspin.UserName(p.User),
+		Offset:     p.Offset,
+		Record:     p.Record,
+		Root:       root,
+		Checkpoint: p.Checkpoint,
+		Error:      errors.UnmarshalError(p.Error),
+	}, nil
+}
@@ -0,0 +1,241 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package replica
+
+import (
+	"sync"
+	"time"
+
+	"upspin.io/dir/server/serverlog"
+	"upspin.io/errors"
+	"upspin.io/log"
+	"upspin.io/upspin"
+)
+
+// RetryInterval is how long a Standby waits before reconnecting to its
+// source after the connection fails.
+// It can be modified, such as for testing.
+var RetryInterval = 10 * time.Second
+
+// Standby maintains copies of a primary directory server's logs.
+type Standby struct {
+	logDir string
+	dial   func() (Source, error)
+
+	// mu protects the fields below.
+	mu sync.Mutex
+
+	// users holds the open logs of the replicated users.
+	users map[upspin.UserName]*standbyUser
+}
+
+// standbyUser holds the replication state of one user.
+type standbyUser struct {
+	log *serverlog.User
+
+	// root and checkpoint, if root is non-nil, are a root received
+	// from the source that cannot be saved until the log reaches the
+	// checkpoint.
+	root       *upspin.DirEntry
+	checkpoint int64
+
+	// failed, if non-nil, is the error that stopped replication.
+	failed error
+}
+
+// NewStandby returns a Standby that keeps copies in logDir of the logs
+// of the Source returned by dial. Dial is called again to reconnect if
+// the stream of updates ends.
+func NewStandby(logDir string, dial func() (Source, error)) *Standby {
+	return &Standby{
+		logDir: logDir,
+		dial:   dial,
+		users:  make(map[upspin.UserName]*standbyUser),
+	}
+}
+
+// Run replicates the logs until done is closed, reconnecting after
+// failures and resuming where it left off. When it returns, all logs
+// have been closed.
+func (s *Standby) Run(done <-chan struct{}) {
+	const op errors.Op = "dir/server/replica.Standby.Run"
+	defer s.close()
+	for {
+		err := s.runOnce(done)
+		select {
+		case <-done:
+			return
+		default:
+		}
+		if err != nil {
+			log.Error.Printf("%s: %v", op, err)
+		}
+		log.Info.Printf("%s: reconnecting in %v", op, RetryInterval)
+		select {
+		case <-done:
+			return
+		case <-time.After(RetryInterval):
+		}
+	}
+}
+
+// runOnce connects to the source and applies its updates until the
+// stream ends or done is closed.
+func (s *Standby) runOnce(done <-chan struct{}) error {
+	states, err := s.States()
+	if err != nil {
+		return err
+	}
+	src, err := s.dial()
+	if err != nil {
+		return err
+	}
+	stop := make(chan struct{})
+	defer close(stop)
+	updates, err := src.Replicate(states, stop)
+	if err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-done:
+			return nil
+		case u, ok := <-updates:
+			if !ok {
+				return errors.Str("replication stream ended")
+			}
+			if u.User == "" && u.Error != nil {
+				// The stream itself failed.
+				return u.Error
+			}
+			s.apply(&u)
+		}
+	}
+}
+
+// States returns the state of the logs held by the standby, including
+// those it has not opened yet. Users whose replication has failed are
+// omitted, so that they are not replicated again until restarted.
+func (s *Standby) States() ([]UserState, error) {
+	names, err := serverlog.ListUsers(s.logDir)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var states []UserState
+	for _, name := range names {
+		su, err := s.user(name)
+		if err != nil {
+			return nil, err
+		}
+		if su.failed != nil {
+			continue
+		}
+		last, sum, err := su.log.LastRecord()
+		if err != nil {
+			return nil, errors.E(name, err)
+		}
+		states = append(states, UserState{
+			User:         name,
+			Offset:       su.log.AppendOffset(),
+			LastOffset:   last,
+			LastChecksum: sum,
+		})
+	}
+	return states, nil
+}
+
+// Err returns the error that stopped the replication of the user's log,
+// or nil if it is proceeding.
+func (s *Standby) Err(name upspin.UserName) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if su, ok := s.users[name]; ok {
+		return su.failed
+	}
+	return nil
+}
+
+// user returns the standbyUser for the named user, opening the user's
+// logs if necessary. s.mu must be held.
+func (s *Standby) user(name upspin.UserName) (*standbyUser, error) {
+	if su, ok := s.users[name]; ok {
+		return su, nil
+	}
+	u, err := serverlog.Open(name, s.logDir, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	su := &standbyUser{log: u}
+	s.users[name] = su
+	return su, nil
+}
+
+// apply applies an update from the source.
+func (s *Standby) apply(u *Update) {
+	const op errors.Op = "dir/server/replica.Standby.apply"
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	su, err := s.user(u.User)
+	if err != nil {
+		log.Error.Printf("%s: %v", op, errors.E(u.User, err))
+		return
+	}
+	if su.failed != nil {
+		return
+	}
+	fail := func(err error) {
+		su.failed = errors.E(op, u.User, err)
+		log.Error.Printf("%s: REPLICATION STOPPED: %v", op, su.failed)
+	}
+	if u.Error != nil {
+		fail(u.Error)
+		return
+	}
+	if u.Record != nil {
+		le, err := serverlog.ParseRecord(u.Record)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if err := su.log.AppendAt(u.Offset, le); err != nil {
+			fail(errors.Errorf("%v: %v", ErrDiverged, err))
+			return
+		}
+	}
+	if u.Root != nil {
+		su.root = u.Root
+		su.checkpoint = u.Checkpoint
+	}
+	// Save the root once the log has caught up with it.
+	if su.root != nil && su.log.AppendOffset() >= su.checkpoint {
+		// This is the order used by the primary's tree. If we stop
+		// in between, the source sends its root again when we
+		// reconnect, restoring consistency.
+		if err := su.log.SaveOffset(su.checkpoint); err != nil {
+			fail(err)
+			return
+		}
+		if err := su.log.SaveRoot(su.root); err != nil {
+			fail(err)
+			return
+		}
+		su.root = nil
+	}
+}
+
+// close closes the logs of all users.
+func (s *Standby) close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for name, su := range s.users {
+		if err := su.log.Close(); err != nil {
+			log.Error.Printf("dir/server/replica: closing logs of %s: %v", name, err)
+		}
+		delete(s.users, name)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"upspin.io/dir/server/replica"
+	"upspin.io/dir/server/serverlog"
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+var _ replica.Source = (*server)(nil)
+
+// replicateBatch is the maximum number of records read from a user's log
+// at a time while replicating.
+const replicateBatch = 100
+
+// replicaCursor records the progress of replication of one user's log.
+type replicaCursor struct {
+	// offset is the offset of the next record to send.
+	offset int64
+
+	// check, if non-nil, is the standby's state as it reported it,
+	// which must be verified before any records are sent.
+	check *replica.UserState
+
+	// sentRoot, checkpoint and rootSeq describe the last root sent.
+	sentRoot   bool
+	checkpoint int64
+	rootSeq    int64
+
+	// failed reports that replication of the user has stopped.
+	failed bool
+}
+
+// Replicate implements replica.Source. Only the server's own user,
+// running the standby, may replicate the server's logs.
+func (s *server) Replicate(states []replica.UserState, done <-chan struct{}) (<-chan replica.Update, error) {
+	const op errors.Op = "dir/server.Replicate"
+	if s.userName != s.serverConfig.UserName() {
+		return nil, errors.E(op, s.userName, errors.Permission, "only the server's user may replicate its logs")
+	}
+	cursors := make(map[upspin.UserName]*replicaCursor)
+	for i := range states {
+		st := &states[i]
+		cursors[st.User] = &replicaCursor{
+			offset: st.Offset,
+			check:  st,
+		}
+	}
+	out := make(chan replica.Update)
+	go s.replicate(cursors, out, done)
+	return out, nil
+}
+
+// replicate, which runs in a goroutine, sends the new records in the
+// logs of all users to out until done is closed. After a first pass over
+// every user's log it waits to be told of changes, so that it loads only
+// the trees of the users whose logs have changed.
+func (s *server) replicate(cursors map[upspin.UserName]*replicaCursor, out chan<- replica.Update, done <-chan struct{}) {
+	const op errors.Op = "dir/server.Replicate"
+	defer close(out)
+	// Watch before listing the users so no change is missed.
+	w := s.logChanges.watch()
+	defer s.logChanges.unwatch(w)
+	users, err := serverlog.ListUsers(s.logDir)
+	if err != nil {
+		logger.Error.Printf("%s: %v", op, err)
+		return
+	}
+	for {
+		for _, name := range users {
+			c := cursors[name]
+			if c == nil {
+				c = new(replicaCursor)
+				cursors[name] = c
+			}
+			for more := true; more && !c.failed; {
+				var updates []replica.Update
+				updates, more, err = s.replicateUser(name, c)
+				if err != nil {
+					c.failed = true
+					err = errors.E(op, name, err)
+					logger.Error.Printf("REPLICATION STOPPED: %v", err)
+					updates = append(updates, replica.Update{User: name, Error: err})
+				}
+				for _, u := range updates {
+					select {
+					case out <- u:
+					case <-done:
+						return
+					}
+				}
+			}
+		}
+		select {
+		case <-done:
+			return
+		case <-w.ready:
+		}
+		users = s.logChanges.take(w)
+	}
+}
+
+// logChanges tells the running replications which users' logs, roots
+// or checkpoints have changed. Its changed method is set as the
+// OnChange function of every serverlog.User the server opens.
+type logChanges struct {
+	mu       sync.Mutex
+	watchers map[*logWatcher]bool
+}
+
+// logWatcher holds the users whose logs have changed since one
+// replication last looked.
+type logWatcher struct {
+	users map[upspin.UserName]bool // Protected by logChanges.mu.
+	ready chan struct{}            // Signaled when users becomes non-empty.
+}
+
+func newLogChanges() *logChanges {
+	return &logChanges{watchers: make(map[*logWatcher]bool)}
+}
+
+// changed records that the named user's log has changed.
+func (l *logChanges) changed(name upspin.UserName) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for w := range l.watchers {
+		w.users[name] = true
+		select {
+		case w.ready <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// watch returns a new watcher of changes.
+func (l *logChanges) watch() *logWatcher {
+	w := &logWatcher{
+		users: make(map[upspin.UserName]bool),
+		ready: make(chan struct{}, 1),
+	}
+	l.mu.Lock()
+	l.watchers[w] = true
+	l.mu.Unlock()
+	return w
+}
+
+// unwatch stops the watcher.
+func (l *logChanges) unwatch(w *logWatcher) {
+	l.mu.Lock()
+	delete(l.watchers, w)
+	l.mu.Unlock()
+}
+
+// take returns, in order, the users whose logs have changed since the
+// watcher last looked, and forgets them.
+func (l *logChanges) take(w *logWatcher) []upspin.UserName {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	users := make([]upspin.UserName, 0, len(w.users))
+	for name := range w.users {
+		users = append(users, name)
+	}
+	w.users = make(map[upspin.UserName]bool)
+	sort.Slice(users, func(i, j int) bool { return users[i] < users[j] })
+	return users
+}
+
+// replicateUser returns the updates to the named user's log since the
+// position recorded in c, and reports whether there are more to come.
+func (s *server) replicateUser(name upspin.UserName, c *replicaCursor) ([]replica.Update, bool, error) {
+	tree, err := s.loadTreeFor(name)
+	if err != nil {
+		return nil, false, err
+	}
+	if st := c.check; st != nil {
+		// Verify that the standby's last record matches ours.
+		diverged := func(format string, args ...interface{}) error {
+			return errors.E(errors.Invalid, errors.Errorf("%v: "+format, append([]interface{}{replica.ErrDiverged}, args...)...))
+		}
+		switch {
+		case st.LastOffset < 0 && st.Offset != 0:
+			return nil, false, diverged("standby log is empty but ends at offset %d", st.Offset)
+		case st.LastOffset >= 0:
+			recs, err := tree.ReadLog(st.LastOffset, 1)
+			if err != nil {
+				return nil, false, diverged("%v", err)
+			}
+			if len(recs) == 0 {
+				return nil, false, diverged("standby log extends beyond offset %d", st.LastOffset)
+			}
+			rec := recs[0].Record
+			if !bytes.Equal(serverlog.RecordChecksum(rec), st.LastChecksum) || st.LastOffset+int64(len(rec)) != st.Offset {
+				return nil, false, diverged("records at offset %d differ", st.LastOffset)
+			}
+		}
+		c.check = nil
+	}
+
+	recs, err := tree.ReadLog(c.offset, replicateBatch)
+	if err != nil {
+		return nil, false, err
+	}
+	var updates []replica.Update
+	for _, r := range recs {
+		updates = append(updates, replica.Update{
+			User:   name,
+			Offset: r.Offset,
+			Record: r.Record,
+		})
+		c.offset = r.Offset + int64(len(r.Record))
+	}
+
+	root, checkpoint, err := tree.SavedRoot()
+	if err != nil {
+		return nil, false, err
+	}
+	if root != nil && (!c.sentRoot || checkpoint != c.checkpoint || root.Sequence != c.rootSeq) {
+		updates = append(updates, replica.Update{
+			User:       name,
+			Root:       root,
+			Checkpoint: checkpoint,
+		})
+		c.sentRoot = true
+		c.checkpoint = checkpoint
+		c.rootSeq = root.Sequence
+	}
+	return updates, len(recs) == replicateBatch, nil
+}
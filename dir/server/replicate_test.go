@@ -0,0 +1,277 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"upspin.io/config"
+	"upspin.io/dir/server/replica"
+	"upspin.io/dir/server/serverlog"
+	"upspin.io/factotum"
+	"upspin.io/test/testutil"
+	"upspin.io/upspin"
+)
+
+func TestReplicate(t *testing.T) {
+	primary, user, cleanup := newReplicationPrimary(t)
+	defer cleanup()
+	primaryDir := primary.logDir
+	standbyDir, err := os.MkdirTemp("", "DirServerStandby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(standbyDir)
+
+	if _, err := makeDirectory(user, userName+"/"); err != nil {
+		t.Fatal(err)
+	}
+	replicationOps(t, user, 0, 150)
+
+	// Replicate, then stop the standby, make more changes and
+	// restart it, so it must resume where it left off.
+	runStandby := func() {
+		standby := replica.NewStandby(standbyDir, func() (replica.Source, error) { return primary, nil })
+		done := make(chan struct{})
+		finished := make(chan struct{})
+		go func() {
+			standby.Run(done)
+			close(finished)
+		}()
+		waitForStandby(t, primary, primaryDir, standbyDir)
+		if err := standby.Err(userName); err != nil {
+			t.Fatal(err)
+		}
+		close(done)
+		<-finished
+	}
+	runStandby()
+	replicationOps(t, user, 150, 300)
+	runStandby()
+
+	// Promote the standby and check it has the same tree.
+	promoted, err := New(primary.serverConfig, "logDir="+standbyDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	svc, err := promoted.Dial(config.SetUserName(config.New(), userName), primary.Endpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	compareTrees(t, user, svc.(*server), userName+"/")
+}
+
+func TestReplicateDiverged(t *testing.T) {
+	primary, user, cleanup := newReplicationPrimary(t)
+	defer cleanup()
+	standbyDir, err := os.MkdirTemp("", "DirServerStandby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(standbyDir)
+
+	if _, err := makeDirectory(user, userName+"/"); err != nil {
+		t.Fatal(err)
+	}
+	replicationOps(t, user, 0, 10)
+
+	// Give the standby a log that holds a different first record.
+	log, err := serverlog.Open(userName, standbyDir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = log.Append(&serverlog.Entry{
+		Op: serverlog.Put,
+		Entry: upspin.DirEntry{
+			Name:       userName + "/",
+			SignedName: userName + "/",
+			Attr:       upspin.AttrDirectory,
+			Writer:     otherUser,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := log.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	standby := replica.NewStandby(standbyDir, func() (replica.Source, error) { return primary, nil })
+	done := make(chan struct{})
+	defer close(done)
+	go standby.Run(done)
+	for i := 0; ; i++ {
+		err := standby.Err(userName)
+		if err != nil {
+			if !strings.Contains(err.Error(), replica.ErrDiverged.Error()) {
+				t.Fatalf("err = %v, want %v", err, replica.ErrDiverged)
+			}
+			break
+		}
+		if i > 500 {
+			t.Fatal("divergence not detected")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestLogChanges checks that replication is told of changes to a user's
+// log, so that it need not load the trees of users without any.
+func TestLogChanges(t *testing.T) {
+	primary, user, cleanup := newReplicationPrimary(t)
+	defer cleanup()
+
+	w := primary.logChanges.watch()
+	defer primary.logChanges.unwatch(w)
+	if _, err := makeDirectory(user, userName+"/"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-w.ready:
+	default:
+		t.Fatal("no change reported")
+	}
+	if got := primary.logChanges.take(w); len(got) != 1 || got[0] != userName {
+		t.Fatalf("changed users = %v, want [%s]", got, userName)
+	}
+
+	// Reads change nothing.
+	if _, err := user.Lookup(userName + "/"); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-w.ready:
+		t.Fatalf("change reported after a lookup: %v", primary.logChanges.take(w))
+	default:
+	}
+}
+
+// newReplicationPrimary returns a primary server with its own log
+// directory, the same server dialed for userName, and a cleanup function.
+func newReplicationPrimary(t *testing.T) (primary, user *server, cleanup func()) {
+	// Register the users' keys.
+	_, userCfg := newDirServerForTesting(t, userName)
+
+	prevRetry := replica.RetryInterval
+	replica.RetryInterval = 10 * time.Millisecond
+
+	f, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpointInProcess := upspin.Endpoint{Transport: upspin.InProcess}
+	cfg := config.New()
+	cfg = config.SetUserName(cfg, serverName)
+	cfg = config.SetPacking(cfg, upspin.EEPack)
+	cfg = config.SetFactotum(cfg, f)
+	cfg = config.SetKeyEndpoint(cfg, endpointInProcess)
+	cfg = config.SetStoreEndpoint(cfg, endpointInProcess)
+	cfg = config.SetDirEndpoint(cfg, endpointInProcess)
+
+	dir, err := os.MkdirTemp("", "DirServerPrimary")
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := New(cfg, "logDir="+dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := p.Dial(userCfg, endpointInProcess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p.(*server), u.(*server), func() {
+		replica.RetryInterval = prevRetry
+		os.RemoveAll(dir)
+	}
+}
+
+// replicationOps makes the directories, files and deletions numbered
+// from start to end in userName's tree.
+func replicationOps(t *testing.T, s *server, start, end int) {
+	for i := start; i < end; i++ {
+		dir := upspin.PathName(fmt.Sprintf("%s/d%d", userName, i%10))
+		var err error
+		switch {
+		case i < 10:
+			_, err = makeDirectory(s, dir)
+		case i >= 20 && i%7 == 0:
+			_, err = s.Delete(upspin.PathName(fmt.Sprintf("%s/f%d", dir, i-10)))
+		default:
+			name := upspin.PathName(fmt.Sprintf("%s/f%d", dir, i))
+			_, err = s.Put(&upspin.DirEntry{
+				Name:       name,
+				SignedName: name,
+				Attr:       upspin.AttrNone,
+				Writer:     userName,
+				Packing:    upspin.PlainPack,
+				Sequence:   upspin.SeqIgnore,
+			})
+		}
+		if err != nil {
+			t.Fatalf("op %d: %v", i, err)
+		}
+		if i%50 == 0 {
+			// Flush the tree so new roots are replicated too.
+			tree, err := s.loadTreeFor(userName)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := tree.Flush(); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+// waitForStandby waits until the standby's log and checkpoint match the
+// primary's.
+func waitForStandby(t *testing.T, primary *server, primaryDir, standbyDir string) {
+	for i := 0; ; i++ {
+		want, err := serverlog.VerifyUser(userName, primaryDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := serverlog.VerifyUser(userName, standbyDir)
+		if err == nil && got.OK() && got.End == want.End && got.Checkpoint == want.Checkpoint {
+			return
+		}
+		if i > 500 {
+			t.Fatalf("standby did not catch up: got\n%v\nwant\n%v", got, want)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// compareTrees checks that the trees served by a and b below the
+// directory are the same.
+func compareTrees(t *testing.T, a, b *server, dir upspin.PathName) {
+	aEntries, err := a.Glob(string(dir) + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	bEntries, err := b.Glob(string(dir) + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(aEntries) != len(bEntries) {
+		t.Fatalf("%s: %d entries in primary, %d in standby", dir, len(aEntries), len(bEntries))
+	}
+	for i, ae := range aEntries {
+		be := bEntries[i]
+		// Directory blocks are encrypted afresh when flushed,
+		// so only compare their names and sequence numbers.
+		if ae.Name != be.Name || ae.Sequence != be.Sequence || ae.Attr != be.Attr {
+			t.Fatalf("primary has %s seq %d, standby has %s seq %d", ae.Name, ae.Sequence, be.Name, be.Sequence)
+		}
+		if ae.IsDir() {
+			compareTrees(t, a, b, ae.Name+"/")
+		}
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"upspin.io/errors"
+)
+
+// snapshotSchedule says when snapshots are taken. It is either a fixed
+// interval between snapshots or a restricted cron expression.
+//
+// An interval is written as a Go duration, such as "12h" or "90m".
+//
+// A cron expression has five space-separated fields: minute (0-59),
+// hour (0-23), day of month (1-31), month (1-12) and day of week
+// (0-6, Sunday is 0). Each field is "*" or a comma-separated list of
+// numbers and ranges such as "9-17", either of which may be followed by a
+// step such as "*/15" or "9-17/2". Names of months and days and the
+// @hourly-style shorthands are not supported. As in cron, if both day
+// fields are restricted a day matching either one will do. Times are UTC,
+// as are the names of snapshot directories.
+//
+// For example, "0 9-17 * * 1-5" is on the hour during working hours on
+// weekdays.
+type snapshotSchedule struct {
+	spec     string
+	interval time.Duration
+
+	// Bit i of each field is set if value i matches.
+	minute, hour, dom, month, dow uint64
+	// domStar and dowStar record that the day fields are "*".
+	domStar, dowStar bool
+}
+
+// minSnapshotInterval is the shortest interval schedule accepted.
+const minSnapshotInterval = time.Minute
+
+// parseSnapshotSchedule parses a schedule as described for snapshotSchedule.
+func parseSnapshotSchedule(spec string) (*snapshotSchedule, error) {
+	const op errors.Op = "dir/server.parseSnapshotSchedule"
+	spec = strings.TrimSpace(spec)
+	fields := strings.Fields(spec)
+	if len(fields) == 1 {
+		d, err := time.ParseDuration(spec)
+		if err != nil {
+			return nil, errors.E(op, errors.Invalid, errors.Errorf("bad snapshot interval %q: %v", spec, err))
+		}
+		if d < minSnapshotInterval {
+			return nil, errors.E(op, errors.Invalid, errors.Errorf("snapshot interval %v is shorter than %v", d, minSnapshotInterval))
+		}
+		return &snapshotSchedule{spec: spec, interval: d}, nil
+	}
+	if len(fields) != 5 {
+		return nil, errors.E(op, errors.Invalid, errors.Errorf("snapshot schedule %q must be an interval or have five fields", spec))
+	}
+	sc := &snapshotSchedule{spec: spec}
+	var err error
+	for _, f := range []struct {
+		field    string
+		bits     *uint64
+		min, max int
+	}{
+		{fields[0], &sc.minute, 0, 59},
+		{fields[1], &sc.hour, 0, 23},
+		{fields[2], &sc.dom, 1, 31},
+		{fields[3], &sc.month, 1, 12},
+		{fields[4], &sc.dow, 0, 6},
+	} {
+		*f.bits, err = parseCronField(f.field, f.min, f.max)
+		if err != nil {
+			return nil, errors.E(op, errors.Invalid, errors.Errorf("snapshot schedule %q: %v", spec, err))
+		}
+	}
+	sc.domStar = fields[2] == "*"
+	sc.dowStar = fields[4] == "*"
+	return sc, nil
+}
+
+// parseCronField parses one field of a cron expression whose values lie
+// in [min, max] and returns the set of matching values as a bit mask.
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		rng, step := part, 1
+		if i := strings.IndexByte(part, '/'); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, errors.Errorf("bad step in %q", part)
+			}
+			rng, step = part[:i], n
+		}
+		lo, hi := min, max
+		if rng != "*" {
+			var err error
+			if i := strings.IndexByte(rng, '-'); i >= 0 {
+				lo, err = strconv.Atoi(rng[:i])
+				if err == nil {
+					hi, err = strconv.Atoi(rng[i+1:])
+				}
+			} else {
+				lo, err = strconv.Atoi(rng)
+				hi = lo
+			}
+			if err != nil {
+				return 0, errors.Errorf("bad value in %q", part)
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, errors.Errorf("%q out of range %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// next returns when the snapshot after one taken at last is due. If there
+// has been no snapshot, last is the zero time. A returned time not after
+// now means a snapshot is due now; however many scheduled times have been
+// missed, only one snapshot is owed.
+func (sc *snapshotSchedule) next(last, now time.Time) time.Time {
+	if sc.interval > 0 {
+		if last.IsZero() {
+			return now
+		}
+		return last.Add(sc.interval)
+	}
+	after := last
+	if last.IsZero() {
+		// Wait for the first scheduled time.
+		after = now.Add(-time.Minute)
+	}
+	t := after.UTC().Truncate(time.Minute).Add(time.Minute)
+	// Any valid expression matches within about four years (Feb 29);
+	// give up after five and try again later.
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case sc.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !sc.matchesDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+		case sc.hour&(1<<uint(t.Hour())) == 0:
+			t = t.Truncate(time.Hour).Add(time.Hour)
+		case sc.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return limit
+}
+
+// matchesDay reports whether the day fields of a cron schedule select
+// t's day.
+func (sc *snapshotSchedule) matchesDay(t time.Time) bool {
+	dom := sc.dom&(1<<uint(t.Day())) != 0
+	dow := sc.dow&(1<<uint(t.Weekday())) != 0
+	if !sc.domStar && !sc.dowStar {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+func (sc *snapshotSchedule) String() string {
+	return sc.spec
+}
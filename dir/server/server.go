@@ -6,7 +6,10 @@
 package server
 
 import (
+	"expvar"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -28,6 +31,8 @@ import (
 	"upspin.io/valid"
 )
 
+var logger = log.For("dir/server")
+
 // common error values.
 var (
 	errNotExist = errors.E(errors.NotExist)
@@ -68,10 +73,24 @@ type server struct {
 	// for different users is okay as the LRU is thread-safe.
 	userTrees *cache.LRU
 
+	// treeCacheBytes is the approximate number of bytes of memory the
+	// trees in userTrees may use before the least recently used clean
+	// ones are evicted. If zero, only the number of trees is bounded.
+	treeCacheBytes int64
+
+	// logSync says when the users' logs are flushed to stable storage.
+	logSync serverlog.SyncPolicy
+
+	// logChanges tells replications which users' logs have changed.
+	logChanges *logChanges
+
 	// access caches the parsed contents of Access files as struct
 	// accessEntry, indexed by their path names.
 	access *cache.LRU
 
+	// listings caches the contents of recently listed directories.
+	listings *listingCache
+
 	// defaultAccess caches parsed empty Access files that implicitly exists
 	// at the root of every user's tree, if an explicit one is not found.
 	// It's indexed by the username.
@@ -93,6 +112,14 @@ type server struct {
 	// snapshot loop.
 	snapshotControl chan snapshotCreate
 
+	// snapshotReschedule tells the snapshot loop that a schedule
+	// may have changed.
+	snapshotReschedule chan struct{}
+
+	// snapshotSchedule is the schedule of snapshots for users whose
+	// snapshot root has no Schedule file.
+	snapshotSchedule *snapshotSchedule
+
 	// now returns the time now. It's usually just upspin.Now but is
 	// overridden for tests.
 	now func() upspin.Time
@@ -100,9 +127,20 @@ type server struct {
 	// dialed reports whether the instance was created using Dial, not New.
 	dialed bool
 
+	// txns holds the transactions prepared by PreparePut.
+	txns *txnTable
+
 	// The Storage backend in which to make backup copies of roots.
 	// If nil, no backups are made.
 	storage storage.Storage
+
+	// history records the requests made to the server.
+	// If nil, none are recorded.
+	history *accessHistory
+
+	// audit records the requests denied by the server.
+	// If nil, none are recorded.
+	audit *auditLog
 }
 
 // snapshotCreate is used to create a snapshot and report its success.
@@ -113,6 +151,13 @@ type snapshotCreate struct {
 
 var _ upspin.DirServer = (*server)(nil)
 
+// treeBytes is the approximate memory used by the trees cached by the
+// most recently active server.
+var treeBytes = expvar.NewInt("dirserver-tree-bytes")
+
+// ops records the calls handled by all directory servers in this process.
+var ops = metric.NewOps("dirserver")
+
 // options are optional parameters to almost every inner method of directory
 // for doing optional, non-correctness-related work.
 type options struct {
@@ -120,7 +165,15 @@ type options struct {
 	// Add other things below (for example, some health monitoring stats).
 }
 
-// New creates a new instance of DirServer with the given options
+// New creates a new instance of DirServer with the given options.
+// The option "sync=policy" sets when the users' logs are flushed to
+// stable storage; see serverlog.ParseSyncPolicy for the policies.
+// The option "accessHistory=true" makes the server keep a log of all
+// requests for AccessHistory; the options "accessHistoryMaxBytes=n" and
+// "accessHistoryMaxAge=duration" set when the log is rotated.
+// The option "auditLog=file" makes the server append to the file a
+// record of each request it denies for want of rights; with the option
+// "auditHashPaths=true", the paths in the records are hashed.
 func New(cfg upspin.Config, options ...string) (upspin.DirServer, error) {
 	const op errors.Op = "dir/server.New"
 	if cfg == nil {
@@ -146,6 +199,14 @@ func New(cfg upspin.Config, options ...string) (upspin.DirServer, error) {
 		logDir         string
 		storageBackend string
 		storageOpts    []storage.DialOpts
+		treeCacheBytes int64
+		logSync        serverlog.SyncPolicy
+		schedule       = snapshotDefaultSchedule
+		history        bool
+		historyBytes   int64         = accessHistoryMaxBytes
+		historyAge     time.Duration = accessHistoryMaxAge
+		auditFile      string
+		auditHash      bool
 	)
 	for _, opt := range options {
 		const logDirPrefix = "logDir="
@@ -153,6 +214,72 @@ func New(cfg upspin.Config, options ...string) (upspin.DirServer, error) {
 			logDir = opt[len(logDirPrefix):]
 			continue
 		}
+		const treeCacheBytesPrefix = "treeCacheBytes="
+		if strings.HasPrefix(opt, treeCacheBytesPrefix) {
+			n, err := strconv.ParseInt(opt[len(treeCacheBytesPrefix):], 10, 64)
+			if err != nil || n < 0 {
+				return nil, errors.E(op, errors.Invalid, errors.Errorf("invalid option %q", opt))
+			}
+			treeCacheBytes = n
+			continue
+		}
+		const syncPrefix = "sync="
+		if strings.HasPrefix(opt, syncPrefix) {
+			p, err := serverlog.ParseSyncPolicy(opt[len(syncPrefix):])
+			if err != nil {
+				return nil, errors.E(op, err)
+			}
+			logSync = p
+			continue
+		}
+		const snapshotSchedulePrefix = "snapshotSchedule="
+		if strings.HasPrefix(opt, snapshotSchedulePrefix) {
+			// Options are often given as a comma-separated list,
+			// so lists in the schedule may use semicolons.
+			schedule = strings.Replace(opt[len(snapshotSchedulePrefix):], ";", ",", -1)
+			continue
+		}
+		const historyPrefix = "accessHistory="
+		if strings.HasPrefix(opt, historyPrefix) {
+			b, err := strconv.ParseBool(opt[len(historyPrefix):])
+			if err != nil {
+				return nil, errors.E(op, errors.Invalid, errors.Errorf("invalid option %q", opt))
+			}
+			history = b
+			continue
+		}
+		const historyBytesPrefix = "accessHistoryMaxBytes="
+		if strings.HasPrefix(opt, historyBytesPrefix) {
+			n, err := strconv.ParseInt(opt[len(historyBytesPrefix):], 10, 64)
+			if err != nil || n <= 0 {
+				return nil, errors.E(op, errors.Invalid, errors.Errorf("invalid option %q", opt))
+			}
+			historyBytes = n
+			continue
+		}
+		const historyAgePrefix = "accessHistoryMaxAge="
+		if strings.HasPrefix(opt, historyAgePrefix) {
+			d, err := time.ParseDuration(opt[len(historyAgePrefix):])
+			if err != nil || d <= 0 {
+				return nil, errors.E(op, errors.Invalid, errors.Errorf("invalid option %q", opt))
+			}
+			historyAge = d
+			continue
+		}
+		const auditLogPrefix = "auditLog="
+		if strings.HasPrefix(opt, auditLogPrefix) {
+			auditFile = opt[len(auditLogPrefix):]
+			continue
+		}
+		const auditHashPrefix = "auditHashPaths="
+		if strings.HasPrefix(opt, auditHashPrefix) {
+			b, err := strconv.ParseBool(opt[len(auditHashPrefix):])
+			if err != nil {
+				return nil, errors.E(op, errors.Invalid, errors.Errorf("invalid option %q", opt))
+			}
+			auditHash = b
+			continue
+		}
 		const backendPrefix = "backend="
 		if strings.HasPrefix(opt, backendPrefix) {
 			storageBackend = opt[len(backendPrefix):]
@@ -165,10 +292,36 @@ func New(cfg upspin.Config, options ...string) (upspin.DirServer, error) {
 		if err != nil {
 			return nil, errors.E(op, errors.IO, err)
 		}
-		log.Error.Printf("%s: warning: writing important logs to a temporary directory (%q). A server restart will lose data.", op, dir)
+		logger.Error.Printf("%s: warning: writing important logs to a temporary directory (%q). A server restart will lose data.", op, dir)
 		logDir = dir
 	}
 
+	txns, err := openTxnTable(filepath.Join(logDir, "tx"))
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var hist *accessHistory
+	if history {
+		hist, err = openAccessHistory(filepath.Join(logDir, "access"), historyBytes, historyAge)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	var audit *auditLog
+	if auditFile != "" {
+		audit, err = openAuditLog(auditFile, auditHash)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+
+	sched, err := parseSnapshotSchedule(schedule)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
 	var store storage.Storage
 	if storageBackend != "" {
 		// Dial a storage backend in which to store the roots.
@@ -185,16 +338,25 @@ func New(cfg upspin.Config, options ...string) (upspin.DirServer, error) {
 		groupCacheSize  = 100
 	)
 	s := &server{
-		serverConfig:  cfg,
-		userName:      cfg.UserName(),
-		logDir:        logDir,
-		userTrees:     cache.NewLRU(userCacheSize),
-		access:        cache.NewLRU(accessCacheSize),
-		defaultAccess: cache.NewLRU(accessCacheSize),
-		remoteGroups:  cache.NewLRU(groupCacheSize),
-		userLocks:     make([]sync.Mutex, numUserLocks),
-		now:           upspin.Now,
-		storage:       store,
+		serverConfig:   cfg,
+		userName:       cfg.UserName(),
+		logDir:         logDir,
+		userTrees:      cache.NewLRU(userCacheSize),
+		treeCacheBytes: treeCacheBytes,
+		logSync:        logSync,
+		logChanges:     newLogChanges(),
+		access:         cache.NewLRU(accessCacheSize),
+		listings:       newListingCache(),
+		defaultAccess:  cache.NewLRU(accessCacheSize),
+		remoteGroups:   cache.NewLRU(groupCacheSize),
+		userLocks:      make([]sync.Mutex, numUserLocks),
+		now:            upspin.Now,
+		storage:        store,
+		txns:           txns,
+		history:        hist,
+		audit:          audit,
+
+		snapshotSchedule: sched,
 	}
 	shutdown.Handle(s.shutdown)
 	// Start background services.
@@ -204,8 +366,10 @@ func New(cfg upspin.Config, options ...string) (upspin.DirServer, error) {
 }
 
 // Lookup implements upspin.DirServer.
-func (s *server) Lookup(name upspin.PathName) (*upspin.DirEntry, error) {
+func (s *server) Lookup(name upspin.PathName) (_ *upspin.DirEntry, err error) {
 	const op errors.Op = "dir/server.Lookup"
+	defer ops.Observe("Lookup", time.Now(), &err)
+	defer s.recordAccess("Lookup", &err, name)
 	o, m := newOptMetric(op)
 	defer m.Done()
 	return s.lookupWithPermissions(op, name, o)
@@ -295,8 +459,24 @@ func (s *server) lookup(p path.Parsed, entryMustBeClean bool, opts ...options) (
 }
 
 // Put implements upspin.DirServer.
-func (s *server) Put(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
+func (s *server) Put(entry *upspin.DirEntry) (_ *upspin.DirEntry, err error) {
 	const op errors.Op = "dir/server.Put"
+	defer ops.Observe("Put", time.Now(), &err)
+	if entry != nil {
+		defer s.recordAccess("Put", &err, entry.Name)
+	}
+	return s.putEntry(op, entry, true)
+}
+
+// PutUncheckedLink implements upspin.UncheckedLinkPutter.
+func (s *server) PutUncheckedLink(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
+	const op errors.Op = "dir/server.PutUncheckedLink"
+	return s.putEntry(op, entry, false)
+}
+
+// putEntry implements Put and PutUncheckedLink. If checkLink is true and
+// the entry is a link, its target is checked by checkLinkTarget.
+func (s *server) putEntry(op errors.Op, entry *upspin.DirEntry, checkLink bool) (*upspin.DirEntry, error) {
 	o, m := newOptMetric(op)
 	defer m.Done()
 
@@ -324,7 +504,63 @@ func (s *server) Put(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
 		}
 		return entry, <-errorC // Returned error reports status of snapshot.
 	}
+	// The Schedule file is the only other one the snapshot owner may
+	// put in the otherwise read-only snapshot tree.
+	if isSnapshotUser(p.User()) && s.isSnapshotOwner(p.User()) && isSnapshotScheduleFile(p) {
+		if err := s.checkSnapshotScheduleEntry(entry); err != nil {
+			return nil, errors.E(op, err)
+		}
+		entry, err = s.put(op, p, entry, o)
+		if err != nil {
+			return entry, err
+		}
+		// Tell the snapshot loop, without waiting for it.
+		select {
+		case s.snapshotReschedule <- struct{}{}:
+		default:
+		}
+		return &upspin.DirEntry{
+			Attr:     upspin.AttrIncomplete,
+			Sequence: entry.Sequence,
+		}, nil
+	}
+
+	if err := s.txns.checkFree([]path.Parsed{p}, []bool{false}); err != nil {
+		return nil, errors.E(op, err)
+	}
+	existing, err := s.checkPut(op, p, entry, checkLink, o)
+	if err == upspin.ErrFollowLink {
+		return s.errLink(op, existing, o)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		// If we're updating an Access or Group file, forget the
+		// cached copy and let the new one be loaded lazily.
+		s.forgetAccessControl(op, p)
+	}
+
+	entry, err = s.put(op, p, entry, o)
+	if err != nil {
+		return entry, err
+	}
+	// Return Incomplete entry with Sequence number.
+	retEntry := &upspin.DirEntry{
+		Attr:     upspin.AttrIncomplete,
+		Sequence: entry.Sequence,
+	}
+	return retEntry, nil
+}
 
+// checkPut checks that the entry, already checked by valid.DirEntry, may
+// be put at p: that Access and Group files are well formed, that the user
+// has the right to create or overwrite it, and that its sequence number is
+// as required. If checkLink is true and the entry is a link, its
+// target is checked by checkLinkTarget. checkPut returns the existing entry
+// at p, or nil if there is none. If p crosses a link, it returns the link
+// and ErrFollowLink.
+func (s *server) checkPut(op errors.Op, p path.Parsed, entry *upspin.DirEntry, checkLink bool, o options) (*upspin.DirEntry, error) {
 	isAccess := access.IsAccessFile(p.Path())
 	isGroup := access.IsGroupFile(p.Path())
 	isLink := entry.IsLink()
@@ -365,7 +601,7 @@ func (s *server) Put(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
 	}
 	if isGroupFile {
 		// Validate group files at Put time to detect bad ones early.
-		err = s.loadGroup(p, entry)
+		err := s.loadGroup(p, entry)
 		if err != nil {
 			return nil, errors.E(op, err)
 		}
@@ -382,10 +618,11 @@ func (s *server) Put(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
 	// Check for links along the path.
 	existingEntry, err := s.lookup(p, !entryMustBeClean, o)
 	if err == upspin.ErrFollowLink {
-		return s.errLink(op, existingEntry, o)
+		return existingEntry, err
 	}
 
 	if errors.Is(errors.NotExist, err) {
+		existingEntry = nil
 		// OK; entry not found as expected. Can we create it?
 		canCreate, _, err := s.hasRight(access.Create, p, o)
 		if err == upspin.ErrFollowLink {
@@ -440,32 +677,29 @@ func (s *server) Put(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
 			}
 		}
 
-		// If we're updating an Access file delete it from the cache and
-		// let it be re-loaded lazily when needed again.
-		if access.IsAccessFile(entry.Name) {
-			s.access.Remove(entry.Name)
-		}
-		// If we're updating a Group file, remove the old one from the
-		// access group cache. Let the new one be loaded lazily.
-		if access.IsGroupFile(entry.Name) {
-			err = access.RemoveGroup(entry.Name)
-			if err != nil {
-				// Nothing to do but log.
-				log.Error.Printf("%s: Error removing group file %s: %s", op, entry.Name, err)
-			}
+	}
+
+	if isLink && checkLink {
+		if err := s.checkLinkTarget(p, entry.Link, o); err != nil {
+			return nil, errors.E(op, p.Path(), err)
 		}
 	}
+	return existingEntry, nil
+}
 
-	entry, err = s.put(op, p, entry, o)
-	if err != nil {
-		return entry, err
+// forgetAccessControl removes p from the Access and Group file caches,
+// if it is such a file, so it is loaded afresh when next needed.
+func (s *server) forgetAccessControl(op errors.Op, p path.Parsed) {
+	if access.IsAccessFile(p.Path()) {
+		s.access.Remove(p.Path())
 	}
-	// Return Incomplete entry with Sequence number.
-	retEntry := &upspin.DirEntry{
-		Attr:     upspin.AttrIncomplete,
-		Sequence: entry.Sequence,
+	if access.IsGroupFile(p.Path()) {
+		if err := access.RemoveGroup(p.Path()); err != nil {
+			// Nothing to do but log (it may not have been loaded
+			// yet, so it's not an error).
+			logger.Printf("%s: Error removing group file %s: %s", op, p.Path(), err)
+		}
 	}
-	return retEntry, nil
 }
 
 // put performs Put on the user's tree.
@@ -488,9 +722,49 @@ func (s *server) put(op errors.Op, p path.Parsed, entry *upspin.DirEntry, opts .
 	return entry, nil
 }
 
+// checkLinkTarget checks the target of a link being put at p. The target's
+// syntax, including its user name, has already been checked by
+// valid.DirEntry. If it is in the same tree as the link, it must also
+// exist, although if the caller cannot see whether it exists the check is
+// skipped rather than reveal anything. Targets in other users' trees,
+// which may be on other servers, are not looked up.
+// Failures have kind BrokenLink.
+func (s *server) checkLinkTarget(p path.Parsed, target upspin.PathName, opts ...options) error {
+	t, err := path.Parse(target)
+	if err != nil {
+		return errors.E(errors.BrokenLink, err)
+	}
+	if t.User() != p.User() {
+		return nil
+	}
+	const op errors.Op = "dir/server.checkLinkTarget"
+	_, err = s.lookupWithPermissions(op, t.Path(), opts...)
+	if errors.Is(errors.NotExist, err) {
+		return errors.E(errors.BrokenLink, errors.Errorf("link target %s does not exist", t.Path()))
+	}
+	// Anything else, including ErrFollowLink for a target
+	// reached through another link, is accepted.
+	return nil
+}
+
 // Glob implements upspin.DirServer.
-func (s *server) Glob(pattern string) ([]*upspin.DirEntry, error) {
+func (s *server) Glob(pattern string) (_ []*upspin.DirEntry, err error) {
 	const op errors.Op = "dir/server.Glob"
+	defer ops.Observe("Glob", time.Now(), &err)
+	defer s.recordAccess("Glob", &err, upspin.PathName(pattern))
+	return s.glob(op, pattern, false)
+}
+
+// GlobShallow implements upspin.ShallowGlobber.
+func (s *server) GlobShallow(pattern string) (_ []*upspin.DirEntry, err error) {
+	const op errors.Op = "dir/server.GlobShallow"
+	defer ops.Observe("GlobShallow", time.Now(), &err)
+	defer s.recordAccess("GlobShallow", &err, upspin.PathName(pattern))
+	return s.glob(op, pattern, true)
+}
+
+// glob implements Glob and, if shallow is set, GlobShallow.
+func (s *server) glob(op errors.Op, pattern string, shallow bool) ([]*upspin.DirEntry, error) {
 	o, m := newOptMetric(op)
 	defer m.Done()
 
@@ -501,24 +775,32 @@ func (s *server) Glob(pattern string) ([]*upspin.DirEntry, error) {
 		defer ss.End()
 		return s.lookupWithPermissions(op, name, o)
 	}
-	// lookup implements serverutil.ListFunc. It checks permissions.
-	listDir := func(dirName upspin.PathName) ([]*upspin.DirEntry, error) {
+	// listDir implements serverutil.ListPrefixFunc. It checks permissions.
+	listDir := func(dirName upspin.PathName, prefix string) ([]*upspin.DirEntry, error) {
 		const op errors.Op = "dir/server.listDir"
 		o, ss := subspan(op, []options{o})
 		defer ss.End()
-		return s.listDir(op, dirName, o)
+		return s.listDir(op, dirName, prefix, shallow, o)
 	}
 
-	entries, err := serverutil.Glob(pattern, lookup, listDir)
+	entries, err := serverutil.GlobPrefix(pattern, lookup, listDir)
 	if err != nil && err != upspin.ErrFollowLink {
 		err = errors.E(op, err)
 	}
+	if shallow {
+		for _, e := range entries {
+			e.MarkIncomplete()
+		}
+	}
 	return entries, err
 }
 
-// listDir implements serverutil.ListFunc, with an additional options variadic.
-// dirName should always be a directory. It checks permissions.
-func (s *server) listDir(op errors.Op, dirName upspin.PathName, opts ...options) ([]*upspin.DirEntry, error) {
+// listDir implements serverutil.ListPrefixFunc, with an additional options
+// variadic. dirName should always be a directory. It checks permissions.
+// If prefix is not empty, only the entries whose names begin with it are
+// listed. If shallow is set, the caller will discard the entries' blocks,
+// so dirty entries are not flushed.
+func (s *server) listDir(op errors.Op, dirName upspin.PathName, prefix string, shallow bool, opts ...options) ([]*upspin.DirEntry, error) {
 	parsed, err := path.Parse(dirName)
 	if err != nil {
 		return nil, errors.E(op, err)
@@ -531,7 +813,7 @@ func (s *server) listDir(op errors.Op, dirName upspin.PathName, opts ...options)
 
 	// Fetch the directory's contents. Don't return the error from List
 	// until we know if we have List rights.
-	entries, isDirty, listErr := tree.List(parsed)
+	entries, isDirty, listErr := s.list(tree, parsed, prefix)
 	if listErr == upspin.ErrFollowLink {
 		entry, err := s.errLink(op, entries[0], opts...)
 		if entry != nil {
@@ -555,14 +837,14 @@ func (s *server) listDir(op errors.Op, dirName upspin.PathName, opts ...options)
 	}
 	canRead, _, _ = s.hasRight(access.Read, parsed, opts...)
 
-	if canRead && isDirty {
+	if canRead && isDirty && !shallow {
 		// User wants DirEntries with valid blocks, so we must flush
 		// the Tree if something is dirty and try again.
 		err = tree.Flush()
 		if err != nil {
 			return nil, errors.E(op, err)
 		}
-		entries, _, err = tree.List(parsed)
+		entries, _, err = s.list(tree, parsed, prefix)
 		if err != nil { // Not ErrFollowLink
 			return nil, errors.E(op, err)
 		}
@@ -577,9 +859,38 @@ func (s *server) listDir(op errors.Op, dirName upspin.PathName, opts ...options)
 	return entries, nil
 }
 
+// list returns the contents of the directory, as does tree.List, from
+// s.listings if the directory is unchanged since it was last listed.
+// If prefix is not empty, it returns only the entries whose names begin
+// with it, as does tree.ListPrefix, which is faster than filtering a
+// listing of a large directory.
+func (s *server) list(t *tree.Tree, p path.Parsed, prefix string) ([]*upspin.DirEntry, bool, error) {
+	if prefix != "" {
+		return t.ListPrefix(p, prefix)
+	}
+	dir, dirty, err := t.Lookup(p)
+	if err != nil || dirty || !dir.IsDir() {
+		// Let List report the error or link, or the dirty entries.
+		return t.List(p)
+	}
+	if entries, ok := s.listings.get(p.Path(), dir.Sequence); ok {
+		return entries, false, nil
+	}
+	entries, dirty, err := t.List(p)
+	if err == nil && !dirty {
+		// The directory may have changed since it was looked up,
+		// in which case this listing has a later sequence number
+		// and will not be used.
+		s.listings.add(p.Path(), dir.Sequence, entries)
+	}
+	return entries, dirty, err
+}
+
 // Delete implements upspin.DirServer.
-func (s *server) Delete(name upspin.PathName) (*upspin.DirEntry, error) {
+func (s *server) Delete(name upspin.PathName) (_ *upspin.DirEntry, err error) {
 	const op errors.Op = "dir/server.Delete"
+	defer ops.Observe("Delete", time.Now(), &err)
+	defer s.recordAccess("Delete", &err, name)
 	o, m := newOptMetric(op)
 	defer m.Done()
 
@@ -598,6 +909,9 @@ func (s *server) Delete(name upspin.PathName) (*upspin.DirEntry, error) {
 	if !canDelete {
 		return nil, s.errPerm(op, p, o)
 	}
+	if err := s.txns.checkFree([]path.Parsed{p}, []bool{true}); err != nil {
+		return nil, errors.E(op, err)
+	}
 
 	// Load the tree for this user.
 	t, err := s.loadTreeFor(p.User(), o)
@@ -608,23 +922,15 @@ func (s *server) Delete(name upspin.PathName) (*upspin.DirEntry, error) {
 	if err != nil {
 		return entry, err // could be ErrFollowLink.
 	}
-	// If we just deleted an Access file, remove it from the access cache
-	// too.
-	if access.IsAccessFile(p.Path()) {
-		s.access.Remove(p.Path())
-	}
-	// If we just deleted a Group file, remove it from the Group cache too.
-	if access.IsGroupFile(p.Path()) {
-		err = access.RemoveGroup(p.Path())
-		if err != nil {
-			// Nothing to do but log (it may not have been loaded
-			// yet, so it's not an error).
-			log.Printf("%s: Error removing group file: %s", op, err)
-		}
-	}
+	// If we just deleted an Access or Group file, remove it from the
+	// caches too.
+	s.forgetAccessControl(op, p)
 	// If we just deleted the root, close the tree, remove it from the cache
 	// and delete all logs associated with the tree owner.
 	if p.IsRoot() {
+		// A new root restarts the sequence numbers, which would
+		// make old listings look current.
+		s.listings.purge()
 		user := t.User()
 		if err := s.closeTree(p.User()); err != nil {
 			return nil, errors.E(op, name, err)
@@ -637,12 +943,198 @@ func (s *server) Delete(name upspin.PathName) (*upspin.DirEntry, error) {
 	return entry, nil
 }
 
+// ApplyBatch implements upspin.DirBatcher.
+func (s *server) ApplyBatch(changes []upspin.DirChange) (_ []*upspin.DirEntry, err error) {
+	const op errors.Op = "dir/server.ApplyBatch"
+	defer ops.Observe("ApplyBatch", time.Now(), &err)
+	defer s.recordAccess("ApplyBatch", &err, changeNames(changes)...)
+	o, m := newOptMetric(op)
+	defer m.Done()
+
+	if len(changes) == 0 {
+		return nil, nil
+	}
+	b, err := s.checkBatch(op, changes, o)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.txns.checkFree(b.names, b.deletes); err != nil {
+		return nil, errors.E(op, err)
+	}
+	return s.applyBatch(op, b, o)
+}
+
+// A batch is a list of changes to a user's tree checked by checkBatch.
+type batch struct {
+	user    upspin.UserName
+	names   []path.Parsed
+	deletes []bool
+	entries []*serverlog.Entry
+}
+
+// newBatch returns the batch of the changes, which it checks are to the
+// tree of a single user, but not whether they may be made.
+func newBatch(op errors.Op, changes []upspin.DirChange) (*batch, error) {
+	if len(changes) > upspin.MaxBatchChanges {
+		return nil, errors.E(op, errors.Invalid, errors.Errorf("%d changes in batch; limit is %d", len(changes), upspin.MaxBatchChanges))
+	}
+
+	b := &batch{
+		names:   make([]path.Parsed, len(changes)),
+		deletes: make([]bool, len(changes)),
+		entries: make([]*serverlog.Entry, len(changes)),
+	}
+	for i, c := range changes {
+		if c.Entry == nil {
+			return nil, errors.E(op, errors.Invalid, "nil entry in batch")
+		}
+		p, err := path.Parse(c.Entry.Name)
+		if err != nil {
+			return nil, errors.E(op, c.Entry.Name, err)
+		}
+		if i == 0 {
+			b.user = p.User()
+		} else if p.User() != b.user {
+			return nil, errors.E(op, p.Path(), errors.Invalid, "batch spans the trees of several users")
+		}
+		if isSnapshotUser(b.user) {
+			return nil, errors.E(op, p.Path(), errors.Invalid, "cannot apply batch to a snapshot tree")
+		}
+		if p.IsRoot() {
+			return nil, errors.E(op, p.Path(), errors.Invalid, "cannot put or delete a root in a batch")
+		}
+		if c.Delete {
+			b.entries[i] = &serverlog.Entry{
+				Op:    serverlog.Delete,
+				Entry: upspin.DirEntry{Name: p.Path()},
+			}
+		} else {
+			b.entries[i] = &serverlog.Entry{
+				Op:    serverlog.Put,
+				Entry: *c.Entry,
+			}
+		}
+		b.names[i] = p
+		b.deletes[i] = c.Delete
+	}
+	return b, nil
+}
+
+// checkBatch checks every change against the tree as it is now, for
+// ApplyBatch and PreparePut.
+func (s *server) checkBatch(op errors.Op, changes []upspin.DirChange, o options) (*batch, error) {
+	b, err := newBatch(op, changes)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range b.names {
+		if b.deletes[i] {
+			if err := s.checkDelete(op, p, o); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		entry := changes[i].Entry
+		if err := valid.DirEntry(entry); err != nil {
+			return nil, errors.E(op, err)
+		}
+		// Link targets are not checked, as they may be
+		// created or moved by the batch itself.
+		if _, err := s.checkPut(op, p, entry, false, o); err == upspin.ErrFollowLink {
+			return nil, errors.E(op, p.Path(), errors.Invalid, "path in batch crosses a link")
+		} else if err != nil {
+			return nil, err
+		}
+	}
+	return b, nil
+}
+
+// applyBatch applies a batch checked by checkBatch, atomically.
+func (s *server) applyBatch(op errors.Op, b *batch, o options) ([]*upspin.DirEntry, error) {
+	t, err := s.loadTreeFor(b.user, o)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	result, err := t.Apply(b.entries)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	for i, p := range b.names {
+		s.forgetAccessControl(op, p)
+		if !b.deletes[i] {
+			// As in Put, return an Incomplete entry with the
+			// Sequence number.
+			result[i] = &upspin.DirEntry{
+				Attr:     upspin.AttrIncomplete,
+				Sequence: result[i].Sequence,
+			}
+		}
+	}
+	return result, nil
+}
+
+// checkDelete checks that the user may delete p, which must not cross a
+// link, for ApplyBatch.
+func (s *server) checkDelete(op errors.Op, p path.Parsed, o options) error {
+	canDelete, _, err := s.hasRight(access.Delete, p, o)
+	if err == upspin.ErrFollowLink {
+		return errors.E(op, p.Path(), errors.Invalid, "path in batch crosses a link")
+	}
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if !canDelete {
+		return s.errPerm(op, p, o)
+	}
+	return nil
+}
+
 // WhichAccess implements upspin.DirServer.
-func (s *server) WhichAccess(name upspin.PathName) (*upspin.DirEntry, error) {
+func (s *server) WhichAccess(name upspin.PathName) (_ *upspin.DirEntry, err error) {
 	const op errors.Op = "dir/server.WhichAccess"
+	defer ops.Observe("WhichAccess", time.Now(), &err)
+	defer s.recordAccess("WhichAccess", &err, name)
 	o, m := newOptMetric(op)
 	defer m.Done()
 
+	return s.whichAccessFor(op, name, o)
+}
+
+// WhichAccessBatch implements upspin.WhichAccessBatcher.
+func (s *server) WhichAccessBatch(names []upspin.PathName) (_ []upspin.WhichAccessResult, err error) {
+	const op errors.Op = "dir/server.WhichAccessBatch"
+	defer ops.Observe("WhichAccessBatch", time.Now(), &err)
+	defer s.recordAccess("WhichAccessBatch", &err, names...)
+	o, m := newOptMetric(op)
+	defer m.Done()
+
+	if len(names) > upspin.MaxWhichAccessBatch {
+		return nil, errors.E(op, errors.Invalid, errors.Errorf("%d names in batch; limit is %d", len(names), upspin.MaxWhichAccessBatch))
+	}
+	type version struct {
+		name upspin.PathName
+		seq  int64
+	}
+	seen := make(map[version]*upspin.DirEntry)
+	results := make([]upspin.WhichAccessResult, len(names))
+	for i, name := range names {
+		entry, err := s.whichAccessFor(op, name, o)
+		if err == nil && entry != nil {
+			// Share the entry among the names it controls.
+			v := version{entry.Name, entry.Sequence}
+			if e, ok := seen[v]; ok {
+				entry = e
+			} else {
+				seen[v] = entry
+			}
+		}
+		results[i] = upspin.WhichAccessResult{Entry: entry, Error: err}
+	}
+	return results, nil
+}
+
+// whichAccessFor implements WhichAccess and WhichAccessBatch for one name.
+func (s *server) whichAccessFor(op errors.Op, name upspin.PathName, o options) (*upspin.DirEntry, error) {
 	p, err := path.Parse(name)
 	if err != nil {
 		return nil, errors.E(op, name, err)
@@ -664,51 +1156,115 @@ func (s *server) WhichAccess(name upspin.PathName) (*upspin.DirEntry, error) {
 }
 
 // Watch implements upspin.DirServer.Watch.
-func (s *server) Watch(name upspin.PathName, sequence int64, done <-chan struct{}) (<-chan upspin.Event, error) {
+func (s *server) Watch(name upspin.PathName, sequence int64, done <-chan struct{}) (_ <-chan upspin.Event, err error) {
 	const op errors.Op = "dir/server.Watch"
+	defer ops.Observe("Watch", time.Now(), &err)
+	defer s.recordAccess("Watch", &err, name)
 	o, m := newOptMetric(op)
 	defer m.Done()
 
-	p, err := path.Parse(name)
-	if err != nil {
-		return nil, errors.E(op, name, err)
-	}
+	return s.watchPaths(op, []upspin.WatchPath{{Name: name, Sequence: sequence}}, false, done, o)
+}
 
-	// Don't permit Watches of snapshot trees.
-	// See issue #536.
-	if isSnapshotUser(p.User()) {
-		return nil, upspin.ErrNotSupported
-	}
+// WatchMulti implements upspin.MultiWatcher.
+func (s *server) WatchMulti(paths []upspin.WatchPath, done <-chan struct{}) (_ <-chan upspin.Event, err error) {
+	const op errors.Op = "dir/server.WatchMulti"
+	defer ops.Observe("WatchMulti", time.Now(), &err)
+	defer s.recordAccess("WatchMulti", &err, watchNames(paths)...)
+	o, m := newOptMetric(op)
+	defer m.Done()
 
-	tree, err := s.loadTreeFor(p.User(), o)
-	if err != nil {
-		return nil, errors.E(op, err)
+	if len(paths) == 0 {
+		return nil, errors.E(op, errors.Invalid, "no paths to watch")
 	}
-
-	// Establish a channel with the tree and start a goroutine that filters
-	// out requests not visible by the caller.
-	treeEvents, err := tree.Watch(p, sequence, done)
-	if err != nil {
-		return nil, errors.E(op, err)
+	seen := make(map[upspin.PathName]bool)
+	for _, wp := range paths {
+		if seen[wp.Name] {
+			return nil, errors.E(op, wp.Name, errors.Invalid, "path watched twice")
+		}
+		seen[wp.Name] = true
 	}
-	events := make(chan upspin.Event, 1)
+	return s.watchPaths(op, paths, true, done, o)
+}
+
+// watchPaths implements Watch and WatchMulti. It watches each of the paths
+// and merges their events into one channel. If tag is set, each event's
+// Root is set to the name of the path to which it pertains.
+func (s *server) watchPaths(op errors.Op, paths []upspin.WatchPath, tag bool, done <-chan struct{}, o options) (<-chan upspin.Event, error) {
+	// The tree watchers stop when stop is closed, which happens when
+	// done is closed or, if setting up fails, at once.
+	stop := make(chan struct{})
+	finished := make(chan struct{})
+
+	treeEvents := make([]<-chan *upspin.Event, len(paths))
+	for i, wp := range paths {
+		p, err := path.Parse(wp.Name)
+		if err != nil {
+			close(stop)
+			return nil, errors.E(op, wp.Name, err)
+		}
+
+		// Don't permit Watches of snapshot trees.
+		// See issue #536.
+		if isSnapshotUser(p.User()) {
+			close(stop)
+			return nil, upspin.ErrNotSupported
+		}
 
-	go s.watch(op, treeEvents, events)
+		tree, err := s.loadTreeFor(p.User(), o)
+		if err != nil {
+			close(stop)
+			return nil, errors.E(op, err)
+		}
+
+		// Establish a channel with the tree. A goroutine below filters
+		// out requests not visible by the caller.
+		treeEvents[i], err = tree.Watch(p, wp.Sequence, stop)
+		if err != nil {
+			close(stop)
+			return nil, errors.E(op, err)
+		}
+	}
 
+	events := make(chan upspin.Event, 1)
+	var wg sync.WaitGroup
+	for i, wp := range paths {
+		var root upspin.PathName
+		if tag {
+			root = wp.Name
+		}
+		wg.Add(1)
+		go func(treeEvents <-chan *upspin.Event) {
+			defer wg.Done()
+			s.watch(op, root, treeEvents, events)
+		}(treeEvents[i])
+	}
+	go func() {
+		wg.Wait()
+		close(events)
+		close(finished)
+	}()
+	go func() {
+		select {
+		case <-done:
+			close(stop)
+		case <-finished:
+		}
+	}()
 	return events, nil
 }
 
-// watcher runs in a goroutine reading events from the tree and passing them
+// watch runs in a goroutine reading events from the tree and passing them
 // along to the original caller, but first verifying whether the user has rights
-// to know about the event.
-func (s *server) watch(op errors.Op, treeEvents <-chan *upspin.Event, outEvents chan<- upspin.Event) {
+// to know about the event. Each event's Root is set to root.
+func (s *server) watch(op errors.Op, root upspin.PathName, treeEvents <-chan *upspin.Event, outEvents chan<- upspin.Event) {
 	const sendTimeout = time.Minute
 
 	t := time.NewTimer(sendTimeout)
-	defer close(outEvents)
 	defer t.Stop()
 
 	sendEvent := func(e *upspin.Event) bool {
+		e.Root = root
 		// Send e on outEvents, with a timeout.
 		if !t.Stop() {
 			<-t.C
@@ -720,7 +1276,7 @@ func (s *server) watch(op errors.Op, treeEvents <-chan *upspin.Event, outEvents
 			return true
 		case <-t.C:
 			// Timed out.
-			log.Printf("%s: timeout sending event for %s", op, s.userName)
+			logger.Printf("%s: timeout sending event for %s", op, s.userName)
 			return false
 		}
 	}
@@ -810,7 +1366,7 @@ func (s *server) Close() {
 	// garbage-collected even if other servers have pointers into the
 	// cache (which at least one will have, the one created with New).
 	if err := s.closeTree(s.userName); err != nil {
-		log.Error.Printf("%s: Error closing user tree %q: %q", op, s.userName, err)
+		logger.Error.Printf("%s: Error closing user tree %q: %q", op, s.userName, err)
 	}
 
 	if !s.dialed {
@@ -843,6 +1399,7 @@ func (s *server) loadTreeFor(userName upspin.UserName, opts ...options) (*tree.T
 	// Do we have a cached tree for this user already?
 	if val, found := s.userTrees.Get(userName); found {
 		if tree, ok := val.(*tree.Tree); ok {
+			s.trimTrees(userName)
 			return tree, nil
 		}
 		// This should never happen because we only store type tree.Tree in the userTree.
@@ -863,6 +1420,8 @@ func (s *server) loadTreeFor(userName upspin.UserName, opts ...options) (*tree.T
 	if err != nil {
 		return nil, err
 	}
+	user.SetSyncPolicy(s.logSync)
+	user.SetOnChange(s.logChanges.changed)
 	// If user has root, we can load the tree from it.
 	if _, err := user.Root(); err != nil {
 		// Likely the user has no root yet.
@@ -885,9 +1444,48 @@ func (s *server) loadTreeFor(userName upspin.UserName, opts ...options) (*tree.T
 	}
 	// Add to the cache and return
 	s.userTrees.Add(userName, tree)
+	s.trimTrees(userName)
 	return tree, nil
 }
 
+// trimTrees evicts the least recently used trees until the memory used by
+// the cached trees is within s.treeCacheBytes. It never evicts the tree of
+// the named user, which the caller is about to use, nor any tree that is
+// not evictable, such as one with dirty entries. It also updates the
+// dirserver-tree-bytes variable.
+func (s *server) trimTrees(keep upspin.UserName) {
+	var total int64
+	it := s.userTrees.NewIterator()
+	for {
+		_, v, ok := it.GetAndAdvance()
+		if !ok {
+			break
+		}
+		total += v.(*tree.Tree).MemoryBytes()
+	}
+	if s.treeCacheBytes > 0 && total > s.treeCacheBytes {
+		it = s.userTrees.NewReverseIterator()
+		for total > s.treeCacheBytes {
+			k, v, ok := it.GetAndAdvance()
+			if !ok {
+				break
+			}
+			user, t := k.(upspin.UserName), v.(*tree.Tree)
+			if user == keep || !t.Evictable() {
+				continue
+			}
+			// Another goroutine may be about to use the tree, so
+			// leave it to the garbage collector to close it, as
+			// when the LRU evicts it.
+			s.userTrees.Remove(user)
+			t.OnEviction(user)
+			total -= t.MemoryBytes()
+			logger.Debug.Printf("dir/server: evicted tree for %s; tree cache now %d bytes", user, total)
+		}
+	}
+	treeBytes.Set(total)
+}
+
 // canCreateRoot reports whether the current user can create a root for the
 // named user.
 func (s *server) canCreateRoot(user upspin.UserName) bool {
@@ -947,7 +1545,7 @@ func (s *server) shutdown() {
 		user := k.(upspin.UserName)
 		err := s.closeTree(user)
 		if err != nil {
-			log.Printf("error closing tree for user %s: %v", user, err)
+			logger.Printf("error closing tree for user %s: %v", user, err)
 		}
 	}
 }
@@ -33,10 +33,15 @@ for historical reasons, to be the varint encoding of signed 0 or 1.
 
 A record looks like this in the logs:
 
-one byte: the Op, 0x00 for a Put, 0x02 for a Delete.
+one byte: the Op, 0x00 for a Put, 0x02 for a Delete, plus 0x04 if the
+record is part of a batch and more records of the batch follow.
 N bytes: the result of calling DirEntry.Marshal for the entry.
 4 bytes: a simple checksum calculated by the checksum function.
 
+The records of a batch, written by AppendBatch, must be applied together.
+A batch whose last record is missing, as after a crash during the write,
+is discarded when the log is recovered.
+
 To prevent problems with corrupted logs, a marshaled DirEntry is
 required to fit within 64MB.
 
@@ -47,7 +47,11 @@ func main() {
 			if file.version == 0 {
 				le.Entry.Sequence &= version0SeqMask
 			}
-			fmt.Printf("%d: %q: op %s seq %d off %d\n", i, le.Entry.Name, le.Op, le.Entry.Sequence, offset+file.offset)
+			batched := ""
+			if le.Batched {
+				batched = " (batched)"
+			}
+			fmt.Printf("%d: %q: op %s seq %d off %d%s\n", i, le.Entry.Name, le.Op, le.Entry.Sequence, offset+file.offset, batched)
 			offset += int64(count)
 		}
 	}
@@ -55,8 +59,9 @@ func main() {
 
 // Entry is the unit of logging.
 type Entry struct {
-	Op    Operation
-	Entry upspin.DirEntry
+	Op      Operation
+	Entry   upspin.DirEntry
+	Batched bool
 }
 
 const version0SeqMask = 1<<23 - 1
@@ -144,7 +149,7 @@ func (le *Entry) unmarshal(fd io.ReaderAt, data []byte, offset int64) (int, erro
 	if err != nil && err != io.EOF || nRead < 8 { // Sanity check.
 		return 0, errors.E(errors.IO, errors.Errorf("reading op: %s", err))
 	}
-	switch data[0] {
+	switch data[0] &^ 0x04 {
 	case 0x00:
 		le.Op = Put
 	case 0x02:
@@ -152,6 +157,7 @@ func (le *Entry) unmarshal(fd io.ReaderAt, data []byte, offset int64) (int, erro
 	default:
 		return 0, errors.E(errors.Invalid, errors.Errorf("unknown Op %d", data[0]))
 	}
+	le.Batched = data[0]&0x04 != 0
 
 	size, n := binary.Varint(data[1:])
 	if n <= 0 {
@@ -0,0 +1,215 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package serverlog
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// History is a read-only view of a user's logs, for tools such as backup
+// programs that stream a user's history elsewhere. Unlike Open, OpenUser
+// never creates, moves or writes files, so it may be used on the logs of a
+// running server.
+//
+// The view is fixed when OpenUser is called: it holds the root and
+// checkpoint saved at that time, and its Cursors return only the records
+// up to that checkpoint, which the root reflects. Records appended later,
+// including any being written, are not returned.
+type History struct {
+	user       *User
+	root       *upspin.DirEntry
+	checkpoint int64
+}
+
+// A Record is a log record returned by a Cursor.
+type Record struct {
+	Op    Operation
+	Entry *upspin.DirEntry
+
+	// Offset is the global offset of the record in the user's logs.
+	Offset int64
+}
+
+// OpenUser returns a read-only view of the logs of the named user in the
+// directory. Both the current format and the legacy version 0 format,
+// including a legacy single log file not yet moved by Open, are read.
+func OpenUser(userName upspin.UserName, directory string) (*History, error) {
+	const op errors.Op = "dir/server/serverlog.OpenUser"
+	u := &User{
+		name:      userName,
+		directory: directory,
+	}
+	h := &History{
+		user: u,
+	}
+
+	// Read the checkpoint before the root; the server saves them in
+	// that order, so the root is at least as new as the checkpoint.
+	buf, err := os.ReadFile(u.checkpointFile())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.E(op, userName, errors.IO, err)
+	}
+	if len(buf) > 0 {
+		offset, n := binary.Varint(buf)
+		if n <= 0 {
+			return nil, errors.E(op, userName, errors.Invalid, "invalid checkpoint")
+		}
+		h.checkpoint = offset
+	}
+	buf, err = os.ReadFile(u.rootFile())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.E(op, userName, errors.IO, err)
+	}
+	if len(buf) > 0 {
+		h.root = new(upspin.DirEntry)
+		if _, err := h.root.Unmarshal(buf); err != nil {
+			return nil, errors.E(op, userName, errors.Invalid, err)
+		}
+	}
+
+	u.findLogFiles(u.logSubDir())
+	if len(u.files) == 0 {
+		// A legacy log that Open has not yet moved.
+		name := filepath.Join(directory, oldStyleLogFilePrefix+string(userName))
+		if _, err := os.Stat(name); err == nil {
+			u.files = []*logFile{{name: name}}
+		}
+	}
+	if len(u.files) == 0 && h.root == nil {
+		return nil, errors.E(op, userName, errors.NotExist, "no logs")
+	}
+	// Remove duplicates, as may be left by an interrupted move of
+	// a version 0 log.
+	files := u.files[:0]
+	for _, f := range u.files {
+		if len(files) > 0 && files[len(files)-1].offset == f.offset {
+			continue
+		}
+		f.index = len(files)
+		files = append(files, f)
+	}
+	u.files = files
+	return h, nil
+}
+
+// User returns the name of the user whose logs are viewed.
+func (h *History) User() upspin.UserName {
+	return h.user.name
+}
+
+// Root returns the user's root as saved when the History was opened,
+// or nil if none had been saved.
+func (h *History) Root() *upspin.DirEntry {
+	return h.root
+}
+
+// Checkpoint returns the offset in the logs up to which the root reflects
+// the log records. Cursors stop there. It is zero if no checkpoint had
+// been saved, in which case there are no records to read.
+func (h *History) Checkpoint() int64 {
+	return h.checkpoint
+}
+
+// NewCursor returns a Cursor that reads the records starting at offset,
+// which must be at a record boundary.
+func (h *History) NewCursor(offset int64) *Cursor {
+	return &Cursor{
+		h:      h,
+		offset: offset,
+		data:   make([]byte, 4096),
+	}
+}
+
+// Cursor iterates over the records in a History.
+// A Cursor is not safe for concurrent use.
+type Cursor struct {
+	h      *History
+	offset int64
+	rec    Record
+	err    error
+
+	file *logFile
+	fd   *os.File
+	data []byte
+}
+
+// Next advances the cursor to the next record, which is then available
+// through Record. It returns false when there are no more records or an
+// error occurs; Err distinguishes the cases.
+func (c *Cursor) Next() bool {
+	if c.err != nil || c.offset >= c.h.checkpoint {
+		return false
+	}
+	if err := c.openFile(); err != nil {
+		c.err = err
+		return false
+	}
+	var le Entry
+	n, err := le.unmarshal(c.fd, c.data, c.offset-c.file.offset)
+	if err != nil {
+		c.err = errors.E(c.h.user.name, errors.Errorf("offset %d: %v", c.offset, err))
+		return false
+	}
+	if c.file.version == 0 {
+		le.Entry.Sequence &= version0SeqMask
+	}
+	c.rec = Record{
+		Op:     le.Op,
+		Entry:  &le.Entry,
+		Offset: c.offset,
+	}
+	c.offset += int64(n)
+	return true
+}
+
+// openFile makes sure c.fd is the log file holding c.offset.
+func (c *Cursor) openFile() error {
+	files := c.h.user.files
+	i := len(files) - 1
+	for i > 0 && files[i].offset > c.offset {
+		i--
+	}
+	if i < 0 || files[i].offset > c.offset {
+		return errors.E(c.h.user.name, errors.NotExist, errors.Errorf("no log file for offset %d", c.offset))
+	}
+	if c.file == files[i] {
+		return nil
+	}
+	if c.fd != nil {
+		c.fd.Close()
+		c.fd = nil
+	}
+	fd, err := os.Open(files[i].name)
+	if err != nil {
+		return errors.E(c.h.user.name, errors.IO, err)
+	}
+	c.file, c.fd = files[i], fd
+	return nil
+}
+
+// Record returns the record most recently read by Next.
+func (c *Cursor) Record() Record {
+	return c.rec
+}
+
+// Err returns the error, if any, that stopped the cursor.
+func (c *Cursor) Err() error {
+	return c.err
+}
+
+// Close releases the resources held by the cursor.
+func (c *Cursor) Close() error {
+	if c.fd == nil {
+		return nil
+	}
+	err := c.fd.Close()
+	c.fd = nil
+	return err
+}
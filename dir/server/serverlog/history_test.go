@@ -0,0 +1,124 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package serverlog
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"upspin.io/upspin"
+)
+
+func TestHistory(t *testing.T) {
+	dir, cleanup := setup(t, "History")
+	defer cleanup()
+	makeVerifyLogs(t, dir)
+	before := snapshot(t, dir)
+
+	h, err := OpenUser(verifyUser, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := h.Checkpoint(), int64(8*recSize); got != want {
+		t.Errorf("Checkpoint = %d, want %d", got, want)
+	}
+	if h.Root() == nil || h.Root().Name != upspin.PathName(verifyUser)+"/" {
+		t.Errorf("Root = %v, want entry for %s/", h.Root(), verifyUser)
+	}
+
+	// The records span four files; the last two are past the checkpoint.
+	for _, start := range []int64{0, 3 * recSize} {
+		c := h.NewCursor(start)
+		want := start
+		for c.Next() {
+			r := c.Record()
+			if r.Offset != want {
+				t.Fatalf("record at offset %d, want %d", r.Offset, want)
+			}
+			if r.Op != Put || r.Entry.Name != upspin.PathName(verifyUser)+"/testing-testing" {
+				t.Fatalf("offset %d: got %v %q", r.Offset, r.Op, r.Entry.Name)
+			}
+			want += recSize
+		}
+		if err := c.Err(); err != nil {
+			t.Fatal(err)
+		}
+		if want != 8*recSize {
+			t.Errorf("cursor from %d stopped at %d, want %d", start, want, 8*recSize)
+		}
+		c.Close()
+	}
+
+	if !reflect.DeepEqual(before, snapshot(t, dir)) {
+		t.Error("OpenUser modified the log directory")
+	}
+
+	if _, err := OpenUser("nobody@example.com", dir); err == nil {
+		t.Error("OpenUser succeeded for user without logs")
+	}
+}
+
+func TestHistoryVersion0(t *testing.T) {
+	const (
+		user = "user@example.com"
+		end  = 2738 // Size of the version 0 log.
+	)
+	data, err := os.ReadFile("testdata/version0/d.tree.log.user@example.com/0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, legacy := range []string{
+		"d.tree.log.user@example.com/0", // A version 0 log file.
+		"tree.log.user@example.com",     // A legacy log not yet moved by Open.
+	} {
+		dir, cleanup := setup(t, "HistoryVersion0")
+		defer cleanup()
+		name := filepath.Join(dir, legacy)
+		if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		var buf [binary.MaxVarintLen64]byte
+		n := binary.PutVarint(buf[:], end)
+		if err := os.WriteFile(filepath.Join(dir, checkpointFilePrefix+user), buf[:n], 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		h, err := OpenUser(user, dir)
+		if err != nil {
+			t.Fatalf("%s: %v", legacy, err)
+		}
+		// This is the series of events in the version 0 log; see TestVersion0.
+		sequence := []int64{0, 1, 1, 1, 1, 1, 2}
+		names := []upspin.PathName{"dir", "dir/file", "file", "file", "dir/file", "dir/file", "dir/file"}
+		ops := []Operation{Put, Put, Put, Delete, Delete, Put, Put}
+		c := h.NewCursor(0)
+		i := 0
+		for ; c.Next(); i++ {
+			r := c.Record()
+			if i >= len(names) {
+				t.Fatalf("%s: too many records", legacy)
+			}
+			if r.Entry.Sequence != sequence[i] || r.Entry.Name != user+"/"+names[i] || r.Op != ops[i] {
+				t.Errorf("%s: record %d: got %v %q seq %d; want %v %q seq %d", legacy, i, r.Op, r.Entry.Name, r.Entry.Sequence, ops[i], user+"/"+names[i], sequence[i])
+			}
+		}
+		if err := c.Err(); err != nil {
+			t.Fatalf("%s: %v", legacy, err)
+		}
+		if i != len(names) {
+			t.Errorf("%s: got %d records, want %d", legacy, i, len(names))
+		}
+		c.Close()
+		if _, err := os.Stat(filepath.Join(dir, "d.tree.log.user@example.com", "2738.1")); err == nil {
+			t.Errorf("%s: OpenUser created a log file", legacy)
+		}
+	}
+}
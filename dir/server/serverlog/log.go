@@ -47,6 +47,16 @@ type User struct {
 	// from version 0 to version 1. If there are no version 0
 	// logs, it will be zero.
 	v1Transition upspin.Time
+
+	// syncPolicy says when appends are flushed to stable storage.
+	// Under SyncInterval, group coordinates the appends waiting
+	// for a flush.
+	syncPolicy SyncPolicy
+	group      *groupSync
+
+	// onChange, if not nil, is called after each change to the log,
+	// root or checkpoint. See SetOnChange.
+	onChange func(upspin.UserName)
 }
 
 // Operation is the kind of operation performed on the DirEntry.
@@ -66,6 +76,11 @@ var MaxLogSize int64 = 100 * 1024 * 1024 // 100 MB
 type Entry struct {
 	Op    Operation
 	Entry upspin.DirEntry
+
+	// Batched is set if the entry is part of a batch appended by
+	// AppendBatch and is not the last entry of the batch. A batch must
+	// be replayed in its entirety or not at all.
+	Batched bool
 }
 
 // writer is an append-only log of Entry.
@@ -467,7 +482,10 @@ func (u *User) Close() error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
 
-	err1 := u.writer.close()
+	err1 := u.syncBeforeClose()
+	if err := u.writer.close(); err1 == nil {
+		err1 = err
+	}
 	err2 := u.checkpoint.close()
 	err3 := u.root.close()
 	if err1 != nil {
@@ -684,10 +702,83 @@ func (u *User) OffsetOf(seq int64) int64 {
 
 // Append appends a Entry to the end of the writer log.
 func (u *User) Append(e *Entry) error {
-	buf, err := e.marshal()
+	return u.appendAt(-1, e)
+}
+
+// AppendDeferred is like Append but, under the SyncInterval policy,
+// returns before the entry is flushed. The caller must call the returned
+// function, which waits for the flush, before reporting the append as
+// done. It lets the caller release its own locks while it waits, so that
+// other appends can share the flush.
+func (u *User) AppendDeferred(e *Entry) (wait func() error, err error) {
+	end, err := u.write(-1, []*Entry{e})
+	if err != nil {
+		return nil, err
+	}
+	if u.syncPolicy.Mode != SyncInterval {
+		return func() error { return nil }, nil
+	}
+	return func() error { return u.waitSync(end) }, nil
+}
+
+// AppendBatch appends the entries to the end of the writer log with a
+// single write, setting the Batched field of all but the last of them.
+// If it fails, the log is truncated to remove any part of the batch that
+// was written.
+func (u *User) AppendBatch(entries []*Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	for i, e := range entries {
+		e.Batched = i < len(entries)-1
+	}
+	offset := u.AppendOffset()
+	err := u.appendAt(-1, entries...)
+	if err != nil {
+		if tErr := u.Truncate(offset); tErr != nil {
+			log.Error.Printf("serverlog: truncating partial batch for %s: %v", u.name, tErr)
+		}
+	}
+	return err
+}
+
+// AppendAt appends a Entry to the end of the writer log, which must
+// end at the given offset. It is used to keep a copy of a log, such as
+// one held by a standby server, identical to the original.
+func (u *User) AppendAt(offset int64, e *Entry) error {
+	if offset < 0 {
+		return errors.E(errors.Invalid, u.name, errors.Errorf("invalid offset %d", offset))
+	}
+	return u.appendAt(offset, e)
+}
+
+// appendAt appends the entries to the writer log with a single write and
+// flushes them as the sync policy requires.
+// If want is not negative, the log must end at that offset.
+func (u *User) appendAt(want int64, entries ...*Entry) error {
+	end, err := u.write(want, entries)
 	if err != nil {
 		return err
 	}
+	if u.syncPolicy.Mode == SyncInterval {
+		return u.waitSync(end)
+	}
+	return nil
+}
+
+// write implements appendAt, except for waiting for a flush under the
+// SyncInterval policy. It returns the offset of the end of the entries.
+func (u *User) write(want int64, entries []*Entry) (int64, error) {
+	var buf []byte
+	sizes := make([]int64, len(entries))
+	for i, e := range entries {
+		b, err := e.marshal()
+		if err != nil {
+			return 0, err
+		}
+		buf = append(buf, b...)
+		sizes[i] = int64(len(b))
+	}
 
 	u.mu.Lock()
 	defer u.mu.Unlock()
@@ -696,18 +787,24 @@ func (u *User) Append(e *Entry) error {
 
 	prevSize := size(w.fd)
 	offset := w.file.offset + prevSize
+	if want >= 0 && offset != want {
+		return 0, errors.E(errors.Invalid, u.name, errors.Errorf("log ends at offset %d, not %d", offset, want))
+	}
 
 	// Is it time to move to a new log file?
 	if prevSize >= MaxLogSize {
 		// Close the current underlying log file.
-		err = w.close()
+		if err := u.syncBeforeClose(); err != nil {
+			return 0, err
+		}
+		err := w.close()
 		if err != nil {
-			return errors.E(errors.IO, err)
+			return 0, errors.E(errors.IO, err)
 		}
 		// Create a new log file where the previous one left off.
 		file, fd, err := u.createLogFile(w.file.offset + prevSize)
 		if err != nil {
-			return errors.E(errors.IO, err)
+			return 0, errors.E(errors.IO, err)
 		}
 		w.file = file
 		w.fd = fd
@@ -717,22 +814,28 @@ func (u *User) Append(e *Entry) error {
 	// File is append-only, so this is guaranteed to write to the tail.
 	n, err := w.fd.Write(buf)
 	if err != nil {
-		return errors.E(errors.IO, err)
+		return 0, errors.E(errors.IO, err)
 	}
-	err = w.fd.Sync()
-	if err != nil {
-		return errors.E(errors.IO, err)
+	if u.syncPolicy.Mode == SyncAlways {
+		err = syncFile(w.fd)
+		if err != nil {
+			return 0, errors.E(errors.IO, err)
+		}
 	}
-	// Sanity check: flush worked and the new offset relative to the
+	// Sanity check: the write worked and the new offset relative to the
 	// beginning of this file is the expected one.
 	newOffs := prevSize + int64(n)
 	if newOffs != size(w.fd) {
 		// This might indicate a race somewhere, despite the locks.
-		return errors.E(errors.IO, errors.Errorf("file.Sync did not update offset: expected %d, got %d", newOffs, size(w.fd)))
+		return 0, errors.E(errors.IO, errors.Errorf("file.Sync did not update offset: expected %d, got %d", newOffs, size(w.fd)))
 	}
 
-	u.addOffSeq(offset, e.Entry.Sequence)
-	return nil
+	for i, e := range entries {
+		u.addOffSeq(offset, e.Entry.Sequence)
+		offset += sizes[i]
+	}
+	u.changed()
+	return offset, nil
 }
 
 // addOffSeq remembers an offset/sequence pair.
@@ -863,6 +966,9 @@ func (u *User) Truncate(offset int64) error {
 	// Move the writer to that file, if not already there.
 	w := u.writer
 	if w.file != file {
+		if err := u.syncBeforeClose(); err != nil {
+			return err
+		}
 		if err := w.close(); err != nil {
 			return errors.E(errors.IO, err)
 		}
@@ -884,6 +990,8 @@ func (u *User) Truncate(offset int64) error {
 		w.fd.Seek(pos, io.SeekStart)
 	}
 	u.truncateOffSeqs(offset)
+	u.truncateSynced(offset)
+	u.changed()
 	return nil
 }
 
@@ -1000,7 +1108,11 @@ func (r *root) get() (*upspin.DirEntry, error) {
 func (u *User) SaveRoot(root *upspin.DirEntry) error {
 	u.mu.Lock()
 	defer u.mu.Unlock()
-	return u.root.put(root)
+	if err := u.root.put(root); err != nil {
+		return err
+	}
+	u.changed()
+	return nil
 }
 
 func (r *root) put(root *upspin.DirEntry) error {
@@ -1138,7 +1250,11 @@ func (cp *checkpoint) saveOffset(offset int64) error {
 	cp.user.mu.Lock()
 	defer cp.user.mu.Unlock()
 
-	return overwriteAndSync(cp.checkpointFile, tmp[:n])
+	if err := overwriteAndSync(cp.checkpointFile, tmp[:n]); err != nil {
+		return err
+	}
+	cp.user.changed()
+	return nil
 }
 
 // close closes the checkpoint. user.mu must be held
@@ -1156,14 +1272,19 @@ func (le *Entry) marshal() ([]byte, error) {
 	var b []byte
 	// For historical reasons, the entry was written with binary.PutVarint,
 	// but that adds unnecessary overhead.
+	var op byte
 	switch le.Op {
 	case Put:
-		b = append(b, 0x00)
+		op = 0x00
 	case Delete:
-		b = append(b, 0x02)
+		op = 0x02
 	default:
 		panic("bad Op in marshal")
 	}
+	if le.Batched {
+		op |= 0x04
+	}
+	b = append(b, op)
 
 	entry, err := le.Entry.Marshal()
 	if err != nil {
@@ -1208,7 +1329,7 @@ func (le *Entry) unmarshal(fd io.ReaderAt, data []byte, offset int64) (int, erro
 	if err != nil && err != io.EOF || nRead < 8 { // Sanity check.
 		return 0, errors.E(errors.IO, errors.Errorf("reading op: %s", err))
 	}
-	switch data[0] {
+	switch data[0] &^ 0x04 {
 	case 0x00:
 		le.Op = Put
 	case 0x02:
@@ -1216,6 +1337,7 @@ func (le *Entry) unmarshal(fd io.ReaderAt, data []byte, offset int64) (int, erro
 	default:
 		return 0, errors.E(errors.Invalid, errors.Errorf("unknown Op %d", data[0]))
 	}
+	le.Batched = data[0]&0x04 != 0
 
 	size, n := binary.Varint(data[1:])
 	if n <= 0 {
@@ -0,0 +1,89 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package serverlog
+
+import (
+	"bytes"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// Record returns the Entry in the form in which it is stored in a log,
+// ending with its checksum.
+func (le *Entry) Record() ([]byte, error) {
+	return le.marshal()
+}
+
+// ParseRecord parses a record in the form returned by Record,
+// validating its checksum.
+func ParseRecord(rec []byte) (*Entry, error) {
+	le := new(Entry)
+	n, err := le.unmarshal(bytes.NewReader(rec), make([]byte, len(rec)), 0)
+	if err != nil {
+		return nil, err
+	}
+	if n != len(rec) {
+		return nil, errors.E(errors.Invalid, errors.Errorf("%d bytes left after record", len(rec)-n))
+	}
+	return le, nil
+}
+
+// RecordChecksum returns the checksum that ends a record.
+func RecordChecksum(rec []byte) []byte {
+	if len(rec) < len(checksumSalt) {
+		return nil
+	}
+	return rec[len(rec)-len(checksumSalt):]
+}
+
+// LastRecord returns the offset of the last record in the user's log
+// and its checksum. If the log is empty, the offset is -1.
+func (u *User) LastRecord() (offset int64, checksum []byte, err error) {
+	u.mu.Lock()
+	offset = -1
+	for _, o := range u.offSeqs {
+		if o.offset > offset {
+			offset = o.offset
+		}
+	}
+	u.mu.Unlock()
+	if offset < 0 {
+		return -1, nil, nil
+	}
+
+	r, err := u.NewReader()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer r.Close()
+	le, _, err := r.ReadAt(offset)
+	if err != nil {
+		return 0, nil, err
+	}
+	rec, err := le.Record()
+	if err != nil {
+		return 0, nil, err
+	}
+	return offset, RecordChecksum(rec), nil
+}
+
+// SetOnChange sets a function to be called with the user's name after
+// each change to the user's log, root or checkpoint, so that a replicator
+// can learn which logs have something new without reading them all. It
+// should be called before the first change. The function is called with
+// the User locked, so it must not block or use the User.
+func (u *User) SetOnChange(f func(upspin.UserName)) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.onChange = f
+}
+
+// changed calls the onChange function, if any. u.mu must be held.
+func (u *User) changed() {
+	if u.onChange != nil {
+		u.onChange(u.name)
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package serverlog
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"upspin.io/errors"
+)
+
+// SyncMode says when appends to a log are flushed to stable storage.
+type SyncMode int
+
+const (
+	// SyncAlways flushes the log after every append, before the append
+	// returns. It is the default.
+	SyncAlways SyncMode = iota
+
+	// SyncInterval flushes the log at most once per interval. An append
+	// does not return until a flush covering it has completed, so
+	// appends made within an interval of each other, such as those of
+	// concurrent writers, share a single flush.
+	SyncInterval
+
+	// SyncOS never flushes the log explicitly, leaving it to the
+	// operating system. An append may be lost if the system crashes,
+	// but not if only the server does.
+	SyncOS
+)
+
+// SyncPolicy says when appends to a log are flushed to stable storage.
+// The zero SyncPolicy is SyncAlways.
+type SyncPolicy struct {
+	Mode SyncMode
+
+	// Interval is the longest time an append waits for others to
+	// share its flush. It is used only by SyncInterval.
+	Interval time.Duration
+}
+
+// ParseSyncPolicy parses a policy of the form "always", "os" or
+// "interval:d", where d is a duration such as 10ms.
+func ParseSyncPolicy(s string) (SyncPolicy, error) {
+	const op errors.Op = "dir/server/serverlog.ParseSyncPolicy"
+	switch {
+	case s == "always":
+		return SyncPolicy{Mode: SyncAlways}, nil
+	case s == "os":
+		return SyncPolicy{Mode: SyncOS}, nil
+	case strings.HasPrefix(s, "interval:"):
+		d, err := time.ParseDuration(strings.TrimPrefix(s, "interval:"))
+		if err != nil || d <= 0 {
+			return SyncPolicy{}, errors.E(op, errors.Invalid, errors.Errorf("bad sync interval in %q", s))
+		}
+		return SyncPolicy{Mode: SyncInterval, Interval: d}, nil
+	}
+	return SyncPolicy{}, errors.E(op, errors.Invalid, errors.Errorf("unknown sync policy %q", s))
+}
+
+// String returns the policy in the form accepted by ParseSyncPolicy.
+func (p SyncPolicy) String() string {
+	switch p.Mode {
+	case SyncAlways:
+		return "always"
+	case SyncInterval:
+		return "interval:" + p.Interval.String()
+	case SyncOS:
+		return "os"
+	}
+	return fmt.Sprintf("SyncMode(%d)", p.Mode)
+}
+
+// syncFile flushes a log file to stable storage. It is a variable so
+// tests can intercept it.
+var syncFile = (*os.File).Sync
+
+// SetSyncPolicy sets the policy for flushing the user's log. It should be
+// called before the first append. The root and checkpoint are always
+// flushed when saved.
+func (u *User) SetSyncPolicy(p SyncPolicy) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.syncPolicy = p
+	if p.Mode == SyncInterval && u.group == nil {
+		u.group = newGroupSync()
+	}
+}
+
+// groupSync coordinates the appends waiting for a flush of a log under
+// the SyncInterval policy. The first waiter becomes the leader: it waits
+// for the interval, flushes the log as far as it has been written, and
+// releases every waiter whose append the flush covered. Waiters that
+// arrive during the flush wait for the next one.
+type groupSync struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	leader bool // Whether a waiter is leading a flush.
+
+	// synced is the offset through which the log has been flushed.
+	synced int64
+	// failed is the offset through which the most recent failed flush
+	// would have flushed the log, and err its error.
+	failed int64
+	err    error
+}
+
+func newGroupSync() *groupSync {
+	g := &groupSync{}
+	g.cond = sync.NewCond(&g.mu)
+	return g
+}
+
+// waitSync waits until the log has been flushed through offset end, as
+// the SyncInterval policy requires.
+// u.mu must not be held.
+func (u *User) waitSync(end int64) error {
+	g := u.group
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for g.synced < end {
+		if g.failed >= end {
+			return g.err
+		}
+		if g.leader {
+			g.cond.Wait()
+			continue
+		}
+		g.leader = true
+		g.mu.Unlock()
+		time.Sleep(u.syncPolicy.Interval)
+		u.mu.Lock()
+		target, err := u.syncLocked()
+		u.mu.Unlock()
+		g.mu.Lock()
+		g.leader = false
+		if err != nil {
+			g.failed, g.err = target, err
+		}
+		g.cond.Broadcast()
+	}
+	return nil
+}
+
+// syncLocked flushes the log file being written, if any, and returns the
+// offset through which the log is then flushed.
+// u.mu must be held.
+func (u *User) syncLocked() (int64, error) {
+	w := u.writer
+	if w == nil || w.fd == nil {
+		return 0, nil
+	}
+	end := w.file.offset + size(w.fd)
+	if err := syncFile(w.fd); err != nil {
+		return end, errors.E(errors.IO, err)
+	}
+	u.setSynced(end)
+	return end, nil
+}
+
+// setSynced records that the log has been flushed through offset end.
+func (u *User) setSynced(end int64) {
+	if u.group == nil {
+		return
+	}
+	u.group.mu.Lock()
+	if end > u.group.synced {
+		u.group.synced = end
+	}
+	u.group.mu.Unlock()
+}
+
+// truncateSynced records that the log now ends at offset end.
+func (u *User) truncateSynced(end int64) {
+	if u.group == nil {
+		return
+	}
+	u.group.mu.Lock()
+	if end < u.group.synced {
+		u.group.synced = end
+	}
+	if end < u.group.failed {
+		u.group.failed = end
+	}
+	u.group.mu.Unlock()
+}
+
+// syncBeforeClose flushes the log file being written, if the policy has
+// not already done so, before it is closed, so that no waiter is left
+// waiting for a flush that can no longer be made.
+// u.mu must be held.
+func (u *User) syncBeforeClose() error {
+	if u.syncPolicy.Mode != SyncInterval {
+		return nil
+	}
+	_, err := u.syncLocked()
+	return err
+}
@@ -0,0 +1,394 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package serverlog
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"upspin.io/upspin"
+)
+
+func TestParseSyncPolicy(t *testing.T) {
+	for _, test := range []struct {
+		in   string
+		want SyncPolicy
+		err  bool
+	}{
+		{"always", SyncPolicy{Mode: SyncAlways}, false},
+		{"os", SyncPolicy{Mode: SyncOS}, false},
+		{"interval:10ms", SyncPolicy{Mode: SyncInterval, Interval: 10 * time.Millisecond}, false},
+		{"interval:0s", SyncPolicy{}, true},
+		{"interval:-1ms", SyncPolicy{}, true},
+		{"interval:", SyncPolicy{}, true},
+		{"interval", SyncPolicy{}, true},
+		{"never", SyncPolicy{}, true},
+		{"", SyncPolicy{}, true},
+	} {
+		got, err := ParseSyncPolicy(test.in)
+		if test.err {
+			if err == nil {
+				t.Errorf("ParseSyncPolicy(%q) = %v, want error", test.in, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseSyncPolicy(%q): %v", test.in, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseSyncPolicy(%q) = %v, want %v", test.in, got, test.want)
+		}
+		if s := got.String(); s != test.in {
+			t.Errorf("%q: String() = %q", test.in, s)
+		}
+	}
+}
+
+// disk simulates the stable storage beneath the log files: it records
+// the contents of a log file each time it is flushed, which is all that
+// would survive a crash of the system.
+type disk struct {
+	mu      sync.Mutex
+	syncs   int
+	durable map[string][]byte // Base name of log file to flushed contents.
+
+	// If block is not nil, a flush waits to receive from it before it
+	// records anything, simulating a crash while waiting for the disk.
+	block chan bool
+	// started is signaled when a flush starts.
+	started chan bool
+}
+
+// install makes the disk record every flush of a log file until the
+// returned function is called.
+func (d *disk) install(t testing.TB) func() {
+	d.durable = make(map[string][]byte)
+	old := syncFile
+	syncFile = func(f *os.File) error {
+		if d.started != nil {
+			d.started <- true
+		}
+		if d.block != nil {
+			<-d.block
+		}
+		buf, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Error(err)
+			return err
+		}
+		d.mu.Lock()
+		d.syncs++
+		d.durable[filepath.Base(f.Name())] = buf
+		d.mu.Unlock()
+		return old(f)
+	}
+	return func() { syncFile = old }
+}
+
+// flushes returns the number of flushes of log files so far.
+func (d *disk) flushes() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.syncs
+}
+
+// flushedEnd returns the offset through which the log has been flushed.
+func (d *disk) flushedEnd(u *User) int64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var end int64
+	for _, f := range u.files {
+		if buf, ok := d.durable[filepath.Base(f.name)]; ok && f.offset+int64(len(buf)) > end {
+			end = f.offset + int64(len(buf))
+		}
+	}
+	return end
+}
+
+// crash writes the user's log files as they would be found after a crash
+// of the system to a new directory and returns its name. The extra bytes
+// are appended to the last file, simulating a write that reached the disk
+// only in part.
+func (d *disk) crash(t *testing.T, u *User, extra []byte) string {
+	dir, err := os.MkdirTemp("", "crash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &User{name: u.name, directory: dir}
+	if err := os.MkdirAll(c.logSubDir(), 0700); err != nil {
+		t.Fatal(err)
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for i, f := range u.files {
+		buf := d.durable[filepath.Base(f.name)]
+		if i == len(u.files)-1 {
+			buf = append(buf[:len(buf):len(buf)], extra...)
+		}
+		if err := os.WriteFile(filepath.Join(c.logSubDir(), filepath.Base(f.name)), buf, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestGroupSync(t *testing.T) {
+	dir, cleanup := setup(t, "GroupSync")
+	defer cleanup()
+
+	var d disk
+	defer d.install(t)()
+
+	user, err := Open(userName, dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.SetSyncPolicy(SyncPolicy{Mode: SyncInterval, Interval: 20 * time.Millisecond})
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 1; i <= n; i++ {
+		wg.Add(1)
+		go func(seq int) {
+			defer wg.Done()
+			name := upspin.PathName(fmt.Sprintf("%s/file%d", userName, seq))
+			if err := user.Append(newEntry(name, seq)); err != nil {
+				t.Error(err)
+				return
+			}
+			// The append must be on disk by the time it returns.
+			if off, end := user.OffsetOf(int64(seq)), d.flushedEnd(user); off < 0 || off >= end {
+				t.Errorf("append of %d at offset %d returned before flush; flushed through %d", seq, off, end)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if got := d.flushes(); got >= n {
+		t.Errorf("%d appends made %d flushes; want fewer", n, got)
+	}
+	if got, want := d.flushedEnd(user), user.AppendOffset(); got != want {
+		t.Errorf("flushed through %d, want %d", got, want)
+	}
+	if err := user.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCrash checks that, under each policy, the appends that have
+// returned are in the log as it would be found after a crash of the
+// system.
+func TestCrash(t *testing.T) {
+	for _, policy := range []SyncPolicy{
+		{Mode: SyncAlways},
+		{Mode: SyncInterval, Interval: time.Millisecond},
+		{Mode: SyncOS},
+	} {
+		t.Run(policy.String(), func(t *testing.T) {
+			dir, cleanup := setup(t, "Crash")
+			defer cleanup()
+
+			oldMax := MaxLogSize
+			MaxLogSize = 1024
+			defer func() { MaxLogSize = oldMax }()
+
+			var d disk
+			defer d.install(t)()
+
+			user, err := Open(userName, dir, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			user.SetSyncPolicy(policy)
+			const n = 50
+			for i := 1; i <= n; i++ {
+				name := upspin.PathName(fmt.Sprintf("%s/file%d", userName, i))
+				if err := user.Append(newEntry(name, i)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(user.files) < 2 {
+				t.Fatalf("log did not rotate: %d files", len(user.files))
+			}
+			if policy.Mode == SyncOS {
+				// Nothing is flushed, so everything may be lost.
+				if got := d.flushes(); got != 0 {
+					t.Errorf("%d flushes, want none", got)
+				}
+				if err := user.Close(); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			crashed := d.crash(t, user, nil)
+			defer os.RemoveAll(crashed)
+			r, err := VerifyUser(userName, crashed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !r.OK() {
+				t.Fatalf("log corrupt after crash:\n%s", r)
+			}
+			if r.Records != n {
+				t.Errorf("%d records survived the crash, want %d", r.Records, n)
+			}
+			if err := user.Close(); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+// TestCrashBeforeSync simulates a crash of the system after an append has
+// been written but before it has been flushed, with part of the append
+// on disk. The append has not returned, so it may be lost, but the log
+// must be recoverable: its torn end is found and can be truncated away
+// before appending again.
+func TestCrashBeforeSync(t *testing.T) {
+	dir, cleanup := setup(t, "CrashBeforeSync")
+	defer cleanup()
+
+	d := disk{
+		block:   make(chan bool),
+		started: make(chan bool),
+	}
+	defer d.install(t)()
+
+	user, err := Open(userName, dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.SetSyncPolicy(SyncPolicy{Mode: SyncInterval, Interval: time.Millisecond})
+
+	// Make one append durable.
+	errc := make(chan error)
+	go func() { errc <- user.Append(newEntry(upspin.PathName(userName+"/first"), 1)) }()
+	<-d.started
+	d.block <- true
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	durable := user.AppendOffset()
+
+	// Start a second and stop it in its flush.
+	go func() { errc <- user.Append(newEntry(upspin.PathName(userName+"/second"), 3)) }()
+	<-d.started
+	buf, err := os.ReadFile(user.writer.file.name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		t.Fatalf("append returned before its flush: %v", err)
+	default:
+	}
+	torn := buf[durable : durable+(int64(len(buf))-durable)/2]
+
+	crashed := d.crash(t, user, torn)
+	defer os.RemoveAll(crashed)
+
+	// Let the server finish; the crash has already been recorded.
+	d.block <- true
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+	d.block, d.started = nil, nil
+	if err := user.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := VerifyUser(userName, crashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.OK() || r.Corrupt != durable || r.End != durable || r.Records != 1 {
+		t.Fatalf("torn log: got\n%s\nwant one record and corruption at %d", r, durable)
+	}
+
+	// Recover.
+	u, err := Open(userName, crashed, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u.SetSyncPolicy(SyncPolicy{Mode: SyncInterval, Interval: time.Millisecond})
+	if err := u.Truncate(r.End); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Append(newEntry(upspin.PathName(userName+"/third"), 5)); err != nil {
+		t.Fatal(err)
+	}
+	if err := u.Close(); err != nil {
+		t.Fatal(err)
+	}
+	r, err = VerifyUser(userName, crashed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !r.OK() || r.Records != 2 {
+		t.Fatalf("recovered log: got\n%s\nwant two good records", r)
+	}
+	u, err = Open(userName, crashed, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer u.Close()
+	rd, err := u.NewReader()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for off := int64(0); off < r.End; {
+		e, next, err := rd.ReadAt(off)
+		if err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, string(e.Entry.Name))
+		off = next
+	}
+	if got, want := strings.Join(names, " "), fmt.Sprintf("%s/first %[1]s/third", userName); got != want {
+		t.Errorf("recovered entries = %q, want %q", got, want)
+	}
+}
+
+func BenchmarkAppend(b *testing.B) {
+	for _, policy := range []SyncPolicy{
+		{Mode: SyncAlways},
+		{Mode: SyncInterval, Interval: time.Millisecond},
+		{Mode: SyncInterval, Interval: 10 * time.Millisecond},
+		{Mode: SyncOS},
+	} {
+		b.Run(policy.String(), func(b *testing.B) {
+			dir, cleanup := setup(b, "BenchmarkAppend")
+			defer cleanup()
+
+			user, err := Open(userName, dir, nil, nil)
+			if err != nil {
+				b.Fatal(err)
+			}
+			defer user.Close()
+			user.SetSyncPolicy(policy)
+			e := newEntry(upspin.PathName(userName+"/file"), 1)
+
+			// Many concurrent writers, as on a busy server.
+			b.SetParallelism(64)
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					if err := user.Append(e); err != nil {
+						b.Error(err)
+						return
+					}
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package serverlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// Report describes the state of one user's logs, as found by Verify.
+type Report struct {
+	User upspin.UserName
+
+	// Files is the number of log files examined.
+	Files int
+	// Records is the number of valid log records read.
+	Records int
+	// End is the global offset just past the last valid record.
+	End int64
+	// Corrupt is the global offset of the first corrupt record,
+	// or -1 if all records are valid.
+	Corrupt int64
+	// Checkpoint is the offset recorded in the checkpoint file,
+	// or -1 if there is none.
+	Checkpoint int64
+
+	// Problems lists the inconsistencies found, in the order found.
+	Problems []string
+}
+
+// OK reports whether no problems were found.
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *Report) problemf(format string, args ...interface{}) {
+	r.Problems = append(r.Problems, fmt.Sprintf(format, args...))
+}
+
+// String returns a multi-line, human-readable form of the report.
+func (r *Report) String() string {
+	var b strings.Builder
+	status := "ok"
+	if !r.OK() {
+		status = "CORRUPT"
+	}
+	fmt.Fprintf(&b, "%s: %s: %d files, %d records, end offset %d", r.User, status, r.Files, r.Records, r.End)
+	if r.Checkpoint >= 0 {
+		fmt.Fprintf(&b, ", checkpoint %d", r.Checkpoint)
+	}
+	if r.Corrupt >= 0 {
+		fmt.Fprintf(&b, ", first corrupt offset %d", r.Corrupt)
+	}
+	for _, p := range r.Problems {
+		fmt.Fprintf(&b, "\n\t%s", p)
+	}
+	return b.String()
+}
+
+// Verify checks the logs of every user in the directory and returns a
+// report for each. See VerifyUser for the checks made.
+func Verify(directory string) ([]*Report, error) {
+	users, err := ListUsers(directory)
+	if err != nil {
+		return nil, err
+	}
+	var reports []*Report
+	for _, user := range users {
+		r, err := VerifyUser(user, directory)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, r)
+	}
+	return reports, nil
+}
+
+// VerifyUser checks the logs of the user in the directory for internal
+// consistency. It checks that the log files are named correctly and are
+// contiguous, that every record has a valid checksum and holds a valid
+// DirEntry, that the checkpoint is at a record boundary, and that the
+// saved root is a valid DirEntry. The logs are only read, never
+// modified, so VerifyUser may be run on the logs of a live server;
+// records appended while it runs may be reported as incomplete.
+// The returned error reports only a failure to run the checks;
+// inconsistencies are recorded in the Report.
+func VerifyUser(userName upspin.UserName, directory string) (*Report, error) {
+	const op errors.Op = "dir/server/serverlog.VerifyUser"
+	u := &User{
+		name:      userName,
+		directory: directory,
+	}
+	r := &Report{
+		User:       userName,
+		Corrupt:    -1,
+		Checkpoint: -1,
+	}
+
+	files, err := verifyLogFileNames(u, r)
+	if err != nil {
+		return nil, errors.E(op, userName, err)
+	}
+	r.Files = len(files)
+
+	// Read the checkpoint first so the walk can check it is at a boundary.
+	if buf, err := os.ReadFile(u.checkpointFile()); err != nil && !os.IsNotExist(err) {
+		return nil, errors.E(op, userName, errors.IO, err)
+	} else if len(buf) > 0 {
+		offset, n := binary.Varint(buf)
+		if n <= 0 {
+			r.problemf("checkpoint: invalid offset encoding")
+		} else {
+			r.Checkpoint = offset
+		}
+	}
+
+	atCheckpoint := r.Checkpoint == 0
+	data := make([]byte, 4096)
+	for i, file := range files {
+		if file.offset != r.End {
+			r.problemf("%s: starts at offset %d; expected %d", filepath.Base(file.name), file.offset, r.End)
+			r.Corrupt = r.End
+			break
+		}
+		if i > 0 && file.version < files[i-1].version {
+			r.problemf("%s: version %d follows version %d", filepath.Base(file.name), file.version, files[i-1].version)
+		}
+		fd, err := os.Open(file.name)
+		if err != nil {
+			return nil, errors.E(op, userName, errors.IO, err)
+		}
+		size := size(fd)
+		var off int64
+		for off < size {
+			var le Entry
+			n, err := le.unmarshal(fd, data, off)
+			if err != nil {
+				r.problemf("%s: offset %d: %v", filepath.Base(file.name), file.offset+off, err)
+				r.Corrupt = file.offset + off
+				break
+			}
+			off += int64(n)
+			r.Records++
+			r.End = file.offset + off
+			if r.End == r.Checkpoint {
+				atCheckpoint = true
+			}
+		}
+		fd.Close()
+		if r.Corrupt >= 0 {
+			break
+		}
+	}
+	if r.Checkpoint >= 0 && !atCheckpoint {
+		if r.Corrupt >= 0 && r.Checkpoint > r.Corrupt {
+			r.problemf("checkpoint: offset %d is beyond the first corrupt record", r.Checkpoint)
+		} else {
+			r.problemf("checkpoint: offset %d is not at a record boundary", r.Checkpoint)
+		}
+	}
+
+	buf, err := os.ReadFile(u.rootFile())
+	if err != nil && !os.IsNotExist(err) {
+		return nil, errors.E(op, userName, errors.IO, err)
+	}
+	if len(buf) > 0 {
+		var root upspin.DirEntry
+		more, err := root.Unmarshal(buf)
+		switch {
+		case err != nil:
+			r.problemf("root: %v", err)
+		case len(more) != 0:
+			r.problemf("root: %d left over bytes", len(more))
+		}
+	}
+
+	return r, nil
+}
+
+// verifyLogFileNames returns the user's log files in increasing offset
+// order, recording in the report any whose names are malformed.
+func verifyLogFileNames(u *User, r *Report) ([]*logFile, error) {
+	dir := u.logSubDir()
+	d, err := os.Open(dir)
+	if os.IsNotExist(err) {
+		r.problemf("no log directory %s", dir)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	names, err := d.Readdirnames(-1)
+	d.Close()
+	if err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	var files []*logFile
+	for _, name := range names {
+		lf := &logFile{name: filepath.Join(dir, name)}
+		elems := strings.Split(name, ".")
+		var err error
+		lf.offset, err = strconv.ParseInt(elems[0], 10, 64)
+		if err == nil && len(elems) == 2 {
+			lf.version, err = strconv.Atoi(elems[1])
+			if err == nil && lf.version < 1 {
+				err = errors.Str("version must be positive")
+			}
+		}
+		switch {
+		case err != nil || len(elems) > 2 || lf.offset < 0:
+			r.problemf("%s: malformed log file name", name)
+			continue
+		case lf.version > version:
+			r.problemf("%s: unknown version %d", name, lf.version)
+			continue
+		case name != filepath.Base(u.logFileName(lf.offset, lf.version)):
+			r.problemf("%s: non-canonical log file name", name)
+			continue
+		}
+		files = append(files, lf)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].offset < files[j].offset })
+	for i := range files {
+		files[i].index = i
+	}
+	return files, nil
+}
@@ -0,0 +1,205 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package serverlog
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"upspin.io/upspin"
+)
+
+const (
+	verifyUser upspin.UserName = "bob@example.com"
+	recSize                    = 58 // Size of the records written by makeVerifyLogs.
+)
+
+// makeVerifyLogs writes ten records of recSize bytes, two to a log file,
+// saves a checkpoint after the eighth and a root, and returns the user.
+func makeVerifyLogs(t *testing.T, dir string) *User {
+	prevMaxLogSize := MaxLogSize
+	MaxLogSize = 100
+	defer func() {
+		MaxLogSize = prevMaxLogSize
+	}()
+
+	user, err := Open(verifyUser, dir, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		if err := user.Append(newEntry(upspin.PathName(verifyUser)+"/testing-testing", 1)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := user.SaveOffset(8 * recSize); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.SaveRoot(&newEntry(upspin.PathName(verifyUser)+"/", 1).Entry); err != nil {
+		t.Fatal(err)
+	}
+	if err := user.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// snapshot returns the names and sizes of all files under dir.
+func snapshot(t *testing.T, dir string) map[string]int64 {
+	files := make(map[string]int64)
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		files[path] = info.Size()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestVerifyGood(t *testing.T) {
+	dir, cleanup := setup(t, "VerifyGood")
+	defer cleanup()
+	makeVerifyLogs(t, dir)
+
+	before := snapshot(t, dir)
+	reports, err := Verify(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(before, snapshot(t, dir)) {
+		t.Error("Verify modified the log directory")
+	}
+	if len(reports) != 1 {
+		t.Fatalf("got %d reports, want 1", len(reports))
+	}
+	r := reports[0]
+	if !r.OK() {
+		t.Fatalf("unexpected problems:\n%s", r)
+	}
+	want := Report{
+		User:       verifyUser,
+		Files:      5,
+		Records:    10,
+		End:        10 * recSize,
+		Corrupt:    -1,
+		Checkpoint: 8 * recSize,
+	}
+	if !reflect.DeepEqual(*r, want) {
+		t.Errorf("report = %+v, want %+v", *r, want)
+	}
+}
+
+func TestVerifyCorrupt(t *testing.T) {
+	tests := []struct {
+		name    string
+		corrupt func(u *User) error
+		offset  int64  // Expected first corrupt offset, or -1.
+		problem string // Expected in the first problem.
+	}{
+		{
+			name:    "op byte",
+			corrupt: flipByte(2*recSize, 0),
+			offset:  2 * recSize,
+			problem: "unknown Op",
+		},
+		{
+			name:    "entry",
+			corrupt: flipByte(2*recSize, recSize+20),
+			offset:  3 * recSize,
+			problem: "",
+		},
+		{
+			name:    "checksum",
+			corrupt: flipByte(8*recSize, 2*recSize-1),
+			offset:  9 * recSize,
+			problem: "invalid checksum",
+		},
+		{
+			name: "truncated",
+			corrupt: func(u *User) error {
+				return os.Truncate(u.logFileName(8*recSize, version), 2*recSize-3)
+			},
+			offset:  9 * recSize,
+			problem: "",
+		},
+		{
+			name: "missing file",
+			corrupt: func(u *User) error {
+				return os.Remove(u.logFileName(4*recSize, version))
+			},
+			offset:  4 * recSize,
+			problem: "starts at offset",
+		},
+		{
+			name: "bad file name",
+			corrupt: func(u *User) error {
+				return os.WriteFile(filepath.Join(u.logSubDir(), "0.junk"), nil, 0600)
+			},
+			offset:  -1,
+			problem: "malformed log file name",
+		},
+		{
+			name: "checkpoint",
+			corrupt: func(u *User) error {
+				buf := []byte{byte(2 * (recSize + 1))} // Varint of recSize+1.
+				return os.WriteFile(u.checkpointFile(), buf, 0600)
+			},
+			offset:  -1,
+			problem: "not at a record boundary",
+		},
+		{
+			name: "root",
+			corrupt: func(u *User) error {
+				return os.WriteFile(u.rootFile(), []byte("garbage"), 0600)
+			},
+			offset:  -1,
+			problem: "root:",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			dir, cleanup := setup(t, "VerifyCorrupt")
+			defer cleanup()
+			u := makeVerifyLogs(t, dir)
+			if err := test.corrupt(u); err != nil {
+				t.Fatal(err)
+			}
+			r, err := VerifyUser(verifyUser, dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if r.OK() {
+				t.Fatalf("corruption not detected:\n%s", r)
+			}
+			if r.Corrupt != test.offset {
+				t.Errorf("first corrupt offset = %d, want %d\n%s", r.Corrupt, test.offset, r)
+			}
+			if !strings.Contains(r.Problems[0], test.problem) {
+				t.Errorf("problem = %q, want %q", r.Problems[0], test.problem)
+			}
+		})
+	}
+}
+
+// flipByte returns a function that inverts the byte at pos in the log
+// file starting at offset.
+func flipByte(offset, pos int64) func(u *User) error {
+	return func(u *User) error {
+		name := u.logFileName(offset, version)
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		data[pos] ^= 0xff
+		return os.WriteFile(name, data, 0600)
+	}
+}
@@ -5,12 +5,15 @@
 package server
 
 import (
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"upspin.io/client/clientutil"
 	"upspin.io/dir/server/serverlog"
 	"upspin.io/errors"
-	"upspin.io/log"
+	"upspin.io/pack"
 	"upspin.io/path"
 	"upspin.io/upspin"
 	"upspin.io/user"
@@ -20,23 +23,26 @@ import (
 // contains directories that form the timestamp of when the snapshot was taken,
 // such as bob@example.com/2017/02/12/15:45/.
 //
-// Snapshots are automatically taken every 12 hours.
+// Snapshots are automatically taken every 12 hours, unless the server is
+// configured with another schedule or the snapshot root contains a
+// Schedule file holding one. See snapshotSchedule for the syntax.
 const (
 	snapshotSuffix          = "snapshot"
 	snapshotControlFile     = "TakeSnapshot"
+	snapshotScheduleFile    = "Schedule"
 	snapshotDateFormat      = "2006/01/02/"
 	snapshotTimeFormat      = "15:04"
 	snapshotFullDateFormat  = snapshotDateFormat + snapshotTimeFormat
-	snapshotDefaultInterval = 12 * time.Hour
+	snapshotDefaultSchedule = "12h"
 	snapshotWorkerInterval  = 2 * time.Hour
+	snapshotMinWait         = time.Minute
 )
 
 // snapshotConfig holds the configuration for a snapshot. Users may have
 // multiple such configurations.
 type snapshotConfig struct {
-	srcDir   upspin.PathName
-	dstDir   upspin.PathName
-	interval time.Duration
+	srcDir upspin.PathName
+	dstDir upspin.PathName
 }
 
 // getSnapshotConfig retrieves all configured snapshots for a user and domain
@@ -55,18 +61,59 @@ func (s *server) getSnapshotConfig(userName upspin.UserName) (*snapshotConfig, e
 	uname = uname[:len(uname)-len(snapshotSuffix)-1]
 
 	return &snapshotConfig{
-		srcDir:   upspin.PathName(uname + "@" + domain + "/"),
-		dstDir:   upspin.PathName(userName),
-		interval: snapshotDefaultInterval,
+		srcDir: upspin.PathName(uname + "@" + domain + "/"),
+		dstDir: upspin.PathName(userName),
 	}, nil
 }
 
+// getSnapshotSchedule returns the schedule for the snapshots described
+// by cfg: the one in the snapshot root's Schedule file if there is one
+// and it is not empty, and otherwise the server's.
+func (s *server) getSnapshotSchedule(cfg *snapshotConfig) (*snapshotSchedule, error) {
+	p, err := path.Parse(path.Join(cfg.dstDir, snapshotScheduleFile))
+	if err != nil {
+		return nil, err
+	}
+	entry, err := s.lookup(p, entryMustBeClean)
+	if errors.Is(errors.NotExist, err) {
+		return s.snapshotSchedule, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	sched, err := s.loadSnapshotSchedule(entry)
+	if err != nil {
+		return nil, err
+	}
+	if sched == nil {
+		return s.snapshotSchedule, nil
+	}
+	return sched, nil
+}
+
+// loadSnapshotSchedule reads and parses a Schedule file. It returns a nil
+// schedule if the file is empty.
+func (s *server) loadSnapshotSchedule(entry *upspin.DirEntry) (*snapshotSchedule, error) {
+	if len(entry.Blocks) == 0 {
+		return nil, nil
+	}
+	data, err := clientutil.ReadAll(s.serverConfig, entry)
+	if err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(string(data)) == "" {
+		return nil, nil
+	}
+	return parseSnapshotSchedule(string(data))
+}
+
 func (s *server) startSnapshotLoop() {
 	if s.snapshotControl != nil {
-		log.Error.Printf("dir/server.startSnapshotLoop: attempting to restart snapshot worker")
+		logger.Error.Printf("dir/server.startSnapshotLoop: attempting to restart snapshot worker")
 		return
 	}
 	s.snapshotControl = make(chan snapshotCreate)
+	s.snapshotReschedule = make(chan struct{}, 1)
 	go s.snapshotLoop()
 }
 
@@ -74,16 +121,25 @@ func (s *server) startSnapshotLoop() {
 // We call takeSnapshotFor only from here to serialize requests with
 // time-triggered snapshot.
 func (s *server) snapshotLoop() {
-	// Run once upon starting.
-	s.snapshotAll() // returned error is already logged.
+	// Run once upon starting, which also catches up on a snapshot
+	// missed while the server was down.
+	next, _ := s.snapshotAllNext() // returned error is already logged.
 
-	// Run periodically.
-	ticker := time.NewTicker(snapshotWorkerInterval)
-	defer ticker.Stop()
+	// Then run whenever the next snapshot is due, but look around
+	// every so often for new snapshot users.
+	timer := time.NewTimer(s.snapshotWait(next))
+	defer timer.Stop()
 	for {
 		select {
-		case <-ticker.C:
-			s.snapshotAll() // returned error is already logged.
+		case <-timer.C:
+			next, _ = s.snapshotAllNext() // returned error is already logged.
+			timer.Reset(s.snapshotWait(next))
+		case <-s.snapshotReschedule:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			next, _ = s.snapshotAllNext()
+			timer.Reset(s.snapshotWait(next))
 		case sc := <-s.snapshotControl:
 			if sc.userName == "" {
 				// Closing the ticker channel.
@@ -94,14 +150,40 @@ func (s *server) snapshotLoop() {
 	}
 }
 
+// snapshotWait returns how long the snapshot loop should sleep before
+// running again, given the time the next snapshot is due.
+func (s *server) snapshotWait(next time.Time) time.Duration {
+	d := next.Sub(s.now().Go())
+	if next.IsZero() || d > snapshotWorkerInterval {
+		return snapshotWorkerInterval
+	}
+	if d < snapshotMinWait {
+		return snapshotMinWait
+	}
+	return d
+}
+
 // snapshotAll scans all roots that have a +snapshot suffix, determines whether
 // it's time to perform a new snapshot for them and if so snapshots them.
 func (s *server) snapshotAll() error {
+	_, err := s.snapshotAllNext()
+	return err
+}
+
+// snapshotAllNext is snapshotAll but also returns when the next snapshot of
+// any user is due, or the zero time if no user has snapshots.
+func (s *server) snapshotAllNext() (time.Time, error) {
 	const op errors.Op = "dir/server.snapshotAll"
 	users, err := serverlog.ListUsersWithSuffix(snapshotSuffix, s.logDir)
 	if err != nil {
-		log.Error.Printf("%s: error listing snapshot users: %s", op, err)
-		return err
+		logger.Error.Printf("%s: error listing snapshot users: %s", op, err)
+		return time.Time{}, err
+	}
+	var earliest time.Time
+	soonest := func(t time.Time) {
+		if earliest.IsZero() || t.Before(earliest) {
+			earliest = t
+		}
 	}
 	var firstErr error
 	check := func(err error) error {
@@ -113,23 +195,32 @@ func (s *server) snapshotAll() error {
 	for _, userName := range users {
 		cfg, err := s.getSnapshotConfig(userName)
 		if check(err) != nil {
-			log.Error.Printf("%s: can't get config for user %q", op, userName)
+			logger.Error.Printf("%s: can't get config for user %q", op, userName)
 			continue
 		}
-		ok, dstPath, err := s.shouldSnapshot(cfg)
+		sched, err := s.getSnapshotSchedule(cfg)
 		if check(err) != nil {
-			log.Error.Printf("%s: error checking whether to snapshot: %s", op, err)
+			logger.Error.Printf("%s: can't get schedule for user %q: %s", op, userName, err)
+			continue
+		}
+		ok, next, dstPath, err := s.shouldSnapshot(cfg, sched)
+		if check(err) != nil {
+			logger.Error.Printf("%s: error checking whether to snapshot: %s", op, err)
 			continue
 		}
 		if !ok {
+			soonest(next)
 			continue
 		}
 		err = s.takeSnapshot(dstPath, cfg.srcDir)
 		if check(err) != nil {
-			log.Error.Printf("%s: error snapshotting: %s", op, err)
+			logger.Error.Printf("%s: error snapshotting: %s", op, err)
+			continue
 		}
+		now := s.now().Go()
+		soonest(sched.next(now, now))
 	}
-	return firstErr
+	return earliest, firstErr
 }
 
 // snapshotDir returns the destination path for a snapshot given its
@@ -145,50 +236,91 @@ func (s *server) snapshotDir(cfg *snapshotConfig) (path.Parsed, error) {
 	return p, nil
 }
 
-// shouldSnapshot reports whether it's time to snapshot the given configuration.
+// shouldSnapshot reports whether it's time to snapshot the given configuration
+// according to the schedule, and if not, when it will be.
 // It also returns the parsed path of where the snapshot will be made.
-func (s *server) shouldSnapshot(cfg *snapshotConfig) (bool, path.Parsed, error) {
+func (s *server) shouldSnapshot(cfg *snapshotConfig, sched *snapshotSchedule) (bool, time.Time, path.Parsed, error) {
 	const op errors.Op = "dir/server.shouldSnapshot"
 
 	p, err := s.snapshotDir(cfg)
 	if err != nil {
-		return false, path.Parsed{}, errors.E(op, err)
+		return false, time.Time{}, path.Parsed{}, errors.E(op, err)
+	}
+	root, err := path.Parse(cfg.dstDir)
+	if err != nil {
+		return false, time.Time{}, path.Parsed{}, errors.E(op, err)
+	}
+	last, err := s.lastSnapshot(root)
+	if err != nil {
+		return false, time.Time{}, path.Parsed{}, errors.E(op, err)
+	}
+	now := s.now().Go()
+	next := sched.next(last, now)
+	if next.After(now) {
+		// Not time yet. Nothing to do.
+		return false, next, p, nil
 	}
+	return true, next, p, nil
+}
 
-	// List today's snapshot directory, including any suffixed snapshot.
-	tree, err := s.loadTreeFor(p.User())
+// lastSnapshot returns the time of the most recent snapshot under root,
+// or the zero time if there is none.
+func (s *server) lastSnapshot(root path.Parsed) (time.Time, error) {
+	tree, err := s.loadTreeFor(root.User())
 	if err != nil {
-		return false, path.Parsed{}, errors.E(op, err)
+		return time.Time{}, err
 	}
-	entries, _, err := tree.List(p)
-	if err == upspin.ErrFollowLink {
-		// We need to get the real entry and we cannot resolve links on our own.
-		return false, path.Parsed{}, errors.E(op, errors.Internal, p.Path(), "cannot follow a link to snapshot")
-	} else if err != nil && !errors.Is(errors.NotExist, err) {
-		// Some other error. Abort.
-		return false, path.Parsed{}, errors.E(op, err)
-	}
-	if len(entries) > 0 {
-		var mostRecent time.Time
+	// Snapshots are at root/yyyy/mm/dd/hh:mm. Descend through the
+	// newest directories at each level until one holds a snapshot.
+	widths := []int{4, 2, 2}
+	var find func(dir path.Parsed, depth int) (time.Time, error)
+	find = func(dir path.Parsed, depth int) (time.Time, error) {
+		entries, _, err := tree.List(dir)
+		if err == upspin.ErrFollowLink {
+			// We need to get the real entry and we cannot resolve links on our own.
+			return time.Time{}, errors.E(errors.Internal, dir.Path(), "cannot follow a link to snapshot")
+		}
+		if errors.Is(errors.NotExist, err) {
+			return time.Time{}, nil
+		}
+		if err != nil {
+			return time.Time{}, err
+		}
+		var names []path.Parsed
 		for _, e := range entries {
-			parsed, _ := path.Parse(e.Name) // can't be an error.
-			t, err := time.Parse(snapshotFullDateFormat, parsed.FilePath())
-			if err != nil {
-				// Not a valid name. Ignore.
+			if !e.IsDir() {
 				continue
 			}
-			if t.After(mostRecent) {
-				mostRecent = t
+			parsed, _ := path.Parse(e.Name) // can't be an error.
+			if depth < len(widths) {
+				elem := parsed.Elem(parsed.NElem() - 1)
+				if _, err := strconv.Atoi(elem); err != nil || len(elem) != widths[depth] {
+					// Not a valid name. Ignore.
+					continue
+				}
 			}
+			names = append(names, parsed)
 		}
-		// Is the last entry so old that a new snapshot is now warranted?
-		if mostRecent.Add(cfg.interval).After(s.now().Go()) {
-			// Not time yet. Nothing to do.
-			return false, p, nil
+		sort.Slice(names, func(i, j int) bool {
+			return names[i].FilePath() > names[j].FilePath()
+		})
+		for _, n := range names {
+			if depth == len(widths) {
+				t, err := time.Parse(snapshotFullDateFormat, n.FilePath())
+				if err != nil {
+					// Not a valid name. Ignore.
+					continue
+				}
+				return t, nil
+			}
+			t, err := find(n, depth+1)
+			if err != nil || !t.IsZero() {
+				return t, err
+			}
 		}
-		// Ok, proceed.
+		return time.Time{}, nil
 	}
-	return true, p, nil
+	return find(root, 0)
 }
 
 // takeSnapshotFor takes a snapshot for a user.
@@ -233,7 +365,7 @@ func (s *server) takeSnapshot(dstDir path.Parsed, srcDir upspin.PathName) error
 		return err
 	}
 
-	log.Printf("dir/server: Snapshotted %q into %q", entry.SignedName, snapEntry.Name)
+	logger.Printf("dir/server: Snapshotted %q into %q", entry.SignedName, snapEntry.Name)
 	return nil
 }
 
@@ -298,7 +430,7 @@ func isSnapshotUser(userName upspin.UserName) bool {
 	}
 	_, suffix, _, err := user.Parse(userName)
 	if err != nil {
-		log.Error.Printf("dir/server.isSnapshotUser: error parsing user name %q: %s", userName, err)
+		logger.Error.Printf("dir/server.isSnapshotUser: error parsing user name %q: %s", userName, err)
 		return false
 	}
 	return suffix == snapshotSuffix
@@ -327,6 +459,36 @@ func isSnapshotControlFile(p path.Parsed) bool {
 	return p.NElem() == 1 && p.Elem(0) == snapshotControlFile
 }
 
+// isSnapshotScheduleFile reports whether the path name is for an entry in
+// the root named snapshotScheduleFile.
+func isSnapshotScheduleFile(p path.Parsed) bool {
+	return p.NElem() == 1 && p.Elem(0) == snapshotScheduleFile
+}
+
+// checkSnapshotScheduleEntry checks that an entry is a Schedule file the
+// server can read and that it holds a valid schedule.
+func (s *server) checkSnapshotScheduleEntry(entry *upspin.DirEntry) error {
+	if entry.IsLink() || entry.IsDir() {
+		return errors.E(errors.Invalid, entry.Name, "snapshot schedule must be a file")
+	}
+	if len(entry.Blocks) == 0 {
+		return nil
+	}
+	packer := pack.Lookup(entry.Packing)
+	if packer == nil {
+		return errors.E(errors.Invalid, entry.Name, errors.Errorf("unknown packing %s", entry.Packing))
+	}
+	ok, err := packer.UnpackableByAll(entry)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return errors.E(errors.Invalid, entry.Name, "snapshot schedule must be readable by all")
+	}
+	_, err = s.loadSnapshotSchedule(entry)
+	return err
+}
+
 // isValidSnapshotControlEntry reports whether an entry correctly represents the
 // control entry we expect in order to start a new snapshot.
 func isValidSnapshotControlEntry(entry *upspin.DirEntry) error {
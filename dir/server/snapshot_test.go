@@ -12,6 +12,7 @@ import (
 
 	"upspin.io/access"
 	"upspin.io/errors"
+	"upspin.io/pack"
 	"upspin.io/path"
 	"upspin.io/upspin"
 )
@@ -423,6 +424,174 @@ func TestSnapshotUserCanCreateSnapshotRoot(t *testing.T) {
 	create(t, s, "user+snapshot@example.com/", isDir)
 }
 
+func TestSnapshotScheduleNext(t *testing.T) {
+	at := func(s string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tm
+	}
+	for _, c := range []struct {
+		spec, last, now, next string
+	}{
+		// Intervals count from the last snapshot.
+		{"12h", "", "2017-01-02 10:00", "2017-01-02 10:00"},
+		{"12h", "2017-01-02 10:00", "2017-01-02 11:00", "2017-01-02 22:00"},
+		{"90m", "2017-01-02 10:00", "2017-01-03 11:00", "2017-01-02 11:30"},
+		// Hourly during working hours.
+		{"0 9-17 * * *", "2017-01-02 10:00", "2017-01-02 10:30", "2017-01-02 11:00"},
+		{"0 9-17 * * *", "2017-01-02 17:00", "2017-01-02 17:30", "2017-01-03 09:00"},
+		{"0 9-17 * * *", "2017-01-02 10:13", "2017-01-02 10:30", "2017-01-02 11:00"},
+		// Several missed snapshots are owed as one, now.
+		{"0 9-17 * * *", "2017-01-02 10:00", "2017-01-02 15:30", "2017-01-02 11:00"},
+		// The first snapshot waits for the schedule.
+		{"0 9-17 * * *", "", "2017-01-02 07:30", "2017-01-02 09:00"},
+		{"0 9-17 * * *", "", "2017-01-02 09:00", "2017-01-02 09:00"},
+		// Steps, lists and weekdays. 2017-01-06 is a Friday.
+		{"*/15 9,12 * * *", "2017-01-02 09:45", "2017-01-02 10:00", "2017-01-02 12:00"},
+		{"30 8 * * 1-5", "2017-01-06 08:30", "2017-01-06 09:00", "2017-01-09 08:30"},
+		// Either day field matches when both are given.
+		{"0 0 1 * 0", "2017-01-01 00:00", "2017-01-01 01:00", "2017-01-08 00:00"},
+		{"0 0 29 2 *", "2017-01-01 00:00", "2017-01-01 01:00", "2020-02-29 00:00"},
+	} {
+		sched, err := parseSnapshotSchedule(c.spec)
+		if err != nil {
+			t.Fatalf("parseSnapshotSchedule(%q): %v", c.spec, err)
+		}
+		var last time.Time
+		if c.last != "" {
+			last = at(c.last)
+		}
+		if got, want := sched.next(last, at(c.now)), at(c.next); !got.Equal(want) {
+			t.Errorf("%q.next(%q, %q) = %v, want %v", c.spec, c.last, c.now, got, want)
+		}
+	}
+
+	for _, spec := range []string{
+		"", "12", "30s", "0 * 9-17 *", "60 * * * *", "0 24 * * *", "0 * 0 * *",
+		"0 * * 13 *", "0 * * * 7", "0 17-9 * * *", "*/0 * * * *", "a * * * *",
+	} {
+		if _, err := parseSnapshotSchedule(spec); !errors.Is(errors.Invalid, err) {
+			t.Errorf("parseSnapshotSchedule(%q): err = %v, want Invalid", spec, err)
+		}
+	}
+}
+
+func TestSnapshotScheduleFile(t *testing.T) {
+	dir := generatorInstance.(*server)
+	s, cfg := newDirServerForTesting(t, canonicalUser)
+
+	// Stop the background snapshot loop so only this test takes
+	// snapshots, driven by the mock clock.
+	dir.snapshotControl <- snapshotCreate{}
+
+	count := func() int {
+		ents, err := s.Glob(snapshotUser + "/*/*/*/*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(ents)
+	}
+	set := func(s string) {
+		tm, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		mockTime.set(tm)
+	}
+	run := func(wantNew int, wantNext string) time.Time {
+		t.Helper()
+		before := count()
+		// Errors are expected for other snapshot users whose
+		// trees don't exist, and are checked by the counts.
+		next, _ := dir.snapshotAllNext()
+		if got := count() - before; got != wantNew {
+			t.Errorf("%v: took %d snapshots, want %d", mockTime.now().Go(), got, wantNew)
+		}
+		want, _ := time.Parse("2006-01-02 15:04", wantNext)
+		if !next.Equal(want) {
+			t.Errorf("%v: next snapshot at %v, want %v", mockTime.now().Go(), next, want)
+		}
+		return next
+	}
+
+	// Earlier tests left the last snapshot on the evening of 2017-01-02.
+	set("2017-01-03 08:30")
+	if _, err := putSnapshotSchedule(t, s, cfg, "0 9-17 * * *\n"); err != nil {
+		t.Fatal(err)
+	}
+	next := run(0, "2017-01-03 09:00")
+	if got, want := dir.snapshotWait(next), 30*time.Minute; got != want {
+		t.Errorf("snapshotWait = %v, want %v", got, want)
+	}
+
+	set("2017-01-03 09:00")
+	run(1, "2017-01-03 10:00")
+	set("2017-01-03 09:30")
+	run(0, "2017-01-03 10:00")
+
+	// The server was down at 10, 11 and 12; only one snapshot is owed.
+	set("2017-01-03 12:30")
+	run(1, "2017-01-03 13:00")
+	set("2017-01-03 12:59")
+	run(0, "2017-01-03 13:00")
+
+	// Overnight there are none.
+	set("2017-01-03 17:00")
+	run(1, "2017-01-04 09:00")
+	set("2017-01-04 03:00")
+	run(0, "2017-01-04 09:00")
+
+	// An empty schedule restores the server's default of 12 hours.
+	if _, err := putSnapshotSchedule(t, s, cfg, ""); err != nil {
+		t.Fatal(err)
+	}
+	run(0, "2017-01-04 05:00")
+
+	// Bad schedules are refused.
+	_, err := putSnapshotSchedule(t, s, cfg, "every hour")
+	if !errors.Is(errors.Invalid, err) {
+		t.Errorf("Put of bad schedule: err = %v, want Invalid", err)
+	}
+
+	// Only the owner may set the schedule.
+	spy, spyCfg := newDirServerForTesting(t, "spy@nsa.gov")
+	_, err = putSnapshotSchedule(t, spy, spyCfg, "1h")
+	if !errors.Is(errors.Private, err) && !errors.Is(errors.Permission, err) {
+		t.Errorf("Put of schedule by non-owner: err = %v, want Private or Permission", err)
+	}
+}
+
+// putSnapshotSchedule puts a Schedule file for snapshotUser holding the
+// given contents, packed so the server can read it.
+func putSnapshotSchedule(t *testing.T, s *server, cfg upspin.Config, contents string) (*upspin.DirEntry, error) {
+	name := upspin.PathName(snapshotUser + "/" + snapshotScheduleFile)
+	de := &upspin.DirEntry{
+		Name:       name,
+		SignedName: name,
+		Time:       upspin.Now(),
+		Sequence:   upspin.SeqIgnore,
+		Writer:     cfg.UserName(),
+		Packing:    upspin.EEIntegrityPack,
+	}
+	if contents != "" {
+		bp, err := pack.Lookup(upspin.EEIntegrityPack).Pack(cfg, de)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cipher, err := bp.Pack([]byte(contents))
+		if err != nil {
+			t.Fatal(err)
+		}
+		bp.SetLocation(writeToStore(t, cfg, cipher))
+		if err := bp.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s.Put(de)
+}
+
 func create(t *testing.T, s *server, name upspin.PathName, isDir bool) {
 	var err error
 	if isDir {
@@ -5,8 +5,10 @@
 package tree
 
 import (
+	"fmt"
 	"os"
 	"reflect"
+	"sync"
 	"testing"
 	"time"
 
@@ -689,6 +691,159 @@ func TestList(t *testing.T) {
 	}
 }
 
+// TestConcurrentLookup checks that lookups and lists, some of which
+// must load nodes from the Store, may run alongside puts.
+func TestConcurrentLookup(t *testing.T) {
+	config, user := newConfigForTesting(t, userName)
+	tree, err := New(config, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buildTree(t, tree, config)
+
+	// Start afresh, so the first accesses load nodes from the Store.
+	tree, err = New(config, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := mkpath(t, userName+"/orig/sub1/file1.txt")
+	dir := mkpath(t, userName+"/orig/sub1")
+	var wg sync.WaitGroup
+	errc := make(chan error, 100)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 20; j++ {
+				if _, _, err := tree.Lookup(file); err != nil {
+					errc <- err
+					return
+				}
+				if _, _, err := tree.List(dir); err != nil {
+					errc <- err
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 20; j++ {
+			if _, err := tree.Put(newDirEntry(upspin.PathName(fmt.Sprintf("/other/file%d", j)), !isDir, config)); err != nil {
+				errc <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(errc)
+	for err := range errc {
+		t.Error(err)
+	}
+
+	entries, _, err := tree.List(mkpath(t, userName+"/other"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(entries), 20; got != want {
+		t.Errorf("len(entries) = %d, want = %d", got, want)
+	}
+}
+
+func TestListPrefix(t *testing.T) {
+	config, user := newConfigForTesting(t, userName)
+	tree, err := New(config, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(name upspin.PathName, isDir bool) {
+		t.Helper()
+		p, de := newDirEntry(name, isDir, config)
+		if _, err := tree.Put(p, de); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("/", isDir)
+	put("/photos", isDir)
+	for _, name := range []upspin.PathName{"2024-12", "2025-01", "2025-02", "2026-01", "202"} {
+		put("/photos/"+name, !isDir)
+	}
+	photos := mkpath(t, userName+"/photos")
+	check := func(prefix string, names ...upspin.PathName) {
+		t.Helper()
+		entries, _, err := tree.ListPrefix(photos, prefix)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := make(map[upspin.PathName]upspin.PathName)
+		for _, name := range names {
+			want[userName+"/photos/"+name] = userName + "/photos/" + name
+		}
+		if err := checkDirList(entries, want); err != nil {
+			t.Errorf("ListPrefix(%q): %v", prefix, err)
+		}
+	}
+	check("2025", "2025-01", "2025-02")
+	check("202", "202", "2024-12", "2025-01", "2025-02", "2026-01")
+	check("2027")
+
+	// The index follows changes to the directory.
+	put("/photos/2025-03", !isDir)
+	if _, err := tree.Delete(mkpath(t, userName+"/photos/2025-01")); err != nil {
+		t.Fatal(err)
+	}
+	check("2025", "2025-02", "2025-03")
+
+	// And is rebuilt once the directory is loaded again.
+	if err := tree.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	tree, err = New(config, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	check("2025", "2025-02", "2025-03")
+
+	// A file is listed as itself, whatever the prefix.
+	entries, _, err := tree.ListPrefix(mkpath(t, userName+"/photos/2024-12"), "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name != userName+"/photos/2024-12" {
+		t.Errorf("ListPrefix of file = %v, want the file", entries)
+	}
+}
+
+func BenchmarkLookupParallel(b *testing.B) {
+	config, user := newConfigForTesting(b, userName)
+	tree, err := New(config, user)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, name := range []upspin.PathName{"/", "/a", "/a/b", "/a/b/c"} {
+		if _, err := tree.Put(newDirEntry(name, isDir, config)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	var files []path.Parsed
+	for i := 0; i < 100; i++ {
+		p, de := newDirEntry(upspin.PathName(fmt.Sprintf("/a/b/c/file%d", i)), !isDir, config)
+		if _, err := tree.Put(p, de); err != nil {
+			b.Fatal(err)
+		}
+		files = append(files, p)
+	}
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			if _, _, err := tree.Lookup(files[i%len(files)]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
 func TestPutDirSameTreeNonRoot(t *testing.T) {
 	config, user := newConfigForTesting(t, userName)
 	tree, err := New(config, user)
@@ -1126,6 +1281,183 @@ func TestCorruptTreeAndRecover(t *testing.T) {
 
 var topDir string // where we write our test data.
 
+func TestMemoryBytes(t *testing.T) {
+	config, user := newConfigForTesting(t, userName)
+	tree, err := New(config, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkdir(t, tree, config, "/")
+	mkdir(t, tree, config, "/dir")
+	for i := 0; i < 20; i++ {
+		p, de := newDirEntry(upspin.PathName(fmt.Sprintf("/dir/file%d", i)), !isDir, config)
+		if _, err := tree.Put(p, de); err != nil {
+			t.Fatal(err)
+		}
+	}
+	loaded := tree.MemoryBytes()
+	if min := int64(22 * nodeOverhead); loaded < min {
+		t.Fatalf("MemoryBytes = %d, want at least %d", loaded, min)
+	}
+	if tree.Evictable() {
+		t.Fatal("tree with dirty entries is evictable")
+	}
+	if err := tree.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if !tree.Evictable() {
+		t.Fatal("flushed tree is not evictable")
+	}
+
+	// A tree loaded afresh holds only what has been looked up.
+	tree, err = New(config, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tree.Lookup(mkpath(t, userName+"/")); err != nil {
+		t.Fatal(err)
+	}
+	root := tree.MemoryBytes()
+	if root <= 0 || root >= loaded {
+		t.Fatalf("MemoryBytes with only root loaded = %d, want between 0 and %d", root, loaded)
+	}
+	if _, _, err := tree.Lookup(mkpath(t, userName+"/dir/file0")); err != nil {
+		t.Fatal(err)
+	}
+	if got := tree.MemoryBytes(); got <= root {
+		t.Fatalf("MemoryBytes after loading dir = %d, want more than %d", got, root)
+	}
+
+	// Deleting everything leaves only the root.
+	for i := 0; i < 20; i++ {
+		if _, err := tree.Delete(mkpath(t, upspin.PathName(fmt.Sprintf("%s/dir/file%d", userName, i)))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tree.Delete(mkpath(t, userName+"/dir")); err != nil {
+		t.Fatal(err)
+	}
+	if got := tree.MemoryBytes(); got != root {
+		t.Fatalf("MemoryBytes after deleting all = %d, want %d", got, root)
+	}
+}
+
+func TestApply(t *testing.T) {
+	config, user := newConfigForTesting(t, userName)
+	tree, err := New(config, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mkdir(t, tree, config, "/")
+	mkdir(t, tree, config, "/dir")
+	mkdir(t, tree, config, "/dir/sub")
+	for _, name := range []upspin.PathName{"/dir/a", "/dir/sub/b"} {
+		if _, err := tree.Put(newDirEntry(name, !isDir, config)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Rename dir to new in one batch.
+	put := func(name upspin.PathName, dir bool) *serverlog.Entry {
+		_, de := newDirEntry(name, dir, config)
+		return &serverlog.Entry{Op: serverlog.Put, Entry: *de}
+	}
+	del := func(name upspin.PathName) *serverlog.Entry {
+		return &serverlog.Entry{Op: serverlog.Delete, Entry: upspin.DirEntry{Name: userName + name}}
+	}
+	batch := []*serverlog.Entry{
+		put("/new", isDir),
+		put("/new/a", !isDir),
+		put("/new/sub", isDir),
+		put("/new/sub/b", !isDir),
+		del("/dir/sub/b"),
+		del("/dir/sub"),
+		del("/dir/a"),
+		del("/dir"),
+	}
+	entries, err := tree.Apply(batch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != len(batch) {
+		t.Fatalf("Apply returned %d entries, want %d", len(entries), len(batch))
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Sequence <= entries[i-1].Sequence {
+			t.Errorf("entry %d has sequence %d, not after %d", i, entries[i].Sequence, entries[i-1].Sequence)
+		}
+	}
+	checkRoot := func(tree *Tree) {
+		t.Helper()
+		list, _, err := tree.List(mkpath(t, userName+"/"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := map[upspin.PathName]upspin.PathName{
+			userName + "/new": userName + "/new",
+		}
+		if err := checkDirList(list, want); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := tree.Lookup(mkpath(t, userName+"/new/sub/b")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkRoot(tree)
+
+	// A batch that fails part way leaves the tree and log untouched.
+	offset := user.AppendOffset()
+	_, err = tree.Apply([]*serverlog.Entry{
+		put("/x", isDir),
+		del("/nothere"),
+	})
+	if !errors.Is(errors.NotExist, err) {
+		t.Fatalf("Apply with missing entry: err = %v, want NotExist", err)
+	}
+	if got := user.AppendOffset(); got != offset {
+		t.Errorf("log grew from %d to %d after failed batch", offset, got)
+	}
+	if _, _, err := tree.Lookup(mkpath(t, userName+"/x")); !errors.Is(errors.NotExist, err) {
+		t.Errorf("Lookup of /x after failed batch: err = %v, want NotExist", err)
+	}
+	checkRoot(tree)
+
+	// A directory cannot be overwritten.
+	_, err = tree.Apply([]*serverlog.Entry{put("/new", !isDir)})
+	if !errors.Is(errors.Exist, err) {
+		t.Fatalf("Apply overwriting directory: err = %v, want Exist", err)
+	}
+	checkRoot(tree)
+
+	// An incomplete batch at the end of the log is discarded on
+	// recovery, as if after a crash while writing it.
+	offset = user.AppendOffset()
+	_, de := newDirEntry("/partial", isDir, config)
+	if err := user.Append(&serverlog.Entry{Op: serverlog.Put, Entry: *de, Batched: true}); err != nil {
+		t.Fatal(err)
+	}
+	tree, err = New(config, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkRoot(tree)
+	if got := user.AppendOffset(); got != offset {
+		t.Errorf("log ends at %d after recovery, want %d", got, offset)
+	}
+
+	// A complete batch is recovered.
+	if err := user.AppendBatch([]*serverlog.Entry{put("/r", isDir), put("/r/f", !isDir)}); err != nil {
+		t.Fatal(err)
+	}
+	tree, err = New(config, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := tree.Lookup(mkpath(t, userName+"/r/f")); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestMain(m *testing.M) {
 	var err error
 	topDir, err = os.MkdirTemp("", "Tree")
@@ -1172,7 +1504,7 @@ func checkDirList(got []*upspin.DirEntry, want map[upspin.PathName]upspin.PathNa
 	return nil
 }
 
-func mkpath(t *testing.T, pathName upspin.PathName) path.Parsed {
+func mkpath(t testing.TB, pathName upspin.PathName) path.Parsed {
 	p, err := path.Parse(pathName)
 	if err != nil {
 		t.Fatal(err)
@@ -1218,7 +1550,7 @@ func newDirEntry(name upspin.PathName, isDir bool, config upspin.Config) (path.P
 
 // newConfigForTesting creates the necessary items to instantiate a Tree for
 // testing.
-func newConfigForTesting(t *testing.T, userName upspin.UserName) (upspin.Config, *serverlog.User) {
+func newConfigForTesting(t testing.TB, userName upspin.UserName) (upspin.Config, *serverlog.User) {
 	factotum, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "test"))
 	if err != nil {
 		t.Fatal(err)
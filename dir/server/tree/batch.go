@@ -0,0 +1,108 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"upspin.io/dir/server/serverlog"
+	"upspin.io/errors"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+// Apply applies the log entries to the tree, in order, as a single atomic
+// operation. Each entry's Op says whether its Entry is to be put or
+// deleted, as by Put or Delete, except that the root cannot be put or
+// deleted, a directory cannot be overwritten, and a path that crosses a
+// link is an error rather than ErrFollowLink.
+//
+// If every entry can be applied, Apply records them in the log as one
+// batch, flushes the tree, and returns the entries as put or deleted,
+// with their new sequence numbers. Otherwise the tree is left unchanged.
+func (t *Tree) Apply(entries []*serverlog.Entry) ([]*upspin.DirEntry, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if err := t.loadRoot(); err != nil {
+		return nil, err
+	}
+	// Flush first so the tree saved in the store is the one from before
+	// the batch, to which reset can return.
+	if err := t.flush(); err != nil {
+		return nil, err
+	}
+	seq := t.sequence
+
+	result := make([]*upspin.DirEntry, len(entries))
+	for i, le := range entries {
+		de, err := t.apply(le)
+		if err != nil {
+			t.reset(seq)
+			return nil, err
+		}
+		result[i] = de
+	}
+	if err := t.user.AppendBatch(entries); err != nil {
+		t.reset(seq)
+		return nil, err
+	}
+	for _, le := range entries {
+		t.notifyWatchers(le.Entry.Name)
+	}
+	// The batch is in the log now, so if the flush fails it will
+	// be recovered from there.
+	if err := t.flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// apply applies a single log entry for Apply, updating the entry to
+// record the change as it should be logged.
+// t.mu must be held.
+func (t *Tree) apply(le *serverlog.Entry) (*upspin.DirEntry, error) {
+	p, err := path.Parse(le.Entry.Name)
+	if err != nil {
+		return nil, err
+	}
+	if p.IsRoot() {
+		return nil, errors.E(errors.Invalid, p.Path(), "cannot put or delete a root in a batch")
+	}
+
+	var n *node
+	switch le.Op {
+	case serverlog.Put:
+		if existing, lerr := t.loadPath(p); lerr == nil && (existing.entry.IsDir() || le.Entry.IsDir()) {
+			return nil, errors.E(errors.Exist, p.Path(), "cannot overwrite directory")
+		}
+		n, err = t.put(p, &le.Entry)
+		if err == nil {
+			return le.Entry.Copy(), nil
+		}
+	case serverlog.Delete:
+		n, err = t.delete(p)
+		if err == nil {
+			le.Entry = n.entry
+			return n.entry.Copy(), nil
+		}
+	default:
+		return nil, errors.E(errors.Invalid, p.Path(), errors.Errorf("no such log operation: %v", le.Op))
+	}
+	if err == upspin.ErrFollowLink {
+		return nil, errors.E(errors.Invalid, p.Path(), errors.Errorf("path crosses link: %s", n.entry.Name))
+	}
+	return nil, err
+}
+
+// reset discards all changes not yet flushed, returning the tree to the
+// state last saved, whose sequence number was seq.
+// t.mu must be held.
+func (t *Tree) reset(seq int64) {
+	if t.root != nil {
+		t.forget(t.root)
+	}
+	t.root = nil
+	t.dirtyNodes = nil
+	t.sequence = seq
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tree
+
+// This file implements the index of a directory node's kids by name,
+// which lets List find the kids whose names begin with a given prefix,
+// as when globbing a pattern such as ann@example.com/photos/2025*,
+// in time proportional to the number found rather than to the size of
+// the directory.
+//
+// The index is a sorted slice of the names. It is built when first
+// needed, which requires t.mu to be held for writing, and is then kept
+// up to date as kids are added and removed. When a node's kids are
+// loaded from the Store, or replayed from the log, the index is dropped
+// and built again on demand.
+
+import (
+	"sort"
+	"strings"
+)
+
+// indexed reports whether the node's index is up to date.
+func (n *node) indexed() bool {
+	return n.names != nil || len(n.kids) == 0
+}
+
+// buildIndex builds the node's index, if it is not up to date.
+// t.mu must be held for writing.
+func (n *node) buildIndex() {
+	if n.indexed() {
+		return
+	}
+	n.names = make([]string, 0, len(n.kids))
+	for elem := range n.kids {
+		n.names = append(n.names, elem)
+	}
+	sort.Strings(n.names)
+}
+
+// dropIndex discards the node's index.
+func (n *node) dropIndex() {
+	n.names = nil
+}
+
+// indexAdd records in the node's index, if it has one, that its kids
+// include elem.
+func (n *node) indexAdd(elem string) {
+	if n.names == nil {
+		return
+	}
+	i := sort.SearchStrings(n.names, elem)
+	if i < len(n.names) && n.names[i] == elem {
+		return
+	}
+	n.names = append(n.names, "")
+	copy(n.names[i+1:], n.names[i:])
+	n.names[i] = elem
+}
+
+// indexRemove records in the node's index, if it has one, that elem is
+// no longer one of its kids.
+func (n *node) indexRemove(elem string) {
+	if n.names == nil {
+		return
+	}
+	i := sort.SearchStrings(n.names, elem)
+	if i == len(n.names) || n.names[i] != elem {
+		return
+	}
+	n.names = append(n.names[:i], n.names[i+1:]...)
+	if len(n.names) == 0 {
+		n.names = nil
+	}
+}
+
+// kidsWithPrefix returns the node's kids whose names begin with prefix,
+// in order. The index must be up to date.
+func (n *node) kidsWithPrefix(prefix string) []*node {
+	var kids []*node
+	for i := sort.SearchStrings(n.names, prefix); i < len(n.names); i++ {
+		name := n.names[i]
+		if !strings.HasPrefix(name, prefix) {
+			break
+		}
+		kids = append(kids, n.kids[name])
+	}
+	return kids
+}
@@ -0,0 +1,79 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"sync/atomic"
+
+	"upspin.io/upspin"
+)
+
+// The Tree keeps an approximate count of the memory used by its loaded
+// nodes so that the DirServer can bound the memory held by all the trees
+// it caches. Each node's size is computed once, when the node is loaded
+// or created, and that same amount is subtracted when it is dropped.
+
+const (
+	// nodeOverhead approximates the memory used by a node, its
+	// DirEntry and its slot in its parent's kids map, excluding the
+	// variable-length fields of the DirEntry.
+	nodeOverhead = 256
+
+	// blockOverhead approximates the memory used by a DirBlock,
+	// excluding its variable-length fields.
+	blockOverhead = 96
+)
+
+// nodeSize returns the approximate number of bytes of memory used by
+// a node holding the entry.
+func nodeSize(e *upspin.DirEntry) int64 {
+	n := nodeOverhead + len(e.Name) + len(e.SignedName) + len(e.Link) + len(e.Writer) + len(e.Packdata)
+	for i := range e.Blocks {
+		b := &e.Blocks[i]
+		n += blockOverhead + len(b.Location.Reference) + len(b.Location.Endpoint.NetAddr) + len(b.Packdata)
+	}
+	return int64(n)
+}
+
+// account adds the node's size to the tree's memory usage.
+// t.mu must be held.
+func (t *Tree) account(n *node) {
+	n.size = nodeSize(&n.entry)
+	atomic.AddInt64(&t.bytes, n.size)
+}
+
+// forget subtracts the sizes of the node and its loaded descendants from
+// the tree's memory usage.
+// t.mu must be held.
+func (t *Tree) forget(n *node) {
+	for _, kid := range n.kids {
+		t.forget(kid)
+	}
+	atomic.AddInt64(&t.bytes, -n.size)
+	n.size = 0
+}
+
+// MemoryBytes returns the approximate number of bytes of memory used by
+// the tree's loaded entries. Unlike the Tree's other methods, it does not
+// wait for operations in progress.
+func (t *Tree) MemoryBytes() int64 {
+	return atomic.LoadInt64(&t.bytes)
+}
+
+// Evictable reports whether the tree may be dropped from memory without
+// losing or disrupting anything: it has no dirty entries, no active
+// watchers and no operation in progress.
+func (t *Tree) Evictable() bool {
+	if !t.mu.TryLock() {
+		return false
+	}
+	defer t.mu.Unlock()
+	for _, m := range t.dirtyNodes {
+		if len(m) > 0 {
+			return false
+		}
+	}
+	return len(t.watchers) == 0
+}
@@ -0,0 +1,79 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package tree
+
+import (
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// LogRecord is a record from a Tree's log, in the form in which it is
+// stored there.
+type LogRecord struct {
+	Offset int64  // The offset of the record in the log.
+	Record []byte // As returned by serverlog.Entry.Record.
+}
+
+// ReadLog returns up to max records from the Tree's log, starting with
+// the one at offset, which must be at a record boundary. It returns no
+// records if offset is the end of the log.
+func (t *Tree) ReadLog(offset int64, max int) ([]LogRecord, error) {
+	if t.user.V1Transition() != 0 {
+		// Records in the old format are rewritten when read,
+		// so their offsets cannot be preserved.
+		return nil, errors.E(errors.Invalid, t.user.Name(), "cannot replicate logs in the version 0 format")
+	}
+	if end := t.user.AppendOffset(); offset > end {
+		return nil, errors.E(errors.Invalid, t.user.Name(), errors.Errorf("offset %d is beyond the end of the log at %d", offset, end))
+	}
+	r, err := t.user.NewReader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var records []LogRecord
+	for len(records) < max {
+		le, next, err := r.ReadAt(offset)
+		if err != nil {
+			return nil, errors.E(t.user.Name(), errors.Errorf("cannot read log at offset %d: %v", offset, err))
+		}
+		if next == offset {
+			break
+		}
+		rec, err := le.Record()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, LogRecord{Offset: offset, Record: rec})
+		offset = next
+	}
+	return records, nil
+}
+
+// SavedRoot returns the root most recently saved to stable storage,
+// or nil if there is none, and the log offset up to which that root
+// reflects the log.
+func (t *Tree) SavedRoot() (root *upspin.DirEntry, checkpoint int64, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Tree.flush saves both while holding t.mu, so they are consistent.
+	checkpoint, err = t.user.ReadOffset()
+	if errors.Is(errors.NotExist, err) {
+		return nil, 0, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	root, err = t.user.Root()
+	if errors.Is(errors.NotExist, err) {
+		return nil, checkpoint, nil
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return root, checkpoint, nil
+}
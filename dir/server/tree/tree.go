@@ -22,6 +22,8 @@ import (
 	"upspin.io/upspin"
 )
 
+var logger = log.For("dir/server/tree")
+
 // node is an internal representation of a node in the tree.
 // All node accesses must be protected by the tree's mutex.
 type node struct {
@@ -34,9 +36,17 @@ type node struct {
 	// (not all subdir nodes may be in-memory at a given time).
 	kids map[string]*node
 
+	// names holds the sorted names of the kids, if they are indexed.
+	// See index.go.
+	names []string
+
 	// dirty indicates whether this node's DirEntry has been modified
 	// since it was last written to the store.
 	dirty bool
+
+	// size is the approximate number of bytes of memory used by the
+	// node, as counted in its tree's memory usage. See memory.go.
+	size int64
 }
 
 // Tree is a representation of a directory tree for a single Upspin user.
@@ -44,9 +54,16 @@ type node struct {
 // configured when instantiating the Tree. It uses a Log to log changes not
 // yet committed to the Store.
 type Tree struct {
+	// bytes is the approximate memory used by the loaded nodes.
+	// It is accessed atomically and is first in the struct to
+	// keep it 64-bit aligned.
+	bytes int64
+
 	// mu protects all accesses to the tree and its nodes and must
-	// be held when calling all unexported methods.
-	mu sync.Mutex
+	// be held when calling all unexported methods. Only findPath may
+	// be called with it held just for reading, as it changes nothing;
+	// the others may load nodes from the Store and need it for writing.
+	mu sync.RWMutex
 
 	user     *serverlog.User
 	sequence int64 // Most recent sequence number for user.
@@ -140,12 +157,26 @@ func New(config upspin.Config, user *serverlog.User) (*Tree, error) {
 // operation as outlined in the description for upspin.ErrFollowLink.
 // Otherwise in the case of error the returned DirEntry will be nil.
 func (t *Tree) Lookup(p path.Parsed) (de *upspin.DirEntry, dirty bool, err error) {
+	// Most lookups find their nodes loaded and may proceed concurrently.
+	t.mu.RLock()
+	de, dirty, err = t.lookup(p, t.findPath)
+	t.mu.RUnlock()
+	if err != errNotLoaded {
+		return de, dirty, err
+	}
+
+	// Some of the path must be loaded from the Store.
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	return t.lookup(p, t.loadPath)
+}
 
-	node, err := t.loadPath(p)
+// lookup implements Lookup using find, which is findPath or loadPath.
+// t.mu must be held, for writing if find is loadPath.
+func (t *Tree) lookup(p path.Parsed, find func(path.Parsed) (*node, error)) (de *upspin.DirEntry, dirty bool, err error) {
+	node, err := find(p)
 	if err == upspin.ErrFollowLink {
-		return &node.entry, node.dirty, err
+		return node.entry.Copy(), node.dirty, err
 	}
 	if err != nil {
 		return nil, false, err
@@ -161,33 +192,52 @@ func (t *Tree) Lookup(p path.Parsed) (de *upspin.DirEntry, dirty bool, err error
 // (with the added step of updating the Name field of the argument
 // DirEntry). Otherwise, the returned DirEntry will be the one put.
 func (t *Tree) Put(p path.Parsed, de *upspin.DirEntry) (*upspin.DirEntry, error) {
+	wait, de, err := t.putLogged(p, de)
+	if err != nil {
+		return de, err
+	}
+	// Wait for the log to be flushed without holding the lock,
+	// so other changes may share the flush.
+	if err := wait(); err != nil {
+		return nil, err
+	}
+	return de, nil
+}
+
+// putLogged implements Put. It returns a function that waits until the
+// log entry recording the Put is flushed to stable storage.
+func (t *Tree) putLogged(p path.Parsed, de *upspin.DirEntry) (wait func() error, _ *upspin.DirEntry, _ error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	if p.IsRoot() {
-		return de, t.createRoot(p, de)
+		return noWait, de, t.createRoot(p, de)
 	}
 
 	node, err := t.put(p, de)
 	if err == upspin.ErrFollowLink {
-		return node.entry.Copy(), err
+		return nil, node.entry.Copy(), err
 	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	// Generate log entry.
 	logEntry := &serverlog.Entry{
 		Op:    serverlog.Put,
 		Entry: *de,
 	}
-	err = t.user.Append(logEntry)
+	wait, err = t.user.AppendDeferred(logEntry)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	t.notifyWatchers(de.Name)
-	return de.Copy(), nil
+	return wait, de.Copy(), nil
 }
 
+// noWait is a function, as returned by putLogged and deleteLogged, for
+// a change that need not wait.
+func noWait() error { return nil }
+
 // put implements the bulk of Tree.Put, but does not append to the log so it
 // can be used to recover the Tree's state from the log.
 // t.mu must be held.
@@ -210,6 +260,7 @@ func (t *Tree) put(p path.Parsed, de *upspin.DirEntry) (*node, error) {
 	node := &node{
 		entry: *de,
 	}
+	t.account(node)
 	err = t.addKid(node, p, parent, parentPath)
 	if err != nil {
 		return nil, err
@@ -253,6 +304,9 @@ func (t *Tree) PutDir(dstDir path.Parsed, de *upspin.DirEntry) (*upspin.DirEntry
 
 	// Put the synthetic node into the tree at dst.
 	n, err := t.put(dstDir, &existingEntryNode.entry)
+	// The synthetic node's kids are not used by the new node, which
+	// will load its own when needed.
+	t.forget(existingEntryNode)
 	if err == upspin.ErrFollowLink {
 		return nil, errors.E(errors.Invalid, dstDir.Path(), "path cannot contain a link")
 	}
@@ -299,11 +353,16 @@ func (t *Tree) addKid(n *node, nodePath path.Parsed, parent *node, parentPath pa
 	nElem := parentPath.NElem()
 	if nodePath.Drop(1).Path() != parentPath.Path() {
 		err := errors.E(nodePath.Path(), errors.Internal, "parent path does match parent of dir path")
-		log.Error.Print(err)
+		logger.Error.Print(err)
 		return err
 	}
 	// No need to check if it exists. Simply overwrite. DirServer checks these things.
-	parent.kids[nodePath.Elem(nElem)] = n
+	elem := nodePath.Elem(nElem)
+	if old, ok := parent.kids[elem]; ok {
+		t.forget(old)
+	}
+	parent.kids[elem] = n
+	parent.indexAdd(elem)
 	// Mark entire path as dirty, from the point that needs to be re-packed
 	// and up to the root.
 	if n.entry.IsDir() && len(n.entry.Blocks) == 0 {
@@ -339,7 +398,7 @@ func (t *Tree) markDirty(p path.Parsed) error {
 		// names and references packed in them.
 		if !n.entry.IsDir() {
 			err := errors.E(errors.Internal, n.entry.Name, "marking non-dir dirty")
-			log.Error.Printf("%s", err)
+			logger.Error.Printf("%s", err)
 			return err
 		}
 		t.setNodeDirtyAt(i+1, n)
@@ -388,12 +447,52 @@ func (t *Tree) loadPath(p path.Parsed) (*node, error) {
 	return node, nil
 }
 
+// errNotLoaded is returned by findPath when a node must be loaded from
+// the Store to resolve the path.
+var errNotLoaded = errors.Str("node not loaded")
+
+// findPath is like loadPath but loads no nodes, returning errNotLoaded
+// if it would need to. It changes nothing, so t.mu need be held only
+// for reading.
+func (t *Tree) findPath(p path.Parsed) (*node, error) {
+	if t.root == nil {
+		return nil, errNotLoaded
+	}
+	node := t.root
+	for i := 0; i < p.NElem(); i++ {
+		if node.entry.IsLink() {
+			return node, upspin.ErrFollowLink
+		}
+		elem := p.Elem(i)
+		if !node.entry.IsDir() {
+			return node, errors.E(errors.NotExist, path.Join(node.entry.Name, elem))
+		}
+		if !loaded(node) {
+			return nil, errNotLoaded
+		}
+		child, ok := node.kids[elem]
+		if !ok {
+			return node, errors.E(errors.NotExist, path.Join(node.entry.Name, elem))
+		}
+		node = child
+	}
+	if node.entry.Name != p.Path() {
+		return node, errors.E(errors.NotExist, p.Path())
+	}
+	return node, nil
+}
+
+// loaded reports whether the kids of a directory's node are loaded.
+func loaded(dir *node) bool {
+	return dir.kids != nil || len(dir.entry.Blocks) == 0
+}
+
 // loadDir loads the contents of a directory's node if it's not already loaded.
 // The node must be known to be a directory and cannot be a link.
 // t.mu must be held.
 func (t *Tree) loadDir(dir *node) error {
 	// Must load from store if kids are not loaded.
-	if dir.kids == nil && len(dir.entry.Blocks) > 0 {
+	if !loaded(dir) {
 		err := t.loadKids(dir)
 		if err != nil {
 			return err
@@ -417,10 +516,8 @@ func (t *Tree) loadNode(parent *node, elem string) (*node, error) {
 	if err != nil {
 		return nil, err
 	}
-	for dirName, node := range parent.kids {
-		if elem == dirName {
-			return node, nil
-		}
+	if node, ok := parent.kids[elem]; ok {
+		return node, nil
 	}
 	return nil, errors.E(errors.NotExist, path.Join(parent.entry.Name, elem))
 }
@@ -438,7 +535,11 @@ func (t *Tree) loadKids(parent *node) error {
 	if err != nil {
 		return err
 	}
+	for _, kid := range kids {
+		t.account(kid)
+	}
 	parent.kids = kids
+	parent.dropIndex()
 	return nil
 }
 
@@ -458,6 +559,7 @@ func (t *Tree) loadRoot() error {
 	t.root = &node{
 		entry: *rootDirEntry,
 	}
+	t.account(t.root)
 	t.sequence = rootDirEntry.Sequence
 	return nil
 }
@@ -482,13 +584,14 @@ func (t *Tree) createRoot(p path.Parsed, de *upspin.DirEntry) error {
 	// To be sure, the log must be empty too (or t.root wouldn't be empty).
 	if t.user.AppendOffset() != 0 {
 		err := errors.E(errors.Internal, "index not empty, but root not found")
-		log.Error.Print(err)
+		logger.Error.Print(err)
 		return err
 	}
 	// Finally let's create it.
 	node := &node{
 		entry: *de,
 	}
+	t.account(node)
 	t.root = node
 	t.sequence = upspin.SeqBase
 	de.Sequence = upspin.SeqBase
@@ -499,7 +602,7 @@ func (t *Tree) createRoot(p path.Parsed, de *upspin.DirEntry) error {
 	// The root of the tree must be flushed immediately or its recovery
 	// becomes cumbersome. Nothing else exists prior to a root existing,
 	// so only the root will be flushed.
-	log.Debug.Printf("Created root: %s", p)
+	logger.Debug.Printf("Created root: %s", p)
 	return t.flush()
 }
 
@@ -514,10 +617,37 @@ func (t *Tree) createRoot(p path.Parsed, de *upspin.DirEntry) error {
 // upspin.ErrFollowLink. (And in that case, only one DirEntry will be
 // returned, that of the link itself.)
 func (t *Tree) List(prefix path.Parsed) ([]*upspin.DirEntry, bool, error) {
+	return t.ListPrefix(prefix, "")
+}
+
+// ListPrefix is like List but, if prefix names a directory, returns only
+// the entries whose final path element begins with elemPrefix. For a
+// large directory this is much faster than listing all of its entries
+// and discarding those that do not match.
+func (t *Tree) ListPrefix(prefix path.Parsed, elemPrefix string) ([]*upspin.DirEntry, bool, error) {
+	// As with Lookup, try first without loading anything.
+	t.mu.RLock()
+	entries, dirty, err := t.list(prefix, elemPrefix, false)
+	t.mu.RUnlock()
+	if err != errNotLoaded {
+		return entries, dirty, err
+	}
+
 	t.mu.Lock()
 	defer t.mu.Unlock()
+	return t.list(prefix, elemPrefix, true)
+}
 
-	node, err := t.loadPath(prefix)
+// list implements ListPrefix. If load is false, it loads no nodes, nor
+// builds the index of a directory's kids, and returns errNotLoaded if
+// it would need to.
+// t.mu must be held, for writing if load is true.
+func (t *Tree) list(prefix path.Parsed, elemPrefix string, load bool) ([]*upspin.DirEntry, bool, error) {
+	find := t.findPath
+	if load {
+		find = t.loadPath
+	}
+	node, err := find(prefix)
 	if err == upspin.ErrFollowLink {
 		return []*upspin.DirEntry{node.entry.Copy()}, node.dirty, err
 	}
@@ -530,13 +660,28 @@ func (t *Tree) List(prefix path.Parsed) ([]*upspin.DirEntry, bool, error) {
 	if !node.entry.IsDir() {
 		return []*upspin.DirEntry{node.entry.Copy()}, node.dirty, nil
 	}
+	if !load && !loaded(node) {
+		return nil, false, errNotLoaded
+	}
 	err = t.loadDir(node)
 	if err != nil {
 		return nil, false, err
 	}
 	dirty := node.dirty
 	var entries []*upspin.DirEntry
-	for _, n := range node.kids {
+	if elemPrefix == "" {
+		for _, n := range node.kids {
+			entries = append(entries, n.entry.Copy())
+		}
+		return entries, dirty, nil
+	}
+	if !node.indexed() {
+		if !load {
+			return nil, false, errNotLoaded
+		}
+		node.buildIndex()
+	}
+	for _, n := range node.kidsWithPrefix(elemPrefix) {
 		entries = append(entries, n.entry.Copy())
 	}
 	return entries, dirty, nil
@@ -552,31 +697,45 @@ func (t *Tree) List(prefix path.Parsed) ([]*upspin.DirEntry, bool, error) {
 // returned DirEntry will be nil whether the operation succeeded
 // or not.
 func (t *Tree) Delete(p path.Parsed) (*upspin.DirEntry, error) {
+	wait, de, err := t.deleteLogged(p)
+	if err != nil {
+		return de, err
+	}
+	// As in Put, wait for the flush without holding the lock.
+	if err := wait(); err != nil {
+		return nil, err
+	}
+	return de, nil
+}
+
+// deleteLogged implements Delete. It returns a function that waits until
+// the log entry recording the Delete is flushed to stable storage.
+func (t *Tree) deleteLogged(p path.Parsed) (wait func() error, _ *upspin.DirEntry, _ error) {
 	t.mu.Lock()
 	defer t.mu.Unlock()
 
 	if p.IsRoot() {
-		return nil, t.deleteRoot()
+		return noWait, nil, t.deleteRoot()
 	}
 
 	node, err := t.delete(p)
 	if err == upspin.ErrFollowLink {
-		return node.entry.Copy(), err
+		return nil, node.entry.Copy(), err
 	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	// Generate log entry.
 	logEntry := &serverlog.Entry{
 		Op:    serverlog.Delete,
 		Entry: node.entry,
 	}
-	err = t.user.Append(logEntry)
+	wait, err = t.user.AppendDeferred(logEntry)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	t.notifyWatchers(node.entry.Name)
-	return node.entry.Copy(), err
+	return wait, node.entry.Copy(), nil
 }
 
 // delete implements the bulk of Tree.Delete, but does not append to the log
@@ -613,6 +772,8 @@ func (t *Tree) delete(p path.Parsed) (*node, error) {
 	// Remove this elem from the parent's kids map.
 	// No need to check if it was there -- it wouldn't have loaded if it weren't.
 	delete(parent.kids, elem)
+	parent.indexRemove(elem)
+	t.forget(node)
 
 	// If node was dirty, there's no need to flush it to Store ever.
 	t.removeFromDirtyList(p, node)
@@ -633,7 +794,7 @@ func (t *Tree) deleteRoot() error {
 	if t.root == nil {
 		return errors.E(errors.NotExist, "root does not exist")
 	}
-	log.Debug.Printf("Deleting root %q", t.root.entry.Name)
+	logger.Debug.Printf("Deleting root %q", t.root.entry.Name)
 	if len(t.root.kids) > 0 {
 		// Root is not empty.
 		return errors.E(errors.NotEmpty, t.root.entry.Name)
@@ -655,6 +816,7 @@ func (t *Tree) deleteRoot() error {
 	if err != nil {
 		return err
 	}
+	t.forget(t.root)
 	t.root = nil
 	return nil
 }
@@ -762,7 +924,7 @@ func (t *Tree) recoverFromLog() error {
 	}
 	if lastOffset == lastProcessed {
 		// All caught up.
-		log.Debug.Printf("recoverFromLog: Tree is all caught up for user %s", t.user.Name())
+		logger.Debug.Printf("recoverFromLog: Tree is all caught up for user %s", t.user.Name())
 		return nil
 	}
 	err = t.loadRoot()
@@ -777,62 +939,92 @@ func (t *Tree) recoverFromLog() error {
 	}
 	recovered := 0
 	curr := lastProcessed
+	// The entries of a batch are held in batch until the batch's last
+	// entry is read, which starts at offset batchStart.
+	var batch []serverlog.Entry
+	var batchStart int64
 	for {
-		log.Debug.Printf("recoverFromLog: Recovering from log... %d", curr)
+		logger.Debug.Printf("recoverFromLog: Recovering from log... %d", curr)
 		logEntry, next, err := lrd.ReadAt(curr)
 		if err != nil {
-			log.Error.Printf("recoverFromLog: Error in log recovery, possible data loss at offset %d: %s", lastProcessed, err)
+			logger.Error.Printf("recoverFromLog: Error in log recovery, possible data loss at offset %d: %s", lastProcessed, err)
+			if len(batch) > 0 {
+				curr = batchStart
+			}
 			return t.user.Truncate(curr)
 		}
 		if next == curr {
 			break
 		}
-
-		de := logEntry.Entry
-		p, err := path.Parse(de.Name)
-		if err != nil {
-			// We don't expect this to fail because
-			// de.Name was in the log already and thus
-			// has been validated.
-			return err
+		if len(batch) == 0 {
+			batchStart = curr
+		}
+		batch = append(batch, logEntry)
+		curr = next
+		if logEntry.Batched {
+			continue
 		}
 
-		switch logEntry.Op {
-		case serverlog.Put:
-			log.Debug.Printf("recoverFromLog: Putting dirEntry: %q", de.Name)
-			_, err = t.put(p, &de)
-		case serverlog.Delete:
-			log.Debug.Printf("recoverFromLog: Deleting path: %q", p.Path())
-			_, err = t.delete(p)
-		default:
-			return errors.E(errors.Internal, errors.Errorf("no such log operation: %v", logEntry.Op))
+		for _, logEntry := range batch {
+			if err := t.replay(&logEntry); err != nil {
+				// Now we're in serious trouble. We can't recover.
+				return errors.E(t.user.Name(), errors.Errorf("can't recover log: %v", err))
+			}
+			recovered++
 		}
-		if err != nil {
-			// Now we're in serious trouble. We can't recover.
-			return errors.E(t.user.Name(), errors.Errorf("can't recover log: %v", err))
+		batch = batch[:0]
+	}
+	if len(batch) > 0 {
+		// The last batch was not completely written. Discard it.
+		logger.Error.Printf("recoverFromLog: Discarding incomplete batch of %d entries at offset %d", len(batch), batchStart)
+		if err := t.user.Truncate(batchStart); err != nil {
+			return err
 		}
-		recovered++
-		curr = next
 	}
-	log.Debug.Printf("recoverFromLog: %d entries recovered. Tree is current.", recovered)
-	log.Debug.Printf("recoverFromLog: Tree:\n%s\n", t)
+	logger.Debug.Printf("recoverFromLog: %d entries recovered. Tree is current.", recovered)
+	logger.Debug.Printf("recoverFromLog: Tree:\n%s\n", t)
 	return nil
 }
 
+// replay applies a log entry to the tree during recovery.
+// t.mu must be held.
+func (t *Tree) replay(logEntry *serverlog.Entry) error {
+	de := logEntry.Entry
+	p, err := path.Parse(de.Name)
+	if err != nil {
+		// We don't expect this to fail because
+		// de.Name was in the log already and thus
+		// has been validated.
+		return err
+	}
+
+	switch logEntry.Op {
+	case serverlog.Put:
+		logger.Debug.Printf("recoverFromLog: Putting dirEntry: %q", de.Name)
+		_, err = t.put(p, &de)
+	case serverlog.Delete:
+		logger.Debug.Printf("recoverFromLog: Deleting path: %q", p.Path())
+		_, err = t.delete(p)
+	default:
+		return errors.E(errors.Internal, errors.Errorf("no such log operation: %v", logEntry.Op))
+	}
+	return err
+}
+
 // OnEviction implements cache.EvictionNotifier.
 func (t *Tree) OnEviction(key interface{}) {
-	log.Debug.Printf("OnEviction: tree being evicted: %s", t.user.Name())
+	logger.Debug.Printf("OnEviction: tree being evicted: %s", t.user.Name())
 	// We do not call t.Close here because we can't be sure the DirServer
 	// is done using us. But because this is likely our last chance to clean
 	// up, we set a finalizer.
 	err := t.Flush()
 	if err != nil {
-		log.Error.Printf("OnEviction: flush: %v", err)
+		logger.Error.Printf("OnEviction: flush: %v", err)
 	}
 	runtime.SetFinalizer(t, func(t *Tree) {
 		err := t.Close()
 		if err != nil {
-			log.Error.Printf("OnEviction: finalizing tree: %s", err)
+			logger.Error.Printf("OnEviction: finalizing tree: %s", err)
 		}
 	})
 }
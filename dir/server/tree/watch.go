@@ -22,7 +22,6 @@ import (
 
 	"upspin.io/dir/server/serverlog"
 	"upspin.io/errors"
-	"upspin.io/log"
 	"upspin.io/path"
 	"upspin.io/upspin"
 )
@@ -313,7 +312,7 @@ func (w *watcher) sendError(err error) {
 	case <-time.After(3 * watcherTimeout):
 		// Can't send another error since we timed out again. Log an
 		// error and close the watcher.
-		log.Error.Printf("dir/server/tree.sendError: %s", errTimeout)
+		logger.Error.Printf("dir/server/tree.sendError: %s", errTimeout)
 	}
 }
 
@@ -375,7 +374,7 @@ func (w *watcher) watch(offset int64, initialErr error) {
 		offset, err = w.sendEventFromLog(offset)
 		if err != nil {
 			if err != errTimeout && err != errClosed {
-				log.Debug.Printf("watch: sending error to client: %s", err)
+				logger.Debug.Printf("watch: sending error to client: %s", err)
 				w.sendError(err)
 			}
 			return
@@ -411,7 +410,7 @@ func (w *watcher) close() {
 func isPrefixPath(name upspin.PathName, prefix path.Parsed) bool {
 	parsed, err := path.Parse(name)
 	if err != nil {
-		log.Debug.Print("dir/server/tree.isPrefixPath: error parsing path", name)
+		logger.Debug.Print("dir/server/tree.isPrefixPath: error parsing path", name)
 		return false
 	}
 	return parsed.HasPrefix(prefix)
@@ -0,0 +1,165 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"fmt"
+	"os"
+	"testing"
+
+	"upspin.io/bind"
+	"upspin.io/config"
+	"upspin.io/dir/server/tree"
+	"upspin.io/factotum"
+	"upspin.io/test/testutil"
+	"upspin.io/upspin"
+)
+
+func TestTreeCacheBytes(t *testing.T) {
+	const (
+		numUsers = 4
+		numFiles = 300
+	)
+	// Register the server's key.
+	newDirServerForTesting(t, userName)
+
+	f, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	endpointInProcess := upspin.Endpoint{Transport: upspin.InProcess}
+	cfg := config.New()
+	cfg = config.SetUserName(cfg, serverName)
+	cfg = config.SetPacking(cfg, upspin.EEPack)
+	cfg = config.SetFactotum(cfg, f)
+	cfg = config.SetKeyEndpoint(cfg, endpointInProcess)
+	cfg = config.SetStoreEndpoint(cfg, endpointInProcess)
+	cfg = config.SetDirEndpoint(cfg, endpointInProcess)
+
+	dir, err := os.MkdirTemp("", "DirServerTreeCache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// The budget is set below, once the size of a tree is known.
+	gen, err := New(cfg, "logDir="+dir, "treeCacheBytes=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := gen.(*server).treeCacheBytes; got != 1 {
+		t.Fatalf("treeCacheBytes = %d, want 1", got)
+	}
+	key, err := bind.KeyServer(cfg, endpointInProcess)
+	if err != nil {
+		t.Fatal(err)
+	}
+	bob, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Build several large trees, each as its owner.
+	var users []*server
+	for i := 0; i < numUsers; i++ {
+		name := upspin.UserName(fmt.Sprintf("treecache%d@example.com", i))
+		err := key.Put(&upspin.User{
+			Name:      name,
+			Dirs:      []upspin.Endpoint{endpointInProcess},
+			Stores:    []upspin.Endpoint{endpointInProcess},
+			PublicKey: bob.PublicKey(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		userCfg := config.SetUserName(config.New(), name)
+		userCfg = config.SetFactotum(userCfg, bob)
+		s, err := gen.Dial(userCfg, endpointInProcess)
+		if err != nil {
+			t.Fatal(err)
+		}
+		users = append(users, s.(*server))
+	}
+	var treeSize int64
+	for i, s := range users {
+		if _, err := makeDirectory(s, upspin.PathName(s.userName+"/")); err != nil {
+			t.Fatal(err)
+		}
+		for j := 0; j < numFiles; j++ {
+			name := upspin.PathName(fmt.Sprintf("%s/file%d", s.userName, j))
+			_, err := s.Put(&upspin.DirEntry{
+				Name:       name,
+				SignedName: name,
+				Attr:       upspin.AttrNone,
+				Writer:     s.userName,
+				Packing:    upspin.PlainPack,
+				Sequence:   upspin.SeqIgnore,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+		}
+		tr := flushTree(t, s)
+		if i == 0 {
+			// Allow room for two trees, but not three.
+			treeSize = tr.MemoryBytes()
+			for _, s := range users {
+				s.treeCacheBytes = 5 * treeSize / 2
+			}
+		}
+		// Loading the tree enforces the budget.
+		flushTree(t, s)
+		if got, max := treeBytes.Value(), 5*treeSize/2; got > max {
+			t.Fatalf("after building tree %d: tree cache holds %d bytes, want at most %d", i, got, max)
+		}
+	}
+	if got := users[0].userTrees.Len(); got != 2 {
+		t.Errorf("tree cache holds %d trees, want 2", got)
+	}
+
+	// Evicted trees are reloaded when needed.
+	if _, err := users[0].Lookup(upspin.PathName(users[0].userName + "/file7")); err != nil {
+		t.Fatal(err)
+	}
+
+	// A dirty tree is never evicted, even when over budget.
+	name := upspin.PathName(users[0].userName + "/dirty")
+	_, err = users[0].Put(&upspin.DirEntry{
+		Name:       name,
+		SignedName: name,
+		Attr:       upspin.AttrNone,
+		Writer:     users[0].userName,
+		Packing:    upspin.PlainPack,
+		Sequence:   upspin.SeqIgnore,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range users[1:] {
+		if _, err := s.loadTreeFor(s.userName); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, ok := users[0].userTrees.Get(users[0].userName); !ok {
+		t.Error("dirty tree was evicted")
+	}
+
+	for _, s := range users {
+		if err := s.closeTree(s.userName); err != nil {
+			t.Error(err)
+		}
+	}
+}
+
+// flushTree loads and flushes the tree of the server's user.
+func flushTree(t *testing.T, s *server) *tree.Tree {
+	tr, err := s.loadTreeFor(s.userName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tr.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	return tr
+}
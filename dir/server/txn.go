@@ -0,0 +1,404 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+// This file implements upspin.DirTransactor. A prepared transaction is
+// recorded in a file of its own in the "tx" subdirectory of the log
+// directory, so that it survives a restart of the server, and the names
+// it changes are reserved until it is committed, aborted or expires.
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+var _ upspin.DirTransactor = (*server)(nil)
+
+// PreparePut implements upspin.DirTransactor.
+func (s *server) PreparePut(txID string, changes []upspin.DirChange) (err error) {
+	const op errors.Op = "dir/server.PreparePut"
+	defer ops.Observe("PreparePut", time.Now(), &err)
+	o, m := newOptMetric(op)
+	defer m.Done()
+
+	if err := validTxID(txID); err != nil {
+		return errors.E(op, err)
+	}
+	if len(changes) == 0 {
+		return errors.E(op, errors.Invalid, "empty transaction")
+	}
+	b, err := s.checkBatch(op, changes, o)
+	if err != nil {
+		return err
+	}
+	// ApplyBatch leaves it to the tree to find that a name to be
+	// deleted does not exist, but a transaction should not be
+	// prepared if it cannot be committed.
+	put := make(map[upspin.PathName]bool)
+	for i, p := range b.names {
+		if !b.deletes[i] {
+			put[p.Path()] = true
+			continue
+		}
+		if put[p.Path()] {
+			continue
+		}
+		if _, err := s.lookup(p, !entryMustBeClean, o); err == upspin.ErrFollowLink {
+			return errors.E(op, p.Path(), errors.Invalid, "path in batch crosses a link")
+		} else if err != nil {
+			return errors.E(op, err)
+		}
+	}
+	tx := &txn{
+		key:     txnKey{user: s.userName, id: txID},
+		changes: changes,
+		batch:   b,
+		expires: time.Now().Add(upspin.TxTimeout),
+	}
+	if err := s.txns.prepare(tx); err != nil {
+		return errors.E(op, err)
+	}
+	return nil
+}
+
+// CommitTx implements upspin.DirTransactor.
+func (s *server) CommitTx(txID string) (_ []*upspin.DirEntry, err error) {
+	const op errors.Op = "dir/server.CommitTx"
+	defer ops.Observe("CommitTx", time.Now(), &err)
+	o, m := newOptMetric(op)
+	defer m.Done()
+
+	key := txnKey{user: s.userName, id: txID}
+	tx, err := s.txns.startCommit(key)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	// The changes were checked by PreparePut and the names they
+	// change have been reserved since, so they are not checked again.
+	entries, err := s.applyBatch(op, tx.batch, o)
+	s.txns.endCommit(tx, err == nil)
+	return entries, err
+}
+
+// AbortTx implements upspin.DirTransactor.
+func (s *server) AbortTx(txID string) (err error) {
+	const op errors.Op = "dir/server.AbortTx"
+	defer ops.Observe("AbortTx", time.Now(), &err)
+
+	if err := s.txns.abort(txnKey{user: s.userName, id: txID}); err != nil {
+		return errors.E(op, err)
+	}
+	return nil
+}
+
+// validTxID reports whether id is a valid transaction ID.
+func validTxID(id string) error {
+	if id == "" || len(id) > 64 {
+		return errors.E(errors.Invalid, errors.Errorf("transaction ID %q must be 1 to 64 bytes long", id))
+	}
+	for _, c := range id {
+		switch {
+		case 'a' <= c && c <= 'z', 'A' <= c && c <= 'Z', '0' <= c && c <= '9', c == '-', c == '_':
+		default:
+			return errors.E(errors.Invalid, errors.Errorf("invalid character %q in transaction ID", c))
+		}
+	}
+	return nil
+}
+
+// txnKey identifies a transaction. Transaction IDs are chosen by the
+// users who prepare them, so they are unique only for each user.
+type txnKey struct {
+	user upspin.UserName
+	id   string
+}
+
+// A txn is a prepared transaction.
+type txn struct {
+	key     txnKey
+	changes []upspin.DirChange
+	batch   *batch
+	expires time.Time
+
+	// committing is set while CommitTx applies the transaction.
+	committing bool
+}
+
+// txnTable holds the prepared transactions of a server and the names they
+// reserve. It is shared by all the server's dialed instances.
+type txnTable struct {
+	dir string // Where the transactions are recorded.
+
+	mu   sync.Mutex
+	txns map[txnKey]*txn
+	// names maps each name changed by a prepared transaction to it.
+	names map[upspin.PathName]*txn
+	// parents counts, for each directory, the entries put into it by
+	// prepared transactions. Such a directory may not be deleted.
+	parents map[upspin.PathName]int
+}
+
+// openTxnTable returns a txnTable recording its transactions in dir,
+// holding the transactions already recorded there.
+func openTxnTable(dir string) (*txnTable, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	t := &txnTable{
+		dir:     dir,
+		txns:    make(map[txnKey]*txn),
+		names:   make(map[upspin.PathName]*txn),
+		parents: make(map[upspin.PathName]int),
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	for _, file := range files {
+		tx, err := readTxn(file)
+		if err != nil {
+			return nil, err
+		}
+		t.reserve(tx)
+	}
+	return t, nil
+}
+
+// checkFree returns an error of kind Transient if any of the names, which
+// are deleted if the corresponding element of deletes is true and
+// otherwise put, is reserved by a prepared transaction.
+func (t *txnTable) checkFree(names []path.Parsed, deletes []bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expire()
+	return t.conflict(nil, names, deletes)
+}
+
+// conflict is the implementation of checkFree for the changes of tx,
+// which may be nil. Changes do not conflict with their own transaction.
+// Called with t.mu locked.
+func (t *txnTable) conflict(tx *txn, names []path.Parsed, deletes []bool) error {
+	for i, p := range names {
+		if other, ok := t.names[p.Path()]; ok && other != tx {
+			return errors.E(p.Path(), errors.Transient, "name is being changed by a transaction")
+		}
+		if deletes[i] {
+			if t.parents[p.Path()] > tx.puts(p.Path()) {
+				return errors.E(p.Path(), errors.Transient, "directory is being changed by a transaction")
+			}
+			continue
+		}
+		parent := p.Drop(1).Path()
+		if other, ok := t.names[parent]; ok && other != tx {
+			return errors.E(p.Path(), errors.Transient, "directory is being changed by a transaction")
+		}
+	}
+	return nil
+}
+
+// puts returns the number of entries tx, which may be nil, puts into
+// the directory dir.
+func (tx *txn) puts(dir upspin.PathName) int {
+	if tx == nil {
+		return 0
+	}
+	n := 0
+	for i, p := range tx.batch.names {
+		if !tx.batch.deletes[i] && p.Drop(1).Path() == dir {
+			n++
+		}
+	}
+	return n
+}
+
+// prepare records tx and reserves the names it changes.
+func (t *txnTable) prepare(tx *txn) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expire()
+	if _, ok := t.txns[tx.key]; ok {
+		return errors.E(errors.Exist, errors.Errorf("transaction %q is already prepared", tx.key.id))
+	}
+	if err := t.conflict(tx, tx.batch.names, tx.batch.deletes); err != nil {
+		return err
+	}
+	if err := t.write(tx); err != nil {
+		return err
+	}
+	t.reserve(tx)
+	return nil
+}
+
+// startCommit returns the prepared transaction with the given key,
+// marked as being committed. Called by CommitTx, which must then call
+// endCommit.
+func (t *txnTable) startCommit(key txnKey) (*txn, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.expire()
+	tx, ok := t.txns[key]
+	if !ok {
+		return nil, errors.E(errors.NotExist, errors.Errorf("no prepared transaction %q", key.id))
+	}
+	if tx.committing {
+		return nil, errors.E(errors.Transient, errors.Errorf("transaction %q is being committed", key.id))
+	}
+	tx.committing = true
+	return tx, nil
+}
+
+// endCommit forgets tx if it was committed; otherwise it remains prepared.
+func (t *txnTable) endCommit(tx *txn, committed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tx.committing = false
+	if committed {
+		t.remove(tx)
+	}
+}
+
+// abort forgets the prepared transaction with the given key, if any.
+func (t *txnTable) abort(key txnKey) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tx, ok := t.txns[key]
+	if !ok {
+		return nil
+	}
+	if tx.committing {
+		return errors.E(errors.Transient, errors.Errorf("transaction %q is being committed", key.id))
+	}
+	t.remove(tx)
+	return nil
+}
+
+// expire forgets the transactions that have expired.
+// Called with t.mu locked.
+func (t *txnTable) expire() {
+	now := time.Now()
+	for _, tx := range t.txns {
+		if !tx.committing && now.After(tx.expires) {
+			logger.Info.Printf("dir/server: transaction %q of %s expired", tx.key.id, tx.key.user)
+			t.remove(tx)
+		}
+	}
+}
+
+// reserve adds tx to the table. Called with t.mu locked.
+func (t *txnTable) reserve(tx *txn) {
+	t.txns[tx.key] = tx
+	for i, p := range tx.batch.names {
+		t.names[p.Path()] = tx
+		if !tx.batch.deletes[i] {
+			t.parents[p.Drop(1).Path()]++
+		}
+	}
+}
+
+// remove removes tx from the table and its record from the disk.
+// Called with t.mu locked.
+func (t *txnTable) remove(tx *txn) {
+	delete(t.txns, tx.key)
+	for i, p := range tx.batch.names {
+		if t.names[p.Path()] == tx {
+			delete(t.names, p.Path())
+		}
+		if !tx.batch.deletes[i] {
+			parent := p.Drop(1).Path()
+			if t.parents[parent]--; t.parents[parent] <= 0 {
+				delete(t.parents, parent)
+			}
+		}
+	}
+	if err := os.Remove(t.file(tx.key)); err != nil && !os.IsNotExist(err) {
+		logger.Error.Printf("dir/server: removing record of transaction %q: %v", tx.key.id, err)
+	}
+}
+
+// file returns the name of the file recording the transaction.
+func (t *txnTable) file(key txnKey) string {
+	return filepath.Join(t.dir, hex.EncodeToString([]byte(string(key.user)+"/"+key.id))+".json")
+}
+
+// txnRecord is the form in which a transaction is recorded on disk.
+type txnRecord struct {
+	User    upspin.UserName
+	ID      string
+	Expires time.Time
+	Changes []txnChange
+}
+
+type txnChange struct {
+	Delete bool
+	Entry  []byte // A marshaled DirEntry.
+}
+
+// write records tx in its file.
+func (t *txnTable) write(tx *txn) error {
+	r := txnRecord{
+		User:    tx.key.user,
+		ID:      tx.key.id,
+		Expires: tx.expires,
+		Changes: make([]txnChange, len(tx.changes)),
+	}
+	for i, c := range tx.changes {
+		b, err := c.Entry.Marshal()
+		if err != nil {
+			return errors.E(errors.Invalid, err)
+		}
+		r.Changes[i] = txnChange{Delete: c.Delete, Entry: b}
+	}
+	data, err := json.Marshal(r)
+	if err != nil {
+		return errors.E(errors.Internal, err)
+	}
+	// Write the record atomically, so a crash cannot leave half of it.
+	file := t.file(tx.key)
+	if err := os.WriteFile(file+".tmp", data, 0600); err != nil {
+		return errors.E(errors.IO, err)
+	}
+	if err := os.Rename(file+".tmp", file); err != nil {
+		return errors.E(errors.IO, err)
+	}
+	return nil
+}
+
+// readTxn reads a transaction recorded by write.
+func readTxn(file string) (*txn, error) {
+	const op errors.Op = "dir/server.readTxn"
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	var r txnRecord
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, errors.E(op, errors.Invalid, errors.Errorf("%s: %v", file, err))
+	}
+	tx := &txn{
+		key:     txnKey{user: r.User, id: r.ID},
+		changes: make([]upspin.DirChange, len(r.Changes)),
+		expires: r.Expires,
+	}
+	for i, c := range r.Changes {
+		var e upspin.DirEntry
+		if _, err := e.Unmarshal(c.Entry); err != nil {
+			return nil, errors.E(op, errors.Invalid, errors.Errorf("%s: %v", file, err))
+		}
+		tx.changes[i] = upspin.DirChange{Delete: c.Delete, Entry: &e}
+	}
+	tx.batch, err = newBatch(op, tx.changes)
+	if err != nil {
+		return nil, err
+	}
+	return tx, nil
+}
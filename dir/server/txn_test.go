@@ -0,0 +1,105 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"testing"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+func TestTransaction(t *testing.T) {
+	s, _ := newDirServerForTesting(t, userName)
+	var (
+		dir  = upspin.PathName(userName + "/txdir")
+		file = dir + "/file"
+	)
+	entry := func(name upspin.PathName, attr upspin.Attribute) *upspin.DirEntry {
+		return &upspin.DirEntry{
+			Name:       name,
+			SignedName: name,
+			Attr:       attr,
+			Writer:     userName,
+			Packing:    upspin.PlainPack,
+			Sequence:   upspin.SeqIgnore,
+		}
+	}
+	if _, err := s.Put(entry(dir, upspin.AttrDirectory)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.PreparePut("bad/id", []upspin.DirChange{{Entry: entry(file, upspin.AttrNone)}}); !errors.Is(errors.Invalid, err) {
+		t.Errorf("PreparePut with bad ID: err = %v, want Invalid", err)
+	}
+	err := s.PreparePut("tx1", []upspin.DirChange{{Delete: true, Entry: entry(dir+"/nonexistent", upspin.AttrNone)}})
+	if !errors.Is(errors.NotExist, err) {
+		t.Errorf("PreparePut of impossible change: err = %v, want NotExist", err)
+	}
+
+	changes := []upspin.DirChange{{Entry: entry(file, upspin.AttrNone)}}
+	if err := s.PreparePut("tx1", changes); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PreparePut("tx1", changes); !errors.Is(errors.Exist, err) {
+		t.Errorf("second PreparePut of tx1: err = %v, want Exist", err)
+	}
+	// The names the transaction changes are reserved.
+	if _, err := s.Put(entry(file, upspin.AttrNone)); !errors.Is(errors.Transient, err) {
+		t.Errorf("Put of reserved name: err = %v, want Transient", err)
+	}
+	if _, err := s.Delete(dir); !errors.Is(errors.Transient, err) {
+		t.Errorf("Delete of reserved directory: err = %v, want Transient", err)
+	}
+	if err := s.PreparePut("tx2", changes); !errors.Is(errors.Transient, err) {
+		t.Errorf("PreparePut of reserved name: err = %v, want Transient", err)
+	}
+	if _, err := s.Lookup(file); !errors.Is(errors.NotExist, err) {
+		t.Errorf("Lookup before commit: err = %v, want NotExist", err)
+	}
+
+	// The transaction survives a restart.
+	txns, err := openTxnTable(s.txns.dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := txns.txns[txnKey{user: userName, id: "tx1"}]; !ok {
+		t.Errorf("tx1 not reloaded")
+	}
+
+	entries, err := s.CommitTx("tx1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Sequence <= 0 {
+		t.Errorf("CommitTx returned %v, want one entry with a sequence number", entries)
+	}
+	if _, err := s.Lookup(file); err != nil {
+		t.Errorf("Lookup after commit: %v", err)
+	}
+	if _, err := s.CommitTx("tx1"); !errors.Is(errors.NotExist, err) {
+		t.Errorf("second CommitTx: err = %v, want NotExist", err)
+	}
+
+	// An aborted transaction changes nothing and releases its names.
+	if err := s.PreparePut("tx2", []upspin.DirChange{{Delete: true, Entry: entry(file, upspin.AttrNone)}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AbortTx("tx2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AbortTx("tx2"); err != nil {
+		t.Errorf("second AbortTx: %v", err)
+	}
+	if _, err := s.CommitTx("tx2"); !errors.Is(errors.NotExist, err) {
+		t.Errorf("CommitTx after AbortTx: err = %v, want NotExist", err)
+	}
+	if _, err := s.Delete(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Delete(dir); err != nil {
+		t.Fatal(err)
+	}
+}
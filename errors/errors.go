@@ -275,6 +275,12 @@ func (e *Error) Error() string {
 	return b.String()
 }
 
+// Unwrap returns the underlying error, if any, so the Is and As functions
+// of the standard errors package can examine it.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
 // Recreate the errors.New functionality of the standard Go errors package
 // so we can create simple text errors when needed.
 
@@ -7,6 +7,7 @@
 package errors
 
 import (
+	goerrors "errors"
 	"io"
 	"os"
 	"os/exec"
@@ -215,3 +216,21 @@ func TestIssue398(t *testing.T) {
 		t.Errorf("wrong path:  got %q; want %q", e.Path, "e@f.com/")
 	}
 }
+
+func TestUnwrap(t *testing.T) {
+	sentinel := Str("sentinel")
+	err := E(Op("outer"), E(Op("inner"), upspin.PathName("a@b.com/file"), Exist, sentinel))
+	if !goerrors.Is(err, sentinel) {
+		t.Errorf("Is(%q, sentinel) = false, want true", err)
+	}
+	if goerrors.Is(err, Str("sentinel")) {
+		t.Errorf("Is(%q, other error) = true, want false", err)
+	}
+	var e *Error
+	if !goerrors.As(E(Op("outer"), io.EOF), &e) || e.Op != "outer" {
+		t.Errorf("As did not find *Error")
+	}
+	if !goerrors.Is(E(Op("outer"), io.EOF), io.EOF) {
+		t.Errorf("Is(err, io.EOF) = false, want true")
+	}
+}
@@ -36,6 +36,7 @@ type keyHashArray [sha256.Size]byte
 type factotum struct {
 	current  keyHashArray
 	previous keyHashArray
+	older    []keyHashArray // Keys before previous, newest first.
 	keys     map[keyHashArray]factotumKey
 }
 
@@ -53,6 +54,21 @@ func KeyHash(p upspin.PublicKey) []byte {
 // AllUsersKeyHash is the hash of upspin.AllUsersKey.
 var AllUsersKeyHash = KeyHash(upspin.AllUsersKey)
 
+// RevokeHash returns the hash that must be signed to revoke a key.
+// See upspin.KeyServer.RevokeKey.
+func RevokeHash(p upspin.PublicKey) []byte {
+	h := sha256.Sum256([]byte("revoke:" + string(p)))
+	return h[:]
+}
+
+// TransferHash returns the hash that a domain's administrator must sign
+// to transfer the domain to the key server at dest.
+// See upspin.KeyDomainTransferrer.
+func TransferHash(domain string, dest upspin.NetAddr) []byte {
+	h := sha256.Sum256([]byte("transfer-domain:" + domain + "-" + string(dest)))
+	return h[:]
+}
+
 // NewFromDir returns a new Factotum providing all needed private key operations,
 // loading keys from a directory containing *.upspinkey files.
 // Our desired end state is that Factotum is implemented on each platform by the
@@ -110,6 +126,9 @@ func newFactotum(op errors.Op, public, private, archived []byte) (upspin.Factotu
 	// then old secret.upspinkey, and repeat. This should be cleaned up someday
 	// when we have a better idea of what other kinds of keys we need to save.
 	// For now, it is cavalier about bailing out at first little mistake.
+	// Keys are appended as they are rotated out, so the last one in the
+	// file is the most recent.
+	var prior []keyHashArray
 	lines := strings.Split(string(archived), "\n")
 	for {
 		if len(lines) < 5 {
@@ -129,6 +148,7 @@ func newFactotum(op errors.Op, public, private, archived []byte) (upspin.Factotu
 		}
 		pfk, err := makeKey(upspin.PublicKey(lines[1]+"\n"+lines[2]+"\n"+lines[3]+"\n"), lines[4]+"\n")
 		if err != nil {
+			f.setPrior(prior)
 			return f, errors.E(op, err)
 		}
 		lines = lines[5:]
@@ -139,11 +159,24 @@ func newFactotum(op errors.Op, public, private, archived []byte) (upspin.Factotu
 			continue
 		}
 		f.keys[h] = *pfk
-		f.previous = h
+		prior = append(prior, h)
 	}
+	f.setPrior(prior)
 	return f, nil
 }
 
+// setPrior records the archived keys, given oldest first, as the
+// previous and older keys of f.
+func (f *factotum) setPrior(prior []keyHashArray) {
+	if len(prior) == 0 {
+		return
+	}
+	f.previous = prior[len(prior)-1]
+	for i := len(prior) - 2; i >= 0; i-- {
+		f.older = append(f.older, prior[i])
+	}
+}
+
 // stripCR removes \r.
 func stripCR(b []byte) []byte {
 	return bytes.Replace(b, []byte("\r"), []byte(""), -1)
@@ -269,6 +302,30 @@ func (f factotum) Sign(hash []byte) (upspin.Signature, error) {
 	return upspin.Signature{R: r, S: s}, nil
 }
 
+// FormatSignature returns the text form of a signature: R and S in
+// hexadecimal, separated by a hyphen. This is the form used in domain TXT
+// records and key revocations.
+func FormatSignature(sig upspin.Signature) string {
+	return fmt.Sprintf("%x-%x", sig.R, sig.S)
+}
+
+// ParseSignature parses a signature in the form produced by FormatSignature.
+func ParseSignature(s string) (upspin.Signature, error) {
+	const op errors.Op = "factotum.ParseSignature"
+	fields := strings.Split(s, "-")
+	if len(fields) != 2 {
+		return sig0, errors.E(op, errors.Invalid, "expected two signature fields")
+	}
+	var rs, ss big.Int
+	if _, ok := rs.SetString(fields[0], 16); !ok {
+		return sig0, errors.E(op, errors.Invalid, "invalid signature field0")
+	}
+	if _, ok := ss.SetString(fields[1], 16); !ok {
+		return sig0, errors.E(op, errors.Invalid, "invalid signature field1")
+	}
+	return upspin.Signature{R: &rs, S: &ss}, nil
+}
+
 // Verify verifies whether the given hash's signature was signed by the private
 // key corresponding to the given public key.
 func Verify(hash []byte, sig upspin.Signature, key upspin.PublicKey) error {
@@ -296,10 +353,31 @@ func (f factotum) HKDF(salt, info, out []byte) error {
 }
 
 // Pop derives a Factotum by switching default from the current to the previous key.
+// Successive calls walk back through the archived keys, newest first.
 func (f factotum) Pop() upspin.Factotum {
-	// Arbitrarily keep f.previous unchanged, so Pop() is idempotent.
-	// We don't yet have any need to go further back in time.
-	return &factotum{current: f.previous, previous: f.previous, keys: f.keys}
+	if len(f.older) == 0 {
+		// Keep f.previous unchanged, so Pop() is idempotent
+		// once we reach the oldest key.
+		return &factotum{current: f.previous, previous: f.previous, keys: f.keys}
+	}
+	return &factotum{current: f.previous, previous: f.older[0], older: f.older[1:], keys: f.keys}
+}
+
+// Keys returns the public keys held by f: the current key followed by
+// any prior keys, newest first. The prior keys are found by repeated
+// calls to Pop, so Keys works for any Factotum implementation.
+func Keys(f upspin.Factotum) []upspin.PublicKey {
+	var keys []upspin.PublicKey
+	seen := make(map[upspin.PublicKey]bool)
+	for {
+		k := f.PublicKey()
+		if seen[k] {
+			return keys
+		}
+		seen[k] = true
+		keys = append(keys, k)
+		f = f.Pop()
+	}
 }
 
 // PublicKey returns the user's latest public key.
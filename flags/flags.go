@@ -28,12 +28,15 @@ type flagVar struct {
 
 const (
 	defaultBlockSize  = upspin.BlockSize
+	defaultBlockCache = int64(256 << 20)
 	maxBlockSize      = upspin.MaxBlockSize
 	defaultHTTPAddr   = ":80"
 	defaultHTTPSAddr  = ":443"
 	defaultLog        = "info"
+	defaultLogFormat  = "text"
 	defaultServerKind = "inprocess"
 	defaultCacheSize  = int64(5e9)
+	defaultPutConc    = 4
 )
 
 var (
@@ -58,13 +61,13 @@ var None = []string{}
 // Server is the set of flags most useful in servers. It can be passed as the
 // argument to Parse to set up the package for a server.
 var Server = []string{
-	"config", "log", "http", "https", "letscache", "tls", "addr", "insecure",
+	"config", "log", "logformat", "http", "https", "letscache", "tls", "addr", "insecure",
 }
 
 // Client is the set of flags most useful in clients. It can be passed as the
 // argument to Parse to set up the package for a client.
 var Client = []string{
-	"config", "log", "blocksize", "prudent",
+	"config", "log", "blocksize", "blockcache", "putconcurrency", "prudent",
 }
 
 // The Parse and Register functions bind these variables to their respective
@@ -74,6 +77,11 @@ var (
 	// The default is 1MB; it can be no larger than 1GB.
 	BlockSize = defaultBlockSize
 
+	// BlockCacheSize ("blockcache") is the most bytes of recently read
+	// blocks a client keeps in memory. Zero disables the cache, as does
+	// configuring a cacheserver, which caches blocks itself.
+	BlockCacheSize = defaultBlockCache
+
 	// CacheDir ("cachedir") specifies the directory for the various file
 	// caches.
 	CacheDir = defaultCacheDir
@@ -103,8 +111,13 @@ var (
 	LetsEncryptCache = defaultLetsEncryptCache
 
 	// Log ("log") sets the level of logging (implements flag.Value).
+	// It may also set the levels of subsystems; see log.SetLevels.
 	Log logFlag
 
+	// LogFormat ("logformat") sets the format of log messages, text or
+	// json (implements flag.Value).
+	LogFormat logFormatFlag
+
 	// NetAddr ("addr") is the publicly accessible network address of this
 	// server.
 	NetAddr = ""
@@ -116,6 +129,10 @@ var (
 	// ServerKind ("kind") is the implementation kind of this server.
 	ServerKind = defaultServerKind
 
+	// PutConcurrency ("putconcurrency") is the number of blocks a client
+	// stores in parallel when writing a file.
+	PutConcurrency = defaultPutConc
+
 	// Prudent ("prudent") sets an extra security mode in the client to
 	// check for malicious or buggy servers, at possible cost in
 	// performance or convenience. Specifically, one check is that the
@@ -123,6 +140,8 @@ var (
 	// currently with write permission. This protects against a forged
 	// directory entry at the cost of potentially blocking a legitimate
 	// file written by a user who no longer has write permission.
+	// Another is that data read from a store matches its reference,
+	// if that is a SHA-256 hash; see package upspin.io/client/clientutil.
 	Prudent = false
 
 	// TLSCertFile and TLSKeyFile ("tls") specify the location of a TLS
@@ -130,6 +149,10 @@ var (
 	TLSCertFile = ""
 	TLSKeyFile  = ""
 
+	// Timing ("timing") causes the program to print to standard error,
+	// on exit, a summary of the RPCs it made and the time they took.
+	Timing = false
+
 	// Version causes the program to print its release version and exit.
 	// The printed version is only meaningful in released binaries.
 	Version = false
@@ -151,6 +174,17 @@ var flags = map[string]*flagVar{
 			return fmt.Sprintf("-blocksize=%d", BlockSize)
 		},
 	},
+	"blockcache": &flagVar{
+		set: func(fs *flag.FlagSet) {
+			fs.Int64Var(&BlockCacheSize, "blockcache", defaultBlockCache, "maximum `bytes` of recently read blocks to keep in memory")
+		},
+		arg: func() string {
+			if BlockCacheSize == defaultBlockCache {
+				return ""
+			}
+			return fmt.Sprintf("-blockcache=%d", BlockCacheSize)
+		},
+	},
 	"cachedir": strVar(&CacheDir, "cachedir", CacheDir, "`directory` containing all file caches"),
 	"cachesize": &flagVar{
 		set: func(fs *flag.FlagSet) {
@@ -182,16 +216,34 @@ var flags = map[string]*flagVar{
 	"log": &flagVar{
 		set: func(fs *flag.FlagSet) {
 			Log.Set("info")
-			fs.Var(&Log, "log", "`level` of logging: debug, info, error, disabled")
+			fs.Var(&Log, "log", "`level` of logging: debug, info, error, disabled; may be followed by subsystem=level pairs, as in info,dir/server=debug")
 		},
 		arg: func() string { return strArg("log", Log.String(), defaultLog) },
 	},
+	"logformat": &flagVar{
+		set: func(fs *flag.FlagSet) {
+			LogFormat.Set(defaultLogFormat)
+			fs.Var(&LogFormat, "logformat", "`format` of log messages: text, json")
+		},
+		arg: func() string { return strArg("logformat", LogFormat.String(), defaultLogFormat) },
+	},
 	"serverconfig": &flagVar{
 		set: func(fs *flag.FlagSet) {
 			fs.Var(configFlag{&ServerConfig}, "serverconfig", "comma-separated list of configuration options (key=value) for this server")
 		},
 		arg: func() string { return strArg("serverconfig", configFlag{&ServerConfig}.String(), "") },
 	},
+	"putconcurrency": &flagVar{
+		set: func(fs *flag.FlagSet) {
+			fs.IntVar(&PutConcurrency, "putconcurrency", defaultPutConc, "`number` of blocks to store in parallel when writing a file")
+		},
+		arg: func() string {
+			if PutConcurrency == defaultPutConc {
+				return ""
+			}
+			return fmt.Sprintf("-putconcurrency=%d", PutConcurrency)
+		},
+	},
 	"prudent": &flagVar{
 		set: func(fs *flag.FlagSet) {
 			fs.BoolVar(&Prudent, "prudent", false, "protect against malicious directory server")
@@ -203,6 +255,17 @@ var flags = map[string]*flagVar{
 			return "-prudent"
 		},
 	},
+	"timing": &flagVar{
+		set: func(fs *flag.FlagSet) {
+			fs.BoolVar(&Timing, "timing", false, "print a summary of RPC times to standard error on exit")
+		},
+		arg: func() string {
+			if !Timing {
+				return ""
+			}
+			return "-timing"
+		},
+	},
 	"tls": &flagVar{
 		set: func(fs *flag.FlagSet) {
 			fs.StringVar(&TLSCertFile, "tls_cert", "", "TLS Certificate `file` in PEM format")
@@ -375,18 +438,40 @@ func (f logFlag) String() string {
 }
 
 // Set implements flag.Value.
-func (f *logFlag) Set(level string) error {
-	err := log.SetLevel(level)
+func (f *logFlag) Set(levels string) error {
+	err := log.SetLevels(levels)
 	if err != nil {
 		return err
 	}
-	*f = logFlag(log.GetLevel())
+	*f = logFlag(log.GetLevels())
 	return nil
 }
 
 // Get implements flag.Getter.
 func (logFlag) Get() interface{} {
-	return log.GetLevel()
+	return log.GetLevels()
+}
+
+type logFormatFlag string
+
+// String implements flag.Value.
+func (f logFormatFlag) String() string {
+	return string(f)
+}
+
+// Set implements flag.Value.
+func (f *logFormatFlag) Set(format string) error {
+	err := log.SetFormat(format)
+	if err != nil {
+		return err
+	}
+	*f = logFormatFlag(log.GetFormat())
+	return nil
+}
+
+// Get implements flag.Getter.
+func (logFormatFlag) Get() interface{} {
+	return log.GetFormat()
 }
 
 type configFlag struct {
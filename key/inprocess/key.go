@@ -7,8 +7,10 @@ package inprocess // import "upspin.io/key/inprocess"
 
 import (
 	"sync"
+	"time"
 
 	"upspin.io/errors"
+	"upspin.io/factotum"
 	"upspin.io/upspin"
 	"upspin.io/user"
 	"upspin.io/valid"
@@ -16,7 +18,8 @@ import (
 
 func New() upspin.KeyServer {
 	return &server{db: &database{
-		users: make(map[upspin.UserName]*upspin.User),
+		users:    make(map[upspin.UserName]*upspin.User),
+		versions: make(map[upspin.UserName][]upspin.UserVersion),
 	}}
 }
 
@@ -27,7 +30,11 @@ type server struct {
 	db *database
 }
 
-var _ upspin.KeyServer = (*server)(nil)
+var (
+	_ upspin.KeyServer    = (*server)(nil)
+	_ upspin.KeyRevoker   = (*server)(nil)
+	_ upspin.KeyHistorian = (*server)(nil)
+)
 
 // A database holds the information for the known users.
 // There is one instance, created in init, shared by all server objects.
@@ -35,6 +42,31 @@ type database struct {
 	// mu protects the fields below.
 	mu    sync.RWMutex
 	users map[upspin.UserName]*upspin.User
+	// versions holds every version of each user's record, oldest first.
+	versions map[upspin.UserName][]upspin.UserVersion
+}
+
+// store makes u the current record for its user, keeping the previous
+// one in the user's history. Called with mu locked.
+func (db *database) store(u *upspin.User) {
+	now := time.Now()
+	db.users[u.Name] = u
+	v := db.versions[u.Name]
+	if len(v) > 0 {
+		v[len(v)-1].ValidUntil = now
+	}
+	db.versions[u.Name] = append(v, upspin.UserVersion{User: *dup(u), ValidFrom: now})
+}
+
+// hadKey reports whether key is or was a public key of the named user.
+// Called with mu locked.
+func (db *database) hadKey(name upspin.UserName, key upspin.PublicKey) bool {
+	for _, v := range db.versions[name] {
+		if v.User.PublicKey == key {
+			return true
+		}
+	}
+	return false
 }
 
 // Lookup reports the set of locations the user's directory might be,
@@ -64,6 +96,7 @@ func dup(u *upspin.User) *upspin.User {
 	copy(v.Dirs, u.Dirs)
 	v.Stores = make([]upspin.Endpoint, len(u.Stores))
 	copy(v.Stores, u.Stores)
+	v.Revoked = append([]upspin.PublicKey(nil), u.Revoked...)
 	return &v
 }
 
@@ -83,10 +116,98 @@ func (s *server) Put(u *upspin.User) error {
 
 	s.db.mu.Lock()
 	defer s.db.mu.Unlock()
-	s.db.users[u.Name] = dup(u)
+	var revoked []upspin.PublicKey
+	if old, ok := s.db.users[u.Name]; ok {
+		revoked = old.Revoked
+	}
+	for _, k := range revoked {
+		if k == u.PublicKey {
+			return errors.E(op, errors.Permission, u.Name, "public key has been revoked")
+		}
+	}
+	nu := dup(u)
+	nu.Revoked = revoked
+	s.db.store(nu)
+	return nil
+}
+
+// RevokeKey implements upspin.KeyRevoker.
+// The in-process server has no notion of an authenticated caller,
+// so it accepts only revocations signed by the key being revoked,
+// which must be the user's current or an earlier key.
+func (s *server) RevokeKey(name upspin.UserName, key upspin.PublicKey, revokeSig []byte) error {
+	const op errors.Op = "key/inprocess.RevokeKey"
+	if err := valid.UserName(name); err != nil {
+		return errors.E(op, err)
+	}
+	sig, err := factotum.ParseSignature(string(revokeSig))
+	if err != nil {
+		return errors.E(op, name, err)
+	}
+	if err := factotum.Verify(factotum.RevokeHash(key), sig, key); err != nil {
+		return errors.E(op, errors.Permission, name, err)
+	}
+
+	s.db.mu.Lock()
+	defer s.db.mu.Unlock()
+	u, ok := s.db.users[name]
+	if !ok {
+		return errors.E(op, name, errors.NotExist)
+	}
+	if !s.db.hadKey(name, key) {
+		return errors.E(op, errors.Permission, name, "key does not belong to user")
+	}
+	for _, k := range u.Revoked {
+		if k == key {
+			return nil
+		}
+	}
+	nu := dup(u)
+	nu.Revoked = append(nu.Revoked, key)
+	s.db.store(nu)
 	return nil
 }
 
+// LookupAt implements upspin.KeyHistorian.
+func (s *server) LookupAt(name upspin.UserName, t time.Time) (*upspin.User, error) {
+	const op errors.Op = "key/inprocess.LookupAt"
+	if err := valid.UserName(name); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	for _, v := range s.db.versions[name] {
+		if !t.Before(v.ValidFrom) && (v.ValidUntil.IsZero() || t.Before(v.ValidUntil)) {
+			return dup(&v.User), nil
+		}
+	}
+	return nil, errors.E(op, name, errors.NotExist)
+}
+
+// History implements upspin.KeyHistorian.
+// The in-process server has no notion of an authenticated caller,
+// so it gives the history to anyone.
+func (s *server) History(name upspin.UserName) ([]upspin.UserVersion, error) {
+	const op errors.Op = "key/inprocess.History"
+	if err := valid.UserName(name); err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	s.db.mu.RLock()
+	defer s.db.mu.RUnlock()
+	v, ok := s.db.versions[name]
+	if !ok {
+		return nil, errors.E(op, name, errors.NotExist)
+	}
+	h := make([]upspin.UserVersion, len(v))
+	for i := range v {
+		h[i] = v[i]
+		h[i].User = *dup(&v[i].User)
+	}
+	return h, nil
+}
+
 // Endpoint implements upspin.server.
 func (s *server) Endpoint() upspin.Endpoint {
 	return upspin.Endpoint{
@@ -7,7 +7,11 @@ package inprocess
 import (
 	"reflect"
 	"testing"
+	"time"
 
+	"upspin.io/errors"
+	"upspin.io/factotum"
+	"upspin.io/test/testutil"
 	"upspin.io/upspin"
 
 	_ "upspin.io/dir/inprocess"
@@ -44,3 +48,101 @@ func TestInstallAndLookup(t *testing.T) {
 		t.Errorf("Lookup: incorrect data returned: got %v; want %v", got, &testUser)
 	}
 }
+
+func TestRevokeKey(t *testing.T) {
+	f, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "joe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := New()
+	user := testUser
+	user.PublicKey = f.PublicKey()
+	if err := key.Put(&user); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only a signature by the key itself is accepted.
+	sig, err := f.Sign(factotum.RevokeHash("some other key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = key.(upspin.KeyRevoker).RevokeKey(user.Name, f.PublicKey(), []byte(factotum.FormatSignature(sig)))
+	if !errors.Is(errors.Permission, err) {
+		t.Fatalf("RevokeKey with bad signature: err = %v, want Permission", err)
+	}
+
+	// Nor is a self-signed revocation of a key the user never had.
+	other, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig, err = other.Sign(factotum.RevokeHash(other.PublicKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = key.(upspin.KeyRevoker).RevokeKey(user.Name, other.PublicKey(), []byte(factotum.FormatSignature(sig)))
+	if !errors.Is(errors.Permission, err) {
+		t.Fatalf("RevokeKey of another's key: err = %v, want Permission", err)
+	}
+
+	sig, err = f.Sign(factotum.RevokeHash(f.PublicKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = key.(upspin.KeyRevoker).RevokeKey(user.Name, f.PublicKey(), []byte(factotum.FormatSignature(sig)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := key.Lookup(user.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []upspin.PublicKey{f.PublicKey()}; !reflect.DeepEqual(got.Revoked, want) {
+		t.Errorf("Revoked = %v, want %v", got.Revoked, want)
+	}
+	if err := key.Put(&user); !errors.Is(errors.Permission, err) {
+		t.Errorf("Put of revoked key: err = %v, want Permission", err)
+	}
+}
+
+func TestLookupAt(t *testing.T) {
+	key := New()
+	historian := key.(upspin.KeyHistorian)
+	before := time.Now()
+	user := testUser
+	if err := key.Put(&user); err != nil {
+		t.Fatal(err)
+	}
+	t1 := time.Now()
+	user.PublicKey = "this is a new key"
+	if err := key.Put(&user); err != nil {
+		t.Fatal(err)
+	}
+	t2 := time.Now()
+
+	if _, err := historian.LookupAt(user.Name, before); !errors.Is(errors.NotExist, err) {
+		t.Errorf("LookupAt before first Put: err = %v, want NotExist", err)
+	}
+	got, err := historian.LookupAt(user.Name, t1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, &testUser) {
+		t.Errorf("LookupAt(t1) = %v, want %v", got, &testUser)
+	}
+	got, err = historian.LookupAt(user.Name, t2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, &user) {
+		t.Errorf("LookupAt(t2) = %v, want %v", got, &user)
+	}
+
+	h, err := historian.History(user.Name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h) != 2 || h[0].User.PublicKey != testUser.PublicKey || h[1].User.PublicKey != user.PublicKey {
+		t.Errorf("History = %v, want the two versions", h)
+	}
+}
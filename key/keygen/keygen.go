@@ -7,11 +7,14 @@
 package keygen // import "upspin.io/key/keygen"
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"upspin.io/errors"
 	"upspin.io/key/proquint"
@@ -67,6 +70,50 @@ func FromSecret(curveName, secret string) (public, private, secretStr string, er
 	return string(pub), priv, secretStr, nil
 }
 
+// SeedChecksum returns a proquint word derived from the seed's SHA-256
+// hash. Printed alongside the seed, it lets VerifySeed detect a seed
+// that was mis-transcribed into another valid seed.
+func SeedChecksum(seed string) (string, error) {
+	const op errors.Op = "keygen.SeedChecksum"
+	if !ValidSecretSeed(seed) {
+		return "", errors.E(op, errors.Invalid, errors.Errorf("invalid secret seed %q", seed))
+	}
+	b := secretFromProquint(seed)
+	sum := sha256.Sum256(b[:])
+	return string(proquint.Encode(binary.BigEndian.Uint16(sum[:2]))), nil
+}
+
+// VerifySeed checks that the seed regenerates the public key, whose curve
+// is determined from the key itself. If checksum is not empty it must
+// match the seed's SeedChecksum. Nothing is written.
+func VerifySeed(seed, checksum, public string) error {
+	const op errors.Op = "keygen.VerifySeed"
+	if !ValidSecretSeed(seed) {
+		return errors.E(op, errors.Invalid, errors.Errorf("%q is not a valid secret seed; check for transcription errors", seed))
+	}
+	if checksum != "" {
+		sum, err := SeedChecksum(seed)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if sum != checksum {
+			return errors.E(op, errors.Invalid, errors.Errorf("checksum %q does not match seed; check for transcription errors", checksum))
+		}
+	}
+	curve := public
+	if i := strings.IndexByte(public, '\n'); i >= 0 {
+		curve = public[:i]
+	}
+	pub, _, _, err := FromSecret(curve, seed)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if strings.TrimSpace(pub) != strings.TrimSpace(public) {
+		return errors.E(op, errors.Invalid, "seed does not regenerate the public key")
+	}
+	return nil
+}
+
 // ValidSecretSeed reports whether a seed conforms to the proquint format.
 func ValidSecretSeed(seed string) bool {
 	if len(seed) != 47 {
@@ -169,7 +216,7 @@ func SaveKeys(where string, rotate bool, newPublic, newPrivate, secretStr string
 	if err != nil {
 		modtime = ""
 	} else {
-		modtime = info.ModTime().UTC().Format(" 2006-01-02 15:04:05Z")
+		modtime = " " + info.ModTime().UTC().Format(archiveTimeFormat)
 	}
 	_, err = fmt.Fprintf(archive, "# EE%s\n%s%s", modtime, public, private)
 	if err != nil {
@@ -183,3 +230,91 @@ func SaveKeys(where string, rotate bool, newPublic, newPrivate, secretStr string
 	// Write the new keys.
 	return writeKeys(where, newPublic, newPrivate, secretStr)
 }
+
+// archiveTimeFormat is the format of the time recorded in the "# EE" line
+// that introduces each key pair in secret2.upspinkey.
+const archiveTimeFormat = "2006-01-02 15:04:05Z"
+
+// ArchivedKey describes a key pair saved in secret2.upspinkey.
+type ArchivedKey struct {
+	// Time is the time recorded when the key was archived.
+	// It is the zero Time if the record has no time.
+	Time time.Time
+
+	// Public is the archived public key.
+	Public string
+
+	// text is the complete record, including the "# EE" line.
+	text string
+}
+
+// ReadArchive returns the key pairs in the secret2.upspinkey file in the
+// given directory, in the order they appear in the file (oldest first).
+// It returns an empty slice if there is no archive file.
+func ReadArchive(where string) ([]ArchivedKey, error) {
+	const op errors.Op = "keygen.ReadArchive"
+	data, err := os.ReadFile(filepath.Join(where, "secret2.upspinkey"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	lines := strings.SplitAfter(string(data), "\n")
+	var keys []ArchivedKey
+	// Each record is the "# EE" line, then the three lines of the public
+	// key, then the private key. See factotum.NewFromKeys.
+	for len(lines) >= 5 {
+		if !strings.HasPrefix(lines[0], "# EE") {
+			return nil, errors.E(op, errors.Invalid, errors.Errorf("unexpected line in archive: %q", lines[0]))
+		}
+		k := ArchivedKey{
+			Public: strings.Join(lines[1:4], ""),
+			text:   strings.Join(lines[:5], ""),
+		}
+		if date := strings.TrimSpace(strings.TrimPrefix(lines[0], "# EE")); date != "" {
+			k.Time, err = time.Parse(archiveTimeFormat, date)
+			if err != nil {
+				return nil, errors.E(op, errors.Invalid, err)
+			}
+		}
+		keys = append(keys, k)
+		lines = lines[5:]
+	}
+	return keys, nil
+}
+
+// PruneKeys removes from the secret2.upspinkey file in the given directory
+// all key pairs archived before the given time, and returns them.
+// Key pairs with no recorded time are kept.
+func PruneKeys(where string, before time.Time) ([]ArchivedKey, error) {
+	const op errors.Op = "keygen.PruneKeys"
+	keys, err := ReadArchive(where)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	var kept bytes.Buffer
+	var pruned []ArchivedKey
+	for _, k := range keys {
+		if !k.Time.IsZero() && k.Time.Before(before) {
+			pruned = append(pruned, k)
+			continue
+		}
+		kept.WriteString(k.text)
+	}
+	if len(pruned) == 0 {
+		return nil, nil
+	}
+	// Write the surviving keys to a temporary file and rename it
+	// so a failure cannot leave a truncated archive.
+	archiveFile := filepath.Join(where, "secret2.upspinkey")
+	tmp := archiveFile + ".tmp"
+	if err := os.WriteFile(tmp, kept.Bytes(), 0600); err != nil {
+		return nil, errors.E(op, errors.IO, err)
+	}
+	if err := os.Rename(tmp, archiveFile); err != nil {
+		os.Remove(tmp)
+		return nil, errors.E(op, errors.IO, err)
+	}
+	return pruned, nil
+}
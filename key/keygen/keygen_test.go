@@ -5,8 +5,11 @@
 package keygen
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 var keygenTestCases = []struct {
@@ -98,3 +101,120 @@ func TestValidSecretSeed(t *testing.T) {
 		}
 	}
 }
+
+func TestPruneKeys(t *testing.T) {
+	const archive = `# EE 2017-01-02 03:04:05Z
+p256
+1
+2
+3 # first
+# EE
+p256
+4
+5
+6 # undated
+# EE 2018-01-02 03:04:05Z
+p256
+7
+8
+9 # second
+# EE 2019-01-02 03:04:05Z
+p256
+10
+11
+12 # third
+`
+	dir := t.TempDir()
+	file := filepath.Join(dir, "secret2.upspinkey")
+	if err := os.WriteFile(file, []byte(archive), 0600); err != nil {
+		t.Fatal(err)
+	}
+	keys, err := ReadArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 4 {
+		t.Fatalf("ReadArchive: got %d keys, want 4", len(keys))
+	}
+	if !keys[1].Time.IsZero() {
+		t.Errorf("undated key has time %v", keys[1].Time)
+	}
+
+	pruned, err := PruneKeys(dir, time.Date(2018, 6, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pruned) != 2 {
+		t.Fatalf("PruneKeys: pruned %d keys, want 2", len(pruned))
+	}
+	if got, want := pruned[0].Public, "p256\n1\n2\n"; got != want {
+		t.Errorf("pruned[0] = %q, want %q", got, want)
+	}
+	if got, want := pruned[1].Public, "p256\n7\n8\n"; got != want {
+		t.Errorf("pruned[1] = %q, want %q", got, want)
+	}
+	keys, err = ReadArchive(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 2 {
+		t.Fatalf("after prune: got %d keys, want 2", len(keys))
+	}
+	if got, want := keys[0].Public, "p256\n4\n5\n"; got != want {
+		t.Errorf("keys[0] = %q, want %q", got, want)
+	}
+	if got, want := keys[1].Public, "p256\n10\n11\n"; got != want {
+		t.Errorf("keys[1] = %q, want %q", got, want)
+	}
+}
+
+func TestVerifySeed(t *testing.T) {
+	for _, curve := range []string{"p256", "p384", "p521"} {
+		public, _, seed, err := Generate(curve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum, err := SeedChecksum(seed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifySeed(seed, sum, public); err != nil {
+			t.Errorf("%s: VerifySeed: %v", curve, err)
+		}
+		if err := VerifySeed(seed, "", public); err != nil {
+			t.Errorf("%s: VerifySeed without checksum: %v", curve, err)
+		}
+		other, _, _, err := Generate(curve)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := VerifySeed(seed, sum, other); err == nil {
+			t.Errorf("%s: VerifySeed with wrong public key succeeded", curve)
+		}
+	}
+}
+
+func TestSeedChecksumDetectsTypo(t *testing.T) {
+	const seed = "latoj-katuf-kijuh-latuh.lanon-kunol-kinoz-lanuj"
+	sum, err := SeedChecksum(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every single-character substitution must be caught, either because
+	// the result is not a valid seed or because the checksum differs.
+	const letters = "bdfghjklmnprstvzaiou"
+	for i := range seed {
+		for _, c := range letters {
+			if byte(c) == seed[i] {
+				continue
+			}
+			typo := seed[:i] + string(c) + seed[i+1:]
+			if !ValidSecretSeed(typo) {
+				continue
+			}
+			if s, _ := SeedChecksum(typo); s == sum {
+				t.Errorf("checksum of %q matches that of %q", typo, seed)
+			}
+		}
+	}
+}
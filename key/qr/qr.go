@@ -0,0 +1,438 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package qr encodes short text, such as a key's secret seed, as a QR code.
+//
+// Only what is needed for seeds is implemented: byte mode, error
+// correction level L, and versions 1 through 5, which hold up to
+// 106 bytes.
+package qr // import "upspin.io/key/qr"
+
+import (
+	"image"
+	"image/color"
+	"strings"
+
+	"upspin.io/errors"
+)
+
+// quietZone is the width in modules of the blank border around a code.
+const quietZone = 4
+
+// A Code is a QR code.
+type Code struct {
+	// Size is the width and height of the code in modules,
+	// not counting the quiet zone.
+	Size int
+
+	dark     [][]bool
+	function [][]bool // Finder, timing, alignment and format modules.
+}
+
+// Black reports whether the module at column x, row y is dark.
+// Coordinates outside the code are light.
+func (c *Code) Black(x, y int) bool {
+	if x < 0 || y < 0 || x >= c.Size || y >= c.Size {
+		return false
+	}
+	return c.dark[y][x]
+}
+
+// Text returns the code drawn with Unicode full blocks for dark modules,
+// two characters per module, surrounded by a quiet zone. It is meant
+// to be shown on a terminal with a light background.
+func (c *Code) Text() string {
+	var b strings.Builder
+	for y := -quietZone; y < c.Size+quietZone; y++ {
+		for x := -quietZone; x < c.Size+quietZone; x++ {
+			if c.Black(x, y) {
+				b.WriteString("██")
+			} else {
+				b.WriteString("  ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Image returns the code as an image with each module drawn
+// as a scale by scale square, surrounded by a quiet zone.
+func (c *Code) Image(scale int) image.Image {
+	n := (c.Size + 2*quietZone) * scale
+	img := image.NewGray(image.Rect(0, 0, n, n))
+	for py := 0; py < n; py++ {
+		for px := 0; px < n; px++ {
+			v := color.Gray{Y: 0xFF}
+			if c.Black(px/scale-quietZone, py/scale-quietZone) {
+				v.Y = 0
+			}
+			img.SetGray(px, py, v)
+		}
+	}
+	return img
+}
+
+// Codeword counts for error correction level L, indexed by version.
+// All of versions 1 through 5 at level L use a single block.
+var (
+	dataCodewords = [...]int{0, 19, 34, 55, 80, 108}
+	eccCodewords  = [...]int{0, 7, 10, 15, 20, 26}
+)
+
+// Encode returns a QR code holding the text, using the smallest
+// version that fits.
+func Encode(text string) (*Code, error) {
+	const op errors.Op = "qr.Encode"
+	version := 0
+	for v := 1; v < len(dataCodewords); v++ {
+		// Mode indicator and character count take two bytes.
+		if len(text)+2 <= dataCodewords[v] {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, errors.E(op, errors.Invalid, errors.Errorf("text too long: %d bytes", len(text)))
+	}
+
+	data := encodeData(text, dataCodewords[version])
+	data = append(data, reedSolomon(data, eccCodewords[version])...)
+
+	c := newCode(version)
+	c.drawFunctionPatterns(version)
+	c.drawCodewords(data)
+
+	// Choose the mask with the lowest penalty, as the standard requires.
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		c.applyMask(mask)
+		c.drawFormat(mask)
+		if p := c.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		c.applyMask(mask) // Masking is its own inverse.
+	}
+	c.applyMask(best)
+	c.drawFormat(best)
+	return c, nil
+}
+
+// encodeData returns the byte mode bit stream for the text,
+// padded to n codewords.
+func encodeData(text string, n int) []byte {
+	var bits []bool
+	put := func(v, nbits int) {
+		for i := nbits - 1; i >= 0; i-- {
+			bits = append(bits, v>>uint(i)&1 == 1)
+		}
+	}
+	put(0x4, 4) // Byte mode.
+	put(len(text), 8)
+	for i := 0; i < len(text); i++ {
+		put(int(text[i]), 8)
+	}
+	// Terminator of up to four zero bits, then pad to a byte boundary.
+	for i := 0; i < 4 && len(bits) < n*8; i++ {
+		bits = append(bits, false)
+	}
+	for len(bits)%8 != 0 {
+		bits = append(bits, false)
+	}
+	out := make([]byte, 0, n)
+	for i := 0; i < len(bits); i += 8 {
+		var b byte
+		for j := 0; j < 8; j++ {
+			if bits[i+j] {
+				b |= 1 << uint(7-j)
+			}
+		}
+		out = append(out, b)
+	}
+	for pad := byte(0xEC); len(out) < n; pad ^= 0xEC ^ 0x11 {
+		out = append(out, pad)
+	}
+	return out
+}
+
+// gfMul multiplies in GF(2^8) modulo the QR polynomial x^8+x^4+x^3+x^2+1.
+func gfMul(x, y byte) byte {
+	var z byte
+	for i := 7; i >= 0; i-- {
+		hi := z & 0x80
+		z <<= 1
+		if hi != 0 {
+			z ^= 0x1D
+		}
+		if y>>uint(i)&1 == 1 {
+			z ^= x
+		}
+	}
+	return z
+}
+
+// reedSolomon returns the n error correction codewords for data.
+func reedSolomon(data []byte, n int) []byte {
+	// Generator polynomial (x - a^0)(x - a^1)...(x - a^(n-1)),
+	// coefficients from highest to lowest power, leading 1 omitted.
+	gen := make([]byte, n)
+	gen[n-1] = 1
+	root := byte(1)
+	for i := 0; i < n; i++ {
+		for j := 0; j < n; j++ {
+			gen[j] = gfMul(gen[j], root)
+			if j+1 < n {
+				gen[j] ^= gen[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	rem := make([]byte, n)
+	for _, b := range data {
+		factor := b ^ rem[0]
+		copy(rem, rem[1:])
+		rem[n-1] = 0
+		for i := range rem {
+			rem[i] ^= gfMul(gen[i], factor)
+		}
+	}
+	return rem
+}
+
+func newCode(version int) *Code {
+	size := 4*version + 17
+	c := &Code{
+		Size:     size,
+		dark:     make([][]bool, size),
+		function: make([][]bool, size),
+	}
+	for i := range c.dark {
+		c.dark[i] = make([]bool, size)
+		c.function[i] = make([]bool, size)
+	}
+	return c
+}
+
+func (c *Code) setFunction(x, y int, dark bool) {
+	c.dark[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns(version int) {
+	for i := 0; i < c.Size; i++ {
+		c.setFunction(6, i, i%2 == 0)
+		c.setFunction(i, 6, i%2 == 0)
+	}
+	c.drawFinder(3, 3)
+	c.drawFinder(c.Size-4, 3)
+	c.drawFinder(3, c.Size-4)
+	if version > 1 {
+		// Versions 2 through 6 have a single alignment pattern.
+		p := 4*version + 10
+		for dy := -2; dy <= 2; dy++ {
+			for dx := -2; dx <= 2; dx++ {
+				c.setFunction(p+dx, p+dy, max(abs(dx), abs(dy)) != 1)
+			}
+		}
+	}
+	// Reserve the format areas; drawFormat fills them in.
+	c.drawFormat(0)
+}
+
+// drawFinder draws a finder pattern and its separator centered at x, y.
+func (c *Code) drawFinder(x, y int) {
+	for dy := -4; dy <= 4; dy++ {
+		for dx := -4; dx <= 4; dx++ {
+			xx, yy := x+dx, y+dy
+			if xx < 0 || yy < 0 || xx >= c.Size || yy >= c.Size {
+				continue
+			}
+			d := max(abs(dx), abs(dy))
+			c.setFunction(xx, yy, d != 2 && d != 4)
+		}
+	}
+}
+
+// drawFormat draws both copies of the format information for
+// error correction level L and the given mask.
+func (c *Code) drawFormat(mask int) {
+	bits := formatBits(mask)
+	bit := func(i int) bool { return bits>>uint(i)&1 == 1 }
+
+	for i := 0; i <= 5; i++ {
+		c.setFunction(8, i, bit(i))
+	}
+	c.setFunction(8, 7, bit(6))
+	c.setFunction(8, 8, bit(7))
+	c.setFunction(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.setFunction(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		c.setFunction(c.Size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.setFunction(8, c.Size-15+i, bit(i))
+	}
+	c.setFunction(8, c.Size-8, true) // The dark module.
+}
+
+// formatBits returns the 15-bit format information, a BCH code of the
+// error correction level L and the mask, XORed with the standard pattern.
+func formatBits(mask int) int {
+	const levelL = 1
+	data := levelL<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	return (data<<10 | rem) ^ 0x5412
+}
+
+// drawCodewords places the codewords in the zigzag order of the standard.
+func (c *Code) drawCodewords(data []byte) {
+	i := 0
+	for right := c.Size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // Skip the vertical timing pattern.
+		}
+		upward := (right+1)&2 == 0
+		for vert := 0; vert < c.Size; vert++ {
+			y := vert
+			if upward {
+				y = c.Size - 1 - vert
+			}
+			for j := 0; j < 2; j++ {
+				x := right - j
+				if c.function[y][x] || i >= len(data)*8 {
+					continue
+				}
+				c.dark[y][x] = data[i/8]>>uint(7-i%8)&1 == 1
+				i++
+			}
+		}
+	}
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.Size; y++ {
+		for x := 0; x < c.Size; x++ {
+			if c.function[y][x] {
+				continue
+			}
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert {
+				c.dark[y][x] = !c.dark[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores the code by the four rules of the standard;
+// lower is better.
+func (c *Code) penalty() int {
+	n := c.Size
+	p := 0
+	// Rule 1: runs of five or more modules of the same color,
+	// and rule 3: patterns resembling a finder.
+	finder := []bool{true, false, true, true, true, false, true}
+	for _, transpose := range []bool{false, true} {
+		at := func(i, j int) bool {
+			if transpose {
+				return c.dark[j][i]
+			}
+			return c.dark[i][j]
+		}
+		for i := 0; i < n; i++ {
+			run := 1
+			for j := 1; j <= n; j++ {
+				if j < n && at(i, j) == at(i, j-1) {
+					run++
+					continue
+				}
+				if run >= 5 {
+					p += run - 2
+				}
+				run = 1
+			}
+			for j := 0; j+7 <= n; j++ {
+				match := true
+				for k, f := range finder {
+					if at(i, j+k) != f {
+						match = false
+						break
+					}
+				}
+				if !match {
+					continue
+				}
+				lightBefore, lightAfter := true, true
+				for k := 1; k <= 4; k++ {
+					if j-k >= 0 && at(i, j-k) {
+						lightBefore = false
+					}
+					if j+6+k < n && at(i, j+6+k) {
+						lightAfter = false
+					}
+				}
+				if lightBefore || lightAfter {
+					p += 40
+				}
+			}
+		}
+	}
+	// Rule 2: 2x2 blocks of the same color.
+	for y := 0; y+1 < n; y++ {
+		for x := 0; x+1 < n; x++ {
+			d := c.dark[y][x]
+			if d == c.dark[y][x+1] && d == c.dark[y+1][x] && d == c.dark[y+1][x+1] {
+				p += 3
+			}
+		}
+	}
+	// Rule 4: balance of dark and light modules.
+	dark := 0
+	for y := 0; y < n; y++ {
+		for x := 0; x < n; x++ {
+			if c.dark[y][x] {
+				dark++
+			}
+		}
+	}
+	k := abs(dark*20-n*n*10) / (n * n)
+	p += k * 10
+	return p
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
+
+func max(x, y int) int {
+	if x > y {
+		return x
+	}
+	return y
+}
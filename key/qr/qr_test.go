@@ -0,0 +1,123 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package qr
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+func TestReedSolomon(t *testing.T) {
+	// The 1-M "HELLO WORLD" example from the standard.
+	data := []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17}
+	want := []byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}
+	if got := reedSolomon(data, len(want)); !bytes.Equal(got, want) {
+		t.Errorf("reedSolomon = %v, want %v", got, want)
+	}
+}
+
+func TestFormatBits(t *testing.T) {
+	// Format information for level L from the standard.
+	want := []int{
+		0x77C4, // 111011111000100
+		0x72F3, // 111001011110011
+		0x7DAA, // 111110110101010
+		0x789D, // 111100010011101
+		0x662F, // 110011000101111
+		0x6318, // 110001100011000
+		0x6C41, // 110110001000001
+		0x6976, // 110100101110110
+	}
+	for mask, w := range want {
+		if got := formatBits(mask); got != w {
+			t.Errorf("formatBits(%d) = %015b, want %015b", mask, got, w)
+		}
+	}
+}
+
+func TestEncodeData(t *testing.T) {
+	got := encodeData("hi", 19)
+	want := []byte{0x40, 0x26, 0x86, 0x90, 0xEC, 0x11, 0xEC}
+	if !bytes.Equal(got[:len(want)], want) || len(got) != 19 {
+		t.Errorf("encodeData = %x, want prefix %x and length 19", got, want)
+	}
+}
+
+func TestEncode(t *testing.T) {
+	seed := "lusab-babad-gutih-tugad.gutuk-bisog-mudof-sakat"
+	c, err := Encode(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Size != 29 {
+		t.Errorf("size = %d, want 29 (version 3)", c.Size)
+	}
+	// The corners hold finder patterns: a dark ring around a light one.
+	for _, p := range [][2]int{{0, 0}, {c.Size - 7, 0}, {0, c.Size - 7}} {
+		if !c.Black(p[0], p[1]) || c.Black(p[0]+1, p[1]+1) || !c.Black(p[0]+3, p[1]+3) {
+			t.Errorf("no finder pattern at %v", p)
+		}
+	}
+	lines := strings.Split(strings.TrimSuffix(c.Text(), "\n"), "\n")
+	if len(lines) != c.Size+2*quietZone {
+		t.Errorf("Text has %d lines, want %d", len(lines), c.Size+2*quietZone)
+	}
+	if b := c.Image(2).Bounds(); b.Dx() != 2*(c.Size+2*quietZone) {
+		t.Errorf("Image width = %d, want %d", b.Dx(), 2*(c.Size+2*quietZone))
+	}
+
+	if _, err := Encode(strings.Repeat("x", 107)); err == nil {
+		t.Error("Encode of 107 bytes succeeded, want error")
+	}
+}
+
+func TestEncodeGolden(t *testing.T) {
+	// The golden files hold the codes made for prefixes of text by an
+	// independent encoder, Kazuhiko Arase's QRCode for JavaScript, told
+	// to use the mask chosen by Encode. Dark modules are '#', light '.'.
+	// For each version there is a prefix that fills it and a shorter one
+	// that needs a terminator and padding.
+	text := strings.Repeat("lusab-babad-gutih-tugad.", 5)
+	tests := []struct {
+		length, version int
+	}{
+		{10, 1}, {17, 1},
+		{25, 2}, {32, 2},
+		{47, 3}, {53, 3}, // 47 bytes is the length of a seed.
+		{70, 4}, {78, 4},
+		{100, 5}, {106, 5},
+	}
+	for _, test := range tests {
+		c, err := Encode(text[:test.length])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := 4*test.version + 17; c.Size != want {
+			t.Errorf("%d bytes: size = %d, want %d", test.length, c.Size, want)
+			continue
+		}
+		want, err := ioutil.ReadFile(fmt.Sprintf("testdata/len%d.golden", test.length))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got strings.Builder
+		for y := 0; y < c.Size; y++ {
+			for x := 0; x < c.Size; x++ {
+				if c.Black(x, y) {
+					got.WriteByte('#')
+				} else {
+					got.WriteByte('.')
+				}
+			}
+			got.WriteByte('\n')
+		}
+		if got.String() != string(want) {
+			t.Errorf("%d bytes: code:\n%s\nwant:\n%s", test.length, got.String(), want)
+		}
+	}
+}
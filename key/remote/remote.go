@@ -7,10 +7,14 @@
 package remote // import "upspin.io/key/remote"
 
 import (
+	"encoding/json"
 	"fmt"
+	"strings"
+	"time"
 
 	"upspin.io/bind"
 	"upspin.io/errors"
+	"upspin.io/key/server/repl"
 	"upspin.io/key/usercache"
 	"upspin.io/log"
 	"upspin.io/rpc"
@@ -22,6 +26,14 @@ import (
 type dialConfig struct {
 	endpoint upspin.Endpoint
 	userName upspin.UserName
+
+	// config is the config given to Dial, used to dial the key server
+	// to which a user's domain has moved.
+	config upspin.Config
+
+	// moved is set for a key server dialed because a domain moved
+	// to it; its own forwarding records are not followed.
+	moved bool
 }
 
 // remote implements upspin.KeyServer.
@@ -30,7 +42,12 @@ type remote struct {
 	cfg        dialConfig
 }
 
-var _ upspin.KeyServer = (*remote)(nil)
+var (
+	_ upspin.KeyServer            = (*remote)(nil)
+	_ upspin.KeyRevoker           = (*remote)(nil)
+	_ upspin.KeyHistorian         = (*remote)(nil)
+	_ upspin.KeyDomainTransferrer = (*remote)(nil)
+)
 
 // Lookup implements upspin.Key.Lookup.
 func (r *remote) Lookup(name upspin.UserName) (*upspin.User, error) {
@@ -45,11 +62,83 @@ func (r *remote) Lookup(name upspin.UserName) (*upspin.User, error) {
 		return nil, op.error(err)
 	}
 	if len(resp.Error) != 0 {
-		return nil, op.error(errors.UnmarshalError(resp.Error))
+		err := errors.UnmarshalError(resp.Error)
+		if key, ok := r.movedTo(err); ok {
+			defer key.Close()
+			return key.Lookup(name)
+		}
+		return nil, op.error(err)
+	}
+	return proto.UpspinUser(resp.User), nil
+}
+
+// LookupAt implements upspin.KeyHistorian.
+func (r *remote) LookupAt(name upspin.UserName, t time.Time) (*upspin.User, error) {
+	op := r.opf("LookupAt", "%q, %v", name, t)
+
+	req := &proto.KeyLookupAtRequest{
+		UserName: string(name),
+		Time:     proto.UnixNano(t),
+	}
+	resp := new(proto.KeyLookupResponse)
+	if err := r.InvokeUnauthenticated("Key/LookupAt", req, resp); err != nil {
+		return nil, op.error(err)
+	}
+	if len(resp.Error) != 0 {
+		err := errors.UnmarshalError(resp.Error)
+		if key, ok := r.movedTo(err); ok {
+			defer key.Close()
+			return key.LookupAt(name, t)
+		}
+		return nil, op.error(err)
 	}
 	return proto.UpspinUser(resp.User), nil
 }
 
+// movedTo returns the key server to which err, returned by this server,
+// says the user's domain has moved. The caller must close it.
+func (r *remote) movedTo(err error) (*remote, bool) {
+	if r.cfg.moved || r.cfg.config == nil {
+		return nil, false
+	}
+	msg := err.Error()
+	i := strings.Index(msg, upspin.KeyMovedPrefix)
+	if i < 0 {
+		return nil, false
+	}
+	f := strings.Fields(msg[i+len(upspin.KeyMovedPrefix):])
+	if len(f) == 0 {
+		return nil, false
+	}
+	addr := upspin.NetAddr(f[0])
+	log.Info.Printf("key/remote: key server %s says the domain has moved to %s; update the keyserver in your config", r.cfg.endpoint.NetAddr, addr)
+	svc, err := r.Dial(r.cfg.config, upspin.Endpoint{Transport: upspin.Remote, NetAddr: addr})
+	if err != nil {
+		log.Error.Printf("key/remote: dialing %s: %v", addr, err)
+		return nil, false
+	}
+	key := svc.(*remote)
+	key.cfg.moved = true
+	return key, true
+}
+
+// History implements upspin.KeyHistorian.
+func (r *remote) History(name upspin.UserName) ([]upspin.UserVersion, error) {
+	op := r.opf("History", "%q", name)
+
+	req := &proto.KeyHistoryRequest{
+		UserName: string(name),
+	}
+	resp := new(proto.KeyHistoryResponse)
+	if err := r.Invoke("Key/History", req, resp, nil, nil); err != nil {
+		return nil, op.error(err)
+	}
+	if len(resp.Error) != 0 {
+		return nil, op.error(errors.UnmarshalError(resp.Error))
+	}
+	return proto.UpspinUserVersions(resp.Versions), nil
+}
+
 func userName(user *upspin.User) string {
 	if user == nil {
 		return "<nil>"
@@ -74,6 +163,92 @@ func (r *remote) Put(user *upspin.User) error {
 	return nil
 }
 
+// RevokeKey implements upspin.KeyRevoker.
+func (r *remote) RevokeKey(name upspin.UserName, key upspin.PublicKey, sig []byte) error {
+	op := r.opf("RevokeKey", "%q", name)
+
+	req := &proto.KeyRevokeRequest{
+		UserName:  string(name),
+		PublicKey: string(key),
+		Signature: sig,
+	}
+	resp := new(proto.KeyRevokeResponse)
+	if err := r.Invoke("Key/RevokeKey", req, resp, nil, nil); err != nil {
+		return op.error(err)
+	}
+	if len(resp.Error) != 0 {
+		return op.error(errors.UnmarshalError(resp.Error))
+	}
+	return nil
+}
+
+// TransferDomain implements upspin.KeyDomainTransferrer.
+func (r *remote) TransferDomain(domain string, dest upspin.NetAddr, sig []byte) error {
+	op := r.opf("TransferDomain", "%q, %q", domain, dest)
+
+	req := &proto.KeyTransferDomainRequest{
+		Domain:    domain,
+		Dest:      string(dest),
+		Signature: sig,
+	}
+	resp := new(proto.KeyTransferDomainResponse)
+	if err := r.Invoke("Key/TransferDomain", req, resp, nil, nil); err != nil {
+		return op.error(err)
+	}
+	if len(resp.Error) != 0 {
+		return op.error(errors.UnmarshalError(resp.Error))
+	}
+	return nil
+}
+
+// ImportDomain implements upspin.KeyDomainTransferrer.
+func (r *remote) ImportDomain(domain string, dest upspin.NetAddr, admin *upspin.User, sig []byte, users []*upspin.User) error {
+	op := r.opf("ImportDomain", "%q, %q, %d users", domain, dest, len(users))
+
+	req := &proto.KeyImportDomainRequest{
+		Domain:    domain,
+		Dest:      string(dest),
+		Admin:     proto.UserProto(admin),
+		Signature: sig,
+	}
+	for _, u := range users {
+		req.Users = append(req.Users, proto.UserProto(u))
+	}
+	resp := new(proto.KeyImportDomainResponse)
+	if err := r.Invoke("Key/ImportDomain", req, resp, nil, nil); err != nil {
+		return op.error(err)
+	}
+	if len(resp.Error) != 0 {
+		return op.error(errors.UnmarshalError(resp.Error))
+	}
+	return nil
+}
+
+// ExportSince returns the user records the server has changed since the
+// given logical time. See package upspin.io/key/server/repl.
+func (r *remote) ExportSince(since uint64) ([]*repl.Record, error) {
+	op := r.opf("ExportSince", "%d", since)
+
+	req := &proto.KeyExportRequest{
+		Since: since,
+	}
+	resp := new(proto.KeyExportResponse)
+	if err := r.Invoke("Key/ExportSince", req, resp, nil, nil); err != nil {
+		return nil, op.error(err)
+	}
+	if len(resp.Error) != 0 {
+		return nil, op.error(errors.UnmarshalError(resp.Error))
+	}
+	recs := make([]*repl.Record, len(resp.Records))
+	for i, b := range resp.Records {
+		recs[i] = new(repl.Record)
+		if err := json.Unmarshal(b, recs[i]); err != nil {
+			return nil, op.error(errors.Invalid, err)
+		}
+	}
+	return recs, nil
+}
+
 // Endpoint implements upspin.StoreServer.Endpoint.
 func (r *remote) Endpoint() upspin.Endpoint {
 	return r.cfg.endpoint
@@ -97,6 +272,7 @@ func (r *remote) Dial(config upspin.Config, e upspin.Endpoint) (upspin.Service,
 		cfg: dialConfig{
 			endpoint: e,
 			userName: config.UserName(),
+			config:   config,
 		},
 	}, nil
 }
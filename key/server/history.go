@@ -0,0 +1,149 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+// This file implements the history of user records. A change to a user's
+// record does not overwrite the earlier version but moves it, with the
+// times between which it was current, to the History of the userEntry,
+// so that an auditor can learn which key a user had at a given time.
+
+import (
+	"reflect"
+	"sort"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/metric"
+	"upspin.io/upspin"
+	"upspin.io/valid"
+)
+
+var _ upspin.KeyHistorian = (*server)(nil)
+
+// next returns the entry to store when the user record of e, which may be
+// nil for a new user, is changed to u at time now.
+func (e *userEntry) next(u upspin.User, isAdmin bool, now time.Time) *userEntry {
+	if e == nil {
+		return &userEntry{User: u, IsAdmin: isAdmin, ValidFrom: now}
+	}
+	ne := *e
+	ne.User = u
+	ne.IsAdmin = isAdmin
+	if reflect.DeepEqual(e.User, u) {
+		// Nothing new to remember.
+		return &ne
+	}
+	ne.ValidFrom = now
+	// Copy the history; e may be shared through the cache.
+	ne.History = append(e.History[:len(e.History):len(e.History)], upspin.UserVersion{
+		User:       e.User,
+		ValidFrom:  e.ValidFrom,
+		ValidUntil: now,
+	})
+	return &ne
+}
+
+// at returns the version of the user record that was current at time t,
+// or nil if there was none that the history records.
+func (e *userEntry) at(t time.Time) *upspin.User {
+	if !t.Before(e.ValidFrom) {
+		u := e.User
+		return &u
+	}
+	for i := len(e.History) - 1; i >= 0; i-- {
+		v := e.History[i]
+		if !t.Before(v.ValidFrom) && t.Before(v.ValidUntil) {
+			return &v.User
+		}
+	}
+	return nil
+}
+
+// hadKey reports whether key is the current public key of e or was an
+// earlier one.
+func (e *userEntry) hadKey(key upspin.PublicKey) bool {
+	if e.User.PublicKey == key {
+		return true
+	}
+	for _, v := range e.History {
+		if v.User.PublicKey == key {
+			return true
+		}
+	}
+	return false
+}
+
+// versions returns the history of e followed by its current version.
+func (e *userEntry) versions() []upspin.UserVersion {
+	v := append([]upspin.UserVersion(nil), e.History...)
+	return append(v, upspin.UserVersion{User: e.User, ValidFrom: e.ValidFrom})
+}
+
+// mergeHistory returns the union of two histories, ordered by the time
+// from which each version was current.
+func mergeHistory(a, b []upspin.UserVersion) []upspin.UserVersion {
+	seen := make(map[int64]bool) // Keyed by UnixNano; Locations may differ.
+	var h []upspin.UserVersion
+	for _, v := range append(append([]upspin.UserVersion(nil), a...), b...) {
+		if seen[v.ValidFrom.UnixNano()] {
+			continue
+		}
+		seen[v.ValidFrom.UnixNano()] = true
+		h = append(h, v)
+	}
+	sort.SliceStable(h, func(i, j int) bool {
+		return h[i].ValidFrom.Before(h[j].ValidFrom)
+	})
+	return h
+}
+
+// LookupAt implements upspin.KeyHistorian.
+func (s *server) LookupAt(name upspin.UserName, t time.Time) (*upspin.User, error) {
+	const op errors.Op = "key/server.LookupAt"
+	m, span := metric.NewSpan(op)
+	defer m.Done()
+
+	if err := valid.UserName(name); err != nil {
+		return nil, errors.E(op, name, err)
+	}
+	if err := s.checkMoved(op, name, span); err != nil {
+		return nil, err
+	}
+	entry, err := s.lookup(op, name, span)
+	if err != nil {
+		return nil, err
+	}
+	u := entry.at(t)
+	if u == nil {
+		return nil, errors.E(op, name, errors.NotExist, errors.Errorf("no record at %v", t))
+	}
+	return u, nil
+}
+
+// History implements upspin.KeyHistorian.
+func (s *server) History(name upspin.UserName) ([]upspin.UserVersion, error) {
+	const op errors.Op = "key/server.History"
+	m, span := metric.NewSpan(op)
+	defer m.Done()
+
+	if s.user == "" {
+		return nil, errors.E(op, errors.Internal, "not bound to user")
+	}
+	if err := valid.UserName(name); err != nil {
+		return nil, errors.E(op, name, err)
+	}
+	caller, err := s.lookup(op, s.user, span)
+	if err != nil {
+		return nil, err
+	}
+	if !caller.IsAdmin {
+		return nil, errors.E(op, errors.Permission, s.user, "only an administrator may read the history of user records")
+	}
+	entry, err := s.lookup(op, name, span)
+	if err != nil {
+		return nil, err
+	}
+	return entry.versions(), nil
+}
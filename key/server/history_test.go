@@ -0,0 +1,136 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"upspin.io/cache"
+	"upspin.io/cloud/storage/storagetest"
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+func TestLookupAt(t *testing.T) {
+	const name = "joe@upspin.io"
+	s := newMemoryKeyServer(name)
+
+	before := time.Now()
+	put(t, s, name, "key1")
+	t1 := time.Now()
+	put(t, s, name, "key1") // No change; no new version.
+	put(t, s, name, "key2")
+	t2 := time.Now()
+
+	check := func() {
+		t.Helper()
+		if _, err := s.LookupAt(name, before); !errors.Is(errors.NotExist, err) {
+			t.Errorf("LookupAt before first Put: err = %v, want NotExist", err)
+		}
+		for _, test := range []struct {
+			t    time.Time
+			want upspin.PublicKey
+		}{
+			{t1, "key1"},
+			{t2, "key2"},
+		} {
+			u, err := s.LookupAt(name, test.t)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if u.PublicKey != test.want {
+				t.Errorf("LookupAt(%v) key = %q, want %q", test.t, u.PublicKey, test.want)
+			}
+		}
+	}
+	check()
+	// Again, from storage.
+	s.forget(name)
+	check()
+
+	u, err := s.Lookup(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.PublicKey != "key2" {
+		t.Errorf("Lookup key = %q, want %q", u.PublicKey, "key2")
+	}
+}
+
+func TestHistory(t *testing.T) {
+	const (
+		name  = "joe@upspin.io"
+		admin = "admin@upspin.io"
+	)
+	s := newMemoryKeyServer(name)
+	put(t, s, name, "key1")
+	put(t, s, name, "key2")
+
+	if _, err := s.History(name); !errors.Is(errors.Permission, err) {
+		t.Fatalf("History by non-admin: err = %v, want Permission", err)
+	}
+
+	if err := s.putUserEntry("test", &userEntry{User: upspin.User{Name: admin}, IsAdmin: true}); err != nil {
+		t.Fatal(err)
+	}
+	s.user = admin
+	h, err := s.History(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(h) != 2 {
+		t.Fatalf("got %d versions, want 2: %v", len(h), h)
+	}
+	if h[0].User.PublicKey != "key1" || h[1].User.PublicKey != "key2" {
+		t.Errorf("versions have keys %q and %q, want key1 and key2", h[0].User.PublicKey, h[1].User.PublicKey)
+	}
+	if !h[0].ValidUntil.Equal(h[1].ValidFrom) || !h[1].ValidUntil.IsZero() {
+		t.Errorf("versions valid %v to %v and %v to %v; want them contiguous and the last current",
+			h[0].ValidFrom, h[0].ValidUntil, h[1].ValidFrom, h[1].ValidUntil)
+	}
+}
+
+func TestMergeHistory(t *testing.T) {
+	t0 := time.Unix(100, 0)
+	v := func(key upspin.PublicKey, from, until int64) upspin.UserVersion {
+		return upspin.UserVersion{
+			User:       upspin.User{PublicKey: key},
+			ValidFrom:  t0.Add(time.Duration(from)),
+			ValidUntil: t0.Add(time.Duration(until)),
+		}
+	}
+	a := []upspin.UserVersion{v("a", 0, 1), v("c", 2, 3)}
+	b := []upspin.UserVersion{v("a", 0, 1), v("b", 1, 2)}
+	h := mergeHistory(a, b)
+	var keys string
+	for _, v := range h {
+		keys += string(v.User.PublicKey)
+	}
+	if keys != "abc" {
+		t.Errorf("merged history has keys %q, want %q", keys, "abc")
+	}
+}
+
+// newMemoryKeyServer returns a server, bound to the named user, whose
+// storage is in memory.
+func newMemoryKeyServer(user upspin.UserName) *server {
+	return &server{
+		storage:   storagetest.Memory(),
+		user:      user,
+		lookupTXT: mockLookupTXT,
+		logger:    &noopLogger{},
+		cache:     cache.NewLRU(10),
+		negCache:  cache.NewLRU(10),
+	}
+}
+
+// put stores a record for the user with the given key.
+func put(t *testing.T, s *server, name upspin.UserName, key upspin.PublicKey) {
+	t.Helper()
+	if err := s.Put(&upspin.User{Name: name, PublicKey: key}); err != nil {
+		t.Fatal(err)
+	}
+}
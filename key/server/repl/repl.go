@@ -0,0 +1,484 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package repl implements active-active replication of a key server's user
+// records between two or more key servers, each with its own storage.
+//
+// A Replica sits between a key server and its storage. Each record Put
+// through it is stamped with a vector clock, whose entry for the replica
+// is incremented, and a logical (Lamport) timestamp, and the replica's
+// peers fetch the records they have not yet seen by calling ExportSince.
+//
+// A record fetched from a peer replaces the local one if its clock is
+// newer. If neither clock is newer, both records were changed since the
+// replicas last synchronized and the one with the higher logical timestamp
+// wins. If the timestamps are equal, as may happen after a split brain,
+// the one with the higher sum of its vector clock wins, and failing that
+// the one from the replica whose ID sorts last. The result carries the
+// union of both clocks, so every replica resolves the conflict the same
+// way and the records converge.
+//
+// The replication metadata is stored in each record, a JSON object, under
+// the key "Repl", which a key server that does not replicate ignores.
+// Records stored before replication began have no clock. They are sent to
+// every peer, but a peer that already holds a record for the same user
+// keeps its own, so the storage of a new replica should start empty or as
+// a copy of another's.
+//
+// Deletions are not replicated.
+package repl // import "upspin.io/key/server/repl"
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"upspin.io/cloud/storage"
+	"upspin.io/errors"
+	"upspin.io/log"
+	"upspin.io/upspin"
+	"upspin.io/valid"
+)
+
+// maxExport is the most records returned by one call to ExportSince.
+const maxExport = 1000
+
+// Clock is a vector clock, the number of changes to a record made by each
+// replica, by replica ID.
+type Clock map[string]uint64
+
+// Order is the result of comparing two Clocks.
+type Order int
+
+const (
+	Equal      Order = iota // The clocks are the same.
+	Before                  // The first clock happened before the second.
+	After                   // The first clock happened after the second.
+	Concurrent              // Neither happened before the other.
+)
+
+// Compare reports how c is ordered with respect to d.
+func (c Clock) Compare(d Clock) Order {
+	less, more := false, false
+	for id, n := range c {
+		if n > d[id] {
+			more = true
+		} else if n < d[id] {
+			less = true
+		}
+	}
+	for id, n := range d {
+		if _, ok := c[id]; !ok && n > 0 {
+			less = true
+		}
+	}
+	switch {
+	case less && more:
+		return Concurrent
+	case less:
+		return Before
+	case more:
+		return After
+	}
+	return Equal
+}
+
+// Sum returns the total number of changes counted by the clock.
+func (c Clock) Sum() uint64 {
+	var sum uint64
+	for _, n := range c {
+		sum += n
+	}
+	return sum
+}
+
+// Merge returns a new clock holding the larger of each entry of c and d.
+func (c Clock) Merge(d Clock) Clock {
+	m := make(Clock, len(c))
+	for id, n := range c {
+		m[id] = n
+	}
+	for id, n := range d {
+		if n > m[id] {
+			m[id] = n
+		}
+	}
+	return m
+}
+
+// Meta is the replication metadata of a record.
+type Meta struct {
+	// Clock is the record's vector clock.
+	Clock Clock `json:",omitempty"`
+
+	// Time is the logical timestamp of the Put that made the record.
+	Time uint64 `json:",omitempty"`
+
+	// Origin is the ID of the replica that made the record.
+	Origin string `json:",omitempty"`
+
+	// Updated is the time of the logical clock of the replica holding
+	// the record when the record was stored there. It orders the
+	// records returned by ExportSince.
+	Updated uint64 `json:",omitempty"`
+}
+
+// Record is a user record as it is exchanged between replicas.
+type Record struct {
+	Name upspin.UserName
+
+	// Data is the record as stored by the key server.
+	Data []byte
+
+	Meta
+}
+
+// Peer is another replica, from which a Replica fetches records.
+type Peer interface {
+	// ExportSince returns the records the peer has stored since the
+	// given time of its logical clock, in the order it stored them.
+	// It may return only some of them, in which case the caller
+	// should call again from the Updated time of the last record.
+	ExportSince(since uint64) ([]*Record, error)
+}
+
+// Config holds the parameters of a Replica.
+type Config struct {
+	// ID names the replica. It must be unique among its peers.
+	ID string
+
+	// Storage holds the records. It must implement storage.Lister.
+	Storage storage.Storage
+
+	// Merge, if not nil, is called to resolve a conflict between two
+	// records of the same user. It returns the data to store, which
+	// is normally the winner's, perhaps with something of the loser's
+	// that must not be lost.
+	Merge func(winner, loser []byte) ([]byte, error)
+
+	// Changed, if not nil, is called with the name of each record
+	// changed by a record fetched from a peer.
+	Changed func(upspin.UserName)
+}
+
+// Replica is a storage.Storage that replicates the user records it holds.
+type Replica struct {
+	cfg Config
+
+	mu    sync.Mutex
+	now   uint64                   // Time of the logical clock.
+	meta  map[upspin.UserName]Meta // Metadata of every record stored.
+	marks map[string]uint64        // The time of each peer up to which we have its records.
+}
+
+var _ storage.Storage = (*Replica)(nil)
+
+// New returns a Replica that reads the metadata of the records already in
+// the storage.
+func New(cfg Config) (*Replica, error) {
+	const op errors.Op = "key/server/repl.New"
+	if cfg.ID == "" {
+		return nil, errors.E(op, errors.Invalid, "empty replica ID")
+	}
+	lister, ok := cfg.Storage.(storage.Lister)
+	if !ok {
+		return nil, errors.E(op, errors.Invalid, "storage cannot list its contents")
+	}
+	r := &Replica{
+		cfg:   cfg,
+		meta:  make(map[upspin.UserName]Meta),
+		marks: make(map[string]uint64),
+	}
+	var unstamped []upspin.UserName
+	for token := ""; ; {
+		refs, next, err := lister.List(token)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		for _, ref := range refs {
+			name := upspin.UserName(ref.Ref)
+			if valid.UserName(name) != nil {
+				continue // Not a user record.
+			}
+			b, err := cfg.Storage.Download(string(name))
+			if err != nil {
+				return nil, errors.E(op, name, err)
+			}
+			m, err := metaOf(b)
+			if err != nil {
+				return nil, errors.E(op, name, err)
+			}
+			if m.Updated == 0 {
+				unstamped = append(unstamped, name)
+			}
+			r.meta[name] = m
+			r.advance(m.Time)
+			r.advance(m.Updated)
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	// Records stored before replication began are stamped as if stored
+	// now, so that peers fetch them. The stamp is written so that the
+	// logical clock never runs backwards across restarts.
+	for _, name := range unstamped {
+		b, err := cfg.Storage.Download(string(name))
+		if err != nil {
+			return nil, errors.E(op, name, err)
+		}
+		m := r.meta[name]
+		m.Updated = r.tick()
+		if err := r.store(name, b, m); err != nil {
+			return nil, errors.E(op, name, err)
+		}
+	}
+	return r, nil
+}
+
+// tick advances the logical clock and returns its new time.
+// r.mu must be held or r not yet shared.
+func (r *Replica) tick() uint64 {
+	r.now++
+	return r.now
+}
+
+// advance moves the logical clock forward to t if it is behind.
+// r.mu must be held or r not yet shared.
+func (r *Replica) advance(t uint64) {
+	if t > r.now {
+		r.now = t
+	}
+}
+
+// LinkBase implements storage.Storage.
+func (r *Replica) LinkBase() (string, error) {
+	return "", upspin.ErrNotSupported
+}
+
+// Download implements storage.Storage.
+func (r *Replica) Download(ref string) ([]byte, error) {
+	return r.cfg.Storage.Download(ref)
+}
+
+// Put implements storage.Storage. It stores a new version of the record
+// for the user named by ref.
+func (r *Replica) Put(ref string, contents []byte) error {
+	const op errors.Op = "key/server/repl.Put"
+	name := upspin.UserName(ref)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := r.meta[name]
+	m.Clock = m.Clock.Merge(nil)
+	m.Clock[r.cfg.ID]++
+	m.Time = r.tick()
+	m.Origin = r.cfg.ID
+	m.Updated = m.Time
+	if err := r.store(name, contents, m); err != nil {
+		return errors.E(op, name, err)
+	}
+	return nil
+}
+
+// Delete implements storage.Storage.
+func (r *Replica) Delete(ref string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.cfg.Storage.Delete(ref); err != nil {
+		return err
+	}
+	delete(r.meta, upspin.UserName(ref))
+	return nil
+}
+
+// List implements storage.Lister.
+func (r *Replica) List(token string) ([]upspin.ListRefsItem, string, error) {
+	return r.cfg.Storage.(storage.Lister).List(token)
+}
+
+// store writes the record with its metadata. r.mu must be held.
+func (r *Replica) store(name upspin.UserName, data []byte, m Meta) error {
+	b, err := withMeta(data, m)
+	if err != nil {
+		return err
+	}
+	if err := r.cfg.Storage.Put(string(name), b); err != nil {
+		return err
+	}
+	r.meta[name] = m
+	return nil
+}
+
+// ExportSince implements Peer.
+func (r *Replica) ExportSince(since uint64) ([]*Record, error) {
+	const op errors.Op = "key/server/repl.ExportSince"
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var recs []*Record
+	for name, m := range r.meta {
+		if m.Updated > since {
+			recs = append(recs, &Record{Name: name, Meta: m})
+		}
+	}
+	sort.Slice(recs, func(i, j int) bool { return recs[i].Updated < recs[j].Updated })
+	if len(recs) > maxExport {
+		recs = recs[:maxExport]
+	}
+	for _, rec := range recs {
+		b, err := r.cfg.Storage.Download(string(rec.Name))
+		if err != nil {
+			return nil, errors.E(op, rec.Name, err)
+		}
+		rec.Data = b
+	}
+	return recs, nil
+}
+
+// Apply stores a record fetched from a peer, if it is newer than the one
+// held or wins the conflict with it.
+func (r *Replica) Apply(rec *Record) error {
+	const op errors.Op = "key/server/repl.Apply"
+	if err := valid.UserName(rec.Name); err != nil {
+		return errors.E(op, err)
+	}
+	r.mu.Lock()
+	changed, err := r.apply(rec)
+	r.mu.Unlock()
+	if err != nil {
+		return errors.E(op, rec.Name, err)
+	}
+	if changed && r.cfg.Changed != nil {
+		r.cfg.Changed(rec.Name)
+	}
+	return nil
+}
+
+// apply implements Apply and reports whether the record held changed.
+// r.mu must be held.
+func (r *Replica) apply(rec *Record) (bool, error) {
+	r.advance(rec.Time)
+	local, ok := r.meta[rec.Name]
+	if !ok {
+		m := rec.Meta
+		m.Updated = r.tick()
+		return true, r.store(rec.Name, rec.Data, m)
+	}
+	switch rec.Clock.Compare(local.Clock) {
+	case Equal, Before:
+		return false, nil
+	case After:
+		m := rec.Meta
+		m.Updated = r.tick()
+		return true, r.store(rec.Name, rec.Data, m)
+	}
+
+	// Both were changed since we last synchronized.
+	data, err := r.cfg.Storage.Download(string(rec.Name))
+	if err != nil {
+		return false, err
+	}
+	winner, loser := rec, &Record{Name: rec.Name, Data: data, Meta: local}
+	if wins(loser, winner) {
+		winner, loser = loser, winner
+	}
+	log.Info.Printf("key/server/repl: conflict for %s: record from %s at %d wins over record from %s at %d",
+		rec.Name, winner.Origin, winner.Time, loser.Origin, loser.Time)
+	if r.cfg.Merge != nil {
+		if data, err = r.cfg.Merge(winner.Data, loser.Data); err != nil {
+			return false, err
+		}
+	} else {
+		data = winner.Data
+	}
+	m := winner.Meta
+	m.Clock = local.Clock.Merge(rec.Clock)
+	m.Updated = r.tick()
+	return true, r.store(rec.Name, data, m)
+}
+
+// wins reports whether record a wins a conflict with record b.
+func wins(a, b *Record) bool {
+	if a.Time != b.Time {
+		return a.Time > b.Time
+	}
+	if sa, sb := a.Clock.Sum(), b.Clock.Sum(); sa != sb {
+		return sa > sb
+	}
+	return a.Origin > b.Origin
+}
+
+// Sync fetches and applies the records the peer has stored since the
+// last Sync with it. The name identifies the peer.
+func (r *Replica) Sync(name string, p Peer) error {
+	const op errors.Op = "key/server/repl.Sync"
+	for {
+		r.mu.Lock()
+		since := r.marks[name]
+		r.mu.Unlock()
+		recs, err := p.ExportSince(since)
+		if err != nil {
+			return errors.E(op, errors.Errorf("peer %s: %v", name, err))
+		}
+		if len(recs) == 0 {
+			return nil
+		}
+		for _, rec := range recs {
+			if err := r.Apply(rec); err != nil {
+				return errors.E(op, errors.Errorf("peer %s: %v", name, err))
+			}
+			r.mu.Lock()
+			if rec.Updated > r.marks[name] {
+				r.marks[name] = rec.Updated
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+// Run calls Sync with each of the peers every interval until done is
+// closed. Errors are logged.
+func (r *Replica) Run(peers map[string]Peer, interval time.Duration, done <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		for name, p := range peers {
+			if err := r.Sync(name, p); err != nil {
+				log.Error.Print(err)
+			}
+		}
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// metaOf returns the replication metadata stored in the record b.
+func metaOf(b []byte) (Meta, error) {
+	var rec struct {
+		Repl Meta
+	}
+	err := json.Unmarshal(b, &rec)
+	return rec.Repl, err
+}
+
+// withMeta returns the record b, a JSON object, with its replication
+// metadata set to m.
+func withMeta(b []byte, m Meta) ([]byte, error) {
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, errors.E(errors.Invalid, err)
+	}
+	mb, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	obj["Repl"] = mb
+	return json.Marshal(obj)
+}
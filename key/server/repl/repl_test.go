@@ -0,0 +1,193 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package repl
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"upspin.io/cloud/storage"
+	"upspin.io/cloud/storage/storagetest"
+	"upspin.io/upspin"
+)
+
+const user = upspin.UserName("ann@example.com")
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		c, d Clock
+		want Order
+	}{
+		{nil, nil, Equal},
+		{Clock{"a": 1}, Clock{"a": 1, "b": 0}, Equal},
+		{Clock{"a": 1}, Clock{"a": 2}, Before},
+		{Clock{"a": 1}, Clock{"a": 1, "b": 1}, Before},
+		{Clock{"a": 2, "b": 1}, Clock{"a": 1}, After},
+		{Clock{"a": 2}, Clock{"a": 1, "b": 1}, Concurrent},
+	}
+	for _, test := range tests {
+		if got := test.c.Compare(test.d); got != test.want {
+			t.Errorf("%v.Compare(%v) = %v, want %v", test.c, test.d, got, test.want)
+		}
+	}
+}
+
+// newReplica returns a Replica with the given ID whose conflicts are
+// merged by appending the loser's value to the winner's.
+func newReplica(t *testing.T, id string, s storage.Storage) *Replica {
+	r, err := New(Config{
+		ID:      id,
+		Storage: s,
+		Merge: func(winner, loser []byte) ([]byte, error) {
+			return record(value(t, winner) + "+" + value(t, loser)), nil
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+// record returns a record holding the value.
+func record(v string) []byte {
+	return []byte(fmt.Sprintf(`{"Value":%q}`, v))
+}
+
+// value returns the value held by the record.
+func value(t *testing.T, b []byte) string {
+	var rec struct{ Value string }
+	if err := json.Unmarshal(b, &rec); err != nil {
+		t.Fatal(err)
+	}
+	return rec.Value
+}
+
+func put(t *testing.T, r *Replica, v string) {
+	if err := r.Put(string(user), record(v)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func get(t *testing.T, r *Replica) string {
+	b, err := r.Download(string(user))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return value(t, b)
+}
+
+func syncFrom(t *testing.T, r, p *Replica) {
+	if err := r.Sync(p.cfg.ID, p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplicate(t *testing.T) {
+	a := newReplica(t, "a", storagetest.Memory())
+	b := newReplica(t, "b", storagetest.Memory())
+	var changed []upspin.UserName
+	b.cfg.Changed = func(name upspin.UserName) { changed = append(changed, name) }
+
+	put(t, a, "1")
+	syncFrom(t, b, a)
+	if got := get(t, b); got != "1" {
+		t.Fatalf("b holds %q, want %q", got, "1")
+	}
+	if len(changed) != 1 || changed[0] != user {
+		t.Errorf("changed = %v, want [%s]", changed, user)
+	}
+
+	// A newer record replaces an older one, and an older one is ignored.
+	put(t, b, "2")
+	syncFrom(t, b, a)
+	syncFrom(t, a, b)
+	if got := get(t, a); got != "2" {
+		t.Fatalf("a holds %q, want %q", got, "2")
+	}
+	if got := get(t, b); got != "2" {
+		t.Fatalf("b holds %q, want %q", got, "2")
+	}
+	if len(changed) != 1 {
+		t.Errorf("changed = %v, want one change", changed)
+	}
+}
+
+func TestConflict(t *testing.T) {
+	a := newReplica(t, "a", storagetest.Memory())
+	b := newReplica(t, "b", storagetest.Memory())
+	put(t, a, "1")
+	syncFrom(t, b, a)
+
+	// Both change the record. The later Put, by logical time, wins.
+	put(t, a, "a")
+	put(t, b, "b")
+	put(t, b, "b2")
+	syncFrom(t, a, b)
+	syncFrom(t, b, a)
+	for _, r := range []*Replica{a, b} {
+		if got := get(t, r); got != "b2+a" {
+			t.Errorf("%s holds %q, want %q", r.cfg.ID, got, "b2+a")
+		}
+	}
+	if ma, mb := a.meta[user], b.meta[user]; ma.Clock.Compare(mb.Clock) != Equal {
+		t.Errorf("clocks differ after conflict: %v and %v", ma.Clock, mb.Clock)
+	}
+}
+
+func TestSplitBrain(t *testing.T) {
+	a := newReplica(t, "a", storagetest.Memory())
+	b := newReplica(t, "b", storagetest.Memory())
+	c := newReplica(t, "c", storagetest.Memory())
+	put(t, a, "1")
+	syncFrom(t, c, a)
+	put(t, c, "2")
+	syncFrom(t, a, c)
+
+	// a and b change the record at the same logical time; a has seen
+	// more changes, so its clock's sum is higher and it wins.
+	put(t, a, "a")
+	b.now = a.meta[user].Time - 1
+	put(t, b, "b")
+	if ta, tb := a.meta[user].Time, b.meta[user].Time; ta != tb {
+		t.Fatalf("times %d and %d differ", ta, tb)
+	}
+	syncFrom(t, a, b)
+	syncFrom(t, b, a)
+	for _, r := range []*Replica{a, b} {
+		if got := get(t, r); got != "a+b" {
+			t.Errorf("%s holds %q, want %q", r.cfg.ID, got, "a+b")
+		}
+	}
+}
+
+func TestRestart(t *testing.T) {
+	s := storagetest.Memory()
+	// A record stored before replication began.
+	if err := s.Put(string(user), record("0")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put("keyserver/log", []byte("not a record")); err != nil {
+		t.Fatal(err)
+	}
+	a := newReplica(t, "a", s)
+	b := newReplica(t, "b", storagetest.Memory())
+	syncFrom(t, b, a)
+	if got := get(t, b); got != "0" {
+		t.Fatalf("b holds %q, want %q", got, "0")
+	}
+
+	// After a restart, the clock carries on and peers see new Puts.
+	put(t, a, "1")
+	a = newReplica(t, "a", s)
+	put(t, a, "2")
+	syncFrom(t, b, a)
+	if got := get(t, b); got != "2" {
+		t.Fatalf("b holds %q, want %q", got, "2")
+	}
+	if n := b.meta[user].Clock["a"]; n != 2 {
+		t.Errorf("clock of a in b's record is %d, want 2", n)
+	}
+}
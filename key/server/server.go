@@ -13,11 +13,14 @@ import (
 	"net"
 	"strings"
 	"sync"
+	"time"
 
+	"upspin.io/bind"
 	"upspin.io/cache"
 	"upspin.io/cloud/storage"
 	"upspin.io/errors"
 	"upspin.io/factotum"
+	"upspin.io/key/server/repl"
 	"upspin.io/log"
 	"upspin.io/metric"
 	"upspin.io/upspin"
@@ -27,37 +30,70 @@ import (
 
 const cacheSize = 10000
 
+// syncInterval is how often a replicated server fetches changes from
+// its peers.
+const syncInterval = 30 * time.Second
+
 // New initializes an instance of the KeyServer
 // that stores its data in the given Storage implementation.
+//
+// The option "replica=id" makes the server one of a set of replicas,
+// each with its own storage, that accept Puts independently and exchange
+// their user records; see package upspin.io/key/server/repl. Each option
+// "peer=host:port" names another replica, from which the server fetches
+// changes once Replicate is called.
 func New(options ...string) (upspin.KeyServer, error) {
 	const op errors.Op = "key/server.New"
 
-	var backend string
+	var backend, replicaID string
+	var peers []upspin.NetAddr
 	var dialOpts []storage.DialOpts
 	for _, option := range options {
 		const prefix = "backend="
-		if strings.HasPrefix(option, prefix) {
+		switch {
+		case strings.HasPrefix(option, prefix):
 			backend = option[len(prefix):]
-			continue
+		case strings.HasPrefix(option, "replica="):
+			replicaID = strings.TrimPrefix(option, "replica=")
+		case strings.HasPrefix(option, "peer="):
+			peers = append(peers, upspin.NetAddr(strings.TrimPrefix(option, "peer=")))
+		default:
+			// Pass other options to the storage backend.
+			dialOpts = append(dialOpts, storage.WithOptions(option))
 		}
-		// Pass other options to the storage backend.
-		dialOpts = append(dialOpts, storage.WithOptions(option))
 	}
 	if backend == "" {
 		return nil, errors.E(op, errors.Invalid, `storage "backend" option is missing`)
 	}
+	if len(peers) > 0 && replicaID == "" {
+		return nil, errors.E(op, errors.Invalid, `"peer" option given without "replica"`)
+	}
 	s, err := storage.Dial(backend, dialOpts...)
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
-	return &server{
+	srv := &server{
 		storage:   s,
 		refCount:  &refCount{count: 1},
 		lookupTXT: net.LookupTXT,
 		logger:    &loggerImpl{storage: s},
 		cache:     cache.NewLRU(cacheSize),
 		negCache:  cache.NewLRU(cacheSize),
-	}, nil
+		peers:     peers,
+	}
+	if replicaID != "" {
+		srv.replica, err = repl.New(repl.Config{
+			ID:      replicaID,
+			Storage: s,
+			Merge:   mergeEntries,
+			Changed: srv.forget,
+		})
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		srv.storage = srv.replica
+	}
+	return srv, nil
 }
 
 // server is the implementation of the KeyServer Service.
@@ -81,9 +117,21 @@ type server struct {
 	// negCache caches the absence of a user. Key is a UserName and value is
 	// ignored.
 	negCache *cache.LRU
+
+	// replica, if not nil, replicates the user records with the peers.
+	// It is also the storage.
+	replica *repl.Replica
+	peers   []upspin.NetAddr
+
+	// dialKey, if not nil, returns the key server at the given address,
+	// to which a domain is to be transferred. See EnableTransfer.
+	dialKey func(upspin.NetAddr) (upspin.KeyServer, error)
 }
 
-var _ upspin.KeyServer = (*server)(nil)
+var (
+	_ upspin.KeyServer  = (*server)(nil)
+	_ upspin.KeyRevoker = (*server)(nil)
+)
 
 type refCount struct {
 	sync.Mutex
@@ -95,6 +143,19 @@ type refCount struct {
 type userEntry struct {
 	User    upspin.User
 	IsAdmin bool
+
+	// ValidFrom is when User was stored. It is zero for records
+	// stored before the server kept history.
+	ValidFrom time.Time
+
+	// History holds the earlier versions of User, oldest first.
+	// It only grows. See history.go.
+	History []upspin.UserVersion `json:",omitempty"`
+
+	// MovedTo is set only in the forwarding record of a domain that has
+	// been transferred, and is the address of its new key server.
+	// See transfer.go.
+	MovedTo upspin.NetAddr `json:",omitempty"`
 }
 
 // Lookup implements upspin.KeyServer.
@@ -106,6 +167,9 @@ func (s *server) Lookup(name upspin.UserName) (*upspin.User, error) {
 	if err := valid.UserName(name); err != nil {
 		return nil, errors.E(op, name, err)
 	}
+	if err := s.checkMoved(op, name, span); err != nil {
+		return nil, err
+	}
 	entry, err := s.lookup(op, name, span)
 	if err != nil {
 		return nil, err
@@ -154,10 +218,14 @@ func (s *server) Put(u *upspin.User) error {
 	if err := valid.User(u); err != nil {
 		return errors.E(op, err)
 	}
+	if err := s.checkMoved(op, u.Name, span); err != nil {
+		return err
+	}
 
 	// Retrieve info about the user we want to Put.
 	isAdmin := false
 	newUser := false
+	var revoked []upspin.PublicKey
 
 	entry, err := s.lookup(op, u.Name, span)
 	switch {
@@ -169,20 +237,36 @@ func (s *server) Put(u *upspin.User) error {
 	default:
 		// User exists.
 		isAdmin = entry.IsAdmin
+		revoked = entry.User.Revoked
 	}
 
 	if err := s.canPut(op, u.Name, newUser, span); err != nil {
 		return err
 	}
 
+	// The revocation list can only grow, through RevokeKey,
+	// and a revoked key cannot be reinstated.
+	for _, k := range revoked {
+		if k == u.PublicKey {
+			return errors.E(op, errors.Permission, u.Name, "public key has been revoked")
+		}
+	}
+	nu := *u
+	nu.Revoked = revoked
+
+	return s.putEntry(op, entry.next(nu, isAdmin, time.Now()), span)
+}
+
+// putEntry logs and stores the given user entry and updates the caches.
+func (s *server) putEntry(op errors.Op, entry *userEntry, span *metric.Span) error {
+	u := &entry.User
 	sp := span.StartSpan("logger.PutAttempt")
-	err = s.logger.PutAttempt(s.user, u)
+	err := s.logger.PutAttempt(s.user, u)
 	sp.End()
 	if err != nil {
 		return errors.E(op, err)
 	}
 
-	entry = &userEntry{User: *u, IsAdmin: isAdmin}
 	sp = span.StartSpan("putUserEntry")
 	err = s.putUserEntry(op, entry)
 	sp.End()
@@ -209,6 +293,81 @@ func (s *server) Put(u *upspin.User) error {
 	return nil
 }
 
+// RevokeKey implements upspin.KeyRevoker.
+func (s *server) RevokeKey(name upspin.UserName, key upspin.PublicKey, revokeSig []byte) error {
+	const op errors.Op = "key/server.RevokeKey"
+	m, span := metric.NewSpan(op)
+	defer m.Done()
+
+	if s.user == "" {
+		return errors.E(op, errors.Internal, "not bound to user")
+	}
+	if err := valid.UserName(name); err != nil {
+		return errors.E(op, err)
+	}
+	if err := s.checkMoved(op, name, span); err != nil {
+		return err
+	}
+	sig, err := factotum.ParseSignature(string(revokeSig))
+	if err != nil {
+		return errors.E(op, name, err)
+	}
+	entry, err := s.lookup(op, name, span)
+	if err != nil {
+		return err
+	}
+	if err := s.canRevoke(op, name, entry, key, sig, span); err != nil {
+		return err
+	}
+	for _, k := range entry.User.Revoked {
+		if k == key {
+			return nil // Already revoked.
+		}
+	}
+
+	nu := entry.User
+	nu.Revoked = append(append([]upspin.PublicKey(nil), entry.User.Revoked...), key)
+	return s.putEntry(op, entry.next(nu, entry.IsAdmin, time.Now()), span)
+}
+
+// canRevoke reports whether the signature authorizes the revocation of the
+// key of the target user, whose entry is given. The signature must be made
+// by the key itself, which must be the target's current or an earlier key,
+// or, if the current logged-in user is an administrator of the target's
+// domain or a global admin, by the current user's key.
+func (s *server) canRevoke(op errors.Op, target upspin.UserName, targetEntry *userEntry, key upspin.PublicKey, sig upspin.Signature, span *metric.Span) error {
+	sp := span.StartSpan("canRevoke")
+	defer sp.End()
+
+	hash := factotum.RevokeHash(key)
+	if factotum.Verify(hash, sig, key) == nil {
+		// Self-revocation. Anyone can sign with a key of their own,
+		// so it must be one the target has had.
+		if !targetEntry.hadKey(key) {
+			return errors.E(op, errors.Permission, target, "key does not belong to user")
+		}
+		return nil
+	}
+	entry, err := s.lookup(op, s.user, span)
+	if err != nil {
+		return err
+	}
+	if err := factotum.Verify(hash, sig, entry.User.PublicKey); err != nil {
+		return errors.E(op, errors.Permission, target, "revocation signature does not match key or caller")
+	}
+	if entry.IsAdmin {
+		return nil
+	}
+	_, _, domain, err := user.Parse(target)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if err := s.verifyOwns(s.user, entry.User.PublicKey, domain); err != nil {
+		return errors.E(op, errors.Permission, s.user, err)
+	}
+	return nil
+}
+
 // canPut reports whether the current logged-in user can Put the (new or
 // existing) target user.
 func (s *server) canPut(op errors.Op, target upspin.UserName, isTargetNew bool, span *metric.Span) error {
@@ -337,6 +496,101 @@ func (s *server) verifyOwns(u upspin.UserName, pubKey upspin.PublicKey, domain s
 	return lastErr
 }
 
+// forget removes the user from the caches.
+func (s *server) forget(name upspin.UserName) {
+	s.cache.Remove(name)
+	s.negCache.Remove(name)
+}
+
+// mergeEntries resolves a conflict between two replicated user entries.
+// The winner's entry is kept, but a key revoked in either stays revoked
+// and a version in the history of either stays in the history.
+func mergeEntries(winner, loser []byte) ([]byte, error) {
+	var w, l userEntry
+	if err := json.Unmarshal(winner, &w); err != nil {
+		return nil, errors.E(errors.Invalid, err)
+	}
+	if err := json.Unmarshal(loser, &l); err != nil {
+		return nil, errors.E(errors.Invalid, err)
+	}
+	revoked := make(map[upspin.PublicKey]bool)
+	for _, k := range w.User.Revoked {
+		revoked[k] = true
+	}
+	for _, k := range l.User.Revoked {
+		if !revoked[k] {
+			revoked[k] = true
+			w.User.Revoked = append(w.User.Revoked, k)
+		}
+	}
+	w.History = mergeHistory(w.History, l.History)
+	return json.Marshal(w)
+}
+
+// ExportSince returns the user records stored since the given time of
+// the replica's logical clock. It is called by the server's peers, which
+// must run as the server's user.
+func (s *server) ExportSince(since uint64) ([]*repl.Record, error) {
+	const op errors.Op = "key/server.ExportSince"
+	if s.replica == nil {
+		return nil, errors.E(op, upspin.ErrNotSupported)
+	}
+	recs, err := s.replica.ExportSince(since)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	return recs, nil
+}
+
+// Replicator starts the replication of a KeyServer's records.
+// It is implemented by the KeyServer in this package,
+// but is not part of the upspin.KeyServer interface.
+type Replicator interface {
+	Replicate(cfg upspin.Config) error
+}
+
+// Replicate starts fetching the changes made by the server's peers,
+// dialing them with the given config, whose user must be the one the
+// peers run as. It does nothing if the server has no peers.
+func (s *server) Replicate(cfg upspin.Config) error {
+	const op errors.Op = "key/server.Replicate"
+	if s.replica == nil || len(s.peers) == 0 {
+		return nil
+	}
+	peers := make(map[string]repl.Peer)
+	for _, addr := range s.peers {
+		key, err := bind.KeyServer(cfg, upspin.Endpoint{Transport: upspin.Remote, NetAddr: addr})
+		if err != nil {
+			return errors.E(op, err)
+		}
+		p, ok := key.(repl.Peer)
+		if !ok {
+			return errors.E(op, errors.Invalid, errors.Errorf("key server %s cannot export its records", addr))
+		}
+		peers[string(addr)] = p
+	}
+	go s.replica.Run(peers, syncInterval, nil)
+	return nil
+}
+
+// StorageChecker checks that a KeyServer's storage backend can be read.
+// It is implemented by the KeyServer in this package,
+// but is not part of the upspin.KeyServer interface.
+type StorageChecker interface {
+	CheckStorage() error
+}
+
+// CheckStorage tries to read a record from the storage backend, bypassing
+// the caches. The record does not exist, so a successful check reports
+// NotExist.
+func (s *server) CheckStorage() error {
+	const op errors.Op = "key/server.CheckStorage"
+	if _, err := s.storage.Download("healthz"); err != nil && !errors.Is(errors.NotExist, err) {
+		return errors.E(op, err)
+	}
+	return nil
+}
+
 // Log implements Logger.
 func (s *server) Log() ([]byte, error) {
 	const op errors.Op = "key/server.Log"
@@ -492,3 +492,162 @@ func newKeyServerWithMocking(user upspin.UserName, ref string, data []byte) (*se
 func mockLookupTXT(domain string) ([]string, error) {
 	return nil, nil
 }
+
+func TestRevokeKeySelf(t *testing.T) {
+	const myName = "joe@upspin.io"
+
+	f, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "joe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := &upspin.User{
+		Name:      myName,
+		PublicKey: f.PublicKey(),
+	}
+	u, mockGCP := newKeyServerWithMocking(myName, myName, marshalUser(t, user, !isAdmin))
+
+	// A signature by some other key is rejected.
+	sig, err := other.Sign(factotum.RevokeHash(f.PublicKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = u.RevokeKey(myName, f.PublicKey(), []byte(factotum.FormatSignature(sig)))
+	if !errors.Is(errors.Permission, err) {
+		t.Fatalf("RevokeKey with wrong signature: err = %v, want Permission", err)
+	}
+
+	// A self-signed revocation of a key the user never had is rejected.
+	sig, err = other.Sign(factotum.RevokeHash(other.PublicKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = u.RevokeKey(myName, other.PublicKey(), []byte(factotum.FormatSignature(sig)))
+	if !errors.Is(errors.Permission, err) {
+		t.Fatalf("RevokeKey of another's key: err = %v, want Permission", err)
+	}
+
+	// A signature by the key itself is accepted.
+	sig, err = f.Sign(factotum.RevokeHash(f.PublicKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = u.RevokeKey(myName, f.PublicKey(), []byte(factotum.FormatSignature(sig)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mockGCP.PutContents) != 1 {
+		t.Fatalf("num puts = %d, want = 1", len(mockGCP.PutContents))
+	}
+	savedUser, _ := unmarshalUser(t, mockGCP.PutContents[0])
+	if want := []upspin.PublicKey{f.PublicKey()}; !reflect.DeepEqual(savedUser.Revoked, want) {
+		t.Errorf("revoked = %v, want = %v", savedUser.Revoked, want)
+	}
+
+	// The revoked key cannot be put back.
+	err = u.Put(user)
+	if !errors.Is(errors.Permission, err) {
+		t.Fatalf("Put of revoked key: err = %v, want Permission", err)
+	}
+}
+
+func TestRevokeKeyEarlier(t *testing.T) {
+	const myName = "joe@upspin.io"
+
+	f, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "joe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The user's key was f's and is now some other.
+	ue := userEntry{
+		User: upspin.User{Name: myName, PublicKey: "new key"},
+		History: []upspin.UserVersion{
+			{User: upspin.User{Name: myName, PublicKey: f.PublicKey()}},
+		},
+	}
+	buf, err := json.Marshal(ue)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, mockGCP := newKeyServerWithMocking(myName, myName, buf)
+
+	sig, err := f.Sign(factotum.RevokeHash(f.PublicKey()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = u.RevokeKey(myName, f.PublicKey(), []byte(factotum.FormatSignature(sig)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mockGCP.PutContents) != 1 {
+		t.Fatalf("num puts = %d, want = 1", len(mockGCP.PutContents))
+	}
+	savedUser, _ := unmarshalUser(t, mockGCP.PutContents[0])
+	if want := []upspin.PublicKey{f.PublicKey()}; !reflect.DeepEqual(savedUser.Revoked, want) {
+		t.Errorf("revoked = %v, want = %v", savedUser.Revoked, want)
+	}
+}
+
+func TestDomainAdminRevokeOther(t *testing.T) {
+	const (
+		// domainAdmin is an admin for otherDude's domain.
+		domainAdmin = "bob@master.com"
+		otherDude   = "other@dude.com"
+	)
+
+	admin, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	adminJSON := marshalUser(t, &upspin.User{Name: domainAdmin, PublicKey: admin.PublicKey()}, !isAdmin)
+	otherKey := upspin.PublicKey("a compromised key")
+	otherJSON := marshalUser(t, &upspin.User{Name: otherDude, PublicKey: otherKey}, !isAdmin)
+
+	u, mockGCP := newKeyServerWithMocking(domainAdmin, otherDude, otherJSON)
+	mockGCP.Ref = append(mockGCP.Ref, domainAdmin)
+	mockGCP.Data = append(mockGCP.Data, adminJSON)
+	u.lookupTXT = func(domain string) ([]string, error) {
+		if domain == "dude.com" {
+			return []string{
+				"upspin:4f1f4d29537fe0239f21d1384c32c61795360c744ad4f6f474f46dd7c2d03edb-494d8cb1988121ee0056fb49d182ab200dd5ad3572f28a47444ed41e8e947123",
+			}, nil
+		}
+		return nil, errors.Str("no host found")
+	}
+
+	sig, err := admin.Sign(factotum.RevokeHash(otherKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = u.RevokeKey(otherDude, otherKey, []byte(factotum.FormatSignature(sig)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mockGCP.PutRef) != 1 || mockGCP.PutRef[0] != otherDude {
+		t.Fatalf("puts = %v, want = [%s]", mockGCP.PutRef, otherDude)
+	}
+	savedUser, _ := unmarshalUser(t, mockGCP.PutContents[0])
+	if want := []upspin.PublicKey{otherKey}; !reflect.DeepEqual(savedUser.Revoked, want) {
+		t.Errorf("revoked = %v, want = %v", savedUser.Revoked, want)
+	}
+}
+
+func TestMergeEntries(t *testing.T) {
+	const name = "joe@upspin.io"
+	winner := marshalUser(t, &upspin.User{Name: name, PublicKey: "new", Revoked: []upspin.PublicKey{"a"}}, !isAdmin)
+	loser := marshalUser(t, &upspin.User{Name: name, PublicKey: "old", Revoked: []upspin.PublicKey{"a", "b"}}, isAdmin)
+	merged, err := mergeEntries(winner, loser)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user, admin := unmarshalUser(t, merged)
+	if user.PublicKey != "new" || admin {
+		t.Errorf("merged entry is %+v, admin %v; want the winner's", user, admin)
+	}
+	if want := []upspin.PublicKey{"a", "b"}; !reflect.DeepEqual(user.Revoked, want) {
+		t.Errorf("revoked = %v, want = %v", user.Revoked, want)
+	}
+}
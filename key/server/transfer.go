@@ -0,0 +1,246 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+// This file implements the transfer of a domain's users to another key
+// server. The transferring server sends the current record of each user
+// to the new server and then stores a forwarding record for the domain,
+// a userEntry named "*@domain" whose MovedTo field holds the address of
+// the new server. The name cannot be that of a user, since Put refuses
+// wildcards. The forwarding record is kept indefinitely, so that clients
+// whose configs still name the old server are sent to the new one.
+
+import (
+	"strings"
+	"time"
+
+	"upspin.io/bind"
+	"upspin.io/cloud/storage"
+	"upspin.io/errors"
+	"upspin.io/factotum"
+	"upspin.io/metric"
+	"upspin.io/upspin"
+	"upspin.io/user"
+	"upspin.io/valid"
+)
+
+var _ upspin.KeyDomainTransferrer = (*server)(nil)
+
+// Transferrer is implemented by the KeyServer in this package, which can
+// transfer a domain to another key server only once EnableTransfer has
+// been called.
+type Transferrer interface {
+	EnableTransfer(cfg upspin.Config)
+}
+
+// EnableTransfer allows the server to transfer domains to other key
+// servers, which it dials with the given config. It must be called
+// before the server is used.
+func (s *server) EnableTransfer(cfg upspin.Config) {
+	s.dialKey = func(addr upspin.NetAddr) (upspin.KeyServer, error) {
+		return bind.KeyServer(cfg, upspin.Endpoint{Transport: upspin.Remote, NetAddr: addr})
+	}
+}
+
+// domainRecord returns the name under which the forwarding record of the
+// domain is stored.
+func domainRecord(domain string) upspin.UserName {
+	return upspin.UserName("*@" + domain)
+}
+
+// checkMoved returns an error naming the new key server if the domain of
+// the named user has been transferred.
+func (s *server) checkMoved(op errors.Op, name upspin.UserName, span *metric.Span) error {
+	_, _, domain, err := user.Parse(name)
+	if err != nil {
+		return errors.E(op, name, err)
+	}
+	entry, err := s.lookup(op, domainRecord(domain), span)
+	if errors.Is(errors.NotExist, err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if entry.MovedTo == "" {
+		return nil
+	}
+	return errors.E(op, name, errors.NotExist, errors.Str(upspin.KeyMovedPrefix+string(entry.MovedTo)))
+}
+
+// TransferDomain implements upspin.KeyDomainTransferrer.
+func (s *server) TransferDomain(domain string, dest upspin.NetAddr, adminSig []byte) error {
+	const op errors.Op = "key/server.TransferDomain"
+	m, span := metric.NewSpan(op)
+	defer m.Done()
+
+	if s.user == "" {
+		return errors.E(op, errors.Internal, "not bound to user")
+	}
+	if s.dialKey == nil {
+		return errors.E(op, errors.Invalid, "server is not configured to transfer domains")
+	}
+	domain, err := user.ParseDomain(domain)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if dest == "" {
+		return errors.E(op, errors.Invalid, "empty destination key server")
+	}
+	caller, err := s.lookup(op, s.user, span)
+	if err != nil {
+		return err
+	}
+	if err := s.canTransfer(op, domain, dest, &caller.User, adminSig); err != nil {
+		return err
+	}
+
+	users, err := s.domainUsers(op, domain)
+	if err != nil {
+		return err
+	}
+	key, err := s.dialKey(dest)
+	if err != nil {
+		return errors.E(op, errors.IO, err)
+	}
+	t, ok := key.(upspin.KeyDomainTransferrer)
+	if !ok {
+		return errors.E(op, errors.Invalid, errors.Errorf("key server %s cannot import a domain", dest))
+	}
+	if err := t.ImportDomain(domain, dest, &caller.User, adminSig, users); err != nil {
+		return errors.E(op, err)
+	}
+
+	fwd := &userEntry{
+		User:      upspin.User{Name: domainRecord(domain)},
+		ValidFrom: time.Now(),
+		MovedTo:   dest,
+	}
+	return s.putEntry(op, fwd, span)
+}
+
+// canTransfer reports whether the signature authorizes the transfer of
+// the domain to dest by admin: it must be admin's signature of the
+// transfer, and admin must administer the domain, as shown by its DNS
+// TXT record.
+func (s *server) canTransfer(op errors.Op, domain string, dest upspin.NetAddr, admin *upspin.User, adminSig []byte) error {
+	sig, err := factotum.ParseSignature(string(adminSig))
+	if err != nil {
+		return errors.E(op, admin.Name, err)
+	}
+	if err := factotum.Verify(factotum.TransferHash(domain, dest), sig, admin.PublicKey); err != nil {
+		return errors.E(op, errors.Permission, admin.Name, "transfer signature does not match key")
+	}
+	if err := s.verifyOwns(admin.Name, admin.PublicKey, domain); err != nil {
+		return errors.E(op, errors.Permission, admin.Name, err)
+	}
+	return nil
+}
+
+// domainUsers returns the current records of all the users of the domain.
+func (s *server) domainUsers(op errors.Op, domain string) ([]*upspin.User, error) {
+	lister, ok := s.storage.(storage.Lister)
+	if !ok {
+		return nil, errors.E(op, upspin.ErrNotSupported, "storage cannot list its contents")
+	}
+	var users []*upspin.User
+	for token := ""; ; {
+		refs, next, err := lister.List(token)
+		if err != nil {
+			return nil, errors.E(op, errors.IO, err)
+		}
+		for _, ref := range refs {
+			name := upspin.UserName(ref.Ref)
+			if !strings.HasSuffix(string(name), "@"+domain) || name == domainRecord(domain) {
+				continue
+			}
+			if valid.UserName(name) != nil {
+				continue // Not a user record.
+			}
+			entry, err := s.fetchUserEntry(op, name)
+			if err != nil {
+				return nil, err
+			}
+			u := entry.User
+			users = append(users, &u)
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	return users, nil
+}
+
+// ImportDomain implements upspin.KeyDomainTransferrer.
+func (s *server) ImportDomain(domain string, dest upspin.NetAddr, admin *upspin.User, adminSig []byte, users []*upspin.User) error {
+	const op errors.Op = "key/server.ImportDomain"
+	m, span := metric.NewSpan(op)
+	defer m.Done()
+
+	if s.user == "" {
+		return errors.E(op, errors.Internal, "not bound to user")
+	}
+	domain, err := user.ParseDomain(domain)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if admin == nil {
+		return errors.E(op, errors.Invalid, "no administrator")
+	}
+	if err := s.canTransfer(op, domain, dest, admin, adminSig); err != nil {
+		return err
+	}
+	for _, u := range users {
+		if err := valid.User(u); err != nil {
+			return errors.E(op, err)
+		}
+		name, _, d, err := user.Parse(u.Name)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		if d != domain || name == "*" {
+			return errors.E(op, errors.Invalid, u.Name, errors.Errorf("user is not of domain %s", domain))
+		}
+	}
+
+	// If the domain was transferred away from here before, it is back.
+	if err := s.storage.Delete(string(domainRecord(domain))); err != nil && !errors.Is(errors.NotExist, err) {
+		return errors.E(op, errors.IO, err)
+	}
+	s.forget(domainRecord(domain))
+
+	now := time.Now()
+	for _, u := range users {
+		entry, err := s.lookup(op, u.Name, span)
+		if err != nil && !errors.Is(errors.NotExist, err) {
+			return err
+		}
+		nu := *u
+		isAdmin := false
+		if entry != nil {
+			isAdmin = entry.IsAdmin
+			// A key revoked here stays revoked.
+			nu.Revoked = mergeRevoked(u.Revoked, entry.User.Revoked)
+		}
+		if err := s.putEntry(op, entry.next(nu, isAdmin, now), span); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeRevoked returns the union of two lists of revoked keys.
+func mergeRevoked(a, b []upspin.PublicKey) []upspin.PublicKey {
+	seen := make(map[upspin.PublicKey]bool)
+	var keys []upspin.PublicKey
+	for _, k := range append(append([]upspin.PublicKey(nil), a...), b...) {
+		if !seen[k] {
+			seen[k] = true
+			keys = append(keys, k)
+		}
+	}
+	return keys
+}
@@ -0,0 +1,126 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package server
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"upspin.io/cache"
+	"upspin.io/cloud/storage/storagetest"
+	"upspin.io/errors"
+	"upspin.io/factotum"
+	"upspin.io/test/testutil"
+	"upspin.io/upspin"
+)
+
+// newMemKeyServer returns a server, bound to the named user, that keeps
+// its records in memory and believes bob@master.com administers dude.com.
+func newMemKeyServer(user upspin.UserName) *server {
+	return &server{
+		storage: storagetest.Memory(),
+		user:    user,
+		lookupTXT: func(domain string) ([]string, error) {
+			if domain == "dude.com" {
+				return []string{
+					"upspin:4f1f4d29537fe0239f21d1384c32c61795360c744ad4f6f474f46dd7c2d03edb-494d8cb1988121ee0056fb49d182ab200dd5ad3572f28a47444ed41e8e947123",
+				}, nil
+			}
+			return nil, errors.Str("no host found")
+		},
+		logger:   &noopLogger{},
+		cache:    cache.NewLRU(10),
+		negCache: cache.NewLRU(10),
+	}
+}
+
+func TestTransferDomain(t *testing.T) {
+	const (
+		domainAdmin = "bob@master.com"
+		destAddr    = upspin.NetAddr("key.dude.com:443")
+	)
+	admin, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "joe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	src := newMemKeyServer(domainAdmin)
+	users := []*upspin.User{
+		{Name: domainAdmin, PublicKey: admin.PublicKey()},
+		{Name: "ann@dude.com", PublicKey: "ann's key", Revoked: []upspin.PublicKey{"ann's old key"}},
+		{Name: "ann+snapshot@dude.com", PublicKey: "ann's key"},
+		{Name: "joe@elsewhere.com", PublicKey: "joe's key"},
+	}
+	for _, u := range users {
+		if err := src.putUserEntry("test", &userEntry{User: *u}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The destination is called by the source's own user.
+	dest := newMemKeyServer("keyserver@master.com")
+	src.dialKey = func(addr upspin.NetAddr) (upspin.KeyServer, error) {
+		if addr != destAddr {
+			t.Fatalf("dialed %s, want %s", addr, destAddr)
+		}
+		return dest, nil
+	}
+
+	sign := func(f upspin.Factotum, dest upspin.NetAddr) []byte {
+		sig, err := f.Sign(factotum.TransferHash("dude.com", dest))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return []byte(factotum.FormatSignature(sig))
+	}
+
+	// Only the administrator's signature of this transfer will do.
+	if err := src.TransferDomain("dude.com", destAddr, sign(other, destAddr)); !errors.Is(errors.Permission, err) {
+		t.Fatalf("TransferDomain signed by another key: err = %v, want Permission", err)
+	}
+	if err := src.TransferDomain("dude.com", destAddr, sign(admin, "key.example.com:443")); !errors.Is(errors.Permission, err) {
+		t.Fatalf("TransferDomain signed for another server: err = %v, want Permission", err)
+	}
+	if err := src.TransferDomain("master.com", destAddr, sign(admin, destAddr)); !errors.Is(errors.Permission, err) {
+		t.Fatalf("TransferDomain of another domain: err = %v, want Permission", err)
+	}
+
+	if err := src.TransferDomain("dude.com", destAddr, sign(admin, destAddr)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The destination has the users of the domain, and only them.
+	for _, u := range users[1:3] {
+		got, err := dest.Lookup(u.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, u) {
+			t.Errorf("dest.Lookup(%q) = %v, want %v", u.Name, got, u)
+		}
+	}
+	if _, err := dest.Lookup("joe@elsewhere.com"); !errors.Is(errors.NotExist, err) {
+		t.Errorf("dest.Lookup of a user of another domain: err = %v, want NotExist", err)
+	}
+
+	// The source sends clients to the destination.
+	for _, name := range []upspin.UserName{"ann@dude.com", "nobody@dude.com"} {
+		_, err := src.Lookup(name)
+		if !errors.Is(errors.NotExist, err) || !strings.Contains(err.Error(), upspin.KeyMovedPrefix+string(destAddr)) {
+			t.Errorf("src.Lookup(%q): err = %v, want forwarding to %s", name, err, destAddr)
+		}
+	}
+	if err := src.Put(&upspin.User{Name: "new@dude.com", PublicKey: "new key"}); err == nil || !strings.Contains(err.Error(), upspin.KeyMovedPrefix) {
+		t.Errorf("src.Put after transfer: err = %v, want forwarding", err)
+	}
+	if _, err := src.Lookup("joe@elsewhere.com"); err != nil {
+		t.Errorf("src.Lookup of a user of another domain: %v", err)
+	}
+}
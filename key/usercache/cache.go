@@ -17,6 +17,7 @@ import (
 
 	"upspin.io/cache"
 	"upspin.io/errors"
+	"upspin.io/key/server/repl"
 	"upspin.io/upspin"
 )
 
@@ -119,6 +120,116 @@ func (c *userCacheServer) Put(user *upspin.User) error {
 	return nil
 }
 
+// RevokeKey passes the request through to the underlying key server,
+// if that server records revoked keys.
+func (c *userCacheServer) RevokeKey(name upspin.UserName, key upspin.PublicKey, sig []byte) error {
+	const op errors.Op = "key/usercache.RevokeKey"
+	if err := c.dial(); err != nil {
+		return errors.E(op, err)
+	}
+	r, ok := c.dd.dialed.(upspin.KeyRevoker)
+	if !ok {
+		return errors.E(op, upspin.ErrNotSupported)
+	}
+	if err := r.RevokeKey(name, key, sig); err != nil {
+		return errors.E(op, err)
+	}
+	c.cache.entries.Remove(name)
+	return nil
+}
+
+// LookupAt passes the request through to the underlying key server,
+// if that server keeps the history of user records.
+// Past records are not cached.
+func (c *userCacheServer) LookupAt(name upspin.UserName, t time.Time) (*upspin.User, error) {
+	const op errors.Op = "key/usercache.LookupAt"
+	if err := c.dial(); err != nil {
+		return nil, errors.E(op, err)
+	}
+	h, ok := c.dd.dialed.(upspin.KeyHistorian)
+	if !ok {
+		return nil, errors.E(op, upspin.ErrNotSupported)
+	}
+	u, err := h.LookupAt(name, t)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	return u, nil
+}
+
+// History passes the request through to the underlying key server,
+// if that server keeps the history of user records.
+func (c *userCacheServer) History(name upspin.UserName) ([]upspin.UserVersion, error) {
+	const op errors.Op = "key/usercache.History"
+	if err := c.dial(); err != nil {
+		return nil, errors.E(op, err)
+	}
+	h, ok := c.dd.dialed.(upspin.KeyHistorian)
+	if !ok {
+		return nil, errors.E(op, upspin.ErrNotSupported)
+	}
+	v, err := h.History(name)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	return v, nil
+}
+
+// TransferDomain passes the request through to the underlying key server,
+// if that server can transfer domains.
+func (c *userCacheServer) TransferDomain(domain string, dest upspin.NetAddr, sig []byte) error {
+	const op errors.Op = "key/usercache.TransferDomain"
+	if err := c.dial(); err != nil {
+		return errors.E(op, err)
+	}
+	t, ok := c.dd.dialed.(upspin.KeyDomainTransferrer)
+	if !ok {
+		return errors.E(op, upspin.ErrNotSupported)
+	}
+	if err := t.TransferDomain(domain, dest, sig); err != nil {
+		return errors.E(op, err)
+	}
+	return nil
+}
+
+// ImportDomain passes the request through to the underlying key server,
+// if that server can transfer domains.
+func (c *userCacheServer) ImportDomain(domain string, dest upspin.NetAddr, admin *upspin.User, sig []byte, users []*upspin.User) error {
+	const op errors.Op = "key/usercache.ImportDomain"
+	if err := c.dial(); err != nil {
+		return errors.E(op, err)
+	}
+	t, ok := c.dd.dialed.(upspin.KeyDomainTransferrer)
+	if !ok {
+		return errors.E(op, upspin.ErrNotSupported)
+	}
+	if err := t.ImportDomain(domain, dest, admin, sig, users); err != nil {
+		return errors.E(op, err)
+	}
+	for _, u := range users {
+		c.cache.entries.Remove(u.Name)
+	}
+	return nil
+}
+
+// ExportSince passes the request through to the underlying key server,
+// if that server exports its records for replication.
+func (c *userCacheServer) ExportSince(since uint64) ([]*repl.Record, error) {
+	const op errors.Op = "key/usercache.ExportSince"
+	if err := c.dial(); err != nil {
+		return nil, errors.E(op, err)
+	}
+	p, ok := c.dd.dialed.(repl.Peer)
+	if !ok {
+		return nil, errors.E(op, upspin.ErrNotSupported)
+	}
+	recs, err := p.ExportSince(since)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	return recs, nil
+}
+
 // Endpoint implements upspin.Service.
 func (c *userCacheServer) Endpoint() upspin.Endpoint {
 	// We don't want Endpoint to trigger a Dial.
@@ -285,6 +285,17 @@ func (s *service) Put(user *upspin.User) error {
 	return nil
 }
 
+func (s *service) RevokeKey(name upspin.UserName, key upspin.PublicKey, sig []byte) error {
+	if u, ok := s.entries[string(name)]; ok {
+		u.Revoked = append(u.Revoked, key)
+	}
+	return nil
+}
+
+func (s *service) LookupAt(name upspin.UserName, t time.Time) (*upspin.User, error) {
+	return s.Lookup(name)
+}
+
 func (s *service) Dial(cfg upspin.Config, e upspin.Endpoint) (upspin.Service, error) {
 	s.dials++
 	s.config = cfg
@@ -0,0 +1,65 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// jsonLogger writes each message as a JSON object on a line of its own.
+type jsonLogger struct {
+	mu sync.Mutex // Serializes writes to w.
+	w  io.Writer
+}
+
+// jsonRecord is the form of a message written by jsonLogger.
+type jsonRecord struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Message string `json:"message"`
+}
+
+// output writes msg, logged at level, to the log.
+func (j *jsonLogger) output(level Level, msg string) {
+	b, err := json.Marshal(jsonRecord{
+		Time:    time.Now().UTC().Format(time.RFC3339Nano),
+		Level:   toString(level),
+		Message: strings.TrimSuffix(msg, "\n"),
+	})
+	if err != nil {
+		// Cannot happen: all the fields are strings.
+		b = []byte(fmt.Sprintf(`{"message":%q}`, err))
+	}
+	b = append(b, '\n')
+	j.mu.Lock()
+	j.w.Write(b)
+	j.mu.Unlock()
+}
+
+// SetFormat sets the format of the messages written by the default
+// loggers: "text", the default, or "json", which writes each message as
+// a JSON object on a line of its own, holding its time, level and text.
+// It does not affect an ExternalLogger.
+func SetFormat(format string) error {
+	if format != "text" && format != "json" {
+		return fmt.Errorf("invalid log format %q", format)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	state.format = format
+	state.setOutput(state.output)
+	return nil
+}
+
+// GetFormat returns the format of the messages written by the default
+// loggers.
+func GetFormat() string {
+	return globals().format
+}
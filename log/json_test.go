@@ -0,0 +1,69 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestJSONFormat(t *testing.T) {
+	var buf bytes.Buffer
+	SetOutput(&buf)
+	if err := SetFormat("json"); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		SetFormat("text")
+		SetOutput(os.Stderr)
+	}()
+	SetLevel("info")
+
+	start := time.Now().Add(-time.Second)
+	Debug.Print("not logged")
+	Info.Printf("hello, %s", "world")
+	Error.Println("a", "quote\"and\nnewline")
+
+	want := []jsonRecord{
+		{Level: "info", Message: "hello, world"},
+		{Level: "error", Message: "a quote\"and\nnewline"},
+	}
+	scanner := bufio.NewScanner(&buf)
+	var got []jsonRecord
+	for scanner.Scan() {
+		var r jsonRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			t.Fatalf("%q: %v", scanner.Text(), err)
+		}
+		tm, err := time.Parse(time.RFC3339Nano, r.Time)
+		if err != nil {
+			t.Fatalf("bad time: %v", err)
+		}
+		if tm.Before(start) || tm.After(time.Now()) {
+			t.Errorf("time %v is not now", tm)
+		}
+		r.Time = ""
+		got = append(got, r)
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %d messages, want %d: %q", len(got), len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("message %d is %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	if err := SetFormat("xml"); err == nil {
+		t.Error("SetFormat(\"xml\") succeeded")
+	}
+	if f := GetFormat(); f != "json" {
+		t.Errorf("GetFormat() = %q, want json", f)
+	}
+}
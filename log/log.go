@@ -52,15 +52,19 @@ type ExternalLogger interface {
 
 // The set of default loggers for each log level.
 var (
-	Debug = &logger{DebugLevel}
-	Info  = &logger{InfoLevel}
-	Error = &logger{ErrorLevel}
+	Debug = &logger{level: DebugLevel}
+	Info  = &logger{level: InfoLevel}
+	Error = &logger{level: ErrorLevel}
 )
 
 type globalState struct {
 	currentLevel  Level
+	levels        map[string]Level // Of subsystems; never modified, only replaced.
 	defaultLogger Logger
 	external      ExternalLogger
+	output        io.Writer   // Where the default loggers write.
+	format        string      // Either "text" or "json".
+	json          *jsonLogger // Replaces defaultLogger if format is "json".
 }
 
 var (
@@ -68,6 +72,8 @@ var (
 	state = globalState{
 		currentLevel:  InfoLevel,
 		defaultLogger: newDefaultLogger(os.Stderr),
+		output:        os.Stderr,
+		format:        "text",
 	}
 )
 
@@ -131,15 +137,27 @@ func SetOutput(w io.Writer) {
 	mu.Lock()
 	defer mu.Unlock()
 
-	if w == nil {
-		state.defaultLogger = nil
-	} else {
-		state.defaultLogger = newDefaultLogger(w)
+	state.setOutput(w)
+}
+
+// setOutput sets the default loggers to write to w in the current
+// format. mu must be held.
+func (g *globalState) setOutput(w io.Writer) {
+	g.output = w
+	g.defaultLogger = nil
+	g.json = nil
+	switch {
+	case w == nil:
+	case g.format == "json":
+		g.json = &jsonLogger{w: w}
+	default:
+		g.defaultLogger = newDefaultLogger(w)
 	}
 }
 
 type logger struct {
 	level Level
+	name  string // Of the subsystem, or empty for the default loggers.
 }
 
 var _ Logger = (*logger)(nil)
@@ -148,13 +166,15 @@ var _ Logger = (*logger)(nil)
 func (l *logger) Printf(format string, v ...interface{}) {
 	g := globals()
 
-	if l.level < g.currentLevel {
+	if l.level < g.levelOf(l.name) {
 		return // Don't log at lower levels.
 	}
 	if g.external != nil {
 		g.external.Log(l.level, fmt.Sprintf(format, v...))
 	}
-	if g.defaultLogger != nil {
+	if g.json != nil {
+		g.json.output(l.level, fmt.Sprintf(format, v...))
+	} else if g.defaultLogger != nil {
 		g.defaultLogger.Printf(format, v...)
 	}
 }
@@ -163,13 +183,15 @@ func (l *logger) Printf(format string, v ...interface{}) {
 func (l *logger) Print(v ...interface{}) {
 	g := globals()
 
-	if l.level < g.currentLevel {
+	if l.level < g.levelOf(l.name) {
 		return // Don't log at lower levels.
 	}
 	if g.external != nil {
 		g.external.Log(l.level, fmt.Sprint(v...))
 	}
-	if g.defaultLogger != nil {
+	if g.json != nil {
+		g.json.output(l.level, fmt.Sprint(v...))
+	} else if g.defaultLogger != nil {
 		g.defaultLogger.Print(v...)
 	}
 }
@@ -178,13 +200,15 @@ func (l *logger) Print(v ...interface{}) {
 func (l *logger) Println(v ...interface{}) {
 	g := globals()
 
-	if l.level < g.currentLevel {
+	if l.level < g.levelOf(l.name) {
 		return // Don't log at lower levels.
 	}
 	if g.external != nil {
 		g.external.Log(l.level, fmt.Sprintln(v...))
 	}
-	if g.defaultLogger != nil {
+	if g.json != nil {
+		g.json.output(l.level, fmt.Sprintln(v...))
+	} else if g.defaultLogger != nil {
 		g.defaultLogger.Println(v...)
 	}
 }
@@ -199,7 +223,10 @@ func (l *logger) Fatal(v ...interface{}) {
 		g.external.Flush()
 		// Fall through to ensure we record it locally too.
 	}
-	if g.defaultLogger != nil {
+	if g.json != nil {
+		g.json.output(l.level, fmt.Sprint(v...))
+		os.Exit(1)
+	} else if g.defaultLogger != nil {
 		g.defaultLogger.Fatal(v...)
 	} else {
 		log.Fatal(v...)
@@ -217,7 +244,10 @@ func (l *logger) Fatalf(format string, v ...interface{}) {
 		g.external.Flush()
 		// Fall through to ensure we record it locally too.
 	}
-	if g.defaultLogger != nil {
+	if g.json != nil {
+		g.json.output(l.level, fmt.Sprintf(format, v...))
+		os.Exit(1)
+	} else if g.defaultLogger != nil {
 		g.defaultLogger.Fatalf(format, v...)
 	} else {
 		log.Fatalf(format, v...)
@@ -0,0 +1,140 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Subsystem holds the loggers of a named part of a program, whose level
+// of logging may be set apart from the global level. By convention the
+// name is the import path of a package without the leading "upspin.io/",
+// such as "dir/server". The level of a subsystem for which none has been
+// set is that of its nearest parent in the hierarchy of names, such as
+// "dir" for "dir/server", or else the global level.
+type Subsystem struct {
+	name string
+
+	// Debug, Info and Error log messages at the respective levels.
+	Debug, Info, Error Logger
+}
+
+// For returns the loggers for the named subsystem.
+// It is typically called once, to initialize a package-level variable.
+func For(name string) *Subsystem {
+	return &Subsystem{
+		name:  name,
+		Debug: &logger{level: DebugLevel, name: name},
+		Info:  &logger{level: InfoLevel, name: name},
+		Error: &logger{level: ErrorLevel, name: name},
+	}
+}
+
+// Printf writes a formatted message to the subsystem's Info log.
+func (s *Subsystem) Printf(format string, v ...interface{}) {
+	s.Info.Printf(format, v...)
+}
+
+// Print writes a message to the subsystem's Info log.
+func (s *Subsystem) Print(v ...interface{}) {
+	s.Info.Print(v...)
+}
+
+// Println writes a line to the subsystem's Info log.
+func (s *Subsystem) Println(v ...interface{}) {
+	s.Info.Println(v...)
+}
+
+// Fatal writes a message to the subsystem's Info log and aborts.
+func (s *Subsystem) Fatal(v ...interface{}) {
+	s.Info.Fatal(v...)
+}
+
+// Fatalf writes a formatted message to the subsystem's Info log and aborts.
+func (s *Subsystem) Fatalf(format string, v ...interface{}) {
+	s.Info.Fatalf(format, v...)
+}
+
+// At returns whether the level will be logged currently by the subsystem.
+func (s *Subsystem) At(level string) bool {
+	g := globals()
+
+	l, err := toLevel(level)
+	if err != nil {
+		return false
+	}
+	return g.levelOf(s.name) <= l
+}
+
+// levelOf returns the level of logging of the named subsystem.
+func (g *globalState) levelOf(name string) Level {
+	for name != "" {
+		if l, ok := g.levels[name]; ok {
+			return l
+		}
+		i := strings.LastIndex(name, "/")
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return g.currentLevel
+}
+
+// SetLevels sets the levels of logging from a comma-separated list of
+// levels. An element of the form name=level sets the level of the named
+// subsystem and its descendants, while a plain level sets the global
+// level. For example, "info,dir/server=debug,rpc=error". The levels of
+// subsystems not named in the list revert to the global level.
+func SetLevels(spec string) error {
+	global := Level(-1)
+	levels := make(map[string]Level)
+	for _, elem := range strings.Split(spec, ",") {
+		elem = strings.TrimSpace(elem)
+		name, level, ok := strings.Cut(elem, "=")
+		if !ok {
+			level, name = name, ""
+		}
+		l, err := toLevel(level)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			global = l
+			continue
+		}
+		name = strings.Trim(name, "/")
+		if name == "" {
+			return fmt.Errorf("empty subsystem name in log level %q", elem)
+		}
+		levels[name] = l
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if global >= 0 {
+		state.currentLevel = global
+	}
+	state.levels = levels
+	return nil
+}
+
+// GetLevels returns the levels of logging in the form accepted by
+// SetLevels, the global level first and then those of the subsystems
+// in alphabetical order.
+func GetLevels() string {
+	g := globals()
+	var names []string
+	for name := range g.levels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	elems := []string{toString(g.currentLevel)}
+	for _, name := range names {
+		elems = append(elems, name+"="+toString(g.levels[name]))
+	}
+	return strings.Join(elems, ",")
+}
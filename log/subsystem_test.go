@@ -0,0 +1,79 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package log
+
+import "testing"
+
+func TestSubsystemLevels(t *testing.T) {
+	defer SetLevels("info")
+
+	const spec = "info,dir/server=debug,rpc=error"
+	if err := SetLevels(spec); err != nil {
+		t.Fatal(err)
+	}
+	if got := GetLevels(); got != spec {
+		t.Errorf("GetLevels() = %q, want %q", got, spec)
+	}
+
+	// Descendants of dir/server log at debug.
+	tree := For("dir/server/tree")
+	setMockLogger("tree debug", false)
+	tree.Debug.Print("tree debug")
+	state.defaultLogger.(*mockLogger).Verify(t)
+	if !tree.At("debug") {
+		t.Error("dir/server/tree not at debug")
+	}
+
+	// rpc logs only errors.
+	rpc := For("rpc")
+	setMockLogger("rpc error", false)
+	rpc.Info.Print("rpc info")
+	rpc.Printf("rpc printf")
+	rpc.Error.Print("rpc error")
+	state.defaultLogger.(*mockLogger).Verify(t)
+
+	// Others, even those sharing a prefix, follow the global level.
+	for _, name := range []string{"store/server", "dir", "rpcx"} {
+		s := For(name)
+		setMockLogger(name+" info", false)
+		s.Debug.Print(name + " debug")
+		s.Info.Print(name + " info")
+		state.defaultLogger.(*mockLogger).Verify(t)
+	}
+
+	// Changing the global level leaves the subsystems alone.
+	if err := SetLevels("error,rpc=error"); err != nil {
+		t.Fatal(err)
+	}
+	if tree.At("debug") {
+		t.Error("dir/server/tree level not reset by SetLevels")
+	}
+	setMockLogger("", false)
+	For("store/server").Info.Print("store info")
+	Info.Print("global info")
+	state.defaultLogger.(*mockLogger).Verify(t)
+}
+
+func TestSetLevelsErrors(t *testing.T) {
+	defer SetLevels("info")
+
+	if err := SetLevels("debug,rpc=error"); err != nil {
+		t.Fatal(err)
+	}
+	for _, spec := range []string{
+		"info,dir/server=loud",
+		"loud",
+		"=debug",
+		"info,/=debug",
+	} {
+		if err := SetLevels(spec); err == nil {
+			t.Errorf("SetLevels(%q) succeeded", spec)
+		}
+	}
+	// A failed SetLevels changes nothing.
+	if got, want := GetLevels(), "debug,rpc=error"; got != want {
+		t.Errorf("GetLevels() = %q, want %q", got, want)
+	}
+}
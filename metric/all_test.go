@@ -5,8 +5,12 @@
 package metric
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"upspin.io/errors"
 )
@@ -75,6 +79,60 @@ func TestFullChannel(t *testing.T) {
 	// If we block, this test will never finish.
 }
 
+func TestOps(t *testing.T) {
+	o := NewOps("testserver")
+	start := time.Now()
+	var err error
+	o.Observe("Lookup", start, &err)
+	o.Observe("Lookup", start, &err)
+	err = errors.E(errors.Op("Lookup"), errors.NotExist)
+	o.Observe("Lookup", start, &err)
+	err = errors.E(errors.Op("Put"), errors.Private)
+	o.Observe("Put", start, &err)
+	err = errors.Str("disk on fire")
+	o.Observe("Put", start, &err)
+
+	for _, c := range []struct {
+		method, outcome string
+		want            int64
+	}{
+		{"Lookup", OK, 2},
+		{"Lookup", NotExist, 1},
+		{"Lookup", Error, 0},
+		{"Put", Permission, 1},
+		{"Put", Error, 1},
+		{"Glob", OK, 0},
+	} {
+		if got := o.Count(c.method, c.outcome); got != c.want {
+			t.Errorf("Count(%q, %q) = %d, want %d", c.method, c.outcome, got, c.want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	OpsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/?format=prometheus", nil))
+	text := w.Body.String()
+	for _, want := range []string{
+		"# TYPE upspin_testserver_requests_total counter\n",
+		`upspin_testserver_requests_total{method="Lookup",outcome="ok"} 2` + "\n",
+		`upspin_testserver_request_duration_seconds_bucket{method="Put",outcome="error",le="+Inf"} 1` + "\n",
+		`upspin_testserver_request_duration_seconds_count{method="Lookup",outcome="not-exist"} 1` + "\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Prometheus output does not contain %q:\n%s", want, text)
+		}
+	}
+
+	w = httptest.NewRecorder()
+	OpsHandler().ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	var all map[string]map[string]map[string]struct{ Count int64 }
+	if err := json.Unmarshal(w.Body.Bytes(), &all); err != nil {
+		t.Fatalf("decoding JSON output: %v\n%s", err, w.Body)
+	}
+	if got := all["testserver"]["Lookup"]["ok"].Count; got != 2 {
+		t.Errorf("JSON count for Lookup ok = %d, want 2", got)
+	}
+}
+
 func verifyMetric(t *testing.T, m *Metric, expectedName errors.Op, expectedSpanNames ...errors.Op) error {
 	if m.Name != expectedName {
 		return fmt.Errorf("Expected %q, got %q", expectedName, m.Name)
@@ -0,0 +1,266 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package metric
+
+import (
+	"bytes"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// Outcomes of an operation, as recorded by Ops.
+const (
+	OK         = "ok"
+	NotExist   = "not-exist"
+	Permission = "permission"
+	Error      = "error"
+)
+
+// Outcome reports the outcome recorded for an operation that returned err.
+func Outcome(err error) string {
+	switch {
+	case err == nil, err == upspin.ErrFollowLink:
+		return OK
+	case errors.Is(errors.NotExist, err):
+		return NotExist
+	case errors.Is(errors.Permission, err), errors.Is(errors.Private, err):
+		return Permission
+	}
+	return Error
+}
+
+// latencyBuckets holds the upper bounds of the latency histogram buckets.
+// A final, implicit bucket holds everything slower.
+var latencyBuckets = [...]time.Duration{
+	1 * time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	500 * time.Millisecond,
+	1 * time.Second,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// Ops counts the operations handled by a server and records their latency,
+// labeled by method and outcome. It deliberately records nothing about the
+// paths or users involved, which would make the number of series unbounded.
+// Ops is an expvar.Var.
+type Ops struct {
+	name string
+
+	mu    sync.Mutex // protects stats
+	stats map[opKey]*opStats
+}
+
+type opKey struct {
+	method, outcome string
+}
+
+type opStats struct {
+	count   int64
+	sum     time.Duration
+	buckets [len(latencyBuckets) + 1]int64 // not cumulative
+}
+
+var (
+	opsMu sync.Mutex
+	ops   = make(map[string]*Ops)
+)
+
+// NewOps returns a new, registered Ops with the given name, which should
+// identify the server, such as "dirserver". It also publishes the Ops as
+// the expvar name+"-ops". It panics if the name is already in use.
+func NewOps(name string) *Ops {
+	opsMu.Lock()
+	defer opsMu.Unlock()
+	if _, ok := ops[name]; ok {
+		panic("metric: ops " + name + " already registered")
+	}
+	o := &Ops{
+		name:  name,
+		stats: make(map[opKey]*opStats),
+	}
+	ops[name] = o
+	expvar.Publish(name+"-ops", o)
+	return o
+}
+
+// Observe records a call of the named method that began at start and is
+// now complete, with the outcome given by the error *errp. It is designed
+// to be deferred by a method with a named error result:
+//
+//	defer ops.Observe("Lookup", time.Now(), &err)
+func (o *Ops) Observe(method string, start time.Time, errp *error) {
+	d := time.Since(start)
+	k := opKey{method, Outcome(*errp)}
+	b := len(latencyBuckets)
+	for i, max := range latencyBuckets {
+		if d <= max {
+			b = i
+			break
+		}
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	st := o.stats[k]
+	if st == nil {
+		st = new(opStats)
+		o.stats[k] = st
+	}
+	st.count++
+	st.sum += d
+	st.buckets[b]++
+}
+
+// Count returns the number of calls of the method with the given outcome.
+func (o *Ops) Count(method, outcome string) int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if st := o.stats[opKey{method, outcome}]; st != nil {
+		return st.count
+	}
+	return 0
+}
+
+// snapshot returns a copy of the recorded statistics, sorted by method and
+// outcome.
+func (o *Ops) snapshot() ([]opKey, []opStats) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	keys := make([]opKey, 0, len(o.stats))
+	for k := range o.stats {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].outcome < keys[j].outcome
+	})
+	stats := make([]opStats, len(keys))
+	for i, k := range keys {
+		stats[i] = *o.stats[k]
+	}
+	return keys, stats
+}
+
+// String implements expvar.Var. It returns the statistics as a JSON object
+// keyed by method and then outcome.
+func (o *Ops) String() string {
+	type jsonStats struct {
+		Count   int64
+		Seconds float64
+		// Buckets holds the cumulative count of calls that took at
+		// most the number of seconds given by the key.
+		Buckets map[string]int64
+	}
+	out := make(map[string]map[string]jsonStats)
+	keys, stats := o.snapshot()
+	for i, k := range keys {
+		st := stats[i]
+		js := jsonStats{
+			Count:   st.count,
+			Seconds: st.sum.Seconds(),
+			Buckets: make(map[string]int64),
+		}
+		var n int64
+		for b, c := range st.buckets {
+			n += c
+			js.Buckets[bucketLabel(b)] = n
+		}
+		if out[k.method] == nil {
+			out[k.method] = make(map[string]jsonStats)
+		}
+		out[k.method][k.outcome] = js
+	}
+	b, err := json.Marshal(out)
+	if err != nil {
+		// Cannot happen.
+		return "{}"
+	}
+	return string(b)
+}
+
+// writeText writes the statistics in the Prometheus text exposition format.
+func (o *Ops) writeText(w io.Writer) {
+	keys, stats := o.snapshot()
+	prefix := "upspin_" + o.name
+	fmt.Fprintf(w, "# HELP %s_requests_total Requests handled, by method and outcome.\n", prefix)
+	fmt.Fprintf(w, "# TYPE %s_requests_total counter\n", prefix)
+	for i, k := range keys {
+		fmt.Fprintf(w, "%s_requests_total{method=%q,outcome=%q} %d\n", prefix, k.method, k.outcome, stats[i].count)
+	}
+	fmt.Fprintf(w, "# HELP %s_request_duration_seconds Request latency, by method and outcome.\n", prefix)
+	fmt.Fprintf(w, "# TYPE %s_request_duration_seconds histogram\n", prefix)
+	for i, k := range keys {
+		st := stats[i]
+		var n int64
+		for b, c := range st.buckets {
+			n += c
+			fmt.Fprintf(w, "%s_request_duration_seconds_bucket{method=%q,outcome=%q,le=%q} %d\n", prefix, k.method, k.outcome, bucketLabel(b), n)
+		}
+		fmt.Fprintf(w, "%s_request_duration_seconds_sum{method=%q,outcome=%q} %g\n", prefix, k.method, k.outcome, st.sum.Seconds())
+		fmt.Fprintf(w, "%s_request_duration_seconds_count{method=%q,outcome=%q} %d\n", prefix, k.method, k.outcome, st.count)
+	}
+}
+
+// bucketLabel returns the upper bound of latency bucket b, in seconds.
+func bucketLabel(b int) string {
+	if b == len(latencyBuckets) {
+		return "+Inf"
+	}
+	return fmt.Sprint(latencyBuckets[b].Seconds())
+}
+
+// registeredOps returns the registered Ops, sorted by name.
+func registeredOps() []*Ops {
+	opsMu.Lock()
+	defer opsMu.Unlock()
+	var list []*Ops
+	for _, o := range ops {
+		list = append(list, o)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].name < list[j].name })
+	return list
+}
+
+// OpsHandler returns an http.Handler that serves the statistics of all
+// registered Ops as a JSON object keyed by name or, if the request has the
+// form value format=prometheus, in the Prometheus text exposition format.
+// The handler does no access control of its own.
+func OpsHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var buf bytes.Buffer
+		if r.FormValue("format") == "prometheus" {
+			w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+			for _, o := range registeredOps() {
+				o.writeText(&buf)
+			}
+		} else {
+			w.Header().Set("Content-Type", "application/json")
+			buf.WriteString("{")
+			for i, o := range registeredOps() {
+				if i > 0 {
+					buf.WriteString(",")
+				}
+				fmt.Fprintf(&buf, "\n%q: %s", o.name, o)
+			}
+			buf.WriteString("\n}\n")
+		}
+		w.Write(buf.Bytes())
+	})
+}
@@ -37,7 +37,10 @@ import (
 
 type keyHashArray [sha256.Size]byte // sometimes we need the array
 
-var _ upspin.Packer = ee{}
+var (
+	_ pack.KeyedPacker      = ee{}
+	_ pack.KeyedBlockPacker = (*blockPacker)(nil)
+)
 
 type ee struct{}
 
@@ -109,6 +112,41 @@ func (ee ee) Pack(cfg upspin.Config, d *upspin.DirEntry) (upspin.BlockPacker, er
 	}, nil
 }
 
+// PackWithKey implements pack.KeyedPacker.
+func (ee ee) PackWithKey(cfg upspin.Config, d *upspin.DirEntry, wrapped []byte) (upspin.BlockPacker, error) {
+	const op errors.Op = "pack/ee.PackWithKey"
+	if err := pack.CheckPacking(ee, d); err != nil {
+		return nil, errors.E(op, errors.Invalid, d.Name, err)
+	}
+	if len(d.SignedName) == 0 {
+		return nil, errors.E(op, errors.Invalid, d.Name, errSignedNameNotSet)
+	}
+	if len(wrapped) == 0 || len(wrapped) > wrappedKeyLen {
+		return nil, errors.E(op, errors.Invalid, d.Name, "malformed wrapped key")
+	}
+	var w wrappedKey
+	getWrappedKey(&w, wrapped)
+	dkey, err := aesUnwrap(cfg.Factotum(), w)
+	if err != nil {
+		return nil, errors.E(op, d.Name, err)
+	}
+	if len(dkey) != aesKeyLen {
+		return nil, errors.E(op, d.Name, errKeyLength)
+	}
+	blockCipher, err := aes.NewCipher(dkey)
+	if err != nil {
+		return nil, errors.E(op, d.Name, err)
+	}
+
+	d.Blocks = nil
+	return &blockPacker{
+		cfg:    cfg,
+		entry:  d,
+		cipher: blockCipher,
+		dkey:   dkey,
+	}, nil
+}
+
 func newKeyAndCipher() ([]byte, cipher.Block, error) {
 	// Pick fresh file encryption key.
 	dkey := make([]byte, aesKeyLen)
@@ -174,6 +212,96 @@ func (bp *blockPacker) Pack(cleartext []byte) (ciphertext []byte, err error) {
 	return ciphertext, nil
 }
 
+// streamChunkSize is the amount of data EncryptStream and DecryptStream
+// hold in memory at once.
+const streamChunkSize = 64 * 1024
+
+// A StreamBlockPacker is a BlockPacker that can also encrypt a block
+// incrementally, without holding the whole block in memory.
+// The BlockPacker returned by the ee Packer implements it.
+type StreamBlockPacker interface {
+	upspin.BlockPacker
+
+	// EncryptStream reads the cleartext of the next block from in
+	// until EOF and writes the ciphertext to out, a chunk at a time.
+	// It records the block in the DirEntry as Pack does, and the
+	// ciphertext is identical to that produced by Pack. As with Pack,
+	// SetLocation must be called before the next block is packed.
+	EncryptStream(in io.Reader, out io.Writer) error
+}
+
+// A StreamBlockUnpacker is a BlockUnpacker that can also decrypt a block
+// incrementally, without holding the whole block in memory.
+// The BlockUnpacker returned by the ee Packer implements it.
+type StreamBlockUnpacker interface {
+	upspin.BlockUnpacker
+
+	// DecryptStream reads the ciphertext of the current block from in
+	// until EOF and writes the cleartext to out, a chunk at a time.
+	// The block's checksum can only be validated once all of the
+	// ciphertext has been read, so if DecryptStream returns an error
+	// the caller must discard everything written to out.
+	DecryptStream(in io.Reader, out io.Writer) error
+}
+
+func (bp *blockPacker) EncryptStream(in io.Reader, out io.Writer) error {
+	const op errors.Op = "pack/ee.blockPacker.EncryptStream"
+	if err := internal.CheckLocationSet(bp.entry); err != nil {
+		return err
+	}
+
+	offs, err := bp.entry.Size()
+	if err != nil {
+		return errors.E(op, errors.Invalid, err)
+	}
+
+	stream := newCTR(bp.cipher, offs)
+	hash := sha256.New()
+	buf := make([]byte, streamChunkSize)
+	var size int64
+	for {
+		n, err := io.ReadFull(in, buf)
+		if n > 0 {
+			chunk := buf[:n]
+			stream.XORKeyStream(chunk, chunk)
+			hash.Write(chunk)
+			if _, err := out.Write(chunk); err != nil {
+				return errors.E(op, errors.IO, err)
+			}
+			size += int64(n)
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return errors.E(op, errors.IO, err)
+		}
+	}
+
+	bp.entry.Blocks = append(bp.entry.Blocks, upspin.DirBlock{
+		Size:     size,
+		Offset:   offs,
+		Packdata: hash.Sum(nil),
+	})
+	return nil
+}
+
+// WrappedKey implements pack.KeyedBlockPacker.
+func (bp *blockPacker) WrappedKey() ([]byte, error) {
+	const op errors.Op = "pack/ee.blockPacker.WrappedKey"
+	rp := bp.cfg.Factotum().PublicKey()
+	p, err := factotum.ParsePublicKey(rp)
+	if err != nil {
+		return nil, errors.E(op, bp.entry.Name, err)
+	}
+	w, err := gcmWrap(rp, p, bp.dkey)
+	if err != nil {
+		return nil, errors.E(op, bp.entry.Name, err)
+	}
+	b := make([]byte, wrappedKeyLen)
+	return b[:putWrappedKey(b, w)], nil
+}
+
 func (bp *blockPacker) SetLocation(l upspin.Location) {
 	bs := bp.entry.Blocks
 	bs[len(bs)-1].Location = l
@@ -243,7 +371,7 @@ func (bp *blockPacker) Close() error {
 	// Compute entry signature.
 	f := bp.cfg.Factotum()
 	e := bp.entry
-	pd.sig, err = f.FileSign(f.DirEntryHash(e.SignedName, e.Link, e.Attr, e.Packing, e.Time, bp.dkey, pd.blockSum))
+	pd.sig, err = f.FileSign(f.DirEntryHash(e.SignedName, e.Link, e.Attr, e.Packing, e.Time, bp.dkey, internal.AttrSum(e, pd.blockSum)))
 	if err != nil {
 		return errors.E(op, err)
 	}
@@ -286,49 +414,84 @@ func (ee ee) Unpack(cfg upspin.Config, d *upspin.DirEntry) (upspin.BlockUnpacker
 	}
 
 	// Pull the decryption key out of the wrapped keys.
-	// For quick lookup, hash my public key and locate my wrapped key in the metadata.
+	// For quick lookup, hash my public keys and locate my wrapped key in the metadata.
 	me := cfg.UserName()
 	f := cfg.Factotum()
-	rhash := factotum.KeyHash(f.PublicKey())
-	for _, w := range pd.wrap {
-		all := bytes.Equal(factotum.AllUsersKeyHash, w.keyHash)
-		if !all && !bytes.Equal(rhash, w.keyHash) {
-			continue
+	w, ok := findWrap(pd.wrap, f)
+	if !ok {
+		return nil, errors.E(op, errors.CannotDecrypt, d.Name, me)
+	}
+	var dkey []byte
+	if bytes.Equal(factotum.AllUsersKeyHash, w.keyHash) {
+		dkey = w.dkey
+	} else {
+		// Decode my wrapped key using my private key.
+		dkey, err = aesUnwrap(f, w)
+		if err != nil {
+			return nil, errors.E(op, d.Name, me, err)
 		}
-		var dkey []byte
-		if all {
-			dkey = w.dkey
-		} else {
-			// Decode my wrapped key using my private key.
-			dkey, err = aesUnwrap(f, w)
-			if err != nil {
-				return nil, errors.E(op, d.Name, me, err)
+	}
+	if len(dkey) != aesKeyLen {
+		return nil, errors.E(op, d.Name, errKeyLength)
+	}
+	// Verify that this was signed with the writer's old or new public key.
+	// If the reader is the writer, the file may instead have been signed
+	// by one of our own older keys.
+	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, internal.AttrSum(d, pd.blockSum))
+	if !verifySig(writerPubKey, vhash, pd) && (writer != me || !verifyPrior(f, vhash, pd)) {
+		return nil, errors.E(op, d.Name, writer, errVerify)
+	}
+	blockCipher, err := aes.NewCipher(dkey)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	// We're OK to start decrypting blocks.
+	return &blockUnpacker{
+		cfg:          cfg,
+		entry:        d,
+		BlockTracker: internal.NewBlockTracker(d.Blocks),
+		cipher:       blockCipher,
+	}, nil
+}
+
+// findWrap returns the wrapped key in wrap that f can decode. It prefers
+// a key wrapped for all users or for f's current key, then tries f's
+// prior keys, newest first.
+func findWrap(wrap []wrappedKey, f upspin.Factotum) (wrappedKey, bool) {
+	for i, pub := range factotum.Keys(f) {
+		rhash := factotum.KeyHash(pub)
+		for _, w := range wrap {
+			if bytes.Equal(rhash, w.keyHash) {
+				return w, true
+			}
+			if i == 0 && bytes.Equal(factotum.AllUsersKeyHash, w.keyHash) {
+				return w, true
 			}
 		}
-		if len(dkey) != aesKeyLen {
-			return nil, errors.E(op, d.Name, errKeyLength)
-		}
-		// Verify that this was signed with the writer's old or new public key.
-		vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, pd.blockSum)
-		if !ecdsa.Verify(writerPubKey, vhash, pd.sig.R, pd.sig.S) &&
-			!ecdsa.Verify(writerPubKey, vhash, pd.sig2.R, pd.sig2.S) {
-			// Check sig2 in case writerPubKey is rotating.
-			return nil, errors.E(op, d.Name, writer, errVerify)
-			// TODO(ehg) If reader is owner, consider trying even older factotum keys.
-		}
-		blockCipher, err := aes.NewCipher(dkey)
+	}
+	return wrappedKey{}, false
+}
+
+// verifySig reports whether either signature in pd is a valid signature
+// of vhash by key. The second signature is checked in case key is rotating.
+func verifySig(key *ecdsa.PublicKey, vhash upspin.DEHash, pd packdata) bool {
+	return ecdsa.Verify(key, vhash, pd.sig.R, pd.sig.S) ||
+		ecdsa.Verify(key, vhash, pd.sig2.R, pd.sig2.S)
+}
+
+// verifyPrior reports whether either signature in pd is a valid signature
+// of vhash by one of the prior keys held by f.
+func verifyPrior(f upspin.Factotum, vhash upspin.DEHash, pd packdata) bool {
+	for _, pub := range factotum.Keys(f)[1:] {
+		key, err := factotum.ParsePublicKey(pub)
 		if err != nil {
-			return nil, errors.E(op, err)
+			continue
+		}
+		if verifySig(key, vhash, pd) {
+			return true
 		}
-		// We're OK to start decrypting blocks.
-		return &blockUnpacker{
-			cfg:          cfg,
-			entry:        d,
-			BlockTracker: internal.NewBlockTracker(d.Blocks),
-			cipher:       blockCipher,
-		}, nil
 	}
-	return nil, errors.E(op, errors.CannotDecrypt, d.Name, me)
+	return false
 }
 
 type blockUnpacker struct {
@@ -359,6 +522,54 @@ func (bp *blockUnpacker) Unpack(ciphertext []byte) (cleartext []byte, err error)
 	return cleartext, nil
 }
 
+func (bp *blockUnpacker) UnpackZero() (cleartext []byte, err error) {
+	const op errors.Op = "pack/ee.blockUnpacker.UnpackZero"
+	block := bp.entry.Blocks[bp.Block]
+	cleartext = bp.buf.Bytes(int(block.Size))
+	zeroSlice(&cleartext)
+
+	// Validate checksum. The ciphertext of zeros is the key stream.
+	ciphertext := make([]byte, len(cleartext))
+	if err := crypt(ciphertext, cleartext, bp.cipher, block.Offset); err != nil {
+		return nil, errors.E(op, bp.entry.Name, err)
+	}
+	b := sha256.Sum256(ciphertext)
+	if got, want := b[:], block.Packdata; !bytes.Equal(got, want) {
+		return nil, errors.E(op, bp.entry.Name, "checksum mismatch")
+	}
+
+	return cleartext, nil
+}
+
+func (bp *blockUnpacker) DecryptStream(in io.Reader, out io.Writer) error {
+	const op errors.Op = "pack/ee.blockUnpacker.DecryptStream"
+	block := bp.entry.Blocks[bp.Block]
+	stream := newCTR(bp.cipher, block.Offset)
+	hash := sha256.New()
+	buf := make([]byte, streamChunkSize)
+	for {
+		n, err := io.ReadFull(in, buf)
+		if n > 0 {
+			chunk := buf[:n]
+			hash.Write(chunk)
+			stream.XORKeyStream(chunk, chunk)
+			if _, err := out.Write(chunk); err != nil {
+				return errors.E(op, bp.entry.Name, errors.IO, err)
+			}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return errors.E(op, bp.entry.Name, errors.IO, err)
+		}
+	}
+	if !bytes.Equal(hash.Sum(nil), block.Packdata) {
+		return errors.E(op, bp.entry.Name, "checksum mismatch")
+	}
+	return nil
+}
+
 func (bp *blockUnpacker) Close() error {
 	return nil
 }
@@ -557,7 +768,7 @@ func (ee ee) updateDirEntry(op errors.Op, cfg upspin.Config, d *upspin.DirEntry,
 	}
 
 	// Verify that this was signed with the writer's old or new public key.
-	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, pd.blockSum)
+	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, internal.AttrSum(d, pd.blockSum))
 	if !ecdsa.Verify(writerPubKey, vhash, pd.sig.R, pd.sig.S) &&
 		!ecdsa.Verify(writerPubKey, vhash, pd.sig2.R, pd.sig2.S) {
 		// Check sig2 in case writerPubKey is rotating.
@@ -573,7 +784,7 @@ func (ee ee) updateDirEntry(op errors.Op, cfg upspin.Config, d *upspin.DirEntry,
 	d.Writer = cfg.UserName()
 	d.SignedName = newName
 	d.Time = newTime
-	vhash = f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, pd.blockSum)
+	vhash = f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, internal.AttrSum(d, pd.blockSum))
 	pd.sig, err = f.FileSign(vhash)
 	if err != nil {
 		return errors.E(op, d.Name, err)
@@ -627,8 +838,13 @@ func (ee ee) Countersign(oldKey upspin.PublicKey, f upspin.Factotum, d *upspin.D
 	}
 
 	// Verify existing signature with oldKey.
-	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, pd.blockSum)
+	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, internal.AttrSum(d, pd.blockSum))
 	if !ecdsa.Verify(oldPubKey, vhash, pd.sig.R, pd.sig.S) {
+		// If the entry is already signed with the new key there is
+		// nothing to do, which makes countersigning safe to repeat.
+		if newPubKey, err := factotum.ParsePublicKey(f.PublicKey()); err == nil && ecdsa.Verify(newPubKey, vhash, pd.sig.R, pd.sig.S) {
+			return nil
+		}
 		return errors.E(op, d.Name, errVerify, "unable to verify existing signature")
 	}
 
@@ -771,11 +987,19 @@ func zeroSlice(b *[]byte) {
 // crypt [enc|de]crypts the input bytes into the output slice
 // with the provided key for the given DirBlock.
 func crypt(out, in []byte, blockCipher cipher.Block, offset int64) error {
+	newCTR(blockCipher, offset).XORKeyStream(out, in)
+	return nil
+}
+
+// newCTR returns the key stream for the data at the given offset
+// in the file.
+func newCTR(blockCipher cipher.Block, offset int64) cipher.Stream {
 	const streamBufferSize = 512  // as defined in $GOROOT/src/crypto/cipher/ctr.go
 	bs := blockCipher.BlockSize() // 16 bytes in practice
 
 	// We start with a zero iv because we're certain that the
-	// encryption key is random and not reused anywhere.
+	// encryption key is random and not reused anywhere, except
+	// by PackWithKey for the same cleartext.
 	iv := make([]byte, bs)
 
 	// Set the initialization vector to whatever it was at the start of the
@@ -799,8 +1023,5 @@ func crypt(out, in []byte, blockCipher cipher.Block, offset int64) error {
 		ctr.XORKeyStream(ignore, ignore)
 	}
 
-	// Encrypt the block.
-	ctr.XORKeyStream(out, in)
-
-	return nil
+	return ctr
 }
@@ -9,6 +9,7 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"fmt"
+	"io"
 	"log"
 	"strings"
 	"testing"
@@ -17,6 +18,7 @@ import (
 	"upspin.io/config"
 	"upspin.io/errors"
 	"upspin.io/factotum"
+	"upspin.io/key/keygen"
 	"upspin.io/pack"
 	"upspin.io/pack/ee"
 	"upspin.io/pack/internal/packtest"
@@ -421,6 +423,12 @@ func TestMultiBlockRoundTrip(t *testing.T) {
 	packtest.TestMultiBlockRoundTrip(t, cfg, packer, userName)
 }
 
+func TestAttributes(t *testing.T) {
+	const userName = upspin.UserName("aly@upspin.io")
+	cfg, packer := setup(userName)
+	packtest.TestAttributes(t, cfg, packer, userName)
+}
+
 func TestConsistentKeyStream(t *testing.T) {
 	// This test that the EE packer with different block sizes still
 	// generates the same ciphertext when all blocks are concatenated.
@@ -529,6 +537,157 @@ func TestConsistentKeyStream(t *testing.T) {
 	}
 }
 
+func TestStream(t *testing.T) {
+	// Streamed blocks must be interchangeable with those from Pack.
+	const (
+		user upspin.UserName = "joe@upspin.io"
+		name                 = upspin.PathName(user + "/file/of/user")
+		bs                   = 92341 // Not a multiple of the chunk size.
+	)
+
+	cfg, packer := setup(user)
+	de := &upspin.DirEntry{
+		Name:       name,
+		SignedName: name,
+		Writer:     cfg.UserName(),
+		Packing:    packer.Packing(),
+	}
+
+	data := make([]byte, 3*bs+17)
+	if _, err := rand.Read(data); err != nil {
+		t.Fatal(err)
+	}
+	dkey, blockCipher, err := ee.NewKeyAndCipher()
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantCipherText := make([]byte, len(data))
+	iv := make([]byte, blockCipher.BlockSize()) // zero
+	cipher.NewCTR(blockCipher, iv).XORKeyStream(wantCipherText, data)
+
+	bp, err := packer.Pack(cfg, de)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ee.SetblockPacker(bp, append([]byte(nil), dkey...), blockCipher)
+	sp := bp.(ee.StreamBlockPacker)
+	var gotCipherText bytes.Buffer
+	for i := 0; i < len(data); i += bs {
+		clear := data[i:]
+		if len(clear) > bs {
+			clear = clear[:bs]
+		}
+		if err := sp.EncryptStream(bytes.NewReader(clear), &gotCipherText); err != nil {
+			t.Fatal(err)
+		}
+		sp.SetLocation(upspin.Location{Reference: "dummy"})
+	}
+	if err := sp.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotCipherText.Bytes(), wantCipherText) {
+		t.Fatal("streamed cipherText did not match")
+	}
+
+	// Decrypt alternate blocks with Unpack and DecryptStream.
+	bu, err := packer.Unpack(cfg, de)
+	if err != nil {
+		t.Fatal(err)
+	}
+	su := bu.(ee.StreamBlockUnpacker)
+	var got bytes.Buffer
+	for i := 0; i < len(data); i += bs {
+		if _, ok := su.NextBlock(); !ok {
+			t.Fatal("expected next block, didn't find one")
+		}
+		ciphertext := wantCipherText[i:]
+		if len(ciphertext) > bs {
+			ciphertext = ciphertext[:bs]
+		}
+		if i/bs%2 == 0 {
+			if err := su.DecryptStream(bytes.NewReader(ciphertext), &got); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+		clear, err := su.Unpack(ciphertext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got.Write(clear)
+	}
+	if !bytes.Equal(got.Bytes(), data) {
+		t.Fatal("streamed cleartext does not match input")
+	}
+
+	// A corrupted block must be detected.
+	bu, err = packer.Unpack(cfg, de)
+	if err != nil {
+		t.Fatal(err)
+	}
+	su = bu.(ee.StreamBlockUnpacker)
+	su.NextBlock()
+	bad := append([]byte(nil), wantCipherText[:bs]...)
+	bad[bs/2] ^= 1
+	err = su.DecryptStream(bytes.NewReader(bad), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Fatalf("DecryptStream of corrupt block: got error %v, want checksum mismatch", err)
+	}
+}
+
+func TestPackWithKey(t *testing.T) {
+	const (
+		user upspin.UserName = "joe@upspin.io"
+		name                 = upspin.PathName(user + "/file/of/user")
+	)
+	cfg, packer := setup(user)
+	text := []byte("the same text, packed twice")
+
+	de := &upspin.DirEntry{Name: name, SignedName: name, Writer: user, Packing: packer.Packing()}
+	bp, err := packer.Pack(cfg, de)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := bp.(pack.KeyedBlockPacker).WrappedKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher, err := bp.Pack(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher = append([]byte(nil), cipher...)
+
+	// Packing again with the key gives the same ciphertext,
+	// and an entry that unpacks it.
+	de2 := &upspin.DirEntry{Name: name, SignedName: name, Writer: user, Packing: packer.Packing()}
+	bp2, err := packer.(pack.KeyedPacker).PackWithKey(cfg, de2, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cipher2, err := bp2.Pack(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cipher, cipher2) {
+		t.Fatal("ciphertext packed with the same key differs")
+	}
+	bp2.SetLocation(upspin.Location{Reference: "dummy"})
+	if err := bp2.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := unpackBlob(t, cfg, packer, de2, cipher2); !bytes.Equal(got, text) {
+		t.Errorf("unpacked %q, want %q", got, text)
+	}
+
+	// The key is useless to others.
+	otherCfg, _ := setup("aly@upspin.io")
+	de3 := &upspin.DirEntry{Name: name, SignedName: name, Writer: user, Packing: packer.Packing()}
+	if _, err := packer.(pack.KeyedPacker).PackWithKey(otherCfg, de3, key); err == nil {
+		t.Error("PackWithKey with another user's key succeeded")
+	}
+}
+
 func TestAllReaders(t *testing.T) {
 	const (
 		userName  = upspin.UserName("joe@upspin.io")
@@ -594,3 +753,81 @@ func TestAllReaders(t *testing.T) {
 		t.Errorf("content unpacked as %q, want %q", got, want)
 	}
 }
+
+func TestUnpackAfterRotations(t *testing.T) {
+	const (
+		userName upspin.UserName = "rot@upspin.io"
+		pathName                 = upspin.PathName(userName + "/old_file")
+		text                     = "packed long ago with my first key"
+	)
+	dir := t.TempDir()
+
+	// newConfig emulates "upspin keygen", or "upspin keygen -rotate" if
+	// rotate is set, and returns a config using the resulting keys.
+	newConfig := func(rotate bool) upspin.Config {
+		public, private, secret, err := keygen.Generate("p256")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := keygen.SaveKeys(dir, rotate, public, private, secret); err != nil {
+			t.Fatal(err)
+		}
+		f, err := factotum.NewFromDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg := config.SetUserName(config.New(), userName)
+		cfg = config.SetFactotum(cfg, f)
+		cfg = config.SetKeyEndpoint(cfg, upspin.Endpoint{Transport: upspin.InProcess})
+		bind.RegisterKeyServer(upspin.InProcess, &dummyKey{
+			userToMatch: []upspin.UserName{userName},
+			keyToReturn: []upspin.PublicKey{f.PublicKey()},
+		})
+		return cfg
+	}
+
+	cfg := newConfig(false)
+	first := cfg.Factotum().PublicKey()
+	packer := pack.Lookup(packing)
+	d := &upspin.DirEntry{
+		Name:       pathName,
+		SignedName: pathName,
+		Writer:     userName,
+	}
+	cipher := packBlob(t, cfg, packer, d, []byte(text))
+
+	for i := 0; i < 3; i++ {
+		cfg = newConfig(true)
+	}
+	keys := factotum.Keys(cfg.Factotum())
+	if len(keys) != 4 {
+		t.Fatalf("got %d keys after three rotations, want 4", len(keys))
+	}
+	if keys[0] != cfg.Factotum().PublicKey() {
+		t.Errorf("first key is not the current key")
+	}
+	if keys[3] != first {
+		t.Errorf("last key is not the original key")
+	}
+
+	// The file is still wrapped and signed only for the first key.
+	clear := unpackBlob(t, cfg, packer, d, cipher)
+	if string(clear) != text {
+		t.Errorf("Expected %q, got %q", text, clear)
+	}
+
+	// Countersign must find the first key, which is the oldest.
+	var err error
+	for _, old := range keys[1:] {
+		if err = packer.Countersign(old, cfg.Factotum(), d); err == nil {
+			break
+		}
+	}
+	if err != nil {
+		t.Fatalf("Countersign with prior keys: %v", err)
+	}
+	clear = unpackBlob(t, cfg, packer, d, cipher)
+	if string(clear) != text {
+		t.Errorf("after countersign: expected %q, got %q", text, clear)
+	}
+}
@@ -64,19 +64,7 @@ func (pd *packdata) Marshal(dst *[]byte) error {
 	// wrap
 	n += binary.PutVarint((*dst)[n:], int64(len(pd.wrap)))
 	for _, w := range pd.wrap {
-		n += packutil.PutBytes((*dst)[n:], w.keyHash)
-		n += packutil.PutBytes((*dst)[n:], w.dkey)
-		n += packutil.PutBytes((*dst)[n:], w.nonce)
-		if w.ephemeral.X != nil {
-			n += packutil.PutBytes((*dst)[n:], w.ephemeral.X.Bytes())
-		} else {
-			n += packutil.PutBytes((*dst)[n:], nil)
-		}
-		if w.ephemeral.Y != nil {
-			n += packutil.PutBytes((*dst)[n:], w.ephemeral.Y.Bytes())
-		} else {
-			n += packutil.PutBytes((*dst)[n:], nil)
-		}
+		n += putWrappedKey((*dst)[n:], w)
 	}
 
 	// blockSum
@@ -121,24 +109,7 @@ func (pd *packdata) Unmarshal(b []byte) error {
 	pd.wrap = make([]wrappedKey, nwrap)
 	for i := 0; i < nwrap; i++ {
 		var w wrappedKey
-		w.keyHash = make([]byte, sha256.Size)
-		w.dkey = make([]byte, aesKeyLen+gcmTagSize)
-		w.nonce = make([]byte, gcmStandardNonceSize)
-		w.ephemeral = ecdsa.PublicKey{X: big.NewInt(0), Y: big.NewInt(0)}
-		n += packutil.GetBytes(&w.keyHash, b[n:])
-		n += packutil.GetBytes(&w.dkey, b[n:])
-		n += packutil.GetBytes(&w.nonce, b[n:])
-		n += packutil.GetBytes(&buf, b[n:])
-		w.ephemeral.X.SetBytes(buf)
-		n += packutil.GetBytes(&buf, b[n:])
-		w.ephemeral.Y.SetBytes(buf)
-		if w.ephemeral.Y.BitLen() > 393 {
-			w.ephemeral.Curve = elliptic.P521()
-		} else if w.ephemeral.Y.BitLen() > 265 {
-			w.ephemeral.Curve = elliptic.P384()
-		} else {
-			w.ephemeral.Curve = elliptic.P256()
-		}
+		n += getWrappedKey(&w, b[n:])
 		pd.wrap[i] = w
 	}
 
@@ -152,20 +123,63 @@ func (pd *packdata) Unmarshal(b []byte) error {
 	return nil
 }
 
+// putWrappedKey stores the binary encoding of w in dst, which must be long
+// enough, and returns the number of bytes written.
+func putWrappedKey(dst []byte, w wrappedKey) int {
+	n := 0
+	n += packutil.PutBytes(dst[n:], w.keyHash)
+	n += packutil.PutBytes(dst[n:], w.dkey)
+	n += packutil.PutBytes(dst[n:], w.nonce)
+	if w.ephemeral.X != nil {
+		n += packutil.PutBytes(dst[n:], w.ephemeral.X.Bytes())
+	} else {
+		n += packutil.PutBytes(dst[n:], nil)
+	}
+	if w.ephemeral.Y != nil {
+		n += packutil.PutBytes(dst[n:], w.ephemeral.Y.Bytes())
+	} else {
+		n += packutil.PutBytes(dst[n:], nil)
+	}
+	return n
+}
+
+// getWrappedKey parses the wrapped key encoded at the start of b into w
+// and returns the number of bytes read.
+func getWrappedKey(w *wrappedKey, b []byte) int {
+	n := 0
+	buf := make([]byte, marshalBufLen)
+	w.keyHash = make([]byte, sha256.Size)
+	w.dkey = make([]byte, aesKeyLen+gcmTagSize)
+	w.nonce = make([]byte, gcmStandardNonceSize)
+	w.ephemeral = ecdsa.PublicKey{X: big.NewInt(0), Y: big.NewInt(0)}
+	n += packutil.GetBytes(&w.keyHash, b[n:])
+	n += packutil.GetBytes(&w.dkey, b[n:])
+	n += packutil.GetBytes(&w.nonce, b[n:])
+	n += packutil.GetBytes(&buf, b[n:])
+	w.ephemeral.X.SetBytes(buf)
+	n += packutil.GetBytes(&buf, b[n:])
+	w.ephemeral.Y.SetBytes(buf)
+	if w.ephemeral.Y.BitLen() > 393 {
+		w.ephemeral.Curve = elliptic.P521()
+	} else if w.ephemeral.Y.BitLen() > 265 {
+		w.ephemeral.Curve = elliptic.P384()
+	} else {
+		w.ephemeral.Curve = elliptic.P256()
+	}
+	return n
+}
+
+// wrappedKeyLen is the maximum length of an encoded wrappedKey.
+const wrappedKeyLen = 5*binary.MaxVarintLen64 + sha256.Size + aesKeyLen + gcmTagSize + gcmStandardNonceSize + 2*marshalBufLen
+
 // packdataLen returns the maximum length of a packdata slice for the given
 // number of wrapped keys.
 func packdataLen(nwrap int) int {
 	intLen := binary.MaxVarintLen64
 
-	// nWrappedKey is the size of a single encoded wrappedKey
-	nWrappedKey := intLen + sha256.Size            // keyHash
-	nWrappedKey += intLen + aesKeyLen + gcmTagSize // dkey
-	nWrappedKey += intLen + gcmStandardNonceSize   // nonce
-	nWrappedKey += 2 * (intLen + marshalBufLen)    // ephemeral
-
 	n := 4 * (intLen + marshalBufLen) // (R,S) for (sig, sig2)
 	n += intLen                       // len(wrap)
-	n += nwrap * nWrappedKey
+	n += nwrap * wrappedKeyLen
 	n += intLen + sha256.Size // blockSum
 
 	// n is commonly an overestimate since the big.Int used in p256 are
@@ -145,7 +145,7 @@ func (bp *blockPacker) Close() error {
 	f := bp.cfg.Factotum()
 	e := bp.entry
 	dkey := make([]byte, aesKeyLen)
-	sig, err := f.FileSign(f.DirEntryHash(e.SignedName, e.Link, e.Attr, e.Packing, e.Time, dkey, sum))
+	sig, err := f.FileSign(f.DirEntryHash(e.SignedName, e.Link, e.Attr, e.Packing, e.Time, dkey, internal.AttrSum(e, sum)))
 	if err != nil {
 		return errors.E(op, err)
 	}
@@ -191,7 +191,7 @@ func (ei ei) Unpack(cfg upspin.Config, d *upspin.DirEntry) (upspin.BlockUnpacker
 	f := cfg.Factotum()
 	dkey := make([]byte, aesKeyLen)
 	// Verify that this was signed with the writer's old or new public key.
-	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, hash)
+	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, internal.AttrSum(d, hash))
 	if !ecdsa.Verify(writerPubKey, vhash, sig.R, sig.S) &&
 		!ecdsa.Verify(writerPubKey, vhash, sig2.R, sig2.S) {
 		// Check sig2 in case writerPubKey is rotating.
@@ -229,6 +229,23 @@ func (bp *blockUnpacker) Unpack(ciphertext []byte) (cleartext []byte, err error)
 	return cleartext, nil
 }
 
+func (bp *blockUnpacker) UnpackZero() (cleartext []byte, err error) {
+	const op errors.Op = "pack/eeintegrity.blockUpacker.UnpackZero"
+	block := bp.entry.Blocks[bp.Block]
+	cleartext = bp.buf.Bytes(int(block.Size))
+	for i := range cleartext {
+		cleartext[i] = 0
+	}
+
+	// Validate checksum.
+	b := sha256.Sum256(cleartext)
+	if got, want := b[:], block.Packdata; !bytes.Equal(got, want) {
+		return nil, errors.E(op, bp.entry.Name, "checksum mismatch")
+	}
+
+	return cleartext, nil
+}
+
 func (bp *blockUnpacker) Close() error {
 	return nil
 }
@@ -290,7 +307,7 @@ func (ei ei) updateDirEntry(op errors.Op, cfg upspin.Config, d *upspin.DirEntry,
 
 	// Verify that this was signed with the writer's old or new public key.
 	f := cfg.Factotum()
-	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, cipherSum)
+	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, internal.AttrSum(d, cipherSum))
 	if !ecdsa.Verify(writerPubKey, vhash, sig.R, sig.S) &&
 		!ecdsa.Verify(writerPubKey, vhash, sig2.R, sig2.S) {
 		// Check sig2 in case writerPubKey is rotating.
@@ -301,7 +318,7 @@ func (ei ei) updateDirEntry(op errors.Op, cfg upspin.Config, d *upspin.DirEntry,
 	d.Writer = cfg.UserName()
 	d.SignedName = newName
 	d.Time = newTime
-	vhash = f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, cipherSum)
+	vhash = f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, internal.AttrSum(d, cipherSum))
 	sig, err = f.FileSign(vhash)
 	if err != nil {
 		return errors.E(op, d.Name, err)
@@ -338,7 +355,7 @@ func (ei ei) Countersign(oldKey upspin.PublicKey, f upspin.Factotum, d *upspin.D
 
 	// Verify existing signature with oldKey.
 	dkey := make([]byte, aesKeyLen)
-	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, cipherSum)
+	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, internal.AttrSum(d, cipherSum))
 	if !ecdsa.Verify(oldPubKey, vhash, sig.R, sig.S) {
 		return errors.E(op, d.Name, errVerify, "unable to verify existing signature")
 	}
@@ -291,3 +291,9 @@ func TestMultiBlockRoundTrip(t *testing.T) {
 	cfg, packer := setup(userName)
 	packtest.TestMultiBlockRoundTrip(t, cfg, packer, userName)
 }
+
+func TestAttributes(t *testing.T) {
+	const userName = upspin.UserName("aly@upspin.io")
+	cfg, packer := setup(userName)
+	packtest.TestAttributes(t, cfg, packer, userName)
+}
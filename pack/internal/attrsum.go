@@ -0,0 +1,42 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package internal
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"sort"
+
+	"upspin.io/upspin"
+)
+
+// AttrSum returns the checksum to sign for the DirEntry given sum, the
+// packing's own checksum of its blocks. If the entry has Attributes, the
+// result is a SHA256 hash of sum and the attributes, so that changing
+// them invalidates the signature. Otherwise it is sum itself, so the
+// signatures of entries without attributes are unchanged.
+func AttrSum(d *upspin.DirEntry, sum []byte) []byte {
+	if len(d.Attributes) == 0 {
+		return sum
+	}
+	names := make([]string, 0, len(d.Attributes))
+	for name := range d.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	hash := sha256.New()
+	hash.Write(sum)
+	var n [4]byte
+	for _, name := range names {
+		value := d.Attributes[name]
+		binary.BigEndian.PutUint32(n[:], uint32(len(name)))
+		hash.Write(n[:])
+		hash.Write([]byte(name))
+		binary.BigEndian.PutUint32(n[:], uint32(len(value)))
+		hash.Write(n[:])
+		hash.Write(value)
+	}
+	return hash.Sum(nil)
+}
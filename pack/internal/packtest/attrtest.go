@@ -0,0 +1,70 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package packtest
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"upspin.io/upspin"
+)
+
+// TestAttributes checks that the packer signs the Attributes of an entry,
+// so that changing them after packing prevents it from being unpacked.
+func TestAttributes(t *testing.T, ctx upspin.Config, packer upspin.Packer, userName upspin.UserName) {
+	pathName := upspin.PathName(userName + "/attrfile")
+	data := []byte("data with attributes")
+	de := &upspin.DirEntry{
+		Name:       pathName,
+		SignedName: pathName,
+		Writer:     userName,
+		Packing:    packer.Packing(),
+		Attributes: map[string][]byte{
+			"content-type": []byte("text/plain"),
+			"tag":          []byte("blue"),
+		},
+	}
+	store := make(fakeStore)
+	if err := packEntry(ctx, store, packer, de, bytes.NewReader(data)); err != nil {
+		t.Fatal("packEntry:", err)
+	}
+	var out bytes.Buffer
+	if err := unpackEntry(ctx, store, packer, de, &out); err != nil {
+		t.Fatal("unpackEntry:", err)
+	}
+	if !bytes.Equal(out.Bytes(), data) {
+		t.Fatal("output did not match input")
+	}
+
+	// Renaming re-signs the attributes too.
+	newName := upspin.PathName(userName + "/renamed")
+	if err := packer.Name(ctx, de, newName); err != nil {
+		t.Fatal("Name:", err)
+	}
+	if err := unpackEntry(ctx, store, packer, de, &out); err != nil {
+		t.Fatal("unpackEntry after Name:", err)
+	}
+
+	for _, tamper := range []struct {
+		desc  string
+		attrs map[string][]byte
+	}{
+		{"changed value", map[string][]byte{"content-type": []byte("text/html"), "tag": []byte("blue")}},
+		{"added attribute", map[string][]byte{"content-type": []byte("text/plain"), "tag": []byte("blue"), "x": nil}},
+		{"removed attribute", map[string][]byte{"content-type": []byte("text/plain")}},
+		{"moved byte", map[string][]byte{"content-type": []byte("text/plai"), "tag": []byte("nblue")}},
+		{"no attributes", nil},
+	} {
+		d := de.Copy()
+		d.Attributes = tamper.attrs
+		err := unpackEntry(ctx, store, packer, d, &out)
+		if err == nil {
+			t.Errorf("%s: unpacked tampered entry", tamper.desc)
+		} else if !strings.Contains(err.Error(), "verif") {
+			t.Errorf("%s: err = %v, want verification failure", tamper.desc, err)
+		}
+	}
+}
@@ -68,3 +68,29 @@ func CheckPacking(p upspin.Packer, entry *upspin.DirEntry) error {
 	}
 	return nil
 }
+
+// A KeyedPacker is a Packer that can pack a file with the key of an earlier
+// packing of it. Since blocks are encrypted with a key stream determined by
+// the key and the offset, packing the same cleartext with the same key gives
+// the same ciphertext, so an interrupted upload can be resumed without
+// storing again the blocks already stored. The EEPack Packer implements it.
+type KeyedPacker interface {
+	upspin.Packer
+
+	// PackWithKey is like Pack but uses the file key returned by the
+	// WrappedKey method of an earlier BlockPacker, which must be
+	// unwrappable by the config's Factotum. Reusing the key for
+	// different cleartext compromises the encryption, so the caller
+	// must be certain the cleartext is the same.
+	PackWithKey(cfg upspin.Config, d *upspin.DirEntry, wrapped []byte) (upspin.BlockPacker, error)
+}
+
+// A KeyedBlockPacker is a BlockPacker that can report its file key.
+// The BlockPackers of KeyedPackers implement it.
+type KeyedBlockPacker interface {
+	upspin.BlockPacker
+
+	// WrappedKey returns the file key wrapped for the packing user,
+	// suitable for KeyedPacker.PackWithKey.
+	WrappedKey() ([]byte, error)
+}
@@ -118,7 +118,7 @@ func (bp *blockPacker) Close() error {
 	e := bp.entry
 	dkey := make([]byte, aesKeyLen)
 	sum := make([]byte, sha256.Size)
-	sig, err := f.FileSign(f.DirEntryHash(e.SignedName, e.Link, e.Attr, e.Packing, e.Time, dkey, sum))
+	sig, err := f.FileSign(f.DirEntryHash(e.SignedName, e.Link, e.Attr, e.Packing, e.Time, dkey, internal.AttrSum(e, sum)))
 	if err != nil {
 		return errors.E(op, err)
 	}
@@ -160,7 +160,7 @@ func (p plainPack) Unpack(cfg upspin.Config, d *upspin.DirEntry) (upspin.BlockUn
 	dkey := make([]byte, aesKeyLen)
 	sum := make([]byte, sha256.Size)
 	// Verify that this was signed with the writer's old or new public key.
-	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, sum)
+	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, internal.AttrSum(d, sum))
 	if !ecdsa.Verify(writerPubKey, vhash, sig.R, sig.S) &&
 		!ecdsa.Verify(writerPubKey, vhash, sig2.R, sig2.S) {
 		// Check sig2 in case writerPubKey is rotating.
@@ -185,6 +185,10 @@ func (bp *blockUnpacker) Unpack(ciphertext []byte) (cleartext []byte, err error)
 	return
 }
 
+func (bp *blockUnpacker) UnpackZero() (cleartext []byte, err error) {
+	return make([]byte, bp.entry.Blocks[bp.Block].Size), nil
+}
+
 func (bp *blockUnpacker) Close() error {
 	return nil
 }
@@ -224,7 +228,7 @@ func (p plainPack) updateDirEntry(op errors.Op, cfg upspin.Config, dirEntry *ups
 	e := dirEntry
 	dkey := make([]byte, aesKeyLen)
 	sum := make([]byte, sha256.Size)
-	sig, err := f.FileSign(f.DirEntryHash(e.SignedName, e.Link, e.Attr, e.Packing, e.Time, dkey, sum))
+	sig, err := f.FileSign(f.DirEntryHash(e.SignedName, e.Link, e.Attr, e.Packing, e.Time, dkey, internal.AttrSum(e, sum)))
 	if err != nil {
 		return errors.E(op, err)
 	}
@@ -253,7 +257,7 @@ func (p plainPack) Countersign(oldKey upspin.PublicKey, f upspin.Factotum, d *up
 	// Verify existing signature with oldKey.
 	dkey := make([]byte, aesKeyLen)
 	sum := make([]byte, sha256.Size)
-	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, sum)
+	vhash := f.DirEntryHash(d.SignedName, d.Link, d.Attr, d.Packing, d.Time, dkey, internal.AttrSum(d, sum))
 	if !ecdsa.Verify(oldPubKey, vhash, sig.R, sig.S) {
 		return errors.E(op, d.Name, errVerify, "unable to verify existing signature")
 	}
@@ -6,8 +6,13 @@ package rpc
 
 import (
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	pb "github.com/golang/protobuf/proto"
@@ -44,6 +49,16 @@ func lookup(user upspin.UserName) (upspin.PublicKey, error) {
 	return "", errors.E(errors.NotExist, "No user here")
 }
 
+// joeRevoked reports whether revoked should report joe's key as revoked.
+var joeRevoked bool
+
+func revoked(user upspin.UserName) ([]upspin.PublicKey, error) {
+	if user == joeUser && joeRevoked {
+		return []upspin.PublicKey{joePublic}, nil
+	}
+	return nil, nil
+}
+
 type server struct {
 	t         *testing.T
 	iteration int
@@ -70,7 +85,15 @@ func startServer(t *testing.T) (port string) {
 		Streams: map[string]Stream{
 			"Count": srv.Count,
 		},
-		Lookup: lookup,
+		BiStreams: map[string]BiStreamMethod{
+			"Count": srv.CountBidi,
+		},
+		Readers: map[string]ReaderMethod{
+			"Repeat": srv.Repeat,
+			"Broken": srv.Broken,
+		},
+		Lookup:  lookup,
+		Revoked: revoked,
 	}))
 
 	ready := make(chan struct{})
@@ -142,6 +165,66 @@ func (s *server) Count(session Session, reqBytes []byte, done <-chan struct{}) (
 	return out, nil
 }
 
+// CountBidi counts as Count does, and then counts again for each further
+// CountRequest the client sends.
+func (s *server) CountBidi(session Session, reqBytes []byte, recv <-chan []byte, done <-chan struct{}) (<-chan pb.Message, error) {
+	var req prototest.CountRequest
+	if err := pb.Unmarshal(reqBytes, &req); err != nil {
+		return nil, err
+	}
+	out := make(chan pb.Message)
+	go func() {
+		defer close(out)
+		for {
+			for i := req.Start; i < req.Start+req.Count; i++ {
+				select {
+				case out <- &prototest.CountResponse{Number: i}:
+				case <-done:
+					return
+				}
+			}
+			select {
+			case b, ok := <-recv:
+				if !ok {
+					return
+				}
+				req = prototest.CountRequest{}
+				if err := pb.Unmarshal(b, &req); err != nil {
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// repeats is how many times Repeat repeats the payload, enough that the
+// reply is sent in many chunks.
+const repeats = 1 << 16
+
+// Repeat replies with the payload and then a stream of bytes that is the
+// payload repeated.
+func (s *server) Repeat(session Session, reqBytes []byte) (pb.Message, io.ReadCloser, error) {
+	var req prototest.EchoRequest
+	if err := pb.Unmarshal(reqBytes, &req); err != nil {
+		return nil, nil, err
+	}
+	r := strings.NewReader(strings.Repeat(req.Payload, repeats))
+	return &prototest.EchoResponse{Payload: req.Payload}, io.NopCloser(r), nil
+}
+
+// Broken is like Repeat but its stream fails part way through.
+func (s *server) Broken(session Session, reqBytes []byte) (pb.Message, io.ReadCloser, error) {
+	resp, rc, err := s.Repeat(session, reqBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	r := io.MultiReader(io.LimitReader(rc, 1<<16), iotest.ErrReader(errors.Str("disk on fire")))
+	return resp, io.NopCloser(r), nil
+}
+
 type client struct {
 	Client   // For sessions and Close.
 	reqCount int
@@ -206,6 +289,90 @@ func (c *client) Count(t *testing.T, start, count int32) {
 	}
 }
 
+func (c *client) CountBidi(t *testing.T) {
+	_, err := c.InvokeBiStream("Server/Echo", &prototest.EchoRequest{})
+	if err != upspin.ErrNotSupported {
+		t.Errorf("InvokeBiStream of one-shot method: err = %v, want %v", err, upspin.ErrNotSupported)
+	}
+
+	s, err := c.InvokeBiStream("Server/Count", &prototest.CountRequest{Start: 0, Count: 3})
+	if err != nil {
+		t.Fatal("CountBidi:", err)
+	}
+	defer s.Close()
+	expect := func(start, count int32) {
+		for i := start; i < start+count; i++ {
+			b, err := s.Recv()
+			if err != nil {
+				t.Fatalf("CountBidi: Recv: %v", err)
+			}
+			var resp prototest.CountResponse
+			if err := pb.Unmarshal(b, &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Number != i {
+				t.Fatalf("CountBidi: got %d, want %d", resp.Number, i)
+			}
+		}
+	}
+	expect(0, 3)
+	// Ask for more on the same stream.
+	if err := s.Send(&prototest.CountRequest{Start: 10, Count: 2}); err != nil {
+		t.Fatal(err)
+	}
+	expect(10, 2)
+	if err := s.Send(&prototest.CountRequest{Start: 20, Count: 4}); err != nil {
+		t.Fatal(err)
+	}
+	expect(20, 4)
+}
+
+func (c *client) Reader(t *testing.T) {
+	resp := new(prototest.EchoResponse)
+	rc, err := c.InvokeReader("Server/Repeat", &prototest.EchoRequest{Payload: payloads[0]}, resp)
+	if err != nil {
+		t.Fatal("Repeat:", err)
+	}
+	if rc == nil {
+		t.Fatal("Repeat: no byte stream")
+	}
+	b, err := io.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal("Repeat:", err)
+	}
+	if resp.Payload != payloads[0] {
+		t.Errorf("Repeat: got payload %q, want %q", resp.Payload, payloads[0])
+	}
+	if want := strings.Repeat(payloads[0], repeats); string(b) != want {
+		t.Errorf("Repeat: got %d bytes, want %d", len(b), len(want))
+	}
+
+	// A stream that fails must not look like a short one.
+	rc, err = c.InvokeReader("Server/Broken", &prototest.EchoRequest{Payload: payloads[0]}, resp)
+	if err != nil {
+		t.Fatal("Broken:", err)
+	}
+	b, err = io.ReadAll(rc)
+	rc.Close()
+	if err == nil {
+		t.Errorf("Broken: read %d bytes with no error", len(b))
+	}
+
+	// A one-shot method is served as usual.
+	srv.iteration = 0
+	rc, err = c.InvokeReader("Server/Echo", &prototest.EchoRequest{Payload: payloads[0]}, resp)
+	if err != nil {
+		t.Fatal("Echo:", err)
+	}
+	if rc != nil {
+		t.Error("Echo: got a byte stream from a one-shot method")
+	}
+	if resp.Payload != payloads[0] {
+		t.Errorf("Echo: got payload %q, want %q", resp.Payload, payloads[0])
+	}
+}
+
 type countStream chan prototest.CountResponse
 
 func (s countStream) Send(b []byte, done <-chan struct{}) error {
@@ -284,6 +451,12 @@ func TestAll(t *testing.T) {
 	// Test authenticated stream.
 	cli.Count(t, 0, 5)
 
+	// Test bidirectional stream.
+	cli.CountBidi(t)
+
+	// Test byte stream.
+	cli.Reader(t)
+
 	// Test that the client retries authentication properly
 	// when the server forgets the auth token.
 	srv.iteration = 0
@@ -300,6 +473,19 @@ func TestAll(t *testing.T) {
 		t.Errorf("Payload %d: Expected response %q, got %q", 1, payloads[1], response)
 	}
 
+	// Revoke joe's key. The established session is dropped
+	// and a new one is refused.
+	interval := revocationCheckInterval
+	t.Cleanup(func() { revocationCheckInterval = interval })
+	revocationCheckInterval = 0
+	joeRevoked = true
+	req := &prototest.EchoRequest{Payload: payloads[2]}
+	err := cli.Invoke("Server/Echo", req, new(prototest.EchoResponse), nil, nil)
+	if err == nil || !strings.Contains(err.Error(), errRevoked.Error()) {
+		t.Errorf("Echo with revoked key: err = %v, want %q", err, errRevoked)
+	}
+	joeRevoked = false
+
 	// Test unauthenticated method.
 	startClient(port, "unknown@user.com")
 	srv.iteration = 0
@@ -317,3 +503,110 @@ func TestAll(t *testing.T) {
 		t.Errorf("Expected client to be on iteration %d, was on %d", srv.iteration, cli.reqCount)
 	}
 }
+
+func TestTuning(t *testing.T) {
+	cfg, err := config.InitConfig(strings.NewReader("secrets: none\nrpc:\n  http2: true\n  streams: 8\n"))
+	if err != config.ErrNoFactotum {
+		t.Fatal(err)
+	}
+	got, err := TuningFromConfig(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultTuning
+	want.HTTP2 = true
+	want.Streams = 8
+	if got != want {
+		t.Errorf("TuningFromConfig = %+v, want %+v", got, want)
+	}
+	for _, bad := range []string{"{conns: -1}", "{colour: blue}", "{conns: many}"} {
+		c := config.SetValue(cfg, "rpc", bad)
+		if _, err := NewClient(c, "localhost:1", NoSecurity, upspin.Endpoint{}); !errors.Is(errors.Invalid, err) {
+			t.Errorf("NewClient with rpc %q: err = %v, want Invalid", bad, err)
+		}
+	}
+
+	// Shared clients share transports if their tunings match.
+	transportOf := func(cfg upspin.Config, user upspin.UserName) *transport {
+		c, err := NewSharedClient(config.SetUserName(cfg, user), "localhost:1", NoSecurity, upspin.Endpoint{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return c.(*httpClient).transport
+	}
+	ann := transportOf(cfg, "ann@example.com")
+	if transportOf(cfg, "bob@example.com") != ann {
+		t.Error("clients with the same tuning do not share a transport")
+	}
+	if transportOf(config.SetValue(cfg, "rpc", "{streams: 2}"), "ann@example.com") == ann {
+		t.Error("clients with different tunings share a transport")
+	}
+	if cap(ann.slots) != 8 {
+		t.Errorf("transport has %d slots, want 8", cap(ann.slots))
+	}
+}
+
+func TestReadAll(t *testing.T) {
+	want := strings.Repeat(payloads[0], 100000)
+	for i := 0; i < 3; i++ {
+		got, err := ReadAll(iotest.OneByteReader(strings.NewReader(want[:len(want)>>i])))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != want[:len(want)>>i] || cap(got) != len(got) {
+			t.Errorf("ReadAll returned %d bytes with capacity %d, want %d", len(got), cap(got), len(want)>>i)
+		}
+	}
+	if _, err := ReadAll(iotest.TimeoutReader(strings.NewReader(want))); err != iotest.ErrTimeout {
+		t.Errorf("ReadAll err = %v, want %v", err, iotest.ErrTimeout)
+	}
+}
+
+func TestPanicRecovery(t *testing.T) {
+	cfg := config.SetUserName(config.New(), "server@upspin.io")
+	h := NewServer(cfg, Service{
+		Name: "Panic",
+		UnauthenticatedMethods: map[string]UnauthenticatedMethod{
+			"Panic": func([]byte) (pb.Message, error) {
+				var m map[string]int
+				m["boom"]++ // Nil map assignment.
+				return nil, nil
+			},
+		},
+		Lookup: lookup,
+	})
+	serve := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("POST", "/api/Panic/Panic", strings.NewReader("")))
+		return w
+	}
+
+	before := panicCount.Value()
+	w := serve()
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", w.Code, http.StatusInternalServerError)
+	}
+	if err := errors.UnmarshalError(w.Body.Bytes()); !errors.Is(errors.Internal, err) {
+		t.Errorf("err = %v, want Internal error", err)
+	}
+	if got := panicCount.Value(); got != before+1 {
+		t.Errorf("panic_count = %d, want %d", got, before+1)
+	}
+
+	// In test mode the panic propagates.
+	prev, ok := os.LookupEnv("UPSPIN_TEST")
+	os.Setenv("UPSPIN_TEST", "1")
+	defer func() {
+		if ok {
+			os.Setenv("UPSPIN_TEST", prev)
+		} else {
+			os.Unsetenv("UPSPIN_TEST")
+		}
+	}()
+	defer func() {
+		if recover() == nil {
+			t.Error("panic was recovered with UPSPIN_TEST set")
+		}
+	}()
+	serve()
+}
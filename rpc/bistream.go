@@ -0,0 +1,190 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/binary"
+	"io"
+	"net/http"
+	"sync"
+
+	pb "github.com/golang/protobuf/proto"
+
+	"upspin.io/errors"
+)
+
+const (
+	// streamHeader is the request header with which a client asks for a
+	// bidirectional stream or a byte stream, and the response header with
+	// which a server advertises that it can serve the former or says it
+	// is serving the latter.
+	streamHeader = "X-Upspin-Stream"
+
+	// streamBidi is the value of streamHeader for bidirectional streams.
+	streamBidi = "bidi"
+
+	// streamBytes is the value of streamHeader for byte streams.
+	streamBytes = "bytes"
+
+	// reasonableMessageSize is the largest stream message accepted.
+	reasonableMessageSize = 1 << 26 // 64MB
+)
+
+// BiStream is a full-duplex channel of messages carried by a single HTTP
+// request and its response. In each direction every message is preceded
+// by its length as a four byte, big-endian-encoded uint32.
+//
+// Send may be called concurrently with Recv, but neither may be called
+// concurrently with itself.
+type BiStream struct {
+	r     io.Reader
+	w     io.Writer
+	flush func()
+	close func() error
+
+	closeOnce sync.Once
+	closeErr  error
+}
+
+// Send encodes and sends a message to the other end of the stream.
+func (s *BiStream) Send(msg pb.Message) error {
+	b, err := pb.Marshal(msg)
+	if err != nil {
+		return errors.E(errors.Invalid, err)
+	}
+	return s.send(b)
+}
+
+func (s *BiStream) send(b []byte) error {
+	var lenBytes [4]byte
+	binary.BigEndian.PutUint32(lenBytes[:], uint32(len(b)))
+	if _, err := s.w.Write(lenBytes[:]); err != nil {
+		return errors.E(errors.IO, err)
+	}
+	if _, err := s.w.Write(b); err != nil {
+		return errors.E(errors.IO, err)
+	}
+	if s.flush != nil {
+		s.flush()
+	}
+	return nil
+}
+
+// Recv returns the next encoded message from the other end of the stream.
+// It returns io.EOF if the other end has finished sending.
+func (s *BiStream) Recv() ([]byte, error) {
+	var msgLen [4]byte
+	if _, err := io.ReadFull(s.r, msgLen[:]); err == io.EOF {
+		return nil, io.EOF
+	} else if err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	l := binary.BigEndian.Uint32(msgLen[:])
+	if l > reasonableMessageSize {
+		return nil, errors.E(errors.Invalid, errors.Errorf("message too long (%d bytes)", l))
+	}
+	buf := make([]byte, l)
+	if _, err := io.ReadFull(s.r, buf); err != nil {
+		return nil, errors.E(errors.IO, err)
+	}
+	return buf, nil
+}
+
+// Close closes the stream in both directions.
+func (s *BiStream) Close() error {
+	s.closeOnce.Do(func() {
+		if s.close != nil {
+			s.closeErr = s.close()
+		}
+	})
+	return s.closeErr
+}
+
+// serveBiStream serves a bidirectional stream on a request that carries the
+// streamHeader. The first message from the client is the request; the
+// remaining ones are delivered to the method as they arrive.
+func serveBiStream(s BiStreamMethod, sess Session, w http.ResponseWriter, r *http.Request) {
+	// HTTP/1 responses may only be written while the request body is
+	// being read if the handler asks for it. HTTP/2 is always duplex.
+	if fd, ok := w.(interface{ EnableFullDuplex() error }); ok {
+		fd.EnableFullDuplex()
+	}
+	bs := &BiStream{
+		r:     r.Body,
+		w:     w,
+		flush: w.(http.Flusher).Flush,
+	}
+	body, err := bs.Recv()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	done := make(chan struct{})
+	var closeDone sync.Once
+	finish := func() { closeDone.Do(func() { close(done) }) }
+	defer finish()
+
+	recv := make(chan []byte)
+	msgs, err := s(sess, body, recv, done)
+	if err != nil {
+		sendError(w, err)
+		return
+	}
+
+	go func() {
+		defer close(recv)
+		for {
+			b, err := bs.Recv()
+			if err == io.EOF {
+				// The client has finished sending but may
+				// still be listening.
+				return
+			}
+			if err != nil {
+				finish()
+				return
+			}
+			select {
+			case recv <- b:
+			case <-done:
+				return
+			}
+		}
+	}()
+	go func() {
+		select {
+		case <-r.Context().Done():
+			finish()
+		case <-done:
+		}
+	}()
+
+	// Write the headers, beginning the stream.
+	w.Header().Set(streamHeader, streamBidi)
+	w.Write([]byte("OK"))
+	w.(http.Flusher).Flush()
+
+	for {
+		select {
+		case msg, ok := <-msgs:
+			if !ok {
+				return
+			}
+			if err := bs.Send(msg); err != nil {
+				logger.Debug.Printf("rpc: error sending on bidirectional stream: %v", err)
+				finish()
+				// Drain msgs so the method's goroutines can exit.
+				for range msgs {
+				}
+				return
+			}
+		case <-done:
+			for range msgs {
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"io"
+	"sync"
+
+	pb "github.com/golang/protobuf/proto"
+)
+
+// Requests and replies that carry blocks of data, those of a StoreServer's
+// Get and Put, are read and encoded in buffers drawn from a pool, so that
+// a busy server or client does not allocate a block's worth of memory, or
+// more, for each of them. A buffer from the pool must not be referred to
+// once it has been freed, so data taken from one that outlives the request
+// is copied.
+
+// maxPooledBuffer is the size of the largest buffer kept in the pool, a
+// few times the default block size of the client.
+const maxPooledBuffer = 4 << 20
+
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(buffer) },
+}
+
+// buffer holds a byte slice that can be reused.
+type buffer struct {
+	b []byte
+}
+
+// getBuffer returns an empty buffer from the pool.
+func getBuffer() *buffer {
+	return bufferPool.Get().(*buffer)
+}
+
+// free returns the buffer to the pool. The buffer and any slice of its
+// bytes must not be used afterwards.
+func (buf *buffer) free() {
+	if cap(buf.b) > maxPooledBuffer {
+		return
+	}
+	buf.b = buf.b[:0]
+	bufferPool.Put(buf)
+}
+
+// readFrom reads from r until EOF, appending to the buffer.
+func (buf *buffer) readFrom(r io.Reader) error {
+	b := buf.b
+	for {
+		if len(b) == cap(b) {
+			// Grow the slice; append picks the new capacity.
+			b = append(b, 0)[:len(b)]
+		}
+		n, err := r.Read(b[len(b):cap(b)])
+		b = b[:len(b)+n]
+		if err == io.EOF {
+			buf.b = b
+			return nil
+		}
+		if err != nil {
+			buf.b = b
+			return err
+		}
+	}
+}
+
+// marshal appends the encoding of msg to the buffer.
+func (buf *buffer) marshal(msg pb.Message) error {
+	p := pb.NewBuffer(buf.b)
+	if err := p.Marshal(msg); err != nil {
+		return err
+	}
+	buf.b = p.Bytes()
+	return nil
+}
+
+// ReadAll reads from r until EOF and returns the data it read, like
+// io.ReadAll. It reads into a buffer from a pool, and so allocates just
+// the memory it returns, which belongs to the caller.
+func ReadAll(r io.Reader) ([]byte, error) {
+	buf := getBuffer()
+	defer buf.free()
+	err := buf.readFrom(r)
+	data := make([]byte, len(buf.b))
+	copy(data, buf.b)
+	return data, err
+}
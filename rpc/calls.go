@@ -0,0 +1,96 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"upspin.io/upspin"
+
+	pb "github.com/golang/protobuf/proto"
+)
+
+// Call describes an RPC made by a client, as reported to the function
+// given to ObserveCalls.
+type Call struct {
+	Method string         // As in "Dir/Lookup".
+	Addr   upspin.NetAddr // Of the server.
+
+	// Sent and Received are the sizes in bytes of the request and of
+	// the reply, including any bytes streamed by InvokeReader.
+	Sent, Received int64
+
+	// Duration is the time from sending the request until the reply
+	// has been read. For streams other than those of InvokeReader, it
+	// ends when the stream begins.
+	Duration time.Duration
+
+	Err error
+}
+
+// observer holds the function, of type func(Call), given to ObserveCalls.
+var observer atomic.Value
+
+// ObserveCalls arranges for f to be called after each RPC made by the
+// clients in this process, to collect statistics about them. It must be
+// safe to call concurrently. A nil f stops the observation.
+func ObserveCalls(f func(Call)) {
+	observer.Store(f)
+}
+
+// observeCall reports a call to the observer, if any. The reply is resp,
+// if not nil, followed by the streamed bytes.
+func (c *httpClient) observeCall(method string, start time.Time, req, resp pb.Message, streamed int64, err error) {
+	f, _ := observer.Load().(func(Call))
+	if f == nil {
+		return
+	}
+	call := Call{
+		Method:   method,
+		Addr:     c.netAddr,
+		Sent:     int64(pb.Size(req)),
+		Received: streamed,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+	if resp != nil && err == nil {
+		call.Received += int64(pb.Size(resp))
+	}
+	f(call)
+}
+
+// observedReader counts the bytes read from the stream of an InvokeReader
+// call and reports the call once the stream is closed.
+type observedReader struct {
+	io.ReadCloser
+	c      *httpClient
+	method string
+	start  time.Time
+	req    pb.Message
+	resp   pb.Message
+	n      int64
+	err    error // First error other than io.EOF.
+	once   sync.Once
+}
+
+func (r *observedReader) Read(b []byte) (int, error) {
+	n, err := r.ReadCloser.Read(b)
+	r.n += int64(n)
+	if err != nil && err != io.EOF && r.err == nil {
+		r.err = err
+	}
+	return n, err
+}
+
+func (r *observedReader) Close() error {
+	err := r.ReadCloser.Close()
+	r.once.Do(func() {
+		r.c.observeCall(r.method, r.start, r.req, r.resp, r.n, r.err)
+	})
+	return err
+}
@@ -13,11 +13,11 @@ import (
 	"net/http"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"upspin.io/bind"
 	"upspin.io/errors"
-	"upspin.io/rpc/local"
 	"upspin.io/serverutil"
 	"upspin.io/upspin"
 
@@ -42,6 +42,20 @@ type Client interface {
 	// ("Server/Method") with request body req. Upon success, resp, if nil,
 	// contains the server's reply, if any.
 	InvokeUnauthenticated(method string, req, resp pb.Message) error
+
+	// InvokeBiStream opens a bidirectional stream to the given RPC method
+	// ("Server/Method"), sending req as its first message. It returns
+	// upspin.ErrNotSupported if the server has not advertised support
+	// for bidirectional streams or does not serve the method that way.
+	InvokeBiStream(method string, req pb.Message) (*BiStream, error)
+
+	// InvokeReader calls the given RPC method ("Server/Method"), asking
+	// for its reply as a byte stream. It decodes the message that begins
+	// the reply into resp and returns a reader of the bytes that follow,
+	// which the caller must close. If the server instead sends a one-shot
+	// reply, as servers that do not stream the method do, that is decoded
+	// into resp and the returned reader is nil.
+	InvokeReader(method string, req, resp pb.Message) (io.ReadCloser, error)
 }
 
 // ResponseChan describes a mechanism to report streamed messages to a client
@@ -76,9 +90,11 @@ const (
 var tokenFreshnessDuration = authTokenDuration - time.Hour
 
 type httpClient struct {
-	client   *http.Client
+	*transport
 	baseURL  string
+	netAddr  upspin.NetAddr
 	proxyFor upspin.Endpoint // the server is a proxy for this endpoint.
+	bidi     atomic.Bool     // the server serves bidirectional streams.
 
 	clientAuth
 }
@@ -88,11 +104,27 @@ type httpClient struct {
 // number, as in domain.com:5580. The security level specifies the expected
 // security guarantees of the connection. If proxyFor is an assigned endpoint,
 // it indicates that this connection is being used to proxy request to that
-// endpoint.
+// endpoint. The client's connections are tuned as the config says; see
+// Tuning.
 func NewClient(cfg upspin.Config, netAddr upspin.NetAddr, security SecurityLevel, proxyFor upspin.Endpoint) (Client, error) {
 	const op errors.Op = "rpc.NewClient"
+	return newClient(op, cfg, netAddr, security, proxyFor, false)
+}
+
+// NewSharedClient is like NewClient but the client shares its connections
+// with the other clients made by NewSharedClient for the same address and
+// with the same TLS certificates and Tuning, whatever their users. It is
+// meant for services, such as StoreServers, to which a process sends many
+// requests at once on behalf of many users. Limits set by the Tuning apply
+// to all the clients together.
+func NewSharedClient(cfg upspin.Config, netAddr upspin.NetAddr, security SecurityLevel, proxyFor upspin.Endpoint) (Client, error) {
+	const op errors.Op = "rpc.NewSharedClient"
+	return newClient(op, cfg, netAddr, security, proxyFor, true)
+}
 
+func newClient(op errors.Op, cfg upspin.Config, netAddr upspin.NetAddr, security SecurityLevel, proxyFor upspin.Endpoint, shared bool) (Client, error) {
 	c := &httpClient{
+		netAddr:  netAddr,
 		proxyFor: proxyFor,
 	}
 	c.clientAuth.config = cfg
@@ -116,31 +148,29 @@ func NewClient(cfg upspin.Config, netAddr upspin.NetAddr, security SecurityLevel
 		return nil, errors.E(op, errors.Invalid, errors.Errorf("invalid security level to NewClient: %v", security))
 	}
 
-	t := &http.Transport{
-		TLSClientConfig: tlsConfig,
-		// The following values are the same as
-		// net/http.DefaultTransport.
-		Proxy: http.ProxyFromEnvironment,
-		DialContext: (&local.Dialer{
-			Timeout:   30 * time.Second,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		MaxIdleConns:          100,
-		MaxIdleConnsPerHost:   10,
-		IdleConnTimeout:       90 * time.Second,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ExpectContinueTimeout: 1 * time.Second,
-	}
-	// TODO(adg): Re-enable HTTP/2 once it's fast enough to be usable.
-	//if err := http2.ConfigureTransport(t); err != nil {
-	//	return nil, errors.E(op, err)
-	//}
-	c.client = &http.Client{Transport: t}
-
+	tuning, err := TuningFromConfig(cfg)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+	if shared {
+		key := transportKey{
+			baseURL:  c.baseURL,
+			tlscerts: cfg.Value("tlscerts"),
+			tuning:   tuning,
+		}
+		c.transport, err = sharedTransport(key, tlsConfig)
+	} else {
+		c.transport, err = newTransport(tlsConfig, tuning)
+	}
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
 	return c, nil
 }
 
-func (c *httpClient) makeAuthenticatedRequest(op errors.Op, method string, req pb.Message) (*http.Response, bool, error) {
+// authHeader returns the headers that authenticate a request to the server.
+// It reports whether the server must authenticate itself in its response.
+func (c *httpClient) authHeader(op errors.Op) (http.Header, bool, error) {
 	token, haveToken := c.authToken()
 	header := make(http.Header)
 	needServerAuth := false
@@ -159,6 +189,14 @@ func (c *httpClient) makeAuthenticatedRequest(op errors.Op, method string, req p
 			header.Set(proxyRequestHeader, c.proxyFor.String())
 		}
 	}
+	return header, needServerAuth, nil
+}
+
+func (c *httpClient) makeAuthenticatedRequest(op errors.Op, method string, req pb.Message) (*http.Response, bool, error) {
+	header, needServerAuth, err := c.authHeader(op)
+	if err != nil {
+		return nil, false, err
+	}
 	resp, err := c.makeRequest(op, method, req, header)
 	return resp, needServerAuth, err
 }
@@ -169,24 +207,38 @@ func (c *httpClient) makeRequest(op errors.Op, method string, req pb.Message, he
 	if err != nil {
 		return nil, errors.E(op, err)
 	}
+	return c.doRequest(op, method, bytes.NewReader(payload), header)
+}
+
+func (c *httpClient) doRequest(op errors.Op, method string, body io.Reader, header http.Header) (*http.Response, error) {
 	header.Set("Content-Type", "application/octet-stream")
 
 	// Make the HTTP request.
 	url := fmt.Sprintf("%s/api/%s", c.baseURL, method)
-	httpReq, err := http.NewRequest("POST", url, bytes.NewReader(payload))
+	httpReq, err := http.NewRequest("POST", url, body)
 	if err != nil {
 		return nil, errors.E(op, errors.Invalid, err)
 	}
 	httpReq.Header = header
-	resp, err := c.client.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return nil, errors.E(op, errors.IO, err)
 	}
+	if resp.Header.Get(streamHeader) == streamBidi {
+		c.bidi.Store(true)
+	}
 	return resp, nil
 }
 
 // InvokeUnauthenticated implements Client.
 func (c *httpClient) InvokeUnauthenticated(method string, req, resp pb.Message) error {
+	start := time.Now()
+	err := c.invokeUnauthenticated(method, req, resp)
+	c.observeCall(method, start, req, resp, 0, err)
+	return err
+}
+
+func (c *httpClient) invokeUnauthenticated(method string, req, resp pb.Message) error {
 	const op errors.Op = "rpc.InvokeUnauthenticated"
 
 	httpResp, err := c.makeRequest(op, method, req, make(http.Header))
@@ -199,6 +251,13 @@ func (c *httpClient) InvokeUnauthenticated(method string, req, resp pb.Message)
 
 // Invoke implements Client.
 func (c *httpClient) Invoke(method string, req, resp pb.Message, stream ResponseChan, done <-chan struct{}) error {
+	start := time.Now()
+	err := c.invoke(method, req, resp, stream, done)
+	c.observeCall(method, start, req, resp, 0, err)
+	return err
+}
+
+func (c *httpClient) invoke(method string, req, resp pb.Message, stream ResponseChan, done <-chan struct{}) error {
 	const op errors.Op = "rpc.Invoke"
 
 	if (resp == nil) == (stream == nil) {
@@ -214,24 +273,11 @@ func (c *httpClient) Invoke(method string, req, resp pb.Message, stream Response
 			return err
 		}
 		if httpResp.StatusCode != http.StatusOK {
-			msg, _ := io.ReadAll(httpResp.Body)
-			httpResp.Body.Close()
-			if httpResp.Header.Get("Content-type") == "application/octet-stream" {
-				err := errors.UnmarshalError(msg)
-				if err.Error() == upspin.ErrNotSupported.Error() {
-					return upspin.ErrNotSupported
-				}
-				return errors.E(op, err)
-			}
-			// TODO(edpin,adg): unmarshal and check as it's more robust.
-			if bytes.Contains(msg, []byte(errUnauthenticated.Error())) {
-				// If the server restarted it will have forgotten about
-				// our session, and so our auth token becomes invalid.
-				// Invalidate the session and retry this request,
-				c.invalidateSession()
+			retry, err := c.responseError(op, httpResp)
+			if retry {
 				continue
 			}
-			return errors.E(op, errors.IO, errors.Errorf("%s: %s", httpResp.Status, msg))
+			return err
 		}
 		break
 	}
@@ -245,11 +291,124 @@ func (c *httpClient) Invoke(method string, req, resp pb.Message, stream Response
 		}
 	}
 
+	if err := c.checkResponseAuth(op, httpResp, needServerAuth); err != nil {
+		body.Close()
+		return err
+	}
+
+	if stream != nil {
+		go decodeStream(stream, body, done)
+	}
+	return nil
+}
+
+// InvokeBiStream implements Client.
+func (c *httpClient) InvokeBiStream(method string, req pb.Message) (*BiStream, error) {
+	const op errors.Op = "rpc.InvokeBiStream"
+
+	if !c.bidi.Load() {
+		return nil, upspin.ErrNotSupported
+	}
+	first, err := pb.Marshal(req)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	var httpResp *http.Response
+	var pw *io.PipeWriter
+	for i := 0; i < 2; i++ {
+		header, needServerAuth, err := c.authHeader(op)
+		if err != nil {
+			return nil, err
+		}
+		header.Set(streamHeader, streamBidi)
+
+		// The request body is the request message followed by
+		// whatever the caller sends later.
+		var pr *io.PipeReader
+		pr, pw = io.Pipe()
+		var lenBytes [4]byte
+		binary.BigEndian.PutUint32(lenBytes[:], uint32(len(first)))
+		body := io.MultiReader(bytes.NewReader(lenBytes[:]), bytes.NewReader(first), pr)
+
+		httpResp, err = c.doRequest(op, method, body, header)
+		if err != nil {
+			pw.Close()
+			return nil, err
+		}
+		if httpResp.StatusCode != http.StatusOK {
+			pw.Close()
+			retry, err := c.responseError(op, httpResp)
+			if retry {
+				continue
+			}
+			return nil, err
+		}
+		if err := c.checkResponseAuth(op, httpResp, needServerAuth); err != nil {
+			pw.Close()
+			httpResp.Body.Close()
+			return nil, err
+		}
+		break
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		// Authentication failed twice.
+		return nil, errors.E(op, errors.Permission, errUnauthenticated)
+	}
+
+	s := &BiStream{
+		r: httpResp.Body,
+		w: pw,
+		close: func() error {
+			pw.Close()
+			return httpResp.Body.Close()
+		},
+	}
+	// A stream begins with the bytes "OK".
+	var ok [2]byte
+	if _, err := io.ReadFull(httpResp.Body, ok[:]); err != nil {
+		s.Close()
+		return nil, errors.E(op, errors.IO, err)
+	}
+	if ok[0] != 'O' || ok[1] != 'K' {
+		s.Close()
+		return nil, errors.E(op, errors.IO, "unexpected stream preamble")
+	}
+	return s, nil
+}
+
+// responseError closes the body of an unsuccessful response and returns
+// the error it reports. It reports whether the request should be retried
+// because the server has forgotten our session.
+func (c *httpClient) responseError(op errors.Op, httpResp *http.Response) (retry bool, err error) {
+	msg, _ := io.ReadAll(httpResp.Body)
+	httpResp.Body.Close()
+	if httpResp.Header.Get("Content-type") == "application/octet-stream" {
+		err := errors.UnmarshalError(msg)
+		if err.Error() == upspin.ErrNotSupported.Error() {
+			return false, upspin.ErrNotSupported
+		}
+		return false, errors.E(op, err)
+	}
+	// TODO(edpin,adg): unmarshal and check as it's more robust.
+	if bytes.Contains(msg, []byte(errUnauthenticated.Error())) {
+		// If the server restarted it will have forgotten about
+		// our session, and so our auth token becomes invalid.
+		// Invalidate the session and retry this request,
+		c.invalidateSession()
+		return true, nil
+	}
+	return false, errors.E(op, errors.IO, errors.Errorf("%s: %s", httpResp.Status, msg))
+}
+
+// checkResponseAuth records the authentication token returned by the
+// server and, if talking to a proxy, checks that the proxy is running as
+// the same user.
+func (c *httpClient) checkResponseAuth(op errors.Op, httpResp *http.Response, needServerAuth bool) error {
 	token := httpResp.Header.Get(authTokenHeader)
 	if len(token) == 0 {
 		authErr := httpResp.Header.Get(authErrorHeader)
 		if len(authErr) > 0 {
-			body.Close()
 			return errors.E(op, errors.Permission, authErr)
 		}
 		// No authentication token returned, but no error either.
@@ -262,28 +421,26 @@ func (c *httpClient) Invoke(method string, req, resp pb.Message, stream Response
 	if needServerAuth {
 		msg, ok := httpResp.Header[authRequestHeader]
 		if !ok {
-			body.Close()
 			return errors.E(op, errors.Permission, "proxy server must authenticate")
 		}
 		if err := c.verifyServerUser(msg); err != nil {
-			body.Close()
 			return errors.E(op, errors.Permission, err)
 		}
 	}
-
-	if stream != nil {
-		go decodeStream(stream, body, done)
-	}
 	return nil
 }
 
+// readResponse reads and decodes a one-shot reply. The reply is read into
+// a pooled buffer, from which decoding copies what resp holds.
 func readResponse(op errors.Op, body io.ReadCloser, resp pb.Message) error {
-	respBytes, err := io.ReadAll(body)
+	buf := getBuffer()
+	defer buf.free()
+	err := buf.readFrom(body)
 	body.Close()
 	if err != nil {
 		return errors.E(op, errors.IO, err)
 	}
-	if err := pb.Unmarshal(respBytes, resp); err != nil {
+	if err := pb.Unmarshal(buf.b, resp); err != nil {
 		return errors.E(op, errors.Invalid, err)
 	}
 	return nil
@@ -324,8 +481,6 @@ func decodeStream(stream ResponseChan, r io.ReadCloser, done <-chan struct{}) {
 		}
 
 		l := binary.BigEndian.Uint32(msgLen[:])
-
-		const reasonableMessageSize = 1 << 26 // 64MB
 		if l > reasonableMessageSize {
 			stream.Error(errors.E(errors.Invalid, errors.Errorf("message too long (%d bytes)", l)))
 			return
@@ -9,10 +9,13 @@ package dirserver // import "upspin.io/rpc/dirserver"
 import (
 	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
 	pb "github.com/golang/protobuf/proto"
 
 	"upspin.io/config"
+	"upspin.io/dir/server/replica"
 	"upspin.io/errors"
 	"upspin.io/log"
 	"upspin.io/rpc"
@@ -20,6 +23,8 @@ import (
 	"upspin.io/upspin/proto"
 )
 
+var logger = log.For("rpc/dirserver")
+
 type server struct {
 	config upspin.Config
 
@@ -43,14 +48,25 @@ func New(cfg upspin.Config, dir upspin.DirServer, addr upspin.NetAddr) http.Hand
 	return rpc.NewServer(cfg, rpc.Service{
 		Name: "Dir",
 		Methods: map[string]rpc.Method{
-			"Delete":      s.Delete,
-			"Glob":        s.Glob,
-			"Lookup":      s.Lookup,
-			"Put":         s.Put,
-			"WhichAccess": s.WhichAccess,
+			"AbortTx":          s.AbortTx,
+			"AccessHistory":    s.AccessHistory,
+			"ApplyBatch":       s.ApplyBatch,
+			"CommitTx":         s.CommitTx,
+			"Delete":           s.Delete,
+			"Glob":             s.Glob,
+			"Lookup":           s.Lookup,
+			"PreparePut":       s.PreparePut,
+			"Put":              s.Put,
+			"WhichAccess":      s.WhichAccess,
+			"WhichAccessBatch": s.WhichAccessBatch,
 		},
 		Streams: map[string]rpc.Stream{
-			"Watch": s.Watch,
+			"Watch":      s.Watch,
+			"WatchMulti": s.WatchMulti,
+			"Replicate":  s.Replicate,
+		},
+		BiStreams: map[string]rpc.BiStreamMethod{
+			"Watch": s.WatchBidi,
 		},
 	})
 }
@@ -79,7 +95,7 @@ func (s *server) Lookup(session rpc.Session, reqBytes []byte) (pb.Message, error
 	}
 	op := logf(session, "Lookup(%q)", req.Name)
 
-	return op.entryError(dir.Lookup(upspin.PathName(req.Name)))
+	return op.entryError(dir.Lookup(pathName(req.Name)))
 }
 
 // Put implements proto.DirServer.
@@ -93,6 +109,14 @@ func (s *server) Put(session rpc.Session, reqBytes []byte) (pb.Message, error) {
 	if err != nil {
 		return &proto.EntryError{Error: errors.MarshalError(err)}, nil
 	}
+	if req.SkipLinkCheck {
+		op := logf(session, "PutUncheckedLink(%q)", entry.Name)
+		if p, ok := dir.(upspin.UncheckedLinkPutter); ok {
+			return op.entryError(p.PutUncheckedLink(entry))
+		}
+		// The server does not check links.
+		return op.entryError(dir.Put(entry))
+	}
 	op := logf(session, "Put(%q)", entry.Name)
 
 	return op.entryError(dir.Put(entry))
@@ -105,9 +129,20 @@ func (s *server) Glob(session rpc.Session, reqBytes []byte) (pb.Message, error)
 	if err != nil {
 		return nil, err
 	}
-	op := logf(session, "Glob(%q)", req.Pattern)
+	op := logf(session, "Glob(%q, shallow=%t)", req.Pattern, req.Shallow)
 
-	entries, globErr := dir.Glob(req.Pattern)
+	var entries []*upspin.DirEntry
+	var globErr error
+	if g, ok := dir.(upspin.ShallowGlobber); ok && req.Shallow {
+		entries, globErr = g.GlobShallow(req.Pattern)
+	} else {
+		entries, globErr = dir.Glob(req.Pattern)
+		if req.Shallow {
+			for _, e := range entries {
+				e.MarkIncomplete()
+			}
+		}
+	}
 	if globErr != nil && globErr != upspin.ErrFollowLink {
 		op.log(globErr)
 		return globError(globErr), nil
@@ -125,6 +160,161 @@ func (s *server) Glob(session rpc.Session, reqBytes []byte) (pb.Message, error)
 	}, nil
 }
 
+// ApplyBatch implements proto.DirServer.
+func (s *server) ApplyBatch(session rpc.Session, reqBytes []byte) (pb.Message, error) {
+	var req proto.DirApplyBatchRequest
+	dir, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "ApplyBatch(%d changes)", len(req.Changes))
+
+	b, ok := dir.(upspin.DirBatcher)
+	if !ok {
+		return globError(upspin.ErrNotSupported), nil
+	}
+	changes, err := upspinDirChanges(req.Changes)
+	if err != nil {
+		op.log(err)
+		return globError(err), nil
+	}
+	entries, err := b.ApplyBatch(changes)
+	if err != nil {
+		op.log(err)
+		return globError(err), nil
+	}
+	eb, err := proto.DirEntryBytes(entries)
+	if err != nil {
+		op.log(err)
+		return globError(err), nil
+	}
+	return &proto.EntriesError{Entries: eb}, nil
+}
+
+// upspinDirChanges converts changes from their protocol buffer form.
+func upspinDirChanges(pc []*proto.DirChange) ([]upspin.DirChange, error) {
+	changes := make([]upspin.DirChange, len(pc))
+	for i, c := range pc {
+		entry, err := proto.UpspinDirEntry(c.Entry)
+		if err != nil {
+			return nil, err
+		}
+		changes[i] = upspin.DirChange{
+			Delete: c.Delete,
+			Entry:  entry,
+		}
+	}
+	return changes, nil
+}
+
+// PreparePut implements proto.DirServer.
+func (s *server) PreparePut(session rpc.Session, reqBytes []byte) (pb.Message, error) {
+	var req proto.DirPrepareRequest
+	dir, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "PreparePut(%q, %d changes)", req.TxId, len(req.Changes))
+
+	tr, ok := dir.(upspin.DirTransactor)
+	if !ok {
+		return globError(upspin.ErrNotSupported), nil
+	}
+	changes, err := upspinDirChanges(req.Changes)
+	if err != nil {
+		op.log(err)
+		return globError(err), nil
+	}
+	if err := tr.PreparePut(req.TxId, changes); err != nil {
+		op.log(err)
+		return globError(err), nil
+	}
+	return &proto.EntriesError{}, nil
+}
+
+// CommitTx implements proto.DirServer.
+func (s *server) CommitTx(session rpc.Session, reqBytes []byte) (pb.Message, error) {
+	var req proto.DirTxRequest
+	dir, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "CommitTx(%q)", req.TxId)
+
+	tr, ok := dir.(upspin.DirTransactor)
+	if !ok {
+		return globError(upspin.ErrNotSupported), nil
+	}
+	entries, err := tr.CommitTx(req.TxId)
+	if err != nil {
+		op.log(err)
+		return globError(err), nil
+	}
+	eb, err := proto.DirEntryBytes(entries)
+	if err != nil {
+		op.log(err)
+		return globError(err), nil
+	}
+	return &proto.EntriesError{Entries: eb}, nil
+}
+
+// AbortTx implements proto.DirServer.
+func (s *server) AbortTx(session rpc.Session, reqBytes []byte) (pb.Message, error) {
+	var req proto.DirTxRequest
+	dir, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "AbortTx(%q)", req.TxId)
+
+	tr, ok := dir.(upspin.DirTransactor)
+	if !ok {
+		return globError(upspin.ErrNotSupported), nil
+	}
+	if err := tr.AbortTx(req.TxId); err != nil {
+		op.log(err)
+		return globError(err), nil
+	}
+	return &proto.EntriesError{}, nil
+}
+
+// AccessHistory implements proto.DirServer.
+func (s *server) AccessHistory(session rpc.Session, reqBytes []byte) (pb.Message, error) {
+	var req proto.DirAccessHistoryRequest
+	dir, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "AccessHistory(%q, %d, %d)", req.Name, req.Since, req.Limit)
+
+	h, ok := dir.(upspin.AccessHistorian)
+	if !ok {
+		return &proto.DirAccessHistoryResponse{Error: errors.MarshalError(upspin.ErrNotSupported)}, nil
+	}
+	var since time.Time
+	if req.Since != 0 {
+		since = time.Unix(0, req.Since)
+	}
+	records, err := h.AccessHistory(pathName(req.Name), since, int(req.Limit))
+	if err != nil {
+		op.log(err)
+		return &proto.DirAccessHistoryResponse{Error: errors.MarshalError(err)}, nil
+	}
+	resp := &proto.DirAccessHistoryResponse{
+		Records: make([]*proto.DirAccessRecord, len(records)),
+	}
+	for i, r := range records {
+		resp.Records[i] = &proto.DirAccessRecord{
+			Time:    r.Time.UnixNano(),
+			User:    string(r.User),
+			Op:      r.Op,
+			Name:    string(r.Name),
+			Success: r.Success,
+		}
+	}
+	return resp, nil
+}
+
 func globError(err error) *proto.EntriesError {
 	return &proto.EntriesError{Error: errors.MarshalError(err)}
 }
@@ -138,12 +328,124 @@ func (s *server) Watch(session rpc.Session, reqBytes []byte, done <-chan struct{
 	}
 	op := logf(session, "Watch(%q, %d)", req.Name, req.Sequence)
 
-	events, err := dir.Watch(upspin.PathName(req.Name), req.Sequence, done)
+	events, err := dir.Watch(pathName(req.Name), req.Sequence, done)
+	if err != nil {
+		op.log(err)
+		return nil, err
+	}
+	return eventStream(op, events), nil
+}
+
+// WatchBidi implements proto.Watch over a bidirectional stream, on which
+// the client may send DirWatchControl messages to restart the watch at
+// another sequence, to withhold puts or deletes, or just to keep the
+// stream alive.
+func (s *server) WatchBidi(session rpc.Session, reqBytes []byte, recv <-chan []byte, done <-chan struct{}) (<-chan pb.Message, error) {
+	var req proto.DirWatchRequest
+	dir, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "Watch(%q, %d) bidi", req.Name, req.Sequence)
+
+	name := pathName(req.Name)
+	watchDone := make(chan struct{})
+	events, err := dir.Watch(name, req.Sequence, watchDone)
+	if err != nil {
+		op.log(err)
+		return nil, err
+	}
+	out := make(chan pb.Message)
+	go func() {
+		defer close(out)
+		defer func() { close(watchDone) }()
+		var skipPuts, skipDeletes bool
+		for {
+			select {
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				if e.Error == nil && (e.Delete && skipDeletes || !e.Delete && skipPuts) {
+					continue
+				}
+				ep, err := proto.EventProto(&e)
+				if err != nil {
+					op.logf("error converting event to proto: %v", err)
+					return
+				}
+				select {
+				case out <- ep:
+				case <-done:
+					return
+				}
+			case b, ok := <-recv:
+				if !ok {
+					// The client has stopped sending.
+					recv = nil
+					continue
+				}
+				var ctl proto.DirWatchControl
+				if err := pb.Unmarshal(b, &ctl); err != nil {
+					op.logf("bad control message: %v", err)
+					return
+				}
+				if ctl.Filter {
+					skipPuts, skipDeletes = ctl.SkipPuts, ctl.SkipDeletes
+				}
+				if !ctl.Restart {
+					continue
+				}
+				close(watchDone)
+				watchDone = make(chan struct{})
+				events, err = dir.Watch(name, ctl.Sequence, watchDone)
+				if err != nil {
+					op.log(err)
+					select {
+					case out <- &proto.Event{Error: errors.MarshalError(err)}:
+					case <-done:
+					}
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// WatchMulti implements proto.WatchMulti.
+func (s *server) WatchMulti(session rpc.Session, reqBytes []byte, done <-chan struct{}) (<-chan pb.Message, error) {
+	var req proto.DirWatchMultiRequest
+	dir, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "WatchMulti(%d paths)", len(req.Paths))
+
+	mw, ok := dir.(upspin.MultiWatcher)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	paths := make([]upspin.WatchPath, len(req.Paths))
+	for i, p := range req.Paths {
+		paths[i] = upspin.WatchPath{
+			Name:     pathName(p.Name),
+			Sequence: p.Sequence,
+		}
+	}
+	events, err := mw.WatchMulti(paths, done)
 	if err != nil {
 		op.log(err)
 		return nil, err
 	}
+	return eventStream(op, events), nil
+}
 
+// eventStream converts the events to protos and sends them on the
+// returned channel, which is closed after events is.
+func eventStream(op operation, events <-chan upspin.Event) <-chan pb.Message {
 	out := make(chan pb.Message)
 	go func() {
 		defer close(out)
@@ -156,6 +458,40 @@ func (s *server) Watch(session rpc.Session, reqBytes []byte, done <-chan struct{
 			out <- ep
 		}
 	}()
+	return out
+}
+
+// Replicate implements proto.Replicate.
+func (s *server) Replicate(session rpc.Session, reqBytes []byte, done <-chan struct{}) (<-chan pb.Message, error) {
+	var req proto.DirReplicateRequest
+	dir, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "Replicate(%d users)", len(req.States))
+
+	src, ok := dir.(replica.Source)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	updates, err := src.Replicate(replica.UserStates(&req), done)
+	if err != nil {
+		op.log(err)
+		return nil, err
+	}
+
+	out := make(chan pb.Message)
+	go func() {
+		defer close(out)
+		for u := range updates {
+			up, err := replica.UpdateProto(&u)
+			if err != nil {
+				op.logf("error converting update to proto: %v", err)
+				return
+			}
+			out <- up
+		}
+	}()
 	return out, nil
 }
 
@@ -168,7 +504,7 @@ func (s *server) Delete(session rpc.Session, reqBytes []byte) (pb.Message, error
 	}
 	op := logf(session, "Delete(%q)", req.Name)
 
-	return op.entryError(dir.Delete(upspin.PathName(req.Name)))
+	return op.entryError(dir.Delete(pathName(req.Name)))
 }
 
 // WhichAccess implements proto.DirServer.
@@ -180,24 +516,89 @@ func (s *server) WhichAccess(session rpc.Session, reqBytes []byte) (pb.Message,
 	}
 	op := logf(session, "WhichAccess(%q)", req.Name)
 
-	return op.entryError(dir.WhichAccess(upspin.PathName(req.Name)))
+	return op.entryError(dir.WhichAccess(pathName(req.Name)))
+}
+
+// WhichAccessBatch implements proto.DirServer.
+func (s *server) WhichAccessBatch(session rpc.Session, reqBytes []byte) (pb.Message, error) {
+	var req proto.DirWhichAccessBatchRequest
+	dir, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "WhichAccessBatch(%d names)", len(req.Names))
+
+	b, ok := dir.(upspin.WhichAccessBatcher)
+	if !ok {
+		return &proto.DirWhichAccessBatchResponse{Error: errors.MarshalError(upspin.ErrNotSupported)}, nil
+	}
+	names := make([]upspin.PathName, len(req.Names))
+	for i, name := range req.Names {
+		names[i] = pathName(name)
+	}
+	results, err := b.WhichAccessBatch(names)
+	if err != nil {
+		op.log(err)
+		return &proto.DirWhichAccessBatchResponse{Error: errors.MarshalError(err)}, nil
+	}
+
+	// Send each distinct entry once, referring to it by index.
+	resp := &proto.DirWhichAccessBatchResponse{
+		Results: make([]*proto.DirWhichAccessResult, len(results)),
+	}
+	index := make(map[*upspin.DirEntry]int32)
+	for i, res := range results {
+		r := &proto.DirWhichAccessResult{Error: errors.MarshalError(res.Error)}
+		if res.Entry != nil {
+			n, ok := index[res.Entry]
+			if !ok {
+				b, err := res.Entry.Marshal()
+				if err != nil {
+					op.log(err)
+					return &proto.DirWhichAccessBatchResponse{Error: errors.MarshalError(err)}, nil
+				}
+				resp.Entries = append(resp.Entries, b)
+				n = int32(len(resp.Entries))
+				index[res.Entry] = n
+			}
+			r.Entry = n
+		}
+		resp.Results[i] = r
+	}
+	return resp, nil
 }
 
 func logf(sess rpc.Session, format string, args ...interface{}) operation {
 	op := fmt.Sprintf("rpc/dirserver: %q: dir.", sess.User())
 	op += fmt.Sprintf(format, args...)
-	log.Debug.Print(op)
+	logger.Debug.Print(op)
 	return operation(op)
 }
 
 type operation string
 
 func (op operation) log(err error) {
-	log.Debug.Printf("%s failed: %s", op, err)
+	logger.Debug.Printf("%s failed: %s", op, err)
 }
 
 func (op operation) logf(format string, args ...interface{}) {
-	log.Debug.Printf("%s: "+format, append([]interface{}{op}, args...)...)
+	logger.Debug.Printf("%s: "+format, append([]interface{}{op}, args...)...)
+}
+
+// pathName converts a name from a request to a path name, removing any
+// trailing slashes, which many HTTP and WebDAV clients append to directory
+// names, so that "user@example.com/dir/" names the same item as
+// "user@example.com/dir". The slash after the user name in a root, as in
+// "user@example.com/", is kept.
+func pathName(name string) upspin.PathName {
+	for len(name) > 1 && name[len(name)-1] == '/' {
+		trimmed := name[:len(name)-1]
+		if !strings.Contains(trimmed, "/") {
+			break
+		}
+		name = trimmed
+	}
+	return upspin.PathName(name)
 }
 
 // entryError performs the common operation of converting a directory entry
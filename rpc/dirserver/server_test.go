@@ -0,0 +1,114 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package dirserver
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	pb "github.com/golang/protobuf/proto"
+
+	"upspin.io/bind"
+	"upspin.io/errors"
+	"upspin.io/rpc"
+	"upspin.io/test/testenv"
+	"upspin.io/upspin"
+	"upspin.io/upspin/proto"
+)
+
+const owner = "aly@example.com"
+
+func TestPathName(t *testing.T) {
+	tests := []struct {
+		in   string
+		want upspin.PathName
+	}{
+		{"user@example.com/dir", "user@example.com/dir"},
+		{"user@example.com/dir/", "user@example.com/dir"},
+		{"user@example.com/dir///", "user@example.com/dir"},
+		{"user@example.com/a/b/", "user@example.com/a/b"},
+		{"user@example.com/", "user@example.com/"},
+		{"user@example.com//", "user@example.com/"},
+		{"user@example.com", "user@example.com"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if got := pathName(test.in); got != test.want {
+			t.Errorf("pathName(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestTrailingSlash(t *testing.T) {
+	env, err := testenv.New(&testenv.Setup{
+		OwnerName: owner,
+		Packing:   upspin.EEPack,
+		Kind:      "inprocess",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer env.Exit()
+	r := testenv.NewRunner()
+	r.AddUser(env.Config)
+	r.As(owner)
+	r.MakeDirectory(owner + "/dir")
+	r.Put(owner+"/dir/file", "hello")
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+
+	dir, err := bind.DirServer(env.Config, env.Config.DirEndpoint())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &server{config: env.Config, dir: dir}
+	session := rpc.NewSession(owner, time.Now().Add(time.Hour), "token", &upspin.Endpoint{}, nil)
+
+	lookup := func(name string) *proto.EntryError {
+		t.Helper()
+		req, err := pb.Marshal(&proto.DirLookupRequest{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := s.Lookup(session, req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.(*proto.EntryError)
+	}
+
+	for _, name := range []string{owner + "/dir", owner + "/dir/file", owner + "/"} {
+		want := lookup(name)
+		if want.Error != nil {
+			t.Fatalf("Lookup(%q): %v", name, errors.UnmarshalError(want.Error))
+		}
+		got := lookup(name + "/")
+		if got.Error != nil {
+			t.Errorf("Lookup(%q): %v", name+"/", errors.UnmarshalError(got.Error))
+			continue
+		}
+		if !bytes.Equal(got.Entry, want.Entry) {
+			t.Errorf("Lookup(%q) and Lookup(%q) returned different entries", name+"/", name)
+		}
+	}
+
+	// Delete also accepts the trailing slash.
+	req, err := pb.Marshal(&proto.DirDeleteRequest{Name: owner + "/dir/file/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.Delete(session, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := resp.(*proto.EntryError).Error; e != nil {
+		t.Fatalf("Delete: %v", errors.UnmarshalError(e))
+	}
+	if got := lookup(owner + "/dir/file"); !errors.Is(errors.NotExist, errors.UnmarshalError(got.Error)) {
+		t.Errorf("Lookup after Delete: got %v, want NotExist", errors.UnmarshalError(got.Error))
+	}
+}
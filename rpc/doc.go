@@ -28,6 +28,26 @@ Each encoded message is preceded by a four byte, big-endian-encoded int32
 that describes the length of the following encoded protocol buffer. The
 stream is considered closed when the HTTP response stream ends.
 
+Some streaming methods, such as the Dir server's Watch, may also be served
+as bidirectional streams. A server that offers them sets the response
+header 'X-Upspin-Stream: bidi' on every reply. A client that has seen that
+header may then send a request with the same header, in which case the
+request body is itself a stream: the request message, preceded by its
+four byte length, followed by further length-prefixed messages that the
+client sends for as long as the stream is open. The response is as for a
+one-way stream. A server that cannot serve the method bidirectionally
+replies at once with an error and does not read the request body.
+
+Some methods, such as the Store server's Get, may reply with a byte
+stream, so that large data need not be held in memory by either end. A
+client asks for one by sending the request header 'X-Upspin-Stream: bytes'.
+A server that streams the method replies with the same header and a body
+holding the response message, preceded by its four byte length, followed
+by the data, sent with chunked transfer encoding. If the server fails
+while sending the data it aborts the response, so a body that ends early
+is an error. A server that does not stream the method ignores the request
+header and replies as usual.
+
 If an error occurs while processing a request, the server returns a 500
 Internal Server Error status code and the response body contains the error
 string.
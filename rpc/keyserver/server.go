@@ -7,15 +7,18 @@
 package keyserver // import "upspin.io/rpc/keyserver"
 
 import (
+	"encoding/json"
 	"expvar"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	pb "github.com/golang/protobuf/proto"
 
 	"upspin.io/config"
 	"upspin.io/errors"
+	"upspin.io/key/server/repl"
 	"upspin.io/log"
 	"upspin.io/rpc"
 	"upspin.io/serverutil"
@@ -56,10 +59,17 @@ func New(cfg upspin.Config, key upspin.KeyServer, addr upspin.NetAddr) http.Hand
 	return rpc.NewServer(cfg, rpc.Service{
 		Name: "Key",
 		Methods: map[string]rpc.Method{
-			"Put": s.Put,
+			"Put":         s.Put,
+			"RevokeKey":   s.RevokeKey,
+			"ExportSince": s.ExportSince,
+			"History":     s.History,
+
+			"TransferDomain": s.TransferDomain,
+			"ImportDomain":   s.ImportDomain,
 		},
 		UnauthenticatedMethods: map[string]rpc.UnauthenticatedMethod{
-			"Lookup": s.Lookup,
+			"Lookup":   s.Lookup,
+			"LookupAt": s.LookupAt,
 		},
 		Lookup: func(userName upspin.UserName) (upspin.PublicKey, error) {
 			user, err := key.Lookup(userName)
@@ -68,6 +78,13 @@ func New(cfg upspin.Config, key upspin.KeyServer, addr upspin.NetAddr) http.Hand
 			}
 			return user.PublicKey, nil
 		},
+		Revoked: func(userName upspin.UserName) ([]upspin.PublicKey, error) {
+			user, err := key.Lookup(userName)
+			if err != nil {
+				return nil, err
+			}
+			return user.Revoked, nil
+		},
 	})
 }
 
@@ -128,9 +145,10 @@ func (s *server) Lookup(reqBytes []byte) (pb.Message, error) {
 		if doLog {
 			logf(nil, "Lookup(%q) failed: %s", req.UserName, err)
 		}
-		if errors.Is(errors.NotExist, err) {
+		if errors.Is(errors.NotExist, err) && !strings.Contains(err.Error(), upspin.KeyMovedPrefix) {
 			// The end user doesn't care about the backend
-			// error if it's a "not exist" error.
+			// error if it's a "not exist" error, unless it
+			// says where the user's domain has moved.
 			err = errors.E(errors.Op("rpc/keyserver"), upspin.UserName(req.UserName), errors.NotExist)
 		}
 		return &proto.KeyLookupResponse{Error: errors.MarshalError(err)}, nil
@@ -138,6 +156,57 @@ func (s *server) Lookup(reqBytes []byte) (pb.Message, error) {
 	return &proto.KeyLookupResponse{User: proto.UserProto(user)}, nil
 }
 
+// LookupAt implements proto.KeyServer, and does not do any authentication.
+func (s *server) LookupAt(reqBytes []byte) (pb.Message, error) {
+	var req proto.KeyLookupAtRequest
+	if err := pb.Unmarshal(reqBytes, &req); err != nil {
+		return nil, err
+	}
+	t := proto.UpspinTime(req.Time)
+	s.incLookupCounters()
+	doLog := s.lookupLogCounter.Rate() < lookupLogMaxRate
+	if doLog {
+		s.lookupLogCounter.Add(1)
+		logf(nil, "LookupAt(%q, %v)", req.UserName, t)
+	}
+
+	h, ok := s.key.(upspin.KeyHistorian)
+	if !ok {
+		return &proto.KeyLookupResponse{Error: errors.MarshalError(upspin.ErrNotSupported)}, nil
+	}
+	user, err := h.LookupAt(upspin.UserName(req.UserName), t)
+	if err != nil {
+		if doLog {
+			logf(nil, "LookupAt(%q, %v) failed: %s", req.UserName, t, err)
+		}
+		return &proto.KeyLookupResponse{Error: errors.MarshalError(err)}, nil
+	}
+	return &proto.KeyLookupResponse{User: proto.UserProto(user)}, nil
+}
+
+// History implements proto.KeyServer. The underlying server decides
+// whether the caller may see the history.
+func (s *server) History(session rpc.Session, reqBytes []byte) (pb.Message, error) {
+	var req proto.KeyHistoryRequest
+	key, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "History(%q)", req.UserName)
+
+	h, ok := key.(upspin.KeyHistorian)
+	if !ok {
+		op.log(upspin.ErrNotSupported)
+		return &proto.KeyHistoryResponse{Error: errors.MarshalError(upspin.ErrNotSupported)}, nil
+	}
+	versions, err := h.History(upspin.UserName(req.UserName))
+	if err != nil {
+		op.log(err)
+		return &proto.KeyHistoryResponse{Error: errors.MarshalError(err)}, nil
+	}
+	return &proto.KeyHistoryResponse{Versions: proto.UserVersionProtos(versions)}, nil
+}
+
 // Put implements proto.KeyServer.
 func (s *server) Put(session rpc.Session, reqBytes []byte) (pb.Message, error) {
 	var req proto.KeyPutRequest
@@ -157,6 +226,128 @@ func (s *server) Put(session rpc.Session, reqBytes []byte) (pb.Message, error) {
 	return &proto.KeyPutResponse{}, nil
 }
 
+// RevokeKey implements proto.KeyServer.
+func (s *server) RevokeKey(session rpc.Session, reqBytes []byte) (pb.Message, error) {
+	var req proto.KeyRevokeRequest
+	key, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "RevokeKey(%q)", req.UserName)
+	s.incPutCounters()
+
+	r, ok := key.(upspin.KeyRevoker)
+	if !ok {
+		op.log(upspin.ErrNotSupported)
+		return &proto.KeyRevokeResponse{Error: errors.MarshalError(upspin.ErrNotSupported)}, nil
+	}
+	err = r.RevokeKey(upspin.UserName(req.UserName), upspin.PublicKey(req.PublicKey), req.Signature)
+	if err != nil {
+		op.log(err)
+		return &proto.KeyRevokeResponse{Error: errors.MarshalError(err)}, nil
+	}
+	return &proto.KeyRevokeResponse{}, nil
+}
+
+// TransferDomain implements proto.KeyServer.
+func (s *server) TransferDomain(session rpc.Session, reqBytes []byte) (pb.Message, error) {
+	var req proto.KeyTransferDomainRequest
+	key, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "TransferDomain(%q, %q)", req.Domain, req.Dest)
+
+	t, ok := key.(upspin.KeyDomainTransferrer)
+	if !ok {
+		op.log(upspin.ErrNotSupported)
+		return &proto.KeyTransferDomainResponse{Error: errors.MarshalError(upspin.ErrNotSupported)}, nil
+	}
+	err = t.TransferDomain(req.Domain, upspin.NetAddr(req.Dest), req.Signature)
+	if err != nil {
+		op.log(err)
+		return &proto.KeyTransferDomainResponse{Error: errors.MarshalError(err)}, nil
+	}
+	return &proto.KeyTransferDomainResponse{}, nil
+}
+
+// ImportDomain implements proto.KeyServer. The destination named in the
+// request must be this server, so that a signature authorizing a transfer
+// to another server cannot be used here.
+func (s *server) ImportDomain(session rpc.Session, reqBytes []byte) (pb.Message, error) {
+	var req proto.KeyImportDomainRequest
+	key, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, err
+	}
+	op := logf(session, "ImportDomain(%q, %q, %d users)", req.Domain, req.Dest, len(req.Users))
+
+	importError := func(err error) (pb.Message, error) {
+		op.log(err)
+		return &proto.KeyImportDomainResponse{Error: errors.MarshalError(err)}, nil
+	}
+	if s.endpoint.NetAddr != "" && upspin.NetAddr(req.Dest) != s.endpoint.NetAddr {
+		return importError(errors.E(errors.Permission, errors.Errorf("transfer is to %s, not this server", req.Dest)))
+	}
+	if req.Admin == nil {
+		return importError(errors.E(errors.Invalid, "no administrator"))
+	}
+	t, ok := key.(upspin.KeyDomainTransferrer)
+	if !ok {
+		return importError(upspin.ErrNotSupported)
+	}
+	users := make([]*upspin.User, len(req.Users))
+	for i, u := range req.Users {
+		users[i] = proto.UpspinUser(u)
+	}
+	err = t.ImportDomain(req.Domain, upspin.NetAddr(req.Dest), proto.UpspinUser(req.Admin), req.Signature, users)
+	if err != nil {
+		return importError(err)
+	}
+	return &proto.KeyImportDomainResponse{}, nil
+}
+
+// exporter is implemented by KeyServers that replicate their user records,
+// such as those of package upspin.io/key/server.
+type exporter interface {
+	ExportSince(since uint64) ([]*repl.Record, error)
+}
+
+// ExportSince implements proto.KeyServer. Only the server's own user,
+// which its peers run as, may call it.
+func (s *server) ExportSince(session rpc.Session, reqBytes []byte) (pb.Message, error) {
+	var req proto.KeyExportRequest
+	if err := pb.Unmarshal(reqBytes, &req); err != nil {
+		return nil, err
+	}
+	op := logf(session, "ExportSince(%d)", req.Since)
+
+	exportError := func(err error) (pb.Message, error) {
+		op.log(err)
+		return &proto.KeyExportResponse{Error: errors.MarshalError(err)}, nil
+	}
+	if session.User() != s.config.UserName() {
+		return exportError(errors.E(errors.Permission, session.User(), "only the server's user may export records"))
+	}
+	exp, ok := s.key.(exporter)
+	if !ok {
+		return exportError(upspin.ErrNotSupported)
+	}
+	recs, err := exp.ExportSince(req.Since)
+	if err != nil {
+		return exportError(err)
+	}
+	resp := &proto.KeyExportResponse{}
+	for _, rec := range recs {
+		b, err := json.Marshal(rec)
+		if err != nil {
+			return exportError(errors.E(errors.Invalid, err))
+		}
+		resp.Records = append(resp.Records, b)
+	}
+	return resp, nil
+}
+
 func putError(err error) *proto.KeyPutResponse {
 	return &proto.KeyPutResponse{Error: errors.MarshalError(err)}
 }
@@ -26,3 +26,21 @@ func PublicUserKeyService(cfg upspin.Config) func(userName upspin.UserName) (ups
 		return u.PublicKey, nil
 	}
 }
+
+// PublicUserRevokedKeys returns a function that looks up the keys a user
+// has revoked. Like PublicUserKeyService, the function returned is bound to
+// a well-known public Upspin user service.
+func PublicUserRevokedKeys(cfg upspin.Config) func(userName upspin.UserName) ([]upspin.PublicKey, error) {
+	const op errors.Op = "rpc.PublicUserRevokedKeys"
+	return func(userName upspin.UserName) ([]upspin.PublicKey, error) {
+		key, err := bind.KeyServer(cfg, cfg.KeyEndpoint())
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		u, err := key.Lookup(userName)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		return u.Revoked, nil
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"encoding/binary"
+	"io"
+	"net/http"
+	"time"
+
+	pb "github.com/golang/protobuf/proto"
+
+	"upspin.io/errors"
+)
+
+// serveReader replies to a request for a byte stream with the message
+// returned by m, preceded by its length, followed by the bytes of its
+// reader. The response has no declared length, so it is sent with chunked
+// transfer encoding as the bytes are read.
+func serveReader(m ReaderMethod, sess Session, w http.ResponseWriter, body []byte) {
+	msg, rc, err := m(sess, body)
+	if err != nil {
+		sendError(w, err)
+		return
+	}
+	if rc != nil {
+		defer rc.Close()
+	}
+	b, err := pb.Marshal(msg)
+	if err != nil {
+		logger.Error.Printf("error encoding response: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set(streamHeader, streamBytes)
+	var lenBytes [4]byte
+	binary.BigEndian.PutUint32(lenBytes[:], uint32(len(b)))
+	if _, err := w.Write(lenBytes[:]); err != nil {
+		return
+	}
+	if _, err := w.Write(b); err != nil || rc == nil {
+		return
+	}
+	if _, err := io.Copy(w, rc); err != nil {
+		// Abort the response rather than end it cleanly,
+		// so the client cannot mistake part of the data for all of it.
+		logger.Debug.Printf("rpc: error sending byte stream: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+// InvokeReader implements Client.
+func (c *httpClient) InvokeReader(method string, req, resp pb.Message) (io.ReadCloser, error) {
+	start := time.Now()
+	rc, err := c.invokeReader(method, req, resp)
+	if rc == nil || observer.Load() == nil {
+		c.observeCall(method, start, req, resp, 0, err)
+		return rc, err
+	}
+	// Report the call once the stream has been read.
+	return &observedReader{
+		ReadCloser: rc,
+		c:          c,
+		method:     method,
+		start:      start,
+		req:        req,
+		resp:       resp,
+	}, nil
+}
+
+func (c *httpClient) invokeReader(method string, req, resp pb.Message) (io.ReadCloser, error) {
+	const op errors.Op = "rpc.InvokeReader"
+
+	var httpResp *http.Response
+	for i := 0; i < 2; i++ {
+		header, needServerAuth, err := c.authHeader(op)
+		if err != nil {
+			return nil, err
+		}
+		header.Set(streamHeader, streamBytes)
+		httpResp, err = c.makeRequest(op, method, req, header)
+		if err != nil {
+			return nil, err
+		}
+		if httpResp.StatusCode != http.StatusOK {
+			retry, err := c.responseError(op, httpResp)
+			if retry {
+				continue
+			}
+			return nil, err
+		}
+		if err := c.checkResponseAuth(op, httpResp, needServerAuth); err != nil {
+			httpResp.Body.Close()
+			return nil, err
+		}
+		break
+	}
+	if httpResp.StatusCode != http.StatusOK {
+		// Authentication failed twice.
+		return nil, errors.E(op, errors.Permission, errUnauthenticated)
+	}
+
+	body := httpResp.Body
+	if httpResp.Header.Get(streamHeader) != streamBytes {
+		// The server does not stream this method.
+		return nil, readResponse(op, body, resp)
+	}
+	var lenBytes [4]byte
+	if _, err := io.ReadFull(body, lenBytes[:]); err != nil {
+		body.Close()
+		return nil, errors.E(op, errors.IO, err)
+	}
+	l := binary.BigEndian.Uint32(lenBytes[:])
+	if l > reasonableMessageSize {
+		body.Close()
+		return nil, errors.E(op, errors.Invalid, errors.Errorf("message too long (%d bytes)", l))
+	}
+	b := make([]byte, l)
+	if _, err := io.ReadFull(body, b); err != nil {
+		body.Close()
+		return nil, errors.E(op, errors.IO, err)
+	}
+	if err := pb.Unmarshal(b, resp); err != nil {
+		body.Close()
+		return nil, errors.E(op, errors.Invalid, err)
+	}
+	return body, nil
+}
@@ -8,15 +8,19 @@ import (
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
+	"expvar"
 	"fmt"
 	"io"
 	"math/big"
 	"net/http"
+	"os"
+	"runtime/debug"
 	"strings"
 	"time"
 
 	pb "github.com/golang/protobuf/proto"
 
+	"upspin.io/cache"
 	"upspin.io/errors"
 	"upspin.io/factotum"
 	"upspin.io/log"
@@ -24,13 +28,23 @@ import (
 	"upspin.io/valid"
 )
 
+var logger = log.For("rpc")
+
 // Errors returned in case of various authentication failure scenarios.
 var (
 	errUnauthenticated  = errors.Str("user not authenticated")
 	errExpired          = errors.Str("auth token expired")
 	errMissingSignature = errors.Str("missing or invalid signature")
+	errRevoked          = errors.Str("public key has been revoked")
 
 	authTokenDuration = 20 * time.Hour // Max duration an auth token lasts.
+
+	// revocationCheckInterval is how long a user's list of revoked keys
+	// is cached before being fetched again from the key server.
+	revocationCheckInterval = 60 * time.Second
+
+	// panicCount counts the panics recovered while serving requests.
+	panicCount = expvar.NewInt("panic_count")
 )
 
 const (
@@ -71,13 +85,33 @@ type Service struct {
 	// The streaming RPC methods to serve.
 	Streams map[string]Stream
 
+	// The bidirectional streaming RPC methods to serve. A name may also
+	// appear in Streams, in which case clients that do not ask for a
+	// bidirectional stream are served by the one-way Stream.
+	BiStreams map[string]BiStreamMethod
+
+	// The RPC methods that reply with a stream of bytes. A name may also
+	// appear in Methods, in which case clients that do not ask for a byte
+	// stream are served by the one-shot Method.
+	Readers map[string]ReaderMethod
+
 	// Lookup is KeyServer.Lookup function that should be used for key
 	// lookups during authentication.
 	// If nil, PublicUserKeyService will be used.
 	Lookup func(userName upspin.UserName) (upspin.PublicKey, error)
+
+	// Revoked returns the public keys that the user has revoked.
+	// Sessions for a revoked key are refused, and established sessions
+	// are dropped once the revocation is noticed.
+	// If nil and Lookup is nil, PublicUserRevokedKeys will be used.
+	// If nil and Lookup is set, revocations are not checked.
+	Revoked func(userName upspin.UserName) ([]upspin.PublicKey, error)
 }
 
 // Method describes an authenticated RPC method.
+// The request bytes belong to the server and must not be used once the
+// method has returned; the same is true of the other kinds of method,
+// except Stream and BiStream.
 type Method func(s Session, reqBytes []byte) (pb.Message, error)
 
 // UnauthenticatedMethod describes an RPC method that does not require
@@ -87,6 +121,18 @@ type UnauthenticatedMethod func(reqBytes []byte) (pb.Message, error)
 // Stream describes an authenticated streaming RPC method.
 type Stream func(s Session, reqBytes []byte, done <-chan struct{}) (<-chan pb.Message, error)
 
+// BiStreamMethod describes an authenticated bidirectional streaming RPC
+// method. It is like Stream but also receives, on recv, the encoded
+// messages the client sends after its request. The recv channel is closed
+// when the client stops sending.
+type BiStreamMethod func(s Session, reqBytes []byte, recv <-chan []byte, done <-chan struct{}) (<-chan pb.Message, error)
+
+// ReaderMethod describes an authenticated RPC method whose reply is a
+// message followed by a stream of bytes, which may be too large to hold
+// in memory. The server sends the bytes read from the returned ReadCloser,
+// if it is not nil, and then closes it.
+type ReaderMethod func(s Session, reqBytes []byte) (pb.Message, io.ReadCloser, error)
+
 // NewServer returns a new Server that uses the given ServerConfig.
 func NewServer(cfg upspin.Config, svc Service) http.Handler {
 	// Validate Service.
@@ -106,16 +152,49 @@ func NewServer(cfg upspin.Config, svc Service) http.Handler {
 			panic(fmt.Sprintf("Stream %q also specified as UnauthenticatedMethod", name))
 		}
 	}
+	for name := range svc.BiStreams {
+		if _, ok := svc.Methods[name]; ok {
+			panic(fmt.Sprintf("BiStream %q also specified as Method", name))
+		}
+		if _, ok := svc.UnauthenticatedMethods[name]; ok {
+			panic(fmt.Sprintf("BiStream %q also specified as UnauthenticatedMethod", name))
+		}
+	}
+	for name := range svc.Readers {
+		if _, ok := svc.UnauthenticatedMethods[name]; ok {
+			panic(fmt.Sprintf("Reader %q also specified as UnauthenticatedMethod", name))
+		}
+		if _, ok := svc.Streams[name]; ok {
+			panic(fmt.Sprintf("Reader %q also specified as Stream", name))
+		}
+		if _, ok := svc.BiStreams[name]; ok {
+			panic(fmt.Sprintf("Reader %q also specified as BiStream", name))
+		}
+	}
+
+	if svc.Revoked == nil && svc.Lookup == nil {
+		svc.Revoked = PublicUserRevokedKeys(cfg)
+	}
 
 	return &serverImpl{
 		config:  cfg,
 		service: svc,
+		revoked: cache.NewLRU(sessionCacheSize),
 	}
 }
 
 type serverImpl struct {
 	config  upspin.Config
 	service Service
+
+	// revoked caches <upspin.UserName, *revokedKeys>. Thread safe.
+	revoked *cache.LRU
+}
+
+// revokedKeys holds a user's revoked keys and the time they were fetched.
+type revokedKeys struct {
+	keys    []upspin.PublicKey
+	fetched time.Time
 }
 
 func (s *serverImpl) lookup(u upspin.UserName) (upspin.PublicKey, error) {
@@ -125,6 +204,39 @@ func (s *serverImpl) lookup(u upspin.UserName) (upspin.PublicKey, error) {
 	return s.service.Lookup(u)
 }
 
+// isRevoked reports whether the user has revoked the given key.
+// The user's revoked keys are cached for revocationCheckInterval.
+// If they cannot be refreshed, a stale list is used if available;
+// otherwise the error is returned.
+func (s *serverImpl) isRevoked(u upspin.UserName, key upspin.PublicKey) (bool, error) {
+	if s.service.Revoked == nil {
+		return false, nil
+	}
+	now := time.Now()
+	var rk *revokedKeys
+	if v, ok := s.revoked.Get(u); ok {
+		rk = v.(*revokedKeys)
+	}
+	if rk == nil || now.Sub(rk.fetched) >= revocationCheckInterval {
+		keys, err := s.service.Revoked(u)
+		switch {
+		case err == nil:
+			rk = &revokedKeys{keys: keys, fetched: now}
+			s.revoked.Add(u, rk)
+		case rk == nil:
+			return false, err
+		default:
+			logger.Debug.Printf("rpc: using stale revoked keys for %q: %v", u, err)
+		}
+	}
+	for _, k := range rk.keys {
+		if k == key {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 func generateRandomToken() (string, error) {
 	var buf [authTokenEntropyLen]byte
 	n, err := rand.Read(buf[:])
@@ -137,8 +249,45 @@ func generateRandomToken() (string, error) {
 	return fmt.Sprintf("%X", buf), nil
 }
 
+// NewOwnerHandler returns an http.Handler that serves requests with h only
+// if they are authenticated, using the same headers as RPC requests, as the
+// given user. Other requests are refused.
+func NewOwnerHandler(cfg upspin.Config, owner upspin.UserName, h http.Handler) http.Handler {
+	return &ownerHandler{
+		server: &serverImpl{
+			config:  cfg,
+			service: Service{Revoked: PublicUserRevokedKeys(cfg)},
+			revoked: cache.NewLRU(sessionCacheSize),
+		},
+		owner:   owner,
+		handler: h,
+	}
+}
+
+type ownerHandler struct {
+	server  *serverImpl
+	owner   upspin.UserName
+	handler http.Handler
+}
+
+func (h *ownerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	session, err := h.server.SessionForRequest(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if session.User() != h.owner {
+		code := http.StatusForbidden
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+	h.handler.ServeHTTP(w, r)
+}
+
 // ServeHTTP exposes the configured Service as an HTTP API.
 func (s *serverImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	defer recoverPanic(w, r)
+
 	d := &s.service
 	prefix := "/api/" + d.Name + "/"
 	if !strings.HasPrefix(r.URL.Path, prefix) {
@@ -150,10 +299,26 @@ func (s *serverImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	method := d.Methods[name]
 	umethod := d.UnauthenticatedMethods[name]
 	stream := d.Streams[name]
-	if method == nil && umethod == nil && stream == nil {
+	bistream := d.BiStreams[name]
+	reader := d.Readers[name]
+	if method == nil && umethod == nil && stream == nil && bistream == nil && reader == nil {
 		http.NotFound(w, r)
 		return
 	}
+	if len(d.BiStreams) > 0 {
+		// Tell clients they may ask for bidirectional streams.
+		w.Header().Set(streamHeader, streamBidi)
+	}
+	bidi := r.Header.Get(streamHeader) == streamBidi
+	if bidi && bistream == nil {
+		// The request body stays open, so refuse without reading it
+		// and do not reuse the connection.
+		if r.ProtoMajor == 1 {
+			w.Header().Set("Connection", "close")
+		}
+		sendError(w, upspin.ErrNotSupported)
+		return
+	}
 
 	var session Session
 	if umethod == nil {
@@ -165,14 +330,43 @@ func (s *serverImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	body, err := io.ReadAll(r.Body)
+	if bidi {
+		serveBiStream(bistream, session, w, r)
+		return
+	}
+	// Clients that ask for a byte stream from a method that cannot
+	// send one are served the one-shot reply, which they recognize by
+	// the absence of the response header.
+	byteStream := r.Header.Get(streamHeader) == streamBytes && reader != nil
+	if stream == nil && method == nil && umethod == nil && !byteStream {
+		// Only a bidirectional or byte stream is served by this name.
+		sendError(w, upspin.ErrNotSupported)
+		return
+	}
+
+	// The body is read into a pooled buffer, which is freed once the
+	// reply has been sent; see buffer.go.
+	buf := getBuffer()
+	err := buf.readFrom(r.Body)
 	r.Body.Close()
 	if err != nil {
+		buf.free()
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	body := buf.b
+	if byteStream || method != nil || umethod != nil {
+		defer buf.free()
+	} else {
+		// A Stream may run on after the reply begins, so it is
+		// given a body of its own.
+		body = append([]byte(nil), body...)
+		buf.free()
+	}
 
 	switch {
+	case byteStream:
+		serveReader(reader, session, w, body)
 	case method != nil:
 		resp, err := method(session, body)
 		sendResponse(w, resp, err)
@@ -186,18 +380,38 @@ func (s *serverImpl) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// recoverPanic, when deferred, recovers from a panic while serving a
+// request so that one bad request does not take down the server. It logs
+// the stack and sends the client an Internal error. If the UPSPIN_TEST
+// environment variable is set, panics are not recovered, so that tests
+// fail clearly. Note that only panics in the goroutine serving the request
+// are recovered, not those in goroutines started by a Stream.
+func recoverPanic(w http.ResponseWriter, r *http.Request) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	if v == http.ErrAbortHandler || os.Getenv("UPSPIN_TEST") != "" {
+		panic(v)
+	}
+	panicCount.Add(1)
+	logger.Error.Printf("rpc: panic serving %s: %v\n%s", r.URL.Path, v, debug.Stack())
+	sendError(w, errors.E(errors.Internal, errors.Str("internal server error")))
+}
+
 func sendResponse(w http.ResponseWriter, resp pb.Message, err error) {
 	if err != nil {
 		sendError(w, err)
 		return
 	}
-	payload, err := pb.Marshal(resp)
-	if err != nil {
-		log.Error.Printf("error encoding response: %v", err)
+	buf := getBuffer()
+	defer buf.free()
+	if err := buf.marshal(resp); err != nil {
+		logger.Error.Printf("error encoding response: %v", err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	w.Write(payload)
+	w.Write(buf.b)
 }
 
 func sendError(w http.ResponseWriter, err error) {
@@ -239,7 +453,7 @@ func serveStream(s Stream, sess Session, w http.ResponseWriter, body []byte) {
 
 			b, err := pb.Marshal(msg)
 			if err != nil {
-				log.Error.Printf("rpc/auth: error encoding proto in stream: %v", err)
+				logger.Error.Printf("rpc/auth: error encoding proto in stream: %v", err)
 				return
 			}
 
@@ -309,7 +523,7 @@ func (s *serverImpl) validateToken(authToken string) (Session, error) {
 	if session == nil {
 		// We don't know this client or have forgotten about it. We must authenticate.
 		// Log it so we can track how often this happens. Maybe we need to increase the session cache size.
-		log.Debug.Printf("Got token from user but there's no session for it.")
+		logger.Debug.Printf("Got token from user but there's no session for it.")
 		return nil, errors.E(errors.Permission, errUnauthenticated)
 	}
 
@@ -319,6 +533,18 @@ func (s *serverImpl) validateToken(authToken string) (Session, error) {
 		return nil, errors.E(errors.Permission, errExpired)
 	}
 
+	// If the session's key has since been revoked, drop the session.
+	if si, ok := session.(*sessionImpl); ok && si.key != "" {
+		revoked, err := s.isRevoked(si.user, si.key)
+		if err != nil {
+			return nil, errors.E(si.user, err)
+		}
+		if revoked {
+			ClearSession(authToken)
+			return nil, errors.E(errors.Permission, si.user, errRevoked)
+		}
+	}
+
 	return session, nil
 }
 
@@ -356,6 +582,15 @@ func (s *serverImpl) handleSessionRequest(w http.ResponseWriter, authRequest []s
 		return nil, errors.E(errors.Permission, user, errors.Errorf("invalid signature: %v", err))
 	}
 
+	// Refuse keys that have been revoked.
+	revoked, err := s.isRevoked(user, key)
+	if err != nil {
+		return nil, errors.E(user, err)
+	}
+	if revoked {
+		return nil, errors.E(errors.Permission, user, errRevoked)
+	}
+
 	// Generate an auth token and bind it to a session for the client.
 	expiration := now.Add(authTokenDuration)
 	authToken, err := generateRandomToken()
@@ -374,7 +609,7 @@ func (s *serverImpl) handleSessionRequest(w http.ResponseWriter, authRequest []s
 		w.Header()[authRequestHeader] = authMsg
 	}
 
-	return NewSession(user, expiration, authToken, ep, nil), nil
+	return newSession(user, key, expiration, authToken, ep, nil), nil
 }
 
 // verifyUser authenticates the remote user.
@@ -396,7 +631,7 @@ func verifyUser(key upspin.PublicKey, msg []string, magic, host string, now time
 	// Currently just print a message if the time is too far off.
 	// TODO(p): we have to do better than this.
 	if msgNow.After(now.Add(30*time.Second)) || msgNow.Before(now.Add(-45*time.Second)) {
-		log.Info.Printf("verifying %s: timestamp is far wrong (%v); proceeding anyway", msg[0], now.Sub(msgNow))
+		logger.Info.Printf("verifying %s: timestamp is far wrong (%v); proceeding anyway", msg[0], now.Sub(msgNow))
 	}
 
 	// Parse signature
@@ -417,7 +652,7 @@ func verifyUser(key upspin.PublicKey, msg []string, magic, host string, now time
 			shortKey = shortKey[:16] + "..."
 		}
 		user := msg[0]
-		log.Debug.Printf("rpc/server: signature fails to validate using key %q for %q: %s", shortKey, user, err)
+		logger.Debug.Printf("rpc/server: signature fails to validate using key %q for %q: %s", shortKey, user, err)
 		return err
 	}
 	return nil
@@ -440,7 +675,7 @@ func signUser(cfg upspin.Config, magic, host string) ([]string, error) {
 	now := time.Now().UTC().Format(time.ANSIC)
 	sig, err := f.Sign(hashUser(magic, user, host, now))
 	if err != nil {
-		log.Error.Printf("proxyRequest signing server user: %v", err)
+		logger.Error.Printf("proxyRequest signing server user: %v", err)
 		return nil, err
 	}
 	return []string{
@@ -36,8 +36,15 @@ var sessionCache *cache.LRU // Caches <authToken, Session> Thread safe.
 
 // NewSession creates a new session with the given contents.
 func NewSession(user upspin.UserName, expiration time.Time, authToken string, proxyFor *upspin.Endpoint, err error) Session {
+	return newSession(user, "", expiration, authToken, proxyFor, err)
+}
+
+// newSession is like NewSession but also records the public key the user
+// authenticated with, so the session can be dropped if the key is revoked.
+func newSession(user upspin.UserName, key upspin.PublicKey, expiration time.Time, authToken string, proxyFor *upspin.Endpoint, err error) Session {
 	session := &sessionImpl{
 		user:      user,
+		key:       key,
 		expires:   expiration,
 		authToken: authToken,
 		err:       err,
@@ -68,6 +75,7 @@ func resetSessions() {
 
 type sessionImpl struct {
 	user      upspin.UserName
+	key       upspin.PublicKey
 	authToken string
 	err       error
 	expires   time.Time
@@ -7,7 +7,9 @@
 package storeserver // import "upspin.io/rpc/storeserver"
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
 
 	pb "github.com/golang/protobuf/proto"
@@ -20,6 +22,8 @@ import (
 	"upspin.io/upspin/proto"
 )
 
+var logger = log.For("rpc/storeserver")
+
 type server struct {
 	config upspin.Config
 
@@ -41,6 +45,9 @@ func New(cfg upspin.Config, store upspin.StoreServer, _ upspin.NetAddr) http.Han
 			"Put":    s.Put,
 			"Delete": s.Delete,
 		},
+		Readers: map[string]rpc.ReaderMethod{
+			"Get": s.GetStream,
+		},
 	})
 }
 
@@ -81,6 +88,40 @@ func (s *server) Get(session rpc.Session, reqBytes []byte) (pb.Message, error) {
 	return resp, nil
 }
 
+// GetStream serves Get as a byte stream. The data are read from the store
+// as they are sent, if the store can stream them.
+func (s *server) GetStream(session rpc.Session, reqBytes []byte) (pb.Message, io.ReadCloser, error) {
+	var req proto.StoreGetRequest
+	store, err := s.serverFor(session, reqBytes, &req)
+	if err != nil {
+		return nil, nil, err
+	}
+	op := s.logf(session, "GetStream(%q)", req.Reference)
+
+	ref := upspin.Reference(req.Reference)
+	var rc io.ReadCloser
+	var refdata *upspin.Refdata
+	var locs []upspin.Location
+	if sg, ok := store.(upspin.StoreStreamGetter); ok {
+		rc, refdata, locs, err = sg.GetStream(ref)
+	} else {
+		var data []byte
+		data, refdata, locs, err = store.Get(ref)
+		if data != nil {
+			rc = io.NopCloser(bytes.NewReader(data))
+		}
+	}
+	if err != nil {
+		op.log(err)
+		return &proto.StoreGetResponse{Error: errors.MarshalError(err)}, nil, nil
+	}
+	resp := &proto.StoreGetResponse{
+		Refdata:   proto.RefdataProto(refdata),
+		Locations: proto.Locations(locs),
+	}
+	return resp, rc, nil
+}
+
 // Put implements proto.StoreServer.
 func (s *server) Put(session rpc.Session, reqBytes []byte) (pb.Message, error) {
 	var req proto.StorePutRequest
@@ -90,7 +131,14 @@ func (s *server) Put(session rpc.Session, reqBytes []byte) (pb.Message, error) {
 	}
 	op := s.logf(session, "Put(%.16x...) (%d bytes)", req.Data, len(req.Data))
 
-	refdata, err := store.Put(req.Data)
+	// The blocks are stored on behalf of the authenticated user,
+	// who will be the writer of the entries that refer to them.
+	var refdata *upspin.Refdata
+	if mp, ok := store.(upspin.StoreMetaPutter); ok {
+		refdata, err = mp.PutWithMeta(req.Data, session.User())
+	} else {
+		refdata, err = store.Put(req.Data)
+	}
 	if err != nil {
 		op.log(err)
 		return &proto.StorePutResponse{Error: errors.MarshalError(err)}, nil
@@ -124,12 +172,12 @@ func (s *server) Delete(session rpc.Session, reqBytes []byte) (pb.Message, error
 func (s *server) logf(sess rpc.Session, format string, args ...interface{}) operation {
 	op := fmt.Sprintf("rpc/storeserver: %q: store.", sess.User())
 	op += fmt.Sprintf(format, args...)
-	log.Debug.Print(op)
+	logger.Debug.Print(op)
 	return operation(op)
 }
 
 type operation string
 
 func (op operation) log(err error) {
-	log.Debug.Print(op)
+	logger.Debug.Print(op)
 }
@@ -0,0 +1,187 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rpc
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/http2"
+	yaml "gopkg.in/yaml.v2"
+
+	"upspin.io/errors"
+	"upspin.io/rpc/local"
+	"upspin.io/upspin"
+)
+
+// Tuning holds the settings of the HTTP connections a Client makes to its
+// server. They may be given in the config under the key "rpc", as in
+//
+//	rpc:
+//	  http2: true
+//	  idleconns: 16
+//
+// Settings not given take their values from DefaultTuning.
+type Tuning struct {
+	// HTTP2 allows secure connections to use HTTP/2, if the server
+	// offers it, so that many requests share each connection.
+	HTTP2 bool `yaml:"http2"`
+
+	// Conns is the most TCP connections to the server at once. Zero
+	// means no limit. Each streaming request, such as a DirServer's
+	// Watch, holds an HTTP/1.1 connection for as long as it runs.
+	Conns int `yaml:"conns"`
+
+	// IdleConns is the most connections kept open for reuse while idle.
+	// Zero means 10.
+	IdleConns int `yaml:"idleconns"`
+
+	// Streams is the most requests in progress at once. Further
+	// requests wait for one to finish. A streaming request is in
+	// progress until its reply is closed. Zero means no limit.
+	Streams int `yaml:"streams"`
+
+	// ReadBuffer and WriteBuffer are the sizes in bytes of the buffers
+	// of each HTTP/1.1 connection. Zero means 4KB.
+	ReadBuffer  int `yaml:"readbuffer"`
+	WriteBuffer int `yaml:"writebuffer"`
+}
+
+// DefaultTuning is the Tuning of Clients whose configs do not give one.
+// It was chosen with BenchmarkGetConcurrent in package
+// upspin.io/store/remote, which fetches 16 blocks at a time from a server
+// on the local machine. Keeping more connections open while idle, which
+// saves TLS handshakes, helped; larger buffers and HTTP/2 did not.
+var DefaultTuning = Tuning{
+	IdleConns: 32,
+}
+
+// TuningFromConfig returns the Tuning given by the config.
+func TuningFromConfig(cfg upspin.Config) (Tuning, error) {
+	const op errors.Op = "rpc.TuningFromConfig"
+	t := DefaultTuning
+	v := cfg.Value("rpc")
+	if v == "" {
+		return t, nil
+	}
+	if err := yaml.UnmarshalStrict([]byte(v), &t); err != nil {
+		return t, errors.E(op, errors.Invalid, errors.Errorf("bad rpc value: %v", err))
+	}
+	if t.Conns < 0 || t.IdleConns < 0 || t.Streams < 0 || t.ReadBuffer < 0 || t.WriteBuffer < 0 {
+		return t, errors.E(op, errors.Invalid, errors.Errorf("bad rpc value: negative setting in %+v", t))
+	}
+	return t, nil
+}
+
+// transport holds the means of sending requests to a server, which may be
+// shared by many Clients.
+type transport struct {
+	client *http.Client
+	slots  chan bool // Holds a value for each request in progress; nil for no limit.
+}
+
+// transportKey identifies the shared transports.
+type transportKey struct {
+	baseURL  string
+	tlscerts string
+	tuning   Tuning
+}
+
+var sharedTransports struct {
+	sync.Mutex
+	m map[transportKey]*transport
+}
+
+// sharedTransport returns the transport for the key, calling newTransport
+// to make it if it does not yet exist.
+func sharedTransport(key transportKey, tlsConfig *tls.Config) (*transport, error) {
+	sharedTransports.Lock()
+	defer sharedTransports.Unlock()
+	if t := sharedTransports.m[key]; t != nil {
+		return t, nil
+	}
+	t, err := newTransport(tlsConfig, key.tuning)
+	if err != nil {
+		return nil, err
+	}
+	if sharedTransports.m == nil {
+		sharedTransports.m = make(map[transportKey]*transport)
+	}
+	sharedTransports.m[key] = t
+	return t, nil
+}
+
+// newTransport returns a transport with the given TLS configuration, nil
+// for insecure connections, and tuning.
+func newTransport(tlsConfig *tls.Config, tuning Tuning) (*transport, error) {
+	t := &http.Transport{
+		TLSClientConfig: tlsConfig,
+		// The following values are the same as
+		// net/http.DefaultTransport.
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&local.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   10,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+
+		MaxConnsPerHost: tuning.Conns,
+		ReadBufferSize:  tuning.ReadBuffer,
+		WriteBufferSize: tuning.WriteBuffer,
+	}
+	if tuning.IdleConns > 0 {
+		t.MaxIdleConnsPerHost = tuning.IdleConns
+	}
+	if tuning.HTTP2 && tlsConfig != nil {
+		t2, err := http2.ConfigureTransports(t)
+		if err != nil {
+			return nil, err
+		}
+		// Queue requests beyond the server's limit on streams
+		// rather than open connections beyond ours.
+		t2.StrictMaxConcurrentStreams = tuning.Conns > 0
+	}
+	tr := &transport{client: &http.Client{Transport: t}}
+	if tuning.Streams > 0 {
+		tr.slots = make(chan bool, tuning.Streams)
+	}
+	return tr, nil
+}
+
+// do sends the request, waiting first if the transport has as many
+// requests in progress as it allows. The request is in progress until the
+// body of the response is closed.
+func (t *transport) do(req *http.Request) (*http.Response, error) {
+	if t.slots == nil {
+		return t.client.Do(req)
+	}
+	t.slots <- true
+	resp, err := t.client.Do(req)
+	if err != nil {
+		<-t.slots
+		return nil, err
+	}
+	resp.Body = &slotBody{ReadCloser: resp.Body, slots: t.slots}
+	return resp, nil
+}
+
+// slotBody is the body of a response that frees its slot when closed.
+type slotBody struct {
+	io.ReadCloser
+	slots chan bool
+	once  sync.Once
+}
+
+func (b *slotBody) Close() error {
+	b.once.Do(func() { <-b.slots })
+	return b.ReadCloser.Close()
+}
@@ -9,14 +9,19 @@ package dirserver // import "upspin.io/serverutil/dirserver"
 import (
 	"flag"
 	"net/http"
+	"strings"
 
+	"upspin.io/bind"
+	"upspin.io/cloud/https"
 	"upspin.io/config"
 	"upspin.io/dir/inprocess"
 	"upspin.io/dir/server"
+	"upspin.io/dir/server/replica"
 	"upspin.io/errors"
 	"upspin.io/flags"
 	"upspin.io/log"
 	"upspin.io/rpc/dirserver"
+	"upspin.io/serverutil"
 	"upspin.io/serverutil/perm"
 	"upspin.io/upspin"
 
@@ -31,7 +36,11 @@ import (
 	_ "upspin.io/transports"
 )
 
-var storeServerUser = flag.String("storeserveruser", "", "`user name` of the StoreServer")
+var (
+	storeServerUser = flag.String("storeserveruser", "", "`user name` of the StoreServer")
+	standbyFor      = flag.String("standby", "", "instead of serving, replicate the logs of the directory server at `host:port`")
+	accessHistory   = flag.Bool("access-history", false, "keep a history of the requests made to the server, queried by AccessHistory")
+)
 
 func Main() (ready chan<- struct{}) {
 	flags.Parse(flags.Server, "kind", "serverconfig")
@@ -42,6 +51,11 @@ func Main() (ready chan<- struct{}) {
 		log.Fatal(err)
 	}
 
+	if *standbyFor != "" {
+		runStandby(cfg, upspin.NetAddr(*standbyFor))
+		return nil
+	}
+
 	// Create a new store implementation.
 	var dir upspin.DirServer
 	err = nil
@@ -49,7 +63,11 @@ func Main() (ready chan<- struct{}) {
 	case "inprocess":
 		dir = inprocess.New(cfg)
 	case "server":
-		dir, err = server.New(cfg, flags.ServerConfig...)
+		opts := flags.ServerConfig
+		if *accessHistory {
+			opts = append(opts, "accessHistory=true")
+		}
+		dir, err = server.New(cfg, opts...)
 	default:
 		err = errors.Errorf("bad -kind %q", flags.ServerKind)
 	}
@@ -57,6 +75,17 @@ func Main() (ready chan<- struct{}) {
 		log.Fatalf("Setting up DirServer: %v", err)
 	}
 
+	h := serverutil.NewHealth()
+	h.Add("keyserver", serverutil.KeyServerCheck(cfg))
+	if dir := logDirOption(); dir != "" {
+		h.Add("logdir", serverutil.WritableCheck(dir))
+	}
+	if check := https.CertificateCheck(https.OptionsFromFlags()); check != nil {
+		h.AddDetailed("certificate", check)
+	}
+	h.Start()
+	http.Handle("/healthz", h)
+
 	// Wrap with permission checks, if requested.
 	if *storeServerUser != "" {
 		readyCh := make(chan struct{})
@@ -71,3 +100,41 @@ func Main() (ready chan<- struct{}) {
 
 	return ready
 }
+
+// runStandby starts replicating the logs of the directory server at addr
+// into the directory named by the logDir server option. The standby
+// serves no requests; see package upspin.io/dir/server/replica.
+// logDirOption returns the logDir given in -serverconfig, if any.
+func logDirOption() string {
+	var logDir string
+	for _, opt := range flags.ServerConfig {
+		if strings.HasPrefix(opt, "logDir=") {
+			logDir = strings.TrimPrefix(opt, "logDir=")
+		}
+	}
+	return logDir
+}
+
+func runStandby(cfg upspin.Config, addr upspin.NetAddr) {
+	logDir := logDirOption()
+	if logDir == "" {
+		log.Fatal("-standby requires a logDir in -serverconfig")
+	}
+	primary := upspin.Endpoint{
+		Transport: upspin.Remote,
+		NetAddr:   addr,
+	}
+	dial := func() (replica.Source, error) {
+		dir, err := bind.DirServer(cfg, primary)
+		if err != nil {
+			return nil, err
+		}
+		src, ok := dir.(replica.Source)
+		if !ok {
+			return nil, errors.Errorf("directory server at %s does not support replication", addr)
+		}
+		return src, nil
+	}
+	log.Printf("Running as a standby for %s; not serving.", addr)
+	go replica.NewStandby(logDir, dial).Run(nil)
+}
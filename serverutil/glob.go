@@ -21,12 +21,30 @@ import (
 // In that one case, it should also return only the DirEntry for that path.
 type ListFunc func(upspin.PathName) ([]*upspin.DirEntry, error)
 
+// ListPrefixFunc is like ListFunc but need return only the entries of the
+// directory whose final path element begins with prefix. It may return
+// others, which are discarded. It lets servers that keep their directories
+// ordered by name avoid listing all of a large directory.
+type ListPrefixFunc func(dir upspin.PathName, prefix string) ([]*upspin.DirEntry, error)
+
 // LookupFunc is a DirServer.Lookup implementation.
 type LookupFunc func(upspin.PathName) (*upspin.DirEntry, error)
 
 // Glob executes a DirServer.Glob operation for the specified pattern
 // using the provided LookupFunc and ListFunc to retrieve data.
 func Glob(pattern string, lookup LookupFunc, ls ListFunc) ([]*upspin.DirEntry, error) {
+	return GlobPrefix(pattern, lookup, func(dir upspin.PathName, _ string) ([]*upspin.DirEntry, error) {
+		return ls(dir)
+	})
+}
+
+// GlobPrefix is like Glob but lists directories with a ListPrefixFunc,
+// passing the fixed prefix, if any, of the pattern element being matched.
+// For a pattern such as ann@example.com/photos/2025*, only the entries of
+// the photos directory beginning with 2025 need be listed. A pattern
+// element that begins with a metacharacter, as in */2025/*, has no
+// prefix and the whole directory must be listed.
+func GlobPrefix(pattern string, lookup LookupFunc, ls ListPrefixFunc) ([]*upspin.DirEntry, error) {
 	p, err := path.Parse(upspin.PathName(pattern))
 	if err != nil {
 		return nil, err
@@ -71,7 +89,7 @@ func Glob(pattern string, lookup LookupFunc, ls ListFunc) ([]*upspin.DirEntry, e
 
 	var toGlob []string // Additional patterns to glob.
 
-	entries, err := ls(basePath)
+	entries, err := ls(basePath, literalPrefix(p.Elem(firstMeta)))
 	if err != nil {
 		if err == upspin.ErrFollowLink {
 			return entries, err
@@ -113,7 +131,7 @@ func Glob(pattern string, lookup LookupFunc, ls ListFunc) ([]*upspin.DirEntry, e
 
 	// Perform any additional glob operations recursively.
 	for _, pattern := range toGlob {
-		entries, err := Glob(pattern, lookup, ls)
+		entries, err := GlobPrefix(pattern, lookup, ls)
 		if errors.Is(errors.Private, err) ||
 			errors.Is(errors.Permission, err) ||
 			errors.Is(errors.NotExist, err) {
@@ -151,6 +169,29 @@ func hasMeta(elem string) bool {
 	return false
 }
 
+// literalPrefix returns the unescaped text of the given pattern element
+// that precedes its first metacharacter.
+func literalPrefix(elem string) string {
+	b := make([]byte, 0, len(elem))
+	esc := false
+	for _, c := range []byte(elem) {
+		if esc {
+			esc = false
+			b = append(b, c)
+			continue
+		}
+		switch c {
+		case '\\':
+			esc = true
+			continue
+		case '*', '[', '?':
+			return string(b)
+		}
+		b = append(b, c)
+	}
+	return string(b)
+}
+
 // unquote removes the escaping from the given pattern and returns the
 // resulting path.
 func unquote(pat string) upspin.PathName {
@@ -221,3 +221,66 @@ func TestUnquote(t *testing.T) {
 		}
 	}
 }
+
+func TestLiteralPrefix(t *testing.T) {
+	cases := []struct {
+		in, want string
+	}{
+		{"2025*", "2025"},
+		{"*", ""},
+		{"fo?", "fo"},
+		{"f[o]o", "f"},
+		{"f\\*o*", "f*o"},
+		{"foo", "foo"},
+	}
+	for _, c := range cases {
+		if got := literalPrefix(c.in); got != c.want {
+			t.Errorf("literalPrefix(%q) = %q, want %q", c.in, got, c.want)
+		}
+	}
+}
+
+func TestGlobPrefix(t *testing.T) {
+	const (
+		user  = "user@example.com"
+		root  = user + "/"
+		dir   = user + "/photos"
+		photo = dir + "/2025-01.jpg"
+		other = dir + "/2024-12.jpg"
+	)
+	lookup := func(name upspin.PathName) (*upspin.DirEntry, error) {
+		return &upspin.DirEntry{Name: name, Attr: upspin.AttrDirectory}, nil
+	}
+	var prefixes []string
+	ls := func(name upspin.PathName, prefix string) ([]*upspin.DirEntry, error) {
+		prefixes = append(prefixes, string(name)+":"+prefix)
+		switch name {
+		case root:
+			return []*upspin.DirEntry{{Name: dir, Attr: upspin.AttrDirectory}}, nil
+		case dir:
+			// Entries not beginning with the prefix may be returned.
+			return []*upspin.DirEntry{{Name: other}, {Name: photo}}, nil
+		}
+		return nil, errNotExist
+	}
+	for _, c := range []struct {
+		pattern  string
+		prefixes string
+		want     []upspin.PathName
+	}{
+		{dir + "/2025*", dir + ":2025", []upspin.PathName{photo}},
+		{user + "/*/20?5*", root + ": " + dir + ":20", []upspin.PathName{photo}},
+	} {
+		prefixes = nil
+		entries, err := GlobPrefix(c.pattern, lookup, ls)
+		if err != nil {
+			t.Fatalf("GlobPrefix(%q): %v", c.pattern, err)
+		}
+		if err := matchEntries(entries, c.want...); err != nil {
+			t.Errorf("GlobPrefix(%q): %v", c.pattern, err)
+		}
+		if got := strings.Join(prefixes, " "); got != c.prefixes {
+			t.Errorf("GlobPrefix(%q) listed %q, want %q", c.pattern, got, c.prefixes)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package serverutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"upspin.io/bind"
+	"upspin.io/errors"
+	"upspin.io/log"
+	"upspin.io/upspin"
+)
+
+// Health periodically checks that the services a server depends on, such
+// as its key server or storage backend, can be reached, and reports the
+// results over HTTP for use by liveness probes. A connection that has
+// been silently dropped otherwise goes unnoticed until the requests that
+// use it time out.
+//
+// Health is an http.Handler, typically served at /healthz.
+type Health struct {
+	// Interval is the time between checks.
+	Interval time.Duration
+
+	// MaxAge is how long the results of a check are reported before
+	// a request for them checks again. It bounds the load that
+	// frequent probes put on the dependencies.
+	MaxAge time.Duration
+
+	// Timeout bounds each check; a check that has not returned by
+	// then counts as a failure.
+	Timeout time.Duration
+
+	// MaxFailures is the number of consecutive failed checks after
+	// which a dependency is considered down.
+	MaxFailures int
+
+	// Down, if not nil, is called once a dependency has failed
+	// MaxFailures consecutive checks.
+	Down func(name string, err error)
+
+	checkMu sync.Mutex // Held while ServeHTTP checks the dependencies.
+
+	mu      sync.Mutex // Guards the fields below.
+	deps    map[string]*dependency
+	checked time.Time // When CheckAll last finished.
+}
+
+type dependency struct {
+	check    func() (string, error)
+	failures int    // Consecutive failed checks.
+	detail   string // Detail reported by the last check.
+	err      error  // Error from the last check.
+}
+
+// NewHealth returns a Health that checks every 30 seconds, allows each
+// check 10 seconds, reports results for up to 10 seconds, and considers
+// a dependency down after 3 consecutive failures.
+func NewHealth() *Health {
+	return &Health{
+		Interval:    30 * time.Second,
+		MaxAge:      10 * time.Second,
+		Timeout:     10 * time.Second,
+		MaxFailures: 3,
+	}
+}
+
+// Add adds a dependency with the given name. The check function returns
+// an error if the dependency cannot be reached.
+func (h *Health) Add(name string, check func() error) {
+	h.AddDetailed(name, func() (string, error) { return "", check() })
+}
+
+// AddDetailed is like Add, but the check function also returns a
+// detail, such as the time remaining before a certificate expires,
+// that is reported along with the result.
+func (h *Health) AddDetailed(name string, check func() (detail string, err error)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.deps == nil {
+		h.deps = make(map[string]*dependency)
+	}
+	h.deps[name] = &dependency{check: check}
+}
+
+// Start starts checking the dependencies in the background.
+func (h *Health) Start() {
+	go func() {
+		for {
+			time.Sleep(h.Interval)
+			h.CheckAll()
+		}
+	}()
+}
+
+// CheckAll checks all the dependencies once, concurrently.
+func (h *Health) CheckAll() {
+	h.mu.Lock()
+	names := make([]string, 0, len(h.deps))
+	for name := range h.deps {
+		names = append(names, name)
+	}
+	h.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, name := range names {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			h.check(name)
+		}(name)
+	}
+	wg.Wait()
+
+	h.mu.Lock()
+	h.checked = time.Now()
+	h.mu.Unlock()
+}
+
+// check checks the named dependency and records the result.
+func (h *Health) check(name string) {
+	h.mu.Lock()
+	d := h.deps[name]
+	h.mu.Unlock()
+
+	// Run the check in its own goroutine so a hung connection
+	// cannot stall the health checks.
+	type result struct {
+		detail string
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		detail, err := d.check()
+		done <- result{detail, err}
+	}()
+	var r result
+	select {
+	case r = <-done:
+	case <-time.After(h.Timeout):
+		r.err = errors.E(errors.IO, errors.Errorf("no response after %v", h.Timeout))
+	}
+	err := r.err
+
+	h.mu.Lock()
+	d.detail = r.detail
+	d.err = err
+	if err == nil {
+		if d.failures >= h.MaxFailures {
+			log.Info.Printf("serverutil: dependency %s is reachable again", name)
+		}
+		d.failures = 0
+		h.mu.Unlock()
+		return
+	}
+	d.failures++
+	failures := d.failures
+	h.mu.Unlock()
+
+	if failures < h.MaxFailures {
+		log.Info.Printf("serverutil: warning: health check of %s failed: %v", name, err)
+		return
+	}
+	if failures == h.MaxFailures {
+		log.Error.Printf("serverutil: dependency %s is down after %d failed health checks: %v", name, failures, err)
+		if h.Down != nil {
+			h.Down(name, err)
+		}
+	}
+}
+
+// ServeHTTP reports the result of the last check of each dependency,
+// with status 200 if all passed and 503 otherwise. If the results are
+// older than MaxAge, it checks the dependencies again first.
+// By default it reports one dependency per line; if the request has the
+// query parameter verbose=1, it reports them as a JSON object.
+func (h *Health) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.refresh()
+
+	h.mu.Lock()
+	status := healthStatus{
+		Status:       "ok",
+		Checked:      h.checked,
+		Dependencies: make(map[string]depStatus, len(h.deps)),
+	}
+	names := make([]string, 0, len(h.deps))
+	for name, d := range h.deps {
+		names = append(names, name)
+		ds := depStatus{
+			Status:   "ok",
+			Detail:   d.detail,
+			Failures: d.failures,
+		}
+		if d.err != nil {
+			ds.Status = "error"
+			ds.Error = d.err.Error()
+			status.Status = "error"
+		}
+		status.Dependencies[name] = ds
+	}
+	h.mu.Unlock()
+	sort.Strings(names)
+
+	code := http.StatusOK
+	if status.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+	if r.FormValue("verbose") == "1" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(code)
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "\t")
+		enc.Encode(status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(code)
+	for _, name := range names {
+		ds := status.Dependencies[name]
+		msg := ds.Error
+		if msg == "" {
+			msg = ds.Status
+		}
+		if ds.Detail != "" {
+			msg += " (" + ds.Detail + ")"
+		}
+		fmt.Fprintf(w, "%s: %s\n", name, msg)
+	}
+}
+
+// healthStatus is the JSON form of the report served by Health.
+type healthStatus struct {
+	Status       string               `json:"status"` // "ok" or "error".
+	Checked      time.Time            `json:"checked"`
+	Dependencies map[string]depStatus `json:"dependencies"`
+}
+
+type depStatus struct {
+	Status   string `json:"status"` // "ok" or "error".
+	Detail   string `json:"detail,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Failures int    `json:"failures,omitempty"` // Consecutive failed checks.
+}
+
+// refresh checks the dependencies if the last check is older than MaxAge.
+// Concurrent callers wait for a single check.
+func (h *Health) refresh() {
+	h.checkMu.Lock()
+	defer h.checkMu.Unlock()
+	h.mu.Lock()
+	stale := time.Since(h.checked) >= h.MaxAge
+	h.mu.Unlock()
+	if stale {
+		h.CheckAll()
+	}
+}
+
+// KeyServerCheck returns a check that the key server named in the
+// config can be reached.
+func KeyServerCheck(cfg upspin.Config) func() error {
+	return func() error {
+		key, err := bind.KeyServer(cfg, cfg.KeyEndpoint())
+		if err != nil {
+			return err
+		}
+		// Only a network failure counts; any other error shows that
+		// the server answered.
+		if _, err := key.Lookup(cfg.UserName()); errors.Is(errors.IO, err) {
+			return err
+		}
+		return nil
+	}
+}
+
+// StoreCheck returns a check that the storage behind the store server
+// can be reached.
+func StoreCheck(store upspin.StoreServer) func() error {
+	return func() error {
+		// The reference does not exist, so a successful check
+		// reports NotExist.
+		_, _, _, err := store.Get("healthz")
+		if err != nil && !errors.Is(errors.NotExist, err) {
+			return err
+		}
+		return nil
+	}
+}
+
+// WritableCheck returns a check that files can be created in the
+// named local directory.
+func WritableCheck(dir string) func() error {
+	return func() error {
+		f, err := os.CreateTemp(dir, ".healthz")
+		if err != nil {
+			return errors.E(errors.IO, err)
+		}
+		f.Close()
+		if err := os.Remove(f.Name()); err != nil {
+			return errors.E(errors.IO, err)
+		}
+		return nil
+	}
+}
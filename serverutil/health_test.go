@@ -0,0 +1,139 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package serverutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"upspin.io/errors"
+)
+
+func TestHealth(t *testing.T) {
+	h := NewHealth()
+	h.Timeout = 50 * time.Millisecond
+	var down []string
+	h.Down = func(name string, err error) { down = append(down, name) }
+
+	var keyErr error
+	h.Add("key", func() error { return keyErr })
+	h.Add("store", func() error { return nil })
+
+	status := func() (int, string) {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/healthz", nil))
+		return rec.Code, rec.Body.String()
+	}
+
+	h.CheckAll()
+	if code, body := status(); code != http.StatusOK || body != "key: ok\nstore: ok\n" {
+		t.Fatalf("healthy: got %d %q", code, body)
+	}
+
+	keyErr = errors.E(errors.IO, errors.Str("connection reset"))
+	for i := 1; i <= 4; i++ {
+		h.CheckAll()
+		if code, _ := status(); code != http.StatusServiceUnavailable {
+			t.Fatalf("check %d: got status %d, want 503", i, code)
+		}
+		if want := i >= h.MaxFailures; (len(down) > 0) != want {
+			t.Fatalf("check %d: down = %v", i, down)
+		}
+	}
+	if len(down) != 1 || down[0] != "key" {
+		t.Fatalf("down = %v, want [key]", down)
+	}
+
+	// A check that hangs fails once the timeout passes.
+	hang := make(chan struct{})
+	defer close(hang)
+	h.Add("key", func() error { <-hang; return nil })
+	h.CheckAll()
+	if code, body := status(); code != http.StatusServiceUnavailable || body != "key: I/O error: no response after 50ms\nstore: ok\n" {
+		t.Fatalf("hung check: got %d %q", code, body)
+	}
+
+	keyErr = nil
+	h.Add("key", func() error { return keyErr })
+	h.CheckAll()
+	if code, _ := status(); code != http.StatusOK {
+		t.Fatalf("recovered: got status %d, want 200", code)
+	}
+}
+
+func TestHealthVerbose(t *testing.T) {
+	h := NewHealth()
+	h.MaxAge = time.Hour
+
+	// A storage backend that fails, counting how often it is probed.
+	var probes int
+	h.Add("storage", func() error {
+		probes++
+		return errors.E(errors.IO, errors.Str("backend unavailable"))
+	})
+	h.AddDetailed("certificate", func() (string, error) { return "42 days remaining", nil })
+
+	get := func(url string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", url, nil))
+		return rec
+	}
+
+	// The first request checks; the rest use the cached results.
+	for i := 0; i < 5; i++ {
+		rec := get("/healthz")
+		want := "certificate: ok (42 days remaining)\nstorage: I/O error: backend unavailable\n"
+		if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != want {
+			t.Fatalf("plain: got %d %q, want 503 %q", rec.Code, rec.Body, want)
+		}
+	}
+	if probes != 1 {
+		t.Errorf("storage probed %d times, want 1", probes)
+	}
+
+	rec := get("/healthz?verbose=1")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("verbose: got status %d, want 503", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("verbose: Content-Type %q", ct)
+	}
+	var got healthStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]depStatus{
+		"certificate": {Status: "ok", Detail: "42 days remaining"},
+		"storage":     {Status: "error", Error: "I/O error: backend unavailable", Failures: 1},
+	}
+	if got.Status != "error" || !reflect.DeepEqual(got.Dependencies, want) {
+		t.Errorf("verbose: got %+v, want dependencies %+v", got, want)
+	}
+	if got.Checked.IsZero() {
+		t.Error("verbose: check time not reported")
+	}
+
+	// Once the results are stale, a request checks again.
+	h.MaxAge = 0
+	get("/healthz")
+	if probes != 2 {
+		t.Errorf("storage probed %d times, want 2", probes)
+	}
+}
+
+func TestWritableCheck(t *testing.T) {
+	dir := t.TempDir()
+	if err := WritableCheck(dir)(); err != nil {
+		t.Fatal(err)
+	}
+	if err := WritableCheck(filepath.Join(dir, "missing"))(); !errors.Is(errors.IO, err) {
+		t.Fatalf("missing directory: err = %v, want I/O error", err)
+	}
+}
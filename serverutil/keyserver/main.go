@@ -11,6 +11,7 @@ import (
 	"net/http"
 	"os"
 
+	"upspin.io/cloud/https"
 	"upspin.io/cloud/mail"
 	"upspin.io/cloud/mail/sendgrid"
 	"upspin.io/config"
@@ -20,6 +21,8 @@ import (
 	"upspin.io/key/server"
 	"upspin.io/log"
 	"upspin.io/rpc/keyserver"
+	"upspin.io/serverutil"
+	"upspin.io/serverutil/rotate"
 	"upspin.io/serverutil/signup"
 	"upspin.io/upspin"
 
@@ -62,12 +65,31 @@ func Main(setup func(upspin.KeyServer)) {
 		setup(key)
 	}
 
+	if r, ok := key.(server.Replicator); ok {
+		if err := r.Replicate(cfg); err != nil {
+			log.Fatalf("Starting replication: %v", err)
+		}
+	}
+	if t, ok := key.(server.Transferrer); ok {
+		t.EnableTransfer(cfg)
+	}
+
 	http.Handle("/api/Key/", keyserver.New(cfg, key, upspin.NetAddr(flags.NetAddr)))
 
 	if logger, ok := key.(server.Logger); ok {
 		http.Handle("/log", logHandler{logger: logger})
 	}
 
+	h := serverutil.NewHealth()
+	if c, ok := key.(server.StorageChecker); ok {
+		h.Add("storage", c.CheckStorage)
+	}
+	if check := https.CertificateCheck(https.OptionsFromFlags()); check != nil {
+		h.AddDetailed("certificate", check)
+	}
+	h.Start()
+	http.Handle("/healthz", h)
+
 	signupURL := "https://" + flags.NetAddr + "/signup"
 	f := cfg.Factotum()
 	if f == nil {
@@ -88,6 +110,11 @@ func Main(setup func(upspin.KeyServer)) {
 		}
 	}
 	http.Handle("/signup", signup.NewHandler(signupURL, f, key, mc))
+	var notify func(upspin.UserName)
+	if mc.Webhook != nil {
+		notify = func(name upspin.UserName) { mc.Webhook.Notify(name, signup.ActionKeyUpdate) }
+	}
+	http.Handle("/rotate", rotate.NewHandler(f, key, notify))
 }
 
 // parseMailConfig reads YAML data and returns a signup.MailConfig
@@ -95,6 +122,8 @@ func Main(setup func(upspin.KeyServer)) {
 // 	notify: notify-signups@email.com
 // 	from: sender@email.com
 //	project: test
+// The optional keys webhook and webhook_secret give the URL of a webhook
+// to notify of signups and key updates and the secret to sign them with.
 func parseMailConfig(data []byte) (*signup.MailConfig, error) {
 	c := make(map[string]string)
 	if err := yaml.Unmarshal(data, &c); err != nil {
@@ -105,10 +134,21 @@ func parseMailConfig(data []byte) (*signup.MailConfig, error) {
 			return nil, errors.E(errors.Invalid, errors.Errorf(`key "%s" is missing in config (need "apikey", "notify", "project" and "from")`, k))
 		}
 	}
-	return &signup.MailConfig{
+	mc := &signup.MailConfig{
 		Project: c["project"],
 		Notify:  c["notify"],
 		From:    c["from"],
 		Mail:    sendgrid.New(c["apikey"]),
-	}, nil
+	}
+	if c["webhook"] != "" {
+		if c["webhook_secret"] == "" {
+			return nil, errors.E(errors.Invalid, errors.Str(`key "webhook_secret" is missing in config (needed with "webhook")`))
+		}
+		mc.Webhook = &signup.Webhook{
+			URL:     c["webhook"],
+			Secret:  c["webhook_secret"],
+			Retries: 3,
+		}
+	}
+	return mc, nil
 }
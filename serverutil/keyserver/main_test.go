@@ -32,6 +32,36 @@ from: server@upspin.io`,
 				From:    "server@upspin.io",
 			},
 		},
+		{
+			name: "webhook",
+			data: `
+apikey: 123
+project: test
+notify: me@email.com
+from: server@upspin.io
+webhook: https://chat.example.com/hook
+webhook_secret: sesame`,
+			mc: &signup.MailConfig{
+				Project: "test",
+				Notify:  "me@email.com",
+				From:    "server@upspin.io",
+				Webhook: &signup.Webhook{
+					URL:     "https://chat.example.com/hook",
+					Secret:  "sesame",
+					Retries: 3,
+				},
+			},
+		},
+		{
+			name: "error-webhook-secret",
+			data: `
+apikey: 123
+project: test
+notify: me@email.com
+from: server@upspin.io
+webhook: https://chat.example.com/hook`,
+			errStr: `key "webhook_secret" is missing`,
+		},
 		{
 			name: "error-from",
 			data: `
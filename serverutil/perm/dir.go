@@ -5,6 +5,9 @@
 package perm // import "upspin.io/serverutil/perm"
 
 import (
+	"time"
+
+	"upspin.io/dir/server/replica"
 	"upspin.io/errors"
 	"upspin.io/path"
 	"upspin.io/upspin"
@@ -51,6 +54,98 @@ func (d *dirWrapper) Put(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
 	return d.DirServer.Put(entry)
 }
 
+// PutUncheckedLink implements upspin.UncheckedLinkPutter. If the wrapped
+// DirServer does not implement it, Put is used instead.
+func (d *dirWrapper) PutUncheckedLink(entry *upspin.DirEntry) (*upspin.DirEntry, error) {
+	p, ok := d.DirServer.(upspin.UncheckedLinkPutter)
+	if !ok || !entry.IsLink() {
+		return d.Put(entry)
+	}
+	// A link cannot be a root, so the check made by Put does not apply.
+	return p.PutUncheckedLink(entry)
+}
+
+// ApplyBatch implements upspin.DirBatcher if the wrapped DirServer does.
+// A batch cannot create a root, so the check made by Put does not apply.
+func (d *dirWrapper) ApplyBatch(changes []upspin.DirChange) ([]*upspin.DirEntry, error) {
+	b, ok := d.DirServer.(upspin.DirBatcher)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	return b.ApplyBatch(changes)
+}
+
+// PreparePut implements upspin.DirTransactor if the wrapped DirServer
+// does. Like a batch, a transaction cannot create a root, so the check
+// made by Put does not apply.
+func (d *dirWrapper) PreparePut(txID string, changes []upspin.DirChange) error {
+	t, ok := d.DirServer.(upspin.DirTransactor)
+	if !ok {
+		return upspin.ErrNotSupported
+	}
+	return t.PreparePut(txID, changes)
+}
+
+// CommitTx implements upspin.DirTransactor if the wrapped DirServer does.
+func (d *dirWrapper) CommitTx(txID string) ([]*upspin.DirEntry, error) {
+	t, ok := d.DirServer.(upspin.DirTransactor)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	return t.CommitTx(txID)
+}
+
+// AbortTx implements upspin.DirTransactor if the wrapped DirServer does.
+func (d *dirWrapper) AbortTx(txID string) error {
+	t, ok := d.DirServer.(upspin.DirTransactor)
+	if !ok {
+		return upspin.ErrNotSupported
+	}
+	return t.AbortTx(txID)
+}
+
+// AccessHistory implements upspin.AccessHistorian if the wrapped DirServer
+// does.
+func (d *dirWrapper) AccessHistory(name upspin.PathName, since time.Time, limit int) ([]upspin.AccessRecord, error) {
+	h, ok := d.DirServer.(upspin.AccessHistorian)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	return h.AccessHistory(name, since, limit)
+}
+
+// WhichAccessBatch implements upspin.WhichAccessBatcher if the wrapped
+// DirServer does.
+func (d *dirWrapper) WhichAccessBatch(names []upspin.PathName) ([]upspin.WhichAccessResult, error) {
+	b, ok := d.DirServer.(upspin.WhichAccessBatcher)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	return b.WhichAccessBatch(names)
+}
+
+// GlobShallow implements upspin.ShallowGlobber. If the wrapped DirServer
+// does not implement it, the entries returned by Glob are stripped.
+func (d *dirWrapper) GlobShallow(pattern string) ([]*upspin.DirEntry, error) {
+	if g, ok := d.DirServer.(upspin.ShallowGlobber); ok {
+		return g.GlobShallow(pattern)
+	}
+	entries, err := d.DirServer.Glob(pattern)
+	for _, e := range entries {
+		e.MarkIncomplete()
+	}
+	return entries, err
+}
+
+// WatchMulti implements upspin.MultiWatcher if the wrapped DirServer does.
+func (d *dirWrapper) WatchMulti(paths []upspin.WatchPath, done <-chan struct{}) (<-chan upspin.Event, error) {
+	mw, ok := d.DirServer.(upspin.MultiWatcher)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	return mw.WatchMulti(paths, done)
+}
+
 // Dial implements upspin.Service.
 func (d *dirWrapper) Dial(config upspin.Config, e upspin.Endpoint) (upspin.Service, error) {
 	const op errors.Op = "serverutil/perm.Dial"
@@ -63,3 +158,12 @@ func (d *dirWrapper) Dial(config upspin.Config, e upspin.Endpoint) (upspin.Servi
 	newDir.DirServer = service.(upspin.DirServer)
 	return &newDir, nil
 }
+
+// Replicate implements replica.Source if the wrapped DirServer does.
+func (d *dirWrapper) Replicate(states []replica.UserState, done <-chan struct{}) (<-chan replica.Update, error) {
+	src, ok := d.DirServer.(replica.Source)
+	if !ok {
+		return nil, upspin.ErrNotSupported
+	}
+	return src.Replicate(states, done)
+}
@@ -72,6 +72,24 @@ func (s *storeWrapper) Put(data []byte) (*upspin.Refdata, error) {
 	return s.StoreServer.Put(data)
 }
 
+// PutWithMeta implements upspin.StoreMetaPutter. Users may store data
+// only on their own behalf.
+func (s *storeWrapper) PutWithMeta(data []byte, writer upspin.UserName) (*upspin.Refdata, error) {
+	const op errors.Op = "store/perm.PutWithMeta"
+
+	if !s.perm.IsWriter(s.user) {
+		return nil, errors.E(op, s.user, errors.Permission, "user not authorized")
+	}
+	if writer != s.user {
+		return nil, errors.E(op, s.user, errors.Permission, errors.Errorf("cannot store data for %s", writer))
+	}
+	mp, ok := s.StoreServer.(upspin.StoreMetaPutter)
+	if !ok {
+		return s.StoreServer.Put(data)
+	}
+	return mp.PutWithMeta(data, writer)
+}
+
 // Delete implements upspin.StoreServer.
 func (s *storeWrapper) Delete(ref upspin.Reference) error {
 	const op errors.Op = "store/perm.Delete"
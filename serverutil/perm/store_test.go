@@ -302,4 +302,20 @@ func TestStoreIntegration(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	// Storing data on one's own behalf follows the same rules as Put.
+	ownerMeta := ownerStore.(upspin.StoreMetaPutter)
+	if _, err := ownerMeta.PutWithMeta([]byte("789"), owner); err != nil {
+		t.Fatal(err)
+	}
+	_, err = writerStore.(upspin.StoreMetaPutter).PutWithMeta([]byte("789"), writer)
+	if !errors.Match(expectedErr, err) {
+		t.Fatalf("err = %v, want = %v", err, expectedErr)
+	}
+
+	// Storing data on behalf of someone else fails.
+	_, err = ownerMeta.PutWithMeta([]byte("789"), writer)
+	if !errors.Match(errors.E(errors.Permission, upspin.UserName(owner)), err) {
+		t.Fatalf("err = %v, want Permission", err)
+	}
 }
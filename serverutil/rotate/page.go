@@ -0,0 +1,254 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rotate
+
+// page is the key rotation page. Its script does all work with private
+// keys in the browser; they are never sent to the server.
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Upspin key rotation</title>
+<style>
+body { font-family: sans-serif; max-width: 50em; margin: 2em auto; }
+textarea { width: 100%; font-family: monospace; }
+.step { border-top: 1px solid #ccc; padding: 0.5em 0; }
+.hidden { display: none; }
+#error { color: #a00; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>Rotate your Upspin key</h1>
+<p>
+This page replaces the public key registered for your Upspin user name
+with a new one generated in your browser. Your private keys never leave
+this browser. If you are comfortable with the command line, the
+'upspin keygen -rotate', 'upspin countersign', 'upspin rotate' and
+'upspin share -r -fix' commands do the same.
+</p>
+
+<div class="step" id="step1">
+<h2>1. Sign in with your current key</h2>
+<p>Your keys are in the secrets directory named in your config,
+usually $HOME/.ssh/your@email.address.</p>
+<p><label>User name: <input id="name" size="40"></label></p>
+<p><label>Contents of public.upspinkey:<br><textarea id="oldpub" rows="4"></textarea></label></p>
+<p><label>Contents of secret.upspinkey:<br><textarea id="oldsecret" rows="2"></textarea></label></p>
+<p><button id="signin">Sign in</button></p>
+</div>
+
+<div class="step hidden" id="step2">
+<h2>2. Generate a new key</h2>
+<p><button id="generate">Generate new key</button></p>
+</div>
+
+<div class="step hidden" id="step3">
+<h2>3. Save your new key</h2>
+<p>Your new public key is:</p>
+<pre id="newpub"></pre>
+<p>Download these files and save them in your secrets directory,
+replacing the old public.upspinkey and secret.upspinkey. Append the
+contents of the third to secret2.upspinkey in the same directory,
+creating it if necessary, so that you can still read files encrypted
+for your old key. Keep a backup: there is no way to recover a lost
+secret key.</p>
+<ul>
+<li><a id="dlpub" download="public.upspinkey">public.upspinkey</a></li>
+<li><a id="dlsecret" download="secret.upspinkey">secret.upspinkey</a></li>
+<li><a id="dlsecret2" download="secret2-append.upspinkey">old key, for secret2.upspinkey</a></li>
+</ul>
+<p><label><input type="checkbox" id="saved"> I have saved my new key.</label></p>
+</div>
+
+<div class="step hidden" id="step4">
+<h2>4. Check the new key</h2>
+<p><button id="validate">Check</button> <span id="valid"></span></p>
+</div>
+
+<div class="step hidden" id="step5">
+<h2>5. Update the key server</h2>
+<p><button id="rotate">Update key server</button></p>
+</div>
+
+<div class="step hidden" id="done">
+<h2>Done</h2>
+<p>The key server now has your new key. To finish, run</p>
+<pre>upspin countersign
+upspin share -r -fix your@email.address/</pre>
+<p>so that your existing files are signed by, and readable with, the new key.</p>
+</div>
+
+<p id="error"></p>
+
+<script>
+"use strict";
+
+// Upspin key curve names, with the WebCrypto names and coordinate sizes.
+var curves = {
+	"p256": {name: "P-256", size: 32},
+	"p384": {name: "P-384", size: 48},
+	"p521": {name: "P-521", size: 66}
+};
+
+var state = {};
+
+function $(id) { return document.getElementById(id); }
+function show(id) { $(id).classList.remove("hidden"); }
+function fail(err) { $("error").textContent = String(err); }
+
+function toBytes(n, size) {
+	var b = new Uint8Array(size);
+	for (var i = size - 1; i >= 0; i--) {
+		b[i] = Number(n & 255n);
+		n >>= 8n;
+	}
+	return b;
+}
+
+function fromBytes(b) {
+	var n = 0n;
+	for (var i = 0; i < b.length; i++) {
+		n = (n << 8n) | BigInt(b[i]);
+	}
+	return n;
+}
+
+function b64url(b) {
+	var s = "";
+	for (var i = 0; i < b.length; i++) {
+		s += String.fromCharCode(b[i]);
+	}
+	return btoa(s).replace(/\+/g, "-").replace(/\//g, "_").replace(/=+$/, "");
+}
+
+function unb64url(s) {
+	var bin = atob(s.replace(/-/g, "+").replace(/_/g, "/"));
+	var b = new Uint8Array(bin.length);
+	for (var i = 0; i < bin.length; i++) {
+		b[i] = bin.charCodeAt(i);
+	}
+	return b;
+}
+
+// parseKey returns the curve and coordinates of an Upspin public key.
+function parseKey(pub) {
+	var f = pub.replace(/\r/g, "").trim().split("\n");
+	var c = curves[f[0]];
+	if (f.length !== 3 || !c) {
+		throw new Error("public.upspinkey is not an Upspin public key");
+	}
+	return {curve: f[0], x: BigInt(f[1]), y: BigInt(f[2])};
+}
+
+// sign returns the Upspin text form of an ECDSA signature of the
+// SHA-256 hash of msg.
+async function sign(key, size, msg) {
+	var sig = new Uint8Array(await crypto.subtle.sign(
+		{name: "ECDSA", hash: "SHA-256"}, key, new TextEncoder().encode(msg)));
+	var r = fromBytes(sig.slice(0, size));
+	var s = fromBytes(sig.slice(size));
+	return r.toString(16) + "-" + s.toString(16);
+}
+
+async function post(params) {
+	var resp = await fetch(location.pathname, {
+		method: "POST",
+		body: new URLSearchParams(params)
+	});
+	var text = await resp.text();
+	if (!resp.ok) {
+		throw new Error(text);
+	}
+	return text;
+}
+
+function download(id, text) {
+	$(id).href = URL.createObjectURL(new Blob([text], {type: "text/plain"}));
+}
+
+$("signin").onclick = async function() {
+	fail("");
+	try {
+		state.name = $("name").value.trim();
+		state.oldPub = $("oldpub").value.replace(/\r/g, "").trim() + "\n";
+		state.oldSecret = $("oldsecret").value.trim().split(/\s/)[0];
+		var k = parseKey(state.oldPub);
+		var c = curves[k.curve];
+		state.oldSize = c.size;
+		state.oldKey = await crypto.subtle.importKey("jwk", {
+			kty: "EC",
+			crv: c.name,
+			x: b64url(toBytes(k.x, c.size)),
+			y: b64url(toBytes(k.y, c.size)),
+			d: b64url(toBytes(BigInt(state.oldSecret), c.size)),
+			ext: false
+		}, {name: "ECDSA", namedCurve: c.name}, false, ["sign"]);
+		state.challenge = await post({op: "challenge", name: state.name});
+		show("step2");
+	} catch (e) {
+		fail(e);
+	}
+};
+
+$("generate").onclick = async function() {
+	fail("");
+	try {
+		// New keys are always on P-256, the Upspin default.
+		var pair = await crypto.subtle.generateKey(
+			{name: "ECDSA", namedCurve: "P-256"}, true, ["sign"]);
+		var jwk = await crypto.subtle.exportKey("jwk", pair.privateKey);
+		state.newKey = pair.privateKey;
+		state.newPub = "p256\n" + fromBytes(unb64url(jwk.x)).toString() + "\n" +
+			fromBytes(unb64url(jwk.y)).toString() + "\n";
+		var secret = fromBytes(unb64url(jwk.d)).toString() + "\n";
+		$("newpub").textContent = state.newPub;
+		download("dlpub", state.newPub);
+		download("dlsecret", secret);
+		download("dlsecret2", "# EE " + new Date().toISOString() + "\n" +
+			state.oldPub + state.oldSecret + "\n");
+		show("step3");
+	} catch (e) {
+		fail(e);
+	}
+};
+
+$("saved").onchange = function() {
+	if ($("saved").checked) {
+		show("step4");
+	}
+};
+
+$("validate").onclick = async function() {
+	fail("");
+	try {
+		await post({op: "validate", key: state.newPub});
+		$("valid").textContent = "The new key is well formed.";
+		show("step5");
+	} catch (e) {
+		fail(e);
+	}
+};
+
+$("rotate").onclick = async function() {
+	fail("");
+	try {
+		var msg = "upspin-rotate\n" + state.name + "\n" + state.challenge + "\n" + state.newPub;
+		await post({
+			op: "rotate",
+			name: state.name,
+			challenge: state.challenge,
+			key: state.newPub,
+			sig: await sign(state.oldKey, state.oldSize, msg),
+			newsig: await sign(state.newKey, 32, msg)
+		});
+		show("done");
+	} catch (e) {
+		fail(e);
+	}
+};
+</script>
+</body>
+</html>
+`
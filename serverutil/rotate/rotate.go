@@ -0,0 +1,277 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package rotate provides an http.Handler that lets users rotate their
+// keys from a web browser, as an alternative to the 'upspin keygen
+// -rotate', 'upspin rotate' sequence.
+//
+// The handler serves a page that, using the browser's WebCrypto API,
+// signs a server-issued challenge with the user's current key, generates
+// a new P-256 key pair and offers it for download. The server checks the
+// signatures and the format of the new public key before updating the
+// user's record on the key server.
+//
+// The page and the server speak a small protocol of POST requests to the
+// handler, distinguished by the "op" form value:
+//
+//	op=challenge name=<user>
+//		returns a challenge, valid for a short time, for the user.
+//	op=validate key=<public key>
+//		checks that the public key is well formed.
+//	op=rotate name=<user> challenge=<challenge> key=<new public key>
+//	    sig=<signature> newsig=<signature>
+//		replaces the user's public key with the new one.
+//
+// Both signatures in a rotate request are ECDSA signatures, in the form
+// returned by factotum.FormatSignature, of the SHA-256 hash of the
+// message returned by Message: sig by the user's current key and newsig
+// by the new key, proving possession of both.
+package rotate // import "upspin.io/serverutil/rotate"
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"upspin.io/config"
+	"upspin.io/errors"
+	"upspin.io/factotum"
+	"upspin.io/log"
+	"upspin.io/serverutil"
+	"upspin.io/upspin"
+	"upspin.io/valid"
+)
+
+// challengeLifetime is how long a challenge may be used.
+const challengeLifetime = 10 * time.Minute
+
+// timeNow is the clock used to issue and check challenges. Tests replace it.
+var timeNow = time.Now
+
+// handler implements an http.Handler that serves the key rotation page
+// and the requests it makes.
+type handler struct {
+	fact   upspin.Factotum
+	key    upspin.KeyServer
+	notify func(upspin.UserName)
+
+	rate serverutil.RateLimiter
+}
+
+// NewHandler returns a handler that serves key rotation requests for
+// users of the given KeyServer. The Factotum, which is the key server's
+// own, is used to sign challenges. If notify is not nil, it is called
+// with the name of each user whose key is replaced.
+func NewHandler(fact upspin.Factotum, key upspin.KeyServer, notify func(upspin.UserName)) http.Handler {
+	return &handler{
+		fact:   fact,
+		key:    key,
+		notify: notify,
+		rate: serverutil.RateLimiter{
+			Backoff: 5 * time.Second,
+			Max:     time.Hour,
+		},
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == "GET" || r.Method == "HEAD" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		fmt.Fprint(w, page)
+		return
+	}
+	if r.Method != "POST" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	v := r.FormValue
+	var err error
+	switch v("op") {
+	case "challenge":
+		var c string
+		c, err = h.challenge(upspin.UserName(v("name")))
+		if err == nil {
+			fmt.Fprint(w, c)
+			return
+		}
+	case "validate":
+		err = validKey(upspin.PublicKey(v("key")))
+	case "rotate":
+		// Rate limit attempts, to slow any guessing of signatures.
+		name := strings.ToLower(v("name"))
+		if ok, wait := h.rate.Pass(name); !ok {
+			msg := fmt.Sprintf("repeated rotation attempt; please wait %v before trying again", wait)
+			http.Error(w, msg, http.StatusTooManyRequests)
+			return
+		}
+		err = h.rotate(upspin.UserName(v("name")), v("challenge"), upspin.PublicKey(v("key")), v("sig"), v("newsig"))
+	default:
+		err = errors.E(errors.Invalid, "unknown op")
+	}
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	fmt.Fprintln(w, "OK")
+}
+
+// challenge returns a challenge for the named user, formed from the
+// current time and the key server's signature of it and the name.
+func (h *handler) challenge(name upspin.UserName) (string, error) {
+	if err := valid.UserName(name); err != nil {
+		return "", err
+	}
+	now := timeNow().Unix()
+	sig, err := h.fact.Sign(challengeHash(name, now))
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d.%s", now, factotum.FormatSignature(sig)), nil
+}
+
+// checkChallenge checks that the challenge was issued by this key server
+// for the named user and has not expired.
+func (h *handler) checkChallenge(name upspin.UserName, challenge string) error {
+	i := strings.IndexByte(challenge, '.')
+	if i < 0 {
+		return errors.E(errors.Invalid, "malformed challenge")
+	}
+	issued, err := strconv.ParseInt(challenge[:i], 10, 64)
+	if err != nil {
+		return errors.E(errors.Invalid, "malformed challenge")
+	}
+	sig, err := factotum.ParseSignature(challenge[i+1:])
+	if err != nil {
+		return errors.E(errors.Invalid, "malformed challenge")
+	}
+	if err := factotum.Verify(challengeHash(name, issued), sig, h.fact.PublicKey()); err != nil {
+		return errors.E(errors.Permission, "challenge was not issued for this user")
+	}
+	if timeNow().After(time.Unix(issued, 0).Add(challengeLifetime)) {
+		return errors.E(errors.Permission, "challenge has expired; please try again")
+	}
+	return nil
+}
+
+func challengeHash(name upspin.UserName, issued int64) []byte {
+	h := sha256.Sum256([]byte(fmt.Sprintf("upspin-rotate-challenge:%s:%d", name, issued)))
+	return h[:]
+}
+
+// Message returns the message that is signed, with both the current and
+// the new key, to rotate the named user's key to the new one.
+func Message(name upspin.UserName, challenge string, newKey upspin.PublicKey) []byte {
+	return []byte(fmt.Sprintf("upspin-rotate\n%s\n%s\n%s", name, challenge, newKey))
+}
+
+// rotate replaces the named user's public key with newKey, after
+// checking the challenge and the signatures by the current and new keys.
+func (h *handler) rotate(name upspin.UserName, challenge string, newKey upspin.PublicKey, sig, newSig string) error {
+	const op errors.Op = "serverutil/rotate"
+	if err := valid.UserName(name); err != nil {
+		return errors.E(op, err)
+	}
+	if err := h.checkChallenge(name, challenge); err != nil {
+		return errors.E(op, name, err)
+	}
+	if err := validKey(newKey); err != nil {
+		return errors.E(op, name, err)
+	}
+	u, err := h.key.Lookup(name)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	if u.PublicKey == newKey {
+		return errors.E(op, name, errors.Invalid, "new key is the same as the current key")
+	}
+	// A revoked key may be in the wrong hands, so it cannot rotate to
+	// another, nor can one be rotated back to it.
+	for _, k := range u.Revoked {
+		switch k {
+		case u.PublicKey:
+			return errors.E(op, name, errors.Permission, "current key has been revoked")
+		case newKey:
+			return errors.E(op, name, errors.Invalid, "new key has been revoked")
+		}
+	}
+
+	hash := sha256.Sum256(Message(name, challenge, newKey))
+	if err := verify(hash[:], sig, u.PublicKey); err != nil {
+		return errors.E(op, name, errors.Permission, errors.Errorf("signature by current key: %v", err))
+	}
+	if err := verify(hash[:], newSig, newKey); err != nil {
+		return errors.E(op, name, errors.Permission, errors.Errorf("signature by new key: %v", err))
+	}
+
+	key, err := h.dialForUser(name)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	defer key.Close()
+	u.PublicKey = newKey
+	if err := key.Put(u); err != nil {
+		return errors.E(op, err)
+	}
+	log.Info.Printf("rotate: rotated key for %q", name)
+	if h.notify != nil {
+		h.notify(name)
+	}
+	return nil
+}
+
+func verify(hash []byte, sig string, key upspin.PublicKey) error {
+	s, err := factotum.ParseSignature(sig)
+	if err != nil {
+		return err
+	}
+	return factotum.Verify(hash, s, key)
+}
+
+// validKey checks that the public key is in the Upspin format and
+// represents a point on its curve.
+func validKey(key upspin.PublicKey) error {
+	pub, err := factotum.ParsePublicKey(key)
+	if err != nil {
+		return err
+	}
+	if !pub.Curve.IsOnCurve(pub.X, pub.Y) {
+		return errors.E(errors.Invalid, "public key is not on its curve")
+	}
+	return nil
+}
+
+// dialForUser dials the key server locally as the named user, so that
+// the user is implicitly authenticated with it.
+func (h *handler) dialForUser(name upspin.UserName) (upspin.KeyServer, error) {
+	cfg := config.New()
+	cfg = config.SetKeyEndpoint(cfg, h.key.Endpoint())
+	cfg = config.SetUserName(cfg, name)
+
+	service, err := h.key.Dial(cfg, h.key.Endpoint())
+	if err != nil {
+		return nil, err
+	}
+	keyServer, ok := service.(upspin.KeyServer)
+	if !ok {
+		return nil, errors.E(errors.Internal, "dialed service not an instance of upspin.KeyServer")
+	}
+	return keyServer, nil
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(errors.Invalid, err):
+		code = http.StatusBadRequest
+	case errors.Is(errors.Permission, err):
+		code = http.StatusForbidden
+	case errors.Is(errors.NotExist, err):
+		code = http.StatusNotFound
+	}
+	http.Error(w, err.Error(), code)
+}
@@ -0,0 +1,253 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package rotate
+
+import (
+	"crypto/sha256"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"upspin.io/factotum"
+	"upspin.io/key/inprocess"
+	"upspin.io/key/keygen"
+	"upspin.io/serverutil"
+	"upspin.io/test/testutil"
+	"upspin.io/upspin"
+)
+
+const userName = upspin.UserName("bob@example.com")
+
+type rotateTest struct {
+	t   *testing.T
+	srv *httptest.Server
+	h   *handler
+	key upspin.KeyServer
+	old upspin.Factotum
+}
+
+func setup(t *testing.T) *rotateTest {
+	serverFact, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	userFact, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "bob"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := inprocess.New()
+	h := NewHandler(serverFact, key, nil).(*handler)
+	h.rate = serverutil.RateLimiter{} // No rate limiting, except where tested.
+
+	userKey, err := h.dialForUser(userName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := userKey.Put(&upspin.User{Name: userName, PublicKey: userFact.PublicKey()}); err != nil {
+		t.Fatal(err)
+	}
+	return &rotateTest{
+		t:   t,
+		srv: httptest.NewServer(h),
+		h:   h,
+		key: key,
+		old: userFact,
+	}
+}
+
+// post makes a POST request with the given form values and returns the
+// response status and body.
+func (r *rotateTest) post(vals url.Values) (int, string) {
+	r.t.Helper()
+	resp, err := http.PostForm(r.srv.URL, vals)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func (r *rotateTest) challenge(name upspin.UserName) string {
+	r.t.Helper()
+	code, body := r.post(url.Values{"op": {"challenge"}, "name": {string(name)}})
+	if code != http.StatusOK {
+		r.t.Fatalf("challenge: %d %s", code, body)
+	}
+	return body
+}
+
+// newKey returns a new P-256 key pair, as the page generates.
+func newKey(t *testing.T) (upspin.PublicKey, upspin.Factotum) {
+	pub, priv, _, err := keygen.Generate("p256")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := factotum.NewFromKeys([]byte(pub), []byte(priv), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return upspin.PublicKey(pub), f
+}
+
+// rotateRequest returns the values of a rotate request for the named
+// user, signed by the given factotums.
+func rotateRequest(t *testing.T, name upspin.UserName, challenge string, pub upspin.PublicKey, old, new upspin.Factotum) url.Values {
+	hash := sha256.Sum256(Message(name, challenge, pub))
+	sig, err := old.Sign(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	newSig, err := new.Sign(hash[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return url.Values{
+		"op":        {"rotate"},
+		"name":      {string(name)},
+		"challenge": {challenge},
+		"key":       {string(pub)},
+		"sig":       {factotum.FormatSignature(sig)},
+		"newsig":    {factotum.FormatSignature(newSig)},
+	}
+}
+
+func (r *rotateTest) currentKey() upspin.PublicKey {
+	r.t.Helper()
+	u, err := r.key.Lookup(userName)
+	if err != nil {
+		r.t.Fatal(err)
+	}
+	return u.PublicKey
+}
+
+func TestPage(t *testing.T) {
+	r := setup(t)
+	defer r.srv.Close()
+
+	resp, err := http.Get(r.srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET: %s", resp.Status)
+	}
+	for _, want := range []string{"crypto.subtle.generateKey", `namedCurve: "P-256"`, `op: "rotate"`} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+}
+
+func TestRotate(t *testing.T) {
+	r := setup(t)
+	defer r.srv.Close()
+
+	pub, newFact := newKey(t)
+	if code, body := r.post(url.Values{"op": {"validate"}, "key": {string(pub)}}); code != http.StatusOK {
+		t.Fatalf("validate: %d %s", code, body)
+	}
+	c := r.challenge(userName)
+	if code, body := r.post(rotateRequest(t, userName, c, pub, r.old, newFact)); code != http.StatusOK {
+		t.Fatalf("rotate: %d %s", code, body)
+	}
+	if got := r.currentKey(); got != pub {
+		t.Errorf("key after rotation = %q, want %q", got, pub)
+	}
+
+	// The request cannot be replayed: the old key is no longer current.
+	pub2, newFact2 := newKey(t)
+	if code, _ := r.post(rotateRequest(t, userName, c, pub2, r.old, newFact2)); code != http.StatusForbidden {
+		t.Errorf("replay with old key: got %d, want %d", code, http.StatusForbidden)
+	}
+	if got := r.currentKey(); got != pub {
+		t.Errorf("key after replay = %q, want %q", got, pub)
+	}
+}
+
+func TestRefused(t *testing.T) {
+	r := setup(t)
+	defer r.srv.Close()
+
+	oldKey := r.currentKey()
+	pub, newFact := newKey(t)
+	_, otherFact := newKey(t)
+	c := r.challenge(userName)
+	otherChallenge := r.challenge("carla@example.com")
+
+	tests := []struct {
+		name string
+		vals url.Values
+		code int
+	}{
+		{"not signed by current key", rotateRequest(t, userName, c, pub, otherFact, newFact), http.StatusForbidden},
+		{"not signed by new key", rotateRequest(t, userName, c, pub, r.old, otherFact), http.StatusForbidden},
+		{"challenge for another user", rotateRequest(t, userName, otherChallenge, pub, r.old, newFact), http.StatusForbidden},
+		{"malformed challenge", rotateRequest(t, userName, "12345", pub, r.old, newFact), http.StatusBadRequest},
+		{"malformed key", rotateRequest(t, userName, c, "p256\n1\n2\n", r.old, newFact), http.StatusBadRequest},
+		{"same key", rotateRequest(t, userName, c, oldKey, r.old, r.old), http.StatusBadRequest},
+		{"unknown user", rotateRequest(t, "nobody@example.com", r.challenge("nobody@example.com"), pub, r.old, newFact), http.StatusNotFound},
+	}
+	for _, test := range tests {
+		code, body := r.post(test.vals)
+		if code != test.code {
+			t.Errorf("%s: got %d %q, want %d", test.name, code, body, test.code)
+		}
+	}
+	if got := r.currentKey(); got != oldKey {
+		t.Errorf("key changed by refused requests")
+	}
+
+	// Challenges expire.
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return time.Now().Add(challengeLifetime + time.Minute) }
+	if code, _ := r.post(rotateRequest(t, userName, c, pub, r.old, newFact)); code != http.StatusForbidden {
+		t.Errorf("expired challenge: got %d, want %d", code, http.StatusForbidden)
+	}
+
+	// Repeated attempts are rate limited.
+	r.h.rate = serverutil.RateLimiter{Backoff: time.Minute, Max: time.Hour}
+	r.post(url.Values{"op": {"rotate"}, "name": {string(userName)}})
+	if code, _ := r.post(url.Values{"op": {"rotate"}, "name": {string(userName)}}); code != http.StatusTooManyRequests {
+		t.Errorf("repeated attempt: got %d, want %d", code, http.StatusTooManyRequests)
+	}
+}
+
+func TestRevokedKey(t *testing.T) {
+	r := setup(t)
+	defer r.srv.Close()
+
+	// Revoke the current key, as its owner would if it were stolen.
+	oldKey := r.currentKey()
+	sig, err := r.old.Sign(factotum.RevokeHash(oldKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.key.(upspin.KeyRevoker).RevokeKey(userName, oldKey, []byte(factotum.FormatSignature(sig))); err != nil {
+		t.Fatal(err)
+	}
+
+	// Whoever holds it cannot use it to install a key of their own.
+	pub, newFact := newKey(t)
+	c := r.challenge(userName)
+	if code, body := r.post(rotateRequest(t, userName, c, pub, r.old, newFact)); code != http.StatusForbidden {
+		t.Errorf("rotate with revoked key: got %d %q, want %d", code, body, http.StatusForbidden)
+	}
+	if got := r.currentKey(); got != oldKey {
+		t.Errorf("key changed by rotation with revoked key")
+	}
+}
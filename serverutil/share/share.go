@@ -0,0 +1,180 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package share provides an http.Handler that serves single Upspin files
+// of the handler's user to anyone presenting a URL signed by that user.
+// The URLs, made by
+// 'upspin link -web', carry an expiry time and are refused once it has
+// passed. For example, a request for
+//
+//	https://host.example.com/share/ann@example.com/report.pdf?expires=1500000000&sig=...
+//
+// returns the Upspin file
+//
+//	ann@example.com/report.pdf
+//
+// if the handler runs as ann@example.com, the signature is hers and the
+// time has not passed.
+package share // import "upspin.io/serverutil/share"
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"upspin.io/bind"
+	"upspin.io/client"
+	"upspin.io/errors"
+	"upspin.io/factotum"
+	"upspin.io/log"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+// Prefix is the URL path under which the handler serves files.
+const Prefix = "/share/"
+
+// timeNow is the clock used to check expiry. Tests replace it.
+var timeNow = time.Now
+
+// URL returns the path and query of a URL, to be appended to the address
+// of a gateway, that serves the named file until the expiry time. The
+// URL is signed by the user of the configuration, who must own the file.
+func URL(cfg upspin.Config, name upspin.PathName, expires time.Time) (string, error) {
+	const op errors.Op = "serverutil/share.URL"
+	p, err := path.Parse(name)
+	if err != nil {
+		return "", errors.E(op, err)
+	}
+	if p.User() != cfg.UserName() {
+		return "", errors.E(op, p.Path(), errors.Permission, "can only share own files")
+	}
+	exp := expires.Unix()
+	sig, err := cfg.Factotum().Sign(hash(p.Path(), exp))
+	if err != nil {
+		return "", errors.E(op, p.Path(), err)
+	}
+	u := url.URL{
+		Path: Prefix + string(p.Path()),
+		RawQuery: url.Values{
+			"expires": {strconv.FormatInt(exp, 10)},
+			"sig":     {factotum.FormatSignature(sig)},
+		}.Encode(),
+	}
+	return u.String(), nil
+}
+
+// hash returns the hash that is signed to share the name until the
+// expiry time, given in seconds since the Unix epoch.
+func hash(name upspin.PathName, expires int64) []byte {
+	h := sha256.Sum256([]byte(fmt.Sprintf("upspin-share:%s:%d", name, expires)))
+	return h[:]
+}
+
+// New returns an http.Handler that serves files named by URLs made by
+// the URL function. It serves only files in the tree of the user of the
+// configuration, with which it reads them, and only for URLs signed by
+// that user. Links are not followed, as their targets may be files
+// that the user can read but has not chosen to share.
+func New(cfg upspin.Config) http.Handler {
+	return &handler{
+		cfg: cfg,
+		cli: client.New(cfg),
+	}
+}
+
+type handler struct {
+	cfg upspin.Config
+	cli upspin.Client
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !strings.HasPrefix(r.URL.Path, Prefix) {
+		http.NotFound(w, r)
+		return
+	}
+	p, err := path.Parse(upspin.PathName(strings.TrimPrefix(r.URL.Path, Prefix)))
+	if err != nil {
+		http.Error(w, "Parse: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	name := p.Path()
+
+	if err := h.verify(p, r.URL.Query()); err != nil {
+		log.Debug.Printf("serverutil/share: refused %s: %v", name, err)
+		code := http.StatusForbidden
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+
+	entry, err := h.cli.Lookup(name, false)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	if entry.IsDir() || entry.IsLink() {
+		code := http.StatusForbidden
+		http.Error(w, http.StatusText(code), code)
+		return
+	}
+	f, err := h.cli.Open(entry.Name)
+	if err != nil {
+		httpError(w, err)
+		return
+	}
+	defer f.Close()
+	// The URL is a capability; don't let shared caches keep the file.
+	w.Header().Set("Cache-Control", "private")
+	// ServeContent sets the Content-Type from the name and handles
+	// range requests.
+	http.ServeContent(w, r, string(name), time.Unix(int64(entry.Time), 0), f)
+}
+
+// verify checks that the path is in the tree of the handler's user and
+// that the query carries an unexpired expiry time and a signature of it
+// and the path by that user.
+func (h *handler) verify(p path.Parsed, q url.Values) error {
+	if p.User() != h.cfg.UserName() {
+		return errors.E(errors.Permission, "not a file of the gateway's user")
+	}
+	exp, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+	if err != nil {
+		return errors.E(errors.Invalid, "bad expiry time")
+	}
+	if !timeNow().Before(time.Unix(exp, 0)) {
+		return errors.E(errors.Permission, "link has expired")
+	}
+	sig, err := factotum.ParseSignature(q.Get("sig"))
+	if err != nil {
+		return err
+	}
+	key, err := bind.KeyServer(h.cfg, h.cfg.KeyEndpoint())
+	if err != nil {
+		return err
+	}
+	u, err := key.Lookup(p.User())
+	if err != nil {
+		return err
+	}
+	return factotum.Verify(hash(p.Path(), exp), sig, u.PublicKey)
+}
+
+func httpError(w http.ResponseWriter, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(errors.Private, err), errors.Is(errors.Permission, err):
+		code = http.StatusForbidden
+	case errors.Is(errors.NotExist, err), errors.Is(errors.BrokenLink, err):
+		code = http.StatusNotFound
+	}
+	http.Error(w, http.StatusText(code), code)
+}
@@ -0,0 +1,201 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package share
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"upspin.io/test/testenv"
+	"upspin.io/upspin"
+)
+
+const (
+	owner = "aly@example.com" // aly has keys in key/testdata/aly
+	other = "bob@uncle.com"   // bob has keys in key/testdata/bob
+
+	fileName = owner + "/dir/hello.txt"
+	contents = "hello, world\n"
+)
+
+func setup(t *testing.T) (ownerEnv, otherEnv *testenv.Env, srv *httptest.Server) {
+	var err error
+	ownerEnv, err = testenv.New(&testenv.Setup{
+		OwnerName: owner,
+		Packing:   upspin.EEPack,
+		Kind:      "inprocess",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherEnv, err = testenv.New(&testenv.Setup{
+		OwnerName: other,
+		Packing:   upspin.EEPack,
+		Kind:      "inprocess",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := testenv.NewRunner()
+	r.AddUser(ownerEnv.Config)
+	r.As(owner)
+	r.MakeDirectory(owner + "/dir")
+	r.Put(fileName, contents)
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+	srv = httptest.NewServer(New(ownerEnv.Config))
+	return ownerEnv, otherEnv, srv
+}
+
+func get(t *testing.T, u string, hdr ...string) (int, string, http.Header) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i+1 < len(hdr); i += 2 {
+		req.Header.Set(hdr[i], hdr[i+1])
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(b), resp.Header
+}
+
+func TestServe(t *testing.T) {
+	ownerEnv, otherEnv, srv := setup(t)
+	defer ownerEnv.Exit()
+	defer otherEnv.Exit()
+	defer srv.Close()
+
+	u, err := URL(ownerEnv.Config, fileName, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, body, hdr := get(t, srv.URL+u)
+	if code != http.StatusOK || body != contents {
+		t.Fatalf("got %d %q, want %d %q", code, body, http.StatusOK, contents)
+	}
+	if ct := hdr.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Errorf("Content-Type = %q, want text/plain", ct)
+	}
+
+	code, body, _ = get(t, srv.URL+u, "Range", "bytes=7-11")
+	if code != http.StatusPartialContent || body != "world" {
+		t.Errorf("range: got %d %q, want %d %q", code, body, http.StatusPartialContent, "world")
+	}
+
+	// Directories are not served.
+	u, err = URL(ownerEnv.Config, owner+"/dir", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _, _ := get(t, srv.URL+u); code != http.StatusForbidden {
+		t.Errorf("directory: got %d, want %d", code, http.StatusForbidden)
+	}
+
+	// Nor are links, whose targets the owner may not mean to share.
+	r := testenv.NewRunner()
+	r.AddUser(ownerEnv.Config)
+	r.As(owner)
+	r.PutLink(fileName, owner+"/dir/link")
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+	u, err = URL(ownerEnv.Config, owner+"/dir/link", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, body, _ := get(t, srv.URL+u); code != http.StatusForbidden {
+		t.Errorf("link: got %d %q, want %d", code, body, http.StatusForbidden)
+	}
+}
+
+func TestRefused(t *testing.T) {
+	ownerEnv, otherEnv, srv := setup(t)
+	defer ownerEnv.Exit()
+	defer otherEnv.Exit()
+	defer srv.Close()
+
+	if _, err := URL(otherEnv.Config, fileName, time.Now().Add(time.Hour)); err == nil {
+		t.Errorf("URL for another user's file succeeded")
+	}
+
+	good, err := URL(ownerEnv.Config, fileName, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := url.Parse(good)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := parsed.Query()
+
+	// A signature by another user, as if they owned the file.
+	otherSig, err := URL(otherEnv.Config, other+"/dir/hello.txt", time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherParsed, err := url.Parse(otherSig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		expires string
+		sig     string
+	}{
+		{"other file", Prefix + owner + "/dir/other.txt", q.Get("expires"), q.Get("sig")},
+		{"later expiry", parsed.Path, "99999999999", q.Get("sig")},
+		{"no signature", parsed.Path, q.Get("expires"), ""},
+		{"bad signature", parsed.Path, q.Get("expires"), "1234-5678"},
+		{"no expiry", parsed.Path, "", q.Get("sig")},
+		{"other signer", parsed.Path, otherParsed.Query().Get("expires"), otherParsed.Query().Get("sig")},
+		{"other user's file", otherParsed.Path, otherParsed.Query().Get("expires"), otherParsed.Query().Get("sig")},
+	}
+	for _, test := range tests {
+		v := url.Values{"expires": {test.expires}, "sig": {test.sig}}
+		u := srv.URL + test.path + "?" + v.Encode()
+		if code, _, _ := get(t, u); code != http.StatusForbidden {
+			t.Errorf("%s: got %d, want %d", test.name, code, http.StatusForbidden)
+		}
+	}
+}
+
+func TestExpired(t *testing.T) {
+	ownerEnv, otherEnv, srv := setup(t)
+	defer ownerEnv.Exit()
+	defer otherEnv.Exit()
+	defer srv.Close()
+
+	now := time.Now()
+	defer func() { timeNow = time.Now }()
+	timeNow = func() time.Time { return now }
+
+	u, err := URL(ownerEnv.Config, fileName, now.Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code, _, _ := get(t, srv.URL+u); code != http.StatusOK {
+		t.Fatalf("before expiry: got %d, want %d", code, http.StatusOK)
+	}
+	now = now.Add(time.Hour)
+	if code, _, _ := get(t, srv.URL+u); code != http.StatusForbidden {
+		t.Errorf("at expiry: got %d, want %d", code, http.StatusForbidden)
+	}
+}
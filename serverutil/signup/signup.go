@@ -65,6 +65,10 @@ type MailConfig struct {
 
 	// From specifies the address from which to send mail messages.
 	From string
+
+	// Webhook, if not nil, is notified of each signup, in addition to
+	// the Notify address.
+	Webhook *Webhook
 }
 
 // NewHandler creates a new handler that serves signup requests (made by
@@ -178,6 +182,7 @@ func (m *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			log.Error.Printf("signup: error sending mail to %q: %v", m.mail.Notify, err)
 			// Don't prevent signup if this fails.
 		}
+		m.mail.Webhook.Notify(u.Name, ActionSignup)
 		log.Info.Printf("signup: registration complete for %q", u.Name)
 
 		// TODO(adg): display user friendly welcome message
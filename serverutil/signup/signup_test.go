@@ -5,11 +5,13 @@
 package signup
 
 import (
+	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
 	"upspin.io/config"
 	"upspin.io/factotum"
@@ -26,10 +28,13 @@ func TestSignup(t *testing.T) {
 	}
 	key := inprocess.New()
 	mail := &mailStub{}
+	events, hook := newWebhookReceiver(t, "sesame", 0)
+	defer hook.Close()
 	mc := MailConfig{
 		Project: "test",
 		Mail:    mail,
 		Notify:  "signup@noti.fy",
+		Webhook: &Webhook{URL: hook.URL, Secret: "sesame"},
 	}
 	h := NewHandler("will-be-overridden", serverFact, key, &mc)
 	s := httptest.NewServer(h)
@@ -122,6 +127,15 @@ func TestSignup(t *testing.T) {
 		if !strings.Contains(mail.text[1], string(userName)) {
 			t.Errorf("signup notification %q does not contain %q", mail.text[1], userName)
 		}
+		// And that the webhook was called.
+		select {
+		case e := <-events:
+			if e.User != userName || e.Action != ActionSignup || e.Time.IsZero() {
+				t.Errorf("webhook got %+v, want signup of %q", e, userName)
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("webhook not called")
+		}
 
 		userName = "yob-" + userName
 		mail.text = nil
@@ -138,3 +152,68 @@ func (m *mailStub) Send(to, from, subject, text, html string) error {
 	m.text = append(m.text, text)
 	return nil
 }
+
+func TestWebhook(t *testing.T) {
+	defer func(d time.Duration) { webhookBackoff = d }(webhookBackoff)
+	webhookBackoff = time.Millisecond
+
+	// The receiver fails the first two attempts.
+	events, hook := newWebhookReceiver(t, "sesame", 2)
+	defer hook.Close()
+	w := &Webhook{URL: hook.URL, Secret: "sesame", Retries: 2}
+	if err := w.post(WebhookEvent{User: "bob@example.com", Action: ActionKeyUpdate}); err != nil {
+		t.Fatal(err)
+	}
+	if e := <-events; e.User != "bob@example.com" || e.Action != ActionKeyUpdate {
+		t.Errorf("webhook got %+v", e)
+	}
+
+	// With fewer retries it gives up.
+	events, hook = newWebhookReceiver(t, "sesame", 2)
+	defer hook.Close()
+	w = &Webhook{URL: hook.URL, Secret: "sesame", Retries: 1}
+	if err := w.post(WebhookEvent{User: "bob@example.com", Action: ActionSignup}); err == nil {
+		t.Error("post succeeded after failures outlasted the retries")
+	}
+
+	// A receiver with another secret rejects the signature.
+	events, hook = newWebhookReceiver(t, "open", 0)
+	defer hook.Close()
+	w = &Webhook{URL: hook.URL, Secret: "sesame"}
+	if err := w.post(WebhookEvent{User: "bob@example.com", Action: ActionSignup}); err == nil {
+		t.Error("post succeeded with the wrong secret")
+	}
+	if len(events) != 0 {
+		t.Errorf("receiver accepted %d events with the wrong secret", len(events))
+	}
+}
+
+// newWebhookReceiver starts a server that checks the signature of the
+// webhook requests it receives, made with secret, and sends their events
+// on the returned channel. It fails the first failures requests.
+func newWebhookReceiver(t *testing.T, secret string, failures int) (<-chan WebhookEvent, *httptest.Server) {
+	events := make(chan WebhookEvent, 10)
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures > 0 {
+			failures--
+			http.Error(w, "try again", http.StatusServiceUnavailable)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		if !VerifyWebhook(secret, body, r.Header.Get(SignatureHeader)) {
+			http.Error(w, "bad signature", http.StatusForbidden)
+			return
+		}
+		var e WebhookEvent
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Error(err)
+			return
+		}
+		events <- e
+	}))
+	return events, s
+}
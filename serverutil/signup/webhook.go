@@ -0,0 +1,141 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package signup
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/log"
+	"upspin.io/upspin"
+)
+
+// Actions reported by a Webhook.
+const (
+	ActionSignup    = "signup"     // A user completed signing up.
+	ActionKeyUpdate = "key-update" // A user replaced their public key.
+)
+
+// webhookBackoff is the wait before the first retry of a webhook request;
+// later retries wait longer. Tests replace it.
+var webhookBackoff = time.Second
+
+// SignatureHeader is the HTTP header of a webhook request that holds
+// "sha256=" followed by the hex-encoded HMAC-SHA256 of the request body,
+// keyed by the webhook's secret.
+const SignatureHeader = "X-Upspin-Signature"
+
+// Webhook posts a notification of signups and key updates to a URL,
+// such as that of a chat service.
+type Webhook struct {
+	// URL is the address to which notifications are posted.
+	URL string
+
+	// Secret is the key with which notifications are signed.
+	Secret string
+
+	// Timeout bounds each attempt to post a notification.
+	// If zero, 10 seconds is used.
+	Timeout time.Duration
+
+	// Retries is the number of times a failed attempt is retried,
+	// after waiting a second, then two seconds, and so on.
+	Retries int
+}
+
+// WebhookEvent is the JSON payload of a webhook request.
+type WebhookEvent struct {
+	User   upspin.UserName `json:"user"`
+	Action string          `json:"action"` // ActionSignup or ActionKeyUpdate.
+	Time   time.Time       `json:"time"`
+}
+
+// Notify posts in the background a notification that the user has taken
+// the action. Failures are logged but not otherwise reported.
+// It does nothing if w is nil.
+func (w *Webhook) Notify(name upspin.UserName, action string) {
+	if w == nil {
+		return
+	}
+	event := WebhookEvent{
+		User:   name,
+		Action: action,
+		Time:   time.Now().UTC(),
+	}
+	go func() {
+		if err := w.post(event); err != nil {
+			log.Error.Printf("signup: webhook for %s of %q: %v", action, name, err)
+		}
+	}()
+}
+
+// post posts the event, retrying as needed.
+func (w *Webhook) post(event WebhookEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	timeout := w.Timeout
+	if timeout == 0 {
+		timeout = 10 * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+	sig := "sha256=" + hex.EncodeToString(webhookMAC(w.Secret, body))
+	for i := 0; ; i++ {
+		err = w.postOnce(client, body, sig)
+		if err == nil || i >= w.Retries {
+			return err
+		}
+		time.Sleep(time.Duration(i+1) * webhookBackoff)
+	}
+}
+
+func (w *Webhook) postOnce(client *http.Client, body []byte, sig string) error {
+	req, err := http.NewRequest("POST", w.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(SignatureHeader, sig)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return errors.Str(resp.Status)
+	}
+	return nil
+}
+
+// VerifyWebhook reports whether sig, the value of the SignatureHeader
+// of a webhook request, is a valid signature of body with the secret.
+// It is for use by receivers of webhook requests.
+func VerifyWebhook(secret string, body []byte, sig string) bool {
+	hexMAC := strings.TrimPrefix(sig, "sha256=")
+	if hexMAC == sig {
+		return false
+	}
+	mac, err := hex.DecodeString(hexMAC)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(mac, webhookMAC(secret, body))
+}
+
+func webhookMAC(secret string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -7,16 +7,21 @@
 package storeserver // import "upspin.io/serverutil/storeserver"
 
 import (
+	"flag"
 	"net/http"
 
+	"upspin.io/bind"
+	"upspin.io/cloud/https"
 	"upspin.io/config"
 	"upspin.io/errors"
 	"upspin.io/flags"
 	"upspin.io/log"
 	"upspin.io/rpc/storeserver"
+	"upspin.io/serverutil"
 	"upspin.io/serverutil/perm"
 	"upspin.io/store/inprocess"
 	"upspin.io/store/server"
+	"upspin.io/store/standby"
 	"upspin.io/upspin"
 
 	// Directory transports to fetch write permissions.
@@ -27,6 +32,13 @@ import (
 	_ "upspin.io/pack/plain"
 )
 
+var (
+	standbyAddr     = flag.String("standby-addr", "", "`endpoint` of a hot standby to which every Put and Delete is mirrored")
+	primaryAddr     = flag.String("primary-addr", "", "serve as the hot standby of the store server at `host:port`, taking over if it fails")
+	standbyRegistry = flag.String("standby-registry", "", "`file`, shared by a primary and its standby, recording which is the primary")
+	failoverAfter   = flag.Duration("failover-after", standby.FailoverAfter, "how long the primary must be unreachable before the standby takes over")
+)
+
 func Main() (ready chan<- struct{}) {
 	flags.Parse(flags.Server, "kind", "serverconfig")
 
@@ -51,6 +63,19 @@ func Main() (ready chan<- struct{}) {
 		log.Fatalf("Setting up StoreServer: %v", err)
 	}
 
+	if *standbyAddr != "" || *primaryAddr != "" {
+		store = setupStandby(cfg, store)
+	}
+
+	h := serverutil.NewHealth()
+	h.Add("storage", serverutil.StoreCheck(store))
+	h.Add("keyserver", serverutil.KeyServerCheck(cfg))
+	if check := https.CertificateCheck(https.OptionsFromFlags()); check != nil {
+		h.AddDetailed("certificate", check)
+	}
+	h.Start()
+	http.Handle("/healthz", h)
+
 	// Wrap with permission checks.
 	readyCh := make(chan struct{})
 	ready = readyCh
@@ -61,3 +86,56 @@ func Main() (ready chan<- struct{}) {
 
 	return ready
 }
+
+// setupStandby wraps store so that it takes part in a hot-standby pair,
+// as the primary if -standby-addr is set or as the standby if
+// -primary-addr is. See package upspin.io/store/standby.
+func setupStandby(cfg upspin.Config, store upspin.StoreServer) upspin.StoreServer {
+	if *standbyAddr != "" && *primaryAddr != "" {
+		log.Fatal("-standby-addr and -primary-addr are mutually exclusive")
+	}
+	if *standbyRegistry == "" {
+		log.Fatal("-standby-addr and -primary-addr require -standby-registry")
+	}
+	reg := standby.NewFileRegistry(*standbyRegistry)
+	self := upspin.Endpoint{
+		Transport: upspin.Remote,
+		NetAddr:   upspin.NetAddr(flags.NetAddr),
+	}
+
+	if *primaryAddr != "" {
+		m := &standby.Monitor{
+			Primary:       upspin.NetAddr(*primaryAddr),
+			FailoverAfter: *failoverAfter,
+		}
+		go func() {
+			if !m.Run(nil) {
+				return
+			}
+			if err := reg.SetPrimary(self); err != nil {
+				log.Error.Printf("store/standby: taking over from %s: %v", *primaryAddr, err)
+				return
+			}
+			log.Printf("Primary %s unreachable for %v; now serving as the primary.", *primaryAddr, *failoverAfter)
+		}()
+		return standby.New(store, self, reg, nil)
+	}
+
+	e, err := upspin.ParseEndpoint(*standbyAddr)
+	if err != nil {
+		log.Fatalf("bad -standby-addr: %v", err)
+	}
+	// Claim the primary role if nobody has.
+	if p, err := reg.Primary(); err != nil {
+		log.Fatal(err)
+	} else if p.Transport == upspin.Unassigned {
+		if err := reg.SetPrimary(self); err != nil {
+			log.Fatal(err)
+		}
+	} else if p != self {
+		log.Error.Printf("Warning: %s is the primary; not mirroring to %s", p, e)
+	}
+	return standby.New(store, self, reg, func() (upspin.StoreServer, error) {
+		return bind.StoreServer(cfg, *e)
+	})
+}
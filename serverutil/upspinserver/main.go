@@ -20,15 +20,21 @@ import (
 	"time"
 
 	"upspin.io/client"
+	"upspin.io/cloud/https"
 	"upspin.io/config"
 	dirServer "upspin.io/dir/server"
+	"upspin.io/dir/server/serverlog"
 	"upspin.io/errors"
 	"upspin.io/factotum"
 	"upspin.io/flags"
 	"upspin.io/log"
+	"upspin.io/metric"
+	"upspin.io/rpc"
 	"upspin.io/rpc/dirserver"
 	"upspin.io/rpc/storeserver"
+	"upspin.io/serverutil"
 	"upspin.io/serverutil/perm"
+	"upspin.io/serverutil/share"
 	"upspin.io/serverutil/web"
 	storeServer "upspin.io/store/server"
 	"upspin.io/subcmd"
@@ -45,9 +51,13 @@ import (
 )
 
 var (
-	cfgPath   = flag.String("serverconfig", defaultCfgPath(), "server configuration `directory`")
-	enableWeb = flag.Bool("web", false, "enable Upspin web interface")
-	readyCh   = make(chan struct{})
+	cfgPath     = flag.String("serverconfig", defaultCfgPath(), "server configuration `directory`")
+	enableWeb   = flag.Bool("web", false, "enable Upspin web interface, and WebDAV for the server owner at /dav/")
+	enableShare = flag.Bool("share", false, "serve the server user's files to holders of URLs made by 'upspin link -web'")
+	verifyLogs  = flag.Bool("verifylogs", false, "verify the directory server logs and exit; safe while a server is running")
+	accessHist  = flag.Bool("access-history", false, "keep a history of the requests made to the directory server, queried by AccessHistory")
+	restart     = flag.Bool("restart-on-dependency-failure", false, "exit, so a supervisor can restart the server, when the key server or storage stays unreachable")
+	readyCh     = make(chan struct{})
 )
 
 func defaultCfgPath() string {
@@ -61,6 +71,10 @@ func defaultCfgPath() string {
 func Main() (ready chan struct{}) {
 	flags.Parse(flags.Server)
 
+	if *verifyLogs {
+		os.Exit(verify(logDir()))
+	}
+
 	if git := version.GitSHA; git != "" {
 		log.Info.Printf("upspinserver built on %s at commit %s",
 			version.BuildTime.In(time.UTC).Format(time.Stamp+" UTC"),
@@ -79,6 +93,29 @@ func Main() (ready chan struct{}) {
 	return readyCh
 }
 
+// logDir returns the directory holding the directory server logs.
+func logDir() string {
+	return filepath.Join(*cfgPath, "dirserver-logs")
+}
+
+// verify checks the directory server logs in dir, printing a report for
+// each user, and returns the exit status: 1 if any logs are corrupt.
+func verify(dir string) int {
+	reports, err := serverlog.Verify(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	status := 0
+	for _, r := range reports {
+		fmt.Println(r)
+		if !r.OK() {
+			status = 1
+		}
+	}
+	return status
+}
+
 var noConfig = errors.Str("no configuration")
 
 type initMode int
@@ -132,16 +169,25 @@ func initServer(mode initMode) (*subcmd.ServerConfig, upspin.Config, *perm.Perm,
 	}
 
 	// Set up DirServer.
-	logDir := filepath.Join(*cfgPath, "dirserver-logs")
+	logDir := logDir()
 	if err := os.MkdirAll(logDir, 0700); err != nil {
 		return nil, nil, nil, err
 	}
 	dirServerConfig := append([]string{"logDir=" + logDir}, storeServerConfig...)
+	if *accessHist {
+		dirServerConfig = append(dirServerConfig, "accessHistory=true")
+	}
 	dir, err := dirServer.New(dirCfg, dirServerConfig...)
 	if err != nil {
 		return nil, nil, nil, err
 	}
 
+	// Set public-facing network address (used by Let's Encrypt).
+	flags.NetAddr = string(serverConfig.Addr)
+
+	// Watch the services the servers depend on.
+	startHealthChecks(cfg, store, logDir)
+
 	// Wrap store and dir with permission checking.
 	perm := perm.NewWithDir(dirCfg, readyCh, serverConfig.User, dir)
 	store = perm.WrapStore(store)
@@ -152,9 +198,17 @@ func initServer(mode initMode) (*subcmd.ServerConfig, upspin.Config, *perm.Perm,
 	httpDir := dirserver.New(dirCfg, dir, serverConfig.Addr)
 	http.Handle("/api/Store/", httpStore)
 	http.Handle("/api/Dir/", httpDir)
+	if *enableShare {
+		http.Handle(share.Prefix, share.New(cfg))
+	}
 
-	// Set public-facing network address (used by Let's Encrypt).
-	flags.NetAddr = string(serverConfig.Addr)
+	// Serve operation metrics to the server's owner only.
+	http.Handle("/debug/metrics", rpc.NewOwnerHandler(cfg, serverConfig.User, metric.OpsHandler()))
+
+	// Serve the name space over WebDAV, also to the owner only.
+	if *enableWeb {
+		http.Handle("/dav/", rpc.NewOwnerHandler(cfg, serverConfig.User, web.NewDAV(cfg, "/dav")))
+	}
 
 	log.Println("Store and Directory servers initialized.")
 	log.Printf("Store server configuration: %s", fmtStoreConfig(storeServerConfig))
@@ -170,6 +224,33 @@ func initServer(mode initMode) (*subcmd.ServerConfig, upspin.Config, *perm.Perm,
 	return serverConfig, cfg, perm, nil
 }
 
+// startHealthChecks periodically checks that the key server and the
+// storage behind store can be reached and that the directory server can
+// write its logs, and reports the results, along with the time left
+// before the TLS certificate expires, at /healthz. If the
+// -restart-on-dependency-failure flag is set, the process exits once
+// the key server or storage has been down for several checks, so that
+// whatever supervises it can start it afresh with new connections.
+func startHealthChecks(cfg upspin.Config, store upspin.StoreServer, logDir string) {
+	h := serverutil.NewHealth()
+	h.Add("keyserver", serverutil.KeyServerCheck(cfg))
+	h.Add("storage", serverutil.StoreCheck(store))
+	h.Add("logdir", serverutil.WritableCheck(logDir))
+	if check := https.CertificateCheck(https.OptionsFromFlags()); check != nil {
+		h.AddDetailed("certificate", check)
+	}
+	if *restart {
+		h.Down = func(name string, err error) {
+			if name != "keyserver" && name != "storage" {
+				return
+			}
+			log.Fatalf("upspinserver: exiting because %s is unreachable: %v", name, err)
+		}
+	}
+	h.Start()
+	http.Handle("/healthz", h)
+}
+
 // fmtStoreConfig formats a ServerConfig.StoreConfig value as a string,
 // omitting any fields that may include sensitive information.
 func fmtStoreConfig(cfg []string) string {
@@ -0,0 +1,459 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package web
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/webdav"
+
+	"upspin.io/client"
+	"upspin.io/client/clientutil"
+	"upspin.io/errors"
+	"upspin.io/log"
+	"upspin.io/path"
+	"upspin.io/upspin"
+)
+
+// NewDAV returns an http.Handler that serves the Upspin name space over
+// WebDAV, acting as the user of the given configuration. The handler
+// serves URLs beginning with prefix; for example, with prefix "/dav", a
+// request for "/dav/user@example.com/file" refers to the Upspin path
+// "user@example.com/file".
+//
+// Requests refused by Access files receive status 403 Forbidden, and GET
+// and HEAD requests for links are redirected to the links' targets.
+// Locking is not supported: LOCK and UNLOCK requests receive status 501
+// Not Implemented and only compliance class 1 is advertised.
+//
+// The handler does no authentication of its own, so it should be made
+// available only to the user of the configuration.
+func NewDAV(cfg upspin.Config, prefix string) http.Handler {
+	prefix = strings.TrimSuffix(prefix, "/")
+	cli := client.New(cfg)
+	return &dav{
+		cli:    cli,
+		prefix: prefix,
+		h: &webdav.Handler{
+			Prefix:     prefix,
+			FileSystem: &davFS{cli: cli},
+			// The handler takes temporary locks itself around
+			// each change, so it needs a LockSystem even though
+			// clients cannot take locks.
+			LockSystem: webdav.NewMemLS(),
+			Logger: func(r *http.Request, err error) {
+				if err != nil {
+					log.Debug.Printf("serverutil/web: dav %s %s: %v", r.Method, r.URL.Path, err)
+				}
+			},
+		},
+	}
+}
+
+type dav struct {
+	cli    upspin.Client
+	prefix string
+	h      *webdav.Handler
+}
+
+// davRequestKey is the context key for the *davRequest of a request.
+type davRequestKey struct{}
+
+// davRequest records, for one request, whether the Upspin servers refused
+// access to any item it touched.
+type davRequest struct {
+	denied bool
+}
+
+func (d *dav) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "LOCK", "UNLOCK":
+		code := http.StatusNotImplemented
+		http.Error(w, http.StatusText(code), code)
+		return
+	case "OPTIONS":
+		// Override the webdav package's advertisement of class 2,
+		// which requires locking. A successful OPTIONS writes
+		// nothing, leaving the headers to be sent on return.
+		ow := &davOptionsWriter{ResponseWriter: w}
+		defer ow.fix()
+		w = ow
+	case "GET", "HEAD":
+		if d.redirectLink(w, r) {
+			return
+		}
+	}
+	req := &davRequest{}
+	r = r.WithContext(context.WithValue(r.Context(), davRequestKey{}, req))
+	d.h.ServeHTTP(&davStatusWriter{ResponseWriter: w, req: req}, r)
+}
+
+// redirectLink redirects the request to the target of the link it names,
+// if it names one, and reports whether it did so.
+func (d *dav) redirectLink(w http.ResponseWriter, r *http.Request) bool {
+	if !strings.HasPrefix(r.URL.Path, d.prefix+"/") {
+		return false
+	}
+	name, err := davName(strings.TrimPrefix(r.URL.Path, d.prefix))
+	if err != nil || name == "" {
+		return false
+	}
+	entry, err := d.cli.Lookup(name, false)
+	if err != nil || !entry.IsLink() {
+		return false
+	}
+	http.Redirect(w, r, d.prefix+"/"+string(entry.Link), http.StatusFound)
+	return true
+}
+
+// davStatusWriter replaces the status of failed requests with 403
+// Forbidden if access to an Upspin item was refused.
+type davStatusWriter struct {
+	http.ResponseWriter
+	req *davRequest
+}
+
+func (w *davStatusWriter) WriteHeader(code int) {
+	if code >= 400 && w.req.denied {
+		code = http.StatusForbidden
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// davOptionsWriter advertises WebDAV compliance class 1 only.
+type davOptionsWriter struct {
+	http.ResponseWriter
+}
+
+func (w *davOptionsWriter) WriteHeader(code int) {
+	w.fix()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *davOptionsWriter) Write(b []byte) (int, error) {
+	w.fix()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *davOptionsWriter) fix() {
+	h := w.Header()
+	if h.Get("DAV") != "" {
+		h.Set("DAV", "1")
+	}
+	if allow := h.Get("Allow"); allow != "" {
+		var methods []string
+		for _, m := range strings.Split(allow, ", ") {
+			if m != "LOCK" && m != "UNLOCK" {
+				methods = append(methods, m)
+			}
+		}
+		h.Set("Allow", strings.Join(methods, ", "))
+	}
+}
+
+// davName converts the slash-separated name used by the webdav package
+// into an Upspin path name. The root, which holds no Upspin items, is
+// returned as the empty name.
+func davName(name string) (upspin.PathName, error) {
+	name = strings.Trim(name, "/")
+	if name == "" {
+		return "", nil
+	}
+	p, err := path.Parse(upspin.PathName(name))
+	if err != nil {
+		return "", err
+	}
+	return p.Path(), nil
+}
+
+// davFS implements webdav.FileSystem using an upspin.Client.
+type davFS struct {
+	cli upspin.Client
+}
+
+var _ webdav.FileSystem = (*davFS)(nil)
+
+// fsError converts an Upspin error into the form the webdav package
+// understands, recording refused access in the request.
+func fsError(ctx context.Context, op, name string, err error) error {
+	if err == nil {
+		return nil
+	}
+	e := err
+	switch {
+	case errors.Is(errors.NotExist, err), errors.Is(errors.BrokenLink, err):
+		e = os.ErrNotExist
+	case errors.Is(errors.Exist, err):
+		e = os.ErrExist
+	case errors.Is(errors.Permission, err), errors.Is(errors.Private, err):
+		e = os.ErrPermission
+		if req, ok := ctx.Value(davRequestKey{}).(*davRequest); ok {
+			req.denied = true
+		}
+	}
+	return &os.PathError{Op: op, Path: name, Err: e}
+}
+
+// Mkdir implements webdav.FileSystem.
+func (fs *davFS) Mkdir(ctx context.Context, name string, perm os.FileMode) error {
+	p, err := davName(name)
+	if err != nil {
+		return fsError(ctx, "mkdir", name, err)
+	}
+	if p == "" {
+		return &os.PathError{Op: "mkdir", Path: name, Err: os.ErrExist}
+	}
+	_, err = fs.cli.MakeDirectory(p)
+	return fsError(ctx, "mkdir", name, err)
+}
+
+// OpenFile implements webdav.FileSystem.
+func (fs *davFS) OpenFile(ctx context.Context, name string, flag int, perm os.FileMode) (webdav.File, error) {
+	p, err := davName(name)
+	if err != nil {
+		return nil, fsError(ctx, "open", name, err)
+	}
+	if flag&(os.O_WRONLY|os.O_RDWR) != 0 {
+		if p == "" {
+			return nil, &os.PathError{Op: "open", Path: name, Err: os.ErrPermission}
+		}
+		if flag&os.O_CREATE == 0 {
+			if _, err := fs.cli.Lookup(p, true); err != nil {
+				return nil, fsError(ctx, "open", name, err)
+			}
+		}
+		return &davFile{fs: fs, ctx: ctx, name: p, writing: true, info: &davFileInfo{entry: &upspin.DirEntry{Name: p}}}, nil
+	}
+	if p == "" {
+		return &davFile{fs: fs, ctx: ctx, info: davRootInfo}, nil
+	}
+	entry, err := fs.cli.Lookup(p, true)
+	if err != nil {
+		return nil, fsError(ctx, "open", name, err)
+	}
+	f := &davFile{fs: fs, ctx: ctx, name: entry.Name, info: &davFileInfo{entry: entry}}
+	if !entry.IsDir() {
+		f.r, err = fs.cli.Open(entry.Name)
+		if err != nil {
+			return nil, fsError(ctx, "open", name, err)
+		}
+	}
+	return f, nil
+}
+
+// RemoveAll implements webdav.FileSystem.
+func (fs *davFS) RemoveAll(ctx context.Context, name string) error {
+	p, err := davName(name)
+	if err != nil {
+		return fsError(ctx, "remove", name, err)
+	}
+	if p == "" {
+		return &os.PathError{Op: "remove", Path: name, Err: os.ErrPermission}
+	}
+	return fsError(ctx, "remove", name, fs.removeAll(p))
+}
+
+func (fs *davFS) removeAll(name upspin.PathName) error {
+	entry, err := fs.cli.Lookup(name, false)
+	if err != nil {
+		return err
+	}
+	if entry.IsDir() {
+		entries, err := fs.cli.Glob(upspin.AllFilesGlob(name))
+		if err != nil {
+			return err
+		}
+		for _, e := range entries {
+			if err := fs.removeAll(e.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return fs.cli.Delete(name)
+}
+
+// Rename implements webdav.FileSystem.
+func (fs *davFS) Rename(ctx context.Context, oldName, newName string) error {
+	oldP, err := davName(oldName)
+	if err != nil {
+		return fsError(ctx, "rename", oldName, err)
+	}
+	newP, err := davName(newName)
+	if err != nil {
+		return fsError(ctx, "rename", newName, err)
+	}
+	if oldP == "" || newP == "" {
+		return &os.PathError{Op: "rename", Path: oldName, Err: os.ErrPermission}
+	}
+	entry, err := fs.cli.Lookup(oldP, false)
+	if err != nil {
+		return fsError(ctx, "rename", oldName, err)
+	}
+	if entry.IsDir() {
+		dr, ok := fs.cli.(clientutil.DirRenamer)
+		if !ok {
+			return &os.PathError{Op: "rename", Path: oldName, Err: upspin.ErrNotSupported}
+		}
+		_, err = dr.RenameDir(oldP, newP)
+	} else {
+		_, err = fs.cli.Rename(oldP, newP)
+	}
+	return fsError(ctx, "rename", oldName, err)
+}
+
+// Stat implements webdav.FileSystem.
+func (fs *davFS) Stat(ctx context.Context, name string) (os.FileInfo, error) {
+	p, err := davName(name)
+	if err != nil {
+		return nil, fsError(ctx, "stat", name, err)
+	}
+	if p == "" {
+		return davRootInfo, nil
+	}
+	entry, err := fs.cli.Lookup(p, true)
+	if err != nil {
+		return nil, fsError(ctx, "stat", name, err)
+	}
+	return &davFileInfo{entry: entry}, nil
+}
+
+// davFile implements webdav.File. A file opened for reading reads from
+// the Upspin file; one opened for writing collects the data and puts it
+// when closed.
+type davFile struct {
+	fs   *davFS
+	ctx  context.Context
+	name upspin.PathName
+	info *davFileInfo
+
+	r       upspin.File // Set when reading a file.
+	writing bool
+	buf     bytes.Buffer  // The data written.
+	listed  bool          // Whether the directory has been read.
+	dir     []os.FileInfo // Entries not yet returned by Readdir.
+}
+
+var _ webdav.File = (*davFile)(nil)
+
+func (f *davFile) Close() error {
+	if f.r != nil {
+		return f.r.Close()
+	}
+	if !f.writing {
+		return nil
+	}
+	_, err := f.fs.cli.Put(f.name, f.buf.Bytes())
+	return fsError(f.ctx, "close", string(f.name), err)
+}
+
+func (f *davFile) Read(b []byte) (int, error) {
+	if f.r == nil {
+		return 0, &os.PathError{Op: "read", Path: string(f.name), Err: os.ErrInvalid}
+	}
+	return f.r.Read(b)
+}
+
+func (f *davFile) Seek(offset int64, whence int) (int64, error) {
+	if f.r == nil {
+		return 0, &os.PathError{Op: "seek", Path: string(f.name), Err: os.ErrInvalid}
+	}
+	return f.r.Seek(offset, whence)
+}
+
+func (f *davFile) Write(b []byte) (int, error) {
+	if !f.writing {
+		return 0, &os.PathError{Op: "write", Path: string(f.name), Err: os.ErrInvalid}
+	}
+	return f.buf.Write(b)
+}
+
+func (f *davFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.info.IsDir() {
+		return nil, &os.PathError{Op: "readdir", Path: string(f.name), Err: errors.Str("not a directory")}
+	}
+	if !f.listed && f.name != "" {
+		entries, err := f.fs.cli.Glob(upspin.AllFilesGlob(f.name))
+		if err != nil {
+			return nil, fsError(f.ctx, "readdir", string(f.name), err)
+		}
+		for _, e := range entries {
+			f.dir = append(f.dir, &davFileInfo{entry: e})
+		}
+	}
+	f.listed = true
+	if count <= 0 {
+		infos := f.dir
+		f.dir = nil
+		return infos, nil
+	}
+	if len(f.dir) == 0 {
+		return nil, io.EOF
+	}
+	if count > len(f.dir) {
+		count = len(f.dir)
+	}
+	infos := f.dir[:count]
+	f.dir = f.dir[count:]
+	return infos, nil
+}
+
+func (f *davFile) Stat() (os.FileInfo, error) {
+	if f.writing {
+		return &davFileInfo{entry: &upspin.DirEntry{Name: f.name, Time: upspin.Now()}, size: int64(f.buf.Len())}, nil
+	}
+	return f.info, nil
+}
+
+// davFileInfo implements os.FileInfo for a DirEntry.
+type davFileInfo struct {
+	entry *upspin.DirEntry
+	size  int64 // Used if the entry has no blocks.
+}
+
+// davRootInfo describes the root of the served name space.
+var davRootInfo = &davFileInfo{entry: &upspin.DirEntry{Attr: upspin.AttrDirectory}}
+
+func (fi *davFileInfo) Name() string {
+	if fi.entry.Name == "" {
+		return "/"
+	}
+	p, err := path.Parse(fi.entry.Name)
+	if err != nil || p.NElem() == 0 {
+		return string(fi.entry.Name)
+	}
+	return p.Elem(p.NElem() - 1)
+}
+
+func (fi *davFileInfo) Size() int64 {
+	if size, err := fi.entry.Size(); err == nil && size > 0 {
+		return size
+	}
+	return fi.size
+}
+
+func (fi *davFileInfo) Mode() os.FileMode {
+	if fi.entry.IsDir() {
+		return os.ModeDir | 0700
+	}
+	return 0600
+}
+
+func (fi *davFileInfo) ModTime() time.Time {
+	return fi.entry.Time.Go()
+}
+
+func (fi *davFileInfo) IsDir() bool {
+	return fi.entry.IsDir()
+}
+
+func (fi *davFileInfo) Sys() interface{} {
+	return fi.entry
+}
@@ -0,0 +1,152 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package web
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"upspin.io/test/testenv"
+	"upspin.io/upspin"
+)
+
+const (
+	owner = "aly@example.com" // aly has keys in key/testdata/aly
+	other = "bob@uncle.com"   // bob has keys in key/testdata/bob
+)
+
+type davTest struct {
+	t   *testing.T
+	srv *httptest.Server
+}
+
+// do makes a request with the given method, path below /dav and body,
+// and returns the response status and body. Redirects are not followed.
+func (d *davTest) do(method, path, body string, hdr ...string) (int, string, http.Header) {
+	d.t.Helper()
+	req, err := http.NewRequest(method, d.srv.URL+"/dav/"+path, strings.NewReader(body))
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	for i := 0; i+1 < len(hdr); i += 2 {
+		req.Header.Set(hdr[i], hdr[i+1])
+	}
+	c := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := c.Do(req)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		d.t.Fatal(err)
+	}
+	return resp.StatusCode, string(b), resp.Header
+}
+
+// expect makes a request and checks its status.
+func (d *davTest) expect(want int, method, path, body string, hdr ...string) string {
+	d.t.Helper()
+	code, got, _ := d.do(method, path, body, hdr...)
+	if code != want {
+		d.t.Errorf("%s %s: status %d, want %d; body:\n%s", method, path, code, want, got)
+	}
+	return got
+}
+
+func TestDAV(t *testing.T) {
+	ownerEnv, err := testenv.New(&testenv.Setup{
+		OwnerName: owner,
+		Packing:   upspin.EEPack,
+		Kind:      "inprocess",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ownerEnv.Exit()
+	otherEnv, err := testenv.New(&testenv.Setup{
+		OwnerName: other,
+		Packing:   upspin.EEPack,
+		Kind:      "inprocess",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer otherEnv.Exit()
+
+	r := testenv.NewRunner()
+	r.AddUser(ownerEnv.Config)
+	r.AddUser(otherEnv.Config)
+	r.As(other)
+	r.Put(other+"/private", "private data") // No Access file: owner only.
+	r.As(owner)
+	r.MakeDirectory(owner + "/target")
+	r.Put(owner+"/target/file", "target data")
+	r.PutLink(owner+"/target/file", owner+"/link")
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/dav/", NewDAV(ownerEnv.Config, "/dav"))
+	d := &davTest{t: t, srv: httptest.NewServer(mux)}
+	defer d.srv.Close()
+
+	// Locking is not offered.
+	_, _, hdr := d.do("OPTIONS", owner+"/", "")
+	if got := hdr.Get("DAV"); got != "1" {
+		t.Errorf("OPTIONS: DAV header %q, want %q", got, "1")
+	}
+	if allow := hdr.Get("Allow"); strings.Contains(allow, "LOCK") || !strings.Contains(allow, "PROPFIND") {
+		t.Errorf("OPTIONS: Allow header %q", allow)
+	}
+	d.expect(http.StatusNotImplemented, "LOCK", owner+"/target/file", "")
+
+	// Make a collection and put a file in it.
+	d.expect(http.StatusCreated, "MKCOL", owner+"/dir", "")
+	d.expect(http.StatusMethodNotAllowed, "MKCOL", owner+"/dir", "")
+	d.expect(http.StatusConflict, "MKCOL", owner+"/nodir/dir", "")
+	d.expect(http.StatusCreated, "PUT", owner+"/dir/hello.txt", "hello, world\n")
+	if got := d.expect(http.StatusOK, "GET", owner+"/dir/hello.txt", ""); got != "hello, world\n" {
+		t.Errorf("GET: got %q", got)
+	}
+
+	// List it.
+	got := d.expect(http.StatusMultiStatus, "PROPFIND", owner+"/dir", "", "Depth", "1")
+	for _, want := range []string{"/dav/" + owner + "/dir/", "/dav/" + owner + "/dir/hello.txt", "<D:getcontentlength>13</D:getcontentlength>"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("PROPFIND: response does not contain %q:\n%s", want, got)
+		}
+	}
+
+	// Move the file.
+	d.expect(http.StatusCreated, "MOVE", owner+"/dir/hello.txt", "", "Destination", d.srv.URL+"/dav/"+owner+"/dir/moved.txt")
+	d.expect(http.StatusNotFound, "GET", owner+"/dir/hello.txt", "")
+	if got := d.expect(http.StatusOK, "GET", owner+"/dir/moved.txt", ""); got != "hello, world\n" {
+		t.Errorf("GET after MOVE: got %q", got)
+	}
+
+	// Delete the collection and its contents.
+	d.expect(http.StatusNoContent, "DELETE", owner+"/dir", "")
+	d.expect(http.StatusNotFound, "PROPFIND", owner+"/dir", "", "Depth", "0")
+
+	// Links are redirected to their targets.
+	_, _, hdr = d.do("GET", owner+"/link", "")
+	if got, want := hdr.Get("Location"), "/dav/"+owner+"/target/file"; got != want {
+		t.Errorf("GET of link: Location %q, want %q", got, want)
+	}
+
+	// Access denied by Access files is reported as Forbidden.
+	d.expect(http.StatusForbidden, "GET", other+"/private", "")
+	d.expect(http.StatusForbidden, "PUT", other+"/new", "data")
+	d.expect(http.StatusForbidden, "DELETE", other+"/private", "")
+}
@@ -0,0 +1,125 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package https implements a read-only store server that fetches
+// references by plain HTTPS requests, such as from a content delivery
+// network that serves a copy of a StoreServer's data. The Endpoint's
+// NetAddr is the base URL; the data for a reference is fetched from the
+// base URL followed by a slash and the reference.
+//
+// The server at the base URL need not be trusted: the references must be
+// SHA-256 hashes of their data, as made by upspin.io/store/server, and
+// each response is verified against its reference.
+package https // import "upspin.io/store/https"
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"upspin.io/bind"
+	"upspin.io/errors"
+	"upspin.io/key/sha256key"
+	"upspin.io/upspin"
+	"upspin.io/valid"
+)
+
+// server implements upspin.StoreServer.
+type server struct {
+	endpoint upspin.Endpoint
+	base     string // Base URL, with a trailing slash.
+}
+
+var _ upspin.StoreServer = (*server)(nil)
+
+// httpClient is used for all requests. Tests replace it.
+var httpClient = &http.Client{Timeout: time.Minute}
+
+var errMismatch = errors.Str("data does not match reference")
+
+// Get implements upspin.StoreServer.
+func (s *server) Get(ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Location, error) {
+	const op errors.Op = "store/https.Get"
+	hash, err := sha256key.Parse(string(ref))
+	if err != nil {
+		// In particular, no metadata is available.
+		return nil, nil, nil, errors.E(op, errors.NotExist, errors.Errorf("reference %q is not a SHA-256 hash", ref))
+	}
+	u := s.base + url.PathEscape(string(ref))
+	resp, err := httpClient.Get(u)
+	if err != nil {
+		return nil, nil, nil, errors.E(op, errors.IO, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		err := errors.Errorf("fetching %s: %s", u, resp.Status)
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil, nil, errors.E(op, errors.NotExist, err)
+		}
+		return nil, nil, nil, errors.E(op, errors.IO, err)
+	}
+	// Read one byte more than a block may hold, to detect overlong responses.
+	data, err := io.ReadAll(io.LimitReader(resp.Body, upspin.MaxBlockSize+1))
+	if err != nil {
+		return nil, nil, nil, errors.E(op, errors.IO, err)
+	}
+	if len(data) > upspin.MaxBlockSize {
+		return nil, nil, nil, errors.E(op, errors.IO, errors.Errorf("fetching %s: response too long", u))
+	}
+	if sha256key.Of(data) != hash {
+		return nil, nil, nil, errors.E(op, errors.IO, errMismatch)
+	}
+	refdata := &upspin.Refdata{
+		Reference: ref,
+		Volatile:  false,
+		Duration:  0,
+	}
+	return data, refdata, nil, nil
+}
+
+// Put implements upspin.StoreServer. The store is read-only.
+func (s *server) Put(data []byte) (*upspin.Refdata, error) {
+	return nil, upspin.ErrNotSupported
+}
+
+// Delete implements upspin.StoreServer. The store is read-only.
+func (s *server) Delete(ref upspin.Reference) error {
+	return upspin.ErrNotSupported
+}
+
+// Endpoint implements upspin.Service.
+func (s *server) Endpoint() upspin.Endpoint {
+	return s.endpoint
+}
+
+// Close implements upspin.Service.
+func (s *server) Close() {
+}
+
+// Dial implements upspin.Service.
+func (s *server) Dial(config upspin.Config, e upspin.Endpoint) (upspin.Service, error) {
+	const op errors.Op = "store/https.Dial"
+	if e.Transport != upspin.HTTPS {
+		return nil, errors.E(op, errors.Invalid, "unrecognized transport")
+	}
+	if err := valid.Endpoint(e); err != nil {
+		return nil, errors.E(op, err)
+	}
+	base := string(e.NetAddr)
+	if !strings.HasSuffix(base, "/") {
+		base += "/"
+	}
+	return &server{
+		endpoint: e,
+		base:     base,
+	}, nil
+}
+
+const transport = upspin.HTTPS
+
+func init() {
+	bind.RegisterStoreServer(transport, &server{})
+}
@@ -0,0 +1,99 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package https
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"upspin.io/bind"
+	"upspin.io/config"
+	"upspin.io/errors"
+	"upspin.io/key/sha256key"
+	"upspin.io/upspin"
+)
+
+// newFileServer returns a store dialed to a TLS file server that serves
+// the files written into dir under the path /cdn/.
+func newFileServer(t *testing.T) (store upspin.StoreServer, dir string) {
+	dir = t.TempDir()
+	ts := httptest.NewTLSServer(http.StripPrefix("/cdn/", http.FileServer(http.Dir(dir))))
+	t.Cleanup(ts.Close)
+	old := httpClient
+	httpClient = ts.Client()
+	t.Cleanup(func() { httpClient = old })
+
+	e := upspin.Endpoint{Transport: upspin.HTTPS, NetAddr: upspin.NetAddr(ts.URL + "/cdn")}
+	store, err := bind.StoreServer(config.New(), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, dir
+}
+
+func writeFile(t *testing.T, dir string, ref upspin.Reference, data []byte) {
+	if err := os.WriteFile(filepath.Join(dir, string(ref)), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGet(t *testing.T) {
+	store, dir := newFileServer(t)
+	data := []byte("some public data")
+	ref := upspin.Reference(sha256key.Of(data).String())
+	writeFile(t, dir, ref, data)
+
+	got, refdata, locs, err := store.Get(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != string(data) || refdata.Reference != ref || locs != nil {
+		t.Errorf("Get = %q, %v, %v; want %q, %q, nil", got, refdata, locs, data, ref)
+	}
+}
+
+func TestGetMismatch(t *testing.T) {
+	store, dir := newFileServer(t)
+	ref := upspin.Reference(sha256key.Of([]byte("the real data")).String())
+	writeFile(t, dir, ref, []byte("tampered data"))
+
+	_, _, _, err := store.Get(ref)
+	if !errors.Match(errors.E(errors.IO, errMismatch), err) {
+		t.Errorf("Get of tampered data: err = %v, want IO error %q", err, errMismatch)
+	}
+}
+
+func TestGetErrors(t *testing.T) {
+	store, _ := newFileServer(t)
+	missing := upspin.Reference(sha256key.Of([]byte("missing")).String())
+	for _, ref := range []upspin.Reference{missing, upspin.HTTPBaseMetadata, "../etc/passwd"} {
+		if _, _, _, err := store.Get(ref); !errors.Is(errors.NotExist, err) {
+			t.Errorf("Get(%q): err = %v, want NotExist", ref, err)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	store, _ := newFileServer(t)
+	if _, err := store.Put([]byte("data")); err != upspin.ErrNotSupported {
+		t.Errorf("Put: err = %v, want %v", err, upspin.ErrNotSupported)
+	}
+	ref := upspin.Reference(sha256key.Of([]byte("data")).String())
+	if err := store.Delete(ref); err != upspin.ErrNotSupported {
+		t.Errorf("Delete: err = %v, want %v", err, upspin.ErrNotSupported)
+	}
+}
+
+func TestDialBadBase(t *testing.T) {
+	for _, addr := range []upspin.NetAddr{"http://cdn.example.com", "cdn.example.com"} {
+		e := upspin.Endpoint{Transport: upspin.HTTPS, NetAddr: addr}
+		if _, err := bind.StoreServer(config.New(), e); err == nil {
+			t.Errorf("Dial of %q succeeded", addr)
+		}
+	}
+}
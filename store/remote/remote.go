@@ -7,6 +7,7 @@
 package remote // import "upspin.io/store/remote"
 
 import (
+	"bytes"
 	"fmt"
 	"io"
 	"net/http"
@@ -41,12 +42,36 @@ type remote struct {
 	baseURL string
 }
 
-var _ upspin.StoreServer = (*remote)(nil)
+var (
+	_ upspin.StoreServer       = (*remote)(nil)
+	_ upspin.StoreStreamGetter = (*remote)(nil)
+)
 
 // Get implements upspin.StoreServer.Get.
 func (r *remote) Get(ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Location, error) {
 	op := r.opf("Get", "%q", ref)
 
+	rc, refdata, locs, err := r.getStream(op, ref)
+	if err != nil || rc == nil {
+		return nil, refdata, locs, err
+	}
+	// The data are read into a pooled buffer and copied out, so that
+	// the caller's copy is the only one allocated.
+	data, err := rpc.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		return nil, nil, nil, op.error(errors.IO, err)
+	}
+	return data, refdata, nil, nil
+}
+
+// GetStream implements upspin.StoreStreamGetter.
+func (r *remote) GetStream(ref upspin.Reference) (io.ReadCloser, *upspin.Refdata, []upspin.Location, error) {
+	op := r.opf("GetStream", "%q", ref)
+	return r.getStream(op, ref)
+}
+
+func (r *remote) getStream(op *operation, ref upspin.Reference) (io.ReadCloser, *upspin.Refdata, []upspin.Location, error) {
 	if !strings.HasPrefix(string(ref), "metadata:") {
 		if err := r.probeDirect(); err != nil {
 			op.error(err)
@@ -59,23 +84,19 @@ func (r *remote) Get(ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Lo
 				return nil, nil, nil, op.error(err)
 			}
 			if resp.StatusCode != http.StatusOK {
+				resp.Body.Close()
 				err := errors.Errorf("fetching %s: %s", u, resp.Status)
 				if resp.StatusCode == http.StatusNotFound {
 					err = errors.E(errors.NotExist, err)
 				}
 				return nil, nil, nil, op.error(err)
 			}
-			body, err := io.ReadAll(resp.Body)
-			resp.Body.Close()
-			if err != nil {
-				return nil, nil, nil, op.error(err)
-			}
 			refData := &upspin.Refdata{
 				Reference: ref,
 				Volatile:  false,
 				Duration:  0,
 			}
-			return body, refData, nil, nil
+			return resp.Body, refData, nil, nil
 		}
 	}
 
@@ -83,13 +104,28 @@ func (r *remote) Get(ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Lo
 		Reference: string(ref),
 	}
 	resp := new(proto.StoreGetResponse)
-	if err := r.Invoke("Store/Get", req, resp, nil, nil); err != nil {
+	rc, err := r.InvokeReader("Store/Get", req, resp)
+	if err != nil {
 		return nil, nil, nil, op.error(err)
 	}
 	if len(resp.Error) != 0 {
+		if rc != nil {
+			rc.Close()
+		}
 		return nil, nil, nil, errors.UnmarshalError(resp.Error)
 	}
-	return resp.Data, proto.UpspinRefdata(resp.Refdata), proto.UpspinLocations(resp.Locations), nil
+	locs := proto.UpspinLocations(resp.Locations)
+	if len(locs) > 0 {
+		if rc != nil {
+			rc.Close()
+		}
+		return nil, nil, locs, nil
+	}
+	if rc == nil {
+		// The server does not stream; the data came in the response.
+		rc = io.NopCloser(bytes.NewReader(resp.Data))
+	}
+	return rc, proto.UpspinRefdata(resp.Refdata), nil, nil
 }
 
 // Put implements upspin.StoreServer.Put.
@@ -133,7 +169,7 @@ func dialCache(config upspin.Config, proxyFor upspin.Endpoint) (upspin.Service,
 	}
 
 	// Call the cache. The cache is local so don't bother with TLS.
-	authClient, err := rpc.NewClient(config, ce.NetAddr, rpc.NoSecurity, proxyFor)
+	authClient, err := rpc.NewSharedClient(config, ce.NetAddr, rpc.NoSecurity, proxyFor)
 	if err != nil {
 		return nil, err
 	}
@@ -162,8 +198,9 @@ func (r *remote) Dial(config upspin.Config, e upspin.Endpoint) (upspin.Service,
 		return svc, nil
 	}
 
-	// Call the server directly.
-	authClient, err := rpc.NewClient(config, e.NetAddr, rpc.Secure, upspin.Endpoint{})
+	// Call the server directly. Clients for all users share connections
+	// to the server, so that many blocks may be fetched from it at once.
+	authClient, err := rpc.NewSharedClient(config, e.NetAddr, rpc.Secure, upspin.Endpoint{})
 	if err != nil {
 		return nil, op.error(errors.IO, err)
 	}
@@ -0,0 +1,213 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"crypto/rand"
+	"crypto/tls"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"upspin.io/bind"
+	"upspin.io/config"
+	"upspin.io/factotum"
+	"upspin.io/rpc/storeserver"
+	"upspin.io/test/testutil"
+	"upspin.io/upspin"
+
+	keyserver "upspin.io/key/inprocess"
+	storeinprocess "upspin.io/store/inprocess"
+)
+
+const (
+	benchBlocks    = 64
+	benchBlockSize = 1 << 20 // The default block size of the client.
+	benchFetchers  = 16      // Blocks fetched at once.
+)
+
+// tunings are the settings compared by BenchmarkGetConcurrent.
+var tunings = []struct {
+	name string
+	rpc  string // The config's rpc value.
+}{
+	{"http1", "{idleconns: 10}"}, // The settings before Tuning.
+	{"http1/buffers=64K", "{idleconns: 10, readbuffer: 65536, writebuffer: 65536}"},
+	{"http1/conns=4", "{idleconns: 10, conns: 4}"},
+	{"http1/idleconns=32", "{idleconns: 32}"},
+	{"http2", "{http2: true}"},
+	{"http2/streams=4", "{http2: true, streams: 4}"},
+	{"default", ""},
+}
+
+// BenchmarkGetConcurrent fetches blocks, benchFetchers at a time, from a
+// StoreServer on a local TLS server that speaks HTTP/1.1 and HTTP/2, and
+// reports the throughput for each of a set of tunings of the connections.
+func BenchmarkGetConcurrent(b *testing.B) {
+	cfg, ep, refs := startBenchServer(b)
+	for _, tu := range tunings {
+		b.Run(tu.name, func(b *testing.B) {
+			cfg := cfg
+			if tu.rpc != "" {
+				cfg = config.SetValue(cfg, "rpc", tu.rpc)
+			}
+			svc, err := (&remote{}).Dial(cfg, ep)
+			if err != nil {
+				b.Fatal(err)
+			}
+			store := svc.(upspin.StoreServer)
+			b.SetBytes(benchBlocks * benchBlockSize)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				getAll(b, store, refs)
+			}
+		})
+	}
+}
+
+// BenchmarkGet fetches blocks one at a time and reports, besides the
+// allocations, the time per block the garbage collector stopped the world.
+func BenchmarkGet(b *testing.B) {
+	cfg, ep, refs := startBenchServer(b)
+	store := dialBench(b, cfg, ep)
+	b.SetBytes(benchBlockSize)
+	b.ReportAllocs()
+	defer reportGCPause(b)()
+	for i := 0; i < b.N; i++ {
+		data, _, _, err := store.Get(refs[i%len(refs)])
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(data) != benchBlockSize {
+			b.Fatalf("got %d bytes, want %d", len(data), benchBlockSize)
+		}
+	}
+}
+
+// BenchmarkPut stores blocks one at a time and reports the same as
+// BenchmarkGet.
+func BenchmarkPut(b *testing.B) {
+	cfg, ep, _ := startBenchServer(b)
+	store := dialBench(b, cfg, ep)
+	block := make([]byte, benchBlockSize)
+	rand.Read(block)
+	b.SetBytes(benchBlockSize)
+	b.ReportAllocs()
+	defer reportGCPause(b)()
+	for i := 0; i < b.N; i++ {
+		block[0] = byte(i) // Vary the reference.
+		if _, err := store.Put(block); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func dialBench(b *testing.B, cfg upspin.Config, ep upspin.Endpoint) upspin.StoreServer {
+	svc, err := (&remote{}).Dial(cfg, ep)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return svc.(upspin.StoreServer)
+}
+
+// reportGCPause resets the benchmark timer and returns a function that
+// reports the garbage collector's pauses since, per operation.
+func reportGCPause(b *testing.B) func() {
+	runtime.GC()
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	b.ResetTimer()
+	return func() {
+		b.StopTimer()
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+		b.ReportMetric(float64(after.PauseTotalNs-before.PauseTotalNs)/float64(b.N), "gc-pause-ns/op")
+		b.ReportMetric(float64(after.NumGC-before.NumGC)/float64(b.N), "gcs/op")
+	}
+}
+
+// getAll fetches the blocks, benchFetchers at a time.
+func getAll(b *testing.B, store upspin.StoreServer, refs []upspin.Reference) {
+	var wg sync.WaitGroup
+	next := make(chan upspin.Reference)
+	for i := 0; i < benchFetchers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ref := range next {
+				data, _, _, err := store.Get(ref)
+				if err != nil {
+					b.Error(err)
+					continue
+				}
+				if len(data) != benchBlockSize {
+					b.Errorf("got %d bytes, want %d", len(data), benchBlockSize)
+				}
+			}
+		}()
+	}
+	for _, ref := range refs {
+		next <- ref
+	}
+	close(next)
+	wg.Wait()
+}
+
+// startBenchServer starts a TLS server for a StoreServer holding
+// benchBlocks blocks. It returns a config for a user it accepts, the
+// server's endpoint and the blocks' references.
+func startBenchServer(b *testing.B) (upspin.Config, upspin.Endpoint, []upspin.Reference) {
+	inProcess := upspin.Endpoint{Transport: upspin.InProcess}
+	bind.RegisterKeyServer(upspin.InProcess, keyserver.New())
+
+	f, err := factotum.NewFromDir(testutil.Repo("key", "testdata", "user1"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	cfg := config.SetUserName(config.New(), "bench@example.com")
+	cfg = config.SetFactotum(cfg, f)
+	cfg = config.SetKeyEndpoint(cfg, inProcess)
+	cfg = config.SetValue(cfg, "tlscerts", testutil.Repo("rpc", "testdata"))
+	key, err := bind.KeyServer(cfg, inProcess)
+	if err != nil {
+		b.Fatal(err)
+	}
+	err = key.Put(&upspin.User{
+		Name:      cfg.UserName(),
+		PublicKey: f.PublicKey(),
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	store := storeinprocess.New()
+	var refs []upspin.Reference
+	for i := 0; i < benchBlocks; i++ {
+		block := make([]byte, benchBlockSize)
+		rand.Read(block)
+		refdata, err := store.Put(block)
+		if err != nil {
+			b.Fatal(err)
+		}
+		refs = append(refs, refdata.Reference)
+	}
+
+	cert, err := tls.LoadX509KeyPair(testutil.Repo("rpc", "testdata", "cert.pem"), testutil.Repo("rpc", "testdata", "key.pem"))
+	if err != nil {
+		b.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(storeserver.New(cfg, store, ""))
+	srv.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	b.Cleanup(srv.Close)
+
+	// The certificate is for localhost.
+	addr := strings.Replace(srv.Listener.Addr().String(), "127.0.0.1", "localhost", 1)
+	ep := upspin.Endpoint{Transport: upspin.Remote, NetAddr: upspin.NetAddr(addr)}
+	return cfg, ep, refs
+}
@@ -4,13 +4,32 @@
 
 // Package server implements upspin.StoreServer using storage.Storage as its
 // storage back end.
+//
+// Besides the storage backend's own options, New accepts
+//
+//	backend=<name>
+//
+// which names the storage backend, and
+//
+//	httpsBase=<url>
+//
+// which makes Get redirect requests for data to the store at the given
+// base URL, accessed through the HTTPS transport (see upspin.io/store/https),
+// rather than return the data itself. The base URL, typically that of a
+// content delivery network in front of the storage, must serve the stored
+// data by reference. StoreServers give any reference's data to anyone who
+// asks, relying on packing to protect its contents, so this exposes nothing
+// more than the server does already.
 package server // import "upspin.io/store/server"
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"strings"
 	"sync"
+	"time"
 
 	"upspin.io/cloud/storage"
 	"upspin.io/errors"
@@ -18,29 +37,57 @@ import (
 	"upspin.io/log"
 	"upspin.io/metric"
 	"upspin.io/upspin"
+	"upspin.io/valid"
 )
 
+var logger = log.For("store/server")
+
 // server implements upspin.StoreServer.
 type server struct {
 	storage storage.Storage
 
+	// httpsBase, if set, is the endpoint to which Get redirects
+	// requests for data.
+	httpsBase upspin.Endpoint
+
 	mu       sync.RWMutex // Protects fields below.
 	refCount uint64       // How many clones of us exist.
 	linkBase []byte
 }
 
-var _ upspin.StoreServer = (*server)(nil)
+var (
+	_ upspin.StoreServer       = (*server)(nil)
+	_ upspin.StoreMetaPutter   = (*server)(nil)
+	_ upspin.StoreStreamGetter = (*server)(nil)
+)
+
+// ops records the calls handled by all store servers in this process.
+var ops = metric.NewOps("storeserver")
 
 // New returns a StoreServer that serves the given endpoint with the provided options.
 func New(options ...string) (upspin.StoreServer, error) {
 	const op errors.Op = "store/server.New"
 
 	var backend string
+	var httpsBase upspin.Endpoint
 	var dialOpts []storage.DialOpts
 	for _, option := range options {
-		const prefix = "backend="
-		if strings.HasPrefix(option, prefix) {
-			backend = option[len(prefix):]
+		const (
+			backendPrefix = "backend="
+			httpsPrefix   = "httpsBase="
+		)
+		switch {
+		case strings.HasPrefix(option, backendPrefix):
+			backend = option[len(backendPrefix):]
+			continue
+		case strings.HasPrefix(option, httpsPrefix):
+			httpsBase = upspin.Endpoint{
+				Transport: upspin.HTTPS,
+				NetAddr:   upspin.NetAddr(option[len(httpsPrefix):]),
+			}
+			if err := valid.Endpoint(httpsBase); err != nil {
+				return nil, errors.E(op, errors.Invalid, err)
+			}
 			continue
 		}
 		// Pass other options to the storage backend.
@@ -54,21 +101,42 @@ func New(options ...string) (upspin.StoreServer, error) {
 		return nil, errors.E(op, err)
 	}
 	return &server{
-		storage: s,
+		storage:   s,
+		httpsBase: httpsBase,
 	}, nil
 }
 
 // Put implements upspin.StoreServer.
-func (s *server) Put(data []byte) (*upspin.Refdata, error) {
+func (s *server) Put(data []byte) (_ *upspin.Refdata, err error) {
 	const op errors.Op = "store/server.Put"
+	defer ops.Observe("Put", time.Now(), &err)
 
+	return s.put(op, data, "")
+}
+
+// PutWithMeta implements upspin.StoreMetaPutter. If the storage backend
+// can keep users' data apart, the data is stored as the writer's.
+func (s *server) PutWithMeta(data []byte, writer upspin.UserName) (_ *upspin.Refdata, err error) {
+	const op errors.Op = "store/server.PutWithMeta"
+	defer ops.Observe("PutWithMeta", time.Now(), &err)
+
+	return s.put(op, data, writer)
+}
+
+func (s *server) put(op errors.Op, data []byte, writer upspin.UserName) (*upspin.Refdata, error) {
 	m, sp := metric.NewSpan(op)
 	sp.SetAnnotation(fmt.Sprintf("size=%d", len(data)))
 	defer m.Done()
 	defer sp.End()
 
 	ref := sha256key.Of(data).String()
-	if err := s.storage.Put(ref, data); err != nil {
+	var err error
+	if tp, ok := s.storage.(storage.TenantPutter); ok && writer != "" {
+		err = tp.PutTenant(ref, data, writer)
+	} else {
+		err = s.storage.Put(ref, data)
+	}
+	if err != nil {
 		return nil, errors.E(op, err)
 	}
 
@@ -81,9 +149,45 @@ func (s *server) Put(data []byte) (*upspin.Refdata, error) {
 }
 
 // Get implements upspin.StoreServer.
-func (s *server) Get(ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Location, error) {
+func (s *server) Get(ref upspin.Reference) (_ []byte, _ *upspin.Refdata, _ []upspin.Location, err error) {
 	const op errors.Op = "store/server.Get"
+	defer ops.Observe("Get", time.Now(), &err)
+
+	return s.get(op, ref)
+}
+
+// GetStream implements upspin.StoreStreamGetter. If the storage backend
+// can read data incrementally, the data is streamed from it.
+func (s *server) GetStream(ref upspin.Reference) (_ io.ReadCloser, _ *upspin.Refdata, _ []upspin.Location, err error) {
+	const op errors.Op = "store/server.GetStream"
+	defer ops.Observe("GetStream", time.Now(), &err)
 
+	st, ok := s.storage.(storage.Streamer)
+	if !ok || s.httpsBase.Transport != upspin.Unassigned || strings.HasPrefix(string(ref), "metadata:") {
+		data, refdata, locs, err := s.get(op, ref)
+		if err != nil || data == nil {
+			return nil, refdata, locs, err
+		}
+		return io.NopCloser(bytes.NewReader(data)), refdata, nil, nil
+	}
+
+	m, sp := metric.NewSpan(op)
+	defer m.Done()
+	defer sp.End()
+
+	rc, err := st.DownloadStream(string(ref))
+	if err != nil {
+		return nil, nil, nil, errors.E(op, err)
+	}
+	refdata := &upspin.Refdata{
+		Reference: ref,
+		Volatile:  false,
+		Duration:  0,
+	}
+	return rc, refdata, nil, nil
+}
+
+func (s *server) get(op errors.Op, ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Location, error) {
 	m, sp := metric.NewSpan(op)
 	defer m.Done()
 	defer sp.End()
@@ -133,6 +237,14 @@ func (s *server) Get(ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Lo
 		}
 		return b, refdata, nil, nil
 
+	case s.httpsBase.Transport != upspin.Unassigned:
+		// Metadata is handled above; all else is served from the base URL.
+		loc := upspin.Location{
+			Endpoint:  s.httpsBase,
+			Reference: ref,
+		}
+		return nil, nil, []upspin.Location{loc}, nil
+
 	default:
 		data, err := s.storage.Download(string(ref))
 		if err != nil {
@@ -149,13 +261,14 @@ func (s *server) Get(ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Lo
 }
 
 // Delete implements upspin.StoreServer.
-func (s *server) Delete(ref upspin.Reference) error {
+func (s *server) Delete(ref upspin.Reference) (err error) {
 	const op errors.Op = "store/server.Delete"
+	defer ops.Observe("Delete", time.Now(), &err)
 
 	m, _ := metric.NewSpan(op)
 	defer m.Done()
 
-	err := s.storage.Delete(string(ref))
+	err = s.storage.Delete(string(ref))
 	if err != nil {
 		return errors.E(op, errors.Errorf("%s: %s", ref, err))
 	}
@@ -176,7 +289,7 @@ func (s *server) Close() {
 	defer s.mu.Unlock()
 
 	if s.refCount == 0 {
-		log.Error.Printf("store/server: closing store that was not dialed")
+		logger.Error.Printf("store/server: closing store that was not dialed")
 		return
 	}
 	s.refCount--
@@ -5,12 +5,15 @@
 package server
 
 import (
+	"io"
 	"os"
 	"testing"
 
 	"upspin.io/cloud/storage"
 	"upspin.io/cloud/storage/storagetest"
 	"upspin.io/errors"
+	"upspin.io/metric"
+	"upspin.io/upspin"
 
 	// Import needed storage backend.
 	_ "upspin.io/cloud/storage/disk"
@@ -48,6 +51,55 @@ func TestPutAndGet(t *testing.T) {
 	}
 }
 
+func TestGetStream(t *testing.T) {
+	// A backend that cannot stream is read in full.
+	s := newStoreServer(nil)
+	rc, _, locs, err := s.GetStream(expectedRef)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(locs) != 0 {
+		t.Fatalf("Expected 0 location, got %d", len(locs))
+	}
+	if b, err := io.ReadAll(rc); err != nil || string(b) != contents {
+		t.Errorf("Got data %q, %v; want %q", b, err, contents)
+	}
+	rc.Close()
+
+	// One that can is streamed.
+	dir, err := os.MkdirTemp("", "test-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	ss, err := New("backend=Disk", "basePath="+dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refdata, err := ss.Put([]byte(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sg := ss.(upspin.StoreStreamGetter)
+	rc, got, _, err := sg.GetStream(refdata.Reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := rc.(*os.File); !ok {
+		t.Errorf("GetStream returned a %T, want an *os.File", rc)
+	}
+	if b, err := io.ReadAll(rc); err != nil || string(b) != contents {
+		t.Errorf("Got data %q, %v; want %q", b, err, contents)
+	}
+	rc.Close()
+	if got.Reference != refdata.Reference {
+		t.Errorf("Got reference %q, want %q", got.Reference, refdata.Reference)
+	}
+	if _, _, _, err := sg.GetStream("bla bla bla"); !errors.Is(errors.NotExist, err) {
+		t.Errorf("GetStream of missing ref: got %v, want NotExist", err)
+	}
+}
+
 func TestDelete(t *testing.T) {
 	s := newStoreServer(nil)
 
@@ -76,6 +128,33 @@ func TestGetInvalidRef(t *testing.T) {
 	}
 }
 
+func TestOps(t *testing.T) {
+	s := newStoreServer(nil)
+	putOK := ops.Count("Put", metric.OK)
+	getOK := ops.Count("Get", metric.OK)
+	getNotExist := ops.Count("Get", metric.NotExist)
+
+	if _, err := s.Put([]byte(contents)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := s.Get(expectedRef); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := s.Get("bla bla bla"); err == nil {
+		t.Fatal("Expected error")
+	}
+
+	if got := ops.Count("Put", metric.OK) - putOK; got != 1 {
+		t.Errorf("Put ok count moved by %d, want 1", got)
+	}
+	if got := ops.Count("Get", metric.OK) - getOK; got != 1 {
+		t.Errorf("Get ok count moved by %d, want 1", got)
+	}
+	if got := ops.Count("Get", metric.NotExist) - getNotExist; got != 1 {
+		t.Errorf("Get not-exist count moved by %d, want 1", got)
+	}
+}
+
 func TestNew(t *testing.T) {
 	_, err := New("dance=the macarena")
 	if err == nil {
@@ -133,3 +212,47 @@ func (t *testGCP) Delete(ref string) error {
 	t.deletedRef = ref // Capture the ref
 	return nil
 }
+
+func TestHTTPSBase(t *testing.T) {
+	const base = "https://cdn.example.com/store"
+	ss, err := New("backend=Disk", "basePath="+t.TempDir(), "httpsBase="+base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	refdata, err := ss.Put([]byte(contents))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := upspin.Location{
+		Endpoint:  upspin.Endpoint{Transport: upspin.HTTPS, NetAddr: base},
+		Reference: refdata.Reference,
+	}
+	data, _, locs, err := ss.Get(refdata.Reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data != nil || len(locs) != 1 || locs[0] != want {
+		t.Errorf("Get = %q, %v; want nil, [%v]", data, locs, want)
+	}
+	rc, _, locs, err := ss.(upspin.StoreStreamGetter).GetStream(refdata.Reference)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rc != nil || len(locs) != 1 || locs[0] != want {
+		t.Errorf("GetStream = %v, %v; want nil, [%v]", rc, locs, want)
+	}
+
+	// Metadata is still served directly.
+	data, _, locs, err = ss.Get(upspin.ListRefsMetadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data == nil || locs != nil {
+		t.Errorf("Get of metadata = %q, %v; want data and no locations", data, locs)
+	}
+
+	_, err = New("backend=Disk", "basePath="+t.TempDir(), "httpsBase=http://cdn.example.com")
+	if !errors.Is(errors.Invalid, err) {
+		t.Errorf("New with http base: err = %v, want Invalid", err)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package standby
+
+import (
+	"net"
+	"time"
+
+	"upspin.io/log"
+	"upspin.io/upspin"
+)
+
+const (
+	// FailoverAfter is the default time for which the primary must be
+	// unreachable before the standby takes over.
+	FailoverAfter = 30 * time.Second
+
+	// keepAlive is the period of the TCP keepalives sent to the primary.
+	keepAlive = 5 * time.Second
+
+	// redialInterval is how long the monitor waits between attempts
+	// to connect to the primary.
+	redialInterval = 2 * time.Second
+)
+
+// Monitor watches the primary of a pair from the standby.
+type Monitor struct {
+	// Primary is the address of the primary.
+	Primary upspin.NetAddr
+
+	// FailoverAfter is how long the primary must be unreachable before
+	// Run returns. If zero, the package's FailoverAfter is used.
+	FailoverAfter time.Duration
+
+	// dial, if not nil, replaces net.Dial in tests.
+	dial func(addr string) (net.Conn, error)
+
+	// redial, if not zero, replaces redialInterval in tests.
+	redial time.Duration
+}
+
+// Run keeps a TCP connection open to the primary, with keepalives so that
+// the loss of the primary is noticed even though nothing is sent, and
+// redials when it is lost. It returns true once no connection could be
+// made for FailoverAfter, or false if done is closed first.
+func (m *Monitor) Run(done <-chan struct{}) bool {
+	failAfter := m.FailoverAfter
+	if failAfter == 0 {
+		failAfter = FailoverAfter
+	}
+	redial := m.redial
+	if redial == 0 {
+		redial = redialInterval
+	}
+	dial := m.dial
+	if dial == nil {
+		d := &net.Dialer{Timeout: redial, KeepAlive: keepAlive}
+		dial = func(addr string) (net.Conn, error) {
+			return d.Dial("tcp", addr)
+		}
+	}
+
+	lastSeen := time.Now()
+	for {
+		conn, err := dial(string(m.Primary))
+		if err == nil {
+			lastSeen = time.Now()
+			m.wait(conn, done)
+			lastSeen = time.Now()
+		} else {
+			log.Info.Printf("store/standby: primary %s unreachable: %v", m.Primary, err)
+			if time.Since(lastSeen) >= failAfter {
+				return true
+			}
+		}
+		select {
+		case <-done:
+			return false
+		case <-time.After(redial):
+		}
+	}
+}
+
+// wait returns when conn is broken, or closed by the primary, or done
+// is closed.
+func (m *Monitor) wait(conn net.Conn, done <-chan struct{}) {
+	broken := make(chan struct{})
+	go func() {
+		var buf [1]byte
+		for {
+			if _, err := conn.Read(buf[:]); err != nil {
+				close(broken)
+				return
+			}
+		}
+	}()
+	select {
+	case <-broken:
+	case <-done:
+	}
+	conn.Close()
+}
@@ -0,0 +1,245 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package standby implements hot-standby failover for a pair of store
+// servers.
+//
+// The primary mirrors every Put and Delete to the standby before it
+// replies, so the standby always holds every block the primary does. A
+// Registry, a small piece of metadata shared by the two servers, records
+// which of them is the current primary. The standby monitors the primary
+// with a TCP connection using keepalives, and if it cannot reach the
+// primary for longer than FailoverAfter, it records itself in the
+// Registry as the primary.
+//
+// Directory entries name the endpoint of the store that held the primary
+// role when they were written, so after a failover clients still ask the
+// old primary for blocks until its address is pointed at the new one.
+// To help, a server that is not the primary answers a Get for a block it
+// does not hold with a redirection to the current primary, and it does
+// not mirror what it is given.
+package standby // import "upspin.io/store/standby"
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/log"
+	"upspin.io/upspin"
+)
+
+// A Registry records which store server of a pair is the primary.
+type Registry interface {
+	// Primary returns the endpoint of the current primary, or the
+	// unassigned endpoint if none has been recorded.
+	Primary() (upspin.Endpoint, error)
+
+	// SetPrimary records the endpoint of the current primary.
+	SetPrimary(upspin.Endpoint) error
+}
+
+// NewFileRegistry returns a Registry kept in the named file, which must
+// be readable and writable by both servers, such as on a shared file
+// system.
+func NewFileRegistry(name string) Registry {
+	return fileRegistry(name)
+}
+
+type fileRegistry string
+
+// Primary implements Registry.
+func (r fileRegistry) Primary() (upspin.Endpoint, error) {
+	const op errors.Op = "store/standby.Primary"
+	data, err := os.ReadFile(string(r))
+	if os.IsNotExist(err) {
+		return upspin.Endpoint{}, nil
+	}
+	if err != nil {
+		return upspin.Endpoint{}, errors.E(op, errors.IO, err)
+	}
+	e, err := upspin.ParseEndpoint(strings.TrimSpace(string(data)))
+	if err != nil {
+		return upspin.Endpoint{}, errors.E(op, err)
+	}
+	return *e, nil
+}
+
+// SetPrimary implements Registry.
+func (r fileRegistry) SetPrimary(e upspin.Endpoint) error {
+	const op errors.Op = "store/standby.SetPrimary"
+	// Write a new file and rename it so a reader never sees a partial one.
+	tmp, err := os.CreateTemp(filepath.Dir(string(r)), filepath.Base(string(r))+".tmp")
+	if err != nil {
+		return errors.E(op, errors.IO, err)
+	}
+	_, err = tmp.WriteString(e.String() + "\n")
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), string(r))
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return errors.E(op, errors.IO, err)
+	}
+	return nil
+}
+
+// registryTTL is how long a server relies on what it last read from the
+// Registry.
+const registryTTL = time.Second
+
+// New returns a StoreServer that serves store as the server at endpoint
+// self. While the Registry names self as the primary, Puts and Deletes
+// are applied to the StoreServer returned by standby, too, if standby
+// is not nil.
+func New(store upspin.StoreServer, self upspin.Endpoint, reg Registry, standby func() (upspin.StoreServer, error)) upspin.StoreServer {
+	return &server{
+		StoreServer: store,
+		shared: &shared{
+			self:    self,
+			reg:     reg,
+			standby: standby,
+		},
+	}
+}
+
+// server is a StoreServer taking part in a standby pair.
+type server struct {
+	upspin.StoreServer
+	*shared
+}
+
+// shared holds the state common to all the instances returned by Dial.
+type shared struct {
+	self    upspin.Endpoint
+	reg     Registry
+	standby func() (upspin.StoreServer, error)
+
+	mu      sync.Mutex
+	primary upspin.Endpoint // As last read from reg.
+	readAt  time.Time       // When it was read.
+}
+
+// currentPrimary returns the endpoint of the current primary. If the
+// Registry cannot be read, the last known primary is returned, and if
+// none is known, self.
+func (s *shared) currentPrimary() upspin.Endpoint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if time.Since(s.readAt) >= registryTTL {
+		e, err := s.reg.Primary()
+		if err != nil {
+			log.Error.Printf("store/standby: %v", err)
+		} else {
+			s.primary = e
+		}
+		s.readAt = time.Now()
+	}
+	if s.primary.Transport == upspin.Unassigned {
+		return s.self
+	}
+	return s.primary
+}
+
+// isPrimary reports whether this server is the primary.
+func (s *shared) isPrimary() bool {
+	return s.currentPrimary() == s.self
+}
+
+// Get implements upspin.StoreServer.
+func (s *server) Get(ref upspin.Reference) ([]byte, *upspin.Refdata, []upspin.Location, error) {
+	data, refdata, locs, err := s.StoreServer.Get(ref)
+	if errors.Is(errors.NotExist, err) {
+		if p := s.currentPrimary(); p != s.self {
+			return nil, nil, []upspin.Location{{Endpoint: p, Reference: ref}}, nil
+		}
+	}
+	return data, refdata, locs, err
+}
+
+// Put implements upspin.StoreServer.
+func (s *server) Put(data []byte) (*upspin.Refdata, error) {
+	refdata, err := s.StoreServer.Put(data)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mirrorPut(data, refdata.Reference); err != nil {
+		return nil, err
+	}
+	return refdata, nil
+}
+
+// PutWithMeta implements upspin.StoreMetaPutter if the underlying store
+// does. The standby is given the data only.
+func (s *server) PutWithMeta(data []byte, writer upspin.UserName) (*upspin.Refdata, error) {
+	mp, ok := s.StoreServer.(upspin.StoreMetaPutter)
+	if !ok {
+		return s.Put(data)
+	}
+	refdata, err := mp.PutWithMeta(data, writer)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.mirrorPut(data, refdata.Reference); err != nil {
+		return nil, err
+	}
+	return refdata, nil
+}
+
+// mirrorPut stores data on the standby if this server is the primary.
+func (s *server) mirrorPut(data []byte, ref upspin.Reference) error {
+	const op errors.Op = "store/standby.Put"
+	if s.standby == nil || !s.isPrimary() {
+		return nil
+	}
+	standby, err := s.standby()
+	if err != nil {
+		return errors.E(op, errors.IO, err)
+	}
+	refdata, err := standby.Put(data)
+	if err != nil {
+		return errors.E(op, errors.IO, err)
+	}
+	if refdata.Reference != ref {
+		return errors.E(op, errors.IO, errors.Errorf("standby stored %q as %q", ref, refdata.Reference))
+	}
+	return nil
+}
+
+// Delete implements upspin.StoreServer.
+func (s *server) Delete(ref upspin.Reference) error {
+	const op errors.Op = "store/standby.Delete"
+	if err := s.StoreServer.Delete(ref); err != nil {
+		return err
+	}
+	if s.standby == nil || !s.isPrimary() {
+		return nil
+	}
+	standby, err := s.standby()
+	if err != nil {
+		return errors.E(op, errors.IO, err)
+	}
+	if err := standby.Delete(ref); err != nil && !errors.Is(errors.NotExist, err) {
+		return errors.E(op, errors.IO, err)
+	}
+	return nil
+}
+
+// Dial implements upspin.Service.
+func (s *server) Dial(cfg upspin.Config, e upspin.Endpoint) (upspin.Service, error) {
+	svc, err := s.StoreServer.Dial(cfg, e)
+	if err != nil {
+		return nil, err
+	}
+	return &server{
+		StoreServer: svc.(upspin.StoreServer),
+		shared:      s.shared,
+	}, nil
+}
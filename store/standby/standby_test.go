@@ -0,0 +1,149 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package standby
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/store/inprocess"
+	"upspin.io/upspin"
+)
+
+var (
+	primaryEndpoint = upspin.Endpoint{Transport: upspin.Remote, NetAddr: "primary.example.com:443"}
+	standbyEndpoint = upspin.Endpoint{Transport: upspin.Remote, NetAddr: "standby.example.com:443"}
+)
+
+func TestFailover(t *testing.T) {
+	dir, err := os.MkdirTemp("", "store-standby")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	reg := NewFileRegistry(filepath.Join(dir, "primary"))
+	if err := reg.SetPrimary(primaryEndpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	primaryStore, standbyStore := inprocess.New(), inprocess.New()
+	primary := New(primaryStore, primaryEndpoint, reg, func() (upspin.StoreServer, error) {
+		return standbyStore, nil
+	})
+	standby := New(standbyStore, standbyEndpoint, reg, nil)
+
+	// Puts and Deletes on the primary are mirrored.
+	data := []byte("some data")
+	refdata, err := primary.Put(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := refdata.Reference
+	got, _, _, err := standbyStore.Get(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Fatalf("standby holds %q, want %q", got, data)
+	}
+	if err := primary.Delete(ref); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := standbyStore.Get(ref); !errors.Is(errors.NotExist, err) {
+		t.Fatalf("standby Get after Delete: err = %v, want NotExist", err)
+	}
+
+	// The standby redirects to the primary what it does not hold.
+	checkRedirect(t, standby, "missing", primaryEndpoint)
+
+	// After failover the roles are reversed. New servers are made
+	// so as not to wait for them to notice.
+	if err := reg.SetPrimary(standbyEndpoint); err != nil {
+		t.Fatal(err)
+	}
+	primary = New(primaryStore, primaryEndpoint, reg, func() (upspin.StoreServer, error) {
+		return standbyStore, nil
+	})
+	standby = New(standbyStore, standbyEndpoint, reg, nil)
+	refdata, err = primary.Put([]byte("more data"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := standbyStore.Get(refdata.Reference); !errors.Is(errors.NotExist, err) {
+		t.Fatalf("demoted primary mirrored a Put: err = %v, want NotExist", err)
+	}
+	checkRedirect(t, primary, "missing", standbyEndpoint)
+	if _, _, _, err := standby.Get("missing"); !errors.Is(errors.NotExist, err) {
+		t.Fatalf("promoted standby Get: err = %v, want NotExist", err)
+	}
+}
+
+func checkRedirect(t *testing.T, s upspin.StoreServer, ref upspin.Reference, to upspin.Endpoint) {
+	t.Helper()
+	_, _, locs, err := s.Get(ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := upspin.Location{Endpoint: to, Reference: ref}
+	if len(locs) != 1 || locs[0] != want {
+		t.Fatalf("Get(%q) redirected to %v, want %v", ref, locs, want)
+	}
+}
+
+func TestMonitor(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accepted := make(chan net.Conn, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn
+		}
+	}()
+	m := &Monitor{
+		Primary:       upspin.NetAddr(ln.Addr().String()),
+		FailoverAfter: 200 * time.Millisecond,
+		redial:        10 * time.Millisecond,
+	}
+	result := make(chan bool)
+	done := make(chan struct{})
+	go func() { result <- m.Run(done) }()
+
+	// While the primary is up, there is no failover.
+	var conn net.Conn
+	select {
+	case conn = <-accepted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("monitor did not connect")
+	}
+	select {
+	case <-result:
+		t.Fatal("monitor returned while the primary was up")
+	case <-time.After(2 * m.FailoverAfter):
+	}
+
+	// Once it goes away for long enough, there is.
+	ln.Close()
+	conn.Close()
+	select {
+	case failover := <-result:
+		if !failover {
+			t.Fatal("monitor returned false, want true")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("monitor did not notice the primary went away")
+	}
+	close(done)
+}
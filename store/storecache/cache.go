@@ -17,8 +17,8 @@ import (
 
 	"upspin.io/bind"
 	"upspin.io/cache"
+	"upspin.io/cache/shm"
 	"upspin.io/key/sha256key"
-	"upspin.io/log"
 	"upspin.io/upspin"
 )
 
@@ -63,6 +63,18 @@ type storeCache struct {
 	wbq   *writebackQueue
 	log   *os.File
 
+	// shared, if non-nil, is a block cache in memory shared with other
+	// processes, consulted before the files in dir.
+	shared *shm.Cache
+
+	// pins records the pins made by clients reading files, which keep
+	// the files' blocks from being evicted.
+	pins pins
+
+	// overflow holds blocks fetched while the cache was full and
+	// everything in it was pinned.
+	overflow *overflowBuffer
+
 	logLock   sync.Mutex
 	buffered  *bufio.Writer
 	logLen    int64
@@ -72,7 +84,7 @@ type storeCache struct {
 // newCache returns the cache rooted at dir. It will walk the cache
 // to put all files into the LRU and the writeback tree to continue
 // trying to write refs back.
-func newCache(cfg upspin.Config, dir, wbDir string, maxBytes int64, writethrough bool) (*storeCache, func(upspin.Location), error) {
+func newCache(cfg upspin.Config, dir, wbDir string, maxBytes, overflowBytes int64, writethrough bool, shared *shm.Cache) (*storeCache, func(upspin.Location), error) {
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return nil, nil, err
 	}
@@ -80,7 +92,9 @@ func newCache(cfg upspin.Config, dir, wbDir string, maxBytes int64, writethrough
 	if maxRefs > 10000000 {
 		maxRefs = 10000000
 	}
-	c := &storeCache{cfg: cfg, dir: dir, wbDir: wbDir, limit: maxBytes, lru: cache.NewLRU(maxRefs)}
+	c := &storeCache{cfg: cfg, dir: dir, wbDir: wbDir, limit: maxBytes, lru: cache.NewLRU(maxRefs), shared: shared}
+	c.pins.leases = make(map[string]*pinLease)
+	c.overflow = newOverflowBuffer(overflowBytes)
 	var blockFlusher func(upspin.Location)
 	if !writethrough {
 		c.wbq = newWritebackQueue(c)
@@ -123,12 +137,12 @@ func (c *storeCache) walkedWriteBack(relPath string, size int64) {
 		return
 	}
 	if err := os.MkdirAll(filepath.Dir(cachePath), 0700); err != nil {
-		log.Error.Printf("walkedWriteBack %s: %s", relPath, err)
+		logger.Error.Printf("walkedWriteBack %s: %s", relPath, err)
 		return
 	}
 	wbPath := c.absWritebackPath(relPath)
 	if err := os.Link(wbPath, cachePath); err != nil {
-		log.Error.Printf("walkedWriteBack %s: %s", relPath, err)
+		logger.Error.Printf("walkedWriteBack %s: %s", relPath, err)
 	}
 }
 
@@ -155,7 +169,7 @@ func (c *storeCache) walk(root, relDirPath string, action func(string, int64)) e
 	info, err := f.Readdir(0)
 	f.Close()
 	if err != nil {
-		log.Error.Printf("walking cache dirs: %s", err)
+		logger.Error.Printf("walking cache dirs: %s", err)
 		return err
 
 	}
@@ -228,7 +242,7 @@ func (c *storeCache) rewriteLog() {
 	logPath := c.absCachePath(logName)
 	f, err := os.Create(tmpLogPath)
 	if err != nil {
-		log.Error.Printf("creating log file: %s", err)
+		logger.Error.Printf("creating log file: %s", err)
 		return
 	}
 	buffered := bufio.NewWriter(f)
@@ -240,18 +254,18 @@ func (c *storeCache) rewriteLog() {
 			break
 		}
 		if _, err := buffered.WriteString(key.(string) + "\n"); err != nil {
-			log.Error.Printf("appending to access log: %s", err)
+			logger.Error.Printf("appending to access log: %s", err)
 			break
 		}
 		logLen++
 	}
 	if err := buffered.Flush(); err != nil {
-		log.Error.Printf("appending to temporary log: %s", err)
+		logger.Error.Printf("appending to temporary log: %s", err)
 	}
 
 	// Rename temporary to permanent log.
 	if err := os.Rename(tmpLogPath, logPath); err != nil {
-		log.Error.Printf("appending to temporary log: %s", err)
+		logger.Error.Printf("appending to temporary log: %s", err)
 	}
 
 	// Switch to new log.
@@ -263,7 +277,7 @@ func (c *storeCache) rewriteLog() {
 func (c *storeCache) logAccess(file string) {
 	c.logLock.Lock()
 	if _, err := c.buffered.WriteString(file + "\n"); err != nil {
-		log.Error.Printf("appending to access log: %s", err)
+		logger.Error.Printf("appending to access log: %s", err)
 	}
 	c.logLen++
 
@@ -320,9 +334,36 @@ func (c *storeCache) get(cfg upspin.Config, ref upspin.Reference, e upspin.Endpo
 		return []byte("cache flushed"), nil, nil
 	}
 
+	if refs := strings.TrimPrefix(string(ref), string(upspin.PinMetadata)); len(refs) < len(ref) {
+		data, err := c.pin(refs, e)
+		return data, nil, err
+	}
+	if token := strings.TrimPrefix(string(ref), string(upspin.UnpinMetadata)); len(token) < len(ref) {
+		return nil, nil, c.unpin(token)
+	}
+
 	file := c.cachePath(ref, e)
 
-	c.enforceByteLimitByRemovingLeastRecentlyUsedFile()
+	if c.shared != nil {
+		if data, ok := c.shared.Get(file); ok {
+			// Keep our copy of the file fresh too.
+			c.mu.Lock()
+			_, ok := c.lru.Get(file)
+			c.mu.Unlock()
+			if ok {
+				c.logAccess(file)
+			}
+			return data, nil, nil
+		}
+	}
+
+	if data, ok := c.overflow.get(file); ok {
+		return data, nil, nil
+	}
+
+	// If the cache is full of pinned files, keep what we fetch in the
+	// overflow buffer instead.
+	full := !c.enforceByteLimitByRemovingLeastRecentlyUsedFile()
 
 	// The loop terminates either by returning the cached data
 	// or while holding the cachedRef's Lock, ready to fetch
@@ -363,6 +404,7 @@ func (c *storeCache) get(cfg upspin.Config, ref upspin.Reference, e upspin.Endpo
 			break
 		}
 		cr.Unlock()
+		c.sharedPut(file, data)
 		c.logAccess(file)
 		return data, nil, nil
 	}
@@ -416,10 +458,13 @@ func (c *storeCache) get(cfg upspin.Config, ref upspin.Reference, e upspin.Endpo
 			}
 			if locs == nil && err == nil {
 				// Success, maybe cache the data.
-				if !refdata.Volatile {
+				if !refdata.Volatile && full {
+					c.overflow.put(file, data)
+				} else if !refdata.Volatile {
 					if err := cr.saveToCacheFile(file, data); err != nil {
-						log.Error.Printf("saving cached ref %s to %s: %s", string(ref), file, err)
+						logger.Error.Printf("saving cached ref %s to %s: %s", string(ref), file, err)
 					}
+					c.sharedPut(file, data)
 				}
 				c.logAccess(file)
 				return data, nil, nil
@@ -485,7 +530,7 @@ func (c *storeCache) put(cfg upspin.Config, data []byte, e upspin.Endpoint) (ups
 
 	// Save the data in a file and remember we cached it.
 	if err := cr.saveToCacheFile(file, data); err != nil {
-		log.Error.Printf("saving cached ref %s to %s: %s", string(ref), file, err)
+		logger.Error.Printf("saving cached ref %s to %s: %s", string(ref), file, err)
 		if c.wbq != nil {
 			// When writing back, any problem writing the file into the
 			// cache is fatal.
@@ -493,6 +538,8 @@ func (c *storeCache) put(cfg upspin.Config, data []byte, e upspin.Endpoint) (ups
 		}
 	}
 
+	c.sharedPut(file, data)
+
 	// Add to list of files to write back.
 	if c.wbq != nil {
 		if err := c.wbq.requestWriteback(ref, e); err != nil {
@@ -518,6 +565,10 @@ func (c *storeCache) delete(cfg upspin.Config, ref upspin.Reference, e upspin.En
 		return err
 	}
 	file := c.cachePath(ref, e)
+	if c.shared != nil {
+		c.shared.Remove(file)
+	}
+	c.overflow.remove(file)
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	value, ok := c.lru.Get(file)
@@ -535,6 +586,13 @@ func (c *storeCache) delete(cfg upspin.Config, ref upspin.Reference, e upspin.En
 	return nil
 }
 
+// sharedPut adds the data for a cache file to the shared cache, if any.
+func (c *storeCache) sharedPut(file string, data []byte) {
+	if c.shared != nil {
+		c.shared.Put(file, data)
+	}
+}
+
 // readFromCachefile reads in the cache file, if it exists.
 // Called with the cachedFile locked.
 func (c *storeCache) readFromCacheFile(file string) ([]byte, error) {
@@ -574,7 +632,7 @@ func (cr *cachedRef) saveToCacheFile(file string, data []byte) error {
 	cleanup := func() {
 		f.Close()
 		if err := os.Remove(tmpName); err != nil {
-			log.Info.Printf("removing cache file: %s", err)
+			logger.Info.Printf("removing cache file: %s", err)
 		}
 	}
 	n, err := f.Write(data)
@@ -611,20 +669,20 @@ func (cr *cachedRef) saveToCacheFile(file string, data []byte) error {
 	return nil
 }
 
-// enforceByteLimitByRemovingLeastRecentlyUsedFile removes the oldest entries until inUse is below limit. We take a leap
-// of faith that the least recently used entry is not currently in use.
-func (c *storeCache) enforceByteLimitByRemovingLeastRecentlyUsedFile() {
+// enforceByteLimitByRemovingLeastRecentlyUsedFile removes the oldest unpinned entries until inUse is below limit.
+// It reports whether it succeeded. We take a leap of faith that the least recently used entry is not currently in use.
+func (c *storeCache) enforceByteLimitByRemovingLeastRecentlyUsedFile() bool {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	for {
 		if atomic.LoadInt64(&c.inUse) < c.limit {
-			break
+			return true
 		}
 		key, value := c.lru.RemoveOldest()
 		if value == nil {
-			// Nothing left.
-			log.Info.Printf("exceeding cache byte limit")
-			break
+			// Nothing left that is not pinned.
+			logger.Info.Printf("exceeding cache byte limit")
+			return false
 		}
 		value.(*cachedRef).OnEviction(key)
 	}
@@ -638,7 +696,7 @@ func (cr *cachedRef) OnEviction(key interface{}) {
 	if cr.busy {
 		// Someone is trying to read this in or put it. Don't bother removing anything
 		// but this is an odd situation so log it.
-		log.Info.Printf("cache file busy on eviction: %s", file)
+		logger.Info.Printf("cache file busy on eviction: %s", file)
 		// Remember to remove it when it is no longer busy.
 		cr.remove = true
 		return
@@ -653,6 +711,6 @@ func (cr *cachedRef) removeFile(file string) {
 	cr.remove = false
 	atomic.AddInt64(&cr.c.inUse, -cr.size)
 	if err := os.Remove(cr.c.absCachePath(file)); err != nil {
-		log.Info.Printf("can't remove file on eviction: %s", err)
+		logger.Info.Printf("can't remove file on eviction: %s", err)
 	}
 }
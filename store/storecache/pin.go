@@ -0,0 +1,140 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package storecache
+
+import (
+	"encoding/json"
+	"strconv"
+	"sync"
+	"time"
+
+	"upspin.io/errors"
+	"upspin.io/upspin"
+)
+
+// pinTimeout is how long a pin lasts if it is not released.
+const pinTimeout = 10 * time.Minute
+
+// pinLease records the cache files pinned by one PinMetadata request.
+type pinLease struct {
+	files []string
+	timer *time.Timer // Releases the pins after pinTimeout.
+}
+
+// pins records the outstanding pin leases, keyed by token.
+type pins struct {
+	mu     sync.Mutex
+	seq    int64
+	leases map[string]*pinLease
+}
+
+// pin pins in the LRU the cache files of the references stored at e,
+// which are JSON-encoded as described by upspin.PinMetadata. It returns
+// the token with which to unpin them.
+func (c *storeCache) pin(refs string, e upspin.Endpoint) ([]byte, error) {
+	const op errors.Op = "store/storecache.pin"
+	var list []upspin.Reference
+	if err := json.Unmarshal([]byte(refs), &list); err != nil {
+		return nil, errors.E(op, errors.Invalid, err)
+	}
+	lease := &pinLease{files: make([]string, len(list))}
+	for i, ref := range list {
+		lease.files[i] = c.cachePath(ref, e)
+		c.lru.Pin(lease.files[i])
+	}
+
+	c.pins.mu.Lock()
+	defer c.pins.mu.Unlock()
+	c.pins.seq++
+	token := strconv.FormatInt(c.pins.seq, 10)
+	lease.timer = time.AfterFunc(pinTimeout, func() { c.unpin(token) })
+	c.pins.leases[token] = lease
+	return []byte(token), nil
+}
+
+// unpin releases the pins made by the pin call that returned token.
+func (c *storeCache) unpin(token string) error {
+	const op errors.Op = "store/storecache.unpin"
+	c.pins.mu.Lock()
+	lease, ok := c.pins.leases[token]
+	delete(c.pins.leases, token)
+	c.pins.mu.Unlock()
+	if !ok {
+		// Perhaps the pins expired.
+		return errors.E(op, errors.NotExist, errors.Errorf("no pins for token %q", token))
+	}
+	lease.timer.Stop()
+	for _, file := range lease.files {
+		c.lru.Unpin(file)
+	}
+	return nil
+}
+
+// overflowBuffer holds in memory the blocks that could not be cached on
+// disk because the cache was full and everything in it was pinned. It is
+// bounded in size, discarding its oldest blocks to make room for new ones.
+type overflowBuffer struct {
+	mu    sync.Mutex
+	limit int64
+	size  int64
+	order []string // Oldest first.
+	data  map[string][]byte
+}
+
+func newOverflowBuffer(limit int64) *overflowBuffer {
+	return &overflowBuffer{
+		limit: limit,
+		data:  make(map[string][]byte),
+	}
+}
+
+// get returns the block for the cache file, if it is in the buffer.
+func (o *overflowBuffer) get(file string) ([]byte, bool) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	data, ok := o.data[file]
+	return data, ok
+}
+
+// put adds the block for the cache file to the buffer, if it fits.
+func (o *overflowBuffer) put(file string, data []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if int64(len(data)) > o.limit {
+		return
+	}
+	if _, ok := o.data[file]; ok {
+		return
+	}
+	for o.size+int64(len(data)) > o.limit {
+		o.removeLocked(o.order[0])
+	}
+	o.data[file] = data
+	o.order = append(o.order, file)
+	o.size += int64(len(data))
+}
+
+// remove removes the block for the cache file from the buffer.
+func (o *overflowBuffer) remove(file string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.removeLocked(file)
+}
+
+// removeLocked is remove with o.mu held.
+func (o *overflowBuffer) removeLocked(file string) {
+	data, ok := o.data[file]
+	if !ok {
+		return
+	}
+	delete(o.data, file)
+	o.size -= int64(len(data))
+	for i, f := range o.order {
+		if f == file {
+			o.order = append(o.order[:i], o.order[i+1:]...)
+			break
+		}
+	}
+}
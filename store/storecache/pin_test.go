@@ -0,0 +1,112 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package storecache
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"upspin.io/bind"
+	"upspin.io/config"
+	"upspin.io/errors"
+	"upspin.io/upspin"
+
+	storeserver "upspin.io/store/inprocess"
+)
+
+func TestPinAndOverflow(t *testing.T) {
+	bind.RegisterStoreServer(upspin.InProcess, storeserver.New())
+	cfg := config.SetUserName(config.New(), "user@example.com")
+	e := upspin.Endpoint{Transport: upspin.InProcess}
+	store, err := bind.StoreServer(cfg, e)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := os.MkdirTemp("", "storecache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	// Room for two blocks, and one more in the overflow buffer.
+	const blockSize = 400
+	c, _, err := newCache(cfg, filepath.Join(dir, "cache"), filepath.Join(dir, "wb"), 1024, blockSize, true, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var refs []upspin.Reference
+	var blocks [][]byte
+	for i := 0; i < 5; i++ {
+		data := bytes.Repeat([]byte{byte(i)}, blockSize)
+		rd, err := store.Put(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		refs = append(refs, rd.Reference)
+		blocks = append(blocks, data)
+	}
+	get := func(i int) {
+		data, _, err := c.get(cfg, refs[i], e)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(data, blocks[i]) {
+			t.Fatalf("block %d: wrong data", i)
+		}
+	}
+	onDisk := func(i int) bool {
+		_, err := os.Stat(c.absCachePath(c.cachePath(refs[i], e)))
+		return err == nil
+	}
+
+	// Pin the first three blocks and read them, overfilling the cache.
+	list, err := json.Marshal(refs[:3])
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, _, err := c.get(cfg, upspin.PinMetadata+upspin.Reference(list), e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		get(i)
+	}
+
+	// Everything in the cache is pinned, so the next block overflows.
+	get(3)
+	for i := 0; i < 3; i++ {
+		if !onDisk(i) {
+			t.Errorf("pinned block %d was evicted", i)
+		}
+	}
+	if onDisk(3) {
+		t.Errorf("block 3 was cached on disk, want overflow")
+	}
+	if _, ok := c.overflow.get(c.cachePath(refs[3], e)); !ok {
+		t.Errorf("block 3 is not in the overflow buffer")
+	}
+	get(3)
+
+	// Once unpinned, the blocks may be evicted.
+	if _, _, err := c.get(cfg, upspin.UnpinMetadata+upspin.Reference(token), e); err != nil {
+		t.Fatal(err)
+	}
+	get(4)
+	if onDisk(0) {
+		t.Errorf("unpinned block 0 was not evicted")
+	}
+	if !onDisk(4) {
+		t.Errorf("block 4 was not cached on disk")
+	}
+
+	_, _, err = c.get(cfg, upspin.UnpinMetadata+upspin.Reference(token), e)
+	if !errors.Is(errors.NotExist, err) {
+		t.Errorf("second unpin: err = %v, want NotExist", err)
+	}
+}
@@ -10,11 +10,14 @@ import (
 	"fmt"
 	"path"
 
+	"upspin.io/cache/shm"
 	"upspin.io/errors"
 	"upspin.io/log"
 	"upspin.io/upspin"
 )
 
+var logger = log.For("store/storecache")
+
 // server implements upspin.Storeserver.
 type server struct {
 	cfg upspin.Config
@@ -30,9 +33,13 @@ type server struct {
 // For writeback caches, it also returns a function to flush Blocks
 // that are waiting to be written back. This is important to allow
 // the client to flush out Access file blocks before writing the
-// DirEntry.
-func New(cfg upspin.Config, cacheDir string, maxBytes int64, writethrough bool) (upspin.StoreServer, func(upspin.Location), error) {
-	c, blockFlusher, err := newCache(cfg, path.Join(cacheDir, "storecache"), path.Join(cacheDir, "storewritebackqueue"), maxBytes, writethrough)
+// DirEntry. If shared is non-nil, blocks are also kept in it, so that
+// other processes using the same shared cache need not fetch them again.
+// Blocks fetched when the cache is full and all its blocks are pinned
+// (see upspin.PinMetadata) are kept in memory instead, in up to
+// overflowBytes.
+func New(cfg upspin.Config, cacheDir string, maxBytes, overflowBytes int64, writethrough bool, shared *shm.Cache) (upspin.StoreServer, func(upspin.Location), error) {
+	c, blockFlusher, err := newCache(cfg, path.Join(cacheDir, "storecache"), path.Join(cacheDir, "storewritebackqueue"), maxBytes, overflowBytes, writethrough, shared)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -112,7 +119,7 @@ func (s *server) Close()                    {}
 
 func logf(format string, args ...interface{}) operation {
 	s := fmt.Sprintf(format, args...)
-	log.Debug.Print("store/storecache: " + s)
+	logger.Debug.Print("store/storecache: " + s)
 	return operation(s)
 }
 
@@ -13,7 +13,6 @@ import (
 
 	"upspin.io/bind"
 	"upspin.io/errors"
-	"upspin.io/log"
 	"upspin.io/serverutil"
 	"upspin.io/upspin"
 )
@@ -133,17 +132,17 @@ func (wbq *writebackQueue) enqueueWritebackFile(relPath string) {
 	const op errors.Op = "store/storecache.isWritebackFile"
 
 	if wbq == nil {
-		log.Error.Printf("%s: writeback file %s but running as writethrough", op, relPath)
+		logger.Error.Printf("%s: writeback file %s but running as writethrough", op, relPath)
 		return
 	}
 	elems := strings.Split(relPath, string(filepath.Separator))
 	if len(elems) != 3 {
-		log.Error.Printf("%s: odd writeback file %s", op, relPath)
+		logger.Error.Printf("%s: odd writeback file %s", op, relPath)
 		return
 	}
 	e, err := upspin.ParseEndpoint(elems[0])
 	if err != nil {
-		log.Error.Printf("%s: odd writeback file %s: %s", op, relPath, err)
+		logger.Error.Printf("%s: odd writeback file %s: %s", op, relPath, err)
 		return
 	}
 	wbq.request <- &request{
@@ -162,11 +161,11 @@ func (wbq *writebackQueue) scheduler() {
 	for {
 		select {
 		case r := <-wbq.request:
-			log.Debug.Printf("%s: received %s %s", op, r.Reference, r.Endpoint)
+			logger.Debug.Printf("%s: received %s %s", op, r.Reference, r.Endpoint)
 			// Keep a map of requests so that we can handle flushes
 			// and avoid Duplicates.
 			if wbq.queued[r.Location] != nil {
-				log.Debug.Printf("%s: %s %s already queued", op, r.Reference, r.Endpoint)
+				logger.Debug.Printf("%s: %s %s already queued", op, r.Reference, r.Endpoint)
 				// Already queued. Unusual but OK.
 				break
 			}
@@ -181,7 +180,7 @@ func (wbq *writebackQueue) scheduler() {
 			}
 			epq.queue = append(epq.queue, r)
 			wbq.enqueued++
-			log.Debug.Printf("%s: %s %s queued", op, r.Reference, r.Endpoint)
+			logger.Debug.Printf("%s: %s %s queued", op, r.Reference, r.Endpoint)
 		case r := <-wbq.done:
 			// A request has been completed.
 			epq := wbq.byEndpoint[r.Endpoint]
@@ -191,12 +190,12 @@ func (wbq *writebackQueue) scheduler() {
 					// The error has been dealt with. Since it was
 					// probably a server timeout, count it for output.
 					wbq.output.Add(r.len)
-					log.Error.Printf("%s: timeout: goodput %s, output %s",
+					logger.Error.Printf("%s: timeout: goodput %s, output %s",
 						op, wbq.goodput.String(),
 						wbq.output.String())
 					break
 				} else {
-					log.Error.Printf("%s: writeback failed: %s", op, r.err)
+					logger.Error.Printf("%s: writeback failed: %s", op, r.err)
 				}
 
 				// Mark endpoint as dead so we don't waste time trying. Retry
@@ -217,7 +216,7 @@ func (wbq *writebackQueue) scheduler() {
 
 			// Awaken everyone waiting for a flush of a particular block.
 			for _, c := range r.flushChans {
-				log.Debug.Printf("awakening block flusher")
+				logger.Debug.Printf("awakening block flusher")
 				close(c)
 			}
 			delete(wbq.queued, r.Location)
@@ -226,13 +225,13 @@ func (wbq *writebackQueue) scheduler() {
 			// Awaken everyone waiting for a flush of all writebacks.
 			if wbq.enqueued == 0 {
 				for _, c := range wbq.flushChans {
-					log.Debug.Printf("awakening all flusher")
+					logger.Debug.Printf("awakening all flusher")
 					close(c)
 				}
 				wbq.flushChans = nil
 			}
 
-			log.Debug.Printf("%s: %s %s done", op, r.Reference, r.Endpoint)
+			logger.Debug.Printf("%s: %s %s done", op, r.Reference, r.Endpoint)
 		case epq := <-wbq.retry:
 			// Set its state to unknown so we'll try a single request to feel it out.
 			if epq.state == dead {
@@ -331,7 +330,7 @@ func (wbq *writebackQueue) writeback(r *request) error {
 	data, err := wbq.sc.readFromCacheFile(absPath)
 	if err != nil {
 		// Nothing we can do, log it but act like we succeeded.
-		log.Error.Printf("store/storecache.writer: data for %s@%s disappeared before writeback: %s", r.Reference, r.Endpoint, err)
+		logger.Error.Printf("store/storecache.writer: data for %s@%s disappeared before writeback: %s", r.Reference, r.Endpoint, err)
 		return nil
 	}
 	r.len = int64(len(data))
@@ -350,9 +349,9 @@ func (wbq *writebackQueue) writeback(r *request) error {
 		return err
 	}
 	if err := os.Remove(absPath); err != nil {
-		log.Error.Printf("store/storecache.writer: fail remove after writeback: %s", err)
+		logger.Error.Printf("store/storecache.writer: fail remove after writeback: %s", err)
 	}
-	log.Info.Printf("store/storecache.writer: %s@%s writeback successful", r.Reference, r.Endpoint)
+	logger.Info.Printf("store/storecache.writer: %s@%s writeback successful", r.Reference, r.Endpoint)
 	return nil
 }
 
@@ -374,7 +373,7 @@ func (wbq *writebackQueue) requestWriteback(ref upspin.Reference, e upspin.Endpo
 				// Someone else is already writing it back.
 				return nil
 			}
-			log.Debug.Printf("%s", err)
+			logger.Debug.Printf("%s", err)
 			return err
 		}
 	}
@@ -452,7 +451,7 @@ func (p *parallelism) failure(err error) bool {
 	// We assume that even at half the maximum attainable error-free
 	// concurrency we will achieve maximum throughput.
 	p.max = (p.max + 1) / 2
-	log.Debug.Printf("%s: down %d", op, p.max)
+	logger.Debug.Printf("%s: down %d", op, p.max)
 	return true
 }
 
@@ -502,7 +501,7 @@ func (p *parallelism) success() {
 	if p.successes >= 2*p.max {
 		p.successes = 0
 		p.max++
-		log.Debug.Printf("%s: up %d", op, p.max)
+		logger.Debug.Printf("%s: up %d", op, p.max)
 	}
 }
 
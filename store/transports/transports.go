@@ -12,6 +12,7 @@ import (
 	"upspin.io/store/inprocess"
 	"upspin.io/upspin"
 
+	_ "upspin.io/store/https"
 	_ "upspin.io/store/remote"
 	_ "upspin.io/store/unassigned"
 )
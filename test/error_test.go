@@ -340,7 +340,7 @@ func testPutLinkErrors(t *testing.T, r *testenv.Runner) {
 	r.MakeDirectory(base)
 	r.MakeDirectory(srcDir)
 	r.MakeDirectory(dstDir)
-	r.PutLink(fileTarget, fileLink)
+	r.PutUncheckedLink(fileTarget, fileLink)
 	r.PutLink(dirTarget, dirLink)
 	r.Put(fileThroughFileLink, content)
 	r.Get(fileTarget)
@@ -554,7 +554,7 @@ func testMakeDirectoryLinkErrors(t *testing.T, r *testenv.Runner) {
 	r.MakeDirectory(base)
 	r.MakeDirectory(srcDir)
 	r.MakeDirectory(dstDir)
-	r.PutLink(directTarget, directLink)
+	r.PutUncheckedLink(directTarget, directLink)
 	r.PutLink(indirectTarget, indirectLink)
 	r.MakeDirectory(dirThroughDirectLink)
 	r.Delete(dir)
@@ -975,9 +975,9 @@ func testGlobLinkErrors(t *testing.T, r *testenv.Runner) {
 	r.MakeDirectory(dir3)
 	r.Put(dir1file, content)
 	r.PutLink(dir2, dir2link)
+	r.Put(dir2file, content)
 	r.PutLink(dir2file, dir2filelink)
 	r.PutLink(dir3, dir3link)
-	r.Put(dir2file, content)
 	r.Put(dir3file, content)
 	if r.Failed() {
 		t.Fatal(r.Diag())
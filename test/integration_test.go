@@ -35,6 +35,7 @@ const (
 
 var (
 	errExist      = errors.E(errors.Exist)
+	errInvalid    = errors.E(errors.Invalid)
 	errNotExist   = errors.E(errors.NotExist)
 	errPermission = errors.E(errors.Permission)
 	errPrivate    = errors.E(errors.Private)
@@ -357,6 +358,8 @@ var integrationTests = []struct {
 
 	{"CopyEntries", testCopyEntries},
 	{"Snapshot", testSnapshot},
+	{"RenameDir", testRenameDir},
+	{"RenameDirAcrossUsers", testRenameDirAcrossUsers},
 	{"DeleteErrors", testDeleteErrors},
 
 	// Each of these tests depend on the output of the previous one.
@@ -0,0 +1,159 @@
+// Copyright 2026 The Upspin Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package test
+
+import (
+	"strings"
+	"testing"
+
+	"upspin.io/test/testenv"
+	"upspin.io/upspin"
+)
+
+func testRenameDir(t *testing.T, r *testenv.Runner) {
+	const (
+		base   = ownerName + "/renamedir-test"
+		from   = base + "/from"
+		shared = base + "/shared"
+		to     = shared + "/to"
+	)
+	files := map[upspin.PathName]string{
+		"/a":     "contents of a",
+		"/sub/b": "contents of b",
+	}
+
+	r.As(ownerName)
+	r.MakeDirectory(base)
+	r.MakeDirectory(shared)
+	r.Put(shared+"/Access", "*:"+ownerName+"\nr:"+readerName)
+	r.MakeDirectory(from)
+	r.MakeDirectory(from + "/sub")
+	for name, data := range files {
+		r.Put(from+name, data)
+	}
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+
+	r.RenameDir(from, to)
+	if err := r.Err(); err != nil {
+		if !strings.Contains(err.Error(), upspin.ErrNotSupported.Error()) {
+			t.Fatal(err)
+		}
+		// This DirServer cannot rename directories, so nothing
+		// may have changed.
+		r.DirLookup(from + "/sub/b")
+		if r.Failed() {
+			t.Fatal(r.Diag())
+		}
+		return
+	}
+
+	r.DirLookup(from)
+	if !r.Match(errNotExist) {
+		t.Fatal(r.Diag())
+	}
+	for name, data := range files {
+		r.Get(to + name)
+		if r.Failed() {
+			t.Fatal(r.Diag())
+		}
+		if r.Data != data {
+			t.Errorf("Get(%q) = %q, want %q", to+name, r.Data, data)
+		}
+	}
+
+	// The reader may read the files under their new Access file.
+	r.As(readerName)
+	r.Get(to + "/sub/b")
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+	if want := files["/sub/b"]; r.Data != want {
+		t.Errorf("reader Get(%q) = %q, want %q", to+"/sub/b", r.Data, want)
+	}
+
+	// A directory cannot be moved beneath itself.
+	r.As(ownerName)
+	r.RenameDir(to, to+"/sub/to")
+	if !r.Match(errInvalid) {
+		t.Fatal(r.Diag())
+	}
+}
+
+func testRenameDirAcrossUsers(t *testing.T, r *testenv.Runner) {
+	const (
+		from     = ownerName + "/renamedir-users-test"
+		toRoot   = readerName + "/"
+		toAccess = readerName + "/Access"
+		to       = readerName + "/to"
+	)
+	files := map[upspin.PathName]string{
+		"/a":     "contents of a",
+		"/sub/b": "contents of b",
+	}
+	// The names in the tree, children before parents.
+	names := []upspin.PathName{"/sub/b", "/sub", "/a", ""}
+
+	r.As(ownerName)
+	r.MakeDirectory(from)
+	r.MakeDirectory(from + "/sub")
+	for name, data := range files {
+		r.Put(from+name, data)
+	}
+	r.As(readerName)
+	r.MakeDirectory(toRoot)
+	r.Put(toAccess, "*:"+readerName+","+ownerName)
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+
+	r.As(ownerName)
+	r.RenameDir(from, to)
+	if err := r.Err(); err != nil {
+		if !strings.Contains(err.Error(), upspin.ErrNotSupported.Error()) {
+			t.Fatal(err)
+		}
+		// These DirServers cannot take part in transactions,
+		// so nothing may have changed.
+		r.DirLookup(from + "/sub/b")
+		r.As(readerName)
+		r.DirLookup(to)
+		if !r.Match(errNotExist) {
+			t.Fatal(r.Diag())
+		}
+		r.As(ownerName)
+		for _, name := range names {
+			r.Delete(from + name)
+		}
+	} else {
+		r.DirLookup(from)
+		if !r.Match(errNotExist) {
+			t.Fatal(r.Diag())
+		}
+		// The new owner of the tree may read the files.
+		r.As(readerName)
+		for name, data := range files {
+			r.Get(to + name)
+			if r.Failed() {
+				t.Fatal(r.Diag())
+			}
+			if r.Data != data {
+				t.Errorf("Get(%q) = %q, want %q", to+name, r.Data, data)
+			}
+		}
+		for _, name := range names {
+			r.Delete(to + name)
+		}
+	}
+
+	// Other tests expect the reader to have no root.
+	r.As(readerName)
+	r.Delete(toAccess)
+	r.Delete(toRoot)
+	if r.Failed() {
+		t.Fatal(r.Diag())
+	}
+}
@@ -141,6 +141,17 @@ func (r *Runner) PutLink(oldName, linkName upspin.PathName) {
 	r.setErr(err)
 }
 
+// PutUncheckedLink is like PutLink but does not require the link's
+// target to exist. See client.PutUncheckedLink.
+func (r *Runner) PutUncheckedLink(oldName, linkName upspin.PathName) {
+	if r.err != nil {
+		return
+	}
+	entry, err := r.clients[r.user].(*client.Client).PutUncheckedLink(oldName, linkName)
+	r.Entry = entry
+	r.setErr(err)
+}
+
 // MakeDirectory creates a directory by issuing a Put request
 // as the user and populates the Runner's Entry field with the result.
 func (r *Runner) MakeDirectory(p upspin.PathName) {
@@ -152,6 +163,17 @@ func (r *Runner) MakeDirectory(p upspin.PathName) {
 	r.setErr(err)
 }
 
+// RenameDir renames a directory tree atomically, as the user,
+// and populates the Runner's Entry field with the result.
+func (r *Runner) RenameDir(oldName, newName upspin.PathName) {
+	if r.err != nil {
+		return
+	}
+	entry, err := r.clients[r.user].(*client.Client).RenameDir(oldName, newName)
+	r.Entry = entry
+	r.setErr(err)
+}
+
 // Delete performs a Delete request as the user.
 func (r *Runner) Delete(p upspin.PathName) {
 	if r.err != nil {
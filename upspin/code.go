@@ -21,6 +21,11 @@ const (
 	// DirBlocks in a DirEntry. It should be high enough that the limit
 	// will never be reached in practice. 1 million seems okay.
 	maxDirBlocks = 1000000
+
+	// attrHasAttributes is set in the marshaled Attr byte of a DirEntry
+	// that has Attributes, which follow the Sequence. Entries marshaled
+	// before Attributes existed never have it set.
+	attrHasAttributes = 0x80
 )
 
 // This code is very careful not to grow a buffer of length more than a 32-bit
@@ -320,12 +325,51 @@ func (d *DirEntry) MarshalAppend(b []byte) ([]byte, error) {
 		acc.int64(-1)
 	}
 
-	acc.byte(byte(d.Attr))
+	attr := byte(d.Attr)
+	if len(d.Attributes) > 0 {
+		if err := CheckAttributes(d.Attributes); err != nil {
+			return nil, err
+		}
+		attr |= attrHasAttributes
+	}
+	acc.byte(attr)
 	acc.int64(d.Sequence)
 
+	// Attributes: a count n followed by n names and values, sorted by
+	// name so the encoding is deterministic.
+	if len(d.Attributes) > 0 {
+		names := make([]string, 0, len(d.Attributes))
+		for name := range d.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		acc.int64(int64(len(names)))
+		for _, name := range names {
+			acc.string(name)
+			acc.bytes(d.Attributes[name])
+		}
+	}
+
 	return acc.result()
 }
 
+// ErrAttributesTooLarge is returned when the Attributes of a DirEntry
+// exceed MaxAttributesSize.
+var ErrAttributesTooLarge = fmt.Errorf("attributes larger than %d bytes", MaxAttributesSize)
+
+// CheckAttributes returns ErrAttributesTooLarge if the total length of the
+// names and values of attrs exceeds MaxAttributesSize.
+func CheckAttributes(attrs map[string][]byte) error {
+	size := 0
+	for name, value := range attrs {
+		size += len(name) + len(value)
+		if size > MaxAttributesSize {
+			return ErrAttributesTooLarge
+		}
+	}
+	return nil
+}
+
 // ErrTooShort is returned by Unmarshal methods if the data is incomplete.
 var ErrTooShort = errors.New("Unmarshal buffer too short")
 
@@ -396,9 +440,31 @@ func (d *DirEntry) Unmarshal(b []byte) ([]byte, error) {
 		d.Name = PathName(cons.nBytes(length))
 	}
 
-	d.Attr = Attribute(cons.byte())
+	attr := cons.byte()
+	d.Attr = Attribute(attr &^ attrHasAttributes)
 	d.Sequence = cons.int64()
 
+	// Attributes, only if flagged, for compatibility with old entries.
+	d.Attributes = nil
+	if attr&attrHasAttributes != 0 {
+		n := cons.int64()
+		if n <= 0 || n > MaxAttributesSize {
+			return nil, fmt.Errorf("DirEntry has bad attribute count: %d", n)
+		}
+		d.Attributes = make(map[string][]byte, n)
+		for i := int64(0); i < n && cons.err == nil; i++ {
+			name := string(cons.bytes())
+			// Must copy the value - can't return buffer's own contents.
+			value := cons.bytes()
+			d.Attributes[name] = append(make([]byte, 0, len(value)), value...)
+		}
+		if cons.err == nil {
+			if err := CheckAttributes(d.Attributes); err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	return cons.remainder()
 }
 
@@ -448,11 +514,12 @@ func (d *DirEntry) IsIncomplete() bool {
 }
 
 // MarkIncomplete marks this entry as incomplete
-// and zeroes the Blocks and Packdata fields.
+// and zeroes the Blocks, Packdata, and Attributes fields.
 func (d *DirEntry) MarkIncomplete() {
 	d.Attr |= AttrIncomplete
 	d.Blocks = nil
 	d.Packdata = nil
+	d.Attributes = nil
 }
 
 // Copy makes a deep copy of the entry and returns a pointer to the copy.
@@ -466,6 +533,12 @@ func (d *DirEntry) Copy() *DirEntry {
 	for _, b := range d.Blocks {
 		cp.Blocks = append(cp.Blocks, *b.Copy())
 	}
+	if d.Attributes != nil {
+		cp.Attributes = make(map[string][]byte, len(d.Attributes))
+		for name, value := range d.Attributes {
+			cp.Attributes[name] = append([]byte{}, value...)
+		}
+	}
 	return &cp
 }
 
@@ -497,6 +570,8 @@ func (t Transport) String() string {
 		return "inprocess"
 	case Remote:
 		return "remote"
+	case HTTPS:
+		return "https"
 	default:
 		return fmt.Sprintf("transport(%d)", int(t))
 	}
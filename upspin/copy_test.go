@@ -64,6 +64,7 @@ func TestCopyEntry(t *testing.T) {
 		Packing:    EEPack,
 		Blocks:     []DirBlock{*block},
 		Packdata:   []byte(entryPackdata),
+		Attributes: map[string][]byte{"type": []byte("text")},
 		Writer:     writer,
 		Attr:       AttrDirectory,
 		Sequence:   17,
@@ -79,6 +80,8 @@ func TestCopyEntry(t *testing.T) {
 	cp.Name = "foo"
 	cp.Blocks[0].Packdata[0] = 0xff
 	cp.Writer = "Shakespeare"
+	cp.Attributes["type"][0] = 'T'
+	cp.Attributes["new"] = nil
 
 	// Verify original did not change.
 	if string(e.Packdata) != entryPackdata {
@@ -93,4 +96,7 @@ func TestCopyEntry(t *testing.T) {
 	if e.Writer != writer {
 		t.Errorf("got = %s, want = %s", e.Writer, writer)
 	}
+	if len(e.Attributes) != 1 || string(e.Attributes["type"]) != "text" {
+		t.Errorf("got = %q, want = map[type:text]", e.Attributes)
+	}
 }
@@ -14,6 +14,7 @@ func TestParseAndString(t *testing.T) {
 	tests := []string{
 		"remote,localhost:8080",
 		"inprocess",
+		"https,https://cdn.example.com/upspin",
 	}
 	for _, test := range tests {
 		ep, err := ParseEndpoint(test)
@@ -33,6 +34,7 @@ func TestErrorCases(t *testing.T) {
 		endpoint, error string
 	}{
 		{"remote", "requires a netaddr"},
+		{"https", "requires a base URL"},
 		{"supersonic,https://supersonic.com", "unknown transport type"},
 	}
 	for _, test := range tests {
@@ -5,6 +5,7 @@
 package upspin
 
 import (
+	"bytes"
 	"fmt"
 	"reflect"
 	"strings"
@@ -65,6 +66,22 @@ var linkDirEnt = DirEntry{
 	Writer:     "u@foo.com",
 }
 
+var attrDirEnt = DirEntry{
+	Name:       "u@foo.com/a/file",
+	SignedName: "u@foo.com/a/file",
+	Packing:    EEPack,
+	Time:       123456,
+	Blocks:     []DirBlock{dirBlock1},
+	Packdata:   []byte{1, 2, 3, 4},
+	Attributes: map[string][]byte{
+		"content-type": []byte("text/plain"),
+		"empty":        {},
+		"tag":          {0, 0x80, 0xff},
+	},
+	Sequence: 1234,
+	Writer:   "u@foo.com",
+}
+
 var nameCombinations = []DirEntry{
 	// These are nonsense entries, with many empty fields, but it's just a test.
 	{Name: "", SignedName: ""},
@@ -77,6 +94,7 @@ var nameCombinations = []DirEntry{
 func TestDirEntryMarshal(t *testing.T) {
 	testDirEntryMarshal(t, "regular file", &dirEnt)
 	testDirEntryMarshal(t, "link", &linkDirEnt)
+	testDirEntryMarshal(t, "attributes", &attrDirEnt)
 	for _, e := range nameCombinations {
 		testDirEntryMarshal(t, fmt.Sprintf("Name=%q SignedName=%q", e.Name, e.SignedName), &e)
 	}
@@ -140,6 +158,78 @@ func TestDirEntryMarshalClearsBlocks(t *testing.T) {
 	}
 }
 
+// Entries without attributes must marshal as they did before attributes
+// existed, so old entries still unmarshal and their hashes are unchanged.
+func TestDirEntryMarshalCompatible(t *testing.T) {
+	const old = " u@foo.com/a/link\x01\x80\x89\x0f\x00\x00\x1ev@bar.com/b/foo\x12u@foo.com\x01\x02\xa4\x13"
+	data, err := linkDirEnt.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != old {
+		t.Errorf("Marshal = %q, want %q", data, old)
+	}
+	var e DirEntry
+	e.Attributes = map[string][]byte{"stale": nil}
+	if _, err := e.Unmarshal([]byte(old)); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&e, &linkDirEnt) {
+		t.Errorf("bad result. got:\n\t%+v\nwant:\n\t%+v", &e, &linkDirEnt)
+	}
+}
+
+// Attributes must not disturb the decoding of entries that follow,
+// as in a directory block.
+func TestDirEntryMarshalAttributesSequence(t *testing.T) {
+	var data []byte
+	var err error
+	entries := []*DirEntry{&attrDirEnt, &linkDirEnt, &attrDirEnt}
+	for _, e := range entries {
+		data, err = e.MarshalAppend(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, want := range entries {
+		var got DirEntry
+		data, err = got.Unmarshal(data)
+		if err != nil {
+			t.Fatalf("entry %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(&got, want) {
+			t.Errorf("entry %d: got:\n\t%+v\nwant:\n\t%+v", i, &got, want)
+		}
+	}
+	if len(data) != 0 {
+		t.Errorf("data remains after unmarshal")
+	}
+}
+
+func TestDirEntryMarshalAttributesTooLarge(t *testing.T) {
+	e := attrDirEnt
+	e.Attributes = map[string][]byte{
+		"big": make([]byte, MaxAttributesSize-len("big")),
+	}
+	data, err := e.Marshal()
+	if err != nil {
+		t.Fatalf("attributes of maximum size: %v", err)
+	}
+	e.Attributes["x"] = nil
+	if _, err := e.Marshal(); err != ErrAttributesTooLarge {
+		t.Errorf("Marshal of too large attributes: err = %v, want %v", err, ErrAttributesTooLarge)
+	}
+	// Rewrite the value one byte too long; Unmarshal must reject it.
+	i := bytes.Index(data, []byte("\x06big"))
+	acc := accumulator{buf: data[:i]}
+	acc.string("big")
+	acc.bytes(make([]byte, MaxAttributesSize-len("big")+1))
+	var got DirEntry
+	if _, err := got.Unmarshal(acc.buf); err != ErrAttributesTooLarge {
+		t.Errorf("Unmarshal of too large attributes: err = %v, want %v", err, ErrAttributesTooLarge)
+	}
+}
+
 func TestDirBlockMarshal(t *testing.T) {
 	data, err := dirBlock1.Marshal()
 	if err != nil {
@@ -163,6 +163,7 @@ func UpspinUser(user *User) *upspin.User {
 		Dirs:      UpspinEndpoints(user.Dirs),
 		Stores:    UpspinEndpoints(user.Stores),
 		PublicKey: upspin.PublicKey(user.PublicKey),
+		Revoked:   UpspinPublicKeys(user.Revoked),
 	}
 }
 
@@ -173,9 +174,62 @@ func UserProto(user *upspin.User) *User {
 		Dirs:      Endpoints(user.Dirs),
 		Stores:    Endpoints(user.Stores),
 		PublicKey: string(user.PublicKey),
+		Revoked:   PublicKeys(user.Revoked),
 	}
 }
 
+// UnixNano returns t in nanoseconds since the Unix epoch,
+// or zero if t is the zero time.
+func UnixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// UpspinTime converts a time in nanoseconds since the Unix epoch, as
+// returned by UnixNano, to a time.Time. Zero is the zero time.
+func UpspinTime(nsec int64) time.Time {
+	if nsec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nsec)
+}
+
+// UpspinUserVersions converts a slice of proto.UserVersion to a slice of
+// upspin.UserVersion.
+func UpspinUserVersions(v []*UserVersion) []upspin.UserVersion {
+	if v == nil {
+		return nil
+	}
+	uv := make([]upspin.UserVersion, len(v))
+	for i := range v {
+		uv[i] = upspin.UserVersion{
+			User:       *UpspinUser(v[i].User),
+			ValidFrom:  UpspinTime(v[i].ValidFrom),
+			ValidUntil: UpspinTime(v[i].ValidUntil),
+		}
+	}
+	return uv
+}
+
+// UserVersionProtos converts a slice of upspin.UserVersion to a slice of
+// proto.UserVersion.
+func UserVersionProtos(uv []upspin.UserVersion) []*UserVersion {
+	if uv == nil {
+		return nil
+	}
+	v := make([]*UserVersion, len(uv))
+	for i := range uv {
+		v[i] = &UserVersion{
+			User:       UserProto(&uv[i].User),
+			ValidFrom:  UnixNano(uv[i].ValidFrom),
+			ValidUntil: UnixNano(uv[i].ValidUntil),
+		}
+	}
+	return v
+}
+
 // RefdataProto converts an upspin.Refdata to a proto.Refdata.
 func RefdataProto(refdata *upspin.Refdata) *Refdata {
 	if refdata == nil {
@@ -242,6 +296,7 @@ func UpspinEvent(event *Event) (*upspin.Event, error) {
 		Entry:  entry, // may be nil.
 		Delete: event.Delete,
 		Error:  errors.UnmarshalError(event.Error),
+		Root:   upspin.PathName(event.Root),
 	}, nil
 }
 
@@ -270,5 +325,6 @@ func EventProto(event *upspin.Event) (*Event, error) {
 		Entry:  b,
 		Delete: event.Delete,
 		Error:  err,
+		Root:   string(event.Root),
 	}, nil
 }
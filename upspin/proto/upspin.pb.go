@@ -24,6 +24,14 @@ It has these top-level messages:
 	KeyLookupResponse
 	KeyPutRequest
 	KeyPutResponse
+	KeyRevokeRequest
+	KeyRevokeResponse
+	KeyExportRequest
+	KeyExportResponse
+	KeyLookupAtRequest
+	UserVersion
+	KeyHistoryRequest
+	KeyHistoryResponse
 	EntryError
 	EntriesError
 	DirLookupRequest
@@ -31,8 +39,27 @@ It has these top-level messages:
 	DirGlobRequest
 	DirDeleteRequest
 	DirWhichAccessRequest
+	DirWhichAccessBatchRequest
+	DirWhichAccessResult
+	DirWhichAccessBatchResponse
 	DirWatchRequest
+	DirWatchControl
+	DirWatchMultiRequest
+	DirChange
+	DirApplyBatchRequest
 	Event
+	DirReplicaState
+	DirReplicateRequest
+	DirReplicaUpdate
+	DirPrepareRequest
+	DirTxRequest
+	DirAccessHistoryRequest
+	DirAccessRecord
+	DirAccessHistoryResponse
+	KeyTransferDomainRequest
+	KeyTransferDomainResponse
+	KeyImportDomainRequest
+	KeyImportDomainResponse
 */
 package proto
 
@@ -291,6 +318,7 @@ type User struct {
 	Dirs      []*Endpoint `protobuf:"bytes,2,rep,name=dirs" json:"dirs,omitempty"`
 	Stores    []*Endpoint `protobuf:"bytes,3,rep,name=stores" json:"stores,omitempty"`
 	PublicKey string      `protobuf:"bytes,4,opt,name=public_key,json=publicKey" json:"public_key,omitempty"`
+	Revoked   []string    `protobuf:"bytes,5,rep,name=revoked" json:"revoked,omitempty"`
 }
 
 func (m *User) Reset()                    { *m = User{} }
@@ -326,6 +354,13 @@ func (m *User) GetPublicKey() string {
 	return ""
 }
 
+func (m *User) GetRevoked() []string {
+	if m != nil {
+		return m.Revoked
+	}
+	return nil
+}
+
 type KeyLookupRequest struct {
 	UserName string `protobuf:"bytes,1,opt,name=user_name,json=userName" json:"user_name,omitempty"`
 }
@@ -398,6 +433,198 @@ func (m *KeyPutResponse) GetError() []byte {
 	return nil
 }
 
+type KeyRevokeRequest struct {
+	UserName  string `protobuf:"bytes,1,opt,name=user_name,json=userName" json:"user_name,omitempty"`
+	PublicKey string `protobuf:"bytes,2,opt,name=public_key,json=publicKey" json:"public_key,omitempty"`
+	Signature []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *KeyRevokeRequest) Reset()                    { *m = KeyRevokeRequest{} }
+func (m *KeyRevokeRequest) String() string            { return proto1.CompactTextString(m) }
+func (*KeyRevokeRequest) ProtoMessage()               {}
+func (*KeyRevokeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+
+func (m *KeyRevokeRequest) GetUserName() string {
+	if m != nil {
+		return m.UserName
+	}
+	return ""
+}
+
+func (m *KeyRevokeRequest) GetPublicKey() string {
+	if m != nil {
+		return m.PublicKey
+	}
+	return ""
+}
+
+func (m *KeyRevokeRequest) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type KeyRevokeResponse struct {
+	Error []byte `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *KeyRevokeResponse) Reset()                    { *m = KeyRevokeResponse{} }
+func (m *KeyRevokeResponse) String() string            { return proto1.CompactTextString(m) }
+func (*KeyRevokeResponse) ProtoMessage()               {}
+func (*KeyRevokeResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+
+func (m *KeyRevokeResponse) GetError() []byte {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+// KeyExportRequest asks a replicated KeyServer for the user records it
+// has stored since the given time of its logical clock.
+type KeyExportRequest struct {
+	Since uint64 `protobuf:"varint,1,opt,name=since" json:"since,omitempty"`
+}
+
+func (m *KeyExportRequest) Reset()                    { *m = KeyExportRequest{} }
+func (m *KeyExportRequest) String() string            { return proto1.CompactTextString(m) }
+func (*KeyExportRequest) ProtoMessage()               {}
+func (*KeyExportRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+
+func (m *KeyExportRequest) GetSince() uint64 {
+	if m != nil {
+		return m.Since
+	}
+	return 0
+}
+
+// Each of the records is a JSON-encoded repl.Record.
+type KeyExportResponse struct {
+	Records [][]byte `protobuf:"bytes,1,rep,name=records,proto3" json:"records,omitempty"`
+	Error   []byte   `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *KeyExportResponse) Reset()                    { *m = KeyExportResponse{} }
+func (m *KeyExportResponse) String() string            { return proto1.CompactTextString(m) }
+func (*KeyExportResponse) ProtoMessage()               {}
+func (*KeyExportResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+
+func (m *KeyExportResponse) GetRecords() [][]byte {
+	if m != nil {
+		return m.Records
+	}
+	return nil
+}
+
+func (m *KeyExportResponse) GetError() []byte {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+// KeyLookupAtRequest asks for a user's record as it was at the given
+// time, in nanoseconds since the Unix epoch. The reply is a
+// KeyLookupResponse.
+type KeyLookupAtRequest struct {
+	UserName string `protobuf:"bytes,1,opt,name=user_name,json=userName" json:"user_name,omitempty"`
+	Time     int64  `protobuf:"varint,2,opt,name=time" json:"time,omitempty"`
+}
+
+func (m *KeyLookupAtRequest) Reset()                    { *m = KeyLookupAtRequest{} }
+func (m *KeyLookupAtRequest) String() string            { return proto1.CompactTextString(m) }
+func (*KeyLookupAtRequest) ProtoMessage()               {}
+func (*KeyLookupAtRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+
+func (m *KeyLookupAtRequest) GetUserName() string {
+	if m != nil {
+		return m.UserName
+	}
+	return ""
+}
+
+func (m *KeyLookupAtRequest) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+// UserVersion is a version of a user's record. The times are in
+// nanoseconds since the Unix epoch, or zero if unknown or none.
+type UserVersion struct {
+	User       *User `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	ValidFrom  int64 `protobuf:"varint,2,opt,name=valid_from,json=validFrom" json:"valid_from,omitempty"`
+	ValidUntil int64 `protobuf:"varint,3,opt,name=valid_until,json=validUntil" json:"valid_until,omitempty"`
+}
+
+func (m *UserVersion) Reset()                    { *m = UserVersion{} }
+func (m *UserVersion) String() string            { return proto1.CompactTextString(m) }
+func (*UserVersion) ProtoMessage()               {}
+func (*UserVersion) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+
+func (m *UserVersion) GetUser() *User {
+	if m != nil {
+		return m.User
+	}
+	return nil
+}
+
+func (m *UserVersion) GetValidFrom() int64 {
+	if m != nil {
+		return m.ValidFrom
+	}
+	return 0
+}
+
+func (m *UserVersion) GetValidUntil() int64 {
+	if m != nil {
+		return m.ValidUntil
+	}
+	return 0
+}
+
+type KeyHistoryRequest struct {
+	UserName string `protobuf:"bytes,1,opt,name=user_name,json=userName" json:"user_name,omitempty"`
+}
+
+func (m *KeyHistoryRequest) Reset()                    { *m = KeyHistoryRequest{} }
+func (m *KeyHistoryRequest) String() string            { return proto1.CompactTextString(m) }
+func (*KeyHistoryRequest) ProtoMessage()               {}
+func (*KeyHistoryRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+
+func (m *KeyHistoryRequest) GetUserName() string {
+	if m != nil {
+		return m.UserName
+	}
+	return ""
+}
+
+type KeyHistoryResponse struct {
+	Versions []*UserVersion `protobuf:"bytes,1,rep,name=versions" json:"versions,omitempty"`
+	Error    []byte         `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *KeyHistoryResponse) Reset()                    { *m = KeyHistoryResponse{} }
+func (m *KeyHistoryResponse) String() string            { return proto1.CompactTextString(m) }
+func (*KeyHistoryResponse) ProtoMessage()               {}
+func (*KeyHistoryResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+
+func (m *KeyHistoryResponse) GetVersions() []*UserVersion {
+	if m != nil {
+		return m.Versions
+	}
+	return nil
+}
+
+func (m *KeyHistoryResponse) GetError() []byte {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
 type EntryError struct {
 	Entry []byte `protobuf:"bytes,1,opt,name=entry,proto3" json:"entry,omitempty"`
 	Error []byte `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
@@ -406,7 +633,7 @@ type EntryError struct {
 func (m *EntryError) Reset()                    { *m = EntryError{} }
 func (m *EntryError) String() string            { return proto1.CompactTextString(m) }
 func (*EntryError) ProtoMessage()               {}
-func (*EntryError) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{16} }
+func (*EntryError) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
 
 func (m *EntryError) GetEntry() []byte {
 	if m != nil {
@@ -430,7 +657,7 @@ type EntriesError struct {
 func (m *EntriesError) Reset()                    { *m = EntriesError{} }
 func (m *EntriesError) String() string            { return proto1.CompactTextString(m) }
 func (*EntriesError) ProtoMessage()               {}
-func (*EntriesError) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{17} }
+func (*EntriesError) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{25} }
 
 func (m *EntriesError) GetEntries() [][]byte {
 	if m != nil {
@@ -453,7 +680,7 @@ type DirLookupRequest struct {
 func (m *DirLookupRequest) Reset()                    { *m = DirLookupRequest{} }
 func (m *DirLookupRequest) String() string            { return proto1.CompactTextString(m) }
 func (*DirLookupRequest) ProtoMessage()               {}
-func (*DirLookupRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{18} }
+func (*DirLookupRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{26} }
 
 func (m *DirLookupRequest) GetName() string {
 	if m != nil {
@@ -464,12 +691,14 @@ func (m *DirLookupRequest) GetName() string {
 
 type DirPutRequest struct {
 	Entry []byte `protobuf:"bytes,1,opt,name=entry,proto3" json:"entry,omitempty"`
+	// If set, the server uses PutUncheckedLink.
+	SkipLinkCheck bool `protobuf:"varint,2,opt,name=skipLinkCheck" json:"skipLinkCheck,omitempty"`
 }
 
 func (m *DirPutRequest) Reset()                    { *m = DirPutRequest{} }
 func (m *DirPutRequest) String() string            { return proto1.CompactTextString(m) }
 func (*DirPutRequest) ProtoMessage()               {}
-func (*DirPutRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{19} }
+func (*DirPutRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{27} }
 
 func (m *DirPutRequest) GetEntry() []byte {
 	if m != nil {
@@ -478,14 +707,24 @@ func (m *DirPutRequest) GetEntry() []byte {
 	return nil
 }
 
+func (m *DirPutRequest) GetSkipLinkCheck() bool {
+	if m != nil {
+		return m.SkipLinkCheck
+	}
+	return false
+}
+
 type DirGlobRequest struct {
 	Pattern string `protobuf:"bytes,1,opt,name=pattern" json:"pattern,omitempty"`
+	// If shallow is set, the entries are returned without their
+	// blocks and packdata, marked incomplete.
+	Shallow bool `protobuf:"varint,2,opt,name=shallow" json:"shallow,omitempty"`
 }
 
 func (m *DirGlobRequest) Reset()                    { *m = DirGlobRequest{} }
 func (m *DirGlobRequest) String() string            { return proto1.CompactTextString(m) }
 func (*DirGlobRequest) ProtoMessage()               {}
-func (*DirGlobRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{20} }
+func (*DirGlobRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{28} }
 
 func (m *DirGlobRequest) GetPattern() string {
 	if m != nil {
@@ -494,6 +733,13 @@ func (m *DirGlobRequest) GetPattern() string {
 	return ""
 }
 
+func (m *DirGlobRequest) GetShallow() bool {
+	if m != nil {
+		return m.Shallow
+	}
+	return false
+}
+
 type DirDeleteRequest struct {
 	Name string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
 }
@@ -501,7 +747,7 @@ type DirDeleteRequest struct {
 func (m *DirDeleteRequest) Reset()                    { *m = DirDeleteRequest{} }
 func (m *DirDeleteRequest) String() string            { return proto1.CompactTextString(m) }
 func (*DirDeleteRequest) ProtoMessage()               {}
-func (*DirDeleteRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{21} }
+func (*DirDeleteRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{29} }
 
 func (m *DirDeleteRequest) GetName() string {
 	if m != nil {
@@ -517,7 +763,7 @@ type DirWhichAccessRequest struct {
 func (m *DirWhichAccessRequest) Reset()                    { *m = DirWhichAccessRequest{} }
 func (m *DirWhichAccessRequest) String() string            { return proto1.CompactTextString(m) }
 func (*DirWhichAccessRequest) ProtoMessage()               {}
-func (*DirWhichAccessRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{22} }
+func (*DirWhichAccessRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{30} }
 
 func (m *DirWhichAccessRequest) GetName() string {
 	if m != nil {
@@ -526,6 +772,81 @@ func (m *DirWhichAccessRequest) GetName() string {
 	return ""
 }
 
+type DirWhichAccessBatchRequest struct {
+	Names []string `protobuf:"bytes,1,rep,name=names" json:"names,omitempty"`
+}
+
+func (m *DirWhichAccessBatchRequest) Reset()                    { *m = DirWhichAccessBatchRequest{} }
+func (m *DirWhichAccessBatchRequest) String() string            { return proto1.CompactTextString(m) }
+func (*DirWhichAccessBatchRequest) ProtoMessage()               {}
+func (*DirWhichAccessBatchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{31} }
+
+func (m *DirWhichAccessBatchRequest) GetNames() []string {
+	if m != nil {
+		return m.Names
+	}
+	return nil
+}
+
+// The result of WhichAccess for one name in a DirWhichAccessBatchRequest.
+// The entry is the index, plus one, of the Access file's entry in the
+// response's table of entries, or zero if there is no entry.
+type DirWhichAccessResult struct {
+	Entry int32  `protobuf:"varint,1,opt,name=entry" json:"entry,omitempty"`
+	Error []byte `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *DirWhichAccessResult) Reset()                    { *m = DirWhichAccessResult{} }
+func (m *DirWhichAccessResult) String() string            { return proto1.CompactTextString(m) }
+func (*DirWhichAccessResult) ProtoMessage()               {}
+func (*DirWhichAccessResult) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{32} }
+
+func (m *DirWhichAccessResult) GetEntry() int32 {
+	if m != nil {
+		return m.Entry
+	}
+	return 0
+}
+
+func (m *DirWhichAccessResult) GetError() []byte {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type DirWhichAccessBatchResponse struct {
+	Entries [][]byte                `protobuf:"bytes,1,rep,name=entries,proto3" json:"entries,omitempty"`
+	Results []*DirWhichAccessResult `protobuf:"bytes,2,rep,name=results" json:"results,omitempty"`
+	Error   []byte                  `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *DirWhichAccessBatchResponse) Reset()                    { *m = DirWhichAccessBatchResponse{} }
+func (m *DirWhichAccessBatchResponse) String() string            { return proto1.CompactTextString(m) }
+func (*DirWhichAccessBatchResponse) ProtoMessage()               {}
+func (*DirWhichAccessBatchResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{33} }
+
+func (m *DirWhichAccessBatchResponse) GetEntries() [][]byte {
+	if m != nil {
+		return m.Entries
+	}
+	return nil
+}
+
+func (m *DirWhichAccessBatchResponse) GetResults() []*DirWhichAccessResult {
+	if m != nil {
+		return m.Results
+	}
+	return nil
+}
+
+func (m *DirWhichAccessBatchResponse) GetError() []byte {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
 type DirWatchRequest struct {
 	Name     string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
 	Sequence int64  `protobuf:"varint,2,opt,name=sequence" json:"sequence,omitempty"`
@@ -534,7 +855,7 @@ type DirWatchRequest struct {
 func (m *DirWatchRequest) Reset()                    { *m = DirWatchRequest{} }
 func (m *DirWatchRequest) String() string            { return proto1.CompactTextString(m) }
 func (*DirWatchRequest) ProtoMessage()               {}
-func (*DirWatchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{23} }
+func (*DirWatchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{34} }
 
 func (m *DirWatchRequest) GetName() string {
 	if m != nil {
@@ -550,6 +871,115 @@ func (m *DirWatchRequest) GetSequence() int64 {
 	return 0
 }
 
+// DirWatchControl is sent by the client over a bidirectional Watch stream.
+// A message that neither restarts nor filters is a keep-alive ping.
+type DirWatchControl struct {
+	// If restart is set, the watch begins again at sequence.
+	Restart  bool  `protobuf:"varint,1,opt,name=restart" json:"restart,omitempty"`
+	Sequence int64 `protobuf:"varint,2,opt,name=sequence" json:"sequence,omitempty"`
+	// If filter is set, skip_puts and skip_deletes replace the set of
+	// events withheld from the client. Errors are always delivered.
+	Filter      bool `protobuf:"varint,3,opt,name=filter" json:"filter,omitempty"`
+	SkipPuts    bool `protobuf:"varint,4,opt,name=skip_puts,json=skipPuts" json:"skip_puts,omitempty"`
+	SkipDeletes bool `protobuf:"varint,5,opt,name=skip_deletes,json=skipDeletes" json:"skip_deletes,omitempty"`
+}
+
+func (m *DirWatchControl) Reset()                    { *m = DirWatchControl{} }
+func (m *DirWatchControl) String() string            { return proto1.CompactTextString(m) }
+func (*DirWatchControl) ProtoMessage()               {}
+func (*DirWatchControl) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{35} }
+
+func (m *DirWatchControl) GetRestart() bool {
+	if m != nil {
+		return m.Restart
+	}
+	return false
+}
+
+func (m *DirWatchControl) GetSequence() int64 {
+	if m != nil {
+		return m.Sequence
+	}
+	return 0
+}
+
+func (m *DirWatchControl) GetFilter() bool {
+	if m != nil {
+		return m.Filter
+	}
+	return false
+}
+
+func (m *DirWatchControl) GetSkipPuts() bool {
+	if m != nil {
+		return m.SkipPuts
+	}
+	return false
+}
+
+func (m *DirWatchControl) GetSkipDeletes() bool {
+	if m != nil {
+		return m.SkipDeletes
+	}
+	return false
+}
+
+type DirWatchMultiRequest struct {
+	Paths []*DirWatchRequest `protobuf:"bytes,1,rep,name=paths" json:"paths,omitempty"`
+}
+
+func (m *DirWatchMultiRequest) Reset()                    { *m = DirWatchMultiRequest{} }
+func (m *DirWatchMultiRequest) String() string            { return proto1.CompactTextString(m) }
+func (*DirWatchMultiRequest) ProtoMessage()               {}
+func (*DirWatchMultiRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{36} }
+
+func (m *DirWatchMultiRequest) GetPaths() []*DirWatchRequest {
+	if m != nil {
+		return m.Paths
+	}
+	return nil
+}
+
+type DirChange struct {
+	Delete bool   `protobuf:"varint,1,opt,name=delete" json:"delete,omitempty"`
+	Entry  []byte `protobuf:"bytes,2,opt,name=entry,proto3" json:"entry,omitempty"`
+}
+
+func (m *DirChange) Reset()                    { *m = DirChange{} }
+func (m *DirChange) String() string            { return proto1.CompactTextString(m) }
+func (*DirChange) ProtoMessage()               {}
+func (*DirChange) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{37} }
+
+func (m *DirChange) GetDelete() bool {
+	if m != nil {
+		return m.Delete
+	}
+	return false
+}
+
+func (m *DirChange) GetEntry() []byte {
+	if m != nil {
+		return m.Entry
+	}
+	return nil
+}
+
+type DirApplyBatchRequest struct {
+	Changes []*DirChange `protobuf:"bytes,1,rep,name=changes" json:"changes,omitempty"`
+}
+
+func (m *DirApplyBatchRequest) Reset()                    { *m = DirApplyBatchRequest{} }
+func (m *DirApplyBatchRequest) String() string            { return proto1.CompactTextString(m) }
+func (*DirApplyBatchRequest) ProtoMessage()               {}
+func (*DirApplyBatchRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{38} }
+
+func (m *DirApplyBatchRequest) GetChanges() []*DirChange {
+	if m != nil {
+		return m.Changes
+	}
+	return nil
+}
+
 // The first response in the stream is whether dir.Watch succeeded. If it
 // didn't, the error field contains the error and no streaming happens. If it
 // did succeed the error is nil and subsequent streams are from the Events
@@ -559,12 +989,14 @@ type Event struct {
 	Sequence int64  `protobuf:"varint,2,opt,name=sequence" json:"sequence,omitempty"`
 	Delete   bool   `protobuf:"varint,3,opt,name=delete" json:"delete,omitempty"`
 	Error    []byte `protobuf:"bytes,4,opt,name=error,proto3" json:"error,omitempty"`
+	// The name of the watched path, set only by WatchMulti.
+	Root string `protobuf:"bytes,5,opt,name=root" json:"root,omitempty"`
 }
 
 func (m *Event) Reset()                    { *m = Event{} }
 func (m *Event) String() string            { return proto1.CompactTextString(m) }
 func (*Event) ProtoMessage()               {}
-func (*Event) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{24} }
+func (*Event) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{39} }
 
 func (m *Event) GetEntry() []byte {
 	if m != nil {
@@ -594,6 +1026,394 @@ func (m *Event) GetError() []byte {
 	return nil
 }
 
+func (m *Event) GetRoot() string {
+	if m != nil {
+		return m.Root
+	}
+	return ""
+}
+
+// DirReplicaState describes how much of a user's log a standby holds.
+type DirReplicaState struct {
+	User         string `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	Offset       int64  `protobuf:"varint,2,opt,name=offset" json:"offset,omitempty"`
+	LastOffset   int64  `protobuf:"varint,3,opt,name=lastOffset" json:"lastOffset,omitempty"`
+	LastChecksum []byte `protobuf:"bytes,4,opt,name=lastChecksum,proto3" json:"lastChecksum,omitempty"`
+}
+
+func (m *DirReplicaState) Reset()                    { *m = DirReplicaState{} }
+func (m *DirReplicaState) String() string            { return proto1.CompactTextString(m) }
+func (*DirReplicaState) ProtoMessage()               {}
+func (*DirReplicaState) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{40} }
+
+func (m *DirReplicaState) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *DirReplicaState) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *DirReplicaState) GetLastOffset() int64 {
+	if m != nil {
+		return m.LastOffset
+	}
+	return 0
+}
+
+func (m *DirReplicaState) GetLastChecksum() []byte {
+	if m != nil {
+		return m.LastChecksum
+	}
+	return nil
+}
+
+type DirReplicateRequest struct {
+	States []*DirReplicaState `protobuf:"bytes,1,rep,name=states" json:"states,omitempty"`
+}
+
+func (m *DirReplicateRequest) Reset()                    { *m = DirReplicateRequest{} }
+func (m *DirReplicateRequest) String() string            { return proto1.CompactTextString(m) }
+func (*DirReplicateRequest) ProtoMessage()               {}
+func (*DirReplicateRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{41} }
+
+func (m *DirReplicateRequest) GetStates() []*DirReplicaState {
+	if m != nil {
+		return m.States
+	}
+	return nil
+}
+
+// The responses to a DirReplicateRequest stream changes to the logs of
+// the primary's users. If the replication fails to start, the error field
+// of the first response contains the error and no streaming happens.
+type DirReplicaUpdate struct {
+	User       string `protobuf:"bytes,1,opt,name=user" json:"user,omitempty"`
+	Offset     int64  `protobuf:"varint,2,opt,name=offset" json:"offset,omitempty"`
+	Record     []byte `protobuf:"bytes,3,opt,name=record,proto3" json:"record,omitempty"`
+	Root       []byte `protobuf:"bytes,4,opt,name=root,proto3" json:"root,omitempty"`
+	Checkpoint int64  `protobuf:"varint,5,opt,name=checkpoint" json:"checkpoint,omitempty"`
+	Error      []byte `protobuf:"bytes,6,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *DirReplicaUpdate) Reset()                    { *m = DirReplicaUpdate{} }
+func (m *DirReplicaUpdate) String() string            { return proto1.CompactTextString(m) }
+func (*DirReplicaUpdate) ProtoMessage()               {}
+func (*DirReplicaUpdate) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{42} }
+
+func (m *DirReplicaUpdate) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *DirReplicaUpdate) GetOffset() int64 {
+	if m != nil {
+		return m.Offset
+	}
+	return 0
+}
+
+func (m *DirReplicaUpdate) GetRecord() []byte {
+	if m != nil {
+		return m.Record
+	}
+	return nil
+}
+
+func (m *DirReplicaUpdate) GetRoot() []byte {
+	if m != nil {
+		return m.Root
+	}
+	return nil
+}
+
+func (m *DirReplicaUpdate) GetCheckpoint() int64 {
+	if m != nil {
+		return m.Checkpoint
+	}
+	return 0
+}
+
+func (m *DirReplicaUpdate) GetError() []byte {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+// DirPrepareRequest prepares a transaction. The reply is an EntriesError
+// holding only an error, if any.
+type DirPrepareRequest struct {
+	TxId    string       `protobuf:"bytes,1,opt,name=tx_id,json=txId" json:"tx_id,omitempty"`
+	Changes []*DirChange `protobuf:"bytes,2,rep,name=changes" json:"changes,omitempty"`
+}
+
+func (m *DirPrepareRequest) Reset()                    { *m = DirPrepareRequest{} }
+func (m *DirPrepareRequest) String() string            { return proto1.CompactTextString(m) }
+func (*DirPrepareRequest) ProtoMessage()               {}
+func (*DirPrepareRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{43} }
+
+func (m *DirPrepareRequest) GetTxId() string {
+	if m != nil {
+		return m.TxId
+	}
+	return ""
+}
+
+func (m *DirPrepareRequest) GetChanges() []*DirChange {
+	if m != nil {
+		return m.Changes
+	}
+	return nil
+}
+
+// DirTxRequest commits or aborts a prepared transaction. The reply is an
+// EntriesError.
+type DirTxRequest struct {
+	TxId string `protobuf:"bytes,1,opt,name=tx_id,json=txId" json:"tx_id,omitempty"`
+}
+
+func (m *DirTxRequest) Reset()                    { *m = DirTxRequest{} }
+func (m *DirTxRequest) String() string            { return proto1.CompactTextString(m) }
+func (*DirTxRequest) ProtoMessage()               {}
+func (*DirTxRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{44} }
+
+func (m *DirTxRequest) GetTxId() string {
+	if m != nil {
+		return m.TxId
+	}
+	return ""
+}
+
+// DirAccessHistoryRequest asks for the recorded requests concerning a
+// name or anything beneath it. Since is in nanoseconds since the Unix
+// epoch; a limit of zero means the server's maximum.
+type DirAccessHistoryRequest struct {
+	Name  string `protobuf:"bytes,1,opt,name=name" json:"name,omitempty"`
+	Since int64  `protobuf:"varint,2,opt,name=since" json:"since,omitempty"`
+	Limit int32  `protobuf:"varint,3,opt,name=limit" json:"limit,omitempty"`
+}
+
+func (m *DirAccessHistoryRequest) Reset()                    { *m = DirAccessHistoryRequest{} }
+func (m *DirAccessHistoryRequest) String() string            { return proto1.CompactTextString(m) }
+func (*DirAccessHistoryRequest) ProtoMessage()               {}
+func (*DirAccessHistoryRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{45} }
+
+func (m *DirAccessHistoryRequest) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *DirAccessHistoryRequest) GetSince() int64 {
+	if m != nil {
+		return m.Since
+	}
+	return 0
+}
+
+func (m *DirAccessHistoryRequest) GetLimit() int32 {
+	if m != nil {
+		return m.Limit
+	}
+	return 0
+}
+
+// DirAccessRecord is one recorded request. The time is in nanoseconds
+// since the Unix epoch.
+type DirAccessRecord struct {
+	Time    int64  `protobuf:"varint,1,opt,name=time" json:"time,omitempty"`
+	User    string `protobuf:"bytes,2,opt,name=user" json:"user,omitempty"`
+	Op      string `protobuf:"bytes,3,opt,name=op" json:"op,omitempty"`
+	Name    string `protobuf:"bytes,4,opt,name=name" json:"name,omitempty"`
+	Success bool   `protobuf:"varint,5,opt,name=success" json:"success,omitempty"`
+}
+
+func (m *DirAccessRecord) Reset()                    { *m = DirAccessRecord{} }
+func (m *DirAccessRecord) String() string            { return proto1.CompactTextString(m) }
+func (*DirAccessRecord) ProtoMessage()               {}
+func (*DirAccessRecord) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{46} }
+
+func (m *DirAccessRecord) GetTime() int64 {
+	if m != nil {
+		return m.Time
+	}
+	return 0
+}
+
+func (m *DirAccessRecord) GetUser() string {
+	if m != nil {
+		return m.User
+	}
+	return ""
+}
+
+func (m *DirAccessRecord) GetOp() string {
+	if m != nil {
+		return m.Op
+	}
+	return ""
+}
+
+func (m *DirAccessRecord) GetName() string {
+	if m != nil {
+		return m.Name
+	}
+	return ""
+}
+
+func (m *DirAccessRecord) GetSuccess() bool {
+	if m != nil {
+		return m.Success
+	}
+	return false
+}
+
+type DirAccessHistoryResponse struct {
+	Records []*DirAccessRecord `protobuf:"bytes,1,rep,name=records" json:"records,omitempty"`
+	Error   []byte             `protobuf:"bytes,2,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *DirAccessHistoryResponse) Reset()                    { *m = DirAccessHistoryResponse{} }
+func (m *DirAccessHistoryResponse) String() string            { return proto1.CompactTextString(m) }
+func (*DirAccessHistoryResponse) ProtoMessage()               {}
+func (*DirAccessHistoryResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{47} }
+
+func (m *DirAccessHistoryResponse) GetRecords() []*DirAccessRecord {
+	if m != nil {
+		return m.Records
+	}
+	return nil
+}
+
+func (m *DirAccessHistoryResponse) GetError() []byte {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type KeyTransferDomainRequest struct {
+	Domain    string `protobuf:"bytes,1,opt,name=domain" json:"domain,omitempty"`
+	Dest      string `protobuf:"bytes,2,opt,name=dest" json:"dest,omitempty"`
+	Signature []byte `protobuf:"bytes,3,opt,name=signature,proto3" json:"signature,omitempty"`
+}
+
+func (m *KeyTransferDomainRequest) Reset()                    { *m = KeyTransferDomainRequest{} }
+func (m *KeyTransferDomainRequest) String() string            { return proto1.CompactTextString(m) }
+func (*KeyTransferDomainRequest) ProtoMessage()               {}
+func (*KeyTransferDomainRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{48} }
+
+func (m *KeyTransferDomainRequest) GetDomain() string {
+	if m != nil {
+		return m.Domain
+	}
+	return ""
+}
+
+func (m *KeyTransferDomainRequest) GetDest() string {
+	if m != nil {
+		return m.Dest
+	}
+	return ""
+}
+
+func (m *KeyTransferDomainRequest) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type KeyTransferDomainResponse struct {
+	Error []byte `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *KeyTransferDomainResponse) Reset()                    { *m = KeyTransferDomainResponse{} }
+func (m *KeyTransferDomainResponse) String() string            { return proto1.CompactTextString(m) }
+func (*KeyTransferDomainResponse) ProtoMessage()               {}
+func (*KeyTransferDomainResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{49} }
+
+func (m *KeyTransferDomainResponse) GetError() []byte {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
+type KeyImportDomainRequest struct {
+	Domain    string  `protobuf:"bytes,1,opt,name=domain" json:"domain,omitempty"`
+	Dest      string  `protobuf:"bytes,2,opt,name=dest" json:"dest,omitempty"`
+	Admin     *User   `protobuf:"bytes,3,opt,name=admin" json:"admin,omitempty"`
+	Signature []byte  `protobuf:"bytes,4,opt,name=signature,proto3" json:"signature,omitempty"`
+	Users     []*User `protobuf:"bytes,5,rep,name=users" json:"users,omitempty"`
+}
+
+func (m *KeyImportDomainRequest) Reset()                    { *m = KeyImportDomainRequest{} }
+func (m *KeyImportDomainRequest) String() string            { return proto1.CompactTextString(m) }
+func (*KeyImportDomainRequest) ProtoMessage()               {}
+func (*KeyImportDomainRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{50} }
+
+func (m *KeyImportDomainRequest) GetDomain() string {
+	if m != nil {
+		return m.Domain
+	}
+	return ""
+}
+
+func (m *KeyImportDomainRequest) GetDest() string {
+	if m != nil {
+		return m.Dest
+	}
+	return ""
+}
+
+func (m *KeyImportDomainRequest) GetAdmin() *User {
+	if m != nil {
+		return m.Admin
+	}
+	return nil
+}
+
+func (m *KeyImportDomainRequest) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+func (m *KeyImportDomainRequest) GetUsers() []*User {
+	if m != nil {
+		return m.Users
+	}
+	return nil
+}
+
+type KeyImportDomainResponse struct {
+	Error []byte `protobuf:"bytes,1,opt,name=error,proto3" json:"error,omitempty"`
+}
+
+func (m *KeyImportDomainResponse) Reset()                    { *m = KeyImportDomainResponse{} }
+func (m *KeyImportDomainResponse) String() string            { return proto1.CompactTextString(m) }
+func (*KeyImportDomainResponse) ProtoMessage()               {}
+func (*KeyImportDomainResponse) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{51} }
+
+func (m *KeyImportDomainResponse) GetError() []byte {
+	if m != nil {
+		return m.Error
+	}
+	return nil
+}
+
 func init() {
 	proto1.RegisterType((*Endpoint)(nil), "proto.Endpoint")
 	proto1.RegisterType((*Location)(nil), "proto.Location")
@@ -611,6 +1431,14 @@ func init() {
 	proto1.RegisterType((*KeyLookupResponse)(nil), "proto.KeyLookupResponse")
 	proto1.RegisterType((*KeyPutRequest)(nil), "proto.KeyPutRequest")
 	proto1.RegisterType((*KeyPutResponse)(nil), "proto.KeyPutResponse")
+	proto1.RegisterType((*KeyRevokeRequest)(nil), "proto.KeyRevokeRequest")
+	proto1.RegisterType((*KeyRevokeResponse)(nil), "proto.KeyRevokeResponse")
+	proto1.RegisterType((*KeyExportRequest)(nil), "proto.KeyExportRequest")
+	proto1.RegisterType((*KeyExportResponse)(nil), "proto.KeyExportResponse")
+	proto1.RegisterType((*KeyLookupAtRequest)(nil), "proto.KeyLookupAtRequest")
+	proto1.RegisterType((*UserVersion)(nil), "proto.UserVersion")
+	proto1.RegisterType((*KeyHistoryRequest)(nil), "proto.KeyHistoryRequest")
+	proto1.RegisterType((*KeyHistoryResponse)(nil), "proto.KeyHistoryResponse")
 	proto1.RegisterType((*EntryError)(nil), "proto.EntryError")
 	proto1.RegisterType((*EntriesError)(nil), "proto.EntriesError")
 	proto1.RegisterType((*DirLookupRequest)(nil), "proto.DirLookupRequest")
@@ -618,65 +1446,146 @@ func init() {
 	proto1.RegisterType((*DirGlobRequest)(nil), "proto.DirGlobRequest")
 	proto1.RegisterType((*DirDeleteRequest)(nil), "proto.DirDeleteRequest")
 	proto1.RegisterType((*DirWhichAccessRequest)(nil), "proto.DirWhichAccessRequest")
+	proto1.RegisterType((*DirWhichAccessBatchRequest)(nil), "proto.DirWhichAccessBatchRequest")
+	proto1.RegisterType((*DirWhichAccessResult)(nil), "proto.DirWhichAccessResult")
+	proto1.RegisterType((*DirWhichAccessBatchResponse)(nil), "proto.DirWhichAccessBatchResponse")
 	proto1.RegisterType((*DirWatchRequest)(nil), "proto.DirWatchRequest")
+	proto1.RegisterType((*DirWatchControl)(nil), "proto.DirWatchControl")
+	proto1.RegisterType((*DirWatchMultiRequest)(nil), "proto.DirWatchMultiRequest")
+	proto1.RegisterType((*DirChange)(nil), "proto.DirChange")
+	proto1.RegisterType((*DirApplyBatchRequest)(nil), "proto.DirApplyBatchRequest")
 	proto1.RegisterType((*Event)(nil), "proto.Event")
+	proto1.RegisterType((*DirReplicaState)(nil), "proto.DirReplicaState")
+	proto1.RegisterType((*DirReplicateRequest)(nil), "proto.DirReplicateRequest")
+	proto1.RegisterType((*DirReplicaUpdate)(nil), "proto.DirReplicaUpdate")
+	proto1.RegisterType((*DirPrepareRequest)(nil), "proto.DirPrepareRequest")
+	proto1.RegisterType((*DirTxRequest)(nil), "proto.DirTxRequest")
+	proto1.RegisterType((*DirAccessHistoryRequest)(nil), "proto.DirAccessHistoryRequest")
+	proto1.RegisterType((*DirAccessRecord)(nil), "proto.DirAccessRecord")
+	proto1.RegisterType((*DirAccessHistoryResponse)(nil), "proto.DirAccessHistoryResponse")
+	proto1.RegisterType((*KeyTransferDomainRequest)(nil), "proto.KeyTransferDomainRequest")
+	proto1.RegisterType((*KeyTransferDomainResponse)(nil), "proto.KeyTransferDomainResponse")
+	proto1.RegisterType((*KeyImportDomainRequest)(nil), "proto.KeyImportDomainRequest")
+	proto1.RegisterType((*KeyImportDomainResponse)(nil), "proto.KeyImportDomainResponse")
 }
 
 func init() { proto1.RegisterFile("upspin.proto", fileDescriptor0) }
 
 var fileDescriptor0 = []byte{
-	// 839 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x55, 0x5f, 0x8f, 0xdb, 0x44,
-	0x10, 0x8f, 0xcf, 0xf9, 0xe3, 0x4c, 0xd2, 0x4b, 0x6e, 0xdb, 0x2b, 0xae, 0x29, 0x22, 0x5a, 0xd4,
-	0x12, 0x71, 0xa2, 0x3d, 0x42, 0x85, 0xfa, 0x52, 0x20, 0x22, 0xd1, 0x49, 0xa4, 0x42, 0x95, 0x51,
-	0xc5, 0x63, 0xe4, 0x8b, 0xb7, 0x9c, 0xd5, 0xd4, 0x36, 0xeb, 0xf5, 0x49, 0xf9, 0x04, 0x3c, 0xf3,
-	0xc0, 0x87, 0xe1, 0xc3, 0x21, 0xa1, 0x5d, 0xef, 0xae, 0xd7, 0x8e, 0x13, 0x40, 0xf7, 0x74, 0x99,
-	0x9d, 0xdf, 0x6f, 0xe6, 0x37, 0x33, 0x9e, 0x39, 0x18, 0xe6, 0x69, 0x96, 0x46, 0xf1, 0xb3, 0x94,
-	0x26, 0x2c, 0x41, 0x1d, 0xf1, 0x07, 0xff, 0x00, 0xce, 0x32, 0x0e, 0xd3, 0x24, 0x8a, 0x19, 0x7a,
-	0x0c, 0x7d, 0x46, 0x83, 0x38, 0x4b, 0x13, 0xca, 0x5c, 0x6b, 0x62, 0x4d, 0x3b, 0x7e, 0xf9, 0x80,
-	0x1e, 0x81, 0x13, 0x13, 0xb6, 0x0e, 0xc2, 0x90, 0xba, 0x27, 0x13, 0x6b, 0xda, 0xf7, 0x7b, 0x31,
-	0x61, 0xf3, 0x30, 0xa4, 0xf8, 0x2d, 0x38, 0xaf, 0x93, 0x4d, 0xc0, 0xa2, 0x24, 0x46, 0x17, 0xe0,
-	0x10, 0x19, 0x50, 0xc4, 0x18, 0xcc, 0x46, 0x45, 0xc6, 0x67, 0x2a, 0x8f, 0xaf, 0x01, 0x3c, 0x23,
-	0x25, 0xef, 0x08, 0x25, 0xf1, 0x86, 0xc8, 0xa0, 0xe5, 0x03, 0x5e, 0x43, 0xcf, 0x27, 0xef, 0xc2,
-	0x80, 0x05, 0x55, 0xa0, 0x55, 0x03, 0x22, 0x0f, 0x9c, 0xdb, 0x64, 0x1b, 0xb0, 0x68, 0x5b, 0x44,
-	0x71, 0x7c, 0x6d, 0x73, 0x5f, 0x98, 0x53, 0xa1, 0xcd, 0xb5, 0x27, 0xd6, 0xd4, 0xf6, 0xb5, 0x8d,
-	0xcf, 0x60, 0xa4, 0x45, 0x91, 0xdf, 0x72, 0x92, 0x31, 0xfc, 0x1d, 0x8c, 0xcb, 0xa7, 0x2c, 0x4d,
-	0xe2, 0x8c, 0xfc, 0xaf, 0x92, 0xf0, 0x73, 0x18, 0xfd, 0xcc, 0x12, 0x4a, 0xae, 0x88, 0x8a, 0x79,
-	0x5c, 0x3c, 0xfe, 0xd3, 0x82, 0x71, 0xc9, 0x90, 0x29, 0x11, 0xb4, 0x79, 0xdd, 0x02, 0x3d, 0xf4,
-	0xc5, 0x6f, 0x34, 0x85, 0x1e, 0x2d, 0xda, 0x21, 0x8a, 0x1c, 0xcc, 0x4e, 0xa5, 0x0a, 0xd9, 0x24,
-	0x5f, 0xb9, 0xd1, 0x97, 0xd0, 0xdf, 0xca, 0x79, 0x64, 0xae, 0x3d, 0xb1, 0x0d, 0xc5, 0x6a, 0x4e,
-	0x7e, 0x89, 0x40, 0x0f, 0xa0, 0x43, 0x28, 0x4d, 0xa8, 0xdb, 0x16, 0xd9, 0x0a, 0x03, 0x3f, 0x91,
-	0x85, 0xbc, 0xc9, 0x75, 0x21, 0x0d, 0xaa, 0xb0, 0x2f, 0xd5, 0x0b, 0x98, 0x54, 0x6f, 0x28, 0xb5,
-	0x8e, 0x2b, 0xd5, 0xa9, 0x4f, 0xcc, 0xd4, 0x33, 0x40, 0x22, 0xe6, 0x82, 0x6c, 0x09, 0x23, 0xff,
-	0xad, 0x8d, 0x17, 0x70, 0xbf, 0xc2, 0x91, 0x52, 0x74, 0x02, 0xcb, 0x4c, 0xf0, 0xbb, 0x05, 0xed,
-	0xb7, 0x19, 0xa1, 0xbc, 0xa2, 0x38, 0xf8, 0xa0, 0xc2, 0x89, 0xdf, 0xe8, 0x33, 0x68, 0x87, 0x11,
-	0xcd, 0xdc, 0x93, 0x4a, 0xe3, 0xf4, 0xa8, 0x85, 0x13, 0x7d, 0x0e, 0xdd, 0x8c, 0xa7, 0xab, 0xf7,
-	0x57, 0xc3, 0xa4, 0x1b, 0x7d, 0x02, 0x90, 0xe6, 0xd7, 0xdb, 0x68, 0xb3, 0x7e, 0x4f, 0x76, 0xa2,
-	0xc3, 0x7d, 0xbf, 0x5f, 0xbc, 0xac, 0xc8, 0x0e, 0x3f, 0x87, 0xf1, 0x8a, 0xec, 0x5e, 0x27, 0xc9,
-	0xfb, 0x3c, 0x55, 0x85, 0x7e, 0x0c, 0xfd, 0x3c, 0x23, 0x74, 0x6d, 0x28, 0x73, 0xf8, 0xc3, 0x4f,
-	0xc1, 0x07, 0x82, 0x7f, 0x84, 0x33, 0x83, 0x20, 0xab, 0xfc, 0x14, 0xda, 0x1c, 0x20, 0xbb, 0x3d,
-	0x90, 0x5a, 0x78, 0x85, 0xbe, 0x70, 0x1c, 0xe8, 0xf3, 0x25, 0xdc, 0x5b, 0x91, 0x9d, 0x31, 0xe0,
-	0x7f, 0x8b, 0x83, 0x9f, 0xc2, 0xa9, 0x62, 0x1c, 0x6d, 0xf0, 0x4b, 0x80, 0x65, 0xcc, 0xe8, 0x6e,
-	0xc9, 0x2d, 0x81, 0xe1, 0x96, 0xc6, 0x70, 0xe3, 0x80, 0xa6, 0x6f, 0x61, 0xc8, 0x99, 0x11, 0xc9,
-	0x0a, 0xae, 0x0b, 0x3d, 0x52, 0xd8, 0xae, 0x35, 0xb1, 0xa7, 0x43, 0x5f, 0x99, 0x07, 0xf8, 0x4f,
-	0x61, 0xbc, 0x88, 0x68, 0xb5, 0xa1, 0x0d, 0x53, 0xc6, 0x4f, 0xe0, 0xde, 0x22, 0xa2, 0x46, 0xed,
-	0x8d, 0x22, 0xf1, 0x17, 0x70, 0xba, 0x88, 0xe8, 0xd5, 0x36, 0xb9, 0x56, 0x38, 0x17, 0x7a, 0x69,
-	0xc0, 0x18, 0xa1, 0xb1, 0x8c, 0xa7, 0x4c, 0x99, 0xba, 0xfa, 0xd1, 0x36, 0xa5, 0xbe, 0x80, 0xf3,
-	0x45, 0x44, 0x7f, 0xb9, 0x89, 0x36, 0x37, 0xf3, 0xcd, 0x86, 0x64, 0xd9, 0x31, 0xf0, 0x1c, 0x46,
-	0x1c, 0x1c, 0xb0, 0xcd, 0xcd, 0x11, 0x18, 0x3f, 0x73, 0x19, 0x77, 0xab, 0x43, 0x6a, 0xfb, 0xda,
-	0xc6, 0xbf, 0x42, 0x67, 0x79, 0x4b, 0xe2, 0x03, 0x25, 0x1e, 0xa3, 0xa2, 0x87, 0xd0, 0x0d, 0x45,
-	0x3d, 0xe2, 0x76, 0x3a, 0xbe, 0xb4, 0x9a, 0x4f, 0xc6, 0xec, 0x6f, 0x0b, 0x3a, 0x62, 0x09, 0xd1,
-	0x2b, 0xe3, 0xdf, 0xca, 0xc3, 0xfa, 0x6a, 0x14, 0x65, 0x78, 0x1f, 0xed, 0xbd, 0x17, 0x9f, 0x14,
-	0x6e, 0xa1, 0x97, 0x60, 0x5f, 0x91, 0x92, 0x59, 0x3b, 0xa8, 0x9a, 0x59, 0x3f, 0x9b, 0x05, 0xf3,
-	0x4d, 0x5e, 0x63, 0x96, 0x43, 0xae, 0x32, 0x8d, 0xcf, 0x18, 0xb7, 0xd0, 0x1c, 0xba, 0xc5, 0xe8,
-	0xd0, 0x23, 0x13, 0x54, 0x19, 0xa7, 0xe7, 0x35, 0xb9, 0x54, 0x88, 0xd9, 0x5f, 0x16, 0xd8, 0x2b,
-	0xb2, 0xbb, 0x6b, 0xf5, 0xaf, 0xa0, 0x5b, 0x7c, 0xbf, 0x48, 0x81, 0xea, 0x27, 0xc2, 0x73, 0xf7,
-	0x1d, 0x9a, 0xfe, 0xa2, 0x68, 0xc1, 0x83, 0x12, 0x62, 0x34, 0xe0, 0xbc, 0xf6, 0xaa, 0xb5, 0xff,
-	0x61, 0x83, 0xbd, 0x88, 0xe8, 0x5d, 0xb5, 0x7f, 0xb3, 0xa7, 0xbd, 0xbe, 0x8d, 0xde, 0x99, 0x66,
-	0xab, 0x03, 0x81, 0x5b, 0xe8, 0xb2, 0x2a, 0xba, 0xb2, 0x9a, 0xcd, 0x8c, 0x17, 0xd0, 0xe6, 0x6b,
-	0x89, 0xce, 0x4b, 0x8a, 0xb1, 0xa6, 0xde, 0x7d, 0x83, 0xa3, 0x8e, 0x49, 0xa1, 0x4f, 0x4e, 0xd9,
-	0xd0, 0x57, 0x9d, 0x71, 0x63, 0xb6, 0xef, 0x61, 0x60, 0x2c, 0x2c, 0x7a, 0x5c, 0x92, 0xf7, 0xf7,
-	0xb8, 0x39, 0xc2, 0x57, 0xd0, 0x11, 0x5b, 0xac, 0xbb, 0x5a, 0x5b, 0x6b, 0x6f, 0xa8, 0x58, 0x7c,
-	0x57, 0x71, 0xeb, 0xd2, 0xba, 0xee, 0x8a, 0x87, 0xaf, 0xff, 0x09, 0x00, 0x00, 0xff, 0xff, 0xe7,
-	0x95, 0x7f, 0xb4, 0xba, 0x09, 0x00, 0x00,
+	// 1832 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xa4, 0x58, 0x5b, 0x73, 0xdc, 0x48,
+	0x15, 0xce, 0xdc, 0x3c, 0x33, 0x67, 0x26, 0xb1, 0xdd, 0x76, 0x6c, 0x59, 0xb9, 0x39, 0xbd, 0xb0,
+	0x18, 0x02, 0x89, 0xd7, 0xec, 0x52, 0x4b, 0x51, 0x81, 0x38, 0x1e, 0x13, 0x16, 0x07, 0x36, 0xa5,
+	0xd8, 0x50, 0xc0, 0x83, 0x4b, 0x1e, 0xb5, 0xd7, 0x5d, 0xd6, 0x48, 0xa2, 0xd5, 0xf2, 0x7a, 0x8a,
+	0x27, 0xaa, 0xf8, 0x0b, 0x3c, 0x03, 0x6f, 0xfc, 0x01, 0xfe, 0x15, 0x7f, 0x81, 0x2a, 0xaa, 0x6f,
+	0x52, 0x4b, 0xa3, 0x19, 0x3b, 0xbb, 0x4f, 0x9e, 0xd3, 0xe7, 0xd2, 0xdf, 0xb9, 0xf4, 0xd1, 0x39,
+	0x86, 0x61, 0x96, 0xa4, 0x09, 0x8d, 0x9e, 0x27, 0x2c, 0xe6, 0x31, 0xea, 0xc8, 0x3f, 0xf8, 0x00,
+	0x7a, 0x87, 0x51, 0x90, 0xc4, 0x34, 0xe2, 0xe8, 0x21, 0xf4, 0x39, 0xf3, 0xa3, 0x34, 0x89, 0x19,
+	0x77, 0x1a, 0xdb, 0x8d, 0x9d, 0x8e, 0x57, 0x1c, 0xa0, 0x2d, 0xe8, 0x45, 0x84, 0x9f, 0xfa, 0x41,
+	0xc0, 0x9c, 0xe6, 0x76, 0x63, 0xa7, 0xef, 0x75, 0x23, 0xc2, 0xf7, 0x83, 0x80, 0xe1, 0x13, 0xe8,
+	0xbd, 0x8d, 0xc7, 0x3e, 0xa7, 0x71, 0x84, 0x9e, 0x41, 0x8f, 0x68, 0x83, 0xd2, 0xc6, 0x60, 0x6f,
+	0x59, 0xdd, 0xf8, 0xdc, 0xdc, 0xe3, 0xf5, 0x88, 0x75, 0x23, 0x23, 0xe7, 0x84, 0x91, 0x68, 0x4c,
+	0xb4, 0xd1, 0xe2, 0x00, 0x9f, 0x42, 0xd7, 0x23, 0xe7, 0x81, 0xcf, 0xfd, 0xb2, 0x60, 0xa3, 0x22,
+	0x88, 0x5c, 0xe8, 0x5d, 0xc5, 0xa1, 0xcf, 0x69, 0xa8, 0xac, 0xf4, 0xbc, 0x9c, 0x16, 0xbc, 0x20,
+	0x63, 0x12, 0x9b, 0xd3, 0xda, 0x6e, 0xec, 0xb4, 0xbc, 0x9c, 0xc6, 0xab, 0xb0, 0x9c, 0x83, 0x22,
+	0x7f, 0xce, 0x48, 0xca, 0xf1, 0x2f, 0x60, 0xa5, 0x38, 0x4a, 0x93, 0x38, 0x4a, 0xc9, 0x07, 0xb9,
+	0x84, 0x5f, 0xc0, 0xf2, 0x7b, 0x1e, 0x33, 0xf2, 0x86, 0x18, 0x9b, 0x8b, 0xc1, 0xe3, 0xbf, 0x37,
+	0x60, 0xa5, 0xd0, 0xd0, 0x57, 0x22, 0x68, 0x0b, 0xbf, 0xa5, 0xf4, 0xd0, 0x93, 0xbf, 0xd1, 0x0e,
+	0x74, 0x99, 0x0a, 0x87, 0x74, 0x72, 0xb0, 0x77, 0x4f, 0xa3, 0xd0, 0x41, 0xf2, 0x0c, 0x1b, 0xfd,
+	0x08, 0xfa, 0xa1, 0xce, 0x47, 0xea, 0xb4, 0xb6, 0x5b, 0x16, 0x62, 0x93, 0x27, 0xaf, 0x90, 0x40,
+	0xeb, 0xd0, 0x21, 0x8c, 0xc5, 0xcc, 0x69, 0xcb, 0xdb, 0x14, 0x81, 0xbf, 0xab, 0x1d, 0x79, 0x97,
+	0xe5, 0x8e, 0xd4, 0xa0, 0xc2, 0x1e, 0xac, 0x14, 0x62, 0x1a, 0xbd, 0x85, 0xb4, 0xb1, 0x18, 0x69,
+	0x7e, 0x75, 0xd3, 0xbe, 0x7a, 0x0f, 0x90, 0xb4, 0x39, 0x22, 0x21, 0xe1, 0xe4, 0x76, 0x61, 0x7c,
+	0x06, 0x6b, 0x25, 0x1d, 0x0d, 0x25, 0xbf, 0xa0, 0x61, 0x5f, 0xf0, 0xaf, 0x06, 0xb4, 0x4f, 0x52,
+	0xc2, 0x84, 0x47, 0x91, 0x3f, 0x31, 0xe6, 0xe4, 0x6f, 0xf4, 0x11, 0xb4, 0x03, 0xca, 0x52, 0xa7,
+	0xb9, 0xdd, 0xaa, 0x4b, 0xb5, 0x64, 0xa2, 0xef, 0xc1, 0x52, 0x2a, 0xae, 0xab, 0xc6, 0x37, 0x17,
+	0xd3, 0x6c, 0xf4, 0x08, 0x20, 0xc9, 0xce, 0x42, 0x3a, 0x3e, 0xbd, 0x24, 0x53, 0x19, 0xe1, 0xbe,
+	0xd7, 0x57, 0x27, 0x47, 0x64, 0x8a, 0x1c, 0x11, 0xaa, 0xab, 0xf8, 0x92, 0x04, 0x4e, 0x67, 0xbb,
+	0x25, 0x1e, 0x95, 0x26, 0xf1, 0x0b, 0x58, 0x39, 0x22, 0xd3, 0xb7, 0x71, 0x7c, 0x99, 0x25, 0x26,
+	0x04, 0x0f, 0xa0, 0x9f, 0xa5, 0x84, 0x9d, 0x5a, 0x98, 0x7b, 0xe2, 0xe0, 0xb7, 0xfe, 0x84, 0xe0,
+	0x5f, 0xc3, 0xaa, 0xa5, 0xa0, 0xfd, 0x7f, 0x02, 0x6d, 0x21, 0xa0, 0xf3, 0x30, 0xd0, 0x28, 0x85,
+	0xef, 0x9e, 0x64, 0xcc, 0xc9, 0xc0, 0x2e, 0xdc, 0x3d, 0x22, 0x53, 0x2b, 0xf5, 0x37, 0xd9, 0xc1,
+	0x1f, 0xc3, 0x3d, 0xa3, 0xb1, 0x30, 0xf4, 0xa1, 0x74, 0xcb, 0x93, 0x4e, 0xde, 0xc6, 0xad, 0x4a,
+	0x00, 0x9b, 0xd5, 0x00, 0x3e, 0x84, 0x7e, 0x4a, 0xbf, 0x8a, 0x7c, 0x9e, 0x31, 0x22, 0x1f, 0xf8,
+	0xd0, 0x2b, 0x0e, 0xf0, 0xf7, 0x61, 0xd5, 0xba, 0x6d, 0x21, 0xb0, 0x1d, 0x09, 0xec, 0xf0, 0x5a,
+	0x34, 0x3b, 0x03, 0x6c, 0x1d, 0x3a, 0x29, 0x35, 0xe5, 0xd6, 0xf6, 0x14, 0x81, 0x0f, 0x60, 0xd5,
+	0x92, 0xd4, 0x46, 0x65, 0x22, 0xc7, 0x31, 0x0b, 0x52, 0xa7, 0xb1, 0xdd, 0xda, 0x19, 0x7a, 0x86,
+	0x9c, 0x13, 0xe1, 0x43, 0x40, 0x79, 0xb6, 0xf6, 0xf9, 0xad, 0x22, 0x81, 0xa0, 0xcd, 0xe9, 0x44,
+	0xb5, 0xb8, 0x96, 0x27, 0x7f, 0xe3, 0x08, 0x06, 0x22, 0x09, 0xbf, 0x23, 0x2c, 0x15, 0xdd, 0xf7,
+	0xc6, 0x74, 0x3f, 0x02, 0xb8, 0xf2, 0x43, 0x1a, 0x9c, 0x9e, 0xb3, 0x78, 0xa2, 0x2d, 0xf5, 0xe5,
+	0xc9, 0x2f, 0x59, 0x3c, 0x41, 0x4f, 0x60, 0xa0, 0xd8, 0x59, 0xc4, 0x69, 0xa8, 0x1b, 0xa6, 0xd2,
+	0x38, 0x11, 0x27, 0x78, 0x57, 0xfa, 0xfe, 0x2b, 0x2a, 0xaa, 0x7b, 0x7a, 0xab, 0xb2, 0xfc, 0x23,
+	0x20, 0x5b, 0x43, 0x87, 0xeb, 0x39, 0xf4, 0xae, 0x14, 0x66, 0x15, 0xaf, 0xc1, 0x1e, 0xb2, 0xc0,
+	0x6a, 0x77, 0xbc, 0x5c, 0x66, 0x4e, 0x10, 0x3f, 0x07, 0x38, 0x8c, 0x38, 0x9b, 0x1e, 0x0a, 0x4a,
+	0xca, 0x08, 0x2a, 0xcf, 0xab, 0x20, 0xe6, 0x68, 0xfe, 0x1c, 0x86, 0x42, 0x93, 0x92, 0x54, 0xe9,
+	0x3a, 0xd0, 0x25, 0x8a, 0x36, 0xe9, 0xd3, 0xe4, 0x1c, 0xfd, 0x8f, 0x61, 0x65, 0x44, 0x59, 0xf9,
+	0x75, 0xd6, 0x34, 0x13, 0x7c, 0x04, 0x77, 0x47, 0x94, 0x59, 0x0f, 0xa9, 0x1e, 0xe4, 0x77, 0xe0,
+	0x6e, 0x7a, 0x49, 0x93, 0xb7, 0x34, 0xba, 0x3c, 0xb8, 0x20, 0xe3, 0x4b, 0xfd, 0x19, 0x2b, 0x1f,
+	0xe2, 0x11, 0xdc, 0x1b, 0x51, 0xf6, 0x26, 0x8c, 0xcf, 0x8c, 0x35, 0x07, 0xba, 0x89, 0xcf, 0x39,
+	0x61, 0x91, 0xbe, 0xd5, 0x90, 0x82, 0x93, 0x5e, 0xf8, 0x61, 0x18, 0x7f, 0xad, 0x6d, 0x19, 0x52,
+	0x43, 0x2f, 0xf7, 0xd6, 0x3a, 0xe8, 0xcf, 0xe0, 0xfe, 0x88, 0xb2, 0xdf, 0x5f, 0xd0, 0xf1, 0xc5,
+	0xfe, 0x78, 0x4c, 0xd2, 0x74, 0x91, 0xf0, 0x1e, 0xb8, 0x65, 0xe1, 0xd7, 0x3e, 0x1f, 0x5f, 0x58,
+	0x4e, 0x0b, 0x29, 0x15, 0xdb, 0xbe, 0xa7, 0x08, 0xfc, 0x1a, 0xd6, 0xab, 0x17, 0xa4, 0x59, 0x58,
+	0x09, 0x51, 0x67, 0x71, 0x1e, 0xff, 0xd6, 0x80, 0x07, 0xb5, 0x17, 0x17, 0xcf, 0x72, 0x4e, 0x5e,
+	0x3f, 0x13, 0x0f, 0x56, 0xdc, 0x67, 0x3a, 0xfd, 0x03, 0x5d, 0x80, 0x75, 0x98, 0x3c, 0x23, 0x5b,
+	0xc0, 0x68, 0xd9, 0x30, 0xf6, 0x61, 0x59, 0xa8, 0xd9, 0x3e, 0xd7, 0x7d, 0x5a, 0x5c, 0xe8, 0xa5,
+	0x82, 0x6d, 0xc6, 0x9d, 0x96, 0x97, 0xd3, 0xf8, 0x1f, 0x8d, 0xc2, 0xc6, 0x41, 0x1c, 0x71, 0x16,
+	0x87, 0xaa, 0xa9, 0xa4, 0xdc, 0xd7, 0xf3, 0x58, 0xcf, 0x33, 0xe4, 0x22, 0x4b, 0x68, 0x03, 0x96,
+	0xce, 0x69, 0xc8, 0x89, 0xc2, 0xd8, 0xf3, 0x34, 0x25, 0x9e, 0xa9, 0xa8, 0xa7, 0xd3, 0x24, 0xe3,
+	0xa9, 0xfc, 0x12, 0xf5, 0xbc, 0x9e, 0x38, 0x78, 0x97, 0xf1, 0x14, 0x3d, 0x85, 0xa1, 0x64, 0x06,
+	0xb2, 0x2e, 0x52, 0xa7, 0x23, 0xf9, 0x03, 0x71, 0xa6, 0x4a, 0x25, 0xc5, 0x23, 0x58, 0x37, 0x00,
+	0x7f, 0x93, 0x85, 0x9c, 0x1a, 0x4f, 0x7f, 0x08, 0x9d, 0xc4, 0xe7, 0x17, 0xe6, 0x21, 0x6f, 0x58,
+	0x71, 0xb4, 0x02, 0xe2, 0x29, 0x21, 0xfc, 0x53, 0xe8, 0x8f, 0x28, 0x3b, 0xb8, 0xf0, 0xa3, 0xaf,
+	0x24, 0x54, 0x75, 0xa1, 0xf6, 0x4f, 0x53, 0x45, 0x09, 0x34, 0xad, 0x57, 0xa2, 0x0b, 0x66, 0x3f,
+	0x49, 0xc2, 0x69, 0xa9, 0xbc, 0x7e, 0x00, 0xdd, 0xb1, 0xb4, 0x67, 0x20, 0xac, 0x14, 0x10, 0xd4,
+	0x45, 0x9e, 0x11, 0xc0, 0x7f, 0x81, 0xce, 0xe1, 0x15, 0x89, 0xe6, 0x3d, 0xc4, 0x1b, 0xe2, 0xaa,
+	0xc1, 0xb6, 0x66, 0xc0, 0xce, 0xcc, 0x4f, 0x22, 0xff, 0x2c, 0x8e, 0xb9, 0x0c, 0x64, 0xdf, 0x93,
+	0xbf, 0xf1, 0x5f, 0x55, 0x8e, 0x3d, 0x92, 0x84, 0x74, 0xec, 0xbf, 0xe7, 0x3e, 0x97, 0x5d, 0x3d,
+	0x6f, 0xd9, 0x7d, 0xdd, 0xa5, 0x37, 0x60, 0x29, 0x3e, 0x3f, 0x4f, 0x09, 0xd7, 0x18, 0x34, 0x85,
+	0x1e, 0x03, 0x84, 0x7e, 0xca, 0xbf, 0x54, 0x3c, 0xdd, 0x9d, 0x8b, 0x13, 0x84, 0x61, 0x28, 0x28,
+	0xd9, 0x2d, 0xd2, 0x6c, 0xa2, 0x01, 0x95, 0xce, 0xf0, 0x21, 0xac, 0x15, 0x10, 0x8a, 0x0e, 0xf0,
+	0x5c, 0x0c, 0x34, 0x3e, 0x27, 0x35, 0x59, 0xb4, 0xe1, 0x7a, 0x5a, 0x0a, 0xff, 0xb3, 0x01, 0x2b,
+	0x05, 0xef, 0x24, 0x09, 0x3e, 0xd4, 0x97, 0x0d, 0x58, 0x52, 0x5f, 0x48, 0xfd, 0x92, 0x34, 0x95,
+	0xc7, 0x4d, 0x61, 0x97, 0xbf, 0x85, 0xdf, 0x63, 0x81, 0x5f, 0xcd, 0xe0, 0x1d, 0xe5, 0x77, 0x71,
+	0x52, 0x64, 0x60, 0xc9, 0x7e, 0x94, 0xc7, 0xb0, 0x2a, 0x7a, 0x2f, 0x23, 0x89, 0xcf, 0x72, 0x3f,
+	0xd7, 0xa0, 0xc3, 0xaf, 0x4f, 0x69, 0x60, 0x30, 0xf2, 0xeb, 0x2f, 0x02, 0xbb, 0x80, 0x9a, 0x37,
+	0x15, 0xd0, 0x47, 0x30, 0x1c, 0x51, 0x76, 0x7c, 0xbd, 0xc8, 0x20, 0xfe, 0x03, 0x6c, 0x8a, 0x4a,
+	0x95, 0x1d, 0xa4, 0xf2, 0xb1, 0xac, 0xeb, 0x0b, 0xf9, 0x9c, 0xa1, 0x42, 0xa4, 0x08, 0x71, 0x1a,
+	0xd2, 0x09, 0x55, 0x89, 0xee, 0x78, 0x8a, 0xc0, 0x5f, 0xc3, 0x72, 0x6e, 0xda, 0xcb, 0x43, 0xc6,
+	0xa9, 0x36, 0xa9, 0x07, 0x83, 0x3c, 0x15, 0x4d, 0x2b, 0x15, 0xf7, 0xa0, 0x19, 0x27, 0xd2, 0x5a,
+	0xdf, 0x6b, 0xc6, 0x49, 0x0e, 0xa5, 0x6d, 0x41, 0x11, 0xdf, 0x8d, 0x4c, 0x1a, 0xd7, 0x2d, 0xc0,
+	0x90, 0xf8, 0x0c, 0x9c, 0x59, 0x9f, 0x74, 0x9b, 0xdd, 0x2d, 0x4f, 0x3f, 0xa5, 0xf2, 0xb1, 0xa1,
+	0xde, 0x34, 0x15, 0x05, 0xe0, 0x1c, 0x91, 0xe9, 0xb1, 0x58, 0x3a, 0xcf, 0x09, 0x1b, 0xc5, 0x13,
+	0x9f, 0x46, 0x26, 0x70, 0xe2, 0xf9, 0xc9, 0x03, 0x1d, 0x3a, 0x4d, 0xc9, 0xad, 0x84, 0xa4, 0xdc,
+	0x78, 0x1a, 0xe8, 0x5d, 0x61, 0xc1, 0x54, 0xf8, 0x09, 0x6c, 0xd5, 0xdc, 0xb2, 0x70, 0x3a, 0xfc,
+	0x77, 0x03, 0x36, 0x8e, 0xc8, 0xf4, 0x8b, 0x49, 0x12, 0x33, 0xfe, 0xcd, 0x71, 0x3d, 0x85, 0x8e,
+	0x1f, 0x4c, 0xa8, 0x5a, 0x45, 0x2b, 0x03, 0x9a, 0xe2, 0x94, 0xa1, 0xb7, 0x2b, 0xd0, 0x85, 0x01,
+	0x91, 0xca, 0x54, 0x6e, 0x0b, 0x55, 0x03, 0x92, 0x83, 0x5f, 0xc0, 0xe6, 0x0c, 0xd2, 0x45, 0xbe,
+	0xed, 0xfd, 0xaf, 0x01, 0x1d, 0xb9, 0x3b, 0xa1, 0x97, 0xd6, 0x7f, 0x03, 0x36, 0xaa, 0x1b, 0x8d,
+	0x72, 0xd7, 0xdd, 0x9c, 0x39, 0x57, 0xc6, 0xf1, 0x1d, 0xf4, 0x39, 0xb4, 0xde, 0x90, 0x42, 0xb3,
+	0xb2, 0x07, 0xbb, 0x9b, 0x33, 0xe7, 0xb6, 0xe6, 0xbb, 0xac, 0xa2, 0xf9, 0x2e, 0xab, 0xd7, 0xb4,
+	0x76, 0x0c, 0x7c, 0x07, 0xed, 0xc3, 0x92, 0xfa, 0x3e, 0xa1, 0x2d, 0x5b, 0xa8, 0x34, 0xde, 0xb8,
+	0x6e, 0x1d, 0xcb, 0x98, 0xd8, 0xfb, 0x6f, 0x1b, 0x5a, 0x62, 0x95, 0xf8, 0x96, 0xde, 0xbf, 0x84,
+	0x25, 0x35, 0x0f, 0x22, 0x23, 0x54, 0xdd, 0xdf, 0x5c, 0x67, 0x96, 0x91, 0xab, 0x7f, 0xaa, 0x42,
+	0xb0, 0x5e, 0x88, 0x58, 0x01, 0xb8, 0x5f, 0x39, 0xcd, 0xb5, 0x5e, 0x41, 0x5f, 0x6d, 0x37, 0xc2,
+	0x01, 0xeb, 0xde, 0xd2, 0x82, 0xe5, 0x3a, 0xb3, 0x8c, 0xdc, 0xc2, 0x6b, 0x18, 0xa8, 0x55, 0xe6,
+	0xbd, 0x6c, 0x3a, 0x96, 0x8d, 0xd2, 0x2e, 0xe4, 0x3a, 0xb3, 0x0c, 0x2b, 0x09, 0x3d, 0xb3, 0xc9,
+	0xa0, 0xad, 0xaa, 0x8f, 0xfb, 0xfc, 0x36, 0xee, 0xbf, 0x82, 0xae, 0x6e, 0x2a, 0xc8, 0x12, 0x2b,
+	0xf7, 0x4e, 0x77, 0xab, 0x86, 0x93, 0x5b, 0x38, 0x81, 0x7b, 0xe5, 0x27, 0x8d, 0x9e, 0x14, 0xe2,
+	0xb5, 0x2d, 0xc5, 0xdd, 0x9e, 0x2f, 0x90, 0x9b, 0xfd, 0x12, 0x86, 0xf6, 0x5b, 0x42, 0x8f, 0x0a,
+	0x9d, 0x9a, 0x6e, 0xe0, 0x3e, 0x9e, 0xc7, 0xce, 0xcb, 0xed, 0x3f, 0x5d, 0x68, 0x8d, 0x28, 0xfb,
+	0xb6, 0xe5, 0xf6, 0x93, 0x99, 0x72, 0xab, 0x2e, 0x24, 0xee, 0x6a, 0xae, 0x6d, 0x76, 0x24, 0x7c,
+	0x07, 0xed, 0x96, 0xeb, 0xac, 0xb4, 0x9d, 0xd4, 0x6b, 0x7c, 0x0a, 0x6d, 0xb1, 0x73, 0xa0, 0xfb,
+	0x85, 0x8a, 0xb5, 0x83, 0xb8, 0x6b, 0x96, 0x8e, 0xd9, 0xa7, 0x14, 0x3e, 0xfd, 0x30, 0x2d, 0x7c,
+	0xe5, 0x67, 0x59, 0x7b, 0xdb, 0x2b, 0x18, 0x58, 0xe3, 0x37, 0x7a, 0x38, 0x67, 0x2a, 0x5f, 0x60,
+	0xe1, 0x4f, 0xb0, 0x52, 0xdd, 0x07, 0xd0, 0xd3, 0x5a, 0x33, 0xf6, 0x14, 0xe9, 0xe2, 0x45, 0x22,
+	0x79, 0xd8, 0x3f, 0x81, 0x8e, 0x9c, 0x6a, 0xd1, 0x9c, 0x31, 0xd7, 0x1d, 0x1a, 0x48, 0x62, 0xca,
+	0xc4, 0x77, 0x76, 0x1b, 0xe8, 0x67, 0x00, 0xc5, 0xd0, 0x8c, 0x1e, 0x54, 0xf4, 0xec, 0x51, 0xba,
+	0x46, 0xf9, 0x15, 0x40, 0x31, 0xf0, 0xda, 0xca, 0x33, 0x63, 0xf0, 0xbc, 0x44, 0x8c, 0xa0, 0xaf,
+	0xa7, 0x34, 0x4e, 0x90, 0x3b, 0x33, 0xd6, 0x15, 0xe9, 0xd8, 0x9c, 0xe1, 0xa9, 0xb1, 0x4e, 0xe2,
+	0x78, 0x09, 0xa0, 0x27, 0x29, 0x51, 0x3d, 0x8e, 0x55, 0x3d, 0xa5, 0xf9, 0x6a, 0x7e, 0x35, 0xf4,
+	0x0e, 0xe2, 0xc9, 0x84, 0xf2, 0xe3, 0x6b, 0xb4, 0x56, 0x28, 0x1f, 0x5f, 0xdf, 0xa0, 0xf7, 0x19,
+	0x74, 0xf7, 0xcf, 0x62, 0xf6, 0xa1, 0x6a, 0x1e, 0xdc, 0x2d, 0x0d, 0x2a, 0xe8, 0x71, 0x75, 0x1e,
+	0xa9, 0x74, 0x96, 0x27, 0x73, 0xf9, 0x26, 0xf3, 0x67, 0x4b, 0x52, 0xe2, 0xc7, 0xff, 0x1f, 0x00,
+	0x61, 0x1b, 0xee, 0x34, 0x48, 0x17, 0x00, 0x00,
 }
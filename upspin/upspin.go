@@ -7,6 +7,7 @@ package upspin // import "upspin.io/upspin"
 import (
 	"crypto/elliptic"
 	"errors"
+	"io"
 	"math/big"
 	"time"
 )
@@ -41,6 +42,12 @@ const (
 	// (Although called remote, the service may be running on the same machine.)
 	// The Endpoint's NetAddr contains the HTTP address of the server.
 	Remote
+
+	// HTTPS denotes a read-only store, such as a content delivery
+	// network, from which data is fetched by plain HTTPS requests.
+	// The Endpoint's NetAddr is a base URL; the data for a reference
+	// is at the base URL followed by a slash and the reference.
+	HTTPS
 )
 
 // A Location identifies where a piece of data is stored and how to retrieve it.
@@ -95,8 +102,29 @@ var (
 	// requests. The response from such a request is a JSON-encoded
 	// ListRefsResponse.
 	ListRefsMetadata Reference = "metadata:ListRefs:"
+
+	// PinMetadata is used by clients of a caching StoreServer to keep the
+	// blocks of a file they are reading from being evicted before they
+	// are read. Callers append a JSON-encoded list of the references to
+	// pin. The response is a token that, appended to UnpinMetadata,
+	// releases the pins once the blocks have been read. Pins that are
+	// never released expire after a time chosen by the server.
+	PinMetadata Reference = "metadata:Pin:"
+
+	// UnpinMetadata is used to release the pins made by a request for
+	// PinMetadata. Callers append the token returned by that request.
+	UnpinMetadata Reference = "metadata:Unpin:"
 )
 
+// ZeroReference is a reserved reference recorded in the Location of a
+// block whose cleartext is entirely zero bytes, such as a block of a
+// sparse file. Such a block is never stored: clients reading it produce
+// Size zero bytes without contacting the StoreServer, after checking
+// with the packing's ZeroBlockUnpacker that the block's signed checksum
+// is that of zero bytes. StoreServers never return this reference from
+// Put and need not support it in Get.
+const ZeroReference Reference = "zero"
+
 // ListRefsResponse describes a response from a StoreServer.Get
 // call for ListRefsMetadata.
 type ListRefsResponse struct {
@@ -124,6 +152,7 @@ type Signature struct {
 // DEHash is a hash of the Name, Link, Attribute, Packing, and Time
 // fields of a DirEntry. When using EE or EEIntegrity the hash also includes the
 // block checksums; when using EE it includes the block encryption key.
+// If the entry has Attributes, the packers include them in the checksum.
 type DEHash []byte
 
 // Factotum implements an agent, potentially remote, to handle private key operations.
@@ -152,6 +181,8 @@ type Factotum interface {
 	HKDF(salt, info, out []byte) error
 
 	// Pop derives a Factotum that defaults to the previous key.
+	// Repeated calls walk back through older keys; popping the
+	// oldest key returns a Factotum with that same key.
 	Pop() Factotum
 
 	// PublicKey returns the user's public key in canonical string format.
@@ -206,6 +237,18 @@ type BlockUnpacker interface {
 	Close() error
 }
 
+// ZeroBlockUnpacker is implemented by BlockUnpackers that can unpack a
+// block stored with ZeroReference, whose ciphertext is not fetched.
+type ZeroBlockUnpacker interface {
+	// UnpackZero returns the cleartext of the current block, Size zero
+	// bytes, after verifying against the block's Packdata that the
+	// block was packed from such cleartext.
+	//
+	// The cleartext slice remains valid until the next call to Unpack
+	// or UnpackZero.
+	UnpackZero() (cleartext []byte, err error)
+}
+
 // Packer provides the implementation of a Packing. The pack package binds
 // Packing values to the concrete implementations of this interface.
 type Packer interface {
@@ -267,7 +310,9 @@ type Packer interface {
 	// is in the process of switching to a new key. It checks that
 	// the first existing signature verifies under the old key, copies
 	// that one over the second existing signature, and creates a new
-	// first signature using the key from factotum.
+	// first signature using the key from factotum. If the first
+	// signature already verifies under the key from factotum, the
+	// entry has been countersigned and is left unchanged.
 	Countersign(oldKey PublicKey, f Factotum, d *DirEntry) error
 
 	// UnpackableByAll reports whether the packed data may be unpacked by
@@ -329,6 +374,13 @@ type User struct {
 
 	// PublicKey is the user's current public key.
 	PublicKey PublicKey
+
+	// Revoked lists public keys the user, or an administrator of the
+	// user's domain, has revoked. Servers must not accept a revoked key
+	// for authentication.
+	// It is omitted from JSON when empty so existing encoded
+	// records, such as the key server's log, are unchanged.
+	Revoked []PublicKey `json:",omitempty"`
 }
 
 // The KeyServer interface provides access to public information about users.
@@ -346,6 +398,77 @@ type KeyServer interface {
 	Put(user *User) error
 }
 
+// KeyRevoker is implemented by KeyServers that can record that a
+// user's key has been revoked. See User.Revoked.
+type KeyRevoker interface {
+	// RevokeKey marks the user's public key as revoked, so that it can
+	// no longer be used for authentication. The revokeSignature must be
+	// a signature over "revoke:" followed by the public key, made either
+	// by the key being revoked, which must be the user's current or an
+	// earlier key, or by the authenticated caller if that caller
+	// administers the user's domain.
+	// See factotum.RevokeHash.
+	RevokeKey(userName UserName, publicKey PublicKey, revokeSignature []byte) error
+}
+
+// KeyHistorian is implemented by KeyServers that keep every version of
+// each user's record.
+type KeyHistorian interface {
+	// LookupAt returns the public information about a user as it was
+	// at the given time, such as the key that was then current.
+	// It returns an error of kind NotExist if the user had no record
+	// then or the server kept no history of it.
+	LookupAt(userName UserName, t time.Time) (*User, error)
+
+	// History returns every version of the user's record the server
+	// has kept, oldest first, the last being the current one.
+	// The caller must be an administrator of the key server.
+	History(userName UserName) ([]UserVersion, error)
+}
+
+// UserVersion is a version of a user's record, as returned by
+// KeyHistorian.History.
+type UserVersion struct {
+	User User
+
+	// ValidFrom is when the version was stored. It is zero if the
+	// version was stored before the server began to keep history.
+	ValidFrom time.Time
+
+	// ValidUntil is when the version was replaced. It is zero for the
+	// current version.
+	ValidUntil time.Time
+}
+
+// KeyDomainTransferrer is implemented by KeyServers that can move the
+// records of all the users of a domain to another key server.
+type KeyDomainTransferrer interface {
+	// TransferDomain copies the record of every user of the domain to
+	// the key server at dest, using its ImportDomain method, and then
+	// records that the domain has moved. From then on, the server
+	// answers a request about a user of the domain with an error whose
+	// text holds KeyMovedPrefix followed by dest, and clients that find
+	// it ask dest instead.
+	// The authenticated caller must administer the domain, as shown by
+	// the domain's DNS TXT record (see cmd/upspin setupdomain), and the
+	// adminSignature must be the caller's signature of
+	// factotum.TransferHash(domain, dest).
+	TransferDomain(domain string, dest NetAddr, adminSignature []byte) error
+
+	// ImportDomain stores the records of the users of the domain, as
+	// sent by TransferDomain on behalf of admin, who must administer
+	// the domain as shown by its DNS TXT record. The adminSignature is
+	// the one given to TransferDomain and dest is the address to which
+	// it was given, the address of this server.
+	// Existing records of the users are replaced.
+	ImportDomain(domain string, dest NetAddr, admin *User, adminSignature []byte, users []*User) error
+}
+
+// KeyMovedPrefix begins the text that, followed by the network address
+// of the new key server, is in the error returned by a key server for a
+// user whose domain has been transferred. See KeyDomainTransferrer.
+const KeyMovedPrefix = "domain moved to key server "
+
 // A PublicKey can be seen by anyone and is used for authenticating a user.
 type PublicKey string
 
@@ -532,6 +655,175 @@ type DirServer interface {
 	Watch(name PathName, sequence int64, done <-chan struct{}) (<-chan Event, error)
 }
 
+// UncheckedLinkPutter is implemented by DirServers that can store a link
+// without checking its target. A DirServer's Put may reject a link whose
+// target is invalid or does not exist with an error of kind BrokenLink;
+// PutUncheckedLink is used to create such a link deliberately, as by
+// "upspin link -f". For entries that are not links it behaves like Put.
+type UncheckedLinkPutter interface {
+	PutUncheckedLink(entry *DirEntry) (*DirEntry, error)
+}
+
+// MultiWatcher is implemented by DirServers that can watch several paths
+// on a single stream, saving clients that watch many directories a
+// connection and a server goroutine for each.
+type MultiWatcher interface {
+	// WatchMulti is like calling Watch for each of the paths, but the
+	// events for all of them are delivered on the one channel. Each
+	// event's Root field holds the Name of the WatchPath to which it
+	// pertains, and each path's Sequence is interpreted independently,
+	// as in Watch.
+	//
+	// An error affecting a single path is delivered as an event tagged
+	// with that path and ends the events for that path only. The
+	// channel is closed when the events for all paths have ended or
+	// when done is closed.
+	WatchMulti(paths []WatchPath, done <-chan struct{}) (<-chan Event, error)
+}
+
+// WatchPath is a path to be watched by MultiWatcher.WatchMulti,
+// with the sequence number from which to watch it.
+type WatchPath struct {
+	Name     PathName
+	Sequence int64
+}
+
+// DirBatcher is implemented by DirServers that can apply several changes
+// to a user's tree as a single atomic operation.
+type DirBatcher interface {
+	// ApplyBatch applies the changes, in order, to the tree of a single
+	// user. Other clients observe either none of the changes or all of
+	// them; if any change cannot be made, none is. Each change is
+	// subject to the same checks as the corresponding Put or Delete,
+	// made against the tree as it was before the batch, except that
+	// names may not cross links and link targets are not checked.
+	// At most MaxBatchChanges changes may be applied at once.
+	//
+	// ApplyBatch returns, for each change, the entry that Put or Delete
+	// would have returned.
+	ApplyBatch(changes []DirChange) ([]*DirEntry, error)
+}
+
+// MaxBatchChanges is the maximum number of changes that may be passed
+// to DirBatcher.ApplyBatch.
+const MaxBatchChanges = 10000
+
+// DirChange is one change in a batch applied by DirBatcher.ApplyBatch.
+type DirChange struct {
+	// Delete is true if the entry named by Entry.Name is to be deleted,
+	// in which case no other fields of Entry are used.
+	// Otherwise Entry is put.
+	Delete bool
+
+	// Entry is the DirEntry to put or, for a delete, to name.
+	Entry *DirEntry
+}
+
+// DirTransactor is implemented by DirServers that can take part in a
+// transaction whose changes span the trees of several users, possibly on
+// several DirServers. The client coordinates the transaction using
+// two-phase commit: it prepares the changes to each tree with PreparePut
+// and then, if every server agreed, calls CommitTx on each of them, or
+// otherwise AbortTx.
+type DirTransactor interface {
+	// PreparePut checks the changes, which must all be to the tree of
+	// a single user, as ApplyBatch would and, if they could be made,
+	// records them as the transaction txID and returns nil.
+	// Until the transaction is committed or aborted, other changes to
+	// the names it changes fail with an error of kind Transient, so
+	// that CommitTx can apply the changes as checked.
+	// A transaction that is neither committed nor aborted within
+	// TxTimeout is aborted by the server.
+	//
+	// The txID is chosen by the caller and must be a string of at most
+	// 64 letters, digits, hyphens and underscores. It need be unique
+	// only among the caller's transactions in progress.
+	PreparePut(txID string, changes []DirChange) error
+
+	// CommitTx applies the changes of the prepared transaction txID,
+	// atomically, and returns for each change the entry that ApplyBatch
+	// would have returned. Only the user who prepared the transaction
+	// may commit it. If the changes cannot be applied, the transaction
+	// remains prepared and CommitTx may be retried.
+	CommitTx(txID string) ([]*DirEntry, error)
+
+	// AbortTx discards the prepared transaction txID. Only the user who
+	// prepared the transaction may abort it. It is not an error to
+	// abort a transaction that is unknown or was already aborted.
+	AbortTx(txID string) error
+}
+
+// TxTimeout is how long a DirTransactor keeps a prepared transaction
+// that is neither committed nor aborted.
+const TxTimeout = 5 * time.Minute
+
+// WhichAccessBatcher is implemented by DirServers that can report the
+// Access files controlling many names in one request.
+type WhichAccessBatcher interface {
+	// WhichAccessBatch returns, for each name, the entry and error that
+	// WhichAccess would return for it, subject to the same access checks.
+	// Names controlled by the same Access file share the same DirEntry.
+	// At most MaxWhichAccessBatch names may be passed at once.
+	// The error is non-nil only if the request as a whole fails.
+	WhichAccessBatch(names []PathName) ([]WhichAccessResult, error)
+}
+
+// MaxWhichAccessBatch is the maximum number of names that may be passed
+// to WhichAccessBatcher.WhichAccessBatch.
+const MaxWhichAccessBatch = 10000
+
+// WhichAccessResult is the result of WhichAccess for one of the names
+// passed to WhichAccessBatcher.WhichAccessBatch.
+type WhichAccessResult struct {
+	Entry *DirEntry
+	Error error
+}
+
+// ShallowGlobber is implemented by DirServers and Clients that can return
+// the entries matched by a pattern without their Blocks and Packdata, for
+// callers such as directory listings that need only names and attributes.
+type ShallowGlobber interface {
+	// GlobShallow is like Glob, but every returned DirEntry is
+	// incomplete (see the description of AttrIncomplete), which
+	// makes the reply much smaller for files of many blocks.
+	GlobShallow(pattern string) ([]*DirEntry, error)
+}
+
+// AccessHistorian is implemented by DirServers that keep a log of the
+// requests made to them, including those that only read.
+type AccessHistorian interface {
+	// AccessHistory returns the records of requests made at or after
+	// since that concern name or, if name is a directory, anything
+	// beneath it, oldest first. At most limit records are returned;
+	// if limit is not positive, or is more than MaxAccessRecords,
+	// MaxAccessRecords is used. Only the owner of the tree holding name
+	// and the directory server's own user may see its history.
+	AccessHistory(name PathName, since time.Time, limit int) ([]AccessRecord, error)
+}
+
+// MaxAccessRecords is the maximum number of records returned by one call
+// to AccessHistorian.AccessHistory.
+const MaxAccessRecords = 10000
+
+// AccessRecord records a request made to a DirServer, as returned by
+// AccessHistorian.AccessHistory.
+type AccessRecord struct {
+	// Time is when the request was made.
+	Time time.Time
+
+	// User is the user who made the request.
+	User UserName
+
+	// Op is the name of the DirServer method called, such as "Lookup".
+	Op string
+
+	// Name is the path name, or for Glob the pattern, of the request.
+	Name PathName
+
+	// Success reports whether the request succeeded.
+	Success bool
+}
+
 // Event represents the creation, modification, or deletion of a DirEntry
 // within a DirServer.
 type Event struct {
@@ -544,8 +836,12 @@ type Event struct {
 	Delete bool
 
 	// Error is non-nil if an error occurred while waiting for events.
-	// In that case, all other fields are zero.
+	// In that case, all other fields but Root are zero.
 	Error error
+
+	// Root is the name of the watched path to which the event pertains.
+	// It is set only for events delivered by MultiWatcher.WatchMulti.
+	Root PathName
 }
 
 // Time represents a timestamp in units of seconds since
@@ -566,6 +862,18 @@ type DirEntry struct {
 	Blocks     []DirBlock // Descriptors for each block. A nil or empty slice represents an empty file.
 	Packdata   []byte     // Information maintained by the packing algorithm.
 
+	// Attributes holds user-defined metadata, such as a content type,
+	// keyed by name. The total length of the names and values may not
+	// exceed MaxAttributesSize. Like Blocks and Packdata, Attributes
+	// are elided from incomplete entries.
+	//
+	// Their presence is marked in the marshaled entry by the bit 0x80
+	// of the Attr byte. Clients that predate Attributes take that bit
+	// to be part of Attr and ignore the attributes, so they cannot
+	// verify the signature of an entry that has them; entries without
+	// Attributes marshal as they always have.
+	Attributes map[string][]byte
+
 	// Field determining the key used for the signature, hence also tamper-resistant.
 	Writer UserName // Writer of the file, often the same as owner.
 
@@ -585,6 +893,10 @@ const BlockSize = 1024 * 1024
 // guarantees that 32-bit machines can process the data without problems.
 const MaxBlockSize = 1024 * 1024 * 1024
 
+// MaxAttributesSize is the maximum total length of the names and values
+// of the Attributes of a DirEntry.
+const MaxAttributesSize = 4096
+
 // DirBlock describes a block of data representing a contiguous section of a file.
 // The block may be of any non-negative size, but in large files is usually
 // BlockSize long.
@@ -672,6 +984,27 @@ type StoreServer interface {
 	Delete(ref Reference) error
 }
 
+// StoreMetaPutter is implemented by StoreServers that can record on whose
+// behalf data is stored, for instance to keep each user's blocks apart.
+type StoreMetaPutter interface {
+	// PutWithMeta is like Put but also names the user who is storing
+	// the data, that is, the Writer of the DirEntry that will refer to
+	// it. The reference returned is the same as Put would return.
+	PutWithMeta(data []byte, writer UserName) (*Refdata, error)
+}
+
+// StoreStreamGetter is implemented by StoreServers that can deliver data
+// without holding all of it in memory at once.
+type StoreStreamGetter interface {
+	// GetStream is like Get but returns the data as a stream of bytes,
+	// read directly from the store's backing storage where possible.
+	// When Get would return data, the ReadCloser is non-nil and the
+	// caller must close it. Otherwise it is nil and the Locations and
+	// error are as Get would return them. An error reading the stream
+	// means the data is incomplete and must not be used.
+	GetStream(ref Reference) (io.ReadCloser, *Refdata, []Location, error)
+}
+
 // Client API.
 
 // The Client interface provides a higher-level API suitable for applications
@@ -705,9 +1038,9 @@ type Client interface {
 	// the documentation for Delete.) Like Get, it is not the usual
 	// access method. The file-like API is preferred.
 	//
-	// A successful Put returns an incomplete DirEntry (see the
-	// description of AttrIncomplete) containing only the
-	// new sequence number.
+	// A successful Put returns the DirEntry as stored, holding the
+	// sequence number assigned by the DirServer, which a later Lookup
+	// will also report until the item changes again.
 	Put(name PathName, data []byte) (*DirEntry, error)
 
 	// PutSequenced stores the data at the given name only if
@@ -719,9 +1052,12 @@ type Client interface {
 	// the documentation for Delete.) Like Get, it is not the usual
 	// access method. The file-like API is preferred.
 	//
-	// A successful PutSequenced returns an incomplete DirEntry (see the
-	// description of AttrIncomplete) containing only the
-	// new sequence number.
+	// A successful PutSequenced returns the DirEntry as Put does.
+	// Because a PutSequenced whose sequence number matches succeeds
+	// only once, PutSequenced can be used for optimistic concurrency:
+	// of several clients writing with the same sequence number, one
+	// succeeds and the others fail. Package client reports such
+	// failures with an error wrapping client.ErrSequenceConflict.
 	PutSequenced(name PathName, seq int64, data []byte) (*DirEntry, error)
 
 	// PutLink creates a link from the new name to the old name. The
@@ -734,9 +1070,8 @@ type Client interface {
 	// contains links. The name is canonicalized, however (see
 	// path.Clean).
 	//
-	// A successful PutLink returns an incomplete DirEntry (see the
-	// description of AttrIncomplete) containing only the
-	// new sequence number.
+	// A successful PutLink returns the DirEntry for the link as
+	// stored, holding the sequence number assigned by the DirServer.
 	PutLink(oldName, newName PathName) (*DirEntry, error)
 
 	// PutDuplicate creates a new name for the references referred to
@@ -755,9 +1090,10 @@ type Client interface {
 	// must not already exist. All but the last element of the path
 	// name must already exist and be directories.
 	//
-	// A successful MakeDirectory returns an incomplete DirEntry (see the
-	// description of AttrIncomplete) containing only the
-	// new sequence number.
+	// A successful MakeDirectory returns the DirEntry for the new
+	// directory, holding the sequence number assigned by the DirServer.
+	// A directory's Writer and Time are maintained by the DirServer
+	// as the directory changes, and are reported only by Lookup.
 	MakeDirectory(dirName PathName) (*DirEntry, error)
 
 	// Rename renames oldName to newName. The old name is no longer valid.
@@ -867,6 +1203,16 @@ type Config interface {
 	Value(key string) string
 }
 
+// DirReplicaConfig is implemented by Configs that list read-only
+// replicas of the user's DirServer. Clients may read the user's own tree
+// from whichever replica is nearest; all other requests go to the
+// DirServer at DirEndpoint.
+type DirReplicaConfig interface {
+	// DirEndpoints lists the endpoints of all replicas of the
+	// DirServer. The first element is the same as DirEndpoint.
+	DirEndpoints() []Endpoint
+}
+
 // Dialer defines how to connect and authenticate to a server. Each
 // service type (KeyServer, DirServer, StoreServer) implements the methods of
 // the Dialer interface. These methods are not used directly by
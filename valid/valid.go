@@ -8,6 +8,7 @@
 package valid // import "upspin.io/valid"
 
 import (
+	"net/url"
 	"strconv"
 
 	"upspin.io/access"
@@ -102,6 +103,11 @@ func Endpoint(endpoint upspin.Endpoint) error {
 		if endpoint.NetAddr == "" {
 			return errors.E(op, errors.Invalid, errors.Errorf("%q: missing network address", endpoint))
 		}
+	case upspin.HTTPS:
+		u, err := url.Parse(string(endpoint.NetAddr))
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			return errors.E(op, errors.Invalid, errors.Errorf("%q: base must be an https URL", endpoint))
+		}
 	default:
 		return errors.E(op, errors.Invalid, errors.Errorf("%d unrecognized transport", endpoint.Transport))
 	}
@@ -171,6 +171,21 @@ func TestEndpoint(t *testing.T) {
 		t.Fatal("no error for bad transport")
 	}
 	restore()
+	// An https endpoint needs an https URL.
+	endpoint = upspin.Endpoint{
+		Transport: upspin.HTTPS,
+		NetAddr:   "https://cdn.example.com/upspin",
+	}
+	if err := Endpoint(endpoint); err != nil {
+		t.Fatalf("expected no error for https endpoint; got %q", err)
+	}
+	for _, addr := range []upspin.NetAddr{"", "cdn.example.com", "http://cdn.example.com", "https:///path"} {
+		endpoint.NetAddr = addr
+		if err := Endpoint(endpoint); err == nil {
+			t.Fatalf("no error for https endpoint with base %q", addr)
+		}
+	}
+	restore()
 	// One last check for network address for unassigned.
 	endpoint.Transport = upspin.Unassigned
 	if err := Endpoint(endpoint); err == nil {